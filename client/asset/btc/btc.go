@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,7 +23,6 @@ import (
 
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/dex"
-	"decred.org/dcrdex/dex/calc"
 	"decred.org/dcrdex/dex/config"
 	dexbtc "decred.org/dcrdex/dex/networks/btc"
 	"github.com/btcsuite/btcd/btcjson"
@@ -43,6 +43,7 @@ const (
 	methodGetBlockVerboseTx = "getblock"
 	methodGetNetworkInfo    = "getnetworkinfo"
 	BipID                   = 0
+	redeemFeeLimitKey       = "redeemfeelimit"
 	// The default fee is passed to the user as part of the asset.WalletInfo
 	// structure.
 	defaultFee         = 112
@@ -53,14 +54,27 @@ const (
 var (
 	// blockTicker is the delay between calls to check for new blocks.
 	blockTicker = time.Second
+	// walletCheckInterval is the delay between checks that the coins funding
+	// orders are still locked by the wallet, which they won't be if the wallet
+	// process was restarted.
+	walletCheckInterval = 30 * time.Second
 	// walletInfo defines some general information about a Bitcoin wallet.
 	walletInfo = &asset.WalletInfo{
 		Name:              "Bitcoin",
 		Units:             "Satoshis",
 		DefaultConfigPath: dexbtc.SystemConfigPath("bitcoin"),
-		ConfigOpts:        config.Options(&dexbtc.Config{}),
+		ConfigOpts:        append(append(append(append(config.Options(&dexbtc.Config{}), RedeemFeeLimitOption), SPVOptions...), ElectrumOptions...), DeviceOption),
 		DefaultFeeRate:    defaultFee,
 	}
+	// RedeemFeeLimitOption is the wallet setting for the highest effective fee
+	// rate, in atoms/byte, that an unconfirmed redemption will be bumped to.
+	// Redemptions are not bumped if the limit is not set. A BTC clone can append
+	// RedeemFeeLimitOption to its WalletInfo.ConfigOpts.
+	RedeemFeeLimitOption = &config.Option{
+		Key:         redeemFeeLimitKey,
+		DisplayName: "Redemption Fee Limit",
+		Description: "The highest fee rate, in atoms/byte, to bump an unconfirmed redemption to (0 to disable)",
+	}
 )
 
 // rpcClient is a wallet RPC client. In production, rpcClient is satisfied by
@@ -242,6 +256,7 @@ func init() {
 type ExchangeWallet struct {
 	client            *rpcclient.Client
 	node              rpcClient
+	spv               *spvWallet
 	wallet            *walletClient
 	walletInfo        *asset.WalletInfo
 	chainParams       *chaincfg.Params
@@ -262,11 +277,51 @@ type ExchangeWallet struct {
 	// shutdown.
 	fundingMtx   sync.RWMutex
 	fundingCoins map[string]*compositeUTXO
+
+	// Redemptions are tracked so that their fees can be bumped with a CPFP
+	// transaction if they linger unconfirmed. They are deleted once confirmed.
+	redeemFeeLimit uint64
+	redeemMtx      sync.Mutex
+	redemptions    map[chainhash.Hash]*redeemPackage
+}
+
+// redeemPackage is a redemption transaction and any CPFP children.
+type redeemPackage struct {
+	// size is the total serialized size of the transactions.
+	size uint64
+	// fees is the total fees paid by the transactions.
+	fees uint64
+	// tip is the output of the last transaction in the chain.
+	tip *output
 }
 
 // Check that ExchangeWallet satisfies the Wallet interface.
 var _ asset.Wallet = (*ExchangeWallet)(nil)
 
+// Check that ExchangeWallet satisfies the BatchWithdrawer interface.
+var _ asset.BatchWithdrawer = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the CoinManager interface.
+var _ asset.CoinManager = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the CoinSelector interface.
+var _ asset.CoinSelector = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the CoinLister interface.
+var _ asset.CoinLister = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the FeeBumper interface.
+var _ asset.FeeBumper = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the RedeemEstimator interface.
+var _ asset.RedeemEstimator = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the Rescanner interface.
+var _ asset.Rescanner = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the DepositTracker interface.
+var _ asset.DepositTracker = (*ExchangeWallet)(nil)
+
 // NewWallet is the exported constructor by which the DEX will import the
 // exchange wallet. The wallet will shut down when the provided context is
 // canceled. The configPath can be an empty string, in which case the standard
@@ -299,31 +354,90 @@ func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network)
 // BTCCloneWallet creates a wallet backend for a set of network parameters and
 // default network ports. A BTC clone can use this method, possibly in
 // conjunction with ReadCloneParams, to create a ExchangeWallet for other assets
-// with minimal coding.
+// with minimal coding. If the spv setting is enabled, the built-in SPV wallet
+// is used instead of an RPC wallet. If an electrum server is set, the wallet
+// syncs with the Electrum server instead.
 func BTCCloneWallet(cfg *BTCCloneCFG) (*ExchangeWallet, error) {
-
-	// Read the configuration parameters
-	btcCfg, err := dexbtc.LoadConfigFromSettings(cfg.WalletCFG.Settings, cfg.Symbol, cfg.Network, cfg.Ports)
+	spvCfg, err := loadSPVConfig(cfg.WalletCFG.Settings, cfg.Symbol, cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	electrumCfg, err := loadElectrumConfig(cfg.WalletCFG.Settings, cfg.Symbol, cfg.ChainParams)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint := btcCfg.RPCBind + "/wallet/" + cfg.WalletCFG.Account
-	cfg.Logger.Infof("Setting up new %s wallet at %s.", cfg.Symbol, endpoint)
+	var btc *ExchangeWallet
+	switch {
+	case spvCfg != nil && electrumCfg != nil:
+		return nil, fmt.Errorf("the %s and %s settings cannot be used together", spvKey, electrumKey)
+	case spvCfg != nil:
+		cfg.Logger.Infof("Setting up new %s SPV wallet in %s.", cfg.Symbol, spvCfg.Dir)
+		spv := newSPVWallet(spvCfg, cfg.ChainParams, cfg.Logger)
+		btc = newWallet(cfg, spv)
+		btc.spv = spv
+	case electrumCfg != nil:
+		cfg.Logger.Infof("Setting up new %s Electrum server wallet for %s in %s.", cfg.Symbol,
+			electrumCfg.Server, electrumCfg.Dir)
+		spv, err := newElectrumWallet(electrumCfg, cfg.ChainParams, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		btc = newWallet(cfg, spv)
+		btc.spv = spv
+	default:
+		// Read the configuration parameters
+		btcCfg, err := dexbtc.LoadConfigFromSettings(cfg.WalletCFG.Settings, cfg.Symbol, cfg.Network, cfg.Ports)
+		if err != nil {
+			return nil, err
+		}
 
-	client, err := rpcclient.New(&rpcclient.ConnConfig{
-		HTTPPostMode: true,
-		DisableTLS:   true,
-		Host:         endpoint,
-		User:         btcCfg.RPCUser,
-		Pass:         btcCfg.RPCPass,
-	}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating BTC RPC client: %v", err)
+		endpoint := btcCfg.RPCBind + "/wallet/" + cfg.WalletCFG.Account
+		cfg.Logger.Infof("Setting up new %s wallet at %s.", cfg.Symbol, endpoint)
+
+		connCfg := &rpcclient.ConnConfig{
+			HTTPPostMode: true,
+			DisableTLS:   true,
+			Host:         endpoint,
+			User:         btcCfg.RPCUser,
+			Pass:         btcCfg.RPCPass,
+		}
+		if btcCfg.Proxy != "" {
+			// The HTTP POST client takes the proxy as a URL.
+			proxyURL := &url.URL{Scheme: "socks5", Host: btcCfg.Proxy}
+			if btcCfg.ProxyUser != "" {
+				proxyURL.User = url.UserPassword(btcCfg.ProxyUser, btcCfg.ProxyPass)
+			}
+			connCfg.Proxy = proxyURL.String()
+		}
+		client, err := rpcclient.New(connCfg, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating BTC RPC client: %v", err)
+		}
+
+		btc = newWallet(cfg, client)
+		btc.client = client
+	}
+
+	if btc.spv != nil {
+		// The seed is cleared once the keystore is created.
+		btc.spv.seed = append([]byte(nil), cfg.WalletCFG.Seed...)
 	}
 
-	btc := newWallet(cfg, client)
-	btc.client = client
+	if device := cfg.WalletCFG.Settings[deviceKey]; device != "" {
+		if btc.spv == nil {
+			return nil, fmt.Errorf("the %s setting requires the built-in wallet", deviceKey)
+		}
+		assetID, _ := dex.BipSymbolID(cfg.Symbol)
+		btc.spv.setDevice(assetID, device, cfg.WalletCFG.Settings, cfg.WalletCFG.DevicePrompt)
+	}
+
+	if limit, found := cfg.WalletCFG.Settings[redeemFeeLimitKey]; found && limit != "" {
+		btc.redeemFeeLimit, err = strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", redeemFeeLimitKey, err)
+		}
+	}
 
 	return btc, nil
 }
@@ -339,6 +453,7 @@ func newWallet(cfg *BTCCloneCFG, node rpcClient) *ExchangeWallet {
 		tradeChange:       make(map[string]time.Time),
 		tipChange:         cfg.WalletCFG.TipChange,
 		fundingCoins:      make(map[string]*compositeUTXO),
+		redemptions:       make(map[chainhash.Hash]*redeemPackage),
 		minNetworkVersion: cfg.MinNetworkVersion,
 		fallbackFeeRate:   cfg.WalletCFG.FallbackFeeRate,
 		walletInfo:        cfg.WalletInfo,
@@ -355,16 +470,25 @@ func (btc *ExchangeWallet) Info() *asset.WalletInfo {
 // Connect connects the wallet to the RPC server. Satisfies the dex.Connector
 // interface.
 func (btc *ExchangeWallet) Connect(ctx context.Context) (*sync.WaitGroup, error) {
-	// Check the version. Do it here, so we can also diagnose a bad connection.
-	netVer, codeVer, err := btc.getVersion()
-	if err != nil {
-		return nil, fmt.Errorf("error getting version: %v", err)
-	}
-	if netVer < btc.minNetworkVersion {
-		return nil, fmt.Errorf("reported node version %d is less than minimum %d", netVer, minNetworkVersion)
-	}
-	if codeVer < minProtocolVersion {
-		return nil, fmt.Errorf("node software out of date. version %d is less than minimum %d", codeVer, minProtocolVersion)
+	var spvWG *sync.WaitGroup
+	if btc.spv != nil {
+		var err error
+		spvWG, err = btc.spv.connect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error starting built-in wallet: %v", err)
+		}
+	} else {
+		// Check the version. Do it here, so we can also diagnose a bad connection.
+		netVer, codeVer, err := btc.getVersion()
+		if err != nil {
+			return nil, fmt.Errorf("error getting version: %v", err)
+		}
+		if netVer < btc.minNetworkVersion {
+			return nil, fmt.Errorf("reported node version %d is less than minimum %d", netVer, minNetworkVersion)
+		}
+		if codeVer < minProtocolVersion {
+			return nil, fmt.Errorf("node software out of date. version %d is less than minimum %d", codeVer, minProtocolVersion)
+		}
 	}
 	// If this is the first time connecting, clear the locked coins. This should
 	// have been done at shutdown, but shutdown may not have been clean.
@@ -383,6 +507,9 @@ func (btc *ExchangeWallet) Connect(ctx context.Context) (*sync.WaitGroup, error)
 		if err != nil {
 			btc.log.Errorf("failed to unlock %s outputs on shutdown: %v", btc.symbol, err)
 		}
+		if spvWG != nil {
+			spvWG.Wait()
+		}
 	}()
 	return &wg, nil
 }
@@ -408,7 +535,13 @@ func (btc *ExchangeWallet) Balance() (*asset.Balance, error) {
 
 // FeeRate returns the current optimal fee rate in sat / byte.
 func (btc *ExchangeWallet) FeeRate() (uint64, error) {
-	feeResult, err := btc.node.EstimateSmartFee(1, &btcjson.EstimateModeConservative)
+	return btc.estimateFeeRate(1)
+}
+
+// estimateFeeRate returns the optimal fee rate in sat / byte for confirmation
+// within confTarget blocks.
+func (btc *ExchangeWallet) estimateFeeRate(confTarget int64) (uint64, error) {
+	feeResult, err := btc.node.EstimateSmartFee(confTarget, &btcjson.EstimateModeConservative)
 	if err != nil {
 		return 0, err
 	}
@@ -442,62 +575,42 @@ func (btc *ExchangeWallet) feeRateWithFallback() uint64 {
 // FundOrder selects utxos (as asset.Coin) for use in an order. Any Coins
 // returned will be locked. Part of the asset.Wallet interface.
 func (btc *ExchangeWallet) FundOrder(value uint64, nfo *dex.Asset) (asset.Coins, error) {
+	return btc.FundOrderWith(value, nfo, new(asset.FundingOptions))
+}
+
+// FundOrderWith selects utxos (as asset.Coin) for use in an order according to
+// the funding options. Any Coins returned will be locked. Part of the
+// asset.CoinSelector interface.
+func (btc *ExchangeWallet) FundOrderWith(value uint64, nfo *dex.Asset, opts *asset.FundingOptions) (asset.Coins, error) {
 	if value == 0 {
 		return nil, fmt.Errorf("cannot fund value = 0")
 	}
 	// Now that we allow funding with 0 conf UTXOs, some more logic could be
 	// used out of caution, including preference for >0 confs.
-	utxos, _, avail, err := btc.spendableUTXOs(0)
+	utxos, utxoMap, avail, err := btc.spendableUTXOs(0)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing unspent outputs: %v", err)
 	}
 	if len(utxos) == 0 {
 		return nil, fmt.Errorf("insufficient funds. %.8f available", float64(avail)/1e8)
 	}
-	var sum uint64
-	var size uint32
-	var coins asset.Coins
-	var spents []*output
-	fundingCoins := make(map[string]*compositeUTXO)
 
 	// TODO: For the chained swaps, make sure that contract outputs are P2WSH,
 	// and that change outputs that fund further swaps are P2WPKH.
 
-	isEnoughWith := func(unspent *compositeUTXO) bool {
-		reqFunds := calc.RequiredOrderFunds(value, uint64(size+unspent.input.VBytes()), nfo)
-		return sum+unspent.amount >= reqFunds
+	selected, err := selectCoins(value, nfo, utxos, utxoMap, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	addUTXO := func(unspent *compositeUTXO) {
-		v := unspent.amount
-		op := newOutput(btc.node, unspent.txHash, unspent.vout, v, unspent.redeemScript)
+	coins := make(asset.Coins, 0, len(selected))
+	spents := make([]*output, 0, len(selected))
+	fundingCoins := make(map[string]*compositeUTXO, len(selected))
+	for _, unspent := range selected {
+		op := newOutput(btc.node, unspent.txHash, unspent.vout, unspent.amount, unspent.redeemScript)
 		coins = append(coins, op)
 		spents = append(spents, op)
-		size += unspent.input.VBytes()
 		fundingCoins[op.String()] = unspent
-		sum += v
-	}
-
-out:
-	for {
-		// If there are none left, we don't have enough.
-		if len(utxos) == 0 {
-			return nil, fmt.Errorf("not enough to cover requested funds + fees = %d",
-				calc.RequiredOrderFunds(value, uint64(size), nfo))
-		}
-		// On each loop, find the smallest UTXO that is enough for the value. If
-		// no UTXO is large enough, add the largest and continue.
-		var txout *compositeUTXO
-		for _, txout = range utxos {
-			if isEnoughWith(txout) {
-				addUTXO(txout)
-				break out
-			}
-		}
-		// Append the last output, which is the largest.
-		addUTXO(txout)
-		// Pop the utxo from the unspents
-		utxos = utxos[:len(utxos)-1]
 	}
 
 	err = btc.wallet.LockUnspent(false, spents)
@@ -698,6 +811,16 @@ func (btc *ExchangeWallet) Swap(swaps *asset.Swaps) ([]asset.Receipt, asset.Coin
 	return receipts, change, nil
 }
 
+// RedeemFee estimates the fee rate and the fee of a transaction that redeems a
+// single swap contract to a P2WPKH output. Part of the asset.RedeemEstimator
+// interface.
+func (btc *ExchangeWallet) RedeemFee() (feeRate, fee uint64) {
+	const size = dexbtc.MimimumTxOverhead + dexbtc.TxInOverhead + 1 +
+		dexbtc.RedeemSwapSigScriptSize + dexbtc.P2WPKHOutputSize
+	feeRate = btc.feeRateWithFallback()
+	return feeRate, feeRate * size
+}
+
 // Redeem sends the redemption transaction, completing the atomic swap.
 func (btc *ExchangeWallet) Redeem(redemptions []*asset.Redemption) ([]dex.Bytes, asset.Coin, error) {
 	// Create a transaction that spends the referenced contract.
@@ -782,7 +905,95 @@ func (btc *ExchangeWallet) Redeem(redemptions []*asset.Redemption) ([]dex.Bytes,
 	for i := range redemptions {
 		coinIDs = append(coinIDs, toCoinID(txHash, uint32(i)))
 	}
-	return coinIDs, newOutput(btc.node, txHash, 0, uint64(txOut.Value), nil), nil
+	redeemOutput := newOutput(btc.node, txHash, 0, uint64(txOut.Value), nil)
+	btc.redeemMtx.Lock()
+	btc.redemptions[*txHash] = &redeemPackage{
+		size: uint64(msgTx.SerializeSize()),
+		fees: fee,
+		tip:  redeemOutput,
+	}
+	btc.redeemMtx.Unlock()
+	return coinIDs, redeemOutput, nil
+}
+
+// BumpRedemptionFee raises the effective fee rate of an unconfirmed redemption
+// by spending its output in a child transaction that pays the difference (CPFP).
+// The redemption transaction itself is not replaceable. A redemption that has
+// already been bumped is bumped again by extending the chain of children.
+// Redemptions sent before the wallet was restarted are not tracked, and cannot
+// be bumped since the fees paid by the transaction and any children are not
+// known. Part of the asset.FeeBumper interface.
+func (btc *ExchangeWallet) BumpRedemptionFee(coinID dex.Bytes) (asset.Coin, uint64, error) {
+	if btc.redeemFeeLimit == 0 {
+		return nil, 0, asset.NoFeeBumpError
+	}
+	txHash, _, err := decodeCoinID(coinID)
+	if err != nil {
+		return nil, 0, err
+	}
+	btc.redeemMtx.Lock()
+	defer btc.redeemMtx.Unlock()
+	pkg, found := btc.redemptions[*txHash]
+	if !found {
+		btc.log.Debugf("Not bumping the fee of untracked redemption %s", txHash)
+		return nil, 0, asset.NoFeeBumpError
+	}
+	feeRate, err := btc.FeeRate()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error retrieving fee rate: %v", err)
+	}
+	if feeRate > btc.redeemFeeLimit {
+		feeRate = btc.redeemFeeLimit
+	}
+	if feeRate <= pkg.fees/pkg.size {
+		return nil, 0, asset.NoFeeBumpError
+	}
+
+	// Send the redemption output back to the wallet.
+	addr, err := btc.wallet.ChangeAddress()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting new address from the wallet: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating change script: %v", err)
+	}
+	baseTx := wire.NewMsgTx(wire.TxVersion)
+	baseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&pkg.tip.txHash, pkg.tip.vout), nil, nil))
+	txOut := wire.NewTxOut(int64(pkg.tip.value), pkScript)
+	baseTx.AddTxOut(txOut)
+	// Sign once to get the size. The output value does not change the size.
+	msgTx, err := btc.wallet.SignTx(baseTx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("signing error: %v", err)
+	}
+	size := uint64(msgTx.SerializeSize())
+	fee := feeRate*(pkg.size+size) - pkg.fees
+	if fee >= pkg.tip.value {
+		return nil, 0, fmt.Errorf("redemption output not worth the fee bump")
+	}
+	txOut.Value = int64(pkg.tip.value - fee)
+	if dexbtc.IsDust(txOut, feeRate) {
+		return nil, 0, fmt.Errorf("fee bump output is dust")
+	}
+	msgTx, err = btc.wallet.SignTx(baseTx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("signing error: %v", err)
+	}
+	checkHash := msgTx.TxHash()
+	childHash, err := btc.node.SendRawTransaction(msgTx, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if *childHash != checkHash {
+		return nil, 0, fmt.Errorf("fee bump sent, but received unexpected transaction ID back from RPC server. "+
+			"expected %s, got %s", checkHash, *childHash)
+	}
+	btc.addChange(childHash.String(), 0)
+	pkg.size += uint64(msgTx.SerializeSize())
+	pkg.fees += fee
+	pkg.tip = newOutput(btc.node, childHash, 0, uint64(txOut.Value), nil)
+	return pkg.tip, pkg.fees / pkg.size, nil
 }
 
 // SignMessage signs the message with the private key associated with the
@@ -799,6 +1010,13 @@ func (btc *ExchangeWallet) SignMessage(coin asset.Coin, msg dex.Bytes) (pubkeys,
 	if utxo == nil {
 		return nil, nil, fmt.Errorf("no utxo found for %s", output)
 	}
+	if btc.spv != nil && btc.spv.hw != nil {
+		sig, pubkey, err := btc.spv.hwSignHash(utxo.address, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []dex.Bytes{pubkey}, []dex.Bytes{sig}, nil
+	}
 	privKey, err := btc.wallet.PrivKeyForAddress(utxo.address)
 	if err != nil {
 		return nil, nil, err
@@ -826,6 +1044,18 @@ func (btc *ExchangeWallet) AuditContract(coinID dex.Bytes, contract dex.Bytes) (
 	if err != nil {
 		return nil, fmt.Errorf("error extracting swap addresses: %v", err)
 	}
+	// The SPV wallet only knows about the outputs it has found.
+	if btc.spv != nil {
+		contractAddr, err := btcutil.NewAddressScriptHash(contract, btc.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("error creating contract address: %v", err)
+		}
+		pkScript, _ := txscript.PayToAddrScript(contractAddr)
+		if err := btc.spv.findOutput(txHash, vout, pkScript); err != nil {
+			btc.log.Debugf("Contract output %s:%d not found: %v", txHash, vout, err)
+			return nil, asset.CoinNotFoundError
+		}
+	}
 	// Get the contracts P2SH address from the tx output's pubkey script.
 	txOut, err := btc.node.GetTxOut(txHash, vout, true)
 	if err != nil {
@@ -959,7 +1189,7 @@ func (btc *ExchangeWallet) FindRedemption(ctx context.Context, coinID dex.Bytes)
 	}
 	contractBlock := *blockHash
 	var contractHash []byte
-	blockHeight := tx.BlockIndex
+	var blockHeight int64
 	for {
 		select {
 		case <-ctx.Done():
@@ -970,6 +1200,7 @@ func (btc *ExchangeWallet) FindRedemption(ctx context.Context, coinID dex.Bytes)
 		if err != nil {
 			return nil, fmt.Errorf("error fetching verbose block '%s': %v", blockHash, err)
 		}
+		blockHeight = int64(block.Height)
 		// If this is the swap contract's block, grab the contract hash.
 		if *blockHash == contractBlock {
 			for i := range block.Tx {
@@ -1093,6 +1324,101 @@ func (btc *ExchangeWallet) Refund(coinID, contract dex.Bytes) (dex.Bytes, error)
 	return toCoinID(refundHash, 0), nil
 }
 
+// Rescan rescans the blockchain from the block height for wallet
+// transactions. Part of the asset.Rescanner interface.
+func (btc *ExchangeWallet) Rescan(height uint32) error {
+	return btc.wallet.Rescan(height)
+}
+
+// AddressDeposits returns the wallet's unspent outputs that pay to any of the
+// addresses, including unconfirmed outputs. Part of the asset.DepositTracker
+// interface.
+func (btc *ExchangeWallet) AddressDeposits(addrs []string) ([]*asset.Deposit, error) {
+	unspents, err := btc.wallet.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	addrSet := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		addrSet[addr] = true
+	}
+	var deposits []*asset.Deposit
+	for _, txout := range unspents {
+		if !addrSet[txout.Address] {
+			continue
+		}
+		txHash, err := chainhash.NewHashFromStr(txout.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding txid in ListUnspentResult: %v", err)
+		}
+		deposits = append(deposits, &asset.Deposit{
+			Address:       txout.Address,
+			CoinID:        toCoinID(txHash, txout.Vout),
+			Value:         toSatoshi(txout.Amount),
+			Confirmations: txout.Confirmations,
+		})
+	}
+	return deposits, nil
+}
+
+// ListCoins returns the wallet's safe unspent outputs, and the outputs that are
+// locked. Part of the asset.CoinLister interface.
+func (btc *ExchangeWallet) ListCoins() ([]*asset.WalletCoin, error) {
+	unspents, err := btc.wallet.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	coins := make([]*asset.WalletCoin, 0, len(unspents))
+	for _, txout := range unspents {
+		if !txout.Safe {
+			continue
+		}
+		txHash, err := chainhash.NewHashFromStr(txout.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding txid in ListUnspentResult: %v", err)
+		}
+		coins = append(coins, &asset.WalletCoin{
+			ID:            toCoinID(txHash, txout.Vout),
+			String:        outpointID(txout.TxID, txout.Vout),
+			Value:         toSatoshi(txout.Amount),
+			Confirmations: txout.Confirmations,
+			Address:       txout.Address,
+			Label:         txout.Label,
+		})
+	}
+
+	lockedOutpoints, err := btc.wallet.ListLockUnspent()
+	if err != nil {
+		return nil, err
+	}
+	for _, outPoint := range lockedOutpoints {
+		txHash, err := chainhash.NewHashFromStr(outPoint.TxID)
+		if err != nil {
+			return nil, err
+		}
+		txOut, err := btc.node.GetTxOut(txHash, outPoint.Vout, true)
+		if err != nil {
+			return nil, err
+		}
+		if txOut == nil {
+			// Must be spent now?
+			continue
+		}
+		coin := &asset.WalletCoin{
+			ID:            toCoinID(txHash, outPoint.Vout),
+			String:        outpointID(outPoint.TxID, outPoint.Vout),
+			Value:         toSatoshi(txOut.Value),
+			Confirmations: uint32(txOut.Confirmations),
+			Locked:        true,
+		}
+		if len(txOut.ScriptPubKey.Addresses) == 1 {
+			coin.Address = txOut.ScriptPubKey.Addresses[0]
+		}
+		coins = append(coins, coin)
+	}
+	return coins, nil
+}
+
 // Address returns a new external address from the wallet.
 func (btc *ExchangeWallet) Address() (string, error) {
 	addr, err := btc.wallet.AddressPKH()
@@ -1121,6 +1447,302 @@ func (btc *ExchangeWallet) Withdraw(address string, value uint64) (asset.Coin, e
 	return newOutput(btc.node, txHash, vout, sent, nil), nil
 }
 
+// withdrawal is an unsigned withdrawal transaction, funded but without its
+// change output.
+type withdrawal struct {
+	tx      *wire.MsgTx
+	feeRate uint64
+	// size is the estimated size of the signed transaction, in vbytes.
+	size uint64
+	fee  uint64
+	// change is the value of the change output, or zero if there is no change.
+	change uint64
+}
+
+// EstimateWithdrawal selects the UTXOs to fund the withdrawal and estimates the
+// size and fees of the transaction. Part of the asset.BatchWithdrawer
+// interface.
+func (btc *ExchangeWallet) EstimateWithdrawal(w *asset.Withdrawal) (*asset.WithdrawalEstimate, error) {
+	wd, err := btc.prepareWithdrawal(w)
+	if err != nil {
+		return nil, err
+	}
+	return &asset.WithdrawalEstimate{
+		FeeRate: wd.feeRate,
+		Size:    wd.size,
+		Fee:     wd.fee,
+		Inputs:  len(wd.tx.TxIn),
+	}, nil
+}
+
+// SendWithdrawal sends the withdrawal to its outputs in a single transaction.
+// Part of the asset.BatchWithdrawer interface.
+func (btc *ExchangeWallet) SendWithdrawal(w *asset.Withdrawal) ([]asset.Coin, error) {
+	wd, err := btc.prepareWithdrawal(w)
+	if err != nil {
+		return nil, err
+	}
+	baseTx := wd.tx
+	if wd.change > 0 {
+		changeAddr, err := btc.wallet.ChangeAddress()
+		if err != nil {
+			return nil, fmt.Errorf("error creating change address: %v", err)
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, fmt.Errorf("error creating change script: %v", err)
+		}
+		baseTx.AddTxOut(wire.NewTxOut(int64(wd.change), changeScript))
+	}
+	msgTx, err := btc.wallet.SignTx(baseTx)
+	if err != nil {
+		return nil, fmt.Errorf("signing error: %v", err)
+	}
+	if vSize := txVBytes(msgTx); wd.fee < wd.feeRate*vSize {
+		btc.log.Warnf("withdrawal fee rate is lower than requested. %d < %d", wd.fee/vSize, wd.feeRate)
+	}
+	checkHash := msgTx.TxHash()
+	txHash, err := btc.node.SendRawTransaction(msgTx, false)
+	if err != nil {
+		btc.log.Errorf("SendWithdrawal error, %d outputs: %v", len(w.Outputs), err)
+		return nil, err
+	}
+	if *txHash != checkHash {
+		return nil, fmt.Errorf("transaction sent, but received unexpected transaction ID back from RPC server. "+
+			"expected %s, got %s", checkHash, *txHash)
+	}
+	coins := make([]asset.Coin, 0, len(w.Outputs))
+	for i := range w.Outputs {
+		coins = append(coins, newOutput(btc.node, txHash, uint32(i), uint64(msgTx.TxOut[i].Value), nil))
+	}
+	return coins, nil
+}
+
+// prepareWithdrawal creates the withdrawal's outputs and selects UTXOs to fund
+// them. The UTXOs are not locked. If the change would be dust, there is no
+// change output and the dust is added to the fees.
+func (btc *ExchangeWallet) prepareWithdrawal(w *asset.Withdrawal) (*withdrawal, error) {
+	if len(w.Outputs) == 0 {
+		return nil, fmt.Errorf("no withdrawal outputs")
+	}
+	feeRate := w.FeeRate
+	if feeRate == 0 {
+		confTarget := int64(w.TargetConfs)
+		if confTarget == 0 {
+			confTarget = 1
+		}
+		var err error
+		feeRate, err = btc.estimateFeeRate(confTarget)
+		if err != nil {
+			feeRate = btc.fallbackFeeRate
+			btc.log.Warnf("Unable to get optimal fee rate for %d blocks, using fallback of %d: %v",
+				confTarget, btc.fallbackFeeRate, err)
+		}
+	}
+
+	baseTx := wire.NewMsgTx(wire.TxVersion)
+	size := uint64(dexbtc.MimimumTxOverhead)
+	var totalOut uint64
+	for _, out := range w.Outputs {
+		if out.Value == 0 {
+			return nil, fmt.Errorf("cannot send value = 0 to %s", out.Address)
+		}
+		addr, err := btcutil.DecodeAddress(out.Address, btc.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", out.Address, err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("error creating pubkey script for %s: %v", out.Address, err)
+		}
+		txOut := wire.NewTxOut(int64(out.Value), pkScript)
+		baseTx.AddTxOut(txOut)
+		size += uint64(txOut.SerializeSize())
+		totalOut += out.Value
+	}
+
+	utxos, _, avail, err := btc.spendableUTXOs(0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing unspent outputs: %v", err)
+	}
+	// The fees are estimated with a change output.
+	reqFunds := func(size uint64) uint64 {
+		if w.Subtract {
+			return totalOut
+		}
+		return totalOut + feeRate*(size+dexbtc.P2WPKHOutputSize)
+	}
+	var sum uint64
+	var segwit bool
+	addUTXO := func(utxo *compositeUTXO) {
+		baseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(utxo.txHash, utxo.vout), nil, nil))
+		size += uint64(utxo.input.VBytes())
+		sum += utxo.amount
+		segwit = segwit || utxo.input.WitnessSize > 0
+	}
+	for sum < reqFunds(size) {
+		if len(utxos) == 0 {
+			return nil, fmt.Errorf("not enough to cover requested funds + fees = %d. %d available",
+				reqFunds(size), avail)
+		}
+		// Add the smallest UTXO that is enough. If none are large enough, add the
+		// largest and continue.
+		idx := len(utxos) - 1
+		for i, utxo := range utxos {
+			if sum+utxo.amount >= reqFunds(size+uint64(utxo.input.VBytes())) {
+				idx = i
+				break
+			}
+		}
+		addUTXO(utxos[idx])
+		utxos = append(utxos[:idx], utxos[idx+1:]...)
+	}
+	if segwit {
+		// The segwit marker and flag add half a vbyte.
+		size++
+	}
+
+	wd := &withdrawal{
+		tx:      baseTx,
+		feeRate: feeRate,
+		size:    size,
+	}
+	// Check whether the change is worth an output.
+	changeSize := size + dexbtc.P2WPKHOutputSize
+	var change uint64
+	if w.Subtract {
+		change = sum - totalOut
+	} else {
+		change = sum - totalOut - feeRate*changeSize
+	}
+	changeScript := append([]byte{txscript.OP_0, txscript.OP_DATA_20}, make([]byte, 20)...)
+	if change > 0 && !dexbtc.IsDust(wire.NewTxOut(int64(change), changeScript), feeRate) {
+		wd.change = change
+		wd.size = changeSize
+	}
+	if w.Subtract {
+		subtracted := feeRate * wd.size
+		subtractee := baseTx.TxOut[0]
+		if subtracted >= uint64(subtractee.Value) {
+			return nil, fmt.Errorf("output value %d is too small to pay the fees of %d", subtractee.Value, subtracted)
+		}
+		subtractee.Value -= int64(subtracted)
+		if dexbtc.IsDust(subtractee, feeRate) {
+			return nil, fmt.Errorf("output value %d is dust after subtracting fees of %d", subtractee.Value, subtracted)
+		}
+		totalOut -= subtracted
+	}
+	// Any dust change goes to the miner.
+	wd.fee = sum - totalOut - wd.change
+	return wd, nil
+}
+
+// txVBytes is the virtual size of the transaction.
+func txVBytes(tx *wire.MsgTx) uint64 {
+	weight := tx.SerializeSizeStripped()*3 + tx.SerializeSize()
+	return uint64(weight+3) / 4
+}
+
+// CoinValues returns the values of the spendable UTXOs, in ascending order.
+// Part of the asset.CoinManager interface.
+func (btc *ExchangeWallet) CoinValues() ([]uint64, error) {
+	utxos, _, _, err := btc.spendableUTXOs(0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing unspent outputs: %v", err)
+	}
+	vals := make([]uint64, 0, len(utxos))
+	for _, utxo := range utxos {
+		vals = append(vals, utxo.amount)
+	}
+	return vals, nil
+}
+
+// Consolidate spends up to maxInputs of the smallest confirmed UTXOs worth less
+// than threshold to a single output at a new internal address. UTXOs worth
+// less than the fees to spend them are skipped. Part of the asset.CoinManager
+// interface.
+func (btc *ExchangeWallet) Consolidate(threshold uint64, maxInputs int, feeRate uint64) (asset.Coin, error) {
+	utxos, _, _, err := btc.spendableUTXOs(1)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing unspent outputs: %v", err)
+	}
+	baseTx := wire.NewMsgTx(wire.TxVersion)
+	size := uint64(dexbtc.MimimumTxOverhead + dexbtc.P2WPKHOutputSize)
+	var sum uint64
+	var segwit bool
+	for _, utxo := range utxos {
+		if utxo.amount >= threshold || len(baseTx.TxIn) == maxInputs {
+			break
+		}
+		inputSize := uint64(utxo.input.VBytes())
+		if utxo.amount <= feeRate*inputSize {
+			continue
+		}
+		baseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(utxo.txHash, utxo.vout), nil, nil))
+		size += inputSize
+		sum += utxo.amount
+		segwit = segwit || utxo.input.WitnessSize > 0
+	}
+	if len(baseTx.TxIn) < 2 {
+		return nil, fmt.Errorf("found %d outputs to consolidate, need at least 2", len(baseTx.TxIn))
+	}
+	if segwit {
+		size++
+	}
+	fee := feeRate * size
+	if fee >= sum {
+		return nil, fmt.Errorf("consolidated value %d does not cover the fees of %d", sum, fee)
+	}
+	addr, err := btc.wallet.ChangeAddress()
+	if err != nil {
+		return nil, fmt.Errorf("error creating consolidation address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("error creating consolidation script: %v", err)
+	}
+	txOut := wire.NewTxOut(int64(sum-fee), pkScript)
+	if dexbtc.IsDust(txOut, feeRate) {
+		return nil, fmt.Errorf("consolidated output of %d is dust", sum-fee)
+	}
+	baseTx.AddTxOut(txOut)
+	msgTx, err := btc.wallet.SignTx(baseTx)
+	if err != nil {
+		return nil, fmt.Errorf("signing error: %v", err)
+	}
+	checkHash := msgTx.TxHash()
+	txHash, err := btc.node.SendRawTransaction(msgTx, false)
+	if err != nil {
+		return nil, err
+	}
+	if *txHash != checkHash {
+		return nil, fmt.Errorf("transaction sent, but received unexpected transaction ID back from RPC server. "+
+			"expected %s, got %s", checkHash, *txHash)
+	}
+	btc.log.Infof("Consolidated %d outputs worth %d into %s:0", len(msgTx.TxIn), sum, txHash)
+	return newOutput(btc.node, txHash, 0, uint64(txOut.Value), nil), nil
+}
+
+// Split sends n outputs of the value to new internal addresses in a single
+// transaction. Part of the asset.CoinManager interface.
+func (btc *ExchangeWallet) Split(value uint64, n int, feeRate uint64) ([]asset.Coin, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of outputs %d", n)
+	}
+	w := &asset.Withdrawal{
+		Outputs: make([]*asset.WithdrawalOutput, 0, n),
+		FeeRate: feeRate,
+	}
+	for i := 0; i < n; i++ {
+		addr, err := btc.wallet.ChangeAddress()
+		if err != nil {
+			return nil, fmt.Errorf("error creating split address: %v", err)
+		}
+		w.Outputs = append(w.Outputs, &asset.WithdrawalOutput{Address: addr.String(), Value: value})
+	}
+	return btc.SendWithdrawal(w)
+}
+
 // ValidateSecret checks that the secret satisfies the contract.
 func (btc *ExchangeWallet) ValidateSecret(secret, secretHash []byte) bool {
 	h := sha256.Sum256(secret)
@@ -1168,25 +1790,61 @@ func (btc *ExchangeWallet) Confirmations(id dex.Bytes) (uint32, error) {
 }
 
 // run pings for new blocks and runs the tipChange callback function when the
-// block changes.
+// block changes. run also supervises an external wallet process. If the wallet
+// stops responding, the connection is considered lost until a request
+// succeeds again, at which point the funding coins are relocked and the
+// tipChange callback is run so that any pending swaps are checked.
 func (btc *ExchangeWallet) run(ctx context.Context) {
+	var tipHash chainhash.Hash
 	h, err := btc.node.GetBestBlockHash()
+	connected := err == nil
 	if err != nil {
 		btc.tipChange(fmt.Errorf("error initializing best block for %s: %v", btc.symbol, err))
+	} else {
+		tipHash = *h
 	}
-	tipHash := *h
 	ticker := time.NewTicker(blockTicker)
 	defer ticker.Stop()
+	checkTicker := time.NewTicker(walletCheckInterval)
+	defer checkTicker.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			h, err := btc.node.GetBestBlockHash()
 			if err != nil {
+				if connected {
+					btc.log.Errorf("Lost connection to %s wallet: %v", btc.symbol, err)
+					connected = false
+				}
 				btc.tipChange(fmt.Errorf("failed to get best block hash from %s node", btc.symbol))
 				continue
 			}
+			if !connected {
+				connected = true
+				btc.log.Infof("Connection to %s wallet restored.", btc.symbol)
+				btc.recoverWallet()
+				tipHash = *h
+				btc.tipChange(nil)
+				continue
+			}
 			if *h != tipHash {
 				tipHash = *h
+				btc.pruneRedemptions()
+				btc.tipChange(nil)
+			}
+		case <-checkTicker.C:
+			// A wallet process that restarts between block checks is
+			// detected by the loss of its coin locks.
+			if !connected || btc.spv != nil {
+				continue
+			}
+			n, err := btc.relockFundingCoins()
+			if err != nil {
+				btc.log.Errorf("Error checking %s funding coin locks: %v", btc.symbol, err)
+				continue
+			}
+			if n > 0 {
+				btc.log.Warnf("%s wallet lost locks on %d funding coins. Was the wallet restarted?", btc.symbol, n)
 				btc.tipChange(nil)
 			}
 		case <-ctx.Done():
@@ -1195,6 +1853,70 @@ func (btc *ExchangeWallet) run(ctx context.Context) {
 	}
 }
 
+// pruneRedemptions stops tracking redemptions that have been mined, or that the
+// wallet no longer knows about.
+func (btc *ExchangeWallet) pruneRedemptions() {
+	btc.redeemMtx.Lock()
+	defer btc.redeemMtx.Unlock()
+	for txHash := range btc.redemptions {
+		tx, err := btc.wallet.GetTransaction(txHash.String())
+		if err != nil {
+			if !isTxNotFoundErr(err) {
+				btc.log.Errorf("Error checking redemption %s: %v", txHash, err)
+				continue
+			}
+		} else if tx.Confirmations == 0 {
+			continue
+		}
+		delete(btc.redemptions, txHash)
+	}
+}
+
+// recoverWallet relocks the funding coins after the connection to the wallet
+// is restored.
+func (btc *ExchangeWallet) recoverWallet() {
+	n, err := btc.relockFundingCoins()
+	if err != nil {
+		btc.log.Errorf("Error relocking %s funding coins: %v", btc.symbol, err)
+		return
+	}
+	if n > 0 {
+		btc.log.Infof("Relocked %d %s funding coins.", n, btc.symbol)
+	}
+}
+
+// relockFundingCoins locks any coins funding orders that the wallet does not
+// report as locked, as is the case after the wallet process restarts. Coins
+// that cannot be locked, such as coins spent while the wallet was down, are
+// skipped. The number of coins relocked is returned.
+func (btc *ExchangeWallet) relockFundingCoins() (int, error) {
+	lockedOutpoints, err := btc.wallet.ListLockUnspent()
+	if err != nil {
+		return 0, err
+	}
+	locked := make(map[string]bool, len(lockedOutpoints))
+	for _, op := range lockedOutpoints {
+		locked[outpointID(op.TxID, op.Vout)] = true
+	}
+	var unlocked []*output
+	btc.fundingMtx.RLock()
+	for opID, utxo := range btc.fundingCoins {
+		if !locked[opID] {
+			unlocked = append(unlocked, newOutput(btc.node, utxo.txHash, utxo.vout, utxo.amount, utxo.redeemScript))
+		}
+	}
+	btc.fundingMtx.RUnlock()
+	var n int
+	for _, op := range unlocked {
+		if err := btc.wallet.LockUnspent(false, []*output{op}); err != nil {
+			btc.log.Debugf("Unable to relock %s funding coin %s: %v", btc.symbol, op, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
 // convertCoin converts the asset.Coin to an output.
 func (btc *ExchangeWallet) convertCoin(coin asset.Coin) (*output, error) {
 	op, _ := coin.(*output)
@@ -1301,6 +2023,9 @@ func (btc *ExchangeWallet) sendWithReturn(baseTx *wire.MsgTx, addr btcutil.Addre
 // createSig creates and returns the serialized raw signature and compressed
 // pubkey for a transaction input signature.
 func (btc *ExchangeWallet) createSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	if btc.spv != nil && btc.spv.hw != nil {
+		return btc.spv.hwInputSig(tx, idx, pkScript, addr)
+	}
 	privKey, err := btc.wallet.PrivKeyForAddress(addr.String())
 	if err != nil {
 		return nil, nil, err
@@ -1319,6 +2044,7 @@ type compositeUTXO struct {
 	address      string
 	redeemScript []byte
 	amount       uint64
+	confs        uint32
 	input        *dexbtc.SpendInfo
 }
 
@@ -1350,6 +2076,7 @@ func (btc *ExchangeWallet) spendableUTXOs(confs uint32) ([]*compositeUTXO, map[s
 				address:      txout.Address,
 				redeemScript: txout.RedeemScript,
 				amount:       toSatoshi(txout.Amount),
+				confs:        txout.Confirmations,
 				input:        nfo,
 			}
 			utxos = append(utxos, utxo)
@@ -173,7 +173,7 @@ func (c *tRPCClient) addRawTx(blockHeight int64, tx *btcjson.TxRawResult) (*chai
 	if !found {
 		var newHash chainhash.Hash
 		copy(newHash[:], randBytes(32))
-		c.verboseBlocks[newHash.String()] = &btcjson.GetBlockVerboseResult{}
+		c.verboseBlocks[newHash.String()] = &btcjson.GetBlockVerboseResult{Height: blockHeight}
 		blkHash = &newHash
 		c.mainchain[blockHeight] = blkHash
 	}
@@ -499,6 +499,57 @@ func TestReturnCoins(t *testing.T) {
 	}
 }
 
+func TestRelockFundingCoins(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	txid := tTxHash.String()
+	wallet.fundingMtx.Lock()
+	wallet.fundingCoins[outpointID(txid, 0)] = &compositeUTXO{txHash: tTxHash, vout: 0, amount: 1}
+	wallet.fundingCoins[outpointID(txid, 1)] = &compositeUTXO{txHash: tTxHash, vout: 1, amount: 1}
+	wallet.fundingMtx.Unlock()
+	node.rawRes[methodListLockUnspent] = mustMarshal(t, []*RPCOutpoint{{TxID: txid, Vout: 0}})
+	node.rawRes[methodLockUnspent] = []byte(`true`)
+
+	// Only the coin without a lock is relocked.
+	n, err := wallet.relockFundingCoins()
+	if err != nil {
+		t.Fatalf("relockFundingCoins error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 coin relocked, got %d", n)
+	}
+	var unlock bool
+	var ops []*RPCOutpoint
+	if node.lastMethod != methodLockUnspent || len(node.lastParams) != 2 {
+		t.Fatalf("expected lockunspent request, got %s", node.lastMethod)
+	}
+	_ = json.Unmarshal(node.lastParams[0], &unlock)
+	_ = json.Unmarshal(node.lastParams[1], &ops)
+	if unlock || len(ops) != 1 || ops[0].Vout != 1 {
+		t.Fatalf("wrong lockunspent request: unlock = %t, ops = %v", unlock, ops)
+	}
+
+	// Coins that fail to lock are skipped.
+	node.rawRes[methodLockUnspent] = []byte(`false`)
+	n, err = wallet.relockFundingCoins()
+	if err != nil {
+		t.Fatalf("relockFundingCoins error: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 coins relocked, got %d", n)
+	}
+	node.rawRes[methodLockUnspent] = []byte(`true`)
+
+	// listlockunspent error.
+	node.rawErr[methodListLockUnspent] = tErr
+	_, err = wallet.relockFundingCoins()
+	if err == nil {
+		t.Fatalf("no error for listlockunspent error")
+	}
+	node.rawErr[methodListLockUnspent] = nil
+}
+
 func TestFundingCoins(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -930,6 +981,151 @@ func TestRedeem(t *testing.T) {
 	node.sendHash = nil
 }
 
+func TestBumpRedemptionFee(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	secret := randBytes(32)
+	secretHash := sha256.Sum256(secret)
+	contract, err := dexbtc.MakeContract(tP2PKHAddr, tP2PKHAddr, secretHash[:], time.Now().Add(time.Hour).Unix(), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("error making swap contract: %v", err)
+	}
+	addr, _ := btcutil.DecodeAddress(tP2PKHAddr, &chaincfg.MainNetParams)
+	redemption := &asset.Redemption{
+		Spends: &auditInfo{
+			output:    newOutput(node, tTxHash, 0, toSatoshi(5), contract),
+			recipient: addr,
+		},
+		Secret: secret,
+	}
+	privBytes, _ := hex.DecodeString("b07209eec1a8fb6cfe5cb6ace36567406971a75c330db7101fb21bc679bc5330")
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privBytes)
+	wif, _ := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true)
+	node.rawRes[methodChangeAddress] = mustMarshal(t, tP2WPKHAddr)
+	node.rawRes[methodPrivKeyForAddress] = mustMarshal(t, wif.String())
+	node.signFunc = func(params []json.RawMessage) (json.RawMessage, error) {
+		var msgHex string
+		_ = json.Unmarshal(params[0], &msgHex)
+		msgBytes, _ := hex.DecodeString(msgHex)
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		if err := msgTx.Deserialize(bytes.NewReader(msgBytes)); err != nil {
+			t.Fatalf("error deserializing transaction: %v", err)
+		}
+		msgTx.TxIn[0].SignatureScript = randBytes(dexbtc.RedeemP2PKHSigScriptSize)
+		buf := new(bytes.Buffer)
+		_ = msgTx.Serialize(buf)
+		return mustMarshal(t, &SignTxResult{Hex: buf.Bytes(), Complete: true}), nil
+	}
+
+	coinIDs, _, err := wallet.Redeem([]*asset.Redemption{redemption})
+	if err != nil {
+		t.Fatalf("redeem error: %v", err)
+	}
+	coinID := coinIDs[0]
+	txHash, _, _ := decodeCoinID(coinID)
+	pkg := wallet.redemptions[*txHash]
+	redeemSize, redeemValue := pkg.size, pkg.tip.value
+
+	// Not enabled.
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err != asset.NoFeeBumpError {
+		t.Fatalf("expected NoFeeBumpError with no fee limit, got %v", err)
+	}
+	wallet.redeemFeeLimit = 100
+
+	// The redemption already pays the optimal fee rate.
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err != asset.NoFeeBumpError {
+		t.Fatalf("expected NoFeeBumpError for optimal fee rate, got %v", err)
+	}
+
+	// Unknown redemption, e.g. sent before a restart.
+	_, _, err = wallet.BumpRedemptionFee(toCoinID(tTxHash, 0))
+	if err != asset.NoFeeBumpError {
+		t.Fatalf("expected NoFeeBumpError for unknown redemption, got %v", err)
+	}
+
+	// Bump to the fee limit.
+	pkg.fees = redeemSize * 5
+	wallet.redeemFeeLimit = 10
+	coin, feeRate, err := wallet.BumpRedemptionFee(coinID)
+	if err != nil {
+		t.Fatalf("fee bump error: %v", err)
+	}
+	if feeRate != 10 {
+		t.Fatalf("expected fee rate 10, got %d", feeRate)
+	}
+	childFee := pkg.fees - redeemSize*5
+	if coin.Value() != redeemValue-childFee {
+		t.Fatalf("wrong child output value. wanted %d, got %d", redeemValue-childFee, coin.Value())
+	}
+	if pkg.tip != coin {
+		t.Fatalf("package tip not updated")
+	}
+
+	// Bump again to the optimal fee rate by extending the chain.
+	wallet.redeemFeeLimit = 100
+	optimalRate := optimalFeeRate + 1
+	_, feeRate, err = wallet.BumpRedemptionFee(coinID)
+	if err != nil {
+		t.Fatalf("second fee bump error: %v", err)
+	}
+	if feeRate != optimalRate {
+		t.Fatalf("expected fee rate %d, got %d", optimalRate, feeRate)
+	}
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err != asset.NoFeeBumpError {
+		t.Fatalf("expected NoFeeBumpError after bump, got %v", err)
+	}
+
+	// Reset the package for the error cases.
+	resetPkg := func() {
+		pkg.size, pkg.fees = redeemSize, redeemSize*5
+		pkg.tip = newOutput(node, txHash, 0, redeemValue, nil)
+	}
+
+	// Not worth the fees.
+	resetPkg()
+	pkg.tip.value = 1000
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err == nil {
+		t.Fatalf("no error for small redemption output")
+	}
+
+	// Sign error.
+	resetPkg()
+	node.rawErr[methodSignTx] = tErr
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err == nil {
+		t.Fatalf("no error for signing error")
+	}
+	node.rawErr[methodSignTx] = nil
+
+	// Send error.
+	node.sendErr = tErr
+	_, _, err = wallet.BumpRedemptionFee(coinID)
+	if err == nil {
+		t.Fatalf("no error for send error")
+	}
+	node.sendErr = nil
+	if pkg.fees != redeemSize*5 {
+		t.Fatalf("package updated after failed fee bump")
+	}
+
+	// Unconfirmed redemptions are kept, and confirmed ones are deleted.
+	node.rawRes[methodGetTransaction] = mustMarshal(t, &GetTransactionResult{})
+	wallet.pruneRedemptions()
+	if wallet.redemptions[*txHash] == nil {
+		t.Fatalf("unconfirmed redemption pruned")
+	}
+	node.rawRes[methodGetTransaction] = mustMarshal(t, &GetTransactionResult{Confirmations: 1})
+	wallet.pruneRedemptions()
+	if wallet.redemptions[*txHash] != nil {
+		t.Fatalf("confirmed redemption not pruned")
+	}
+}
+
 func TestSignMessage(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -1411,6 +1607,342 @@ func TestWithdraw(t *testing.T) {
 	testSender(t, tWithdrawSender)
 }
 
+func TestWithdrawal(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.rawRes[methodListUnspent] = mustMarshal(t, []*ListUnspentResult{{
+		TxID:          tTxID,
+		Address:       tP2PKHAddr,
+		Amount:        1,
+		Confirmations: 1,
+		Vout:          0,
+		ScriptPubKey:  tP2PKH,
+		Safe:          true,
+	}, {
+		TxID:          tTxID,
+		Address:       tP2PKHAddr,
+		Amount:        3,
+		Confirmations: 1,
+		Vout:          1,
+		ScriptPubKey:  tP2PKH,
+		Safe:          true,
+	}})
+	node.rawRes[methodChangeAddress] = mustMarshal(t, tP2WPKHAddr)
+	var sentTx *wire.MsgTx
+	node.signFunc = func(params []json.RawMessage) (json.RawMessage, error) {
+		var msgHex string
+		json.Unmarshal(params[0], &msgHex)
+		msgBytes, _ := hex.DecodeString(msgHex)
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		if err := msgTx.Deserialize(bytes.NewReader(msgBytes)); err != nil {
+			t.Fatalf("error deserializing transaction: %v", err)
+		}
+		for i := range msgTx.TxIn {
+			msgTx.TxIn[i].SignatureScript = randBytes(dexbtc.RedeemP2PKHSigScriptSize)
+		}
+		sentTx = msgTx
+		buf := new(bytes.Buffer)
+		msgTx.Serialize(buf)
+		return mustMarshal(t, &SignTxResult{Hex: buf.Bytes(), Complete: true}), nil
+	}
+
+	const feeRate = 10
+	w := &asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{
+			{Address: tP2PKHAddr, Value: toSatoshi(1.5)},
+			{Address: tP2WPKHAddr, Value: toSatoshi(0.5)},
+		},
+		FeeRate: feeRate,
+	}
+	// The 3 BTC output is the smallest that is enough. The estimated size
+	// includes the change output.
+	wantSize := uint64(dexbtc.MimimumTxOverhead + dexbtc.P2PKHOutputSize + 2*dexbtc.P2WPKHOutputSize +
+		dexbtc.RedeemP2PKHInputSize)
+	est, err := wallet.EstimateWithdrawal(w)
+	if err != nil {
+		t.Fatalf("EstimateWithdrawal error: %v", err)
+	}
+	if est.Inputs != 1 || est.Size != wantSize || est.Fee != feeRate*wantSize || est.FeeRate != feeRate {
+		t.Fatalf("wrong estimate %+v, wanted size %d", est, wantSize)
+	}
+
+	coins, err := wallet.SendWithdrawal(w)
+	if err != nil {
+		t.Fatalf("SendWithdrawal error: %v", err)
+	}
+	if len(coins) != 2 || coins[0].Value() != toSatoshi(1.5) || coins[1].Value() != toSatoshi(0.5) {
+		t.Fatalf("wrong coins returned")
+	}
+	if len(sentTx.TxOut) != 3 {
+		t.Fatalf("expected 3 outputs with change, got %d", len(sentTx.TxOut))
+	}
+	change := sentTx.TxOut[2].Value
+	if uint64(change) != toSatoshi(1)-est.Fee {
+		t.Fatalf("wrong change value %d", change)
+	}
+
+	// Subtracting the fees, spending both UTXOs with no change.
+	subW := &asset.Withdrawal{
+		Outputs:  []*asset.WithdrawalOutput{{Address: tP2PKHAddr, Value: toSatoshi(4)}},
+		FeeRate:  feeRate,
+		Subtract: true,
+	}
+	coins, err = wallet.SendWithdrawal(subW)
+	if err != nil {
+		t.Fatalf("SendWithdrawal subtract error: %v", err)
+	}
+	wantFee := feeRate * uint64(dexbtc.MimimumTxOverhead+dexbtc.P2PKHOutputSize+2*dexbtc.RedeemP2PKHInputSize)
+	if len(sentTx.TxOut) != 1 || coins[0].Value() != toSatoshi(4)-wantFee {
+		t.Fatalf("wrong subtracted output value %d, wanted %d", coins[0].Value(), toSatoshi(4)-wantFee)
+	}
+
+	// Estimated fee rate.
+	w.FeeRate = 0
+	est, err = wallet.EstimateWithdrawal(w)
+	if err != nil {
+		t.Fatalf("EstimateWithdrawal error: %v", err)
+	}
+	if est.FeeRate != optimalFeeRate+1 {
+		t.Fatalf("wrong estimated fee rate. wanted %d, got %d", optimalFeeRate+1, est.FeeRate)
+	}
+	w.FeeRate = feeRate
+
+	// Not enough funds.
+	_, err = wallet.EstimateWithdrawal(&asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{{Address: tP2PKHAddr, Value: toSatoshi(4)}},
+		FeeRate: feeRate,
+	})
+	if err == nil {
+		t.Fatalf("no error for insufficient funds")
+	}
+
+	// Bad address.
+	_, err = wallet.EstimateWithdrawal(&asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{{Address: "abc", Value: toSatoshi(1)}},
+		FeeRate: feeRate,
+	})
+	if err == nil {
+		t.Fatalf("no error for bad address")
+	}
+
+	// Signing error.
+	node.rawErr[methodSignTx] = tErr
+	_, err = wallet.SendWithdrawal(w)
+	if err == nil {
+		t.Fatalf("no error for signing error")
+	}
+	node.rawErr[methodSignTx] = nil
+
+	// Send error.
+	node.sendErr = tErr
+	_, err = wallet.SendWithdrawal(w)
+	if err == nil {
+		t.Fatalf("no error for send error")
+	}
+	node.sendErr = nil
+}
+
+func TestCoinManager(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	unspent := func(vout uint32, amt float64, confs uint32) *ListUnspentResult {
+		return &ListUnspentResult{
+			TxID:          tTxID,
+			Address:       tP2PKHAddr,
+			Amount:        amt,
+			Confirmations: confs,
+			Vout:          vout,
+			ScriptPubKey:  tP2PKH,
+			Safe:          true,
+		}
+	}
+	node.rawRes[methodListUnspent] = mustMarshal(t, []*ListUnspentResult{
+		unspent(0, 2, 1),
+		unspent(1, 0.001, 1),
+		unspent(2, 0.00000500, 1), // worth less than the fees to spend it
+		unspent(3, 0.002, 0),      // unconfirmed
+		unspent(4, 0.003, 1),
+	})
+	node.rawRes[methodChangeAddress] = mustMarshal(t, tP2WPKHAddr)
+	var sentTx *wire.MsgTx
+	node.signFunc = func(params []json.RawMessage) (json.RawMessage, error) {
+		var msgHex string
+		json.Unmarshal(params[0], &msgHex)
+		msgBytes, _ := hex.DecodeString(msgHex)
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		if err := msgTx.Deserialize(bytes.NewReader(msgBytes)); err != nil {
+			t.Fatalf("error deserializing transaction: %v", err)
+		}
+		for i := range msgTx.TxIn {
+			msgTx.TxIn[i].SignatureScript = randBytes(dexbtc.RedeemP2PKHSigScriptSize)
+		}
+		sentTx = msgTx
+		buf := new(bytes.Buffer)
+		msgTx.Serialize(buf)
+		return mustMarshal(t, &SignTxResult{Hex: buf.Bytes(), Complete: true}), nil
+	}
+
+	vals, err := wallet.CoinValues()
+	if err != nil {
+		t.Fatalf("CoinValues error: %v", err)
+	}
+	if len(vals) != 5 || vals[0] != 500 || vals[4] != toSatoshi(2) {
+		t.Fatalf("wrong coin values %v", vals)
+	}
+
+	// The two confirmed UTXOs below the threshold that are worth spending are
+	// consolidated.
+	const feeRate = 10
+	coin, err := wallet.Consolidate(toSatoshi(1), 10, feeRate)
+	if err != nil {
+		t.Fatalf("Consolidate error: %v", err)
+	}
+	wantFee := feeRate * uint64(dexbtc.MimimumTxOverhead+dexbtc.P2WPKHOutputSize+2*dexbtc.RedeemP2PKHInputSize)
+	if len(sentTx.TxIn) != 2 || len(sentTx.TxOut) != 1 || coin.Value() != toSatoshi(0.004)-wantFee {
+		t.Fatalf("wrong consolidation. %d inputs, %d outputs, value %d", len(sentTx.TxIn), len(sentTx.TxOut), coin.Value())
+	}
+
+	// Not enough UTXOs with the input limit.
+	if _, err = wallet.Consolidate(toSatoshi(1), 1, feeRate); err == nil {
+		t.Fatalf("no error for a single input")
+	}
+
+	// Split the big UTXO into lots.
+	coins, err := wallet.Split(toSatoshi(0.5), 3, feeRate)
+	if err != nil {
+		t.Fatalf("Split error: %v", err)
+	}
+	if len(coins) != 3 || len(sentTx.TxOut) != 4 {
+		t.Fatalf("wrong split. %d coins, %d outputs", len(coins), len(sentTx.TxOut))
+	}
+	for _, coin := range coins {
+		if coin.Value() != toSatoshi(0.5) {
+			t.Fatalf("wrong split coin value %d", coin.Value())
+		}
+	}
+	if _, err = wallet.Split(toSatoshi(0.5), 0, feeRate); err == nil {
+		t.Fatalf("no error for zero outputs")
+	}
+	if _, err = wallet.Split(toSatoshi(1), 3, feeRate); err == nil {
+		t.Fatalf("no error for insufficient funds")
+	}
+}
+
+func TestAddressDeposits(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.rawRes[methodListUnspent] = mustMarshal(t, []*ListUnspentResult{
+		{TxID: tTxID, Vout: 0, Address: tP2PKHAddr, Amount: 1, Confirmations: 0},
+		{TxID: tTxID, Vout: 1, Address: tP2WPKHAddr, Amount: 2, Confirmations: 3},
+	})
+	deposits, err := wallet.AddressDeposits([]string{tP2PKHAddr})
+	if err != nil {
+		t.Fatalf("AddressDeposits error: %v", err)
+	}
+	if len(deposits) != 1 {
+		t.Fatalf("expected 1 deposit, got %d", len(deposits))
+	}
+	dep := deposits[0]
+	if dep.Address != tP2PKHAddr || dep.Value != toSatoshi(1) || dep.Confirmations != 0 ||
+		!bytes.Equal(dep.CoinID, toCoinID(tTxHash, 0)) {
+		t.Fatalf("wrong deposit %+v", dep)
+	}
+
+	node.rawErr[methodListUnspent] = tErr
+	if _, err := wallet.AddressDeposits([]string{tP2PKHAddr}); err == nil {
+		t.Fatalf("no error for listunspent error")
+	}
+}
+
+func TestListCoins(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.rawRes[methodListUnspent] = mustMarshal(t, []*ListUnspentResult{
+		{TxID: tTxID, Vout: 0, Address: tP2PKHAddr, Label: "savings", Amount: 1, Confirmations: 2, Safe: true},
+		{TxID: tTxID, Vout: 1, Address: tP2WPKHAddr, Amount: 2, Safe: false},
+	})
+	node.rawRes[methodListLockUnspent] = mustMarshal(t, []*RPCOutpoint{{TxID: tTxID, Vout: 2}})
+	node.txOutRes = newTxOutResult([]byte{}, 3e8, 5)
+	coins, err := wallet.ListCoins()
+	if err != nil {
+		t.Fatalf("ListCoins error: %v", err)
+	}
+	if len(coins) != 2 {
+		t.Fatalf("expected 2 coins, got %d", len(coins))
+	}
+	if c := coins[0]; c.Label != "savings" || c.Value != toSatoshi(1) || c.Confirmations != 2 ||
+		c.Locked || !bytes.Equal(c.ID, toCoinID(tTxHash, 0)) {
+		t.Fatalf("wrong unspent coin %+v", c)
+	}
+	if c := coins[1]; !c.Locked || c.Value != 3e8 || !bytes.Equal(c.ID, toCoinID(tTxHash, 2)) {
+		t.Fatalf("wrong locked coin %+v", c)
+	}
+
+	node.rawErr[methodListLockUnspent] = tErr
+	if _, err := wallet.ListCoins(); err == nil {
+		t.Fatalf("no error for listlockunspent error")
+	}
+	node.rawErr[methodListUnspent] = tErr
+	if _, err := wallet.ListCoins(); err == nil {
+		t.Fatalf("no error for listunspent error")
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	chainInfo := &getBlockchainInfoResult{
+		Blocks:               100,
+		Headers:              100,
+		BestBlockHash:        tTxID,
+		VerificationProgress: 0.9999,
+	}
+	node.rawRes[methodGetBlockchainInfo] = mustMarshal(t, chainInfo)
+	node.rawRes[methodGetNetworkInfo] = mustMarshal(t, map[string]uint32{"connections": 8})
+	node.rawRes[methodGetBlockHeader] = mustMarshal(t, &blockHeader{Hash: tTxID, Height: 100, Time: 1600000000})
+	node.rawRes[methodListUnspent] = mustMarshal(t, []*ListUnspentResult{
+		{TxID: tTxID, Vout: 0, Confirmations: 0},
+		{TxID: tTxID, Vout: 1, Confirmations: 0},
+		{TxID: "abcd", Vout: 0, Confirmations: 2},
+	})
+
+	status, err := wallet.SyncStatus()
+	if err != nil {
+		t.Fatalf("SyncStatus error: %v", err)
+	}
+	if !status.Synced || status.Height != 100 || status.Peers != 8 || status.PendingTxs != 1 ||
+		status.BlockTime.Unix() != 1600000000 {
+		t.Fatalf("wrong sync status %+v", status)
+	}
+
+	// Headers ahead of blocks.
+	chainInfo.Headers = 120
+	node.rawRes[methodGetBlockchainInfo] = mustMarshal(t, chainInfo)
+	status, _ = wallet.SyncStatus()
+	if status.Synced || status.TargetHeight != 120 {
+		t.Fatalf("wrong sync status for node behind headers %+v", status)
+	}
+
+	// No peers.
+	chainInfo.Headers = 100
+	node.rawRes[methodGetBlockchainInfo] = mustMarshal(t, chainInfo)
+	node.rawRes[methodGetNetworkInfo] = mustMarshal(t, map[string]uint32{"connections": 0})
+	status, _ = wallet.SyncStatus()
+	if status.Synced {
+		t.Fatalf("synced without peers")
+	}
+
+	node.rawErr[methodGetBlockchainInfo] = tErr
+	if _, err := wallet.SyncStatus(); err == nil {
+		t.Fatalf("no error for getblockchaininfo error")
+	}
+}
+
 func TestConfirmations(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -1438,3 +1970,85 @@ func TestConfirmations(t *testing.T) {
 		t.Fatalf("coin error: %v", err)
 	}
 }
+
+func TestSelectCoins(t *testing.T) {
+	spendInfo := &dexbtc.SpendInfo{SigScriptSize: dexbtc.RedeemP2PKHSigScriptSize}
+	newUTXO := func(i int, amt uint64, confs uint32) *compositeUTXO {
+		return &compositeUTXO{
+			txHash: &chainhash.Hash{byte(i)},
+			amount: amt,
+			confs:  confs,
+			input:  spendInfo,
+		}
+	}
+	// Sorted by ascending value.
+	utxos := []*compositeUTXO{
+		newUTXO(0, 3e5, 100),
+		newUTXO(1, 5e5, 50),
+		newUTXO(2, 6e5, 20),
+		newUTXO(3, 2e6, 5),
+		newUTXO(4, 5e6, 1),
+	}
+	utxoMap := make(map[string]*compositeUTXO, len(utxos))
+	for _, utxo := range utxos {
+		utxoMap[outpointID(utxo.txHash.String(), utxo.vout)] = utxo
+	}
+	coinID := func(i int) dex.Bytes {
+		return toCoinID(utxos[i].txHash, utxos[i].vout)
+	}
+
+	tests := []struct {
+		name    string
+		opts    *asset.FundingOptions
+		want    []int
+		wantErr bool
+	}{
+		{"default", &asset.FundingOptions{}, []int{3}, false},
+		{"min inputs", &asset.FundingOptions{Strategy: asset.FundMinInputs}, []int{4}, false},
+		{"min change", &asset.FundingOptions{Strategy: asset.FundMinChange}, []int{2, 1}, false},
+		{"oldest", &asset.FundingOptions{Strategy: asset.FundOldest}, []int{0, 1, 2}, false},
+		{"manual", &asset.FundingOptions{Strategy: asset.FundManual,
+			CoinIDs: []dex.Bytes{coinID(0), coinID(4)}}, []int{0, 4}, false},
+		{"manual insufficient", &asset.FundingOptions{Strategy: asset.FundManual,
+			CoinIDs: []dex.Bytes{coinID(0), coinID(1)}}, nil, true},
+		{"manual duplicate", &asset.FundingOptions{Strategy: asset.FundManual,
+			CoinIDs: []dex.Bytes{coinID(4), coinID(4)}}, nil, true},
+		{"manual unknown", &asset.FundingOptions{Strategy: asset.FundManual,
+			CoinIDs: []dex.Bytes{toCoinID(&chainhash.Hash{9}, 0)}}, nil, true},
+		{"manual none", &asset.FundingOptions{Strategy: asset.FundManual}, nil, true},
+		{"unknown strategy", &asset.FundingOptions{Strategy: "biggest"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		selected, err := selectCoins(tLotSize, tBTC, utxos, utxoMap, tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(selected) != len(tt.want) {
+			t.Errorf("%s: selected %d coins, expected %d", tt.name, len(selected), len(tt.want))
+			continue
+		}
+		for i, idx := range tt.want {
+			if selected[i] != utxos[idx] {
+				t.Errorf("%s: coin %d is worth %d, expected %d", tt.name, i,
+					selected[i].amount, utxos[idx].amount)
+			}
+		}
+	}
+
+	// Not enough for any strategy.
+	for _, strategy := range []asset.FundingStrategy{asset.FundDefault,
+		asset.FundMinInputs, asset.FundMinChange, asset.FundOldest} {
+		_, err := selectCoins(1e7, tBTC, utxos, utxoMap, &asset.FundingOptions{Strategy: strategy})
+		if err == nil {
+			t.Errorf("%q: no error for insufficient funds", strategy)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"fmt"
+	"sort"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/calc"
+)
+
+// minChangeTries is the most coin combinations tried by the minimum change
+// search before it settles for the best combination found.
+const minChangeTries = 100000
+
+// selectCoins selects the utxos that fund the order according to the funding
+// options. The utxos must be sorted by ascending value, and utxoMap must index
+// them by outpoint ID.
+func selectCoins(value uint64, nfo *dex.Asset, utxos []*compositeUTXO,
+	utxoMap map[string]*compositeUTXO, opts *asset.FundingOptions) ([]*compositeUTXO, error) {
+
+	switch opts.Strategy {
+	case asset.FundDefault:
+		return selectDefault(value, nfo, utxos)
+	case asset.FundMinInputs:
+		largest := make([]*compositeUTXO, 0, len(utxos))
+		for i := len(utxos) - 1; i >= 0; i-- {
+			largest = append(largest, utxos[i])
+		}
+		return selectInOrder(value, nfo, largest)
+	case asset.FundMinChange:
+		return selectMinChange(value, nfo, utxos)
+	case asset.FundOldest:
+		oldest := make([]*compositeUTXO, len(utxos))
+		copy(oldest, utxos)
+		sort.SliceStable(oldest, func(i, j int) bool { return oldest[i].confs > oldest[j].confs })
+		return selectInOrder(value, nfo, oldest)
+	case asset.FundManual:
+		return selectManual(value, nfo, opts.CoinIDs, utxoMap)
+	}
+	return nil, fmt.Errorf("unknown funding strategy %q", opts.Strategy)
+}
+
+// insufficientFunds is the error returned when the utxos cannot cover the
+// order.
+func insufficientFunds(value uint64, size uint32, nfo *dex.Asset) error {
+	return fmt.Errorf("not enough to cover requested funds + fees = %d",
+		calc.RequiredOrderFunds(value, uint64(size), nfo))
+}
+
+// selectDefault selects the smallest utxo that is enough for the value. If no
+// utxo is large enough, the largest is selected and the search is repeated
+// with the remaining utxos.
+func selectDefault(value uint64, nfo *dex.Asset, utxos []*compositeUTXO) ([]*compositeUTXO, error) {
+	var sum uint64
+	var size uint32
+	var selected []*compositeUTXO
+	isEnoughWith := func(unspent *compositeUTXO) bool {
+		reqFunds := calc.RequiredOrderFunds(value, uint64(size+unspent.input.VBytes()), nfo)
+		return sum+unspent.amount >= reqFunds
+	}
+	for {
+		// If there are none left, we don't have enough.
+		if len(utxos) == 0 {
+			return nil, insufficientFunds(value, size, nfo)
+		}
+		var txout *compositeUTXO
+		for _, txout = range utxos {
+			if isEnoughWith(txout) {
+				return append(selected, txout), nil
+			}
+		}
+		// Select the last output, which is the largest.
+		selected = append(selected, txout)
+		sum += txout.amount
+		size += txout.input.VBytes()
+		utxos = utxos[:len(utxos)-1]
+	}
+}
+
+// selectInOrder selects utxos in the order given until they are enough for the
+// value.
+func selectInOrder(value uint64, nfo *dex.Asset, utxos []*compositeUTXO) ([]*compositeUTXO, error) {
+	var sum uint64
+	var size uint32
+	for i, txout := range utxos {
+		sum += txout.amount
+		size += txout.input.VBytes()
+		if sum >= calc.RequiredOrderFunds(value, uint64(size), nfo) {
+			return utxos[:i+1], nil
+		}
+	}
+	return nil, insufficientFunds(value, size, nfo)
+}
+
+// selectMinChange searches for the utxos that are enough for the value with the
+// least excess, preferring fewer utxos when the excess is equal. The search is
+// bounded by minChangeTries, and is never worse than selectDefault.
+func selectMinChange(value uint64, nfo *dex.Asset, utxos []*compositeUTXO) ([]*compositeUTXO, error) {
+	best, err := selectDefault(value, nfo, utxos)
+	if err != nil {
+		return nil, err
+	}
+	excess := func(selected []*compositeUTXO) uint64 {
+		var sum uint64
+		var size uint32
+		for _, txout := range selected {
+			sum += txout.amount
+			size += txout.input.VBytes()
+		}
+		return sum - calc.RequiredOrderFunds(value, uint64(size), nfo)
+	}
+	bestExcess := excess(best)
+
+	// Search the largest utxos first. remaining[i] is the total value of the
+	// utxos from i on, for pruning combinations that cannot be enough.
+	largest := make([]*compositeUTXO, 0, len(utxos))
+	for i := len(utxos) - 1; i >= 0; i-- {
+		largest = append(largest, utxos[i])
+	}
+	remaining := make([]uint64, len(largest)+1)
+	for i := len(largest) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + largest[i].amount
+	}
+
+	var tries int
+	selected := make([]*compositeUTXO, 0, len(largest))
+	var search func(i int, sum uint64, size uint32)
+	search = func(i int, sum uint64, size uint32) {
+		if bestExcess == 0 || tries >= minChangeTries {
+			return
+		}
+		tries++
+		reqFunds := calc.RequiredOrderFunds(value, uint64(size), nfo)
+		if sum >= reqFunds {
+			if ex := sum - reqFunds; ex < bestExcess || (ex == bestExcess && len(selected) < len(best)) {
+				best = append([]*compositeUTXO(nil), selected...)
+				bestExcess = ex
+			}
+			return
+		}
+		// The fees only grow with more utxos, so the combination cannot be
+		// enough if the remaining utxos do not cover the current requirement.
+		if i == len(largest) || sum+remaining[i] < reqFunds {
+			return
+		}
+		txout := largest[i]
+		selected = append(selected, txout)
+		search(i+1, sum+txout.amount, size+txout.input.VBytes())
+		selected = selected[:len(selected)-1]
+		search(i+1, sum, size)
+	}
+	search(0, 0, 0)
+	return best, nil
+}
+
+// selectManual selects the utxos with the given coin IDs, which must be
+// spendable and enough for the value.
+func selectManual(value uint64, nfo *dex.Asset, coinIDs []dex.Bytes,
+	utxoMap map[string]*compositeUTXO) ([]*compositeUTXO, error) {
+
+	if len(coinIDs) == 0 {
+		return nil, fmt.Errorf("no coins selected")
+	}
+	var sum uint64
+	var size uint32
+	selected := make([]*compositeUTXO, 0, len(coinIDs))
+	seen := make(map[string]bool, len(coinIDs))
+	for _, coinID := range coinIDs {
+		txHash, vout, err := decodeCoinID(coinID)
+		if err != nil {
+			return nil, err
+		}
+		opID := outpointID(txHash.String(), vout)
+		if seen[opID] {
+			return nil, fmt.Errorf("coin %s selected more than once", opID)
+		}
+		seen[opID] = true
+		txout, found := utxoMap[opID]
+		if !found {
+			return nil, fmt.Errorf("coin %s is not spendable", opID)
+		}
+		selected = append(selected, txout)
+		sum += txout.amount
+		size += txout.input.VBytes()
+	}
+	if sum < calc.RequiredOrderFunds(value, uint64(size), nfo) {
+		return nil, insufficientFunds(value, size, nfo)
+	}
+	return selected, nil
+}
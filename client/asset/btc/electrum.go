@@ -0,0 +1,683 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	electrumKey = "electrum"
+	// electrumHeaderChunk is the most headers requested at once. ElectrumX
+	// and Fulcrum serve up to 2016 headers per request.
+	electrumHeaderChunk = 2016
+)
+
+var (
+	// ElectrumOptions are the wallet settings for the Electrum server wallet.
+	// If electrum is set, the RPC settings are not used.
+	ElectrumOptions = config.Options(&ElectrumConfig{})
+
+	errElectrumUnsupported = errors.New("not available from an Electrum server")
+)
+
+// ElectrumConfig is the configuration of the Electrum server wallet.
+type ElectrumConfig struct {
+	Server string `ini:"electrum, Electrum Server, Address (host:port) of an ElectrumX or Fulcrum server to use instead of a full node"`
+	TLS    bool   `ini:"electrumtls, Electrum TLS, Connect to the Electrum server with TLS (1 to enable)"`
+	Cert   string `ini:"electrumcert, Electrum TLS Certificate, Path to the Electrum server's TLS certificate, for servers with self-signed certificates"`
+	Dir    string `ini:"electrumdir, Electrum Wallet Directory, Where the Electrum server wallet stores its data (default ~/.dexc/<asset>-electrum/<network>)"`
+}
+
+// loadElectrumConfig parses the Electrum server wallet settings. The returned
+// config is nil if no Electrum server is set.
+func loadElectrumConfig(settings map[string]string, symbol string, params *chaincfg.Params) (*ElectrumConfig, error) {
+	if settings[electrumKey] == "" {
+		return nil, nil
+	}
+	cfg := new(ElectrumConfig)
+	if err := config.Unmapify(settings, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing Electrum settings: %v", err)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		return nil, fmt.Errorf("invalid Electrum server address %q: %v", cfg.Server, err)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(btcutil.AppDataDir("dexc", false), symbol+"-electrum", params.Name)
+	}
+	return cfg, nil
+}
+
+// newElectrumWallet creates an *spvWallet that gets its headers and wallet
+// transactions from an Electrum server rather than from P2P peers. The wallet
+// keys and transactions are kept locally, as with the SPV wallet. Headers are
+// checked for proof of work, and the wallet's mined transactions are checked
+// against the headers with merkle proofs.
+func newElectrumWallet(cfg *ElectrumConfig, params *chaincfg.Params, logger dex.Logger) (*spvWallet, error) {
+	var tlsCfg *tls.Config
+	if cfg.TLS || cfg.Cert != "" {
+		host, _, _ := net.SplitHostPort(cfg.Server)
+		tlsCfg = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: host,
+		}
+		if cfg.Cert != "" {
+			pem, err := ioutil.ReadFile(cfg.Cert)
+			if err != nil {
+				return nil, fmt.Errorf("error reading Electrum server certificate: %v", err)
+			}
+			rootCAs, _ := x509.SystemCertPool()
+			if rootCAs == nil {
+				rootCAs = x509.NewCertPool()
+			}
+			if !rootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("invalid Electrum server certificate %s", cfg.Cert)
+			}
+			tlsCfg.RootCAs = rootCAs
+		}
+	}
+	w := newSPVWallet(&SPVConfig{SPV: true, Dir: cfg.Dir}, params, logger)
+	es := &electrumSource{
+		subscribed: make(map[string]string),
+		synced:     make(map[string]string),
+	}
+	es.electrumClient = newElectrumClient(cfg.Server, tlsCfg, func() {
+		es.mtx.Lock()
+		es.subscribed = make(map[string]string)
+		es.mtx.Unlock()
+		w.notifyBlock()
+	}, func(method string, params json.RawMessage) {
+		es.handleNotification(method, params)
+		w.notifyBlock()
+	}, logger)
+	w.electrum = es
+	w.src = es
+	return w, nil
+}
+
+// electrumSource is an Electrum server connection, and tracks the history
+// status of the wallet's scripts. electrumSource
+// satisfies blockSource, but only transactions can be requested. Headers and
+// wallet transactions are synced with syncElectrum.
+type electrumSource struct {
+	*electrumClient
+
+	mtx sync.Mutex
+	// subscribed is the latest history status reported by the server for each
+	// subscribed script hash.
+	subscribed map[string]string
+	// synced is the history status of each script hash when its history was
+	// last synced.
+	synced map[string]string
+}
+
+// Check that electrumSource satisfies the blockSource interface.
+var _ blockSource = (*electrumSource)(nil)
+
+// electrumTip is the result of blockchain.headers.subscribe.
+type electrumTip struct {
+	Height uint32 `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+// electrumHistoryItem is an element of the blockchain.scripthash.get_history
+// result. The height is 0 or -1 for unconfirmed transactions.
+type electrumHistoryItem struct {
+	Height int64  `json:"height"`
+	TxHash string `json:"tx_hash"`
+}
+
+// electrumMerkle is the result of blockchain.transaction.get_merkle.
+type electrumMerkle struct {
+	BlockHeight uint32   `json:"block_height"`
+	Merkle      []string `json:"merkle"`
+	Pos         uint32   `json:"pos"`
+}
+
+// handleNotification records the script status from a script hash
+// notification. Header notifications only signal that a sync is needed.
+func (es *electrumSource) handleNotification(method string, params json.RawMessage) {
+	if method != "blockchain.scripthash.subscribe" {
+		return
+	}
+	var scriptHash string
+	var status *string
+	if err := parseParams(splitParams(params), &scriptHash, &status); err != nil {
+		es.log.Errorf("Invalid Electrum script hash notification %s", string(params))
+		return
+	}
+	es.mtx.Lock()
+	defer es.mtx.Unlock()
+	if _, found := es.subscribed[scriptHash]; found {
+		es.subscribed[scriptHash] = statusString(status)
+	}
+}
+
+// splitParams splits a JSON array into its elements.
+func splitParams(params json.RawMessage) []json.RawMessage {
+	var elems []json.RawMessage
+	json.Unmarshal(params, &elems)
+	return elems
+}
+
+// statusString is the history status, which is null for an empty history.
+func statusString(status *string) string {
+	if status == nil {
+		return ""
+	}
+	return *status
+}
+
+// serverTip is the height of the server's best block. Requesting the tip also
+// subscribes to header notifications.
+func (es *electrumSource) serverTip() (uint32, error) {
+	res := new(electrumTip)
+	if err := es.call("blockchain.headers.subscribe", nil, res); err != nil {
+		return 0, err
+	}
+	return res.Height, nil
+}
+
+// status is the history status of the script hash, subscribing to the script
+// hash if necessary.
+func (es *electrumSource) status(scriptHash string) (string, error) {
+	es.mtx.Lock()
+	status, found := es.subscribed[scriptHash]
+	es.mtx.Unlock()
+	if found {
+		return status, nil
+	}
+	var res *string
+	if err := es.call("blockchain.scripthash.subscribe", []interface{}{scriptHash}, &res); err != nil {
+		return "", err
+	}
+	es.mtx.Lock()
+	es.subscribed[scriptHash] = statusString(res)
+	es.mtx.Unlock()
+	return statusString(res), nil
+}
+
+// syncedStatus is the history status when the script hash's history was last
+// synced.
+func (es *electrumSource) syncedStatus(scriptHash string) string {
+	es.mtx.Lock()
+	defer es.mtx.Unlock()
+	return es.synced[scriptHash]
+}
+
+// setSynced records the history status of the synced script hash.
+func (es *electrumSource) setSynced(scriptHash, status string) {
+	es.mtx.Lock()
+	es.synced[scriptHash] = status
+	es.mtx.Unlock()
+}
+
+// resetSynced forgets the synced history statuses, so that every script's
+// history is synced again.
+func (es *electrumSource) resetSynced() {
+	es.mtx.Lock()
+	es.synced = make(map[string]string)
+	es.mtx.Unlock()
+}
+
+// history is the confirmed and unconfirmed transactions that pay to or spend
+// from the script hash.
+func (es *electrumSource) history(scriptHash string) ([]*electrumHistoryItem, error) {
+	var res []*electrumHistoryItem
+	err := es.call("blockchain.scripthash.get_history", []interface{}{scriptHash}, &res)
+	return res, err
+}
+
+// headers requests count headers starting at the height. Fewer headers are
+// returned if the server's chain is shorter.
+func (es *electrumSource) headers(height, count uint32) ([]*wire.BlockHeader, error) {
+	var res struct {
+		Count uint32 `json:"count"`
+		Hex   string `json:"hex"`
+	}
+	if err := es.call("blockchain.block.headers", []interface{}{height, count}, &res); err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(res.Hex)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding headers: %v", err)
+	}
+	if res.Count > count || len(b) != int(res.Count)*wire.MaxBlockHeaderPayload {
+		return nil, fmt.Errorf("expected at most %d headers, got %d bytes for %d headers", count, len(b), res.Count)
+	}
+	hdrs := make([]*wire.BlockHeader, 0, res.Count)
+	r := bytes.NewReader(b)
+	for i := uint32(0); i < res.Count; i++ {
+		hdr := new(wire.BlockHeader)
+		if err := hdr.Deserialize(r); err != nil {
+			return nil, fmt.Errorf("error decoding header: %v", err)
+		}
+		hdrs = append(hdrs, hdr)
+	}
+	return hdrs, nil
+}
+
+// peerCount is 1 if connected to the server. Part of the blockSource
+// interface.
+func (es *electrumSource) peerCount() int {
+	if es.connected() {
+		return 1
+	}
+	return 0
+}
+
+// getHeaders is not available from an Electrum server. Part of the
+// blockSource interface.
+func (es *electrumSource) getHeaders(blockchain.BlockLocator) ([]*wire.BlockHeader, error) {
+	return nil, errElectrumUnsupported
+}
+
+// getFilterHeaders is not available from an Electrum server. Part of the
+// blockSource interface.
+func (es *electrumSource) getFilterHeaders(uint32, *chainhash.Hash) ([]chainhash.Hash, error) {
+	return nil, errElectrumUnsupported
+}
+
+// getFilters is not available from an Electrum server. Part of the
+// blockSource interface.
+func (es *electrumSource) getFilters(uint32, *chainhash.Hash, int) ([]*wire.MsgCFilter, error) {
+	return nil, errElectrumUnsupported
+}
+
+// getBlock is not available from an Electrum server. Part of the blockSource
+// interface.
+func (es *electrumSource) getBlock(*chainhash.Hash) (*wire.MsgBlock, error) {
+	return nil, errElectrumUnsupported
+}
+
+// getTx requests the mined or unmined transaction from the server. getTx
+// returns a nil transaction if the server does not know it. Part of the
+// blockSource interface.
+func (es *electrumSource) getTx(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	var txHex string
+	err := es.call("blockchain.transaction.get", []interface{}{txHash.String(), false}, &txHex)
+	if err != nil {
+		var srvErr *electrumError
+		if errors.As(err, &srvErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	txB, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding transaction %s: %v", txHash, err)
+	}
+	tx, err := msgTxFromHex(txB)
+	if err != nil {
+		return nil, err
+	}
+	if tx.TxHash() != *txHash {
+		return nil, fmt.Errorf("requested transaction %s, got %s", txHash, tx.TxHash())
+	}
+	return tx, nil
+}
+
+// sendTx broadcasts the transaction through the server. Part of the
+// blockSource interface.
+func (es *electrumSource) sendTx(tx *wire.MsgTx) error {
+	txB, err := serializeMsgTx(tx)
+	if err != nil {
+		return err
+	}
+	var txid string
+	if err := es.call("blockchain.transaction.broadcast", []interface{}{hex.EncodeToString(txB)}, &txid); err != nil {
+		return err
+	}
+	if txid != tx.TxHash().String() {
+		return fmt.Errorf("server broadcast transaction %s, expected %s", txid, tx.TxHash())
+	}
+	return nil
+}
+
+// estimateFee is the server's fee rate estimate, in BTC/kB, for confirmation
+// within the number of blocks. The server reports -1 if it has no estimate.
+func (es *electrumSource) estimateFee(confTarget int64) (float64, error) {
+	var feeRate float64
+	err := es.call("blockchain.estimatefee", []interface{}{confTarget}, &feeRate)
+	return feeRate, err
+}
+
+// electrumScriptHash is the Electrum protocol's script hash, which is the
+// byte-reversed SHA256 hash of the pubkey script, hex-encoded.
+func electrumScriptHash(pkScript []byte) string {
+	h := sha256.Sum256(pkScript)
+	for i, j := 0, len(h)-1; i < j; i, j = i+1, j-1 {
+		h[i], h[j] = h[j], h[i]
+	}
+	return hex.EncodeToString(h[:])
+}
+
+// syncElectrum brings the wallet up to date with the Electrum server. Headers
+// are synced first, then the histories of the wallet's scripts.
+func (w *spvWallet) syncElectrum() error {
+	tip, err := w.electrum.serverTip()
+	if err != nil {
+		return fmt.Errorf("error getting tip: %v", err)
+	}
+	if err := w.syncElectrumHeaders(tip); err != nil {
+		return fmt.Errorf("error syncing headers: %v", err)
+	}
+	if err := w.syncElectrumHistory(); err != nil {
+		return fmt.Errorf("error syncing history: %v", err)
+	}
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.scanHeight != tipHeight {
+		return w.setScanHeight(tipHeight)
+	}
+	return nil
+}
+
+// syncElectrumHeaders requests headers up to the server's tip. The server's
+// header at the stored tip height is always requested, so that a reorg to a
+// chain of the same length is noticed. If the server's headers don't connect
+// to the stored chain, headers are requested from further back until they do.
+// The headers are checked and stored as with the P2P SPV wallet.
+func (w *spvWallet) syncElectrumHeaders(srvTip uint32) error {
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return err
+	}
+	from := tipHeight + 1
+	if from > srvTip {
+		from = srvTip
+	}
+	step := uint32(1)
+	for from > 0 && from <= srvTip {
+		count := srvTip - from + 1
+		if count > electrumHeaderChunk {
+			count = electrumHeaderChunk
+		}
+		hdrs, err := w.electrum.headers(from, count)
+		if err != nil {
+			return err
+		}
+		if len(hdrs) == 0 {
+			return nil
+		}
+		// Skip the headers that are already stored.
+		start := from
+		for len(hdrs) > 0 {
+			hash, err := w.db.blockHash(start)
+			if err != nil || *hash != hdrs[0].BlockHash() {
+				break
+			}
+			hdrs = hdrs[1:]
+			start++
+		}
+		if len(hdrs) == 0 {
+			from = start
+			continue
+		}
+		if _, found, err := w.db.blockHeight(&hdrs[0].PrevBlock); err != nil {
+			return err
+		} else if !found {
+			if from == 1 {
+				return fmt.Errorf("headers do not connect to the genesis block")
+			}
+			// The server's chain forks below the requested headers.
+			step *= 2
+			if step >= from {
+				from = 1
+			} else {
+				from -= step
+			}
+			continue
+		}
+		tipHeight, _, _, err := w.db.tip()
+		if err != nil {
+			return err
+		}
+		stored, err := w.connectHeaders(hdrs)
+		if err != nil || !stored {
+			return err
+		}
+		if start <= tipHeight {
+			// Transactions in the orphaned blocks were marked unmined, so
+			// every script's history is needed again.
+			w.electrum.resetSynced()
+		}
+		from = start + uint32(len(hdrs))
+	}
+	return nil
+}
+
+// syncElectrumHistory requests the history of each wallet script whose
+// history status has changed since its last sync, and stores the relevant
+// transactions. Storing transactions can add addresses to maintain the gap
+// limit, in which case the new scripts are synced too.
+func (w *spvWallet) syncElectrumHistory() error {
+	for {
+		w.mtx.RLock()
+		scripts := w.scripts()
+		w.mtx.RUnlock()
+		for _, script := range scripts {
+			scriptHash := electrumScriptHash(script)
+			status, err := w.electrum.status(scriptHash)
+			if err != nil {
+				return err
+			}
+			if status == w.electrum.syncedStatus(scriptHash) {
+				continue
+			}
+			complete, err := w.syncElectrumScript(scriptHash)
+			if err != nil {
+				return err
+			}
+			if complete {
+				w.electrum.setSynced(scriptHash, status)
+			}
+		}
+		w.mtx.RLock()
+		n := len(w.addrs) + len(w.watched)
+		w.mtx.RUnlock()
+		if n == len(scripts) {
+			return nil
+		}
+	}
+}
+
+// syncElectrumScript stores the relevant transactions from the script hash's
+// history. Transactions mined above the stored tip, which the server can
+// report before the next header notification arrives, are stored as unmined
+// for now, and the returned bool is false.
+func (w *spvWallet) syncElectrumScript(scriptHash string) (bool, error) {
+	history, err := w.electrum.history(scriptHash)
+	if err != nil {
+		return false, err
+	}
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return false, err
+	}
+	complete := true
+	for _, item := range history {
+		txHash, err := chainhash.NewHashFromStr(item.TxHash)
+		if err != nil {
+			return false, fmt.Errorf("invalid transaction hash %q: %v", item.TxHash, err)
+		}
+		var height uint32
+		if item.Height > 0 {
+			height = uint32(item.Height)
+		}
+		if height > tipHeight {
+			height, complete = 0, false
+		}
+		w.mtx.RLock()
+		known := w.txs[*txHash]
+		w.mtx.RUnlock()
+		if known != nil && known.height == height {
+			continue
+		}
+		stx, err := w.electrumTx(txHash, height, known)
+		if err != nil {
+			return false, err
+		}
+		w.mtx.Lock()
+		if w.relevant(stx.tx) {
+			err = w.storeTx(stx)
+		}
+		w.mtx.Unlock()
+		if err != nil {
+			return false, err
+		}
+	}
+	return complete, nil
+}
+
+// electrumTx prepares the transaction for storage. The transaction is
+// requested from the server if it is not already stored. For a mined
+// transaction, the merkle proof is requested and checked against the stored
+// header at the height.
+func (w *spvWallet) electrumTx(txHash *chainhash.Hash, height uint32, known *storedTx) (*storedTx, error) {
+	stx := &storedTx{
+		hash:     *txHash,
+		received: time.Now(),
+	}
+	if known != nil {
+		stx.tx, stx.received = known.tx, known.received
+	} else {
+		tx, err := w.electrum.getTx(txHash)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			return nil, fmt.Errorf("transaction %s not found", txHash)
+		}
+		stx.tx = tx
+	}
+	if height == 0 {
+		return stx, nil
+	}
+	hdr, _, err := w.db.header(height)
+	if err != nil {
+		return nil, fmt.Errorf("no header at height %d for transaction %s: %v", height, txHash, err)
+	}
+	res := new(electrumMerkle)
+	if err := w.electrum.call("blockchain.transaction.get_merkle", []interface{}{txHash.String(), height}, res); err != nil {
+		return nil, err
+	}
+	if err := checkMerkleProof(txHash, res, &hdr.MerkleRoot); err != nil {
+		return nil, fmt.Errorf("transaction %s at height %d: %v", txHash, height, err)
+	}
+	stx.height = height
+	stx.blockHash = hdr.BlockHash()
+	stx.blockIndex = res.Pos
+	if known == nil {
+		stx.received = hdr.Timestamp
+	}
+	return stx, nil
+}
+
+// checkMerkleProof checks that the merkle branch connects the transaction to
+// the merkle root.
+func checkMerkleProof(txHash *chainhash.Hash, proof *electrumMerkle, root *chainhash.Hash) error {
+	if len(proof.Merkle) >= 32 {
+		return fmt.Errorf("merkle branch too long")
+	}
+	h := *txHash
+	b := make([]byte, 2*chainhash.HashSize)
+	for i, s := range proof.Merkle {
+		sibling, err := chainhash.NewHashFromStr(s)
+		if err != nil {
+			return fmt.Errorf("invalid merkle branch hash %q: %v", s, err)
+		}
+		if proof.Pos>>uint(i)&1 == 1 {
+			copy(b, sibling[:])
+			copy(b[chainhash.HashSize:], h[:])
+		} else {
+			copy(b, h[:])
+			copy(b[chainhash.HashSize:], sibling[:])
+		}
+		h = chainhash.DoubleHashH(b)
+	}
+	if proof.Pos>>uint(len(proof.Merkle)) != 0 {
+		return fmt.Errorf("merkle position %d out of range", proof.Pos)
+	}
+	if h != *root {
+		return fmt.Errorf("merkle proof does not match the block's merkle root")
+	}
+	return nil
+}
+
+// electrumFindTx looks for the transaction in the history of the pubkey
+// script. The returned transaction is nil if it is not found.
+func (w *spvWallet) electrumFindTx(txHash *chainhash.Hash, pkScript []byte) (*storedTx, error) {
+	history, err := w.electrum.history(electrumScriptHash(pkScript))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range history {
+		if item.TxHash != txHash.String() {
+			continue
+		}
+		var height uint32
+		if item.Height > 0 {
+			height = uint32(item.Height)
+		}
+		return w.electrumTx(txHash, height, nil)
+	}
+	return nil, nil
+}
+
+// electrumVerboseBlock describes the stored transactions mined in the block.
+// An Electrum server does not provide blocks, but the spends of watched
+// outputs are stored with the wallet's transactions, so this is enough to find
+// swap contract redemptions.
+func (w *spvWallet) electrumVerboseBlock(blockHash *chainhash.Hash, height uint32) (*verboseBlockTxs, error) {
+	w.mtx.RLock()
+	var stxs []*storedTx
+	for _, stx := range w.txs {
+		if stx.height == height && stx.blockHash == *blockHash {
+			stxs = append(stxs, stx)
+		}
+	}
+	w.mtx.RUnlock()
+	sort.Slice(stxs, func(i, j int) bool { return stxs[i].blockIndex < stxs[j].blockIndex })
+	res := &verboseBlockTxs{
+		Hash:   blockHash.String(),
+		Height: uint64(height),
+		Tx:     make([]btcjson.TxRawResult, 0, len(stxs)),
+	}
+	for _, stx := range stxs {
+		rawTx, err := w.txRawResult(stx.tx)
+		if err != nil {
+			return nil, err
+		}
+		rawTx.BlockHash = res.Hash
+		res.Tx = append(res.Tx, *rawTx)
+	}
+	return res, nil
+}
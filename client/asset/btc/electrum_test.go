@@ -0,0 +1,659 @@
+// +build !harness
+
+package btc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	dexbtc "decred.org/dcrdex/dex/networks/btc"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// tElectrumServer is an Electrum server backed by a tBlockSource.
+type tElectrumServer struct {
+	src *tBlockSource
+	ln  net.Listener
+
+	mtx   sync.Mutex
+	conns map[net.Conn]map[string]string // subscribed script hashes and statuses
+}
+
+func newTElectrumServer(t *testing.T, src *tBlockSource) *tElectrumServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	s := &tElectrumServer{
+		src:   src,
+		ln:    ln,
+		conns: make(map[net.Conn]map[string]string),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mtx.Lock()
+			s.conns[conn] = make(map[string]string)
+			s.mtx.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *tElectrumServer) close() {
+	s.ln.Close()
+	s.mtx.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mtx.Unlock()
+}
+
+func (s *tElectrumServer) serve(conn net.Conn) {
+	defer func() {
+		s.mtx.Lock()
+		delete(s.conns, conn)
+		s.mtx.Unlock()
+		conn.Close()
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxElectrumMsg)
+	for scanner.Scan() {
+		var req struct {
+			ID     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return
+		}
+		res, err := s.handle(conn, req.Method, req.Params)
+		msg := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if err != nil {
+			msg["error"] = &electrumError{Code: 2, Message: err.Error()}
+		} else {
+			msg["result"] = res
+		}
+		s.send(conn, msg)
+	}
+}
+
+func (s *tElectrumServer) send(conn net.Conn, msg interface{}) {
+	b, _ := json.Marshal(msg)
+	s.mtx.Lock()
+	conn.Write(append(b, '\n'))
+	s.mtx.Unlock()
+}
+
+func (s *tElectrumServer) handle(conn net.Conn, method string, params []json.RawMessage) (interface{}, error) {
+	src := s.src
+	switch method {
+	case "server.version":
+		return []string{"TestX 1.0", electrumProtocol}, nil
+	case "server.ping":
+		return nil, nil
+	case "blockchain.headers.subscribe":
+		return s.tip(), nil
+	case "blockchain.block.headers":
+		var start, count int
+		parseParams(params, &start, &count)
+		src.mtx.Lock()
+		defer src.mtx.Unlock()
+		var buf bytes.Buffer
+		var n int
+		for h := start; h < len(src.blocks) && n < count; h++ {
+			src.blocks[h].Header.Serialize(&buf)
+			n++
+		}
+		return map[string]interface{}{"count": n, "hex": hex.EncodeToString(buf.Bytes()), "max": electrumHeaderChunk}, nil
+	case "blockchain.scripthash.subscribe":
+		var scriptHash string
+		parseParams(params, &scriptHash)
+		status := s.status(scriptHash)
+		s.mtx.Lock()
+		s.conns[conn][scriptHash] = statusString(status)
+		s.mtx.Unlock()
+		return status, nil
+	case "blockchain.scripthash.get_history":
+		var scriptHash string
+		parseParams(params, &scriptHash)
+		return s.history(scriptHash), nil
+	case "blockchain.transaction.get":
+		var txid string
+		parseParams(params, &txid)
+		txHash, _ := chainhash.NewHashFromStr(txid)
+		src.mtx.Lock()
+		defer src.mtx.Unlock()
+		tx := src.mempool[*txHash]
+		for _, blk := range src.blocks {
+			for _, blkTx := range blk.Transactions {
+				if blkTx.TxHash() == *txHash {
+					tx = blkTx
+				}
+			}
+		}
+		if tx == nil {
+			return nil, fmt.Errorf("no such transaction")
+		}
+		b, _ := serializeMsgTx(tx)
+		return hex.EncodeToString(b), nil
+	case "blockchain.transaction.get_merkle":
+		var txid string
+		var height int
+		parseParams(params, &txid, &height)
+		src.mtx.Lock()
+		defer src.mtx.Unlock()
+		return tMerkleProof(src.blocks[height], txid)
+	case "blockchain.transaction.broadcast":
+		var txHex string
+		parseParams(params, &txHex)
+		b, _ := hex.DecodeString(txHex)
+		tx, err := msgTxFromHex(b)
+		if err != nil {
+			return nil, err
+		}
+		if err := src.sendTx(tx); err != nil {
+			return nil, err
+		}
+		return tx.TxHash().String(), nil
+	case "blockchain.estimatefee":
+		return 0.0002, nil
+	}
+	return nil, fmt.Errorf("unknown method %s", method)
+}
+
+func (s *tElectrumServer) tip() *electrumTip {
+	s.src.mtx.Lock()
+	defer s.src.mtx.Unlock()
+	var buf bytes.Buffer
+	tip := s.src.blocks[len(s.src.blocks)-1]
+	tip.Header.Serialize(&buf)
+	return &electrumTip{
+		Height: uint32(len(s.src.blocks) - 1),
+		Hex:    hex.EncodeToString(buf.Bytes()),
+	}
+}
+
+// history finds the transactions that pay to or spend from the script hash.
+func (s *tElectrumServer) history(scriptHash string) []*electrumHistoryItem {
+	src := s.src
+	src.mtx.Lock()
+	defer src.mtx.Unlock()
+	scripts := make(map[wire.OutPoint][]byte, len(src.outputs))
+	for op, script := range src.outputs {
+		scripts[op] = script
+	}
+	for txHash, tx := range src.mempool {
+		for i, txOut := range tx.TxOut {
+			scripts[wire.OutPoint{Hash: txHash, Index: uint32(i)}] = txOut.PkScript
+		}
+	}
+	matches := func(tx *wire.MsgTx) bool {
+		for _, txOut := range tx.TxOut {
+			if electrumScriptHash(txOut.PkScript) == scriptHash {
+				return true
+			}
+		}
+		for _, txIn := range tx.TxIn {
+			if script := scripts[txIn.PreviousOutPoint]; script != nil && electrumScriptHash(script) == scriptHash {
+				return true
+			}
+		}
+		return false
+	}
+	var history []*electrumHistoryItem
+	for height, blk := range src.blocks {
+		for _, tx := range blk.Transactions {
+			if matches(tx) {
+				history = append(history, &electrumHistoryItem{Height: int64(height), TxHash: tx.TxHash().String()})
+			}
+		}
+	}
+	for txHash, tx := range src.mempool {
+		if matches(tx) {
+			history = append(history, &electrumHistoryItem{TxHash: txHash.String()})
+		}
+	}
+	return history
+}
+
+func (s *tElectrumServer) status(scriptHash string) *string {
+	history := s.history(scriptHash)
+	if len(history) == 0 {
+		return nil
+	}
+	h := sha256.New()
+	for _, item := range history {
+		fmt.Fprintf(h, "%s:%d:", item.TxHash, item.Height)
+	}
+	status := hex.EncodeToString(h.Sum(nil))
+	return &status
+}
+
+// notify sends the tip and any changed script statuses to the clients.
+func (s *tElectrumServer) notify() {
+	tip := s.tip()
+	s.mtx.Lock()
+	conns := make(map[net.Conn]map[string]string, len(s.conns))
+	for conn, subs := range s.conns {
+		conns[conn] = subs
+	}
+	s.mtx.Unlock()
+	for conn, subs := range conns {
+		s.send(conn, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "blockchain.headers.subscribe",
+			"params":  []interface{}{tip},
+		})
+		s.mtx.Lock()
+		var changed []string
+		for scriptHash, status := range subs {
+			if newStatus := statusString(s.status(scriptHash)); newStatus != status {
+				subs[scriptHash] = newStatus
+				changed = append(changed, scriptHash)
+			}
+		}
+		s.mtx.Unlock()
+		for _, scriptHash := range changed {
+			s.send(conn, map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "blockchain.scripthash.subscribe",
+				"params":  []interface{}{scriptHash, s.status(scriptHash)},
+			})
+		}
+	}
+}
+
+// tMerkleProof is the merkle branch for the transaction in the block.
+func tMerkleProof(blk *wire.MsgBlock, txid string) (*electrumMerkle, error) {
+	var hashes []chainhash.Hash
+	pos := -1
+	for i, tx := range blk.Transactions {
+		hashes = append(hashes, tx.TxHash())
+		if tx.TxHash().String() == txid {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return nil, fmt.Errorf("transaction not in block")
+	}
+	res := &electrumMerkle{Pos: uint32(pos)}
+	for idx := pos; len(hashes) > 1; idx /= 2 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		res.Merkle = append(res.Merkle, hashes[idx^1].String())
+		next := make([]chainhash.Hash, 0, len(hashes)/2)
+		for i := 0; i < len(hashes); i += 2 {
+			next = append(next, chainhash.DoubleHashH(append(hashes[i][:], hashes[i+1][:]...)))
+		}
+		hashes = next
+	}
+	return res, nil
+}
+
+// tElectrumWallet creates and connects an Electrum server wallet in the
+// directory, and waits for it to connect to the server.
+func tElectrumWallet(t *testing.T, dir string, srv *tElectrumServer) (*spvWallet, func()) {
+	t.Helper()
+	w, err := newElectrumWallet(&ElectrumConfig{Server: srv.ln.Addr().String(), Dir: dir}, tSPVParams, tLogger)
+	if err != nil {
+		t.Fatalf("newElectrumWallet error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(tCtx)
+	wg, err := w.connect(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("connect error: %v", err)
+	}
+	shutdown := func() {
+		cancel()
+		wg.Wait()
+	}
+	tWaitFor(t, "connection", func() bool { return w.src.peerCount() > 0 })
+	return w, shutdown
+}
+
+// tWaitFor waits for the wallet to sync in the background until the condition
+// is met.
+func tWaitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; !cond(); i++ {
+		if i == 100 {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// tElectrumSync notifies the wallet of the server's changes, and syncs once
+// the wallet has the server's script statuses.
+func tElectrumSync(t *testing.T, srv *tElectrumServer, w *spvWallet) {
+	t.Helper()
+	srv.notify()
+	tWaitFor(t, "notifications", func() bool {
+		w.electrum.mtx.Lock()
+		defer w.electrum.mtx.Unlock()
+		for scriptHash, status := range w.electrum.subscribed {
+			if statusString(srv.status(scriptHash)) != status {
+				return false
+			}
+		}
+		return true
+	})
+	tSync(t, w)
+}
+
+func TestLoadElectrumConfig(t *testing.T) {
+	cfg, err := loadElectrumConfig(map[string]string{"rpcuser": "user"}, "btc", tSPVParams)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected no config, got %v, %v", cfg, err)
+	}
+	if _, err := loadElectrumConfig(map[string]string{"electrum": "localhost"}, "btc", tSPVParams); err == nil {
+		t.Fatalf("no error for server address without a port")
+	}
+	cfg, err = loadElectrumConfig(map[string]string{"electrum": "localhost:50002", "electrumtls": "1"}, "btc", tSPVParams)
+	if err != nil {
+		t.Fatalf("config error: %v", err)
+	}
+	if cfg.Server != "localhost:50002" || !cfg.TLS || cfg.Dir == "" {
+		t.Fatalf("wrong config %+v", cfg)
+	}
+	if _, err := newElectrumWallet(&ElectrumConfig{Server: "localhost:50002", Cert: "/does/not/exist"},
+		tSPVParams, tLogger); err == nil {
+		t.Fatalf("no error for missing certificate file")
+	}
+
+	_, err = BTCCloneWallet(&BTCCloneCFG{
+		WalletCFG: &asset.WalletConfig{
+			Settings: map[string]string{"spv": "1", "electrum": "localhost:50002"},
+		},
+		Symbol:      "btc",
+		Logger:      tLogger,
+		ChainParams: tSPVParams,
+	})
+	if err == nil {
+		t.Fatalf("no error for both spv and electrum settings")
+	}
+}
+
+func TestCheckMerkleProof(t *testing.T) {
+	src := newTBlockSource()
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(tP2PKHScript(), tSPVParams)
+	txs := make([]*wire.MsgTx, 0, 4)
+	for i := 0; i < 4; i++ {
+		txs = append(txs, tFundingTx(t, addrs[0].String(), 1e8))
+	}
+	blk := src.mine(txs...)
+	for _, tx := range blk.Transactions {
+		txHash := tx.TxHash()
+		proof, err := tMerkleProof(blk, txHash.String())
+		if err != nil {
+			t.Fatalf("error making proof: %v", err)
+		}
+		if err := checkMerkleProof(&txHash, proof, &blk.Header.MerkleRoot); err != nil {
+			t.Fatalf("valid proof rejected: %v", err)
+		}
+		// The last transaction is paired with itself.
+		if proof.Pos < 4 {
+			proof.Pos ^= 1
+			if err := checkMerkleProof(&txHash, proof, &blk.Header.MerkleRoot); err == nil {
+				t.Fatalf("no error for wrong position")
+			}
+			proof.Pos ^= 1
+		}
+		proof.Pos |= 1 << uint(len(proof.Merkle))
+		if err := checkMerkleProof(&txHash, proof, &blk.Header.MerkleRoot); err == nil {
+			t.Fatalf("no error for out of range position")
+		}
+	}
+}
+
+func TestElectrumWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "electrumtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	for i := 0; i < 5; i++ {
+		src.mine()
+	}
+	srv := newTElectrumServer(t, src)
+	defer srv.close()
+	w, shutdown := tElectrumWallet(t, dir, srv)
+	tSync(t, w)
+	if w.scanHeight != 5 {
+		t.Fatalf("expected scan height 5, got %d", w.scanHeight)
+	}
+
+	wc := newWalletClient(w, tSPVParams)
+	if err := wc.Unlock("pass", time.Hour); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	addrWPKH, _ := wc.AddressWPKH()
+	addrPKH, _ := wc.AddressPKH()
+	w.mtx.RLock()
+	farInfo, _ := w.ks.address(branchExternal, gapLimit)
+	w.mtx.RUnlock()
+	src.mine(tFundingTx(t, addrWPKH.String(), 1e8))
+	src.mine(tFundingTx(t, addrPKH.String(), 2e8))
+	src.mine(tFundingTx(t, farInfo.addr.String(), 15e7))
+	src.mine()
+	tSync(t, w)
+	bals, err := wc.Balances()
+	if err != nil {
+		t.Fatalf("Balances error: %v", err)
+	}
+	if toSatoshi(bals.Mine.Trusted) != 45e7 {
+		t.Fatalf("expected 4.5 BTC balance, got %f", bals.Mine.Trusted)
+	}
+	if w.scanHeight != 9 {
+		t.Fatalf("expected scan height 9, got %d", w.scanHeight)
+	}
+	fundHash := src.blocks[6].Transactions[1].TxHash()
+	tx, err := wc.GetTransaction(fundHash.String())
+	if err != nil {
+		t.Fatalf("GetTransaction error: %v", err)
+	}
+	if tx.Confirmations != 4 || tx.BlockIndex != 1 {
+		t.Fatalf("wrong funding transaction %+v", tx)
+	}
+
+	// An unconfirmed payment from outside of the wallet is untrusted.
+	src.mtx.Lock()
+	mempoolTx := tFundingTx(t, addrWPKH.String(), 5e7)
+	src.mempool[mempoolTx.TxHash()] = mempoolTx
+	src.mtx.Unlock()
+	srv.notify()
+	tWaitFor(t, "unconfirmed payment", func() bool {
+		bals, _ = wc.Balances()
+		return toSatoshi(bals.Mine.Untrusted) == 5e7
+	})
+
+	// Confirmations are tracked through the server's notifications.
+	src.mine(mempoolTx)
+	srv.notify()
+	tWaitFor(t, "confirmation", func() bool {
+		w.mtx.RLock()
+		defer w.mtx.RUnlock()
+		return w.confirmations(w.txs[mempoolTx.TxHash()]) == 1
+	})
+
+	// Fee estimate from the server.
+	feeRate, err := w.EstimateSmartFee(1, nil)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee error: %v", err)
+	}
+	if feeRate.FeeRate == nil || *feeRate.FeeRate != 0.0002 {
+		t.Fatalf("wrong fee estimate %+v", feeRate)
+	}
+
+	// Send.
+	dest := tP2PKHScript()
+	_, destAddrs, _, _ := txscript.ExtractPkScriptAddrs(dest, tSPVParams)
+	txHash, err := wc.SendToAddress(destAddrs[0].String(), 25e7, 10, false)
+	if err != nil {
+		t.Fatalf("SendToAddress error: %v", err)
+	}
+	if len(src.sent) != 1 || src.sent[0].TxHash() != *txHash {
+		t.Fatalf("transaction not broadcast")
+	}
+	sendTx := src.sent[0]
+	src.mine(sendTx)
+	tElectrumSync(t, srv, w)
+	if tx, _ = wc.GetTransaction(txHash.String()); tx.Confirmations != 1 || tx.BlockIndex != 1 {
+		t.Fatalf("wrong mined send %+v", tx)
+	}
+	balance := w.spvBalance()
+
+	// Reorg out the send.
+	sendHeight := len(src.blocks) - 1
+	src.truncate(sendHeight - 1)
+	src.mine()
+	src.mine()
+	tElectrumSync(t, srv, w)
+	if tx, _ = wc.GetTransaction(txHash.String()); tx.Confirmations != 0 || tx.BlockHash != "" {
+		t.Fatalf("send still mined after reorg: %+v", tx)
+	}
+	if w.scanHeight != uint32(sendHeight+1) {
+		t.Fatalf("wrong scan height after reorg. expected %d, got %d", sendHeight+1, w.scanHeight)
+	}
+	// Transactions that are in both chains are still mined.
+	if tx, _ = wc.GetTransaction(fundHash.String()); tx.Confirmations == 0 {
+		t.Fatalf("funding transaction unmined after reorg")
+	}
+	// Mined again.
+	src.mine(sendTx)
+	tElectrumSync(t, srv, w)
+	if tx, _ = wc.GetTransaction(txHash.String()); tx.Confirmations != 1 {
+		t.Fatalf("send not mined again after reorg: %+v", tx)
+	}
+
+	// Restart. The wallet is reloaded from the database.
+	shutdown()
+	w, shutdown = tElectrumWallet(t, dir, srv)
+	defer shutdown()
+	if bal := w.spvBalance(); bal != balance {
+		t.Fatalf("wrong balance after restart. expected %d, got %d", balance, bal)
+	}
+	tElectrumSync(t, srv, w)
+
+	// Rescan from the beginning finds the same transactions.
+	w.mtx.Lock()
+	w.txs = make(map[chainhash.Hash]*storedTx)
+	w.spends = make(map[wire.OutPoint]chainhash.Hash)
+	w.mtx.Unlock()
+	wc = newWalletClient(w, tSPVParams)
+	if err := wc.Rescan(0); err != nil {
+		t.Fatalf("Rescan error: %v", err)
+	}
+	if bal := w.spvBalance(); bal != balance {
+		t.Fatalf("wrong balance after rescan. expected %d, got %d", balance, bal)
+	}
+}
+
+func TestElectrumSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "electrumtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	src.mine()
+	srv := newTElectrumServer(t, src)
+	defer srv.close()
+	spv, shutdown := tElectrumWallet(t, dir, srv)
+	defer shutdown()
+	tSync(t, spv)
+	cfg := &BTCCloneCFG{
+		WalletCFG: &asset.WalletConfig{
+			Settings:  map[string]string{},
+			TipChange: func(error) {},
+		},
+		Symbol:      "btc",
+		Logger:      tLogger,
+		ChainParams: tSPVParams,
+	}
+	wallet := newWallet(cfg, spv)
+	wallet.spv = spv
+	if err := wallet.Unlock("pass", time.Hour); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+
+	secret := randBytes(32)
+	secretHash := sha256.Sum256(secret)
+	recipient, _ := wallet.Address()
+	_, sender, _, _ := txscript.ExtractPkScriptAddrs(tP2PKHScript(), tSPVParams)
+	contract, err := dexbtc.MakeContract(recipient, sender[0].String(), secretHash[:], time.Now().Add(time.Hour).Unix(), tSPVParams)
+	if err != nil {
+		t.Fatalf("error making contract: %v", err)
+	}
+	contractAddr, _ := btcutil.NewAddressScriptHash(contract, tSPVParams)
+	contractTx := tFundingTx(t, contractAddr.String(), 1e8)
+	contractHash := contractTx.TxHash()
+	coinID := toCoinID(&contractHash, 0)
+
+	if _, err := wallet.AuditContract(coinID, contract); err == nil {
+		t.Fatalf("no error for unknown contract")
+	}
+
+	// The contract is found in the history of the contract's script.
+	src.mine(tFundingTx(t, contractAddr.String(), 3e8), contractTx)
+	src.mine()
+	tElectrumSync(t, srv, spv)
+	audit, err := wallet.AuditContract(coinID, contract)
+	if err != nil {
+		t.Fatalf("AuditContract error: %v", err)
+	}
+	if audit.Recipient() != recipient || audit.Coin().Value() != 1e8 {
+		t.Fatalf("wrong audit info")
+	}
+	confs, err := audit.Coin().Confirmations()
+	if err != nil || confs != 2 {
+		t.Fatalf("expected 2 confirmations, got %d, %v", confs, err)
+	}
+
+	// The redemption is found in a later block.
+	sigScript, _ := dexbtc.RedeemP2SHContract(contract, randBytes(72), randBytes(33), secret)
+	redeem := wire.NewMsgTx(wire.TxVersion)
+	redeem.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&contractHash, 0), sigScript, nil))
+	redeem.AddTxOut(wire.NewTxOut(9e7, tP2PKHScript()))
+	src.mine()
+	src.mine(redeem)
+	tElectrumSync(t, srv, spv)
+	if _, err := audit.Coin().Confirmations(); err == nil {
+		t.Fatalf("no error for spent contract")
+	}
+	ctx, cancel := context.WithTimeout(tCtx, time.Second*5)
+	defer cancel()
+	found, err := wallet.FindRedemption(ctx, coinID)
+	if err != nil {
+		t.Fatalf("FindRedemption error: %v", err)
+	}
+	if !bytes.Equal(found, secret) {
+		t.Fatalf("wrong secret found")
+	}
+}
@@ -0,0 +1,323 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+)
+
+const (
+	// electrumProtocol is the Electrum protocol version requested from the
+	// server.
+	electrumProtocol = "1.4"
+	// maxElectrumMsg is the longest message accepted from the server. The
+	// largest expected response is a chunk of headers.
+	maxElectrumMsg = 16 << 20
+)
+
+var (
+	// electrumPingInterval is how often the server is pinged to keep the
+	// connection alive.
+	electrumPingInterval = time.Minute
+
+	errNotConnected = errors.New("not connected to the Electrum server")
+)
+
+// electrumRequest is a JSON-RPC request to an Electrum server.
+type electrumRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// electrumError is the error in an Electrum server's response.
+type electrumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error satisfies the error interface.
+func (e *electrumError) Error() string {
+	return fmt.Sprintf("Electrum server error %d: %s", e.Code, e.Message)
+}
+
+// electrumMsg is a response or notification from an Electrum server.
+// Notifications have a method and no ID.
+type electrumMsg struct {
+	ID     *uint64         `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *electrumError  `json:"error"`
+}
+
+// electrumClient is a JSON-RPC client for ElectrumX and Fulcrum servers.
+// Messages are newline-delimited JSON over TCP, optionally with TLS. The
+// client reconnects if the connection is lost.
+type electrumClient struct {
+	addr      string
+	tlsCfg    *tls.Config
+	log       dex.Logger
+	onConnect func()
+	onNotify  func(method string, params json.RawMessage)
+
+	mtx       sync.Mutex
+	conn      net.Conn
+	nextID    uint64
+	responses map[uint64]chan *electrumMsg
+}
+
+// newElectrumClient is the constructor for an *electrumClient. If tlsCfg is
+// nil, the connection is not encrypted. onConnect is called after each
+// successful connection, and onNotify is called with each subscription
+// notification.
+func newElectrumClient(addr string, tlsCfg *tls.Config, onConnect func(),
+	onNotify func(method string, params json.RawMessage), logger dex.Logger) *electrumClient {
+
+	return &electrumClient{
+		addr:      addr,
+		tlsCfg:    tlsCfg,
+		log:       logger,
+		onConnect: onConnect,
+		onNotify:  onNotify,
+		responses: make(map[uint64]chan *electrumMsg),
+	}
+}
+
+// run keeps the client connected until the context is canceled.
+func (c *electrumClient) run(ctx context.Context) {
+	for {
+		conn, err := c.dial(ctx)
+		if err != nil {
+			c.log.Errorf("Error connecting to Electrum server %s: %v", c.addr, err)
+		} else {
+			c.serve(ctx, conn)
+		}
+		select {
+		case <-time.After(connectInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dial connects to the server.
+func (c *electrumClient) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: peerTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.tlsCfg == nil {
+		return conn, nil
+	}
+	tlsConn := tls.Client(conn, c.tlsCfg)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake error: %v", err)
+	}
+	return tlsConn, nil
+}
+
+// serve negotiates the protocol version, and then reads messages from the
+// connection until it fails or the context is canceled.
+func (c *electrumClient) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxElectrumMsg)
+	version, err := c.negotiate(conn, scanner)
+	if err != nil {
+		c.log.Errorf("Electrum server %s version error: %v", c.addr, err)
+		return
+	}
+	c.log.Infof("Connected to Electrum server %s (%s, protocol %s)", c.addr, version[0], version[1])
+
+	c.mtx.Lock()
+	c.conn = conn
+	c.mtx.Unlock()
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.read(scanner)
+		close(done)
+	}()
+	defer func() {
+		c.mtx.Lock()
+		c.conn = nil
+		for id, ch := range c.responses {
+			close(ch)
+			delete(c.responses, id)
+		}
+		c.mtx.Unlock()
+		conn.Close()
+		wg.Wait()
+	}()
+	if c.onConnect != nil {
+		c.onConnect()
+	}
+
+	ticker := time.NewTicker(electrumPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.call("server.ping", nil, nil); err != nil {
+				c.log.Errorf("Electrum server %s ping error: %v", c.addr, err)
+				return
+			}
+		case <-done:
+			c.log.Infof("Disconnected from Electrum server %s", c.addr)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// negotiate sends the server.version request, which must be the first
+// message on the connection, and returns the server's software version and
+// the agreed protocol version.
+func (c *electrumClient) negotiate(conn net.Conn, scanner *bufio.Scanner) ([]string, error) {
+	b, err := json.Marshal(&electrumRequest{
+		JSONRPC: "2.0",
+		Method:  "server.version",
+		Params:  []interface{}{"dexc", electrumProtocol},
+	})
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(peerTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write(append(b, '\n')); err != nil {
+		return nil, err
+	}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("connection closed")
+	}
+	msg := new(electrumMsg)
+	if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	var version []string
+	if err := json.Unmarshal(msg.Result, &version); err != nil || len(version) != 2 {
+		return nil, fmt.Errorf("unexpected server.version result %s", string(msg.Result))
+	}
+	return version, nil
+}
+
+// read reads messages until the connection fails, passing responses to the
+// waiting caller and notifications to onNotify.
+func (c *electrumClient) read(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		msg := new(electrumMsg)
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			c.log.Errorf("Error decoding Electrum message: %v", err)
+			continue
+		}
+		if msg.ID == nil {
+			if msg.Method != "" && c.onNotify != nil {
+				c.onNotify(msg.Method, msg.Params)
+			}
+			continue
+		}
+		c.mtx.Lock()
+		ch := c.responses[*msg.ID]
+		delete(c.responses, *msg.ID)
+		c.mtx.Unlock()
+		if ch == nil {
+			c.log.Debugf("Received an unrequested Electrum response with ID %d", *msg.ID)
+			continue
+		}
+		ch <- msg
+	}
+	if err := scanner.Err(); err != nil {
+		c.log.Debugf("Electrum connection read error: %v", err)
+	}
+}
+
+// connected is true if the client is connected to the server.
+func (c *electrumClient) connected() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.conn != nil
+}
+
+// call sends the request and waits for the response. The result is decoded
+// into res, if non-nil.
+func (c *electrumClient) call(method string, args []interface{}, res interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	ch := make(chan *electrumMsg, 1)
+	c.mtx.Lock()
+	conn := c.conn
+	if conn == nil {
+		c.mtx.Unlock()
+		return errNotConnected
+	}
+	c.nextID++
+	id := c.nextID
+	b, err := json.Marshal(&electrumRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  args,
+	})
+	if err != nil {
+		c.mtx.Unlock()
+		return err
+	}
+	c.responses[id] = ch
+	conn.SetWriteDeadline(time.Now().Add(peerTimeout))
+	_, err = conn.Write(append(b, '\n'))
+	if err != nil {
+		delete(c.responses, id)
+	}
+	c.mtx.Unlock()
+	if err != nil {
+		return fmt.Errorf("error sending %s request: %v", method, err)
+	}
+
+	var msg *electrumMsg
+	select {
+	case msg = <-ch:
+	case <-time.After(peerTimeout):
+		c.mtx.Lock()
+		delete(c.responses, id)
+		c.mtx.Unlock()
+		return fmt.Errorf("timed out waiting for %s response", method)
+	}
+	if msg == nil {
+		return errNotConnected
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if res == nil {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Result, res); err != nil {
+		return fmt.Errorf("error decoding %s result: %v", method, err)
+	}
+	return nil
+}
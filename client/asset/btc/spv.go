@@ -0,0 +1,1404 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	"decred.org/dcrdex/dex/encode"
+	dexbtc "decred.org/dcrdex/dex/networks/btc"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	spvKey = "spv"
+	// auditScanBlocks is how many blocks back from the tip the SPV wallet
+	// looks for a counterparty's swap contract.
+	auditScanBlocks = 144
+	// coinbaseMaturity is the number of confirmations before a coinbase
+	// output can be spent.
+	coinbaseMaturity = 100
+)
+
+var (
+	// syncInterval is how often the SPV wallet syncs if no new blocks are
+	// announced.
+	syncInterval = time.Minute
+
+	// SPVOptions are the wallet settings for the built-in SPV wallet. If spv
+	// is set, the RPC settings are not used.
+	SPVOptions = config.Options(&SPVConfig{})
+)
+
+// SPVConfig is the configuration of the built-in SPV wallet.
+type SPVConfig struct {
+	SPV   bool   `ini:"spv, Built-in SPV Wallet, Use the built-in SPV wallet instead of a bitcoind wallet (1 to enable)"`
+	Dir   string `ini:"spvdir, SPV Data Directory, Where the SPV wallet stores its data (default ~/.dexc/<asset>-spv/<network>)"`
+	Peers string `ini:"peers, SPV Peers, Comma-separated addresses of the only peers to connect to (default discover peers)"`
+}
+
+// loadSPVConfig parses the SPV settings. The returned config is nil if the
+// SPV wallet is not enabled.
+func loadSPVConfig(settings map[string]string, symbol string, params *chaincfg.Params) (*SPVConfig, error) {
+	if settings[spvKey] == "" {
+		return nil, nil
+	}
+	cfg := new(SPVConfig)
+	if err := config.Unmapify(settings, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing SPV settings: %v", err)
+	}
+	if !cfg.SPV {
+		return nil, nil
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(btcutil.AppDataDir("dexc", false), symbol+"-spv", params.Name)
+	}
+	return cfg, nil
+}
+
+// spvWallet is a Bitcoin SPV wallet. Block headers are synced from the
+// network, and BIP157 compact filters are used to find the blocks with wallet
+// transactions. spvWallet satisfies the rpcClient interface, handling the
+// bitcoind RPCs that ExchangeWallet uses.
+type spvWallet struct {
+	params *chaincfg.Params
+	log    dex.Logger
+	dir    string
+	peers  []string
+	db     *spvDB
+	src    blockSource
+	// electrum is set for an Electrum server wallet, in which case it is also
+	// the blockSource.
+	electrum *electrumSource
+
+	syncMtx   sync.Mutex
+	blockNote chan struct{}
+
+	// hw is set if the wallet's keys are held by a hardware wallet.
+	hw *hwSigner
+	// seed is the HD seed used if the keystore is created. A new seed is
+	// generated if seed is not set.
+	seed []byte
+
+	mtx        sync.RWMutex
+	ks         *keystore
+	scanHeight uint32
+	addrs      map[string]*addrInfo // keyed by pkScript
+	txs        map[chainhash.Hash]*storedTx
+	spends     map[wire.OutPoint]chainhash.Hash
+	watched    map[wire.OutPoint][]byte
+	locked     map[wire.OutPoint]bool
+	feeRate    uint64 // atoms/kB
+	lockTimer  *time.Timer
+}
+
+// Check that spvWallet satisfies the rpcClient interface.
+var _ rpcClient = (*spvWallet)(nil)
+
+// newSPVWallet is the constructor for an *spvWallet. The wallet's database is
+// opened by connect.
+func newSPVWallet(cfg *SPVConfig, params *chaincfg.Params, logger dex.Logger) *spvWallet {
+	var peers []string
+	for _, addr := range strings.Split(cfg.Peers, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, params.DefaultPort)
+		}
+		peers = append(peers, addr)
+	}
+	return &spvWallet{
+		params:    params,
+		log:       logger,
+		dir:       cfg.Dir,
+		peers:     peers,
+		blockNote: make(chan struct{}, 1),
+		addrs:     make(map[string]*addrInfo),
+		txs:       make(map[chainhash.Hash]*storedTx),
+		spends:    make(map[wire.OutPoint]chainhash.Hash),
+		watched:   make(map[wire.OutPoint][]byte),
+		locked:    make(map[wire.OutPoint]bool),
+	}
+}
+
+// connect opens the database, loads the wallet, and starts syncing with the
+// network. The wallet shuts down when the context is canceled.
+func (w *spvWallet) connect(ctx context.Context) (*sync.WaitGroup, error) {
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating SPV data directory: %v", err)
+	}
+	db, err := newSPVDB(filepath.Join(w.dir, "spv.db"), &w.params.GenesisBlock.Header)
+	if err != nil {
+		return nil, fmt.Errorf("error opening SPV database: %v", err)
+	}
+	w.db = db
+	if err := w.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	if w.electrum != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.electrum.run(ctx)
+		}()
+	} else if w.src == nil {
+		cs, err := newChainService(w.params, w.dir, w.peers, w.notifyBlock, w.log)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		w.src = cs
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cs.run(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.run(ctx)
+		w.mtx.Lock()
+		if w.lockTimer != nil {
+			w.lockTimer.Stop()
+		}
+		if w.ks != nil {
+			w.ks.lock()
+		}
+		w.mtx.Unlock()
+		if w.hw != nil {
+			w.hw.close()
+		}
+		if err := w.db.Close(); err != nil {
+			w.log.Errorf("error closing SPV database: %v", err)
+		}
+	}()
+	return &wg, nil
+}
+
+// load loads the keystore, transactions, and watched outputs from the
+// database.
+func (w *spvWallet) load() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	ksB, err := w.db.meta(keystoreKey)
+	if err != nil {
+		return err
+	}
+	if len(ksB) > 0 {
+		w.ks, err = decodeKeystore(ksB, w.params)
+		if err != nil {
+			return fmt.Errorf("error loading keystore: %v", err)
+		}
+		if err := w.deriveAddresses(); err != nil {
+			return err
+		}
+	}
+	heightB, err := w.db.meta(scanHeightKey)
+	if err != nil {
+		return err
+	}
+	if len(heightB) == 4 {
+		w.scanHeight = binary.BigEndian.Uint32(heightB)
+	}
+	stxs, err := w.db.storedTxs()
+	if err != nil {
+		return err
+	}
+	for _, stx := range stxs {
+		w.txs[stx.hash] = stx
+		for _, txIn := range stx.tx.TxIn {
+			w.spends[txIn.PreviousOutPoint] = stx.hash
+		}
+	}
+	w.watched, err = w.db.watchedOutputs()
+	return err
+}
+
+// run syncs with the network when a new block is announced, and periodically
+// otherwise.
+func (w *spvWallet) run(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	// Give the peers a moment to connect before the first sync. The Electrum
+	// client signals when it has connected.
+	select {
+	case <-time.After(connectInterval):
+	case <-w.blockNote:
+	case <-ctx.Done():
+		return
+	}
+	for {
+		if err := w.sync(); err != nil {
+			if err == errNoPeers {
+				w.log.Debugf("Cannot sync the SPV wallet: %v", err)
+			} else {
+				w.log.Errorf("SPV wallet sync error: %v", err)
+			}
+		}
+		select {
+		case <-w.blockNote:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyBlock signals the run loop to sync.
+func (w *spvWallet) notifyBlock() {
+	select {
+	case w.blockNote <- struct{}{}:
+	default:
+	}
+}
+
+// deriveAddresses derives the addresses up to gapLimit past the next unused
+// address on each branch. The wallet mutex must be held.
+func (w *spvWallet) deriveAddresses() error {
+	for branch := addrBranch(0); branch < numBranches; branch++ {
+		for i := uint32(0); i < w.ks.next[branch]+gapLimit; i++ {
+			info, err := w.ks.address(branch, i)
+			if err != nil {
+				return fmt.Errorf("error deriving address: %v", err)
+			}
+			w.addrs[string(info.pkScript)] = info
+		}
+	}
+	return nil
+}
+
+// markUsed advances the branch's next unused address past the address, and
+// derives more addresses to maintain the gap limit. The wallet mutex must be
+// held.
+func (w *spvWallet) markUsed(info *addrInfo) error {
+	if info.index < w.ks.next[info.branch] {
+		return nil
+	}
+	w.ks.next[info.branch] = info.index + 1
+	if err := w.deriveAddresses(); err != nil {
+		return err
+	}
+	return w.db.putMeta(keystoreKey, w.ks.encode())
+}
+
+// newAddress gets the next unused address on the branch.
+func (w *spvWallet) newAddress(branch addrBranch) (string, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.ks == nil {
+		return "", fmt.Errorf("wallet not created")
+	}
+	info, err := w.ks.address(branch, w.ks.next[branch])
+	if err != nil {
+		return "", err
+	}
+	if err := w.markUsed(info); err != nil {
+		return "", err
+	}
+	return info.addr.String(), nil
+}
+
+// relevant is true if the transaction pays to or spends from the wallet, or
+// creates or spends a watched output. relevant marks the wallet addresses
+// that the transaction pays as used. The wallet mutex must be held.
+func (w *spvWallet) relevant(tx *wire.MsgTx) bool {
+	var isRelevant bool
+	txHash := tx.TxHash()
+	for i, txOut := range tx.TxOut {
+		if info := w.addrs[string(txOut.PkScript)]; info != nil {
+			isRelevant = true
+			if err := w.markUsed(info); err != nil {
+				w.log.Errorf("error updating address index: %v", err)
+			}
+			continue
+		}
+		if _, found := w.watched[wire.OutPoint{Hash: txHash, Index: uint32(i)}]; found {
+			isRelevant = true
+		}
+	}
+	if isRelevant {
+		return true
+	}
+	for _, txIn := range tx.TxIn {
+		if _, found := w.watched[txIn.PreviousOutPoint]; found {
+			return true
+		}
+		if w.prevOutput(&txIn.PreviousOutPoint) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// prevOutput is the wallet output spent by the outpoint, or nil if the
+// outpoint is not a wallet output. The wallet mutex must be held.
+func (w *spvWallet) prevOutput(op *wire.OutPoint) *wire.TxOut {
+	stx := w.txs[op.Hash]
+	if stx == nil || int(op.Index) >= len(stx.tx.TxOut) {
+		return nil
+	}
+	txOut := stx.tx.TxOut[op.Index]
+	if w.addrs[string(txOut.PkScript)] == nil {
+		return nil
+	}
+	return txOut
+}
+
+// storeTx stores the transaction. Any unmined transaction that spends the
+// same outputs is removed. The wallet mutex must be held.
+func (w *spvWallet) storeTx(stx *storedTx) error {
+	for _, txIn := range stx.tx.TxIn {
+		spender, found := w.spends[txIn.PreviousOutPoint]
+		if !found || spender == stx.hash {
+			continue
+		}
+		if conflict := w.txs[spender]; conflict != nil && conflict.height == 0 {
+			w.log.Infof("Removing transaction %s, which is double spent by %s", spender, stx.hash)
+			if err := w.removeTx(&spender); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.db.putTx(stx); err != nil {
+		return err
+	}
+	w.txs[stx.hash] = stx
+	for _, txIn := range stx.tx.TxIn {
+		w.spends[txIn.PreviousOutPoint] = stx.hash
+	}
+	return nil
+}
+
+// removeTx removes the transaction and any stored transactions that spend its
+// outputs. The wallet mutex must be held.
+func (w *spvWallet) removeTx(txHash *chainhash.Hash) error {
+	stx := w.txs[*txHash]
+	if stx == nil {
+		return nil
+	}
+	delete(w.txs, *txHash)
+	if err := w.db.deleteTx(txHash); err != nil {
+		return err
+	}
+	for _, txIn := range stx.tx.TxIn {
+		if w.spends[txIn.PreviousOutPoint] == *txHash {
+			delete(w.spends, txIn.PreviousOutPoint)
+		}
+	}
+	for i := range stx.tx.TxOut {
+		op := wire.OutPoint{Hash: *txHash, Index: uint32(i)}
+		if spender, found := w.spends[op]; found {
+			if err := w.removeTx(&spender); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// watchOutput adds the output to the outputs that the wallet watches for
+// spends. The wallet mutex must be held.
+func (w *spvWallet) watchOutput(op *wire.OutPoint, pkScript []byte) error {
+	if _, found := w.watched[*op]; found {
+		return nil
+	}
+	w.watched[*op] = pkScript
+	return w.db.putWatched(op, pkScript)
+}
+
+// confirmations is the number of confirmations on the stored transaction. The
+// wallet mutex must be held.
+func (w *spvWallet) confirmations(stx *storedTx) int64 {
+	if stx.height == 0 || stx.height > w.scanHeight {
+		return 0
+	}
+	return int64(w.scanHeight - stx.height + 1)
+}
+
+// spvUTXO is an unspent wallet output.
+type spvUTXO struct {
+	op      wire.OutPoint
+	txOut   *wire.TxOut
+	info    *addrInfo
+	confs   int64
+	trusted bool
+	mature  bool
+}
+
+// utxos is the unspent wallet outputs. The wallet mutex must be held.
+func (w *spvWallet) utxos() []*spvUTXO {
+	var utxos []*spvUTXO
+	for txHash, stx := range w.txs {
+		confs := w.confirmations(stx)
+		// An unconfirmed transaction is trusted if it only spends wallet
+		// outputs.
+		trusted := confs > 0
+		if !trusted {
+			trusted = true
+			for _, txIn := range stx.tx.TxIn {
+				if w.prevOutput(&txIn.PreviousOutPoint) == nil {
+					trusted = false
+					break
+				}
+			}
+		}
+		mature := !blockchain.IsCoinBaseTx(stx.tx) || confs >= coinbaseMaturity
+		for i, txOut := range stx.tx.TxOut {
+			info := w.addrs[string(txOut.PkScript)]
+			if info == nil {
+				continue
+			}
+			op := wire.OutPoint{Hash: txHash, Index: uint32(i)}
+			if _, spent := w.spends[op]; spent {
+				continue
+			}
+			utxos = append(utxos, &spvUTXO{
+				op:      op,
+				txOut:   txOut,
+				info:    info,
+				confs:   confs,
+				trusted: trusted,
+				mature:  mature,
+			})
+		}
+	}
+	return utxos
+}
+
+// RawRequest handles the bitcoind wallet RPCs that ExchangeWallet uses. Part
+// of the rpcClient interface.
+func (w *spvWallet) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	var res interface{}
+	var err error
+	switch method {
+	case methodGetBalances:
+		res, err = w.balances()
+	case methodListUnspent:
+		res, err = w.listUnspent()
+	case methodLockUnspent:
+		var unlock bool
+		var ops []*RPCOutpoint
+		if err = parseParams(params, &unlock, &ops); err == nil {
+			res, err = w.lockUnspent(unlock, ops)
+		}
+	case methodListLockUnspent:
+		res, err = w.listLockUnspent()
+	case methodChangeAddress:
+		res, err = w.newAddress(branchInternal)
+	case methodNewAddress:
+		var label, addrType string
+		if err = parseParams(params, &label, &addrType); err == nil {
+			branch := branchExternal
+			if addrType == "legacy" {
+				branch = branchLegacy
+			}
+			res, err = w.newAddress(branch)
+		}
+	case methodSignTx:
+		var txHex dex.Bytes
+		if err = parseParams(params, &txHex); err == nil {
+			res, err = w.signTx(txHex)
+		}
+	case methodUnlock:
+		var pw string
+		var timeout int64
+		if err = parseParams(params, &pw, &timeout); err == nil {
+			err = w.unlock(pw, time.Duration(timeout)*time.Second)
+		}
+	case methodLock:
+		w.lock()
+	case methodPrivKeyForAddress:
+		var addr string
+		if err = parseParams(params, &addr); err == nil {
+			res, err = w.privKeyWIF(addr)
+		}
+	case methodGetTransaction:
+		var txid string
+		if err = parseParams(params, &txid); err == nil {
+			res, err = w.getTransaction(txid)
+		}
+	case methodSendToAddress:
+		var addr, comment, commentTo string
+		var amt float64
+		var subtract bool
+		if err = parseParams(params, &addr, &amt, &comment, &commentTo, &subtract); err == nil {
+			res, err = w.sendToAddress(addr, toSatoshi(amt), subtract)
+		}
+	case methodSetTxFee:
+		var feeRate float64
+		if err = parseParams(params, &feeRate); err == nil {
+			w.mtx.Lock()
+			w.feeRate = toSatoshi(feeRate)
+			w.mtx.Unlock()
+			res = true
+		}
+	case methodGetBlockHeader:
+		var blockHash string
+		if err = parseParams(params, &blockHash); err == nil {
+			res, err = w.blockHeader(blockHash)
+		}
+	case methodGetBlockVerboseTx:
+		var blockHash string
+		if err = parseParams(params, &blockHash); err == nil {
+			res, err = w.verboseBlock(blockHash)
+		}
+	case methodRescan:
+		var height int64
+		if err = parseParams(params, &height); err == nil {
+			err = w.rescan(uint32(height))
+		}
+	default:
+		return nil, fmt.Errorf("method %s is not supported by the SPV wallet", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// parseParams decodes the RPC parameters into the targets. Targets for missing
+// parameters are not modified.
+func parseParams(params []json.RawMessage, targets ...interface{}) error {
+	for i, param := range params {
+		if i >= len(targets) {
+			break
+		}
+		if err := json.Unmarshal(param, targets[i]); err != nil {
+			return fmt.Errorf("error decoding parameter %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// balances is the wallet balance. Confirmed outputs and unconfirmed outputs
+// from transactions that only spend wallet outputs are trusted.
+func (w *spvWallet) balances() (*GetBalancesResult, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	res := new(GetBalancesResult)
+	for _, utxo := range w.utxos() {
+		amt := btcutil.Amount(utxo.txOut.Value).ToBTC()
+		switch {
+		case !utxo.mature:
+			res.Mine.Immature += amt
+		case utxo.trusted:
+			res.Mine.Trusted += amt
+		default:
+			res.Mine.Untrusted += amt
+		}
+	}
+	return res, nil
+}
+
+// listUnspent lists the spendable wallet outputs that are not locked.
+func (w *spvWallet) listUnspent() ([]*ListUnspentResult, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	unspents := make([]*ListUnspentResult, 0)
+	for _, utxo := range w.utxos() {
+		if !utxo.mature || w.locked[utxo.op] {
+			continue
+		}
+		unspents = append(unspents, &ListUnspentResult{
+			TxID:          utxo.op.Hash.String(),
+			Vout:          utxo.op.Index,
+			Address:       utxo.info.addr.String(),
+			ScriptPubKey:  utxo.txOut.PkScript,
+			Amount:        btcutil.Amount(utxo.txOut.Value).ToBTC(),
+			Confirmations: uint32(utxo.confs),
+			Spendable:     true,
+			Solvable:      true,
+			Safe:          utxo.trusted,
+		})
+	}
+	return unspents, nil
+}
+
+// lockUnspent locks or unlocks the outputs. If unlock is true and no outputs
+// are specified, all outputs are unlocked.
+func (w *spvWallet) lockUnspent(unlock bool, ops []*RPCOutpoint) (bool, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if unlock && len(ops) == 0 {
+		w.locked = make(map[wire.OutPoint]bool)
+		return true, nil
+	}
+	for _, rpcOp := range ops {
+		txHash, err := chainhash.NewHashFromStr(rpcOp.TxID)
+		if err != nil {
+			return false, err
+		}
+		op := wire.OutPoint{Hash: *txHash, Index: rpcOp.Vout}
+		if unlock {
+			delete(w.locked, op)
+		} else {
+			w.locked[op] = true
+		}
+	}
+	return true, nil
+}
+
+// listLockUnspent lists the locked outputs.
+func (w *spvWallet) listLockUnspent() ([]*RPCOutpoint, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	ops := make([]*RPCOutpoint, 0, len(w.locked))
+	for op := range w.locked {
+		ops = append(ops, &RPCOutpoint{
+			TxID: op.Hash.String(),
+			Vout: op.Index,
+		})
+	}
+	return ops, nil
+}
+
+// unlock unlocks the keystore, locking it again after the timeout, if
+// non-zero. If the wallet has no keystore, one is created with the password.
+func (w *spvWallet) unlock(pw string, timeout time.Duration) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.ks == nil {
+		var ks *keystore
+		var err error
+		if w.hw != nil {
+			ks, err = w.hw.newKeystore([]byte(pw), w.params)
+		} else {
+			ks, err = newKeystore([]byte(pw), w.seed, w.params)
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.db.putMeta(keystoreKey, ks.encode()); err != nil {
+			return err
+		}
+		w.ks = ks
+		encode.ClearBytes(w.seed)
+		w.seed = nil
+		if err := w.deriveAddresses(); err != nil {
+			return err
+		}
+		w.log.Infof("Created a new %s SPV wallet", w.params.Name)
+	} else if err := w.ks.unlock([]byte(pw)); err != nil {
+		return err
+	}
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+	if timeout > 0 {
+		w.lockTimer = time.AfterFunc(timeout, w.lock)
+	}
+	return nil
+}
+
+// lock locks the keystore.
+func (w *spvWallet) lock() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.ks != nil {
+		w.ks.lock()
+	}
+}
+
+// privKeyWIF is the WIF-encoded private key for the wallet address.
+func (w *spvWallet) privKeyWIF(addrStr string) (string, error) {
+	addr, err := btcutil.DecodeAddress(addrStr, w.params)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	info := w.addrs[string(pkScript)]
+	if info == nil {
+		return "", fmt.Errorf("address %s is not a wallet address", addrStr)
+	}
+	privKey, err := w.ks.privKey(info)
+	if err != nil {
+		return "", err
+	}
+	wif, err := btcutil.NewWIF(privKey, w.params, true)
+	if err != nil {
+		return "", err
+	}
+	return wif.String(), nil
+}
+
+// signTx signs the transaction inputs that spend wallet outputs. Inputs that
+// spend other outputs are reported as errors.
+func (w *spvWallet) signTx(txB []byte) (*SignTxResult, error) {
+	tx, err := msgTxFromHex(txB)
+	if err != nil {
+		return nil, err
+	}
+	res := new(SignTxResult)
+	if err := w.sign(tx, res); err != nil {
+		return nil, err
+	}
+	res.Complete = len(res.Errors) == 0
+	res.Hex, err = serializeMsgTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// sign signs the transaction inputs that spend wallet outputs, adding any
+// signing errors to the result. The wallet mutex must not be held, since a
+// hardware wallet may wait for the user.
+func (w *spvWallet) sign(tx *wire.MsgTx, res *SignTxResult) error {
+	if w.hw != nil {
+		return w.hwSignInputs(tx, res)
+	}
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	if w.ks == nil {
+		return fmt.Errorf("wallet not created")
+	}
+	return w.signInputs(tx, res)
+}
+
+// addSignError adds an error signing the input to the result.
+func addSignError(res *SignTxResult, txIn *wire.TxIn, err error) {
+	res.Errors = append(res.Errors, &SignTxError{
+		TxID:     txIn.PreviousOutPoint.Hash.String(),
+		Vout:     txIn.PreviousOutPoint.Index,
+		Sequence: uint64(txIn.Sequence),
+		Error:    err.Error(),
+	})
+}
+
+// signInputs signs the transaction inputs that spend wallet outputs with the
+// keystore's private keys. The wallet mutex must be held.
+func (w *spvWallet) signInputs(tx *wire.MsgTx, res *SignTxResult) error {
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i, txIn := range tx.TxIn {
+		prevOut := w.prevOutput(&txIn.PreviousOutPoint)
+		if prevOut == nil {
+			addSignError(res, txIn, fmt.Errorf("input not found or already spent"))
+			continue
+		}
+		info := w.addrs[string(prevOut.PkScript)]
+		privKey, err := w.ks.privKey(info)
+		if err != nil {
+			return err
+		}
+		if info.branch == branchLegacy {
+			txIn.SignatureScript, err = txscript.SignatureScript(tx, i, prevOut.PkScript, txscript.SigHashAll, privKey, true)
+		} else {
+			txIn.Witness, err = txscript.WitnessSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript,
+				txscript.SigHashAll, privKey, true)
+		}
+		if err != nil {
+			addSignError(res, txIn, err)
+		}
+	}
+	return nil
+}
+
+// getTransaction describes the wallet transaction. An RPCError with code
+// ErrRPCInvalidAddressOrKey is returned if the transaction is unknown, as with
+// bitcoind.
+func (w *spvWallet) getTransaction(txid string) (*GetTransactionResult, error) {
+	txHash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, err
+	}
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	stx := w.txs[*txHash]
+	if stx == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid or non-wallet transaction id",
+		}
+	}
+	txB, err := serializeMsgTx(stx.tx)
+	if err != nil {
+		return nil, err
+	}
+	res := &GetTransactionResult{
+		Confirmations: uint64(w.confirmations(stx)),
+		TxID:          txid,
+		Time:          uint64(stx.received.Unix()),
+		TimeReceived:  uint64(stx.received.Unix()),
+		Hex:           txB,
+	}
+	if stx.height > 0 {
+		res.BlockHash = stx.blockHash.String()
+		res.BlockIndex = int64(stx.blockIndex)
+		res.BlockTime = uint64(stx.received.Unix())
+		if hdr, _, err := w.db.header(stx.height); err == nil {
+			res.BlockTime = uint64(hdr.Timestamp.Unix())
+		}
+	}
+	// The transaction was sent by the wallet if all of the inputs are wallet
+	// outputs.
+	var debit int64
+	sent := len(stx.tx.TxIn) > 0
+	for _, txIn := range stx.tx.TxIn {
+		prevOut := w.prevOutput(&txIn.PreviousOutPoint)
+		if prevOut == nil {
+			sent = false
+			continue
+		}
+		debit += prevOut.Value
+	}
+	var credit, outs int64
+	for i, txOut := range stx.tx.TxOut {
+		outs += txOut.Value
+		var addr string
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, w.params)
+		if len(addrs) == 1 {
+			addr = addrs[0].String()
+		}
+		if w.addrs[string(txOut.PkScript)] != nil {
+			credit += txOut.Value
+			category := TxCatReceive
+			if blockchain.IsCoinBaseTx(stx.tx) {
+				category = TxCatGenerate
+				if res.Confirmations < coinbaseMaturity {
+					category = TxCatImmature
+				}
+			}
+			res.Details = append(res.Details, &WalletTxDetails{
+				Address:  addr,
+				Category: category,
+				Amount:   btcutil.Amount(txOut.Value).ToBTC(),
+				Vout:     uint32(i),
+			})
+			continue
+		}
+		if sent {
+			res.Details = append(res.Details, &WalletTxDetails{
+				Address:  addr,
+				Category: TxCatSend,
+				Amount:   -btcutil.Amount(txOut.Value).ToBTC(),
+				Vout:     uint32(i),
+			})
+		}
+	}
+	res.Amount = btcutil.Amount(credit - debit).ToBTC()
+	if sent {
+		res.Fee = -btcutil.Amount(debit - outs).ToBTC()
+		res.Amount = btcutil.Amount(credit - debit - (debit - outs)).ToBTC()
+		for _, d := range res.Details {
+			if d.Category == TxCatSend {
+				d.Fee = res.Fee
+			}
+		}
+	}
+	return res, nil
+}
+
+// sendToAddress sends the value to the address, with change to an internal
+// address. If subtract is true, the fee is subtracted from the sent value.
+func (w *spvWallet) sendToAddress(addrStr string, value uint64, subtract bool) (string, error) {
+	addr, err := btcutil.DecodeAddress(addrStr, w.params)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %v", addrStr, err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+	changeAddr, err := w.newAddress(branchInternal)
+	if err != nil {
+		return "", err
+	}
+	changeScript, err := txscript.PayToAddrScript(mustDecodeAddress(changeAddr, w.params))
+	if err != nil {
+		return "", err
+	}
+
+	w.mtx.Lock()
+	feeRate := w.feeRate / 1000
+	if feeRate == 0 {
+		feeRate = defaultFee
+	}
+	utxos := w.utxos()
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].txOut.Value > utxos[j].txOut.Value })
+	tx := wire.NewMsgTx(wire.TxVersion)
+	payment := wire.NewTxOut(int64(value), pkScript)
+	tx.AddTxOut(payment)
+	size := uint64(dexbtc.MimimumTxOverhead + payment.SerializeSize())
+	var sum, fee uint64
+	for _, utxo := range utxos {
+		if !utxo.mature || !utxo.trusted || w.locked[utxo.op] {
+			continue
+		}
+		nfo, err := dexbtc.InputInfo(utxo.txOut.PkScript, nil, w.params)
+		if err != nil {
+			w.mtx.Unlock()
+			return "", err
+		}
+		tx.AddTxIn(wire.NewTxIn(&utxo.op, nil, nil))
+		size += uint64(nfo.VBytes())
+		sum += uint64(utxo.txOut.Value)
+		fee = size * feeRate
+		if subtract && sum >= value || sum >= value+fee {
+			break
+		}
+	}
+	if subtract {
+		if sum < value || value <= fee {
+			w.mtx.Unlock()
+			return "", fmt.Errorf("insufficient funds. %d available to send %d", sum, value)
+		}
+		payment.Value = int64(value - fee)
+	} else if sum < value+fee {
+		w.mtx.Unlock()
+		return "", fmt.Errorf("insufficient funds. %d available to send %d plus fees %d", sum, value, fee)
+	}
+	change := wire.NewTxOut(int64(sum-value-fee), changeScript)
+	if subtract {
+		change.Value = int64(sum - value)
+	}
+	changeFee := uint64(change.SerializeSize()) * feeRate
+	if change.Value > int64(changeFee) {
+		change.Value -= int64(changeFee)
+		if !dexbtc.IsDust(change, feeRate) {
+			tx.AddTxOut(change)
+		}
+	}
+	w.mtx.Unlock()
+	res := new(SignTxResult)
+	if err := w.sign(tx, res); err != nil {
+		return "", err
+	}
+	if len(res.Errors) > 0 {
+		return "", fmt.Errorf("error signing transaction: %s", res.Errors[0].Error)
+	}
+	txHash, err := w.SendRawTransaction(tx, false)
+	if err != nil {
+		return "", err
+	}
+	return txHash.String(), nil
+}
+
+// mustDecodeAddress decodes an address created by the wallet.
+func mustDecodeAddress(addr string, params *chaincfg.Params) btcutil.Address {
+	a, err := btcutil.DecodeAddress(addr, params)
+	if err != nil {
+		panic(fmt.Sprintf("error decoding wallet address %s: %v", addr, err))
+	}
+	return a
+}
+
+// blockHeader describes the main chain block.
+func (w *spvWallet) blockHeader(blockHashStr string) (*blockHeader, error) {
+	blockHash, err := chainhash.NewHashFromStr(blockHashStr)
+	if err != nil {
+		return nil, err
+	}
+	height, found, err := w.db.blockHeight(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("block %s not found", blockHash)
+	}
+	hdr, _, err := w.db.header(height)
+	if err != nil {
+		return nil, err
+	}
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return nil, err
+	}
+	// The median time is the median timestamp of the last 11 blocks.
+	var stamps []int64
+	for h := int64(height); h >= 0 && h > int64(height)-11; h-- {
+		prev, _, err := w.db.header(uint32(h))
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, prev.Timestamp.Unix())
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i] < stamps[j] })
+	return &blockHeader{
+		Hash:          blockHashStr,
+		Confirmations: int64(tipHeight-height) + 1,
+		Height:        int32(height),
+		Time:          hdr.Timestamp.Unix(),
+		MedianTime:    stamps[len(stamps)/2],
+	}, nil
+}
+
+// verboseBlock requests the main chain block from the network, and describes
+// its transactions.
+func (w *spvWallet) verboseBlock(blockHashStr string) (*verboseBlockTxs, error) {
+	blockHash, err := chainhash.NewHashFromStr(blockHashStr)
+	if err != nil {
+		return nil, err
+	}
+	height, found, err := w.db.blockHeight(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("block %s not found", blockHash)
+	}
+	if w.electrum != nil {
+		return w.electrumVerboseBlock(blockHash, height)
+	}
+	blk, err := w.fetchBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	res := &verboseBlockTxs{
+		Hash:   blockHashStr,
+		Height: uint64(height),
+		Tx:     make([]btcjson.TxRawResult, 0, len(blk.Transactions)),
+	}
+	for _, tx := range blk.Transactions {
+		rawTx, err := w.txRawResult(tx)
+		if err != nil {
+			return nil, err
+		}
+		rawTx.BlockHash = blockHashStr
+		res.Tx = append(res.Tx, *rawTx)
+	}
+	return res, nil
+}
+
+// txRawResult describes the transaction in the format of bitcoind's
+// getrawtransaction.
+func (w *spvWallet) txRawResult(tx *wire.MsgTx) (*btcjson.TxRawResult, error) {
+	txB, err := serializeMsgTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	res := &btcjson.TxRawResult{
+		Hex:      hex.EncodeToString(txB),
+		Txid:     tx.TxHash().String(),
+		Hash:     tx.WitnessHash().String(),
+		Size:     int32(tx.SerializeSize()),
+		Version:  tx.Version,
+		LockTime: tx.LockTime,
+		Vin:      make([]btcjson.Vin, 0, len(tx.TxIn)),
+		Vout:     make([]btcjson.Vout, 0, len(tx.TxOut)),
+	}
+	isCoinbase := blockchain.IsCoinBaseTx(tx)
+	for _, txIn := range tx.TxIn {
+		vin := btcjson.Vin{Sequence: txIn.Sequence}
+		if isCoinbase {
+			vin.Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		} else {
+			vin.Txid = txIn.PreviousOutPoint.Hash.String()
+			vin.Vout = txIn.PreviousOutPoint.Index
+			vin.ScriptSig = &btcjson.ScriptSig{Hex: hex.EncodeToString(txIn.SignatureScript)}
+		}
+		for _, item := range txIn.Witness {
+			vin.Witness = append(vin.Witness, hex.EncodeToString(item))
+		}
+		res.Vin = append(res.Vin, vin)
+	}
+	for i, txOut := range tx.TxOut {
+		res.Vout = append(res.Vout, btcjson.Vout{
+			Value:        btcutil.Amount(txOut.Value).ToBTC(),
+			N:            uint32(i),
+			ScriptPubKey: w.scriptPubKeyResult(txOut.PkScript),
+		})
+	}
+	return res, nil
+}
+
+// scriptPubKeyResult describes the pubkey script.
+func (w *spvWallet) scriptPubKeyResult(pkScript []byte) btcjson.ScriptPubKeyResult {
+	class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript, w.params)
+	res := btcjson.ScriptPubKeyResult{
+		Hex:     hex.EncodeToString(pkScript),
+		ReqSigs: int32(reqSigs),
+		Type:    class.String(),
+	}
+	for _, addr := range addrs {
+		res.Addresses = append(res.Addresses, addr.String())
+	}
+	return res
+}
+
+// EstimateSmartFee is not available in SPV mode, so the fallback fee rate is
+// used. An Electrum server wallet requests the server's estimate. Part of the
+// rpcClient interface.
+func (w *spvWallet) EstimateSmartFee(confTarget int64, _ *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error) {
+	if w.electrum != nil {
+		feeRate, err := w.electrum.estimateFee(confTarget)
+		if err != nil {
+			return nil, err
+		}
+		if feeRate > 0 {
+			return &btcjson.EstimateSmartFeeResult{
+				FeeRate: &feeRate,
+				Blocks:  confTarget,
+			}, nil
+		}
+	}
+	return &btcjson.EstimateSmartFeeResult{
+		Errors: []string{"fee estimation is not available in SPV mode"},
+	}, nil
+}
+
+// SendRawTransaction broadcasts the transaction. If the transaction is
+// relevant to the wallet, it is stored as unmined. Any P2SH outputs are
+// watched, so that their spends, e.g. swap contract redemptions, are found.
+// Part of the rpcClient interface.
+func (w *spvWallet) SendRawTransaction(tx *wire.MsgTx, _ bool) (*chainhash.Hash, error) {
+	if err := w.src.sendTx(tx); err != nil {
+		return nil, err
+	}
+	txHash := tx.TxHash()
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for i, txOut := range tx.TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.ScriptHashTy {
+			if err := w.watchOutput(&wire.OutPoint{Hash: txHash, Index: uint32(i)}, txOut.PkScript); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if w.txs[txHash] == nil && w.relevant(tx) {
+		err := w.storeTx(&storedTx{
+			tx:       tx,
+			hash:     txHash,
+			received: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &txHash, nil
+}
+
+// GetTxOut describes the unspent output. Only outputs of stored transactions
+// are known. If mempool is false, spends by unmined transactions are ignored.
+// Part of the rpcClient interface.
+func (w *spvWallet) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	stx := w.txs[*txHash]
+	if stx == nil || int(index) >= len(stx.tx.TxOut) || (!mempool && stx.height == 0) {
+		return nil, nil
+	}
+	if spender, found := w.spends[wire.OutPoint{Hash: *txHash, Index: index}]; found {
+		if spendTx := w.txs[spender]; spendTx != nil && (mempool || spendTx.height > 0) {
+			return nil, nil
+		}
+	}
+	bestHash, err := w.db.blockHash(w.scanHeight)
+	if err != nil {
+		return nil, err
+	}
+	txOut := stx.tx.TxOut[index]
+	return &btcjson.GetTxOutResult{
+		BestBlock:     bestHash.String(),
+		Confirmations: w.confirmations(stx),
+		Value:         btcutil.Amount(txOut.Value).ToBTC(),
+		ScriptPubKey:  w.scriptPubKeyResult(txOut.PkScript),
+		Coinbase:      blockchain.IsCoinBaseTx(stx.tx),
+	}, nil
+}
+
+// GetBlockHash is the hash of the main chain block at the height. Blocks above
+// the scan height are not reported. Part of the rpcClient interface.
+func (w *spvWallet) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	w.mtx.RLock()
+	scanHeight := w.scanHeight
+	w.mtx.RUnlock()
+	if blockHeight < 0 || blockHeight > int64(scanHeight) {
+		return nil, fmt.Errorf("block height %d out of range", blockHeight)
+	}
+	return w.db.blockHash(uint32(blockHeight))
+}
+
+// GetBestBlockHash is the hash of the block at the scan height. Part of the
+// rpcClient interface.
+func (w *spvWallet) GetBestBlockHash() (*chainhash.Hash, error) {
+	w.mtx.RLock()
+	scanHeight := w.scanHeight
+	w.mtx.RUnlock()
+	return w.db.blockHash(scanHeight)
+}
+
+// GetRawMempool lists the unmined wallet transactions. The SPV wallet does not
+// see the rest of the mempool. Part of the rpcClient interface.
+func (w *spvWallet) GetRawMempool() ([]*chainhash.Hash, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	var hashes []*chainhash.Hash
+	for txHash, stx := range w.txs {
+		if stx.height == 0 {
+			h := txHash
+			hashes = append(hashes, &h)
+		}
+	}
+	return hashes, nil
+}
+
+// GetRawTransactionVerbose describes the stored transaction. If the
+// transaction is not stored, it is requested from the mempools of the
+// network's peers. Part of the rpcClient interface.
+func (w *spvWallet) GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	w.mtx.RLock()
+	stx := w.txs[*txHash]
+	var confs int64
+	if stx != nil {
+		confs = w.confirmations(stx)
+	}
+	w.mtx.RUnlock()
+	if stx == nil {
+		tx, err := w.src.getTx(txHash)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "No such mempool or blockchain transaction",
+			}
+		}
+		return w.txRawResult(tx)
+	}
+	res, err := w.txRawResult(stx.tx)
+	if err != nil {
+		return nil, err
+	}
+	res.Confirmations = uint64(confs)
+	if stx.height > 0 {
+		res.BlockHash = stx.blockHash.String()
+	}
+	return res, nil
+}
+
+// findOutput finds the transaction with the output, which must pay to the
+// pubkey script, and stores it. The output is watched for spends. The
+// transaction is requested from the network's mempools first. If it's not
+// there, the filters of recent blocks are scanned for the pubkey script. An
+// Electrum server wallet looks in the pubkey script's history instead.
+// findOutput is used to locate a counterparty's swap contract.
+func (w *spvWallet) findOutput(txHash *chainhash.Hash, vout uint32, pkScript []byte) error {
+	op := wire.OutPoint{Hash: *txHash, Index: vout}
+	store := func(stx *storedTx) error {
+		if int(vout) >= len(stx.tx.TxOut) || string(stx.tx.TxOut[vout].PkScript) != string(pkScript) {
+			return fmt.Errorf("transaction %s output %d does not pay to the expected script", txHash, vout)
+		}
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		if err := w.watchOutput(&op, pkScript); err != nil {
+			return err
+		}
+		if known := w.txs[*txHash]; known != nil && known.height >= stx.height {
+			return nil
+		}
+		return w.storeTx(stx)
+	}
+
+	w.mtx.RLock()
+	known := w.txs[*txHash]
+	scanHeight := w.scanHeight
+	w.mtx.RUnlock()
+	if known != nil && known.height > 0 {
+		return store(known)
+	}
+	if w.electrum != nil {
+		stx, err := w.electrumFindTx(txHash, pkScript)
+		if err != nil {
+			return err
+		}
+		if stx != nil {
+			return store(stx)
+		}
+		if known != nil {
+			return store(known)
+		}
+		return fmt.Errorf("transaction %s not found", txHash)
+	}
+	tx, err := w.src.getTx(txHash)
+	if err != nil {
+		return err
+	}
+	if tx != nil {
+		return store(&storedTx{
+			tx:       tx,
+			hash:     *txHash,
+			received: time.Now(),
+		})
+	}
+
+	var start uint32
+	if scanHeight >= auditScanBlocks {
+		start = scanHeight - auditScanBlocks + 1
+	}
+	for stop := scanHeight; stop >= start && stop > 0; {
+		first := start
+		if stop-first+1 > wire.MaxGetCFiltersReqRange {
+			first = stop - wire.MaxGetCFiltersReqRange + 1
+		}
+		filters, hashes, err := w.blockFilters(first, stop)
+		if err != nil {
+			return err
+		}
+		// Newest first.
+		for i := len(filters) - 1; i >= 0; i-- {
+			match, err := matchFilter(filters[i], hashes[i], [][]byte{pkScript})
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			blk, err := w.fetchBlock(hashes[i])
+			if err != nil {
+				return err
+			}
+			for idx, tx := range blk.Transactions {
+				if tx.TxHash() == *txHash {
+					return store(&storedTx{
+						tx:         tx,
+						hash:       *txHash,
+						height:     first + uint32(i),
+						blockHash:  *hashes[i],
+						blockIndex: uint32(idx),
+						received:   blk.Header.Timestamp,
+					})
+				}
+			}
+		}
+		if first == 0 {
+			break
+		}
+		stop = first - 1
+	}
+	if known != nil {
+		return store(known)
+	}
+	return fmt.Errorf("transaction %s not found", txHash)
+}
@@ -0,0 +1,888 @@
+// +build !harness
+
+package btc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	dexbtc "decred.org/dcrdex/dex/networks/btc"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/lightninglabs/neutrino/filterdb"
+	"github.com/lightninglabs/neutrino/headerfs"
+)
+
+var tSPVParams = &chaincfg.RegressionNetParams
+
+// tBlockSource is an in-memory blockchain that satisfies blockSource.
+type tBlockSource struct {
+	mtx        sync.Mutex
+	blocks     []*wire.MsgBlock
+	filters    []*gcs.Filter
+	filterHdrs []chainhash.Hash
+	outputs    map[wire.OutPoint][]byte
+	mempool    map[chainhash.Hash]*wire.MsgTx
+	sent       []*wire.MsgTx
+	sendErr    error
+	stamp      time.Time
+}
+
+func newTBlockSource() *tBlockSource {
+	s := &tBlockSource{
+		outputs: make(map[wire.OutPoint][]byte),
+		mempool: make(map[chainhash.Hash]*wire.MsgTx),
+		stamp:   time.Now(),
+	}
+	s.addBlock(tSPVParams.GenesisBlock)
+	return s
+}
+
+func (s *tBlockSource) addBlock(blk *wire.MsgBlock) {
+	var prevScripts [][]byte
+	for _, tx := range blk.Transactions {
+		for _, txIn := range tx.TxIn {
+			if script := s.outputs[txIn.PreviousOutPoint]; script != nil {
+				prevScripts = append(prevScripts, script)
+			}
+		}
+	}
+	for _, tx := range blk.Transactions {
+		txHash := tx.TxHash()
+		for i, txOut := range tx.TxOut {
+			s.outputs[wire.OutPoint{Hash: txHash, Index: uint32(i)}] = txOut.PkScript
+		}
+		delete(s.mempool, txHash)
+	}
+	filter, err := builder.BuildBasicFilter(blk, prevScripts)
+	if err != nil {
+		panic(err.Error())
+	}
+	var prevHdr chainhash.Hash
+	if len(s.filterHdrs) > 0 {
+		prevHdr = s.filterHdrs[len(s.filterHdrs)-1]
+	}
+	hdr, err := builder.MakeHeaderForFilter(filter, prevHdr)
+	if err != nil {
+		panic(err.Error())
+	}
+	s.blocks = append(s.blocks, blk)
+	s.filters = append(s.filters, filter)
+	s.filterHdrs = append(s.filterHdrs, hdr)
+}
+
+// mine mines a block with the transactions.
+func (s *tBlockSource) mine(txs ...*wire.MsgTx) *wire.MsgBlock {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	height := len(s.blocks)
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32),
+		[]byte(fmt.Sprintf("height %d %d", height, time.Now().UnixNano())), nil))
+	coinbase.AddTxOut(wire.NewTxOut(50e8, tP2PKHScript()))
+	blk := wire.NewMsgBlock(&wire.BlockHeader{
+		Version:   4,
+		PrevBlock: s.blocks[height-1].BlockHash(),
+		Timestamp: s.stamp.Add(time.Duration(height) * time.Second),
+		Bits:      tSPVParams.PowLimitBits,
+	})
+	blk.AddTransaction(coinbase)
+	for _, tx := range txs {
+		blk.AddTransaction(tx)
+	}
+	utilTxs := make([]*btcutil.Tx, 0, len(blk.Transactions))
+	for _, tx := range blk.Transactions {
+		utilTxs = append(utilTxs, btcutil.NewTx(tx))
+	}
+	merkles := blockchain.BuildMerkleTreeStore(utilTxs, false)
+	blk.Header.MerkleRoot = *merkles[len(merkles)-1]
+	target := blockchain.CompactToBig(blk.Header.Bits)
+	for {
+		hash := blk.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			break
+		}
+		blk.Header.Nonce++
+	}
+	s.addBlock(blk)
+	return blk
+}
+
+// truncate removes the blocks above the height, for a reorg.
+func (s *tBlockSource) truncate(height int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.blocks = s.blocks[:height+1]
+	s.filters = s.filters[:height+1]
+	s.filterHdrs = s.filterHdrs[:height+1]
+	// Make sure the new blocks have different hashes.
+	s.stamp = s.stamp.Add(time.Hour)
+}
+
+func (s *tBlockSource) heightOf(hash *chainhash.Hash) (int, bool) {
+	for i, blk := range s.blocks {
+		if blk.BlockHash() == *hash {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *tBlockSource) peerCount() int {
+	return 1
+}
+
+func (s *tBlockSource) getHeaders(locator blockchain.BlockLocator) ([]*wire.BlockHeader, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, hash := range locator {
+		height, found := s.heightOf(hash)
+		if !found {
+			continue
+		}
+		var hdrs []*wire.BlockHeader
+		for _, blk := range s.blocks[height+1:] {
+			if len(hdrs) == wire.MaxBlockHeadersPerMsg {
+				break
+			}
+			hdr := blk.Header
+			hdrs = append(hdrs, &hdr)
+		}
+		return hdrs, nil
+	}
+	return nil, fmt.Errorf("no common block")
+}
+
+func (s *tBlockSource) getFilterHeaders(start uint32, stopHash *chainhash.Hash) ([]chainhash.Hash, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stop, found := s.heightOf(stopHash)
+	if !found {
+		return nil, fmt.Errorf("unknown stop hash")
+	}
+	return append([]chainhash.Hash(nil), s.filterHdrs[start:stop+1]...), nil
+}
+
+func (s *tBlockSource) getFilters(start uint32, stopHash *chainhash.Hash, count int) ([]*wire.MsgCFilter, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stop, found := s.heightOf(stopHash)
+	if !found {
+		return nil, fmt.Errorf("unknown stop hash")
+	}
+	var msgs []*wire.MsgCFilter
+	for h := int(start); h <= stop; h++ {
+		b, _ := s.filters[h].NBytes()
+		msgs = append(msgs, wire.NewMsgCFilter(wire.GCSFilterRegular, &s.blocks[h].Header.PrevBlock, b))
+		msgs[len(msgs)-1].BlockHash = s.blocks[h].BlockHash()
+	}
+	return msgs, nil
+}
+
+func (s *tBlockSource) getBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	height, found := s.heightOf(hash)
+	if !found {
+		return nil, fmt.Errorf("block not found")
+	}
+	return s.blocks[height], nil
+}
+
+func (s *tBlockSource) getTx(hash *chainhash.Hash) (*wire.MsgTx, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.mempool[*hash], nil
+}
+
+func (s *tBlockSource) sendTx(tx *wire.MsgTx) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+	s.sent = append(s.sent, tx)
+	s.mempool[tx.TxHash()] = tx
+	return nil
+}
+
+func tP2PKHScript() []byte {
+	addr, _ := btcutil.NewAddressPubKeyHash(randBytes(20), tSPVParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	return pkScript
+}
+
+// tFundingTx is a transaction from outside of the wallet that pays to the
+// address.
+func tFundingTx(t *testing.T, addr string, value int64) *wire.MsgTx {
+	t.Helper()
+	a, err := btcutil.DecodeAddress(addr, tSPVParams)
+	if err != nil {
+		t.Fatalf("error decoding address %s: %v", addr, err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(a)
+	var prevHash chainhash.Hash
+	copy(prevHash[:], randBytes(32))
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), randBytes(100), nil))
+	tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	return tx
+}
+
+// tSPVWallet creates and connects an SPV wallet in the directory.
+func tSPVWallet(t *testing.T, dir string, src *tBlockSource) (*spvWallet, func()) {
+	t.Helper()
+	w := newSPVWallet(&SPVConfig{SPV: true, Dir: dir}, tSPVParams, tLogger)
+	w.src = src
+	ctx, cancel := context.WithCancel(tCtx)
+	wg, err := w.connect(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("connect error: %v", err)
+	}
+	return w, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// spvBalance sums the wallet's unspent outputs.
+func (w *spvWallet) spvBalance() uint64 {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	var sum uint64
+	for _, utxo := range w.utxos() {
+		sum += uint64(utxo.txOut.Value)
+	}
+	return sum
+}
+
+func tSync(t *testing.T, w *spvWallet) {
+	t.Helper()
+	if err := w.sync(); err != nil {
+		t.Fatalf("sync error: %v", err)
+	}
+}
+
+func tCall(t *testing.T, w *spvWallet, method string, args anylist, thing interface{}) error {
+	t.Helper()
+	return newWalletClient(w, tSPVParams).call(method, args, thing)
+}
+
+func TestLoadSPVConfig(t *testing.T) {
+	cfg, err := loadSPVConfig(map[string]string{"rpcuser": "user"}, "btc", tSPVParams)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected no config, got %v, %v", cfg, err)
+	}
+	cfg, err = loadSPVConfig(map[string]string{"spv": "0"}, "btc", tSPVParams)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected no config for disabled SPV, got %v, %v", cfg, err)
+	}
+	cfg, err = loadSPVConfig(map[string]string{"spv": "1", "peers": "127.0.0.1, 10.0.0.1:18555"}, "btc", tSPVParams)
+	if err != nil {
+		t.Fatalf("config error: %v", err)
+	}
+	if !cfg.SPV || cfg.Dir == "" {
+		t.Fatalf("wrong config %+v", cfg)
+	}
+	w := newSPVWallet(cfg, tSPVParams, tLogger)
+	if len(w.peers) != 2 || w.peers[0] != "127.0.0.1:"+tSPVParams.DefaultPort || w.peers[1] != "10.0.0.1:18555" {
+		t.Fatalf("wrong peers %v", w.peers)
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	pw := []byte("abc")
+	if _, err := newKeystore(nil, nil, tSPVParams); err == nil {
+		t.Fatalf("no error for empty password")
+	}
+	ks, err := newKeystore(pw, nil, tSPVParams)
+	if err != nil {
+		t.Fatalf("newKeystore error: %v", err)
+	}
+	ks.next[branchLegacy] = 5
+	info, err := ks.address(branchLegacy, 3)
+	if err != nil {
+		t.Fatalf("address error: %v", err)
+	}
+	privKey, err := ks.privKey(info)
+	if err != nil {
+		t.Fatalf("privKey error: %v", err)
+	}
+	if !bytes.Equal(btcutil.Hash160(privKey.PubKey().SerializeCompressed()), info.addr.ScriptAddress()) {
+		t.Fatalf("private key doesn't match address")
+	}
+
+	reKS, err := decodeKeystore(ks.encode(), tSPVParams)
+	if err != nil {
+		t.Fatalf("decodeKeystore error: %v", err)
+	}
+	if !reKS.locked() {
+		t.Fatalf("decoded keystore not locked")
+	}
+	if reKS.next != ks.next || reKS.birthday.Unix() != ks.birthday.Unix() {
+		t.Fatalf("decoded keystore mismatch")
+	}
+	reInfo, _ := reKS.address(branchLegacy, 3)
+	if reInfo.addr.String() != info.addr.String() {
+		t.Fatalf("decoded keystore derives different addresses")
+	}
+	if _, err := reKS.privKey(reInfo); err == nil {
+		t.Fatalf("no error for private key from locked keystore")
+	}
+	if err := reKS.unlock([]byte("wrong")); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	if err := reKS.unlock(pw); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	rePrivKey, err := reKS.privKey(reInfo)
+	if err != nil {
+		t.Fatalf("privKey error: %v", err)
+	}
+	if !bytes.Equal(rePrivKey.Serialize(), privKey.Serialize()) {
+		t.Fatalf("wrong private key after unlock")
+	}
+
+	if _, err := decodeKeystore(ks.encode(), &chaincfg.MainNetParams); err == nil {
+		t.Fatalf("no error for wrong network")
+	}
+
+	// Keystores created from the same seed derive the same addresses.
+	seed := bytes.Repeat([]byte{1}, 32)
+	var addrs [2]string
+	for i := range addrs {
+		seedKS, err := newKeystore(pw, seed, tSPVParams)
+		if err != nil {
+			t.Fatalf("newKeystore error for seed: %v", err)
+		}
+		info, err := seedKS.address(branchLegacy, 0)
+		if err != nil {
+			t.Fatalf("address error: %v", err)
+		}
+		addrs[i] = info.addr.String()
+	}
+	if addrs[0] != addrs[1] {
+		t.Fatalf("keystores from the same seed derive different addresses")
+	}
+}
+
+func TestSPVWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spvtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	for i := 0; i < 5; i++ {
+		src.mine()
+	}
+	w, shutdown := tSPVWallet(t, dir, src)
+	tSync(t, w)
+
+	// No addresses before the wallet is created.
+	var addr string
+	if err := tCall(t, w, methodNewAddress, anylist{"", "bech32"}, &addr); err == nil {
+		t.Fatalf("no error for address from uncreated wallet")
+	}
+	if w.scanHeight != 5 {
+		t.Fatalf("expected scan height 5, got %d", w.scanHeight)
+	}
+
+	// The first unlock creates the wallet.
+	wc := newWalletClient(w, tSPVParams)
+	if err := wc.Unlock("pass", time.Hour); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	addrWPKH, err := wc.AddressWPKH()
+	if err != nil {
+		t.Fatalf("AddressWPKH error: %v", err)
+	}
+	if _, ok := addrWPKH.(*btcutil.AddressWitnessPubKeyHash); !ok {
+		t.Fatalf("wrong address type %T", addrWPKH)
+	}
+	addrPKH, err := wc.AddressPKH()
+	if err != nil {
+		t.Fatalf("AddressPKH error: %v", err)
+	}
+	if _, ok := addrPKH.(*btcutil.AddressPubKeyHash); !ok {
+		t.Fatalf("wrong address type %T", addrPKH)
+	}
+
+	// Fund the wallet. The payment to an address past the gap limit is found
+	// because the earlier payment extends the lookahead.
+	w.mtx.RLock()
+	farInfo, _ := w.ks.address(branchExternal, gapLimit)
+	fartherInfo, _ := w.ks.address(branchExternal, gapLimit+15)
+	_, watched := w.addrs[string(fartherInfo.pkScript)]
+	w.mtx.RUnlock()
+	if watched {
+		t.Fatalf("address past the gap limit is watched")
+	}
+	src.mine(tFundingTx(t, addrWPKH.String(), 1e8))
+	src.mine(tFundingTx(t, addrPKH.String(), 2e8))
+	src.mine(tFundingTx(t, farInfo.addr.String(), 15e7))
+	src.mine(tFundingTx(t, fartherInfo.addr.String(), 15e7))
+	src.mine()
+	tSync(t, w)
+
+	bals, err := wc.Balances()
+	if err != nil {
+		t.Fatalf("Balances error: %v", err)
+	}
+	if toSatoshi(bals.Mine.Trusted) != 6e8 {
+		t.Fatalf("expected 6 BTC balance, got %f", bals.Mine.Trusted)
+	}
+	hash, _ := w.GetBestBlockHash()
+	if *hash != src.blocks[10].BlockHash() {
+		t.Fatalf("wrong best block")
+	}
+
+	unspents, err := wc.ListUnspent()
+	if err != nil {
+		t.Fatalf("ListUnspent error: %v", err)
+	}
+	if len(unspents) != 4 {
+		t.Fatalf("expected 4 unspent outputs, got %d", len(unspents))
+	}
+	for _, u := range unspents {
+		if toSatoshi(u.Amount) == 1e8 && u.Confirmations != 5 {
+			t.Fatalf("expected 5 confirmations, got %d", u.Confirmations)
+		}
+		if !u.Safe {
+			t.Fatalf("confirmed output not safe")
+		}
+	}
+
+	// Lock an output.
+	lockHash := src.blocks[6].Transactions[1].TxHash()
+	op := newOutput(w, &lockHash, 0, 1e8, nil)
+	if err := wc.LockUnspent(false, []*output{op}); err != nil {
+		t.Fatalf("LockUnspent error: %v", err)
+	}
+	locked, _ := wc.ListLockUnspent()
+	if len(locked) != 1 || locked[0].TxID != op.txHash.String() {
+		t.Fatalf("wrong locked outputs %v", locked)
+	}
+	unspents, _ = wc.ListUnspent()
+	if len(unspents) != 3 {
+		t.Fatalf("locked output listed")
+	}
+	if err := wc.LockUnspent(true, nil); err != nil {
+		t.Fatalf("LockUnspent error: %v", err)
+	}
+	if locked, _ = wc.ListLockUnspent(); len(locked) != 0 {
+		t.Fatalf("outputs still locked")
+	}
+
+	// Send.
+	dest := tP2PKHScript()
+	_, destAddrs, _, _ := txscript.ExtractPkScriptAddrs(dest, tSPVParams)
+	destAddr := destAddrs[0].String()
+	txHash, err := wc.SendToAddress(destAddr, 25e7, 10, false)
+	if err != nil {
+		t.Fatalf("SendToAddress error: %v", err)
+	}
+	if len(src.sent) != 1 || src.sent[0].TxHash() != *txHash {
+		t.Fatalf("transaction not broadcast")
+	}
+	sendTx := src.sent[0]
+	tx, err := wc.GetTransaction(txHash.String())
+	if err != nil {
+		t.Fatalf("GetTransaction error: %v", err)
+	}
+	if tx.Confirmations != 0 || tx.Fee >= 0 {
+		t.Fatalf("wrong unmined send %+v", tx)
+	}
+	var foundSend bool
+	for _, d := range tx.Details {
+		if d.Category == TxCatSend && d.Address == destAddr && toSatoshi(-d.Amount) == 25e7 {
+			foundSend = true
+		}
+	}
+	if !foundSend {
+		t.Fatalf("send details not found")
+	}
+	fee := toSatoshi(-tx.Fee)
+	if fee == 0 || fee > 10*uint64(sendTx.SerializeSize()) {
+		t.Fatalf("unexpected fee %d for size %d", fee, sendTx.SerializeSize())
+	}
+	if bal := w.spvBalance(); bal != 6e8-25e7-fee {
+		t.Fatalf("wrong balance after send. expected %d, got %d", 6e8-25e7-fee, bal)
+	}
+	// Change from the wallet's own transaction is trusted.
+	bals, _ = wc.Balances()
+	if toSatoshi(bals.Mine.Trusted) != 6e8-25e7-fee {
+		t.Fatalf("change not trusted")
+	}
+	src.mine(sendTx)
+	tSync(t, w)
+	if tx, _ = wc.GetTransaction(txHash.String()); tx.Confirmations != 1 || tx.BlockIndex != 1 {
+		t.Fatalf("wrong mined send %+v", tx)
+	}
+
+	// Insufficient funds.
+	if _, err := wc.SendToAddress(destAddr, 10e8, 10, false); err == nil {
+		t.Fatalf("no error for insufficient funds")
+	}
+
+	// An input that isn't the wallet's can't be signed.
+	foreign := wire.NewMsgTx(wire.TxVersion)
+	foreign.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	foreign.AddTxOut(wire.NewTxOut(1e8, dest))
+	if _, err := wc.SignTx(foreign); err == nil {
+		t.Fatalf("no error for signing foreign input")
+	}
+
+	// Reorg out the send.
+	sendHeight := len(src.blocks) - 1
+	src.truncate(sendHeight - 1)
+	src.mine()
+	src.mine()
+	tSync(t, w)
+	if tx, _ = wc.GetTransaction(txHash.String()); tx.Confirmations != 0 || tx.BlockHash != "" {
+		t.Fatalf("send still mined after reorg: %+v", tx)
+	}
+	if w.scanHeight != uint32(sendHeight+1) {
+		t.Fatalf("wrong scan height after reorg. expected %d, got %d", sendHeight+1, w.scanHeight)
+	}
+
+	// The private key for a wallet address.
+	wif, err := wc.PrivKeyForAddress(addrPKH.String())
+	if err != nil {
+		t.Fatalf("PrivKeyForAddress error: %v", err)
+	}
+	if !bytes.Equal(btcutil.Hash160(wif.PubKey().SerializeCompressed()), addrPKH.ScriptAddress()) {
+		t.Fatalf("wrong private key")
+	}
+	if err := wc.Lock(); err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	if _, err := wc.PrivKeyForAddress(addrPKH.String()); err == nil {
+		t.Fatalf("no error for private key from locked wallet")
+	}
+
+	// Restart. The wallet is reloaded from the database.
+	balance := w.spvBalance()
+	shutdown()
+	w, shutdown = tSPVWallet(t, dir, src)
+	defer shutdown()
+	if bal := w.spvBalance(); bal != balance {
+		t.Fatalf("wrong balance after restart. expected %d, got %d", balance, bal)
+	}
+	wc = newWalletClient(w, tSPVParams)
+	if err := wc.Unlock("wrong", 0); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	if err := wc.Unlock("pass", 0); err != nil {
+		t.Fatalf("unlock error after restart: %v", err)
+	}
+	if _, err := wc.PrivKeyForAddress(addrPKH.String()); err != nil {
+		t.Fatalf("PrivKeyForAddress error after restart: %v", err)
+	}
+
+	// Rescan from the beginning finds the same transactions.
+	w.mtx.Lock()
+	w.txs = make(map[chainhash.Hash]*storedTx)
+	w.spends = make(map[wire.OutPoint]chainhash.Hash)
+	w.mtx.Unlock()
+	if err := wc.Rescan(0); err != nil {
+		t.Fatalf("Rescan error: %v", err)
+	}
+	if bal := w.spvBalance(); bal != 6e8 {
+		t.Fatalf("wrong balance after rescan. expected %d, got %d", uint64(6e8), bal)
+	}
+
+	// Unsupported method.
+	if _, err := w.RawRequest("getnetworkinfo", nil); err == nil {
+		t.Fatalf("no error for unsupported method")
+	}
+}
+
+func TestSPVAuditContract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spvtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	src.mine()
+	spv, shutdown := tSPVWallet(t, dir, src)
+	defer shutdown()
+	tSync(t, spv)
+	cfg := &BTCCloneCFG{
+		WalletCFG: &asset.WalletConfig{
+			Settings:  map[string]string{},
+			TipChange: func(error) {},
+		},
+		Symbol:      "btc",
+		Logger:      tLogger,
+		ChainParams: tSPVParams,
+	}
+	wallet := newWallet(cfg, spv)
+	wallet.spv = spv
+	if err := wallet.Unlock("pass", time.Hour); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+
+	secret := randBytes(32)
+	secretHash := sha256.Sum256(secret)
+	recipient, _ := wallet.Address()
+	_, sender, _, _ := txscript.ExtractPkScriptAddrs(tP2PKHScript(), tSPVParams)
+	contract, err := dexbtc.MakeContract(recipient, sender[0].String(), secretHash[:], time.Now().Add(time.Hour).Unix(), tSPVParams)
+	if err != nil {
+		t.Fatalf("error making contract: %v", err)
+	}
+	contractAddr, _ := btcutil.NewAddressScriptHash(contract, tSPVParams)
+	contractTx := tFundingTx(t, contractAddr.String(), 1e8)
+	contractHash := contractTx.TxHash()
+	coinID := toCoinID(&contractHash, 0)
+
+	// Not mined or in mempool.
+	if _, err := wallet.AuditContract(coinID, contract); err == nil {
+		t.Fatalf("no error for unknown contract")
+	}
+
+	// Found in a recent block.
+	src.mine(contractTx)
+	src.mine()
+	tSync(t, spv)
+	audit, err := wallet.AuditContract(coinID, contract)
+	if err != nil {
+		t.Fatalf("AuditContract error: %v", err)
+	}
+	if audit.Recipient() != recipient || audit.Coin().Value() != 1e8 {
+		t.Fatalf("wrong audit info")
+	}
+	confs, err := audit.Coin().Confirmations()
+	if err != nil || confs != 2 {
+		t.Fatalf("expected 2 confirmations, got %d, %v", confs, err)
+	}
+
+	// The spend of the watched output is found.
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&contractHash, 0), randBytes(50), nil))
+	spend.AddTxOut(wire.NewTxOut(9e7, tP2PKHScript()))
+	src.mine(spend)
+	tSync(t, spv)
+	if _, err := audit.Coin().Confirmations(); err == nil {
+		t.Fatalf("no error for spent contract")
+	}
+
+	// A contract in the mempool.
+	contractTx = tFundingTx(t, contractAddr.String(), 2e8)
+	contractHash = contractTx.TxHash()
+	src.mempool[contractHash] = contractTx
+	audit, err = wallet.AuditContract(toCoinID(&contractHash, 0), contract)
+	if err != nil {
+		t.Fatalf("AuditContract error for mempool contract: %v", err)
+	}
+	if audit.Coin().Value() != 2e8 {
+		t.Fatalf("wrong value for mempool contract")
+	}
+
+	// Wrong contract.
+	otherContract, _ := dexbtc.MakeContract(recipient, sender[0].String(), randBytes(32), time.Now().Add(time.Hour).Unix(), tSPVParams)
+	if _, err := wallet.AuditContract(toCoinID(&contractHash, 0), otherContract); err == nil {
+		t.Fatalf("no error for wrong contract")
+	}
+}
+
+func TestRequiredBits(t *testing.T) {
+	// Synthetic ancestors with a block every ten minutes.
+	start := time.Unix(1600000000, 0)
+	var hdrs []*wire.BlockHeader
+	mkChain := func(params *chaincfg.Params, n int) {
+		hdrs = make([]*wire.BlockHeader, n)
+		for i := range hdrs {
+			hdrs[i] = &wire.BlockHeader{
+				Bits:      params.PowLimitBits,
+				Timestamp: start.Add(time.Duration(i) * params.TargetTimePerBlock),
+			}
+		}
+	}
+	hdrAt := func(height uint32) (*wire.BlockHeader, error) {
+		if int(height) >= len(hdrs) {
+			return nil, fmt.Errorf("no header at height %d", height)
+		}
+		return hdrs[height], nil
+	}
+	check := func(params *chaincfg.Params, height uint32, stamp time.Time, want uint32) {
+		t.Helper()
+		bits, err := requiredBits(params, height, stamp, hdrAt)
+		if err != nil {
+			t.Fatalf("requiredBits error: %v", err)
+		}
+		if bits != want {
+			t.Fatalf("wanted bits %08x, got %08x", want, bits)
+		}
+	}
+
+	mainnet := &chaincfg.MainNetParams
+	const retarget = 2016
+	mkChain(mainnet, retarget+1)
+	hardBits := blockchain.BigToCompact(new(big.Int).Rsh(mainnet.PowLimit, 4))
+	hdrs[retarget-1].Bits = hardBits
+	next := hdrs[retarget-1].Timestamp.Add(mainnet.TargetTimePerBlock)
+
+	// Between retargets, the difficulty is unchanged.
+	check(mainnet, retarget-1, next, mainnet.PowLimitBits)
+	hdrs[retarget-2].Bits = hardBits
+	check(mainnet, retarget-1, next, hardBits)
+
+	// At a retarget with blocks on schedule, the target is unchanged. The
+	// timespan is measured over one block fewer than the interval.
+	oneBlock := int64(mainnet.TargetTimePerBlock / time.Second)
+	wantTarget := new(big.Int).Mul(blockchain.CompactToBig(hardBits), big.NewInt(int64(mainnet.TargetTimespan/time.Second)-oneBlock))
+	wantTarget.Div(wantTarget, big.NewInt(int64(mainnet.TargetTimespan/time.Second)))
+	check(mainnet, retarget, next, blockchain.BigToCompact(wantTarget))
+
+	// Fast blocks are limited to a factor of four increase in difficulty.
+	for i := range hdrs {
+		hdrs[i].Timestamp = start.Add(time.Duration(i) * time.Second)
+	}
+	wantTarget = new(big.Int).Div(blockchain.CompactToBig(hardBits), big.NewInt(4))
+	check(mainnet, retarget, next, blockchain.BigToCompact(wantTarget))
+
+	// Slow blocks are limited to a factor of four decrease in difficulty,
+	// and can't lower the difficulty below the minimum.
+	for i := range hdrs {
+		hdrs[i].Timestamp = start.Add(time.Duration(i) * time.Hour)
+	}
+	wantTarget = new(big.Int).Mul(blockchain.CompactToBig(hardBits), big.NewInt(4))
+	check(mainnet, retarget, next, blockchain.BigToCompact(wantTarget))
+	hdrs[retarget-1].Bits = mainnet.PowLimitBits
+	check(mainnet, retarget, next, mainnet.PowLimitBits)
+
+	// On testnet, a block more than 20 minutes after the previous one may
+	// have the minimum difficulty. Otherwise, it must have the difficulty of
+	// the last block that did not.
+	testnet := &chaincfg.TestNet3Params
+	mkChain(testnet, 10)
+	hdrs[5].Bits = hardBits
+	prevStamp := hdrs[9].Timestamp
+	check(testnet, 10, prevStamp.Add(testnet.MinDiffReductionTime+time.Second), testnet.PowLimitBits)
+	check(testnet, 10, prevStamp.Add(time.Minute), hardBits)
+
+	// Regtest does not retarget.
+	check(&chaincfg.RegressionNetParams, retarget, next, 0)
+}
+
+func TestChainService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spvtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The stores are filled directly before the chain service is started.
+	cs, err := newChainService(tSPVParams, dir, []string{"127.0.0.1:1"}, func() {}, tLogger)
+	if err != nil {
+		t.Fatalf("newChainService error: %v", err)
+	}
+
+	src := newTBlockSource()
+	for i := 0; i < 5; i++ {
+		src.mine()
+	}
+	const filterTip = 3
+	for i, blk := range src.blocks[1:] {
+		height := uint32(i + 1)
+		hdr := blk.Header
+		if err := cs.BlockHeaders.WriteHeaders(headerfs.BlockHeader{BlockHeader: &hdr, Height: height}); err != nil {
+			t.Fatalf("error writing header: %v", err)
+		}
+		blockHash := blk.BlockHash()
+		if err := cs.FilterDB.PutFilter(&blockHash, src.filters[height], filterdb.RegularFilter); err != nil {
+			t.Fatalf("error storing filter: %v", err)
+		}
+		if height > filterTip {
+			continue
+		}
+		err := cs.RegFilterHeaders.WriteHeaders(headerfs.FilterHeader{
+			HeaderHash: blockHash,
+			FilterHash: src.filterHdrs[height],
+			Height:     height,
+		})
+		if err != nil {
+			t.Fatalf("error writing filter header: %v", err)
+		}
+	}
+	tipHash := src.blocks[5].BlockHash()
+
+	// Headers follow the first locator block in the best chain.
+	hdrs, err := cs.getHeaders(blockchain.BlockLocator{&chainhash.Hash{1}, &src.blocks[2].Header.PrevBlock})
+	if err != nil {
+		t.Fatalf("getHeaders error: %v", err)
+	}
+	if len(hdrs) != 4 || hdrs[0].BlockHash() != src.blocks[2].BlockHash() || hdrs[3].BlockHash() != tipHash {
+		t.Fatalf("wrong headers returned")
+	}
+	if _, err := cs.getHeaders(blockchain.BlockLocator{&chainhash.Hash{1}}); err == nil {
+		t.Fatalf("no error for unknown locator")
+	}
+
+	// Filter headers are returned up to neutrino's filter header tip.
+	fHdrs, err := cs.getFilterHeaders(1, &tipHash)
+	if err != nil {
+		t.Fatalf("getFilterHeaders error: %v", err)
+	}
+	if len(fHdrs) != filterTip {
+		t.Fatalf("expected %d filter headers, got %d", filterTip, len(fHdrs))
+	}
+	for i, hdr := range fHdrs {
+		if hdr != src.filterHdrs[i+1] {
+			t.Fatalf("wrong filter header at height %d", i+1)
+		}
+	}
+
+	filters, err := cs.getFilters(4, &tipHash, 2)
+	if err != nil {
+		t.Fatalf("getFilters error: %v", err)
+	}
+	for i, msg := range filters {
+		height := 4 + i
+		b, _ := src.filters[height].NBytes()
+		if msg.BlockHash != src.blocks[height].BlockHash() || !bytes.Equal(msg.Data, b) {
+			t.Fatalf("wrong filter for height %d", height)
+		}
+	}
+	if _, err := cs.getFilters(4, &tipHash, 3); err == nil {
+		t.Fatalf("no error for wrong filter count")
+	}
+
+	ctx, cancel := context.WithCancel(tCtx)
+	done := make(chan struct{})
+	go func() {
+		cs.run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if cs.peerCount() != 0 {
+		t.Fatalf("expected no peers")
+	}
+	if err := cs.sendTx(src.blocks[1].Transactions[0]); err != errNoPeers {
+		t.Fatalf("expected errNoPeers, got %v", err)
+	}
+}
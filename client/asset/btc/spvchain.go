@@ -0,0 +1,581 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+)
+
+// birthdaySlack is how far before the keystore birthday that blocks are
+// scanned, to allow for block timestamps that lag the actual time.
+const birthdaySlack = 2 * time.Hour
+
+// sync brings the wallet up to date with the network. Headers are synced
+// first, then filter headers, then the filters are scanned for wallet
+// transactions. An Electrum server wallet syncs with syncElectrum instead.
+func (w *spvWallet) sync() error {
+	w.syncMtx.Lock()
+	defer w.syncMtx.Unlock()
+	if w.src.peerCount() == 0 {
+		return errNoPeers
+	}
+	if w.electrum != nil {
+		return w.syncElectrum()
+	}
+	if err := w.syncHeaders(); err != nil {
+		return fmt.Errorf("error syncing headers: %v", err)
+	}
+	if err := w.syncFilterHeaders(); err != nil {
+		return fmt.Errorf("error syncing filter headers: %v", err)
+	}
+	if err := w.scanBlocks(); err != nil {
+		return fmt.Errorf("error scanning blocks: %v", err)
+	}
+	return nil
+}
+
+// blockLocator creates a block locator from the stored headers, starting at
+// the tip. The first ten hashes are for consecutive blocks, after which the
+// step between blocks doubles, ending with the genesis block.
+func (w *spvWallet) blockLocator() (blockchain.BlockLocator, error) {
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return nil, err
+	}
+	var locator blockchain.BlockLocator
+	height, step := int64(tipHeight), int64(1)
+	for height > 0 {
+		hash, err := w.db.blockHash(uint32(height))
+		if err != nil {
+			return nil, err
+		}
+		locator = append(locator, hash)
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		height -= step
+	}
+	locator = append(locator, w.params.GenesisHash)
+	return locator, nil
+}
+
+// syncHeaders requests headers until the peer has no more to give.
+func (w *spvWallet) syncHeaders() error {
+	for {
+		locator, err := w.blockLocator()
+		if err != nil {
+			return err
+		}
+		hdrs, err := w.src.getHeaders(locator)
+		if err != nil {
+			return err
+		}
+		if len(hdrs) == 0 {
+			return nil
+		}
+		stored, err := w.connectHeaders(hdrs)
+		if err != nil || !stored {
+			return err
+		}
+		if len(hdrs) < wire.MaxBlockHeadersPerMsg {
+			return nil
+		}
+	}
+}
+
+// connectHeaders checks and stores the headers, which must connect to the
+// stored chain. Headers are checked for proof of work, difficulty retargeting,
+// and against the network's checkpoints. If the headers fork from the stored
+// chain, the chain with the most work is kept. When a fork is accepted, wallet
+// transactions in the orphaned blocks are marked unmined, and the blocks after
+// the fork point are rescanned. The returned bool is false if the stored chain
+// was kept.
+func (w *spvWallet) connectHeaders(hdrs []*wire.BlockHeader) (bool, error) {
+	forkHeight, found, err := w.db.blockHeight(&hdrs[0].PrevBlock)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, fmt.Errorf("headers do not connect to the stored chain")
+	}
+	_, work, err := w.db.header(forkHeight)
+	if err != nil {
+		return false, err
+	}
+	// Ancestors above the fork height are in the new headers rather than the
+	// stored chain.
+	hdrAt := func(height uint32) (*wire.BlockHeader, error) {
+		if height > forkHeight {
+			return hdrs[height-forkHeight-1], nil
+		}
+		hdr, _, err := w.db.header(height)
+		return hdr, err
+	}
+	works := make([]*big.Int, 0, len(hdrs))
+	for i, hdr := range hdrs {
+		height := forkHeight + 1 + uint32(i)
+		if err := w.checkHeader(hdr, height, hdrAt); err != nil {
+			return false, err
+		}
+		work = new(big.Int).Add(work, blockchain.CalcWork(hdr.Bits))
+		works = append(works, work)
+	}
+	tipHeight, _, tipWork, err := w.db.tip()
+	if err != nil {
+		return false, err
+	}
+	if forkHeight < tipHeight {
+		if work.Cmp(tipWork) <= 0 {
+			// The stored chain has at least as much work.
+			return false, nil
+		}
+		w.log.Infof("Chain reorganization from height %d", forkHeight+1)
+		if err := w.disconnectBlocks(forkHeight); err != nil {
+			return false, err
+		}
+	}
+	if err := w.db.putHeaders(forkHeight+1, hdrs, works); err != nil {
+		return false, err
+	}
+	w.log.Debugf("Synced headers to height %d", forkHeight+uint32(len(hdrs)))
+	return true, nil
+}
+
+// checkHeader checks that the header connects to the previous block, has the
+// difficulty target required by the network's retargeting rules, has valid
+// proof of work for that target, and matches any checkpoint at the height.
+// hdrAt provides the ancestors of the header.
+func (w *spvWallet) checkHeader(hdr *wire.BlockHeader, height uint32, hdrAt func(uint32) (*wire.BlockHeader, error)) error {
+	hash := hdr.BlockHash()
+	prev, err := hdrAt(height - 1)
+	if err != nil {
+		return err
+	}
+	if hdr.PrevBlock != prev.BlockHash() {
+		return fmt.Errorf("header %s at height %d does not connect to the previous header", hash, height)
+	}
+	bits, err := requiredBits(w.params, height, hdr.Timestamp, hdrAt)
+	if err != nil {
+		return err
+	}
+	if bits != 0 && hdr.Bits != bits {
+		return fmt.Errorf("header %s at height %d has difficulty bits %08x, expected %08x",
+			hash, height, hdr.Bits, bits)
+	}
+	target := blockchain.CompactToBig(hdr.Bits)
+	if target.Sign() <= 0 || target.Cmp(w.params.PowLimit) > 0 {
+		return fmt.Errorf("header %s at height %d has an invalid target", hash, height)
+	}
+	if blockchain.HashToBig(&hash).Cmp(target) > 0 {
+		return fmt.Errorf("header %s at height %d has insufficient proof of work", hash, height)
+	}
+	for _, cp := range w.params.Checkpoints {
+		if cp.Height == int32(height) && *cp.Hash != hash {
+			return fmt.Errorf("header %s at height %d does not match checkpoint %s", hash, height, cp.Hash)
+		}
+	}
+	return nil
+}
+
+// requiredBits calculates the difficulty bits that a header at the height
+// with the timestamp must have, following the rules of btcd's
+// calcNextRequiredDifficulty. hdrAt provides the ancestors of the header.
+// Zero is returned for regtest, which does not retarget.
+func requiredBits(params *chaincfg.Params, height uint32, timestamp time.Time,
+	hdrAt func(uint32) (*wire.BlockHeader, error)) (uint32, error) {
+
+	if params.Name == chaincfg.RegressionNetParams.Name {
+		return 0, nil
+	}
+	prev, err := hdrAt(height - 1)
+	if err != nil {
+		return 0, err
+	}
+	blocksPerRetarget := uint32(params.TargetTimespan / params.TargetTimePerBlock)
+
+	if height%blocksPerRetarget != 0 {
+		if !params.ReduceMinDifficulty {
+			return prev.Bits, nil
+		}
+		// A block more than MinDiffReductionTime after the previous block
+		// may have the minimum difficulty. Otherwise, it must have the
+		// difficulty of the last block that did not use that rule.
+		if timestamp.After(prev.Timestamp.Add(params.MinDiffReductionTime)) {
+			return params.PowLimitBits, nil
+		}
+		h, hdr := height-1, prev
+		for h%blocksPerRetarget != 0 && hdr.Bits == params.PowLimitBits {
+			h--
+			if hdr, err = hdrAt(h); err != nil {
+				return 0, err
+			}
+		}
+		return hdr.Bits, nil
+	}
+
+	first, err := hdrAt(height - blocksPerRetarget)
+	if err != nil {
+		return 0, err
+	}
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	adjustmentFactor := params.RetargetAdjustmentFactor
+	actualTimespan := prev.Timestamp.Unix() - first.Timestamp.Unix()
+	if minTimespan := targetTimespan / adjustmentFactor; actualTimespan < minTimespan {
+		actualTimespan = minTimespan
+	} else if maxTimespan := targetTimespan * adjustmentFactor; actualTimespan > maxTimespan {
+		actualTimespan = maxTimespan
+	}
+	newTarget := new(big.Int).Mul(blockchain.CompactToBig(prev.Bits), big.NewInt(actualTimespan))
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+	if newTarget.Cmp(params.PowLimit) > 0 {
+		newTarget.Set(params.PowLimit)
+	}
+	return blockchain.BigToCompact(newTarget), nil
+}
+
+// disconnectBlocks marks wallet transactions mined above the fork height as
+// unmined, and lowers the scan height to the fork height. Coinbase
+// transactions from disconnected blocks are removed.
+func (w *spvWallet) disconnectBlocks(forkHeight uint32) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for _, stx := range w.txs {
+		if stx.height <= forkHeight {
+			continue
+		}
+		if blockchain.IsCoinBaseTx(stx.tx) {
+			if err := w.removeTx(&stx.hash); err != nil {
+				return err
+			}
+			continue
+		}
+		stx.height, stx.blockHash, stx.blockIndex = 0, chainhash.Hash{}, 0
+		if err := w.db.putTx(stx); err != nil {
+			return err
+		}
+	}
+	if w.scanHeight > forkHeight {
+		return w.setScanHeight(forkHeight)
+	}
+	return nil
+}
+
+// syncFilterHeaders requests the filter headers for any stored block headers
+// that don't have them yet. The filter headers are chained from the genesis
+// block and checked for agreement between peers by the block source, so a
+// peer serving bad filters is detected when the filters are checked against
+// the stored filter headers.
+func (w *spvWallet) syncFilterHeaders() error {
+	tipHeight, _, _, err := w.db.tip()
+	if err != nil {
+		return err
+	}
+	fTip, found, err := w.db.filterTip()
+	if err != nil {
+		return err
+	}
+	var start uint32
+	if found {
+		start = fTip + 1
+	}
+	for start <= tipHeight {
+		stop := start + wire.MaxCFHeadersPerMsg - 1
+		if stop > tipHeight {
+			stop = tipHeight
+		}
+		stopHash, err := w.db.blockHash(stop)
+		if err != nil {
+			return err
+		}
+		hdrs, err := w.src.getFilterHeaders(start, stopHash)
+		if err != nil {
+			return err
+		}
+		if len(hdrs) > int(stop-start+1) {
+			return fmt.Errorf("expected at most %d filter headers, got %d", stop-start+1, len(hdrs))
+		}
+		if len(hdrs) == 0 {
+			// The block source hasn't synced these filter headers yet.
+			return nil
+		}
+		if err := w.db.putFilterHeaders(start, hdrs); err != nil {
+			return err
+		}
+		w.log.Debugf("Synced filter headers to height %d", start+uint32(len(hdrs))-1)
+		if len(hdrs) < int(stop-start+1) {
+			return nil
+		}
+		start = stop + 1
+	}
+	return nil
+}
+
+// filterHeader computes the BIP157 filter header from the filter hash and the
+// previous filter header.
+func filterHeader(filterHash, prevHeader *chainhash.Hash) chainhash.Hash {
+	b := make([]byte, 0, 2*chainhash.HashSize)
+	b = append(b, filterHash[:]...)
+	b = append(b, prevHeader[:]...)
+	return chainhash.DoubleHashH(b)
+}
+
+// blockFilters requests the filters for the blocks from start to stop, and
+// checks them against the stored filter headers.
+func (w *spvWallet) blockFilters(start, stop uint32) ([]*gcs.Filter, []*chainhash.Hash, error) {
+	stopHash, err := w.db.blockHash(stop)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs, err := w.src.getFilters(start, stopHash, int(stop-start+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	var prevHeader chainhash.Hash
+	if start > 0 {
+		prevHeader, err = w.db.filterHeader(start - 1)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	filters := make([]*gcs.Filter, 0, len(msgs))
+	hashes := make([]*chainhash.Hash, 0, len(msgs))
+	for i, msg := range msgs {
+		height := start + uint32(i)
+		blockHash, err := w.db.blockHash(height)
+		if err != nil {
+			return nil, nil, err
+		}
+		if msg.BlockHash != *blockHash {
+			return nil, nil, fmt.Errorf("expected filter for block %s, got %s", blockHash, msg.BlockHash)
+		}
+		storedHeader, err := w.db.filterHeader(height)
+		if err != nil {
+			return nil, nil, err
+		}
+		filterHash := chainhash.DoubleHashH(msg.Data)
+		prevHeader = filterHeader(&filterHash, &prevHeader)
+		if prevHeader != storedHeader {
+			return nil, nil, fmt.Errorf("filter for block %s does not match the filter header", blockHash)
+		}
+		filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, msg.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding filter for block %s: %v", blockHash, err)
+		}
+		filters = append(filters, filter)
+		hashes = append(hashes, blockHash)
+	}
+	return filters, hashes, nil
+}
+
+// matchFilter checks whether any of the scripts could be in the block.
+func matchFilter(filter *gcs.Filter, blockHash *chainhash.Hash, scripts [][]byte) (bool, error) {
+	if len(scripts) == 0 || filter.N() == 0 {
+		return false, nil
+	}
+	return filter.MatchAny(builder.DeriveKey(blockHash), scripts)
+}
+
+// fetchBlock requests the block from the network and checks the transactions
+// against the merkle root of the header.
+func (w *spvWallet) fetchBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	blk, err := w.src.getBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if blk.BlockHash() != *hash {
+		return nil, fmt.Errorf("requested block %s, got %s", hash, blk.BlockHash())
+	}
+	txs := make([]*btcutil.Tx, 0, len(blk.Transactions))
+	for _, tx := range blk.Transactions {
+		txs = append(txs, btcutil.NewTx(tx))
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("block %s has no transactions", hash)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(txs, false)
+	if *merkles[len(merkles)-1] != blk.Header.MerkleRoot {
+		return nil, fmt.Errorf("block %s transactions do not match the merkle root", hash)
+	}
+	return blk, nil
+}
+
+// scanBlocks scans the filters of the blocks above the scan height for wallet
+// addresses and watched outputs, and processes the matching blocks. Blocks
+// mined well before the keystore was created are skipped. If there is no
+// keystore, there is nothing to scan, and the scan height is set to the tip.
+func (w *spvWallet) scanBlocks() error {
+	fTip, found, err := w.db.filterTip()
+	if err != nil || !found {
+		return err
+	}
+	w.mtx.Lock()
+	start := w.scanHeight + 1
+	var birthday time.Time
+	if w.ks == nil {
+		start = fTip + 1
+		if w.scanHeight < fTip {
+			err = w.setScanHeight(fTip)
+		}
+	} else {
+		birthday = w.ks.birthday.Add(-birthdaySlack)
+	}
+	w.mtx.Unlock()
+	if err != nil || start > fTip {
+		return err
+	}
+
+	// Find the first block at or after the birthday. Block timestamps are not
+	// strictly increasing, but the birthday slack accounts for that.
+	var searchErr error
+	first := start + uint32(sort.Search(int(fTip-start+1), func(i int) bool {
+		hdr, _, err := w.db.header(start + uint32(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !hdr.Timestamp.Before(birthday)
+	}))
+	if searchErr != nil {
+		return searchErr
+	}
+
+	for start = first; start <= fTip; {
+		stop := start + wire.MaxGetCFiltersReqRange - 1
+		if stop > fTip {
+			stop = fTip
+		}
+		filters, hashes, err := w.blockFilters(start, stop)
+		if err != nil {
+			return err
+		}
+		for i, filter := range filters {
+			height := start + uint32(i)
+			w.mtx.RLock()
+			scripts := w.scripts()
+			w.mtx.RUnlock()
+			match, err := matchFilter(filter, hashes[i], scripts)
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			blk, err := w.fetchBlock(hashes[i])
+			if err != nil {
+				return err
+			}
+			w.mtx.Lock()
+			err = w.processBlock(blk, height)
+			w.mtx.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		w.mtx.Lock()
+		err = w.setScanHeight(stop)
+		w.mtx.Unlock()
+		if err != nil {
+			return err
+		}
+		w.log.Debugf("Scanned blocks to height %d", stop)
+		start = stop + 1
+	}
+	// Blocks between the scan height and the birthday had nothing to scan.
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.scanHeight < fTip {
+		return w.setScanHeight(fTip)
+	}
+	return nil
+}
+
+// setScanHeight stores the scan height. The wallet mutex must be held.
+func (w *spvWallet) setScanHeight(height uint32) error {
+	w.scanHeight = height
+	return w.db.putMeta(scanHeightKey, encode.Uint32Bytes(height))
+}
+
+// scripts is the pubkey scripts of the wallet's addresses and watched outputs.
+// The wallet mutex must be held.
+func (w *spvWallet) scripts() [][]byte {
+	scripts := make([][]byte, 0, len(w.addrs)+len(w.watched))
+	for script := range w.addrs {
+		scripts = append(scripts, []byte(script))
+	}
+	for _, script := range w.watched {
+		scripts = append(scripts, script)
+	}
+	return scripts
+}
+
+// processBlock stores any transactions in the block that are relevant to the
+// wallet. The wallet mutex must be held.
+func (w *spvWallet) processBlock(blk *wire.MsgBlock, height uint32) error {
+	blockHash := blk.BlockHash()
+	for i, tx := range blk.Transactions {
+		if !w.relevant(tx) {
+			continue
+		}
+		stx := &storedTx{
+			tx:         tx,
+			hash:       tx.TxHash(),
+			height:     height,
+			blockHash:  blockHash,
+			blockIndex: uint32(i),
+			received:   blk.Header.Timestamp,
+		}
+		if known := w.txs[stx.hash]; known != nil {
+			stx.received = known.received
+		}
+		if err := w.storeTx(stx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rescan rescans the blocks from the height. The keystore birthday is moved
+// back if the block predates it.
+func (w *spvWallet) rescan(height uint32) error {
+	// The genesis block's outputs are not spendable.
+	if height == 0 {
+		height = 1
+	}
+	hdr, _, err := w.db.header(height)
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	if w.ks == nil {
+		w.mtx.Unlock()
+		return fmt.Errorf("wallet not created")
+	}
+	if hdr.Timestamp.Add(birthdaySlack).Before(w.ks.birthday) {
+		w.ks.birthday = hdr.Timestamp.Add(birthdaySlack)
+		err = w.db.putMeta(keystoreKey, w.ks.encode())
+	}
+	if err == nil && height-1 < w.scanHeight {
+		err = w.setScanHeight(height - 1)
+	}
+	w.mtx.Unlock()
+	if w.electrum != nil {
+		w.electrum.resetSynced()
+	}
+	if err != nil {
+		return err
+	}
+	return w.sync()
+}
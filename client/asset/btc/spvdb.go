@@ -0,0 +1,384 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	// spvHeadersBucket maps block height to the serialized block header
+	// followed by the cumulative chain work.
+	spvHeadersBucket = []byte("headers")
+	// spvHashesBucket maps block hash to block height.
+	spvHashesBucket = []byte("hashes")
+	// spvFilterHeadersBucket maps block height to the BIP157 filter header.
+	spvFilterHeadersBucket = []byte("filterheaders")
+	// spvTxsBucket maps txid to a stored transaction.
+	spvTxsBucket = []byte("txs")
+	// spvWatchedBucket maps outpoint to the pubkey script of a watched output.
+	spvWatchedBucket = []byte("watched")
+	// spvMetaBucket holds the keystore and the scan progress.
+	spvMetaBucket = []byte("meta")
+
+	keystoreKey   = []byte("keystore")
+	scanHeightKey = []byte("scanheight")
+)
+
+// spvDB is the SPV wallet's database. Block headers and filter headers are
+// stored for the entire chain. Transactions are stored if they pay to or spend
+// from the wallet, or if they involve an output that the wallet is watching.
+type spvDB struct {
+	*bbolt.DB
+}
+
+// newSPVDB opens the database, creating it if it doesn't exist. A new
+// database is seeded with the genesis block header.
+func newSPVDB(path string, genesis *wire.BlockHeader) (*spvDB, error) {
+	bdb, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	db := &spvDB{DB: bdb}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{spvHeadersBucket, spvHashesBucket, spvFilterHeadersBucket,
+			spvTxsBucket, spvWatchedBucket, spvMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return fmt.Errorf("error creating %s bucket: %v", string(bucket), err)
+			}
+		}
+		if tx.Bucket(spvHeadersBucket).Stats().KeyN > 0 {
+			return nil
+		}
+		return putHeader(tx, 0, genesis, blockchain.CalcWork(genesis.Bits))
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// putHeader stores the header at the height.
+func putHeader(tx *bbolt.Tx, height uint32, hdr *wire.BlockHeader, work *big.Int) error {
+	b := bytes.NewBuffer(make([]byte, 0, wire.MaxBlockHeaderPayload+32))
+	if err := hdr.Serialize(b); err != nil {
+		return err
+	}
+	b.Write(work.Bytes())
+	hash := hdr.BlockHash()
+	heightB := encode.Uint32Bytes(height)
+	if err := tx.Bucket(spvHeadersBucket).Put(heightB, b.Bytes()); err != nil {
+		return err
+	}
+	return tx.Bucket(spvHashesBucket).Put(hash[:], heightB)
+}
+
+// decodeHeader decodes the header and cumulative work stored by putHeader.
+func decodeHeader(b []byte) (*wire.BlockHeader, *big.Int, error) {
+	if len(b) < wire.MaxBlockHeaderPayload {
+		return nil, nil, fmt.Errorf("invalid stored header length %d", len(b))
+	}
+	hdr := new(wire.BlockHeader)
+	if err := hdr.Deserialize(bytes.NewReader(b[:wire.MaxBlockHeaderPayload])); err != nil {
+		return nil, nil, err
+	}
+	return hdr, new(big.Int).SetBytes(b[wire.MaxBlockHeaderPayload:]), nil
+}
+
+// tip is the height, header, and cumulative work of the best block header.
+func (db *spvDB) tip() (height uint32, hdr *wire.BlockHeader, work *big.Int, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		k, v := tx.Bucket(spvHeadersBucket).Cursor().Last()
+		if k == nil {
+			return fmt.Errorf("no headers")
+		}
+		height = binary.BigEndian.Uint32(k)
+		hdr, work, err = decodeHeader(v)
+		return err
+	})
+	return
+}
+
+// header is the block header and cumulative work at the height.
+func (db *spvDB) header(height uint32) (hdr *wire.BlockHeader, work *big.Int, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(spvHeadersBucket).Get(encode.Uint32Bytes(height))
+		if v == nil {
+			return fmt.Errorf("no header at height %d", height)
+		}
+		hdr, work, err = decodeHeader(v)
+		return err
+	})
+	return
+}
+
+// blockHash is the hash of the main chain block at the height.
+func (db *spvDB) blockHash(height uint32) (*chainhash.Hash, error) {
+	hdr, _, err := db.header(height)
+	if err != nil {
+		return nil, err
+	}
+	hash := hdr.BlockHash()
+	return &hash, nil
+}
+
+// blockHeight is the height of the main chain block with the hash. The
+// returned bool is false if the block is not in the main chain.
+func (db *spvDB) blockHeight(hash *chainhash.Hash) (height uint32, found bool, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(spvHashesBucket).Get(hash[:])
+		if v == nil {
+			return nil
+		}
+		height, found = binary.BigEndian.Uint32(v), true
+		return nil
+	})
+	return
+}
+
+// putHeaders stores the headers beginning at startHeight. Any stored headers
+// and filter headers at or above startHeight are removed first, so putHeaders
+// also handles reorganizations.
+func (db *spvDB) putHeaders(startHeight uint32, hdrs []*wire.BlockHeader, works []*big.Int) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		startB := encode.Uint32Bytes(startHeight)
+		hashBkt := tx.Bucket(spvHashesBucket)
+		c := tx.Bucket(spvHeadersBucket).Cursor()
+		for _, v := c.Seek(startB); v != nil; _, v = c.Next() {
+			hdr, _, err := decodeHeader(v)
+			if err != nil {
+				return err
+			}
+			hash := hdr.BlockHash()
+			if err := hashBkt.Delete(hash[:]); err != nil {
+				return err
+			}
+		}
+		if err := deleteFrom(tx.Bucket(spvHeadersBucket), startB); err != nil {
+			return err
+		}
+		if err := deleteFrom(tx.Bucket(spvFilterHeadersBucket), startB); err != nil {
+			return err
+		}
+		for i, hdr := range hdrs {
+			if err := putHeader(tx, startHeight+uint32(i), hdr, works[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteFrom deletes the entries of the bucket with keys at or after start.
+func deleteFrom(bkt *bbolt.Bucket, start []byte) error {
+	// Deleting while iterating with a cursor can skip entries, so collect the
+	// keys first.
+	var keys [][]byte
+	c := bkt.Cursor()
+	for k, _ := c.Seek(start); k != nil; k, _ = c.Next() {
+		keys = append(keys, encode.CopySlice(k))
+	}
+	for _, k := range keys {
+		if err := bkt.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterTip is the height of the highest stored filter header. The returned
+// bool is false if there are no filter headers.
+func (db *spvDB) filterTip() (height uint32, found bool, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		k, _ := tx.Bucket(spvFilterHeadersBucket).Cursor().Last()
+		if k != nil {
+			height, found = binary.BigEndian.Uint32(k), true
+		}
+		return nil
+	})
+	return
+}
+
+// filterHeader is the filter header at the height.
+func (db *spvDB) filterHeader(height uint32) (hdr chainhash.Hash, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(spvFilterHeadersBucket).Get(encode.Uint32Bytes(height))
+		if v == nil {
+			return fmt.Errorf("no filter header at height %d", height)
+		}
+		copy(hdr[:], v)
+		return nil
+	})
+	return
+}
+
+// putFilterHeaders stores the filter headers beginning at startHeight.
+func (db *spvDB) putFilterHeaders(startHeight uint32, hdrs []chainhash.Hash) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(spvFilterHeadersBucket)
+		for i := range hdrs {
+			if err := bkt.Put(encode.Uint32Bytes(startHeight+uint32(i)), hdrs[i][:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// storedTx is a transaction that is relevant to the wallet. A height of zero
+// indicates that the transaction is not mined.
+type storedTx struct {
+	tx         *wire.MsgTx
+	hash       chainhash.Hash
+	height     uint32
+	blockHash  chainhash.Hash
+	blockIndex uint32
+	received   time.Time
+}
+
+// encode serializes the storedTx.
+func (stx *storedTx) encode() ([]byte, error) {
+	txB, err := serializeMsgTx(stx.tx)
+	if err != nil {
+		return nil, err
+	}
+	return encode.BuildyBytes{0}.
+		AddData(txB).
+		AddData(encode.Uint32Bytes(stx.height)).
+		AddData(stx.blockHash[:]).
+		AddData(encode.Uint32Bytes(stx.blockIndex)).
+		AddData(encode.Uint64Bytes(uint64(stx.received.Unix()))), nil
+}
+
+// decodeStoredTx decodes the versioned blob to a *storedTx.
+func decodeStoredTx(b []byte) (*storedTx, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeStoredTx_v0(pushes)
+	}
+	return nil, fmt.Errorf("unknown stored transaction version %d", ver)
+}
+
+func decodeStoredTx_v0(pushes [][]byte) (*storedTx, error) {
+	if len(pushes) != 5 {
+		return nil, fmt.Errorf("decodeStoredTx_v0: expected 5 pushes, got %d", len(pushes))
+	}
+	msgTx, err := msgTxFromHex(pushes[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(pushes[1]) != 4 || len(pushes[2]) != chainhash.HashSize || len(pushes[3]) != 4 || len(pushes[4]) != 8 {
+		return nil, fmt.Errorf("decodeStoredTx_v0: invalid push lengths")
+	}
+	stx := &storedTx{
+		tx:         msgTx,
+		hash:       msgTx.TxHash(),
+		height:     binary.BigEndian.Uint32(pushes[1]),
+		blockIndex: binary.BigEndian.Uint32(pushes[3]),
+		received:   time.Unix(int64(binary.BigEndian.Uint64(pushes[4])), 0),
+	}
+	copy(stx.blockHash[:], pushes[2])
+	return stx, nil
+}
+
+// storedTxs loads all stored transactions.
+func (db *spvDB) storedTxs() ([]*storedTx, error) {
+	var stxs []*storedTx
+	return stxs, db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvTxsBucket).ForEach(func(k, v []byte) error {
+			stx, err := decodeStoredTx(v)
+			if err != nil {
+				return fmt.Errorf("error decoding stored transaction %x: %v", k, err)
+			}
+			stxs = append(stxs, stx)
+			return nil
+		})
+	})
+}
+
+// putTx stores the transaction.
+func (db *spvDB) putTx(stx *storedTx) error {
+	b, err := stx.encode()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvTxsBucket).Put(stx.hash[:], b)
+	})
+}
+
+// deleteTx removes the transaction.
+func (db *spvDB) deleteTx(hash *chainhash.Hash) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvTxsBucket).Delete(hash[:])
+	})
+}
+
+// outpointKey is the database key for the outpoint.
+func outpointKey(op *wire.OutPoint) []byte {
+	return append(op.Hash.CloneBytes(), encode.Uint32Bytes(op.Index)...)
+}
+
+// watchedOutputs loads the watched outputs.
+func (db *spvDB) watchedOutputs() (map[wire.OutPoint][]byte, error) {
+	watched := make(map[wire.OutPoint][]byte)
+	return watched, db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvWatchedBucket).ForEach(func(k, v []byte) error {
+			if len(k) != chainhash.HashSize+4 {
+				return fmt.Errorf("invalid watched output key %x", k)
+			}
+			var op wire.OutPoint
+			copy(op.Hash[:], k[:chainhash.HashSize])
+			op.Index = binary.BigEndian.Uint32(k[chainhash.HashSize:])
+			watched[op] = encode.CopySlice(v)
+			return nil
+		})
+	})
+}
+
+// putWatched stores the watched output.
+func (db *spvDB) putWatched(op *wire.OutPoint, pkScript []byte) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvWatchedBucket).Put(outpointKey(op), pkScript)
+	})
+}
+
+// deleteWatched removes the watched output.
+func (db *spvDB) deleteWatched(op *wire.OutPoint) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvWatchedBucket).Delete(outpointKey(op))
+	})
+}
+
+// meta retrieves the value stored under the key in the meta bucket. An empty
+// value is returned if there is no entry.
+func (db *spvDB) meta(k []byte) (v []byte, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		v = encode.CopySlice(tx.Bucket(spvMetaBucket).Get(k))
+		return nil
+	})
+	return
+}
+
+// putMeta stores the value under the key in the meta bucket.
+func (db *spvDB) putMeta(k, v []byte) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(spvMetaBucket).Put(k, v)
+	})
+}
@@ -0,0 +1,275 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex/config"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const deviceKey = "device"
+
+// DeviceOption is the wallet setting for a hardware wallet that holds the keys
+// of the built-in wallet. The device must be connected when the wallet is
+// created and whenever the wallet signs. A BTC clone can append DeviceOption
+// to its WalletInfo.ConfigOpts.
+var DeviceOption = &config.Option{
+	Key:         deviceKey,
+	DisplayName: "Hardware Wallet",
+	Description: "The hardware wallet that holds the built-in wallet's keys, e.g. ledger or trezor (default none)",
+}
+
+// hwSigner signs for the built-in wallet with a hardware wallet. The device is
+// connected when first needed.
+type hwSigner struct {
+	assetID  uint32
+	name     string
+	settings map[string]string
+	prompt   asset.Prompter
+
+	mtx sync.Mutex
+	dev asset.Device
+}
+
+// device connects to the hardware wallet if not already connected.
+func (hw *hwSigner) device() (asset.Device, error) {
+	hw.mtx.Lock()
+	defer hw.mtx.Unlock()
+	if hw.dev == nil {
+		dev, err := asset.OpenDevice(hw.name, hw.settings, hw.prompt)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to hardware wallet: %v", err)
+		}
+		hw.dev = dev
+	}
+	return hw.dev, nil
+}
+
+// close disconnects from the hardware wallet.
+func (hw *hwSigner) close() {
+	hw.mtx.Lock()
+	defer hw.mtx.Unlock()
+	if hw.dev != nil {
+		hw.dev.Close()
+		hw.dev = nil
+	}
+}
+
+// newKeystore creates a watch-only keystore with the device's account keys.
+func (hw *hwSigner) newKeystore(pw []byte, params *chaincfg.Params) (*keystore, error) {
+	dev, err := hw.device()
+	if err != nil {
+		return nil, err
+	}
+	var acctPubs [2]string
+	for i := range acctPubs {
+		acctPubs[i], err = dev.ExtendedPubKey(hw.assetID, accountPath(i, params))
+		if err != nil {
+			return nil, fmt.Errorf("error getting account key from %s: %v", dev.Name(), err)
+		}
+	}
+	return newWatchOnlyKeystore(pw, acctPubs, params)
+}
+
+// signTx asks the user to confirm the transaction, then has the device sign
+// the inputs.
+func (hw *hwSigner) signTx(tx *wire.MsgTx, inputs []*asset.SignInput, summary string) ([][]byte, error) {
+	dev, err := hw.device()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	hw.confirm(dev, summary)
+	sigs, err := dev.SignTx(&asset.SignTxRequest{
+		AssetID: hw.assetID,
+		Tx:      buf.Bytes(),
+		Inputs:  inputs,
+		Summary: summary,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s signing error: %v", dev.Name(), err)
+	}
+	if len(sigs) != len(inputs) {
+		return nil, fmt.Errorf("%s returned %d signatures for %d inputs", dev.Name(), len(sigs), len(inputs))
+	}
+	return sigs, nil
+}
+
+// confirm tells the user to review the operation on the device.
+func (hw *hwSigner) confirm(dev asset.Device, msg string) {
+	if hw.prompt == nil {
+		return
+	}
+	hw.prompt(&asset.DevicePrompt{
+		Kind:    asset.PromptConfirm,
+		Device:  dev.Name(),
+		Message: msg,
+	})
+}
+
+// setDevice has the wallet keep its keys on the hardware wallet. setDevice
+// must be called before the wallet is connected.
+func (w *spvWallet) setDevice(assetID uint32, name string, settings map[string]string, prompt asset.Prompter) {
+	w.hw = &hwSigner{
+		assetID:  assetID,
+		name:     name,
+		settings: settings,
+		prompt:   prompt,
+	}
+}
+
+// hwSignInputs signs the transaction inputs that spend wallet outputs with the
+// hardware wallet. The wallet mutex must not be held.
+func (w *spvWallet) hwSignInputs(tx *wire.MsgTx, res *SignTxResult) error {
+	w.mtx.RLock()
+	if w.ks == nil {
+		w.mtx.RUnlock()
+		return fmt.Errorf("wallet not created")
+	}
+	var inputs []*asset.SignInput
+	var pubKeys [][]byte
+	for i, txIn := range tx.TxIn {
+		prevOut := w.prevOutput(&txIn.PreviousOutPoint)
+		if prevOut == nil {
+			addSignError(res, txIn, fmt.Errorf("input not found or already spent"))
+			continue
+		}
+		info := w.addrs[string(prevOut.PkScript)]
+		pubKey, err := w.ks.pubKey(info.branch, info.index)
+		if err != nil {
+			w.mtx.RUnlock()
+			return err
+		}
+		inputs = append(inputs, &asset.SignInput{
+			Index:   uint32(i),
+			Path:    w.ks.path(info),
+			Script:  prevOut.PkScript,
+			Value:   uint64(prevOut.Value),
+			Witness: info.branch != branchLegacy,
+		})
+		pubKeys = append(pubKeys, pubKey.SerializeCompressed())
+	}
+	summary := w.txSummary(tx)
+	w.mtx.RUnlock()
+	if len(inputs) == 0 {
+		return nil
+	}
+	sigs, err := w.hw.signTx(tx, inputs, summary)
+	if err != nil {
+		return err
+	}
+	for i, in := range inputs {
+		txIn := tx.TxIn[in.Index]
+		if in.Witness {
+			txIn.Witness = wire.TxWitness{sigs[i], pubKeys[i]}
+			continue
+		}
+		txIn.SignatureScript, err = txscript.NewScriptBuilder().AddData(sigs[i]).AddData(pubKeys[i]).Script()
+		if err != nil {
+			addSignError(res, txIn, err)
+		}
+	}
+	return nil
+}
+
+// hwInputSig signs the transaction input, which spends a non-segwit script,
+// with the hardware wallet key for the address. The signature and the
+// compressed pubkey are returned.
+func (w *spvWallet) hwInputSig(tx *wire.MsgTx, idx int, script []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	w.mtx.RLock()
+	path, pubkey, err := w.addrKey(addr)
+	summary := w.txSummary(tx)
+	w.mtx.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	sigs, err := w.hw.signTx(tx, []*asset.SignInput{{
+		Index:  uint32(idx),
+		Path:   path,
+		Script: script,
+	}}, summary)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sigs[0], pubkey, nil
+}
+
+// hwSignHash signs the hash with the hardware wallet key for the address. The
+// DER-encoded signature and the compressed pubkey are returned.
+func (w *spvWallet) hwSignHash(addrStr string, hash []byte) (sig, pubkey []byte, err error) {
+	addr, err := btcutil.DecodeAddress(addrStr, w.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.mtx.RLock()
+	path, pubkey, err := w.addrKey(addr)
+	w.mtx.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	dev, err := w.hw.device()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hw.confirm(dev, fmt.Sprintf("Sign a message with the key for %s", addrStr))
+	sig, err = dev.SignHash(w.hw.assetID, path, hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s signing error: %v", dev.Name(), err)
+	}
+	return sig, pubkey, nil
+}
+
+// addrKey is the derivation path and compressed pubkey of the wallet address's
+// key. The wallet mutex must be held.
+func (w *spvWallet) addrKey(addr btcutil.Address) ([]uint32, []byte, error) {
+	if w.ks == nil {
+		return nil, nil, fmt.Errorf("wallet not created")
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := w.addrs[string(pkScript)]
+	if info == nil {
+		return nil, nil, fmt.Errorf("address %s is not a wallet address", addr)
+	}
+	pubKey, err := w.ks.pubKey(info.branch, info.index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.ks.path(info), pubKey.SerializeCompressed(), nil
+}
+
+// txSummary describes the outputs of the transaction for the user to compare
+// with the device's display. The wallet mutex must be held.
+func (w *spvWallet) txSummary(tx *wire.MsgTx) string {
+	var pays []string
+	for _, txOut := range tx.TxOut {
+		if w.addrs[string(txOut.PkScript)] != nil {
+			continue // change
+		}
+		dest := "a script"
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, w.params)
+		if err == nil && len(addrs) == 1 {
+			dest = addrs[0].String()
+		}
+		pays = append(pays, fmt.Sprintf("%.8f to %s", btcutil.Amount(txOut.Value).ToBTC(), dest))
+	}
+	if len(pays) == 0 {
+		return "Confirm the transfer between your own addresses"
+	}
+	return "Confirm the payment of " + strings.Join(pays, ", ")
+}
@@ -0,0 +1,252 @@
+// +build !harness
+
+package btc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// tDevice is a hardware wallet with a software seed.
+type tDevice struct {
+	master *hdkeychain.ExtendedKey
+	mtx    sync.Mutex
+	signed int
+	closed bool
+}
+
+func (d *tDevice) Name() string { return "Test Device" }
+
+func (d *tDevice) key(path []uint32) (*hdkeychain.ExtendedKey, error) {
+	key := d.master
+	for _, idx := range path {
+		var err error
+		key, err = key.Child(idx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (d *tDevice) privKey(path []uint32) (*btcec.PrivateKey, error) {
+	key, err := d.key(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.ECPrivKey()
+}
+
+func (d *tDevice) ExtendedPubKey(assetID uint32, path []uint32) (string, error) {
+	key, err := d.key(path)
+	if err != nil {
+		return "", err
+	}
+	pub, err := key.Neuter()
+	if err != nil {
+		return "", err
+	}
+	return pub.String(), nil
+}
+
+func (d *tDevice) SignTx(req *asset.SignTxRequest) ([][]byte, error) {
+	tx := new(wire.MsgTx)
+	if err := tx.Deserialize(bytes.NewReader(req.Tx)); err != nil {
+		return nil, err
+	}
+	sigHashes := txscript.NewTxSigHashes(tx)
+	sigs := make([][]byte, 0, len(req.Inputs))
+	for _, in := range req.Inputs {
+		privKey, err := d.privKey(in.Path)
+		if err != nil {
+			return nil, err
+		}
+		var sig []byte
+		if in.Witness {
+			sig, err = txscript.RawTxInWitnessSignature(tx, sigHashes, int(in.Index), int64(in.Value),
+				in.Script, txscript.SigHashAll, privKey)
+		} else {
+			sig, err = txscript.RawTxInSignature(tx, int(in.Index), in.Script, txscript.SigHashAll, privKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	d.mtx.Lock()
+	d.signed++
+	d.mtx.Unlock()
+	return sigs, nil
+}
+
+func (d *tDevice) SignHash(assetID uint32, path []uint32, hash []byte) ([]byte, error) {
+	privKey, err := d.privKey(path)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := privKey.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+func (d *tDevice) Close() error {
+	d.mtx.Lock()
+	d.closed = true
+	d.mtx.Unlock()
+	return nil
+}
+
+type tDeviceDriver struct {
+	dev *tDevice
+}
+
+func (drv *tDeviceDriver) Open(settings map[string]string, prompt asset.Prompter) (asset.Device, error) {
+	return drv.dev, nil
+}
+
+var tDeviceDrv = new(tDeviceDriver)
+
+func init() {
+	asset.RegisterDevice("tdevice", tDeviceDrv)
+}
+
+func TestSPVHardwareWallet(t *testing.T) {
+	seed, _ := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	master, err := hdkeychain.NewMaster(seed, tSPVParams)
+	if err != nil {
+		t.Fatalf("NewMaster error: %v", err)
+	}
+	dev := &tDevice{master: master}
+	tDeviceDrv.dev = dev
+
+	dir, err := ioutil.TempDir("", "spvtest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	src.mine()
+	w := newSPVWallet(&SPVConfig{SPV: true, Dir: dir}, tSPVParams, tLogger)
+	w.src = src
+	var prompts []*asset.DevicePrompt
+	w.setDevice(0, "tdevice", nil, func(p *asset.DevicePrompt) (string, error) {
+		prompts = append(prompts, p)
+		return "", nil
+	})
+	ctx, cancel := context.WithCancel(tCtx)
+	wg, err := w.connect(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("connect error: %v", err)
+	}
+	shutdown := func() {
+		cancel()
+		wg.Wait()
+	}
+	tSync(t, w)
+
+	wc := newWalletClient(w, tSPVParams)
+	if err := wc.Unlock("pass", time.Hour); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	if !w.ks.watchOnly() {
+		t.Fatalf("keystore has a seed")
+	}
+	addrWPKH, _ := wc.AddressWPKH()
+	addrPKH, _ := wc.AddressPKH()
+	fundWPKH := tFundingTx(t, addrWPKH.String(), 1e8)
+	fundPKH := tFundingTx(t, addrPKH.String(), 2e8)
+	src.mine(fundWPKH, fundPKH)
+	tSync(t, w)
+
+	// The device's keys control the wallet's addresses.
+	wif, err := wc.PrivKeyForAddress(addrPKH.String())
+	if err == nil {
+		t.Fatalf("private key exported from hardware wallet: %v", wif)
+	}
+
+	// Spending both outputs has the device sign both kinds of input.
+	dest := tP2PKHScript()
+	_, destAddrs, _, _ := txscript.ExtractPkScriptAddrs(dest, tSPVParams)
+	txHash, err := wc.SendToAddress(destAddrs[0].String(), 25e7, 10, false)
+	if err != nil {
+		t.Fatalf("SendToAddress error: %v", err)
+	}
+	if dev.signed != 1 {
+		t.Fatalf("expected 1 device signing, got %d", dev.signed)
+	}
+	if len(prompts) != 1 || prompts[0].Kind != asset.PromptConfirm || prompts[0].Device != dev.Name() {
+		t.Fatalf("wrong prompts %+v", prompts)
+	}
+	sendTx := src.sent[0]
+	if sendTx.TxHash() != *txHash || len(sendTx.TxIn) != 2 {
+		t.Fatalf("wrong sent transaction")
+	}
+	prevOuts := map[chainhash.Hash]*wire.TxOut{
+		fundWPKH.TxHash(): fundWPKH.TxOut[0],
+		fundPKH.TxHash():  fundPKH.TxOut[0],
+	}
+	sigHashes := txscript.NewTxSigHashes(sendTx)
+	for i, txIn := range sendTx.TxIn {
+		prevOut := prevOuts[txIn.PreviousOutPoint.Hash]
+		vm, err := txscript.NewEngine(prevOut.PkScript, sendTx, i, txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value)
+		if err != nil {
+			t.Fatalf("NewEngine error: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("input %d signature invalid: %v", i, err)
+		}
+	}
+
+	// A message signed by the device verifies with the address's pubkey.
+	hash := chainhash.HashB([]byte("msg"))
+	sigB, pubB, err := w.hwSignHash(addrPKH.String(), hash)
+	if err != nil {
+		t.Fatalf("hwSignHash error: %v", err)
+	}
+	if !bytes.Equal(btcutil.Hash160(pubB), addrPKH.ScriptAddress()) {
+		t.Fatalf("wrong pubkey")
+	}
+	sig, err := btcec.ParseDERSignature(sigB, btcec.S256())
+	if err != nil {
+		t.Fatalf("error parsing signature: %v", err)
+	}
+	pub, _ := btcec.ParsePubKey(pubB, btcec.S256())
+	if !sig.Verify(hash, pub) {
+		t.Fatalf("signature doesn't verify")
+	}
+
+	// The keystore is reloaded watch-only, and the password is still checked.
+	shutdown()
+	if !dev.closed {
+		t.Fatalf("device not closed on shutdown")
+	}
+	w, shutdown = tSPVWallet(t, dir, src)
+	defer shutdown()
+	wc = newWalletClient(w, tSPVParams)
+	if err := wc.Unlock("wrong", 0); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	if err := wc.Unlock("pass", 0); err != nil {
+		t.Fatalf("unlock error after restart: %v", err)
+	}
+	if !w.ks.watchOnly() {
+		t.Fatalf("reloaded keystore has a seed")
+	}
+}
@@ -0,0 +1,341 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/encrypt"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// gapLimit is the number of addresses past the last used address on each
+// branch that the SPV wallet watches for payments.
+const gapLimit = 20
+
+// addrBranch is a branch of the SPV wallet's HD key tree.
+type addrBranch uint32
+
+const (
+	// branchExternal is the BIP84 (P2WPKH) receiving branch,
+	// m/84'/coin'/0'/0.
+	branchExternal addrBranch = iota
+	// branchInternal is the BIP84 (P2WPKH) change branch, m/84'/coin'/0'/1.
+	branchInternal
+	// branchLegacy is the BIP44 (P2PKH) receiving branch, m/44'/coin'/0'/0.
+	// Legacy addresses are used for swap contracts, which require a pubkey
+	// hash.
+	branchLegacy
+	numBranches
+)
+
+// account is the index of the account key for the branch, and child is the
+// branch's index under the account key.
+func (b addrBranch) account() int {
+	if b == branchLegacy {
+		return 1
+	}
+	return 0
+}
+
+func (b addrBranch) child() uint32 {
+	if b == branchInternal {
+		return 1
+	}
+	return 0
+}
+
+// acctPurposes are the BIP43 purposes of the account keys.
+var acctPurposes = [2]uint32{84, 44}
+
+// accountPath is the derivation path of the account key, m/purpose'/coin'/0'.
+func accountPath(acct int, params *chaincfg.Params) []uint32 {
+	return []uint32{
+		hdkeychain.HardenedKeyStart + acctPurposes[acct],
+		hdkeychain.HardenedKeyStart + params.HDCoinType,
+		hdkeychain.HardenedKeyStart,
+	}
+}
+
+// addrInfo is a wallet address and its place in the key tree.
+type addrInfo struct {
+	addr     btcutil.Address
+	pkScript []byte
+	branch   addrBranch
+	index    uint32
+}
+
+// keystore holds the SPV wallet's HD seed, encrypted with the wallet password.
+// The account public keys are stored unencrypted, so that addresses can be
+// derived while the wallet is locked. The account private keys are only
+// available while the wallet is unlocked. A watch-only keystore has no seed,
+// because the keys are held by a hardware wallet.
+type keystore struct {
+	params   *chaincfg.Params
+	crypter  []byte
+	encSeed  []byte
+	acctPubs [2]*hdkeychain.ExtendedKey
+	acctPrvs [2]*hdkeychain.ExtendedKey
+	// next is the index of the next unused address on each branch.
+	next [numBranches]uint32
+	// birthday is the keystore creation time. Blocks mined well before the
+	// birthday cannot have wallet transactions and are not scanned.
+	birthday time.Time
+}
+
+// newKeystore encrypts the HD seed with the password. If seed is nil, a new
+// seed is generated. The returned keystore is unlocked.
+func newKeystore(pw, seed []byte, params *chaincfg.Params) (*keystore, error) {
+	if len(pw) == 0 {
+		return nil, fmt.Errorf("a password is required to create the wallet")
+	}
+	if len(seed) == 0 {
+		var err error
+		seed, err = hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+		if err != nil {
+			return nil, err
+		}
+		defer encode.ClearBytes(seed)
+	}
+	crypter := encrypt.NewCrypter(pw)
+	defer crypter.Close()
+	encSeed, err := crypter.Encrypt(seed)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting seed: %v", err)
+	}
+	ks := &keystore{
+		params:   params,
+		crypter:  crypter.Serialize(),
+		encSeed:  encSeed,
+		birthday: time.Now(),
+	}
+	if err := ks.setAccountKeys(seed); err != nil {
+		return nil, err
+	}
+	for i, prv := range ks.acctPrvs {
+		ks.acctPubs[i], err = prv.Neuter()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// newWatchOnlyKeystore creates a keystore from the serialized account public
+// keys. The password is only checked by unlock.
+func newWatchOnlyKeystore(pw []byte, acctPubs [2]string, params *chaincfg.Params) (*keystore, error) {
+	if len(pw) == 0 {
+		return nil, fmt.Errorf("a password is required to create the wallet")
+	}
+	crypter := encrypt.NewCrypter(pw)
+	defer crypter.Close()
+	ks := &keystore{
+		params:   params,
+		crypter:  crypter.Serialize(),
+		birthday: time.Now(),
+	}
+	for i, s := range acctPubs {
+		key, err := hdkeychain.NewKeyFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding account key: %v", err)
+		}
+		if key.IsPrivate() || !key.IsForNet(params) {
+			return nil, fmt.Errorf("account key is not a public key for %s", params.Name)
+		}
+		ks.acctPubs[i] = key
+	}
+	return ks, nil
+}
+
+// watchOnly is true if the keystore has no seed.
+func (ks *keystore) watchOnly() bool {
+	return len(ks.encSeed) == 0
+}
+
+// setAccountKeys derives the account private keys from the seed.
+func (ks *keystore) setAccountKeys(seed []byte) error {
+	master, err := hdkeychain.NewMaster(seed, ks.params)
+	if err != nil {
+		return fmt.Errorf("error creating master key: %v", err)
+	}
+	defer master.Zero()
+	for i := range ks.acctPrvs {
+		key := master
+		for _, idx := range accountPath(i, ks.params) {
+			key, err = key.Child(idx)
+			if err != nil {
+				return fmt.Errorf("error deriving account key: %v", err)
+			}
+		}
+		ks.acctPrvs[i] = key
+	}
+	return nil
+}
+
+// encode serializes the keystore. The account private keys are not included.
+func (ks *keystore) encode() []byte {
+	b := encode.BuildyBytes{0}.
+		AddData(ks.crypter).
+		AddData(ks.encSeed).
+		AddData([]byte(ks.acctPubs[0].String())).
+		AddData([]byte(ks.acctPubs[1].String())).
+		AddData(encode.Uint64Bytes(uint64(ks.birthday.Unix())))
+	for _, next := range ks.next {
+		b = b.AddData(encode.Uint32Bytes(next))
+	}
+	return b
+}
+
+// decodeKeystore decodes the versioned blob to a locked *keystore.
+func decodeKeystore(b []byte, params *chaincfg.Params) (*keystore, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeKeystore_v0(pushes, params)
+	}
+	return nil, fmt.Errorf("unknown keystore version %d", ver)
+}
+
+func decodeKeystore_v0(pushes [][]byte, params *chaincfg.Params) (*keystore, error) {
+	if len(pushes) != 5+int(numBranches) {
+		return nil, fmt.Errorf("decodeKeystore_v0: expected %d pushes, got %d", 5+numBranches, len(pushes))
+	}
+	ks := &keystore{
+		params:  params,
+		crypter: pushes[0],
+		encSeed: pushes[1],
+	}
+	for i := range ks.acctPubs {
+		key, err := hdkeychain.NewKeyFromString(string(pushes[2+i]))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding account key: %v", err)
+		}
+		if !key.IsForNet(params) {
+			return nil, fmt.Errorf("account key is for the wrong network")
+		}
+		ks.acctPubs[i] = key
+	}
+	if len(pushes[4]) != 8 {
+		return nil, fmt.Errorf("decodeKeystore_v0: invalid birthday length %d", len(pushes[4]))
+	}
+	ks.birthday = time.Unix(int64(binary.BigEndian.Uint64(pushes[4])), 0)
+	for i := range ks.next {
+		nextB := pushes[5+i]
+		if len(nextB) != 4 {
+			return nil, fmt.Errorf("decodeKeystore_v0: invalid index length %d", len(nextB))
+		}
+		ks.next[i] = binary.BigEndian.Uint32(nextB)
+	}
+	return ks, nil
+}
+
+// unlock decrypts the seed and derives the account private keys.
+func (ks *keystore) unlock(pw []byte) error {
+	crypter, err := encrypt.Deserialize(pw, ks.crypter)
+	if err != nil {
+		return fmt.Errorf("error decrypting keystore: %v", err)
+	}
+	defer crypter.Close()
+	if ks.watchOnly() {
+		return nil
+	}
+	seed, err := crypter.Decrypt(ks.encSeed)
+	if err != nil {
+		return fmt.Errorf("incorrect password")
+	}
+	defer encode.ClearBytes(seed)
+	ks.lock()
+	return ks.setAccountKeys(seed)
+}
+
+// lock zeros and discards the account private keys.
+func (ks *keystore) lock() {
+	for i, prv := range ks.acctPrvs {
+		if prv != nil {
+			prv.Zero()
+			ks.acctPrvs[i] = nil
+		}
+	}
+}
+
+// locked is true if the account private keys are not available.
+func (ks *keystore) locked() bool {
+	return ks.acctPrvs[0] == nil
+}
+
+// pubKey derives the public key at the index on the branch.
+func (ks *keystore) pubKey(branch addrBranch, index uint32) (*btcec.PublicKey, error) {
+	key, err := ks.acctPubs[branch.account()].Child(branch.child())
+	if err != nil {
+		return nil, err
+	}
+	key, err = key.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	return key.ECPubKey()
+}
+
+// path is the full derivation path of the address's key.
+func (ks *keystore) path(info *addrInfo) []uint32 {
+	return append(accountPath(info.branch.account(), ks.params), info.branch.child(), info.index)
+}
+
+// address derives the address at the index on the branch.
+func (ks *keystore) address(branch addrBranch, index uint32) (*addrInfo, error) {
+	pubKey, err := ks.pubKey(branch, index)
+	if err != nil {
+		return nil, err
+	}
+	pkHash := btcutil.Hash160(pubKey.SerializeCompressed())
+	var addr btcutil.Address
+	if branch == branchLegacy {
+		addr, err = btcutil.NewAddressPubKeyHash(pkHash, ks.params)
+	} else {
+		addr, err = btcutil.NewAddressWitnessPubKeyHash(pkHash, ks.params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &addrInfo{
+		addr:     addr,
+		pkScript: pkScript,
+		branch:   branch,
+		index:    index,
+	}, nil
+}
+
+// privKey derives the private key for the address. The keystore must be
+// unlocked.
+func (ks *keystore) privKey(info *addrInfo) (*btcec.PrivateKey, error) {
+	if ks.watchOnly() {
+		return nil, fmt.Errorf("private keys are held by a hardware wallet")
+	}
+	if ks.locked() {
+		return nil, fmt.Errorf("wallet is locked")
+	}
+	key, err := ks.acctPrvs[info.branch.account()].Child(info.branch.child())
+	if err != nil {
+		return nil, err
+	}
+	key, err = key.Child(info.index)
+	if err != nil {
+		return nil, err
+	}
+	return key.ECPrivKey()
+}
@@ -0,0 +1,312 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb" // bdb init() registers a driver
+	"github.com/lightninglabs/neutrino"
+)
+
+var (
+	// peerTimeout is how long to wait for a peer or server to respond to a
+	// request.
+	peerTimeout = 30 * time.Second
+	// txTimeout is how long to wait for a peer to respond to a request for a
+	// transaction. A peer that doesn't have a transaction may not respond at
+	// all.
+	txTimeout = 5 * time.Second
+	// connectInterval is how long the wallet waits for peers to connect before
+	// the first sync.
+	connectInterval = 5 * time.Second
+
+	errNoPeers = errors.New("no peers connected")
+)
+
+// blockSource is a source of block headers, compact filters, blocks, and
+// transactions. blockSource is satisfied by *chainService and
+// *electrumSource.
+type blockSource interface {
+	// peerCount is the number of connected peers.
+	peerCount() int
+	// getHeaders returns up to wire.MaxBlockHeadersPerMsg headers following
+	// the locator.
+	getHeaders(locator blockchain.BlockLocator) ([]*wire.BlockHeader, error)
+	// getFilterHeaders returns the regular filter headers for the blocks from
+	// startHeight to the block with stopHash. Fewer headers are returned if
+	// the source has not synced the filter headers up to stopHash.
+	getFilterHeaders(startHeight uint32, stopHash *chainhash.Hash) ([]chainhash.Hash, error)
+	// getFilters returns the count regular filters for the blocks from
+	// startHeight to the block with stopHash.
+	getFilters(startHeight uint32, stopHash *chainhash.Hash, count int) ([]*wire.MsgCFilter, error)
+	// getBlock returns the block.
+	getBlock(hash *chainhash.Hash) (*wire.MsgBlock, error)
+	// getTx requests the unconfirmed transaction. getTx returns a nil
+	// transaction if no peer has it.
+	getTx(hash *chainhash.Hash) (*wire.MsgTx, error)
+	// sendTx broadcasts the transaction.
+	sendTx(tx *wire.MsgTx) error
+}
+
+// chainService is a blockSource backed by a neutrino light client. neutrino
+// handles peer discovery, header validation, and checking the filter headers
+// of multiple peers for agreement. If a static list of peers is configured,
+// only those peers are used. Otherwise, peers are discovered through the
+// network's DNS seeds.
+type chainService struct {
+	*neutrino.ChainService
+	db       walletdb.DB
+	log      dex.Logger
+	newBlock func()
+}
+
+// newChainService is the constructor for a *chainService. The neutrino
+// database and header files are kept in dir. newBlock is called when neutrino
+// connects a block.
+func newChainService(params *chaincfg.Params, dir string, static []string, newBlock func(),
+	logger dex.Logger) (*chainService, error) {
+
+	db, err := walletdb.Create("bdb", filepath.Join(dir, "neutrino.db"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening neutrino database: %v", err)
+	}
+	cs, err := neutrino.NewChainService(neutrino.Config{
+		DataDir:      dir,
+		Database:     db,
+		ChainParams:  *params,
+		ConnectPeers: static,
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating neutrino chain service: %v", err)
+	}
+	return &chainService{
+		ChainService: cs,
+		db:           db,
+		log:          logger,
+		newBlock:     newBlock,
+	}, nil
+}
+
+// run starts neutrino, and calls newBlock for each connected block until the
+// context is canceled, at which point neutrino is stopped.
+func (cs *chainService) run(ctx context.Context) {
+	defer func() {
+		if err := cs.db.Close(); err != nil {
+			cs.log.Errorf("error closing neutrino database: %v", err)
+		}
+	}()
+	if err := cs.Start(); err != nil {
+		cs.log.Errorf("error starting neutrino: %v", err)
+		return
+	}
+	defer func() {
+		if err := cs.Stop(); err != nil {
+			cs.log.Errorf("error stopping neutrino: %v", err)
+		}
+	}()
+	sub, err := (&neutrino.RescanChainSource{ChainService: cs.ChainService}).Subscribe(0)
+	if err != nil {
+		cs.log.Errorf("error subscribing to neutrino block notifications: %v", err)
+		return
+	}
+	defer sub.Cancel()
+	for {
+		select {
+		case <-sub.Notifications:
+			cs.newBlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// peerCount is the number of connected peers. Part of the blockSource
+// interface.
+func (cs *chainService) peerCount() int {
+	return int(cs.ConnectedCount())
+}
+
+// bestPeerHeight is the best block height announced by a connected peer.
+func (cs *chainService) bestPeerHeight() uint32 {
+	var best int32
+	for _, sp := range cs.Peers() {
+		if h := sp.LastBlock(); h > best {
+			best = h
+		}
+	}
+	return uint32(best)
+}
+
+// mainChainHeight is the height of the block if it is in neutrino's best
+// chain.
+func (cs *chainService) mainChainHeight(hash *chainhash.Hash) (uint32, bool) {
+	_, height, err := cs.BlockHeaders.FetchHeader(hash)
+	if err != nil {
+		return 0, false
+	}
+	hdr, err := cs.BlockHeaders.FetchHeaderByHeight(height)
+	if err != nil || hdr.BlockHash() != *hash {
+		return 0, false
+	}
+	return height, true
+}
+
+// getHeaders returns the headers from neutrino's best chain following the
+// first locator block in that chain. Part of the blockSource interface.
+func (cs *chainService) getHeaders(locator blockchain.BlockLocator) ([]*wire.BlockHeader, error) {
+	_, tipHeight, err := cs.BlockHeaders.ChainTip()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range locator {
+		height, found := cs.mainChainHeight(hash)
+		if !found {
+			continue
+		}
+		var hdrs []*wire.BlockHeader
+		for h := height + 1; h <= tipHeight && len(hdrs) < wire.MaxBlockHeadersPerMsg; h++ {
+			hdr, err := cs.BlockHeaders.FetchHeaderByHeight(h)
+			if err != nil {
+				return nil, err
+			}
+			hdrs = append(hdrs, hdr)
+		}
+		return hdrs, nil
+	}
+	return nil, fmt.Errorf("no locator block is in the best chain")
+}
+
+// getFilterHeaders returns the filter headers that neutrino has synced and
+// checked with its peers. Part of the blockSource interface.
+func (cs *chainService) getFilterHeaders(startHeight uint32, stopHash *chainhash.Hash) ([]chainhash.Hash, error) {
+	stopHeight, found := cs.mainChainHeight(stopHash)
+	if !found {
+		return nil, fmt.Errorf("block %s is not in the best chain", stopHash)
+	}
+	_, filterTip, err := cs.RegFilterHeaders.ChainTip()
+	if err != nil {
+		return nil, err
+	}
+	if stopHeight > filterTip {
+		stopHeight = filterTip
+	}
+	var hdrs []chainhash.Hash
+	for h := startHeight; h <= stopHeight; h++ {
+		hdr, err := cs.RegFilterHeaders.FetchHeaderByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		hdrs = append(hdrs, *hdr)
+	}
+	return hdrs, nil
+}
+
+// getFilters returns the filters for the blocks in neutrino's best chain.
+// Part of the blockSource interface.
+func (cs *chainService) getFilters(startHeight uint32, stopHash *chainhash.Hash, count int) ([]*wire.MsgCFilter, error) {
+	stopHeight, found := cs.mainChainHeight(stopHash)
+	if !found {
+		return nil, fmt.Errorf("block %s is not in the best chain", stopHash)
+	}
+	if stopHeight+1 != startHeight+uint32(count) {
+		return nil, fmt.Errorf("%d filters requested from height %d to %d", count, startHeight, stopHeight)
+	}
+	msgs := make([]*wire.MsgCFilter, 0, count)
+	for h := startHeight; h <= stopHeight; h++ {
+		hdr, err := cs.BlockHeaders.FetchHeaderByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		blockHash := hdr.BlockHash()
+		filter, err := cs.GetCFilter(blockHash, wire.GCSFilterRegular)
+		if err != nil {
+			return nil, fmt.Errorf("error getting filter for block %s: %v", blockHash, err)
+		}
+		b, err := filter.NBytes()
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, wire.NewMsgCFilter(wire.GCSFilterRegular, &blockHash, b))
+	}
+	return msgs, nil
+}
+
+// getBlock requests the block through neutrino. Part of the blockSource
+// interface.
+func (cs *chainService) getBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	blk, err := cs.GetBlock(*hash)
+	if err != nil {
+		return nil, err
+	}
+	return blk.MsgBlock(), nil
+}
+
+// getTx requests the transaction from each peer in turn until one responds
+// with it. Peers will only have the transaction if it is in their mempool.
+// Part of the blockSource interface.
+func (cs *chainService) getTx(hash *chainhash.Hash) (*wire.MsgTx, error) {
+	peers := cs.Peers()
+	if len(peers) == 0 {
+		return nil, errNoPeers
+	}
+	req := wire.NewMsgGetData()
+	req.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessTx, hash))
+	for _, sp := range peers {
+		if tx := requestTx(sp, req, hash); tx != nil {
+			return tx, nil
+		}
+	}
+	return nil, nil
+}
+
+// requestTx sends the getdata request to the peer and waits for the
+// transaction. nil is returned if the peer does not have it.
+func requestTx(sp *neutrino.ServerPeer, req *wire.MsgGetData, hash *chainhash.Hash) *wire.MsgTx {
+	msgs, cancel := sp.SubscribeRecvMsg()
+	defer cancel()
+	sp.QueueMessage(req, nil)
+	timeout := time.After(txTimeout)
+	for {
+		select {
+		case msg := <-msgs:
+			switch msg := msg.(type) {
+			case *wire.MsgTx:
+				if msg.TxHash() == *hash {
+					return msg
+				}
+			case *wire.MsgNotFound:
+				for _, iv := range msg.InvList {
+					if iv.Hash == *hash {
+						return nil
+					}
+				}
+			}
+		case <-timeout:
+			return nil
+		case <-sp.OnDisconnect():
+			return nil
+		}
+	}
+}
+
+// sendTx broadcasts the transaction. neutrino rebroadcasts it with each new
+// block until it is mined. Part of the blockSource interface.
+func (cs *chainService) sendTx(tx *wire.MsgTx) error {
+	if cs.ConnectedCount() == 0 {
+		return errNoPeers
+	}
+	return cs.SendTransaction(tx)
+}
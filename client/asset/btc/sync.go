@@ -0,0 +1,113 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+)
+
+const methodGetBlockchainInfo = "getblockchaininfo"
+
+// Check that ExchangeWallet satisfies the SyncReporter interface.
+var _ asset.SyncReporter = (*ExchangeWallet)(nil)
+
+// getBlockchainInfoResult is a partial result of the getblockchaininfo RPC.
+type getBlockchainInfoResult struct {
+	Blocks               uint32  `json:"blocks"`
+	Headers              uint32  `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+}
+
+// SyncStatus reports the sync progress of the wallet's node, or of the SPV
+// wallet, with the number of unconfirmed wallet transactions. Part of the
+// asset.SyncReporter interface.
+func (btc *ExchangeWallet) SyncStatus() (*asset.SyncStatus, error) {
+	var status *asset.SyncStatus
+	var err error
+	if btc.spv != nil {
+		status, err = btc.spv.syncStatus()
+	} else {
+		status, err = btc.nodeSyncStatus()
+	}
+	if err != nil {
+		return nil, err
+	}
+	unspents, err := btc.wallet.ListUnspent()
+	if err != nil {
+		return nil, fmt.Errorf("error listing unspent outputs: %v", err)
+	}
+	pending := make(map[string]bool)
+	for _, utxo := range unspents {
+		if utxo.Confirmations == 0 {
+			pending[utxo.TxID] = true
+		}
+	}
+	status.PendingTxs = uint32(len(pending))
+	return status, nil
+}
+
+// nodeSyncStatus is the sync status of the RPC wallet's node.
+func (btc *ExchangeWallet) nodeSyncStatus() (*asset.SyncStatus, error) {
+	chainInfo := new(getBlockchainInfoResult)
+	if err := btc.wallet.call(methodGetBlockchainInfo, nil, chainInfo); err != nil {
+		return nil, fmt.Errorf("error getting blockchain info: %v", err)
+	}
+	netInfo := &struct {
+		Connections uint32 `json:"connections"`
+	}{}
+	if err := btc.wallet.call(methodGetNetworkInfo, nil, netInfo); err != nil {
+		return nil, fmt.Errorf("error getting network info: %v", err)
+	}
+	hdr, err := btc.getBlockHeader(chainInfo.BestBlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting best block header: %v", err)
+	}
+	return &asset.SyncStatus{
+		Synced:       !chainInfo.InitialBlockDownload && chainInfo.Blocks >= chainInfo.Headers && netInfo.Connections > 0,
+		Progress:     float32(chainInfo.VerificationProgress),
+		Height:       chainInfo.Blocks,
+		TargetHeight: chainInfo.Headers,
+		BlockTime:    time.Unix(hdr.Time, 0),
+		Peers:        netInfo.Connections,
+	}, nil
+}
+
+// syncStatus is the sync status of the SPV wallet. The wallet is synced when
+// it has scanned the blocks up to the best header known from its peers.
+func (w *spvWallet) syncStatus() (*asset.SyncStatus, error) {
+	tipHeight, hdr, _, err := w.db.tip()
+	if err != nil {
+		return nil, fmt.Errorf("error getting tip: %v", err)
+	}
+	target := tipHeight
+	if cs, ok := w.src.(*chainService); ok {
+		if best := cs.bestPeerHeight(); best > target {
+			target = best
+		}
+	}
+	w.mtx.RLock()
+	scanHeight := w.scanHeight
+	w.mtx.RUnlock()
+	if scanHeight > tipHeight {
+		scanHeight = tipHeight
+	}
+	var progress float32 = 1
+	if target > 0 {
+		progress = float32(scanHeight) / float32(target)
+	}
+	peers := uint32(w.src.peerCount())
+	return &asset.SyncStatus{
+		Synced:       peers > 0 && scanHeight >= target,
+		Progress:     progress,
+		Height:       scanHeight,
+		TargetHeight: target,
+		BlockTime:    hdr.Timestamp,
+		Peers:        peers,
+	}, nil
+}
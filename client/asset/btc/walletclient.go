@@ -33,6 +33,7 @@ const (
 	methodGetTransaction    = "gettransaction"
 	methodSendToAddress     = "sendtoaddress"
 	methodSetTxFee          = "settxfee"
+	methodRescan            = "rescanblockchain"
 )
 
 // walletClient is a bitcoind wallet RPC client that uses rpcclient.Client's
@@ -210,6 +211,11 @@ func (wc *walletClient) SendToAddress(address string, value, feeRate uint64, sub
 	return chainhash.NewHashFromStr(txid)
 }
 
+// Rescan rescans the blockchain from the block height.
+func (wc *walletClient) Rescan(height uint32) error {
+	return wc.call(methodRescan, anylist{height}, nil)
+}
+
 // call is used internally to  marshal parmeters and send requests to  the RPC
 // server via (*rpcclient.Client).RawRequest. If `thing` is non-nil, the result
 // will be marshaled into `thing`.
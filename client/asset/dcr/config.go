@@ -37,6 +37,9 @@ type Config struct {
 	RPCPass   string `ini:"password, RPC Password, dcrwallet's 'password' setting for JSON-RPC"`
 	RPCListen string `ini:"rpclisten, RPC Address (host or host:port), dcrwallet's address (default port: 9109, testnet: 19109)"`
 	RPCCert   string `ini:"rpccert, TLS Certificate, Path to the dcrwallet TLS certificate file"`
+	Proxy     string `ini:"proxy, Proxy Address, SOCKS5 proxy <host>:<port> for a remote dcrwallet (optional)"`
+	ProxyUser string `ini:"proxyuser, Proxy Username, SOCKS5 proxy username (optional)"`
+	ProxyPass string `ini:"proxypass, Proxy Password, SOCKS5 proxy password (optional)"`
 	// Context should be canceled when the application exits. This will cause
 	// some cleanup to be performed during shutdown.
 	Context context.Context `ini:"-"`
@@ -63,28 +66,35 @@ func loadConfig(settings map[string]string, network dex.Network) (*Config, error
 		return nil, fmt.Errorf("missing dcrwallet rpc credentials:%s", missing)
 	}
 
+	defaultServer, err := setChainParams(network)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RPCListen == "" {
+		cfg.RPCListen = defaultServer
+	}
+	if cfg.RPCCert == "" {
+		cfg.RPCCert = defaultRPCCert
+	}
+
+	return cfg, nil
+}
+
+// setChainParams sets the chainParams package variable for the network, and
+// returns the default dcrwallet RPC address for the network.
+func setChainParams(network dex.Network) (string, error) {
 	// Get network settings. Zero value is mainnet, but unknown non-zero cfg.Net
 	// is an error.
-	var defaultServer string
 	switch network {
 	case dex.Simnet:
 		chainParams = chaincfg.SimNetParams()
-		defaultServer = defaultSimnet
+		return defaultSimnet, nil
 	case dex.Testnet:
 		chainParams = chaincfg.TestNet3Params()
-		defaultServer = defaultTestnet3
+		return defaultTestnet3, nil
 	case dex.Mainnet:
 		chainParams = chaincfg.MainNetParams()
-		defaultServer = defaultMainnet
-	default:
-		return nil, fmt.Errorf("unknown network ID: %d", uint8(network))
+		return defaultMainnet, nil
 	}
-	if cfg.RPCListen == "" {
-		cfg.RPCListen = defaultServer
-	}
-	if cfg.RPCCert == "" {
-		cfg.RPCCert = defaultRPCCert
-	}
-
-	return cfg, nil
+	return "", fmt.Errorf("unknown network ID: %d", uint8(network))
 }
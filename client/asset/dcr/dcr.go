@@ -9,6 +9,7 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -45,12 +46,16 @@ const (
 var (
 	// blockTicker is the delay between calls to check for new blocks.
 	blockTicker = time.Second
+	// walletCheckInterval is the delay between checks that the coins funding
+	// orders are still locked by the wallet, which they won't be if the wallet
+	// process was restarted.
+	walletCheckInterval = 30 * time.Second
 	// walletInfo defines some general information about a Decred wallet.
 	walletInfo = &asset.WalletInfo{
 		Name:              "Decred",
 		Units:             "atoms",
 		DefaultConfigPath: defaultConfigPath,
-		ConfigOpts:        config.Options(&Config{}),
+		ConfigOpts:        append(config.Options(&Config{}), SPVOptions...),
 		DefaultFeeRate:    defaultFee,
 	}
 )
@@ -244,6 +249,7 @@ func init() {
 type ExchangeWallet struct {
 	client          *rpcclient.Client
 	node            rpcClient
+	spv             *spvWallet
 	log             dex.Logger
 	acct            string
 	tipChange       func(error)
@@ -265,10 +271,40 @@ type ExchangeWallet struct {
 // Check that ExchangeWallet satisfies the Wallet interface.
 var _ asset.Wallet = (*ExchangeWallet)(nil)
 
+// Check that ExchangeWallet satisfies the Rescanner, SeedExporter, and
+// BatchWithdrawer interfaces.
+var _ asset.Rescanner = (*ExchangeWallet)(nil)
+var _ asset.SeedExporter = (*ExchangeWallet)(nil)
+var _ asset.BatchWithdrawer = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the RedeemEstimator interface.
+var _ asset.RedeemEstimator = (*ExchangeWallet)(nil)
+
+// Check that ExchangeWallet satisfies the DepositTracker interface.
+var _ asset.DepositTracker = (*ExchangeWallet)(nil)
+
 // NewWallet is the exported constructor by which the DEX will import the
 // exchange wallet. The wallet will shut down when the provided context is
-// canceled.
+// canceled. If the spv setting is enabled, the built-in SPV wallet is used
+// instead of a dcrwallet instance.
 func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (*ExchangeWallet, error) {
+	spvCfg, err := loadSPVConfig(cfg.Settings, network)
+	if err != nil {
+		return nil, err
+	}
+	if spvCfg != nil {
+		// DEX funds are kept in the SPV wallet's DEX account.
+		cfg.Account = spvCfg.Account
+		dcr := unconnectedWallet(cfg, logger)
+		logger.Infof("Setting up new DCR SPV wallet in %s.", spvCfg.Dir)
+		dcr.spv = newSPVWallet(spvCfg, chainParams, logger)
+		if len(dcr.spv.restoreSeed) == 0 && len(cfg.Seed) > 0 {
+			// Create the wallet from the seed provided by the client.
+			dcr.spv.restoreSeed = append([]byte(nil), cfg.Seed...)
+		}
+		dcr.node = dcr.spv
+		return dcr, nil
+	}
 	// loadConfig will set fields if defaults are used and set the chainParams
 	// package variable.
 	walletCfg, err := loadConfig(cfg.Settings, network)
@@ -282,8 +318,7 @@ func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network)
 
 	logger.Infof("Setting up new DCR wallet at %s with TLS certificate %q.",
 		walletCfg.RPCListen, walletCfg.RPCCert)
-	dcr.client, err = newClient(walletCfg.RPCListen, walletCfg.RPCUser,
-		walletCfg.RPCPass, walletCfg.RPCCert)
+	dcr.client, err = newClient(walletCfg)
 	if err != nil {
 		return nil, fmt.Errorf("DCR ExchangeWallet.Run error: %v", err)
 	}
@@ -307,20 +342,23 @@ func unconnectedWallet(cfg *asset.WalletConfig, logger dex.Logger) *ExchangeWall
 }
 
 // newClient attempts to create a new websocket connection to a dcrwallet
-// instance with the given credentials and notification handlers.
-func newClient(host, user, pass, cert string) (*rpcclient.Client, error) {
+// instance with the configured credentials and proxy.
+func newClient(cfg *Config) (*rpcclient.Client, error) {
 
-	certs, err := ioutil.ReadFile(cert)
+	certs, err := ioutil.ReadFile(cfg.RPCCert)
 	if err != nil {
 		return nil, fmt.Errorf("TLS certificate read error: %v", err)
 	}
 
 	config := &rpcclient.ConnConfig{
-		Host:                host,
+		Host:                cfg.RPCListen,
 		Endpoint:            "ws",
-		User:                user,
-		Pass:                pass,
+		User:                cfg.RPCUser,
+		Pass:                cfg.RPCPass,
 		Certificates:        certs,
+		Proxy:               cfg.Proxy,
+		ProxyUser:           cfg.ProxyUser,
+		ProxyPass:           cfg.ProxyPass,
 		DisableConnectOnNew: true,
 	}
 
@@ -340,18 +378,27 @@ func (dcr *ExchangeWallet) Info() *asset.WalletInfo {
 // Connect connects the wallet to the RPC server. Satisfies the dex.Connector
 // interface.
 func (dcr *ExchangeWallet) Connect(ctx context.Context) (*sync.WaitGroup, error) {
-	err := dcr.client.Connect(ctx, false)
-	if err != nil {
-		return nil, fmt.Errorf("Decred Wallet connect error: %v", err)
-	}
-	// Check the min api versions.
-	versions, err := dcr.client.Version()
-	if err != nil {
-		return nil, fmt.Errorf("DCR ExchangeWallet version fetch error: %v", err)
-	}
-	err = checkVersionInfo(versions)
-	if err != nil {
-		return nil, fmt.Errorf("DCR ExchangeWallet version check failed: %v", err)
+	var spvWG *sync.WaitGroup
+	if dcr.spv != nil {
+		var err error
+		spvWG, err = dcr.spv.connect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error starting DCR SPV wallet: %v", err)
+		}
+	} else {
+		err := dcr.client.Connect(ctx, false)
+		if err != nil {
+			return nil, fmt.Errorf("Decred Wallet connect error: %v", err)
+		}
+		// Check the min api versions.
+		versions, err := dcr.client.Version()
+		if err != nil {
+			return nil, fmt.Errorf("DCR ExchangeWallet version fetch error: %v", err)
+		}
+		err = checkVersionInfo(versions)
+		if err != nil {
+			return nil, fmt.Errorf("DCR ExchangeWallet version check failed: %v", err)
+		}
 	}
 	// If this is the first time connecting, clear the locked coins. This should
 	// have been done at shutdown, but shutdown may not have been clean.
@@ -367,6 +414,9 @@ func (dcr *ExchangeWallet) Connect(ctx context.Context) (*sync.WaitGroup, error)
 		defer wg.Done()
 		dcr.monitorBlocks(ctx)
 		dcr.shutdown()
+		if spvWG != nil {
+			spvWG.Wait()
+		}
 	}()
 	return &wg, nil
 }
@@ -410,7 +460,13 @@ func (dcr *ExchangeWallet) Balance() (*asset.Balance, error) {
 // FeeRate returns the current optimal fee rate in atoms / byte.
 func (dcr *ExchangeWallet) FeeRate() (uint64, error) {
 	// estimatesmartfee 1 returns extremely high rates on DCR.
-	dcrPerKB, err := dcr.node.EstimateSmartFee(2, chainjson.EstimateSmartFeeConservative)
+	return dcr.estimateFeeRate(2)
+}
+
+// estimateFeeRate returns the optimal fee rate in atoms / byte for
+// confirmation within confTarget blocks.
+func (dcr *ExchangeWallet) estimateFeeRate(confTarget int64) (uint64, error) {
+	dcrPerKB, err := dcr.node.EstimateSmartFee(confTarget, chainjson.EstimateSmartFeeConservative)
 	if err != nil {
 		return 0, err
 	}
@@ -741,6 +797,16 @@ func (dcr *ExchangeWallet) Swap(swaps *asset.Swaps) ([]asset.Receipt, asset.Coin
 	return receipts, change, nil
 }
 
+// RedeemFee estimates the fee rate and the fee of a transaction that redeems a
+// single swap contract to a P2PKH output. Part of the asset.RedeemEstimator
+// interface.
+func (dcr *ExchangeWallet) RedeemFee() (feeRate, fee uint64) {
+	const size = dexdcr.MsgTxOverhead + dexdcr.TxInOverhead + 1 +
+		dexdcr.RedeemSwapSigScriptSize + dexdcr.P2PKHOutputSize
+	feeRate = dcr.feeRateWithFallback()
+	return feeRate, feeRate * size
+}
+
 // Redeem sends the redemption transaction, which may contain more than one
 // redemption.
 func (dcr *ExchangeWallet) Redeem(redemptions []*asset.Redemption) ([]dex.Bytes, asset.Coin, error) {
@@ -897,6 +963,18 @@ func (dcr *ExchangeWallet) AuditContract(coinID, contract dex.Bytes) (asset.Audi
 	if err != nil {
 		return nil, fmt.Errorf("error extracting swap addresses: %v", err)
 	}
+	// The SPV wallet only knows about the outputs it has found.
+	if dcr.spv != nil {
+		contractAddr, err := dcrutil.NewAddressScriptHash(contract, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("error creating contract address: %v", err)
+		}
+		pkScript, _ := txscript.PayToAddrScript(contractAddr)
+		if err := dcr.spv.findOutput(txHash, vout, pkScript); err != nil {
+			dcr.log.Debugf("Contract output %s:%d not found: %v", txHash, vout, err)
+			return nil, asset.CoinNotFoundError
+		}
+	}
 	// Get the contracts P2SH address from the tx output's pubkey script.
 	txOut, err := dcr.node.GetTxOut(txHash, vout, true)
 	if err != nil {
@@ -1217,6 +1295,208 @@ func (dcr *ExchangeWallet) Withdraw(address string, value uint64) (asset.Coin, e
 	return newOutput(dcr.node, msgTx.CachedTxHash(), 0, net, wire.TxTreeRegular, nil), nil
 }
 
+// withdrawal is an unsigned withdrawal transaction, funded but without its
+// change output.
+type withdrawal struct {
+	tx      *wire.MsgTx
+	feeRate uint64
+	// size is the estimated size of the signed transaction.
+	size uint64
+	fee  uint64
+	// change is the value of the change output, or zero if there is no change.
+	change uint64
+}
+
+// EstimateWithdrawal selects the UTXOs to fund the withdrawal and estimates the
+// size and fees of the transaction. Part of the asset.BatchWithdrawer
+// interface.
+func (dcr *ExchangeWallet) EstimateWithdrawal(w *asset.Withdrawal) (*asset.WithdrawalEstimate, error) {
+	wd, err := dcr.prepareWithdrawal(w)
+	if err != nil {
+		return nil, err
+	}
+	return &asset.WithdrawalEstimate{
+		FeeRate: wd.feeRate,
+		Size:    wd.size,
+		Fee:     wd.fee,
+		Inputs:  len(wd.tx.TxIn),
+	}, nil
+}
+
+// SendWithdrawal sends the withdrawal to its outputs in a single transaction.
+// Part of the asset.BatchWithdrawer interface.
+func (dcr *ExchangeWallet) SendWithdrawal(w *asset.Withdrawal) ([]asset.Coin, error) {
+	wd, err := dcr.prepareWithdrawal(w)
+	if err != nil {
+		return nil, err
+	}
+	baseTx := wd.tx
+	if wd.change > 0 {
+		changeAddr, err := dcr.node.GetRawChangeAddress(dcr.acct, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("error creating change address: %v", err)
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, fmt.Errorf("error creating change script for address '%s': %v", changeAddr, err)
+		}
+		baseTx.AddTxOut(wire.NewTxOut(int64(wd.change), changeScript))
+	}
+	msgTx, signed, err := dcr.node.SignRawTransaction(baseTx)
+	if err != nil {
+		return nil, fmt.Errorf("signing error: %v", err)
+	}
+	if !signed {
+		return nil, fmt.Errorf("incomplete raw tx signature")
+	}
+	if _, checkRate := fees(msgTx); checkRate < float64(wd.feeRate) {
+		dcr.log.Warnf("withdrawal fee rate is lower than requested. %f < %d", checkRate, wd.feeRate)
+	}
+	checkHash := msgTx.TxHash()
+	txHash, err := dcr.node.SendRawTransaction(msgTx, false)
+	if err != nil {
+		dcr.log.Errorf("SendWithdrawal error, %d outputs: %v", len(w.Outputs), err)
+		return nil, err
+	}
+	if *txHash != checkHash {
+		return nil, fmt.Errorf("transaction sent, but received unexpected transaction ID back from RPC server. "+
+			"expected %s, got %s", checkHash, *txHash)
+	}
+	coins := make([]asset.Coin, 0, len(w.Outputs))
+	for i := range w.Outputs {
+		coins = append(coins, newOutput(dcr.node, txHash, uint32(i), uint64(msgTx.TxOut[i].Value), wire.TxTreeRegular, nil))
+	}
+	return coins, nil
+}
+
+// prepareWithdrawal creates the withdrawal's outputs and selects UTXOs to fund
+// them. The UTXOs are not locked. If the change would be dust, there is no
+// change output and the dust is added to the fees.
+func (dcr *ExchangeWallet) prepareWithdrawal(w *asset.Withdrawal) (*withdrawal, error) {
+	if len(w.Outputs) == 0 {
+		return nil, fmt.Errorf("no withdrawal outputs")
+	}
+	feeRate := w.FeeRate
+	if feeRate == 0 {
+		confTarget := int64(w.TargetConfs)
+		if confTarget == 0 {
+			confTarget = 2
+		}
+		var err error
+		feeRate, err = dcr.estimateFeeRate(confTarget)
+		if err != nil {
+			feeRate = dcr.fallbackFeeRate
+			dcr.log.Warnf("Unable to get optimal fee rate for %d blocks, using fallback of %d: %v",
+				confTarget, dcr.fallbackFeeRate, err)
+		}
+	}
+
+	baseTx := wire.NewMsgTx()
+	size := uint64(dexdcr.MsgTxOverhead)
+	var totalOut uint64
+	for _, out := range w.Outputs {
+		if out.Value == 0 {
+			return nil, fmt.Errorf("cannot send value = 0 to %s", out.Address)
+		}
+		addr, err := dcrutil.DecodeAddress(out.Address, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", out.Address, err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("error creating pubkey script for %s: %v", out.Address, err)
+		}
+		txOut := wire.NewTxOut(int64(out.Value), pkScript)
+		baseTx.AddTxOut(txOut)
+		size += uint64(txOut.SerializeSize())
+		totalOut += out.Value
+	}
+
+	unspents, err := dcr.node.ListUnspentMin(0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(unspents, func(i, j int) bool { return unspents[i].Amount < unspents[j].Amount })
+	utxos, avail, err := dcr.spendableUTXOs(unspents, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing unspent outputs: %v", err)
+	}
+	// The fees are estimated with a change output.
+	reqFunds := func(size uint64) uint64 {
+		if w.Subtract {
+			return totalOut
+		}
+		return totalOut + feeRate*(size+dexdcr.P2PKHOutputSize)
+	}
+	var sum uint64
+	addUTXO := func(utxo *compositeUTXO) error {
+		txHash, err := chainhash.NewHashFromStr(utxo.rpc.TxID)
+		if err != nil {
+			return fmt.Errorf("error decoding txid: %v", err)
+		}
+		v := toAtoms(utxo.rpc.Amount)
+		prevOut := wire.NewOutPoint(txHash, utxo.rpc.Vout, utxo.rpc.Tree)
+		baseTx.AddTxIn(wire.NewTxIn(prevOut, int64(v), []byte{}))
+		size += uint64(utxo.input.Size())
+		sum += v
+		return nil
+	}
+	for sum < reqFunds(size) {
+		if len(utxos) == 0 {
+			return nil, fmt.Errorf("not enough to cover requested funds + fees = %d. %d available",
+				reqFunds(size), avail)
+		}
+		// Add the smallest UTXO that is enough. If none are large enough, add the
+		// largest and continue.
+		idx := len(utxos) - 1
+		for i, utxo := range utxos {
+			if sum+toAtoms(utxo.rpc.Amount) >= reqFunds(size+uint64(utxo.input.Size())) {
+				idx = i
+				break
+			}
+		}
+		if err := addUTXO(utxos[idx]); err != nil {
+			return nil, err
+		}
+		utxos = append(utxos[:idx], utxos[idx+1:]...)
+	}
+
+	wd := &withdrawal{
+		tx:      baseTx,
+		feeRate: feeRate,
+		size:    size,
+	}
+	// Check whether the change is worth an output.
+	changeSize := size + dexdcr.P2PKHOutputSize
+	var change uint64
+	if w.Subtract {
+		change = sum - totalOut
+	} else {
+		change = sum - totalOut - feeRate*changeSize
+	}
+	changeScript := append([]byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20},
+		append(make([]byte, 20), txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)...)
+	if change > 0 && !dexdcr.IsDust(wire.NewTxOut(int64(change), changeScript), feeRate) {
+		wd.change = change
+		wd.size = changeSize
+	}
+	if w.Subtract {
+		subtracted := feeRate * wd.size
+		subtractee := baseTx.TxOut[0]
+		if subtracted >= uint64(subtractee.Value) {
+			return nil, fmt.Errorf("output value %d is too small to pay the fees of %d", subtractee.Value, subtracted)
+		}
+		subtractee.Value -= int64(subtracted)
+		if dexdcr.IsDust(subtractee, feeRate) {
+			return nil, fmt.Errorf("output value %d is dust after subtracting fees of %d", subtractee.Value, subtracted)
+		}
+		totalOut -= subtracted
+	}
+	// Any dust change goes to the miner.
+	wd.fee = sum - totalOut - wd.change
+	return wd, nil
+}
+
 // ValidateSecret checks that the secret satisfies the contract.
 func (dcr *ExchangeWallet) ValidateSecret(secret, secretHash []byte) bool {
 	h := sha256.Sum256(secret)
@@ -1275,6 +1555,61 @@ func (dcr *ExchangeWallet) shutdown() {
 	}
 }
 
+// Rescan rescans the blockchain from the block height for wallet
+// transactions. Part of the asset.Rescanner interface.
+func (dcr *ExchangeWallet) Rescan(height uint32) error {
+	if dcr.spv != nil {
+		return dcr.spv.rescan(height)
+	}
+	_, err := dcr.client.RawRequest("rescanwallet", []json.RawMessage{[]byte(strconv.FormatUint(uint64(height), 10))})
+	return err
+}
+
+// AddressDeposits returns the wallet's unspent outputs that pay to any of the
+// addresses, including unconfirmed outputs. Part of the asset.DepositTracker
+// interface.
+func (dcr *ExchangeWallet) AddressDeposits(addrs []string) ([]*asset.Deposit, error) {
+	unspents, err := dcr.node.ListUnspentMin(0)
+	if err != nil {
+		return nil, err
+	}
+	addrSet := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		addrSet[addr] = true
+	}
+	var deposits []*asset.Deposit
+	for _, txout := range unspents {
+		if !addrSet[txout.Address] {
+			continue
+		}
+		txHash, err := chainhash.NewHashFromStr(txout.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding txid %s: %v", txout.TxID, err)
+		}
+		var confs uint32
+		if txout.Confirmations > 0 {
+			confs = uint32(txout.Confirmations)
+		}
+		deposits = append(deposits, &asset.Deposit{
+			Address:       txout.Address,
+			CoinID:        toCoinID(txHash, txout.Vout),
+			Value:         toAtoms(txout.Amount),
+			Confirmations: confs,
+		})
+	}
+	return deposits, nil
+}
+
+// ExportSeed decrypts the seed of the built-in SPV wallet with the wallet
+// password. Seeds of dcrwallet instances are not available. Part of the
+// asset.SeedExporter interface.
+func (dcr *ExchangeWallet) ExportSeed(pw string) (dex.Bytes, error) {
+	if dcr.spv == nil {
+		return nil, fmt.Errorf("the seed of an external wallet cannot be exported. use dcrwallet to back up the wallet")
+	}
+	return dcr.spv.seed([]byte(pw))
+}
+
 // Combines the RPC type with the spending input information.
 type compositeUTXO struct {
 	rpc   walletjson.ListUnspentResult
@@ -1637,33 +1972,115 @@ func (dcr *ExchangeWallet) getKeys(addr dcrutil.Address) (*secp256k1.PrivateKey,
 }
 
 // monitorBlocks pings for new blocks and runs the tipChange callback function
-// when the block changes.
+// when the block changes. monitorBlocks also supervises the dcrwallet process.
+// If the wallet stops responding, the connection is considered lost until a
+// request succeeds again, such as after the RPC client reconnects, at which
+// point the funding coins are relocked and the tipChange callback is run so
+// that any pending swaps are checked.
 func (dcr *ExchangeWallet) monitorBlocks(ctx context.Context) {
+	var tipHash chainhash.Hash
 	h, _, err := dcr.node.GetBestBlock()
+	connected := err == nil
 	if err != nil {
 		dcr.tipChange(fmt.Errorf("error initializing best block for DCR: %v", err))
+	} else {
+		tipHash = *h
 	}
-	tipHash := *h
 	ticker := time.NewTicker(blockTicker)
 	defer ticker.Stop()
+	checkTicker := time.NewTicker(walletCheckInterval)
+	defer checkTicker.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			h, _, err := dcr.node.GetBestBlock()
 			if err != nil {
+				if connected {
+					dcr.log.Errorf("Lost connection to DCR wallet: %v", err)
+					connected = false
+				}
 				dcr.tipChange(fmt.Errorf("failed to get best block hash from DCR node"))
 				continue
 			}
+			if !connected {
+				connected = true
+				dcr.log.Infof("Connection to DCR wallet restored.")
+				dcr.recoverWallet()
+				tipHash = *h
+				dcr.tipChange(nil)
+				continue
+			}
 			if *h != tipHash {
 				tipHash = *h
 				dcr.tipChange(nil)
 			}
+		case <-checkTicker.C:
+			// A dcrwallet process that restarts between block checks is
+			// detected by the loss of its coin locks.
+			if !connected || dcr.spv != nil {
+				continue
+			}
+			n, err := dcr.relockFundingCoins()
+			if err != nil {
+				dcr.log.Errorf("Error checking DCR funding coin locks: %v", err)
+				continue
+			}
+			if n > 0 {
+				dcr.log.Warnf("DCR wallet lost locks on %d funding coins. Was the wallet restarted?", n)
+				dcr.tipChange(nil)
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// recoverWallet relocks the funding coins after the connection to the wallet
+// is restored.
+func (dcr *ExchangeWallet) recoverWallet() {
+	n, err := dcr.relockFundingCoins()
+	if err != nil {
+		dcr.log.Errorf("Error relocking DCR funding coins: %v", err)
+		return
+	}
+	if n > 0 {
+		dcr.log.Infof("Relocked %d DCR funding coins.", n)
+	}
+}
+
+// relockFundingCoins locks any coins funding orders that the wallet does not
+// report as locked, as is the case after the dcrwallet process restarts. Coins
+// that cannot be locked, such as coins spent while the wallet was down, are
+// skipped. The number of coins relocked is returned.
+func (dcr *ExchangeWallet) relockFundingCoins() (int, error) {
+	lockedOutpoints, err := dcr.node.ListLockUnspent()
+	if err != nil {
+		return 0, err
+	}
+	locked := make(map[string]bool, len(lockedOutpoints))
+	for _, op := range lockedOutpoints {
+		locked[outpointID(&op.Hash, op.Index)] = true
+	}
+	var unlocked []*wire.OutPoint
+	dcr.fundingMtx.RLock()
+	for opID, fCoin := range dcr.fundingCoins {
+		if !locked[opID] {
+			op := fCoin.op
+			unlocked = append(unlocked, wire.NewOutPoint(&op.txHash, op.vout, op.tree))
+		}
+	}
+	dcr.fundingMtx.RUnlock()
+	var n int
+	for _, op := range unlocked {
+		if err := dcr.node.LockUnspent(false, []*wire.OutPoint{op}); err != nil {
+			dcr.log.Debugf("Unable to relock DCR funding coin %s: %v", op, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
 // Convert the DCR value to atoms.
 func toAtoms(v float64) uint64 {
 	return uint64(math.Round(v * 1e8))
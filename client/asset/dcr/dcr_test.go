@@ -151,6 +151,7 @@ type tRPCClient struct {
 	bestHash        chainhash.Hash
 	locked          []*wire.OutPoint
 	listLockedErr   error
+	lockedOps       []*wire.OutPoint
 }
 
 func newTRPCClient() *tRPCClient {
@@ -233,6 +234,9 @@ func (c *tRPCClient) ListUnspentMin(int) ([]walletjson.ListUnspentResult, error)
 }
 
 func (c *tRPCClient) LockUnspent(unlock bool, ops []*wire.OutPoint) error {
+	if !unlock && c.lockUnspentErr == nil {
+		c.lockedOps = append(c.lockedOps, ops...)
+	}
 	return c.lockUnspentErr
 }
 
@@ -528,6 +532,50 @@ func TestReturnCoins(t *testing.T) {
 	}
 }
 
+func TestRelockFundingCoins(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	stillLocked := newOutput(node, tTxHash, 0, 1, wire.TxTreeRegular, nil)
+	lostLock := newOutput(node, tTxHash, 1, 1, wire.TxTreeRegular, nil)
+	wallet.fundingMtx.Lock()
+	wallet.fundingCoins[stillLocked.String()] = &fundingCoin{op: stillLocked}
+	wallet.fundingCoins[lostLock.String()] = &fundingCoin{op: lostLock}
+	wallet.fundingMtx.Unlock()
+	node.locked = []*wire.OutPoint{wire.NewOutPoint(tTxHash, 0, wire.TxTreeRegular)}
+
+	// Only the coin without a lock is relocked.
+	n, err := wallet.relockFundingCoins()
+	if err != nil {
+		t.Fatalf("relockFundingCoins error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 coin relocked, got %d", n)
+	}
+	if len(node.lockedOps) != 1 || node.lockedOps[0].Index != 1 {
+		t.Fatalf("wrong coin relocked: %v", node.lockedOps)
+	}
+
+	// Coins that fail to lock are skipped.
+	node.lockUnspentErr = tErr
+	n, err = wallet.relockFundingCoins()
+	if err != nil {
+		t.Fatalf("relockFundingCoins error: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 coins relocked, got %d", n)
+	}
+	node.lockUnspentErr = nil
+
+	// listlockunspent error.
+	node.listLockedErr = tErr
+	_, err = wallet.relockFundingCoins()
+	if err == nil {
+		t.Fatalf("no error for listlockunspent error")
+	}
+	node.listLockedErr = nil
+}
+
 func TestFundingCoins(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -1304,6 +1352,151 @@ func TestWithdraw(t *testing.T) {
 	testSender(t, tWithdrawSender)
 }
 
+func TestWithdrawal(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.unspent = []walletjson.ListUnspentResult{{
+		TxID:          tTxID,
+		Address:       tPKHAddr.String(),
+		Amount:        3,
+		Confirmations: 1,
+		Vout:          1,
+		ScriptPubKey:  hex.EncodeToString(tP2PKHScript),
+	}, {
+		TxID:          tTxID,
+		Address:       tPKHAddr.String(),
+		Amount:        1,
+		Confirmations: 1,
+		ScriptPubKey:  hex.EncodeToString(tP2PKHScript),
+	}}
+	node.changeAddr = tPKHAddr
+	var sentTx *wire.MsgTx
+	node.signFunc = func(msgTx *wire.MsgTx) (*wire.MsgTx, bool, error) {
+		for i := range msgTx.TxIn {
+			msgTx.TxIn[i].SignatureScript = randBytes(dexdcr.P2PKHSigScriptSize)
+		}
+		sentTx = msgTx
+		return msgTx, true, nil
+	}
+
+	const feeRate = 10
+	w := &asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{
+			{Address: tPKHAddr.String(), Value: toAtoms(1.5)},
+			{Address: tPKHAddr.String(), Value: toAtoms(0.5)},
+		},
+		FeeRate: feeRate,
+	}
+	// The 3 DCR output is the smallest that is enough. The estimated size
+	// includes the change output.
+	wantSize := uint64(dexdcr.MsgTxOverhead + 3*dexdcr.P2PKHOutputSize + dexdcr.P2PKHInputSize)
+	est, err := wallet.EstimateWithdrawal(w)
+	if err != nil {
+		t.Fatalf("EstimateWithdrawal error: %v", err)
+	}
+	if est.Inputs != 1 || est.Size != wantSize || est.Fee != feeRate*wantSize || est.FeeRate != feeRate {
+		t.Fatalf("wrong estimate %+v, wanted size %d", est, wantSize)
+	}
+
+	coins, err := wallet.SendWithdrawal(w)
+	if err != nil {
+		t.Fatalf("SendWithdrawal error: %v", err)
+	}
+	if len(coins) != 2 || coins[0].Value() != toAtoms(1.5) || coins[1].Value() != toAtoms(0.5) {
+		t.Fatalf("wrong coins returned")
+	}
+	if len(sentTx.TxOut) != 3 || uint64(sentTx.TxOut[2].Value) != toAtoms(1)-est.Fee {
+		t.Fatalf("wrong change output")
+	}
+
+	// Subtracting the fees, spending both UTXOs with no change.
+	coins, err = wallet.SendWithdrawal(&asset.Withdrawal{
+		Outputs:  []*asset.WithdrawalOutput{{Address: tPKHAddr.String(), Value: toAtoms(4)}},
+		FeeRate:  feeRate,
+		Subtract: true,
+	})
+	if err != nil {
+		t.Fatalf("SendWithdrawal subtract error: %v", err)
+	}
+	wantFee := feeRate * uint64(dexdcr.MsgTxOverhead+dexdcr.P2PKHOutputSize+2*dexdcr.P2PKHInputSize)
+	if len(sentTx.TxOut) != 1 || coins[0].Value() != toAtoms(4)-wantFee {
+		t.Fatalf("wrong subtracted output value %d, wanted %d", coins[0].Value(), toAtoms(4)-wantFee)
+	}
+
+	// Estimated fee rate.
+	w.FeeRate = 0
+	est, err = wallet.EstimateWithdrawal(w)
+	if err != nil {
+		t.Fatalf("EstimateWithdrawal error: %v", err)
+	}
+	if est.FeeRate != optimalFeeRate+1 {
+		t.Fatalf("wrong estimated fee rate. wanted %d, got %d", optimalFeeRate+1, est.FeeRate)
+	}
+	w.FeeRate = feeRate
+
+	// Not enough funds.
+	_, err = wallet.EstimateWithdrawal(&asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{{Address: tPKHAddr.String(), Value: toAtoms(4)}},
+		FeeRate: feeRate,
+	})
+	if err == nil {
+		t.Fatalf("no error for insufficient funds")
+	}
+
+	// Bad address.
+	_, err = wallet.EstimateWithdrawal(&asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{{Address: "abc", Value: toAtoms(1)}},
+		FeeRate: feeRate,
+	})
+	if err == nil {
+		t.Fatalf("no error for bad address")
+	}
+
+	// Change address error.
+	node.changeAddrErr = tErr
+	_, err = wallet.SendWithdrawal(w)
+	if err == nil {
+		t.Fatalf("no error for change address error")
+	}
+	node.changeAddrErr = nil
+
+	// Send error.
+	node.sendRawErr = tErr
+	_, err = wallet.SendWithdrawal(w)
+	if err == nil {
+		t.Fatalf("no error for send error")
+	}
+	node.sendRawErr = nil
+}
+
+func TestAddressDeposits(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.unspent = []walletjson.ListUnspentResult{
+		{TxID: tTxID, Vout: 0, Address: tPKHAddr.String(), Amount: 1, Confirmations: 0},
+		{TxID: tTxID, Vout: 1, Address: "DsTya4cCFBgtofDLiRhkyPYEQjgs3HnarVQ", Amount: 2, Confirmations: 3},
+	}
+	deposits, err := wallet.AddressDeposits([]string{tPKHAddr.String()})
+	if err != nil {
+		t.Fatalf("AddressDeposits error: %v", err)
+	}
+	if len(deposits) != 1 {
+		t.Fatalf("expected 1 deposit, got %d", len(deposits))
+	}
+	dep := deposits[0]
+	if dep.Address != tPKHAddr.String() || dep.Value != toAtoms(1) || dep.Confirmations != 0 ||
+		!bytes.Equal(dep.CoinID, toCoinID(tTxHash, 0)) {
+		t.Fatalf("wrong deposit %+v", dep)
+	}
+
+	node.unspentErr = tErr
+	if _, err := wallet.AddressDeposits([]string{tPKHAddr.String()}); err == nil {
+		t.Fatalf("no error for listunspent error")
+	}
+}
+
 func TestConfirmations(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -0,0 +1,1333 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dcr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/encrypt"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrjson/v3"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrd/hdkeychain/v2"
+	chainjson "github.com/decred/dcrd/rpc/jsonrpc/types/v2"
+	"github.com/decred/dcrd/rpcclient/v5"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	walleterrors "github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/p2p/v2"
+	walletjson "github.com/decred/dcrwallet/rpc/jsonrpc/types"
+	"github.com/decred/dcrwallet/wallet/v3"
+	_ "github.com/decred/dcrwallet/wallet/v3/drivers/bdb" // bdb wallet database driver
+)
+
+const (
+	spvKey = "spv"
+	// defaultSPVAccountName is the name of the account that holds the SPV
+	// wallet's DEX funds.
+	defaultSPVAccountName = "dex"
+	// auditScanBlocks is how many blocks back from the tip the SPV wallet
+	// looks for a counterparty's swap contract. 288 blocks is about one day.
+	auditScanBlocks = 288
+	// gapLimit is the number of unused addresses past the last used address
+	// on each branch that the SPV wallet watches for payments.
+	gapLimit = 20
+	// accountGapLimit is the number of unused accounts searched when
+	// restoring from a seed.
+	accountGapLimit = 10
+	// relayFee is the minimum relay fee rate, DCR/kB.
+	relayFee = 0.0001
+
+	walletDBName = "wallet.db"
+	seedFileName = "seed"
+)
+
+var (
+	// syncInterval is how often the SPV wallet syncs if no new blocks are
+	// announced.
+	syncInterval = time.Minute
+
+	// SPVOptions are the wallet settings for the built-in SPV wallet. If spv
+	// is set, the RPC settings are not used.
+	SPVOptions = config.Options(&SPVConfig{})
+
+	errWalletNotCreated = errors.New("the SPV wallet has not been created. unlock the wallet to create it")
+)
+
+// SPVConfig is the configuration of the built-in SPV wallet.
+type SPVConfig struct {
+	SPV     bool   `ini:"spv, Built-in SPV Wallet, Use the built-in SPV wallet instead of a dcrwallet instance (1 to enable)"`
+	Dir     string `ini:"spvdir, SPV Data Directory, Where the SPV wallet stores its data (default ~/.dexc/dcr-spv/<network>)"`
+	Peers   string `ini:"peers, SPV Peers, Comma-separated addresses of the only peers to connect to (default discover peers)"`
+	Account string `ini:"spvaccount, SPV Account, The SPV wallet account that holds DEX funds (default dex)"`
+	Seed    string `ini:"spvseed, Restore Seed, Hex-encoded seed of a wallet to restore. Only used when the SPV wallet is created"`
+}
+
+// loadSPVConfig parses the SPV settings. The returned config is nil if the
+// SPV wallet is not enabled. If there is no error, the module-level
+// chainParams variable will be set appropriately for the network.
+func loadSPVConfig(settings map[string]string, network dex.Network) (*SPVConfig, error) {
+	if settings[spvKey] == "" {
+		return nil, nil
+	}
+	cfg := new(SPVConfig)
+	if err := config.Unmapify(settings, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing SPV settings: %v", err)
+	}
+	if !cfg.SPV {
+		return nil, nil
+	}
+	if _, err := setChainParams(network); err != nil {
+		return nil, err
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(dcrutil.AppDataDir("dexc", false), "dcr-spv", chainParams.Name)
+	}
+	if cfg.Account == "" {
+		cfg.Account = defaultSPVAccountName
+	}
+	if cfg.Seed != "" {
+		seed, err := hex.DecodeString(cfg.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding restore seed: %v", err)
+		}
+		if len(seed) < hdkeychain.MinSeedBytes || len(seed) > hdkeychain.MaxSeedBytes {
+			return nil, fmt.Errorf("invalid restore seed length %d", len(seed))
+		}
+	}
+	return cfg, nil
+}
+
+// spvWallet is a Decred SPV wallet. The wallet is dcrwallet's wallet, synced
+// with block headers and committed filters from the network, and
+// transactions are found by matching the filters against the wallet's
+// addresses and outputs. spvWallet satisfies the rpcClient interface, handling
+// the dcrwallet RPCs that ExchangeWallet uses, and is the wallet's
+// wallet.NetworkBackend. DEX funds are kept in their own account.
+type spvWallet struct {
+	params   *chaincfg.Params
+	log      dex.Logger
+	dir      string
+	peers    []string
+	acctName string
+	src      blockSource
+	ctx      context.Context
+
+	// restoreSeed is only used if the wallet is created.
+	restoreSeed []byte
+
+	syncMtx   sync.Mutex
+	blockNote chan struct{}
+	synced    bool
+
+	mtx         sync.RWMutex
+	db          wallet.DB
+	w           *wallet.Wallet
+	acct        uint32
+	acctCreated bool
+
+	filterMtx sync.RWMutex
+	scripts   map[string]struct{}
+	outpoints map[wire.OutPoint]struct{}
+
+	foundMtx sync.Mutex
+	found    map[wire.OutPoint]*foundOutput
+	spends   map[wire.OutPoint]*spendScan
+}
+
+// foundOutput is an output that is not the wallet's, such as a
+// counterparty's swap contract, that was found on the network.
+type foundOutput struct {
+	tx        *wire.MsgTx
+	blockHash *chainhash.Hash
+	// height is the height of the output's block, or -1 if the transaction
+	// is unmined.
+	height int32
+	// scanned is the height of the last block checked for the transaction
+	// while it was unmined.
+	scanned int32
+}
+
+// spendScan is the progress of a search for the spender of a non-wallet
+// output.
+type spendScan struct {
+	through int32
+	spent   bool
+}
+
+// Check that spvWallet satisfies the rpcClient and wallet.NetworkBackend
+// interfaces.
+var _ rpcClient = (*spvWallet)(nil)
+var _ wallet.NetworkBackend = (*spvWallet)(nil)
+
+// newSPVWallet is the constructor for an *spvWallet. The wallet's database is
+// opened by connect.
+func newSPVWallet(cfg *SPVConfig, params *chaincfg.Params, logger dex.Logger) *spvWallet {
+	var peers []string
+	for _, addr := range strings.Split(cfg.Peers, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, params.DefaultPort)
+		}
+		peers = append(peers, addr)
+	}
+	// The seed was validated by loadSPVConfig.
+	restoreSeed, _ := hex.DecodeString(cfg.Seed)
+	return &spvWallet{
+		params:      params,
+		log:         logger,
+		dir:         cfg.Dir,
+		peers:       peers,
+		acctName:    cfg.Account,
+		restoreSeed: restoreSeed,
+		ctx:         context.Background(),
+		blockNote:   make(chan struct{}, 1),
+		scripts:     make(map[string]struct{}),
+		outpoints:   make(map[wire.OutPoint]struct{}),
+		found:       make(map[wire.OutPoint]*foundOutput),
+		spends:      make(map[wire.OutPoint]*spendScan),
+	}
+}
+
+// connect opens the wallet if it has been created, and starts syncing with
+// the network. The wallet shuts down when the context is canceled.
+func (w *spvWallet) connect(ctx context.Context) (*sync.WaitGroup, error) {
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating SPV data directory: %v", err)
+	}
+	w.ctx = ctx
+	wallet.UseLogger(w.log)
+	p2p.UseLogger(w.log)
+	dbPath := filepath.Join(w.dir, walletDBName)
+	if _, err := os.Stat(dbPath); err == nil {
+		db, err := wallet.OpenDB("bdb", dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening SPV wallet database: %v", err)
+		}
+		if err := w.open(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	if w.src == nil {
+		ps := newPeerSet(w.params, w.dir, w.peers, w.notifyBlock, w.log)
+		w.src = ps
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ps.run(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.run(ctx)
+		w.syncMtx.Lock()
+		defer w.syncMtx.Unlock()
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		if w.w == nil {
+			return
+		}
+		w.w.Lock()
+		if err := w.db.Close(); err != nil {
+			w.log.Errorf("error closing SPV wallet database: %v", err)
+		}
+		w.w = nil
+	}()
+	return &wg, nil
+}
+
+// open loads the wallet from the database. The wallet mutex must be held or
+// the wallet not yet shared.
+func (w *spvWallet) open(db wallet.DB) error {
+	wal, err := wallet.Open(w.ctx, &wallet.Config{
+		DB:              db,
+		PubPassphrase:   []byte(wallet.InsecurePubPassphrase),
+		GapLimit:        gapLimit,
+		AccountGapLimit: accountGapLimit,
+		RelayFee:        relayFee,
+		Params:          w.params,
+	})
+	if err != nil {
+		return fmt.Errorf("error loading SPV wallet: %v", err)
+	}
+	wal.SetNetworkBackend(w)
+	w.db, w.w = db, wal
+	return w.loadAccount()
+}
+
+// create creates a new wallet, restoring from the configured seed if there is
+// one, and opens it. The seed is stored, encrypted with the password, so that
+// it can be exported for backup. The wallet mutex must be held.
+func (w *spvWallet) create(pw []byte) error {
+	if len(pw) == 0 {
+		return fmt.Errorf("a password is required to create the wallet")
+	}
+	var seed []byte
+	if len(w.restoreSeed) > 0 {
+		seed = make([]byte, len(w.restoreSeed))
+		copy(seed, w.restoreSeed)
+	} else {
+		var err error
+		seed, err = hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+		if err != nil {
+			return err
+		}
+	}
+	defer encode.ClearBytes(seed)
+	crypter := encrypt.NewCrypter(pw)
+	defer crypter.Close()
+	encSeed, err := crypter.Encrypt(seed)
+	if err != nil {
+		return fmt.Errorf("error encrypting seed: %v", err)
+	}
+	dbPath := filepath.Join(w.dir, walletDBName)
+	db, err := wallet.CreateDB("bdb", dbPath)
+	if err != nil {
+		return fmt.Errorf("error creating SPV wallet database: %v", err)
+	}
+	err = wallet.Create(w.ctx, db, []byte(wallet.InsecurePubPassphrase), pw, seed, w.params)
+	if err == nil {
+		b := encode.BuildyBytes{0}.AddData(crypter.Serialize()).AddData(encSeed)
+		err = ioutil.WriteFile(filepath.Join(w.dir, seedFileName), b, 0600)
+	}
+	if err == nil {
+		err = w.open(db)
+	}
+	if err != nil {
+		db.Close()
+		os.Remove(dbPath)
+		return fmt.Errorf("error creating SPV wallet: %v", err)
+	}
+	if len(w.restoreSeed) > 0 {
+		w.log.Infof("Restored a %s SPV wallet from seed", w.params.Name)
+	} else {
+		w.log.Infof("Created a new %s SPV wallet", w.params.Name)
+	}
+	w.restoreSeed = nil
+	return nil
+}
+
+// loadAccount looks up the DEX account. If the account doesn't exist and the
+// wallet is unlocked, the account is created. The wallet mutex must be held.
+func (w *spvWallet) loadAccount() error {
+	acct, err := w.w.AccountNumber(w.ctx, w.acctName)
+	if err == nil {
+		w.acct, w.acctCreated = acct, true
+		return nil
+	}
+	if !walleterrors.Is(err, walleterrors.NotExist) {
+		return err
+	}
+	if w.w.Locked() {
+		// The account is created when the wallet is unlocked.
+		return nil
+	}
+	acct, err = w.w.NextAccount(w.ctx, w.acctName)
+	if err != nil {
+		return fmt.Errorf("error creating account %q: %v", w.acctName, err)
+	}
+	w.log.Infof("Created account %q (%d) for DEX funds", w.acctName, acct)
+	w.acct, w.acctCreated = acct, true
+	return nil
+}
+
+// wallet is the loaded wallet and the DEX account number. An error is
+// returned if the wallet or account have not been created.
+func (w *spvWallet) wallet() (*wallet.Wallet, uint32, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	if w.w == nil || !w.acctCreated {
+		return nil, 0, errWalletNotCreated
+	}
+	return w.w, w.acct, nil
+}
+
+// seed decrypts the wallet's seed with the wallet password.
+func (w *spvWallet) seed(pw []byte) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(w.dir, seedFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errWalletNotCreated
+		}
+		return nil, err
+	}
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	if ver != 0 || len(pushes) != 2 {
+		return nil, fmt.Errorf("unknown seed file version %d", ver)
+	}
+	crypter, err := encrypt.Deserialize(pw, pushes[0])
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting seed: %v", err)
+	}
+	defer crypter.Close()
+	seed, err := crypter.Decrypt(pushes[1])
+	if err != nil {
+		return nil, fmt.Errorf("incorrect password")
+	}
+	return seed, nil
+}
+
+// run syncs with the network when a new block is announced, and periodically
+// otherwise.
+func (w *spvWallet) run(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	// Give the peers a moment to connect before the first sync.
+	select {
+	case <-time.After(connectInterval):
+	case <-ctx.Done():
+		return
+	}
+	for {
+		if err := w.sync(ctx); err != nil {
+			if errors.Is(err, errNoPeers) || errors.Is(err, errWalletNotCreated) {
+				w.log.Debugf("Cannot sync the SPV wallet: %v", err)
+			} else if ctx.Err() == nil {
+				w.log.Errorf("SPV wallet sync error: %v", err)
+			}
+		}
+		select {
+		case <-w.blockNote:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyBlock signals the run loop to sync.
+func (w *spvWallet) notifyBlock() {
+	select {
+	case w.blockNote <- struct{}{}:
+	default:
+	}
+}
+
+// sync fetches new headers and filters, and scans the new blocks for wallet
+// transactions. On the first sync, addresses used since the last sync are
+// discovered before scanning, and unmined transactions are rebroadcast.
+func (w *spvWallet) sync(ctx context.Context) error {
+	w.syncMtx.Lock()
+	defer w.syncMtx.Unlock()
+	wal, _, err := w.wallet()
+	if err != nil {
+		return err
+	}
+	if w.src.peerCount() == 0 {
+		return errNoPeers
+	}
+	if err := wal.FetchMissingCFilters(ctx, w.src); err != nil {
+		return err
+	}
+	count, _, _, _, tipHeight, err := wal.FetchHeaders(ctx, w.src)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		w.log.Debugf("Fetched %d new header(s). Tip height %d", count, tipHeight)
+	}
+	rescanPoint, err := wal.RescanPoint(ctx)
+	if err != nil {
+		return err
+	}
+	if !w.synced && rescanPoint != nil {
+		if err := wal.DiscoverActiveAddresses(ctx, w.src, rescanPoint, false); err != nil {
+			return fmt.Errorf("address discovery error: %v", err)
+		}
+	}
+	if !w.synced {
+		if err := wal.LoadActiveDataFilters(ctx, w, true); err != nil {
+			return err
+		}
+	}
+	if rescanPoint != nil {
+		if err := wal.Rescan(ctx, w, rescanPoint); err != nil {
+			return err
+		}
+	}
+	if !w.synced {
+		if err := wal.PublishUnminedTransactions(ctx, w.src); err != nil {
+			w.log.Warnf("Error rebroadcasting unmined transactions: %v", err)
+		}
+		w.synced = true
+	}
+	return nil
+}
+
+// rescan rescans the blocks from the height for wallet transactions.
+func (w *spvWallet) rescan(height uint32) error {
+	w.syncMtx.Lock()
+	defer w.syncMtx.Unlock()
+	wal, _, err := w.wallet()
+	if err != nil {
+		return err
+	}
+	if height == 0 {
+		// The genesis block is not scanned.
+		height = 1
+	}
+	return wal.RescanFromHeight(w.ctx, w, int32(height))
+}
+
+// Blocks requests the blocks from the network. Part of the wallet.Peer
+// interface.
+func (w *spvWallet) Blocks(ctx context.Context, blockHashes []*chainhash.Hash) ([]*wire.MsgBlock, error) {
+	return w.src.Blocks(ctx, blockHashes)
+}
+
+// CFilters requests the blocks' committed filters from the network. Part of
+// the wallet.Peer interface.
+func (w *spvWallet) CFilters(ctx context.Context, blockHashes []*chainhash.Hash) ([]*gcs.Filter, error) {
+	return w.src.CFilters(ctx, blockHashes)
+}
+
+// Headers requests headers following the locators from the network. Part of
+// the wallet.Peer interface.
+func (w *spvWallet) Headers(ctx context.Context, blockLocators []*chainhash.Hash, hashStop *chainhash.Hash) ([]*wire.BlockHeader, error) {
+	return w.src.Headers(ctx, blockLocators, hashStop)
+}
+
+// PublishTransactions broadcasts the transactions. Part of the wallet.Peer
+// interface.
+func (w *spvWallet) PublishTransactions(ctx context.Context, txs ...*wire.MsgTx) error {
+	return w.src.PublishTransactions(ctx, txs...)
+}
+
+// String describes the wallet's network backend for the wallet's log messages.
+func (w *spvWallet) String() string {
+	return fmt.Sprintf("%v", w.src)
+}
+
+// LoadTxFilter adds addresses and outpoints to the filter used to find
+// wallet transactions in blocks. If reload is true, the filter is cleared
+// first. Part of the wallet.NetworkBackend interface.
+func (w *spvWallet) LoadTxFilter(_ context.Context, reload bool, addrs []dcrutil.Address, outpoints []wire.OutPoint) error {
+	w.filterMtx.Lock()
+	defer w.filterMtx.Unlock()
+	if reload {
+		w.scripts = make(map[string]struct{})
+		w.outpoints = make(map[wire.OutPoint]struct{})
+	}
+	for _, addr := range addrs {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return err
+		}
+		w.scripts[string(pkScript)] = struct{}{}
+	}
+	for _, op := range outpoints {
+		w.outpoints[op] = struct{}{}
+	}
+	return nil
+}
+
+// filterEntries is the watched scripts and outpoints, as filter entries.
+func (w *spvWallet) filterEntries() blockcf.Entries {
+	w.filterMtx.RLock()
+	defer w.filterMtx.RUnlock()
+	entries := make(blockcf.Entries, 0, len(w.scripts)+len(w.outpoints))
+	for script := range w.scripts {
+		entries.AddRegularPkScript([]byte(script))
+	}
+	for op := range w.outpoints {
+		op := op
+		entries.AddOutPoint(&op)
+	}
+	return entries
+}
+
+// relevantTxs is the block's transactions that spend a watched outpoint or pay
+// a watched script. The outputs paying watched scripts are added to the
+// watched outpoints.
+func (w *spvWallet) relevantTxs(blk *wire.MsgBlock) []*wire.MsgTx {
+	w.filterMtx.Lock()
+	defer w.filterMtx.Unlock()
+	var txs []*wire.MsgTx
+	check := func(tx *wire.MsgTx, tree int8) {
+		var relevant bool
+		for _, txIn := range tx.TxIn {
+			op := txIn.PreviousOutPoint
+			if _, found := w.outpoints[op]; found {
+				relevant = true
+			}
+		}
+		txHash := tx.TxHash()
+		for i, txOut := range tx.TxOut {
+			pkScript := txOut.PkScript
+			if tree == wire.TxTreeStake && len(pkScript) > 0 {
+				// Stake outputs are prefixed with a stake opcode.
+				pkScript = pkScript[1:]
+			}
+			if _, found := w.scripts[string(pkScript)]; found {
+				relevant = true
+				w.outpoints[wire.OutPoint{Hash: txHash, Index: uint32(i), Tree: tree}] = struct{}{}
+			}
+		}
+		if relevant {
+			txs = append(txs, tx)
+		}
+	}
+	for _, tx := range blk.Transactions {
+		check(tx, wire.TxTreeRegular)
+	}
+	for _, tx := range blk.STransactions {
+		check(tx, wire.TxTreeStake)
+	}
+	return txs
+}
+
+// Rescan checks the blocks' committed filters against the watched scripts and
+// outpoints, fetches the blocks that match, and saves their relevant
+// transactions. The blocks are handled in order, so that outputs found in a
+// block are watched for spends in later blocks. Part of the
+// wallet.NetworkBackend interface.
+func (w *spvWallet) Rescan(ctx context.Context, blocks []chainhash.Hash, save func(block *chainhash.Hash, txs []*wire.MsgTx) error) error {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return err
+	}
+	for i := range blocks {
+		blockHash := &blocks[i]
+		f, err := wal.CFilter(ctx, blockHash)
+		if err != nil {
+			return err
+		}
+		entries := w.filterEntries()
+		if len(entries) == 0 || !f.MatchAny(blockcf.Key(blockHash), entries) {
+			continue
+		}
+		blks, err := w.src.Blocks(ctx, []*chainhash.Hash{blockHash})
+		if err != nil {
+			return err
+		}
+		txs := w.relevantTxs(blks[0])
+		if len(txs) == 0 {
+			// False positive.
+			continue
+		}
+		if err := save(blockHash, txs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StakeDifficulty is not available from the network. Part of the
+// wallet.NetworkBackend interface.
+func (w *spvWallet) StakeDifficulty(context.Context) (dcrutil.Amount, error) {
+	return 0, fmt.Errorf("stake difficulty is not available to the SPV wallet")
+}
+
+// EstimateSmartFee is not available to the SPV wallet. ExchangeWallet will
+// use the fallback fee rate.
+func (w *spvWallet) EstimateSmartFee(int64, chainjson.EstimateSmartFeeMode) (float64, error) {
+	return 0, fmt.Errorf("fee estimation is not available to the SPV wallet")
+}
+
+// SendRawTransaction records the transaction in the wallet and broadcasts it.
+func (w *spvWallet) SendRawTransaction(tx *wire.MsgTx, _ bool) (*chainhash.Hash, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	txB, err := tx.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return wal.PublishTransaction(w.ctx, tx, txB, w)
+}
+
+// GetTxOut returns the output if it is unspent. Wallet transactions and
+// outputs found by findOutput are checked.
+func (w *spvWallet) GetTxOut(txHash *chainhash.Hash, index uint32, _ bool) (*chainjson.GetTxOutResult, error) {
+	wal, acct, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	ctx := w.ctx
+	tipHash, tipHeight := wal.MainChainTip(ctx)
+	op := wire.OutPoint{Hash: *txHash, Index: index, Tree: wire.TxTreeRegular}
+	var tx *wire.MsgTx
+	height := int32(-1)
+	summary, confs, _, err := wal.TransactionSummary(ctx, txHash)
+	switch {
+	case err == nil:
+		tx = new(wire.MsgTx)
+		if err := tx.FromBytes(summary.Transaction); err != nil {
+			return nil, err
+		}
+		if confs > 0 {
+			height = tipHeight - confs + 1
+		}
+	case walleterrors.Is(err, walleterrors.NotExist):
+		fo := w.foundOutput(ctx, wal, &op)
+		if fo == nil {
+			return nil, nil
+		}
+		tx, height = fo.tx, fo.height
+	default:
+		return nil, err
+	}
+	if int(index) >= len(tx.TxOut) {
+		return nil, nil
+	}
+	txOut := tx.TxOut[index]
+	spent, err := w.outputSpent(ctx, wal, acct, &op, txOut.PkScript, height)
+	if err != nil {
+		return nil, err
+	}
+	if spent {
+		return nil, nil
+	}
+	var txConfs int64
+	if height >= 0 {
+		txConfs = int64(tipHeight - height + 1)
+	}
+	return &chainjson.GetTxOutResult{
+		BestBlock:     tipHash.String(),
+		Confirmations: txConfs,
+		Value:         dcrutil.Amount(txOut.Value).ToCoin(),
+		ScriptPubKey:  w.scriptPubKeyResult(txOut.Version, txOut.PkScript),
+		Version:       int32(txOut.Version),
+	}, nil
+}
+
+// outputSpent checks whether the output has been spent. Wallet outputs are
+// checked against the account's unspent outputs. For other outputs, the
+// wallet's unmined transactions are checked, and then the filters of blocks
+// from the output's block to the tip are checked for the outpoint.
+func (w *spvWallet) outputSpent(ctx context.Context, wal *wallet.Wallet, acct uint32, op *wire.OutPoint,
+	pkScript []byte, height int32) (bool, error) {
+
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(0, pkScript, w.params)
+	if len(addrs) == 1 {
+		if mine, _ := wal.HaveAddress(ctx, addrs[0]); mine {
+			unspents, err := wal.UnspentOutputs(ctx, wallet.OutputSelectionPolicy{Account: acct})
+			if err != nil {
+				return false, err
+			}
+			for _, unspent := range unspents {
+				if unspent.OutPoint.Hash == op.Hash && unspent.OutPoint.Index == op.Index {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	}
+	unmined, err := wal.UnminedTransactions(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, tx := range unmined {
+		if spends(tx, op) {
+			return true, nil
+		}
+	}
+	if height < 0 {
+		return false, nil
+	}
+	w.foundMtx.Lock()
+	defer w.foundMtx.Unlock()
+	scan := w.spends[*op]
+	if scan == nil {
+		scan = &spendScan{through: height - 1}
+		w.spends[*op] = scan
+	}
+	if scan.spent {
+		return true, nil
+	}
+	var entries blockcf.Entries
+	entries.AddOutPoint(op)
+	_, tipHeight := wal.MainChainTip(ctx)
+	for h := scan.through + 1; h <= tipHeight; h++ {
+		info, err := wal.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(h))
+		if err != nil {
+			return false, err
+		}
+		f, err := wal.CFilter(ctx, &info.Hash)
+		if err != nil {
+			return false, err
+		}
+		if f.Match(blockcf.Key(&info.Hash), entries[0]) {
+			blks, err := w.src.Blocks(ctx, []*chainhash.Hash{&info.Hash})
+			if err != nil {
+				return false, err
+			}
+			for _, tx := range blks[0].Transactions {
+				if spends(tx, op) {
+					scan.spent = true
+					return true, nil
+				}
+			}
+		}
+		scan.through = h
+	}
+	return false, nil
+}
+
+// spends is true if the transaction spends the outpoint.
+func spends(tx *wire.MsgTx, op *wire.OutPoint) bool {
+	for _, txIn := range tx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		if prevOut.Hash == op.Hash && prevOut.Index == op.Index {
+			return true
+		}
+	}
+	return false
+}
+
+// findOutput searches the network for the output, which is not the wallet's.
+// The mempools of peers are checked first, then the blocks back to
+// auditScanBlocks from the tip. The found output is remembered, and
+// GetTxOut will return it until it is spent.
+func (w *spvWallet) findOutput(txHash *chainhash.Hash, vout uint32, pkScript []byte) error {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return err
+	}
+	ctx := w.ctx
+	op := wire.OutPoint{Hash: *txHash, Index: vout, Tree: wire.TxTreeRegular}
+	w.foundMtx.Lock()
+	_, found := w.found[op]
+	w.foundMtx.Unlock()
+	if found {
+		return nil
+	}
+	hasOutput := func(tx *wire.MsgTx) bool {
+		return tx.TxHash() == *txHash && int(vout) < len(tx.TxOut) &&
+			bytes.Equal(tx.TxOut[vout].PkScript, pkScript)
+	}
+	_, tipHeight := wal.MainChainTip(ctx)
+	tx, err := w.src.getTx(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if tx != nil && hasOutput(tx) {
+		w.addFound(&op, &foundOutput{tx: tx, height: -1, scanned: tipHeight})
+		return nil
+	}
+	for h := tipHeight; h > 0 && h > tipHeight-auditScanBlocks; h-- {
+		fo, err := w.scanBlock(ctx, wal, h, pkScript, hasOutput)
+		if err != nil {
+			return err
+		}
+		if fo != nil {
+			w.addFound(&op, fo)
+			return nil
+		}
+	}
+	return fmt.Errorf("output %s:%d not found", txHash, vout)
+}
+
+// addFound remembers an output found by findOutput.
+func (w *spvWallet) addFound(op *wire.OutPoint, fo *foundOutput) {
+	w.foundMtx.Lock()
+	defer w.foundMtx.Unlock()
+	w.found[*op] = fo
+}
+
+// scanBlock checks the filter of the block at the height for the script, and
+// if it matches, looks for a transaction in the block that satisfies match.
+func (w *spvWallet) scanBlock(ctx context.Context, wal *wallet.Wallet, height int32, pkScript []byte,
+	match func(*wire.MsgTx) bool) (*foundOutput, error) {
+
+	info, err := wal.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(height))
+	if err != nil {
+		return nil, err
+	}
+	f, err := wal.CFilter(ctx, &info.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if !f.Match(blockcf.Key(&info.Hash), pkScript) {
+		return nil, nil
+	}
+	blks, err := w.src.Blocks(ctx, []*chainhash.Hash{&info.Hash})
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range blks[0].Transactions {
+		if match(tx) {
+			blockHash := info.Hash
+			return &foundOutput{tx: tx, blockHash: &blockHash, height: height}, nil
+		}
+	}
+	return nil, nil
+}
+
+// foundOutput is the output found by findOutput. If the output's transaction
+// was unmined when found, the blocks mined since are checked for it.
+func (w *spvWallet) foundOutput(ctx context.Context, wal *wallet.Wallet, op *wire.OutPoint) *foundOutput {
+	w.foundMtx.Lock()
+	fo := w.found[*op]
+	w.foundMtx.Unlock()
+	if fo == nil || fo.height >= 0 {
+		return fo
+	}
+	_, tipHeight := wal.MainChainTip(ctx)
+	txHash := fo.tx.TxHash()
+	isTx := func(tx *wire.MsgTx) bool { return tx.TxHash() == txHash }
+	pkScript := fo.tx.TxOut[op.Index].PkScript
+	for h := fo.scanned + 1; h <= tipHeight; h++ {
+		mined, err := w.scanBlock(ctx, wal, h, pkScript, isTx)
+		if err != nil {
+			w.log.Errorf("Error checking block %d for transaction %s: %v", h, txHash, err)
+			return fo
+		}
+		if mined != nil {
+			w.addFound(op, mined)
+			return mined
+		}
+		fo.scanned = h
+	}
+	return fo
+}
+
+// GetBalanceMinConf gets the balance of the DEX account. The account argument
+// is ignored.
+func (w *spvWallet) GetBalanceMinConf(_ string, minConfirms int) (*walletjson.GetBalanceResult, error) {
+	wal, acct, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	bal, err := wal.CalculateAccountBalance(w.ctx, acct, int32(minConfirms))
+	if err != nil {
+		return nil, err
+	}
+	tipHash, _ := wal.MainChainTip(w.ctx)
+	return &walletjson.GetBalanceResult{
+		Balances: []walletjson.GetAccountBalanceResult{{
+			AccountName:             w.acctName,
+			ImmatureCoinbaseRewards: bal.ImmatureCoinbaseRewards.ToCoin(),
+			ImmatureStakeGeneration: bal.ImmatureStakeGeneration.ToCoin(),
+			LockedByTickets:         bal.LockedByTickets.ToCoin(),
+			Spendable:               bal.Spendable.ToCoin(),
+			Total:                   bal.Total.ToCoin(),
+			Unconfirmed:             bal.Unconfirmed.ToCoin(),
+			VotingAuthority:         bal.VotingAuthority.ToCoin(),
+		}},
+		BlockHash: tipHash.String(),
+	}, nil
+}
+
+// GetBestBlock is the wallet's best block.
+func (w *spvWallet) GetBestBlock() (*chainhash.Hash, int64, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, height := wal.MainChainTip(w.ctx)
+	return &hash, int64(height), nil
+}
+
+// GetBlockHash is the hash of the main chain block at the height.
+func (w *spvWallet) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	info, err := wal.BlockInfo(w.ctx, wallet.NewBlockIdentifierFromHeight(int32(blockHeight)))
+	if err != nil {
+		return nil, err
+	}
+	return &info.Hash, nil
+}
+
+// GetBlockVerbose fetches the block from the network. The transactions are
+// always included.
+func (w *spvWallet) GetBlockVerbose(blockHash *chainhash.Hash, _ bool) (*chainjson.GetBlockVerboseResult, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	info, err := wal.BlockInfo(w.ctx, wallet.NewBlockIdentifierFromHash(blockHash))
+	if err != nil {
+		return nil, err
+	}
+	blks, err := w.src.Blocks(w.ctx, []*chainhash.Hash{blockHash})
+	if err != nil {
+		return nil, err
+	}
+	blk := blks[0]
+	res := &chainjson.GetBlockVerboseResult{
+		Hash:          blockHash.String(),
+		Confirmations: int64(info.Confirmations),
+		Height:        int64(info.Height),
+		MerkleRoot:    blk.Header.MerkleRoot.String(),
+		StakeRoot:     blk.Header.StakeRoot.String(),
+		Time:          blk.Header.Timestamp.Unix(),
+		PreviousHash:  blk.Header.PrevBlock.String(),
+	}
+	for _, tx := range blk.Transactions {
+		res.RawTx = append(res.RawTx, *w.txRawResult(tx, blockHash, info.Height, info.Confirmations))
+	}
+	for _, tx := range blk.STransactions {
+		res.RawSTx = append(res.RawSTx, *w.txRawResult(tx, blockHash, info.Height, info.Confirmations))
+	}
+	if info.Confirmations > 1 {
+		next, err := wal.BlockInfo(w.ctx, wallet.NewBlockIdentifierFromHeight(info.Height+1))
+		if err != nil {
+			return nil, err
+		}
+		res.NextHash = next.Hash.String()
+	}
+	return res, nil
+}
+
+// GetRawMempool is the wallet's unmined transactions.
+func (w *spvWallet) GetRawMempool(chainjson.GetRawMempoolTxTypeCmd) ([]*chainhash.Hash, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	txs, err := wal.UnminedTransactions(w.ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]*chainhash.Hash, 0, len(txs))
+	for _, tx := range txs {
+		txHash := tx.TxHash()
+		hashes = append(hashes, &txHash)
+	}
+	return hashes, nil
+}
+
+// GetRawTransactionVerbose gets a wallet transaction, a transaction found by
+// findOutput, or a transaction in the mempool of a peer.
+func (w *spvWallet) GetRawTransactionVerbose(txHash *chainhash.Hash) (*chainjson.TxRawResult, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	ctx := w.ctx
+	_, tipHeight := wal.MainChainTip(ctx)
+	summary, confs, blockHash, err := wal.TransactionSummary(ctx, txHash)
+	if err == nil {
+		tx := new(wire.MsgTx)
+		if err := tx.FromBytes(summary.Transaction); err != nil {
+			return nil, err
+		}
+		return w.txRawResult(tx, blockHash, tipHeight-confs+1, confs), nil
+	}
+	if !walleterrors.Is(err, walleterrors.NotExist) {
+		return nil, err
+	}
+	w.foundMtx.Lock()
+	for op, fo := range w.found {
+		if op.Hash == *txHash {
+			w.foundMtx.Unlock()
+			var confs int32
+			if fo.height >= 0 {
+				confs = tipHeight - fo.height + 1
+			}
+			return w.txRawResult(fo.tx, fo.blockHash, fo.height, confs), nil
+		}
+	}
+	w.foundMtx.Unlock()
+	tx, err := w.src.getTx(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, &dcrjson.RPCError{
+			Code:    dcrjson.ErrRPCNoTxInfo,
+			Message: fmt.Sprintf("transaction %s not found", txHash),
+		}
+	}
+	return w.txRawResult(tx, nil, 0, 0), nil
+}
+
+// txRawResult describes the transaction.
+func (w *spvWallet) txRawResult(tx *wire.MsgTx, blockHash *chainhash.Hash, height, confs int32) *chainjson.TxRawResult {
+	txB, _ := tx.Bytes()
+	res := &chainjson.TxRawResult{
+		Hex:      hex.EncodeToString(txB),
+		Txid:     tx.TxHash().String(),
+		Version:  int32(tx.Version),
+		LockTime: tx.LockTime,
+		Expiry:   tx.Expiry,
+		Vin:      make([]chainjson.Vin, 0, len(tx.TxIn)),
+		Vout:     make([]chainjson.Vout, 0, len(tx.TxOut)),
+	}
+	if blockHash != nil {
+		res.BlockHash = blockHash.String()
+		res.BlockHeight = int64(height)
+		res.Confirmations = int64(confs)
+	}
+	for i, txIn := range tx.TxIn {
+		vin := chainjson.Vin{
+			Sequence: txIn.Sequence,
+			AmountIn: dcrutil.Amount(txIn.ValueIn).ToCoin(),
+		}
+		if i == 0 && (txIn.PreviousOutPoint.Hash == chainhash.Hash{}) {
+			vin.Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		} else {
+			vin.Txid = txIn.PreviousOutPoint.Hash.String()
+			vin.Vout = txIn.PreviousOutPoint.Index
+			vin.Tree = txIn.PreviousOutPoint.Tree
+			vin.ScriptSig = &chainjson.ScriptSig{
+				Hex: hex.EncodeToString(txIn.SignatureScript),
+			}
+		}
+		res.Vin = append(res.Vin, vin)
+	}
+	for i, txOut := range tx.TxOut {
+		res.Vout = append(res.Vout, chainjson.Vout{
+			Value:        dcrutil.Amount(txOut.Value).ToCoin(),
+			N:            uint32(i),
+			Version:      txOut.Version,
+			ScriptPubKey: w.scriptPubKeyResult(txOut.Version, txOut.PkScript),
+		})
+	}
+	return res
+}
+
+// scriptPubKeyResult describes the pubkey script.
+func (w *spvWallet) scriptPubKeyResult(version uint16, pkScript []byte) chainjson.ScriptPubKeyResult {
+	class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(version, pkScript, w.params)
+	res := chainjson.ScriptPubKeyResult{
+		Hex:     hex.EncodeToString(pkScript),
+		ReqSigs: int32(reqSigs),
+		Type:    class.String(),
+	}
+	for _, addr := range addrs {
+		res.Addresses = append(res.Addresses, addr.Address())
+	}
+	return res
+}
+
+// ListUnspentMin lists the unlocked unspent outputs of the DEX account.
+func (w *spvWallet) ListUnspentMin(minConf int) ([]walletjson.ListUnspentResult, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	unspents, err := wal.ListUnspent(w.ctx, int32(minConf), math.MaxInt32, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]walletjson.ListUnspentResult, 0, len(unspents))
+	for _, unspent := range unspents {
+		if unspent.Account == w.acctName {
+			res = append(res, *unspent)
+		}
+	}
+	return res, nil
+}
+
+// LockUnspent locks or unlocks the outputs. If unlock is true and no outputs
+// are specified, all outputs are unlocked.
+func (w *spvWallet) LockUnspent(unlock bool, ops []*wire.OutPoint) error {
+	wal, _, err := w.wallet()
+	if err != nil {
+		if unlock && len(ops) == 0 && errors.Is(err, errWalletNotCreated) {
+			// Nothing is locked.
+			return nil
+		}
+		return err
+	}
+	if unlock && len(ops) == 0 {
+		wal.ResetLockedOutpoints()
+		return nil
+	}
+	for _, op := range ops {
+		if unlock {
+			wal.UnlockOutpoint(*op)
+		} else {
+			wal.LockOutpoint(*op)
+		}
+	}
+	return nil
+}
+
+// ListLockUnspent lists the locked outputs.
+func (w *spvWallet) ListLockUnspent() ([]*wire.OutPoint, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	locked := wal.LockedOutpoints()
+	ops := make([]*wire.OutPoint, 0, len(locked))
+	for _, in := range locked {
+		txHash, err := chainhash.NewHashFromStr(in.Txid)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, wire.NewOutPoint(txHash, in.Vout, in.Tree))
+	}
+	return ops, nil
+}
+
+// GetRawChangeAddress gets a new internal address from the DEX account. The
+// account argument is ignored.
+func (w *spvWallet) GetRawChangeAddress(string, dcrutil.AddressParams) (dcrutil.Address, error) {
+	wal, acct, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := wal.NewChangeAddress(w.ctx, acct)
+	if err != nil {
+		return nil, err
+	}
+	return stdAddress(addr, w.params)
+}
+
+// stdAddress converts an address returned by the wallet to the standard
+// dcrutil type, so that it can be used to create payment scripts.
+func stdAddress(addr dcrutil.Address, params *chaincfg.Params) (dcrutil.Address, error) {
+	return dcrutil.DecodeAddress(addr.Address(), params)
+}
+
+// GetNewAddressGapPolicy gets a new external address from the DEX account,
+// ignoring the gap limit. The account and gap policy arguments are ignored.
+func (w *spvWallet) GetNewAddressGapPolicy(string, rpcclient.GapPolicy, dcrutil.AddressParams) (dcrutil.Address, error) {
+	wal, acct, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := wal.NewExternalAddress(w.ctx, acct, wallet.WithGapPolicyIgnore())
+	if err != nil {
+		return nil, err
+	}
+	return stdAddress(addr, w.params)
+}
+
+// SignRawTransaction signs the transaction's inputs that spend wallet
+// outputs.
+func (w *spvWallet) SignRawTransaction(tx *wire.MsgTx) (*wire.MsgTx, bool, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, false, err
+	}
+	sigErrs, err := wal.SignTransaction(w.ctx, tx, txscript.SigHashAll, nil, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, sigErr := range sigErrs {
+		w.log.Debugf("Error signing input %d of transaction %s: %v", sigErr.InputIndex, tx.TxHash(), sigErr.Error)
+	}
+	return tx, len(sigErrs) == 0, nil
+}
+
+// DumpPrivKey gets the private key for the wallet address. The wallet must be
+// unlocked.
+func (w *spvWallet) DumpPrivKey(address dcrutil.Address, net [2]byte) (*dcrutil.WIF, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	wif, err := wal.DumpWIFPrivateKey(w.ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return dcrutil.DecodeWIF(wif, net)
+}
+
+// GetTransaction gets a wallet transaction.
+func (w *spvWallet) GetTransaction(txHash *chainhash.Hash) (*walletjson.GetTransactionResult, error) {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return nil, err
+	}
+	summary, confs, blockHash, err := wal.TransactionSummary(w.ctx, txHash)
+	if err != nil {
+		if walleterrors.Is(err, walleterrors.NotExist) {
+			return nil, &dcrjson.RPCError{
+				Code:    dcrjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("no information for transaction %s", txHash),
+			}
+		}
+		return nil, err
+	}
+	res := &walletjson.GetTransactionResult{
+		Confirmations:   int64(confs),
+		TxID:            txHash.String(),
+		WalletConflicts: []string{},
+		Time:            summary.Timestamp,
+		TimeReceived:    summary.Timestamp,
+		Details:         []walletjson.GetTransactionDetailsResult{},
+		Hex:             hex.EncodeToString(summary.Transaction),
+		Fee:             summary.Fee.ToCoin(),
+	}
+	if blockHash != nil {
+		res.BlockHash = blockHash.String()
+	}
+	return res, nil
+}
+
+// WalletLock locks the wallet.
+func (w *spvWallet) WalletLock() error {
+	wal, _, err := w.wallet()
+	if err != nil {
+		return err
+	}
+	wal.Lock()
+	return nil
+}
+
+// WalletPassphrase unlocks the wallet. If the wallet has not been created, it
+// is created with the passphrase. If timeoutSecs is zero, the wallet remains
+// unlocked until locked with WalletLock.
+func (w *spvWallet) WalletPassphrase(passphrase string, timeoutSecs int64) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	pw := []byte(passphrase)
+	var created bool
+	if w.w == nil {
+		if err := w.create(pw); err != nil {
+			return err
+		}
+		created = true
+	}
+	var timeout <-chan time.Time
+	if timeoutSecs > 0 {
+		timeout = time.After(time.Duration(timeoutSecs) * time.Second)
+	}
+	if err := w.w.Unlock(w.ctx, pw, timeout); err != nil {
+		return err
+	}
+	if !w.acctCreated {
+		if err := w.loadAccount(); err != nil {
+			return err
+		}
+	}
+	if created {
+		w.notifyBlock()
+	}
+	return nil
+}
+
+// Disconnected is true if the wallet has no peers.
+func (w *spvWallet) Disconnected() bool {
+	return w.src == nil || w.src.peerCount() == 0
+}
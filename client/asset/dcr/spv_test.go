@@ -0,0 +1,725 @@
+// +build !harness
+
+package dcr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrec"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrd/rpcclient/v5"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/p2p/v2"
+)
+
+var tSPVParams = chaincfg.SimNetParams()
+
+// tBlockSource is an in-memory blockchain that satisfies blockSource.
+type tBlockSource struct {
+	mtx     sync.Mutex
+	blocks  []*wire.MsgBlock
+	filters []*gcs.Filter
+	mempool map[chainhash.Hash]*wire.MsgTx
+	sent    []*wire.MsgTx
+}
+
+func newTBlockSource() *tBlockSource {
+	s := &tBlockSource{
+		mempool: make(map[chainhash.Hash]*wire.MsgTx),
+	}
+	s.addBlock(tSPVParams.GenesisBlock)
+	return s
+}
+
+func (s *tBlockSource) addBlock(blk *wire.MsgBlock) {
+	f, err := blockcf.Regular(blk)
+	if err != nil {
+		panic(err.Error())
+	}
+	s.blocks = append(s.blocks, blk)
+	s.filters = append(s.filters, f)
+	for _, tx := range blk.Transactions {
+		delete(s.mempool, tx.TxHash())
+	}
+}
+
+// mine adds a block with a coinbase and the transactions. The header has the
+// minimum difficulty and ticket price, so it is only valid for short test
+// chains.
+func (s *tBlockSource) mine(txs ...*wire.MsgTx) *wire.MsgBlock {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	prev := &s.blocks[len(s.blocks)-1].Header
+	height := prev.Height + 1
+	coinbase := wire.NewMsgTx()
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex, wire.TxTreeRegular),
+		Sequence:         wire.MaxTxInSequenceNum,
+		BlockHeight:      wire.NullBlockHeight,
+		BlockIndex:       wire.NullBlockIndex,
+		SignatureScript:  []byte{byte(height), byte(height >> 8), 0, 0},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_TRUE}))
+	blk := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   prev.Version,
+			PrevBlock: prev.BlockHash(),
+			VoteBits:  dcrutil.BlockValid,
+			Bits:      prev.Bits,
+			SBits:     tSPVParams.MinimumStakeDiff,
+			Height:    height,
+			Timestamp: prev.Timestamp.Add(tSPVParams.TargetTimePerBlock),
+		},
+		Transactions: append([]*wire.MsgTx{coinbase}, txs...),
+	}
+	blk.Header.MerkleRoot = standalone.CalcTxTreeMerkleRoot(blk.Transactions)
+	blk.Header.StakeRoot = standalone.CalcTxTreeMerkleRoot(nil)
+	for {
+		hash := blk.Header.BlockHash()
+		if standalone.CheckProofOfWork(&hash, blk.Header.Bits, tSPVParams.PowLimit) == nil {
+			break
+		}
+		blk.Header.Nonce++
+	}
+	s.addBlock(blk)
+	return blk
+}
+
+func (s *tBlockSource) heightOf(hash *chainhash.Hash) (int, bool) {
+	for i, blk := range s.blocks {
+		if blk.BlockHash() == *hash {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *tBlockSource) peerCount() int {
+	return 1
+}
+
+func (s *tBlockSource) Headers(_ context.Context, locators []*chainhash.Hash, _ *chainhash.Hash) ([]*wire.BlockHeader, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	start := 0
+	for _, hash := range locators {
+		if h, found := s.heightOf(hash); found {
+			start = h + 1
+			break
+		}
+	}
+	var hdrs []*wire.BlockHeader
+	for _, blk := range s.blocks[start:] {
+		hdr := blk.Header
+		hdrs = append(hdrs, &hdr)
+	}
+	return hdrs, nil
+}
+
+func (s *tBlockSource) CFilters(_ context.Context, blockHashes []*chainhash.Hash) ([]*gcs.Filter, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	filters := make([]*gcs.Filter, 0, len(blockHashes))
+	for _, hash := range blockHashes {
+		h, found := s.heightOf(hash)
+		if !found {
+			return nil, errors.New("unknown block")
+		}
+		filters = append(filters, s.filters[h])
+	}
+	return filters, nil
+}
+
+func (s *tBlockSource) Blocks(_ context.Context, blockHashes []*chainhash.Hash) ([]*wire.MsgBlock, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	blks := make([]*wire.MsgBlock, 0, len(blockHashes))
+	for _, hash := range blockHashes {
+		h, found := s.heightOf(hash)
+		if !found {
+			return nil, errors.New("unknown block")
+		}
+		blks = append(blks, s.blocks[h])
+	}
+	return blks, nil
+}
+
+func (s *tBlockSource) getTx(_ context.Context, hash *chainhash.Hash) (*wire.MsgTx, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.mempool[*hash], nil
+}
+
+func (s *tBlockSource) PublishTransactions(_ context.Context, txs ...*wire.MsgTx) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, tx := range txs {
+		s.mempool[tx.TxHash()] = tx
+		s.sent = append(s.sent, tx)
+	}
+	return nil
+}
+
+// tFundingTx creates a transaction paying the address from an unknown output.
+func tFundingTx(t *testing.T, addr dcrutil.Address, value int64) *wire.MsgTx {
+	t.Helper()
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript error: %v", err)
+	}
+	tx := wire.NewMsgTx()
+	prevHash := chainhash.HashH(encodeTestBytes(value))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0, wire.TxTreeRegular), value+1e5, nil))
+	tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	return tx
+}
+
+func encodeTestBytes(v int64) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24), byte(v >> 32)}
+}
+
+func tSPVWallet(t *testing.T, dir string, src *tBlockSource, seed string) (*spvWallet, func()) {
+	t.Helper()
+	w := newSPVWallet(&SPVConfig{
+		SPV:     true,
+		Dir:     dir,
+		Account: defaultSPVAccountName,
+		Seed:    seed,
+	}, tSPVParams, tLogger)
+	w.src = src
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, err := w.connect(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("connect error: %v", err)
+	}
+	return w, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func tSync(t *testing.T, w *spvWallet) {
+	t.Helper()
+	if err := w.sync(context.Background()); err != nil {
+		t.Fatalf("sync error: %v", err)
+	}
+}
+
+func TestLoadSPVConfig(t *testing.T) {
+	cfg, err := loadSPVConfig(map[string]string{}, dex.Simnet)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected no config without spv setting. cfg = %v, err = %v", cfg, err)
+	}
+	cfg, err = loadSPVConfig(map[string]string{"spv": "0"}, dex.Simnet)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected no config with spv disabled. cfg = %v, err = %v", cfg, err)
+	}
+	cfg, err = loadSPVConfig(map[string]string{"spv": "1", "peers": "127.0.0.1"}, dex.Simnet)
+	if err != nil {
+		t.Fatalf("loadSPVConfig error: %v", err)
+	}
+	if cfg.Dir == "" {
+		t.Fatalf("default directory not set")
+	}
+	if cfg.Account != defaultSPVAccountName {
+		t.Fatalf("wrong default account %q", cfg.Account)
+	}
+	if chainParams.Net != tSPVParams.Net {
+		t.Fatalf("chain parameters not set")
+	}
+	w := newSPVWallet(cfg, tSPVParams, tLogger)
+	if len(w.peers) != 1 || w.peers[0] != "127.0.0.1:"+tSPVParams.DefaultPort {
+		t.Fatalf("wrong peers %v", w.peers)
+	}
+	_, err = loadSPVConfig(map[string]string{"spv": "1", "spvseed": "abcd"}, dex.Simnet)
+	if err == nil {
+		t.Fatalf("no error for short seed")
+	}
+	_, err = loadSPVConfig(map[string]string{"spv": "1", "spvseed": "zz"}, dex.Simnet)
+	if err == nil {
+		t.Fatalf("no error for invalid seed")
+	}
+}
+
+func TestSPVWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcrspv")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	w, shutdown := tSPVWallet(t, dir, src, "")
+	defer shutdown()
+
+	// The wallet isn't created until it is unlocked.
+	if _, err := w.GetBalanceMinConf("", 0); !errors.Is(err, errWalletNotCreated) {
+		t.Fatalf("expected errWalletNotCreated, got %v", err)
+	}
+	if err := w.LockUnspent(true, nil); err != nil {
+		t.Fatalf("LockUnspent error before creation: %v", err)
+	}
+	pw := "abc"
+	if err := w.WalletPassphrase(pw, 0); err != nil {
+		t.Fatalf("WalletPassphrase error: %v", err)
+	}
+	tSync(t, w)
+
+	addr, err := w.GetNewAddressGapPolicy("", rpcclient.GapPolicyIgnore, tSPVParams)
+	if err != nil {
+		t.Fatalf("GetNewAddressGapPolicy error: %v", err)
+	}
+	// The address is from the DEX account, not the default account.
+	wal, acct, _ := w.wallet()
+	if acct == 0 {
+		t.Fatalf("DEX funds are in the default account")
+	}
+	if info, err := wal.AddressInfo(w.ctx, addr); err != nil || info.Account() != acct {
+		t.Fatalf("address not in the DEX account. err = %v", err)
+	}
+
+	// Mine a payment to the wallet.
+	fundingTx := tFundingTx(t, addr, 5e8)
+	src.mine(fundingTx)
+	src.mine()
+	tSync(t, w)
+
+	bal, err := w.GetBalanceMinConf("", 1)
+	if err != nil {
+		t.Fatalf("GetBalanceMinConf error: %v", err)
+	}
+	if len(bal.Balances) != 1 || toAtoms(bal.Balances[0].Spendable) != 5e8 {
+		t.Fatalf("wrong balance %+v", bal.Balances)
+	}
+	unspents, err := w.ListUnspentMin(1)
+	if err != nil {
+		t.Fatalf("ListUnspentMin error: %v", err)
+	}
+	fundingHash := fundingTx.TxHash()
+	if len(unspents) != 1 || unspents[0].TxID != fundingHash.String() || unspents[0].Confirmations != 2 {
+		t.Fatalf("wrong unspents %+v", unspents)
+	}
+	txOut, err := w.GetTxOut(&fundingHash, 0, true)
+	if err != nil {
+		t.Fatalf("GetTxOut error: %v", err)
+	}
+	if txOut == nil || txOut.Confirmations != 2 || toAtoms(txOut.Value) != 5e8 {
+		t.Fatalf("wrong output %+v", txOut)
+	}
+
+	// Locked outputs.
+	op := wire.NewOutPoint(&fundingHash, 0, wire.TxTreeRegular)
+	if err := w.LockUnspent(false, []*wire.OutPoint{op}); err != nil {
+		t.Fatalf("LockUnspent error: %v", err)
+	}
+	locked, err := w.ListLockUnspent()
+	if err != nil {
+		t.Fatalf("ListLockUnspent error: %v", err)
+	}
+	if len(locked) != 1 || *locked[0] != *op {
+		t.Fatalf("wrong locked outputs %v", locked)
+	}
+	if err := w.LockUnspent(true, nil); err != nil {
+		t.Fatalf("LockUnspent error: %v", err)
+	}
+
+	// Spend the output and send the transaction.
+	changeAddr, err := w.GetRawChangeAddress("", tSPVParams)
+	if err != nil {
+		t.Fatalf("GetRawChangeAddress error: %v", err)
+	}
+	changeScript, _ := txscript.PayToAddrScript(changeAddr)
+	spendTx := wire.NewMsgTx()
+	spendTx.AddTxIn(wire.NewTxIn(op, 5e8, nil))
+	spendTx.AddTxOut(wire.NewTxOut(5e8-1e5, changeScript))
+	signedTx, complete, err := w.SignRawTransaction(spendTx)
+	if err != nil || !complete {
+		t.Fatalf("SignRawTransaction error: complete = %t, err = %v", complete, err)
+	}
+	spendHash, err := w.SendRawTransaction(signedTx, false)
+	if err != nil {
+		t.Fatalf("SendRawTransaction error: %v", err)
+	}
+	if len(src.sent) != 1 || src.sent[0].TxHash() != *spendHash {
+		t.Fatalf("transaction not broadcast")
+	}
+	if txOut, _ := w.GetTxOut(&fundingHash, 0, true); txOut != nil {
+		t.Fatalf("spent output returned")
+	}
+	mempool, err := w.GetRawMempool("")
+	if err != nil {
+		t.Fatalf("GetRawMempool error: %v", err)
+	}
+	if len(mempool) != 1 || *mempool[0] != *spendHash {
+		t.Fatalf("wrong mempool %v", mempool)
+	}
+	src.mine(signedTx)
+	tSync(t, w)
+	tx, err := w.GetTransaction(spendHash)
+	if err != nil {
+		t.Fatalf("GetTransaction error: %v", err)
+	}
+	if tx.Confirmations != 1 {
+		t.Fatalf("wrong confirmations %d", tx.Confirmations)
+	}
+	blkHash, err := w.GetBlockHash(3)
+	if err != nil {
+		t.Fatalf("GetBlockHash error: %v", err)
+	}
+	blk, err := w.GetBlockVerbose(blkHash, true)
+	if err != nil {
+		t.Fatalf("GetBlockVerbose error: %v", err)
+	}
+	if len(blk.RawTx) != 2 || blk.RawTx[1].Txid != spendHash.String() || blk.NextHash != "" {
+		t.Fatalf("wrong block %+v", blk)
+	}
+	var unknown chainhash.Hash
+	if _, err := w.GetTransaction(&unknown); !isTxNotFoundErr(err) {
+		t.Fatalf("expected tx not found error, got %v", err)
+	}
+
+	// Export the seed and restore the wallet from it. The restored wallet has
+	// the same DEX account addresses and finds the wallet's transactions.
+	if _, err := w.seed([]byte("wrong")); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	seed, err := w.seed([]byte(pw))
+	if err != nil {
+		t.Fatalf("seed error: %v", err)
+	}
+	restoreDir, err := ioutil.TempDir("", "dcrspv")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(restoreDir)
+	restored, shutdownRestored := tSPVWallet(t, restoreDir, src, hex.EncodeToString(seed))
+	defer shutdownRestored()
+	if err := restored.WalletPassphrase("def", 0); err != nil {
+		t.Fatalf("WalletPassphrase error: %v", err)
+	}
+	tSync(t, restored)
+	restoredSeed, err := restored.seed([]byte("def"))
+	if err != nil || !bytes.Equal(restoredSeed, seed) {
+		t.Fatalf("wrong restored seed. err = %v", err)
+	}
+	bal, err = restored.GetBalanceMinConf("", 1)
+	if err != nil {
+		t.Fatalf("GetBalanceMinConf error: %v", err)
+	}
+	if toAtoms(bal.Balances[0].Total) != 5e8-1e5 {
+		t.Fatalf("wrong restored balance %+v", bal.Balances)
+	}
+}
+
+func TestSPVFindOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcrspv")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTBlockSource()
+	w, shutdown := tSPVWallet(t, dir, src, "")
+	defer shutdown()
+	if err := w.WalletPassphrase("abc", 0); err != nil {
+		t.Fatalf("WalletPassphrase error: %v", err)
+	}
+	tSync(t, w)
+
+	// A counterparty's contract that the wallet doesn't know about.
+	contract := []byte{txscript.OP_TRUE}
+	contractAddr, _ := dcrutil.NewAddressScriptHash(contract, tSPVParams)
+	pkScript, _ := txscript.PayToAddrScript(contractAddr)
+	contractTx := tFundingTx(t, contractAddr, 1e8)
+	contractHash := contractTx.TxHash()
+	if txOut, _ := w.GetTxOut(&contractHash, 0, true); txOut != nil {
+		t.Fatalf("unknown output returned")
+	}
+
+	// Found in the mempool.
+	src.PublishTransactions(context.Background(), contractTx)
+	if err := w.findOutput(&contractHash, 0, pkScript); err != nil {
+		t.Fatalf("findOutput error for mempool output: %v", err)
+	}
+	txOut, err := w.GetTxOut(&contractHash, 0, true)
+	if err != nil || txOut == nil || txOut.Confirmations != 0 {
+		t.Fatalf("mempool output not returned. txOut = %+v, err = %v", txOut, err)
+	}
+	// Mined since it was found.
+	src.mine(contractTx)
+	tSync(t, w)
+	txOut, err = w.GetTxOut(&contractHash, 0, true)
+	if err != nil || txOut == nil || txOut.Confirmations != 1 {
+		t.Fatalf("mined output not returned. txOut = %+v, err = %v", txOut, err)
+	}
+	if _, err := w.GetRawTransactionVerbose(&contractHash); err != nil {
+		t.Fatalf("GetRawTransactionVerbose error: %v", err)
+	}
+
+	// Found in a block.
+	otherTx := tFundingTx(t, contractAddr, 2e8)
+	otherHash := otherTx.TxHash()
+	src.mine(otherTx)
+	src.mine()
+	tSync(t, w)
+	if err := w.findOutput(&otherHash, 0, pkScript); err != nil {
+		t.Fatalf("findOutput error for mined output: %v", err)
+	}
+	txOut, err = w.GetTxOut(&otherHash, 0, true)
+	if err != nil || txOut == nil || txOut.Confirmations != 2 {
+		t.Fatalf("mined output not returned. txOut = %+v, err = %v", txOut, err)
+	}
+	if err := w.findOutput(&otherHash, 1, pkScript); err == nil {
+		t.Fatalf("no error for missing output")
+	}
+
+	// Spent.
+	spendTx := wire.NewMsgTx()
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&contractHash, 0, wire.TxTreeRegular), 1e8, nil))
+	spendTx.AddTxOut(wire.NewTxOut(1e8-1e5, []byte{txscript.OP_TRUE}))
+	src.mine(spendTx)
+	tSync(t, w)
+	if txOut, _ := w.GetTxOut(&contractHash, 0, true); txOut != nil {
+		t.Fatalf("spent output returned")
+	}
+}
+
+func TestSPVRescan(t *testing.T) {
+	w := newSPVWallet(&SPVConfig{}, tSPVParams, tLogger)
+	addr, _ := dcrutil.NewAddressPubKeyHash(bytes.Repeat([]byte{1}, 20), tSPVParams, dcrec.STEcdsaSecp256k1)
+	w.LoadTxFilter(context.Background(), true, []dcrutil.Address{addr}, nil)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	src := newTBlockSource()
+	fundingTx := tFundingTx(t, addr, 1e8)
+	blk := src.mine(fundingTx, tFundingTx(t, contractTestAddr(t), 1e8))
+	f := src.filters[1]
+	blkHash := blk.BlockHash()
+	if !f.Match(blockcf.Key(&blkHash), pkScript) {
+		t.Fatalf("filter doesn't match")
+	}
+
+	txs := w.relevantTxs(blk)
+	if len(txs) != 1 || txs[0].TxHash() != fundingTx.TxHash() {
+		t.Fatalf("wrong relevant transactions")
+	}
+	// The payment is watched for spends.
+	fundingHash := fundingTx.TxHash()
+	op := wire.NewOutPoint(&fundingHash, 0, wire.TxTreeRegular)
+	if _, found := w.outpoints[*op]; !found {
+		t.Fatalf("output not watched")
+	}
+	spendTx := wire.NewMsgTx()
+	spendTx.AddTxIn(wire.NewTxIn(op, 1e8, nil))
+	spendTx.AddTxOut(wire.NewTxOut(1e8-1e5, []byte{txscript.OP_TRUE}))
+	blk = src.mine(spendTx)
+	txs = w.relevantTxs(blk)
+	if len(txs) != 1 || txs[0].TxHash() != spendTx.TxHash() {
+		t.Fatalf("spend not found")
+	}
+}
+
+func contractTestAddr(t *testing.T) dcrutil.Address {
+	addr, err := dcrutil.NewAddressScriptHash([]byte{txscript.OP_TRUE}, tSPVParams)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHash error: %v", err)
+	}
+	return addr
+}
+
+func TestSPVPeers(t *testing.T) {
+	src := newTBlockSource()
+	blk := src.mine(tFundingTx(t, contractTestAddr(t), 1e8))
+	mempoolTx := tFundingTx(t, contractTestAddr(t), 2e8)
+	src.PublishTransactions(context.Background(), mempoolTx)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer ln.Close()
+	received := make(chan *wire.MsgTx, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tServePeer(conn, src, received)
+	}()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	announced := make(chan struct{}, 1)
+	newBlock := func() {
+		select {
+		case announced <- struct{}{}:
+		default:
+		}
+	}
+	ps := newPeerSet(tSPVParams, dir, []string{ln.Addr().String()}, newBlock, tLogger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ps.run(ctx)
+	timeout := time.After(5 * time.Second)
+	for ps.peerCount() == 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("peer not connected")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	select {
+	case <-announced:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("block announcement not received")
+	}
+
+	genesisHash := tSPVParams.GenesisHash
+	hdrs, err := ps.Headers(ctx, []*chainhash.Hash{&genesisHash}, &chainhash.Hash{})
+	if err != nil {
+		t.Fatalf("Headers error: %v", err)
+	}
+	blkHash := blk.BlockHash()
+	if len(hdrs) != 1 || hdrs[0].BlockHash() != blkHash {
+		t.Fatalf("wrong headers")
+	}
+	filters, err := ps.CFilters(ctx, []*chainhash.Hash{&blkHash})
+	if err != nil {
+		t.Fatalf("CFilters error: %v", err)
+	}
+	if len(filters) != 1 || !bytes.Equal(filters[0].NBytes(), src.filters[1].NBytes()) {
+		t.Fatalf("wrong filters")
+	}
+	blks, err := ps.Blocks(ctx, []*chainhash.Hash{&blkHash})
+	if err != nil {
+		t.Fatalf("Blocks error: %v", err)
+	}
+	if len(blks) != 1 || blks[0].BlockHash() != blkHash {
+		t.Fatalf("wrong blocks")
+	}
+
+	// A mempool transaction is returned, and an unknown one is not.
+	mempoolHash := mempoolTx.TxHash()
+	tx, err := ps.getTx(ctx, &mempoolHash)
+	if err != nil || tx == nil || tx.TxHash() != mempoolHash {
+		t.Fatalf("mempool transaction not returned. tx = %v, err = %v", tx, err)
+	}
+	unknownTx := tFundingTx(t, contractTestAddr(t), 3e8)
+	unknownHash := unknownTx.TxHash()
+	if tx, err := ps.getTx(ctx, &unknownHash); err != nil || tx != nil {
+		t.Fatalf("unknown transaction returned. tx = %v, err = %v", tx, err)
+	}
+
+	// A published transaction is served to the peer when it asks for it.
+	if err := ps.PublishTransactions(ctx, unknownTx); err != nil {
+		t.Fatalf("PublishTransactions error: %v", err)
+	}
+	select {
+	case tx := <-received:
+		if tx.TxHash() != unknownHash {
+			t.Fatalf("wrong transaction served")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("published transaction not served")
+	}
+
+	// A block with transactions that don't match the header is rejected.
+	bad := *blk
+	bad.Transactions = bad.Transactions[:1]
+	if checkMerkleRoot(&bad) {
+		t.Fatalf("no error for bad merkle root")
+	}
+}
+
+// tServePeer is the remote side of a peer connection, serving the chain and
+// the mempool. The tip is announced once the handshake is complete. Announced
+// transactions are requested, and passed to received.
+func tServePeer(conn net.Conn, src *tBlockSource, received chan<- *wire.MsgTx) {
+	net := tSPVParams.Net
+	send := func(msg wire.Message) error {
+		return wire.WriteMessage(conn, msg, p2p.Pver, net)
+	}
+	for {
+		msg, _, err := wire.ReadMessage(conn, p2p.Pver, net)
+		if err != nil {
+			return
+		}
+		switch m := msg.(type) {
+		case *wire.MsgVersion:
+			me := wire.NewNetAddressIPPort(nil, 0, requiredServices)
+			ver := wire.NewMsgVersion(me, me, 1, int32(len(src.blocks)-1))
+			ver.Services = requiredServices
+			send(ver)
+			send(wire.NewMsgVerAck())
+		case *wire.MsgVerAck:
+			tipHash := src.blocks[len(src.blocks)-1].BlockHash()
+			inv := wire.NewMsgInv()
+			inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &tipHash))
+			send(inv)
+		case *wire.MsgGetHeaders:
+			hdrs, _ := src.Headers(context.Background(), m.BlockLocatorHashes, &m.HashStop)
+			resp := wire.NewMsgHeaders()
+			for _, hdr := range hdrs {
+				resp.AddBlockHeader(hdr)
+			}
+			send(resp)
+		case *wire.MsgGetCFilter:
+			filters, _ := src.CFilters(context.Background(), []*chainhash.Hash{&m.BlockHash})
+			send(wire.NewMsgCFilter(&m.BlockHash, m.FilterType, filters[0].NBytes()))
+		case *wire.MsgGetData:
+			notFound := wire.NewMsgNotFound()
+			for _, iv := range m.InvList {
+				switch iv.Type {
+				case wire.InvTypeBlock:
+					blks, err := src.Blocks(context.Background(), []*chainhash.Hash{&iv.Hash})
+					if err != nil {
+						notFound.AddInvVect(iv)
+						continue
+					}
+					send(blks[0])
+				case wire.InvTypeTx:
+					tx, _ := src.getTx(context.Background(), &iv.Hash)
+					if tx == nil {
+						notFound.AddInvVect(iv)
+						continue
+					}
+					send(tx)
+				}
+			}
+			if len(notFound.InvList) > 0 {
+				send(notFound)
+			}
+		case *wire.MsgInv:
+			getData := wire.NewMsgGetData()
+			for _, iv := range m.InvList {
+				if iv.Type == wire.InvTypeTx {
+					getData.AddInvVect(iv)
+				}
+			}
+			send(getData)
+		case *wire.MsgTx:
+			received <- m
+		}
+	}
+}
@@ -0,0 +1,498 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"github.com/decred/dcrd/addrmgr"
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/p2p/v2"
+	"github.com/decred/dcrwallet/wallet/v3"
+)
+
+const (
+	// targetPeers is the number of peers that the SPV wallet tries to stay
+	// connected to.
+	targetPeers = 4
+	// requiredServices are the services a peer must offer to be useful to the
+	// SPV wallet. SFNodeCF is version 1 committed filter support.
+	requiredServices = wire.SFNodeNetwork | wire.SFNodeCF
+	// cfilterBatch is the most committed filters requested from a peer at
+	// once.
+	cfilterBatch = 200
+	// blockBatch is the most blocks requested from a peer in one getdata.
+	blockBatch = 16
+	// maxAddrPicks is the most times the address manager is asked for a
+	// candidate address in one round of connection attempts.
+	maxAddrPicks = 100
+)
+
+var (
+	// txTimeout is how long to wait for a peer to respond to a request for a
+	// transaction. A peer that doesn't have a transaction may not respond at
+	// all.
+	txTimeout = 5 * time.Second
+	// connectInterval is how often to try connecting new peers when the
+	// wallet has fewer than targetPeers.
+	connectInterval = 5 * time.Second
+	// retryDelay is how long to wait before reconnecting to a peer address.
+	retryDelay = time.Minute
+	// seedInterval is how often to query the DNS seeds when the address
+	// manager needs more addresses.
+	seedInterval = 5 * time.Minute
+	// publishedExpiry is how long a published transaction is served to peers
+	// that request it.
+	publishedExpiry = 10 * time.Minute
+
+	errNoPeers = errors.New("no peers connected")
+)
+
+// blockSource is a source of block headers, committed filters, blocks, and
+// transactions, and a way to broadcast transactions. blockSource is satisfied
+// by *peerSet.
+type blockSource interface {
+	wallet.Peer
+	// peerCount is the number of connected peers.
+	peerCount() int
+	// getTx requests the unconfirmed transaction. getTx returns a nil
+	// transaction if no peer has it.
+	getTx(ctx context.Context, hash *chainhash.Hash) (*wire.MsgTx, error)
+}
+
+// spvPeer is a connected peer, and the height of the best block it is known
+// to have.
+type spvPeer struct {
+	*p2p.RemotePeer
+	lastBlock int64 // atomic
+}
+
+// publishedTx is a transaction sent to peers, and when it was sent.
+type publishedTx struct {
+	tx    *wire.MsgTx
+	stamp time.Time
+}
+
+// peerSet manages the SPV wallet's peer connections, and satisfies
+// blockSource. Connections and the wire protocol are handled by dcrwallet's
+// p2p.LocalPeer. If a static list of peers is configured, only those peers are
+// used. Otherwise, peers are chosen by the address manager, which learns
+// addresses from the network's DNS seeds and the addr messages of connected
+// peers. Requests to peers are handled one at a time.
+type peerSet struct {
+	params   *chaincfg.Params
+	log      dex.Logger
+	static   []string
+	newBlock func()
+	amgr     *addrmgr.AddrManager
+	lp       *p2p.LocalPeer
+
+	mtx      sync.RWMutex
+	peers    map[string]*spvPeer
+	pending  map[string]bool
+	attempts map[string]time.Time
+	lastSeed time.Time
+
+	queryMtx sync.Mutex
+
+	pubMtx    sync.Mutex
+	published map[chainhash.Hash]*publishedTx
+}
+
+// newPeerSet is the constructor for a *peerSet. Known peer addresses are
+// saved in dir. newBlock is called when a peer announces a block.
+func newPeerSet(params *chaincfg.Params, dir string, static []string, newBlock func(), logger dex.Logger) *peerSet {
+	amgr := addrmgr.New(dir, net.LookupIP)
+	return &peerSet{
+		params:    params,
+		log:       logger,
+		static:    static,
+		newBlock:  newBlock,
+		amgr:      amgr,
+		lp:        p2p.NewLocalPeer(params, nil, amgr),
+		peers:     make(map[string]*spvPeer),
+		pending:   make(map[string]bool),
+		attempts:  make(map[string]time.Time),
+		published: make(map[chainhash.Hash]*publishedTx),
+	}
+}
+
+// String describes the peer set for the wallet's log messages.
+func (ps *peerSet) String() string {
+	return fmt.Sprintf("%d %s peer(s)", ps.peerCount(), ps.params.Name)
+}
+
+// run connects peers and handles their block announcements and transaction
+// requests until the context is canceled, at which point the peers are
+// disconnected.
+func (ps *peerSet) run(ctx context.Context) {
+	ps.amgr.Start()
+	defer func() {
+		if err := ps.amgr.Stop(); err != nil {
+			ps.log.Errorf("Error stopping the peer address manager: %v", err)
+		}
+	}()
+	ps.lp.AddHandledMessages(p2p.MaskInv | p2p.MaskGetData)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ps.receiveInvs(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		ps.receiveGetData(ctx)
+	}()
+	ticker := time.NewTicker(connectInterval)
+	defer ticker.Stop()
+	for {
+		ps.connectPeers(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+	}
+}
+
+// connectPeers starts connecting to candidate addresses if there are fewer
+// than targetPeers peers.
+func (ps *peerSet) connectPeers(ctx context.Context) {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+	need := targetPeers - len(ps.peers) - len(ps.pending)
+	if need <= 0 {
+		return
+	}
+	skip := func(addr string) bool {
+		return ps.peers[addr] != nil || ps.pending[addr] || time.Since(ps.attempts[addr]) < retryDelay
+	}
+	var addrs []string
+	if len(ps.static) > 0 {
+		for _, addr := range ps.static {
+			if !skip(addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	} else {
+		if ps.amgr.NeedMoreAddresses() && time.Since(ps.lastSeed) > seedInterval {
+			ps.lastSeed = time.Now()
+			go ps.lp.DNSSeed(requiredServices)
+		}
+		// GetAddress picks at random, favoring addresses that have worked
+		// before.
+		picked := make(map[string]bool)
+		for i := 0; i < maxAddrPicks && len(addrs) < need; i++ {
+			ka := ps.amgr.GetAddress()
+			if ka == nil {
+				break
+			}
+			na := ka.NetAddress()
+			addr := net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port)))
+			if na.Services&requiredServices != requiredServices || picked[addr] || skip(addr) {
+				continue
+			}
+			picked[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	for _, addr := range addrs {
+		if need == 0 {
+			break
+		}
+		need--
+		ps.attempts[addr] = time.Now()
+		ps.pending[addr] = true
+		go ps.connectPeer(ctx, addr)
+	}
+}
+
+// connectPeer connects to the peer at the address, and keeps it in the set
+// until it disconnects.
+func (ps *peerSet) connectPeer(ctx context.Context, addr string) {
+	rp, err := ps.lp.ConnectOutbound(ctx, addr, requiredServices)
+	ps.mtx.Lock()
+	delete(ps.pending, addr)
+	if err != nil {
+		ps.mtx.Unlock()
+		ps.log.Debugf("Failed to connect to peer %s: %v", addr, err)
+		return
+	}
+	ps.peers[addr] = &spvPeer{
+		RemotePeer: rp,
+		lastBlock:  int64(rp.InitialHeight()),
+	}
+	ps.mtx.Unlock()
+	ps.log.Infof("Connected to peer %s (%s), height %d", addr, rp.UA(), rp.InitialHeight())
+	err = rp.Err()
+	ps.mtx.Lock()
+	delete(ps.peers, addr)
+	ps.mtx.Unlock()
+	ps.log.Infof("Disconnected from peer %s: %v", addr, err)
+}
+
+// receiveInvs signals the wallet when a peer announces a block.
+func (ps *peerSet) receiveInvs(ctx context.Context) {
+	for {
+		_, msg, err := ps.lp.ReceiveInv(ctx)
+		if err != nil {
+			return
+		}
+		for _, iv := range msg.InvList {
+			if iv.Type == wire.InvTypeBlock {
+				ps.newBlock()
+				break
+			}
+		}
+	}
+}
+
+// receiveGetData serves published transactions to the peers that request
+// them.
+func (ps *peerSet) receiveGetData(ctx context.Context) {
+	for {
+		rp, msg, err := ps.lp.ReceiveGetData(ctx)
+		if err != nil {
+			return
+		}
+		go ps.serveGetData(ctx, rp, msg)
+	}
+}
+
+// serveGetData sends the requested transactions that have been published.
+// notfound is sent for anything else, since the wallet does not serve blocks.
+func (ps *peerSet) serveGetData(ctx context.Context, rp *p2p.RemotePeer, msg *wire.MsgGetData) {
+	notFound := wire.NewMsgNotFound()
+	for _, iv := range msg.InvList {
+		var tx *wire.MsgTx
+		if iv.Type == wire.InvTypeTx {
+			ps.pubMtx.Lock()
+			if pub := ps.published[iv.Hash]; pub != nil {
+				tx = pub.tx
+			}
+			ps.pubMtx.Unlock()
+		}
+		if tx == nil {
+			notFound.AddInvVect(iv)
+			continue
+		}
+		if err := rp.SendMessage(ctx, tx); err != nil {
+			ps.log.Debugf("Failed to send transaction %s to peer %s: %v", iv.Hash, rp, err)
+			return
+		}
+	}
+	if len(notFound.InvList) > 0 {
+		if err := rp.SendMessage(ctx, notFound); err != nil {
+			ps.log.Debugf("Failed to send notfound to peer %s: %v", rp, err)
+		}
+	}
+}
+
+// sortedPeers is the connected peers, with the peer with the best block
+// first.
+func (ps *peerSet) sortedPeers() []*spvPeer {
+	ps.mtx.RLock()
+	peers := make([]*spvPeer, 0, len(ps.peers))
+	for _, sp := range ps.peers {
+		peers = append(peers, sp)
+	}
+	ps.mtx.RUnlock()
+	sort.Slice(peers, func(i, j int) bool {
+		return atomic.LoadInt64(&peers[i].lastBlock) > atomic.LoadInt64(&peers[j].lastBlock)
+	})
+	return peers
+}
+
+// peerCount is the number of connected peers. Part of the blockSource
+// interface.
+func (ps *peerSet) peerCount() int {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+	return len(ps.peers)
+}
+
+// Headers requests headers from the peer with the best block. Part of the
+// wallet.Peer interface.
+func (ps *peerSet) Headers(ctx context.Context, locators []*chainhash.Hash, hashStop *chainhash.Hash) ([]*wire.BlockHeader, error) {
+	ps.queryMtx.Lock()
+	defer ps.queryMtx.Unlock()
+	peers := ps.sortedPeers()
+	if len(peers) == 0 {
+		return nil, errNoPeers
+	}
+	sp := peers[0]
+	hdrs, err := sp.Headers(ctx, locators, hashStop)
+	if err != nil {
+		return nil, err
+	}
+	if len(hdrs) > 0 {
+		height := int64(hdrs[len(hdrs)-1].Height)
+		if height > atomic.LoadInt64(&sp.lastBlock) {
+			atomic.StoreInt64(&sp.lastBlock, height)
+		}
+	}
+	return hdrs, nil
+}
+
+// CFilters requests the regular committed filters for the blocks, in batches
+// of up to cfilterBatch. Each batch is requested from each peer in turn until
+// one responds with all of the filters. Part of the wallet.Peer interface.
+func (ps *peerSet) CFilters(ctx context.Context, blockHashes []*chainhash.Hash) ([]*gcs.Filter, error) {
+	ps.queryMtx.Lock()
+	defer ps.queryMtx.Unlock()
+	filters := make([]*gcs.Filter, 0, len(blockHashes))
+	for len(filters) < len(blockHashes) {
+		end := len(filters) + cfilterBatch
+		if end > len(blockHashes) {
+			end = len(blockHashes)
+		}
+		batch := blockHashes[len(filters):end]
+		var batchFilters []*gcs.Filter
+		for _, sp := range ps.sortedPeers() {
+			fs, err := sp.CFilters(ctx, batch)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				ps.log.Debugf("Peer %s failed to send filters: %v", sp, err)
+				continue
+			}
+			batchFilters = fs
+			break
+		}
+		if batchFilters == nil {
+			return nil, fmt.Errorf("no peer responded with filters for %d blocks starting at %s", len(batch), batch[0])
+		}
+		filters = append(filters, batchFilters...)
+	}
+	return filters, nil
+}
+
+// Blocks requests the blocks, in batches of up to blockBatch. Each batch is
+// requested from each peer in turn until one responds with all of the blocks.
+// The merkle roots of the blocks are checked against their headers. Part of
+// the wallet.Peer interface.
+func (ps *peerSet) Blocks(ctx context.Context, blockHashes []*chainhash.Hash) ([]*wire.MsgBlock, error) {
+	ps.queryMtx.Lock()
+	defer ps.queryMtx.Unlock()
+	blocks := make([]*wire.MsgBlock, 0, len(blockHashes))
+	for len(blocks) < len(blockHashes) {
+		end := len(blocks) + blockBatch
+		if end > len(blockHashes) {
+			end = len(blockHashes)
+		}
+		batch := blockHashes[len(blocks):end]
+		var batchBlocks []*wire.MsgBlock
+	peers:
+		for _, sp := range ps.sortedPeers() {
+			blks, err := sp.Blocks(ctx, batch)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				ps.log.Debugf("Peer %s failed to send blocks: %v", sp, err)
+				continue
+			}
+			for _, blk := range blks {
+				if !checkMerkleRoot(blk) {
+					err := fmt.Errorf("block %s has an invalid merkle root", blk.BlockHash())
+					ps.log.Debugf("Peer %s sent a bad block: %v", sp, err)
+					sp.Disconnect(err)
+					continue peers
+				}
+			}
+			batchBlocks = blks
+			break
+		}
+		if batchBlocks == nil {
+			return nil, fmt.Errorf("no peer responded with %d blocks starting at %s", len(batch), batch[0])
+		}
+		blocks = append(blocks, batchBlocks...)
+	}
+	return blocks, nil
+}
+
+// checkMerkleRoot checks the block's transactions against the merkle root in
+// its header. Depending on the agenda status of the header commitments
+// consensus change, the merkle root commits to either the regular transaction
+// tree or both transaction trees.
+func checkMerkleRoot(blk *wire.MsgBlock) bool {
+	root := blk.Header.MerkleRoot
+	return standalone.CalcTxTreeMerkleRoot(blk.Transactions) == root ||
+		standalone.CalcCombinedTxTreeMerkleRoot(blk.Transactions, blk.STransactions) == root
+}
+
+// getTx requests the transaction from each peer in turn until one responds
+// with it. Peers will only have the transaction if it is in their mempool.
+// Part of the blockSource interface.
+func (ps *peerSet) getTx(ctx context.Context, hash *chainhash.Hash) (*wire.MsgTx, error) {
+	ps.queryMtx.Lock()
+	defer ps.queryMtx.Unlock()
+	peers := ps.sortedPeers()
+	if len(peers) == 0 {
+		return nil, errNoPeers
+	}
+	for _, sp := range peers {
+		txCtx, cancel := context.WithTimeout(ctx, txTimeout)
+		txs, err := sp.Transactions(txCtx, []*chainhash.Hash{hash})
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// A notfound response is an error with a nil transaction.
+		if len(txs) == 1 && txs[0] != nil {
+			return txs[0], nil
+		}
+		if err != nil {
+			ps.log.Tracef("Peer %s did not send transaction %s: %v", sp, hash, err)
+		}
+	}
+	return nil, nil
+}
+
+// PublishTransactions announces the transactions to all peers, and serves
+// them to the peers that request them. Part of the wallet.Peer interface.
+func (ps *peerSet) PublishTransactions(ctx context.Context, txs ...*wire.MsgTx) error {
+	ps.pubMtx.Lock()
+	for txHash, pub := range ps.published {
+		if time.Since(pub.stamp) > publishedExpiry {
+			delete(ps.published, txHash)
+		}
+	}
+	now := time.Now()
+	for _, tx := range txs {
+		ps.published[tx.TxHash()] = &publishedTx{tx: tx, stamp: now}
+	}
+	ps.pubMtx.Unlock()
+	peers := ps.sortedPeers()
+	if len(peers) == 0 {
+		return errNoPeers
+	}
+	var sent bool
+	for _, sp := range peers {
+		if err := sp.PublishTransactions(ctx, txs...); err != nil {
+			ps.log.Debugf("Failed to announce %d transaction(s) to peer %s: %v", len(txs), sp, err)
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return fmt.Errorf("failed to send transactions to any peer")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package asset
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"decred.org/dcrdex/dex"
+)
+
+// UnsupportedError is returned by a Device for an operation that the device
+// cannot perform.
+const UnsupportedError = dex.ErrorKind("unsupported by device")
+
+// Device prompt kinds.
+const (
+	// PromptConfirm asks the user to review and confirm an operation on the
+	// device. No response is needed.
+	PromptConfirm = "confirm"
+	// PromptPIN asks the user for the device's PIN.
+	PromptPIN = "pin"
+	// PromptPassphrase asks the user for the device's passphrase.
+	PromptPassphrase = "passphrase"
+)
+
+// DevicePrompt is a request for the user to interact with a hardware wallet.
+type DevicePrompt struct {
+	// Kind is one of PromptConfirm, PromptPIN or PromptPassphrase.
+	Kind string
+	// Device is the device's display name.
+	Device string
+	// Message describes what the user is being asked to do.
+	Message string
+}
+
+// Prompter presents a DevicePrompt to the user. For a PromptConfirm, Prompter
+// returns immediately. Otherwise, Prompter blocks until the user responds, and
+// returns the response.
+type Prompter func(*DevicePrompt) (string, error)
+
+// SignInput is a transaction input to be signed by a Device.
+type SignInput struct {
+	// Index is the input's index in the transaction.
+	Index uint32
+	// Path is the derivation path of the signing key.
+	Path []uint32
+	// Script is the script being satisfied, i.e. the previous output's
+	// pkScript or the swap contract.
+	Script []byte
+	// Value is the value of the previous output.
+	Value uint64
+	// Witness is true if the input spends a segwit output.
+	Witness bool
+}
+
+// SignTxRequest is a request for a Device to sign transaction inputs.
+type SignTxRequest struct {
+	AssetID uint32
+	// Tx is the serialized unsigned transaction.
+	Tx []byte
+	// Inputs are the inputs to sign.
+	Inputs []*SignInput
+	// Summary is a description of the transaction for the confirmation
+	// prompt, e.g. "Redeem 2 swaps".
+	Summary string
+}
+
+// Device is a connected hardware wallet that holds a wallet's keys. Signing
+// may block while the user confirms on the device.
+type Device interface {
+	// Name is the device's display name, e.g. "Ledger Nano S".
+	Name() string
+	// ExtendedPubKey is the serialized extended public key at the path.
+	ExtendedPubKey(assetID uint32, path []uint32) (string, error)
+	// SignTx signs the requested inputs, returning a DER-encoded signature
+	// with the sighash type appended for each input, in order.
+	SignTx(req *SignTxRequest) ([][]byte, error)
+	// SignHash signs the hash with the key at the path, returning a
+	// DER-encoded signature. A device that cannot sign arbitrary data returns
+	// UnsupportedError.
+	SignHash(assetID uint32, path []uint32, hash []byte) ([]byte, error)
+	// Close disconnects from the device.
+	Close() error
+}
+
+// DeviceDriver opens a type of hardware wallet.
+type DeviceDriver interface {
+	// Open connects to the device. The Prompter is used for any interaction
+	// with the user for as long as the Device is open.
+	Open(settings map[string]string, prompt Prompter) (Device, error)
+}
+
+var (
+	deviceDriversMtx sync.RWMutex
+	deviceDrivers    = make(map[string]DeviceDriver)
+)
+
+// RegisterDevice should be called by the init function of a hardware wallet's
+// package.
+func RegisterDevice(name string, driver DeviceDriver) {
+	deviceDriversMtx.Lock()
+	defer deviceDriversMtx.Unlock()
+	if driver == nil {
+		panic("asset: RegisterDevice driver is nil")
+	}
+	if _, dup := deviceDrivers[name]; dup {
+		panic(fmt.Sprint("asset: RegisterDevice called twice for device ", name))
+	}
+	deviceDrivers[name] = driver
+}
+
+// OpenDevice connects to a hardware wallet with a registered driver.
+func OpenDevice(name string, settings map[string]string, prompt Prompter) (Device, error) {
+	deviceDriversMtx.RLock()
+	drv, ok := deviceDrivers[name]
+	deviceDriversMtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("asset: unknown hardware wallet %q", name)
+	}
+	return drv.Open(settings, prompt)
+}
+
+// DeviceNames returns the sorted names of the registered hardware wallets.
+func DeviceNames() []string {
+	deviceDriversMtx.RLock()
+	defer deviceDriversMtx.RUnlock()
+	names := make([]string, 0, len(deviceDrivers))
+	for name := range deviceDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	dexeth "decred.org/dcrdex/dex/networks/eth"
+)
+
+const (
+	defaultRPCAddr = "http://127.0.0.1:8545"
+	// defaultGasPriceLimit is the default highest gas price, in gwei, paid
+	// for redemptions, refunds, and withdrawals.
+	defaultGasPriceLimit = 200
+	tokenAddressKey      = "tokenaddress"
+	unlimitedApprovalKey = "unlimitedapproval"
+)
+
+// Config holds the parameters needed to use an Ethereum node's accounts over
+// HTTP JSON-RPC. The node must have the personal API enabled.
+type Config struct {
+	RPCAddr       string `ini:"rpcaddr, JSON-RPC Address, The node's HTTP JSON-RPC URL (default http://127.0.0.1:8545)"`
+	Account       string `ini:"account, Account, The node account to trade with (default: the node's first account)"`
+	SwapContract  string `ini:"swapcontract, Swap Contract, The swap contract address (required if the DEX contract is not known for the network)"`
+	GasPriceLimit uint64 `ini:"gaspricelimit, Gas Price Limit, The highest gas price in gwei to pay for redemptions, refunds, and withdrawals (default 200)"`
+}
+
+// TokenAddressOption is the wallet setting for an ERC-20 token's contract
+// address, which is required if the token is not known for the network.
+var TokenAddressOption = &config.Option{
+	Key:         tokenAddressKey,
+	DisplayName: "Token Address",
+	Description: "The token contract address (required if the token is not known for the network)",
+}
+
+// UnlimitedApprovalOption is the wallet setting that allows the swap contract
+// to transfer any amount of an ERC-20 token, so that only the first swap needs
+// an approve transaction. By default, each swap approves only its own value.
+var UnlimitedApprovalOption = &config.Option{
+	Key:         unlimitedApprovalKey,
+	DisplayName: "Unlimited Approval",
+	Description: "Allow the swap contract to transfer any amount of the token instead of approving each swap's value (default: false)",
+}
+
+// loadConfig loads the Config from a settings map. defaultContract is the
+// swap contract's address for the network, if known.
+func loadConfig(settings map[string]string, defaultContract string) (*Config, error) {
+	cfg := new(Config)
+	if err := config.Unmapify(settings, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config: %v", err)
+	}
+	if cfg.RPCAddr == "" {
+		cfg.RPCAddr = defaultRPCAddr
+	}
+	if !strings.HasPrefix(cfg.RPCAddr, "http://") && !strings.HasPrefix(cfg.RPCAddr, "https://") {
+		cfg.RPCAddr = "http://" + cfg.RPCAddr
+	}
+	if cfg.SwapContract == "" {
+		cfg.SwapContract = defaultContract
+	}
+	if cfg.SwapContract == "" {
+		return nil, fmt.Errorf("no swap contract address")
+	}
+	if _, err := dexeth.HexToAddress(cfg.SwapContract); err != nil {
+		return nil, fmt.Errorf("invalid swap contract address: %v", err)
+	}
+	if cfg.Account != "" {
+		if _, err := dexeth.HexToAddress(cfg.Account); err != nil {
+			return nil, fmt.Errorf("invalid account address: %v", err)
+		}
+	}
+	if cfg.GasPriceLimit == 0 {
+		cfg.GasPriceLimit = defaultGasPriceLimit
+	}
+	return cfg, nil
+}
+
+// tokenAddress gets the token's contract address from the settings, or the
+// known address for the network.
+func tokenAddress(settings map[string]string, token *dexeth.Token, network dex.Network) (dexeth.Address, error) {
+	s := settings[tokenAddressKey]
+	if s == "" {
+		s = token.Addresses[network]
+	}
+	if s == "" {
+		return dexeth.Address{}, fmt.Errorf("no %s contract address for network %s", token.Name, network)
+	}
+	addr, err := dexeth.HexToAddress(s)
+	if err != nil {
+		return addr, fmt.Errorf("invalid token address: %v", err)
+	}
+	return addr, nil
+}
+
+// unlimitedApproval parses the UnlimitedApprovalOption setting.
+func unlimitedApproval(settings map[string]string) (bool, error) {
+	s := settings[unlimitedApprovalKey]
+	if s == "" {
+		return false, nil
+	}
+	unlimited, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s setting %q: %v", unlimitedApprovalKey, s, err)
+	}
+	return unlimited, nil
+}
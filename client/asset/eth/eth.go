@@ -0,0 +1,1116 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	dexeth "decred.org/dcrdex/dex/networks/eth"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+const (
+	// BipID is the BIP-0044 asset ID.
+	BipID = dexeth.BipID
+	// defaultGasPrice is the fallback gas price in gwei, passed to the user as
+	// part of the asset.WalletInfo structure.
+	defaultGasPrice = 20
+
+	methodAccounts      = "eth_accounts"
+	methodBlockNumber   = "eth_blockNumber"
+	methodGetBlock      = "eth_getBlockByNumber"
+	methodGetBalance    = "eth_getBalance"
+	methodGasPrice      = "eth_gasPrice"
+	methodCall          = "eth_call"
+	methodSendTx        = "eth_sendTransaction"
+	methodGetTx         = "eth_getTransactionByHash"
+	methodGetReceipt    = "eth_getTransactionReceipt"
+	methodSign          = "eth_sign"
+	methodUnlockAccount = "personal_unlockAccount"
+	methodLockAccount   = "personal_lockAccount"
+)
+
+var (
+	// blockTicker is the delay between calls to check for new blocks.
+	blockTicker = 5 * time.Second
+	// walletInfo defines some general information about an Ethereum wallet.
+	walletInfo = &asset.WalletInfo{
+		Name:           "Ethereum",
+		Units:          "gwei",
+		ConfigOpts:     config.Options(&Config{}),
+		DefaultFeeRate: defaultGasPrice,
+	}
+	zero = new(big.Int)
+)
+
+func init() {
+	asset.Register(BipID, &Driver{})
+}
+
+// Driver implements asset.Driver.
+type Driver struct{}
+
+// Setup creates the ETH exchange wallet. Start the wallet with its Run method.
+func (d *Driver) Setup(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
+	return NewWallet(cfg, logger, network)
+}
+
+// DecodeCoinID creates a human-readable representation of a coin ID for
+// Ethereum.
+func (d *Driver) DecodeCoinID(coinID []byte) (string, error) {
+	return dexeth.CoinIDString(coinID)
+}
+
+// Info returns basic information about the wallet and asset.
+func (d *Driver) Info() *asset.WalletInfo {
+	return walletInfo
+}
+
+// fundingCoin is an amount reserved from the account balance for an order.
+// fundingCoin satisfies the asset.Coin interface.
+type fundingCoin struct {
+	addr  dexeth.Address
+	value uint64
+}
+
+// ID is the funding coin ID. Part of the asset.Coin interface.
+func (c *fundingCoin) ID() dex.Bytes {
+	return dexeth.EncodeFundingCoinID(c.addr, c.value)
+}
+
+// String is a string representation of the coin. Part of the asset.Coin
+// interface.
+func (c *fundingCoin) String() string {
+	return fmt.Sprintf("%s:%d", c.addr, c.value)
+}
+
+// Value is the reserved amount. Part of the asset.Coin interface.
+func (c *fundingCoin) Value() uint64 {
+	return c.value
+}
+
+// Confirmations is always zero for a funding coin, which is not a
+// transaction. Part of the asset.Coin interface.
+func (c *fundingCoin) Confirmations() (uint32, error) {
+	return 0, nil
+}
+
+// Redeem is always nil for a funding coin. Part of the asset.Coin interface.
+func (c *fundingCoin) Redeem() dex.Bytes {
+	return nil
+}
+
+// swapCoin is a swap in an initiate transaction. swapCoin satisfies the
+// asset.Coin interface.
+type swapCoin struct {
+	w          *ExchangeWallet
+	txHash     [32]byte
+	secretHash [32]byte
+	value      uint64
+}
+
+// ID is the swap coin ID. Part of the asset.Coin interface.
+func (c *swapCoin) ID() dex.Bytes {
+	return dexeth.EncodeSwapCoinID(c.txHash, c.secretHash)
+}
+
+// String is a string representation of the coin. Part of the asset.Coin
+// interface.
+func (c *swapCoin) String() string {
+	return fmt.Sprintf("%x:%x", c.txHash, c.secretHash)
+}
+
+// Value is the swap value. Part of the asset.Coin interface.
+func (c *swapCoin) Value() uint64 {
+	return c.value
+}
+
+// Confirmations is the number of confirmations on the initiate transaction.
+// An error is returned if the swap has been redeemed or refunded. Part of the
+// asset.Coin interface.
+func (c *swapCoin) Confirmations() (uint32, error) {
+	state, err := c.w.swapState(c.secretHash)
+	if err != nil {
+		return 0, err
+	}
+	switch state.State {
+	case dexeth.SSInitiated:
+	case dexeth.SSNone:
+		// The initiation may be pending.
+	default:
+		return 0, fmt.Errorf("swap %x has been %s", c.secretHash, state.State)
+	}
+	return c.w.txConfirmations(c.txHash)
+}
+
+// Redeem is the swap's secret hash, which identifies the swap in the
+// contract. Part of the asset.Coin interface.
+func (c *swapCoin) Redeem() dex.Bytes {
+	return c.secretHash[:]
+}
+
+// txCoin is the output of a redeem, refund, or send transaction. txCoin
+// satisfies the asset.Coin interface.
+type txCoin struct {
+	w      *ExchangeWallet
+	txHash [32]byte
+	value  uint64
+}
+
+// ID is the transaction hash. Part of the asset.Coin interface.
+func (c *txCoin) ID() dex.Bytes {
+	return c.txHash[:]
+}
+
+// String is a string representation of the coin. Part of the asset.Coin
+// interface.
+func (c *txCoin) String() string {
+	return fmt.Sprintf("%x", c.txHash)
+}
+
+// Value is the transaction's value. Part of the asset.Coin interface.
+func (c *txCoin) Value() uint64 {
+	return c.value
+}
+
+// Confirmations is the number of confirmations on the transaction. Part of
+// the asset.Coin interface.
+func (c *txCoin) Confirmations() (uint32, error) {
+	return c.w.txConfirmations(c.txHash)
+}
+
+// Redeem is always nil for a txCoin. Part of the asset.Coin interface.
+func (c *txCoin) Redeem() dex.Bytes {
+	return nil
+}
+
+// auditInfo is information about a swap contract on the blockchain.
+// auditInfo satisfies the asset.AuditInfo interface.
+type auditInfo struct {
+	recipient  dexeth.Address
+	expiration time.Time
+	coin       *swapCoin
+}
+
+// Recipient is the participant's address. Part of the asset.AuditInfo
+// interface.
+func (ci *auditInfo) Recipient() string {
+	return ci.recipient.String()
+}
+
+// Expiration is the time after which the initiator can refund. Part of the
+// asset.AuditInfo interface.
+func (ci *auditInfo) Expiration() time.Time {
+	return ci.expiration
+}
+
+// Coin is the swap coin. Part of the asset.AuditInfo interface.
+func (ci *auditInfo) Coin() asset.Coin {
+	return ci.coin
+}
+
+// SecretHash is the swap's secret hash. Part of the asset.AuditInfo
+// interface.
+func (ci *auditInfo) SecretHash() dex.Bytes {
+	return ci.coin.secretHash[:]
+}
+
+// swapReceipt is information about a sent swap. swapReceipt satisfies the
+// asset.Receipt interface.
+type swapReceipt struct {
+	coin       *swapCoin
+	expiration time.Time
+}
+
+// Expiration is the time after which the swap can be refunded. Part of the
+// asset.Receipt interface.
+func (r *swapReceipt) Expiration() time.Time {
+	return r.expiration
+}
+
+// Coin is the swap coin. Part of the asset.Receipt interface.
+func (r *swapReceipt) Coin() asset.Coin {
+	return r.coin
+}
+
+// String provides a human-readable representation of the swap. Part of the
+// asset.Receipt interface.
+func (r *swapReceipt) String() string {
+	return r.coin.String()
+}
+
+// ExchangeWallet is a wallet backend for Ethereum and ERC-20 tokens that uses
+// an account of an Ethereum node. The node signs the transactions. Ethereum
+// has no UTXOs, so funding an order reserves an amount of the account balance
+// that is tracked by the wallet. The exchange wallet satisfies the
+// dex.Connector and asset.Wallet interfaces.
+type ExchangeWallet struct {
+	node          ethNode
+	log           dex.Logger
+	tipChange     func(error)
+	info          *asset.WalletInfo
+	acctSetting   string
+	contract      dexeth.Address
+	gases         *dexeth.Gases
+	unitsPerAtom  *big.Int
+	gasPriceLimit uint64
+	// token is nil for the ETH wallet.
+	token     *dexeth.Token
+	tokenAddr dexeth.Address
+	// unlimitedApproval is set if token approvals are for any amount rather
+	// than the value of the swap.
+	unlimitedApproval bool
+
+	acctMtx sync.RWMutex
+	acct    dexeth.Address
+
+	// fundMtx guards locked, which is the sum of the unspent funding coins.
+	fundMtx sync.Mutex
+	locked  uint64
+}
+
+// Check that ExchangeWallet satisfies the asset.Wallet interface.
+var _ asset.Wallet = (*ExchangeWallet)(nil)
+
+// NewWallet is the exchange wallet constructor.
+func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
+	ethCfg, err := loadConfig(cfg.Settings, dexeth.ContractAddresses[network])
+	if err != nil {
+		return nil, err
+	}
+	w := newWallet(cfg, ethCfg, newRPCNode(ethCfg.RPCAddr), logger)
+	w.info = walletInfo
+	w.gases = dexeth.ETHGases
+	w.unitsPerAtom = dexeth.WeiPerGwei
+	return w, nil
+}
+
+// newWallet creates the ExchangeWallet with the node and settings that are
+// common to ETH and token wallets.
+func newWallet(cfg *asset.WalletConfig, ethCfg *Config, node ethNode, logger dex.Logger) *ExchangeWallet {
+	// The config has already been checked.
+	contract, _ := dexeth.HexToAddress(ethCfg.SwapContract)
+	return &ExchangeWallet{
+		node:          node,
+		log:           logger,
+		tipChange:     cfg.TipChange,
+		acctSetting:   ethCfg.Account,
+		contract:      contract,
+		gasPriceLimit: ethCfg.GasPriceLimit,
+	}
+}
+
+// Info returns basic information about the wallet and asset.
+func (w *ExchangeWallet) Info() *asset.WalletInfo {
+	return w.info
+}
+
+// Connect selects the node account and starts the block monitoring loop.
+// Satisfies the dex.Connector interface.
+func (w *ExchangeWallet) Connect(ctx context.Context) (*sync.WaitGroup, error) {
+	var accts []string
+	if err := w.node.call(&accts, methodAccounts); err != nil {
+		return nil, fmt.Errorf("error listing node accounts: %v", err)
+	}
+	if len(accts) == 0 {
+		return nil, fmt.Errorf("the node has no accounts")
+	}
+	acctStr := accts[0]
+	if w.acctSetting != "" {
+		acctStr = ""
+		for _, a := range accts {
+			if strings.EqualFold(a, w.acctSetting) {
+				acctStr = a
+				break
+			}
+		}
+		if acctStr == "" {
+			return nil, fmt.Errorf("account %s not found in the node", w.acctSetting)
+		}
+	}
+	acct, err := dexeth.HexToAddress(acctStr)
+	if err != nil {
+		return nil, fmt.Errorf("node account error: %v", err)
+	}
+	w.acctMtx.Lock()
+	w.acct = acct
+	w.acctMtx.Unlock()
+
+	tip, err := w.blockNumber()
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.run(ctx, tip)
+	}()
+	return &wg, nil
+}
+
+// run checks for new blocks until the context is canceled.
+func (w *ExchangeWallet) run(ctx context.Context, tip uint64) {
+	ticker := time.NewTicker(blockTicker)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			height, err := w.blockNumber()
+			if err != nil {
+				w.tipChange(fmt.Errorf("failed to get best block from the Ethereum node: %v", err))
+				continue
+			}
+			if height != tip {
+				tip = height
+				w.tipChange(nil)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// account is the node account used by the wallet.
+func (w *ExchangeWallet) account() dexeth.Address {
+	w.acctMtx.RLock()
+	defer w.acctMtx.RUnlock()
+	return w.acct
+}
+
+// Balance returns the total available funds in the account, which includes
+// pending transactions. Locked is the amount reserved by funding coins.
+func (w *ExchangeWallet) Balance() (*asset.Balance, error) {
+	bal, err := w.balance()
+	if err != nil {
+		return nil, err
+	}
+	w.fundMtx.Lock()
+	locked := w.locked
+	w.fundMtx.Unlock()
+	var avail uint64
+	if bal > locked {
+		avail = bal - locked
+	}
+	return &asset.Balance{
+		Available: avail,
+		Locked:    locked,
+	}, nil
+}
+
+// FundOrder reserves funds for an order. For ETH, the reserved amount includes
+// the gas for the maximum number of swaps at the DEX's maximum fee rate. A
+// token wallet requires enough ETH in the account for the gas, but does not
+// reserve it.
+func (w *ExchangeWallet) FundOrder(value uint64, nfo *dex.Asset) (asset.Coins, error) {
+	if value == 0 {
+		return nil, fmt.Errorf("cannot fund value = 0")
+	}
+	if nfo.LotSize == 0 {
+		return nil, fmt.Errorf("invalid lot size 0")
+	}
+	maxSwaps := value / nfo.LotSize
+	if maxSwaps == 0 {
+		maxSwaps = 1
+	}
+	maxFees := maxSwaps * nfo.SwapSize * nfo.MaxFeeRate
+
+	required := value
+	if w.token == nil {
+		required += maxFees
+	} else {
+		if w.approvalNeeded(value) {
+			maxFees += dexeth.ApproveGas * nfo.MaxFeeRate
+		}
+		if err := w.checkGas(maxFees); err != nil {
+			return nil, err
+		}
+	}
+
+	bal, err := w.balance()
+	if err != nil {
+		return nil, err
+	}
+	w.fundMtx.Lock()
+	defer w.fundMtx.Unlock()
+	if bal < w.locked || bal-w.locked < required {
+		return nil, fmt.Errorf("insufficient funds. %d requested, %d available", required, availableBal(bal, w.locked))
+	}
+	w.locked += required
+	return asset.Coins{&fundingCoin{addr: w.account(), value: required}}, nil
+}
+
+// availableBal is the balance that is not locked.
+func availableBal(bal, locked uint64) uint64 {
+	if bal < locked {
+		return 0
+	}
+	return bal - locked
+}
+
+// unlock releases funds reserved by funding coins. The fundMtx MUST be
+// locked.
+func (w *ExchangeWallet) unlock(value uint64) {
+	if value > w.locked {
+		w.log.Errorf("Attempted to unlock %d with only %d locked", value, w.locked)
+		value = w.locked
+	}
+	w.locked -= value
+}
+
+// decodeFundingCoins checks that the coins are funding coins for the wallet's
+// account, and returns their total value.
+func (w *ExchangeWallet) decodeFundingCoins(ids []dex.Bytes) (uint64, error) {
+	acct := w.account()
+	var total uint64
+	for _, id := range ids {
+		addr, value, err := dexeth.DecodeFundingCoinID(id)
+		if err != nil {
+			return 0, err
+		}
+		if addr != acct {
+			return 0, fmt.Errorf("funding coin address %s is not the wallet's account %s", addr, acct)
+		}
+		total += value
+	}
+	return total, nil
+}
+
+// coinIDs is the IDs of the coins.
+func coinIDs(coins asset.Coins) []dex.Bytes {
+	ids := make([]dex.Bytes, 0, len(coins))
+	for _, c := range coins {
+		ids = append(ids, c.ID())
+	}
+	return ids
+}
+
+// ReturnCoins releases the funds reserved by funding coins.
+func (w *ExchangeWallet) ReturnCoins(coins asset.Coins) error {
+	total, err := w.decodeFundingCoins(coinIDs(coins))
+	if err != nil {
+		return err
+	}
+	w.fundMtx.Lock()
+	w.unlock(total)
+	w.fundMtx.Unlock()
+	return nil
+}
+
+// FundingCoins reserves funds for funding coins created in a previous
+// session.
+func (w *ExchangeWallet) FundingCoins(ids []dex.Bytes) (asset.Coins, error) {
+	total, err := w.decodeFundingCoins(ids)
+	if err != nil {
+		return nil, err
+	}
+	bal, err := w.balance()
+	if err != nil {
+		return nil, err
+	}
+	w.fundMtx.Lock()
+	defer w.fundMtx.Unlock()
+	if bal < w.locked || bal-w.locked < total {
+		return nil, fmt.Errorf("insufficient funds for funding coins. %d requested, %d available", total, availableBal(bal, w.locked))
+	}
+	w.locked += total
+	coins := make(asset.Coins, 0, len(ids))
+	acct := w.account()
+	for _, id := range ids {
+		_, value, _ := dexeth.DecodeFundingCoinID(id)
+		coins = append(coins, &fundingCoin{addr: acct, value: value})
+	}
+	return coins, nil
+}
+
+// Unlock unlocks the node account.
+func (w *ExchangeWallet) Unlock(pw string, dur time.Duration) error {
+	return w.node.call(nil, methodUnlockAccount, w.account().String(), pw, uint64(dur/time.Second))
+}
+
+// Lock locks the node account.
+func (w *ExchangeWallet) Lock() error {
+	return w.node.call(nil, methodLockAccount, w.account().String())
+}
+
+// Swap sends the swaps in a single initiate transaction. The gas price is the
+// DEX's fee rate. The change coin is a funding coin for the remainder of the
+// inputs.
+func (w *ExchangeWallet) Swap(swaps *asset.Swaps) ([]asset.Receipt, asset.Coin, error) {
+	totalIn, err := w.decodeFundingCoins(coinIDs(swaps.Inputs))
+	if err != nil {
+		return nil, nil, err
+	}
+	inits := make([]*dexeth.Initiation, 0, len(swaps.Contracts))
+	var totalValue uint64
+	for _, contract := range swaps.Contracts {
+		participant, err := dexeth.HexToAddress(contract.Address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid participant address: %v", err)
+		}
+		if len(contract.SecretHash) != 32 {
+			return nil, nil, fmt.Errorf("invalid secret hash length %d", len(contract.SecretHash))
+		}
+		init := &dexeth.Initiation{
+			RefundTimestamp: contract.LockTime,
+			Participant:     participant,
+			Value:           dexeth.FromAtoms(contract.Value, w.unitsPerAtom),
+		}
+		copy(init.SecretHash[:], contract.SecretHash)
+		inits = append(inits, init)
+		totalValue += contract.Value
+	}
+	if len(inits) == 0 {
+		return nil, nil, fmt.Errorf("no contracts")
+	}
+
+	gas := w.gases.Swap * uint64(len(inits))
+	fees := gas * swaps.FeeRate
+	spent := totalValue
+	txValue := zero
+	if w.token == nil {
+		spent += fees
+		txValue = dexeth.FromAtoms(totalValue, w.unitsPerAtom)
+	}
+	if totalIn < spent {
+		return nil, nil, fmt.Errorf("unfunded contract. %d < %d", totalIn, spent)
+	}
+	if w.token != nil {
+		if err := w.approve(totalValue, swaps.FeeRate); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	txHash, err := w.sendTx(w.contract, txValue, gas, swaps.FeeRate, dexeth.PackInitiateData(inits))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error sending initiate transaction: %v", err)
+	}
+
+	receipts := make([]asset.Receipt, 0, len(inits))
+	for i, init := range inits {
+		receipts = append(receipts, &swapReceipt{
+			coin: &swapCoin{
+				w:          w,
+				txHash:     txHash,
+				secretHash: init.SecretHash,
+				value:      swaps.Contracts[i].Value,
+			},
+			expiration: time.Unix(int64(init.RefundTimestamp), 0).UTC(),
+		})
+	}
+	change := totalIn - spent
+	w.fundMtx.Lock()
+	w.unlock(spent)
+	w.fundMtx.Unlock()
+	return receipts, &fundingCoin{addr: w.account(), value: change}, nil
+}
+
+// Redeem redeems the swaps in a single redeem transaction. The input coin IDs
+// are the swap coin IDs, and the output Coin is the redeem transaction.
+func (w *ExchangeWallet) Redeem(redemptions []*asset.Redemption) ([]dex.Bytes, asset.Coin, error) {
+	redeems := make([]*dexeth.Redemption, 0, len(redemptions))
+	ids := make([]dex.Bytes, 0, len(redemptions))
+	var total uint64
+	for _, r := range redemptions {
+		coin := r.Spends.Coin()
+		_, secretHash, err := dexeth.DecodeSwapCoinID(coin.ID())
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(r.Secret) != 32 || !w.ValidateSecret(r.Secret, secretHash[:]) {
+			return nil, nil, fmt.Errorf("invalid secret for swap %x", secretHash)
+		}
+		redeem := &dexeth.Redemption{SecretHash: secretHash}
+		copy(redeem.Secret[:], r.Secret)
+		redeems = append(redeems, redeem)
+		ids = append(ids, coin.ID())
+		total += coin.Value()
+	}
+	if len(redeems) == 0 {
+		return nil, nil, fmt.Errorf("no redemptions")
+	}
+	gasPrice, err := w.gasPrice()
+	if err != nil {
+		return nil, nil, err
+	}
+	txHash, err := w.sendTx(w.contract, zero, w.gases.Redeem*uint64(len(redeems)), gasPrice, dexeth.PackRedeemData(redeems))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error sending redeem transaction: %v", err)
+	}
+	return ids, &txCoin{w: w, txHash: txHash, value: total}, nil
+}
+
+// SignMessage signs the message with the account key using eth_sign. The
+// pubkey is recovered from the signature.
+func (w *ExchangeWallet) SignMessage(coin asset.Coin, msg dex.Bytes) (pubkeys, sigs []dex.Bytes, err error) {
+	acct := w.account()
+	var sigHex string
+	if err := w.node.call(&sigHex, methodSign, acct.String(), hexBytes(msg)); err != nil {
+		return nil, nil, fmt.Errorf("error signing message: %v", err)
+	}
+	sig, err := parseHexBytes(sigHex)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubKey, err := recoverPubKey(msg, sig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pubKeyAddress(pubKey) != acct {
+		return nil, nil, fmt.Errorf("signature is not from the account %s", acct)
+	}
+	return []dex.Bytes{pubKey.SerializeCompressed()}, []dex.Bytes{sig}, nil
+}
+
+// recoverPubKey recovers the pubkey from an eth_sign signature, which is
+// [R || S || V] with V 27 or 28, of the Ethereum signed message hash.
+func recoverPubKey(msg, sig []byte) (*btcec.PublicKey, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return nil, fmt.Errorf("invalid signature recovery ID %d", sig[64])
+	}
+	hash := dexeth.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))), msg)
+	// btcec's compact signatures are [V || R || S], with V offset by 27, and
+	// another 4 for a compressed pubkey.
+	compact := append([]byte{27 + 4 + v}, sig[:64]...)
+	pubKey, _, err := btcec.RecoverCompact(btcec.S256(), compact, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error recovering pubkey: %v", err)
+	}
+	return pubKey, nil
+}
+
+// pubKeyAddress is the Ethereum address of the pubkey.
+func pubKeyAddress(pubKey *btcec.PublicKey) dexeth.Address {
+	var addr dexeth.Address
+	copy(addr[:], dexeth.Keccak256(pubKey.SerializeUncompressed()[1:])[12:])
+	return addr
+}
+
+// AuditContract checks the swap in the initiate transaction. The contract is
+// the swap's secret hash. The transaction may still be pending.
+func (w *ExchangeWallet) AuditContract(coinID dex.Bytes, contract dex.Bytes) (asset.AuditInfo, error) {
+	txHash, secretHash, err := dexeth.DecodeSwapCoinID(coinID)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(contract, secretHash[:]) {
+		return nil, fmt.Errorf("contract %x does not match the secret hash %x of the coin ID", contract, secretHash)
+	}
+	tx, err := w.transaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, asset.CoinNotFoundError
+	}
+	to, err := dexeth.HexToAddress(tx.To)
+	if err != nil || to != w.contract {
+		return nil, fmt.Errorf("transaction %x is not to the swap contract %s", txHash, w.contract)
+	}
+	input, err := parseHexBytes(tx.Input)
+	if err != nil {
+		return nil, err
+	}
+	inits, err := dexeth.ParseInitiateData(input)
+	if err != nil {
+		return nil, err
+	}
+	var init *dexeth.Initiation
+	for _, in := range inits {
+		if in.SecretHash == secretHash {
+			init = in
+			break
+		}
+	}
+	if init == nil {
+		return nil, fmt.Errorf("swap %x not found in transaction %x", secretHash, txHash)
+	}
+	receipt, err := w.receipt(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt != nil && receipt.Status != "0x1" {
+		return nil, fmt.Errorf("initiate transaction %x failed", txHash)
+	}
+	return &auditInfo{
+		recipient:  init.Participant,
+		expiration: time.Unix(int64(init.RefundTimestamp), 0).UTC(),
+		coin: &swapCoin{
+			w:          w,
+			txHash:     txHash,
+			secretHash: secretHash,
+			value:      dexeth.ToAtoms(init.Value, w.unitsPerAtom),
+		},
+	}, nil
+}
+
+// contractSecretHash decodes the contract, which is a swap's secret hash.
+func contractSecretHash(contract dex.Bytes) ([32]byte, error) {
+	var secretHash [32]byte
+	if len(contract) != 32 {
+		return secretHash, fmt.Errorf("invalid contract length %d", len(contract))
+	}
+	copy(secretHash[:], contract)
+	return secretHash, nil
+}
+
+// LocktimeExpired is true if the latest block's timestamp is past the swap's
+// refund time.
+func (w *ExchangeWallet) LocktimeExpired(contract dex.Bytes) (bool, error) {
+	secretHash, err := contractSecretHash(contract)
+	if err != nil {
+		return false, err
+	}
+	state, err := w.swapState(secretHash)
+	if err != nil {
+		return false, err
+	}
+	if state.State == dexeth.SSNone {
+		return false, fmt.Errorf("swap %x not found", secretHash)
+	}
+	return w.refundTimeReached(state)
+}
+
+// refundTimeReached is true if the latest block's timestamp is past the refund
+// time of the swap, so that the contract will accept a refund.
+func (w *ExchangeWallet) refundTimeReached(state *dexeth.SwapState) (bool, error) {
+	blk := new(rpcBlock)
+	if err := w.node.call(blk, methodGetBlock, "latest", false); err != nil {
+		return false, fmt.Errorf("error getting latest block: %v", err)
+	}
+	stamp, err := parseHexUint64(blk.Timestamp)
+	if err != nil {
+		return false, fmt.Errorf("invalid block timestamp: %v", err)
+	}
+	return stamp >= state.RefundTimestamp, nil
+}
+
+// FindRedemption gets the secret of a redeemed swap from the swap contract.
+func (w *ExchangeWallet) FindRedemption(ctx context.Context, coinID dex.Bytes) (dex.Bytes, error) {
+	_, secretHash, err := dexeth.DecodeSwapCoinID(coinID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := w.swapState(secretHash)
+	if err != nil {
+		return nil, err
+	}
+	switch state.State {
+	case dexeth.SSRedeemed:
+		return state.Secret[:], nil
+	case dexeth.SSNone:
+		return nil, asset.CoinNotFoundError
+	}
+	return nil, fmt.Errorf("swap %x has not been redeemed. state = %s", secretHash, state.State)
+}
+
+// Refund refunds the swap after its refund time.
+func (w *ExchangeWallet) Refund(coinID, contract dex.Bytes) (dex.Bytes, error) {
+	_, secretHash, err := dexeth.DecodeSwapCoinID(coinID)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(contract, secretHash[:]) {
+		return nil, fmt.Errorf("contract %x does not match the secret hash %x of the coin ID", contract, secretHash)
+	}
+	state, err := w.swapState(secretHash)
+	if err != nil {
+		return nil, err
+	}
+	if state.State != dexeth.SSInitiated {
+		if state.State == dexeth.SSNone {
+			return nil, asset.CoinNotFoundError
+		}
+		return nil, fmt.Errorf("swap %x cannot be refunded. state = %s", secretHash, state.State)
+	}
+	if state.Initiator != w.account() {
+		return nil, fmt.Errorf("swap %x was not initiated by the wallet's account", secretHash)
+	}
+	// The contract rejects an early refund, but only after the gas is spent.
+	expired, err := w.refundTimeReached(state)
+	if err != nil {
+		return nil, err
+	}
+	if !expired {
+		return nil, fmt.Errorf("swap %x cannot be refunded until its locktime expires at %s", secretHash,
+			time.Unix(int64(state.RefundTimestamp), 0))
+	}
+	gasPrice, err := w.gasPrice()
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := w.sendTx(w.contract, zero, w.gases.Refund, gasPrice, dexeth.PackRefundData(secretHash))
+	if err != nil {
+		return nil, fmt.Errorf("error sending refund transaction: %v", err)
+	}
+	return txHash[:], nil
+}
+
+// Address returns the account address.
+func (w *ExchangeWallet) Address() (string, error) {
+	return w.account().String(), nil
+}
+
+// PayFee sends the dex registration fee. The gas is paid in addition to the
+// fee.
+func (w *ExchangeWallet) PayFee(address string, regFee uint64) (asset.Coin, error) {
+	return w.send(address, regFee, false)
+}
+
+// Withdraw withdraws funds to the specified address. For ETH, the gas is
+// subtracted from the value.
+func (w *ExchangeWallet) Withdraw(address string, value uint64) (asset.Coin, error) {
+	return w.send(address, value, true)
+}
+
+// send sends value to the address. If subtract is true, the gas for an ETH
+// transfer is subtracted from the value. The gas for a token transfer is
+// always paid in ETH.
+func (w *ExchangeWallet) send(address string, value uint64, subtract bool) (asset.Coin, error) {
+	to, err := dexeth.HexToAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %v", err)
+	}
+	gasPrice, err := w.gasPrice()
+	if err != nil {
+		return nil, err
+	}
+	bal, err := w.balance()
+	if err != nil {
+		return nil, err
+	}
+	w.fundMtx.Lock()
+	avail := availableBal(bal, w.locked)
+	w.fundMtx.Unlock()
+
+	var txHash [32]byte
+	if w.token != nil {
+		if value > avail {
+			return nil, fmt.Errorf("insufficient funds. %d requested, %d available", value, avail)
+		}
+		gas := dexeth.TokenTransferGas
+		if err := w.checkGas(gas * gasPrice); err != nil {
+			return nil, err
+		}
+		txHash, err = w.sendTx(w.tokenAddr, zero, gas, gasPrice,
+			dexeth.PackTransferData(to, dexeth.FromAtoms(value, w.unitsPerAtom)))
+	} else {
+		if value > avail {
+			return nil, fmt.Errorf("insufficient funds. %d requested, %d available", value, avail)
+		}
+		fee := dexeth.TransferGas * gasPrice
+		if subtract {
+			if value <= fee {
+				return nil, fmt.Errorf("value %d does not cover the fee %d", value, fee)
+			}
+			value -= fee
+		} else if value+fee > avail {
+			return nil, fmt.Errorf("insufficient funds. %d requested, %d available", value+fee, avail)
+		}
+		txHash, err = w.sendTx(to, dexeth.FromAtoms(value, w.unitsPerAtom), dexeth.TransferGas, gasPrice, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error sending transaction: %v", err)
+	}
+	return &txCoin{w: w, txHash: txHash, value: value}, nil
+}
+
+// ValidateSecret checks that the secret satisfies the contract.
+func (w *ExchangeWallet) ValidateSecret(secret, secretHash []byte) bool {
+	h := sha256.Sum256(secret)
+	return bytes.Equal(h[:], secretHash)
+}
+
+// Confirmations gets the number of confirmations for the transaction of a
+// swap coin or transaction coin.
+func (w *ExchangeWallet) Confirmations(id dex.Bytes) (uint32, error) {
+	txHash, err := dexeth.DecodeTxCoinID(id)
+	if err != nil {
+		return 0, err
+	}
+	return w.txConfirmations(txHash)
+}
+
+// txConfirmations is the number of confirmations for a transaction. A pending
+// transaction has zero confirmations, and a failed transaction is an error.
+func (w *ExchangeWallet) txConfirmations(txHash [32]byte) (uint32, error) {
+	receipt, err := w.receipt(txHash)
+	if err != nil {
+		return 0, err
+	}
+	if receipt == nil {
+		tx, err := w.transaction(txHash)
+		if err != nil {
+			return 0, err
+		}
+		if tx == nil {
+			return 0, asset.CoinNotFoundError
+		}
+		return 0, nil
+	}
+	if receipt.Status != "0x1" {
+		return 0, fmt.Errorf("transaction %x failed", txHash)
+	}
+	height, err := parseHexUint64(receipt.BlockNumber)
+	if err != nil {
+		return 0, fmt.Errorf("invalid receipt block number: %v", err)
+	}
+	tip, err := w.blockNumber()
+	if err != nil {
+		return 0, err
+	}
+	if tip < height {
+		return 0, nil
+	}
+	return uint32(tip - height + 1), nil
+}
+
+// blockNumber is the height of the node's best block.
+func (w *ExchangeWallet) blockNumber() (uint64, error) {
+	var s string
+	if err := w.node.call(&s, methodBlockNumber); err != nil {
+		return 0, fmt.Errorf("error getting block number: %v", err)
+	}
+	return parseHexUint64(s)
+}
+
+// ethBalance is the account's ETH balance in gwei, including pending
+// transactions.
+func (w *ExchangeWallet) ethBalance() (uint64, error) {
+	var s string
+	if err := w.node.call(&s, methodGetBalance, w.account().String(), "pending"); err != nil {
+		return 0, fmt.Errorf("error getting balance: %v", err)
+	}
+	wei, err := parseHexBig(s)
+	if err != nil {
+		return 0, err
+	}
+	return dexeth.ToAtoms(wei, dexeth.WeiPerGwei), nil
+}
+
+// balance is the account's balance of the wallet's asset, in atoms.
+func (w *ExchangeWallet) balance() (uint64, error) {
+	if w.token == nil {
+		return w.ethBalance()
+	}
+	return w.tokenBalance()
+}
+
+// gasPrice is the node's suggested gas price in gwei, limited by the
+// configured gas price limit.
+func (w *ExchangeWallet) gasPrice() (uint64, error) {
+	var s string
+	if err := w.node.call(&s, methodGasPrice); err != nil {
+		return 0, fmt.Errorf("error getting gas price: %v", err)
+	}
+	wei, err := parseHexBig(s)
+	if err != nil {
+		return 0, err
+	}
+	// Round up to the next gwei.
+	gwei := dexeth.ToAtoms(new(big.Int).Add(wei, new(big.Int).Sub(dexeth.WeiPerGwei, big.NewInt(1))), dexeth.WeiPerGwei)
+	if gwei == 0 {
+		gwei = 1
+	}
+	if gwei > w.gasPriceLimit {
+		w.log.Warnf("Suggested gas price %d gwei is higher than the limit %d gwei", gwei, w.gasPriceLimit)
+		gwei = w.gasPriceLimit
+	}
+	return gwei, nil
+}
+
+// checkGas checks that the account has enough ETH for the gas, in gwei.
+func (w *ExchangeWallet) checkGas(gas uint64) error {
+	bal, err := w.ethBalance()
+	if err != nil {
+		return err
+	}
+	if bal < gas {
+		return fmt.Errorf("insufficient ETH for gas. %d gwei needed, %d available", gas, bal)
+	}
+	return nil
+}
+
+// ethCall calls a contract's view function at the pending block.
+func (w *ExchangeWallet) ethCall(to dexeth.Address, data []byte) ([]byte, error) {
+	var s string
+	args := &txArgs{From: w.account().String(), To: to.String(), Data: hexBytes(data)}
+	if err := w.node.call(&s, methodCall, args, "pending"); err != nil {
+		return nil, err
+	}
+	return parseHexBytes(s)
+}
+
+// swapState gets the state of a swap from the contract.
+func (w *ExchangeWallet) swapState(secretHash [32]byte) (*dexeth.SwapState, error) {
+	b, err := w.ethCall(w.contract, dexeth.PackSwapData(secretHash))
+	if err != nil {
+		return nil, fmt.Errorf("error getting swap state: %v", err)
+	}
+	return dexeth.ParseSwapState(b)
+}
+
+// sendTx sends a transaction from the account. value is in wei, and gasPrice
+// is in gwei.
+func (w *ExchangeWallet) sendTx(to dexeth.Address, value *big.Int, gas, gasPrice uint64, data []byte) ([32]byte, error) {
+	var txHash [32]byte
+	args := &txArgs{
+		From:     w.account().String(),
+		To:       to.String(),
+		Gas:      hexUint64(gas),
+		GasPrice: hexBig(dexeth.FromAtoms(gasPrice, dexeth.WeiPerGwei)),
+		Value:    hexBig(value),
+	}
+	if len(data) > 0 {
+		args.Data = hexBytes(data)
+	}
+	var s string
+	if err := w.node.call(&s, methodSendTx, args); err != nil {
+		return txHash, err
+	}
+	b, err := parseHexBytes(s)
+	if err != nil || len(b) != 32 {
+		return txHash, fmt.Errorf("invalid transaction hash %q", s)
+	}
+	copy(txHash[:], b)
+	w.log.Debugf("Sent transaction %x to %s", txHash, to)
+	return txHash, nil
+}
+
+// transaction gets a transaction. The transaction is nil if it is not known
+// to the node.
+func (w *ExchangeWallet) transaction(txHash [32]byte) (*rpcTx, error) {
+	var tx *rpcTx
+	if err := w.node.call(&tx, methodGetTx, hexBytes(txHash[:])); err != nil {
+		return nil, fmt.Errorf("error getting transaction %x: %v", txHash, err)
+	}
+	return tx, nil
+}
+
+// receipt gets a transaction's receipt. The receipt is nil if the transaction
+// is pending or unknown.
+func (w *ExchangeWallet) receipt(txHash [32]byte) (*rpcReceipt, error) {
+	var receipt *rpcReceipt
+	if err := w.node.call(&receipt, methodGetReceipt, hexBytes(txHash[:])); err != nil {
+		return nil, fmt.Errorf("error getting receipt for transaction %x: %v", txHash, err)
+	}
+	return receipt, nil
+}
@@ -0,0 +1,934 @@
+// +build !harness
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+	dexeth "decred.org/dcrdex/dex/networks/eth"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/decred/slog"
+)
+
+var (
+	tLogger   dex.Logger
+	tLotSize  uint64 = 1e8 // 0.1 ETH
+	tGasPrice uint64 = 50
+	tETH             = &dex.Asset{
+		ID:         BipID,
+		Symbol:     "eth",
+		SwapSize:   dexeth.ETHGases.Swap,
+		MaxFeeRate: 100,
+		LotSize:    tLotSize,
+		RateStep:   100,
+		SwapConf:   1,
+	}
+	tContract = dexeth.Address{0xc0}
+	tToken    = dexeth.Address{0x70}
+)
+
+func TestMain(m *testing.M) {
+	tLogger = slog.NewBackend(os.Stdout).Logger("TEST")
+	tLogger.SetLevel(slog.LevelTrace)
+	os.Exit(m.Run())
+}
+
+// tNode is a fake Ethereum node with one account and a swap contract.
+type tNode struct {
+	mtx       sync.Mutex
+	key       *btcec.PrivateKey
+	acct      dexeth.Address
+	accounts  []string
+	blockNum  uint64
+	blockTime uint64
+	balance   *big.Int // wei
+	gasPrice  *big.Int // wei
+	tokenBal  *big.Int
+	allowance *big.Int
+	swaps     map[[32]byte]*dexeth.SwapState
+	txs       map[string]*rpcTx
+	receipts  map[string]*rpcReceipt
+	sent      []*txArgs
+	unlocked  bool
+	errs      map[string]error
+}
+
+func newTNode() *tNode {
+	key, _ := btcec.NewPrivateKey(btcec.S256())
+	acct := pubKeyAddress(key.PubKey())
+	return &tNode{
+		key:       key,
+		acct:      acct,
+		accounts:  []string{acct.String()},
+		blockNum:  100,
+		blockTime: 1600000000,
+		balance:   dexeth.FromAtoms(1e10, dexeth.WeiPerGwei), // 10 ETH
+		gasPrice:  dexeth.FromAtoms(tGasPrice, dexeth.WeiPerGwei),
+		tokenBal:  big.NewInt(0),
+		allowance: big.NewInt(0),
+		swaps:     make(map[[32]byte]*dexeth.SwapState),
+		txs:       make(map[string]*rpcTx),
+		receipts:  make(map[string]*rpcReceipt),
+		errs:      make(map[string]error),
+	}
+}
+
+func tWord(v *big.Int) []byte {
+	b := make([]byte, 32)
+	vb := v.Bytes()
+	copy(b[32-len(vb):], vb)
+	return b
+}
+
+func tAddrWord(addr dexeth.Address) []byte {
+	return append(make([]byte, 12), addr[:]...)
+}
+
+func packSwapState(s *dexeth.SwapState) []byte {
+	value := s.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	return bytes.Join([][]byte{
+		s.Secret[:],
+		tWord(value),
+		tWord(new(big.Int).SetUint64(s.BlockHeight)),
+		tWord(new(big.Int).SetUint64(s.RefundTimestamp)),
+		tAddrWord(s.Initiator),
+		tAddrWord(s.Participant),
+		tWord(big.NewInt(int64(s.State))),
+	}, nil)
+}
+
+func (n *tNode) call(res interface{}, method string, args ...interface{}) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if err := n.errs[method]; err != nil {
+		return err
+	}
+	var result interface{}
+	switch method {
+	case methodAccounts:
+		result = n.accounts
+	case methodBlockNumber:
+		result = hexUint64(n.blockNum)
+	case methodGetBlock:
+		result = &rpcBlock{Number: hexUint64(n.blockNum), Timestamp: hexUint64(n.blockTime)}
+	case methodGetBalance:
+		result = hexBig(n.balance)
+	case methodGasPrice:
+		result = hexBig(n.gasPrice)
+	case methodCall:
+		tx := args[0].(*txArgs)
+		data, _ := parseHexBytes(tx.Data)
+		var b []byte
+		switch {
+		case bytes.Equal(data[:4], dexeth.PackSwapData([32]byte{})[:4]):
+			var secretHash [32]byte
+			copy(secretHash[:], data[4:])
+			s := n.swaps[secretHash]
+			if s == nil {
+				s = new(dexeth.SwapState)
+			}
+			b = packSwapState(s)
+		case bytes.Equal(data[:4], dexeth.PackBalanceOfData(n.acct)[:4]):
+			b = tWord(n.tokenBal)
+		case bytes.Equal(data[:4], dexeth.PackAllowanceData(n.acct, n.acct)[:4]):
+			b = tWord(n.allowance)
+		default:
+			return fmt.Errorf("unknown call data %x", data)
+		}
+		result = hexBytes(b)
+	case methodSendTx:
+		tx := args[0].(*txArgs)
+		n.sent = append(n.sent, tx)
+		h := sha256.Sum256([]byte(fmt.Sprintf("tx%d", len(n.sent))))
+		hash := hexBytes(h[:])
+		n.txs[hash] = &rpcTx{Hash: hash, From: tx.From, To: tx.To, Value: tx.Value, Input: tx.Data}
+		result = hash
+	case methodGetTx:
+		if tx := n.txs[args[0].(string)]; tx != nil {
+			result = tx
+		}
+	case methodGetReceipt:
+		if r := n.receipts[args[0].(string)]; r != nil {
+			result = r
+		}
+	case methodSign:
+		msg, _ := parseHexBytes(args[1].(string))
+		hash := dexeth.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))), msg)
+		compact, err := btcec.SignCompact(btcec.S256(), n.key, hash, false)
+		if err != nil {
+			return err
+		}
+		result = hexBytes(append(compact[1:], compact[0]))
+	case methodUnlockAccount:
+		n.unlocked = args[1].(string) == "pass"
+		if !n.unlocked {
+			return errors.New("could not decrypt key with given password")
+		}
+		result = true
+	case methodLockAccount:
+		n.unlocked = false
+		result = true
+	default:
+		return fmt.Errorf("unknown method %s", method)
+	}
+	if res == nil || result == nil {
+		return nil
+	}
+	b, _ := json.Marshal(result)
+	return json.Unmarshal(b, res)
+}
+
+// lastSent is the last transaction sent, and its hash.
+func (n *tNode) lastSent() (*txArgs, [32]byte) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	var txHash [32]byte
+	if len(n.sent) == 0 {
+		return nil, txHash
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("tx%d", len(n.sent))))
+	copy(txHash[:], h[:])
+	return n.sent[len(n.sent)-1], txHash
+}
+
+// mine adds a receipt for the transaction at the next block.
+func (n *tNode) mine(txHash [32]byte, ok bool) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.blockNum++
+	status := "0x1"
+	if !ok {
+		status = "0x0"
+	}
+	n.receipts[hexBytes(txHash[:])] = &rpcReceipt{BlockNumber: hexUint64(n.blockNum), Status: status}
+}
+
+func tNewWallet(t *testing.T, token bool) (*ExchangeWallet, *tNode, func()) {
+	t.Helper()
+	node := newTNode()
+	cfg := &asset.WalletConfig{TipChange: func(error) {}}
+	ethCfg := &Config{SwapContract: tContract.String(), GasPriceLimit: defaultGasPriceLimit}
+	w := newWallet(cfg, ethCfg, node, tLogger)
+	w.info = walletInfo
+	w.gases = dexeth.ETHGases
+	w.unitsPerAtom = dexeth.WeiPerGwei
+	if token {
+		setToken(w, dexeth.Tokens[60001], tToken)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, err := w.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	return w, node, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func tSecret(b byte) (secret, secretHash [32]byte) {
+	secret[0] = b
+	return secret, sha256.Sum256(secret[:])
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(map[string]string{"swapcontract": tContract.String()}, "")
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.RPCAddr != defaultRPCAddr || cfg.GasPriceLimit != defaultGasPriceLimit {
+		t.Fatalf("defaults not set")
+	}
+
+	cfg, err = loadConfig(map[string]string{"rpcaddr": "10.0.0.1:8545", "gaspricelimit": "10"}, tContract.String())
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.RPCAddr != "http://10.0.0.1:8545" || cfg.SwapContract != tContract.String() || cfg.GasPriceLimit != 10 {
+		t.Fatalf("wrong config %+v", cfg)
+	}
+
+	for _, settings := range []map[string]string{
+		{},
+		{"swapcontract": "0x1234"},
+		{"swapcontract": tContract.String(), "account": "abc"},
+	} {
+		if _, err := loadConfig(settings, ""); err == nil {
+			t.Fatalf("no error for settings %v", settings)
+		}
+	}
+
+	token := dexeth.Tokens[60001]
+	if addr, err := tokenAddress(map[string]string{}, token, dex.Mainnet); err != nil || addr.String() != token.Addresses[dex.Mainnet] {
+		t.Fatalf("wrong mainnet token address %s: %v", addr, err)
+	}
+	if _, err := tokenAddress(map[string]string{}, token, dex.Simnet); err == nil {
+		t.Fatalf("no error for missing token address")
+	}
+	if addr, err := tokenAddress(map[string]string{tokenAddressKey: tToken.String()}, token, dex.Simnet); err != nil || addr != tToken {
+		t.Fatalf("wrong configured token address %s: %v", addr, err)
+	}
+
+	if unlimited, err := unlimitedApproval(map[string]string{}); err != nil || unlimited {
+		t.Fatalf("unlimited approval is not off by default")
+	}
+	if unlimited, err := unlimitedApproval(map[string]string{unlimitedApprovalKey: "true"}); err != nil || !unlimited {
+		t.Fatalf("unlimited approval not set")
+	}
+	if _, err := unlimitedApproval(map[string]string{unlimitedApprovalKey: "yes please"}); err == nil {
+		t.Fatalf("no error for invalid unlimited approval setting")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+	if addr, _ := w.Address(); addr != node.acct.String() {
+		t.Fatalf("wrong address %s", addr)
+	}
+
+	// Configured account.
+	other := dexeth.Address{0x01}
+	node.accounts = []string{other.String(), node.acct.String()}
+	w.acctSetting = node.acct.String()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, err := w.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	cancel()
+	wg.Wait()
+	if w.account() != node.acct {
+		t.Fatalf("wrong account selected")
+	}
+
+	// Unknown account.
+	w.acctSetting = dexeth.Address{0x02}.String()
+	if _, err := w.Connect(context.Background()); err == nil {
+		t.Fatalf("no error for unknown account")
+	}
+	// No accounts.
+	w.acctSetting = ""
+	node.accounts = nil
+	if _, err := w.Connect(context.Background()); err == nil {
+		t.Fatalf("no error for no accounts")
+	}
+}
+
+func TestTipChange(t *testing.T) {
+	defer func(d time.Duration) { blockTicker = d }(blockTicker)
+	blockTicker = time.Millisecond
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+	tipChange := make(chan error, 10)
+	w.tipChange = func(err error) { tipChange <- err }
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-tipChange:
+		t.Fatalf("tip change without a new block")
+	default:
+	}
+	node.mtx.Lock()
+	node.blockNum++
+	node.mtx.Unlock()
+	select {
+	case err := <-tipChange:
+		if err != nil {
+			t.Fatalf("tip change error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no tip change")
+	}
+}
+
+func TestFundOrder(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	bal, err := w.Balance()
+	if err != nil {
+		t.Fatalf("Balance error: %v", err)
+	}
+	if bal.Available != 1e10 || bal.Locked != 0 {
+		t.Fatalf("wrong balance %+v", bal)
+	}
+
+	// 3 lots reserves the gas for 3 swaps.
+	value := 3 * tLotSize
+	coins, err := w.FundOrder(value, tETH)
+	if err != nil {
+		t.Fatalf("FundOrder error: %v", err)
+	}
+	required := value + 3*tETH.SwapSize*tETH.MaxFeeRate
+	if len(coins) != 1 || coins[0].Value() != required {
+		t.Fatalf("wrong funding coins")
+	}
+	addr, v, err := dexeth.DecodeFundingCoinID(coins[0].ID())
+	if err != nil || addr != node.acct || v != required {
+		t.Fatalf("wrong funding coin ID: %v", err)
+	}
+	bal, _ = w.Balance()
+	if bal.Available != 1e10-required || bal.Locked != required {
+		t.Fatalf("wrong balance after funding %+v", bal)
+	}
+
+	// Not enough left.
+	if _, err := w.FundOrder(1e10-required, tETH); err == nil {
+		t.Fatalf("no error for insufficient funds")
+	}
+	if _, err := w.FundOrder(0, tETH); err == nil {
+		t.Fatalf("no error for zero value")
+	}
+
+	// Return the coins.
+	if err := w.ReturnCoins(coins); err != nil {
+		t.Fatalf("ReturnCoins error: %v", err)
+	}
+	if bal, _ = w.Balance(); bal.Locked != 0 {
+		t.Fatalf("coins not returned")
+	}
+
+	// Reload the coins.
+	reloaded, err := w.FundingCoins([]dex.Bytes{coins[0].ID()})
+	if err != nil {
+		t.Fatalf("FundingCoins error: %v", err)
+	}
+	if len(reloaded) != 1 || !bytes.Equal(reloaded[0].ID(), coins[0].ID()) {
+		t.Fatalf("wrong reloaded coins")
+	}
+	if bal, _ = w.Balance(); bal.Locked != required {
+		t.Fatalf("reloaded coins not locked")
+	}
+
+	// Another account's coin.
+	if _, err := w.FundingCoins([]dex.Bytes{dexeth.EncodeFundingCoinID(dexeth.Address{0x01}, 1)}); err == nil {
+		t.Fatalf("no error for another account's coin")
+	}
+	// Too much.
+	if _, err := w.FundingCoins([]dex.Bytes{dexeth.EncodeFundingCoinID(node.acct, 1e10)}); err == nil {
+		t.Fatalf("no error for unfunded coin")
+	}
+
+	node.errs[methodGetBalance] = errors.New("test error")
+	if _, err := w.FundOrder(tLotSize, tETH); err == nil {
+		t.Fatalf("no error for balance error")
+	}
+}
+
+func tContracts(n int) []*asset.Contract {
+	contracts := make([]*asset.Contract, 0, n)
+	for i := 0; i < n; i++ {
+		_, secretHash := tSecret(byte(i))
+		contracts = append(contracts, &asset.Contract{
+			Address:    dexeth.Address{0xaa, byte(i)}.String(),
+			Value:      tLotSize,
+			SecretHash: secretHash[:],
+			LockTime:   1600000000 + uint64(i),
+		})
+	}
+	return contracts
+}
+
+func TestSwap(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	coins, _ := w.FundOrder(3*tLotSize, tETH)
+	swaps := &asset.Swaps{
+		Inputs:    coins,
+		Contracts: tContracts(2),
+		FeeRate:   tGasPrice,
+	}
+	receipts, change, err := w.Swap(swaps)
+	if err != nil {
+		t.Fatalf("Swap error: %v", err)
+	}
+	tx, txHash := node.lastSent()
+	gas := 2 * dexeth.ETHGases.Swap
+	if tx.To != tContract.String() || tx.Value != hexBig(dexeth.FromAtoms(2*tLotSize, dexeth.WeiPerGwei)) ||
+		tx.Gas != hexUint64(gas) || tx.GasPrice != hexBig(dexeth.FromAtoms(tGasPrice, dexeth.WeiPerGwei)) {
+		t.Fatalf("wrong initiate transaction %+v", tx)
+	}
+	data, _ := parseHexBytes(tx.Data)
+	inits, err := dexeth.ParseInitiateData(data)
+	if err != nil || len(inits) != 2 {
+		t.Fatalf("wrong initiate data: %v", err)
+	}
+	for i, init := range inits {
+		c := swaps.Contracts[i]
+		if init.Participant.String() != c.Address || !bytes.Equal(init.SecretHash[:], c.SecretHash) ||
+			init.RefundTimestamp != c.LockTime || dexeth.ToAtoms(init.Value, dexeth.WeiPerGwei) != c.Value {
+			t.Fatalf("wrong initiation %d", i)
+		}
+	}
+	if len(receipts) != 2 {
+		t.Fatalf("expected 2 receipts, got %d", len(receipts))
+	}
+	for i, r := range receipts {
+		c := swaps.Contracts[i]
+		txHash2, secretHash, _ := dexeth.DecodeSwapCoinID(r.Coin().ID())
+		if txHash2 != txHash || !bytes.Equal(secretHash[:], c.SecretHash) ||
+			!bytes.Equal(r.Coin().Redeem(), c.SecretHash) || r.Expiration().Unix() != int64(c.LockTime) {
+			t.Fatalf("wrong receipt %d", i)
+		}
+	}
+	wantChange := coins[0].Value() - 2*tLotSize - gas*tGasPrice
+	if change.Value() != wantChange {
+		t.Fatalf("wrong change. wanted %d, got %d", wantChange, change.Value())
+	}
+	if bal, _ := w.Balance(); bal.Locked != wantChange {
+		t.Fatalf("wrong locked amount after swap %d", bal.Locked)
+	}
+
+	// Unfunded.
+	swaps.Inputs = asset.Coins{change}
+	swaps.Contracts = tContracts(2)
+	if _, _, err := w.Swap(swaps); err == nil {
+		t.Fatalf("no error for unfunded swap")
+	}
+	// Bad participant.
+	swaps.Contracts = tContracts(1)
+	swaps.Contracts[0].Address = "abc"
+	if _, _, err := w.Swap(swaps); err == nil {
+		t.Fatalf("no error for bad address")
+	}
+	// Send error.
+	swaps.Contracts = tContracts(1)
+	node.errs[methodSendTx] = errors.New("test error")
+	if _, _, err := w.Swap(swaps); err == nil {
+		t.Fatalf("no error for send error")
+	}
+}
+
+func TestTokenSwap(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, true)
+	defer shutdown()
+	tUSDC := &dex.Asset{
+		ID:         60001,
+		Symbol:     "usdc.eth",
+		SwapSize:   w.gases.Swap,
+		MaxFeeRate: 100,
+		LotSize:    1e6,
+	}
+	node.tokenBal = big.NewInt(5e6)
+
+	bal, err := w.Balance()
+	if err != nil || bal.Available != 5e6 {
+		t.Fatalf("wrong token balance: %v", err)
+	}
+
+	// The funding coin does not include the gas.
+	coins, err := w.FundOrder(2e6, tUSDC)
+	if err != nil {
+		t.Fatalf("FundOrder error: %v", err)
+	}
+	if coins[0].Value() != 2e6 {
+		t.Fatalf("wrong funding coin value %d", coins[0].Value())
+	}
+
+	// Not enough ETH for gas.
+	node.balance = big.NewInt(0)
+	if _, err := w.FundOrder(1e6, tUSDC); err == nil {
+		t.Fatalf("no error for insufficient gas")
+	}
+	node.balance = dexeth.FromAtoms(1e9, dexeth.WeiPerGwei)
+
+	// No allowance, so the contract is approved first.
+	swaps := &asset.Swaps{
+		Inputs:    coins,
+		Contracts: tContracts(1),
+		FeeRate:   tGasPrice,
+	}
+	swaps.Contracts[0].Value = 1e6
+	_, change, err := w.Swap(swaps)
+	if err != nil {
+		t.Fatalf("Swap error: %v", err)
+	}
+	if len(node.sent) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(node.sent))
+	}
+	approve := node.sent[0]
+	if approve.To != tToken.String() || approve.Data != hexBytes(dexeth.PackApproveData(tContract, big.NewInt(1e6))) {
+		t.Fatalf("wrong approve transaction %+v", approve)
+	}
+	init := node.sent[1]
+	if init.To != tContract.String() || init.Value != "0x0" || init.Gas != hexUint64(w.gases.Swap) {
+		t.Fatalf("wrong token initiate transaction %+v", init)
+	}
+	if change.Value() != 1e6 {
+		t.Fatalf("wrong change %d", change.Value())
+	}
+
+	// With an allowance, there is no approval.
+	node.allowance = dexeth.MaxUint256
+	swaps.Inputs = asset.Coins{change}
+	swaps.Contracts = tContracts(1)
+	swaps.Contracts[0].Value = 1e6
+	if _, _, err := w.Swap(swaps); err != nil {
+		t.Fatalf("Swap error: %v", err)
+	}
+	if len(node.sent) != 3 || node.sent[2].To != tContract.String() {
+		t.Fatalf("unexpected approval")
+	}
+
+	// Unlimited approval is opt-in.
+	node.allowance = big.NewInt(0)
+	w.unlimitedApproval = true
+	if err := w.approve(1e6, tGasPrice); err != nil {
+		t.Fatalf("approve error: %v", err)
+	}
+	if approve, _ = node.lastSent(); approve.Data != hexBytes(dexeth.PackApproveData(tContract, dexeth.MaxUint256)) {
+		t.Fatalf("wrong unlimited approve transaction %+v", approve)
+	}
+
+	// Token withdraw.
+	coin, err := w.Withdraw(dexeth.Address{0x01}.String(), 1e6)
+	if err != nil {
+		t.Fatalf("Withdraw error: %v", err)
+	}
+	tx, _ := node.lastSent()
+	if coin.Value() != 1e6 || tx.To != tToken.String() ||
+		tx.Data != hexBytes(dexeth.PackTransferData(dexeth.Address{0x01}, big.NewInt(1e6))) {
+		t.Fatalf("wrong token transfer %+v", tx)
+	}
+}
+
+func tAuditInfo(w *ExchangeWallet, secretHash [32]byte, value uint64) *auditInfo {
+	return &auditInfo{
+		coin: &swapCoin{w: w, secretHash: secretHash, value: value},
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	secret1, secretHash1 := tSecret(1)
+	secret2, secretHash2 := tSecret(2)
+	redemptions := []*asset.Redemption{
+		{Spends: tAuditInfo(w, secretHash1, 1e8), Secret: secret1[:]},
+		{Spends: tAuditInfo(w, secretHash2, 2e8), Secret: secret2[:]},
+	}
+	ids, coin, err := w.Redeem(redemptions)
+	if err != nil {
+		t.Fatalf("Redeem error: %v", err)
+	}
+	if len(ids) != 2 || coin.Value() != 3e8 {
+		t.Fatalf("wrong redeem results")
+	}
+	tx, txHash := node.lastSent()
+	if !bytes.Equal(coin.ID(), txHash[:]) {
+		t.Fatalf("wrong redeem coin ID")
+	}
+	data, _ := parseHexBytes(tx.Data)
+	redeems, err := dexeth.ParseRedeemData(data)
+	if err != nil || len(redeems) != 2 || redeems[0].Secret != secret1 || redeems[1].SecretHash != secretHash2 {
+		t.Fatalf("wrong redeem data: %v", err)
+	}
+	if tx.Gas != hexUint64(2*dexeth.ETHGases.Redeem) || tx.GasPrice != hexBig(node.gasPrice) {
+		t.Fatalf("wrong redeem gas")
+	}
+
+	// The gas price is limited.
+	w.gasPriceLimit = 10
+	if _, _, err := w.Redeem(redemptions[:1]); err != nil {
+		t.Fatalf("Redeem error: %v", err)
+	}
+	if tx, _ = node.lastSent(); tx.GasPrice != hexBig(dexeth.FromAtoms(10, dexeth.WeiPerGwei)) {
+		t.Fatalf("gas price not limited")
+	}
+
+	// Bad secret.
+	redemptions[0].Secret = secret2[:]
+	if _, _, err := w.Redeem(redemptions); err == nil {
+		t.Fatalf("no error for bad secret")
+	}
+}
+
+func TestAuditContract(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	coins, _ := w.FundOrder(2*tLotSize, tETH)
+	contracts := tContracts(2)
+	receipts, _, err := w.Swap(&asset.Swaps{Inputs: coins, Contracts: contracts, FeeRate: tGasPrice})
+	if err != nil {
+		t.Fatalf("Swap error: %v", err)
+	}
+	coinID := receipts[1].Coin().ID()
+	contract := receipts[1].Coin().Redeem()
+
+	ai, err := w.AuditContract(coinID, contract)
+	if err != nil {
+		t.Fatalf("AuditContract error: %v", err)
+	}
+	if ai.Recipient() != contracts[1].Address || ai.Coin().Value() != tLotSize ||
+		ai.Expiration().Unix() != int64(contracts[1].LockTime) || !bytes.Equal(ai.SecretHash(), contract) {
+		t.Fatalf("wrong audit info")
+	}
+
+	// Wrong contract.
+	if _, err := w.AuditContract(coinID, receipts[0].Coin().Redeem()); err == nil {
+		t.Fatalf("no error for wrong contract")
+	}
+	// Unknown transaction.
+	_, secretHash := tSecret(9)
+	if _, err := w.AuditContract(dexeth.EncodeSwapCoinID([32]byte{1}, secretHash), secretHash[:]); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	// Swap not in the transaction.
+	txHash, _, _ := dexeth.DecodeSwapCoinID(coinID)
+	if _, err := w.AuditContract(dexeth.EncodeSwapCoinID(txHash, secretHash), secretHash[:]); err == nil {
+		t.Fatalf("no error for missing swap")
+	}
+	// Failed transaction.
+	node.mine(txHash, false)
+	if _, err := w.AuditContract(coinID, contract); err == nil {
+		t.Fatalf("no error for failed transaction")
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	coins, _ := w.FundOrder(tLotSize, tETH)
+	receipts, _, _ := w.Swap(&asset.Swaps{Inputs: coins, Contracts: tContracts(1), FeeRate: tGasPrice})
+	coin := receipts[0].Coin()
+	txHash, secretHash, _ := dexeth.DecodeSwapCoinID(coin.ID())
+
+	// Pending.
+	confs, err := coin.Confirmations()
+	if err != nil || confs != 0 {
+		t.Fatalf("wrong pending confirmations %d: %v", confs, err)
+	}
+
+	// Mined.
+	node.mine(txHash, true)
+	node.swaps[secretHash] = &dexeth.SwapState{State: dexeth.SSInitiated}
+	node.blockNum++
+	if confs, err = coin.Confirmations(); err != nil || confs != 2 {
+		t.Fatalf("wrong confirmations %d: %v", confs, err)
+	}
+	if confs, err = w.Confirmations(coin.ID()); err != nil || confs != 2 {
+		t.Fatalf("wrong wallet confirmations %d: %v", confs, err)
+	}
+	if confs, err = w.Confirmations(txHash[:]); err != nil || confs != 2 {
+		t.Fatalf("wrong tx confirmations %d: %v", confs, err)
+	}
+
+	// Redeemed.
+	node.swaps[secretHash].State = dexeth.SSRedeemed
+	if _, err := coin.Confirmations(); err == nil {
+		t.Fatalf("no error for redeemed swap")
+	}
+
+	// Unknown.
+	if _, err := w.Confirmations(make([]byte, 32)); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	// Failed.
+	node.mine(txHash, false)
+	if _, err := w.Confirmations(txHash[:]); err == nil {
+		t.Fatalf("no error for failed transaction")
+	}
+	// Funding coin.
+	if _, err := w.Confirmations(coins[0].ID()); err == nil {
+		t.Fatalf("no error for funding coin")
+	}
+}
+
+func TestRefund(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	_, secretHash := tSecret(1)
+	coinID := dexeth.EncodeSwapCoinID([32]byte{1}, secretHash)
+	node.swaps[secretHash] = &dexeth.SwapState{
+		State:           dexeth.SSInitiated,
+		Initiator:       node.acct,
+		RefundTimestamp: node.blockTime + 1,
+	}
+
+	expired, err := w.LocktimeExpired(secretHash[:])
+	if err != nil || expired {
+		t.Fatalf("wrong expiration: %v", err)
+	}
+	// Too early to refund.
+	if _, err := w.Refund(coinID, secretHash[:]); err == nil {
+		t.Fatalf("no error for refund before the locktime")
+	}
+	if tx, _ := node.lastSent(); tx != nil {
+		t.Fatalf("refund sent before the locktime")
+	}
+	node.blockTime++
+	if expired, err = w.LocktimeExpired(secretHash[:]); err != nil || !expired {
+		t.Fatalf("not expired: %v", err)
+	}
+
+	refundID, err := w.Refund(coinID, secretHash[:])
+	if err != nil {
+		t.Fatalf("Refund error: %v", err)
+	}
+	tx, txHash := node.lastSent()
+	if !bytes.Equal(refundID, txHash[:]) || tx.Data != hexBytes(dexeth.PackRefundData(secretHash)) ||
+		tx.Gas != hexUint64(dexeth.ETHGases.Refund) {
+		t.Fatalf("wrong refund transaction %+v", tx)
+	}
+
+	// Wrong contract.
+	_, otherHash := tSecret(2)
+	if _, err := w.Refund(coinID, otherHash[:]); err == nil {
+		t.Fatalf("no error for wrong contract")
+	}
+	// Unknown swap.
+	if _, err := w.Refund(dexeth.EncodeSwapCoinID([32]byte{1}, otherHash), otherHash[:]); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	if _, err := w.LocktimeExpired(otherHash[:]); err == nil {
+		t.Fatalf("no error for unknown swap")
+	}
+	// Not the initiator.
+	node.swaps[secretHash].Initiator = dexeth.Address{0x01}
+	if _, err := w.Refund(coinID, secretHash[:]); err == nil {
+		t.Fatalf("no error for another initiator")
+	}
+	// Already refunded.
+	node.swaps[secretHash].State = dexeth.SSRefunded
+	if _, err := w.Refund(coinID, secretHash[:]); err == nil {
+		t.Fatalf("no error for refunded swap")
+	}
+}
+
+func TestFindRedemption(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	secret, secretHash := tSecret(1)
+	coinID := dexeth.EncodeSwapCoinID([32]byte{1}, secretHash)
+	if _, err := w.FindRedemption(context.Background(), coinID); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	node.swaps[secretHash] = &dexeth.SwapState{State: dexeth.SSInitiated}
+	if _, err := w.FindRedemption(context.Background(), coinID); err == nil {
+		t.Fatalf("no error for unredeemed swap")
+	}
+	node.swaps[secretHash] = &dexeth.SwapState{State: dexeth.SSRedeemed, Secret: secret}
+	found, err := w.FindRedemption(context.Background(), coinID)
+	if err != nil {
+		t.Fatalf("FindRedemption error: %v", err)
+	}
+	if !bytes.Equal(found, secret[:]) {
+		t.Fatalf("wrong secret")
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+
+	msg := []byte("test message")
+	pubkeys, sigs, err := w.SignMessage(nil, msg)
+	if err != nil {
+		t.Fatalf("SignMessage error: %v", err)
+	}
+	if len(pubkeys) != 1 || len(sigs) != 1 || !bytes.Equal(pubkeys[0], node.key.PubKey().SerializeCompressed()) {
+		t.Fatalf("wrong pubkey")
+	}
+	if len(sigs[0]) != 65 {
+		t.Fatalf("wrong signature length %d", len(sigs[0]))
+	}
+
+	// Another account's signature.
+	w.acct = dexeth.Address{0x01}
+	if _, _, err := w.SignMessage(nil, msg); err == nil {
+		t.Fatalf("no error for wrong signer")
+	}
+}
+
+func TestWithdraw(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+	to := dexeth.Address{0x01}
+	fee := dexeth.TransferGas * tGasPrice
+
+	coin, err := w.Withdraw(to.String(), 1e9)
+	if err != nil {
+		t.Fatalf("Withdraw error: %v", err)
+	}
+	tx, txHash := node.lastSent()
+	if !bytes.Equal(coin.ID(), txHash[:]) || coin.Value() != 1e9-fee ||
+		tx.Value != hexBig(dexeth.FromAtoms(1e9-fee, dexeth.WeiPerGwei)) || tx.To != to.String() {
+		t.Fatalf("wrong withdraw transaction %+v", tx)
+	}
+
+	// PayFee adds the gas.
+	coin, err = w.PayFee(to.String(), 1e9)
+	if err != nil || coin.Value() != 1e9 {
+		t.Fatalf("PayFee error: %v", err)
+	}
+	if _, err := w.PayFee(to.String(), 1e10); err == nil {
+		t.Fatalf("no error for fee with no funds for gas")
+	}
+	if _, err := w.Withdraw(to.String(), fee); err == nil {
+		t.Fatalf("no error for withdrawal that doesn't cover the fee")
+	}
+	if _, err := w.Withdraw("abc", 1e9); err == nil {
+		t.Fatalf("no error for bad address")
+	}
+
+	// Locked funds are unavailable.
+	if _, err := w.FundOrder(8e9, tETH); err != nil {
+		t.Fatalf("FundOrder error: %v", err)
+	}
+	if _, err := w.Withdraw(to.String(), 1e9); err == nil {
+		t.Fatalf("no error for withdrawing locked funds")
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	w, node, shutdown := tNewWallet(t, false)
+	defer shutdown()
+	if err := w.Unlock("wrong", time.Minute); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	if err := w.Unlock("pass", time.Minute); err != nil || !node.unlocked {
+		t.Fatalf("Unlock error: %v", err)
+	}
+	if err := w.Lock(); err != nil || node.unlocked {
+		t.Fatalf("Lock error: %v", err)
+	}
+}
+
+func TestDecodeCoinID(t *testing.T) {
+	d := &Driver{}
+	id := make([]byte, 28)
+	binary.BigEndian.PutUint64(id[20:], 5)
+	s, err := d.DecodeCoinID(id)
+	var zeroAddr dexeth.Address
+	if err != nil || s != zeroAddr.String()+":5" {
+		t.Fatalf("wrong funding coin string %q: %v", s, err)
+	}
+	if _, err := d.DecodeCoinID([]byte{1}); err == nil {
+		t.Fatalf("no error for invalid coin ID")
+	}
+	if info := (&TokenDriver{assetID: 60001}).Info(); info.Name != "USD Coin" {
+		t.Fatalf("wrong token wallet info")
+	}
+}
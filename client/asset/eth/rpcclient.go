@@ -0,0 +1,192 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rpcTimeout is the timeout for requests to the node.
+var rpcTimeout = 30 * time.Second
+
+// ethNode is an Ethereum JSON-RPC client. In production, ethNode is satisfied
+// by *rpcNode. A stub can be used for testing.
+type ethNode interface {
+	// call calls the method and decodes the result into res, if non-nil. A
+	// null result leaves res unchanged.
+	call(res interface{}, method string, args ...interface{}) error
+}
+
+// rpcRequest is a JSON-RPC 2.0 request.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcError is the error in a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error satisfies the error interface.
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("node error %d: %s", e.Code, e.Message)
+}
+
+// rpcResponse is a JSON-RPC 2.0 response.
+type rpcResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcNode is an HTTP JSON-RPC client for an Ethereum node.
+type rpcNode struct {
+	url    string
+	client *http.Client
+	nextID uint64
+}
+
+// newRPCNode is the constructor for an *rpcNode.
+func newRPCNode(url string) *rpcNode {
+	return &rpcNode{
+		url:    url,
+		client: &http.Client{Timeout: rpcTimeout},
+	}
+}
+
+// call sends the request and decodes the result. Part of the ethNode
+// interface.
+func (n *rpcNode) call(res interface{}, method string, args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	b, err := json.Marshal(&rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&n.nextID, 1),
+		Method:  method,
+		Params:  args,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s request error: %v", method, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading %s response: %v", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s request failed with status %s: %s", method, resp.Status, strings.TrimSpace(string(body)))
+	}
+	msg := new(rpcResponse)
+	if err := json.Unmarshal(body, msg); err != nil {
+		return fmt.Errorf("error decoding %s response: %v", method, err)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if res == nil || len(msg.Result) == 0 || string(msg.Result) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Result, res); err != nil {
+		return fmt.Errorf("error decoding %s result: %v", method, err)
+	}
+	return nil
+}
+
+// hexUint64 encodes a JSON-RPC quantity.
+func hexUint64(v uint64) string {
+	return "0x" + strconv.FormatUint(v, 16)
+}
+
+// hexBig encodes a JSON-RPC quantity.
+func hexBig(v *big.Int) string {
+	return "0x" + v.Text(16)
+}
+
+// hexBytes encodes JSON-RPC data.
+func hexBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// parseHexUint64 decodes a JSON-RPC quantity.
+func parseHexUint64(s string) (uint64, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return 0, fmt.Errorf("quantity %q is missing the 0x prefix", s)
+	}
+	return strconv.ParseUint(s[2:], 16, 64)
+}
+
+// parseHexBig decodes a JSON-RPC quantity.
+func parseHexBig(s string) (*big.Int, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("quantity %q is missing the 0x prefix", s)
+	}
+	v, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity %q", s)
+	}
+	return v, nil
+}
+
+// parseHexBytes decodes JSON-RPC data.
+func parseHexBytes(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("data %q is missing the 0x prefix", s)
+	}
+	return hex.DecodeString(s[2:])
+}
+
+// txArgs are the arguments for eth_sendTransaction and eth_call.
+type txArgs struct {
+	From     string `json:"from,omitempty"`
+	To       string `json:"to"`
+	Gas      string `json:"gas,omitempty"`
+	GasPrice string `json:"gasPrice,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+// rpcTx is a transaction as returned by eth_getTransactionByHash. BlockNumber
+// is empty for a pending transaction.
+type rpcTx struct {
+	Hash        string `json:"hash"`
+	BlockNumber string `json:"blockNumber"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Value       string `json:"value"`
+	Input       string `json:"input"`
+}
+
+// rpcReceipt is a transaction receipt as returned by
+// eth_getTransactionReceipt.
+type rpcReceipt struct {
+	BlockNumber string `json:"blockNumber"`
+	Status      string `json:"status"`
+	GasUsed     string `json:"gasUsed"`
+}
+
+// rpcBlock is a block header as returned by eth_getBlockByNumber.
+type rpcBlock struct {
+	Number    string `json:"number"`
+	Hash      string `json:"hash"`
+	Timestamp string `json:"timestamp"`
+}
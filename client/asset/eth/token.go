@@ -0,0 +1,160 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"fmt"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/config"
+	dexeth "decred.org/dcrdex/dex/networks/eth"
+)
+
+func init() {
+	for assetID, token := range dexeth.Tokens {
+		// Tokens are only offered where the ERC-20 swap contract has been
+		// deployed.
+		if len(token.SwapContracts) == 0 {
+			continue
+		}
+		asset.Register(assetID, &TokenDriver{assetID: assetID})
+	}
+}
+
+// TokenDriver implements asset.Driver for an ERC-20 token.
+type TokenDriver struct {
+	assetID uint32
+}
+
+// Setup creates the token's exchange wallet.
+func (d *TokenDriver) Setup(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
+	return NewTokenWallet(d.assetID, cfg, logger, network)
+}
+
+// DecodeCoinID creates a human-readable representation of a coin ID for the
+// token.
+func (d *TokenDriver) DecodeCoinID(coinID []byte) (string, error) {
+	return dexeth.CoinIDString(coinID)
+}
+
+// Info returns basic information about the wallet and asset.
+func (d *TokenDriver) Info() *asset.WalletInfo {
+	return tokenWalletInfo(dexeth.Tokens[d.assetID])
+}
+
+// tokenWalletInfo is the WalletInfo for a token.
+func tokenWalletInfo(token *dexeth.Token) *asset.WalletInfo {
+	return &asset.WalletInfo{
+		Name:           token.Name,
+		Units:          token.Units,
+		ConfigOpts:     append(config.Options(&Config{}), TokenAddressOption, UnlimitedApprovalOption),
+		DefaultFeeRate: defaultGasPrice,
+	}
+}
+
+// NewTokenWallet is the constructor for an ERC-20 token's exchange wallet.
+// The gas for the token's transactions is paid from the account's ETH
+// balance.
+func NewTokenWallet(assetID uint32, cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
+	token, found := dexeth.Tokens[assetID]
+	if !found {
+		return nil, fmt.Errorf("unknown token asset ID %d", assetID)
+	}
+	tokenAddr, err := tokenAddress(cfg.Settings, token, network)
+	if err != nil {
+		return nil, err
+	}
+	unlimited, err := unlimitedApproval(cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+	ethCfg, err := loadConfig(cfg.Settings, token.SwapContracts[network])
+	if err != nil {
+		return nil, err
+	}
+	w := newWallet(cfg, ethCfg, newRPCNode(ethCfg.RPCAddr), logger)
+	setToken(w, token, tokenAddr)
+	w.unlimitedApproval = unlimited
+	return w, nil
+}
+
+// setToken makes the ExchangeWallet a token wallet.
+func setToken(w *ExchangeWallet, token *dexeth.Token, tokenAddr dexeth.Address) {
+	w.info = tokenWalletInfo(token)
+	w.token = token
+	w.tokenAddr = tokenAddr
+	w.gases = token.Gases
+	w.unitsPerAtom = token.UnitsPerAtom()
+}
+
+// tokenBalance is the account's token balance in atoms, including pending
+// transactions.
+func (w *ExchangeWallet) tokenBalance() (uint64, error) {
+	b, err := w.ethCall(w.tokenAddr, dexeth.PackBalanceOfData(w.account()))
+	if err != nil {
+		return 0, fmt.Errorf("error getting token balance: %v", err)
+	}
+	bal, err := dexeth.ParseUint256(b)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing token balance: %v", err)
+	}
+	return dexeth.ToAtoms(bal, w.unitsPerAtom), nil
+}
+
+// allowance is the amount, in atoms, that the swap contract is allowed to
+// transfer from the account.
+func (w *ExchangeWallet) allowance() (uint64, error) {
+	b, err := w.ethCall(w.tokenAddr, dexeth.PackAllowanceData(w.account(), w.contract))
+	if err != nil {
+		return 0, fmt.Errorf("error getting token allowance: %v", err)
+	}
+	allowance, err := dexeth.ParseUint256(b)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing token allowance: %v", err)
+	}
+	allowance = allowance.Quo(allowance, w.unitsPerAtom)
+	if !allowance.IsUint64() {
+		return ^uint64(0), nil
+	}
+	return allowance.Uint64(), nil
+}
+
+// approvalNeeded is true if the swap contract is not allowed to transfer the
+// value from the account. If the allowance cannot be checked, approval is
+// assumed to be needed.
+func (w *ExchangeWallet) approvalNeeded(value uint64) bool {
+	allowance, err := w.allowance()
+	if err != nil {
+		w.log.Errorf("Error checking allowance: %v", err)
+		return true
+	}
+	return allowance < value
+}
+
+// approve sends an approve transaction allowing the swap contract to transfer
+// the value from the account, if the current allowance does not cover it. The
+// allowance is set to any amount only if the wallet is configured for
+// unlimited approval. The node assigns the approval the next nonce, so it is
+// mined before a subsequent initiation.
+func (w *ExchangeWallet) approve(value, gasPrice uint64) error {
+	allowance, err := w.allowance()
+	if err != nil {
+		return err
+	}
+	if allowance >= value {
+		return nil
+	}
+	amt := dexeth.FromAtoms(value, w.unitsPerAtom)
+	if w.unlimitedApproval {
+		amt = dexeth.MaxUint256
+	}
+	txHash, err := w.sendTx(w.tokenAddr, zero, dexeth.ApproveGas, gasPrice,
+		dexeth.PackApproveData(w.contract, amt))
+	if err != nil {
+		return fmt.Errorf("error sending approve transaction: %v", err)
+	}
+	w.log.Infof("Approved the %s swap contract %s with transaction %x", w.token.Name, w.contract, txHash)
+	return nil
+}
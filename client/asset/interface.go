@@ -17,6 +17,12 @@ import (
 // exist and be unspent.
 const CoinNotFoundError = dex.ErrorKind("coin not found")
 
+// NoFeeBumpError is returned from BumpRedemptionFee when the redemption's
+// effective fee rate already meets the optimal fee rate or the user's fee
+// limit, when fee bumping is not enabled, or when the wallet is not tracking
+// the redemption, e.g. because it was sent before a restart.
+const NoFeeBumpError = dex.ErrorKind("no fee bump")
+
 // WalletInfo is auxiliary information about an ExchangeWallet.
 type WalletInfo struct {
 	// Name is the display name for the currency, e.g. "Decred"
@@ -54,6 +60,15 @@ type WalletConfig struct {
 	// needed. If the error is non-nil, the wallet monitoring loop encountered an
 	// error while retrieving tip information.
 	TipChange func(error)
+	// DevicePrompt is used by wallets with keys on a hardware wallet to
+	// prompt the user for interaction with the device.
+	DevicePrompt Prompter
+	// Seed is derived from the client's application seed, and is only set
+	// when the wallet is created with CreateWallet. A wallet with keys managed
+	// by the client, such as a built-in SPV wallet, should use Seed if it
+	// creates a new HD wallet, so that the wallet can be restored from the
+	// application seed.
+	Seed []byte
 }
 
 // Wallet is a common interface to be implemented by cryptocurrency wallet
@@ -139,6 +154,164 @@ type Wallet interface {
 	ValidateSecret(secret, secretHash []byte) bool
 }
 
+// FeeBumper is a Wallet that can raise the effective fee rate of an unconfirmed
+// redemption transaction. Implementing FeeBumper is optional.
+type FeeBumper interface {
+	// BumpRedemptionFee raises the effective fee rate of the redemption
+	// transaction identified by a coin ID returned from Redeem toward the
+	// current optimal fee rate, but not above the limit configured by the user.
+	// The bump may be done with a replacement (RBF) or a child (CPFP)
+	// transaction. The output Coin of the new transaction and the resulting
+	// effective fee rate of the redemption are returned.
+	BumpRedemptionFee(coinID dex.Bytes) (Coin, uint64, error)
+}
+
+// Rescanner is a Wallet that can rescan the blockchain for wallet transactions
+// that it missed. Implementing Rescanner is optional.
+type Rescanner interface {
+	// Rescan rescans the blockchain from the block height.
+	Rescan(height uint32) error
+}
+
+// SeedExporter is a Wallet with a seed managed by the client, such as a
+// built-in SPV wallet, that can export the seed for backup. Implementing
+// SeedExporter is optional.
+type SeedExporter interface {
+	// ExportSeed decrypts the wallet's seed with the wallet password.
+	ExportSeed(pw string) (dex.Bytes, error)
+}
+
+// BatchWithdrawer is a Wallet that can send a withdrawal to multiple addresses
+// in a single transaction, at a fee rate chosen by the user, and estimate the
+// fees before the transaction is signed. Implementing BatchWithdrawer is
+// optional.
+type BatchWithdrawer interface {
+	// EstimateWithdrawal selects the coins to fund the withdrawal and estimates
+	// the size and fees of the transaction without signing or sending it.
+	EstimateWithdrawal(*Withdrawal) (*WithdrawalEstimate, error)
+	// SendWithdrawal sends the withdrawal. A Coin is returned for each of the
+	// withdrawal's outputs, in order.
+	SendWithdrawal(*Withdrawal) ([]Coin, error)
+}
+
+// RedeemEstimator is a Wallet that can estimate the fee of a transaction that
+// redeems a swap contract at the current network fee rate. Implementing
+// RedeemEstimator is optional.
+type RedeemEstimator interface {
+	// RedeemFee estimates the fee rate in atoms/byte, and the fee of a
+	// transaction that redeems a single swap contract.
+	RedeemFee() (feeRate, fee uint64)
+}
+
+// CoinManager is a Wallet that can consolidate small coins into one and split
+// its funds into coins sized for orders. Implementing CoinManager is optional.
+type CoinManager interface {
+	// FeeRate is the current optimal fee rate in atoms/byte.
+	FeeRate() (uint64, error)
+	// CoinValues returns the values of the wallet's spendable coins, in
+	// ascending order. Coins locked for orders are not included.
+	CoinValues() ([]uint64, error)
+	// Consolidate spends up to maxInputs of the confirmed coins worth less
+	// than threshold to a single coin in the wallet, at the fee rate. Coins
+	// that are worth less than the fees to spend them are not used.
+	Consolidate(threshold uint64, maxInputs int, feeRate uint64) (Coin, error)
+	// Split sends n coins of the value to the wallet in a single transaction,
+	// at the fee rate.
+	Split(value uint64, n int, feeRate uint64) ([]Coin, error)
+}
+
+// FundingStrategy is a coin selection strategy for funding orders.
+type FundingStrategy string
+
+const (
+	// FundDefault is the wallet's own coin selection. For UTXO-based wallets,
+	// this is the smallest coin that covers the order, or the largest coins
+	// until one more coin covers the order.
+	FundDefault FundingStrategy = ""
+	// FundMinInputs spends the largest coins first to fund the order with as
+	// few inputs as possible.
+	FundMinInputs FundingStrategy = "mininputs"
+	// FundMinChange searches for the coins that cover the order with the least
+	// value left over, so that the least value is locked for the order.
+	FundMinChange FundingStrategy = "minchange"
+	// FundOldest spends the coins with the most confirmations first.
+	FundOldest FundingStrategy = "oldest"
+	// FundManual spends exactly the coins chosen by the user.
+	FundManual FundingStrategy = "manual"
+)
+
+// FundingOptions select the coins that fund an order.
+type FundingOptions struct {
+	Strategy FundingStrategy
+	// CoinIDs are the coins to spend with FundManual. They are ignored by the
+	// other strategies.
+	CoinIDs []dex.Bytes
+}
+
+// CoinSelector is a Wallet that can fund orders with a chosen coin selection
+// strategy. Implementing CoinSelector is optional.
+type CoinSelector interface {
+	// FundOrderWith is like FundOrder, but selects the coins according to the
+	// FundingOptions.
+	FundOrderWith(value uint64, nfo *dex.Asset, opts *FundingOptions) (Coins, error)
+}
+
+// CoinLister is a Wallet that can list its coins for manual coin control.
+// Implementing CoinLister is optional.
+type CoinLister interface {
+	// ListCoins returns the wallet's unspent coins that can fund orders,
+	// including the coins that are locked.
+	ListCoins() ([]*WalletCoin, error)
+}
+
+// DepositTracker is a Wallet that can list the coins received by its
+// addresses. Implementing DepositTracker is optional.
+type DepositTracker interface {
+	// AddressDeposits returns the wallet's unspent coins that pay to any of
+	// the addresses, including unconfirmed coins.
+	AddressDeposits(addrs []string) ([]*Deposit, error)
+}
+
+// SyncReporter is a Wallet that can report its blockchain sync progress and
+// network health. Implementing SyncReporter is optional.
+type SyncReporter interface {
+	// SyncStatus returns the wallet's current sync status.
+	SyncStatus() (*SyncStatus, error)
+}
+
+// SyncStatus is the blockchain sync progress and network health of a wallet.
+type SyncStatus struct {
+	// Synced is true if the wallet is synced with the network and may be used
+	// for trading.
+	Synced bool `json:"synced"`
+	// Progress is the fraction of the sync that is complete, from 0 to 1.
+	Progress float32 `json:"progress"`
+	// Height is the wallet's best block height, and TargetHeight is the best
+	// block height known from the network.
+	Height       uint32 `json:"height"`
+	TargetHeight uint32 `json:"targetHeight"`
+	// BlockTime is the time stamp of the wallet's best block.
+	BlockTime time.Time `json:"blockTime"`
+	// Peers is the number of network peers of the wallet or its node.
+	Peers uint32 `json:"peers"`
+	// PendingTxs is the number of the wallet's unconfirmed transactions.
+	PendingTxs uint32 `json:"pendingTxs"`
+}
+
+// WalletCoin is an unspent coin in a wallet, as listed by a CoinLister.
+type WalletCoin struct {
+	ID            dex.Bytes `json:"id"`
+	String        string    `json:"string"`
+	Value         uint64    `json:"value"`
+	Confirmations uint32    `json:"confs"`
+	Address       string    `json:"address"`
+	// Label is the wallet's label for the coin's address, if any.
+	Label string `json:"label"`
+	// Locked is true if the coin is locked, e.g. to fund an order. Locked
+	// coins cannot be chosen to fund another order.
+	Locked bool `json:"locked"`
+}
+
 // Balance is categorized information about a wallet's balance.
 type Balance struct {
 	// Available is the balance that is available for trading immediately.
@@ -219,6 +392,54 @@ type Contract struct {
 	LockTime uint64
 }
 
+// Withdrawal is a withdrawal to one or more addresses in a single transaction.
+type Withdrawal struct {
+	// Outputs are the addresses and values to send.
+	Outputs []*WithdrawalOutput
+	// FeeRate is the fee rate in atoms/byte. If zero, the fee rate is estimated
+	// for confirmation within TargetConfs blocks.
+	FeeRate uint64
+	// TargetConfs is the confirmation target for fee rate estimation, in
+	// blocks. If zero, the wallet's usual target is used.
+	TargetConfs uint32
+	// Subtract is true if the fees should be subtracted from the value of the
+	// first output. If false, the fees are in addition to the output values.
+	Subtract bool
+}
+
+// WithdrawalOutput is an address and value to send in a Withdrawal.
+type WithdrawalOutput struct {
+	Address string
+	Value   uint64
+}
+
+// WithdrawalEstimate is the estimated size and fees of a withdrawal
+// transaction.
+type WithdrawalEstimate struct {
+	// FeeRate is the fee rate in atoms/byte.
+	FeeRate uint64 `json:"feeRate"`
+	// Size is the estimated size of the signed transaction in bytes, or
+	// virtual bytes for segwit transactions.
+	Size uint64 `json:"size"`
+	// Fee is the estimated transaction fee.
+	Fee uint64 `json:"fee"`
+	// Inputs is the number of coins that will be spent.
+	Inputs int `json:"inputs"`
+}
+
+// Deposit is a coin received by one of the wallet's addresses.
+type Deposit struct {
+	// Address is the address that received the coin.
+	Address string
+	// CoinID is the ID of the received coin.
+	CoinID dex.Bytes
+	// Value is the value of the coin.
+	Value uint64
+	// Confirmations is the number of confirmations of the transaction that
+	// created the coin.
+	Confirmations uint32
+}
+
 // Redemption is a redemption transaction that spends a counter-party's swap
 // contract.
 type Redemption struct {
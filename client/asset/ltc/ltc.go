@@ -29,7 +29,7 @@ var (
 		Name:              "Litecoin",
 		Units:             "Litoshi",
 		DefaultConfigPath: dexbtc.SystemConfigPath("litecoin"),
-		ConfigOpts:        config.Options(&dexbtc.Config{}),
+		ConfigOpts:        append(config.Options(&dexbtc.Config{}), btc.RedeemFeeLimitOption),
 		DefaultFeeRate:    defaultWithdrawalFee,
 	}
 )
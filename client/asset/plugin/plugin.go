@@ -0,0 +1,623 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package plugin provides wallets for assets whose wallets run as separate
+// executables, so that wallets that are maintained outside of this repository
+// can be used with the client without being compiled into it. Load registers
+// the asset of a plugin executable with the asset package, and Serve serves an
+// asset.Driver from an executable written in Go. The protocol is documented
+// with the types in types.go.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+)
+
+// retryInterval is how long to wait after a failed Wallet.Tips request before
+// trying again.
+const retryInterval = 5 * time.Second
+
+// driver is an asset.Driver for a plugin.
+type driver struct {
+	dial    func() (io.ReadWriteCloser, error)
+	assetID uint32
+	info    *asset.WalletInfo
+	// client is connected to the process that decodes coin IDs.
+	client *rpc.Client
+}
+
+// Load starts the plugin executable to request its asset and wallet info, and
+// registers a driver for the asset with the asset package. The asset must not
+// already have a driver.
+func Load(path string) (uint32, error) {
+	drv, err := newDriver(func() (io.ReadWriteCloser, error) {
+		return startProcess(path)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error loading wallet plugin %s: %w", path, err)
+	}
+	if _, err := asset.Info(drv.assetID); err == nil {
+		drv.client.Close()
+		return 0, fmt.Errorf("wallet plugin %s is for asset %d, which already has a wallet", path, drv.assetID)
+	}
+	asset.Register(drv.assetID, drv)
+	return drv.assetID, nil
+}
+
+// newDriver requests the asset and wallet info from a new plugin connection.
+func newDriver(dial func() (io.ReadWriteCloser, error)) (*driver, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	client := jsonrpc.NewClient(conn)
+	var info InfoReply
+	if err := client.Call("Plugin.Info", NoArgs{}, &info); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if info.Info == nil {
+		client.Close()
+		return nil, fmt.Errorf("no wallet info")
+	}
+	return &driver{
+		dial:    dial,
+		assetID: info.AssetID,
+		info:    info.Info,
+		client:  client,
+	}, nil
+}
+
+// Setup is part of the asset.Driver interface. A plugin process is started for
+// the wallet. Device prompts are not supported.
+func (d *driver) Setup(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
+	w := &wallet{
+		drv: d,
+		cfg: cfg,
+		log: logger,
+		net: network,
+	}
+	if _, err := w.start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// DecodeCoinID is part of the asset.Driver interface.
+func (d *driver) DecodeCoinID(coinID []byte) (string, error) {
+	var s string
+	err := translateErr(d.client.Call("Plugin.DecodeCoinID", dex.Bytes(coinID), &s))
+	return s, err
+}
+
+// Info is part of the asset.Driver interface.
+func (d *driver) Info() *asset.WalletInfo {
+	return d.info
+}
+
+// process is a plugin process, communicating over its stdin and stdout.
+type process struct {
+	io.ReadCloser
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// startProcess starts the plugin executable. The plugin's stderr is forwarded
+// to the client's stderr.
+func startProcess(path string) (*process, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{
+		ReadCloser:  stdout,
+		WriteCloser: stdin,
+		cmd:         cmd,
+	}, nil
+}
+
+// Close closes the plugin's stdin, which should stop the plugin, and kills the
+// plugin if it does not exit promptly.
+func (p *process) Close() error {
+	p.WriteCloser.Close()
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// translateErr translates the plugin's "coin not found" error to
+// asset.CoinNotFoundError.
+func translateErr(err error) error {
+	if se, ok := err.(rpc.ServerError); ok && string(se) == asset.CoinNotFoundError.Error() {
+		return asset.CoinNotFoundError
+	}
+	return err
+}
+
+// wallet is an asset.Wallet served by a plugin process.
+type wallet struct {
+	drv *driver
+	cfg *asset.WalletConfig
+	log dex.Logger
+	net dex.Network
+
+	clientMtx sync.RWMutex
+	client    *rpc.Client
+	tipSeq    uint64
+}
+
+// Check that wallet satisfies the asset.Wallet interface.
+var _ asset.Wallet = (*wallet)(nil)
+
+// start starts a plugin process for the wallet and sets up the wallet, if
+// there is no process running.
+func (w *wallet) start() (*rpc.Client, error) {
+	w.clientMtx.Lock()
+	defer w.clientMtx.Unlock()
+	if w.client != nil {
+		return w.client, nil
+	}
+	conn, err := w.drv.dial()
+	if err != nil {
+		return nil, err
+	}
+	client := jsonrpc.NewClient(conn)
+	args := &SetupArgs{
+		Account:         w.cfg.Account,
+		FallbackFeeRate: w.cfg.FallbackFeeRate,
+		Settings:        w.cfg.Settings,
+		Seed:            w.cfg.Seed,
+		Network:         w.net.String(),
+	}
+	var ok bool
+	if err := client.Call("Plugin.Setup", args, &ok); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("wallet plugin setup error: %w", err)
+	}
+	w.client = client
+	w.tipSeq = 0
+	return client, nil
+}
+
+// stop stops the wallet's plugin process.
+func (w *wallet) stop() {
+	w.clientMtx.Lock()
+	defer w.clientMtx.Unlock()
+	if w.client != nil {
+		w.client.Close()
+		w.client = nil
+	}
+}
+
+// call calls the method of the wallet's plugin process.
+func (w *wallet) call(method string, args, reply interface{}) error {
+	w.clientMtx.RLock()
+	client := w.client
+	w.clientMtx.RUnlock()
+	if client == nil {
+		return fmt.Errorf("wallet plugin is not running")
+	}
+	return translateErr(client.Call("Wallet."+method, args, reply))
+}
+
+// Connect is part of the dex.Connector interface. The plugin process is
+// stopped on disconnect, and started again on the next Connect.
+func (w *wallet) Connect(ctx context.Context) (*sync.WaitGroup, error) {
+	if _, err := w.start(); err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := w.call("Connect", NoArgs{}, &ok); err != nil {
+		w.stop()
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.watchTips(ctx)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		if err := w.call("Disconnect", NoArgs{}, &ok); err != nil {
+			w.log.Errorf("Error disconnecting wallet plugin: %v", err)
+		}
+		w.stop()
+	}()
+	return &wg, nil
+}
+
+// watchTips requests tip changes from the plugin until the context is
+// canceled, reporting them with the wallet config's TipChange.
+func (w *wallet) watchTips(ctx context.Context) {
+	for {
+		w.clientMtx.RLock()
+		after := w.tipSeq
+		w.clientMtx.RUnlock()
+		var reply TipsReply
+		err := w.call("Tips", TipsArgs{After: after}, &reply)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.log.Errorf("Error requesting tip changes from wallet plugin: %v", err)
+			w.cfg.TipChange(fmt.Errorf("wallet plugin error: %w", err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+		w.clientMtx.Lock()
+		w.tipSeq = reply.Seq
+		w.clientMtx.Unlock()
+		for _, errStr := range reply.Errs {
+			if errStr != "" {
+				w.cfg.TipChange(fmt.Errorf("%s", errStr))
+				continue
+			}
+			w.cfg.TipChange(nil)
+		}
+	}
+}
+
+// Info is part of the asset.Wallet interface.
+func (w *wallet) Info() *asset.WalletInfo {
+	return w.drv.info
+}
+
+// Balance is part of the asset.Wallet interface.
+func (w *wallet) Balance() (*asset.Balance, error) {
+	bal := new(asset.Balance)
+	if err := w.call("Balance", NoArgs{}, bal); err != nil {
+		return nil, err
+	}
+	return bal, nil
+}
+
+// FundOrder is part of the asset.Wallet interface.
+func (w *wallet) FundOrder(value uint64, nfo *dex.Asset) (asset.Coins, error) {
+	var coins []*Coin
+	if err := w.call("FundOrder", FundArgs{Value: value, Asset: nfo}, &coins); err != nil {
+		return nil, err
+	}
+	return w.coins(coins), nil
+}
+
+// ReturnCoins is part of the asset.Wallet interface.
+func (w *wallet) ReturnCoins(coins asset.Coins) error {
+	var ok bool
+	return w.call("ReturnCoins", encodeCoins(coins), &ok)
+}
+
+// FundingCoins is part of the asset.Wallet interface.
+func (w *wallet) FundingCoins(ids []dex.Bytes) (asset.Coins, error) {
+	var coins []*Coin
+	if err := w.call("FundingCoins", ids, &coins); err != nil {
+		return nil, err
+	}
+	return w.coins(coins), nil
+}
+
+// Swap is part of the asset.Wallet interface.
+func (w *wallet) Swap(swaps *asset.Swaps) ([]asset.Receipt, asset.Coin, error) {
+	args := &SwapArgs{
+		Inputs:    encodeCoins(swaps.Inputs),
+		Contracts: make([]*Contract, 0, len(swaps.Contracts)),
+		FeeRate:   swaps.FeeRate,
+	}
+	for _, c := range swaps.Contracts {
+		args.Contracts = append(args.Contracts, &Contract{
+			Address:    c.Address,
+			Value:      c.Value,
+			SecretHash: c.SecretHash,
+			LockTime:   c.LockTime,
+		})
+	}
+	var reply SwapReply
+	if err := w.call("Swap", args, &reply); err != nil {
+		return nil, nil, err
+	}
+	receipts := make([]asset.Receipt, 0, len(reply.Receipts))
+	for _, r := range reply.Receipts {
+		receipts = append(receipts, &receipt{
+			coin:       w.coin(r.Coin),
+			expiration: decodeTime(r.Expiration),
+			str:        r.String,
+		})
+	}
+	var change asset.Coin
+	if reply.Change != nil {
+		change = w.coin(reply.Change)
+	}
+	return receipts, change, nil
+}
+
+// Redeem is part of the asset.Wallet interface.
+func (w *wallet) Redeem(redeems []*asset.Redemption) ([]dex.Bytes, asset.Coin, error) {
+	args := make([]*Redemption, 0, len(redeems))
+	for _, r := range redeems {
+		args = append(args, &Redemption{
+			Spends: encodeAuditInfo(r.Spends),
+			Secret: r.Secret,
+		})
+	}
+	var reply RedeemReply
+	if err := w.call("Redeem", args, &reply); err != nil {
+		return nil, nil, err
+	}
+	if reply.Coin == nil {
+		return nil, nil, fmt.Errorf("no redemption coin")
+	}
+	return reply.Inputs, w.coin(reply.Coin), nil
+}
+
+// SignMessage is part of the asset.Wallet interface.
+func (w *wallet) SignMessage(coin asset.Coin, msg dex.Bytes) (pubkeys, sigs []dex.Bytes, err error) {
+	var reply SignReply
+	if err := w.call("SignMessage", SignArgs{Coin: encodeCoin(coin), Msg: msg}, &reply); err != nil {
+		return nil, nil, err
+	}
+	return reply.PubKeys, reply.Sigs, nil
+}
+
+// AuditContract is part of the asset.Wallet interface.
+func (w *wallet) AuditContract(coinID, contract dex.Bytes) (asset.AuditInfo, error) {
+	var reply AuditInfo
+	if err := w.call("AuditContract", ContractArgs{CoinID: coinID, Contract: contract}, &reply); err != nil {
+		return nil, err
+	}
+	if reply.Coin == nil {
+		return nil, fmt.Errorf("no contract coin")
+	}
+	return &auditInfo{
+		recipient:  reply.Recipient,
+		expiration: decodeTime(reply.Expiration),
+		coin:       w.coin(reply.Coin),
+		secretHash: reply.SecretHash,
+	}, nil
+}
+
+// LocktimeExpired is part of the asset.Wallet interface.
+func (w *wallet) LocktimeExpired(contract dex.Bytes) (bool, error) {
+	var expired bool
+	err := w.call("LocktimeExpired", contract, &expired)
+	return expired, err
+}
+
+// FindRedemption is part of the asset.Wallet interface. The request is
+// abandoned if the context is canceled.
+func (w *wallet) FindRedemption(ctx context.Context, coinID dex.Bytes) (dex.Bytes, error) {
+	w.clientMtx.RLock()
+	client := w.client
+	w.clientMtx.RUnlock()
+	if client == nil {
+		return nil, fmt.Errorf("wallet plugin is not running")
+	}
+	var secret dex.Bytes
+	call := client.Go("Wallet.FindRedemption", coinID, &secret, nil)
+	select {
+	case <-call.Done:
+		return secret, translateErr(call.Error)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Refund is part of the asset.Wallet interface.
+func (w *wallet) Refund(coinID, contract dex.Bytes) (dex.Bytes, error) {
+	var refundID dex.Bytes
+	err := w.call("Refund", ContractArgs{CoinID: coinID, Contract: contract}, &refundID)
+	return refundID, err
+}
+
+// Address is part of the asset.Wallet interface.
+func (w *wallet) Address() (string, error) {
+	var addr string
+	err := w.call("Address", NoArgs{}, &addr)
+	return addr, err
+}
+
+// Unlock is part of the asset.Wallet interface.
+func (w *wallet) Unlock(pw string, dur time.Duration) error {
+	var ok bool
+	args := UnlockArgs{Password: pw, Duration: uint64(dur / time.Millisecond)}
+	return w.call("Unlock", args, &ok)
+}
+
+// Lock is part of the asset.Wallet interface.
+func (w *wallet) Lock() error {
+	var ok bool
+	return w.call("Lock", NoArgs{}, &ok)
+}
+
+// PayFee is part of the asset.Wallet interface.
+func (w *wallet) PayFee(address string, feeAmt uint64) (asset.Coin, error) {
+	return w.send("PayFee", address, feeAmt)
+}
+
+// Withdraw is part of the asset.Wallet interface.
+func (w *wallet) Withdraw(address string, value uint64) (asset.Coin, error) {
+	return w.send("Withdraw", address, value)
+}
+
+// send calls PayFee or Withdraw.
+func (w *wallet) send(method, address string, value uint64) (asset.Coin, error) {
+	var c Coin
+	if err := w.call(method, SendArgs{Address: address, Value: value}, &c); err != nil {
+		return nil, err
+	}
+	return w.coin(&c), nil
+}
+
+// Confirmations is part of the asset.Wallet interface.
+func (w *wallet) Confirmations(id dex.Bytes) (uint32, error) {
+	var confs uint32
+	err := w.call("Confirmations", id, &confs)
+	return confs, err
+}
+
+// ValidateSecret is part of the asset.Wallet interface.
+func (w *wallet) ValidateSecret(secret, secretHash []byte) bool {
+	var ok bool
+	if err := w.call("ValidateSecret", SecretArgs{Secret: secret, SecretHash: secretHash}, &ok); err != nil {
+		w.log.Errorf("ValidateSecret error: %v", err)
+		return false
+	}
+	return ok
+}
+
+// coin is an asset.Coin of a plugin wallet.
+type coin struct {
+	w    *wallet
+	info Coin
+}
+
+// ID is part of the asset.Coin interface.
+func (c *coin) ID() dex.Bytes {
+	return c.info.ID
+}
+
+// String is part of the asset.Coin interface.
+func (c *coin) String() string {
+	return c.info.String
+}
+
+// Value is part of the asset.Coin interface.
+func (c *coin) Value() uint64 {
+	return c.info.Value
+}
+
+// Redeem is part of the asset.Coin interface.
+func (c *coin) Redeem() dex.Bytes {
+	return c.info.Redeem
+}
+
+// Confirmations is part of the asset.Coin interface.
+func (c *coin) Confirmations() (uint32, error) {
+	var confs uint32
+	err := c.w.call("CoinConfirmations", c.info.ID, &confs)
+	return confs, err
+}
+
+// coin is the asset.Coin described by the plugin.
+func (w *wallet) coin(c *Coin) *coin {
+	return &coin{w: w, info: *c}
+}
+
+// coins are the asset.Coins described by the plugin.
+func (w *wallet) coins(cs []*Coin) asset.Coins {
+	coins := make(asset.Coins, 0, len(cs))
+	for _, c := range cs {
+		coins = append(coins, w.coin(c))
+	}
+	return coins
+}
+
+// encodeCoin describes the coin for the plugin.
+func encodeCoin(c asset.Coin) *Coin {
+	return &Coin{
+		ID:     c.ID(),
+		String: c.String(),
+		Value:  c.Value(),
+		Redeem: c.Redeem(),
+	}
+}
+
+// encodeCoins describes the coins for the plugin.
+func encodeCoins(coins asset.Coins) []*Coin {
+	cs := make([]*Coin, 0, len(coins))
+	for _, c := range coins {
+		cs = append(cs, encodeCoin(c))
+	}
+	return cs
+}
+
+// encodeAuditInfo describes the audit info for the plugin.
+func encodeAuditInfo(ai asset.AuditInfo) *AuditInfo {
+	return &AuditInfo{
+		Recipient:  ai.Recipient(),
+		Expiration: encodeTime(ai.Expiration()),
+		Coin:       encodeCoin(ai.Coin()),
+		SecretHash: ai.SecretHash(),
+	}
+}
+
+// receipt is an asset.Receipt of a plugin wallet.
+type receipt struct {
+	coin       asset.Coin
+	expiration time.Time
+	str        string
+}
+
+// Expiration is part of the asset.Receipt interface.
+func (r *receipt) Expiration() time.Time {
+	return r.expiration
+}
+
+// Coin is part of the asset.Receipt interface.
+func (r *receipt) Coin() asset.Coin {
+	return r.coin
+}
+
+// String is part of the asset.Receipt interface.
+func (r *receipt) String() string {
+	return r.str
+}
+
+// auditInfo is an asset.AuditInfo of a plugin wallet.
+type auditInfo struct {
+	recipient  string
+	expiration time.Time
+	coin       asset.Coin
+	secretHash dex.Bytes
+}
+
+// Recipient is part of the asset.AuditInfo interface.
+func (ai *auditInfo) Recipient() string {
+	return ai.recipient
+}
+
+// Expiration is part of the asset.AuditInfo interface.
+func (ai *auditInfo) Expiration() time.Time {
+	return ai.expiration
+}
+
+// Coin is part of the asset.AuditInfo interface.
+func (ai *auditInfo) Coin() asset.Coin {
+	return ai.coin
+}
+
+// SecretHash is part of the asset.AuditInfo interface.
+func (ai *auditInfo) SecretHash() dex.Bytes {
+	return ai.secretHash
+}
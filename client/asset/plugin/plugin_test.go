@@ -0,0 +1,302 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+	"github.com/decred/slog"
+)
+
+var tLogger = slog.NewBackend(os.Stdout).Logger("TEST")
+
+const tAssetID = 42
+
+type tCoin struct {
+	id     dex.Bytes
+	value  uint64
+	redeem dex.Bytes
+	confs  uint32
+}
+
+func (c *tCoin) ID() dex.Bytes                  { return c.id }
+func (c *tCoin) String() string                 { return c.id.String() }
+func (c *tCoin) Value() uint64                  { return c.value }
+func (c *tCoin) Confirmations() (uint32, error) { return c.confs, nil }
+func (c *tCoin) Redeem() dex.Bytes              { return c.redeem }
+
+type tReceipt struct {
+	coin *tCoin
+}
+
+func (r *tReceipt) Expiration() time.Time { return time.Unix(1600000000, 0) }
+func (r *tReceipt) Coin() asset.Coin      { return r.coin }
+func (r *tReceipt) String() string        { return "receipt" }
+
+type tAuditInfo struct {
+	coin *tCoin
+}
+
+func (ai *tAuditInfo) Recipient() string     { return "recipient" }
+func (ai *tAuditInfo) Expiration() time.Time { return time.Unix(1600000000, 0) }
+func (ai *tAuditInfo) Coin() asset.Coin      { return ai.coin }
+func (ai *tAuditInfo) SecretHash() dex.Bytes { return dex.Bytes{0x05} }
+
+type tWallet struct {
+	cfg       *asset.WalletConfig
+	connected bool
+	fundCoin  *tCoin
+	returned  asset.Coins
+	swapped   *asset.Swaps
+	redeemed  []*asset.Redemption
+	audit     *tAuditInfo
+	unlockDur time.Duration
+}
+
+func (w *tWallet) Connect(ctx context.Context) (*sync.WaitGroup, error) {
+	w.connected = true
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		w.connected = false
+	}()
+	return &wg, nil
+}
+
+func (w *tWallet) Info() *asset.WalletInfo { return tInfo }
+func (w *tWallet) Balance() (*asset.Balance, error) {
+	return &asset.Balance{Available: 1, Immature: 2, Locked: 3}, nil
+}
+func (w *tWallet) FundOrder(value uint64, nfo *dex.Asset) (asset.Coins, error) {
+	if nfo.ID != tAssetID {
+		return nil, fmt.Errorf("wrong asset")
+	}
+	return asset.Coins{w.fundCoin}, nil
+}
+func (w *tWallet) ReturnCoins(coins asset.Coins) error {
+	w.returned = coins
+	return nil
+}
+func (w *tWallet) FundingCoins(ids []dex.Bytes) (asset.Coins, error) {
+	if len(ids) != 1 || !bytes.Equal(ids[0], w.fundCoin.id) {
+		return nil, asset.CoinNotFoundError
+	}
+	return asset.Coins{w.fundCoin}, nil
+}
+func (w *tWallet) Swap(swaps *asset.Swaps) ([]asset.Receipt, asset.Coin, error) {
+	w.swapped = swaps
+	return []asset.Receipt{&tReceipt{coin: &tCoin{id: dex.Bytes{0x02}, redeem: dex.Bytes{0x03}}}},
+		&tCoin{id: dex.Bytes{0x04}}, nil
+}
+func (w *tWallet) Redeem(redeems []*asset.Redemption) ([]dex.Bytes, asset.Coin, error) {
+	w.redeemed = redeems
+	return []dex.Bytes{{0x06}}, &tCoin{id: dex.Bytes{0x07}}, nil
+}
+func (w *tWallet) SignMessage(coin asset.Coin, msg dex.Bytes) (pubkeys, sigs []dex.Bytes, err error) {
+	if coin != w.fundCoin {
+		return nil, nil, fmt.Errorf("wrong coin")
+	}
+	return []dex.Bytes{{0x08}}, []dex.Bytes{msg}, nil
+}
+func (w *tWallet) AuditContract(coinID, contract dex.Bytes) (asset.AuditInfo, error) {
+	if !bytes.Equal(coinID, w.audit.coin.id) {
+		return nil, asset.CoinNotFoundError
+	}
+	return w.audit, nil
+}
+func (w *tWallet) LocktimeExpired(contract dex.Bytes) (bool, error) { return true, nil }
+func (w *tWallet) FindRedemption(ctx context.Context, coinID dex.Bytes) (dex.Bytes, error) {
+	return dex.Bytes{0x09}, nil
+}
+func (w *tWallet) Refund(coinID, contract dex.Bytes) (dex.Bytes, error) {
+	return dex.Bytes{0x0a}, nil
+}
+func (w *tWallet) Address() (string, error) { return "address", nil }
+func (w *tWallet) Unlock(pw string, dur time.Duration) error {
+	if pw != "pass" {
+		return fmt.Errorf("wrong password")
+	}
+	w.unlockDur = dur
+	return nil
+}
+func (w *tWallet) Lock() error { return nil }
+func (w *tWallet) PayFee(address string, feeAmt uint64) (asset.Coin, error) {
+	return &tCoin{id: dex.Bytes{0x0b}, value: feeAmt}, nil
+}
+func (w *tWallet) Confirmations(id dex.Bytes) (uint32, error) { return 2, nil }
+func (w *tWallet) Withdraw(address string, value uint64) (asset.Coin, error) {
+	return &tCoin{id: dex.Bytes{0x0c}, value: value}, nil
+}
+func (w *tWallet) ValidateSecret(secret, secretHash []byte) bool {
+	return bytes.Equal(secret, secretHash)
+}
+
+var tInfo = &asset.WalletInfo{Name: "Test", Units: "atoms"}
+
+type tDriver struct {
+	wallet *tWallet
+}
+
+func (d *tDriver) Setup(cfg *asset.WalletConfig, logger dex.Logger, net dex.Network) (asset.Wallet, error) {
+	if net != dex.Simnet {
+		return nil, fmt.Errorf("wrong network")
+	}
+	d.wallet.cfg = cfg
+	return d.wallet, nil
+}
+func (d *tDriver) DecodeCoinID(coinID []byte) (string, error) {
+	return fmt.Sprintf("%x:0", coinID), nil
+}
+func (d *tDriver) Info() *asset.WalletInfo { return tInfo }
+
+func TestPlugin(t *testing.T) {
+	tw := &tWallet{
+		fundCoin: &tCoin{id: dex.Bytes{0x01}, value: 1e8, confs: 3},
+		audit:    &tAuditInfo{coin: &tCoin{id: dex.Bytes{0x0d}, redeem: dex.Bytes{0x0e}}},
+	}
+	tDrv := &tDriver{wallet: tw}
+	dial := func() (io.ReadWriteCloser, error) {
+		c1, c2 := net.Pipe()
+		go Serve(tAssetID, tDrv, c2, tLogger)
+		return c1, nil
+	}
+	drv, err := newDriver(dial)
+	if err != nil {
+		t.Fatalf("newDriver error: %v", err)
+	}
+	if drv.assetID != tAssetID || drv.Info().Name != "Test" {
+		t.Fatalf("wrong plugin info")
+	}
+	if s, err := drv.DecodeCoinID([]byte{0x01}); err != nil || s != "01:0" {
+		t.Fatalf("wrong decoded coin ID %q, %v", s, err)
+	}
+
+	tipChange := make(chan error, 1)
+	cfg := &asset.WalletConfig{
+		Settings:  map[string]string{"a": "b"},
+		TipChange: func(err error) { tipChange <- err },
+	}
+	if _, err := drv.Setup(cfg, tLogger, dex.Mainnet); err == nil {
+		t.Fatalf("no error for wrong network")
+	}
+	wallet, err := drv.Setup(cfg, tLogger, dex.Simnet)
+	if err != nil {
+		t.Fatalf("Setup error: %v", err)
+	}
+	if tw.cfg.Settings["a"] != "b" {
+		t.Fatalf("settings not passed to the wallet")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, err := wallet.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	if !tw.connected {
+		t.Fatalf("wallet not connected")
+	}
+
+	// Tip changes are reported to the client.
+	tw.cfg.TipChange(nil)
+	select {
+	case err := <-tipChange:
+		if err != nil {
+			t.Fatalf("unexpected tip change error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no tip change")
+	}
+
+	bal, err := wallet.Balance()
+	if err != nil || bal.Available != 1 || bal.Immature != 2 || bal.Locked != 3 {
+		t.Fatalf("wrong balance %+v, %v", bal, err)
+	}
+
+	// The wallet gets its own coins back.
+	coins, err := wallet.FundOrder(1e8, &dex.Asset{ID: tAssetID})
+	if err != nil || len(coins) != 1 || coins[0].Value() != 1e8 {
+		t.Fatalf("wrong funding coins %v, %v", coins, err)
+	}
+	if confs, err := coins[0].Confirmations(); err != nil || confs != 3 {
+		t.Fatalf("wrong coin confirmations %d, %v", confs, err)
+	}
+	if _, sigs, err := wallet.SignMessage(coins[0], dex.Bytes{0x0f}); err != nil || !bytes.Equal(sigs[0], []byte{0x0f}) {
+		t.Fatalf("wrong signature %v, %v", sigs, err)
+	}
+	receipts, change, err := wallet.Swap(&asset.Swaps{
+		Inputs:    coins,
+		Contracts: []*asset.Contract{{Address: "addr", Value: 5e7, SecretHash: dex.Bytes{0x05}, LockTime: 1}},
+		FeeRate:   10,
+	})
+	if err != nil {
+		t.Fatalf("Swap error: %v", err)
+	}
+	if tw.swapped.Inputs[0] != tw.fundCoin || tw.swapped.Contracts[0].Address != "addr" || tw.swapped.FeeRate != 10 {
+		t.Fatalf("wrong swaps passed to the wallet")
+	}
+	if len(receipts) != 1 || !receipts[0].Expiration().Equal(time.Unix(1600000000, 0)) ||
+		!bytes.Equal(receipts[0].Coin().Redeem(), []byte{0x03}) || !bytes.Equal(change.ID(), []byte{0x04}) {
+		t.Fatalf("wrong swap results")
+	}
+	// The spent input was forgotten, so it is requested as a funding coin.
+	if err := wallet.ReturnCoins(coins); err != nil || tw.returned[0] != tw.fundCoin {
+		t.Fatalf("ReturnCoins error: %v", err)
+	}
+
+	audit, err := wallet.AuditContract(tw.audit.coin.id, nil)
+	if err != nil || audit.Recipient() != "recipient" {
+		t.Fatalf("AuditContract error: %v", err)
+	}
+	if _, err := wallet.AuditContract(dex.Bytes{0xff}, nil); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	inputs, redeemCoin, err := wallet.Redeem([]*asset.Redemption{{Spends: audit, Secret: dex.Bytes{0x10}}})
+	if err != nil || len(inputs) != 1 || !bytes.Equal(redeemCoin.ID(), []byte{0x07}) {
+		t.Fatalf("Redeem error: %v", err)
+	}
+	if tw.redeemed[0].Spends != tw.audit {
+		t.Fatalf("wrong audit info passed to the wallet")
+	}
+
+	if err := wallet.Unlock("wrong", time.Minute); err == nil {
+		t.Fatalf("no error for wrong password")
+	}
+	if err := wallet.Unlock("pass", time.Minute); err != nil || tw.unlockDur != time.Minute {
+		t.Fatalf("Unlock error: %v", err)
+	}
+	if secret, err := wallet.FindRedemption(ctx, dex.Bytes{0x01}); err != nil || !bytes.Equal(secret, []byte{0x09}) {
+		t.Fatalf("FindRedemption error: %v", err)
+	}
+	if coin, err := wallet.Withdraw("addr", 5); err != nil || coin.Value() != 5 {
+		t.Fatalf("Withdraw error: %v", err)
+	}
+	if !wallet.ValidateSecret([]byte{0x01}, []byte{0x01}) || wallet.ValidateSecret([]byte{0x01}, []byte{0x02}) {
+		t.Fatalf("wrong ValidateSecret result")
+	}
+	if _, err := wallet.FundingCoins([]dex.Bytes{{0xff}}); !errors.Is(err, asset.CoinNotFoundError) {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+
+	// Disconnecting stops the plugin's wallet.
+	cancel()
+	wg.Wait()
+	if tw.connected {
+		t.Fatalf("wallet still connected")
+	}
+	if _, err := wallet.Address(); err == nil {
+		t.Fatalf("no error for stopped plugin")
+	}
+}
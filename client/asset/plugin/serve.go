@@ -0,0 +1,613 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+)
+
+// tipPollTimeout is how long a Wallet.Tips request waits for a tip change.
+const tipPollTimeout = 30 * time.Second
+
+// maxTipChanges is the number of recent tip changes kept for Wallet.Tips.
+const maxTipChanges = 64
+
+// ServeStdio serves the driver on stdin and stdout until stdin is closed. A
+// wallet plugin written in Go calls ServeStdio from its main function, and
+// should log to stderr.
+func ServeStdio(assetID uint32, drv asset.Driver, logger dex.Logger) error {
+	return Serve(assetID, drv, &stdio{}, logger)
+}
+
+// stdio is the process's stdin and stdout.
+type stdio struct{}
+
+func (stdio) Read(b []byte) (int, error)  { return os.Stdin.Read(b) }
+func (stdio) Write(b []byte) (int, error) { return os.Stdout.Write(b) }
+func (stdio) Close() error                { return os.Stdout.Close() }
+
+// Serve serves the driver for the asset on the connection until it is closed.
+// One wallet at a time is served, which is set up with Plugin.Setup.
+func Serve(assetID uint32, drv asset.Driver, conn io.ReadWriteCloser, logger dex.Logger) error {
+	s := &server{
+		assetID:   assetID,
+		drv:       drv,
+		log:       logger,
+		tipSignal: make(chan struct{}),
+		coins:     make(map[string]asset.Coin),
+		audits:    make(map[string]asset.AuditInfo),
+	}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Plugin", &pluginHandler{s}); err != nil {
+		return err
+	}
+	if err := srv.RegisterName("Wallet", &walletHandler{s}); err != nil {
+		return err
+	}
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	s.disconnect()
+	return nil
+}
+
+// server is the state of a plugin serving a wallet.
+type server struct {
+	assetID uint32
+	drv     asset.Driver
+	log     dex.Logger
+
+	walletMtx sync.RWMutex
+	wallet    asset.Wallet
+	cancel    context.CancelFunc
+	wg        *sync.WaitGroup
+
+	tipMtx    sync.Mutex
+	tipSeq    uint64
+	tips      []string
+	tipSignal chan struct{}
+
+	// coins and audits are the coins and audit infos returned to the client,
+	// by coin ID, so that the wallet receives its own types back.
+	coinMtx sync.Mutex
+	coins   map[string]asset.Coin
+	audits  map[string]asset.AuditInfo
+}
+
+// tipChange records a tip change reported by the wallet.
+func (s *server) tipChange(err error) {
+	var errStr string
+	if err != nil {
+		errStr = err.Error()
+	}
+	s.tipMtx.Lock()
+	defer s.tipMtx.Unlock()
+	s.tipSeq++
+	s.tips = append(s.tips, errStr)
+	if len(s.tips) > maxTipChanges {
+		s.tips = s.tips[len(s.tips)-maxTipChanges:]
+	}
+	close(s.tipSignal)
+	s.tipSignal = make(chan struct{})
+}
+
+// tipsAfter returns the latest tip change sequence number and the tip changes
+// after the sequence number, and a channel that is closed on the next change.
+func (s *server) tipsAfter(after uint64) (uint64, []string, <-chan struct{}) {
+	s.tipMtx.Lock()
+	defer s.tipMtx.Unlock()
+	var errs []string
+	if s.tipSeq > after {
+		n := s.tipSeq - after
+		if n > uint64(len(s.tips)) {
+			n = uint64(len(s.tips))
+		}
+		errs = append(errs, s.tips[uint64(len(s.tips))-n:]...)
+	}
+	return s.tipSeq, errs, s.tipSignal
+}
+
+// getWallet is the wallet that was set up.
+func (s *server) getWallet() (asset.Wallet, error) {
+	s.walletMtx.RLock()
+	defer s.walletMtx.RUnlock()
+	if s.wallet == nil {
+		return nil, fmt.Errorf("wallet not set up")
+	}
+	return s.wallet, nil
+}
+
+// disconnect disconnects the wallet, if connected.
+func (s *server) disconnect() {
+	s.walletMtx.Lock()
+	cancel, wg := s.cancel, s.wg
+	s.cancel, s.wg = nil, nil
+	s.walletMtx.Unlock()
+	if cancel != nil {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// coinMsg describes the coin for the client, remembering the coin.
+func (s *server) coinMsg(c asset.Coin) *Coin {
+	s.coinMtx.Lock()
+	s.coins[c.ID().String()] = c
+	s.coinMtx.Unlock()
+	return encodeCoin(c)
+}
+
+// coinMsgs describes the coins for the client, remembering the coins.
+func (s *server) coinMsgs(coins asset.Coins) []*Coin {
+	cs := make([]*Coin, 0, len(coins))
+	for _, c := range coins {
+		cs = append(cs, s.coinMsg(c))
+	}
+	return cs
+}
+
+// coin is the wallet's coin described by the client. Coins that were not
+// returned by this process are requested as funding coins.
+func (s *server) coin(w asset.Wallet, c *Coin) (asset.Coin, error) {
+	if c == nil {
+		return nil, fmt.Errorf("no coin")
+	}
+	s.coinMtx.Lock()
+	known, found := s.coins[c.ID.String()]
+	s.coinMtx.Unlock()
+	if found {
+		return known, nil
+	}
+	coins, err := w.FundingCoins([]dex.Bytes{c.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) != 1 {
+		return nil, fmt.Errorf("expected 1 funding coin, got %d", len(coins))
+	}
+	return coins[0], nil
+}
+
+// forgetCoins forgets the coins, which are spent or returned.
+func (s *server) forgetCoins(coins asset.Coins) {
+	s.coinMtx.Lock()
+	defer s.coinMtx.Unlock()
+	for _, c := range coins {
+		delete(s.coins, c.ID().String())
+	}
+}
+
+// serviceErr converts the wallet error for the response, such that
+// asset.CoinNotFoundError is recognized by the client.
+func serviceErr(err error) error {
+	if errors.Is(err, asset.CoinNotFoundError) {
+		return asset.CoinNotFoundError
+	}
+	return err
+}
+
+// pluginHandler provides the Plugin methods.
+type pluginHandler struct {
+	s *server
+}
+
+// Info responds with the asset ID and wallet info.
+func (h *pluginHandler) Info(_ NoArgs, reply *InfoReply) error {
+	reply.AssetID = h.s.assetID
+	reply.Info = h.s.drv.Info()
+	return nil
+}
+
+// DecodeCoinID responds with the human-readable coin ID.
+func (h *pluginHandler) DecodeCoinID(coinID dex.Bytes, s *string) error {
+	str, err := h.s.drv.DecodeCoinID(coinID)
+	if err != nil {
+		return err
+	}
+	*s = str
+	return nil
+}
+
+// Setup sets up the wallet, replacing any wallet that was set up before.
+func (h *pluginHandler) Setup(args SetupArgs, ok *bool) error {
+	net, err := dex.NetFromString(args.Network)
+	if err != nil {
+		return err
+	}
+	cfg := &asset.WalletConfig{
+		Account:         args.Account,
+		FallbackFeeRate: args.FallbackFeeRate,
+		Settings:        args.Settings,
+		TipChange:       h.s.tipChange,
+		Seed:            args.Seed,
+	}
+	w, err := h.s.drv.Setup(cfg, h.s.log, net)
+	if err != nil {
+		return err
+	}
+	h.s.disconnect()
+	h.s.walletMtx.Lock()
+	h.s.wallet = w
+	h.s.walletMtx.Unlock()
+	*ok = true
+	return nil
+}
+
+// walletHandler provides the Wallet methods.
+type walletHandler struct {
+	s *server
+}
+
+// Connect connects the wallet.
+func (h *walletHandler) Connect(_ NoArgs, ok *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	h.s.disconnect()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, err := w.Connect(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	h.s.walletMtx.Lock()
+	h.s.cancel, h.s.wg = cancel, wg
+	h.s.walletMtx.Unlock()
+	*ok = true
+	return nil
+}
+
+// Disconnect disconnects the wallet.
+func (h *walletHandler) Disconnect(_ NoArgs, ok *bool) error {
+	h.s.disconnect()
+	*ok = true
+	return nil
+}
+
+// Tips waits up to tipPollTimeout for tip changes after the requested sequence
+// number.
+func (h *walletHandler) Tips(args TipsArgs, reply *TipsReply) error {
+	seq, errs, signal := h.s.tipsAfter(args.After)
+	if len(errs) == 0 && seq >= args.After {
+		select {
+		case <-signal:
+			seq, errs, _ = h.s.tipsAfter(args.After)
+		case <-time.After(tipPollTimeout):
+		}
+	}
+	reply.Seq = seq
+	reply.Errs = errs
+	return nil
+}
+
+// Balance responds with the wallet's balance.
+func (h *walletHandler) Balance(_ NoArgs, reply *asset.Balance) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	bal, err := w.Balance()
+	if err != nil {
+		return err
+	}
+	*reply = *bal
+	return nil
+}
+
+// FundOrder responds with the coins funding the order.
+func (h *walletHandler) FundOrder(args FundArgs, reply *[]*Coin) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	if args.Asset == nil {
+		return fmt.Errorf("no asset")
+	}
+	coins, err := w.FundOrder(args.Value, args.Asset)
+	if err != nil {
+		return err
+	}
+	*reply = h.s.coinMsgs(coins)
+	return nil
+}
+
+// ReturnCoins unlocks the coins.
+func (h *walletHandler) ReturnCoins(args []*Coin, ok *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	coins := make(asset.Coins, 0, len(args))
+	for _, c := range args {
+		coin, err := h.s.coin(w, c)
+		if err != nil {
+			return serviceErr(err)
+		}
+		coins = append(coins, coin)
+	}
+	if err := w.ReturnCoins(coins); err != nil {
+		return err
+	}
+	h.s.forgetCoins(coins)
+	*ok = true
+	return nil
+}
+
+// FundingCoins responds with the funding coins.
+func (h *walletHandler) FundingCoins(ids []dex.Bytes, reply *[]*Coin) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	coins, err := w.FundingCoins(ids)
+	if err != nil {
+		return serviceErr(err)
+	}
+	*reply = h.s.coinMsgs(coins)
+	return nil
+}
+
+// Swap sends the swaps.
+func (h *walletHandler) Swap(args SwapArgs, reply *SwapReply) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	swaps := &asset.Swaps{
+		Inputs:    make(asset.Coins, 0, len(args.Inputs)),
+		Contracts: make([]*asset.Contract, 0, len(args.Contracts)),
+		FeeRate:   args.FeeRate,
+	}
+	for _, c := range args.Inputs {
+		coin, err := h.s.coin(w, c)
+		if err != nil {
+			return serviceErr(err)
+		}
+		swaps.Inputs = append(swaps.Inputs, coin)
+	}
+	for _, c := range args.Contracts {
+		swaps.Contracts = append(swaps.Contracts, &asset.Contract{
+			Address:    c.Address,
+			Value:      c.Value,
+			SecretHash: c.SecretHash,
+			LockTime:   c.LockTime,
+		})
+	}
+	receipts, change, err := w.Swap(swaps)
+	if err != nil {
+		return serviceErr(err)
+	}
+	h.s.forgetCoins(swaps.Inputs)
+	for _, r := range receipts {
+		reply.Receipts = append(reply.Receipts, &Receipt{
+			Coin:       h.s.coinMsg(r.Coin()),
+			Expiration: encodeTime(r.Expiration()),
+			String:     r.String(),
+		})
+	}
+	if change != nil {
+		reply.Change = h.s.coinMsg(change)
+	}
+	return nil
+}
+
+// Redeem sends the redemptions.
+func (h *walletHandler) Redeem(args []*Redemption, reply *RedeemReply) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	redeems := make([]*asset.Redemption, 0, len(args))
+	for _, r := range args {
+		if r.Spends == nil || r.Spends.Coin == nil {
+			return fmt.Errorf("no contract to redeem")
+		}
+		ai, err := h.auditInfo(w, r.Spends.Coin)
+		if err != nil {
+			return serviceErr(err)
+		}
+		redeems = append(redeems, &asset.Redemption{
+			Spends: ai,
+			Secret: r.Secret,
+		})
+	}
+	inputs, coin, err := w.Redeem(redeems)
+	if err != nil {
+		return serviceErr(err)
+	}
+	h.s.coinMtx.Lock()
+	for _, r := range args {
+		delete(h.s.audits, r.Spends.Coin.ID.String())
+	}
+	h.s.coinMtx.Unlock()
+	reply.Inputs = inputs
+	reply.Coin = h.s.coinMsg(coin)
+	return nil
+}
+
+// auditInfo is the wallet's audit info for the contract coin, which is audited
+// again if it was not audited by this process.
+func (h *walletHandler) auditInfo(w asset.Wallet, c *Coin) (asset.AuditInfo, error) {
+	h.s.coinMtx.Lock()
+	ai, found := h.s.audits[c.ID.String()]
+	h.s.coinMtx.Unlock()
+	if found {
+		return ai, nil
+	}
+	return w.AuditContract(c.ID, c.Redeem)
+}
+
+// SignMessage signs the message with the coin's keys.
+func (h *walletHandler) SignMessage(args SignArgs, reply *SignReply) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	coin, err := h.s.coin(w, args.Coin)
+	if err != nil {
+		return serviceErr(err)
+	}
+	pubkeys, sigs, err := w.SignMessage(coin, args.Msg)
+	if err != nil {
+		return err
+	}
+	reply.PubKeys, reply.Sigs = pubkeys, sigs
+	return nil
+}
+
+// AuditContract responds with the contract's audit info.
+func (h *walletHandler) AuditContract(args ContractArgs, reply *AuditInfo) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	ai, err := w.AuditContract(args.CoinID, args.Contract)
+	if err != nil {
+		return serviceErr(err)
+	}
+	h.s.coinMtx.Lock()
+	h.s.audits[ai.Coin().ID().String()] = ai
+	h.s.coinMtx.Unlock()
+	*reply = *encodeAuditInfo(ai)
+	return nil
+}
+
+// LocktimeExpired responds with whether the contract's locktime has expired.
+func (h *walletHandler) LocktimeExpired(contract dex.Bytes, expired *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*expired, err = w.LocktimeExpired(contract)
+	return err
+}
+
+// FindRedemption responds with the secret of the redemption of the contract.
+func (h *walletHandler) FindRedemption(coinID dex.Bytes, secret *dex.Bytes) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*secret, err = w.FindRedemption(context.Background(), coinID)
+	return serviceErr(err)
+}
+
+// Refund refunds the contract.
+func (h *walletHandler) Refund(args ContractArgs, refundID *dex.Bytes) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*refundID, err = w.Refund(args.CoinID, args.Contract)
+	return serviceErr(err)
+}
+
+// Address responds with a new address.
+func (h *walletHandler) Address(_ NoArgs, addr *string) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*addr, err = w.Address()
+	return err
+}
+
+// Unlock unlocks the wallet.
+func (h *walletHandler) Unlock(args UnlockArgs, ok *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	if err := w.Unlock(args.Password, time.Duration(args.Duration)*time.Millisecond); err != nil {
+		return err
+	}
+	*ok = true
+	return nil
+}
+
+// Lock locks the wallet.
+func (h *walletHandler) Lock(_ NoArgs, ok *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	if err := w.Lock(); err != nil {
+		return err
+	}
+	*ok = true
+	return nil
+}
+
+// PayFee pays the registration fee.
+func (h *walletHandler) PayFee(args SendArgs, reply *Coin) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	coin, err := w.PayFee(args.Address, args.Value)
+	if err != nil {
+		return err
+	}
+	*reply = *h.s.coinMsg(coin)
+	return nil
+}
+
+// Withdraw withdraws to the address.
+func (h *walletHandler) Withdraw(args SendArgs, reply *Coin) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	coin, err := w.Withdraw(args.Address, args.Value)
+	if err != nil {
+		return err
+	}
+	*reply = *h.s.coinMsg(coin)
+	return nil
+}
+
+// Confirmations responds with the number of confirmations of the coin.
+func (h *walletHandler) Confirmations(coinID dex.Bytes, confs *uint32) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*confs, err = w.Confirmations(coinID)
+	return serviceErr(err)
+}
+
+// CoinConfirmations responds with the number of confirmations of a coin that
+// was returned to the client. The wallet's Confirmations is used for unknown
+// coins.
+func (h *walletHandler) CoinConfirmations(coinID dex.Bytes, confs *uint32) error {
+	h.s.coinMtx.Lock()
+	coin, found := h.s.coins[coinID.String()]
+	h.s.coinMtx.Unlock()
+	if !found {
+		return h.Confirmations(coinID, confs)
+	}
+	var err error
+	*confs, err = coin.Confirmations()
+	return serviceErr(err)
+}
+
+// ValidateSecret responds with whether the secret hashes to the secret hash.
+func (h *walletHandler) ValidateSecret(args SecretArgs, ok *bool) error {
+	w, err := h.s.getWallet()
+	if err != nil {
+		return err
+	}
+	*ok = w.ValidateSecret(args.Secret, args.SecretHash)
+	return nil
+}
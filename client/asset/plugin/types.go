@@ -0,0 +1,198 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package plugin
+
+import (
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+)
+
+// A wallet plugin is an executable that serves JSON-RPC 1.0, as implemented by
+// the standard library's net/rpc/jsonrpc package, on its stdin and stdout. Log
+// messages should be written to stderr. Each request is an object with the
+// fields "method", "params" and "id", where params is an array holding the
+// single argument described below. Each response is an object with the fields
+// "id", "result" and "error". Byte strings are hex encoded and times are in
+// milliseconds since the UNIX epoch. A plugin that cannot find a requested
+// coin must respond with the error "coin not found".
+//
+// The client starts the plugin once to request its Info, which is kept while
+// the client runs, and requests coin ID decoding from that process. Each
+// wallet is served by a separate process, which is set up with Setup, started
+// with Wallet.Connect and stopped after Wallet.Disconnect. The Wallet methods
+// mirror the asset.Wallet interface. The coins, receipts and audit info that a
+// plugin returns are sent back to the plugin by their descriptions, so the
+// plugin should remember them by coin ID.
+//
+//   Method                   Param            Result
+//   Plugin.Info              NoArgs           InfoReply
+//   Plugin.DecodeCoinID      hex string       string
+//   Plugin.Setup             SetupArgs        bool
+//   Wallet.Connect           NoArgs           bool
+//   Wallet.Disconnect        NoArgs           bool
+//   Wallet.Tips              TipsArgs         TipsReply
+//   Wallet.Balance           NoArgs           asset.Balance
+//   Wallet.FundOrder         FundArgs         []Coin
+//   Wallet.ReturnCoins       []Coin           bool
+//   Wallet.FundingCoins      []hex string     []Coin
+//   Wallet.Swap              SwapArgs         SwapReply
+//   Wallet.Redeem            []Redemption     RedeemReply
+//   Wallet.SignMessage       SignArgs         SignReply
+//   Wallet.AuditContract     ContractArgs     AuditInfo
+//   Wallet.LocktimeExpired   hex string       bool
+//   Wallet.FindRedemption    hex string       hex string
+//   Wallet.Refund            ContractArgs     hex string
+//   Wallet.Address           NoArgs           string
+//   Wallet.Unlock            UnlockArgs       bool
+//   Wallet.Lock              NoArgs           bool
+//   Wallet.PayFee            SendArgs         Coin
+//   Wallet.Confirmations     hex string       uint32
+//   Wallet.CoinConfirmations hex string       uint32
+//   Wallet.Withdraw          SendArgs         Coin
+//   Wallet.ValidateSecret    SecretArgs       bool
+
+// NoArgs is the argument of methods that take no arguments.
+type NoArgs struct{}
+
+// InfoReply is the result of Plugin.Info.
+type InfoReply struct {
+	// AssetID is the BIP-0044 asset ID of the plugin's asset.
+	AssetID uint32            `json:"assetid"`
+	Info    *asset.WalletInfo `json:"info"`
+}
+
+// SetupArgs are the arguments of Plugin.Setup, which correspond to the fields
+// of an asset.WalletConfig.
+type SetupArgs struct {
+	Account         string            `json:"account"`
+	FallbackFeeRate uint64            `json:"fallbackfeerate"`
+	Settings        map[string]string `json:"settings"`
+	Seed            dex.Bytes         `json:"seed,omitempty"`
+	Network         string            `json:"network"`
+}
+
+// TipsArgs are the arguments of Wallet.Tips, which waits for tip changes with a
+// sequence number after After.
+type TipsArgs struct {
+	After uint64 `json:"after"`
+}
+
+// TipsReply is the result of Wallet.Tips. Seq is the sequence number of the
+// latest tip change, and Errs holds the error message of each tip change after
+// the requested sequence number, with an empty string for success. Errs is
+// empty if no tip changed before the plugin's poll timeout.
+type TipsReply struct {
+	Seq  uint64   `json:"seq"`
+	Errs []string `json:"errs"`
+}
+
+// Coin describes an asset.Coin.
+type Coin struct {
+	ID     dex.Bytes `json:"id"`
+	String string    `json:"string"`
+	Value  uint64    `json:"value"`
+	Redeem dex.Bytes `json:"redeem,omitempty"`
+}
+
+// Receipt describes an asset.Receipt.
+type Receipt struct {
+	Coin       *Coin  `json:"coin"`
+	Expiration uint64 `json:"expiration"`
+	String     string `json:"string"`
+}
+
+// AuditInfo describes an asset.AuditInfo.
+type AuditInfo struct {
+	Recipient  string    `json:"recipient"`
+	Expiration uint64    `json:"expiration"`
+	Coin       *Coin     `json:"coin"`
+	SecretHash dex.Bytes `json:"secrethash"`
+}
+
+// FundArgs are the arguments of Wallet.FundOrder.
+type FundArgs struct {
+	Value uint64     `json:"value"`
+	Asset *dex.Asset `json:"asset"`
+}
+
+// Contract describes an asset.Contract.
+type Contract struct {
+	Address    string    `json:"address"`
+	Value      uint64    `json:"value"`
+	SecretHash dex.Bytes `json:"secrethash"`
+	LockTime   uint64    `json:"locktime"`
+}
+
+// SwapArgs are the arguments of Wallet.Swap, which describe an asset.Swaps.
+type SwapArgs struct {
+	Inputs    []*Coin     `json:"inputs"`
+	Contracts []*Contract `json:"contracts"`
+	FeeRate   uint64      `json:"feerate"`
+}
+
+// SwapReply is the result of Wallet.Swap.
+type SwapReply struct {
+	Receipts []*Receipt `json:"receipts"`
+	Change   *Coin      `json:"change,omitempty"`
+}
+
+// Redemption describes an asset.Redemption.
+type Redemption struct {
+	Spends *AuditInfo `json:"spends"`
+	Secret dex.Bytes  `json:"secret"`
+}
+
+// RedeemReply is the result of Wallet.Redeem.
+type RedeemReply struct {
+	Inputs []dex.Bytes `json:"inputs"`
+	Coin   *Coin       `json:"coin"`
+}
+
+// SignArgs are the arguments of Wallet.SignMessage.
+type SignArgs struct {
+	Coin *Coin     `json:"coin"`
+	Msg  dex.Bytes `json:"msg"`
+}
+
+// SignReply is the result of Wallet.SignMessage.
+type SignReply struct {
+	PubKeys []dex.Bytes `json:"pubkeys"`
+	Sigs    []dex.Bytes `json:"sigs"`
+}
+
+// ContractArgs are the arguments of Wallet.AuditContract and Wallet.Refund.
+type ContractArgs struct {
+	CoinID   dex.Bytes `json:"coinid"`
+	Contract dex.Bytes `json:"contract"`
+}
+
+// UnlockArgs are the arguments of Wallet.Unlock. Duration is in milliseconds.
+type UnlockArgs struct {
+	Password string `json:"password"`
+	Duration uint64 `json:"duration"`
+}
+
+// SendArgs are the arguments of Wallet.PayFee and Wallet.Withdraw.
+type SendArgs struct {
+	Address string `json:"address"`
+	Value   uint64 `json:"value"`
+}
+
+// SecretArgs are the arguments of Wallet.ValidateSecret.
+type SecretArgs struct {
+	Secret     dex.Bytes `json:"secret"`
+	SecretHash dex.Bytes `json:"secrethash"`
+}
+
+// encodeTime encodes the time in milliseconds since the UNIX epoch.
+func encodeTime(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// decodeTime decodes the time from milliseconds since the UNIX epoch.
+func decodeTime(ms uint64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
@@ -12,4 +12,5 @@ require (
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/jrick/logrotate v1.0.0
 	github.com/rivo/tview v0.0.0-20191129065140-82b05c9fb329
+	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
 )
@@ -0,0 +1,211 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"decred.org/dcrdex/client/cmd/dexc/ui"
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/encrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// credentials are the secrets that a headless dexc starts with.
+type credentials struct {
+	AppPass string `json:"appPass"`
+}
+
+// credsFile is the encrypted credentials file. The credentials are encrypted
+// with a key derived from the file's passphrase using the key parameters.
+type credsFile struct {
+	KeyParams dex.Bytes `json:"keyParams"`
+	Creds     dex.Bytes `json:"creds"`
+}
+
+// stdin reads the lines of secrets piped to a headless dexc.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readSecret reads a secret from stdin, prompting for it without echo if stdin
+// is a terminal.
+func readSecret(prompt string) ([]byte, error) {
+	fd := int(syscall.Stdin)
+	if terminal.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return secret, err
+	}
+	line, err := stdin.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("error reading stdin: %v", err)
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// credsPassphrase gets the passphrase of the credentials file from the
+// configured command, or from stdin.
+func credsPassphrase(ctx context.Context, cfg *ui.Config) ([]byte, error) {
+	if cfg.CredsPassCmd == "" {
+		pass, err := readSecret("Credentials passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if len(pass) == 0 {
+			return nil, fmt.Errorf("empty credentials passphrase")
+		}
+		return pass, nil
+	}
+	args := strings.Fields(cfg.CredsPassCmd)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running credentials passphrase command: %v", err)
+	}
+	pass := bytes.TrimRight(out, "\r\n")
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("credentials passphrase command printed no passphrase")
+	}
+	return pass, nil
+}
+
+// newCredentials creates the encrypted credentials file from the app password
+// and passphrase read from stdin. The passphrase is instead read from the
+// configured command if there is one, so that it may be kept in a keyring.
+func newCredentials(ctx context.Context, cfg *ui.Config) error {
+	if _, err := os.Stat(cfg.CredsFile); err == nil {
+		return fmt.Errorf("credentials file %s already exists", cfg.CredsFile)
+	}
+	appPW, err := readSecret("App password: ")
+	if err != nil {
+		return err
+	}
+	defer encode.ClearBytes(appPW)
+	if len(appPW) == 0 {
+		return fmt.Errorf("empty app password")
+	}
+	pass, err := credsPassphrase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer encode.ClearBytes(pass)
+
+	b, err := json.Marshal(&credentials{AppPass: string(appPW)})
+	if err != nil {
+		return fmt.Errorf("error encoding credentials: %v", err)
+	}
+	defer encode.ClearBytes(b)
+	crypter := encrypt.NewCrypter(pass)
+	defer crypter.Close()
+	enc, err := crypter.Encrypt(b)
+	if err != nil {
+		return fmt.Errorf("error encrypting credentials: %v", err)
+	}
+	fileB, err := json.MarshalIndent(&credsFile{
+		KeyParams: crypter.Serialize(),
+		Creds:     enc,
+	}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error encoding credentials file: %v", err)
+	}
+	if err = ioutil.WriteFile(cfg.CredsFile, fileB, 0600); err != nil {
+		return fmt.Errorf("error writing credentials file: %v", err)
+	}
+	return nil
+}
+
+// loadCredentials decrypts the credentials file.
+func loadCredentials(ctx context.Context, cfg *ui.Config) (*credentials, error) {
+	fileB, err := ioutil.ReadFile(cfg.CredsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials file: %v", err)
+	}
+	file := new(credsFile)
+	if err = json.Unmarshal(fileB, file); err != nil {
+		return nil, fmt.Errorf("error decoding credentials file %s: %v", cfg.CredsFile, err)
+	}
+	pass, err := credsPassphrase(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer encode.ClearBytes(pass)
+	crypter, err := encrypt.Deserialize(pass, file.KeyParams)
+	if err != nil {
+		return nil, fmt.Errorf("error unlocking credentials file: %v", err)
+	}
+	defer crypter.Close()
+	b, err := crypter.Decrypt(file.Creds)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting credentials. wrong passphrase?")
+	}
+	defer encode.ClearBytes(b)
+	creds := new(credentials)
+	if err = json.Unmarshal(b, creds); err != nil {
+		return nil, fmt.Errorf("error decoding credentials: %v", err)
+	}
+	return creds, nil
+}
+
+// startHeadless logs in with the credentials once the core is running. The
+// client is initialized with the app password if it is new. The wallets are
+// unlocked, and the DEX accounts are logged in. Wallets that can't be unlocked
+// and DEX accounts that fail to log in are logged, but are not fatal, since
+// the daemon may still be monitored and their problems fixed over RPC.
+func startHeadless(ctx context.Context, clientCore *core.Core, creds *credentials) error {
+	select {
+	case <-clientCore.Ready():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	appPW := []byte(creds.AppPass)
+	defer encode.ClearBytes(appPW)
+
+	initialized, err := clientCore.IsInitialized()
+	if err != nil {
+		return fmt.Errorf("error checking if the client is initialized: %v", err)
+	}
+	if !initialized {
+		if err = clientCore.InitializeClient(appPW); err != nil {
+			return fmt.Errorf("error initializing the client: %v", err)
+		}
+		log.Infof("Initialized the client with the app password from the credentials file.")
+	}
+
+	for assetID, a := range clientCore.User().Assets {
+		if a.Wallet == nil {
+			continue
+		}
+		if err = clientCore.OpenWallet(assetID, appPW); err != nil {
+			log.Errorf("Error unlocking the %s wallet: %v", a.Symbol, err)
+			continue
+		}
+		log.Infof("Unlocked the %s wallet.", a.Symbol)
+	}
+
+	res, err := clientCore.Login(appPW)
+	if err != nil {
+		return fmt.Errorf("login error: %v", err)
+	}
+	for _, dexStat := range res.DEXes {
+		if !dexStat.Authed {
+			log.Errorf("Failed to log in to %s: %s", dexStat.Host, dexStat.AuthErr)
+			continue
+		}
+		log.Infof("Logged in to %s with %d active trades.", dexStat.Host, len(dexStat.TradeIDs))
+	}
+	log.Infof("Headless startup complete.")
+	return nil
+}
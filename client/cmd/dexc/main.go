@@ -12,9 +12,12 @@ import (
 
 	_ "decred.org/dcrdex/client/asset/btc" // register btc asset
 	_ "decred.org/dcrdex/client/asset/dcr" // register dcr asset
+	_ "decred.org/dcrdex/client/asset/eth" // register eth and token assets
 	_ "decred.org/dcrdex/client/asset/ltc" // register ltc asset
+	"decred.org/dcrdex/client/asset/plugin"
 	"decred.org/dcrdex/client/cmd/dexc/ui"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/client/rpcserver"
 	"decred.org/dcrdex/client/webserver"
 	"decred.org/dcrdex/dex"
@@ -52,10 +55,31 @@ func main() {
 		os.Exit(0)
 	}
 
+	if cfg.NewCreds {
+		if err := newCredentials(appCtx, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error creating credentials file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote credentials file %s\n", cfg.CredsFile)
+		os.Exit(0)
+	}
+
+	// A headless daemon has no web server, and is unlocked with the
+	// credentials file before anything is started.
+	var creds *credentials
+	if cfg.Headless {
+		cfg.NoWeb = true
+		creds, err = loadCredentials(appCtx, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// If explicitly running without web server then you must run the rpc
-	// server or the terminal ui.
-	if cfg.NoWeb && !cfg.RPCOn {
-		fmt.Fprintf(os.Stderr, "Cannot run without web server unless --rpc or --tui is specified\n")
+	// server or the terminal ui, or be headless.
+	if cfg.NoWeb && !cfg.RPCOn && !cfg.Headless {
+		fmt.Fprintf(os.Stderr, "Cannot run without web server unless --rpc, --tui or --headless is specified\n")
 		os.Exit(1)
 	}
 
@@ -67,10 +91,24 @@ func main() {
 	logMaker := ui.InitLogging(logStdout, cfg.DebugLevel)
 	core.UseLoggerMaker(logMaker)
 	log = logMaker.Logger("DEXC")
+	if cfg.ReadOnly {
+		log.Infof("Starting in read-only mode. Log in to enable trading and wallet requests.")
+	}
+
+	for _, path := range cfg.Plugins {
+		assetID, err := plugin.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		log.Infof("Loaded %s wallet plugin %s.", dex.BipIDSymbol(assetID), path)
+	}
 
 	clientCore, err := core.New(&core.Config{
-		DBPath: cfg.DBPath, // global set in config.go
-		Net:    cfg.Net,
+		DBPath:     cfg.DBPath, // global set in config.go
+		Net:        cfg.Net,
+		RateSource: cfg.RateSource,
+		Language:   cfg.Language,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error creating client core: %v\n", err)
@@ -84,9 +122,25 @@ func main() {
 		wg.Done()
 	}()
 
+	if creds != nil {
+		go func() {
+			if err := startHeadless(appCtx, clientCore, creds); err != nil {
+				log.Errorf("Error starting headless: %v", err)
+				cancel()
+			}
+		}()
+	}
+
+	marketMaker := mm.New(clientCore, cfg.RateSource, logMaker.Logger("MM"))
+	wg.Add(1)
+	go func() {
+		marketMaker.Run(appCtx)
+		wg.Done()
+	}()
+
 	if cfg.RPCOn {
 		rpcserver.SetLogger(logMaker.Logger("RPC"))
-		rpcCfg := &rpcserver.Config{clientCore, cfg.RPCAddr, cfg.RPCUser, cfg.RPCPass, cfg.RPCCert, cfg.RPCKey}
+		rpcCfg := &rpcserver.Config{clientCore, cfg.RPCAddr, cfg.RPCUser, cfg.RPCPass, cfg.RPCCert, cfg.RPCKey, marketMaker, cfg.ReadOnly}
 		rpcSrv, err := rpcserver.New(rpcCfg)
 		if err != nil {
 			log.Errorf("Error creating rpc server: %v", err)
@@ -108,7 +162,7 @@ func main() {
 
 	if !cfg.NoWeb {
 		wg.Add(1)
-		webSrv, err := webserver.New(clientCore, cfg.WebAddr, logMaker.Logger("WEB"), cfg.ReloadHTML)
+		webSrv, err := webserver.New(clientCore, marketMaker, cfg.WebAddr, logMaker.Logger("WEB"), cfg.ReloadHTML, cfg.WebAPIKeys, cfg.ReadOnly)
 		if err != nil {
 			log.Errorf("Error creating web server: %v", err)
 			cancel()
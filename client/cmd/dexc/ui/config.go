@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 
+	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/dex"
 	"github.com/decred/dcrd/dcrutil/v2"
 	flags "github.com/jessevdk/go-flags"
@@ -23,6 +24,7 @@ const (
 	defaultRPCKeyFile  = "rpc.key"
 	defaultWebAddr     = "localhost:5758"
 	configFilename     = "dexc.conf"
+	credsFilename      = "credentials.json"
 	defaultLogLevel    = "info"
 )
 
@@ -55,23 +57,35 @@ func setNet(applicationDirectory, net string) string {
 
 // Config is the configuration for the DEX client application.
 type Config struct {
-	AppData    string `long:"appdata" description:"Path to application directory."`
-	Config     string `long:"config" description:"Path to an INI configuration file."`
-	DBPath     string `long:"db" description:"Database filepath. Database will be created if it does not exist."`
-	RPCOn      bool   `long:"rpc" description:"turn on the rpc server"`
-	RPCAddr    string `long:"rpcaddr" description:"RPC server listen address"`
-	RPCUser    string `long:"rpcuser" description:"RPC server user name"`
-	RPCPass    string `long:"rpcpass" description:"RPC server password"`
-	RPCCert    string `long:"rpccert" description:"RPC server certificate file location"`
-	RPCKey     string `long:"rpckey" description:"RPC server key file location"`
-	WebAddr    string `long:"webaddr" description:"HTTP server address"`
-	NoWeb      bool   `long:"noweb" description:"disable the web server."`
-	TUI        bool   `long:"tui" description:"enable the terminal-based user interface."`
-	Testnet    bool   `long:"testnet" description:"use testnet"`
-	Simnet     bool   `long:"simnet" description:"use simnet"`
-	ReloadHTML bool   `long:"reload-html" description:"Reload the webserver's page template with every request. For development purposes."`
-	DebugLevel string `long:"log" description:"Logging level {trace, debug, info, warn, error, critical}"`
-	Net        dex.Network
+	AppData      string   `long:"appdata" description:"Path to application directory."`
+	Config       string   `long:"config" description:"Path to an INI configuration file."`
+	DBPath       string   `long:"db" description:"Database filepath. Database will be created if it does not exist."`
+	RPCOn        bool     `long:"rpc" description:"turn on the rpc server"`
+	RPCAddr      string   `long:"rpcaddr" description:"RPC server listen address"`
+	RPCUser      string   `long:"rpcuser" description:"RPC server user name"`
+	RPCPass      string   `long:"rpcpass" description:"RPC server password"`
+	RPCCert      string   `long:"rpccert" description:"RPC server certificate file location"`
+	RPCKey       string   `long:"rpckey" description:"RPC server key file location"`
+	WebAddr      string   `long:"webaddr" description:"HTTP server address"`
+	NoWeb        bool     `long:"noweb" description:"disable the web server."`
+	WebAPIKeys   []string `long:"webapikey" description:"API key for the web server's versioned JSON API at /api/v1. May be specified multiple times. API tokens can also be created from the settings API."`
+	ReadOnly     bool     `long:"readonly" description:"Start in read-only mode. The markets, order books and order history are available from the web and RPC servers, but other requests are refused until login with the app password."`
+	TUI          bool     `long:"tui" description:"enable the terminal-based user interface."`
+	Testnet      bool     `long:"testnet" description:"use testnet"`
+	Simnet       bool     `long:"simnet" description:"use simnet"`
+	ReloadHTML   bool     `long:"reload-html" description:"Reload the webserver's page template with every request. For development purposes."`
+	FiatRates    []string `long:"fiatrates" description:"Enable fiat exchange rates from the named source {coingecko, coinpaprika, binance}. May be specified multiple times. The median of the sources' rates is used."`
+	Plugins      []string `long:"walletplugin" description:"Path to a wallet plugin executable, which provides the wallet for an asset that is not built in. May be specified multiple times."`
+	DebugLevel   string   `long:"log" description:"Logging level {trace, debug, info, warn, error, critical}"`
+	Language     string   `long:"lang" description:"Language of notifications {en-US, es-ES}. The web server renders notifications and errors in the language selected by each browser instead, if it is supported."`
+	Headless     bool     `long:"headless" description:"Run as a daemon without the web server or terminal UI. The wallets are unlocked and the DEX accounts logged in with the app password from the encrypted credentials file. The RPC server may still be enabled with --rpc."`
+	CredsFile    string   `long:"credsfile" description:"Path to the encrypted credentials file used by --headless and created by --newcreds. Defaults to credentials.json in the network directory."`
+	CredsPassCmd string   `long:"credspasscmd" description:"Command that prints the passphrase of the credentials file, such as a keyring lookup, e.g. \"secret-tool lookup application dexc\". The passphrase is read from stdin if no command is set."`
+	NewCreds     bool     `long:"newcreds" description:"Create the encrypted credentials file from the app password and passphrase read from stdin, and exit."`
+	Net          dex.Network
+	// RateSource is the fiat rate source created from the FiatRates sources,
+	// or nil if none were specified.
+	RateSource core.RateSource
 }
 
 var defaultConfig = Config{
@@ -163,6 +177,24 @@ func Configure() (*Config, error) {
 		cfg.DBPath = defaultDBPath
 	}
 
+	if cfg.CredsFile == "" {
+		cfg.CredsFile = filepath.Join(netDirectory, credsFilename)
+	} else {
+		cfg.CredsFile = cleanAndExpandPath(cfg.CredsFile)
+	}
+
+	if cfg.Headless && cfg.TUI {
+		return nil, fmt.Errorf("headless and tui cannot both be specified")
+	}
+
+	if len(cfg.FiatRates) > 0 {
+		rateSource, err := core.NewFiatRateSource(cfg.FiatRates...)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RateSource = rateSource
+	}
+
 	return cfg, nil
 }
 
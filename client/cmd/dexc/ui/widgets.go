@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/client/rpcserver"
 	"decred.org/dcrdex/client/webserver"
 	"decred.org/dcrdex/dex"
@@ -26,6 +27,9 @@ var (
 	// The core DEX client application. Used by both the RPC server and the
 	// web server.
 	clientCore *core.Core
+	// The market maker bots, controlled through the RPC server and the web
+	// server.
+	marketMaker *mm.MarketMaker
 	// These are the main view widgets and loggers attached to their journals.
 	screen            *Screen
 	mainMenu          *chooser
@@ -108,14 +112,17 @@ func createApp() {
 	core.UseLoggerMaker(lm)
 
 	clientCore, err = core.New(&core.Config{
-		DBPath: cfg.DBPath, // global set in config.go
-		Net:    cfg.Net,
+		DBPath:     cfg.DBPath, // global set in config.go
+		Net:        cfg.Net,
+		RateSource: cfg.RateSource,
 	})
 	if err != nil {
 		log.Errorf("error creating client core: %v", err)
 		return
 	}
 	go clientCore.Run(appCtx)
+	marketMaker = mm.New(clientCore, cfg.RateSource, lm.Logger("MM"))
+	go marketMaker.Run(appCtx)
 	createWidgets()
 	// Create the Screen, which is the top-level layout manager.
 	flex := tview.NewFlex().
@@ -157,7 +164,7 @@ func createWidgets() {
 	webView = newServerView("Web", cfg.WebAddr, func(ctx context.Context, addr string, logger slog.Logger) {
 		setWebLabelOn(true)
 		defer setWebLabelOn(false)
-		webSrv, err := webserver.New(clientCore, cfg.WebAddr, logger, cfg.ReloadHTML)
+		webSrv, err := webserver.New(clientCore, marketMaker, cfg.WebAddr, logger, cfg.ReloadHTML, cfg.WebAPIKeys, cfg.ReadOnly)
 		if err != nil {
 			log.Errorf("Error creating web server: %v", err)
 			return
@@ -174,7 +181,7 @@ func createWidgets() {
 		setRPCLabelOn(true)
 		defer setRPCLabelOn(false)
 		rpcserver.SetLogger(logger)
-		rpcCfg := &rpcserver.Config{clientCore, cfg.RPCAddr, cfg.RPCUser, cfg.RPCPass, cfg.RPCCert, cfg.RPCKey}
+		rpcCfg := &rpcserver.Config{clientCore, cfg.RPCAddr, cfg.RPCUser, cfg.RPCPass, cfg.RPCCert, cfg.RPCKey, marketMaker, cfg.ReadOnly}
 		rpcSrv, err := rpcserver.New(rpcCfg)
 		if err != nil {
 			log.Errorf("Error starting rpc server: %v", err)
@@ -52,14 +52,25 @@ func main() {
 // promptPasswords is a map of routes to password prompts. Passwords are
 // prompted in the order given.
 var promptPasswords = map[string][]string{
-	"cancel":     {"App password:"},
-	"init":       {"Set new app password:"},
-	"login":      {"App password:"},
-	"newwallet":  {"App password:", "Wallet password:"},
-	"openwallet": {"App password:"},
-	"register":   {"App password:"},
-	"trade":      {"App password:"},
-	"withdraw":   {"App password:"},
+	"appseed":       {"App password:"},
+	"batch":         {"App password:"},
+	"cancel":        {"App password:"},
+	"cancelall":     {"App password:"},
+	"cloneorder":    {"App password:"},
+	"exportbackup":  {"App password:"},
+	"init":          {"Set new app password:"},
+	"login":         {"App password:"},
+	"newwallet":     {"App password:", "Wallet password:"},
+	"openwallet":    {"App password:"},
+	"refundswap":    {"App password:"},
+	"register":      {"App password:"},
+	"respondprompt": {"Device PIN or passphrase:"},
+	"restorebackup": {"Backup app password:"},
+	"restoreseed":   {"Set new app password:", "Seed words:"},
+	"sendmany":      {"App password:"},
+	"startbot":      {"App password:"},
+	"trade":         {"App password:"},
+	"withdraw":      {"App password:"},
 }
 
 // optionalTextFiles is a map of routes to arg index for routes that should read
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"decred.org/dcrdex/dex/msgjson"
+	"github.com/decred/go-socks/socks"
 	"github.com/gorilla/websocket"
 )
 
@@ -27,8 +28,9 @@ const (
 	// The maximum time in seconds to write to a connection.
 	writeWait = time.Second * 3
 
-	// reconnetInterval is the initial and increment between reconnect tries.
-	reconnectInterval = 5 * time.Second
+	// reconnectInterval is the initial wait between reconnect tries. The wait
+	// doubles after each failed try, up to maxReconnectInterval.
+	reconnectInterval = time.Second
 
 	// maxReconnetInterval is the maximum allowed reconnect interval.
 	maxReconnectInterval = time.Minute
@@ -66,6 +68,15 @@ type WsCfg struct {
 	PingWait time.Duration
 	// The server's certificate.
 	Cert []byte
+	// Proxy is the address of a SOCKS5 proxy to connect through. If not set,
+	// any proxy set in the environment is used.
+	Proxy string
+	// ProxyUser and ProxyPass are the proxy credentials, if required.
+	ProxyUser string
+	ProxyPass string
+	// TorIsolation uses random proxy credentials for each connection, so that
+	// Tor uses a new circuit for each connection.
+	TorIsolation bool
 	// ReconnectSync runs the needed reconnection synchronization after
 	// a reconnect.
 	ReconnectSync func()
@@ -165,6 +176,16 @@ func (conn *wsConn) connect(ctx context.Context) error {
 		HandshakeTimeout: 10 * time.Second,
 		TLSClientConfig:  conn.tlsCfg,
 	}
+	if conn.cfg.Proxy != "" {
+		proxy := &socks.Proxy{
+			Addr:         conn.cfg.Proxy,
+			Username:     conn.cfg.ProxyUser,
+			Password:     conn.cfg.ProxyPass,
+			TorIsolation: conn.cfg.TorIsolation,
+		}
+		dialer.Proxy = nil
+		dialer.NetDial = proxy.Dial
+	}
 
 	ws, _, err := dialer.Dial(conn.cfg.URL, nil)
 	if err != nil {
@@ -330,9 +351,10 @@ func (conn *wsConn) keepAlive(ctx context.Context) {
 				conn.reconnects++
 				if conn.reconnects+1 < maxReconnects {
 					conn.queueReconnect(rcInt)
-					// Increment the wait up to PingWait.
-					if rcInt < maxReconnectInterval {
-						rcInt += reconnectInterval
+					// Back off up to maxReconnectInterval.
+					rcInt *= 2
+					if rcInt > maxReconnectInterval {
+						rcInt = maxReconnectInterval
 					}
 				}
 				continue
@@ -0,0 +1,112 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A client may hold more than one account at a DEX server, e.g. to separate a
+// bot's funds from manual trading. Each additional account is identified by an
+// account host, which is the DEX host prefixed with the account's label and an
+// @, e.g. bot@dex.example.com:7232. The host alone identifies the default
+// account. The conns map, the database and the settings that are kept per
+// account are keyed by account host, so each account has its own connection,
+// its own key derived from the app seed, and its own orders.
+
+// maxAccountLabelLen is the maximum length of an account label.
+const maxAccountLabelLen = 32
+
+// accountHost is the account host of the labeled account at the DEX host. An
+// empty label is the default account, whose account host is the host.
+func accountHost(label, host string) string {
+	if label == "" {
+		return host
+	}
+	return label + "@" + host
+}
+
+// splitAccountHost splits the account host into the account label and the DEX
+// host. The label is empty for the default account.
+func splitAccountHost(acctHost string) (label, host string) {
+	i := strings.Index(acctHost, "@")
+	if i <= 0 || strings.ContainsAny(acctHost[:i], ":/") {
+		return "", acctHost
+	}
+	return acctHost[:i], acctHost[i+1:]
+}
+
+// selectAccount is the account host of the labeled account at the DEX address.
+// An empty label selects the account of the address itself, which may already
+// be an account host.
+func selectAccount(addr, label string) string {
+	host := addrHost(addr)
+	if label == "" {
+		return host
+	}
+	_, host = splitAccountHost(host)
+	return accountHost(label, host)
+}
+
+// checkAccountLabel checks that the account label is short and contains only
+// letters, numbers, dashes and underscores.
+func checkAccountLabel(label string) error {
+	if len(label) > maxAccountLabelLen {
+		return fmt.Errorf("account label longer than %d characters", maxAccountLabelLen)
+	}
+	for _, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return fmt.Errorf("invalid character %q in account label", r)
+		}
+	}
+	return nil
+}
+
+// acctHost is the account host of the account selected by the form.
+func (form *TradeForm) acctHost() string {
+	return selectAccount(form.Host, form.Account)
+}
+
+// dexCert is the TLS certificate of another account at the DEX of the account
+// host, or nil if there is none.
+func (c *Core) dexCert(acctHost string) []byte {
+	_, host := splitAccountHost(acctHost)
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	for ah, dc := range c.conns {
+		if _, h := splitAccountHost(ah); h != host {
+			continue
+		}
+		if cert := dc.acct.certificate(); len(cert) > 0 {
+			return cert
+		}
+	}
+	return nil
+}
+
+// DEXAccounts returns the account hosts of the accounts at the DEX server,
+// sorted with the default account first.
+func (c *Core) DEXAccounts(dexAddr string) []string {
+	_, host := splitAccountHost(addrHost(dexAddr))
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	accts := make([]string, 0, 1)
+	for acctHost := range c.conns {
+		if _, h := splitAccountHost(acctHost); h == host {
+			accts = append(accts, acctHost)
+		}
+	}
+	sort.Slice(accts, func(i, j int) bool {
+		// The default account has no label and sorts before the others.
+		if accts[i] == host || accts[j] == host {
+			return accts[i] == host
+		}
+		return accts[i] < accts[j]
+	})
+	return accts
+}
@@ -0,0 +1,190 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	orderbook "decred.org/dcrdex/client/order"
+)
+
+// syncedBooks returns the order books for the market pair from every DEX
+// connection that has synced the market's book, keyed by host. The returned
+// hosts are sorted.
+func (c *Core) syncedBooks(base, quote uint32) ([]string, map[string]*bookie) {
+	mkt := marketName(base, quote)
+	books := make(map[string]*bookie)
+	hosts := make([]string, 0)
+	c.connMtx.RLock()
+	for host, dc := range c.conns {
+		dc.booksMtx.RLock()
+		book, found := dc.books[mkt]
+		dc.booksMtx.RUnlock()
+		if found {
+			books[host] = book
+			hosts = append(hosts, host)
+		}
+	}
+	c.connMtx.RUnlock()
+	sort.Strings(hosts)
+	return hosts, books
+}
+
+// AggregateBook combines the order books for a market pair from every DEX with
+// a synced book. Sync must be called for each DEX that should be included.
+func (c *Core) AggregateBook(base, quote uint32) (*AggregateOrderBook, error) {
+	hosts, books := c.syncedBooks(base, quote)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no synced order books for market %s", marketName(base, quote))
+	}
+
+	agg := &AggregateOrderBook{
+		BaseID:  base,
+		QuoteID: quote,
+		Hosts:   hosts,
+	}
+	for _, host := range hosts {
+		buys, sells, _ := books[host].Orders()
+		agg.Buys = append(agg.Buys, hostBookSide(host, buys)...)
+		agg.Sells = append(agg.Sells, hostBookSide(host, sells)...)
+	}
+	// The stable sort keeps each host's time priority, and breaks ties between
+	// hosts by host name.
+	sort.SliceStable(agg.Buys, func(i, j int) bool {
+		return agg.Buys[i].Rate > agg.Buys[j].Rate
+	})
+	sort.SliceStable(agg.Sells, func(i, j int) bool {
+		return agg.Sells[i].Rate < agg.Sells[j].Rate
+	})
+	if len(agg.Sells) > 0 {
+		agg.BuyVenue = agg.Sells[0].Host
+	}
+	if len(agg.Buys) > 0 {
+		agg.SellVenue = agg.Buys[0].Host
+	}
+	return agg, nil
+}
+
+// hostBookSide translates the orders and tags them with the host.
+func hostBookSide(host string, ords []*orderbook.Order) []*MiniOrder {
+	minis := translateBookSide(ords)
+	for _, mini := range minis {
+		mini.Host = host
+	}
+	return minis
+}
+
+// BestVenues estimates how an order for qty units of the base asset would fill
+// on each DEX with a synced book for the market pair, and returns the estimates
+// sorted best first. A venue that can fill the entire quantity is preferred,
+// followed by the venue with the best average rate.
+func (c *Core) BestVenues(base, quote uint32, sell bool, qty uint64) ([]*VenueHint, error) {
+	if qty == 0 {
+		return nil, fmt.Errorf("zero quantity")
+	}
+	hosts, books := c.syncedBooks(base, quote)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no synced order books for market %s", marketName(base, quote))
+	}
+
+	hints := make([]*VenueHint, 0, len(hosts))
+	for _, host := range hosts {
+		buys, sells, _ := books[host].Orders()
+		// A sell order fills against the buy side of the book.
+		side := sells
+		if sell {
+			side = buys
+		}
+		hints = append(hints, venueHint(host, side, qty))
+	}
+
+	sort.SliceStable(hints, func(i, j int) bool {
+		hi, hj := hints[i], hints[j]
+		if hi.Complete != hj.Complete {
+			return hi.Complete
+		}
+		if hi.Qty == 0 || hj.Qty == 0 {
+			return hi.Qty > hj.Qty
+		}
+		if hi.Rate != hj.Rate {
+			if sell {
+				return hi.Rate > hj.Rate
+			}
+			return hi.Rate < hj.Rate
+		}
+		return hi.Qty > hj.Qty
+	})
+	return hints, nil
+}
+
+// venueHint walks the sorted book side to estimate the fill for qty.
+func venueHint(host string, side []*orderbook.Order, qty uint64) *VenueHint {
+	var filled uint64
+	var weighted float64
+	for _, ord := range side {
+		if filled == qty {
+			break
+		}
+		q := ord.Quantity
+		if q > qty-filled {
+			q = qty - filled
+		}
+		filled += q
+		weighted += float64(q) * float64(ord.Rate)
+	}
+	hint := &VenueHint{
+		Host:     host,
+		Qty:      float64(filled) / conversionFactor,
+		Complete: filled == qty,
+	}
+	if filled > 0 {
+		hint.Rate = weighted / float64(filled) / conversionFactor
+	}
+	return hint
+}
+
+// AggregateOrders lists the user's orders and their matches for a market pair
+// across all DEX servers.
+func (c *Core) AggregateOrders(base, quote uint32) *AggregateOrders {
+	mkt := marketName(base, quote)
+	agg := &AggregateOrders{
+		BaseID:  base,
+		QuoteID: quote,
+		Orders:  make([]*Order, 0),
+		Matches: make([]*OrderMatch, 0),
+	}
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		dc.tradeMtx.RLock()
+		for _, tracker := range dc.trades {
+			if tracker.mktID != mkt {
+				continue
+			}
+			corder, _ := tracker.coreOrder()
+			agg.Orders = append(agg.Orders, corder)
+		}
+		dc.tradeMtx.RUnlock()
+	}
+	c.connMtx.RUnlock()
+
+	sort.Slice(agg.Orders, func(i, j int) bool {
+		oi, oj := agg.Orders[i], agg.Orders[j]
+		if oi.Stamp != oj.Stamp {
+			return oi.Stamp > oj.Stamp
+		}
+		return oi.ID < oj.ID
+	})
+	for _, corder := range agg.Orders {
+		for _, match := range corder.Matches {
+			agg.Matches = append(agg.Matches, &OrderMatch{
+				Host:    corder.Host,
+				OrderID: corder.ID,
+				Sell:    corder.Sell,
+				Match:   match,
+			})
+		}
+	}
+	return agg
+}
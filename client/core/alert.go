@@ -0,0 +1,373 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// Alert kinds.
+const (
+	// AlertPrice fires once when the market rate crosses the alert's rate.
+	AlertPrice = "price"
+	// AlertFill fires each time the alert's order is filled, until the order
+	// is no longer active.
+	AlertFill = "fill"
+	// AlertSwap fires when a match has been waiting on a swap step for longer
+	// than the alert's Minutes.
+	AlertSwap = "swap"
+)
+
+var (
+	// alertInterval is how often the alerts are checked.
+	alertInterval = 10 * time.Second
+	// webhookClient sends alert and routed notifications to webhooks.
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// AlertForm is the user's definition of an alert. Kind is one of AlertPrice,
+// AlertFill, or AlertSwap, and determines which of the other fields are used.
+// A price alert is for the market specified by Host, Base, and Quote, and
+// fires when the market's mid-gap rate is at or above Rate if Above is true,
+// or at or below Rate if Above is false. A fill alert is for the active order
+// with OrderID. A swap alert is for any match on Host, or on any DEX if Host
+// is empty, that has not progressed in Minutes. Every alert is delivered to
+// the notification feed. If Webhook is set, the notification is also POSTed
+// to the URL as JSON. Desktop is passed on with the notification to request a
+// desktop notification from the UI.
+type AlertForm struct {
+	Kind    string `json:"kind"`
+	Host    string `json:"host"`
+	Base    uint32 `json:"base"`
+	Quote   uint32 `json:"quote"`
+	Rate    uint64 `json:"rate"`
+	Above   bool   `json:"above"`
+	OrderID string `json:"orderID"`
+	Minutes uint32 `json:"minutes"`
+	Webhook string `json:"webhook"`
+	Desktop bool   `json:"desktop"`
+}
+
+// Alert is a user-defined alert.
+type Alert struct {
+	AlertForm
+	ID    string `json:"id"`
+	Stamp uint64 `json:"stamp"`
+}
+
+// AlertNote is a notification that an alert has fired.
+type AlertNote struct {
+	db.Notification
+	Alert *Alert `json:"alert"`
+}
+
+func newAlertNote(topic Topic, severity db.Severity, alert *Alert, args ...interface{}) *AlertNote {
+	return &AlertNote{
+		Notification: newTopicNote(NoteTypeAlert, topic, severity, args),
+		Alert:        alert,
+	}
+}
+
+// AddAlert validates and stores the alert.
+func (c *Core) AddAlert(form *AlertForm) (*Alert, error) {
+	dbAlert := &db.Alert{
+		Kind:    form.Kind,
+		Webhook: form.Webhook,
+		Desktop: form.Desktop,
+		Stamp:   encode.UnixMilliU(time.Now()),
+	}
+	switch form.Kind {
+	case AlertPrice:
+		host := addrHost(form.Host)
+		c.connMtx.RLock()
+		dc, found := c.conns[host]
+		c.connMtx.RUnlock()
+		if !found {
+			return nil, fmt.Errorf("unknown DEX %s", form.Host)
+		}
+		if dc.market(marketName(form.Base, form.Quote)) == nil {
+			return nil, fmt.Errorf("price alert for unknown market")
+		}
+		if form.Rate == 0 {
+			return nil, fmt.Errorf("zero rate not allowed")
+		}
+		dbAlert.Host = host
+		dbAlert.Base = form.Base
+		dbAlert.Quote = form.Quote
+		dbAlert.Rate = form.Rate
+		dbAlert.Above = form.Above
+	case AlertFill:
+		oid, err := order.IDFromHex(form.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order ID %q: %v", form.OrderID, err)
+		}
+		_, tracker, isCancel := c.findDEXOrder(oid)
+		if tracker == nil || isCancel {
+			return nil, fmt.Errorf("no active trade %s", oid)
+		}
+		dbAlert.OrderID = oid[:]
+	case AlertSwap:
+		if form.Minutes == 0 {
+			return nil, fmt.Errorf("zero minutes not allowed")
+		}
+		if form.Host != "" {
+			dbAlert.Host = addrHost(form.Host)
+		}
+		dbAlert.Minutes = form.Minutes
+	default:
+		return nil, fmt.Errorf("unknown alert kind %q", form.Kind)
+	}
+	if form.Webhook != "" {
+		if err := checkWebhook(form.Webhook); err != nil {
+			return nil, err
+		}
+	}
+
+	err := c.db.SaveAlert(dbAlert)
+	if err != nil {
+		return nil, fmt.Errorf("error saving alert: %v", err)
+	}
+	return coreAlert(dbAlert), nil
+}
+
+// Alerts lists the user's alerts, oldest first.
+func (c *Core) Alerts() ([]*Alert, error) {
+	dbAlerts, err := c.db.Alerts()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving alerts: %v", err)
+	}
+	alerts := make([]*Alert, 0, len(dbAlerts))
+	for _, dbAlert := range dbAlerts {
+		alerts = append(alerts, coreAlert(dbAlert))
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Stamp < alerts[j].Stamp
+	})
+	return alerts, nil
+}
+
+// RemoveAlert deletes the alert.
+func (c *Core) RemoveAlert(id string) error {
+	idB, err := hex.DecodeString(id)
+	if err != nil {
+		return fmt.Errorf("invalid alert ID %q: %v", id, err)
+	}
+	c.alertMtx.Lock()
+	defer c.alertMtx.Unlock()
+	delete(c.alertFills, id)
+	delete(c.alertMatches, id)
+	return c.db.DeleteAlert(idB)
+}
+
+// coreAlert converts the *db.Alert to an *Alert.
+func coreAlert(dbAlert *db.Alert) *Alert {
+	alert := &Alert{
+		AlertForm: AlertForm{
+			Kind:    dbAlert.Kind,
+			Host:    dbAlert.Host,
+			Base:    dbAlert.Base,
+			Quote:   dbAlert.Quote,
+			Rate:    dbAlert.Rate,
+			Above:   dbAlert.Above,
+			Minutes: dbAlert.Minutes,
+			Webhook: dbAlert.Webhook,
+			Desktop: dbAlert.Desktop,
+		},
+		ID:    dbAlert.ID().String(),
+		Stamp: dbAlert.Stamp,
+	}
+	if len(dbAlert.OrderID) > 0 {
+		alert.OrderID = hex.EncodeToString(dbAlert.OrderID)
+	}
+	return alert
+}
+
+// runAlerts checks the alerts every alertInterval until the context is
+// canceled.
+func (c *Core) runAlerts(ctx context.Context) {
+	ticker := time.NewTicker(alertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkAlerts(ctx)
+		case <-ctx.Done():
+			c.alertMtx.Lock()
+			closeBookFeeds(c.alertFeeds, nil)
+			c.alertMtx.Unlock()
+			return
+		}
+	}
+}
+
+// checkAlerts sends notifications for the alerts whose conditions are met.
+// Price alerts and fill alerts for completed orders are removed once they
+// have fired.
+func (c *Core) checkAlerts(ctx context.Context) {
+	c.alertMtx.Lock()
+	defer c.alertMtx.Unlock()
+	dbAlerts, err := c.db.Alerts()
+	if err != nil {
+		log.Errorf("error retrieving alerts: %v", err)
+		return
+	}
+	now := encode.UnixMilliU(time.Now())
+	feeds := make(map[string]bool)
+	for _, dbAlert := range dbAlerts {
+		var done bool
+		switch dbAlert.Kind {
+		case AlertPrice:
+			c.connMtx.RLock()
+			dc, found := c.conns[dbAlert.Host]
+			c.connMtx.RUnlock()
+			if !found {
+				continue
+			}
+			feeds[c.syncBookFeed(ctx, c.alertFeeds, dbAlert.Host, dbAlert.Base, dbAlert.Quote)] = true
+			done = c.checkPriceAlert(dc, dbAlert)
+		case AlertFill:
+			done = c.checkFillAlert(dbAlert)
+		case AlertSwap:
+			c.checkSwapAlert(dbAlert, now)
+		}
+		if done {
+			id := dbAlert.ID()
+			if err := c.db.DeleteAlert(id); err != nil {
+				log.Errorf("error deleting alert %s: %v", id, err)
+			}
+			delete(c.alertFills, id.String())
+		}
+	}
+	closeBookFeeds(c.alertFeeds, feeds)
+}
+
+// checkPriceAlert fires the price alert if the market rate has crossed the
+// alert rate. The returned bool indicates whether the alert fired.
+func (c *Core) checkPriceAlert(dc *dexConnection, dbAlert *db.Alert) bool {
+	rate, err := dc.midGap(dbAlert.Base, dbAlert.Quote)
+	if err != nil {
+		return false
+	}
+	if (dbAlert.Above && rate < dbAlert.Rate) || (!dbAlert.Above && rate > dbAlert.Rate) {
+		return false
+	}
+	mkt := fmt.Sprintf("%s-%s", unbip(dbAlert.Base), unbip(dbAlert.Quote))
+	c.sendAlert(newAlertNote(TopicPriceAlert, db.Success, coreAlert(dbAlert), mkt, dbAlert.Host,
+		float64(rate)/conversionFactor, float64(dbAlert.Rate)/conversionFactor))
+	return true
+}
+
+// checkFillAlert fires the fill alert if the order has been filled since the
+// last check. The first check after a restart records the filled amount
+// without firing. The returned bool indicates whether the order is no longer
+// active, and the alert can be removed.
+func (c *Core) checkFillAlert(dbAlert *db.Alert) bool {
+	var oid order.OrderID
+	copy(oid[:], dbAlert.OrderID)
+	_, tracker, _ := c.findDEXOrder(oid)
+	if tracker == nil {
+		return true
+	}
+	corder, _ := tracker.coreOrder()
+	id := dbAlert.ID().String()
+	lastFilled, found := c.alertFills[id]
+	c.alertFills[id] = corder.Filled
+	if found && corder.Filled > lastFilled {
+		c.sendAlert(newAlertNote(TopicOrderFilledAlert, db.Success, coreAlert(dbAlert),
+			tracker.token(), corder.Host, float64(corder.Filled)/float64(corder.Qty)*100,
+			float64(corder.Filled)/conversionFactor, float64(corder.Qty)/conversionFactor, unbip(tracker.Base())))
+	}
+	return corder.Status > order.OrderStatusBooked
+}
+
+// checkSwapAlert fires the swap alert for each match that has been waiting on
+// a swap step for longer than the alert's Minutes. The alert fires only once
+// for each match.
+func (c *Core) checkSwapAlert(dbAlert *db.Alert, now uint64) {
+	id := dbAlert.ID().String()
+	alerted := c.alertMatches[id]
+	if alerted == nil {
+		alerted = make(map[string]bool)
+		c.alertMatches[id] = alerted
+	}
+	pending := make(map[string]bool)
+	limit := uint64(dbAlert.Minutes) * 60 * 1000
+
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	for host, dc := range c.conns {
+		if dbAlert.Host != "" && host != dbAlert.Host {
+			continue
+		}
+		dc.tradeMtx.RLock()
+		for _, tracker := range dc.trades {
+			tracker.matchMtx.RLock()
+			for _, match := range tracker.matches {
+				last, waiting := match.lastProgress()
+				if !waiting {
+					continue
+				}
+				mid := match.id.String()
+				pending[mid] = true
+				if alerted[mid] || now < last+limit {
+					continue
+				}
+				alerted[mid] = true
+				c.sendAlert(newAlertNote(TopicSwapNeedsAttention, db.WarningLevel, coreAlert(dbAlert),
+					match.id, tracker.token(), host, match.Match.Status, (now-last)/60000))
+			}
+			tracker.matchMtx.RUnlock()
+		}
+		dc.tradeMtx.RUnlock()
+	}
+	// Forget the matches that are no longer waiting.
+	for mid := range alerted {
+		if !pending[mid] {
+			delete(alerted, mid)
+		}
+	}
+}
+
+// lastProgress is the time of the match's most recent swap step, in
+// milliseconds. The returned bool is false if the match is not waiting on any
+// further steps from the user, either because its swap is complete or because
+// it was revoked or refunded. lastProgress should be called with the
+// trackedTrade's matchMtx locked.
+func (match *matchTracker) lastProgress() (uint64, bool) {
+	dbMatch, _, proof, auth := match.parts()
+	if match.failErr != nil || proof.IsRevoked || proof.RefundCoin != nil {
+		return 0, false
+	}
+	status := dbMatch.Status
+	if status == order.MatchComplete || (dbMatch.Side == order.Maker && status >= order.MakerRedeemed) {
+		return 0, false
+	}
+	var last uint64
+	for _, stamp := range []uint64{auth.MatchStamp, auth.InitStamp, auth.AuditStamp,
+		auth.RedeemStamp, auth.RedemptionStamp} {
+		if stamp > last {
+			last = stamp
+		}
+	}
+	return last, last > 0
+}
+
+// sendAlert sends the alert notification to the notification feed, and to the
+// alert's webhook, if it has one.
+func (c *Core) sendAlert(note *AlertNote) {
+	c.notify(note)
+	if note.Alert.Webhook == "" {
+		return
+	}
+	go postWebhook(note.Alert.Webhook, note)
+}
@@ -0,0 +1,189 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+)
+
+// backupChunkSize is the maximum size of the encrypted backup data in a single
+// push of the backup archive.
+const backupChunkSize = 0xfff0
+
+// backupArchiveVersion is the version of the backup archive created by
+// ExportBackup. Version 1 adds the encrypted application seed.
+const backupArchiveVersion = 1
+
+// ExportBackup creates an encrypted archive of the client's accounts, order
+// history, and wallet configuration, which can be restored on another machine
+// with RestoreBackup. The keys of external wallets are not included. The
+// archive is encrypted with the application password, and the same password is
+// required for RestoreBackup.
+func (c *Core) ExportBackup(pw []byte) ([]byte, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("ExportBackup password error: %v", err)
+	}
+	defer crypter.Close()
+	keyParams, err := c.db.Get(keyParamsKey)
+	if err != nil {
+		return nil, fmt.Errorf("key retrieval error: %v", err)
+	}
+	// appSeed creates the seed of a legacy client, so the seed is stored
+	// before it is retrieved encrypted.
+	seed, err := c.appSeed(crypter)
+	if err != nil {
+		return nil, err
+	}
+	encode.ClearBytes(seed)
+	encSeed, err := c.db.Get(appSeedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving application seed: %v", err)
+	}
+	backup := new(db.ClientBackup)
+	backup.Accounts, err = c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving accounts: %v", err)
+	}
+	for _, acct := range backup.Accounts {
+		ords, err := c.db.AccountOrders(acct.Host, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving orders for %s: %v", acct.Host, err)
+		}
+		for _, ord := range ords {
+			matches, err := c.db.MatchesForOrder(ord.Order.ID())
+			if err != nil {
+				return nil, fmt.Errorf("error retrieving matches for order %s: %v", ord.Order.ID(), err)
+			}
+			backup.Matches = append(backup.Matches, matches...)
+		}
+		backup.Orders = append(backup.Orders, ords...)
+	}
+	backup.Wallets, err = c.db.Wallets()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving wallets: %v", err)
+	}
+	encBackup, err := crypter.Encrypt(backup.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting backup: %v", err)
+	}
+	// The key params are needed to decrypt the backup, as well as the account
+	// keys and wallet passwords within it.
+	archive := encode.BuildyBytes{backupArchiveVersion}.AddData(keyParams).AddData(encSeed)
+	for len(encBackup) > 0 {
+		n := backupChunkSize
+		if len(encBackup) < n {
+			n = len(encBackup)
+		}
+		archive = archive.AddData(encBackup[:n])
+		encBackup = encBackup[n:]
+	}
+	return archive, nil
+}
+
+// RestoreBackup initializes the client from an archive created with
+// ExportBackup. The password must be the application password at the time of
+// the export, and becomes the application password of this client. The client
+// must not already be initialized.
+func (c *Core) RestoreBackup(pw, archive []byte) error {
+	if initialized, err := c.IsInitialized(); err != nil {
+		return fmt.Errorf("error checking if app is already initialized: %v", err)
+	} else if initialized {
+		return fmt.Errorf("cannot restore a backup to an initialized client")
+	}
+	ver, pushes, err := encode.DecodeBlob(archive)
+	if err != nil {
+		return fmt.Errorf("error decoding backup archive: %v", err)
+	}
+	if ver > backupArchiveVersion {
+		return fmt.Errorf("unknown backup archive version %d", ver)
+	}
+	if len(pushes) == 0 {
+		return fmt.Errorf("backup archive is empty")
+	}
+	keyParams, pushes := pushes[0], pushes[1:]
+	// An archive without the application seed restores a client that will be
+	// given a new seed.
+	var encSeed []byte
+	if ver > 0 {
+		if len(pushes) == 0 {
+			return fmt.Errorf("backup archive has no seed")
+		}
+		encSeed, pushes = pushes[0], pushes[1:]
+	}
+	if len(pushes) == 0 {
+		return fmt.Errorf("backup archive has no data")
+	}
+	var encBackup []byte
+	for _, chunk := range pushes {
+		encBackup = append(encBackup, chunk...)
+	}
+	crypter, err := c.reCrypter(pw, keyParams)
+	if err != nil {
+		return fmt.Errorf("RestoreBackup password error: %v", err)
+	}
+	defer crypter.Close()
+	backupB, err := crypter.Decrypt(encBackup)
+	if err != nil {
+		return fmt.Errorf("error decrypting backup: %v", err)
+	}
+	backup, err := db.DecodeClientBackup(backupB)
+	if err != nil {
+		return fmt.Errorf("error decoding backup: %v", err)
+	}
+
+	for _, acct := range backup.Accounts {
+		if err := c.db.CreateAccount(acct); err != nil {
+			return fmt.Errorf("error restoring account for %s: %v", acct.Host, err)
+		}
+		if !acct.Paid {
+			continue
+		}
+		// The server's fee payment signature is not backed up, but the
+		// account is known to be paid.
+		err := c.db.AccountPaid(&db.AccountProof{
+			Host:  acct.Host,
+			Stamp: encode.UnixMilliU(time.Now()),
+		})
+		if err != nil {
+			return fmt.Errorf("error restoring fee payment for %s: %v", acct.Host, err)
+		}
+	}
+	for _, ord := range backup.Orders {
+		if err := c.db.UpdateOrder(ord); err != nil {
+			return fmt.Errorf("error restoring order %s: %v", ord.Order.ID(), err)
+		}
+	}
+	for _, match := range backup.Matches {
+		if err := c.db.UpdateMatch(match); err != nil {
+			return fmt.Errorf("error restoring match %s: %v", match.Match.MatchID, err)
+		}
+	}
+	for _, wallet := range backup.Wallets {
+		// The balance is updated when the wallet is connected.
+		wallet.Balance = &db.Balance{Stamp: time.Now()}
+		if err := c.db.UpdateWallet(wallet); err != nil {
+			return fmt.Errorf("error restoring %s wallet: %v", unbip(wallet.AssetID), err)
+		}
+	}
+	if len(encSeed) > 0 {
+		if err := c.db.Store(appSeedKey, encSeed); err != nil {
+			return fmt.Errorf("error storing application seed: %v", err)
+		}
+	}
+	// Storing the key params last leaves the client uninitialized if the
+	// restore fails, so that it can be attempted again.
+	if err := c.db.Store(keyParamsKey, keyParams); err != nil {
+		return fmt.Errorf("error storing key parameters: %v", err)
+	}
+	log.Infof("Restored %d accounts, %d orders, %d matches, and %d wallets from backup",
+		len(backup.Accounts), len(backup.Orders), len(backup.Matches), len(backup.Wallets))
+	// Connect to the restored DEXes and load the restored wallets.
+	c.initialize()
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	orderbook "decred.org/dcrdex/client/order"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
 )
 
 var (
@@ -155,7 +156,12 @@ func (c *Core) Sync(host string, base, quote uint32) (*OrderBook, *BookFeed, err
 	if !found {
 		return nil, nil, fmt.Errorf("unknown DEX '%s'", host)
 	}
+	return c.syncBook(dc, base, quote)
+}
 
+// syncBook subscribes to the market's order book on the dexConnection, unless
+// it is already synced, and returns the book and a new BookFeed.
+func (c *Core) syncBook(dc *dexConnection, base, quote uint32) (*OrderBook, *BookFeed, error) {
 	mkt := marketName(base, quote)
 	dc.booksMtx.Lock()
 	defer dc.booksMtx.Unlock()
@@ -301,7 +307,7 @@ func handleBookOrderMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) error
 
 // handleUnbookOrderMsg is called when an unbook_order notification is
 // received.
-func handleUnbookOrderMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) error {
+func handleUnbookOrderMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error {
 	note := new(msgjson.UnbookOrderNote)
 	err := msg.Unmarshal(note)
 	if err != nil {
@@ -327,9 +333,41 @@ func handleUnbookOrderMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) erro
 		Payload:  &MiniOrder{Token: token(note.OrderID)},
 	})
 
+	c.checkOddLotRelease(dc, note)
+
 	return nil
 }
 
+// checkOddLotRelease checks whether an unbooked order is one of the user's
+// booked orders on a market that releases odd lots. If it is, the order may
+// have been executed with its odd lot released, which the match requests do
+// not reveal, so the order statuses are resynced with the server.
+func (c *Core) checkOddLotRelease(dc *dexConnection, note *msgjson.UnbookOrderNote) {
+	mkt := dc.market(note.MarketID)
+	if mkt == nil || mkt.OddLotPolicy != string(dex.OddLotRelease) {
+		return
+	}
+	var oid order.OrderID
+	copy(oid[:], note.OrderID)
+	dc.tradeMtx.RLock()
+	tracker, found := dc.trades[oid]
+	dc.tradeMtx.RUnlock()
+	if !found {
+		return
+	}
+	tracker.mtx.RLock()
+	booked := tracker.metaData.Status == order.OrderStatusBooked && tracker.cancel == nil
+	tracker.mtx.RUnlock()
+	if !booked {
+		return
+	}
+	go func() {
+		if _, err := c.resyncOrders(dc); err != nil {
+			log.Errorf("error resyncing orders with %s after order %s was unbooked: %v", dc.acct.host, oid, err)
+		}
+	}()
+}
+
 // handleUpdateRemainingMsg is called when an update_remaining notification is
 // received.
 func handleUpdateRemainingMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) error {
@@ -0,0 +1,249 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+)
+
+// CandleBinSizes are the supported candlestick durations.
+var CandleBinSizes = []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+
+// validCandleBin checks that the bin size is one of the CandleBinSizes.
+func validCandleBin(binSize time.Duration) bool {
+	for _, bin := range CandleBinSizes {
+		if binSize == bin {
+			return true
+		}
+	}
+	return false
+}
+
+// Candles gets the OHLC candles for the market on the DEX at host, with bins of
+// binSize and start stamps from start to end, in milliseconds. A zero end
+// requests candles up to the present. Candles are cached in the database, so
+// only the candles since the last cached candle are requested from the server.
+// If the server cannot be reached, the cached candles are returned.
+func (c *Core) Candles(host string, base, quote uint32, binSize time.Duration, start, end uint64) ([]*Candle, error) {
+	if !validCandleBin(binSize) {
+		return nil, fmt.Errorf("unsupported candle bin size %s", binSize)
+	}
+	binMs := uint64(binSize / time.Millisecond)
+	if end == 0 {
+		end = encode.UnixMilliU(time.Now())
+	}
+	if start > end {
+		return nil, fmt.Errorf("candle range start %d is after end %d", start, end)
+	}
+	start -= start % binMs
+
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	var connected bool
+	if found {
+		connected = dc.connected
+	}
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+	mkt := marketName(base, quote)
+	if dc.market(mkt) == nil {
+		return nil, fmt.Errorf("no market %s at %s", mkt, host)
+	}
+
+	cached, err := c.db.Candles(host, base, quote, binMs, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving cached candles: %v", err)
+	}
+	if err = dc.checkRoute(msgjson.CandlesRoute); err != nil {
+		log.Debugf("Serving %d cached %s candles for %s: %v", len(cached), binSize, mkt, err)
+		return translateCandles(cached), nil
+	}
+	if !connected {
+		log.Debugf("Serving %d cached %s candles for %s while disconnected from %s", len(cached), binSize, mkt, host)
+		return translateCandles(cached), nil
+	}
+
+	// If the cache covers the start of the range, only request from the last
+	// cached candle, which may have been in progress when it was stored.
+	fetchStart := start
+	if len(cached) > 0 && cached[0].StartStamp == start {
+		fetchStart = cached[len(cached)-1].StartStamp
+	}
+	var msgCandles []*msgjson.Candle
+	err = sendRequest(dc.WsConn, msgjson.CandlesRoute, &msgjson.CandlesRequest{
+		Base:    base,
+		Quote:   quote,
+		BinSize: binMs,
+		Start:   fetchStart,
+		End:     end,
+	}, &msgCandles)
+	if err != nil {
+		if len(cached) == 0 {
+			return nil, fmt.Errorf("error requesting candles from %s: %v", host, err)
+		}
+		log.Warnf("Error requesting %s candles for %s from %s. Serving %d cached candles: %v",
+			binSize, mkt, host, len(cached), err)
+		return translateCandles(cached), nil
+	}
+
+	fetched := make([]*db.Candle, 0, len(msgCandles))
+	for _, mc := range msgCandles {
+		if mc.StartStamp < fetchStart || mc.StartStamp > end {
+			continue
+		}
+		fetched = append(fetched, &db.Candle{
+			StartStamp:  mc.StartStamp,
+			EndStamp:    mc.EndStamp,
+			MatchVolume: mc.MatchVolume,
+			QuoteVolume: mc.QuoteVolume,
+			HighRate:    mc.HighRate,
+			LowRate:     mc.LowRate,
+			StartRate:   mc.StartRate,
+			EndRate:     mc.EndRate,
+		})
+	}
+	if err = c.db.StoreCandles(host, base, quote, binMs, fetched); err != nil {
+		log.Errorf("Error caching %s candles for %s at %s: %v", binSize, mkt, host, err)
+	}
+
+	// Fetched candles replace any cached candles for the same bin.
+	merged := make(map[uint64]*db.Candle, len(cached)+len(fetched))
+	for _, candle := range cached {
+		merged[candle.StartStamp] = candle
+	}
+	for _, candle := range fetched {
+		merged[candle.StartStamp] = candle
+	}
+	candles := make([]*db.Candle, 0, len(merged))
+	for _, candle := range merged {
+		candles = append(candles, candle)
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].StartStamp < candles[j].StartStamp
+	})
+	return translateCandles(candles), nil
+}
+
+// translateCandles translates from []*db.Candle to []*Candle.
+func translateCandles(dbCandles []*db.Candle) []*Candle {
+	candles := make([]*Candle, 0, len(dbCandles))
+	for _, c := range dbCandles {
+		candles = append(candles, &Candle{
+			StartStamp:  c.StartStamp,
+			EndStamp:    c.EndStamp,
+			MatchVolume: c.MatchVolume,
+			QuoteVolume: c.QuoteVolume,
+			HighRate:    c.HighRate,
+			LowRate:     c.LowRate,
+			StartRate:   c.StartRate,
+			EndRate:     c.EndRate,
+		})
+	}
+	return candles
+}
+
+// Spots gets the most recent spot prices received from the DEX at host, keyed
+// by market name. Spots are only available from servers that send the spots
+// notification.
+func (c *Core) Spots(host string) (map[string]*msgjson.Spot, error) {
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+	dc.spotsMtx.RLock()
+	defer dc.spotsMtx.RUnlock()
+	spots := make(map[string]*msgjson.Spot, len(dc.spots))
+	for mkt, spot := range dc.spots {
+		spots[mkt] = spot
+	}
+	return spots, nil
+}
+
+// handleSpotsMsg is called when a spots notification is received. The spots are
+// stored, and sent to the subscribers of any synced order book for the market.
+func handleSpotsMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) error {
+	var spots []*msgjson.Spot
+	err := msg.Unmarshal(&spots)
+	if err != nil {
+		return fmt.Errorf("spots note unmarshal error: %v", err)
+	}
+
+	dc.spotsMtx.Lock()
+	if dc.spots == nil {
+		dc.spots = make(map[string]*msgjson.Spot, len(spots))
+	}
+	for _, spot := range spots {
+		dc.spots[marketName(spot.BaseID, spot.QuoteID)] = spot
+	}
+	dc.spotsMtx.Unlock()
+
+	dc.booksMtx.RLock()
+	defer dc.booksMtx.RUnlock()
+	for _, spot := range spots {
+		mkt := marketName(spot.BaseID, spot.QuoteID)
+		book, found := dc.books[mkt]
+		if !found {
+			continue
+		}
+		book.send(&BookUpdate{
+			Action:   msg.Route,
+			Host:     dc.acct.host,
+			MarketID: mkt,
+			Payload:  spot,
+		})
+	}
+	return nil
+}
+
+// Depth gets the cumulative depth chart data for the synced order book of the
+// market on the DEX at host. Orders at the same rate are combined into a single
+// point. Depth must be called after Sync.
+func (c *Core) Depth(host string, base, quote uint32) (*DepthChart, error) {
+	book, err := c.Book(host, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	chart := &DepthChart{
+		Host:    host,
+		BaseID:  base,
+		QuoteID: quote,
+		Buys:    depthSide(book.Buys),
+		Sells:   depthSide(book.Sells),
+	}
+	if len(chart.Buys) > 0 && len(chart.Sells) > 0 {
+		chart.MidGap = (chart.Buys[0].Rate + chart.Sells[0].Rate) / 2
+	}
+	return chart, nil
+}
+
+// depthSide combines the orders of one side of the book, which are sorted best
+// rate first, into depth points with the cumulative quantity.
+func depthSide(ords []*MiniOrder) []*DepthPoint {
+	pts := make([]*DepthPoint, 0, len(ords))
+	var depth float64
+	for _, ord := range ords {
+		depth += ord.Qty
+		if n := len(pts); n > 0 && pts[n-1].Rate == ord.Rate {
+			pts[n-1].Qty += ord.Qty
+			pts[n-1].Depth = depth
+			continue
+		}
+		pts = append(pts, &DepthPoint{
+			Rate:  ord.Rate,
+			Qty:   ord.Qty,
+			Depth: depth,
+		})
+	}
+	return pts
+}
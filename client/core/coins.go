@@ -0,0 +1,267 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/calc"
+)
+
+const (
+	// coinPoliciesKey is the app-level db key for the user's coin management
+	// policies.
+	coinPoliciesKey = "coinPolicies"
+	// defaultMaxInputs is the consolidation input limit when the policy does
+	// not set one.
+	defaultMaxInputs = 100
+)
+
+var (
+	// coinInterval is how often the wallets' coins are checked.
+	coinInterval = 10 * time.Minute
+)
+
+// CoinPolicy is the user's coin management policy for a wallet. Coins are only
+// consolidated or split while the wallet's fee rate is at or below MaxFeeRate,
+// so that the transactions are sent during low-fee periods. At most one
+// transaction is sent per wallet each time the coins are checked.
+type CoinPolicy struct {
+	// MaxFeeRate is the highest fee rate, in atoms/byte, at which coins are
+	// consolidated or split.
+	MaxFeeRate uint64 `json:"maxFeeRate"`
+	// DustThreshold is the value below which coins are consolidated. Zero
+	// disables consolidation.
+	DustThreshold uint64 `json:"dustThreshold"`
+	// MinConsolidate is the number of coins below DustThreshold that triggers
+	// a consolidation.
+	MinConsolidate int `json:"minConsolidate"`
+	// MaxInputs is the most coins spent by a consolidation. Zero is the
+	// default of 100.
+	MaxInputs int `json:"maxInputs"`
+	// SplitLots is the number of lots that each pre-split coin can fund, using
+	// the largest lot size of the asset's markets. Zero disables splitting.
+	SplitLots uint64 `json:"splitLots"`
+	// SplitCoins is the number of pre-split coins to keep available.
+	SplitCoins int `json:"splitCoins"`
+}
+
+// CoinPolicies returns the user's coin management policies, keyed by asset ID.
+func (c *Core) CoinPolicies() map[uint32]*CoinPolicy {
+	c.coinMtx.RLock()
+	defer c.coinMtx.RUnlock()
+	policies := make(map[uint32]*CoinPolicy, len(c.coinPolicies))
+	for assetID, policy := range c.coinPolicies {
+		p := *policy
+		policies[assetID] = &p
+	}
+	return policies
+}
+
+// SetCoinPolicy sets the coin management policy for the asset's wallet and
+// saves the policies to the database. A nil policy disables coin management
+// for the wallet.
+func (c *Core) SetCoinPolicy(assetID uint32, policy *CoinPolicy) error {
+	if policy != nil {
+		wallet, found := c.wallet(assetID)
+		if !found {
+			return fmt.Errorf("no %s wallet", unbip(assetID))
+		}
+		if _, ok := wallet.Wallet.(asset.CoinManager); !ok {
+			return fmt.Errorf("%s wallet does not support coin management", unbip(assetID))
+		}
+		if policy.MaxFeeRate == 0 {
+			return fmt.Errorf("no maximum fee rate")
+		}
+		if policy.DustThreshold > 0 && policy.MinConsolidate < 2 {
+			return fmt.Errorf("at least 2 coins are needed to consolidate, got %d", policy.MinConsolidate)
+		}
+		if policy.MaxInputs < 0 || (policy.MaxInputs > 0 && policy.MaxInputs < policy.MinConsolidate) {
+			return fmt.Errorf("invalid input limit %d", policy.MaxInputs)
+		}
+		if policy.SplitLots > 0 && policy.SplitCoins <= 0 {
+			return fmt.Errorf("invalid number of split coins %d", policy.SplitCoins)
+		}
+		p := *policy
+		policy = &p
+	}
+
+	c.coinMtx.Lock()
+	defer c.coinMtx.Unlock()
+	policies := make(map[uint32]*CoinPolicy, len(c.coinPolicies)+1)
+	for k, v := range c.coinPolicies {
+		policies[k] = v
+	}
+	if policy == nil {
+		delete(policies, assetID)
+	} else {
+		policies[assetID] = policy
+	}
+	b, err := json.Marshal(policies)
+	if err != nil {
+		return fmt.Errorf("error encoding coin policies: %v", err)
+	}
+	if err = c.db.Store(coinPoliciesKey, b); err != nil {
+		return fmt.Errorf("error saving coin policies: %v", err)
+	}
+	c.coinPolicies = policies
+	return nil
+}
+
+// loadCoinPolicies loads the user's coin management policies from the
+// database.
+func (c *Core) loadCoinPolicies() {
+	exists, err := c.db.ValueExists(coinPoliciesKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(coinPoliciesKey)
+	if err != nil {
+		log.Errorf("error loading coin policies: %v", err)
+		return
+	}
+	policies := make(map[uint32]*CoinPolicy)
+	if err = json.Unmarshal(b, &policies); err != nil {
+		log.Errorf("error decoding coin policies: %v", err)
+		return
+	}
+	c.coinMtx.Lock()
+	c.coinPolicies = policies
+	c.coinMtx.Unlock()
+}
+
+// runCoins manages the wallets' coins until the context is canceled.
+func (c *Core) runCoins(ctx context.Context) {
+	ticker := time.NewTicker(coinInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.manageCoins()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// manageCoins consolidates or splits the coins of each connected and unlocked
+// wallet with a coin policy.
+func (c *Core) manageCoins() {
+	for assetID, policy := range c.CoinPolicies() {
+		wallet, found := c.wallet(assetID)
+		if !found || !wallet.connected() || !wallet.unlocked() {
+			continue
+		}
+		cm, ok := wallet.Wallet.(asset.CoinManager)
+		if !ok {
+			continue
+		}
+		if c.manageWalletCoins(assetID, cm, policy) {
+			c.updateAssetBalance(assetID)
+		}
+	}
+}
+
+// manageWalletCoins sends a consolidation or split transaction if the wallet's
+// coins call for one and fees are low enough. A consolidation takes priority,
+// since it frees up the fragmented value that a split may need. The return
+// value indicates whether a transaction was sent.
+func (c *Core) manageWalletCoins(assetID uint32, cm asset.CoinManager, policy *CoinPolicy) bool {
+	feeRate, err := cm.FeeRate()
+	if err != nil {
+		log.Errorf("error getting %s fee rate for coin management: %v", unbip(assetID), err)
+		return false
+	}
+	if feeRate > policy.MaxFeeRate {
+		log.Tracef("%s fee rate %d is above the coin management limit of %d", unbip(assetID), feeRate, policy.MaxFeeRate)
+		return false
+	}
+	vals, err := cm.CoinValues()
+	if err != nil {
+		log.Errorf("error getting %s coins: %v", unbip(assetID), err)
+		return false
+	}
+
+	if policy.DustThreshold > 0 {
+		var dust int
+		for _, v := range vals {
+			if v < policy.DustThreshold {
+				dust++
+			}
+		}
+		if dust >= policy.MinConsolidate {
+			maxInputs := policy.MaxInputs
+			if maxInputs == 0 {
+				maxInputs = defaultMaxInputs
+			}
+			coin, err := cm.Consolidate(policy.DustThreshold, maxInputs, feeRate)
+			if err != nil {
+				log.Errorf("error consolidating %d %s coins: %v", dust, unbip(assetID), err)
+				return false
+			}
+			c.notify(newCoinNote(TopicCoinsConsolidated, db.Success, assetID, unbip(assetID), coin))
+			return true
+		}
+	}
+
+	if policy.SplitLots == 0 {
+		return false
+	}
+	splitVal := c.splitValue(assetID, policy.SplitLots)
+	if splitVal == 0 {
+		return false
+	}
+	// Coins that can fund the lots, but not twice over, count as split coins.
+	// The rest of the value is available to split.
+	var ready int
+	var avail uint64
+	for _, v := range vals {
+		if v >= splitVal && v < 2*splitVal {
+			ready++
+		} else {
+			avail += v
+		}
+	}
+	n := policy.SplitCoins - ready
+	for n > 0 && uint64(n)*splitVal >= avail {
+		n--
+	}
+	if n <= 0 {
+		return false
+	}
+	coins, err := cm.Split(splitVal, n, feeRate)
+	if err != nil {
+		log.Errorf("error splitting %s coins: %v", unbip(assetID), err)
+		return false
+	}
+	c.notify(newCoinNote(TopicCoinsSplit, db.Success, assetID,
+		len(coins), unbip(assetID), float64(splitVal)/conversionFactor))
+	return true
+}
+
+// splitValue is the value of a coin that can fund an order of the lots, with
+// swap fees at the maximum fee rate, for the largest lot size of the asset's
+// markets. Zero is returned if no connected DEX supports the asset.
+func (c *Core) splitValue(assetID uint32, lots uint64) uint64 {
+	var nfo *dex.Asset
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		a := dc.asset(assetID)
+		if a != nil && (nfo == nil || a.LotSize > nfo.LotSize) {
+			nfo = a
+		}
+	}
+	c.connMtx.RUnlock()
+	if nfo == nil {
+		return 0
+	}
+	// SwapSize includes a single input.
+	return calc.RequiredOrderFunds(lots*nfo.LotSize, nfo.SwapSize-nfo.SwapSizeBase, nfo)
+}
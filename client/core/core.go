@@ -6,11 +6,13 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +31,7 @@ import (
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/dex/wait"
+	"decred.org/dcrdex/server/account"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
@@ -54,11 +57,15 @@ var (
 type dexConnection struct {
 	comms.WsConn
 	connMaster *dex.ConnectionMaster
-	assets     map[uint32]*dex.Asset
 	cfg        *msgjson.ConfigResult
 	acct       *dexAccount
 	notify     func(Notification)
 
+	// assets is replaced, not modified, when the DEX changes the lot size or
+	// rate step of an asset. See lotsize.go.
+	assetsMtx sync.RWMutex
+	assets    map[uint32]*dex.Asset
+
 	booksMtx sync.RWMutex
 	books    map[string]*bookie
 
@@ -70,13 +77,35 @@ type dexConnection struct {
 
 	epochMtx sync.RWMutex
 	epoch    map[string]uint64
+
+	spotsMtx sync.RWMutex
+	spots    map[string]*msgjson.Spot
 	// connected is a best guess on the ws connection status.
 	connected bool
+	// disconnected is when the connection was lost, or zero if the connection
+	// has not been lost since the last reconnect.
+	disconnected time.Time
 
 	regConfMtx  sync.RWMutex
 	regConfirms *uint32 // nil regConfirms means no pending registration.
 }
 
+// asset returns the DEX's configuration of the asset, or nil if the DEX does
+// not support the asset.
+func (dc *dexConnection) asset(assetID uint32) *dex.Asset {
+	dc.assetsMtx.RLock()
+	defer dc.assetsMtx.RUnlock()
+	return dc.assets[assetID]
+}
+
+// assetMap returns the DEX's asset configurations. The map should be treated
+// as read only.
+func (dc *dexConnection) assetMap() map[uint32]*dex.Asset {
+	dc.assetsMtx.RLock()
+	defer dc.assetsMtx.RUnlock()
+	return dc.assets
+}
+
 // suspended returns the suspended status of the provided market.
 func (dc *dexConnection) suspended(mkt string) bool {
 	dc.marketMtx.Lock()
@@ -127,7 +156,7 @@ func (dc *dexConnection) refreshMarkets() map[string]*Market {
 	for _, mkt := range dc.cfg.Markets {
 		// The presence of the asset for every market was already verified when the
 		// dexConnection was created in connectDEX.
-		base, quote := dc.assets[mkt.Base], dc.assets[mkt.Quote]
+		base, quote := dc.asset(mkt.Base), dc.asset(mkt.Quote)
 		market := &Market{
 			Name:            mkt.Name,
 			BaseID:          base.ID,
@@ -137,6 +166,9 @@ func (dc *dexConnection) refreshMarkets() map[string]*Market {
 			EpochLen:        mkt.EpochLen,
 			StartEpoch:      mkt.StartEpoch,
 			MarketBuyBuffer: mkt.MarketBuyBuffer,
+			TradeFee:        mkt.TradeFee,
+			MinOrderValue:   mkt.MinOrderValue,
+			OddLotPolicy:    mkt.OddLotPolicy,
 			suspended:       dc.suspended(mkt.Name),
 		}
 		mid := market.marketName()
@@ -362,14 +394,18 @@ func (dc *dexConnection) runMatches(matches map[order.OrderID]*serverMatches) er
 // but we also must check for incomplete matches that the server is not
 // reporting.
 //
+// The reported matches with no matchTracker are returned.
+//
 // DRAFT NOTE: Right now, the matches are just checked and notifications sent,
 // but it may be a good  place to trigger a FindRedemption if the conditions
 // warrant.
-func (dc *dexConnection) compareServerMatches(matches map[order.OrderID]*serverMatches) {
+func (dc *dexConnection) compareServerMatches(matches map[order.OrderID]*serverMatches) []*msgjson.Match {
+	var extras []*msgjson.Match
 	for _, match := range matches {
 		// readConnectMatches sends notifications for any problems encountered.
-		match.tracker.readConnectMatches(match.msgMatches)
+		extras = append(extras, match.tracker.readConnectMatches(match.msgMatches)...)
 	}
+	return extras
 }
 
 // tickAsset checks open matches related to a specific asset for needed action.
@@ -458,7 +494,22 @@ type Config struct {
 	DBPath string
 	// Net is the current network.
 	Net dex.Network
-}
+	// RateSource is an optional source of fiat exchange rates. If provided,
+	// the rates are refreshed periodically, and the fiat values of balances,
+	// orders, the trade history, and the Portfolio are included.
+	RateSource RateSource
+	// UserAgent identifies the client software to DEX servers. If empty,
+	// DefaultUserAgent is used.
+	UserAgent string
+	// Language is the BCP 47 language tag of the language that notifications
+	// are rendered in. If empty, the origin language, en-US, is used. See
+	// Locales for the supported languages.
+	Language string
+}
+
+// DefaultUserAgent is the client software identifier sent to DEX servers when
+// Config.UserAgent is empty.
+const DefaultUserAgent = "dexc/0.1.0"
 
 // Core is the core client application. Core manages DEX connections, wallets,
 // database access, match negotiation and more.
@@ -470,17 +521,23 @@ type Core struct {
 	net           dex.Network
 	lockTimeTaker time.Duration
 	lockTimeMaker time.Duration
+	userAgent     string
+	locale        *locale
 
 	wsConstructor func(*comms.WsCfg) (comms.WsConn, error)
 	newCrypter    func([]byte) encrypt.Crypter
 	reCrypter     func([]byte, []byte) (encrypt.Crypter, error)
 	latencyQ      *wait.TickerQueue
+	rateSource    RateSource
+
+	fiatMtx   sync.RWMutex
+	fiatRates map[uint32]float64
 
 	connMtx sync.RWMutex
 	conns   map[string]*dexConnection
 
 	walletMtx sync.RWMutex
-	wallets   map[uint32]*xcWallet
+	wallets   map[string]*xcWallet
 
 	waiterMtx    sync.Mutex
 	blockWaiters map[uint64]*blockWaiter
@@ -488,35 +545,128 @@ type Core struct {
 	userMtx sync.RWMutex
 	user    *User
 
+	// loggedIn is false until the app password is provided with Login. Until
+	// then, the core is in read-only mode. The DEX connections are not
+	// authenticated and the wallets are not unlocked, but the markets, order
+	// books and order history are available.
+	loginMtx sync.RWMutex
+	loggedIn bool
+
 	noteMtx   sync.RWMutex
 	noteChans []chan Notification
+
+	routeMtx   sync.RWMutex
+	noteRoutes map[string]*NoteRoute
+
+	coinMtx      sync.RWMutex
+	coinPolicies map[uint32]*CoinPolicy
+
+	fundingMtx        sync.RWMutex
+	fundingStrategies map[uint32]asset.FundingStrategy
+
+	proxyMtx sync.RWMutex
+	proxies  map[string]*ProxyConfig
+
+	pinMtx sync.RWMutex
+	pins   map[string]*ServerBundle
+
+	codMtx       sync.RWMutex
+	cancelEpochs map[string]uint32
+
+	swapConfMtx sync.RWMutex
+	swapConfs   map[uint32]uint32
+
+	autoRefundMtx sync.RWMutex
+	noAutoRefund  bool
+
+	lotPolicyMtx sync.RWMutex
+	lotPolicy    LotSizePolicy
+
+	guardMtx     sync.Mutex
+	tradeGuard   TradeGuard
+	recentTrades []*recentTrade
+
+	depositMtx   sync.RWMutex
+	depositAddrs map[uint32][]*DepositAddress
+
+	schedMtx   sync.Mutex
+	schedFeeds map[string]*BookFeed
+
+	paperMtx sync.Mutex
+	paper    *paperSession
+
+	alertMtx     sync.Mutex
+	alertFeeds   map[string]*BookFeed
+	alertFills   map[string]uint64
+	alertMatches map[string]map[string]bool
+
+	promptMtx sync.Mutex
+	prompts   map[string]*pendingPrompt
+
+	// ready is closed when Run has started the core.
+	ready chan struct{}
 }
 
 // New is the constructor for a new Core.
 func New(cfg *Config) (*Core, error) {
+	loc := originLocale
+	if cfg.Language != "" {
+		lang, found := MatchLocale(cfg.Language)
+		if !found {
+			return nil, fmt.Errorf("unsupported language %q", cfg.Language)
+		}
+		loc = locales[lang]
+	}
 	db, err := bolt.NewDB(cfg.DBPath)
 	if err != nil {
 		return nil, fmt.Errorf("database initialization error: %v", err)
 	}
 	core := &Core{
-		cfg:           cfg,
-		db:            db,
-		conns:         make(map[string]*dexConnection),
-		wallets:       make(map[uint32]*xcWallet),
-		net:           cfg.Net,
-		lockTimeTaker: dex.LockTimeTaker(cfg.Net),
-		lockTimeMaker: dex.LockTimeMaker(cfg.Net),
-		blockWaiters:  make(map[uint64]*blockWaiter),
+		cfg:               cfg,
+		db:                db,
+		conns:             make(map[string]*dexConnection),
+		wallets:           make(map[string]*xcWallet),
+		net:               cfg.Net,
+		lockTimeTaker:     dex.LockTimeTaker(cfg.Net),
+		lockTimeMaker:     dex.LockTimeMaker(cfg.Net),
+		userAgent:         cfg.UserAgent,
+		locale:            loc,
+		blockWaiters:      make(map[uint64]*blockWaiter),
+		schedFeeds:        make(map[string]*BookFeed),
+		alertFeeds:        make(map[string]*BookFeed),
+		alertFills:        make(map[string]uint64),
+		alertMatches:      make(map[string]map[string]bool),
+		prompts:           make(map[string]*pendingPrompt),
+		noteRoutes:        make(map[string]*NoteRoute),
+		coinPolicies:      make(map[uint32]*CoinPolicy),
+		fundingStrategies: make(map[uint32]asset.FundingStrategy),
+		depositAddrs:      make(map[uint32][]*DepositAddress),
+		proxies:           make(map[string]*ProxyConfig),
+		cancelEpochs:      make(map[string]uint32),
+		swapConfs:         make(map[uint32]uint32),
+		ready:             make(chan struct{}),
 		// Allowing to change the constructor makes testing a lot easier.
 		wsConstructor: comms.NewWsConn,
 		newCrypter:    encrypt.NewCrypter,
 		reCrypter:     encrypt.Deserialize,
 		latencyQ:      wait.NewTickerQueue(recheckInterval),
+		rateSource:    cfg.RateSource,
 	}
 
 	// Populate the initial user data. User won't include any DEX info yet, as
 	// those are retrieved when Run is called and the core connects to the DEXes.
 	core.refreshUser()
+	core.loadNoteRoutes()
+	core.loadCoinPolicies()
+	core.loadFundingStrategies()
+	core.loadProxies()
+	core.loadPins()
+	core.loadCancelOnDisconnect()
+	core.loadSwapConfs()
+	core.loadAutoRefund()
+	core.loadLotSizePolicy()
+	core.loadTradeGuard()
+	core.loadDepositAddresses()
 	log.Debugf("new client core created")
 	return core, nil
 }
@@ -528,6 +678,7 @@ func (c *Core) Run(ctx context.Context) {
 	// when new accounts are registered.
 	c.ctx = ctx
 	c.initialize()
+	close(c.ready)
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
@@ -538,10 +689,53 @@ func (c *Core) Run(ctx context.Context) {
 		defer c.wg.Done()
 		c.latencyQ.Run(ctx)
 	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runScheduler(ctx)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runAlerts(ctx)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runPaper(ctx)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runCoins(ctx)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runDeposits(ctx)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runWalletHealth(ctx)
+	}()
+	if c.rateSource != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.runFiatRates(ctx)
+		}()
+	}
 	c.wg.Wait()
 	log.Infof("DEX client core off")
 }
 
+// Ready returns a channel that is closed when Run has started the core, after
+// which the user may log in.
+func (c *Core) Ready() <-chan struct{} {
+	return c.ready
+}
+
 // addrHost returns the host or url:port pair for an address.
 func addrHost(addr string) string {
 	const defaultHost = "localhost"
@@ -550,6 +744,10 @@ func addrHost(addr string) string {
 	if addr == "" {
 		return defaultHost
 	}
+	// The host of an account host keeps its account label.
+	if label, host := splitAccountHost(addr); label != "" {
+		return accountHost(label, addrHost(host))
+	}
 	host, port, splitErr := net.SplitHostPort(addr)
 	_, portErr := strconv.ParseUint(port, 10, 16)
 	// net.SplitHostPort will error on anything not in the format
@@ -591,27 +789,72 @@ func (c *Core) Exchanges() map[string]*Exchange {
 	defer c.connMtx.RUnlock()
 	infos := make(map[string]*Exchange, len(c.conns))
 	for host, dc := range c.conns {
+		label, _ := splitAccountHost(host)
 		infos[host] = &Exchange{
 			Host:          host,
+			Account:       label,
 			Markets:       dc.markets(),
-			Assets:        dc.assets,
+			Assets:        dc.assetMap(),
 			FeePending:    dc.acct.feePending(),
 			Connected:     dc.connected,
 			ConfsRequired: uint32(dc.cfg.RegFeeConfirms),
 			RegConfirms:   dc.getRegConfirms(),
+			APIVersion:    dc.apiVersion(),
+			Upgrade:       dc.requiredUpgrade(),
 		}
 	}
 	return infos
 }
 
-// wallet gets the wallet for the specified asset ID in a thread-safe way.
+// wallet gets the default wallet for the specified asset ID in a thread-safe
+// way.
 func (c *Core) wallet(assetID uint32) (*xcWallet, bool) {
 	c.walletMtx.RLock()
 	defer c.walletMtx.RUnlock()
-	w, found := c.wallets[assetID]
+	w := c.defaultWallet(assetID)
+	return w, w != nil
+}
+
+// namedWallet gets the asset's wallet with the name in a thread-safe way. An
+// empty name gets the asset's default wallet.
+func (c *Core) namedWallet(assetID uint32, name string) (*xcWallet, bool) {
+	if name == "" {
+		return c.wallet(assetID)
+	}
+	c.walletMtx.RLock()
+	defer c.walletMtx.RUnlock()
+	w, found := c.wallets[db.WalletSID(assetID, name)]
 	return w, found
 }
 
+// defaultWallet is the asset's unnamed wallet, or the first of its named
+// wallets if there is no unnamed wallet. defaultWallet should be called with
+// the walletMtx locked.
+func (c *Core) defaultWallet(assetID uint32) *xcWallet {
+	if w, found := c.wallets[db.WalletSID(assetID, "")]; found {
+		return w
+	}
+	if wallets := c.assetWallets(assetID); len(wallets) > 0 {
+		return wallets[0]
+	}
+	return nil
+}
+
+// assetWallets returns the asset's wallets, sorted by name. assetWallets
+// should be called with the walletMtx locked.
+func (c *Core) assetWallets(assetID uint32) []*xcWallet {
+	var wallets []*xcWallet
+	for _, w := range c.wallets {
+		if w.AssetID == assetID {
+			wallets = append(wallets, w)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].Name < wallets[j].Name
+	})
+	return wallets
+}
+
 // encryptionKey retrieves the application encryption key. The key itself is
 // encrypted using an encryption key derived from the user's password.
 func (c *Core) encryptionKey(pw []byte) (encrypt.Crypter, error) {
@@ -626,11 +869,21 @@ func (c *Core) encryptionKey(pw []byte) (encrypt.Crypter, error) {
 	return crypter, nil
 }
 
-// connectedWallet fetches a wallet and will connect the wallet if it is not
-// already connected.
+// connectedWallet fetches the asset's default wallet and will connect the
+// wallet if it is not already connected.
 func (c *Core) connectedWallet(assetID uint32) (*xcWallet, error) {
-	wallet, exists := c.wallet(assetID)
+	return c.connectedNamedWallet(assetID, "")
+}
+
+// connectedNamedWallet fetches the asset's wallet with the name and will
+// connect the wallet if it is not already connected. An empty name is the
+// asset's default wallet.
+func (c *Core) connectedNamedWallet(assetID uint32, name string) (*xcWallet, error) {
+	wallet, exists := c.namedWallet(assetID, name)
 	if !exists {
+		if name != "" {
+			return nil, fmt.Errorf("no %s wallet named %q", unbip(assetID), name)
+		}
 		return nil, fmt.Errorf("no wallet found for %d -> %s", assetID, unbip(assetID))
 	}
 	if !wallet.connected() {
@@ -685,28 +938,34 @@ func (c *Core) walletBalances(wallet *xcWallet) (*db.Balance, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error updating %s balance in database: %v", unbip(wallet.AssetID), err)
 	}
-	c.notify(newBalanceNote(wallet.AssetID, dbBal))
+	c.notify(newBalanceNote(wallet.AssetID, wallet.Name, dbBal))
 	return dbBal, nil
 }
 
-// updateBalances updates the balance for every key in the counter map.
-// Notifications are sent and refreshUser is called.
+// updateBalances updates the balances of the wallets of every asset in the
+// counter map. Notifications are sent and refreshUser is called.
 func (c *Core) updateBalances(counts assetCounter) {
 	if len(counts) == 0 {
 		return
 	}
 	for assetID := range counts {
-		w, exists := c.wallet(assetID)
-		if !exists {
+		c.walletMtx.RLock()
+		wallets := c.assetWallets(assetID)
+		c.walletMtx.RUnlock()
+		if len(wallets) == 0 {
 			// This should never be the case, but log an error in case I'm
 			// wrong or something changes.
 			log.Errorf("non-existent wallet should exist")
 			continue
 		}
-		_, err := c.walletBalances(w)
-		if err != nil {
-			log.Error("error updateing balance after tick: %v", err)
-			continue
+		for _, w := range wallets {
+			if !w.connected() {
+				continue
+			}
+			_, err := c.walletBalances(w)
+			if err != nil {
+				log.Error("error updateing balance after tick: %v", err)
+			}
 		}
 	}
 	c.refreshUser()
@@ -724,8 +983,14 @@ func (c *Core) Wallets() []*WalletState {
 	defer c.walletMtx.RUnlock()
 	state := make([]*WalletState, 0, len(c.wallets))
 	for _, wallet := range c.wallets {
-		state = append(state, wallet.state())
+		state = append(state, c.walletState(wallet))
 	}
+	sort.Slice(state, func(i, j int) bool {
+		if state[i].AssetID == state[j].AssetID {
+			return state[i].Name < state[j].Name
+		}
+		return state[i].AssetID < state[j].AssetID
+	})
 	return state
 }
 
@@ -738,9 +1003,8 @@ func (c *Core) SupportedAssets() map[uint32]*SupportedAsset {
 	defer c.walletMtx.RUnlock()
 	for assetID, asset := range supported {
 		var wallet *WalletState
-		w, found := c.wallets[assetID]
-		if found {
-			wallet = w.state()
+		if w := c.defaultWallet(assetID); w != nil {
+			wallet = c.walletState(w)
 		}
 		assets[assetID] = &SupportedAsset{
 			ID:     assetID,
@@ -766,22 +1030,37 @@ func (c *Core) refreshUser() {
 	if err != nil {
 		log.Errorf("refreshUser: error checking if app is initialized: %v", err)
 	}
+	currency, rates := c.currentFiatRates()
 	u := &User{
 		Assets:      c.SupportedAssets(),
 		Exchanges:   c.Exchanges(),
 		Initialized: initialized,
+		LoggedIn:    c.LoggedIn(),
+		Currency:    currency,
+		FiatRates:   rates,
 	}
 	c.userMtx.Lock()
 	c.user = u
 	c.userMtx.Unlock()
 }
 
-// CreateWallet creates a new exchange wallet.
+// CreateWallet creates a new exchange wallet. An asset may have more than one
+// wallet, e.g. a built-in SPV wallet and a full node wallet, but each wallet
+// after the first must be named.
 func (c *Core) CreateWallet(appPW, walletPW []byte, form *WalletForm) error {
 	assetID := form.AssetID
 	symbol := unbip(assetID)
-	_, exists := c.wallet(assetID)
+	c.walletMtx.RLock()
+	_, exists := c.wallets[db.WalletSID(assetID, form.Name)]
+	var builtInExists bool
+	for _, w := range c.assetWallets(assetID) {
+		builtInExists = builtInExists || w.builtIn
+	}
+	c.walletMtx.RUnlock()
 	if exists {
+		if form.Name != "" {
+			return fmt.Errorf("%s wallet %q already exists", symbol, form.Name)
+		}
 		return fmt.Errorf("%s wallet already exists", symbol)
 	}
 
@@ -789,11 +1068,6 @@ func (c *Core) CreateWallet(appPW, walletPW []byte, form *WalletForm) error {
 	if err != nil {
 		return err
 	}
-	encPW, err := crypter.Encrypt(walletPW)
-	if err != nil {
-		return fmt.Errorf("wallet password encryption error: %v", err)
-	}
-
 	walletInfo, err := asset.Info(assetID)
 	if err != nil {
 		return err
@@ -824,15 +1098,42 @@ func (c *Core) CreateWallet(appPW, walletPW []byte, form *WalletForm) error {
 		}
 	}
 
+	// The keys of a built-in wallet are derived from the application seed,
+	// and the wallet password is generated if not provided, so that the
+	// application password is the only password the user needs.
+	var seed []byte
+	if builtInWallet(settings) {
+		// The built-in wallet's keys are derived for the asset.
+		if builtInExists {
+			return fmt.Errorf("a built-in %s wallet already exists", symbol)
+		}
+		masterSeed, err := c.appSeed(crypter)
+		if err != nil {
+			return err
+		}
+		seed = walletSeed(masterSeed, assetID)
+		encode.ClearBytes(masterSeed)
+		defer encode.ClearBytes(seed)
+		if len(walletPW) == 0 {
+			walletPW = []byte(hex.EncodeToString(encode.RandomBytes(32)))
+		}
+	}
+
+	encPW, err := crypter.Encrypt(walletPW)
+	if err != nil {
+		return fmt.Errorf("wallet password encryption error: %v", err)
+	}
+
 	dbWallet := &db.Wallet{
 		AssetID:     assetID,
+		Name:        form.Name,
 		Account:     form.Account,
 		Balance:     &db.Balance{},
 		Settings:    settings,
 		EncryptedPW: encPW,
 	}
 
-	wallet, err := c.loadWallet(dbWallet)
+	wallet, err := c.loadWallet(dbWallet, seed)
 	if err != nil {
 		return fmt.Errorf("error loading wallet for %d -> %s: %v", assetID, symbol, err)
 	}
@@ -878,7 +1179,7 @@ func (c *Core) CreateWallet(appPW, walletPW []byte, form *WalletForm) error {
 
 	// The wallet has been successfully created. Store it.
 	c.walletMtx.Lock()
-	c.wallets[assetID] = wallet
+	c.wallets[wallet.id()] = wallet
 	c.walletMtx.Unlock()
 
 	c.refreshUser()
@@ -886,11 +1187,14 @@ func (c *Core) CreateWallet(appPW, walletPW []byte, form *WalletForm) error {
 }
 
 // loadWallet uses the data from the database to construct a new exchange
-// wallet. The returned wallet is running but not connected.
-func (c *Core) loadWallet(dbWallet *db.Wallet) (*xcWallet, error) {
+// wallet. The returned wallet is running but not connected. The seed is only
+// provided when the wallet is being created.
+func (c *Core) loadWallet(dbWallet *db.Wallet, seed []byte) (*xcWallet, error) {
 	wallet := &xcWallet{
 		Account: dbWallet.Account,
 		AssetID: dbWallet.AssetID,
+		Name:    dbWallet.Name,
+		builtIn: builtInWallet(dbWallet.Settings),
 		balance: dbWallet.Balance,
 		encPW:   dbWallet.EncryptedPW,
 		address: dbWallet.Address,
@@ -905,10 +1209,19 @@ func (c *Core) loadWallet(dbWallet *db.Wallet) (*xcWallet, error) {
 		FallbackFeeRate: 80,
 		Settings:        dbWallet.Settings,
 		TipChange: func(err error) {
+			if err != nil {
+				wallet.recordError(err)
+			}
 			c.tipChange(dbWallet.AssetID, err)
 		},
+		DevicePrompt: c.devicePrompter(dbWallet.AssetID),
+		Seed:         seed,
+	}
+	logName := unbip(dbWallet.AssetID)
+	if dbWallet.Name != "" {
+		logName += "-" + dbWallet.Name
 	}
-	logger := loggerMaker.SubLogger("CORE", unbip(dbWallet.AssetID))
+	logger := loggerMaker.SubLogger("CORE", logName)
 	w, err := asset.Setup(dbWallet.AssetID, walletCfg, logger, c.net)
 	if err != nil {
 		return nil, fmt.Errorf("error creating wallet: %v", err)
@@ -918,25 +1231,31 @@ func (c *Core) loadWallet(dbWallet *db.Wallet) (*xcWallet, error) {
 	return wallet, nil
 }
 
-// WalletState returns the *WalletState for the asset ID.
+// WalletState returns the *WalletState for the asset's default wallet.
 func (c *Core) WalletState(assetID uint32) *WalletState {
 	c.walletMtx.Lock()
 	defer c.walletMtx.Unlock()
-	wallet, has := c.wallets[assetID]
-	if !has {
+	wallet := c.defaultWallet(assetID)
+	if wallet == nil {
 		log.Tracef("wallet status requested for unknown asset %d -> %s", assetID, unbip(assetID))
 		return nil
 	}
-	return wallet.state()
+	return c.walletState(wallet)
 }
 
-// OpenWallet opens (unlocks) the wallet for use.
+// OpenWallet opens (unlocks) the asset's default wallet for use.
 func (c *Core) OpenWallet(assetID uint32, appPW []byte) error {
+	return c.OpenNamedWallet(assetID, "", appPW)
+}
+
+// OpenNamedWallet opens (unlocks) the asset's wallet with the name for use. An
+// empty name opens the asset's default wallet.
+func (c *Core) OpenNamedWallet(assetID uint32, name string, appPW []byte) error {
 	crypter, err := c.encryptionKey(appPW)
 	if err != nil {
 		return err
 	}
-	wallet, err := c.connectedWallet(assetID)
+	wallet, err := c.connectedNamedWallet(assetID, name)
 	if err != nil {
 		return fmt.Errorf("OpenWallet: wallet not found for %d -> %s: %v", assetID, unbip(assetID), err)
 	}
@@ -954,7 +1273,9 @@ func (c *Core) OpenWallet(assetID uint32, appPW []byte) error {
 		"= %d / locked = %d, Deposit address = %s",
 		state.Symbol, wallet.Account, balances.Available, balances.Locked, state.Address)
 
-	if dcrID, _ := dex.BipSymbolID("dcr"); assetID == dcrID {
+	// Registration fees are paid from the default wallet.
+	dcrID, _ := dex.BipSymbolID("dcr")
+	if feeWallet, _ := c.wallet(dcrID); wallet == feeWallet {
 		go c.checkUnpaidFees(wallet)
 	}
 	c.refreshUser()
@@ -974,6 +1295,51 @@ func unlockWallet(wallet *xcWallet, crypter encrypt.Crypter) error {
 	return nil
 }
 
+// RescanWallet rescans the blockchain from the block height for transactions
+// that the wallet missed. The wallet must implement asset.Rescanner.
+func (c *Core) RescanWallet(assetID, height uint32) error {
+	wallet, err := c.connectedWallet(assetID)
+	if err != nil {
+		return fmt.Errorf("RescanWallet: wallet not found for %d -> %s: %v", assetID, unbip(assetID), err)
+	}
+	rescanner, ok := wallet.Wallet.(asset.Rescanner)
+	if !ok {
+		return fmt.Errorf("%s wallet does not support rescanning", unbip(assetID))
+	}
+	if err := rescanner.Rescan(height); err != nil {
+		return fmt.Errorf("error rescanning %s wallet: %v", unbip(assetID), err)
+	}
+	c.updateAssetBalance(assetID)
+	return nil
+}
+
+// ExportWalletSeed gets the seed of a wallet whose seed is managed by the
+// client, such as a built-in SPV wallet, for backup. The wallet must
+// implement asset.SeedExporter.
+func (c *Core) ExportWalletSeed(assetID uint32, appPW []byte) (dex.Bytes, error) {
+	crypter, err := c.encryptionKey(appPW)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := c.connectedWallet(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("ExportWalletSeed: wallet not found for %d -> %s: %v", assetID, unbip(assetID), err)
+	}
+	exporter, ok := wallet.Wallet.(asset.SeedExporter)
+	if !ok {
+		return nil, fmt.Errorf("%s wallet does not support seed export", unbip(assetID))
+	}
+	pwB, err := crypter.Decrypt(wallet.encPW)
+	if err != nil {
+		return nil, fmt.Errorf("ExportWalletSeed decryption error: %v", err)
+	}
+	seed, err := exporter.ExportSeed(string(pwB))
+	if err != nil {
+		return nil, fmt.Errorf("error exporting %s wallet seed: %v", unbip(assetID), err)
+	}
+	return seed, nil
+}
+
 // CloseWallet closes the wallet for the specified asset. The wallet cannot be
 // closed if there are active negotiations for the asset.
 func (c *Core) CloseWallet(assetID uint32) error {
@@ -1017,10 +1383,7 @@ func (c *Core) GetFee(dexAddr, cert string) (uint64, error) {
 	if c.isRegistered(host) {
 		return 0, newError(dupeDEXErr, "already registered at %s", dexAddr)
 	}
-	dc, err := c.connectDEX(&db.AccountInfo{
-		Host: host,
-		Cert: []byte(cert),
-	})
+	dc, err := c.connectDEX(c.pinnedAccountInfo(host, []byte(cert)))
 	if err != nil {
 		return 0, codedError(connectionErr, err)
 	}
@@ -1028,6 +1391,52 @@ func (c *Core) GetFee(dexAddr, cert string) (uint64, error) {
 	return dc.cfg.Fee, nil
 }
 
+// GetPolicy fetches the operator's policy document from the specified DEX
+// server and verifies its signature. If the DEX is already registered, the
+// document must be signed with the known DEX key. Otherwise, the key is that
+// of the document, and can be compared to the key learned at registration.
+func (c *Core) GetPolicy(dexAddr, cert string) (*Policy, error) {
+	host := addrHost(dexAddr)
+	c.connMtx.RLock()
+	dc, registered := c.conns[host]
+	c.connMtx.RUnlock()
+	if !registered {
+		var err error
+		dc, err = c.connectDEX(c.pinnedAccountInfo(host, []byte(cert)))
+		if err != nil {
+			return nil, codedError(connectionErr, err)
+		}
+		defer dc.connMaster.Disconnect()
+	}
+
+	if err := dc.checkRoute(msgjson.PolicyRoute); err != nil {
+		return nil, err
+	}
+	policy := new(msgjson.Policy)
+	err := sendRequest(dc.WsConn, msgjson.PolicyRoute, nil, policy)
+	if err != nil {
+		return nil, codedError(connectionErr, err)
+	}
+
+	dexPubKey, err := checkSigS256(policy.Serialize(), policy.DEXPubKey, policy.Sig)
+	if err != nil {
+		return nil, newError(signatureErr, "DEX policy signature validation error: %v", err)
+	}
+	if registered && !dc.acct.isDEXKey(dexPubKey) {
+		return nil, newError(signatureErr, "DEX policy for %s is not signed by the account's DEX key", host)
+	}
+
+	return &Policy{
+		Host:      host,
+		PubKey:    policy.DEXPubKey,
+		Published: encode.UnixTimeMilli(int64(policy.Stamp)),
+		Fees:      policy.Fees,
+		Penalties: policy.Penalties,
+		Contact:   policy.Contact,
+		Terms:     policy.Terms,
+	}, nil
+}
+
 // Register registers an account with a new DEX. If an error occurs while
 // fetching the DEX configuration or creating the fee transaction, it will be
 // returned immediately.
@@ -1043,9 +1452,12 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 	if form.Addr == "" {
 		return nil, newError(emptyHostErr, "no dex address specified")
 	}
-	host := addrHost(form.Addr)
+	if err := checkAccountLabel(form.Account); err != nil {
+		return nil, newError(accountLabelErr, "%v", err)
+	}
+	host := selectAccount(form.Addr, form.Account)
 	if c.isRegistered(host) {
-		return nil, newError(dupeDEXErr, "already registered at %s", form.Addr)
+		return nil, newError(dupeDEXErr, "already registered at %s", host)
 	}
 
 	regFeeAssetID, _ := dex.BipSymbolID(regFeeAssetSymbol)
@@ -1061,10 +1473,10 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 		}
 	}
 
-	dc, err := c.connectDEX(&db.AccountInfo{
-		Host: host,
-		Cert: []byte(form.Cert),
-	})
+	// An additional account uses the certificate of the accounts already at the
+	// DEX unless another is provided. The certificate and key pinned from a
+	// server bundle are used for a new DEX.
+	dc, err := c.connectDEX(c.pinnedAccountInfo(host, []byte(form.Cert)))
 	if err != nil {
 		return nil, codedError(connectionErr, err)
 	}
@@ -1077,12 +1489,17 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 		}
 	}()
 
-	regAsset, found := dc.assets[regFeeAssetID]
-	if !found {
+	regAsset := dc.asset(regFeeAssetID)
+	if regAsset == nil {
 		return nil, newError(assetSupportErr, "dex server does not support %s asset", regFeeAssetSymbol)
 	}
 
-	privKey, err := dc.acct.setupEncryption(crypter)
+	seed, err := c.appSeed(crypter)
+	if err != nil {
+		return nil, codedError(acctKeyErr, err)
+	}
+	privKey, err := dc.acct.setupEncryption(crypter, accountKey(seed, host))
+	encode.ClearBytes(seed)
 	if err != nil {
 		return nil, codedError(acctKeyErr, err)
 	}
@@ -1095,6 +1512,14 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 	}
 	regRes := new(msgjson.RegisterResult)
 	err = dc.signAndRequest(dexReg, msgjson.RegisterRoute, regRes)
+	if nonce, required, powErr := c.solvePoW(dc.acct.host, err, dexReg.Serialize()); required {
+		if powErr != nil {
+			return nil, codedError(registerErr, powErr)
+		}
+		// The nonce is not signed, so the signed request is resent with it.
+		dexReg.Nonce = nonce
+		err = sendRequest(dc.WsConn, msgjson.RegisterRoute, dexReg, regRes)
+	}
 	if err != nil {
 		return nil, codedError(registerErr, err)
 	}
@@ -1105,6 +1530,9 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 	if err != nil {
 		return nil, newError(signatureErr, "DEX signature validation error: %v", err)
 	}
+	if !dc.acct.isDEXKey(dexPubKey) {
+		return nil, newError(signatureErr, "DEX 'register' result signed by %x, which is not the key in the 'config' result", regRes.DEXPubKey)
+	}
 
 	// Check that the fee is non-zero.
 	if regRes.Fee == 0 {
@@ -1130,9 +1558,13 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 	c.connMtx.Lock()
 	c.conns[host] = dc
 	c.connMtx.Unlock()
+	_, dexHost := splitAccountHost(host)
+	c.unpinServer(dexHost)
 
 	// Set the dexConnection account fields and save account info to db.
+	dc.acct.dexKeyMtx.Lock()
 	dc.acct.dexPubKey = dexPubKey
+	dc.acct.dexKeyMtx.Unlock()
 	dc.acct.feeCoin = coin.ID()
 	err = c.db.CreateAccount(dc.acct.dbInfo())
 	if err != nil {
@@ -1142,8 +1574,7 @@ func (c *Core) Register(form *RegisterForm) (*RegisterResult, error) {
 
 	c.updateAssetBalance(regFeeAssetID)
 
-	details := fmt.Sprintf("Waiting for %d confirmations before trading at %s", dc.cfg.RegFeeConfirms, dc.acct.host)
-	c.notify(newFeePaymentNote("Fee payment in progress", details, db.Success, dc.acct.host))
+	c.notify(newFeePaymentNote(TopicFeePaymentInProgress, db.Success, dc.acct.host, dc.cfg.RegFeeConfirms, dc.acct.host))
 
 	// Set up the coin waiter.
 	c.verifyRegistrationFee(wallet, dc, coin.ID(), 0)
@@ -1168,12 +1599,10 @@ func (c *Core) verifyRegistrationFee(wallet *xcWallet, dc *dexConnection, coinID
 		if err != nil && !errors.Is(err, asset.CoinNotFoundError) {
 			return false, fmt.Errorf("Error getting confirmations for %s: %v", coinIDString(wallet.AssetID, coinID), err)
 		}
-		details := fmt.Sprintf("Fee payment confirmations %v/%v", confs, uint32(reqConfs))
-
 		if confs < uint32(reqConfs) {
 			dc.setRegConfirms(confs)
 			c.refreshUser()
-			c.notify(newFeePaymentNoteWithConfirmations("regupdate", details, db.Data, confs, dc.acct.host))
+			c.notify(newFeePaymentNoteWithConfirmations(TopicRegUpdate, db.Data, confs, dc.acct.host, confs, uint32(reqConfs)))
 		}
 
 		return confs >= uint32(reqConfs), nil
@@ -1183,13 +1612,11 @@ func (c *Core) verifyRegistrationFee(wallet *xcWallet, dc *dexConnection, coinID
 		log.Debugf("Registration fee txn %s now has %d confirmations.", coinIDString(wallet.AssetID, coinID), reqConfs)
 		defer func() {
 			if err != nil {
-				details := fmt.Sprintf("Error encountered while paying fees to %s: %v", dc.acct.host, err)
-				c.notify(newFeePaymentNote("Fee payment error", details, db.ErrorLevel, dc.acct.host))
+				c.notify(newFeePaymentNote(TopicFeePaymentError, db.ErrorLevel, dc.acct.host, dc.acct.host, err))
 			} else {
-				details := fmt.Sprintf("You may now trade at %s", dc.acct.host)
 				dc.setRegConfirms(regConfirmationsPaid)
 				c.refreshUser()
-				c.notify(newFeePaymentNote("Account registered", details, db.Success, dc.acct.host))
+				c.notify(newFeePaymentNote(TopicAccountRegistered, db.Success, dc.acct.host, dc.acct.host))
 			}
 		}()
 		if err != nil {
@@ -1215,8 +1642,16 @@ func (c *Core) IsInitialized() (bool, error) {
 	return c.db.ValueExists(keyParamsKey)
 }
 
-// InitializeClient sets the initial app-wide password for the client.
+// InitializeClient sets the initial app-wide password for the client, and
+// creates the application seed from which the client's keys are derived.
 func (c *Core) InitializeClient(pw []byte) error {
+	seed := encode.RandomBytes(appSeedSize)
+	defer encode.ClearBytes(seed)
+	return c.initializeClient(pw, seed)
+}
+
+// initializeClient sets the app-wide password and stores the application seed.
+func (c *Core) initializeClient(pw, seed []byte) error {
 	if initialized, err := c.IsInitialized(); err != nil {
 		return fmt.Errorf("error checking if app is already initialized: %v", err)
 	} else if initialized {
@@ -1228,6 +1663,10 @@ func (c *Core) InitializeClient(pw []byte) error {
 	}
 
 	crypter := c.newCrypter(pw)
+	defer crypter.Close()
+	if err := c.storeAppSeed(crypter, seed); err != nil {
+		return err
+	}
 	err := c.db.Store(keyParamsKey, crypter.Serialize())
 	if err != nil {
 		return fmt.Errorf("error storing key parameters: %v", err)
@@ -1242,6 +1681,9 @@ func (c *Core) Login(pw []byte) (*LoginResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.loginMtx.Lock()
+	c.loggedIn = true
+	c.loginMtx.Unlock()
 
 	loaded := c.resolveActiveTrades(crypter)
 	if loaded > 0 {
@@ -1291,9 +1733,21 @@ func (c *Core) Logout() error {
 		dc.acct.lock()
 	}
 
+	c.loginMtx.Lock()
+	c.loggedIn = false
+	c.loginMtx.Unlock()
 	return nil
 }
 
+// LoggedIn is true if the user has logged in with the app password. Before
+// login, the core is in read-only mode, with the markets, order books and order
+// history available, but no DEX account or wallet keys decrypted.
+func (c *Core) LoggedIn() bool {
+	c.loginMtx.RLock()
+	defer c.loginMtx.RUnlock()
+	return c.loggedIn
+}
+
 // initializeDEXConnections connects to the DEX servers in the conns map and
 // authenticates the connection. If registration is incomplete, reFee is run and
 // the connection will be authenticated once the `notifyfee` request is sent.
@@ -1324,18 +1778,18 @@ func (c *Core) initializeDEXConnections(crypter encrypt.Crypter) []*DEXBrief {
 		}
 		err := dc.acct.unlock(crypter)
 		if err != nil {
-			details := fmt.Sprintf("error unlocking account for %s: %v", dc.acct.host, err)
-			c.notify(newFeePaymentNote("Account unlock error", details, db.ErrorLevel, dc.acct.host))
-			result.AuthErr = details
+			note := newFeePaymentNote(TopicAccountUnlockError, db.ErrorLevel, dc.acct.host, dc.acct.host, err)
+			c.notify(note)
+			result.AuthErr = note.Details()
 			continue
 		}
 		result.AcctID = dc.acct.ID().String()
 		dcrID, _ := dex.BipSymbolID("dcr")
 		if !dc.acct.feePaid() {
 			if len(dc.acct.feeCoin) == 0 {
-				details := fmt.Sprintf("Empty fee coin for %s.", dc.acct.host)
-				c.notify(newFeePaymentNote("Fee coin error", details, db.ErrorLevel, dc.acct.host))
-				result.AuthErr = details
+				note := newFeePaymentNote(TopicFeeCoinError, db.ErrorLevel, dc.acct.host, dc.acct.host)
+				c.notify(note)
+				result.AuthErr = note.Details()
 				continue
 			}
 			// Try to unlock the Decred wallet, which should run the reFee cycle, and
@@ -1343,17 +1797,17 @@ func (c *Core) initializeDEXConnections(crypter encrypt.Crypter) []*DEXBrief {
 			dcrWallet, err := c.connectedWallet(dcrID)
 			if err != nil {
 				log.Debugf("Failed to connect for reFee at %s with error: %v", dc.acct.host, err)
-				details := fmt.Sprintf("Incomplete registration detected for %s, but failed to connect to the Decred wallet", dc.acct.host)
-				c.notify(newFeePaymentNote("Wallet connection warning", details, db.WarningLevel, dc.acct.host))
-				result.AuthErr = details
+				note := newFeePaymentNote(TopicWalletConnectionWarning, db.WarningLevel, dc.acct.host, dc.acct.host)
+				c.notify(note)
+				result.AuthErr = note.Details()
 				continue
 			}
 			if !dcrWallet.unlocked() {
 				err = unlockWallet(dcrWallet, crypter)
 				if err != nil {
-					details := fmt.Sprintf("Connected to Decred wallet to complete registration at %s, but failed to unlock: %v", dc.acct.host, err)
-					c.notify(newFeePaymentNote("Wallet unlock error", details, db.ErrorLevel, dc.acct.host))
-					result.AuthErr = details
+					note := newFeePaymentNote(TopicWalletUnlockError, db.ErrorLevel, dc.acct.host, dc.acct.host, err)
+					c.notify(note)
+					result.AuthErr = note.Details()
 					continue
 				}
 			}
@@ -1364,14 +1818,15 @@ func (c *Core) initializeDEXConnections(crypter encrypt.Crypter) []*DEXBrief {
 		wg.Add(1)
 		go func(dc *dexConnection) {
 			defer wg.Done()
-			err := c.authDEX(dc)
+			extras, err := c.authenticate(dc)
 			if err != nil {
-				details := fmt.Sprintf("%s: %v", dc.acct.host, err)
-				c.notify(newFeePaymentNote("DEX auth error", details, db.ErrorLevel, dc.acct.host))
-				result.AuthErr = details
+				note := newFeePaymentNote(TopicDexAuthError, db.ErrorLevel, dc.acct.host, dc.acct.host, err)
+				c.notify(note)
+				result.AuthErr = note.Details()
 				return
 			}
 			result.Authed = true
+			c.reconcileTrades(dc, extras)
 		}(dc)
 	}
 	wg.Wait()
@@ -1383,7 +1838,7 @@ func (c *Core) initializeDEXConnections(crypter encrypt.Crypter) []*DEXBrief {
 // are loaded, even if there are inactive matches for the same order, but it may
 // be desirable to load all matches, so this behavior may change.
 func (c *Core) resolveActiveTrades(crypter encrypt.Crypter) int {
-	failed := make(map[uint32]struct{})
+	failed := make(map[string]struct{})
 	c.connMtx.RLock()
 	defer c.connMtx.RUnlock()
 	var loaded int
@@ -1391,14 +1846,12 @@ func (c *Core) resolveActiveTrades(crypter encrypt.Crypter) int {
 		// loadDBTrades can add to the failed map.
 		ready, err := c.loadDBTrades(dc, crypter, failed)
 		if err != nil {
-			details := fmt.Sprintf("Some orders failed to load from the database: %v", err)
-			c.notify(newOrderNote("Order load failure", details, db.ErrorLevel, nil))
+			c.notify(newOrderNote(TopicOrderLoadFailure, db.ErrorLevel, nil, err))
 		}
 		if len(ready) > 0 {
 			err = c.resumeTrades(dc, ready)
 			if err != nil {
-				details := fmt.Sprintf("Some active orders failed to resume: %v", err)
-				c.notify(newOrderNote("Order resumption error", details, db.ErrorLevel, nil))
+				c.notify(newOrderNote(TopicOrderResumeFailure, db.ErrorLevel, nil, err))
 			}
 		}
 		loaded += len(ready)
@@ -1492,25 +1945,102 @@ func (c *Core) Withdraw(pw []byte, assetID uint32, value uint64, address string)
 	}
 	coin, err := wallet.Withdraw(address, value)
 	if err != nil {
-		details := fmt.Sprintf("Error encountered during %s withdraw: %v", unbip(assetID), err)
-		c.notify(newWithdrawNote("Withdraw error", details, db.ErrorLevel))
+		c.notify(newWithdrawNote(TopicWithdrawError, db.ErrorLevel, unbip(assetID), err))
 		return nil, err
 	} else {
-		details := fmt.Sprintf("Withdraw of %s has completed successfully. Coin ID = %s", unbip(assetID), coin)
-		c.notify(newWithdrawNote("Withdraw sent", details, db.Success))
+		c.notify(newWithdrawNote(TopicWithdrawSent, db.Success, unbip(assetID), coin))
 	}
 	c.updateAssetBalance(assetID)
 	return coin, nil
 }
 
-// Trade is used to place a market or limit order.
+// EstimateWithdrawal estimates the size and fees of a withdrawal to one or more
+// addresses, without signing or sending the transaction. The wallet must
+// implement asset.BatchWithdrawer.
+func (c *Core) EstimateWithdrawal(assetID uint32, w *asset.Withdrawal) (*asset.WithdrawalEstimate, error) {
+	withdrawer, err := c.batchWithdrawer(assetID, w)
+	if err != nil {
+		return nil, err
+	}
+	est, err := withdrawer.EstimateWithdrawal(w)
+	if err != nil {
+		return nil, fmt.Errorf("error estimating %s withdrawal: %v", unbip(assetID), err)
+	}
+	return est, nil
+}
+
+// SendWithdrawal sends a withdrawal to one or more addresses in a single
+// transaction. A coin is returned for each of the withdrawal's outputs. The
+// client password must be provided as an additional verification. The wallet
+// must implement asset.BatchWithdrawer.
+func (c *Core) SendWithdrawal(pw []byte, assetID uint32, w *asset.Withdrawal) ([]asset.Coin, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("SendWithdrawal password error: %v", err)
+	}
+	withdrawer, err := c.batchWithdrawer(assetID, w)
+	if err != nil {
+		return nil, err
+	}
+	wallet, _ := c.wallet(assetID)
+	err = c.connectAndUnlock(crypter, wallet)
+	if err != nil {
+		return nil, err
+	}
+	coins, err := withdrawer.SendWithdrawal(w)
+	if err != nil {
+		c.notify(newWithdrawNote(TopicWithdrawError, db.ErrorLevel, unbip(assetID), err))
+		return nil, err
+	}
+	c.notify(newWithdrawNote(TopicMultiWithdrawSent, db.Success, unbip(assetID), len(coins), coins))
+	c.updateAssetBalance(assetID)
+	return coins, nil
+}
+
+// batchWithdrawer checks the withdrawal and gets the connected wallet as an
+// asset.BatchWithdrawer.
+func (c *Core) batchWithdrawer(assetID uint32, w *asset.Withdrawal) (asset.BatchWithdrawer, error) {
+	if len(w.Outputs) == 0 {
+		return nil, fmt.Errorf("%s withdraw has no outputs", unbip(assetID))
+	}
+	for _, out := range w.Outputs {
+		if out.Value == 0 {
+			return nil, fmt.Errorf("%s zero withdraw to %s", unbip(assetID), out.Address)
+		}
+	}
+	wallet, err := c.connectedWallet(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s wallet error: %v", unbip(assetID), err)
+	}
+	withdrawer, ok := wallet.Wallet.(asset.BatchWithdrawer)
+	if !ok {
+		return nil, fmt.Errorf("%s wallet does not support withdrawals to multiple addresses or fee selection", unbip(assetID))
+	}
+	return withdrawer, nil
+}
+
+// Trade is used to place a market or limit order. The order is first checked
+// against the user's trade guards, which may return a *TradeGuardError.
 func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	// Check the user password.
 	crypter, err := c.encryptionKey(pw)
 	if err != nil {
 		return nil, fmt.Errorf("Trade password error: %v", err)
 	}
-	host := addrHost(form.Host)
+	placed, err := c.guardTrade(form)
+	if err != nil {
+		return nil, err
+	}
+	corder, err := c.trade(crypter, form)
+	placed(corder)
+	return corder, err
+}
+
+// trade places the order. The crypter is used to connect and unlock the
+// wallets if necessary. If the crypter is nil, the wallets must already be
+// connected and unlocked.
+func (c *Core) trade(crypter encrypt.Crypter, form *TradeForm) (*Order, error) {
+	host := form.acctHost()
 
 	// Get the dexConnection and the dex.Asset for each asset.
 	c.connMtx.RLock()
@@ -1520,6 +2050,10 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 		return nil, fmt.Errorf("unknown DEX %s", form.Host)
 	}
 
+	if err := dc.checkUpgrade(); err != nil {
+		return nil, err
+	}
+
 	mktID := marketName(form.Base, form.Quote)
 	mkt := dc.market(mktID)
 	if mkt == nil {
@@ -1536,20 +2070,37 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	if form.IsLimit && rate == 0 {
 		return nil, fmt.Errorf("zero-rate order not allowed")
 	}
+	if form.Visible > 0 && (!form.IsLimit || form.TifNow) {
+		return nil, fmt.Errorf("only standing limit orders may be iceberg orders")
+	}
 
-	wallets, err := c.walletSet(dc, form.Base, form.Quote, form.Sell)
+	tags, err := checkNotes(form.Notes, form.Tags)
 	if err != nil {
 		return nil, err
 	}
 
-	fromWallet, toWallet := wallets.fromWallet, wallets.toWallet
-	err = c.connectAndUnlock(crypter, fromWallet)
+	wallets, err := c.namedWalletSet(dc, form.Base, form.Quote, form.BaseWallet, form.QuoteWallet, form.Sell)
 	if err != nil {
 		return nil, err
 	}
-	err = c.connectAndUnlock(crypter, toWallet)
-	if err != nil {
-		return nil, err
+
+	fromWallet, toWallet := wallets.fromWallet, wallets.toWallet
+	for _, wallet := range []*xcWallet{fromWallet, toWallet} {
+		if crypter == nil {
+			if !wallet.connected() || !wallet.unlocked() {
+				return nil, fmt.Errorf("%s wallet is not connected and unlocked", wallet.label())
+			}
+			continue
+		}
+		err = c.connectAndUnlock(crypter, wallet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, wallet := range []*xcWallet{fromWallet, toWallet} {
+		if err = wallet.checkSynced(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get an address for the swap contract.
@@ -1563,8 +2114,12 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	if form.IsLimit && !form.Sell {
 		fundQty = calc.BaseToQuote(rate, fundQty)
 	}
-	coins, err := fromWallet.FundOrder(fundQty, wallets.fromAsset)
+	coins, err := c.fundOrder(fromWallet, fundQty, wallets.fromAsset, form)
 	if err != nil {
+		// Explain the failure if the balance is insufficient.
+		if funding, ferr := c.orderFunding(wallets, form); ferr == nil && !funding.OK {
+			return nil, fmt.Errorf("%v: %s", err, funding.Reason)
+		}
 		return nil, err
 	}
 	coinIDs := make([]order.CoinID, 0, len(coins))
@@ -1601,8 +2156,9 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 				Quantity: form.Qty,
 				Address:  addr,
 			},
-			Rate:  form.Rate,
-			Force: tif,
+			Rate:    form.Rate,
+			Force:   tif,
+			Visible: form.Visible,
 		}
 	} else {
 		ord = &order.MarketOrder{
@@ -1627,7 +2183,7 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	var result = new(msgjson.OrderResult)
 	err = dc.signAndRequest(msgOrder, route, result)
 	if err != nil {
-		return nil, err
+		return nil, orderRejectError(err)
 	}
 
 	// If we encounter an error, perform some basic logging.
@@ -1646,7 +2202,8 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 		return nil, err
 	}
 
-	// Store the order.
+	// Store the order, with the wallets that fund and receive its swaps.
+	baseWallet, quoteWallet := wallets.baseQuoteWallets()
 	dbOrder := &db.MetaOrder{
 		MetaData: &db.OrderMetaData{
 			Status: order.OrderStatusEpoch,
@@ -1655,6 +2212,8 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 				DEXSig:   result.Sig,
 				Preimage: preImg[:],
 			},
+			BaseWallet:  baseWallet.Name,
+			QuoteWallet: quoteWallet.Name,
 		},
 		Order: ord,
 	}
@@ -1663,23 +2222,33 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 		logAbandon(fmt.Sprintf("failed to store order in database: %v", err))
 		return nil, fmt.Errorf("Database error. order abandoned")
 	}
+	if form.Notes != "" || len(tags) > 0 {
+		dbOrder.MetaData.Notes, dbOrder.MetaData.Tags = form.Notes, tags
+		if err = c.db.SetOrderNotes(ord.ID(), form.Notes, tags); err != nil {
+			log.Errorf("Failed to store notes for order %s: %v", ord.ID(), err)
+		}
+	}
 
 	// Prepare and store the tracker and get the core.Order to return.
 	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, c.lockTimeTaker, c.lockTimeMaker,
 		c.db, c.latencyQ, wallets, coins, c.notify)
+	tracker.swapConfs = c.swapConfsSetting
+	tracker.autoRefund = c.autoRefundEnabled
 	corder, _ := tracker.coreOrder()
+	c.setOrderFiatValue(corder, form.Base, form.Quote)
 	dc.tradeMtx.Lock()
 	dc.trades[tracker.ID()] = tracker
 	dc.tradeMtx.Unlock()
 
 	// Send a low-priority notification.
-	details := fmt.Sprintf("%sing %.8f %s (%s)",
-		sellString(corder.Sell), float64(corder.Qty)/conversionFactor, unbip(form.Base), tracker.token())
-	if !form.IsLimit && !form.Sell {
-		details = fmt.Sprintf("selling %.8f %s (%s)",
-			float64(corder.Qty)/conversionFactor, unbip(form.Quote), tracker.token())
+	topic, placedAsset := TopicBuyOrderPlaced, form.Base
+	if corder.Sell {
+		topic = TopicSellOrderPlaced
+	} else if !form.IsLimit {
+		// Market buys are quantified in the quote asset.
+		topic, placedAsset = TopicSellOrderPlaced, form.Quote
 	}
-	c.notify(newOrderNote("Order placed", details, db.Poke, corder))
+	c.notify(newOrderNote(topic, db.Poke, corder, float64(corder.Qty)/conversionFactor, unbip(placedAsset), tracker.token()))
 
 	// Refresh the markets and user.
 	dc.refreshMarkets()
@@ -1699,25 +2268,39 @@ type walletSet struct {
 	toAsset    *dex.Asset
 }
 
-// walletSet constructs a walletSet.
+// baseQuoteWallets returns the base and quote asset wallets.
+func (w *walletSet) baseQuoteWallets() (base, quote *xcWallet) {
+	if w.fromAsset == w.baseAsset {
+		return w.fromWallet, w.toWallet
+	}
+	return w.toWallet, w.fromWallet
+}
+
+// walletSet constructs a walletSet with the assets' default wallets.
 func (c *Core) walletSet(dc *dexConnection, baseID, quoteID uint32, sell bool) (*walletSet, error) {
-	baseAsset, found := dc.assets[baseID]
-	if !found {
+	return c.namedWalletSet(dc, baseID, quoteID, "", "", sell)
+}
+
+// namedWalletSet constructs a walletSet with the assets' wallets with the
+// names. An empty name selects the asset's default wallet.
+func (c *Core) namedWalletSet(dc *dexConnection, baseID, quoteID uint32, baseName, quoteName string, sell bool) (*walletSet, error) {
+	baseAsset := dc.asset(baseID)
+	if baseAsset == nil {
 		return nil, fmt.Errorf("unknown base asset %d -> %s for %s", baseID, unbip(baseID), dc.acct.host)
 	}
-	quoteAsset, found := dc.assets[quoteID]
-	if !found {
+	quoteAsset := dc.asset(quoteID)
+	if quoteAsset == nil {
 		return nil, fmt.Errorf("unknown quote asset %d -> %s for %s", quoteID, unbip(quoteID), dc.acct.host)
 	}
 
 	// Connect and open the wallets if needed.
-	baseWallet, found := c.wallet(baseID)
+	baseWallet, found := c.namedWallet(baseID, baseName)
 	if !found {
-		return nil, fmt.Errorf("%s wallet not found", unbip(baseID))
+		return nil, fmt.Errorf("%s wallet not found", walletLabel(baseID, baseName))
 	}
-	quoteWallet, found := c.wallet(quoteID)
+	quoteWallet, found := c.namedWallet(quoteID, quoteName)
 	if !found {
-		return nil, fmt.Errorf("%s wallet not found", unbip(quoteID))
+		return nil, fmt.Errorf("%s wallet not found", walletLabel(quoteID, quoteName))
 	}
 
 	// We actually care less about base/quote, and more about from/to, which
@@ -1752,6 +2335,12 @@ func (c *Core) Cancel(pw []byte, tradeID string) error {
 	if err != nil {
 		return err
 	}
+	return c.cancelOrder(oid)
+}
+
+// cancelOrder sends a cancel order for the limit order. The account must be
+// logged in.
+func (c *Core) cancelOrder(oid order.OrderID) error {
 	dc, tracker, _ := c.findDEXOrder(oid)
 	if tracker == nil {
 		return fmt.Errorf("active order %s not found. cannot cancel", oid)
@@ -1774,7 +2363,7 @@ func (c *Core) Cancel(pw []byte, tradeID string) error {
 		},
 		TargetOrderID: oid,
 	}
-	err = order.ValidateOrder(co, order.OrderStatusEpoch, 0)
+	err := order.ValidateOrder(co, order.OrderStatusEpoch, 0)
 	if err != nil {
 		return err
 	}
@@ -1784,7 +2373,7 @@ func (c *Core) Cancel(pw []byte, tradeID string) error {
 	var result = new(msgjson.OrderResult)
 	err = dc.signAndRequest(msgOrder, route, result)
 	if err != nil {
-		return err
+		return orderRejectError(err)
 	}
 	err = validateOrderResponse(dc, result, co, msgOrder)
 	if err != nil {
@@ -1832,42 +2421,64 @@ func (c *Core) findDEXOrder(oid order.OrderID) (*dexConnection, *trackedTrade, b
 
 // authDEX authenticates the connection for a DEX.
 func (c *Core) authDEX(dc *dexConnection) error {
+	_, err := c.authenticate(dc)
+	return err
+}
+
+// authenticate authenticates the connection for a DEX, and returns the matches
+// reported by the server in the 'connect' response that are not known to an
+// active trade.
+func (c *Core) authenticate(dc *dexConnection) ([]*msgjson.Match, error) {
 	// Prepare and sign the message for the 'connect' route.
 	acctID := dc.acct.ID()
 	payload := &msgjson.Connect{
 		AccountID:  acctID[:],
-		APIVersion: 0,
+		APIVersion: msgjson.APIVersion,
 		Time:       encode.UnixMilliU(time.Now()),
+		UserAgent:  c.userAgent,
+	}
+	if payload.UserAgent == "" {
+		payload.UserAgent = DefaultUserAgent
 	}
 	sigMsg := payload.Serialize()
 	sig, err := dc.acct.sign(sigMsg)
 	if err != nil {
-		return fmt.Errorf("signing error: %v", err)
+		return nil, fmt.Errorf("signing error: %v", err)
 	}
 	payload.SetSig(sig)
+	var result = new(msgjson.ConnectResult)
 	// Send the 'connect' request.
-	req, err := msgjson.NewRequest(dc.NextID(), msgjson.ConnectRoute, payload)
-	if err != nil {
-		return fmt.Errorf("error encoding 'connect' request: %v", err)
+	connect := func() error {
+		req, err := msgjson.NewRequest(dc.NextID(), msgjson.ConnectRoute, payload)
+		if err != nil {
+			return fmt.Errorf("error encoding 'connect' request: %v", err)
+		}
+		errChan := make(chan error, 1)
+		err = dc.Request(req, func(msg *msgjson.Message) {
+			errChan <- msg.UnmarshalResult(result)
+		})
+		// Check the request error.
+		if err != nil {
+			return err
+		}
+		// Check the response error.
+		return extractError(errChan, requestTimeout, "connect")
 	}
-	errChan := make(chan error, 1)
-	var result = new(msgjson.ConnectResult)
-	err = dc.Request(req, func(msg *msgjson.Message) {
-		errChan <- msg.UnmarshalResult(result)
-	})
-	// Check the request error.
-	if err != nil {
-		return err
+	err = connect()
+	if nonce, required, powErr := c.solvePoW(dc.acct.host, err, sigMsg); required {
+		if powErr != nil {
+			return nil, powErr
+		}
+		payload.Nonce = nonce
+		err = connect()
 	}
-	// Check the response error.
-	err = extractError(errChan, requestTimeout, "connect")
 	if err != nil {
-		return fmt.Errorf("'connect' error: %v", err)
+		return nil, fmt.Errorf("'connect' error: %v", err)
 	}
 	// Check the signature.
 	err = dc.acct.checkSig(sigMsg, result.Sig)
 	if err != nil {
-		return newError(signatureErr, "DEX signature validation error: %v", err)
+		return nil, newError(signatureErr, "DEX signature validation error: %v", err)
 	}
 	log.Debugf("authenticated connection to %s", dc.acct.host)
 	// Set the account as authenticated.
@@ -1878,9 +2489,7 @@ func (c *Core) authDEX(dc *dexConnection) error {
 		log.Error(err)
 	}
 
-	dc.compareServerMatches(matches)
-
-	return nil
+	return dc.compareServerMatches(matches), nil
 }
 
 // AssetBalance retrieves the current wallet balance.
@@ -1921,8 +2530,7 @@ func (c *Core) initialize() {
 				log.Infof("Incomplete registration detected for DEX %s. "+
 					"Registration will be completed when the Decred wallet is unlocked.",
 					acct.Host)
-				details := fmt.Sprintf("Unlock your Decred wallet to complete registration for %s", acct.Host)
-				c.notify(newFeePaymentNote("Incomplete registration", details, db.WarningLevel, acct.Host))
+				c.notify(newFeePaymentNote(TopicIncompleteRegistration, db.WarningLevel, acct.Host, acct.Host))
 				// checkUnpaidFees will pay the fees if the wallet is unlocked
 			}
 			host := addrHost(acct.Host)
@@ -1949,7 +2557,7 @@ func (c *Core) initialize() {
 	}
 	c.walletMtx.Lock()
 	for _, dbWallet := range dbWallets {
-		wallet, err := c.loadWallet(dbWallet)
+		wallet, err := c.loadWallet(dbWallet, nil)
 		aid := dbWallet.AssetID
 		if err != nil {
 			log.Errorf("error loading %d -> %s wallet: %v", aid, unbip(aid), err)
@@ -1957,8 +2565,8 @@ func (c *Core) initialize() {
 		}
 		// Wallet is loaded from the DB, but not yet connected.
 		log.Infof("Loaded %s wallet configuration. Account %q, Deposit address = %s",
-			unbip(aid), dbWallet.Account, dbWallet.Address)
-		c.wallets[dbWallet.AssetID] = wallet
+			wallet.label(), dbWallet.Account, dbWallet.Address)
+		c.wallets[wallet.id()] = wallet
 	}
 	numWallets := len(c.wallets)
 	c.walletMtx.Unlock()
@@ -2028,12 +2636,10 @@ func (c *Core) reFee(dcrWallet *xcWallet, dc *dexConnection) {
 		err := c.notifyFee(dc, acctInfo.FeeCoin)
 		if err != nil {
 			log.Errorf("reFee %s - notifyfee error: %v", dc.acct.host, err)
-			details := fmt.Sprintf("Error encountered while paying fees to %s: %v", dc.acct.host, err)
-			c.notify(newFeePaymentNote("Fee payment error", details, db.ErrorLevel, dc.acct.host))
+			c.notify(newFeePaymentNote(TopicFeePaymentError, db.ErrorLevel, dc.acct.host, dc.acct.host, err))
 		} else {
 			log.Infof("Fee paid at %s", dc.acct.host)
-			details := fmt.Sprintf("You may now trade at %s.", dc.acct.host)
-			c.notify(newFeePaymentNote("Account registered", details, db.Success, dc.acct.host))
+			c.notify(newFeePaymentNote(TopicAccountRegistered, db.Success, dc.acct.host, dc.acct.host))
 			// dc.acct.pay() and c.authDEX????
 			dc.acct.markFeePaid()
 			err = c.authDEX(dc)
@@ -2101,8 +2707,11 @@ func (c *Core) dbTrackers(dc *dexConnection) (map[order.OrderID]*trackedTrade, e
 		} else {
 			var preImg order.Preimage
 			copy(preImg[:], dbOrder.MetaData.Proof.Preimage)
-			trackers[dbOrder.Order.ID()] = newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, c.lockTimeTaker,
+			tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, c.lockTimeTaker,
 				c.lockTimeMaker, c.db, c.latencyQ, nil, nil, c.notify)
+			tracker.swapConfs = c.swapConfsSetting
+			tracker.autoRefund = c.autoRefundEnabled
+			trackers[dbOrder.Order.ID()] = tracker
 		}
 	}
 	for oid := range cancels {
@@ -2132,9 +2741,9 @@ func (c *Core) dbTrackers(dc *dexConnection) (map[order.OrderID]*trackedTrade, e
 // loadDBTrades loads orders and matches from the database for the specified
 // dexConnection. If there are active trades, the necessary wallets will be
 // unlocked. To prevent spamming wallet connections, the 'failed' map will be
-// populated with asset IDs for which the attempt to connect or unlock has
+// populated with wallet IDs for which the attempt to connect or unlock has
 // failed. The failed map should be passed on subsequent calls for other dexes.
-func (c *Core) loadDBTrades(dc *dexConnection, crypter encrypt.Crypter, failed map[uint32]struct{}) ([]*trackedTrade, error) {
+func (c *Core) loadDBTrades(dc *dexConnection, crypter encrypt.Crypter, failed map[string]struct{}) ([]*trackedTrade, error) {
 	// Parse the active trades and see if any wallets need unlocking.
 	trades, err := c.dbTrackers(dc)
 	if err != nil {
@@ -2143,42 +2752,32 @@ func (c *Core) loadDBTrades(dc *dexConnection, crypter encrypt.Crypter, failed m
 
 	errs := newErrorSet(dc.acct.host + ": ")
 	ready := make([]*trackedTrade, 0, len(dc.trades))
-	for _, trade := range trades {
-		base, quote := trade.Base(), trade.Quote()
-		_, baseFailed := failed[base]
-		_, quoteFailed := failed[quote]
-		if !baseFailed {
-			baseWallet, err := c.connectedWallet(base)
-			if err != nil {
-				baseFailed = true
-				failed[base] = struct{}{}
-			} else if !baseWallet.unlocked() {
-				err = unlockWallet(baseWallet, crypter)
-				if err != nil {
-					baseFailed = true
-					failed[base] = struct{}{}
-				}
-			}
+	// usable connects and unlocks the order's wallet for the asset, and
+	// records the failure if it cannot be used.
+	usable := func(assetID uint32, name string) bool {
+		wid := db.WalletSID(assetID, name)
+		if _, found := failed[wid]; found {
+			return false
 		}
-		if !baseFailed && !quoteFailed {
-			quoteWallet, err := c.connectedWallet(quote)
-			if err != nil {
-				quoteFailed = true
-				failed[quote] = struct{}{}
-			} else if !quoteWallet.unlocked() {
-				err = unlockWallet(quoteWallet, crypter)
-				if err != nil {
-					quoteFailed = true
-					failed[quote] = struct{}{}
-				}
-			}
+		wallet, err := c.connectedNamedWallet(assetID, name)
+		if err == nil && !wallet.unlocked() {
+			err = unlockWallet(wallet, crypter)
 		}
-		if baseFailed {
-			errs.add("could not complete order %s because the wallet for %s cannot be used", trade.token(), unbip(base))
+		if err != nil {
+			failed[wid] = struct{}{}
+			return false
+		}
+		return true
+	}
+	for _, trade := range trades {
+		base, quote := trade.Base(), trade.Quote()
+		baseName, quoteName := trade.metaData.BaseWallet, trade.metaData.QuoteWallet
+		if !usable(base, baseName) {
+			errs.add("could not complete order %s because the wallet for %s cannot be used", trade.token(), walletLabel(base, baseName))
 			continue
 		}
-		if quoteFailed {
-			errs.add("could not complete order %s because the wallet for %s cannot be used", trade.token(), unbip(quote))
+		if !usable(quote, quoteName) {
+			errs.add("could not complete order %s because the wallet for %s cannot be used", trade.token(), walletLabel(quote, quoteName))
 			continue
 		}
 		ready = append(ready, trade)
@@ -2191,18 +2790,18 @@ func (c *Core) loadDBTrades(dc *dexConnection, crypter encrypt.Crypter, failed m
 // new matches on an order.
 func (c *Core) resumeTrades(dc *dexConnection, trackers []*trackedTrade) error {
 	var tracker *trackedTrade
-	notifyErr := func(subject, s string, a ...interface{}) {
-		detail := fmt.Sprintf(s, a...)
+	notifyErr := func(topic Topic, args ...interface{}) {
 		corder, _ := tracker.coreOrder()
-		c.notify(newOrderNote(subject, detail, db.ErrorLevel, corder))
+		c.notify(newOrderNote(topic, db.ErrorLevel, corder, args...))
 	}
 	for _, tracker = range trackers {
 		// See if the order is 100% filled.
 		trade := tracker.Trade()
 		// Make sure we have the necessary wallets.
-		wallets, err := c.walletSet(dc, tracker.Base(), tracker.Quote(), trade.Sell)
+		wallets, err := c.namedWalletSet(dc, tracker.Base(), tracker.Quote(),
+			tracker.metaData.BaseWallet, tracker.metaData.QuoteWallet, trade.Sell)
 		if err != nil {
-			notifyErr("Wallet missing", "Wallet retrieval error for active order %s: %v", tracker.token(), err)
+			notifyErr(TopicWalletMissing, tracker.token(), err)
 			continue
 		}
 		tracker.wallets = wallets
@@ -2225,19 +2824,19 @@ func (c *Core) resumeTrades(dc *dexConnection, trackers []*trackedTrade) error {
 			if takerNeedsSwap || makerNeedsSwap {
 				if len(counterSwap) == 0 {
 					match.failErr = fmt.Errorf("missing counter-swap, order %s, match %s", tracker.ID(), match.id)
-					notifyErr("Match status error", "Match %s for order %s is in state %s, but has no maker swap coin.", dbMatch.Side, tracker.token(), dbMatch.Status)
+					notifyErr(TopicMatchErrorCoin, dbMatch.Side, tracker.token(), dbMatch.Status)
 					continue
 				}
 				counterContract := metaData.Proof.CounterScript
 				if len(counterContract) == 0 {
 					match.failErr = fmt.Errorf("missing counter-contract, order %s, match %s", tracker.ID(), match.id)
-					notifyErr("Match status error", "Match %s for order %s is in state %s, but has no maker swap contract.", dbMatch.Side, tracker.token(), dbMatch.Status)
+					notifyErr(TopicMatchErrorContract, dbMatch.Side, tracker.token(), dbMatch.Status)
 					continue
 				}
 				auditInfo, err := wallets.toWallet.AuditContract(counterSwap, counterContract)
 				if err != nil {
 					match.failErr = fmt.Errorf("audit error, order %s, match %s: %v", tracker.ID(), match.id, err)
-					notifyErr("Match recovery error", "Error auditing counter-parties swap contract during swap recovery on order %s: %v", tracker.token(), err)
+					notifyErr(TopicMatchRecoveryError, tracker.token(), err)
 					continue
 				}
 				match.counterSwap = auditInfo
@@ -2253,7 +2852,7 @@ func (c *Core) resumeTrades(dc *dexConnection, trackers []*trackedTrade) error {
 				coinIDs = []order.CoinID{tracker.metaData.ChangeCoin}
 			}
 			if len(coinIDs) == 0 {
-				notifyErr("No funding coins", "Order %s has no %s funding coins", tracker.token(), unbip(wallets.fromAsset.ID))
+				notifyErr(TopicNoFundingCoins, tracker.token(), unbip(wallets.fromAsset.ID))
 				continue
 			}
 			byteIDs := make([]dex.Bytes, 0, len(coinIDs))
@@ -2261,12 +2860,12 @@ func (c *Core) resumeTrades(dc *dexConnection, trackers []*trackedTrade) error {
 				byteIDs = append(byteIDs, []byte(cid))
 			}
 			if len(byteIDs) == 0 {
-				notifyErr("Order coin error", "No coins for loaded order %s %s: %v", unbip(wallets.fromAsset.ID), tracker.token(), err)
+				notifyErr(TopicOrderCoinError, unbip(wallets.fromAsset.ID), tracker.token(), err)
 				continue
 			}
 			coins, err := wallets.fromWallet.FundingCoins(byteIDs)
 			if err != nil {
-				notifyErr("Order coin error", "Source coins retrieval error for %s %s: %v", unbip(wallets.fromAsset.ID), tracker.token(), err)
+				notifyErr(TopicOrderCoinFetchError, unbip(wallets.fromAsset.ID), tracker.token(), err)
 				continue
 			}
 			tracker.coins = mapifyCoins(coins)
@@ -2283,16 +2882,17 @@ func (c *Core) resumeTrades(dc *dexConnection, trackers []*trackedTrade) error {
 // account info, but does not authenticate the connection through the 'connect'
 // route.
 func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
-	// Get the host from the DEX URL.
+	// Get the host from the DEX URL. The connection is for the account of the
+	// account host, and is made to the DEX host.
 	host := addrHost(acctInfo.Host)
-	wsAddr := "wss://" + host + "/ws"
+	_, dexHost := splitAccountHost(host)
+	wsAddr := "wss://" + dexHost + "/ws"
 	wsURL, err := url.Parse(wsAddr)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing ws address %s: %v", wsAddr, err)
 	}
 
-	// Create a websocket connection to the server.
-	conn, err := c.wsConstructor(&comms.WsCfg{
+	wsCfg := &comms.WsCfg{
 		URL:      wsURL.String(),
 		PingWait: 60 * time.Second,
 		Cert:     acctInfo.Cert,
@@ -2302,7 +2902,19 @@ func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
 		ConnectEventFunc: func(connected bool) {
 			go c.handleConnectEvent(host, connected)
 		},
-	})
+	}
+	if proxy := c.proxy(dexHost); proxy != nil {
+		wsCfg.Proxy = proxy.Addr
+		wsCfg.TorIsolation = proxy.Isolate
+		if !proxy.Isolate {
+			wsCfg.ProxyUser, wsCfg.ProxyPass = proxy.User, proxy.Pass
+		}
+	} else if isOnion(dexHost) {
+		return nil, fmt.Errorf("a proxy is required to connect to onion service %s", dexHost)
+	}
+
+	// Create a websocket connection to the server.
+	conn, err := c.wsConstructor(wsCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -2324,6 +2936,21 @@ func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
 		connMaster.Disconnect()
 		return nil, fmt.Errorf("Error fetching DEX server config: %v", err)
 	}
+	// Verify the config's signature, and learn of any key rotation.
+	keys, err := verifyConfig(dexCfg, acctInfo.DEXPubKey)
+	if err != nil {
+		connMaster.Disconnect()
+		return nil, fmt.Errorf("Error verifying DEX server config: %w", err)
+	}
+	if keys.rotated {
+		log.Infof("DEX %s has rotated its signing key to %x.", host, dexCfg.DEXPubKey)
+		if err = c.db.UpdateDEXPubKey(host, keys.pubKey); err != nil {
+			log.Errorf("Failed to save the new signing key for DEX %s: %v", host, err)
+		}
+	} else if keys.nextPubKey != nil {
+		log.Infof("DEX %s will rotate its signing key to %x at %v.", host,
+			keys.nextPubKey.SerializeCompressed(), keys.rotation)
+	}
 
 	assets := make(map[uint32]*dex.Asset, len(dexCfg.Assets))
 	for _, asset := range dexCfg.Assets {
@@ -2356,6 +2983,9 @@ func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
 			EpochLen:        mkt.EpochLen,
 			StartEpoch:      mkt.StartEpoch,
 			MarketBuyBuffer: mkt.MarketBuyBuffer,
+			TradeFee:        mkt.TradeFee,
+			MinOrderValue:   mkt.MinOrderValue,
+			OddLotPolicy:    mkt.OddLotPolicy,
 		}
 		marketMap[mkt.Name] = market
 		epochMap[mkt.Name] = 0
@@ -2375,11 +3005,14 @@ func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
 		epoch:      epochMap,
 		connected:  true,
 	}
+	dc.acct.setDEXKey(keys.pubKey, keys.nextPubKey, keys.rotation)
 
 	dc.refreshMarkets()
 	c.wg.Add(1)
 	go c.listen(dc)
-	log.Infof("Connected to DEX server at %s and listening for messages.", host)
+	log.Infof("Connected to DEX server at %s (API version %d) and listening for messages.",
+		host, dc.apiVersion())
+	c.notifyUpgrade(dc)
 
 	return dc, nil
 }
@@ -2394,10 +3027,24 @@ func (c *Core) handleReconnect(host string) {
 		log.Errorf("unable to find previous connection to DEX at %s", host)
 		return
 	}
-	if err := c.authDEX(dc); err != nil {
+	extras, err := c.authenticate(dc)
+	if err != nil {
 		log.Errorf("unable to authorize DEX at %s: %v", host, err)
 		return
 	}
+	c.resync(dc, extras)
+
+	c.connMtx.Lock()
+	disconnected := dc.disconnected
+	dc.disconnected = time.Time{}
+	c.connMtx.Unlock()
+	if !disconnected.IsZero() {
+		c.cancelStaleOrders(dc, time.Since(disconnected))
+	}
+	// The lot sizes may have changed while the connection was down.
+	if err = c.refreshConfig(dc); err != nil {
+		log.Errorf("error refreshing the config of %s after reconnecting: %v", host, err)
+	}
 }
 
 // handleConnectEvent is called when a WsConn indicates that a connection was
@@ -2408,16 +3055,16 @@ func (c *Core) handleConnectEvent(host string, connected bool) {
 	c.connMtx.Lock()
 	if dc, found := c.conns[host]; found {
 		dc.connected = connected
+		if !connected && dc.disconnected.IsZero() {
+			dc.disconnected = time.Now()
+		}
 	}
 	c.connMtx.Unlock()
-	statusStr := "connected"
-	lvl := db.Success
+	topic, lvl := TopicDEXConnected, db.Success
 	if !connected {
-		statusStr = "disconnected"
-		lvl = db.WarningLevel
+		topic, lvl = TopicDEXDisconnected, db.WarningLevel
 	}
-	details := fmt.Sprintf("DEX at %s has %s", host, statusStr)
-	c.notify(newConnEventNote(fmt.Sprintf("DEX %s", statusStr), host, connected, details, lvl))
+	c.notify(newConnEventNote(topic, host, connected, lvl, host))
 	c.refreshUser()
 }
 
@@ -2496,22 +3143,101 @@ func handleRevokeMatchMsg(c *Core, dc *dexConnection, msg *msgjson.Message) erro
 
 	// Notify the user of the failed match.
 	corder, _ := tracker.coreOrder() // no cancel order
-	tracker.notify(newOrderNote("Match revoked", fmt.Sprintf("Match %s has been revoked",
-		tracker.token()), db.WarningLevel, corder))
+	tracker.notify(newOrderNote(TopicMatchRevoked, db.WarningLevel, corder, tracker.token()))
 
 	// Also set the order as revoked.
+	c.revokeOrder(dc, tracker, corder, errs)
+
+	log.Warnf("Match %v revoked in status %v for order %v", matchID, revokedMatch.Match.Status, oid)
+
+	// Respond to DEX.
+	err = dc.ack(msg.ID, matchID, &revocation)
+	if err != nil {
+		errs.add("Audit error: %v", err)
+	}
+
+	return errs.ifany()
+}
+
+// handleRevokeOrderMsg is called when a revoke_order notification is received,
+// such as when a market is delisted.
+func handleRevokeOrderMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error {
+	var revocation msgjson.RevokeOrder
+	err := msg.Unmarshal(&revocation)
+	if err != nil {
+		return fmt.Errorf("revoke order unmarshal error: %v", err)
+	}
+	if len(revocation.OrderID) != order.OrderIDSize {
+		return fmt.Errorf("invalid order ID %x", revocation.OrderID)
+	}
+
+	var oid order.OrderID
+	copy(oid[:], revocation.OrderID)
+
+	tracker, _, _ := dc.findOrder(oid)
+	if tracker == nil {
+		return fmt.Errorf("no order found with id %s", oid.String())
+	}
+
+	errs := newErrorSet("handleRevokeOrderMsg (order %v): ", oid)
+	corder, _ := tracker.coreOrder()
+	c.revokeOrder(dc, tracker, corder, errs)
+	log.Warnf("Order %v revoked", oid)
+
+	return errs.ifany()
+}
+
+// handlePenaltyMsg is called when a penalty notification is received from the
+// DEX, informing the user that their account was closed for a rule violation,
+// or reopened. The user is notified prominently, since a closed account cannot
+// place orders.
+func handlePenaltyMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error {
+	penalty := new(msgjson.Penalty)
+	err := msg.Unmarshal(penalty)
+	if err != nil {
+		return fmt.Errorf("penalty unmarshal error: %v", err)
+	}
+	if err = dc.acct.checkSig(penalty.Serialize(), penalty.Sig); err != nil {
+		return fmt.Errorf("penalty signature error: %v", err)
+	}
+	if acctID := dc.acct.ID(); !bytes.Equal(penalty.AccountID, acctID[:]) {
+		return fmt.Errorf("penalty for account %x received for account %v", penalty.AccountID, dc.acct.ID())
+	}
+
+	p := &Penalty{
+		Host:    dc.acct.host,
+		Rule:    account.Rule(penalty.Rule).String(),
+		Banned:  penalty.Banned,
+		Time:    penalty.Time,
+		Details: penalty.Details,
+		Appeal:  penalty.Appeal,
+	}
+	for _, mid := range penalty.MatchIDs {
+		p.MatchIDs = append(p.MatchIDs, mid.String())
+	}
+	if !p.Banned {
+		log.Infof("Account at %s reopened: %s", dc.acct.host, p.Details)
+		c.notify(newPenaltyNote(TopicAccountReopened, db.Success, p, dc.acct.host, p.Details))
+		return nil
+	}
+	log.Warnf("Account at %s closed for %s: %s", dc.acct.host, p.Rule, p.Details)
+	c.notify(newPenaltyNote(TopicAccountClosed, db.ErrorLevel, p, dc.acct.host, p.Rule, p.Details, p.Appeal))
+	return nil
+}
+
+// revokeOrder sets the order as revoked, notifies the user, and returns the
+// order's funding coins to the wallet.
+func (c *Core) revokeOrder(dc *dexConnection, tracker *trackedTrade, corder *Order, errs *errorSet) {
 	metaOrder := tracker.metaOrder()
 	metaOrder.MetaData.Status = order.OrderStatusRevoked
-	err = tracker.db.UpdateOrder(metaOrder)
+	err := tracker.db.UpdateOrder(metaOrder)
 	if err != nil {
 		errs.add("unable to update order: %v", err)
 	}
 
 	// Notify the user of the revoked order.
-	details := fmt.Sprintf("%s order on %s-%s at %s has been revoked (%s)",
-		strings.Title(sellString(tracker.Trade().Sell)), unbip(tracker.Base()),
-		unbip(tracker.Quote()), dc.acct.host, tracker.token())
-	tracker.notify(newOrderNote("Order revoked", details, db.WarningLevel, corder))
+	tracker.notify(newOrderNote(TopicOrderRevoked, db.WarningLevel, corder, side{tracker.Trade().Sell, true},
+		unbip(tracker.Base()), unbip(tracker.Quote()), dc.acct.host, tracker.token()))
 
 	// Send out a data notification with the revoke information.
 	cancelOrder := &Order{
@@ -2522,7 +3248,7 @@ func handleRevokeMatchMsg(c *Core, dc *dexConnection, msg *msgjson.Message) erro
 		Epoch:    corder.Epoch,
 		TargetID: corder.ID, // the important part for the frontend
 	}
-	tracker.notify(newOrderNote("revoke", "", db.Data, cancelOrder))
+	tracker.notify(newOrderNote(TopicOrderRevokedData, db.Data, cancelOrder))
 
 	// Unlock funding coins if they are not already unlocked. Do this one at a
 	// time if wallet fails if just one cannot be unlocked.
@@ -2538,20 +3264,13 @@ func handleRevokeMatchMsg(c *Core, dc *dexConnection, msg *msgjson.Message) erro
 			log.Warnf("unable to unlock change coin %v: %v", tracker.change, err)
 		}
 	}
+	tracker.matchMtx.Lock()
+	tracker.coinsReturned = true
+	tracker.matchMtx.Unlock()
 
 	// Update market orders, and the balance to account for unlocked coins.
 	dc.refreshMarkets()
 	c.updateAssetBalance(tracker.wallets.fromAsset.ID)
-
-	log.Warnf("Match %v revoked in status %v for order %v", matchID, revokedMatch.Match.Status, oid)
-
-	// Respond to DEX.
-	err = dc.ack(msg.ID, matchID, &revocation)
-	if err != nil {
-		errs.add("Audit error: %v", err)
-	}
-
-	return errs.ifany()
 }
 
 // handleTradeSuspensionMsg is called when a trade suspension notification is received.
@@ -2642,12 +3361,18 @@ var reqHandlers = map[string]routeHandler{
 }
 
 var noteHandlers = map[string]routeHandler{
-	msgjson.MatchProofRoute:      handleMatchProofMsg,
-	msgjson.BookOrderRoute:       handleBookOrderMsg,
-	msgjson.EpochOrderRoute:      handleEpochOrderMsg,
-	msgjson.UnbookOrderRoute:     handleUnbookOrderMsg,
-	msgjson.UpdateRemainingRoute: handleUpdateRemainingMsg,
-	msgjson.SuspensionRoute:      handleTradeSuspensionMsg,
+	msgjson.MatchProofRoute:         handleMatchProofMsg,
+	msgjson.BookOrderRoute:          handleBookOrderMsg,
+	msgjson.EpochOrderRoute:         handleEpochOrderMsg,
+	msgjson.UnbookOrderRoute:        handleUnbookOrderMsg,
+	msgjson.UpdateRemainingRoute:    handleUpdateRemainingMsg,
+	msgjson.SuspensionRoute:         handleTradeSuspensionMsg,
+	msgjson.ResumptionRoute:         handleTradeResumptionMsg,
+	msgjson.RevokeOrderRoute:        handleRevokeOrderMsg,
+	msgjson.SpotsRoute:              handleSpotsMsg,
+	msgjson.ReceiptRoute:            handleReceiptMsg,
+	msgjson.SettlementProgressRoute: handleSettlementProgressMsg,
+	msgjson.PenaltyRoute:            handlePenaltyMsg,
 }
 
 // listen monitors the DEX websocket connection for server requests and
@@ -2744,14 +3469,11 @@ func handlePreimageRequest(c *Core, dc *dexConnection, msg *msgjson.Message) err
 		return fmt.Errorf("preimage send error: %v", err)
 	}
 	corder, cancelOrder := tracker.coreOrder()
-	var details string
+	topic := TopicPreimageSent
 	if isCancel {
-		corder = cancelOrder
-		details = fmt.Sprintf("match cycle has begun for cancellation order for trade %s", tracker.token())
-	} else {
-		details = fmt.Sprintf("match cycle has begun for order %s", tracker.token())
+		corder, topic = cancelOrder, TopicCancelPreimageSent
 	}
-	c.notify(newOrderNote("Preimage sent", details, db.Poke, corder))
+	c.notify(newOrderNote(topic, db.Poke, corder, tracker.token()))
 	return nil
 }
 
@@ -2834,6 +3556,75 @@ func handleRedemptionRoute(c *Core, dc *dexConnection, msg *msgjson.Message) err
 	return err
 }
 
+// handleReceiptMsg is called when a settlement receipt notification is
+// received for a completed match. The user may be either party, so the order
+// is located by either the maker's or the taker's order ID.
+func handleReceiptMsg(_ *Core, dc *dexConnection, msg *msgjson.Message) error {
+	receipt := new(msgjson.SettlementReceipt)
+	err := msg.Unmarshal(receipt)
+	if err != nil {
+		return fmt.Errorf("receipt note unmarshal error: %v", err)
+	}
+	var tracker *trackedTrade
+	for _, oidB := range [][]byte{receipt.MakerOrderID, receipt.TakerOrderID} {
+		var oid order.OrderID
+		copy(oid[:], oidB)
+		if tracker, _, _ = dc.findOrder(oid); tracker != nil {
+			break
+		}
+	}
+	if tracker == nil {
+		return fmt.Errorf("receipt received for unknown match %s", receipt.MatchID)
+	}
+	return tracker.processReceipt(receipt)
+}
+
+// progressTopics are the notification topics of the settlement milestones.
+var progressTopics = map[string]Topic{
+	msgjson.MakerSwapConfirmed: TopicMakerSwapConfirmed,
+	msgjson.TakerSwapConfirmed: TopicTakerSwapConfirmed,
+	msgjson.MakerRedeemed:      TopicMakerRedeemed,
+	msgjson.TakerRedeemed:      TopicTakerRedeemed,
+}
+
+// handleSettlementProgressMsg is called when a settlement progress
+// notification is received for a match that reached a settlement milestone.
+// As with receipts, the order is located by either party's order ID.
+func handleSettlementProgressMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error {
+	progress := new(msgjson.SettlementProgress)
+	err := msg.Unmarshal(progress)
+	if err != nil {
+		return fmt.Errorf("settlement progress note unmarshal error: %v", err)
+	}
+	topic, found := progressTopics[progress.Milestone]
+	if !found {
+		return fmt.Errorf("unknown settlement milestone %q", progress.Milestone)
+	}
+	var mid order.MatchID
+	copy(mid[:], progress.MatchID)
+	var tracker *trackedTrade
+	for _, oidB := range [][]byte{progress.MakerOrderID, progress.TakerOrderID} {
+		var oid order.OrderID
+		copy(oid[:], oidB)
+		if tracker, _, _ = dc.findOrder(oid); tracker != nil {
+			break
+		}
+	}
+	if tracker == nil {
+		return fmt.Errorf("settlement progress received for unknown match %s", mid)
+	}
+	tracker.matchMtx.RLock()
+	_, found = tracker.matches[mid]
+	tracker.matchMtx.RUnlock()
+	if !found {
+		return fmt.Errorf("settlement progress received for unknown match %s of order %s", mid, tracker.ID())
+	}
+	corder, _ := tracker.coreOrder()
+	c.notify(newOrderNote(topic, db.Poke, corder, unbip(progress.AssetID),
+		coinIDString(progress.AssetID, progress.CoinID), mid, tracker.token()))
+	return nil
+}
+
 // removeWaiter removes a blockWaiter from the map.
 func (c *Core) removeWaiter(id uint64) {
 	c.waiterMtx.Lock()
@@ -2949,6 +3740,28 @@ func sendRequest(conn comms.WsConn, route string, request, response interface{})
 	return extractError(errChan, requestTimeout, route)
 }
 
+// solvePoW solves the proof of work for the serialized request data if err is
+// the server's PoWRequiredError, so that the request can be resent with the
+// nonce. The bool is false if err is not a PoWRequiredError.
+func (c *Core) solvePoW(host string, err error, data []byte) (uint64, bool, error) {
+	var msgErr *msgjson.Error
+	if !errors.As(err, &msgErr) {
+		return 0, false, nil
+	}
+	difficulty, required := msgjson.PoWRequired(msgErr)
+	if !required {
+		return 0, false, nil
+	}
+	log.Infof("%s requires a proof of work of %d bits. Solving...", host, difficulty)
+	start := time.Now()
+	nonce, err := msgjson.SolvePoW(c.ctx, data, difficulty)
+	if err != nil {
+		return 0, true, fmt.Errorf("error solving %s proof of work: %w", host, err)
+	}
+	log.Debugf("Solved %d-bit proof of work for %s in %v", difficulty, host, time.Since(start))
+	return nonce, true, nil
+}
+
 // extractError extracts the error from the channel with a timeout.
 func extractError(errChan <-chan error, delay time.Duration, route string) error {
 	select {
@@ -3030,10 +3843,11 @@ func messageOrder(ord order.Order, coins []*msgjson.Coin) (string, msgjson.Stamp
 			tifFlag = msgjson.ImmediateOrderNum
 		}
 		return msgjson.LimitRoute, &msgjson.LimitOrder{
-			Prefix: *messagePrefix(prefix),
-			Trade:  *messageTrade(trade, coins),
-			Rate:   o.Rate,
-			TiF:    tifFlag,
+			Prefix:  *messagePrefix(prefix),
+			Trade:   *messageTrade(trade, coins),
+			Rate:    o.Rate,
+			TiF:     tifFlag,
+			Visible: o.Visible,
 		}
 	case *order.MarketOrder:
 		return msgjson.MarketRoute, &msgjson.MarketOrder{
@@ -3050,6 +3864,37 @@ func messageOrder(ord order.Order, coins []*msgjson.Coin) (string, msgjson.Stamp
 	}
 }
 
+// orderRejectError adds an actionable explanation to an order rejected by the
+// server for a reason the user can address. Other errors are returned
+// unchanged. The server's error can be retrieved with errors.As.
+func orderRejectError(err error) error {
+	var msgErr *msgjson.Error
+	if !errors.As(err, &msgErr) {
+		return err
+	}
+	var reason string
+	switch msgErr.Code {
+	case msgjson.InsufficientFundsError:
+		reason = "the funding coins do not cover the order quantity and fees"
+	case msgjson.FundingError:
+		reason = "the funding coins were not accepted. they may be spent, unconfirmed, or funding another order"
+	case msgjson.LotSizeError:
+		reason = "the order quantity must be a multiple of the market's lot size, " +
+			"and a market buy must be larger than the market buy buffer"
+	case msgjson.RateStepError:
+		reason = "the rate must be a multiple of the market's rate step"
+	case msgjson.MarketSuspendedError:
+		reason = "the market is suspended. try again when trading resumes"
+	case msgjson.MarketNotRunningError:
+		reason = "the market is not accepting orders yet"
+	case msgjson.AccountSuspendedError:
+		reason = "the account is suspended and cannot place new orders"
+	default:
+		return err
+	}
+	return fmt.Errorf("order rejected: %s: %w", reason, err)
+}
+
 // validateOrderResponse validates the response against the order and the order
 // message.
 func validateOrderResponse(dc *dexConnection, result *msgjson.OrderResult, ord order.Order, msgOrder msgjson.Stampable) error {
@@ -5,17 +5,26 @@ package core
 import (
 	"bytes"
 	"context"
+	"crypto/elliptic"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/asset/btc"
 	"decred.org/dcrdex/client/comms"
 	"decred.org/dcrdex/client/db"
 	dbtest "decred.org/dcrdex/client/db/test"
@@ -30,6 +39,9 @@ import (
 	ordertest "decred.org/dcrdex/dex/order/test"
 	"decred.org/dcrdex/dex/wait"
 	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
+	"github.com/decred/dcrd/certgen"
 	"github.com/decred/dcrd/crypto/blake256"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 	"github.com/decred/slog"
@@ -233,6 +245,8 @@ func (conn *TWebsocket) Connect(context.Context) (*sync.WaitGroup, error) {
 
 type TDB struct {
 	updateWalletErr        error
+	dexKeyUpdate           *secp256k1.PublicKey
+	certUpdate             []byte
 	acct                   *db.AccountInfo
 	acctErr                error
 	getErr                 error
@@ -244,7 +258,27 @@ type TDB struct {
 	matchesForOID          []*db.MetaMatch
 	matchesForOIDErr       error
 	activeMatchesForDEX    []*db.MetaMatch
+	activeMatches          []*db.MetaMatch
+	dbOrder                *db.MetaOrder
+	dbOrderErr             error
+	updatedMatch           *db.MetaMatch
 	activeMatchesForDEXErr error
+	accountOrders          []*db.MetaOrder
+	wallets                []*db.Wallet
+	initialized            bool
+	createdAccts           []*db.AccountInfo
+	acctProofs             []*db.AccountProof
+	updatedOrders          []*db.MetaOrder
+	updatedWallets         []*db.Wallet
+	vals                   map[string][]byte
+	scheds                 map[string]*db.ScheduledOrder
+	alerts                 map[string]*db.Alert
+	candles                map[string]map[uint64]*db.Candle
+	noteMtx                sync.Mutex
+	savedNotes             []*db.Notification
+	orderNotes             map[order.OrderID]string
+	matchNotes             map[order.MatchID]string
+	notesErr               error
 }
 
 func (tdb *TDB) Run(context.Context) {}
@@ -262,10 +296,12 @@ func (tdb *TDB) Account(url string) (*db.AccountInfo, error) {
 }
 
 func (tdb *TDB) CreateAccount(ai *db.AccountInfo) error {
+	tdb.createdAccts = append(tdb.createdAccts, ai)
 	return nil
 }
 
 func (tdb *TDB) UpdateOrder(m *db.MetaOrder) error {
+	tdb.updatedOrders = append(tdb.updatedOrders, m)
 	return tdb.updateOrderErr
 }
 
@@ -278,11 +314,11 @@ func (tdb *TDB) ActiveOrders() ([]*db.MetaOrder, error) {
 }
 
 func (tdb *TDB) AccountOrders(dex string, n int, since uint64) ([]*db.MetaOrder, error) {
-	return nil, nil
+	return tdb.accountOrders, nil
 }
 
 func (tdb *TDB) Order(order.OrderID) (*db.MetaOrder, error) {
-	return nil, nil
+	return tdb.dbOrder, tdb.dbOrderErr
 }
 
 func (tdb *TDB) MarketOrders(dex string, base, quote uint32, n int, since uint64) ([]*db.MetaOrder, error) {
@@ -297,12 +333,29 @@ func (tdb *TDB) UpdateOrderStatus(oid order.OrderID, status order.OrderStatus) e
 	return nil
 }
 
+func (tdb *TDB) SetOrderNotes(oid order.OrderID, notes string, tags []string) error {
+	if tdb.orderNotes == nil {
+		tdb.orderNotes = make(map[order.OrderID]string)
+	}
+	tdb.orderNotes[oid] = notes
+	return tdb.notesErr
+}
+
+func (tdb *TDB) SetMatchNotes(oid order.OrderID, mid order.MatchID, notes string, tags []string) error {
+	if tdb.matchNotes == nil {
+		tdb.matchNotes = make(map[order.MatchID]string)
+	}
+	tdb.matchNotes[mid] = notes
+	return tdb.notesErr
+}
+
 func (tdb *TDB) UpdateMatch(m *db.MetaMatch) error {
+	tdb.updatedMatch = m
 	return nil
 }
 
 func (tdb *TDB) ActiveMatches() ([]*db.MetaMatch, error) {
-	return nil, nil
+	return tdb.activeMatches, nil
 }
 
 func (tdb *TDB) MatchesForOrder(oid order.OrderID) ([]*db.MetaMatch, error) {
@@ -314,6 +367,7 @@ func (tdb *TDB) ActiveDEXMatches(dex string) ([]*db.MetaMatch, error) {
 }
 
 func (tdb *TDB) UpdateWallet(wallet *db.Wallet) error {
+	tdb.updatedWallets = append(tdb.updatedWallets, wallet)
 	return tdb.updateWalletErr
 }
 
@@ -322,28 +376,59 @@ func (tdb *TDB) UpdateBalance(wid []byte, balance *db.Balance) error {
 }
 
 func (tdb *TDB) Wallets() ([]*db.Wallet, error) {
-	return nil, nil
+	return tdb.wallets, nil
 }
 
 func (tdb *TDB) AccountPaid(proof *db.AccountProof) error {
+	tdb.acctProofs = append(tdb.acctProofs, proof)
+	return nil
+}
+
+func (tdb *TDB) UpdateDEXPubKey(host string, pubKey *secp256k1.PublicKey) error {
+	tdb.dexKeyUpdate = pubKey
+	return nil
+}
+
+func (tdb *TDB) UpdateCert(host string, cert []byte) error {
+	tdb.certUpdate = cert
+	return nil
+}
+
+func (tdb *TDB) SaveNotification(note *db.Notification) error {
+	tdb.noteMtx.Lock()
+	tdb.savedNotes = append(tdb.savedNotes, note)
+	tdb.noteMtx.Unlock()
 	return nil
 }
 
-func (tdb *TDB) SaveNotification(*db.Notification) error        { return nil }
 func (tdb *TDB) NotificationsN(int) ([]*db.Notification, error) { return nil, nil }
 
 func (tdb *TDB) Store(k string, b []byte) error {
-	return tdb.storeErr
+	if tdb.storeErr != nil {
+		return tdb.storeErr
+	}
+	if tdb.vals == nil {
+		tdb.vals = make(map[string][]byte)
+	}
+	tdb.vals[k] = b
+	return nil
 }
 
 func (tdb *TDB) ValueExists(k string) (bool, error) {
-	return false, nil
+	if k == keyParamsKey {
+		return tdb.initialized, nil
+	}
+	_, found := tdb.vals[k]
+	return found, nil
 }
 
 func (tdb *TDB) Get(k string) ([]byte, error) {
 	if k == keyParamsKey {
 		return nil, tdb.encKeyErr
 	}
+	if v, found := tdb.vals[k]; found {
+		return v, tdb.getErr
+	}
 	return nil, tdb.getErr
 }
 
@@ -353,6 +438,85 @@ func (tdb *TDB) Backup() error {
 
 func (tdb *TDB) AckNotification(id []byte) error { return nil }
 
+func (tdb *TDB) SaveScheduledOrder(sched *db.ScheduledOrder) error {
+	if tdb.scheds == nil {
+		tdb.scheds = make(map[string]*db.ScheduledOrder)
+	}
+	tdb.scheds[sched.ID().String()] = sched
+	return nil
+}
+
+func (tdb *TDB) ScheduledOrders() ([]*db.ScheduledOrder, error) {
+	scheds := make([]*db.ScheduledOrder, 0, len(tdb.scheds))
+	for _, sched := range tdb.scheds {
+		scheds = append(scheds, sched)
+	}
+	return scheds, nil
+}
+
+func (tdb *TDB) DeleteScheduledOrder(id []byte) error {
+	k := hex.EncodeToString(id)
+	if _, found := tdb.scheds[k]; !found {
+		return fmt.Errorf("scheduled order %s not found", k)
+	}
+	delete(tdb.scheds, k)
+	return nil
+}
+
+func (tdb *TDB) SaveAlert(alert *db.Alert) error {
+	if tdb.alerts == nil {
+		tdb.alerts = make(map[string]*db.Alert)
+	}
+	tdb.alerts[alert.ID().String()] = alert
+	return nil
+}
+
+func (tdb *TDB) Alerts() ([]*db.Alert, error) {
+	alerts := make([]*db.Alert, 0, len(tdb.alerts))
+	for _, alert := range tdb.alerts {
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+func (tdb *TDB) DeleteAlert(id []byte) error {
+	k := hex.EncodeToString(id)
+	if _, found := tdb.alerts[k]; !found {
+		return fmt.Errorf("alert %s not found", k)
+	}
+	delete(tdb.alerts, k)
+	return nil
+}
+
+func (tdb *TDB) StoreCandles(host string, base, quote uint32, binSize uint64, candles []*db.Candle) error {
+	if tdb.candles == nil {
+		tdb.candles = make(map[string]map[uint64]*db.Candle)
+	}
+	k := string(db.CandleSeries(host, base, quote, binSize))
+	series := tdb.candles[k]
+	if series == nil {
+		series = make(map[uint64]*db.Candle)
+		tdb.candles[k] = series
+	}
+	for _, candle := range candles {
+		series[candle.StartStamp] = candle
+	}
+	return nil
+}
+
+func (tdb *TDB) Candles(host string, base, quote uint32, binSize, start, end uint64) ([]*db.Candle, error) {
+	candles := make([]*db.Candle, 0)
+	for stamp, candle := range tdb.candles[string(db.CandleSeries(host, base, quote, binSize))] {
+		if stamp >= start && stamp <= end {
+			candles = append(candles, candle)
+		}
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].StartStamp < candles[j].StartStamp
+	})
+	return candles, nil
+}
+
 type tCoin struct {
 	id, script []byte
 	confs      uint32
@@ -432,6 +596,7 @@ type TXCWallet struct {
 	auditInfo      asset.AuditInfo
 	auditErr       error
 	refundCoin     dex.Bytes
+	notExpired     bool
 	refundErr      error
 	redeemCoins    []dex.Bytes
 	badSecret      bool
@@ -443,6 +608,31 @@ type TXCWallet struct {
 	fundingCoins   asset.Coins
 	fundingCoinErr error
 	lockErr        error
+	confs          uint32
+	confsErr       error
+	bumpCoin       asset.Coin
+	bumpRate       uint64
+	bumpErr        error
+	bumps          int
+	withdrawal     *asset.Withdrawal
+	withdrawEst    *asset.WithdrawalEstimate
+	withdrawCoins  []asset.Coin
+	withdrawErr    error
+	coinVals       []uint64
+	consolidations int
+	consolidateErr error
+	splitVal       uint64
+	splitN         int
+	splitErr       error
+	returnedCoins  asset.Coins
+	fundOpts       *asset.FundingOptions
+	walletCoins    []*asset.WalletCoin
+	listCoinsErr   error
+	addr           string
+	deposits       []*asset.Deposit
+	depositsErr    error
+	syncStatus     *asset.SyncStatus
+	syncErr        error
 }
 
 func newTWallet(assetID uint32) (*xcWallet, *TXCWallet) {
@@ -481,12 +671,27 @@ func (w *TXCWallet) FeeRate() (uint64, error) {
 	return 24, nil
 }
 
+func (w *TXCWallet) RedeemFee() (feeRate, fee uint64) {
+	return 20, 20 * 300
+}
+
 func (w *TXCWallet) FundOrder(v uint64, _ *dex.Asset) (asset.Coins, error) {
 	w.fundedVal = v
 	return w.fundCoins, w.fundErr
 }
 
+func (w *TXCWallet) FundOrderWith(v uint64, _ *dex.Asset, opts *asset.FundingOptions) (asset.Coins, error) {
+	w.fundedVal = v
+	w.fundOpts = opts
+	return w.fundCoins, w.fundErr
+}
+
+func (w *TXCWallet) ListCoins() ([]*asset.WalletCoin, error) {
+	return w.walletCoins, w.listCoinsErr
+}
+
 func (w *TXCWallet) ReturnCoins(coins asset.Coins) error {
+	w.returnedCoins = append(w.returnedCoins, coins...)
 	coinInSlice := func(coin asset.Coin) bool {
 		for _, c := range coins {
 			if bytes.Equal(c.ID(), coin.ID()) {
@@ -526,7 +731,7 @@ func (w *TXCWallet) AuditContract(coinID, contract dex.Bytes) (asset.AuditInfo,
 }
 
 func (w *TXCWallet) LocktimeExpired(contract dex.Bytes) (bool, error) {
-	return true, nil
+	return !w.notExpired, nil
 }
 
 func (w *TXCWallet) FindRedemption(ctx context.Context, coinID dex.Bytes) (dex.Bytes, error) {
@@ -538,7 +743,7 @@ func (w *TXCWallet) Refund(dex.Bytes, dex.Bytes) (dex.Bytes, error) {
 }
 
 func (w *TXCWallet) Address() (string, error) {
-	return "", w.addrErr
+	return w.addr, w.addrErr
 }
 
 func (w *TXCWallet) Unlock(pw string, dur time.Duration) error {
@@ -556,7 +761,12 @@ func (w *TXCWallet) Send(address string, fee uint64, _ *dex.Asset) (asset.Coin,
 }
 
 func (w *TXCWallet) Confirmations(id dex.Bytes) (uint32, error) {
-	return 0, nil
+	return w.confs, w.confsErr
+}
+
+func (w *TXCWallet) BumpRedemptionFee(coinID dex.Bytes) (asset.Coin, uint64, error) {
+	w.bumps++
+	return w.bumpCoin, w.bumpRate, w.bumpErr
 }
 
 func (w *TXCWallet) ConfirmTime(id dex.Bytes, nConfs uint32) (time.Time, error) {
@@ -571,6 +781,44 @@ func (w *TXCWallet) Withdraw(address string, value uint64) (asset.Coin, error) {
 	return w.payFeeCoin, w.payFeeErr
 }
 
+func (w *TXCWallet) EstimateWithdrawal(wd *asset.Withdrawal) (*asset.WithdrawalEstimate, error) {
+	w.withdrawal = wd
+	return w.withdrawEst, w.withdrawErr
+}
+
+func (w *TXCWallet) SendWithdrawal(wd *asset.Withdrawal) ([]asset.Coin, error) {
+	w.withdrawal = wd
+	return w.withdrawCoins, w.withdrawErr
+}
+
+func (w *TXCWallet) CoinValues() ([]uint64, error) {
+	return w.coinVals, nil
+}
+
+func (w *TXCWallet) SyncStatus() (*asset.SyncStatus, error) {
+	return w.syncStatus, w.syncErr
+}
+
+func (w *TXCWallet) AddressDeposits(addrs []string) ([]*asset.Deposit, error) {
+	return w.deposits, w.depositsErr
+}
+
+func (w *TXCWallet) Consolidate(threshold uint64, maxInputs int, feeRate uint64) (asset.Coin, error) {
+	if w.consolidateErr != nil {
+		return nil, w.consolidateErr
+	}
+	w.consolidations++
+	return &tCoin{id: []byte{0x0e}}, nil
+}
+
+func (w *TXCWallet) Split(value uint64, n int, feeRate uint64) ([]asset.Coin, error) {
+	if w.splitErr != nil {
+		return nil, w.splitErr
+	}
+	w.splitVal, w.splitN = value, n
+	return make([]asset.Coin, n), nil
+}
+
 func (w *TXCWallet) ValidateSecret(secret, secretHash []byte) bool {
 	return !w.badSecret
 }
@@ -641,8 +889,14 @@ func newTestRig() *testRig {
 			},
 			lockTimeTaker: dex.LockTimeTaker(dex.Testnet),
 			lockTimeMaker: dex.LockTimeMaker(dex.Testnet),
-			wallets:       make(map[uint32]*xcWallet),
+			wallets:       make(map[string]*xcWallet),
 			blockWaiters:  make(map[uint64]*blockWaiter),
+			schedFeeds:    make(map[string]*BookFeed),
+			alertFeeds:    make(map[string]*BookFeed),
+			alertFills:    make(map[string]uint64),
+			alertMatches:  make(map[string]map[string]bool),
+			prompts:       make(map[string]*pendingPrompt),
+			depositAddrs:  make(map[uint32][]*DepositAddress),
 			wsConstructor: func(*comms.WsCfg) (comms.WsConn, error) {
 				return conn, nil
 			},
@@ -659,6 +913,8 @@ func newTestRig() *testRig {
 }
 
 func (rig *testRig) queueConfig() {
+	rig.dc.cfg.DEXPubKey = tDexKey.SerializeCompressed()
+	sign(tDexPriv, rig.dc.cfg)
 	rig.ws.queueResponse(msgjson.ConfigRoute, func(msg *msgjson.Message, f msgFunc) error {
 		resp, _ := msgjson.NewResponse(msg.ID, rig.dc.cfg, nil)
 		f(resp)
@@ -1007,9 +1263,9 @@ func TestCreateWallet(t *testing.T) {
 
 	// Try to add an existing wallet.
 	wallet, tWallet := newTWallet(tILT.ID)
-	tCore.wallets[tILT.ID] = wallet
+	tCore.wallets[wallet.id()] = wallet
 	ensureErr("existing wallet")
-	delete(tCore.wallets, tILT.ID)
+	delete(tCore.wallets, db.WalletSID(tILT.ID, ""))
 
 	// Failure to retrieve encryption key params.
 	rig.db.encKeyErr = tErr
@@ -1059,7 +1315,7 @@ func TestCreateWallet(t *testing.T) {
 	rig.db.updateWalletErr = nil
 
 	// Success
-	delete(tCore.wallets, tILT.ID)
+	delete(tCore.wallets, db.WalletSID(tILT.ID, ""))
 	err := tCore.CreateWallet(tPW, []byte(wPW), form)
 	if err != nil {
 		t.Fatalf("error when should be no error: %v", err)
@@ -1097,6 +1353,78 @@ func TestGetFee(t *testing.T) {
 	}
 }
 
+func TestGetPolicy(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	policy := &msgjson.Policy{
+		DEXPubKey: rig.acct.dexPubKey.Serialize(),
+		Stamp:     encode.UnixMilliU(time.Now()),
+		Fees:      "1 DCR registration",
+		Contact:   "ops@dex.example",
+	}
+	sign(tDexPriv, policy)
+
+	queuePolicy := func(policy *msgjson.Policy) {
+		rig.ws.queueResponse(msgjson.PolicyRoute, func(msg *msgjson.Message, f msgFunc) error {
+			resp, _ := msgjson.NewResponse(msg.ID, policy, nil)
+			f(resp)
+			return nil
+		})
+	}
+
+	// Success with the registered DEX's connection.
+	queuePolicy(policy)
+	p, err := tCore.GetPolicy(tDexHost, "")
+	if err != nil {
+		t.Fatalf("GetPolicy error: %v", err)
+	}
+	if p.Fees != policy.Fees || p.Contact != policy.Contact {
+		t.Fatalf("wrong policy returned")
+	}
+
+	// Bad signature.
+	badSig := *policy
+	badSig.Terms = "changed"
+	queuePolicy(&badSig)
+	_, err = tCore.GetPolicy(tDexHost, "")
+	if !errorHasCode(err, signatureErr) {
+		t.Fatalf("wrong bad signature error: %v", err)
+	}
+
+	// Valid signature by a key other than the account's DEX key.
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	otherPolicy := *policy
+	otherPolicy.DEXPubKey = otherKey.PubKey().SerializeCompressed()
+	sign(otherKey, &otherPolicy)
+	queuePolicy(&otherPolicy)
+	_, err = tCore.GetPolicy(tDexHost, "")
+	if !errorHasCode(err, signatureErr) {
+		t.Fatalf("wrong key mismatch error: %v", err)
+	}
+
+	// Lose the dexConnection. The other key is fine for an unregistered DEX.
+	tCore.connMtx.Lock()
+	delete(tCore.conns, tDexHost)
+	tCore.connMtx.Unlock()
+
+	// connectDEX error
+	_, err = tCore.GetPolicy(tUnparseableHost, "")
+	if !errorHasCode(err, connectionErr) {
+		t.Fatalf("wrong connectDEX error: %v", err)
+	}
+
+	rig.queueConfig()
+	queuePolicy(&otherPolicy)
+	p, err = tCore.GetPolicy(tDexHost, "")
+	if err != nil {
+		t.Fatalf("GetPolicy error for unregistered DEX: %v", err)
+	}
+	if !bytes.Equal(p.PubKey, otherPolicy.DEXPubKey) {
+		t.Fatalf("wrong policy key")
+	}
+}
+
 func TestRegister(t *testing.T) {
 	// This test takes a little longer because the key is decrypted every time
 	// Register is called.
@@ -1106,7 +1434,7 @@ func TestRegister(t *testing.T) {
 	delete(tCore.conns, tDexHost)
 
 	wallet, tWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = wallet
+	tCore.wallets[wallet.id()] = wallet
 
 	// When registering, successfully retrieving *db.AccountInfo from the DB is
 	// an error (no dupes). Initial state is to return an error.
@@ -1247,12 +1575,12 @@ func TestRegister(t *testing.T) {
 	}
 
 	// wallet not found
-	delete(tCore.wallets, tDCR.ID)
+	delete(tCore.wallets, db.WalletSID(tDCR.ID, ""))
 	run()
 	if !errorHasCode(err, walletErr) {
 		t.Fatalf("wrong missing wallet error: %v", err)
 	}
-	tCore.wallets[tDCR.ID] = wallet
+	tCore.wallets[wallet.id()] = wallet
 
 	// Unlock wallet error
 	tWallet.unlockErr = tErr
@@ -1400,11 +1728,19 @@ func TestLogin(t *testing.T) {
 	tCore := rig.core
 	rig.acct.markFeePaid()
 
+	// Read-only until login.
+	if tCore.LoggedIn() {
+		t.Fatalf("logged in before Login")
+	}
+
 	rig.queueConnect()
 	_, err := tCore.Login(tPW)
 	if err != nil || !rig.acct.authed() {
 		t.Fatalf("initial Login error: %v", err)
 	}
+	if !tCore.LoggedIn() || !tCore.User().LoggedIn {
+		t.Fatalf("not logged in after Login")
+	}
 
 	// No encryption key.
 	rig.acct.unauth()
@@ -1440,7 +1776,34 @@ func TestLogin(t *testing.T) {
 		t.Fatalf("account authed after 'connect' error")
 	}
 
+	// Proof of work required. The request is resent with a solution.
+	rig.acct.unauth()
+	const powBits = 8
+	rig.ws.queueResponse(msgjson.ConnectRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, nil, msgjson.NewPoWRequiredError(powBits))
+		f(resp)
+		return nil
+	})
+	var solved bool
+	rig.ws.queueResponse(msgjson.ConnectRoute, func(msg *msgjson.Message, f msgFunc) error {
+		connect := new(msgjson.Connect)
+		msg.Unmarshal(connect)
+		solved = msgjson.CheckPoW(connect.Serialize(), connect.Nonce, powBits)
+		sign(tDexPriv, connect)
+		resp, _ := msgjson.NewResponse(msg.ID, &msgjson.ConnectResult{Sig: connect.Sig}, nil)
+		f(resp)
+		return nil
+	})
+	_, err = tCore.Login(tPW)
+	if err != nil || !rig.acct.authed() {
+		t.Fatalf("Login error with proof of work: %v", err)
+	}
+	if !solved {
+		t.Fatalf("'connect' resent without a valid proof of work")
+	}
+
 	// Success again.
+	rig.acct.unauth()
 	rig.queueConnect()
 	_, err = tCore.Login(tPW)
 	if err != nil || !rig.acct.authed() {
@@ -1448,6 +1811,47 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLoginReconcile(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	rig.acct.markFeePaid()
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, rig.dc.market(tDcrBtcMktName).EpochLen,
+		rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	dc.trades[oid] = tracker
+
+	// The order was executed while the client was offline.
+	rig.queueConnect()
+	rig.ws.queueResponse(msgjson.OrderStatusRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, []*msgjson.OrderStatus{{
+			ID:     oid[:],
+			Status: uint16(order.OrderStatusExecuted),
+		}}, nil)
+		f(resp)
+		return nil
+	})
+	if _, err = tCore.Login(tPW); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	if tracker.metaData.Status != order.OrderStatusExecuted {
+		t.Fatalf("order status not reconciled at login. expected %s, got %s",
+			order.OrderStatusExecuted, tracker.metaData.Status)
+	}
+}
+
 func TestConnectDEX(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
@@ -1500,6 +1904,29 @@ func TestConnectDEX(t *testing.T) {
 		t.Fatalf("no error for 'config' route error")
 	}
 
+	// Unsigned config.
+	rig.ws.queueResponse(msgjson.ConfigRoute, func(msg *msgjson.Message, f msgFunc) error {
+		cfg := *rig.dc.cfg
+		cfg.Sig = nil
+		resp, _ := msgjson.NewResponse(msg.ID, &cfg, nil)
+		f(resp)
+		return nil
+	})
+	_, err = tCore.connectDEX(ai)
+	if err == nil {
+		t.Fatalf("no error for unsigned config")
+	}
+
+	// Config signed by a key other than the account's DEX key.
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	ai.DEXPubKey = otherKey.PubKey()
+	rig.queueConfig()
+	_, err = tCore.connectDEX(ai)
+	if err == nil {
+		t.Fatalf("no error for config signed by the wrong key")
+	}
+	ai.DEXPubKey = nil
+
 	// Success again.
 	rig.queueConfig()
 	_, err = tCore.connectDEX(ai)
@@ -1508,6 +1935,181 @@ func TestConnectDEX(t *testing.T) {
 	}
 }
 
+// tKeyRotation creates a key rotation from the test DEX key to a new key.
+func tKeyRotation(activation time.Time) (*secp256k1.PrivateKey, *msgjson.KeyRotation) {
+	newPriv, _ := secp256k1.GeneratePrivateKey()
+	kr := &msgjson.KeyRotation{
+		OldPubKey:  tDexKey.SerializeCompressed(),
+		NewPubKey:  newPriv.PubKey().SerializeCompressed(),
+		Activation: encode.UnixMilliU(activation),
+	}
+	oldSig, _ := tDexPriv.Sign(kr.Serialize())
+	newSig, _ := newPriv.Sign(kr.Serialize())
+	kr.OldSig, kr.NewSig = oldSig.Serialize(), newSig.Serialize()
+	return newPriv, kr
+}
+
+func TestVerifyConfig(t *testing.T) {
+	activation := time.Now().Add(time.Hour)
+	newPriv, kr := tKeyRotation(activation)
+	newKey := newPriv.PubKey()
+	unknownPriv, _ := secp256k1.GeneratePrivateKey()
+
+	badRotation := *kr
+	badRotation.NewSig = kr.OldSig
+
+	tests := []struct {
+		name        string
+		signer      *secp256k1.PrivateKey
+		rotation    *msgjson.KeyRotation
+		knownKey    *secp256k1.PublicKey
+		wantErr     bool
+		wantKey     *secp256k1.PublicKey
+		wantRotated bool
+		wantNext    bool
+	}{{
+		name:    "unregistered",
+		signer:  tDexPriv,
+		wantKey: tDexKey,
+	}, {
+		name:     "known key",
+		signer:   tDexPriv,
+		knownKey: tDexKey,
+		wantKey:  tDexKey,
+	}, {
+		name:     "unknown key",
+		signer:   unknownPriv,
+		knownKey: tDexKey,
+		wantErr:  true,
+	}, {
+		name:     "pending rotation",
+		signer:   tDexPriv,
+		rotation: kr,
+		knownKey: tDexKey,
+		wantKey:  tDexKey,
+		wantNext: true,
+	}, {
+		name:        "rotated",
+		signer:      newPriv,
+		rotation:    kr,
+		knownKey:    tDexKey,
+		wantKey:     newKey,
+		wantRotated: true,
+	}, {
+		name:     "rotated, already known",
+		signer:   newPriv,
+		rotation: kr,
+		knownKey: newKey,
+		wantKey:  newKey,
+	}, {
+		name:     "rotation without the new key's signature",
+		signer:   tDexPriv,
+		rotation: &badRotation,
+		knownKey: tDexKey,
+		wantErr:  true,
+	}, {
+		name:     "signer not in rotation",
+		signer:   unknownPriv,
+		rotation: kr,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		cfg := &msgjson.ConfigResult{
+			CancelMax:   0.8,
+			Fee:         tFee,
+			DEXPubKey:   test.signer.PubKey().SerializeCompressed(),
+			KeyRotation: test.rotation,
+		}
+		sign(test.signer, cfg)
+		keys, err := verifyConfig(cfg, test.knownKey)
+		if test.wantErr {
+			if err == nil {
+				t.Fatalf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: verifyConfig error: %v", test.name, err)
+		}
+		if !keys.pubKey.IsEqual(test.wantKey) {
+			t.Fatalf("%s: wrong key", test.name)
+		}
+		if keys.rotated != test.wantRotated {
+			t.Fatalf("%s: expected rotated = %v", test.name, test.wantRotated)
+		}
+		if test.wantNext {
+			if keys.nextPubKey == nil || !keys.nextPubKey.IsEqual(newKey) {
+				t.Fatalf("%s: pending rotation not reported", test.name)
+			}
+			if !keys.rotation.Equal(encode.UnixTimeMilli(int64(kr.Activation))) {
+				t.Fatalf("%s: wrong rotation time %v", test.name, keys.rotation)
+			}
+		} else if keys.nextPubKey != nil {
+			t.Fatalf("%s: unexpected pending rotation", test.name)
+		}
+	}
+
+	// A rotated key is saved for the account when connecting.
+	rig := newTestRig()
+	rig.dc.cfg.KeyRotation = kr
+	rig.ws.queueResponse(msgjson.ConfigRoute, func(msg *msgjson.Message, f msgFunc) error {
+		cfg := *rig.dc.cfg
+		cfg.DEXPubKey = newKey.SerializeCompressed()
+		sign(newPriv, &cfg)
+		resp, _ := msgjson.NewResponse(msg.ID, &cfg, nil)
+		f(resp)
+		return nil
+	})
+	dc, err := rig.core.connectDEX(&db.AccountInfo{
+		Host:      "somedex.com",
+		DEXPubKey: tDexKey,
+	})
+	if err != nil {
+		t.Fatalf("connectDEX error: %v", err)
+	}
+	if rig.db.dexKeyUpdate == nil || !rig.db.dexKeyUpdate.IsEqual(newKey) {
+		t.Fatalf("rotated key not saved")
+	}
+	if !dc.acct.dexKey().IsEqual(newKey) {
+		t.Fatalf("rotated key not set for the account")
+	}
+}
+
+func TestCheckSigKeyRotation(t *testing.T) {
+	newPriv, _ := tKeyRotation(time.Now())
+	acct := &dexAccount{
+		host: "somedex.com",
+	}
+	msg := encode.RandomBytes(32)
+	newSig, _ := newPriv.Sign(msg)
+	oldSig, _ := tDexPriv.Sign(msg)
+
+	// Before the rotation time, only the old key is accepted.
+	acct.setDEXKey(tDexKey, newPriv.PubKey(), time.Now().Add(time.Hour))
+	if err := acct.checkSig(msg, newSig.Serialize()); err == nil {
+		t.Fatalf("new key accepted before the rotation time")
+	}
+	if err := acct.checkSig(msg, oldSig.Serialize()); err != nil {
+		t.Fatalf("old key rejected before the rotation time: %v", err)
+	}
+
+	// After the rotation time, the new key replaces the old.
+	acct.setDEXKey(tDexKey, newPriv.PubKey(), time.Now().Add(-time.Second))
+	if err := acct.checkSig(msg, oldSig.Serialize()); err != nil {
+		t.Fatalf("old key rejected before the new key was seen: %v", err)
+	}
+	if err := acct.checkSig(msg, newSig.Serialize()); err != nil {
+		t.Fatalf("new key rejected after the rotation time: %v", err)
+	}
+	if !acct.dexKey().IsEqual(newPriv.PubKey()) {
+		t.Fatalf("new key did not replace the old key")
+	}
+	if err := acct.checkSig(msg, oldSig.Serialize()); err == nil {
+		t.Fatalf("old key accepted after rotation")
+	}
+}
+
 func TestInitializeClient(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
@@ -1543,7 +2145,7 @@ func TestWithdraw(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
 	wallet, tWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = wallet
+	tCore.wallets[wallet.id()] = wallet
 	address := "addr"
 
 	// Successful
@@ -1593,34 +2195,99 @@ func TestWithdraw(t *testing.T) {
 	}
 }
 
-func TestTrade(t *testing.T) {
+func TestSendWithdrawal(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
-	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
-	dcrWallet.Unlock(wPW, time.Hour)
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[wallet.id()] = wallet
+	w := &asset.Withdrawal{
+		Outputs: []*asset.WithdrawalOutput{
+			{Address: "addr1", Value: 1e8},
+			{Address: "addr2", Value: 2e8},
+		},
+		FeeRate: 20,
+	}
+	tWallet.withdrawEst = &asset.WithdrawalEstimate{FeeRate: 20, Size: 300, Fee: 6000, Inputs: 1}
+	tWallet.withdrawCoins = []asset.Coin{&tCoin{id: []byte{'a'}}, &tCoin{id: []byte{'b'}}}
 
-	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
-	btcWallet.Unlock(wPW, time.Hour)
+	est, err := tCore.EstimateWithdrawal(tDCR.ID, w)
+	if err != nil {
+		t.Fatalf("EstimateWithdrawal error: %v", err)
+	}
+	if est.Fee != 6000 || tWallet.withdrawal != w {
+		t.Fatalf("estimate not propagated")
+	}
 
-	qty := tDCR.LotSize * 10
-	rate := tBTC.RateStep * 1000
+	coins, err := tCore.SendWithdrawal(tPW, tDCR.ID, w)
+	if err != nil {
+		t.Fatalf("SendWithdrawal error: %v", err)
+	}
+	if len(coins) != 2 || coins[1].ID()[0] != 'b' {
+		t.Fatalf("coins not propagated")
+	}
 
-	form := &TradeForm{
-		Host:    tDexHost,
-		IsLimit: true,
-		Sell:    true,
-		Base:    tDCR.ID,
-		Quote:   tBTC.ID,
-		Qty:     qty,
-		Rate:    rate,
-		TifNow:  false,
+	// Zero value output.
+	w.Outputs[1].Value = 0
+	if _, err = tCore.EstimateWithdrawal(tDCR.ID, w); err == nil {
+		t.Fatalf("no error for zero value output")
 	}
+	w.Outputs[1].Value = 2e8
 
-	dcrCoin := &tCoin{
+	// No outputs.
+	if _, err = tCore.SendWithdrawal(tPW, tDCR.ID, &asset.Withdrawal{}); err == nil {
+		t.Fatalf("no error for no outputs")
+	}
+
+	// No wallet.
+	if _, err = tCore.SendWithdrawal(tPW, 12345, w); err == nil {
+		t.Fatalf("no error for unknown wallet")
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	if _, err = tCore.SendWithdrawal(tPW, tDCR.ID, w); err == nil {
+		t.Fatalf("no error for password error")
+	}
+	rig.crypter.recryptErr = nil
+
+	// Wallet error.
+	tWallet.withdrawErr = tErr
+	if _, err = tCore.EstimateWithdrawal(tDCR.ID, w); err == nil {
+		t.Fatalf("no error for wallet estimate error")
+	}
+	if _, err = tCore.SendWithdrawal(tPW, tDCR.ID, w); err == nil {
+		t.Fatalf("no error for wallet send error")
+	}
+}
+
+func TestTrade(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	dcrWallet.Unlock(wPW, time.Hour)
+
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet.Unlock(wPW, time.Hour)
+
+	qty := tDCR.LotSize * 10
+	rate := tBTC.RateStep * 1000
+
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     qty,
+		Rate:    rate,
+		TifNow:  false,
+	}
+
+	dcrCoin := &tCoin{
 		id:  encode.RandomBytes(36),
 		val: qty * 2,
 	}
@@ -1740,15 +2407,44 @@ func TestTrade(t *testing.T) {
 	ensureErr("zero rate limit")
 	form.Rate = rate
 
+	// Iceberg orders must be standing limit orders.
+	form.Visible = tDCR.LotSize
+	form.TifNow = true
+	ensureErr("immediate iceberg")
+	form.TifNow = false
+	form.IsLimit = false
+	ensureErr("market iceberg")
+	form.IsLimit = true
+
+	// Iceberg order visible quantity must be a multiple of the lot size.
+	form.Visible = tDCR.LotSize + 1
+	ensureErr("iceberg lot size")
+
+	// Iceberg order visible quantity must be less than the quantity.
+	form.Visible = qty
+	ensureErr("iceberg visible quantity")
+
+	// Iceberg order success.
+	form.Visible = tDCR.LotSize
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	corder, err := tCore.Trade(tPW, form)
+	if err != nil {
+		t.Fatalf("iceberg order error: %v", err)
+	}
+	if corder.Visible != tDCR.LotSize {
+		t.Fatalf("wrong iceberg visible quantity %d, expected %d", corder.Visible, tDCR.LotSize)
+	}
+	form.Visible = 0
+
 	// No from wallet
-	delete(tCore.wallets, tDCR.ID)
+	delete(tCore.wallets, db.WalletSID(tDCR.ID, ""))
 	ensureErr("no dcr wallet")
-	tCore.wallets[tDCR.ID] = dcrWallet
+	tCore.wallets[dcrWallet.id()] = dcrWallet
 
 	// No to wallet
-	delete(tCore.wallets, tBTC.ID)
+	delete(tCore.wallets, db.WalletSID(tBTC.ID, ""))
 	ensureErr("no btc wallet")
-	tCore.wallets[tBTC.ID] = btcWallet
+	tCore.wallets[btcWallet.id()] = btcWallet
 
 	// Address error
 	tBtcWallet.addrErr = tErr
@@ -1909,6 +2605,20 @@ func TestCancel(t *testing.T) {
 	ensureErr("Request error")
 	rig.ws.reqErr = nil
 
+	// A rejection from the server is explained, and the code is available.
+	rig.ws.queueResponse(msgjson.CancelRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, nil, msgjson.NewError(msgjson.MarketSuspendedError, "market suspended at epoch 5"))
+		f(resp)
+		return nil
+	})
+	err = rig.core.Cancel(tPW, sid)
+	var msgErr *msgjson.Error
+	if !errors.As(err, &msgErr) || msgErr.Code != msgjson.MarketSuspendedError {
+		t.Fatalf("expected a MarketSuspendedError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "try again when trading resumes") {
+		t.Fatalf("no explanation for market suspended error: %v", err)
+	}
 }
 
 func TestHandlePreimageRequest(t *testing.T) {
@@ -1955,7 +2665,7 @@ func TestHandleRevokeMatchMsg(t *testing.T) {
 	dc := rig.dc
 	tCore := rig.core
 	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
+	tCore.wallets[dcrWallet.id()] = dcrWallet
 	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
 	dcrWallet.Unlock(wPW, time.Hour)
 
@@ -1963,7 +2673,7 @@ func TestHandleRevokeMatchMsg(t *testing.T) {
 	fundCoinDcr := &tCoin{id: fundCoinDcrID}
 
 	btcWallet, _ := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
+	tCore.wallets[btcWallet.id()] = btcWallet
 	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
 	btcWallet.Unlock(wPW, time.Hour)
 
@@ -2035,470 +2745,524 @@ func TestHandleRevokeMatchMsg(t *testing.T) {
 	}
 }
 
-func TestTradeTracking(t *testing.T) {
+func TestHandleRevokeOrderMsg(t *testing.T) {
 	rig := newTestRig()
 	dc := rig.dc
 	tCore := rig.core
 	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	tCore.wallets[dcrWallet.id()] = dcrWallet
 	dcrWallet.Unlock(wPW, time.Hour)
-
-	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
 	btcWallet.Unlock(wPW, time.Hour)
 
-	matchSize := 4 * tDCR.LotSize
-	cancelledQty := tDCR.LotSize
-	qty := 2*matchSize + cancelledQty
-	rate := tBTC.RateStep * 10
-	lo, dbOrder, preImgL, addr := makeLimitOrder(dc, true, qty, tBTC.RateStep)
-	// fundCoinDcrID := encode.RandomBytes(36)
-	// lo.Coins = []order.CoinID{fundCoinDcrID}
-	loid := lo.ID()
+	fundCoinDcrID := encode.RandomBytes(36)
+	tDcrWallet.fundingCoins = asset.Coins{&tCoin{id: fundCoinDcrID}}
 
-	//fundCoinDcr := &tCoin{id: fundCoinDcrID}
-	//tDcrWallet.fundingCoins = asset.Coins{fundCoinDcr}
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	lo.Coins = []order.CoinID{fundCoinDcrID}
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	oid := lo.ID()
 
-	mid := ordertest.RandomMatchID()
 	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
 	if err != nil {
 		t.Fatalf("walletSet error: %v", err)
 	}
 	mkt := dc.market(tDcrBtcMktName)
-	tracker := newTrackedTrade(dbOrder, preImgL, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen,
+		rig.core.lockTimeTaker, rig.core.lockTimeMaker,
 		rig.db, rig.queue, walletSet, nil, rig.core.notify)
-	rig.dc.trades[tracker.ID()] = tracker
-	var match *matchTracker
-	checkStatus := func(tag string, wantStatus order.MatchStatus) {
-		t.Helper()
-		if match.Match.Status != wantStatus {
-			t.Fatalf("%s: wrong status wanted %v, got %v", tag,
-				wantStatus, match.Match.Status)
-		}
-	}
 
-	// MAKER MATCH
-	matchTime := time.Now()
-	msgMatch := &msgjson.Match{
-		OrderID:    loid[:],
-		MatchID:    mid[:],
-		Quantity:   matchSize,
-		Rate:       rate,
-		Address:    "counterparty-address",
-		Side:       uint8(order.Maker),
-		ServerTime: encode.UnixMilliU(matchTime),
-	}
-	counterSwapID := encode.RandomBytes(36)
-	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: counterSwapID}}}
-	sign(tDexPriv, msgMatch)
-	msg, _ := msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
-	// queue an invalid DEX init ack
-	rig.ws.queueResponse(msgjson.InitRoute, invalidAcker)
-	err = handleMatchRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no error for invalid server ack for init route")
-	}
-	match, found := tracker.matches[mid]
-	if !found {
-		t.Fatalf("match not found")
+	note, _ := msgjson.NewNotification(msgjson.RevokeOrderRoute, &msgjson.RevokeOrder{
+		OrderID: oid[:],
+	})
+
+	// Ensure revoking a non-existent order generates an error.
+	if err = handleRevokeOrderMsg(rig.core, rig.dc, note); err == nil {
+		t.Fatal("[handleRevokeOrderMsg] expected a non-existent order")
 	}
 
-	// We're the maker, so the init transaction should be broadcast.
-	checkStatus("maker swapped", order.MakerSwapCast)
-	_, metaData := match.Match, match.MetaData
-	proof, auth := &metaData.Proof, &metaData.Proof.Auth
-	if len(auth.MatchSig) == 0 {
-		t.Fatalf("no match sig recorded")
+	rig.dc.trades[oid] = tracker
+	if err = handleRevokeOrderMsg(rig.core, rig.dc, note); err != nil {
+		t.Fatalf("handleRevokeOrderMsg error: %v", err)
 	}
-	if !bytes.Equal(proof.MakerSwap, counterSwapID) {
-		t.Fatalf("receipt ID not recorded")
+	if tracker.metaData.Status != order.OrderStatusRevoked {
+		t.Errorf("incorrect order status. got %v, expected %v",
+			tracker.metaData.Status, order.OrderStatusRevoked)
 	}
-	if len(proof.Secret) == 0 {
-		t.Fatalf("secret not set")
+
+	// Bad order ID.
+	note, _ = msgjson.NewNotification(msgjson.RevokeOrderRoute, &msgjson.RevokeOrder{
+		OrderID: oid[:10],
+	})
+	if err = handleRevokeOrderMsg(rig.core, rig.dc, note); err == nil {
+		t.Fatal("[handleRevokeOrderMsg] no error for bad order ID")
 	}
-	if len(proof.SecretHash) == 0 {
-		t.Fatalf("secret hash not set")
+}
+
+func TestHandlePenaltyMsg(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	ch := tCore.NotificationFeed()
+
+	acctID := rig.dc.acct.ID()
+	mid := encode.RandomBytes(32)
+	penalty := &msgjson.Penalty{
+		AccountID: acctID[:],
+		Rule:      uint8(account.FailureToAct),
+		Banned:    true,
+		Details:   "failed to act",
+		MatchIDs:  []msgjson.Bytes{mid},
+		Appeal:    "appeal",
+	}
+	sign(tDexPriv, penalty)
+	note, _ := msgjson.NewNotification(msgjson.PenaltyRoute, penalty)
+	if err := handlePenaltyMsg(tCore, rig.dc, note); err != nil {
+		t.Fatalf("handlePenaltyMsg error: %v", err)
 	}
-	// auth.InitSig should be unset because our init request received
-	// an invalid ack
-	if len(auth.InitSig) != 0 {
-		t.Fatalf("init sig recorded for invalid init ack")
+	select {
+	case n := <-ch:
+		pn, ok := n.(*PenaltyNote)
+		if !ok || pn.Severity() != db.ErrorLevel || !pn.Penalty.Banned || pn.Penalty.Rule != "failure to act" ||
+			len(pn.Penalty.MatchIDs) != 1 || pn.Penalty.MatchIDs[0] != hex.EncodeToString(mid) {
+			t.Fatalf("wrong penalty notification %+v", n)
+		}
+	default:
+		t.Fatalf("no penalty notification")
 	}
 
-	// requeue an invalid DEX init ack and re-send pending init request
-	rig.ws.queueResponse(msgjson.InitRoute, invalidAcker)
-	err = tracker.resendPendingRequests()
-	if err == nil {
-		t.Fatalf("no error for invalid server ack for resent init request")
+	// A penalty that is not signed by the DEX is rejected.
+	penalty.Details = "forged"
+	note, _ = msgjson.NewNotification(msgjson.PenaltyRoute, penalty)
+	if err := handlePenaltyMsg(tCore, rig.dc, note); err == nil {
+		t.Fatalf("no error for bad penalty signature")
 	}
-	// auth.InitSig should remain unset because our resent init request
-	// received an invalid ack still
-	if len(auth.InitSig) != 0 {
-		t.Fatalf("init sig recorded for second invalid init ack")
+
+	// A penalty for another account is rejected.
+	penalty.AccountID = encode.RandomBytes(32)
+	sign(tDexPriv, penalty)
+	note, _ = msgjson.NewNotification(msgjson.PenaltyRoute, penalty)
+	if err := handlePenaltyMsg(tCore, rig.dc, note); err == nil {
+		t.Fatalf("no error for another account's penalty")
 	}
+}
 
-	// queue a valid DEX init ack and re-send pending init request
-	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
-	err = tracker.resendPendingRequests()
+func TestHandleReceiptMsg(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
 	if err != nil {
-		t.Fatalf("unexpected error for resent pending init request: %v", err)
+		t.Fatalf("walletSet error: %v", err)
 	}
-	// auth.InitSig should now be set because our init request received
-	// a valid ack
-	if len(auth.InitSig) == 0 {
-		t.Fatalf("init sig not recorded for valid init ack")
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen,
+		rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	mid := ordertest.RandomMatchID()
+	match := &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{},
+			Match:    &order.UserMatch{},
+		},
 	}
+	tracker.matches[mid] = match
 
-	// Send the counter-party's init info.
-	auditQty := calc.BaseToQuote(rate, matchSize)
-	audit, auditInfo := tMsgAudit(loid, mid, addr, auditQty, proof.SecretHash)
-	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeTaker))
-	tBtcWallet.auditInfo = auditInfo
-	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
+	// The user is the taker.
+	makerOID := ordertest.RandomOrderID()
+	receipt := &msgjson.SettlementReceipt{
+		MatchID:      mid[:],
+		MakerOrderID: makerOID[:],
+		TakerOrderID: oid[:],
+		Quantity:     lo.Quantity,
+		Rate:         lo.Rate,
+		MakerSwap:    encode.RandomBytes(36),
+		TakerSwap:    encode.RandomBytes(36),
+		MakerRedeem:  encode.RandomBytes(36),
+		TakerRedeem:  encode.RandomBytes(36),
+		Time:         encode.UnixMilliU(time.Now()),
+	}
+	sign(tDexPriv, receipt)
+	note, _ := msgjson.NewNotification(msgjson.ReceiptRoute, receipt)
 
-	// Check audit errors.
-	tBtcWallet.auditErr = tErr
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for AuditContract error")
+	// Unknown order.
+	if err = handleReceiptMsg(tCore, dc, note); err == nil {
+		t.Fatalf("no error for unknown order")
 	}
+	dc.trades[oid] = tracker
 
-	// Check expiration error.
-	tBtcWallet.auditErr = asset.CoinNotFoundError
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for AuditContract expiration")
+	if err = handleReceiptMsg(tCore, dc, note); err != nil {
+		t.Fatalf("handleReceiptMsg error: %v", err)
 	}
-	var errSet *errorSet
-	if !errors.As(err, &errSet) {
-		t.Fatalf("unexpected error type")
+	var stored msgjson.SettlementReceipt
+	if err = json.Unmarshal(match.MetaData.Proof.Receipt, &stored); err != nil {
+		t.Fatalf("error decoding stored receipt: %v", err)
 	}
-	var expErr ExpirationErr
-	if !errors.As(errSet.errs[0], &expErr) {
-		t.Fatalf("wrong error type. expecting ExpirationTimeout, got %T: %v", errSet.errs[0], errSet.errs[0])
+	if !bytes.Equal(stored.Serialize(), receipt.Serialize()) || !bytes.Equal(stored.Sig, receipt.Sig) {
+		t.Fatalf("receipt not stored")
 	}
-	tBtcWallet.auditErr = nil
-
-	auditInfo.coin.val = auditQty - 1
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for low value")
+	if rig.db.updatedMatch != &match.MetaMatch {
+		t.Fatalf("match not updated in the DB")
 	}
-	auditInfo.coin.val = auditQty
 
-	auditInfo.secretHash = []byte{0x01}
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for wrong secret hash")
+	// Bad signature.
+	receipt.Time++
+	note, _ = msgjson.NewNotification(msgjson.ReceiptRoute, receipt)
+	if err = handleReceiptMsg(tCore, dc, note); err == nil {
+		t.Fatalf("no error for bad receipt signature")
 	}
-	auditInfo.secretHash = proof.SecretHash
+}
 
-	auditInfo.recipient = "wrong address"
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for wrong address")
+func TestHandleSettlementProgressMsg(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	ch := tCore.NotificationFeed()
+
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen,
+		rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, rig.core.notify)
+	mid := ordertest.RandomMatchID()
+	tracker.matches[mid] = &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{},
+			Match:    &order.UserMatch{},
+		},
 	}
-	auditInfo.recipient = addr
 
-	auditInfo.expiration = matchTime.Add(time.Hour * 23)
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no maker error for early lock time")
+	// The user is the maker.
+	takerOID := ordertest.RandomOrderID()
+	progress := &msgjson.SettlementProgress{
+		MatchID:      mid[:],
+		MakerOrderID: oid[:],
+		TakerOrderID: takerOID[:],
+		Milestone:    msgjson.TakerSwapConfirmed,
+		AssetID:      tBTC.ID,
+		CoinID:       encode.RandomBytes(36),
+		Time:         encode.UnixMilliU(time.Now()),
+	}
+	send := func() error {
+		note, _ := msgjson.NewNotification(msgjson.SettlementProgressRoute, progress)
+		return handleSettlementProgressMsg(tCore, dc, note)
 	}
-	auditInfo.expiration = matchTime.Add(time.Hour * 24)
 
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err != nil {
-		t.Fatalf("match message error: %v", err)
+	// Unknown order.
+	if err := send(); err == nil {
+		t.Fatalf("no error for unknown order")
 	}
-	checkStatus("maker counter-party swapped", order.TakerSwapCast)
-	if match.counterSwap == nil {
-		t.Fatalf("counter-swap not set")
-	}
-	if !bytes.Equal(proof.CounterScript, audit.Contract) {
-		t.Fatalf("counter-script not recorded")
-	}
-	if !bytes.Equal(proof.TakerSwap, audit.CoinID) {
-		t.Fatalf("taker contract ID not set")
-	}
-	if !bytes.Equal(auth.AuditSig, audit.Sig) {
-		t.Fatalf("audit sig not set")
-	}
-	if auth.AuditStamp != audit.Time {
-		t.Fatalf("audit time not set")
-	}
-	// Confirming the counter-swap triggers a redemption.
-	auditInfo.coin.confs = tBTC.SwapConf
-	redeemCoin := encode.RandomBytes(36)
-	tBtcWallet.redeemCoins = []dex.Bytes{redeemCoin}
-	rig.ws.queueResponse(msgjson.RedeemRoute, redeemAcker)
-	dc.tickAsset(tBTC.ID)
-	checkStatus("maker redeemed", order.MakerRedeemed)
-	if !bytes.Equal(proof.MakerRedeem, redeemCoin) {
-		t.Fatalf("redeem coin ID not logged")
+	dc.trades[oid] = tracker
+
+	if err := send(); err != nil {
+		t.Fatalf("handleSettlementProgressMsg error: %v", err)
 	}
-	redemptionCoin := encode.RandomBytes(36)
-	// The taker's redemption is simply logged.
-	redemption := &msgjson.Redemption{
-		Redeem: msgjson.Redeem{
-			OrderID: loid[:],
-			MatchID: mid[:],
-			CoinID:  redemptionCoin,
-		},
+	select {
+	case n := <-ch:
+		on, ok := n.(*OrderNote)
+		if !ok || on.Topic != string(TopicTakerSwapConfirmed) || on.Order == nil || on.Order.ID != oid.String() {
+			t.Fatalf("wrong progress notification %+v", n)
+		}
+	default:
+		t.Fatalf("no progress notification")
 	}
-	msg, _ = msgjson.NewRequest(1, msgjson.RedemptionRoute, redemption)
-	err = handleRedemptionRoute(tCore, rig.dc, msg)
-	if err != nil {
-		t.Fatalf("redemption message error: %v", err)
+
+	// Unknown match.
+	otherMID := ordertest.RandomMatchID()
+	progress.MatchID = otherMID[:]
+	if err := send(); err == nil {
+		t.Fatalf("no error for unknown match")
 	}
-	checkStatus("maker match complete", order.MatchComplete)
-	if !bytes.Equal(redemptionCoin, proof.TakerRedeem) {
-		t.Fatalf("taker redemption coin not recorded")
+	progress.MatchID = mid[:]
+
+	// Unknown milestone.
+	progress.Milestone = "takerswapcast"
+	if err := send(); err == nil {
+		t.Fatalf("no error for unknown milestone")
 	}
+}
 
-	// TAKER MATCH
-	//
-	mid = ordertest.RandomMatchID()
-	msgMatch = &msgjson.Match{
-		OrderID:    loid[:],
+func TestRecoverHistory(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+
+	lo, _, _, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	mid := ordertest.RandomMatchID()
+	msgMatch := &msgjson.Match{
+		OrderID:    oid[:],
 		MatchID:    mid[:],
-		Quantity:   matchSize,
-		Rate:       rate,
+		Quantity:   tDCR.LotSize,
+		Rate:       tBTC.RateStep,
 		Address:    "counterparty-address",
-		Side:       uint8(order.Taker),
-		ServerTime: encode.UnixMilliU(matchTime),
+		Side:       uint8(order.Maker),
+		ServerTime: encode.UnixMilliU(time.Now()),
 	}
 	sign(tDexPriv, msgMatch)
-	msg, _ = msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
-	err = handleMatchRoute(tCore, rig.dc, msg)
-	if err != nil {
-		t.Fatalf("match messages error: %v", err)
-	}
-	match, found = tracker.matches[mid]
-	if !found {
-		t.Fatalf("match not found")
-	}
-	checkStatus("taker matched", order.NewlyMatched)
-	_, metaData = match.Match, match.MetaData
-	proof, auth = &metaData.Proof, &metaData.Proof.Auth
-	if len(auth.MatchSig) == 0 {
-		t.Fatalf("no match sig recorded")
+	audit, _ := tMsgAudit(oid, mid, "", tDCR.LotSize, nil)
+
+	queueExport := func() {
+		rig.ws.queueResponse(msgjson.AccountExportRoute, func(msg *msgjson.Message, f msgFunc) error {
+			resp, _ := msgjson.NewResponse(msg.ID, &msgjson.AccountExportResult{
+				Orders: []*msgjson.ExportedOrder{{
+					Order:  order.EncodeOrder(lo),
+					Status: uint16(order.OrderStatusExecuted),
+				}},
+				Matches: []*msgjson.MatchStatusResult{{
+					MatchID: mid[:],
+					Status:  uint8(order.TakerSwapCast),
+					Match:   msgMatch,
+					Audit:   audit,
+				}},
+			}, nil)
+			f(resp)
+			return nil
+		})
 	}
-	// Secret should not be set yet.
-	if len(proof.Secret) != 0 {
-		t.Fatalf("secret set for taker")
+
+	// Unknown DEX.
+	if _, err := tCore.RecoverHistory("unknown"); err == nil {
+		t.Fatalf("no error for unknown DEX")
 	}
-	if len(proof.SecretHash) != 0 {
-		t.Fatalf("secret hash set for taker")
+
+	// Not logged in.
+	if _, err := tCore.RecoverHistory(tDexHost); err == nil {
+		t.Fatalf("no error for unauthed account")
 	}
-	// Now send through the audit request for the maker's init.
-	audit, auditInfo = tMsgAudit(loid, mid, addr, matchSize, nil)
-	tBtcWallet.auditInfo = auditInfo
-	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
-	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
-	err = handleAuditRoute(tCore, rig.dc, msg)
+	dc.acct.auth()
+
+	// Everything is already known.
+	rig.db.matchesForOID = []*db.MetaMatch{{Match: &order.UserMatch{MatchID: mid}}}
+	queueExport()
+	rec, err := tCore.RecoverHistory(tDexHost)
 	if err != nil {
-		t.Fatalf("taker's match message error: %v", err)
+		t.Fatalf("RecoverHistory error: %v", err)
 	}
-
-	auditInfo.expiration = matchTime.Add(time.Hour * 47)
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no taker error for early lock time")
+	if rec.Orders != 0 || rec.Matches != 0 {
+		t.Fatalf("known order recovered")
 	}
-	auditInfo.expiration = matchTime.Add(time.Hour * 48)
 
-	checkStatus("taker counter-party swapped", order.MakerSwapCast)
-	if len(proof.SecretHash) == 0 {
-		t.Fatalf("secret hash not set for taker")
+	// Unknown order and match.
+	rig.db.dbOrderErr = fmt.Errorf("not found")
+	rig.db.matchesForOID = nil
+	queueExport()
+	rec, err = tCore.RecoverHistory(tDexHost)
+	if err != nil {
+		t.Fatalf("RecoverHistory error: %v", err)
 	}
-	if !bytes.Equal(proof.MakerSwap, audit.CoinID) {
-		t.Fatalf("maker redeem coin not set")
+	if rec.Orders != 1 || rec.Matches != 1 {
+		t.Fatalf("expected 1 order and 1 match recovered, got %d and %d", rec.Orders, rec.Matches)
 	}
-	if !bytes.Equal(auth.AuditSig, audit.Sig) {
-		t.Fatalf("audit sig not set for taker")
+	if len(rig.db.updatedOrders) != 1 || rig.db.updatedOrders[0].Order.ID() != oid {
+		t.Fatalf("order not stored")
 	}
-	if auth.AuditStamp != audit.Time {
-		t.Fatalf("audit time not set for taker")
+	stored := rig.db.updatedMatch
+	if stored == nil || stored.Match.MatchID != mid {
+		t.Fatalf("match not stored")
 	}
-	// The swap should not be sent, since the auditInfo coin doesn't have the
-	// requisite confirmations.
-	if len(proof.TakerSwap) != 0 {
-		t.Fatalf("swap broadcast before confirmations")
+	if !bytes.Equal(stored.MetaData.Proof.TakerSwap, audit.CoinID) {
+		t.Fatalf("counterparty swap not recorded")
 	}
-	// Now with the confirmations.
-	auditInfo.coin.confs = tBTC.SwapConf
-	swapID := encode.RandomBytes(36)
-	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: swapID}}}
-	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
-	dc.tickAsset(tBTC.ID)
-	checkStatus("taker swapped", order.TakerSwapCast)
-	if len(proof.TakerSwap) == 0 {
-		t.Fatalf("swap not broadcast with confirmations")
+
+	// Bad match signature.
+	msgMatch.Sig = encode.RandomBytes(len(msgMatch.Sig))
+	queueExport()
+	if _, err = tCore.RecoverHistory(tDexHost); err == nil {
+		t.Fatalf("no error for bad match signature")
 	}
-	// Receive the maker's redemption.
-	redemptionCoin = encode.RandomBytes(36)
-	redemption = &msgjson.Redemption{
-		Redeem: msgjson.Redeem{
-			OrderID: loid[:],
-			MatchID: mid[:],
-			CoinID:  redemptionCoin,
+}
+
+func TestVerifyEpoch(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	rig.db.dbOrder = dbOrder
+	makerPI := newPreimage()
+	maker := &order.LimitOrder{
+		P: order.Prefix{
+			AccountID:  ordertest.NextAccount(),
+			BaseAsset:  tDCR.ID,
+			QuoteAsset: tBTC.ID,
+			OrderType:  order.LimitOrderType,
+			ClientTime: time.Now().Add(-time.Minute),
+			ServerTime: time.Now().Add(-time.Minute),
+			Commit:     makerPI.Commit(),
 		},
+		T: order.Trade{
+			Coins:    []order.CoinID{encode.RandomBytes(36)},
+			Quantity: 2 * tDCR.LotSize,
+			Address:  ordertest.RandomAddress(),
+		},
+		Rate:  tBTC.RateStep,
+		Force: order.StandingTiF,
 	}
-	redeemCoin = encode.RandomBytes(36)
-	tBtcWallet.redeemCoins = []dex.Bytes{redeemCoin}
-	msg, _ = msgjson.NewRequest(1, msgjson.RedemptionRoute, redemption)
 
-	tBtcWallet.badSecret = true
-	err = handleRedemptionRoute(tCore, rig.dc, msg)
-	if err == nil {
-		t.Fatalf("no error for wrong secret")
+	// Replay the epoch as the server would have matched it.
+	seed, matchSets, result, err := replay.ReplayEpoch(tDCR.LotSize, 0, 0,
+		[]*order.LimitOrder{order.RedactOrder(maker).(*order.LimitOrder)},
+		[]*matcher.OrderRevealed{{Order: order.RedactOrder(lo), Preimage: preImg}})
+	if err != nil {
+		t.Fatalf("ReplayEpoch error: %v", err)
 	}
-	tBtcWallet.badSecret = false
+	if len(matchSets) != 1 {
+		t.Fatalf("expected 1 match set, got %d", len(matchSets))
+	}
+	mid := matchSets[0].Matches()[0].ID()
 
-	rig.ws.queueResponse(msgjson.RedeemRoute, redeemAcker)
-	err = handleRedemptionRoute(tCore, rig.dc, msg)
-	if err != nil {
-		t.Fatalf("redemption message error: %v", err)
+	var res *msgjson.EpochReplayResult
+	resetResult := func() {
+		res = &msgjson.EpochReplayResult{
+			MarketID: tDcrBtcMktName,
+			LotSize:  tDCR.LotSize,
+			Book: []*msgjson.ReplayOrder{{
+				OrderID: maker.ID().Bytes(),
+				Order:   order.EncodeOrder(order.RedactOrder(maker)),
+			}},
+			Queue: []*msgjson.ReplayOrder{{
+				OrderID:  oid[:],
+				Order:    order.EncodeOrder(order.RedactOrder(lo)),
+				Preimage: preImg[:],
+			}},
+			Seed:   seed,
+			Result: result,
+		}
 	}
-	checkStatus("taker complete", order.MatchComplete)
-	if !bytes.Equal(proof.MakerRedeem, redemptionCoin) {
-		t.Fatalf("redemption coin ID not logged")
+	queueReplay := func() {
+		rig.ws.queueResponse(msgjson.EpochReplayRoute, func(msg *msgjson.Message, f msgFunc) error {
+			resp, _ := msgjson.NewResponse(msg.ID, res, nil)
+			f(resp)
+			return nil
+		})
 	}
-	if len(proof.TakerRedeem) == 0 {
-		t.Fatalf("taker redemption not sent")
+
+	// Not logged in.
+	if _, err := tCore.VerifyEpoch(oid.String()); err == nil {
+		t.Fatalf("no error for unauthed account")
 	}
+	dc.acct.auth()
 
-	// CANCEL ORDER MATCH
-	//
-	copy(mid[:], encode.RandomBytes(32))
-	preImgC := newPreimage()
-	co := &order.CancelOrder{
-		P: order.Prefix{
-			AccountID:  dc.acct.ID(),
-			BaseAsset:  tDCR.ID,
-			QuoteAsset: tBTC.ID,
-			OrderType:  order.MarketOrderType,
-			ClientTime: time.Now(),
-			ServerTime: time.Now().Add(time.Millisecond),
-			Commit:     preImgC.Commit(),
-		},
+	// Fair.
+	rig.db.matchesForOID = []*db.MetaMatch{{Match: &order.UserMatch{MatchID: mid}}}
+	resetResult()
+	queueReplay()
+	ver, err := tCore.VerifyEpoch(oid.String())
+	if err != nil {
+		t.Fatalf("VerifyEpoch error: %v", err)
 	}
-	tracker.cancel = &trackedCancel{CancelOrder: *co}
-	coid := co.ID()
-	m1 := &msgjson.Match{
-		OrderID:  loid[:],
-		MatchID:  mid[:],
-		Quantity: cancelledQty,
-		Rate:     rate,
-		Address:  "",
+	if !ver.Fair || ver.Matches != 1 || ver.QueueSize != 1 || ver.BookOrders != 1 {
+		t.Fatalf("unexpected verification %+v", ver)
 	}
-	m2 := &msgjson.Match{
-		OrderID:  coid[:],
-		MatchID:  mid[:],
-		Quantity: cancelledQty,
-		Rate:     rate,
-		Address:  "testaddr",
+
+	// Unknown match.
+	rig.db.matchesForOID = nil
+	queueReplay()
+	ver, err = tCore.VerifyEpoch(oid.String())
+	if err != nil {
+		t.Fatalf("VerifyEpoch error: %v", err)
 	}
-	sign(tDexPriv, m1)
-	sign(tDexPriv, m2)
-	msg, _ = msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{m1, m2})
-	err = handleMatchRoute(tCore, rig.dc, msg)
+	if ver.Fair || len(ver.MissingMatches) != 1 || ver.MissingMatches[0] != mid.String() {
+		t.Fatalf("missing match not reported, %+v", ver)
+	}
+	rig.db.matchesForOID = []*db.MetaMatch{{Match: &order.UserMatch{MatchID: mid}}}
+
+	// Wrong seed and results.
+	res.Seed = encode.RandomBytes(32)
+	res.Result = encode.RandomBytes(32)
+	queueReplay()
+	ver, err = tCore.VerifyEpoch(oid.String())
 	if err != nil {
-		t.Fatalf("match messages error: %v", err)
+		t.Fatalf("VerifyEpoch error: %v", err)
 	}
-	if tracker.cancel.matches.maker == nil {
-		t.Fatalf("cancelMatches.maker not set")
+	if ver.Fair || ver.SeedOK || ver.ResultOK {
+		t.Fatalf("wrong results not reported, %+v", ver)
 	}
-	if tracker.Trade().Filled() != qty {
-		t.Fatalf("fill not set")
+
+	// Preimage does not match the commitment.
+	resetResult()
+	res.Queue[0].Preimage = encode.RandomBytes(32)
+	queueReplay()
+	if _, err = tCore.VerifyEpoch(oid.String()); err == nil {
+		t.Fatalf("no error for bad preimage")
 	}
-	if tracker.cancel.matches.taker == nil {
-		t.Fatalf("cancelMatches.taker not set")
+
+	// The order is altered.
+	resetResult()
+	altered := order.RedactOrder(lo).(*order.LimitOrder)
+	altered.Quantity = tDCR.LotSize
+	res.Queue[0].Order = order.EncodeOrder(altered)
+	queueReplay()
+	if _, err = tCore.VerifyEpoch(oid.String()); err == nil {
+		t.Fatalf("no error for altered order")
+	}
+
+	// The order is not in the queue.
+	resetResult()
+	res.Queue = nil
+	queueReplay()
+	if _, err = tCore.VerifyEpoch(oid.String()); err == nil {
+		t.Fatalf("no error for missing order")
 	}
 }
 
-func TestRefunds(t *testing.T) {
-	checkStatus := func(tag string, match *matchTracker, wantStatus order.MatchStatus) {
+func TestTradeTracking(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	dcrWallet.Unlock(wPW, time.Hour)
+
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet.Unlock(wPW, time.Hour)
+
+	matchSize := 4 * tDCR.LotSize
+	cancelledQty := tDCR.LotSize
+	qty := 2*matchSize + cancelledQty
+	rate := tBTC.RateStep * 10
+	lo, dbOrder, preImgL, addr := makeLimitOrder(dc, true, qty, tBTC.RateStep)
+	// fundCoinDcrID := encode.RandomBytes(36)
+	// lo.Coins = []order.CoinID{fundCoinDcrID}
+	loid := lo.ID()
+
+	//fundCoinDcr := &tCoin{id: fundCoinDcrID}
+	//tDcrWallet.fundingCoins = asset.Coins{fundCoinDcr}
+
+	mid := ordertest.RandomMatchID()
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImgL, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	rig.dc.trades[tracker.ID()] = tracker
+	var match *matchTracker
+	checkStatus := func(tag string, wantStatus order.MatchStatus) {
 		t.Helper()
 		if match.Match.Status != wantStatus {
-			t.Fatalf("%s: wrong status wanted %d, got %d", tag, match.Match.Status, wantStatus)
-		}
-	}
-	checkRefund := func(tracker *trackedTrade, match *matchTracker, expectAmt uint64) {
-		t.Helper()
-		// Confirm that the status is SwapCast.
-		if match.Match.Side == order.Maker {
-			checkStatus("maker swapped", match, order.MakerSwapCast)
-		} else {
-			checkStatus("taker swapped", match, order.TakerSwapCast)
-		}
-		// Confirm isRefundable = true.
-		if !tracker.isRefundable(match) {
-			t.Fatalf("%s's swap not refundable", match.Match.Side)
-		}
-		// Check refund.
-		amtRefunded, err := tracker.refundMatches([]*matchTracker{match})
-		if err != nil {
-			t.Fatalf("unexpected refund error %v", err)
-		}
-		// Check refunded amount.
-		if amtRefunded != expectAmt {
-			t.Fatalf("expected %d refund amount, got %d", expectAmt, amtRefunded)
-		}
-		// Confirm isRefundable = false.
-		if tracker.isRefundable(match) {
-			t.Fatalf("%s's swap refundable after being refunded", match.Match.Side)
-		}
-		// Expect refund re-attempt to not refund any coin.
-		amtRefunded, err = tracker.refundMatches([]*matchTracker{match})
-		if err != nil {
-			t.Fatalf("unexpected refund error %v", err)
-		}
-		if amtRefunded != 0 {
-			t.Fatalf("expected 0 refund amount, got %d", amtRefunded)
-		}
-		// Confirm that the status is unchanged.
-		if match.Match.Side == order.Maker {
-			checkStatus("maker swapped", match, order.MakerSwapCast)
-		} else {
-			checkStatus("taker swapped", match, order.TakerSwapCast)
+			t.Fatalf("%s: wrong status wanted %v, got %v", tag,
+				wantStatus, match.Match.Status)
 		}
 	}
 
-	rig := newTestRig()
-	dc := rig.dc
-	tCore := rig.core
-	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
-	dcrWallet.Unlock(wPW, time.Hour)
-
-	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
-	btcWallet.Unlock(wPW, time.Hour)
-
-	matchSize := 4 * tDCR.LotSize
-	qty := 3 * matchSize
-	rate := tBTC.RateStep * 10
-	lo, dbOrder, preImgL, addr := makeLimitOrder(dc, true, qty, tBTC.RateStep)
-	loid := lo.ID()
-	mid := ordertest.RandomMatchID()
-	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
-	if err != nil {
-		t.Fatalf("walletSet error: %v", err)
-	}
-	mkt := dc.market(tDcrBtcMktName)
-	tracker := newTrackedTrade(dbOrder, preImgL, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
-		rig.db, rig.queue, walletSet, nil, rig.core.notify)
-	rig.dc.trades[tracker.ID()] = tracker
-
-	// MAKER REFUND, INVALID TAKER COUNTERSWAP
-	//
-
+	// MAKER MATCH
 	matchTime := time.Now()
 	msgMatch := &msgjson.Match{
 		OrderID:    loid[:],
@@ -2509,15 +3273,15 @@ func TestRefunds(t *testing.T) {
 		Side:       uint8(order.Maker),
 		ServerTime: encode.UnixMilliU(matchTime),
 	}
-	swapID := encode.RandomBytes(36)
-	contract := encode.RandomBytes(36)
-	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: swapID, script: contract}}}
+	counterSwapID := encode.RandomBytes(36)
+	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: counterSwapID}}}
 	sign(tDexPriv, msgMatch)
 	msg, _ := msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
-	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
+	// queue an invalid DEX init ack
+	rig.ws.queueResponse(msgjson.InitRoute, invalidAcker)
 	err = handleMatchRoute(tCore, rig.dc, msg)
-	if err != nil {
-		t.Fatalf("match messages error: %v", err)
+	if err == nil {
+		t.Fatalf("no error for invalid server ack for init route")
 	}
 	match, found := tracker.matches[mid]
 	if !found {
@@ -2525,17 +3289,56 @@ func TestRefunds(t *testing.T) {
 	}
 
 	// We're the maker, so the init transaction should be broadcast.
-	checkStatus("maker swapped", match, order.MakerSwapCast)
-	proof := &match.MetaData.Proof
-	if !bytes.Equal(proof.Script, contract) {
-		t.Fatalf("invalid contract recorded for Maker swap")
+	checkStatus("maker swapped", order.MakerSwapCast)
+	_, metaData := match.Match, match.MetaData
+	proof, auth := &metaData.Proof, &metaData.Proof.Auth
+	if len(auth.MatchSig) == 0 {
+		t.Fatalf("no match sig recorded")
+	}
+	if !bytes.Equal(proof.MakerSwap, counterSwapID) {
+		t.Fatalf("receipt ID not recorded")
+	}
+	if len(proof.Secret) == 0 {
+		t.Fatalf("secret not set")
+	}
+	if len(proof.SecretHash) == 0 {
+		t.Fatalf("secret hash not set")
+	}
+	// auth.InitSig should be unset because our init request received
+	// an invalid ack
+	if len(auth.InitSig) != 0 {
+		t.Fatalf("init sig recorded for invalid init ack")
+	}
+
+	// requeue an invalid DEX init ack and re-send pending init request
+	rig.ws.queueResponse(msgjson.InitRoute, invalidAcker)
+	err = tracker.resendPendingRequests()
+	if err == nil {
+		t.Fatalf("no error for invalid server ack for resent init request")
+	}
+	// auth.InitSig should remain unset because our resent init request
+	// received an invalid ack still
+	if len(auth.InitSig) != 0 {
+		t.Fatalf("init sig recorded for second invalid init ack")
+	}
+
+	// queue a valid DEX init ack and re-send pending init request
+	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
+	err = tracker.resendPendingRequests()
+	if err != nil {
+		t.Fatalf("unexpected error for resent pending init request: %v", err)
+	}
+	// auth.InitSig should now be set because our init request received
+	// a valid ack
+	if len(auth.InitSig) == 0 {
+		t.Fatalf("init sig not recorded for valid init ack")
 	}
 
 	// Send the counter-party's init info.
 	auditQty := calc.BaseToQuote(rate, matchSize)
 	audit, auditInfo := tMsgAudit(loid, mid, addr, auditQty, proof.SecretHash)
+	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeTaker))
 	tBtcWallet.auditInfo = auditInfo
-	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
 	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
 
 	// Check audit errors.
@@ -2545,23 +3348,110 @@ func TestRefunds(t *testing.T) {
 		t.Fatalf("no maker error for AuditContract error")
 	}
 
-	// Attempt refund.
-	tDcrWallet.refundCoin = encode.RandomBytes(36)
-	tDcrWallet.refundErr = nil
-	tBtcWallet.refundCoin = nil
-	tBtcWallet.refundErr = fmt.Errorf("unexpected call to btcWallet.Refund")
-	checkRefund(tracker, match, matchSize)
+	// Check expiration error.
+	tBtcWallet.auditErr = asset.CoinNotFoundError
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for AuditContract expiration")
+	}
+	var errSet *errorSet
+	if !errors.As(err, &errSet) {
+		t.Fatalf("unexpected error type")
+	}
+	var expErr ExpirationErr
+	if !errors.As(errSet.errs[0], &expErr) {
+		t.Fatalf("wrong error type. expecting ExpirationTimeout, got %T: %v", errSet.errs[0], errSet.errs[0])
+	}
+	tBtcWallet.auditErr = nil
 
-	// TAKER REFUND, NO MAKER REDEEM
+	auditInfo.coin.val = auditQty - 1
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for low value")
+	}
+	auditInfo.coin.val = auditQty
+
+	auditInfo.secretHash = []byte{0x01}
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for wrong secret hash")
+	}
+	auditInfo.secretHash = proof.SecretHash
+
+	auditInfo.recipient = "wrong address"
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for wrong address")
+	}
+	auditInfo.recipient = addr
+
+	auditInfo.expiration = matchTime.Add(time.Hour * 23)
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for early lock time")
+	}
+	auditInfo.expiration = matchTime.Add(time.Hour * 24)
+
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("match message error: %v", err)
+	}
+	checkStatus("maker counter-party swapped", order.TakerSwapCast)
+	if match.counterSwap == nil {
+		t.Fatalf("counter-swap not set")
+	}
+	if !bytes.Equal(proof.CounterScript, audit.Contract) {
+		t.Fatalf("counter-script not recorded")
+	}
+	if !bytes.Equal(proof.TakerSwap, audit.CoinID) {
+		t.Fatalf("taker contract ID not set")
+	}
+	if !bytes.Equal(auth.AuditSig, audit.Sig) {
+		t.Fatalf("audit sig not set")
+	}
+	if auth.AuditStamp != audit.Time {
+		t.Fatalf("audit time not set")
+	}
+	// Confirming the counter-swap triggers a redemption.
+	auditInfo.coin.confs = tBTC.SwapConf
+	redeemCoin := encode.RandomBytes(36)
+	tBtcWallet.redeemCoins = []dex.Bytes{redeemCoin}
+	rig.ws.queueResponse(msgjson.RedeemRoute, redeemAcker)
+	dc.tickAsset(tBTC.ID)
+	checkStatus("maker redeemed", order.MakerRedeemed)
+	if !bytes.Equal(proof.MakerRedeem, redeemCoin) {
+		t.Fatalf("redeem coin ID not logged")
+	}
+	redemptionCoin := encode.RandomBytes(36)
+	// The taker's redemption is simply logged.
+	redemption := &msgjson.Redemption{
+		Redeem: msgjson.Redeem{
+			OrderID: loid[:],
+			MatchID: mid[:],
+			CoinID:  redemptionCoin,
+		},
+	}
+	msg, _ = msgjson.NewRequest(1, msgjson.RedemptionRoute, redemption)
+	err = handleRedemptionRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("redemption message error: %v", err)
+	}
+	checkStatus("maker match complete", order.MatchComplete)
+	if !bytes.Equal(redemptionCoin, proof.TakerRedeem) {
+		t.Fatalf("taker redemption coin not recorded")
+	}
+
+	// TAKER MATCH
 	//
 	mid = ordertest.RandomMatchID()
 	msgMatch = &msgjson.Match{
-		OrderID:  loid[:],
-		MatchID:  mid[:],
-		Quantity: matchSize,
-		Rate:     rate,
-		Address:  "counterparty-address",
-		Side:     uint8(order.Taker),
+		OrderID:    loid[:],
+		MatchID:    mid[:],
+		Quantity:   matchSize,
+		Rate:       rate,
+		Address:    "counterparty-address",
+		Side:       uint8(order.Taker),
+		ServerTime: encode.UnixMilliU(matchTime),
 	}
 	sign(tDexPriv, msgMatch)
 	msg, _ = msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
@@ -2573,844 +3463,5565 @@ func TestRefunds(t *testing.T) {
 	if !found {
 		t.Fatalf("match not found")
 	}
-	checkStatus("taker matched", match, order.NewlyMatched)
-	// Send through the audit request for the maker's init.
-	audit, auditInfo = tMsgAudit(loid, mid, addr, matchSize, nil)
-	tBtcWallet.auditInfo = auditInfo
-	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
-	tBtcWallet.auditErr = nil
-	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
-	err = handleAuditRoute(tCore, rig.dc, msg)
-	if err != nil {
+	checkStatus("taker matched", order.NewlyMatched)
+	_, metaData = match.Match, match.MetaData
+	proof, auth = &metaData.Proof, &metaData.Proof.Auth
+	if len(auth.MatchSig) == 0 {
+		t.Fatalf("no match sig recorded")
+	}
+	// Secret should not be set yet.
+	if len(proof.Secret) != 0 {
+		t.Fatalf("secret set for taker")
+	}
+	if len(proof.SecretHash) != 0 {
+		t.Fatalf("secret hash set for taker")
+	}
+	// Now send through the audit request for the maker's init.
+	audit, auditInfo = tMsgAudit(loid, mid, addr, matchSize, nil)
+	tBtcWallet.auditInfo = auditInfo
+	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
+	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err != nil {
 		t.Fatalf("taker's match message error: %v", err)
 	}
-	checkStatus("taker counter-party swapped", match, order.MakerSwapCast)
+
+	auditInfo.expiration = matchTime.Add(time.Hour * 47)
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no taker error for early lock time")
+	}
+	auditInfo.expiration = matchTime.Add(time.Hour * 48)
+
+	checkStatus("taker counter-party swapped", order.MakerSwapCast)
+	if len(proof.SecretHash) == 0 {
+		t.Fatalf("secret hash not set for taker")
+	}
+	if !bytes.Equal(proof.MakerSwap, audit.CoinID) {
+		t.Fatalf("maker redeem coin not set")
+	}
+	if !bytes.Equal(auth.AuditSig, audit.Sig) {
+		t.Fatalf("audit sig not set for taker")
+	}
+	if auth.AuditStamp != audit.Time {
+		t.Fatalf("audit time not set for taker")
+	}
+	// The swap should not be sent, since the auditInfo coin doesn't have the
+	// requisite confirmations.
+	if len(proof.TakerSwap) != 0 {
+		t.Fatalf("swap broadcast before confirmations")
+	}
+	// Now with the confirmations.
 	auditInfo.coin.confs = tBTC.SwapConf
-	counterSwapID := encode.RandomBytes(36)
-	counterScript := encode.RandomBytes(36)
-	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: counterSwapID, script: counterScript}}}
+	swapID := encode.RandomBytes(36)
+	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: swapID}}}
 	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
 	dc.tickAsset(tBTC.ID)
+	checkStatus("taker swapped", order.TakerSwapCast)
+	if len(proof.TakerSwap) == 0 {
+		t.Fatalf("swap not broadcast with confirmations")
+	}
+	// Receive the maker's redemption.
+	redemptionCoin = encode.RandomBytes(36)
+	redemption = &msgjson.Redemption{
+		Redeem: msgjson.Redeem{
+			OrderID: loid[:],
+			MatchID: mid[:],
+			CoinID:  redemptionCoin,
+		},
+	}
+	redeemCoin = encode.RandomBytes(36)
+	tBtcWallet.redeemCoins = []dex.Bytes{redeemCoin}
+	msg, _ = msgjson.NewRequest(1, msgjson.RedemptionRoute, redemption)
 
-	checkStatus("taker swapped", match, order.TakerSwapCast)
-	if !bytes.Equal(match.MetaData.Proof.Script, counterScript) {
-		t.Fatalf("invalid contract recorded for Taker swap")
+	tBtcWallet.badSecret = true
+	err = handleRedemptionRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no error for wrong secret")
+	}
+	tBtcWallet.badSecret = false
+
+	rig.ws.queueResponse(msgjson.RedeemRoute, redeemAcker)
+	err = handleRedemptionRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("redemption message error: %v", err)
+	}
+	checkStatus("taker complete", order.MatchComplete)
+	if !bytes.Equal(proof.MakerRedeem, redemptionCoin) {
+		t.Fatalf("redemption coin ID not logged")
+	}
+	if len(proof.TakerRedeem) == 0 {
+		t.Fatalf("taker redemption not sent")
+	}
+
+	// CANCEL ORDER MATCH
+	//
+	copy(mid[:], encode.RandomBytes(32))
+	preImgC := newPreimage()
+	co := &order.CancelOrder{
+		P: order.Prefix{
+			AccountID:  dc.acct.ID(),
+			BaseAsset:  tDCR.ID,
+			QuoteAsset: tBTC.ID,
+			OrderType:  order.MarketOrderType,
+			ClientTime: time.Now(),
+			ServerTime: time.Now().Add(time.Millisecond),
+			Commit:     preImgC.Commit(),
+		},
+	}
+	tracker.cancel = &trackedCancel{CancelOrder: *co}
+	coid := co.ID()
+	m1 := &msgjson.Match{
+		OrderID:  loid[:],
+		MatchID:  mid[:],
+		Quantity: cancelledQty,
+		Rate:     rate,
+		Address:  "",
+	}
+	m2 := &msgjson.Match{
+		OrderID:  coid[:],
+		MatchID:  mid[:],
+		Quantity: cancelledQty,
+		Rate:     rate,
+		Address:  "testaddr",
+	}
+	sign(tDexPriv, m1)
+	sign(tDexPriv, m2)
+	msg, _ = msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{m1, m2})
+	err = handleMatchRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("match messages error: %v", err)
+	}
+	if tracker.cancel.matches.maker == nil {
+		t.Fatalf("cancelMatches.maker not set")
+	}
+	if tracker.Trade().Filled() != qty {
+		t.Fatalf("fill not set")
+	}
+	if tracker.cancel.matches.taker == nil {
+		t.Fatalf("cancelMatches.taker not set")
+	}
+}
+
+func TestRefunds(t *testing.T) {
+	checkStatus := func(tag string, match *matchTracker, wantStatus order.MatchStatus) {
+		t.Helper()
+		if match.Match.Status != wantStatus {
+			t.Fatalf("%s: wrong status wanted %d, got %d", tag, match.Match.Status, wantStatus)
+		}
+	}
+	checkRefund := func(tracker *trackedTrade, match *matchTracker, expectAmt uint64) {
+		t.Helper()
+		// Confirm that the status is SwapCast.
+		if match.Match.Side == order.Maker {
+			checkStatus("maker swapped", match, order.MakerSwapCast)
+		} else {
+			checkStatus("taker swapped", match, order.TakerSwapCast)
+		}
+		// Confirm isRefundable = true.
+		if !tracker.isRefundable(match) {
+			t.Fatalf("%s's swap not refundable", match.Match.Side)
+		}
+		// Check refund.
+		amtRefunded, err := tracker.refundMatches([]*matchTracker{match})
+		if err != nil {
+			t.Fatalf("unexpected refund error %v", err)
+		}
+		// Check refunded amount.
+		if amtRefunded != expectAmt {
+			t.Fatalf("expected %d refund amount, got %d", expectAmt, amtRefunded)
+		}
+		// Confirm isRefundable = false.
+		if tracker.isRefundable(match) {
+			t.Fatalf("%s's swap refundable after being refunded", match.Match.Side)
+		}
+		// Expect refund re-attempt to not refund any coin.
+		amtRefunded, err = tracker.refundMatches([]*matchTracker{match})
+		if err != nil {
+			t.Fatalf("unexpected refund error %v", err)
+		}
+		if amtRefunded != 0 {
+			t.Fatalf("expected 0 refund amount, got %d", amtRefunded)
+		}
+		// Confirm that the status is unchanged.
+		if match.Match.Side == order.Maker {
+			checkStatus("maker swapped", match, order.MakerSwapCast)
+		} else {
+			checkStatus("taker swapped", match, order.TakerSwapCast)
+		}
+	}
+
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	dcrWallet.Unlock(wPW, time.Hour)
+
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet.Unlock(wPW, time.Hour)
+
+	matchSize := 4 * tDCR.LotSize
+	qty := 3 * matchSize
+	rate := tBTC.RateStep * 10
+	lo, dbOrder, preImgL, addr := makeLimitOrder(dc, true, qty, tBTC.RateStep)
+	loid := lo.ID()
+	mid := ordertest.RandomMatchID()
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImgL, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	rig.dc.trades[tracker.ID()] = tracker
+
+	// MAKER REFUND, INVALID TAKER COUNTERSWAP
+	//
+
+	matchTime := time.Now()
+	msgMatch := &msgjson.Match{
+		OrderID:    loid[:],
+		MatchID:    mid[:],
+		Quantity:   matchSize,
+		Rate:       rate,
+		Address:    "counterparty-address",
+		Side:       uint8(order.Maker),
+		ServerTime: encode.UnixMilliU(matchTime),
+	}
+	swapID := encode.RandomBytes(36)
+	contract := encode.RandomBytes(36)
+	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: swapID, script: contract}}}
+	sign(tDexPriv, msgMatch)
+	msg, _ := msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
+	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
+	err = handleMatchRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("match messages error: %v", err)
+	}
+	match, found := tracker.matches[mid]
+	if !found {
+		t.Fatalf("match not found")
+	}
+
+	// We're the maker, so the init transaction should be broadcast.
+	checkStatus("maker swapped", match, order.MakerSwapCast)
+	proof := &match.MetaData.Proof
+	if !bytes.Equal(proof.Script, contract) {
+		t.Fatalf("invalid contract recorded for Maker swap")
+	}
+
+	// Send the counter-party's init info.
+	auditQty := calc.BaseToQuote(rate, matchSize)
+	audit, auditInfo := tMsgAudit(loid, mid, addr, auditQty, proof.SecretHash)
+	tBtcWallet.auditInfo = auditInfo
+	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
+	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
+
+	// Check audit errors.
+	tBtcWallet.auditErr = tErr
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err == nil {
+		t.Fatalf("no maker error for AuditContract error")
+	}
+
+	// Attempt refund.
+	tDcrWallet.refundCoin = encode.RandomBytes(36)
+	tDcrWallet.refundErr = nil
+	tBtcWallet.refundCoin = nil
+	tBtcWallet.refundErr = fmt.Errorf("unexpected call to btcWallet.Refund")
+	checkRefund(tracker, match, matchSize)
+
+	// TAKER REFUND, NO MAKER REDEEM
+	//
+	mid = ordertest.RandomMatchID()
+	msgMatch = &msgjson.Match{
+		OrderID:  loid[:],
+		MatchID:  mid[:],
+		Quantity: matchSize,
+		Rate:     rate,
+		Address:  "counterparty-address",
+		Side:     uint8(order.Taker),
+	}
+	sign(tDexPriv, msgMatch)
+	msg, _ = msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
+	err = handleMatchRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("match messages error: %v", err)
+	}
+	match, found = tracker.matches[mid]
+	if !found {
+		t.Fatalf("match not found")
+	}
+	checkStatus("taker matched", match, order.NewlyMatched)
+	// Send through the audit request for the maker's init.
+	audit, auditInfo = tMsgAudit(loid, mid, addr, matchSize, nil)
+	tBtcWallet.auditInfo = auditInfo
+	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
+	tBtcWallet.auditErr = nil
+	msg, _ = msgjson.NewRequest(1, msgjson.AuditRoute, audit)
+	err = handleAuditRoute(tCore, rig.dc, msg)
+	if err != nil {
+		t.Fatalf("taker's match message error: %v", err)
+	}
+	checkStatus("taker counter-party swapped", match, order.MakerSwapCast)
+	auditInfo.coin.confs = tBTC.SwapConf
+	counterSwapID := encode.RandomBytes(36)
+	counterScript := encode.RandomBytes(36)
+	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: counterSwapID, script: counterScript}}}
+	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
+	dc.tickAsset(tBTC.ID)
+
+	checkStatus("taker swapped", match, order.TakerSwapCast)
+	if !bytes.Equal(match.MetaData.Proof.Script, counterScript) {
+		t.Fatalf("invalid contract recorded for Taker swap")
+	}
+
+	// Attempt refund.
+	checkRefund(tracker, match, matchSize)
+}
+
+func TestAutoRefund(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	notes := tCore.NotificationFeed()
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	dcrWallet.Unlock(wPW, time.Hour)
+
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet.Unlock(wPW, time.Hour)
+
+	// Automatic refunds are enabled by default.
+	if !tCore.AutoRefund() {
+		t.Fatalf("automatic refunds not enabled by default")
+	}
+	rig.db.storeErr = tErr
+	if err := tCore.SetAutoRefund(false); err == nil {
+		t.Fatalf("no error for store error")
+	}
+	if !tCore.AutoRefund() {
+		t.Fatalf("setting changed despite store error")
+	}
+	rig.db.storeErr = nil
+	if err := tCore.SetAutoRefund(false); err != nil {
+		t.Fatalf("SetAutoRefund error: %v", err)
+	}
+	if tCore.AutoRefund() {
+		t.Fatalf("automatic refunds not disabled")
+	}
+	// The setting is loaded from the database.
+	tCore.noAutoRefund = false
+	tCore.loadAutoRefund()
+	if tCore.AutoRefund() {
+		t.Fatalf("disabled setting not loaded")
+	}
+
+	waitNote := func(subject string) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case n := <-notes:
+				if n.Subject() == subject {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no %q notification", subject)
+			}
+		}
+	}
+
+	matchSize := 4 * tDCR.LotSize
+	qty := 3 * matchSize
+	rate := tBTC.RateStep * 10
+	lo, dbOrder, preImgL, _ := makeLimitOrder(dc, true, qty, tBTC.RateStep)
+	loid := lo.ID()
+	mid := ordertest.RandomMatchID()
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImgL, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	tracker.autoRefund = tCore.autoRefundEnabled
+	rig.dc.trades[tracker.ID()] = tracker
+
+	msgMatch := &msgjson.Match{
+		OrderID:    loid[:],
+		MatchID:    mid[:],
+		Quantity:   matchSize,
+		Rate:       rate,
+		Address:    "counterparty-address",
+		Side:       uint8(order.Maker),
+		ServerTime: encode.UnixMilliU(time.Now()),
+	}
+	tDcrWallet.swapReceipts = []asset.Receipt{&tReceipt{coin: &tCoin{id: encode.RandomBytes(36), script: encode.RandomBytes(36)}}}
+	sign(tDexPriv, msgMatch)
+	msg, _ := msgjson.NewRequest(1, msgjson.MatchRoute, []*msgjson.Match{msgMatch})
+	rig.ws.queueResponse(msgjson.InitRoute, initAcker)
+	if err := handleMatchRoute(tCore, rig.dc, msg); err != nil {
+		t.Fatalf("match messages error: %v", err)
+	}
+	match, found := tracker.matches[mid]
+	if !found {
+		t.Fatalf("match not found")
+	}
+	if match.Match.Status != order.MakerSwapCast {
+		t.Fatalf("swap not sent")
+	}
+
+	// With automatic refunds disabled, the expired swap is not refunded, and
+	// the user is notified instead.
+	tDcrWallet.refundCoin = encode.RandomBytes(36)
+	tDcrWallet.refundErr = nil
+	tDcrWallet.confs = 0
+	if _, err := tracker.tick(); err != nil {
+		t.Fatalf("tick error: %v", err)
+	}
+	if len(match.MetaData.Proof.RefundCoin) != 0 {
+		t.Fatalf("swap refunded with automatic refunds disabled")
+	}
+	waitNote("Refund available")
+
+	// The swap is refunded manually.
+	if _, err := tCore.RefundSwap(tPW, "abc"); err == nil {
+		t.Fatalf("no error for bad match ID")
+	}
+	rig.db.activeMatches = []*db.MetaMatch{&match.MetaMatch}
+	tDcrWallet.notExpired = true
+	if _, err := tCore.RefundSwap(tPW, mid.String()); err == nil {
+		t.Fatalf("no error for unexpired contract")
+	}
+	tDcrWallet.notExpired = false
+	if _, err := tCore.RefundSwap(tPW, mid.String()); err != nil {
+		t.Fatalf("RefundSwap error: %v", err)
+	}
+	if !bytes.Equal(match.MetaData.Proof.RefundCoin, tDcrWallet.refundCoin) {
+		t.Fatalf("refund not recorded")
+	}
+	if _, err := tCore.RefundSwap(tPW, mid.String()); err == nil {
+		t.Fatalf("no error for refunding twice")
+	}
+
+	// The refund is confirmed on a later tick.
+	if _, err := tracker.tick(); err != nil {
+		t.Fatalf("tick error: %v", err)
+	}
+	if match.refundConfirmed {
+		t.Fatalf("unconfirmed refund marked confirmed")
+	}
+	tDcrWallet.confs = 1
+	if _, err := tracker.tick(); err != nil {
+		t.Fatalf("tick error: %v", err)
+	}
+	if !match.refundConfirmed {
+		t.Fatalf("refund not confirmed")
+	}
+	waitNote("Refund confirmed")
+}
+
+func TestNotifications(t *testing.T) {
+	tCore := newTestRig().core
+
+	// Insert a notification into the database.
+	typedNote := newOrderNote(TopicMatchRevoked, 100, nil, "def")
+
+	ch := tCore.NotificationFeed()
+	tCore.notify(typedNote)
+	select {
+	case n := <-ch:
+		dbtest.MustCompareNotifications(t, n.DBNote(), &typedNote.Notification)
+	default:
+		t.Fatalf("no notification received over the notification channel")
+	}
+}
+
+func TestLocales(t *testing.T) {
+	for tag, want := range map[string]string{
+		"en-US": "en-US",
+		"EN-gb": "en-US",
+		"es":    "es-ES",
+		"es-MX": "es-ES",
+	} {
+		lang, found := MatchLocale(tag)
+		if !found || lang != want {
+			t.Fatalf("MatchLocale(%q) = %q, %v, wanted %q", tag, lang, found, want)
+		}
+	}
+	if _, found := MatchLocale("fr"); found {
+		t.Fatalf("matched an unsupported language")
+	}
+
+	// Order sides are rendered in the locale's words.
+	note := newOrderNote(TopicOrderCanceled, db.Success, nil, side{true, true}, "dcr", "btc", "host", "abc")
+	if note.Subject() != "Order canceled" || note.Details() != "Sell order on dcr-btc at host has been canceled (abc)" {
+		t.Fatalf("wrong origin note: %q, %q", note.Subject(), note.Details())
+	}
+	subject, details := TranslateNote(note, "es")
+	if subject != "Orden cancelada" || details != "Se ha cancelado la orden de Venta en dcr-btc en host (abc)" {
+		t.Fatalf("wrong translated note: %q, %q", subject, details)
+	}
+	// Unsupported languages are rendered in the origin language.
+	if subject, _ = TranslateNote(note, "fr"); subject != note.Subject() {
+		t.Fatalf("wrong subject for an unsupported language: %q", subject)
+	}
+	// Indexed arguments in the subject.
+	note = newOrderNote(TopicPaperOrderFinished, db.Poke, nil, "abc", "dcr_btc", order.OrderStatusCanceled)
+	if note.Subject() != "Paper order canceled" {
+		t.Fatalf("wrong subject %q", note.Subject())
+	}
+
+	err := newError(dupeDEXErr, "already registered at %s", "host")
+	if msg := TranslateError(fmt.Errorf("register: %w", err), "es-ES"); msg != "register: ya está registrado en host" {
+		t.Fatalf("wrong translated error %q", msg)
+	}
+	if msg := TranslateError(err, "en-US"); msg != err.Error() {
+		t.Fatalf("wrong origin error %q", msg)
+	}
+
+	// The Core renders notifications in its language.
+	tCore := newTestRig().core
+	tCore.locale = esLocale
+	ch := tCore.NotificationFeed()
+	tCore.notify(newOrderNote(TopicMatchRevoked, db.WarningLevel, nil, "abc"))
+	n := <-ch
+	if n.Subject() != "Emparejamiento revocado" || n.Details() != "Se ha revocado el emparejamiento abc" {
+		t.Fatalf("wrong Core note: %q, %q", n.Subject(), n.Details())
+	}
+}
+
+func TestResolveActiveTrades(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+
+	rig.acct.auth() // Short path through initializeDEXConnections
+
+	// Create an order
+	qty := tDCR.LotSize * 5
+	rate := tDCR.RateStep * 5
+	lo := &order.LimitOrder{
+		P: order.Prefix{
+			OrderType:  order.LimitOrderType,
+			BaseAsset:  tDCR.ID,
+			QuoteAsset: tBTC.ID,
+			ClientTime: time.Now(),
+			ServerTime: time.Now(),
+			Commit:     ordertest.RandomCommitment(),
+		},
+		T: order.Trade{
+			Quantity: qty,
+			Sell:     true,
+		},
+		Rate: rate,
+	}
+
+	oid := lo.ID()
+	changeCoinID := encode.RandomBytes(32)
+	changeCoin := &tCoin{id: changeCoinID}
+
+	// Need to return an order from db.ActiveDEXOrders
+	rig.db.activeDEXOrders = []*db.MetaOrder{
+		{
+			MetaData: &db.OrderMetaData{
+				Status:     order.OrderStatusBooked,
+				Host:       tDexHost,
+				Proof:      db.OrderProof{},
+				ChangeCoin: changeCoinID,
+			},
+			Order: lo,
+		},
+	}
+
+	mid := ordertest.RandomMatchID()
+	addr := ordertest.RandomAddress()
+	match := &db.MetaMatch{
+		MetaData: &db.MatchMetaData{
+			Status: order.MakerSwapCast,
+			Proof: db.MatchProof{
+				CounterScript: encode.RandomBytes(50),
+				SecretHash:    encode.RandomBytes(32),
+				MakerSwap:     encode.RandomBytes(32),
+				Auth: db.MatchAuth{
+					MatchSig: encode.RandomBytes(32),
+				},
+			},
+			DEX:   tDexHost,
+			Base:  tDCR.ID,
+			Quote: tBTC.ID,
+		},
+		Match: &order.UserMatch{
+			OrderID:  oid,
+			MatchID:  mid,
+			Quantity: qty,
+			Rate:     rate,
+			Address:  addr,
+			Status:   order.MakerSwapCast,
+			Side:     order.Taker,
+		},
+	}
+	rig.db.activeMatchesForDEX = []*db.MetaMatch{match}
+	tDcrWallet.fundingCoins = asset.Coins{changeCoin}
+	_, auditInfo := tMsgAudit(oid, mid, addr, qty, nil)
+	tBtcWallet.auditInfo = auditInfo
+
+	// reset
+	reset := func() {
+		rig.acct.lock()
+		dcrWallet.Lock()
+		btcWallet.Lock()
+		rig.dc.trades = make(map[order.OrderID]*trackedTrade)
+	}
+
+	// Ensure the order is good, and reset the state.
+	ensureGood := func(tag string) {
+		t.Helper()
+		_, err := tCore.Login(tPW)
+		if err != nil {
+			t.Fatalf("%s: login error: %v", tag, err)
+		}
+
+		if !btcWallet.unlocked() {
+			t.Fatalf("%s: btc wallet not unlocked", tag)
+		}
+
+		if !dcrWallet.unlocked() {
+			t.Fatalf("%s: dcr wallet not unlocked", tag)
+		}
+
+		trade, found := rig.dc.trades[oid]
+		if !found {
+			t.Fatalf("%s: trade with expected order id not found. len(trades) = %d", tag, len(rig.dc.trades))
+		}
+
+		_, found = trade.matches[mid]
+		if !found {
+			t.Fatalf("%s: trade with expected order id not found. len(matches) = %d", tag, len(trade.matches))
+		}
+		reset()
+	}
+
+	ensureGood("initial")
+
+	// Ensure a failure AND reset. err != nil just helps to make sure that we're
+	// hitting errors in resolveActiveTrades, which sends errors as
+	// notifications, vs somewhere else.
+	ensureFail := func(tag string) {
+		t.Helper()
+		_, err := tCore.Login(tPW)
+		if err != nil || len(rig.dc.trades) != 0 {
+			t.Fatalf("%s: no error. err = %v, len(trades) = %d", tag, err, len(rig.dc.trades))
+		}
+		reset()
+	}
+
+	// No base wallet
+	delete(tCore.wallets, db.WalletSID(tDCR.ID, ""))
+	ensureFail("missing base")
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+
+	// Base wallet unlock errors
+	tDcrWallet.unlockErr = tErr
+	ensureFail("base unlock")
+	tDcrWallet.unlockErr = nil
+
+	// No quote wallet
+	delete(tCore.wallets, db.WalletSID(tBTC.ID, ""))
+	ensureFail("missing quote")
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	// Quote wallet unlock errors
+	tBtcWallet.unlockErr = tErr
+	ensureFail("quote unlock")
+	tBtcWallet.unlockErr = nil
+
+	// Funding coin error.
+	tDcrWallet.fundingCoinErr = tErr
+	ensureFail("funding coin")
+	tDcrWallet.fundingCoinErr = nil
+
+	// No matches
+	rig.db.activeMatchesForDEXErr = tErr
+	ensureFail("matches error")
+	rig.db.activeMatchesForDEXErr = nil
+
+	// Success again
+	ensureGood("final")
+}
+
+func TestReadConnectMatches(t *testing.T) {
+	rig := newTestRig()
+	preImg := newPreimage()
+	dc := rig.dc
+
+	notes := make(map[string][]Notification)
+	notify := func(note Notification) {
+		notes[note.Type()] = append(notes[note.Type()], note)
+	}
+
+	lo := &order.LimitOrder{
+		P: order.Prefix{
+			// 	OrderType:  order.LimitOrderType,
+			// 	BaseAsset:  tDCR.ID,
+			// 	QuoteAsset: tBTC.ID,
+			// 	ClientTime: time.Now(),
+			ServerTime: time.Now(),
+			// 	Commit:     preImg.Commit(),
+		},
+	}
+	oid := lo.ID()
+	dbOrder := &db.MetaOrder{
+		MetaData: &db.OrderMetaData{},
+		Order:    lo,
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, notify)
+	metaMatch := db.MetaMatch{
+		MetaData: &db.MatchMetaData{},
+		Match:    &order.UserMatch{},
+	}
+
+	// Store a match
+	knownID := ordertest.RandomMatchID()
+	knownMatch := &matchTracker{
+		id:        knownID,
+		MetaMatch: metaMatch,
+	}
+	tracker.matches[knownID] = knownMatch
+	knownMsgMatch := &msgjson.Match{OrderID: oid[:], MatchID: knownID[:]}
+
+	missingID := ordertest.RandomMatchID()
+	missingMatch := &matchTracker{
+		id:        missingID,
+		MetaMatch: metaMatch,
+	}
+	tracker.matches[missingID] = missingMatch
+
+	extraID := ordertest.RandomMatchID()
+	extraMsgMatch := &msgjson.Match{OrderID: oid[:], MatchID: extraID[:]}
+
+	matches := []*msgjson.Match{knownMsgMatch, extraMsgMatch}
+	tracker.readConnectMatches(matches)
+
+	if knownMatch.failErr != nil {
+		t.Fatalf("error set for known and reported match")
+	}
+
+	if missingMatch.failErr == nil {
+		t.Fatalf("error not set for missing match")
+	}
+
+	if len(notes["order"]) != 2 {
+		t.Fatalf("expected 2 core 'order'-type notifications, got %d", len(notes["order"]))
+	}
+
+	if notes["order"][0].Subject() != "Missing matches" {
+		t.Fatalf("no core notification sent for missing matches")
+	}
+
+	if notes["order"][1].Subject() != "Match resolution error" {
+		t.Fatalf("no core notification sent for unknown matches")
+	}
+
+}
+
+func convertMsgLimitOrder(msgOrder *msgjson.LimitOrder) *order.LimitOrder {
+	tif := order.ImmediateTiF
+	if msgOrder.TiF == msgjson.StandingOrderNum {
+		tif = order.StandingTiF
+	}
+	return &order.LimitOrder{
+		P:       convertMsgPrefix(&msgOrder.Prefix, order.LimitOrderType),
+		T:       convertMsgTrade(&msgOrder.Trade),
+		Rate:    msgOrder.Rate,
+		Force:   tif,
+		Visible: msgOrder.Visible,
+	}
+}
+
+func convertMsgMarketOrder(msgOrder *msgjson.MarketOrder) *order.MarketOrder {
+	return &order.MarketOrder{
+		P: convertMsgPrefix(&msgOrder.Prefix, order.MarketOrderType),
+		T: convertMsgTrade(&msgOrder.Trade),
+	}
+}
+
+func convertMsgCancelOrder(msgOrder *msgjson.CancelOrder) *order.CancelOrder {
+	var oid order.OrderID
+	copy(oid[:], msgOrder.TargetID)
+	return &order.CancelOrder{
+		P:             convertMsgPrefix(&msgOrder.Prefix, order.CancelOrderType),
+		TargetOrderID: oid,
+	}
+}
+
+func convertMsgPrefix(msgPrefix *msgjson.Prefix, oType order.OrderType) order.Prefix {
+	var commit order.Commitment
+	copy(commit[:], msgPrefix.Commit)
+	var acctID account.AccountID
+	copy(acctID[:], msgPrefix.AccountID)
+	return order.Prefix{
+		AccountID:  acctID,
+		BaseAsset:  msgPrefix.Base,
+		QuoteAsset: msgPrefix.Quote,
+		OrderType:  oType,
+		ClientTime: encode.UnixTimeMilli(int64(msgPrefix.ClientTime)),
+		//ServerTime set in epoch queue processing pipeline.
+		Commit: commit,
+	}
+}
+
+func convertMsgTrade(msgTrade *msgjson.Trade) order.Trade {
+	coins := make([]order.CoinID, 0, len(msgTrade.Coins))
+	for _, coin := range msgTrade.Coins {
+		var b []byte = coin.ID
+		coins = append(coins, b)
+	}
+	sell := true
+	if msgTrade.Side == msgjson.BuyOrderNum {
+		sell = false
+	}
+	return order.Trade{
+		Coins:    coins,
+		Sell:     sell,
+		Quantity: msgTrade.Quantity,
+		Address:  msgTrade.Address,
+	}
+}
+
+func orderResponse(msgID uint64, msgPrefix msgjson.Stampable, ord order.Order, badSig, noID, badID bool) *msgjson.Message {
+	orderTime := time.Now()
+	timeStamp := encode.UnixMilliU(orderTime)
+	msgPrefix.Stamp(timeStamp)
+	sign(tDexPriv, msgPrefix)
+	if badSig {
+		msgPrefix.SetSig(encode.RandomBytes(5))
+	}
+	ord.SetTime(orderTime)
+	oid := ord.ID()
+	oidB := oid[:]
+	if noID {
+		oidB = nil
+	} else if badID {
+		oidB = encode.RandomBytes(32)
+	}
+	resp, _ := msgjson.NewResponse(msgID, &msgjson.OrderResult{
+		Sig:        msgPrefix.SigBytes(),
+		OrderID:    oidB,
+		ServerTime: timeStamp,
+	}, nil)
+	return resp
+}
+
+func tMsgAudit(oid order.OrderID, mid order.MatchID, recipient string, val uint64, secretHash []byte) (*msgjson.Audit, *tAuditInfo) {
+	auditID := encode.RandomBytes(36)
+	auditContract := encode.RandomBytes(75)
+	if secretHash == nil {
+		secretHash = encode.RandomBytes(32)
+	}
+	auditStamp := encode.UnixMilliU(time.Now())
+	audit := &msgjson.Audit{
+		OrderID:  oid[:],
+		MatchID:  mid[:],
+		Time:     auditStamp,
+		CoinID:   auditID,
+		Contract: auditContract,
+	}
+	sign(tDexPriv, audit)
+	auditCoin := &tCoin{id: auditID, val: val}
+	auditInfo := &tAuditInfo{
+		recipient:  recipient,
+		coin:       auditCoin,
+		secretHash: secretHash,
+	}
+	return audit, auditInfo
+}
+
+func TestHandleEpochOrderMsg(t *testing.T) {
+	rig := newTestRig()
+	ord := &order.LimitOrder{P: order.Prefix{ServerTime: time.Now()}}
+	oid := ord.ID()
+	payload := &msgjson.EpochOrderNote{
+		BookOrderNote: msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  oid.Bytes(),
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     msgjson.BuyOrderNum,
+				Rate:     4,
+				Quantity: 10,
+			},
+		},
+		Epoch: 1,
+	}
+
+	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.EpochOrderRoute, payload)
+
+	// Ensure handling an epoch order associated with a non-existent orderbook
+	// generates an error.
+	err := handleEpochOrderMsg(rig.core, rig.dc, req)
+	if err == nil {
+		t.Fatal("[handleEpochOrderMsg] expected a non-existent orderbook error")
+	}
+
+	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
+
+	err = handleEpochOrderMsg(rig.core, rig.dc, req)
+	if err != nil {
+		t.Fatalf("[handleEpochOrderMsg] unexpected error: %v", err)
+	}
+}
+
+func makeMatchProof(preimages []order.Preimage, commitments []order.Commitment) (msgjson.Bytes, msgjson.Bytes, error) {
+	if len(preimages) != len(commitments) {
+		return nil, nil, fmt.Errorf("expected equal number of preimages and commitments")
+	}
+
+	sbuff := make([]byte, 0, len(preimages)*order.PreimageSize)
+	cbuff := make([]byte, 0, len(commitments)*order.CommitmentSize)
+	for i := 0; i < len(preimages); i++ {
+		sbuff = append(sbuff, preimages[i][:]...)
+		cbuff = append(cbuff, commitments[i][:]...)
+	}
+	seed := blake256.Sum256(sbuff)
+	csum := blake256.Sum256(cbuff)
+	return seed[:], csum[:], nil
+}
+
+func TestHandleMatchProofMsg(t *testing.T) {
+	rig := newTestRig()
+	pimg := newPreimage()
+	cmt := pimg.Commit()
+
+	seed, csum, err := makeMatchProof([]order.Preimage{pimg}, []order.Commitment{cmt})
+	if err != nil {
+		t.Fatalf("[makeMatchProof] unexpected error: %v", err)
+	}
+
+	payload := &msgjson.MatchProofNote{
+		MarketID:  tDcrBtcMktName,
+		Epoch:     1,
+		Preimages: []dex.Bytes{pimg[:]},
+		CSum:      csum[:],
+		Seed:      seed[:],
+	}
+
+	eo := &msgjson.EpochOrderNote{
+		BookOrderNote: msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  encode.RandomBytes(order.OrderIDSize),
+			},
+		},
+		Epoch:  1,
+		Commit: cmt[:],
+	}
+
+	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.MatchProofRoute, payload)
+
+	// Ensure match proof validation generates an error for a non-existent
+	// orderbook generates an error.
+	err = handleMatchProofMsg(rig.core, rig.dc, req)
+	if err == nil {
+		t.Fatal("[handleMatchProofMsg] expected a non-existent orderbook error")
+	}
+
+	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
+
+	err = rig.dc.books[tDcrBtcMktName].Enqueue(eo)
+	if err != nil {
+		t.Fatalf("[Enqueue] unexpected error: %v", err)
+	}
+
+	err = handleMatchProofMsg(rig.core, rig.dc, req)
+	if err != nil {
+		t.Fatalf("[handleMatchProofMsg] unexpected error: %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	ord := &order.LimitOrder{P: order.Prefix{ServerTime: time.Now()}}
+	tracker := &trackedTrade{
+		Order:  ord,
+		preImg: newPreimage(),
+		dc:     rig.dc,
+		metaData: &db.OrderMetaData{
+			Status: order.OrderStatusBooked,
+		},
+		matches: make(map[order.MatchID]*matchTracker),
+	}
+	rig.dc.trades[ord.ID()] = tracker
+
+	dc, _, _ := testDexConnection()
+	initUserAssets := func() {
+		tCore.user = new(User)
+		tCore.user.Assets = make(map[uint32]*SupportedAsset, len(dc.assets))
+		for assetsID := range dc.assets {
+			tCore.user.Assets[assetsID] = &SupportedAsset{
+				ID: assetsID,
+			}
+		}
+	}
+
+	ensureErr := func(tag string) {
+		t.Helper()
+		initUserAssets()
+		err := tCore.Logout()
+		if err == nil {
+			t.Fatalf("%s: no error", tag)
+		}
+	}
+
+	// Active orders error.
+	ensureErr("active orders")
+
+	tracker.metaData = &db.OrderMetaData{
+		Status: order.OrderStatusExecuted,
+	}
+	mid := ordertest.RandomMatchID()
+	tracker.matches[mid] = &matchTracker{
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Status: order.NewlyMatched,
+			},
+		},
+	}
+	// Active orders with matches error.
+	ensureErr("active orders matches")
+	rig.dc.trades = nil
+
+	// Lock wallet error.
+	tDcrWallet.lockErr = tErr
+	ensureErr("lock wallet")
+	tDcrWallet.lockErr = nil
+
+	// Success returns to read-only mode.
+	tCore.loggedIn = true
+	initUserAssets()
+	if err := tCore.Logout(); err != nil {
+		t.Fatalf("Logout error: %v", err)
+	}
+	if tCore.LoggedIn() {
+		t.Fatalf("still logged in after Logout")
+	}
+}
+
+func TestSetEpoch(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 0, 0)
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, rig.core.notify)
+	dc.trades[lo.ID()] = tracker
+	metaData := tracker.metaData
+
+	payload := &msgjson.MatchProofNote{
+		MarketID: tDcrBtcMktName,
+		Epoch:    uint64(tracker.Time()) / tracker.epochLen,
+	}
+	nextReq := func() *msgjson.Message {
+		payload.Epoch++
+		req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.MatchProofRoute, payload)
+		return req
+	}
+
+	ensureStatus := func(tag string, status order.OrderStatus) {
+		t.Helper()
+		err := handleMatchProofMsg(rig.core, rig.dc, nextReq())
+		if err != nil {
+			t.Fatalf("error setting epoch for %s: %v", tag, err)
+		}
+		if metaData.Status != status {
+			t.Fatalf("wrong status for %s. expected %s, got %s", tag, status, metaData.Status)
+		}
+		corder, _ := tracker.coreOrderInternal()
+		if corder.Status != status {
+			t.Fatalf("wrong core order status for %s. expected %s, got %s", tag, status, corder.Status)
+		}
+	}
+
+	// Immediate limit order
+	ensureStatus("immediate limit order", order.OrderStatusExecuted)
+
+	// Standing limit order
+	metaData.Status = order.OrderStatusEpoch
+	lo.Force = order.StandingTiF
+	ensureStatus("standing limit order", order.OrderStatusBooked)
+
+	// Market order
+	mo := &order.MarketOrder{
+		P: lo.P,
+		T: *lo.T.Copy(),
+	}
+	mo.P.OrderType = order.LimitOrderType
+	dbOrder = &db.MetaOrder{
+		MetaData: &db.OrderMetaData{
+			Status: order.OrderStatusEpoch,
+		},
+		Order: mo,
+	}
+	tracker = newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, rig.core.notify)
+	metaData = tracker.metaData
+	dc.trades[mo.ID()] = tracker
+	ensureStatus("market order", order.OrderStatusExecuted)
+
+	// Same epoch shouldn't result in change.
+	payload.Epoch--
+	metaData.Status = order.OrderStatusEpoch
+	ensureStatus("market order unchanged", order.OrderStatusEpoch)
+
+}
+
+func makeLimitOrder(dc *dexConnection, sell bool, qty, rate uint64) (*order.LimitOrder, *db.MetaOrder, order.Preimage, string) {
+	preImg := newPreimage()
+	addr := ordertest.RandomAddress()
+	lo := &order.LimitOrder{
+		P: order.Prefix{
+			AccountID:  dc.acct.ID(),
+			BaseAsset:  tDCR.ID,
+			QuoteAsset: tBTC.ID,
+			OrderType:  order.LimitOrderType,
+			ClientTime: time.Now(),
+			ServerTime: time.Now().Add(time.Millisecond),
+			Commit:     preImg.Commit(),
+		},
+		T: order.Trade{
+			Sell:     true,
+			Quantity: qty,
+			Address:  addr,
+		},
+		Rate:  tBTC.RateStep,
+		Force: order.ImmediateTiF,
+	}
+	dbOrder := &db.MetaOrder{
+		MetaData: &db.OrderMetaData{
+			Status: order.OrderStatusEpoch,
+			Host:   dc.acct.host,
+			Proof: db.OrderProof{
+				Preimage: preImg[:],
+			},
+		},
+		Order: lo,
+	}
+	return lo, dbOrder, preImg, addr
+}
+
+func TestAddrHost(t *testing.T) {
+	tests := []struct {
+		name, addr, want string
+	}{{
+		name: "scheme, host, and port",
+		addr: "https://localhost:5758",
+		want: "localhost:5758",
+	}, {
+		name: "host and port",
+		addr: "localhost:5758",
+		want: "localhost:5758",
+	}, {
+		name: "just port",
+		addr: ":5758",
+		want: "localhost:5758",
+	}, {
+		name: "ip host and port",
+		addr: "127.0.0.1:5758",
+		want: "127.0.0.1:5758",
+	}, {
+		name: "just host",
+		addr: "thatonedex.com",
+		want: "thatonedex.com",
+	}, {
+		name: "shceme and host",
+		addr: "https://thatonedex.com",
+		want: "thatonedex.com",
+	}, {
+		name: "scheme, host, and path",
+		addr: "https://thatonedex.com/any/path",
+		want: "thatonedex.com",
+	}, {
+		name: "ipv6 host",
+		addr: "[1:2::]",
+		want: "[1:2::]",
+	}, {
+		name: "ipv6 host and port",
+		addr: "[1:2::]:5758",
+		want: "[1:2::]:5758",
+	}, {
+		name: "empty address",
+		want: "localhost",
+	}, {
+		name: "invalid host",
+		addr: "https://\n:1234",
+		want: "https://\n:1234",
+	}, {
+		name: "invalid port",
+		addr: ":asdf",
+		want: ":asdf",
+	}, {
+		name: "account host",
+		addr: "bot@localhost:5758",
+		want: "bot@localhost:5758",
+	}, {
+		name: "account, scheme, and host",
+		addr: "bot@https://thatonedex.com/any/path",
+		want: "bot@thatonedex.com",
+	}, {
+		name: "account and just port",
+		addr: "bot@:5758",
+		want: "bot@localhost:5758",
+	}}
+	for _, test := range tests {
+		res := addrHost(test.addr)
+		if res != test.want {
+			t.Fatalf("wanted %s but got %s for test '%s'", test.want, res, test.name)
+		}
+		// Parsing results a second time should produce the same results.
+		res = addrHost(res)
+		if res != test.want {
+			t.Fatalf("wanted %s but got %s for test '%s'", test.want, res, test.name)
+		}
+	}
+}
+
+func TestDEXAccounts(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	if got := selectAccount("https://"+tDexHost, ""); got != tDexHost {
+		t.Fatalf("default account selected %s, expected %s", got, tDexHost)
+	}
+	botHost := accountHost("bot", tDexHost)
+	if got := selectAccount(tDexHost, "bot"); got != botHost {
+		t.Fatalf("bot account selected %s, expected %s", got, botHost)
+	}
+	// A label replaces the label of an account host.
+	if got := selectAccount(accountHost("manual", tDexHost), "bot"); got != botHost {
+		t.Fatalf("bot account selected %s, expected %s", got, botHost)
+	}
+	label, host := splitAccountHost(botHost)
+	if label != "bot" || host != tDexHost {
+		t.Fatalf("split %s into %q and %q", botHost, label, host)
+	}
+
+	for _, label := range []string{"", "bot", "Bot_2-a"} {
+		if err := checkAccountLabel(label); err != nil {
+			t.Fatalf("valid label %q rejected: %v", label, err)
+		}
+	}
+	for _, label := range []string{"b@t", "bot:1", "bot/1", "bot 1", strings.Repeat("b", maxAccountLabelLen+1)} {
+		if err := checkAccountLabel(label); err == nil {
+			t.Fatalf("invalid label %q accepted", label)
+		}
+	}
+	_, err := tCore.Register(&RegisterForm{
+		Addr:    tDexHost,
+		AppPass: tPW,
+		Fee:     tFee,
+		Account: "b@t",
+	})
+	if !errorHasCode(err, accountLabelErr) {
+		t.Fatalf("expected account label error, got %v", err)
+	}
+
+	// Each account has its own connection, keyed by account host.
+	tCore.connMtx.Lock()
+	tCore.conns["zed@"+tDexHost] = rig.dc
+	tCore.conns[botHost] = rig.dc
+	tCore.conns["bot@other.dex"] = rig.dc
+	tCore.connMtx.Unlock()
+	accts := tCore.DEXAccounts(botHost)
+	want := []string{tDexHost, botHost, "zed@" + tDexHost}
+	if len(accts) != len(want) {
+		t.Fatalf("expected accounts %v, got %v", want, accts)
+	}
+	for i := range want {
+		if accts[i] != want[i] {
+			t.Fatalf("expected accounts %v, got %v", want, accts)
+		}
+	}
+	if xc := tCore.Exchanges()[botHost]; xc == nil || xc.Account != "bot" {
+		t.Fatalf("bot account exchange not labeled: %+v", xc)
+	}
+}
+
+func TestAssetBalance(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[wallet.id()] = wallet
+	bal := &asset.Balance{
+		Available: 4e7,
+		Immature:  6e7,
+		Locked:    2e8,
+	}
+	tWallet.bal = bal
+	balances, err := tCore.AssetBalance(tDCR.ID)
+	if err != nil {
+		t.Fatalf("error retreiving asset balance: %v", err)
+	}
+	dbtest.MustCompareAssetBalances(t, "zero-conf", bal, &balances.Balance)
+}
+
+func TestAssetCounter(t *testing.T) {
+	counts := make(assetCounter)
+	counts.add(1, 1)
+	if len(counts) != 1 {
+		t.Fatalf("count not added")
+	}
+	counts.add(1, 3)
+	if counts[1] != 4 {
+		t.Fatalf("counts not incremented properly")
+	}
+	newCounts := assetCounter{
+		1: 100,
+		2: 2,
+	}
+	counts.absorb(newCounts)
+	if len(counts) != 2 {
+		t.Fatalf("counts not absorbed properly")
+	}
+	if counts[2] != 2 {
+		t.Fatalf("absorbed counts not set correctly")
+	}
+	if counts[1] != 104 {
+		t.Fatalf("absorbed counts not combined correctly")
+	}
+}
+
+func TestHandleTradeSuspensionMsg(t *testing.T) {
+	rig := newTestRig()
+
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+
+	// Ensure a non-existent market cannot be suspended.
+	payload := &msgjson.TradeSuspension{
+		MarketID: "dcr_dcr",
+	}
+
+	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
+	err := handleTradeSuspensionMsg(rig.core, rig.dc, req)
+	if err == nil {
+		t.Fatal("[handleTradeSuspensionMsg] expected a market " +
+			"ID not found error: %v")
+	}
+
+	mkt := tDcrBtcMktName
+
+	// Ensure a suspended market cannot be resuspended.
+	err = rig.dc.suspend(mkt)
+	if err != nil {
+		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+	}
+
+	payload = &msgjson.TradeSuspension{
+		MarketID:    mkt,
+		FinalEpoch:  100,
+		SuspendTime: encode.UnixMilliU(time.Now().Add(time.Millisecond * 20)),
+		Persist:     true,
+	}
+
+	req, _ = msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
+	err = handleTradeSuspensionMsg(rig.core, rig.dc, req)
+	if err == nil {
+		t.Fatal("[handleTradeSuspensionMsg] expected a market " +
+			"suspended error: %v")
+	}
+
+	// Suspend a market.
+	err = rig.dc.resume(mkt)
+	if err != nil {
+		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+	}
+
+	req, _ = msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
+	err = handleTradeSuspensionMsg(rig.core, rig.dc, req)
+	if err != nil {
+		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+	}
+
+	// Wait for the suspend to execute.
+	time.Sleep(time.Millisecond * 40)
+
+	// Ensure trades for a suspended market generate an error.
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     tDCR.LotSize * 10,
+		Rate:    tBTC.RateStep * 1000,
+		TifNow:  false,
+	}
+
+	_, err = rig.core.Trade(tPW, form)
+	if err == nil {
+		t.Fatalf("expected a suspension market error")
+	}
+}
+
+func TestAggregateViews(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc1 := rig.dc
+	dc2, _, _ := testDexConnection()
+	const tOtherHost = "otherdex.tld"
+	dc2.acct.host = tOtherHost
+	tCore.conns[tOtherHost] = dc2
+
+	_, err := tCore.AggregateBook(tDCR.ID, tBTC.ID)
+	if err == nil {
+		t.Fatalf("no error for unsynced books")
+	}
+
+	bookNote := func(sell bool, qty, rate uint64) *msgjson.BookOrderNote {
+		var side uint8 = msgjson.BuyOrderNum
+		if sell {
+			side = msgjson.SellOrderNum
+		}
+		oid := ordertest.RandomOrderID()
+		return &msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  oid[:],
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     side,
+				Quantity: qty,
+				Rate:     rate,
+			},
+		}
+	}
+	syncBook := func(dc *dexConnection, notes ...*msgjson.BookOrderNote) {
+		book := newBookie(func() {})
+		err := book.Sync(&msgjson.OrderBook{
+			MarketID: tDcrBtcMktName,
+			Seq:      1,
+			Orders:   notes,
+		})
+		if err != nil {
+			t.Fatalf("order book sync error: %v", err)
+		}
+		dc.books[tDcrBtcMktName] = book
+	}
+
+	lot := tDCR.LotSize
+	// The first DEX has the best ask, but it is small. The second DEX has the
+	// best bid.
+	syncBook(dc1, bookNote(true, lot, 100), bookNote(true, lot*5, 150), bookNote(false, lot, 80))
+	syncBook(dc2, bookNote(true, lot*5, 120), bookNote(false, lot*5, 90), bookNote(false, lot, 70))
+
+	agg, err := tCore.AggregateBook(tDCR.ID, tBTC.ID)
+	if err != nil {
+		t.Fatalf("AggregateBook error: %v", err)
+	}
+	if len(agg.Hosts) != 2 || agg.Hosts[0] != tOtherHost || agg.Hosts[1] != tDexHost {
+		t.Fatalf("wrong hosts %v", agg.Hosts)
+	}
+	if len(agg.Sells) != 3 || len(agg.Buys) != 3 {
+		t.Fatalf("expected 3 sells and 3 buys, got %d and %d", len(agg.Sells), len(agg.Buys))
+	}
+	for i := 1; i < 3; i++ {
+		if agg.Sells[i].Rate < agg.Sells[i-1].Rate {
+			t.Fatalf("sells not sorted")
+		}
+		if agg.Buys[i].Rate > agg.Buys[i-1].Rate {
+			t.Fatalf("buys not sorted")
+		}
+	}
+	if agg.BuyVenue != tDexHost {
+		t.Fatalf("wrong buy venue %s", agg.BuyVenue)
+	}
+	if agg.SellVenue != tOtherHost {
+		t.Fatalf("wrong sell venue %s", agg.SellVenue)
+	}
+	if agg.Sells[1].Host != tOtherHost {
+		t.Fatalf("order not tagged with host")
+	}
+
+	// Buying a single lot is cheapest on the first DEX, but only the second DEX
+	// can fill 5 lots.
+	hints, err := tCore.BestVenues(tDCR.ID, tBTC.ID, false, lot)
+	if err != nil {
+		t.Fatalf("BestVenues error: %v", err)
+	}
+	if len(hints) != 2 || hints[0].Host != tDexHost || !hints[0].Complete {
+		t.Fatalf("wrong best venue for a 1 lot buy: %+v", hints[0])
+	}
+	hints, _ = tCore.BestVenues(tDCR.ID, tBTC.ID, false, lot*5)
+	if hints[0].Host != tOtherHost {
+		t.Fatalf("wrong best venue for a 5 lot buy: %s", hints[0].Host)
+	}
+	if !hints[0].Complete || !hints[1].Complete {
+		t.Fatalf("both venues should fill 5 lots")
+	}
+	// Selling 7 lots, neither DEX can fill it all, so the larger fill wins.
+	hints, _ = tCore.BestVenues(tDCR.ID, tBTC.ID, true, lot*7)
+	if hints[0].Host != tOtherHost || hints[0].Complete {
+		t.Fatalf("wrong best venue for a 7 lot sell: %+v", hints[0])
+	}
+	if hints[1].Qty != float64(lot)/conversionFactor {
+		t.Fatalf("wrong fill quantity %f", hints[1].Qty)
+	}
+	if _, err = tCore.BestVenues(tDCR.ID, tBTC.ID, true, 0); err == nil {
+		t.Fatalf("no error for zero quantity")
+	}
+
+	// Orders from both DEXes are listed together, with their matches.
+	addTrade := func(dc *dexConnection, matched bool) order.OrderID {
+		lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, lot, tBTC.RateStep)
+		tracker := newTrackedTrade(dbOrder, preImg, dc, 60000, tCore.lockTimeTaker, tCore.lockTimeMaker,
+			rig.db, rig.queue, nil, nil, tCore.notify)
+		if matched {
+			mid := ordertest.RandomMatchID()
+			tracker.matches[mid] = &matchTracker{
+				id: mid,
+				MetaMatch: db.MetaMatch{
+					MetaData: &db.MatchMetaData{},
+					Match:    &order.UserMatch{Quantity: lot},
+				},
+			}
+		}
+		dc.trades[lo.ID()] = tracker
+		return lo.ID()
+	}
+	addTrade(dc1, false)
+	oid2 := addTrade(dc2, true)
+
+	orders := tCore.AggregateOrders(tDCR.ID, tBTC.ID)
+	if len(orders.Orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(orders.Orders))
+	}
+	if orders.Orders[0].Host == orders.Orders[1].Host {
+		t.Fatalf("orders from the same host")
+	}
+	if len(orders.Matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(orders.Matches))
+	}
+	if orders.Matches[0].Host != tOtherHost || orders.Matches[0].OrderID != oid2.String() {
+		t.Fatalf("wrong match %+v", orders.Matches[0])
+	}
+
+	orders = tCore.AggregateOrders(tBTC.ID, tDCR.ID)
+	if len(orders.Orders) != 0 {
+		t.Fatalf("unexpected orders for unknown market")
+	}
+}
+
+func TestRouteOrder(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc1 := rig.dc
+	dc1.acct.auth()
+	dc2, tWs2, _ := testDexConnection()
+	const tOtherHost = "otherdex.tld"
+	dc2.acct.host = tOtherHost
+	dc2.acct.auth()
+	// The second DEX has a larger lot size.
+	dcr2 := *tDCR
+	dcr2.LotSize = tDCR.LotSize * 2
+	dc2.assets[tDCR.ID] = &dcr2
+	tCore.conns[tOtherHost] = dc2
+
+	form := &RouteForm{
+		Base:   tDCR.ID,
+		Quote:  tBTC.ID,
+		Qty:    5e7,
+		TifNow: true,
+	}
+	if _, err := tCore.PlanRoute(form); err == nil {
+		t.Fatalf("no error for unsynced books")
+	}
+
+	bookNote := func(sell bool, qty, rate uint64) *msgjson.BookOrderNote {
+		var side uint8 = msgjson.BuyOrderNum
+		if sell {
+			side = msgjson.SellOrderNum
+		}
+		oid := ordertest.RandomOrderID()
+		return &msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  oid[:],
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     side,
+				Quantity: qty,
+				Rate:     rate,
+			},
+		}
+	}
+	syncBook := func(dc *dexConnection, notes ...*msgjson.BookOrderNote) {
+		book := newBookie(func() {})
+		err := book.Sync(&msgjson.OrderBook{
+			MarketID: tDcrBtcMktName,
+			Seq:      1,
+			Orders:   notes,
+		})
+		if err != nil {
+			t.Fatalf("order book sync error: %v", err)
+		}
+		dc.books[tDcrBtcMktName] = book
+	}
+	syncBook(dc1, bookNote(true, 1e7, 1e6), bookNote(true, 2e7, 15e5),
+		bookNote(false, 1e7, 1000100))
+	syncBook(dc2, bookNote(true, 2e7, 12e5), bookNote(true, 4e7, 13e5),
+		bookNote(false, 2e7, 1e6), bookNote(false, 2e7, 9e5))
+
+	// A buy takes the best asks from both DEXes.
+	plan, err := tCore.PlanRoute(form)
+	if err != nil {
+		t.Fatalf("PlanRoute error: %v", err)
+	}
+	if len(plan.Legs) != 2 || plan.Unrouted != 0 {
+		t.Fatalf("expected 2 legs and nothing unrouted, got %d legs and %d unrouted", len(plan.Legs), plan.Unrouted)
+	}
+	leg1, leg2 := plan.Legs[0], plan.Legs[1]
+	if leg1.Host != tDexHost || leg1.Qty != 1e7 || leg1.Rate != 1e6 {
+		t.Fatalf("wrong first leg %+v", leg1)
+	}
+	if leg2.Host != tOtherHost || leg2.Qty != 4e7 || leg2.Rate != 13e5 || leg2.AvgRate != 125e4 {
+		t.Fatalf("wrong second leg %+v", leg2)
+	}
+	if leg2.Fees != 2*tBTC.SwapSize*tBTC.MaxFeeRate {
+		t.Fatalf("wrong fees %d", leg2.Fees)
+	}
+	if plan.AvgRate != 12e5 {
+		t.Fatalf("wrong average rate %d", plan.AvgRate)
+	}
+
+	// A quantity that is not a multiple of the lot sizes is partly unrouted.
+	form.Qty = 55e6
+	plan, _ = tCore.PlanRoute(form)
+	if plan.Unrouted != 5e6 {
+		t.Fatalf("expected 5e6 unrouted, got %d", plan.Unrouted)
+	}
+
+	// A sell prefers the second DEX's lower bid, since the fee per unit is
+	// lower with the larger lot size. The rest is booked at the limit rate on
+	// the second DEX.
+	form = &RouteForm{
+		Sell:  true,
+		Base:  tDCR.ID,
+		Quote: tBTC.ID,
+		Qty:   1e8,
+		Rate:  1e6,
+	}
+	plan, _ = tCore.PlanRoute(form)
+	if len(plan.Legs) != 2 || plan.Legs[0].Host != tOtherHost || plan.Legs[1].Host != tDexHost {
+		t.Fatalf("wrong sell legs")
+	}
+	if plan.Legs[0].Qty != 8e7 || plan.Legs[0].Rate != 1e6 || plan.Legs[1].Qty != 1e7 || plan.Unrouted != 1e7 {
+		t.Fatalf("wrong sell quantities")
+	}
+
+	// Hosts limits the route.
+	form.Hosts = []string{tDexHost}
+	plan, _ = tCore.PlanRoute(form)
+	if len(plan.Legs) != 1 || plan.Legs[0].Host != tDexHost || plan.Legs[0].Qty != 1e8 {
+		t.Fatalf("wrong legs for a single host")
+	}
+
+	// An order without a rate must be immediate.
+	form.Rate = 0
+	if _, err = tCore.PlanRoute(form); err == nil {
+		t.Fatalf("no error for a standing order without a rate")
+	}
+
+	// Place a routed buy.
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+	tBtcWallet.fundCoins = asset.Coins{&tCoin{id: encode.RandomBytes(36), val: 1e8}}
+	handleLimit := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.LimitOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		lo := convertMsgLimitOrder(msgOrder)
+		f(orderResponse(msg.ID, msgOrder, lo, false, false, false))
+		return nil
+	}
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	tWs2.queueResponse(msgjson.LimitRoute, handleLimit)
+	form = &RouteForm{
+		Base:   tDCR.ID,
+		Quote:  tBTC.ID,
+		Qty:    5e7,
+		TifNow: true,
+	}
+	fill, err := tCore.RouteTrade(tPW, form)
+	if err != nil {
+		t.Fatalf("RouteTrade error: %v", err)
+	}
+	if len(fill.Orders) != 2 || fill.Qty != 5e7 || fill.Filled != 0 || fill.Complete || len(fill.Errors) != 0 {
+		t.Fatalf("wrong routed fill %+v", fill)
+	}
+	if fill.Orders[0].Host != tDexHost || fill.Orders[1].Host != tOtherHost {
+		t.Fatalf("orders placed on the wrong hosts")
+	}
+
+	// A leg that fails is reported.
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	tWs2.reqErr = tErr
+	fill, err = tCore.RouteTrade(tPW, form)
+	if err != nil {
+		t.Fatalf("RouteTrade error: %v", err)
+	}
+	if len(fill.Orders) != 1 || fill.Errors[tOtherHost] == "" {
+		t.Fatalf("failed leg not reported")
+	}
+
+	// The consolidated fill averages the match rates.
+	fill = routeFill([]*Order{{
+		Qty:     1e7,
+		Filled:  1e7,
+		Status:  order.OrderStatusExecuted,
+		Matches: []*Match{{Qty: 1e7, Rate: 1e6}},
+	}, {
+		Qty:     4e7,
+		Filled:  3e7,
+		Status:  order.OrderStatusExecuted,
+		Matches: []*Match{{Qty: 3e7, Rate: 2e6}},
+	}})
+	if fill.Qty != 5e7 || fill.Filled != 4e7 || fill.AvgRate != 175e4 || !fill.Complete {
+		t.Fatalf("wrong consolidated fill %+v", fill)
+	}
+}
+
+func TestPreOrder(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	lot := tDCR.LotSize
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     lot*10 + lot/2,
+		Rate:    tBTC.RateStep * 1000,
+	}
+	est, err := tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("PreOrder error: %v", err)
+	}
+	if est.Lots != 10 || est.Qty != lot*10 || est.Remainder != lot/2 {
+		t.Fatalf("wrong lot rounding: %d lots, qty %d, remainder %d", est.Lots, est.Qty, est.Remainder)
+	}
+	swapFee := tDCR.SwapSize * tDCR.MaxFeeRate
+	if est.Swap.AssetID != tDCR.ID || est.Swap.Best != swapFee || est.Swap.Worst != swapFee*10 {
+		t.Fatalf("wrong swap fees %+v", est.Swap)
+	}
+	if est.Locked != lot*10+swapFee*10 {
+		t.Fatalf("wrong locked value %d", est.Locked)
+	}
+	// The redeem fee is estimated by the wallet.
+	if est.Redeem.AssetID != tBTC.ID || est.Redeem.FeeRate != 20 || est.Redeem.Best != 6000 || est.Redeem.Worst != 60000 {
+		t.Fatalf("wrong redeem fees %+v", est.Redeem)
+	}
+	// A standing order may be the maker.
+	bTimeout := time.Duration(rig.dc.cfg.BroadcastTimeout) * time.Millisecond
+	takerDelay := uint64((bTimeout + tCore.lockTimeTaker) / time.Millisecond)
+	makerDelay := uint64(tCore.lockTimeMaker / time.Millisecond)
+	if makerDelay < takerDelay {
+		makerDelay = takerDelay
+	}
+	if est.RefundAfter != makerDelay {
+		t.Fatalf("wrong standing order refund delay %d", est.RefundAfter)
+	}
+	form.TifNow = true
+	est, _ = tCore.PreOrder(form)
+	if est.RefundAfter != takerDelay {
+		t.Fatalf("wrong immediate order refund delay %d", est.RefundAfter)
+	}
+
+	// Less than a lot.
+	form.Qty = lot / 2
+	if _, err = tCore.PreOrder(form); err == nil {
+		t.Fatalf("no error for less than a lot")
+	}
+
+	// A market buy needs the book.
+	form = &TradeForm{
+		Host:  tDexHost,
+		Base:  tDCR.ID,
+		Quote: tBTC.ID,
+		Qty:   calc.BaseToQuote(1e6, lot*3),
+	}
+	if _, err = tCore.PreOrder(form); err == nil {
+		t.Fatalf("no error for market buy without a book")
+	}
+	book := newBookie(func() {})
+	oid := ordertest.RandomOrderID()
+	err = book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Orders: []*msgjson.BookOrderNote{{
+			OrderNote: msgjson.OrderNote{MarketID: tDcrBtcMktName, OrderID: oid[:]},
+			TradeNote: msgjson.TradeNote{Side: msgjson.SellOrderNum, Quantity: lot * 2, Rate: 1e6},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+	rig.dc.books[tDcrBtcMktName] = book
+	est, err = tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("market buy PreOrder error: %v", err)
+	}
+	// The book only has 2 lots.
+	if est.Lots != 2 || est.Qty != form.Qty || est.Swap.AssetID != tBTC.ID {
+		t.Fatalf("wrong market buy estimate %+v", est)
+	}
+	if est.TradeFee != nil {
+		t.Fatalf("trade fee estimated without a trade fee rate")
+	}
+
+	// The trade fee is paid on the quantity received.
+	mkt := rig.dc.market(tDcrBtcMktName)
+	mkt.TradeFee = 20
+	defer func() { mkt.TradeFee = 0 }()
+	est, err = tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("market buy PreOrder error with trade fee: %v", err)
+	}
+	if est.TradeFee == nil || est.TradeFee.AssetID != tDCR.ID || est.TradeFee.Rate != 20 ||
+		est.TradeFee.Fee != calc.TradeFee(lot*2, 20) {
+		t.Fatalf("wrong market buy trade fee %+v", est.TradeFee)
+	}
+	limitSell := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     lot * 3,
+		Rate:    tBTC.RateStep * 1000,
+	}
+	est, err = tCore.PreOrder(limitSell)
+	if err != nil {
+		t.Fatalf("limit sell PreOrder error with trade fee: %v", err)
+	}
+	wantFee := calc.TradeFee(calc.BaseToQuote(limitSell.Rate, lot*3), 20)
+	if est.TradeFee == nil || est.TradeFee.AssetID != tBTC.ID || est.TradeFee.Fee != wantFee {
+		t.Fatalf("wrong limit sell trade fee %+v", est.TradeFee)
+	}
+	// A market sell's proceeds come from the buy side of the book, which is
+	// empty.
+	limitSell.IsLimit = false
+	if _, err = tCore.PreOrder(limitSell); err == nil {
+		t.Fatalf("no error for market sell trade fee without buy orders")
+	}
+
+	// Unknown DEX.
+	form.Host = "unknown"
+	if _, err = tCore.PreOrder(form); err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+}
+
+type tRateSource struct {
+	currency string
+	rates    map[uint32]float64
+	err      error
+	calls    int
+}
+
+func (s *tRateSource) Currency() string {
+	if s.currency == "" {
+		return "USD"
+	}
+	return s.currency
+}
+
+func (s *tRateSource) FiatRates([]uint32) (map[uint32]float64, error) {
+	s.calls++
+	return s.rates, s.err
+}
+
+func TestPortfolio(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	dcrWallet.balance = &db.Balance{
+		Balance: asset.Balance{
+			Available: 1e8,
+			Locked:    4e7,
+		},
+	}
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	lot := tDCR.LotSize
+	addMatch := func(tracker *trackedTrade, qty uint64, swapped bool, status order.MatchStatus) {
+		mid := ordertest.RandomMatchID()
+		match := &matchTracker{
+			id: mid,
+			MetaMatch: db.MetaMatch{
+				MetaData: &db.MatchMetaData{},
+				Match: &order.UserMatch{
+					Quantity: qty,
+					Status:   status,
+					Side:     order.Maker,
+				},
+			},
+		}
+		if swapped {
+			match.MetaData.Proof.Script = encode.RandomBytes(50)
+		}
+		tracker.matches[mid] = match
+	}
+
+	// A booked sell order for 5 lots with one lot swapped and one lot matched
+	// but not yet swapped. A completed match is not counted.
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, lot*5, 0)
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	lo.FillAmt = lot * 3
+	sellTracker := newTrackedTrade(dbOrder, preImg, dc, 60000, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, tCore.notify)
+	addMatch(sellTracker, lot, true, order.MakerSwapCast)
+	addMatch(sellTracker, lot, false, order.NewlyMatched)
+	addMatch(sellTracker, lot, true, order.MatchComplete)
+	dc.trades[lo.ID()] = sellTracker
+
+	// An unmatched buy order, funded with BTC.
+	const rate = 2e7
+	lo, dbOrder, preImg, _ = makeLimitOrder(dc, false, lot*5, 0)
+	lo.T.Sell = false
+	lo.Rate = rate
+	dc.trades[lo.ID()] = newTrackedTrade(dbOrder, preImg, dc, 60000, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, tCore.notify)
+
+	portfolio := tCore.Portfolio()
+	if len(portfolio.Assets) != 2 {
+		t.Fatalf("expected 2 assets, got %d", len(portfolio.Assets))
+	}
+	btc, dcr := portfolio.Assets[0], portfolio.Assets[1]
+	if dcr.AssetID != tDCR.ID || btc.AssetID != tBTC.ID {
+		t.Fatalf("assets not sorted")
+	}
+	if dcr.OrderLocked != lot*3 {
+		t.Fatalf("wrong DCR order locked. wanted %d, got %d", lot*3, dcr.OrderLocked)
+	}
+	if dcr.SwapLocked != lot {
+		t.Fatalf("wrong DCR swap locked. wanted %d, got %d", lot, dcr.SwapLocked)
+	}
+	if dcr.Total != 1e8+4e7+lot {
+		t.Fatalf("wrong DCR total %d", dcr.Total)
+	}
+	if btc.OrderLocked != calc.BaseToQuote(rate, lot*5) {
+		t.Fatalf("wrong BTC order locked %d", btc.OrderLocked)
+	}
+	if portfolio.Currency != "" || dcr.FiatValue != 0 {
+		t.Fatalf("fiat values set without a rate source")
+	}
+
+	// With a rate source.
+	src := &tRateSource{rates: map[uint32]float64{tDCR.ID: 20}}
+	tCore.rateSource = src
+	portfolio = tCore.Portfolio()
+	if portfolio.Currency != "USD" {
+		t.Fatalf("wrong currency %q", portfolio.Currency)
+	}
+	dcr = portfolio.Assets[1]
+	wantValue := float64(dcr.Total) / conversionFactor * 20
+	if dcr.FiatRate != 20 || dcr.FiatValue != wantValue || portfolio.FiatValue != wantValue {
+		t.Fatalf("wrong fiat value %f", portfolio.FiatValue)
+	}
+	if portfolio.Assets[0].FiatValue != 0 {
+		t.Fatalf("fiat value set for asset without a rate")
+	}
+
+	// Rate source error.
+	src.err = tErr
+	portfolio = tCore.Portfolio()
+	if portfolio.Currency != "" || portfolio.FiatValue != 0 {
+		t.Fatalf("fiat values set after rate source error")
+	}
+}
+
+func TestMedianRates(t *testing.T) {
+	src1 := &tRateSource{rates: map[uint32]float64{tDCR.ID: 10, tBTC.ID: 100}}
+	src2 := &tRateSource{rates: map[uint32]float64{tDCR.ID: 40}}
+	src3 := &tRateSource{rates: map[uint32]float64{tDCR.ID: 20, tBTC.ID: 0}}
+	rates, err := NewMedianRates([]RateSource{src1, src2, src3}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewMedianRates error: %v", err)
+	}
+	assetIDs := []uint32{tDCR.ID, tBTC.ID}
+	checkRates := func(wantDCR, wantBTC float64) {
+		t.Helper()
+		r, err := rates.FiatRates(assetIDs)
+		if err != nil {
+			t.Fatalf("FiatRates error: %v", err)
+		}
+		if r[tDCR.ID] != wantDCR || r[tBTC.ID] != wantBTC {
+			t.Fatalf("wrong rates. wanted DCR %f, BTC %f, got %v", wantDCR, wantBTC, r)
+		}
+	}
+	// The median of three rates, and of one rate, since zero rates are ignored.
+	checkRates(20, 100)
+
+	// Cached rates are used until they expire.
+	src1.rates[tDCR.ID] = 30
+	checkRates(20, 100)
+	if src1.calls != 1 {
+		t.Fatalf("expected 1 call to the source, got %d", src1.calls)
+	}
+	for _, cached := range rates.cache {
+		cached.stamp = time.Now().Add(-time.Hour)
+	}
+	// The median of an even number of rates is the mean of the middle two.
+	src3.err = tErr
+	checkRates(35, 100)
+
+	// Every source fails, but rates are cached.
+	src1.err, src2.err = tErr, tErr
+	checkRates(35, 100)
+
+	// Every source fails, and nothing is cached.
+	rates.cache = make(map[uint32]*cachedRate)
+	if _, err = rates.FiatRates(assetIDs); err == nil {
+		t.Fatalf("no error when every source failed")
+	}
+
+	// Sources must agree on the currency.
+	_, err = NewMedianRates([]RateSource{src1, &tRateSource{currency: "EUR"}}, time.Minute)
+	if err == nil {
+		t.Fatalf("no error for mismatched currencies")
+	}
+	if _, err = NewMedianRates(nil, time.Minute); err == nil {
+		t.Fatalf("no error without sources")
+	}
+}
+
+func TestBuiltInRateSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ids := r.URL.Query().Get("ids"); ids != "decred,bitcoin" {
+			http.Error(w, "wrong ids "+ids, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"decred":{"usd":20.5},"bitcoin":{"usd":9000}}`))
+	}))
+	defer srv.Close()
+	defer func(url string) { coinGeckoURL = url }(coinGeckoURL)
+	coinGeckoURL = srv.URL + "?ids=%s"
+
+	sources, err := BuiltInRateSources(CoinGecko)
+	if err != nil {
+		t.Fatalf("BuiltInRateSources error: %v", err)
+	}
+	rates, err := sources[0].FiatRates([]uint32{tDCR.ID, tBTC.ID, 12345})
+	if err != nil {
+		t.Fatalf("FiatRates error: %v", err)
+	}
+	if len(rates) != 2 || rates[tDCR.ID] != 20.5 || rates[tBTC.ID] != 9000 {
+		t.Fatalf("wrong rates %v", rates)
+	}
+
+	if _, err = BuiltInRateSources("nope"); err == nil {
+		t.Fatalf("no error for unknown source")
+	}
+	sources, _ = BuiltInRateSources()
+	if len(sources) != len(builtInFetchers) {
+		t.Fatalf("expected %d sources, got %d", len(builtInFetchers), len(sources))
+	}
+}
+
+func TestRefreshFiatRates(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	ch := tCore.NotificationFeed()
+
+	// Without a rate source.
+	if currency, _ := tCore.currentFiatRates(); currency != "" {
+		t.Fatalf("currency set without a rate source")
+	}
+
+	src := &tRateSource{rates: map[uint32]float64{tDCR.ID: 20, tBTC.ID: 10000}}
+	tCore.rateSource = src
+	tCore.refreshFiatRates()
+	select {
+	case n := <-ch:
+		note, ok := n.(*FiatRatesNote)
+		if !ok || note.FiatRates[tDCR.ID] != 20 {
+			t.Fatalf("wrong notification %v", n)
+		}
+	default:
+		t.Fatalf("no fiat rates notification")
+	}
+	user := tCore.User()
+	if user.Currency != "USD" || user.FiatRates[tDCR.ID] != 20 {
+		t.Fatalf("wrong user fiat rates %s %v", user.Currency, user.FiatRates)
+	}
+	if state := tCore.WalletState(tDCR.ID); state.FiatRate != 20 {
+		t.Fatalf("wrong wallet fiat rate %f", state.FiatRate)
+	}
+
+	// The quantity of a market buy order is in the quote asset.
+	corder := &Order{Type: order.MarketOrderType, Qty: 2e8}
+	tCore.setOrderFiatValue(corder, tDCR.ID, tBTC.ID)
+	if corder.FiatValue != 20000 {
+		t.Fatalf("wrong market buy fiat value %f", corder.FiatValue)
+	}
+	corder.Sell = true
+	tCore.setOrderFiatValue(corder, tDCR.ID, tBTC.ID)
+	if corder.FiatValue != 40 {
+		t.Fatalf("wrong market sell fiat value %f", corder.FiatValue)
+	}
+
+	// An error leaves the last rates.
+	src.err = tErr
+	tCore.refreshFiatRates()
+	if _, rates := tCore.currentFiatRates(); rates[tDCR.ID] != 20 {
+		t.Fatalf("rates cleared after error")
+	}
+}
+
+func TestTradeHistory(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+	rig.db.accts = []*db.AccountInfo{{Host: tDexHost}}
+
+	lot := tDCR.LotSize
+	const rate = 2e7
+	lo, executed, _, _ := makeLimitOrder(dc, true, lot*3, 0)
+	lo.Rate = rate
+	lo.FillAmt = lot * 2
+	executed.MetaData.Status = order.OrderStatusExecuted
+	executed.MetaData.Notes, executed.MetaData.Tags = "manual", []string{"dca"}
+	_, booked, _, _ := makeLimitOrder(dc, true, lot, 0)
+	booked.MetaData.Status = order.OrderStatusBooked
+	rig.db.accountOrders = []*db.MetaOrder{executed, booked}
+
+	const feeRate = 10
+	metaMatch := func(side order.MatchSide, status order.MatchStatus, swapped, refunded bool) *db.MetaMatch {
+		m := &db.MetaMatch{
+			MetaData: &db.MatchMetaData{},
+			Match: &order.UserMatch{
+				OrderID:     lo.ID(),
+				MatchID:     ordertest.RandomMatchID(),
+				Quantity:    lot,
+				Rate:        rate,
+				Side:        side,
+				Status:      status,
+				FeeRateSwap: feeRate,
+			},
+		}
+		if swapped {
+			m.MetaData.Proof.Script = encode.RandomBytes(50)
+		}
+		if refunded {
+			m.MetaData.Proof.RefundCoin = encode.RandomBytes(36)
+		}
+		return m
+	}
+	rig.db.matchesForOID = []*db.MetaMatch{
+		metaMatch(order.Taker, order.MatchComplete, true, false),
+		metaMatch(order.Maker, order.MakerSwapCast, true, true),
+		metaMatch(order.Maker, order.MakerSwapCast, true, false), // active
+	}
+
+	history, err := tCore.TradeHistory()
+	if err != nil {
+		t.Fatalf("TradeHistory error: %v", err)
+	}
+	if len(history.Orders) != 1 || history.Orders[0].ID != lo.ID().String() {
+		t.Fatalf("expected only the executed order")
+	}
+	if history.Orders[0].Notes != "manual" {
+		t.Fatalf("order notes not exported")
+	}
+	if len(history.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(history.Matches))
+	}
+	swapFee := feeRate * tDCR.SwapSize
+	var settled, refunded *ExportedMatch
+	for _, m := range history.Matches {
+		if m.Refunded {
+			refunded = m
+		} else {
+			settled = m
+		}
+	}
+	if settled == nil || refunded == nil {
+		t.Fatalf("missing settled or refunded match")
+	}
+	quoteQty := calc.BaseToQuote(rate, lot)
+	if settled.Sent != lot || settled.Received != quoteQty || settled.SwapFee != swapFee {
+		t.Fatalf("wrong settled match amounts %+v", settled)
+	}
+	if refunded.Sent != 0 || refunded.Received != 0 || refunded.SwapFee != swapFee {
+		t.Fatalf("wrong refunded match amounts %+v", refunded)
+	}
+
+	if len(history.Assets) != 2 {
+		t.Fatalf("expected 2 assets, got %d", len(history.Assets))
+	}
+	btc, dcr := history.Assets[0], history.Assets[1]
+	if dcr.Sent != lot || dcr.Fees != swapFee*2 || dcr.Net != -int64(lot+swapFee*2) {
+		t.Fatalf("wrong DCR P&L %+v", dcr)
+	}
+	if btc.Received != quoteQty || btc.Net != int64(quoteQty) {
+		t.Fatalf("wrong BTC P&L %+v", btc)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = history.WriteCSV(buf); err != nil {
+		t.Fatalf("WriteCSV error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 CSV lines, got %d", len(lines))
+	}
+	if !strings.Contains(buf.String(), ","+formatAtoms(quoteQty)+",") {
+		t.Fatalf("CSV missing received amount: %s", buf.String())
+	}
+	// The matches have the order's notes and tags.
+	if !strings.HasSuffix(lines[1], ",manual,dca") {
+		t.Fatalf("CSV missing notes and tags: %s", lines[1])
+	}
+
+	rig.db.matchesForOIDErr = tErr
+	if _, err = tCore.TradeHistory(); err == nil {
+		t.Fatalf("no error for matches error")
+	}
+}
+
+func TestFormatAtoms(t *testing.T) {
+	tests := map[uint64]string{
+		0:         "0.00000000",
+		1:         "0.00000001",
+		1e8:       "1.00000000",
+		123456789: "1.23456789",
+	}
+	for v, want := range tests {
+		if got := formatAtoms(v); got != want {
+			t.Fatalf("formatAtoms(%d) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestBumpRedemptionFees(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	// Selling DCR, so the redemption is in BTC.
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, tDCR.LotSize, tBTC.RateStep)
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	var notes []*OrderNote
+	notify := func(n Notification) {
+		if note, ok := n.(*OrderNote); ok {
+			notes = append(notes, note)
+		}
+	}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen,
+		tCore.lockTimeTaker, tCore.lockTimeMaker, rig.db, rig.queue, walletSet, nil, notify)
+
+	mid := ordertest.RandomMatchID()
+	redeemCoin := encode.RandomBytes(36)
+	match := &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Proof: db.MatchProof{
+					// The counterparty's redemption is not ours to bump.
+					TakerRedeem: encode.RandomBytes(36),
+				},
+			},
+			Match: &order.UserMatch{
+				OrderID:  lo.ID(),
+				MatchID:  mid,
+				Quantity: tDCR.LotSize,
+				Rate:     tBTC.RateStep,
+				Status:   order.MatchComplete,
+				Side:     order.Maker,
+			},
+		},
+	}
+	tracker.matches[mid] = match
+
+	bump := func() {
+		tracker.matchMtx.Lock()
+		tracker.bumpRedemptionFees()
+		tracker.matchMtx.Unlock()
+	}
+	checkBumps := func(tag string, wantBumps, wantNotes int) {
+		t.Helper()
+		if tBtcWallet.bumps != wantBumps {
+			t.Fatalf("%s: expected %d fee bumps, got %d", tag, wantBumps, tBtcWallet.bumps)
+		}
+		if len(notes) != wantNotes {
+			t.Fatalf("%s: expected %d notifications, got %d", tag, wantNotes, len(notes))
+		}
+	}
+
+	// No redemption of ours yet.
+	bump()
+	checkBumps("no redemption", 0, 0)
+
+	// The redemption time is unknown, so the delay starts now.
+	match.MetaData.Proof.MakerRedeem = redeemCoin
+	bump()
+	checkBumps("unknown redemption time", 0, 0)
+	if match.redeemCheck.IsZero() {
+		t.Fatalf("redemption time not set")
+	}
+
+	// Not unconfirmed for long enough.
+	bump()
+	checkBumps("recent redemption", 0, 0)
+
+	// Confirmations error.
+	match.redeemCheck = time.Now().Add(-redeemBumpDelay)
+	tBtcWallet.confsErr = tErr
+	bump()
+	checkBumps("confirmations error", 0, 0)
+	tBtcWallet.confsErr = nil
+
+	// No bump needed.
+	tBtcWallet.bumpErr = asset.NoFeeBumpError
+	bump()
+	checkBumps("no bump needed", 1, 0)
+
+	// Not again until the delay passes.
+	bump()
+	checkBumps("no bump needed again", 1, 0)
+
+	// Bump error.
+	match.redeemCheck = time.Now().Add(-redeemBumpDelay)
+	tBtcWallet.bumpErr = tErr
+	bump()
+	checkBumps("bump error", 2, 1)
+	if notes[0].Severity() != db.ErrorLevel {
+		t.Fatalf("expected error notification for bump error, got %s", notes[0].Subject())
+	}
+	tBtcWallet.bumpErr = nil
+
+	// Success.
+	match.redeemCheck = time.Now().Add(-redeemBumpDelay)
+	tBtcWallet.bumpCoin = &tCoin{id: encode.RandomBytes(36)}
+	tBtcWallet.bumpRate = 50
+	bump()
+	checkBumps("success", 3, 2)
+	if notes[1].Subject() != "Redemption fee bumped" {
+		t.Fatalf("wrong notification subject %q", notes[1].Subject())
+	}
+
+	// Once confirmed, the redemption is no longer checked.
+	match.redeemCheck = time.Now().Add(-redeemBumpDelay)
+	tBtcWallet.confs = 1
+	bump()
+	checkBumps("confirmed", 3, 2)
+	if !match.redeemConfirmed {
+		t.Fatalf("redemption not marked confirmed")
+	}
+	tBtcWallet.confs = 0
+	match.redeemCheck = time.Now().Add(-redeemBumpDelay)
+	bump()
+	checkBumps("confirmed again", 3, 2)
+}
+
+func TestScheduledOrders(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+	dc.acct.auth()
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+
+	qty := tDCR.LotSize * 2
+	rate := tBTC.RateStep * 1000
+	tDcrWallet.fundCoins = asset.Coins{&tCoin{id: encode.RandomBytes(36), val: qty * 2}}
+
+	// The book has a single sell order at rate, so the mid-gap is rate.
+	book := newBookie(func() {})
+	dc.books[tDcrBtcMktName] = book
+	err := book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Epoch:    1,
+		Orders: []*msgjson.BookOrderNote{{
+			OrderNote: msgjson.OrderNote{OrderID: encode.RandomBytes(32)},
+			TradeNote: msgjson.TradeNote{
+				Side:     msgjson.SellOrderNum,
+				Quantity: tDCR.LotSize,
+				Time:     uint64(time.Now().Unix()),
+				Rate:     rate,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+
+	handleLimit := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.LimitOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		f(orderResponse(msg.ID, msgOrder, convertMsgLimitOrder(msgOrder), false, false, false))
+		return nil
+	}
+
+	ntfns := tCore.NotificationFeed()
+	checkNote := func(subject string) {
+		t.Helper()
+		for {
+			select {
+			case n := <-ntfns:
+				if n.Subject() == subject {
+					return
+				}
+			default:
+				t.Fatalf("no %q notification", subject)
+			}
+		}
+	}
+
+	newForm := func() *ScheduleForm {
+		return &ScheduleForm{
+			TradeForm: TradeForm{
+				Host:    tDexHost,
+				IsLimit: true,
+				Sell:    true,
+				Base:    tDCR.ID,
+				Quote:   tBTC.ID,
+				Qty:     qty,
+				Rate:    rate,
+			},
+			Time: encode.UnixMilliU(time.Now().Add(time.Hour)),
+		}
+	}
+
+	ensureErr := func(tag string, form *ScheduleForm) {
+		t.Helper()
+		_, err := tCore.ScheduleOrder(tPW, form)
+		if err == nil {
+			t.Fatalf("%s: no error", tag)
+		}
+	}
+	form := newForm()
+	form.Host = "unknown"
+	ensureErr("unknown host", form)
+	form = newForm()
+	form.Time = 0
+	ensureErr("no condition", form)
+	form = newForm()
+	form.Time = encode.UnixMilliU(time.Now().Add(-time.Minute))
+	ensureErr("past time", form)
+	form = newForm()
+	form.Qty = 0
+	ensureErr("zero quantity", form)
+	form = newForm()
+	form.Rate = 0
+	ensureErr("zero rate", form)
+	form = newForm()
+	form.Qty = tDCR.LotSize + 1
+	ensureErr("not a lot multiple", form)
+	rig.crypter.recryptErr = tErr
+	ensureErr("password error", newForm())
+	rig.crypter.recryptErr = nil
+
+	numScheds := func() int {
+		t.Helper()
+		scheds, err := tCore.ScheduledOrders()
+		if err != nil {
+			t.Fatalf("ScheduledOrders error: %v", err)
+		}
+		return len(scheds)
+	}
+
+	// A time condition in the future is not triggered.
+	timeSched, err := tCore.ScheduleOrder(tPW, newForm())
+	if err != nil {
+		t.Fatalf("ScheduleOrder error: %v", err)
+	}
+	// A price condition that the market has not reached is not triggered.
+	form = newForm()
+	form.Time = 0
+	form.TriggerRate = rate * 2
+	form.Above = true
+	_, err = tCore.ScheduleOrder(tPW, form)
+	if err != nil {
+		t.Fatalf("ScheduleOrder error for price trigger: %v", err)
+	}
+	tCore.checkScheduledOrders(tCtx)
+	if n := numScheds(); n != 2 {
+		t.Fatalf("expected 2 scheduled orders, got %d", n)
+	}
+	if len(tCore.schedFeeds) != 1 {
+		t.Fatalf("expected 1 book feed, got %d", len(tCore.schedFeeds))
+	}
+
+	// A price condition that the market has reached is placed.
+	form.TriggerRate = rate
+	form.Above = false
+	_, err = tCore.ScheduleOrder(tPW, form)
+	if err != nil {
+		t.Fatalf("ScheduleOrder error for met price trigger: %v", err)
+	}
+	// But not while the account is not logged in.
+	dc.acct.unauth()
+	tCore.checkScheduledOrders(tCtx)
+	if n := numScheds(); n != 3 {
+		t.Fatalf("expected 3 scheduled orders while logged out, got %d", n)
+	}
+	dc.acct.auth()
+	// Nor while a wallet is locked.
+	dcrWallet.Lock()
+	tCore.checkScheduledOrders(tCtx)
+	if n := numScheds(); n != 3 {
+		t.Fatalf("expected 3 scheduled orders with a locked wallet, got %d", n)
+	}
+	dcrWallet.Unlock(wPW, time.Hour)
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	tCore.checkScheduledOrders(tCtx)
+	if n := numScheds(); n != 2 {
+		t.Fatalf("expected 2 scheduled orders after trigger, got %d", n)
+	}
+	checkNote("Scheduled order placed")
+
+	// A failure to place the order removes it.
+	form = newForm()
+	form.Time = encode.UnixMilliU(time.Now().Add(time.Millisecond * 10))
+	_, err = tCore.ScheduleOrder(tPW, form)
+	if err != nil {
+		t.Fatalf("ScheduleOrder error for soon time: %v", err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	tDcrWallet.fundErr = tErr
+	tCore.checkScheduledOrders(tCtx)
+	tDcrWallet.fundErr = nil
+	if n := numScheds(); n != 2 {
+		t.Fatalf("expected 2 scheduled orders after failure, got %d", n)
+	}
+	checkNote("Scheduled order failed")
+
+	// Cancel the remaining orders. The book feed is no longer needed.
+	if err := tCore.CancelScheduledOrder("zz"); err == nil {
+		t.Fatalf("no error for invalid ID")
+	}
+	scheds, _ := tCore.ScheduledOrders()
+	for _, sched := range scheds {
+		err := tCore.CancelScheduledOrder(sched.ID)
+		if err != nil {
+			t.Fatalf("CancelScheduledOrder error: %v", err)
+		}
+	}
+	if err := tCore.CancelScheduledOrder(timeSched.ID); err == nil {
+		t.Fatalf("no error for canceling unknown scheduled order")
+	}
+	tCore.checkScheduledOrders(tCtx)
+	if len(tCore.schedFeeds) != 0 {
+		t.Fatalf("expected no book feeds, got %d", len(tCore.schedFeeds))
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+
+	// The book has a single sell order at rate, so the mid-gap is rate.
+	rate := tBTC.RateStep * 1000
+	book := newBookie(func() {})
+	dc.books[tDcrBtcMktName] = book
+	err := book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Epoch:    1,
+		Orders: []*msgjson.BookOrderNote{{
+			OrderNote: msgjson.OrderNote{OrderID: encode.RandomBytes(32)},
+			TradeNote: msgjson.TradeNote{
+				Side:     msgjson.SellOrderNum,
+				Quantity: tDCR.LotSize,
+				Time:     uint64(time.Now().Unix()),
+				Rate:     rate,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+
+	// An active trade with a match waiting on a swap.
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, tDCR.LotSize*2, tBTC.RateStep)
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen,
+		tCore.lockTimeTaker, tCore.lockTimeMaker, rig.db, rig.queue, walletSet, nil, tCore.notify)
+	dc.trades[lo.ID()] = tracker
+	mid := ordertest.RandomMatchID()
+	matchStamp := encode.UnixMilliU(time.Now().Add(-time.Hour))
+	tracker.matches[mid] = &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Proof: db.MatchProof{
+					Auth: db.MatchAuth{
+						MatchStamp: matchStamp,
+					},
+				},
+			},
+			Match: &order.UserMatch{
+				OrderID:  lo.ID(),
+				MatchID:  mid,
+				Quantity: tDCR.LotSize,
+				Rate:     tBTC.RateStep,
+				Status:   order.NewlyMatched,
+				Side:     order.Maker,
+			},
+		},
+	}
+
+	ntfns := tCore.NotificationFeed()
+	countNotes := func(subject string) int {
+		t.Helper()
+		var n int
+		for {
+			select {
+			case note := <-ntfns:
+				if note.Subject() == subject {
+					n++
+				}
+			default:
+				return n
+			}
+		}
+	}
+
+	newForm := func(kind string) *AlertForm {
+		return &AlertForm{
+			Kind:    kind,
+			Host:    tDexHost,
+			Base:    tDCR.ID,
+			Quote:   tBTC.ID,
+			Rate:    rate * 2,
+			Above:   true,
+			OrderID: lo.ID().String(),
+			Minutes: 30,
+		}
+	}
+
+	ensureErr := func(tag string, form *AlertForm) {
+		t.Helper()
+		_, err := tCore.AddAlert(form)
+		if err == nil {
+			t.Fatalf("%s: no error", tag)
+		}
+	}
+	ensureErr("unknown kind", newForm("nope"))
+	form := newForm(AlertPrice)
+	form.Host = "unknown"
+	ensureErr("unknown host", form)
+	form = newForm(AlertPrice)
+	form.Quote = 12345
+	ensureErr("unknown market", form)
+	form = newForm(AlertPrice)
+	form.Rate = 0
+	ensureErr("zero rate", form)
+	form = newForm(AlertFill)
+	form.OrderID = ordertest.RandomOrderID().String()
+	ensureErr("unknown order", form)
+	form = newForm(AlertSwap)
+	form.Minutes = 0
+	ensureErr("zero minutes", form)
+	form = newForm(AlertSwap)
+	form.Webhook = "ftp://example.com"
+	ensureErr("bad webhook scheme", form)
+
+	numAlerts := func() int {
+		t.Helper()
+		alerts, err := tCore.Alerts()
+		if err != nil {
+			t.Fatalf("Alerts error: %v", err)
+		}
+		return len(alerts)
+	}
+
+	// A webhook receives the notification.
+	hookNotes := make(chan *AlertNote, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		note := new(AlertNote)
+		if err := json.NewDecoder(r.Body).Decode(note); err != nil {
+			t.Errorf("error decoding webhook notification: %v", err)
+		}
+		hookNotes <- note
+	}))
+	defer srv.Close()
+
+	// The price has not reached the alert rate.
+	priceAlert, err := tCore.AddAlert(newForm(AlertPrice))
+	if err != nil {
+		t.Fatalf("AddAlert error for price alert: %v", err)
+	}
+	_, err = tCore.AddAlert(newForm(AlertFill))
+	if err != nil {
+		t.Fatalf("AddAlert error for fill alert: %v", err)
+	}
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Price alert"); n != 0 {
+		t.Fatalf("expected no price alerts, got %d", n)
+	}
+	if n := countNotes("Order filled"); n != 0 {
+		t.Fatalf("expected no fill alerts before a fill, got %d", n)
+	}
+	if len(tCore.alertFeeds) != 1 {
+		t.Fatalf("expected 1 book feed, got %d", len(tCore.alertFeeds))
+	}
+
+	// The price has crossed the alert rate. The alert is removed after firing.
+	form = newForm(AlertPrice)
+	form.Rate = rate
+	form.Webhook = srv.URL
+	_, err = tCore.AddAlert(form)
+	if err != nil {
+		t.Fatalf("AddAlert error for met price alert: %v", err)
+	}
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Price alert"); n != 1 {
+		t.Fatalf("expected 1 price alert, got %d", n)
+	}
+	if n := numAlerts(); n != 2 {
+		t.Fatalf("expected 2 alerts after price alert, got %d", n)
+	}
+	select {
+	case note := <-hookNotes:
+		if note.Alert == nil || note.Alert.Rate != rate {
+			t.Fatalf("wrong webhook notification: %+v", note)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no webhook notification")
+	}
+
+	// A fill fires the fill alert, which remains while the order is active.
+	tracker.Trade().AddFill(tDCR.LotSize)
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Order filled"); n != 1 {
+		t.Fatalf("expected 1 fill alert, got %d", n)
+	}
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Order filled"); n != 0 {
+		t.Fatalf("expected no fill alerts without a new fill, got %d", n)
+	}
+	tracker.Trade().AddFill(tDCR.LotSize)
+	tracker.metaData.Status = order.OrderStatusExecuted
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Order filled"); n != 1 {
+		t.Fatalf("expected 1 fill alert for the final fill, got %d", n)
+	}
+	if n := numAlerts(); n != 1 {
+		t.Fatalf("expected 1 alert after order completion, got %d", n)
+	}
+
+	// The match has been waiting for an hour, so a 30 minute swap alert fires,
+	// but only once.
+	_, err = tCore.AddAlert(newForm(AlertSwap))
+	if err != nil {
+		t.Fatalf("AddAlert error for swap alert: %v", err)
+	}
+	form = newForm(AlertSwap)
+	form.Minutes = 90
+	_, err = tCore.AddAlert(form)
+	if err != nil {
+		t.Fatalf("AddAlert error for long swap alert: %v", err)
+	}
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Swap needs attention"); n != 1 {
+		t.Fatalf("expected 1 swap alert, got %d", n)
+	}
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Swap needs attention"); n != 0 {
+		t.Fatalf("expected no repeat swap alerts, got %d", n)
+	}
+	// A match that has been refunded is not waiting.
+	tracker.matches[mid].MetaData.Proof.RefundCoin = encode.RandomBytes(36)
+	tracker.matches[mid].MetaData.Proof.Auth.MatchStamp = encode.UnixMilliU(time.Now().Add(-2 * time.Hour))
+	tCore.checkAlerts(tCtx)
+	if n := countNotes("Swap needs attention"); n != 0 {
+		t.Fatalf("expected no swap alerts for a refunded match, got %d", n)
+	}
+
+	// Remove the remaining alerts. The book feed is no longer needed.
+	if err := tCore.RemoveAlert("zz"); err == nil {
+		t.Fatalf("no error for invalid ID")
+	}
+	alerts, _ := tCore.Alerts()
+	for _, alert := range alerts {
+		if err := tCore.RemoveAlert(alert.ID); err != nil {
+			t.Fatalf("RemoveAlert error: %v", err)
+		}
+	}
+	if err := tCore.RemoveAlert(priceAlert.ID); err == nil {
+		t.Fatalf("no error for removing unknown alert")
+	}
+	tCore.checkAlerts(tCtx)
+	if len(tCore.alertFeeds) != 0 {
+		t.Fatalf("expected no book feeds, got %d", len(tCore.alertFeeds))
+	}
+}
+
+func TestDevicePrompts(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	notes := tCore.NotificationFeed()
+	prompt := tCore.devicePrompter(tDCR.ID)
+
+	nextPrompt := func() *DevicePrompt {
+		t.Helper()
+		select {
+		case n := <-notes:
+			note, ok := n.(*DevicePromptNote)
+			if !ok {
+				t.Fatalf("wrong notification type %T", n)
+			}
+			return note.Prompt
+		case <-time.After(time.Second):
+			t.Fatalf("no device prompt notification")
+		}
+		return nil
+	}
+
+	// A confirmation prompt doesn't wait.
+	if _, err := prompt(&asset.DevicePrompt{Kind: asset.PromptConfirm, Device: "dev", Message: "confirm"}); err != nil {
+		t.Fatalf("confirm prompt error: %v", err)
+	}
+	if p := nextPrompt(); p.Kind != asset.PromptConfirm || p.AssetID != tDCR.ID {
+		t.Fatalf("wrong confirm prompt %+v", p)
+	}
+	if len(tCore.DevicePrompts()) != 0 {
+		t.Fatalf("confirm prompt is pending")
+	}
+
+	// A PIN prompt waits for the response.
+	type result struct {
+		resp string
+		err  error
+	}
+	results := make(chan *result, 1)
+	go func() {
+		resp, err := prompt(&asset.DevicePrompt{Kind: asset.PromptPIN, Device: "dev", Message: "pin"})
+		results <- &result{resp, err}
+	}()
+	p := nextPrompt()
+	if pending := tCore.DevicePrompts(); len(pending) != 1 || pending[0].ID != p.ID {
+		t.Fatalf("wrong pending prompts %+v", pending)
+	}
+	if err := tCore.RespondDevicePrompt("unknown", []byte("1234")); err == nil {
+		t.Fatalf("no error for unknown prompt")
+	}
+	if err := tCore.RespondDevicePrompt(p.ID, []byte("1234")); err != nil {
+		t.Fatalf("RespondDevicePrompt error: %v", err)
+	}
+	if res := <-results; res.err != nil || res.resp != "1234" {
+		t.Fatalf("wrong prompt result %q, %v", res.resp, res.err)
+	}
+	if len(tCore.DevicePrompts()) != 0 {
+		t.Fatalf("answered prompt is pending")
+	}
+
+	// An unanswered prompt times out.
+	defer func(d time.Duration) { devicePromptTimeout = d }(devicePromptTimeout)
+	devicePromptTimeout = 10 * time.Millisecond
+	go func() {
+		resp, err := prompt(&asset.DevicePrompt{Kind: asset.PromptPassphrase, Device: "dev", Message: "passphrase"})
+		results <- &result{resp, err}
+	}()
+	p = nextPrompt()
+	if res := <-results; res.err == nil {
+		t.Fatalf("no error for unanswered prompt")
+	}
+	if err := tCore.RespondDevicePrompt(p.ID, nil); err == nil {
+		t.Fatalf("no error for expired prompt")
+	}
+}
+
+func TestRecoverableSwaps(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+
+	lo, dbOrder, _, _ := makeLimitOrder(rig.dc, true, 0, 0)
+	rig.db.dbOrder = dbOrder
+	swapCoin := encode.RandomBytes(36)
+	newMatch := func(side order.MatchSide) *db.MetaMatch {
+		proof := db.MatchProof{Script: encode.RandomBytes(75)}
+		if side == order.Maker {
+			proof.MakerSwap = swapCoin
+		} else {
+			proof.TakerSwap = swapCoin
+		}
+		return &db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Status: order.TakerSwapCast,
+				Proof:  proof,
+				DEX:    tDexHost,
+				Base:   tDCR.ID,
+				Quote:  tBTC.ID,
+			},
+			Match: &order.UserMatch{
+				OrderID: lo.ID(),
+				MatchID: ordertest.RandomMatchID(),
+				Side:    side,
+			},
+		}
+	}
+	maker := newMatch(order.Maker)
+	taker := newMatch(order.Taker)
+	noSwap := newMatch(order.Taker)
+	noSwap.MetaData.Proof.TakerSwap = nil
+	rig.db.activeMatches = []*db.MetaMatch{maker, taker, noSwap}
+	expiration := time.Now().Truncate(time.Millisecond)
+	tDcrWallet.auditInfo = &tAuditInfo{
+		expiration: expiration,
+		coin:       &tCoin{id: swapCoin, val: 5e8},
+	}
+
+	swaps, err := tCore.RecoverableSwaps()
+	if err != nil {
+		t.Fatalf("RecoverableSwaps error: %v", err)
+	}
+	if len(swaps) != 2 {
+		t.Fatalf("expected 2 swaps, got %d", len(swaps))
+	}
+	swap := swaps[0]
+	if swap.MatchID != maker.Match.MatchID.String() || swap.AssetID != tDCR.ID || swap.Value != 5e8 ||
+		swap.Expiration != encode.UnixMilliU(expiration) || !swap.Refundable || swap.Tracked || swap.Error != "" {
+		t.Fatalf("wrong swap %+v", swap)
+	}
+
+	// A spent contract is not listed.
+	tDcrWallet.auditErr = asset.CoinNotFoundError
+	if swaps, _ = tCore.RecoverableSwaps(); len(swaps) != 0 {
+		t.Fatalf("spent contracts listed")
+	}
+	// Other errors are reported with the swap.
+	tDcrWallet.auditErr = tErr
+	if swaps, _ = tCore.RecoverableSwaps(); len(swaps) != 2 || swaps[0].Error == "" {
+		t.Fatalf("audit error not reported")
+	}
+	tDcrWallet.auditErr = nil
+
+	// Refund the maker's swap.
+	mid := maker.Match.MatchID.String()
+	tDcrWallet.refundCoin = encode.RandomBytes(36)
+	if _, err := tCore.RefundSwap(tPW, "abc"); err == nil {
+		t.Fatalf("no error for bad match ID")
+	}
+	if _, err := tCore.RefundSwap(tPW, ordertest.RandomMatchID().String()); err == nil {
+		t.Fatalf("no error for unknown match")
+	}
+	if _, err := tCore.RefundSwap(tPW, noSwap.Match.MatchID.String()); err == nil {
+		t.Fatalf("no error for match without swap")
+	}
+	tDcrWallet.notExpired = true
+	if _, err := tCore.RefundSwap(tPW, mid); err == nil {
+		t.Fatalf("no error for unexpired contract")
+	}
+	tDcrWallet.notExpired = false
+	tDcrWallet.refundErr = tErr
+	if _, err := tCore.RefundSwap(tPW, mid); err == nil {
+		t.Fatalf("no error for refund error")
+	}
+	tDcrWallet.refundErr = nil
+	if _, err := tCore.RefundSwap(tPW, mid); err != nil {
+		t.Fatalf("RefundSwap error: %v", err)
+	}
+	if rig.db.updatedMatch != maker || !bytes.Equal(maker.MetaData.Proof.RefundCoin, tDcrWallet.refundCoin) {
+		t.Fatalf("refund not stored")
+	}
+	if _, err := tCore.RefundSwap(tPW, mid); err == nil {
+		t.Fatalf("no error for refunding twice")
+	}
+
+	// A match monitored by a loaded trade is refunded by the trade.
+	rig.dc.trades[lo.ID()] = &trackedTrade{
+		matches: map[order.MatchID]*matchTracker{taker.Match.MatchID: {}},
+	}
+	swaps, _ = tCore.RecoverableSwaps()
+	if len(swaps) != 1 || !swaps[0].Tracked {
+		t.Fatalf("tracked match not indicated")
+	}
+	if _, err := tCore.RefundSwap(tPW, taker.Match.MatchID.String()); err == nil {
+		t.Fatalf("no error for refunding tracked match")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.db.initialized = true
+	rig.db.accts = []*db.AccountInfo{{
+		Host:      tDexHost,
+		EncKey:    encode.RandomBytes(32),
+		DEXPubKey: tDexPriv.PubKey(),
+		FeeCoin:   encode.RandomBytes(36),
+		Paid:      true,
+	}}
+	lo, dbOrder, _, _ := makeLimitOrder(rig.dc, true, 0, 0)
+	dbOrder.MetaData.Proof.DEXSig = encode.RandomBytes(73)
+	rig.db.accountOrders = []*db.MetaOrder{dbOrder}
+	rig.db.matchesForOID = []*db.MetaMatch{{
+		MetaData: &db.MatchMetaData{
+			Status: order.MakerSwapCast,
+			DEX:    tDexHost,
+			Base:   tDCR.ID,
+			Quote:  tBTC.ID,
+		},
+		Match: &order.UserMatch{
+			OrderID: lo.ID(),
+			MatchID: ordertest.RandomMatchID(),
+		},
+	}}
+	rig.db.wallets = []*db.Wallet{{
+		AssetID:     tDCR.ID,
+		Account:     "default",
+		Settings:    map[string]string{"rpcuser": "user"},
+		Balance:     &db.Balance{},
+		EncryptedPW: encode.RandomBytes(32),
+		Address:     ordertest.RandomAddress(),
+	}}
+
+	archive, err := tCore.ExportBackup(tPW)
+	if err != nil {
+		t.Fatalf("ExportBackup error: %v", err)
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	_, err = tCore.ExportBackup(tPW)
+	if err == nil {
+		t.Fatalf("no ExportBackup error for wrong password")
+	}
+	rig.crypter.recryptErr = nil
+
+	// The backup can't be restored to an initialized client.
+	err = tCore.RestoreBackup(tPW, archive)
+	if err == nil {
+		t.Fatalf("no error restoring to an initialized client")
+	}
+	encSeed := rig.db.vals[appSeedKey]
+	if len(encSeed) == 0 {
+		t.Fatalf("application seed not created for backup")
+	}
+
+	rig = newTestRig()
+	tCore = rig.core
+	// Decryption error.
+	rig.crypter.decryptErr = tErr
+	err = tCore.RestoreBackup(tPW, archive)
+	if err == nil {
+		t.Fatalf("no error for undecryptable backup")
+	}
+	rig.crypter.decryptErr = nil
+	// Corrupted archive.
+	err = tCore.RestoreBackup(tPW, archive[:len(archive)-10])
+	if err == nil {
+		t.Fatalf("no error for corrupted backup")
+	}
+	rig.db.createdAccts, rig.db.updatedOrders, rig.db.updatedMatch = nil, nil, nil
+
+	err = tCore.RestoreBackup(tPW, archive)
+	if err != nil {
+		t.Fatalf("RestoreBackup error: %v", err)
+	}
+	if !bytes.Equal(rig.db.vals[appSeedKey], encSeed) {
+		t.Fatalf("application seed not restored")
+	}
+	if len(rig.db.createdAccts) != 1 || rig.db.createdAccts[0].Host != tDexHost {
+		t.Fatalf("account not restored")
+	}
+	if len(rig.db.acctProofs) != 1 || rig.db.acctProofs[0].Host != tDexHost {
+		t.Fatalf("account fee payment not restored")
+	}
+	if len(rig.db.updatedOrders) != 1 || rig.db.updatedOrders[0].Order.ID() != lo.ID() {
+		t.Fatalf("order not restored")
+	}
+	if rig.db.updatedMatch == nil || rig.db.updatedMatch.Match.OrderID != lo.ID() {
+		t.Fatalf("match not restored")
+	}
+	if len(rig.db.updatedWallets) != 1 {
+		t.Fatalf("wallet not restored")
+	}
+	w := rig.db.updatedWallets[0]
+	if w.AssetID != tDCR.ID || w.Settings["rpcuser"] != "user" || w.Balance == nil {
+		t.Fatalf("wrong restored wallet %+v", w)
+	}
+}
+
+func TestAppSeed(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	err := tCore.InitializeClient(tPW)
+	if err != nil {
+		t.Fatalf("InitializeClient error: %v", err)
+	}
+	seed := rig.db.vals[appSeedKey]
+	if len(seed) != appSeedSize {
+		t.Fatalf("application seed not stored")
+	}
+	rig.db.initialized = true
+
+	words, err := tCore.ExportSeed(tPW)
+	if err != nil {
+		t.Fatalf("ExportSeed error: %v", err)
+	}
+	reSeed, err := encode.WordsToSeed(words)
+	if err != nil {
+		t.Fatalf("WordsToSeed error: %v", err)
+	}
+	if !bytes.Equal(reSeed, seed) {
+		t.Fatalf("wrong seed words")
+	}
+
+	// An account with a key derived from the seed can be restored.
+	rig.db.accts = []*db.AccountInfo{{Host: tDexHost, EncKey: accountKey(seed, tDexHost).Serialize()}}
+	if _, err = tCore.ExportSeed(tPW); err != nil {
+		t.Fatalf("ExportSeed error with a seed account: %v", err)
+	}
+
+	// An account created before the seed can't be, so the export is refused.
+	legacyKey, _ := secp256k1.GeneratePrivateKey()
+	rig.db.accts = append(rig.db.accts, &db.AccountInfo{Host: "legacy.dex", EncKey: legacyKey.Serialize()})
+	_, err = tCore.ExportSeed(tPW)
+	if err == nil || !strings.Contains(err.Error(), "legacy.dex") {
+		t.Fatalf("no ExportSeed error for an account created before the seed: %v", err)
+	}
+	rig.db.accts = nil
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	_, err = tCore.ExportSeed(tPW)
+	if err == nil {
+		t.Fatalf("no ExportSeed error for wrong password")
+	}
+	rig.crypter.recryptErr = nil
+
+	// Can't restore to an initialized client.
+	err = tCore.RestoreFromSeed(tPW, words)
+	if err == nil {
+		t.Fatalf("no error restoring seed to an initialized client")
+	}
+
+	// Account keys are derived from the seed, and differ by host.
+	acctKey := accountKey(seed, tDexHost)
+	if !bytes.Equal(accountKey(reSeed, tDexHost).Serialize(), acctKey.Serialize()) {
+		t.Fatalf("account key not deterministic")
+	}
+	if bytes.Equal(accountKey(seed, "other.dex").Serialize(), acctKey.Serialize()) {
+		t.Fatalf("same account key for different hosts")
+	}
+	if bytes.Equal(walletSeed(seed, tDCR.ID), walletSeed(seed, tBTC.ID)) {
+		t.Fatalf("same wallet seed for different assets")
+	}
+
+	// A built-in wallet is created with the derived seed and a generated
+	// password. The settings are checked against the options of the real BTC
+	// driver, since unknown settings are dropped before the check.
+	btcInfo := (&btc.Driver{}).Info()
+	checkBuiltIn := func(assetID uint32, configText string, builtIn bool) {
+		t.Helper()
+		wallet, _ := newTWallet(assetID)
+		var cfgSeed []byte
+		asset.Register(assetID, &tDriver{
+			f: func(wCfg *asset.WalletConfig, logger dex.Logger, net dex.Network) (asset.Wallet, error) {
+				cfgSeed = append([]byte(nil), wCfg.Seed...)
+				return wallet.Wallet, nil
+			},
+			decoder: func(coinID []byte) (string, error) {
+				return asset.DecodeCoinID(tBTC.ID, coinID)
+			},
+			winfo: btcInfo,
+		})
+		var walletPW []byte
+		if !builtIn {
+			walletPW = []byte(wPW)
+		}
+		err := tCore.CreateWallet(tPW, walletPW, &WalletForm{
+			AssetID:    assetID,
+			ConfigText: configText,
+		})
+		if err != nil {
+			t.Fatalf("CreateWallet error for %q: %v", configText, err)
+		}
+		if !builtIn {
+			if cfgSeed != nil {
+				t.Fatalf("seed provided for %q", configText)
+			}
+			return
+		}
+		if !bytes.Equal(cfgSeed, walletSeed(seed, assetID)) {
+			t.Fatalf("built-in wallet not created with the derived seed for %q", configText)
+		}
+		w := rig.db.updatedWallets[len(rig.db.updatedWallets)-1]
+		if len(w.EncryptedPW) == 0 {
+			t.Fatalf("no password generated for built-in wallet for %q", configText)
+		}
+	}
+	checkBuiltIn(0xfff0, "spv=1", true)
+	checkBuiltIn(0xfff1, "electrum=127.0.0.1:50001", true)
+	checkBuiltIn(0xfff2, "spv=0\nrpcuser=user", false)
+
+	// Restore to a new client.
+	rig = newTestRig()
+	tCore = rig.core
+	err = tCore.RestoreFromSeed(tPW, "not a seed")
+	if err == nil {
+		t.Fatalf("no error for invalid seed words")
+	}
+	err = tCore.RestoreFromSeed(tPW, words)
+	if err != nil {
+		t.Fatalf("RestoreFromSeed error: %v", err)
+	}
+	if !bytes.Equal(rig.db.vals[appSeedKey], seed) {
+		t.Fatalf("seed not restored")
+	}
+}
+
+func TestResync(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+
+	resynced := make(chan *ConnEventNote, 1)
+	notes := tCore.NotificationFeed()
+	go func() {
+		for n := range notes {
+			if note, ok := n.(*ConnEventNote); ok && note.Subject() == "DEX resynced" {
+				resynced <- note
+				return
+			}
+		}
+	}()
+
+	// Subscribe to the order book.
+	oid1 := ordertest.RandomOrderID()
+	rig.ws.queueResponse(msgjson.OrderBookRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, &msgjson.OrderBook{
+			Seq:      1,
+			MarketID: tDcrBtcMktName,
+			Orders: []*msgjson.BookOrderNote{{
+				TradeNote: msgjson.TradeNote{Side: msgjson.BuyOrderNum, Quantity: 10, Rate: 2},
+				OrderNote: msgjson.OrderNote{Seq: 1, MarketID: tDcrBtcMktName, OrderID: oid1[:]},
+			}},
+		}, nil)
+		f(resp)
+		return nil
+	})
+	_, feed, err := tCore.Sync(tDexHost, tDCR.ID, tBTC.ID)
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	defer feed.Close()
+
+	// A booked order with a taker match awaiting the maker's swap, and a maker
+	// match awaiting the taker's swap.
+	matchSize := 4 * tDCR.LotSize
+	rate := tBTC.RateStep * 10
+	lo, dbOrder, preImg, addr := makeLimitOrder(dc, true, 4*matchSize, rate)
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	oid := lo.ID()
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen,
+		tCore.lockTimeTaker, tCore.lockTimeMaker, rig.db, rig.queue, walletSet, nil, tCore.notify)
+	dc.trades[oid] = tracker
+	matchTime := time.Now()
+	newMatch := func(side order.MatchSide, status order.MatchStatus) *matchTracker {
+		mid := ordertest.RandomMatchID()
+		match := &matchTracker{
+			id:     mid,
+			prefix: lo.Prefix(),
+			trade:  lo.Trade(),
+			MetaMatch: db.MetaMatch{
+				MetaData: &db.MatchMetaData{
+					Status: status,
+					Proof: db.MatchProof{
+						Auth: db.MatchAuth{MatchStamp: encode.UnixMilliU(matchTime)},
+					},
+					DEX:   tDexHost,
+					Base:  tDCR.ID,
+					Quote: tBTC.ID,
+				},
+				Match: &order.UserMatch{
+					OrderID:  oid,
+					MatchID:  mid,
+					Quantity: matchSize,
+					Rate:     rate,
+					Address:  "counterparty-address",
+					Status:   status,
+					Side:     side,
+				},
+			},
+		}
+		tracker.matches[mid] = match
+		return match
+	}
+	takerMatch := newMatch(order.Taker, order.NewlyMatched)
+	makerMatch := newMatch(order.Maker, order.MakerSwapCast)
+	makerMatch.MetaData.Proof.MakerSwap = encode.RandomBytes(36)
+	msgMatch := func(mid order.MatchID) *msgjson.Match {
+		m := &msgjson.Match{
+			OrderID:    oid[:],
+			MatchID:    mid[:],
+			Quantity:   matchSize,
+			Rate:       rate,
+			Address:    "counterparty-address",
+			Side:       uint8(order.Taker),
+			ServerTime: encode.UnixMilliU(matchTime),
+		}
+		sign(tDexPriv, m)
+		return m
+	}
+
+	// The server reports a match that was missed while disconnected.
+	extraID := ordertest.RandomMatchID()
+	rig.ws.queueResponse(msgjson.ConnectRoute, func(msg *msgjson.Message, f msgFunc) error {
+		connect := new(msgjson.Connect)
+		msg.Unmarshal(connect)
+		sign(tDexPriv, connect)
+		result := &msgjson.ConnectResult{
+			Sig:     connect.Sig,
+			Matches: []*msgjson.Match{msgMatch(takerMatch.id), msgMatch(extraID)},
+		}
+		resp, _ := msgjson.NewResponse(msg.ID, result, nil)
+		f(resp)
+		return nil
+	})
+
+	// The order book has changed.
+	oid2 := ordertest.RandomOrderID()
+	rig.ws.queueResponse(msgjson.OrderBookRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, &msgjson.OrderBook{
+			Seq:      5,
+			MarketID: tDcrBtcMktName,
+			Orders: []*msgjson.BookOrderNote{{
+				TradeNote: msgjson.TradeNote{Side: msgjson.SellOrderNum, Quantity: 10, Rate: 3},
+				OrderNote: msgjson.OrderNote{Seq: 5, MarketID: tDcrBtcMktName, OrderID: oid2[:]},
+			}},
+		}, nil)
+		f(resp)
+		return nil
+	})
+
+	// The order was fully matched.
+	rig.ws.queueResponse(msgjson.OrderStatusRoute, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, []*msgjson.OrderStatus{{
+			ID:     oid[:],
+			Status: uint16(order.OrderStatusExecuted),
+		}}, nil)
+		f(resp)
+		return nil
+	})
+
+	// The maker swapped on the taker match, and the maker match was revoked.
+	audit, auditInfo := tMsgAudit(oid, takerMatch.id, addr, calc.BaseToQuote(rate, matchSize), nil)
+	auditInfo.expiration = encode.DropMilliseconds(matchTime.Add(tracker.lockTimeMaker))
+	tBtcWallet.auditInfo = auditInfo
+	var matchReqs []*msgjson.MatchStatusRequest
+	rig.ws.queueResponse(msgjson.MatchStatusRoute, func(msg *msgjson.Message, f msgFunc) error {
+		msg.Unmarshal(&matchReqs)
+		resp, _ := msgjson.NewResponse(msg.ID, []*msgjson.MatchStatusResult{{
+			MatchID: takerMatch.id[:],
+			Status:  uint8(order.MakerSwapCast),
+			Active:  true,
+			Match:   msgMatch(takerMatch.id),
+			Audit:   audit,
+		}, {
+			MatchID: makerMatch.id[:],
+			Status:  uint8(order.MakerSwapCast),
+			Match:   msgMatch(makerMatch.id),
+		}, {
+			MatchID: extraID[:],
+			Status:  uint8(order.NewlyMatched),
+			Active:  true,
+			Match:   msgMatch(extraID),
+		}}, nil)
+		f(resp)
+		return nil
+	})
+
+	tCore.handleReconnect(tDexHost)
+
+	var note *ConnEventNote
+	select {
+	case note = <-resynced:
+	case <-time.After(time.Second):
+		t.Fatalf("no resynced notification")
+	}
+	if note.Severity() != db.Success {
+		t.Fatalf("resync had errors: %s", note.Details())
+	}
+	if !strings.Contains(note.Details(), "1 order books refreshed, 1 orders updated, 2 matches updated, 1 matches revoked") {
+		t.Fatalf("wrong resync details: %s", note.Details())
+	}
+	if len(matchReqs) != 3 {
+		t.Fatalf("expected 3 match status requests, got %d", len(matchReqs))
+	}
+
+	// The subscriber receives the fresh order book.
+	var update *BookUpdate
+out:
+	for {
+		select {
+		case update = <-feed.C:
+			if update.Action == FreshBookAction {
+				break out
+			}
+		default:
+			t.Fatalf("no fresh order book")
+		}
+	}
+	mktBook, ok := update.Payload.(*MarketOrderBook)
+	if !ok {
+		t.Fatalf("wrong fresh book payload type %T", update.Payload)
+	}
+	if len(mktBook.Book.Sells) != 1 || len(mktBook.Book.Buys) != 0 {
+		t.Fatalf("fresh book not synced")
+	}
+
+	if tracker.metaData.Status != order.OrderStatusExecuted {
+		t.Fatalf("order status not updated. got %s", tracker.metaData.Status)
+	}
+	if takerMatch.Match.Status != order.MakerSwapCast {
+		t.Fatalf("missed audit not replayed. status = %s", takerMatch.Match.Status)
+	}
+	if !bytes.Equal(takerMatch.MetaData.Proof.MakerSwap, audit.CoinID) {
+		t.Fatalf("maker swap not recorded")
+	}
+	if !makerMatch.MetaData.Proof.IsRevoked {
+		t.Fatalf("inactive match not revoked")
+	}
+	if _, found := tracker.matches[extraID]; !found {
+		t.Fatalf("missed match not negotiated")
+	}
+}
+
+func TestOrdersAndMatches(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.db.accts = []*db.AccountInfo{{Host: tDexHost}}
+
+	newOrder := func(status order.OrderStatus, stamp time.Time) *db.MetaOrder {
+		lo, dbOrder, _, _ := makeLimitOrder(rig.dc, true, tDCR.LotSize, tBTC.RateStep)
+		lo.ServerTime = stamp
+		dbOrder.MetaData.Status = status
+		return dbOrder
+	}
+	now := time.Now()
+	booked := newOrder(order.OrderStatusBooked, now)
+	executed := newOrder(order.OrderStatusExecuted, now.Add(-time.Hour))
+	canceled := newOrder(order.OrderStatusCanceled, now.Add(-2*time.Hour))
+	rig.db.accountOrders = []*db.MetaOrder{executed, booked, canceled}
+	matchStamp := encode.UnixMilliU(now.Add(-time.Hour))
+	rig.db.matchesForOID = []*db.MetaMatch{{
+		MetaData: &db.MatchMetaData{
+			Proof: db.MatchProof{Auth: db.MatchAuth{MatchStamp: matchStamp}},
+		},
+		Match: &order.UserMatch{
+			MatchID:  ordertest.RandomMatchID(),
+			Quantity: tDCR.LotSize,
+			Status:   order.MatchComplete,
+		},
+	}}
+
+	// All orders, newest first.
+	ords, err := tCore.Orders(&OrderFilter{})
+	if err != nil {
+		t.Fatalf("Orders error: %v", err)
+	}
+	if len(ords) != 3 || ords[0].ID != booked.Order.ID().String() || ords[2].ID != canceled.Order.ID().String() {
+		t.Fatalf("wrong orders")
+	}
+	if len(ords[0].Matches) != 1 || ords[0].Matches[0].Stamp != matchStamp {
+		t.Fatalf("matches not loaded")
+	}
+
+	// Status filter.
+	ords, _ = tCore.Orders(&OrderFilter{Statuses: []order.OrderStatus{order.OrderStatusExecuted, order.OrderStatusCanceled}})
+	if len(ords) != 2 || ords[0].ID != executed.Order.ID().String() {
+		t.Fatalf("wrong orders for status filter")
+	}
+
+	// Time range.
+	ords, _ = tCore.Orders(&OrderFilter{
+		Since: encode.UnixMilliU(now.Add(-90 * time.Minute)),
+		Until: encode.UnixMilliU(now.Add(-time.Minute)),
+	})
+	if len(ords) != 1 || ords[0].ID != executed.Order.ID().String() {
+		t.Fatalf("wrong orders for time range")
+	}
+
+	// Market, host, and limit.
+	ords, _ = tCore.Orders(&OrderFilter{Market: "abc_xyz"})
+	if len(ords) != 0 {
+		t.Fatalf("orders returned for unknown market")
+	}
+	ords, _ = tCore.Orders(&OrderFilter{Host: "other.tld"})
+	if len(ords) != 0 {
+		t.Fatalf("orders returned for unknown host")
+	}
+	ords, _ = tCore.Orders(&OrderFilter{Market: tDcrBtcMktName, N: 1})
+	if len(ords) != 1 || ords[0].ID != booked.Order.ID().String() {
+		t.Fatalf("wrong orders for limit")
+	}
+
+	// Matches are filtered by match time.
+	matches, err := tCore.Matches(&OrderFilter{Since: matchStamp})
+	if err != nil {
+		t.Fatalf("Matches error: %v", err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 matches, got %d", len(matches))
+	}
+	matches, _ = tCore.Matches(&OrderFilter{Since: matchStamp + 1})
+	if len(matches) != 0 {
+		t.Fatalf("matches returned before match time")
+	}
+
+	// Single order.
+	rig.db.dbOrder = booked
+	ord, err := tCore.Order(booked.Order.ID().String())
+	if err != nil {
+		t.Fatalf("Order error: %v", err)
+	}
+	if ord.Status != order.OrderStatusBooked {
+		t.Fatalf("wrong order status %s", ord.Status)
+	}
+	if _, err := tCore.Order("abc"); err == nil {
+		t.Fatalf("no error for invalid order ID")
+	}
+
+	// Notes and tags.
+	booked.MetaData.Notes, booked.MetaData.Tags = "placed by my Bot", []string{"bot"}
+	ords, _ = tCore.Orders(&OrderFilter{Tags: []string{"bot", "dca"}})
+	if len(ords) != 1 || ords[0].ID != booked.Order.ID().String() || ords[0].Notes != booked.MetaData.Notes {
+		t.Fatalf("wrong orders for tags filter")
+	}
+	ords, _ = tCore.Orders(&OrderFilter{Search: "my bot"})
+	if len(ords) != 1 || ords[0].ID != booked.Order.ID().String() {
+		t.Fatalf("wrong orders for search")
+	}
+	ords, _ = tCore.Orders(&OrderFilter{Search: "manual"})
+	if len(ords) != 0 {
+		t.Fatalf("orders returned for unmatched search")
+	}
+	// A match is selected by the tags of its order or its own.
+	rig.db.matchesForOID[0].MetaData.Tags = []string{"hedge"}
+	matches, _ = tCore.Matches(&OrderFilter{Tags: []string{"bot"}})
+	if len(matches) != 1 || matches[0].OrderID != booked.Order.ID().String() {
+		t.Fatalf("wrong matches for order tags filter")
+	}
+	matches, _ = tCore.Matches(&OrderFilter{Tags: []string{"hedge"}, Search: "bot"})
+	if len(matches) != 1 || matches[0].OrderID != booked.Order.ID().String() {
+		t.Fatalf("wrong matches for match tags filter")
+	}
+}
+
+func TestSetOrderNotes(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+
+	_, dbOrder, preImg, _ := makeLimitOrder(dc, true, tDCR.LotSize, tBTC.RateStep)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen,
+		tCore.lockTimeTaker, tCore.lockTimeMaker, rig.db, rig.queue, nil, nil, tCore.notify)
+	oid := tracker.ID()
+	mid := ordertest.RandomMatchID()
+	tracker.matches[mid] = &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{},
+			Match:    &order.UserMatch{MatchID: mid, OrderID: oid},
+		},
+	}
+	dc.trades[oid] = tracker
+
+	// Tags are trimmed and deduplicated, and the active trade is updated.
+	err := tCore.SetOrderNotes(oid.String(), "manual", []string{" dca", "dca", ""})
+	if err != nil {
+		t.Fatalf("SetOrderNotes error: %v", err)
+	}
+	if rig.db.orderNotes[oid] != "manual" {
+		t.Fatalf("notes not stored")
+	}
+	corder, _ := tracker.coreOrder()
+	if corder.Notes != "manual" || !reflect.DeepEqual(corder.Tags, []string{"dca"}) {
+		t.Fatalf("wrong notes %q and tags %v", corder.Notes, corder.Tags)
+	}
+
+	// Invalid notes and tags.
+	if err = tCore.SetOrderNotes(oid.String(), strings.Repeat("a", maxNotesLen+1), nil); err == nil {
+		t.Fatalf("no error for long notes")
+	}
+	if err = tCore.SetOrderNotes(oid.String(), "", []string{strings.Repeat("a", maxTagLen+1)}); err == nil {
+		t.Fatalf("no error for long tag")
+	}
+	if err = tCore.SetOrderNotes("abc", "manual", nil); err == nil {
+		t.Fatalf("no error for invalid order ID")
+	}
+	rig.db.notesErr = tErr
+	if err = tCore.SetOrderNotes(oid.String(), "manual", nil); err == nil {
+		t.Fatalf("no error for DB error")
+	}
+	rig.db.notesErr = nil
+
+	// Match notes.
+	err = tCore.SetMatchNotes(oid.String(), mid.String(), "hedged", []string{"arb"})
+	if err != nil {
+		t.Fatalf("SetMatchNotes error: %v", err)
+	}
+	if rig.db.matchNotes[mid] != "hedged" {
+		t.Fatalf("match notes not stored")
+	}
+	corder, _ = tracker.coreOrder()
+	if len(corder.Matches) != 1 || corder.Matches[0].Notes != "hedged" {
+		t.Fatalf("match notes not set for active trade")
+	}
+	if err = tCore.SetMatchNotes(oid.String(), "abc", "hedged", nil); err == nil {
+		t.Fatalf("no error for invalid match ID")
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dc := rig.dc
+
+	addTrade := func(status order.OrderStatus) *trackedTrade {
+		_, dbOrder, preImg, _ := makeLimitOrder(dc, true, tDCR.LotSize, tBTC.RateStep)
+		dbOrder.MetaData.Status = status
+		tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen,
+			tCore.lockTimeTaker, tCore.lockTimeMaker, rig.db, rig.queue, nil, nil, tCore.notify)
+		dc.trades[tracker.ID()] = tracker
+		return tracker
+	}
+	booked := addTrade(order.OrderStatusBooked)
+	addTrade(order.OrderStatusEpoch)
+
+	// No orders for another market.
+	ids, err := tCore.CancelAll(tPW, &OrderFilter{Market: "abc_xyz"})
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("unexpected cancellations for unknown market: %v, %v", ids, err)
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	_, err = tCore.CancelAll(tPW, &OrderFilter{})
+	rig.crypter.recryptErr = nil
+	if err == nil {
+		t.Fatalf("no error for password error")
+	}
+
+	// Only the booked order is canceled.
+	handleCancel := func(msg *msgjson.Message, f msgFunc) error {
+		t.Helper()
+		// Need to stamp and sign the message with the server's key.
+		msgOrder := new(msgjson.CancelOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		co := convertMsgCancelOrder(msgOrder)
+		f(orderResponse(msg.ID, msgOrder, co, false, false, false))
+		return nil
+	}
+	rig.ws.queueResponse(msgjson.CancelRoute, handleCancel)
+	ids, err = tCore.CancelAll(tPW, &OrderFilter{Host: tDexHost, Market: tDcrBtcMktName})
+	if err != nil {
+		t.Fatalf("CancelAll error: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != booked.ID().String() {
+		t.Fatalf("wrong canceled orders %v", ids)
+	}
+	if booked.cancel == nil {
+		t.Fatalf("cancel order not tracked")
+	}
+
+	// Already cancelling.
+	ids, err = tCore.CancelAll(tPW, &OrderFilter{})
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("unexpected cancellations: %v, %v", ids, err)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.dc.connected = true
+
+	binSize := 5 * time.Minute
+	binMs := uint64(binSize / time.Millisecond)
+	start := 100 * binMs
+	end := start + 10*binMs
+	msgCandle := func(i uint64) *msgjson.Candle {
+		return &msgjson.Candle{
+			StartStamp:  start + i*binMs,
+			EndStamp:    start + (i+1)*binMs,
+			MatchVolume: tDCR.LotSize * (i + 1),
+			HighRate:    2e6 + i,
+			LowRate:     1e6 + i,
+			StartRate:   1.5e6,
+			EndRate:     1.5e6 + i,
+		}
+	}
+	var lastReq *msgjson.CandlesRequest
+	queueCandles := func(candles ...*msgjson.Candle) {
+		rig.ws.queueResponse(msgjson.CandlesRoute, func(msg *msgjson.Message, f msgFunc) error {
+			lastReq = new(msgjson.CandlesRequest)
+			msg.Unmarshal(lastReq)
+			resp, _ := msgjson.NewResponse(msg.ID, candles, nil)
+			f(resp)
+			return nil
+		})
+	}
+
+	// Unsupported bin size.
+	_, err := tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, time.Minute, start, end)
+	if err == nil {
+		t.Fatalf("no error for unsupported bin size")
+	}
+	// Unknown DEX.
+	_, err = tCore.Candles("unknown.tld", tDCR.ID, tBTC.ID, binSize, start, end)
+	if err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+	// Unknown market.
+	_, err = tCore.Candles(tDexHost, tBTC.ID, tDCR.ID, binSize, start, end)
+	if err == nil {
+		t.Fatalf("no error for unknown market")
+	}
+	// Request error with nothing cached.
+	rig.ws.reqErr = tErr
+	_, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, binSize, start, end)
+	if err == nil {
+		t.Fatalf("no error for request error with empty cache")
+	}
+	rig.ws.reqErr = nil
+
+	// The initial request is for the whole range, and the candles are cached.
+	// The start is rounded down to the bin.
+	queueCandles(msgCandle(0), msgCandle(1), msgCandle(2))
+	candles, err := tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, binSize, start+1, end)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if lastReq.Start != start || lastReq.End != end || lastReq.BinSize != binMs {
+		t.Fatalf("wrong request %+v", lastReq)
+	}
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles, got %d", len(candles))
+	}
+	cached, _ := rig.db.Candles(tDexHost, tDCR.ID, tBTC.ID, binMs, start, end)
+	if len(cached) != 3 {
+		t.Fatalf("expected 3 cached candles, got %d", len(cached))
+	}
+
+	// The next request starts at the last cached candle, which is updated.
+	updated := msgCandle(2)
+	updated.MatchVolume *= 2
+	queueCandles(updated, msgCandle(3))
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, binSize, start, end)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if lastReq.Start != msgCandle(2).StartStamp {
+		t.Fatalf("expected request to start at %d, got %d", msgCandle(2).StartStamp, lastReq.Start)
+	}
+	if len(candles) != 4 {
+		t.Fatalf("expected 4 candles, got %d", len(candles))
+	}
+	if candles[2].MatchVolume != updated.MatchVolume {
+		t.Fatalf("cached candle not updated")
+	}
+	for i := 1; i < len(candles); i++ {
+		if candles[i].StartStamp <= candles[i-1].StartStamp {
+			t.Fatalf("candles not sorted")
+		}
+	}
+
+	// A request error serves the cached candles.
+	rig.ws.reqErr = tErr
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, binSize, start, end)
+	if err != nil {
+		t.Fatalf("error for request error with cached candles: %v", err)
+	}
+	if len(candles) != 4 {
+		t.Fatalf("expected 4 cached candles after request error, got %d", len(candles))
+	}
+	rig.ws.reqErr = nil
+
+	// Disconnected serves the cached candles without a request.
+	rig.dc.connected = false
+	lastReq = nil
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, binSize, start, end)
+	if err != nil {
+		t.Fatalf("error for disconnected DEX: %v", err)
+	}
+	if len(candles) != 4 || lastReq != nil {
+		t.Fatalf("expected 4 cached candles and no request while disconnected")
+	}
+}
+
+func TestSpotsAndDepth(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	lot := tDCR.LotSize
+	bookNote := func(sell bool, qty, rate uint64) *msgjson.BookOrderNote {
+		var side uint8 = msgjson.BuyOrderNum
+		if sell {
+			side = msgjson.SellOrderNum
+		}
+		return &msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  encode.RandomBytes(32),
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     side,
+				Quantity: qty,
+				Rate:     rate,
+			},
+		}
+	}
+
+	_, err := tCore.Depth(tDexHost, tDCR.ID, tBTC.ID)
+	if err == nil {
+		t.Fatalf("no error for unsynced book")
+	}
+
+	book := newBookie(func() {})
+	err = book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Orders: []*msgjson.BookOrderNote{
+			bookNote(true, lot, 2e6),
+			bookNote(true, lot*2, 2e6),
+			bookNote(true, lot, 3e6),
+			bookNote(false, lot*3, 1e6),
+		},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+	rig.dc.books[tDcrBtcMktName] = book
+	feed := book.feed()
+	defer feed.Close()
+
+	chart, err := tCore.Depth(tDexHost, tDCR.ID, tBTC.ID)
+	if err != nil {
+		t.Fatalf("Depth error: %v", err)
+	}
+	if len(chart.Sells) != 2 || len(chart.Buys) != 1 {
+		t.Fatalf("expected 2 sell points and 1 buy point, got %d and %d", len(chart.Sells), len(chart.Buys))
+	}
+	lotF := float64(lot) / conversionFactor
+	if chart.Sells[0].Qty != lotF*3 || chart.Sells[1].Depth != lotF*4 {
+		t.Fatalf("wrong sell depth %+v, %+v", chart.Sells[0], chart.Sells[1])
+	}
+	if chart.MidGap != 0.015 {
+		t.Fatalf("wrong mid-gap %f", chart.MidGap)
+	}
+
+	spot := &msgjson.Spot{
+		Stamp:   1,
+		BaseID:  tDCR.ID,
+		QuoteID: tBTC.ID,
+		Rate:    2e6,
+		Vol24:   lot,
+	}
+	note, _ := msgjson.NewNotification(msgjson.SpotsRoute, []*msgjson.Spot{spot})
+	err = handleSpotsMsg(tCore, rig.dc, note)
+	if err != nil {
+		t.Fatalf("handleSpotsMsg error: %v", err)
+	}
+	spots, err := tCore.Spots(tDexHost)
+	if err != nil {
+		t.Fatalf("Spots error: %v", err)
+	}
+	if s := spots[tDcrBtcMktName]; s == nil || s.Rate != spot.Rate || s.Vol24 != spot.Vol24 {
+		t.Fatalf("wrong spot %+v", s)
+	}
+	select {
+	case u := <-feed.C:
+		if u.Action != msgjson.SpotsRoute || u.MarketID != tDcrBtcMktName {
+			t.Fatalf("wrong book update %+v", u)
+		}
+	default:
+		t.Fatalf("no book update for spot")
+	}
+}
+
+func TestPaperTrading(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	lot := tDCR.LotSize
+
+	_, err := tCore.PaperTrade(&TradeForm{Host: tDexHost})
+	if err == nil {
+		t.Fatalf("no error for paper trade without a session")
+	}
+	err = tCore.StartPaperTrading(&PaperForm{
+		Host:     tDexHost,
+		Balances: map[uint32]uint64{12345: 1},
+	})
+	if err == nil {
+		t.Fatalf("no error for unsupported asset")
+	}
+	err = tCore.StartPaperTrading(&PaperForm{
+		Host:     tDexHost,
+		Balances: map[uint32]uint64{tDCR.ID: 10e8, tBTC.ID: 1e8},
+	})
+	if err != nil {
+		t.Fatalf("StartPaperTrading error: %v", err)
+	}
+	if err = tCore.StartPaperTrading(&PaperForm{Host: tDexHost}); err == nil {
+		t.Fatalf("no error for second paper trading session")
+	}
+
+	bookNote := func(sell bool, qty, rate uint64) *msgjson.BookOrderNote {
+		var side uint8 = msgjson.BuyOrderNum
+		if sell {
+			side = msgjson.SellOrderNum
+		}
+		return &msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				MarketID: tDcrBtcMktName,
+				OrderID:  encode.RandomBytes(32),
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     side,
+				Quantity: qty,
+				Rate:     rate,
+			},
+		}
+	}
+	book := newBookie(func() {})
+	err = book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Orders: []*msgjson.BookOrderNote{
+			bookNote(true, lot*2, 2e6),
+			bookNote(true, lot*3, 3e6),
+			bookNote(false, lot*2, 1e6),
+		},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+	rig.dc.books[tDcrBtcMktName] = book
+
+	limitBuy := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     lot * 4,
+		Rate:    2.5e6,
+	}
+	// Bad forms.
+	badForm := *limitBuy
+	badForm.Host = "otherdex.tld"
+	if _, err = tCore.PaperTrade(&badForm); err == nil {
+		t.Fatalf("no error for wrong host")
+	}
+	badForm = *limitBuy
+	badForm.Qty = lot + 1
+	if _, err = tCore.PaperTrade(&badForm); err == nil {
+		t.Fatalf("no error for bad lot size")
+	}
+	badForm = *limitBuy
+	badForm.Qty = lot * 1000
+	if _, err = tCore.PaperTrade(&badForm); err == nil {
+		t.Fatalf("no error for insufficient balance")
+	}
+
+	checkBalance := func(assetID uint32, avail, locked uint64) {
+		t.Helper()
+		acct, err := tCore.PaperAccount()
+		if err != nil {
+			t.Fatalf("PaperAccount error: %v", err)
+		}
+		bal := acct.Balances[assetID]
+		if bal.Available != avail || bal.Locked != locked {
+			t.Fatalf("wrong %s balance. expected %d/%d, got %d/%d",
+				unbip(assetID), avail, locked, bal.Available, bal.Locked)
+		}
+	}
+	checkOrder := func(id string, status order.OrderStatus, filled uint64) {
+		t.Helper()
+		acct, _ := tCore.PaperAccount()
+		for _, ord := range acct.Orders {
+			if ord.ID == id {
+				if ord.Status != status || ord.Filled != filled {
+					t.Fatalf("expected order status %s and filled %d, got %s and %d", status, filled, ord.Status, ord.Filled)
+				}
+				return
+			}
+		}
+		t.Fatalf("paper order %s not found", id)
+	}
+
+	buy, err := tCore.PaperTrade(limitBuy)
+	if err != nil {
+		t.Fatalf("PaperTrade error: %v", err)
+	}
+	// 4 lots at 0.025 BTC is 0.01 BTC.
+	checkBalance(tBTC.ID, 99e6, 1e6)
+	epochLen := rig.dc.market(tDcrBtcMktName).EpochLen
+	nextEpoch := func(i uint64) uint64 { return (buy.Epoch + i) * epochLen }
+
+	// Nothing happens until the epoch closes.
+	tCore.checkPaperOrders(buy.Epoch * epochLen)
+	checkOrder(buy.ID, order.OrderStatusEpoch, 0)
+
+	// The order takes the 2 lots at 0.02, and the rest is booked. The excess
+	// locked for the filled lots is returned.
+	tCore.checkPaperOrders(nextEpoch(1))
+	checkOrder(buy.ID, order.OrderStatusBooked, lot*2)
+	checkBalance(tBTC.ID, 99.1e6, 5e5)
+	checkBalance(tDCR.ID, 10e8+lot*2, 0)
+
+	// The consumed sell order is not matched again.
+	tCore.checkPaperOrders(nextEpoch(1))
+	checkOrder(buy.ID, order.OrderStatusBooked, lot*2)
+
+	// A market sell takes 1 lot from the buy order at 0.01.
+	sell, err := tCore.PaperTrade(&TradeForm{
+		Host:  tDexHost,
+		Sell:  true,
+		Base:  tDCR.ID,
+		Quote: tBTC.ID,
+		Qty:   lot,
+	})
+	if err != nil {
+		t.Fatalf("PaperTrade market sell error: %v", err)
+	}
+	checkBalance(tDCR.ID, 10e8+lot, lot)
+	tCore.checkPaperOrders((sell.Epoch + 1) * epochLen)
+	checkOrder(sell.ID, order.OrderStatusExecuted, lot)
+	checkBalance(tBTC.ID, 99.2e6, 5e5)
+
+	// An immediate limit sell of 3 lots takes the remaining lot, and the rest is
+	// returned.
+	immSell, err := tCore.PaperTrade(&TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     lot * 3,
+		Rate:    1e6,
+		TifNow:  true,
+	})
+	if err != nil {
+		t.Fatalf("PaperTrade immediate limit sell error: %v", err)
+	}
+	checkBalance(tDCR.ID, 10e8-lot*2, lot*3)
+	tCore.checkPaperOrders((immSell.Epoch + 1) * epochLen)
+	checkOrder(immSell.ID, order.OrderStatusExecuted, lot)
+	checkBalance(tDCR.ID, 10e8, 0)
+	checkBalance(tBTC.ID, 99.3e6, 5e5)
+
+	// A market buy of 0.005 BTC takes 1 lot at 0.03.
+	mktBuy, err := tCore.PaperTrade(&TradeForm{
+		Host:  tDexHost,
+		Base:  tDCR.ID,
+		Quote: tBTC.ID,
+		Qty:   5e5,
+	})
+	if err != nil {
+		t.Fatalf("PaperTrade market buy error: %v", err)
+	}
+	tCore.checkPaperOrders((mktBuy.Epoch + 1) * epochLen)
+	checkOrder(mktBuy.ID, order.OrderStatusExecuted, 3e5)
+	checkBalance(tBTC.ID, 99e6, 5e5)
+	checkBalance(tDCR.ID, 10e8+lot, 0)
+
+	// Only standing limit orders can be canceled.
+	if err = tCore.PaperCancel(mktBuy.ID); err == nil {
+		t.Fatalf("no error for canceling a market order")
+	}
+	if err = tCore.PaperCancel(buy.ID); err != nil {
+		t.Fatalf("PaperCancel error: %v", err)
+	}
+	if err = tCore.PaperCancel(buy.ID); err == nil {
+		t.Fatalf("no error for canceling twice")
+	}
+	// The cancel takes effect after the epoch closes.
+	cancelEpoch := encode.UnixMilliU(time.Now()) / epochLen
+	tCore.checkPaperOrders(cancelEpoch * epochLen)
+	checkOrder(buy.ID, order.OrderStatusBooked, lot*2)
+	tCore.checkPaperOrders((cancelEpoch + 1) * epochLen)
+	checkOrder(buy.ID, order.OrderStatusCanceled, lot*2)
+	checkBalance(tBTC.ID, 99.5e6, 0)
+
+	if err = tCore.StopPaperTrading(); err != nil {
+		t.Fatalf("StopPaperTrading error: %v", err)
+	}
+	if _, err = tCore.PaperAccount(); err == nil {
+		t.Fatalf("no error for paper account after stopping")
+	}
+	if err = tCore.StopPaperTrading(); err == nil {
+		t.Fatalf("no error for stopping twice")
+	}
+}
+
+func TestNoteRoutes(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	feed := tCore.NotificationFeed()
+	numSaved := func() int {
+		rig.db.noteMtx.Lock()
+		defer rig.db.noteMtx.Unlock()
+		return len(rig.db.savedNotes)
+	}
+
+	// Default routing stores Success notifications.
+	tCore.notify(newWithdrawNote(TopicWithdrawSent, db.Success, "dcr", "abc"))
+	if n := numSaved(); n != 1 {
+		t.Fatalf("expected 1 saved notification, got %d", n)
+	}
+	note := <-feed
+	if note.DBNote().LogOnly || note.DBNote().Desktop {
+		t.Fatalf("display hints set for default route")
+	}
+
+	// Bad routes.
+	if err := tCore.SetNoteRoute("nonsense", &NoteRoute{}); err == nil {
+		t.Fatalf("no error for unknown notification type")
+	}
+	if err := tCore.SetNoteRoute(NoteTypeOrder, &NoteRoute{Severity: db.ErrorLevel + 1}); err == nil {
+		t.Fatalf("no error for invalid severity")
+	}
+	if err := tCore.SetNoteRoute(NoteTypeOrder, &NoteRoute{Webhook: "ftp://example.com"}); err == nil {
+		t.Fatalf("no error for invalid webhook")
+	}
+	rig.db.storeErr = tErr
+	if err := tCore.SetNoteRoute(NoteTypeWithdraw, &NoteRoute{LogOnly: true}); err == nil {
+		t.Fatalf("no error for store error")
+	}
+	rig.db.storeErr = nil
+	if len(tCore.NoteRoutes()) != 0 {
+		t.Fatalf("routes set after errors")
+	}
+
+	// Log-only notifications are sent to the feed, but not stored.
+	if err := tCore.SetNoteRoute(NoteTypeWithdraw, &NoteRoute{LogOnly: true}); err != nil {
+		t.Fatalf("SetNoteRoute error: %v", err)
+	}
+	tCore.notify(newWithdrawNote(TopicWithdrawSent, db.Success, "dcr", "abc"))
+	if n := numSaved(); n != 1 {
+		t.Fatalf("log-only notification was saved")
+	}
+	if note = <-feed; !note.DBNote().LogOnly {
+		t.Fatalf("log-only hint not set")
+	}
+
+	// A severity override promotes a notification for storage, and the
+	// webhook receives it.
+	hookNotes := make(chan *OrderNote, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		note := new(OrderNote)
+		if err := json.NewDecoder(r.Body).Decode(note); err != nil {
+			t.Errorf("error decoding webhook notification: %v", err)
+		}
+		hookNotes <- note
+	}))
+	defer srv.Close()
+	route := &NoteRoute{Severity: db.WarningLevel, Desktop: true, Webhook: srv.URL}
+	if err := tCore.SetNoteRoute(NoteTypeOrder, route); err != nil {
+		t.Fatalf("SetNoteRoute error: %v", err)
+	}
+	tCore.notify(newOrderNote(TopicOrderStatusUpdated, db.Poke, nil, "abc", order.OrderStatusExecuted))
+	if n := numSaved(); n != 2 {
+		t.Fatalf("promoted notification was not saved")
+	}
+	note = <-feed
+	if note.Severity() != db.WarningLevel || !note.DBNote().Desktop {
+		t.Fatalf("route not applied to notification")
+	}
+	select {
+	case hookNote := <-hookNotes:
+		if hookNote.Subject() != "Order status updated" || hookNote.Severity() != db.WarningLevel {
+			t.Fatalf("wrong webhook notification %s", hookNote)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("webhook not called")
+	}
+
+	// The routes are persisted and loaded.
+	tCore2 := &Core{db: rig.db}
+	tCore2.loadNoteRoutes()
+	routes := tCore2.NoteRoutes()
+	if len(routes) != 2 || !routes[NoteTypeWithdraw].LogOnly || *routes[NoteTypeOrder] != *route {
+		t.Fatalf("wrong loaded routes")
+	}
+
+	// A nil route restores the default.
+	if err := tCore.SetNoteRoute(NoteTypeWithdraw, nil); err != nil {
+		t.Fatalf("SetNoteRoute error for reset: %v", err)
+	}
+	tCore.notify(newWithdrawNote(TopicWithdrawSent, db.Success, "dcr", "abc"))
+	if n := numSaved(); n != 3 {
+		t.Fatalf("notification not saved after route reset")
+	}
+}
+
+func TestDepositAddresses(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	notes := tCore.NotificationFeed()
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+
+	nextNote := func(subject string) *DepositNote {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case n := <-notes:
+				note, ok := n.(*DepositNote)
+				if !ok {
+					continue
+				}
+				if note.Subject() != subject {
+					t.Fatalf("expected %q notification, got %q", subject, note.Subject())
+				}
+				return note
+			case <-timeout:
+				t.Fatalf("no %q notification", subject)
+			}
+		}
+	}
+
+	// Address errors.
+	if _, err := tCore.NewDepositAddress(tBTC.ID, "savings"); err == nil {
+		t.Fatalf("no error for missing wallet")
+	}
+	tDcrWallet.addrErr = tErr
+	if _, err := tCore.NewDepositAddress(tDCR.ID, "savings"); err == nil {
+		t.Fatalf("no error for address error")
+	}
+	tDcrWallet.addrErr = nil
+	tDcrWallet.addr = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	rig.db.storeErr = tErr
+	if _, err := tCore.NewDepositAddress(tDCR.ID, "savings"); err == nil {
+		t.Fatalf("no error for store error")
+	}
+	if len(tCore.DepositAddresses(tDCR.ID)) != 0 {
+		t.Fatalf("address kept after store error")
+	}
+	rig.db.storeErr = nil
+
+	addr, err := tCore.NewDepositAddress(tDCR.ID, "savings")
+	if err != nil {
+		t.Fatalf("NewDepositAddress error: %v", err)
+	}
+	if addr.Address != tDcrWallet.addr || addr.Label != "savings" || addr.AssetID != tDCR.ID {
+		t.Fatalf("wrong deposit address %+v", addr)
+	}
+	tDcrWallet.addr = "DsTya4cCFBgtofDLiRhkyPYEQjgs3HnarVP"
+	if _, err := tCore.NewDepositAddress(tDCR.ID, ""); err != nil {
+		t.Fatalf("NewDepositAddress error: %v", err)
 	}
 
-	// Attempt refund.
-	checkRefund(tracker, match, matchSize)
-}
+	// Labels can be changed.
+	if err := tCore.SetDepositLabel(tDCR.ID, "unknown", "x"); err == nil {
+		t.Fatalf("no error for unknown address")
+	}
+	if err := tCore.SetDepositLabel(tDCR.ID, tDcrWallet.addr, "trading"); err != nil {
+		t.Fatalf("SetDepositLabel error: %v", err)
+	}
 
-func TestNotifications(t *testing.T) {
-	tCore := newTestRig().core
+	// An unconfirmed deposit is recorded.
+	coinID := encode.RandomBytes(36)
+	tDcrWallet.deposits = []*asset.Deposit{{
+		Address: addr.Address,
+		CoinID:  coinID,
+		Value:   5e8,
+	}}
+	addrs := tCore.DepositAddresses(tDCR.ID)
+	if len(addrs) != 2 || addrs[0].Label != "trading" || addrs[1].Address != addr.Address {
+		t.Fatalf("wrong deposit addresses")
+	}
+	if deps := addrs[1].Deposits; len(deps) != 1 || deps[0].Value != 5e8 || deps[0].Confirmations != 0 ||
+		!bytes.Equal(deps[0].CoinID, coinID) {
+		t.Fatalf("wrong deposits")
+	}
+	nextNote("Deposit received")
+
+	// The deposit is confirmed.
+	tDcrWallet.deposits[0].Confirmations = 1
+	tCore.checkDeposits(tDCR.ID, dcrWallet)
+	nextNote("Deposit confirmed")
+	tDcrWallet.deposits[0].Confirmations = 2
+	tCore.checkDeposits(tDCR.ID, dcrWallet)
+	for len(notes) > 0 {
+		if n := <-notes; n.Type() == NoteTypeDeposit {
+			t.Fatalf("unexpected deposit notification %q", n.Subject())
+		}
+	}
 
-	// Insert a notification into the database.
-	typedNote := newOrderNote("abc", "def", 100, nil)
+	// Spent deposits are kept, and errors leave the deposits unchanged.
+	tDcrWallet.deposits = nil
+	tDcrWallet.depositsErr = tErr
+	addrs = tCore.DepositAddresses(tDCR.ID)
+	if deps := addrs[1].Deposits; len(deps) != 1 || deps[0].Confirmations != 2 {
+		t.Fatalf("deposit not kept")
+	}
 
-	ch := tCore.NotificationFeed()
-	tCore.notify(typedNote)
-	select {
-	case n := <-ch:
-		dbtest.MustCompareNotifications(t, n.DBNote(), &typedNote.Notification)
-	default:
-		t.Fatalf("no notification received over the notification channel")
+	// The addresses are persisted and loaded.
+	tCore2 := &Core{db: rig.db}
+	tCore2.loadDepositAddresses()
+	loaded := tCore2.DepositAddresses(tDCR.ID)
+	if len(loaded) != 2 || loaded[0].Label != "trading" || len(loaded[1].Deposits) != 1 {
+		t.Fatalf("wrong loaded deposit addresses")
 	}
 }
 
-func TestResolveActiveTrades(t *testing.T) {
+func TestCoinManagement(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
-
-	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-
 	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-
-	rig.acct.auth() // Short path through initializeDEXConnections
+	tCore.wallets[dcrWallet.id()] = dcrWallet
 
-	// Create an order
-	qty := tDCR.LotSize * 5
-	rate := tDCR.RateStep * 5
-	lo := &order.LimitOrder{
-		P: order.Prefix{
-			OrderType:  order.LimitOrderType,
-			BaseAsset:  tDCR.ID,
-			QuoteAsset: tBTC.ID,
-			ClientTime: time.Now(),
-			ServerTime: time.Now(),
-			Commit:     ordertest.RandomCommitment(),
-		},
-		T: order.Trade{
-			Quantity: qty,
-			Sell:     true,
-		},
-		Rate: rate,
+	// Bad policies.
+	if err := tCore.SetCoinPolicy(tBTC.ID, &CoinPolicy{MaxFeeRate: 30}); err == nil {
+		t.Fatalf("no error for missing wallet")
+	}
+	badPolicies := []*CoinPolicy{
+		{},
+		{MaxFeeRate: 30, DustThreshold: 1e5, MinConsolidate: 1},
+		{MaxFeeRate: 30, DustThreshold: 1e5, MinConsolidate: 5, MaxInputs: 3},
+		{MaxFeeRate: 30, SplitLots: 2},
+	}
+	for i, policy := range badPolicies {
+		if err := tCore.SetCoinPolicy(tDCR.ID, policy); err == nil {
+			t.Fatalf("no error for bad policy %d", i)
+		}
 	}
 
-	oid := lo.ID()
-	changeCoinID := encode.RandomBytes(32)
-	changeCoin := &tCoin{id: changeCoinID}
+	// Fees are above the limit.
+	policy := &CoinPolicy{
+		MaxFeeRate:     20,
+		DustThreshold:  1e5,
+		MinConsolidate: 3,
+		SplitLots:      2,
+		SplitCoins:     3,
+	}
+	if err := tCore.SetCoinPolicy(tDCR.ID, policy); err != nil {
+		t.Fatalf("SetCoinPolicy error: %v", err)
+	}
+	tDcrWallet.coinVals = []uint64{1e3, 2e3, 3e3, 10e8}
+	tCore.manageCoins()
+	if tDcrWallet.consolidations != 0 || tDcrWallet.splitN != 0 {
+		t.Fatalf("coins managed with high fees")
+	}
 
-	// Need to return an order from db.ActiveDEXOrders
-	rig.db.activeDEXOrders = []*db.MetaOrder{
-		{
-			MetaData: &db.OrderMetaData{
-				Status:     order.OrderStatusBooked,
-				Host:       tDexHost,
-				Proof:      db.OrderProof{},
-				ChangeCoin: changeCoinID,
-			},
-			Order: lo,
-		},
+	// Consolidation comes first.
+	policy.MaxFeeRate = 30
+	if err := tCore.SetCoinPolicy(tDCR.ID, policy); err != nil {
+		t.Fatalf("SetCoinPolicy error: %v", err)
+	}
+	tCore.manageCoins()
+	if tDcrWallet.consolidations != 1 || tDcrWallet.splitN != 0 {
+		t.Fatalf("expected a consolidation only")
 	}
 
-	mid := ordertest.RandomMatchID()
-	addr := ordertest.RandomAddress()
-	match := &db.MetaMatch{
-		MetaData: &db.MatchMetaData{
-			Status: order.MakerSwapCast,
-			Proof: db.MatchProof{
-				CounterScript: encode.RandomBytes(50),
-				SecretHash:    encode.RandomBytes(32),
-				MakerSwap:     encode.RandomBytes(32),
-				Auth: db.MatchAuth{
-					MatchSig: encode.RandomBytes(32),
-				},
-			},
-			DEX:   tDexHost,
-			Base:  tDCR.ID,
-			Quote: tBTC.ID,
-		},
-		Match: &order.UserMatch{
-			OrderID:  oid,
-			MatchID:  mid,
-			Quantity: qty,
-			Rate:     rate,
-			Address:  addr,
-			Status:   order.MakerSwapCast,
-			Side:     order.Taker,
-		},
+	// Split coins for the lots, counting a coin that is already the right
+	// size.
+	splitVal := calc.RequiredOrderFunds(2*tDCR.LotSize, tDCR.SwapSize-tDCR.SwapSizeBase, tDCR)
+	tDcrWallet.coinVals = []uint64{1e3, splitVal, 10e8}
+	tCore.manageCoins()
+	if tDcrWallet.consolidations != 1 || tDcrWallet.splitN != 2 || tDcrWallet.splitVal != splitVal {
+		t.Fatalf("wrong split. %d coins of %d, wanted 2 of %d", tDcrWallet.splitN, tDcrWallet.splitVal, splitVal)
 	}
-	rig.db.activeMatchesForDEX = []*db.MetaMatch{match}
-	tDcrWallet.fundingCoins = asset.Coins{changeCoin}
-	_, auditInfo := tMsgAudit(oid, mid, addr, qty, nil)
-	tBtcWallet.auditInfo = auditInfo
 
-	// reset
-	reset := func() {
-		rig.acct.lock()
-		dcrWallet.Lock()
-		btcWallet.Lock()
-		rig.dc.trades = make(map[order.OrderID]*trackedTrade)
+	// Only split what the wallet can afford.
+	tDcrWallet.splitN = 0
+	tDcrWallet.coinVals = []uint64{splitVal * 5 / 2}
+	tCore.manageCoins()
+	if tDcrWallet.splitN != 2 {
+		t.Fatalf("expected 2 affordable coins, got %d", tDcrWallet.splitN)
 	}
 
-	// Ensure the order is good, and reset the state.
-	ensureGood := func(tag string) {
-		t.Helper()
-		_, err := tCore.Login(tPW)
-		if err != nil {
-			t.Fatalf("%s: login error: %v", tag, err)
-		}
+	// Nothing to do, or the wallet is locked.
+	tDcrWallet.splitN = 0
+	tDcrWallet.coinVals = []uint64{splitVal, splitVal, splitVal}
+	tCore.manageCoins()
+	if tDcrWallet.splitN != 0 {
+		t.Fatalf("split with enough coins")
+	}
+	tDcrWallet.coinVals = []uint64{10e8}
+	dcrWallet.lockTime = time.Time{}
+	tCore.manageCoins()
+	if tDcrWallet.splitN != 0 {
+		t.Fatalf("split with a locked wallet")
+	}
+	dcrWallet.lockTime = time.Now().Add(time.Hour)
 
-		if !btcWallet.unlocked() {
-			t.Fatalf("%s: btc wallet not unlocked", tag)
-		}
+	// The policies are persisted and loaded, and a nil policy disables coin
+	// management.
+	tCore2 := &Core{db: rig.db}
+	tCore2.loadCoinPolicies()
+	if loaded := tCore2.CoinPolicies()[tDCR.ID]; loaded == nil || *loaded != *policy {
+		t.Fatalf("wrong loaded policy")
+	}
+	if err := tCore.SetCoinPolicy(tDCR.ID, nil); err != nil {
+		t.Fatalf("SetCoinPolicy error for nil policy: %v", err)
+	}
+	tCore.manageCoins()
+	if tDcrWallet.splitN != 0 || len(tCore.CoinPolicies()) != 0 {
+		t.Fatalf("coins managed without a policy")
+	}
+}
 
-		if !dcrWallet.unlocked() {
-			t.Fatalf("%s: dcr wallet not unlocked", tag)
-		}
+func TestFundingStrategies(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
 
-		trade, found := rig.dc.trades[oid]
-		if !found {
-			t.Fatalf("%s: trade with expected order id not found. len(trades) = %d", tag, len(rig.dc.trades))
+	// Bad defaults.
+	if err := tCore.SetFundingStrategy(tBTC.ID, asset.FundMinChange); err == nil {
+		t.Fatalf("no error for missing wallet")
+	}
+	for _, strategy := range []asset.FundingStrategy{asset.FundManual, "biggest"} {
+		if err := tCore.SetFundingStrategy(tDCR.ID, strategy); err == nil {
+			t.Fatalf("no error for default strategy %q", strategy)
 		}
+	}
 
-		_, found = trade.matches[mid]
-		if !found {
-			t.Fatalf("%s: trade with expected order id not found. len(matches) = %d", tag, len(trade.matches))
-		}
-		reset()
+	form := &TradeForm{}
+	if _, err := tCore.fundOrder(dcrWallet, 1e8, tDCR, form); err != nil {
+		t.Fatalf("fundOrder error: %v", err)
+	}
+	if tDcrWallet.fundOpts != nil {
+		t.Fatalf("coins selected without a strategy")
 	}
 
-	ensureGood("initial")
+	// The wallet's default strategy.
+	if err := tCore.SetFundingStrategy(tDCR.ID, asset.FundOldest); err != nil {
+		t.Fatalf("SetFundingStrategy error: %v", err)
+	}
+	if _, err := tCore.fundOrder(dcrWallet, 1e8, tDCR, form); err != nil {
+		t.Fatalf("fundOrder error: %v", err)
+	}
+	if tDcrWallet.fundOpts == nil || tDcrWallet.fundOpts.Strategy != asset.FundOldest {
+		t.Fatalf("default strategy not used")
+	}
 
-	// Ensure a failure AND reset. err != nil just helps to make sure that we're
-	// hitting errors in resolveActiveTrades, which sends errors as
-	// notifications, vs somewhere else.
-	ensureFail := func(tag string) {
-		t.Helper()
-		_, err := tCore.Login(tPW)
-		if err != nil || len(rig.dc.trades) != 0 {
-			t.Fatalf("%s: no error. err = %v, len(trades) = %d", tag, err, len(rig.dc.trades))
-		}
-		reset()
+	// The form's strategy overrides the default, and chosen coins select the
+	// manual strategy.
+	form.Funding = asset.FundMinInputs
+	tCore.fundOrder(dcrWallet, 1e8, tDCR, form)
+	if tDcrWallet.fundOpts.Strategy != asset.FundMinInputs {
+		t.Fatalf("form strategy not used")
+	}
+	form.Funding = asset.FundDefault
+	form.Coins = []dex.Bytes{encode.RandomBytes(36)}
+	tCore.fundOrder(dcrWallet, 1e8, tDCR, form)
+	if tDcrWallet.fundOpts.Strategy != asset.FundManual || len(tDcrWallet.fundOpts.CoinIDs) != 1 {
+		t.Fatalf("manual strategy not used")
+	}
+	form.Funding = asset.FundMinChange
+	if _, err := tCore.fundOrder(dcrWallet, 1e8, tDCR, form); err == nil {
+		t.Fatalf("no error for coins chosen with another strategy")
+	}
+	form.Funding, form.Coins = "biggest", nil
+	if _, err := tCore.fundOrder(dcrWallet, 1e8, tDCR, form); err == nil {
+		t.Fatalf("no error for unknown strategy")
 	}
 
-	// No base wallet
-	delete(tCore.wallets, tDCR.ID)
-	ensureFail("missing base")
-	tCore.wallets[tDCR.ID] = dcrWallet
+	// A wallet without coin selection can only use its own selection.
+	plainWallet := &xcWallet{
+		Wallet:    struct{ asset.Wallet }{tDcrWallet},
+		connector: dcrWallet.connector,
+		AssetID:   tDCR.ID,
+		lockTime:  time.Now().Add(time.Hour),
+		hookedUp:  true,
+		dbID:      dcrWallet.dbID,
+	}
+	form.Funding = asset.FundOldest
+	if _, err := tCore.fundOrder(plainWallet, 1e8, tDCR, form); err == nil {
+		t.Fatalf("no error for wallet without coin selection")
+	}
 
-	// Base wallet unlock errors
-	tDcrWallet.unlockErr = tErr
-	ensureFail("base unlock")
-	tDcrWallet.unlockErr = nil
+	// The strategies are persisted and loaded, and the default strategy
+	// clears the wallet's default.
+	tCore2 := &Core{db: rig.db}
+	tCore2.loadFundingStrategies()
+	if loaded := tCore2.FundingStrategies()[tDCR.ID]; loaded != asset.FundOldest {
+		t.Fatalf("wrong loaded strategy %q", loaded)
+	}
+	if err := tCore.SetFundingStrategy(tDCR.ID, asset.FundDefault); err != nil {
+		t.Fatalf("SetFundingStrategy error: %v", err)
+	}
+	if len(tCore.FundingStrategies()) != 0 {
+		t.Fatalf("default strategy not cleared")
+	}
+}
 
-	// No quote wallet
-	delete(tCore.wallets, tBTC.ID)
-	ensureFail("missing quote")
-	tCore.wallets[tBTC.ID] = btcWallet
+func TestWalletCoins(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
 
-	// Quote wallet unlock errors
-	tBtcWallet.unlockErr = tErr
-	ensureFail("quote unlock")
-	tBtcWallet.unlockErr = nil
+	if _, err := tCore.WalletCoins(tDCR.ID, "trading"); err == nil {
+		t.Fatalf("no error for missing wallet")
+	}
 
-	// Funding coin error.
-	tDcrWallet.fundingCoinErr = tErr
-	ensureFail("funding coin")
-	tDcrWallet.fundingCoinErr = nil
+	// A booked sell order funded by one of the wallet's locked coins.
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 3*tDCR.LotSize, tBTC.RateStep)
+	fundCoin := &tCoin{id: encode.RandomBytes(36), val: 4 * tDCR.LotSize}
+	lo.Coins = []order.CoinID{fundCoin.id}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, walletSet, asset.Coins{fundCoin}, tCore.notify)
+	dc.trades[tracker.ID()] = tracker
+
+	tDcrWallet.walletCoins = []*asset.WalletCoin{
+		{ID: encode.RandomBytes(36), Value: 1e8, Label: "savings"},
+		{ID: fundCoin.id, Value: fundCoin.val, Locked: true},
+		{ID: encode.RandomBytes(36), Value: 2e8, Locked: true},
+	}
+	coins, err := tCore.WalletCoins(tDCR.ID, "")
+	if err != nil {
+		t.Fatalf("WalletCoins error: %v", err)
+	}
+	if len(coins) != 3 {
+		t.Fatalf("expected 3 coins, got %d", len(coins))
+	}
+	if coins[0].Label != "savings" || coins[0].OrderID != "" {
+		t.Fatalf("wrong unlocked coin %+v", coins[0])
+	}
+	if coins[1].OrderID != tracker.ID().String() {
+		t.Fatalf("funding coin not matched to its order")
+	}
+	if coins[2].OrderID != "" {
+		t.Fatalf("locked coin matched to an order it does not fund")
+	}
 
-	// No matches
-	rig.db.activeMatchesForDEXErr = tErr
-	ensureFail("matches error")
-	rig.db.activeMatchesForDEXErr = nil
+	tDcrWallet.listCoinsErr = tErr
+	if _, err := tCore.WalletCoins(tDCR.ID, ""); err == nil {
+		t.Fatalf("no error for ListCoins error")
+	}
+	tDcrWallet.listCoinsErr = nil
 
-	// Success again
-	ensureGood("final")
+	dcrWallet.Wallet = struct{ asset.Wallet }{tDcrWallet}
+	if _, err := tCore.WalletCoins(tDCR.ID, ""); err == nil {
+		t.Fatalf("no error for wallet without coin control")
+	}
 }
 
-func TestReadConnectMatches(t *testing.T) {
+func TestReservations(t *testing.T) {
 	rig := newTestRig()
-	preImg := newPreimage()
 	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	lotSize := tDCR.LotSize
+	bal := asset.Balance{Available: lotSize, Locked: 8 * lotSize}
+	dcrWallet.balance = &db.Balance{Balance: bal}
+	tDcrWallet.bal = &bal
 
-	notes := make(map[string][]Notification)
-	notify := func(note Notification) {
-		notes[note.Type()] = append(notes[note.Type()], note)
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
 	}
+	mkt := dc.market(tDcrBtcMktName)
 
-	lo := &order.LimitOrder{
-		P: order.Prefix{
-			// 	OrderType:  order.LimitOrderType,
-			// 	BaseAsset:  tDCR.ID,
-			// 	QuoteAsset: tBTC.ID,
-			// 	ClientTime: time.Now(),
-			ServerTime: time.Now(),
-			// 	Commit:     preImg.Commit(),
-		},
+	// A booked sell order with a swapped maker match, a taker match awaiting
+	// the user's swap, and a completed match.
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 6*lotSize, tBTC.RateStep)
+	lo.Force = order.StandingTiF
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	fundCoin := &tCoin{id: encode.RandomBytes(36), val: 7 * lotSize}
+	lo.Coins = []order.CoinID{fundCoin.id}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, walletSet, asset.Coins{fundCoin}, tCore.notify)
+	dc.trades[tracker.ID()] = tracker
+	matchStamp := encode.UnixMilliU(time.Now())
+	addMatch := func(side order.MatchSide, status order.MatchStatus) *matchTracker {
+		mid := ordertest.RandomMatchID()
+		match := &matchTracker{
+			id:     mid,
+			prefix: lo.Prefix(),
+			trade:  lo.Trade(),
+			MetaMatch: db.MetaMatch{
+				MetaData: &db.MatchMetaData{Status: status},
+				Match: &order.UserMatch{
+					OrderID:  lo.ID(),
+					MatchID:  mid,
+					Quantity: lotSize,
+					Rate:     lo.Rate,
+					Status:   status,
+					Side:     side,
+				},
+			},
+		}
+		match.MetaData.Proof.Auth.MatchStamp = matchStamp
+		tracker.matches[mid] = match
+		return match
 	}
-	oid := lo.ID()
-	dbOrder := &db.MetaOrder{
-		MetaData: &db.OrderMetaData{},
-		Order:    lo,
+	swapped := addMatch(order.Maker, order.MakerSwapCast)
+	swapped.MetaData.Proof.MakerSwap = encode.RandomBytes(36)
+	addMatch(order.Taker, order.NewlyMatched)
+	addMatch(order.Maker, order.MatchComplete).MetaData.Proof.MakerSwap = encode.RandomBytes(36)
+	lo.SetFill(3 * lotSize)
+
+	res, err := tCore.Reservations(tDCR.ID)
+	if err != nil {
+		t.Fatalf("Reservations error: %v", err)
+	}
+	if len(res.Orders) != 1 {
+		t.Fatalf("expected 1 order reservation, got %d", len(res.Orders))
+	}
+	ord := res.Orders[0]
+	if ord.Locked != 7*lotSize || len(ord.Coins) != 1 || ord.Coins[0].Value != 7*lotSize {
+		t.Fatalf("wrong locked coins")
+	}
+	// The unfilled 3 lots and the taker match awaiting a swap.
+	if ord.Pending != 4*lotSize {
+		t.Fatalf("wrong pending value. wanted %d, got %d", 4*lotSize, ord.Pending)
+	}
+	if len(ord.Matches) != 2 {
+		t.Fatalf("expected 2 match reservations, got %d", len(ord.Matches))
+	}
+	wantLockTime := matchStamp + uint64(tCore.lockTimeMaker/time.Millisecond)
+	for _, mr := range ord.Matches {
+		if mr.MatchID == swapped.id.String() {
+			if mr.Contract == "" || mr.LockTime != wantLockTime {
+				t.Fatalf("wrong contract reservation %+v", mr)
+			}
+		} else if mr.Contract != "" || mr.LockTime != 0 {
+			t.Fatalf("contract set for unswapped match")
+		}
+	}
+	if res.OrderLocked != 7*lotSize || res.Contracts != lotSize || res.Untracked != lotSize {
+		t.Fatalf("wrong totals. locked = %d, contracts = %d, untracked = %d",
+			res.OrderLocked, res.Contracts, res.Untracked)
+	}
+	if _, err = tCore.Reservations(12345); err == nil {
+		t.Fatalf("no error for unknown wallet")
+	}
+
+	// The wallet can't fund another order, and says why.
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     2 * lotSize,
+		Rate:    tBTC.RateStep,
+	}
+	funding, err := tCore.OrderFunding(form)
+	if err != nil {
+		t.Fatalf("OrderFunding error: %v", err)
+	}
+	if funding.OK || funding.Required <= 2*lotSize || !strings.Contains(funding.Reason, "locked for 1 other orders") {
+		t.Fatalf("wrong funding check %+v", funding)
+	}
+	form.Qty = lotSize / 2
+	if _, err = tCore.OrderFunding(form); err == nil {
+		t.Fatalf("no error for less than a lot")
+	}
+	bal.Available = 10 * lotSize
+	form.Qty = 2 * lotSize
+	funding, err = tCore.OrderFunding(form)
+	if err != nil {
+		t.Fatalf("OrderFunding error: %v", err)
 	}
-	mkt := dc.market(tDcrBtcMktName)
-	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
-		rig.db, rig.queue, nil, nil, notify)
-	metaMatch := db.MetaMatch{
-		MetaData: &db.MatchMetaData{},
-		Match:    &order.UserMatch{},
+	if !funding.OK || funding.Reason != "" {
+		t.Fatalf("order can't be funded")
 	}
 
-	// Store a match
-	knownID := ordertest.RandomMatchID()
-	knownMatch := &matchTracker{
-		id:        knownID,
-		MetaMatch: metaMatch,
+	// An immediate order that finished without matches returns its coins
+	// after an extra epoch.
+	lo, dbOrder, preImg, _ = makeLimitOrder(dc, true, 2*lotSize, tBTC.RateStep)
+	dbOrder.MetaData.Status = order.OrderStatusExecuted
+	fundCoin = &tCoin{id: encode.RandomBytes(36), val: 3 * lotSize}
+	lo.Coins = []order.CoinID{fundCoin.id}
+	tracker = newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, walletSet, asset.Coins{fundCoin}, tCore.notify)
+	dc.trades[tracker.ID()] = tracker
+	res, _ = tCore.Reservations(tDCR.ID)
+	if len(res.Orders) != 2 {
+		t.Fatalf("expected 2 order reservations, got %d", len(res.Orders))
 	}
-	tracker.matches[knownID] = knownMatch
-	knownMsgMatch := &msgjson.Match{OrderID: oid[:], MatchID: knownID[:]}
-
-	missingID := ordertest.RandomMatchID()
-	missingMatch := &matchTracker{
-		id:        missingID,
-		MetaMatch: metaMatch,
+	if _, err = tracker.tick(); err != nil {
+		t.Fatalf("tick error: %v", err)
 	}
-	tracker.matches[missingID] = missingMatch
-
-	extraID := ordertest.RandomMatchID()
-	extraMsgMatch := &msgjson.Match{OrderID: oid[:], MatchID: extraID[:]}
-
-	matches := []*msgjson.Match{knownMsgMatch, extraMsgMatch}
-	tracker.readConnectMatches(matches)
-
-	if knownMatch.failErr != nil {
-		t.Fatalf("error set for known and reported match")
+	if len(tDcrWallet.returnedCoins) != 0 {
+		t.Fatalf("coins returned before the grace epoch")
 	}
-
-	if missingMatch.failErr == nil {
-		t.Fatalf("error not set for missing match")
+	lo.ServerTime = time.Now().Add(-3 * time.Duration(mkt.EpochLen) * time.Millisecond)
+	counts, err := tracker.tick()
+	if err != nil {
+		t.Fatalf("tick error: %v", err)
 	}
-
-	if len(notes["order"]) != 2 {
-		t.Fatalf("expected 2 core 'order'-type notifications, got %d", len(notes["order"]))
+	if len(tDcrWallet.returnedCoins) != 1 || counts[tDCR.ID] != 1 {
+		t.Fatalf("coins not returned")
 	}
-
-	if notes["order"][0].Subject() != "Missing matches" {
-		t.Fatalf("no core notification sent for missing matches")
+	tracker.tick()
+	if len(tDcrWallet.returnedCoins) != 1 {
+		t.Fatalf("coins returned twice")
 	}
-
-	if notes["order"][1].Subject() != "Match resolution error" {
-		t.Fatalf("no core notification sent for unknown matches")
+	res, _ = tCore.Reservations(tDCR.ID)
+	if len(res.Orders) != 1 {
+		t.Fatalf("returned coins still reserved")
 	}
-
 }
 
-func convertMsgLimitOrder(msgOrder *msgjson.LimitOrder) *order.LimitOrder {
-	tif := order.ImmediateTiF
-	if msgOrder.TiF == msgjson.StandingOrderNum {
-		tif = order.StandingTiF
-	}
-	return &order.LimitOrder{
-		P:     convertMsgPrefix(&msgOrder.Prefix, order.LimitOrderType),
-		T:     convertMsgTrade(&msgOrder.Trade),
-		Rate:  msgOrder.Rate,
-		Force: tif,
+func TestProxies(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	var wsCfg *comms.WsCfg
+	tCore.wsConstructor = func(cfg *comms.WsCfg) (comms.WsConn, error) {
+		wsCfg = cfg
+		return rig.ws, nil
 	}
-}
 
-func convertMsgMarketOrder(msgOrder *msgjson.MarketOrder) *order.MarketOrder {
-	return &order.MarketOrder{
-		P: convertMsgPrefix(&msgOrder.Prefix, order.MarketOrderType),
-		T: convertMsgTrade(&msgOrder.Trade),
+	// Onion services need a proxy.
+	onion := "abcdef.onion:7232"
+	if _, err := tCore.connectDEX(&db.AccountInfo{Host: onion}); err == nil {
+		t.Fatalf("no error connecting to an onion service without a proxy")
 	}
-}
 
-func convertMsgCancelOrder(msgOrder *msgjson.CancelOrder) *order.CancelOrder {
-	var oid order.OrderID
-	copy(oid[:], msgOrder.TargetID)
-	return &order.CancelOrder{
-		P:             convertMsgPrefix(&msgOrder.Prefix, order.CancelOrderType),
-		TargetOrderID: oid,
+	if err := tCore.SetProxy(onion, &ProxyConfig{Addr: "127.0.0.1"}); err == nil {
+		t.Fatalf("no error for proxy address without a port")
+	}
+	if err := tCore.SetProxy("", &ProxyConfig{Addr: "127.0.0.1:9050"}); err == nil {
+		t.Fatalf("no error for empty host")
+	}
+	proxy := &ProxyConfig{Addr: "127.0.0.1:9050", User: "user", Pass: "pass", Isolate: true}
+	if err := tCore.SetProxy(onion, proxy); err != nil {
+		t.Fatalf("SetProxy error: %v", err)
+	}
+	if p := tCore.Proxies()[onion]; p == nil || *p != *proxy {
+		t.Fatalf("wrong proxy: %+v", p)
 	}
-}
 
-func convertMsgPrefix(msgPrefix *msgjson.Prefix, oType order.OrderType) order.Prefix {
-	var commit order.Commitment
-	copy(commit[:], msgPrefix.Commit)
-	var acctID account.AccountID
-	copy(acctID[:], msgPrefix.AccountID)
-	return order.Prefix{
-		AccountID:  acctID,
-		BaseAsset:  msgPrefix.Base,
-		QuoteAsset: msgPrefix.Quote,
-		OrderType:  oType,
-		ClientTime: encode.UnixTimeMilli(int64(msgPrefix.ClientTime)),
-		//ServerTime set in epoch queue processing pipeline.
-		Commit: commit,
+	// Stream isolation replaces the credentials.
+	rig.queueConfig()
+	if _, err := tCore.connectDEX(&db.AccountInfo{Host: onion}); err != nil {
+		t.Fatalf("connectDEX error: %v", err)
+	}
+	if wsCfg.Proxy != proxy.Addr || !wsCfg.TorIsolation || wsCfg.ProxyUser != "" {
+		t.Fatalf("wrong ws proxy config: %+v", wsCfg)
 	}
-}
 
-func convertMsgTrade(msgTrade *msgjson.Trade) order.Trade {
-	coins := make([]order.CoinID, 0, len(msgTrade.Coins))
-	for _, coin := range msgTrade.Coins {
-		var b []byte = coin.ID
-		coins = append(coins, b)
+	proxy.Isolate = false
+	if err := tCore.SetProxy(onion, proxy); err != nil {
+		t.Fatalf("SetProxy error: %v", err)
 	}
-	sell := true
-	if msgTrade.Side == msgjson.BuyOrderNum {
-		sell = false
+	rig.queueConfig()
+	if _, err := tCore.connectDEX(&db.AccountInfo{Host: onion}); err != nil {
+		t.Fatalf("connectDEX error: %v", err)
 	}
-	return order.Trade{
-		Coins:    coins,
-		Sell:     sell,
-		Quantity: msgTrade.Quantity,
-		Address:  msgTrade.Address,
+	if wsCfg.TorIsolation || wsCfg.ProxyUser != "user" || wsCfg.ProxyPass != "pass" {
+		t.Fatalf("wrong ws proxy config: %+v", wsCfg)
 	}
-}
 
-func orderResponse(msgID uint64, msgPrefix msgjson.Stampable, ord order.Order, badSig, noID, badID bool) *msgjson.Message {
-	orderTime := time.Now()
-	timeStamp := encode.UnixMilliU(orderTime)
-	msgPrefix.Stamp(timeStamp)
-	sign(tDexPriv, msgPrefix)
-	if badSig {
-		msgPrefix.SetSig(encode.RandomBytes(5))
+	// Other hosts connect directly.
+	rig.queueConfig()
+	if _, err := tCore.connectDEX(&db.AccountInfo{Host: "somedex.com"}); err != nil {
+		t.Fatalf("connectDEX error: %v", err)
 	}
-	ord.SetTime(orderTime)
-	oid := ord.ID()
-	oidB := oid[:]
-	if noID {
-		oidB = nil
-	} else if badID {
-		oidB = encode.RandomBytes(32)
+	if wsCfg.Proxy != "" {
+		t.Fatalf("proxy set for another host")
 	}
-	resp, _ := msgjson.NewResponse(msgID, &msgjson.OrderResult{
-		Sig:        msgPrefix.SigBytes(),
-		OrderID:    oidB,
-		ServerTime: timeStamp,
-	}, nil)
-	return resp
-}
 
-func tMsgAudit(oid order.OrderID, mid order.MatchID, recipient string, val uint64, secretHash []byte) (*msgjson.Audit, *tAuditInfo) {
-	auditID := encode.RandomBytes(36)
-	auditContract := encode.RandomBytes(75)
-	if secretHash == nil {
-		secretHash = encode.RandomBytes(32)
-	}
-	auditStamp := encode.UnixMilliU(time.Now())
-	audit := &msgjson.Audit{
-		OrderID:  oid[:],
-		MatchID:  mid[:],
-		Time:     auditStamp,
-		CoinID:   auditID,
-		Contract: auditContract,
+	if err := tCore.SetProxy(onion, nil); err != nil {
+		t.Fatalf("error removing proxy: %v", err)
 	}
-	sign(tDexPriv, audit)
-	auditCoin := &tCoin{id: auditID, val: val}
-	auditInfo := &tAuditInfo{
-		recipient:  recipient,
-		coin:       auditCoin,
-		secretHash: secretHash,
+	if len(tCore.Proxies()) != 0 {
+		t.Fatalf("proxy not removed")
 	}
-	return audit, auditInfo
 }
 
-func TestHandleEpochOrderMsg(t *testing.T) {
+func TestCancelOnDisconnect(t *testing.T) {
 	rig := newTestRig()
-	ord := &order.LimitOrder{P: order.Prefix{ServerTime: time.Now()}}
-	oid := ord.ID()
-	payload := &msgjson.EpochOrderNote{
-		BookOrderNote: msgjson.BookOrderNote{
-			OrderNote: msgjson.OrderNote{
-				MarketID: tDcrBtcMktName,
-				OrderID:  oid.Bytes(),
-			},
-			TradeNote: msgjson.TradeNote{
-				Side:     msgjson.BuyOrderNum,
-				Rate:     4,
-				Quantity: 10,
-			},
-		},
-		Epoch: 1,
+	tCore := rig.core
+	dc := rig.dc
+	host := dc.acct.host
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 0, 0)
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, tCore.lockTimeTaker, tCore.lockTimeMaker,
+		rig.db, rig.queue, nil, nil, tCore.notify)
+	tracker.metaData.Status = order.OrderStatusBooked
+	dc.trades[lo.ID()] = tracker
+	epochLen := time.Duration(mkt.EpochLen) * time.Millisecond
+
+	var cancels int
+	handleCancel := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.CancelOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		cancels++
+		f(orderResponse(msg.ID, msgOrder, convertMsgCancelOrder(msgOrder), false, false, false))
+		return nil
 	}
 
-	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.EpochOrderRoute, payload)
+	if err := tCore.SetCancelOnDisconnect("", 2); err == nil {
+		t.Fatalf("no error for empty host")
+	}
 
-	// Ensure handling an epoch order associated with a non-existent orderbook
-	// generates an error.
-	err := handleEpochOrderMsg(rig.core, rig.dc, req)
-	if err == nil {
-		t.Fatal("[handleEpochOrderMsg] expected a non-existent orderbook error")
+	// Nothing is canceled without the setting.
+	tCore.cancelStaleOrders(dc, 10*epochLen)
+	if tracker.cancel != nil {
+		t.Fatalf("order canceled without the setting")
 	}
 
-	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
+	if err := tCore.SetCancelOnDisconnect(host, 2); err != nil {
+		t.Fatalf("SetCancelOnDisconnect error: %v", err)
+	}
+	if tCore.CancelOnDisconnect()[host] != 2 {
+		t.Fatalf("setting not saved")
+	}
 
-	err = handleEpochOrderMsg(rig.core, rig.dc, req)
-	if err != nil {
-		t.Fatalf("[handleEpochOrderMsg] unexpected error: %v", err)
+	// The disconnect time is recorded.
+	tCore.handleConnectEvent(host, false)
+	if dc.disconnected.IsZero() {
+		t.Fatalf("disconnect time not recorded")
 	}
-}
 
-func makeMatchProof(preimages []order.Preimage, commitments []order.Commitment) (msgjson.Bytes, msgjson.Bytes, error) {
-	if len(preimages) != len(commitments) {
-		return nil, nil, fmt.Errorf("expected equal number of preimages and commitments")
+	// A short disconnect leaves the order booked.
+	tCore.cancelStaleOrders(dc, epochLen)
+	if tracker.cancel != nil {
+		t.Fatalf("order canceled after a short disconnect")
 	}
 
-	sbuff := make([]byte, 0, len(preimages)*order.PreimageSize)
-	cbuff := make([]byte, 0, len(commitments)*order.CommitmentSize)
-	for i := 0; i < len(preimages); i++ {
-		sbuff = append(sbuff, preimages[i][:]...)
-		cbuff = append(cbuff, commitments[i][:]...)
+	rig.ws.queueResponse(msgjson.CancelRoute, handleCancel)
+	tCore.cancelStaleOrders(dc, 2*epochLen)
+	if tracker.cancel == nil || cancels != 1 {
+		t.Fatalf("order not canceled after a long disconnect")
+	}
+
+	// An order with a pending cancel is not canceled again.
+	tCore.cancelStaleOrders(dc, 2*epochLen)
+	if cancels != 1 {
+		t.Fatalf("order canceled twice")
+	}
+
+	if err := tCore.SetCancelOnDisconnect(host, 0); err != nil {
+		t.Fatalf("error disabling cancel-on-disconnect: %v", err)
+	}
+	if len(tCore.CancelOnDisconnect()) != 0 {
+		t.Fatalf("setting not removed")
 	}
-	seed := blake256.Sum256(sbuff)
-	csum := blake256.Sum256(cbuff)
-	return seed[:], csum[:], nil
 }
 
-func TestHandleMatchProofMsg(t *testing.T) {
+func TestLotSizeChange(t *testing.T) {
 	rig := newTestRig()
-	pimg := newPreimage()
-	cmt := pimg.Commit()
+	tCore := rig.core
+	dc := rig.dc
+	mkt := dc.market(tDcrBtcMktName)
 
-	seed, csum, err := makeMatchProof([]order.Preimage{pimg}, []order.Commitment{cmt})
-	if err != nil {
-		t.Fatalf("[makeMatchProof] unexpected error: %v", err)
+	addStanding := func(sell bool, qty, rate uint64) *trackedTrade {
+		lo, dbOrder, preImg, _ := makeLimitOrder(dc, sell, qty, rate)
+		lo.Sell, lo.Rate, lo.Force = sell, rate, order.StandingTiF
+		tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, tCore.lockTimeTaker, tCore.lockTimeMaker,
+			rig.db, rig.queue, nil, nil, tCore.notify)
+		tracker.metaData.Status = order.OrderStatusBooked
+		dc.trades[lo.ID()] = tracker
+		return tracker
 	}
+	sellTracker := addStanding(true, 5*tDCR.LotSize, 1e6)
+	buyTracker := addStanding(false, 2*tDCR.LotSize, 1e6+tBTC.RateStep)
 
-	payload := &msgjson.MatchProofNote{
-		MarketID:  tDcrBtcMktName,
-		Epoch:     1,
-		Preimages: []dex.Bytes{pimg[:]},
-		CSum:      csum[:],
-		Seed:      seed[:],
+	var cancels int
+	handleCancel := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.CancelOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		cancels++
+		f(orderResponse(msg.ID, msgOrder, convertMsgCancelOrder(msgOrder), false, false, false))
+		return nil
 	}
 
-	eo := &msgjson.EpochOrderNote{
-		BookOrderNote: msgjson.BookOrderNote{
-			OrderNote: msgjson.OrderNote{
-				MarketID: tDcrBtcMktName,
-				OrderID:  encode.RandomBytes(order.OrderIDSize),
-			},
-		},
-		Epoch:  1,
-		Commit: cmt[:],
+	setConfig := func(lotSize, rateStep uint64) {
+		for _, a := range dc.cfg.Assets {
+			switch a.ID {
+			case tDCR.ID:
+				a.LotSize = lotSize
+			case tBTC.ID:
+				a.RateStep = rateStep
+			}
+		}
+		rig.queueConfig()
 	}
 
-	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.MatchProofRoute, payload)
+	if tCore.LotSizePolicy() != LotSizeNotify {
+		t.Fatalf("wrong default policy %q", tCore.LotSizePolicy())
+	}
+	if err := tCore.SetLotSizePolicy("ignore"); err == nil {
+		t.Fatalf("no error for unknown policy")
+	}
 
-	// Ensure match proof validation generates an error for a non-existent
-	// orderbook generates an error.
-	err = handleMatchProofMsg(rig.core, rig.dc, req)
-	if err == nil {
-		t.Fatal("[handleMatchProofMsg] expected a non-existent orderbook error")
+	// No change leaves the orders unflagged.
+	setConfig(tDCR.LotSize, tBTC.RateStep)
+	if err := tCore.refreshConfig(dc); err != nil {
+		t.Fatalf("refreshConfig error: %v", err)
+	}
+	if sellTracker.lotSizeIssue != "" || buyTracker.lotSizeIssue != "" {
+		t.Fatalf("orders flagged without a change")
+	}
+
+	// The notify policy flags the orders without canceling them.
+	setConfig(2*tDCR.LotSize, 1000)
+	if err := tCore.refreshConfig(dc); err != nil {
+		t.Fatalf("refreshConfig error: %v", err)
+	}
+	if dc.asset(tDCR.ID).LotSize != 2*tDCR.LotSize || dc.asset(tBTC.ID).RateStep != 1000 {
+		t.Fatalf("assets not updated")
+	}
+	if sellTracker.lotSizeIssue != LotSizeIssueInvalid || buyTracker.lotSizeIssue != LotSizeIssueInvalid {
+		t.Fatalf("orders not flagged, sell: %q, buy: %q", sellTracker.lotSizeIssue, buyTracker.lotSizeIssue)
+	}
+	if corder, _ := sellTracker.coreOrder(); corder.LotSizeIssue != LotSizeIssueInvalid {
+		t.Fatalf("flag not reported with the order")
+	}
+	if cancels != 0 {
+		t.Fatalf("orders canceled with the notify policy")
+	}
+
+	// The replace policy cancels both orders, and schedules a replacement for
+	// the sell order. The buy order is less than a lot now, so it is not
+	// replaced.
+	if err := tCore.SetLotSizePolicy(LotSizeReplace); err != nil {
+		t.Fatalf("SetLotSizePolicy error: %v", err)
+	}
+	rig.ws.queueResponse(msgjson.CancelRoute, handleCancel)
+	rig.ws.queueResponse(msgjson.CancelRoute, handleCancel)
+	setConfig(4*tDCR.LotSize, 1000)
+	if err := tCore.refreshConfig(dc); err != nil {
+		t.Fatalf("refreshConfig error: %v", err)
+	}
+	if cancels != 2 || sellTracker.cancel == nil || buyTracker.cancel == nil {
+		t.Fatalf("orders not canceled")
+	}
+	if len(rig.db.scheds) != 1 {
+		t.Fatalf("expected 1 replacement, got %d", len(rig.db.scheds))
+	}
+	for _, sched := range rig.db.scheds {
+		if !sched.Sell || !sched.IsLimit || sched.Qty != 4*tDCR.LotSize || sched.Rate != 1e6 {
+			t.Fatalf("wrong replacement: %+v", sched)
+		}
 	}
 
-	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
+	// Orders with a pending cancel are not checked again.
+	setConfig(3*tDCR.LotSize, 1000)
+	if err := tCore.refreshConfig(dc); err != nil {
+		t.Fatalf("refreshConfig error: %v", err)
+	}
+	if cancels != 2 || sellTracker.lotSizeIssue != "" {
+		t.Fatalf("order with a pending cancel checked")
+	}
 
-	err = rig.dc.books[tDcrBtcMktName].Enqueue(eo)
-	if err != nil {
-		t.Fatalf("[Enqueue] unexpected error: %v", err)
+	// A lot that costs too much to swap is uneconomical.
+	lo := &order.LimitOrder{
+		P:    order.Prefix{BaseAsset: tDCR.ID, QuoteAsset: tBTC.ID},
+		T:    order.Trade{Sell: true, Quantity: 3 * tDCR.LotSize},
+		Rate: 1e6,
+	}
+	expensive := *dc.asset(tDCR.ID)
+	expensive.MaxFeeRate = expensive.LotSize
+	dc.assets = map[uint32]*dex.Asset{tDCR.ID: &expensive, tBTC.ID: dc.asset(tBTC.ID)}
+	if issue := dc.lotSizeIssue(lo); issue != LotSizeIssueUneconomical {
+		t.Fatalf("expected uneconomical, got %q", issue)
 	}
 
-	err = handleMatchProofMsg(rig.core, rig.dc, req)
-	if err != nil {
-		t.Fatalf("[handleMatchProofMsg] unexpected error: %v", err)
+	// Replacement rates are rounded in the user's favor.
+	lo.Rate = 1e6 + 10
+	if _, rate := replacementOrder(lo, tDCR.LotSize, 1000); rate != 1e6+1000 {
+		t.Fatalf("wrong sell rate %d", rate)
+	}
+	lo.Sell = false
+	if _, rate := replacementOrder(lo, tDCR.LotSize, 1000); rate != 1e6 {
+		t.Fatalf("wrong buy rate %d", rate)
 	}
 }
 
-func TestLogout(t *testing.T) {
+func TestSwapConfs(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
+	dc := rig.dc
 
-	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-
-	btcWallet, _ := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
+	btcAsset := *tBTC
+	btcAsset.SwapConf = 3
+	dc.assets[tBTC.ID] = &btcAsset
+	defer func() { dc.assets[tBTC.ID] = tBTC }()
 
-	ord := &order.LimitOrder{P: order.Prefix{ServerTime: time.Now()}}
-	tracker := &trackedTrade{
-		Order:  ord,
-		preImg: newPreimage(),
-		dc:     rig.dc,
-		metaData: &db.OrderMetaData{
-			Status: order.OrderStatusBooked,
-		},
-		matches: make(map[order.MatchID]*matchTracker),
+	// The setting can't be below the DEX requirement.
+	if err := tCore.SetSwapConfs(tBTC.ID, 2); err == nil {
+		t.Fatalf("no error for confirmations below the DEX minimum")
+	}
+	if err := tCore.SetSwapConfs(tBTC.ID, 5); err != nil {
+		t.Fatalf("SetSwapConfs error: %v", err)
+	}
+	if tCore.SwapConfs()[tBTC.ID] != 5 {
+		t.Fatalf("setting not saved")
 	}
-	rig.dc.trades[ord.ID()] = tracker
 
-	dc, _, _ := testDexConnection()
-	initUserAssets := func() {
-		tCore.user = new(User)
-		tCore.user.Assets = make(map[uint32]*SupportedAsset, len(dc.assets))
-		for assetsID := range dc.assets {
-			tCore.user.Assets[assetsID] = &SupportedAsset{
-				ID: assetsID,
-			}
-		}
+	// The setting is persisted and loaded.
+	tCore2 := &Core{db: rig.db}
+	tCore2.loadSwapConfs()
+	if tCore2.SwapConfs()[tBTC.ID] != 5 {
+		t.Fatalf("setting not loaded")
 	}
 
-	ensureErr := func(tag string) {
-		t.Helper()
-		initUserAssets()
-		err := tCore.Logout()
-		if err == nil {
-			t.Fatalf("%s: no error", tag)
-		}
+	// The setting applies to the counterparty's swap on the asset received.
+	_, dbOrder, preImg, _ := makeLimitOrder(dc, true, 0, 0)
+	wallets := &walletSet{fromAsset: tDCR, toAsset: &btcAsset}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen, tCore.lockTimeTaker,
+		tCore.lockTimeMaker, rig.db, rig.queue, wallets, nil, tCore.notify)
+	if confs := tracker.counterSwapConfs(); confs != 3 {
+		t.Fatalf("expected DEX requirement of 3 without a setting source, got %d", confs)
+	}
+	tracker.swapConfs = tCore.swapConfsSetting
+	if confs := tracker.counterSwapConfs(); confs != 5 {
+		t.Fatalf("expected user setting of 5, got %d", confs)
 	}
 
-	// Active orders error.
-	ensureErr("active orders")
+	// A DEX requirement above the setting takes precedence.
+	btcAsset.SwapConf = 6
+	if confs := tracker.counterSwapConfs(); confs != 6 {
+		t.Fatalf("expected DEX requirement of 6, got %d", confs)
+	}
 
-	tracker.metaData = &db.OrderMetaData{
-		Status: order.OrderStatusExecuted,
+	// Zero removes the setting.
+	if err := tCore.SetSwapConfs(tBTC.ID, 0); err != nil {
+		t.Fatalf("error removing setting: %v", err)
 	}
-	mid := ordertest.RandomMatchID()
-	tracker.matches[mid] = &matchTracker{
-		MetaMatch: db.MetaMatch{
-			MetaData: &db.MatchMetaData{
-				Status: order.NewlyMatched,
-			},
-		},
+	if len(tCore.SwapConfs()) != 0 {
+		t.Fatalf("setting not removed")
 	}
-	// Active orders with matches error.
-	ensureErr("active orders matches")
-	rig.dc.trades = nil
 
-	// Lock wallet error.
-	tDcrWallet.lockErr = tErr
-	ensureErr("lock wallet")
+	rig.db.storeErr = tErr
+	if err := tCore.SetSwapConfs(tBTC.ID, 7); err == nil {
+		t.Fatalf("no error for db error")
+	}
+	rig.db.storeErr = nil
 }
 
-func TestSetEpoch(t *testing.T) {
+func TestMultiWallet(t *testing.T) {
 	rig := newTestRig()
-	dc := rig.dc
-	rig.dc.books[tDcrBtcMktName] = newBookie(func() {})
-	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 0, 0)
-	mkt := dc.market(tDcrBtcMktName)
-	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
-		rig.db, rig.queue, nil, nil, rig.core.notify)
-	dc.trades[lo.ID()] = tracker
-	metaData := tracker.metaData
+	tCore := rig.core
 
-	payload := &msgjson.MatchProofNote{
-		MarketID: tDcrBtcMktName,
-		Epoch:    uint64(tracker.Time()) / tracker.epochLen,
+	// An asset's only wallet is its default wallet, even if it is named.
+	spvWallet, tSpvWallet := newTWallet(tDCR.ID)
+	spvWallet.Name = "spv"
+	tCore.wallets[spvWallet.id()] = spvWallet
+	if w, _ := tCore.wallet(tDCR.ID); w != spvWallet {
+		t.Fatalf("named wallet is not the default")
 	}
-	nextReq := func() *msgjson.Message {
-		payload.Epoch++
-		req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.MatchProofRoute, payload)
-		return req
+
+	// The unnamed wallet is the default.
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	if w, _ := tCore.wallet(tDCR.ID); w != dcrWallet {
+		t.Fatalf("unnamed wallet is not the default")
+	}
+	if w, _ := tCore.namedWallet(tDCR.ID, "spv"); w != spvWallet {
+		t.Fatalf("named wallet not found")
+	}
+	if _, found := tCore.namedWallet(tDCR.ID, "other"); found {
+		t.Fatalf("unknown wallet found")
+	}
+	states := tCore.Wallets()
+	if len(states) != 2 || states[0].Name != "" || states[1].Name != "spv" {
+		t.Fatalf("wrong wallet states")
+	}
+	if err := tCore.CreateWallet(tPW, []byte(wPW), &WalletForm{AssetID: tDCR.ID, Name: "spv"}); err == nil {
+		t.Fatalf("no error for duplicate wallet name")
 	}
 
-	ensureStatus := func(tag string, status order.OrderStatus) {
-		t.Helper()
-		err := handleMatchProofMsg(rig.core, rig.dc, nextReq())
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	for _, w := range []*xcWallet{spvWallet, dcrWallet, btcWallet} {
+		w.Unlock(wPW, time.Hour)
+	}
+
+	// Sell from the named wallet.
+	qty := tDCR.LotSize * 10
+	form := &TradeForm{
+		Host:       tDexHost,
+		IsLimit:    true,
+		Sell:       true,
+		Base:       tDCR.ID,
+		Quote:      tBTC.ID,
+		Qty:        qty,
+		Rate:       tBTC.RateStep * 1000,
+		BaseWallet: "spv",
+	}
+	tSpvWallet.fundCoins = asset.Coins{&tCoin{id: encode.RandomBytes(36), val: qty * 2}}
+	handleLimit := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.LimitOrder)
+		err := msg.Unmarshal(msgOrder)
 		if err != nil {
-			t.Fatalf("error setting epoch for %s: %v", tag, err)
-		}
-		if metaData.Status != status {
-			t.Fatalf("wrong status for %s. expected %s, got %s", tag, status, metaData.Status)
-		}
-		corder, _ := tracker.coreOrderInternal()
-		if corder.Status != status {
-			t.Fatalf("wrong core order status for %s. expected %s, got %s", tag, status, corder.Status)
+			t.Fatalf("unmarshal error: %v", err)
 		}
+		lo := convertMsgLimitOrder(msgOrder)
+		f(orderResponse(msg.ID, msgOrder, lo, false, false, false))
+		return nil
 	}
-
-	// Immediate limit order
-	ensureStatus("immediate limit order", order.OrderStatusExecuted)
-
-	// Standing limit order
-	metaData.Status = order.OrderStatusEpoch
-	lo.Force = order.StandingTiF
-	ensureStatus("standing limit order", order.OrderStatusBooked)
-
-	// Market order
-	mo := &order.MarketOrder{
-		P: lo.P,
-		T: *lo.T.Copy(),
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	corder, err := tCore.Trade(tPW, form)
+	if err != nil {
+		t.Fatalf("trade error: %v", err)
 	}
-	mo.P.OrderType = order.LimitOrderType
-	dbOrder = &db.MetaOrder{
-		MetaData: &db.OrderMetaData{
-			Status: order.OrderStatusEpoch,
-		},
-		Order: mo,
+	if tSpvWallet.fundedVal != qty || tDcrWallet.fundedVal != 0 {
+		t.Fatalf("order not funded by the named wallet")
+	}
+	oid, _ := order.IDFromHex(corder.ID)
+	tracker := rig.dc.trades[oid]
+	if tracker.metaData.BaseWallet != "spv" || tracker.metaData.QuoteWallet != "" {
+		t.Fatalf("wrong order wallets %q and %q", tracker.metaData.BaseWallet, tracker.metaData.QuoteWallet)
+	}
+	if tracker.wallets.fromWallet != spvWallet {
+		t.Fatalf("wrong tracker wallet")
 	}
-	tracker = newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen, rig.core.lockTimeTaker, rig.core.lockTimeMaker,
-		rig.db, rig.queue, nil, nil, rig.core.notify)
-	metaData = tracker.metaData
-	dc.trades[mo.ID()] = tracker
-	ensureStatus("market order", order.OrderStatusExecuted)
-
-	// Same epoch shouldn't result in change.
-	payload.Epoch--
-	metaData.Status = order.OrderStatusEpoch
-	ensureStatus("market order unchanged", order.OrderStatusEpoch)
-
-}
 
-func makeLimitOrder(dc *dexConnection, sell bool, qty, rate uint64) (*order.LimitOrder, *db.MetaOrder, order.Preimage, string) {
-	preImg := newPreimage()
-	addr := ordertest.RandomAddress()
-	lo := &order.LimitOrder{
-		P: order.Prefix{
-			AccountID:  dc.acct.ID(),
-			BaseAsset:  tDCR.ID,
-			QuoteAsset: tBTC.ID,
-			OrderType:  order.LimitOrderType,
-			ClientTime: time.Now(),
-			ServerTime: time.Now().Add(time.Millisecond),
-			Commit:     preImg.Commit(),
-		},
-		T: order.Trade{
-			Sell:     true,
-			Quantity: qty,
-			Address:  addr,
-		},
-		Rate:  tBTC.RateStep,
-		Force: order.ImmediateTiF,
+	// Unknown wallet.
+	form.BaseWallet = "other"
+	if _, err = tCore.Trade(tPW, form); err == nil {
+		t.Fatalf("no error for unknown wallet")
 	}
-	dbOrder := &db.MetaOrder{
-		MetaData: &db.OrderMetaData{
-			Status: order.OrderStatusEpoch,
-			Host:   dc.acct.host,
-			Proof: db.OrderProof{
-				Preimage: preImg[:],
-			},
-		},
-		Order: lo,
+
+	// Each of the asset's wallets has its own balance.
+	tSpvWallet.bal = &asset.Balance{Available: 5e8}
+	tDcrWallet.bal = &asset.Balance{Available: 7e8}
+	tCore.updateAssetBalance(tDCR.ID)
+	if spvWallet.cachedBalance().Available != 5e8 || dcrWallet.cachedBalance().Available != 7e8 {
+		t.Fatalf("wrong wallet balances")
 	}
-	return lo, dbOrder, preImg, addr
 }
 
-func TestAddrHost(t *testing.T) {
-	tests := []struct {
-		name, addr, want string
-	}{{
-		name: "scheme, host, and port",
-		addr: "https://localhost:5758",
-		want: "localhost:5758",
-	}, {
-		name: "host and port",
-		addr: "localhost:5758",
-		want: "localhost:5758",
-	}, {
-		name: "just port",
-		addr: ":5758",
-		want: "localhost:5758",
-	}, {
-		name: "ip host and port",
-		addr: "127.0.0.1:5758",
-		want: "127.0.0.1:5758",
-	}, {
-		name: "just host",
-		addr: "thatonedex.com",
-		want: "thatonedex.com",
-	}, {
-		name: "shceme and host",
-		addr: "https://thatonedex.com",
-		want: "thatonedex.com",
-	}, {
-		name: "scheme, host, and path",
-		addr: "https://thatonedex.com/any/path",
-		want: "thatonedex.com",
-	}, {
-		name: "ipv6 host",
-		addr: "[1:2::]",
-		want: "[1:2::]",
-	}, {
-		name: "ipv6 host and port",
-		addr: "[1:2::]:5758",
-		want: "[1:2::]:5758",
-	}, {
-		name: "empty address",
-		want: "localhost",
-	}, {
-		name: "invalid host",
-		addr: "https://\n:1234",
-		want: "https://\n:1234",
-	}, {
-		name: "invalid port",
-		addr: ":asdf",
-		want: ":asdf",
-	}}
-	for _, test := range tests {
-		res := addrHost(test.addr)
-		if res != test.want {
-			t.Fatalf("wanted %s but got %s for test '%s'", test.want, res, test.name)
-		}
-		// Parsing results a second time should produce the same results.
-		res = addrHost(res)
-		if res != test.want {
-			t.Fatalf("wanted %s but got %s for test '%s'", test.want, res, test.name)
+func TestCloneOrder(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+
+	qty := tDCR.LotSize * 10
+	lo, dbOrder, _, _ := makeLimitOrder(rig.dc, true, qty, 0)
+	lo.Force = order.StandingTiF
+	dbOrder.MetaData.Status = order.OrderStatusCanceled
+	rig.db.dbOrder = dbOrder
+	oid := lo.ID().String()
+	tDcrWallet.fundCoins = asset.Coins{&tCoin{id: encode.RandomBytes(36), val: qty * 2}}
+
+	var placed *order.LimitOrder
+	handleLimit := func(msg *msgjson.Message, f msgFunc) error {
+		msgOrder := new(msgjson.LimitOrder)
+		err := msg.Unmarshal(msgOrder)
+		if err != nil {
+			t.Fatalf("unmarshal error: %v", err)
 		}
+		placed = convertMsgLimitOrder(msgOrder)
+		f(orderResponse(msg.ID, msgOrder, placed, false, false, false))
+		return nil
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	_, err := tCore.CloneOrder(tPW, oid, 0)
+	rig.crypter.recryptErr = nil
+	if err == nil {
+		t.Fatalf("no error for password error")
+	}
+
+	// Success with the original rate.
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	corder, err := tCore.CloneOrder(tPW, oid, 0)
+	if err != nil {
+		t.Fatalf("clone error: %v", err)
+	}
+	if corder.ID == oid {
+		t.Fatalf("cloned order has the same ID")
+	}
+	if placed.Quantity != qty || placed.Rate != lo.Rate || !placed.Sell || placed.Force != order.StandingTiF {
+		t.Fatalf("cloned order has the wrong parameters")
+	}
+
+	// Success with a new rate.
+	newRate := lo.Rate * 2
+	rig.ws.queueResponse(msgjson.LimitRoute, handleLimit)
+	if _, err = tCore.CloneOrder(tPW, oid, newRate); err != nil {
+		t.Fatalf("clone with rate error: %v", err)
+	}
+	if placed.Rate != newRate {
+		t.Fatalf("expected rate %d, got %d", newRate, placed.Rate)
+	}
+
+	// An order that is still on the book cannot be cloned.
+	dbOrder.MetaData.Status = order.OrderStatusBooked
+	if _, err = tCore.CloneOrder(tPW, oid, 0); err == nil {
+		t.Fatalf("no error for booked order")
+	}
+	dbOrder.MetaData.Status = order.OrderStatusExecuted
+
+	// A market order cannot be given a rate.
+	dbOrder.Order = &order.MarketOrder{P: lo.P, T: *lo.T.Copy()}
+	if _, err = tCore.CloneOrder(tPW, oid, newRate); err == nil {
+		t.Fatalf("no error for market order rate")
+	}
+
+	// Unknown order ID.
+	if _, err = tCore.CloneOrder(tPW, "abc", 0); err == nil {
+		t.Fatalf("no error for bad order ID")
 	}
 }
 
-func TestAssetBalance(t *testing.T) {
+func TestMatchDeadlines(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
+	dc := rig.dc
+	bTimeout := time.Duration(dc.cfg.BroadcastTimeout) * time.Millisecond
 
-	wallet, tWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = wallet
-	bal := &asset.Balance{
-		Available: 4e7,
-		Immature:  6e7,
-		Locked:    2e8,
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	_, dbOrder, preImg, _ := makeLimitOrder(dc, true, 0, 0)
+	wallets := &walletSet{fromWallet: dcrWallet, fromAsset: tDCR, toAsset: tBTC}
+	tracker := newTrackedTrade(dbOrder, preImg, dc, dc.market(tDcrBtcMktName).EpochLen, tCore.lockTimeTaker,
+		tCore.lockTimeMaker, rig.db, rig.queue, wallets, nil, tCore.notify)
+
+	matchTime := time.Now().Add(-time.Minute)
+	match := &matchTracker{
+		id: ordertest.RandomMatchID(),
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Proof: db.MatchProof{
+					Auth: db.MatchAuth{MatchStamp: encode.UnixMilliU(matchTime)},
+				},
+			},
+			Match: &order.UserMatch{Side: order.Maker},
+		},
 	}
-	tWallet.bal = bal
-	balances, err := tCore.AssetBalance(tDCR.ID)
-	if err != nil {
-		t.Fatalf("error retreiving asset balance: %v", err)
+	msOffset := func(start time.Time, d time.Duration) uint64 {
+		return encode.UnixMilliU(start.Add(d))
 	}
-	dbtest.MustCompareAssetBalances(t, "zero-conf", bal, &balances.Balance)
-}
 
-func TestAssetCounter(t *testing.T) {
-	counts := make(assetCounter)
-	counts.add(1, 1)
-	if len(counts) != 1 {
-		t.Fatalf("count not added")
+	// The maker must swap within the broadcast timeout of the match.
+	dl := tracker.matchDeadlines(match)
+	if dl.Step != "maker swap" || !dl.OurStep || dl.ActBy != msOffset(matchTime, bTimeout) {
+		t.Fatalf("wrong maker swap deadlines %+v", dl)
 	}
-	counts.add(1, 3)
-	if counts[1] != 4 {
-		t.Fatalf("counts not incremented properly")
+	if dl.RefundAt != 0 || dl.CounterRefundAt != 0 {
+		t.Fatalf("refund times set before swaps")
 	}
-	newCounts := assetCounter{
-		1: 100,
-		2: 2,
+
+	// After our swap, the taker's timeout starts when our swap confirms.
+	match.setStatus(order.MakerSwapCast)
+	match.MetaData.Proof.Script = []byte{0x01}
+	match.MetaData.Proof.MakerSwap = encode.RandomBytes(36)
+	tDcrWallet.confs = tDCR.SwapConf - 1
+	tracker.checkSwapConfirmed(match)
+	dl = tracker.matchDeadlines(match)
+	if dl.Step != "taker swap" || dl.OurStep || dl.ActBy != 0 {
+		t.Fatalf("wrong unconfirmed taker swap deadlines %+v", dl)
 	}
-	counts.absorb(newCounts)
-	if len(counts) != 2 {
-		t.Fatalf("counts not absorbed properly")
+	if dl.RefundAt != msOffset(matchTime, tracker.lockTimeMaker) {
+		t.Fatalf("wrong refund time %d", dl.RefundAt)
 	}
-	if counts[2] != 2 {
-		t.Fatalf("absorbed counts not set correctly")
+	tDcrWallet.confs = tDCR.SwapConf
+	tracker.checkSwapConfirmed(match)
+	if match.swapConfirmed.IsZero() {
+		t.Fatalf("swap confirmation time not recorded")
 	}
-	if counts[1] != 104 {
-		t.Fatalf("absorbed counts not combined correctly")
+	dl = tracker.matchDeadlines(match)
+	if dl.ActBy != msOffset(match.swapConfirmed, bTimeout) {
+		t.Fatalf("wrong taker swap deadline %d", dl.ActBy)
+	}
+
+	// The counterparty's swap must confirm before the maker's redeem timeout
+	// starts.
+	match.setStatus(order.TakerSwapCast)
+	counterExpiry := matchTime.Add(tracker.lockTimeTaker)
+	match.counterSwap = &tAuditInfo{
+		expiration: counterExpiry,
+		coin:       &tCoin{confs: 0},
+	}
+	tracker.checkSwapConfirmed(match)
+	dl = tracker.matchDeadlines(match)
+	if dl.Step != "maker redeem" || !dl.OurStep || dl.ActBy != 0 {
+		t.Fatalf("wrong unconfirmed maker redeem deadlines %+v", dl)
+	}
+	if dl.CounterRefundAt != encode.UnixMilliU(counterExpiry) {
+		t.Fatalf("wrong counterparty refund time %d", dl.CounterRefundAt)
+	}
+	match.counterSwap.(*tAuditInfo).coin.confs = tBTC.SwapConf
+	tracker.checkSwapConfirmed(match)
+	if dl = tracker.matchDeadlines(match); dl.ActBy == 0 {
+		t.Fatalf("maker redeem deadline not set")
+	}
+
+	// The taker's redeem timeout starts when the server acks our redeem.
+	match.setStatus(order.MakerRedeemed)
+	redeemTime := time.Now()
+	match.MetaData.Proof.Auth.RedeemStamp = encode.UnixMilliU(redeemTime)
+	dl = tracker.matchDeadlines(match)
+	if dl.Step != "taker redeem" || dl.OurStep || dl.ActBy != msOffset(redeemTime, bTimeout) {
+		t.Fatalf("wrong taker redeem deadlines %+v", dl)
+	}
+
+	// No deadlines for revoked or complete matches.
+	match.MetaData.Proof.IsRevoked = true
+	if tracker.matchDeadlines(match) != nil {
+		t.Fatalf("deadlines for a revoked match")
+	}
+	match.MetaData.Proof.IsRevoked = false
+	match.setStatus(order.MatchComplete)
+	if tracker.matchDeadlines(match) != nil {
+		t.Fatalf("deadlines for a complete match")
 	}
 }
 
-func TestHandleTradeSuspensionMsg(t *testing.T) {
+func TestServerUpgrade(t *testing.T) {
 	rig := newTestRig()
-
 	tCore := rig.core
-	dcrWallet, _ := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-	dcrWallet.Unlock(wPW, time.Hour)
-
-	btcWallet, _ := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-	btcWallet.Unlock(wPW, time.Hour)
+	dc := rig.dc
 
-	// Ensure a non-existent market cannot be suspended.
-	payload := &msgjson.TradeSuspension{
-		MarketID: "dcr_dcr",
+	// A server that predates versioning is not checked.
+	dc.cfg.APIVersion = 0
+	if err := dc.checkRoute(msgjson.EpochReplayRoute); err != nil {
+		t.Fatalf("unversioned server route check error: %v", err)
 	}
-
-	req, _ := msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
-	err := handleTradeSuspensionMsg(rig.core, rig.dc, req)
-	if err == nil {
-		t.Fatal("[handleTradeSuspensionMsg] expected a market " +
-			"ID not found error: %v")
+	dc.cfg.APIVersion = msgjson.APIVersion
+	if err := dc.checkRoute(msgjson.EpochReplayRoute); err != nil {
+		t.Fatalf("current server route check error: %v", err)
+	}
+	msgjson.RouteVersions["future_route"] = msgjson.APIVersion + 1
+	defer delete(msgjson.RouteVersions, "future_route")
+	if err := dc.checkRoute("future_route"); err == nil {
+		t.Fatalf("no error for a route newer than the negotiated version")
+	}
+	// The negotiated version is the client's if the server's is newer.
+	dc.cfg.APIVersion = msgjson.APIVersion + 1
+	if dc.apiVersion() != msgjson.APIVersion {
+		t.Fatalf("wrong negotiated API version %d", dc.apiVersion())
 	}
+	if err := dc.checkRoute("future_route"); err == nil {
+		t.Fatalf("no error for a route newer than the client's version")
+	}
+	dc.cfg.APIVersion = msgjson.APIVersion
 
-	mkt := tDcrBtcMktName
+	// An upgrade to the client's version is not required.
+	dc.cfg.Upgrade = &msgjson.Upgrade{
+		APIVersion: msgjson.APIVersion,
+		Deadline:   encode.UnixMilliU(time.Now().Add(-time.Hour)),
+	}
+	if dc.requiredUpgrade() != nil || dc.checkUpgrade() != nil {
+		t.Fatalf("upgrade required to the current version")
+	}
 
-	// Ensure a suspended market cannot be resuspended.
-	err = rig.dc.suspend(mkt)
-	if err != nil {
-		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+	ch := tCore.NotificationFeed()
+	checkNote := func(wantSeverity db.Severity) {
+		t.Helper()
+		select {
+		case n := <-ch:
+			note, ok := n.(*UpgradeNote)
+			if !ok {
+				t.Fatalf("wrong notification type %T", n)
+			}
+			if note.Severity() != wantSeverity || note.Host != tDexHost {
+				t.Fatalf("wrong upgrade notification severity %d for %s", note.Severity(), note.Host)
+			}
+		default:
+			t.Fatalf("no upgrade notification")
+		}
 	}
 
-	payload = &msgjson.TradeSuspension{
-		MarketID:    mkt,
-		FinalEpoch:  100,
-		SuspendTime: encode.UnixMilliU(time.Now().Add(time.Millisecond * 20)),
-		Persist:     true,
+	// Warn of a newer version before the deadline, but allow trading.
+	dc.cfg.Upgrade = &msgjson.Upgrade{
+		APIVersion: msgjson.APIVersion + 1,
+		Deadline:   encode.UnixMilliU(time.Now().Add(time.Hour)),
+		Notes:      "upgrade to v0.3",
+	}
+	if dc.requiredUpgrade() == nil {
+		t.Fatalf("upgrade not required")
+	}
+	if err := dc.checkUpgrade(); err != nil {
+		t.Fatalf("upgrade enforced before the deadline: %v", err)
+	}
+	tCore.notifyUpgrade(dc)
+	checkNote(db.WarningLevel)
+	if xc := tCore.Exchanges()[tDexHost]; xc.Upgrade == nil || xc.APIVersion != msgjson.APIVersion {
+		t.Fatalf("upgrade not reported with the exchange")
 	}
 
-	req, _ = msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
-	err = handleTradeSuspensionMsg(rig.core, rig.dc, req)
-	if err == nil {
-		t.Fatal("[handleTradeSuspensionMsg] expected a market " +
-			"suspended error: %v")
+	// Refuse to trade after the deadline.
+	dc.cfg.Upgrade.Deadline = encode.UnixMilliU(time.Now().Add(-time.Hour))
+	tCore.notifyUpgrade(dc)
+	checkNote(db.ErrorLevel)
+	_, err := tCore.Trade(tPW, &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     tDCR.LotSize,
+		Rate:    tBTC.RateStep,
+	})
+	if err == nil || !strings.Contains(err.Error(), "requires API version") {
+		t.Fatalf("expected upgrade error for trade, got %v", err)
 	}
+}
 
-	// Suspend a market.
-	err = rig.dc.resume(mkt)
-	if err != nil {
-		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+func TestWalletHealth(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[dcrWallet.id()] = dcrWallet
+	dcrWallet.Unlock(wPW, time.Hour)
+	btcWallet, tBtcWallet := newTWallet(tBTC.ID)
+	tCore.wallets[btcWallet.id()] = btcWallet
+	btcWallet.Unlock(wPW, time.Hour)
+
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	dcrWallet.connector.Connect(ctx)
+	btcWallet.connector.Connect(ctx)
+
+	tDcrWallet.syncStatus = &asset.SyncStatus{
+		Synced:       true,
+		Progress:     1,
+		Height:       100,
+		TargetHeight: 100,
+		Peers:        8,
+		PendingTxs:   2,
 	}
+	tBtcWallet.syncErr = tErr
+	tCore.refreshWalletHealth()
 
-	req, _ = msgjson.NewRequest(rig.dc.NextID(), msgjson.SuspensionRoute, payload)
-	err = handleTradeSuspensionMsg(rig.core, rig.dc, req)
-	if err != nil {
-		t.Fatalf("[handleTradeSuspensionMsg] unexpected error: %v", err)
+	health := tCore.WalletHealth()
+	if len(health) != 2 {
+		t.Fatalf("expected 2 wallets, got %d", len(health))
+	}
+	// Sorted by asset ID.
+	btcHealth, dcrHealth := health[0], health[1]
+	if btcHealth.AssetID != tBTC.ID || dcrHealth.AssetID != tDCR.ID {
+		t.Fatalf("wrong wallet order %d, %d", btcHealth.AssetID, dcrHealth.AssetID)
+	}
+	if dcrHealth.Sync == nil || dcrHealth.Sync.PendingTxs != 2 || len(dcrHealth.Errors) != 0 || dcrHealth.Stamp.IsZero() {
+		t.Fatalf("wrong dcr wallet health %+v", dcrHealth)
+	}
+	if btcHealth.Sync != nil || len(btcHealth.Errors) != 1 {
+		t.Fatalf("wrong btc wallet health %+v", btcHealth)
 	}
 
-	// Wait for the suspend to execute.
-	time.Sleep(time.Millisecond * 40)
+	// Tip change errors are recorded, and only the most recent are kept.
+	for i := 0; i < maxWalletErrors+5; i++ {
+		dcrWallet.recordError(tErr)
+	}
+	if errs := tCore.WalletHealth()[1].Errors; len(errs) != maxWalletErrors {
+		t.Fatalf("expected %d errors, got %d", maxWalletErrors, len(errs))
+	}
 
-	// Ensure trades for a suspended market generate an error.
+	// Trading is blocked while a wallet's sync status is unknown or the
+	// wallet is not synced.
 	form := &TradeForm{
 		Host:    tDexHost,
 		IsLimit: true,
@@ -3419,11 +9030,288 @@ func TestHandleTradeSuspensionMsg(t *testing.T) {
 		Quote:   tBTC.ID,
 		Qty:     tDCR.LotSize * 10,
 		Rate:    tBTC.RateStep * 1000,
-		TifNow:  false,
 	}
+	if _, err := tCore.Trade(tPW, form); err == nil || !strings.Contains(err.Error(), "sync status unknown") {
+		t.Fatalf("expected sync status error, got %v", err)
+	}
+	tBtcWallet.syncErr = nil
+	tDcrWallet.syncStatus = &asset.SyncStatus{
+		Progress:     0.5,
+		Height:       50,
+		TargetHeight: 100,
+		Peers:        8,
+	}
+	if _, err := tCore.Trade(tPW, form); err == nil || !strings.Contains(err.Error(), "not synced") {
+		t.Fatalf("expected not synced error, got %v", err)
+	}
+	if sync := tCore.WalletHealth()[1].Sync; sync == nil || sync.Height != 50 {
+		t.Fatalf("sync status not refreshed by trade: %+v", sync)
+	}
+}
 
-	_, err = rig.core.Trade(tPW, form)
+func TestTradeGuard(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	rate := tBTC.RateStep * 1000
+	book := newBookie(func() {})
+	rig.dc.books[tDcrBtcMktName] = book
+	err := book.Sync(&msgjson.OrderBook{
+		MarketID: tDcrBtcMktName,
+		Seq:      1,
+		Epoch:    1,
+		Orders: []*msgjson.BookOrderNote{{
+			OrderNote: msgjson.OrderNote{OrderID: encode.RandomBytes(32)},
+			TradeNote: msgjson.TradeNote{
+				Side:     msgjson.SellOrderNum,
+				Quantity: tDCR.LotSize,
+				Time:     uint64(time.Now().Unix()),
+				Rate:     rate,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("order book sync error: %v", err)
+	}
+
+	err = tCore.SetTradeGuard(TradeGuard{WarnDeviation: 20, BlockDeviation: 10})
 	if err == nil {
-		t.Fatalf("expected a suspension market error")
+		t.Fatalf("no error for block deviation less than warning deviation")
+	}
+	err = tCore.SetTradeGuard(TradeGuard{WarnDeviation: 10, BlockDeviation: 50, DuplicateWindow: 60})
+	if err != nil {
+		t.Fatalf("SetTradeGuard error: %v", err)
+	}
+
+	newForm := func(r uint64) *TradeForm {
+		return &TradeForm{
+			Host:    tDexHost,
+			IsLimit: true,
+			Sell:    true,
+			Base:    tDCR.ID,
+			Quote:   tBTC.ID,
+			Qty:     tDCR.LotSize * 10,
+			Rate:    r,
+		}
+	}
+	guardErr := func(err error) *TradeGuardError {
+		t.Helper()
+		var e *TradeGuardError
+		if !errors.As(err, &e) {
+			t.Fatalf("expected a TradeGuardError, got %v", err)
+		}
+		return e
+	}
+
+	// A small deviation passes, and a failed order is not a duplicate.
+	placed, err := tCore.guardTrade(newForm(rate * 105 / 100))
+	if err != nil {
+		t.Fatalf("guardTrade error: %v", err)
+	}
+	placed(nil)
+
+	// A large deviation needs confirmation.
+	form := newForm(rate * 2 / 3)
+	_, err = tCore.guardTrade(form)
+	if e := guardErr(err); e.Guard != GuardRateDeviation || e.Blocked || e.MidGap != rate {
+		t.Fatalf("wrong rate deviation error: %+v", e)
+	}
+	form.Confirmed = true
+	placed, err = tCore.guardTrade(form)
+	if err != nil {
+		t.Fatalf("guardTrade error for confirmed order: %v", err)
+	}
+	placed(&Order{ID: "abcd"})
+
+	// An extreme deviation is refused even if confirmed.
+	form = newForm(rate * 3)
+	form.Confirmed = true
+	_, err = tCore.guardTrade(form)
+	if e := guardErr(err); !e.Blocked {
+		t.Fatalf("order not blocked: %+v", e)
+	}
+
+	// The same order again is a duplicate, unless confirmed.
+	form = newForm(rate * 2 / 3)
+	_, err = tCore.guardTrade(form)
+	if e := guardErr(err); e.Guard != GuardRateDeviation {
+		t.Fatalf("expected rate deviation error, got %+v", e)
+	}
+	form = newForm(rate * 105 / 100)
+	placed, err = tCore.guardTrade(form)
+	if err != nil {
+		t.Fatalf("guardTrade error: %v", err)
+	}
+	placed(&Order{ID: "1234"})
+	_, err = tCore.guardTrade(form)
+	if e := guardErr(err); e.Guard != GuardDuplicate || e.OrderID != "1234" {
+		t.Fatalf("wrong duplicate error: %+v", e)
+	}
+	form.Confirmed = true
+	if _, err = tCore.guardTrade(form); err != nil {
+		t.Fatalf("guardTrade error for confirmed duplicate: %v", err)
+	}
+
+	// Disabled guards pass everything.
+	if err = tCore.SetTradeGuard(TradeGuard{}); err != nil {
+		t.Fatalf("SetTradeGuard error: %v", err)
+	}
+	form = newForm(rate * 3)
+	if _, err = tCore.guardTrade(form); err != nil {
+		t.Fatalf("guardTrade error with disabled guards: %v", err)
+	}
+	if _, err = tCore.guardTrade(form); err != nil {
+		t.Fatalf("guardTrade error for duplicate with disabled guards: %v", err)
+	}
+	if guard := tCore.TradeGuard(); guard != (TradeGuard{}) {
+		t.Fatalf("wrong trade guard: %+v", guard)
+	}
+}
+
+func TestServerTrust(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.db.accts = []*db.AccountInfo{rig.acct.dbInfo()}
+
+	newCert := func() []byte {
+		t.Helper()
+		cert, _, err := certgen.NewTLSCertPair(elliptic.P256(), "dcrdex", time.Now().Add(time.Hour), nil)
+		if err != nil {
+			t.Fatalf("error generating certificate: %v", err)
+		}
+		return cert
+	}
+	cert := newCert()
+	hasNote := func(topic Topic) bool {
+		rig.db.noteMtx.Lock()
+		defer rig.db.noteMtx.Unlock()
+		for _, note := range rig.db.savedNotes {
+			if note.Topic == string(topic) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Bad inputs.
+	if _, err := tCore.UpdateServerTrust(tPW, tDexHost, []byte("not a cert"), nil); err == nil {
+		t.Fatalf("no error for an invalid certificate")
+	}
+	if _, err := tCore.UpdateServerTrust(tPW, tDexHost, nil, dex.Bytes{0x02, 0x01}); err == nil {
+		t.Fatalf("no error for an invalid key")
+	}
+	if _, err := tCore.UpdateServerTrust(tPW, "otherdex.tld", cert, nil); err == nil {
+		t.Fatalf("no error for an unregistered DEX")
+	}
+
+	// A key that the DEX does not sign with is rejected.
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	rig.queueConfig()
+	_, err := tCore.UpdateServerTrust(tPW, tDexHost, nil, otherKey.PubKey().SerializeCompressed())
+	if !errorHasCode(err, connectionErr) {
+		t.Fatalf("wrong error for a key the DEX does not sign with: %v", err)
+	}
+	if rig.db.dexKeyUpdate != nil {
+		t.Fatalf("key saved after failed verification")
+	}
+
+	// A new certificate is saved and applied to the connection.
+	rig.queueConfig()
+	trust, err := tCore.UpdateServerTrust(tPW, tDexHost, cert, nil)
+	if err != nil {
+		t.Fatalf("UpdateServerTrust error: %v", err)
+	}
+	if !bytes.Equal(rig.db.certUpdate, cert) || !bytes.Equal(rig.acct.certificate(), cert) {
+		t.Fatalf("certificate not updated")
+	}
+	if trust.CertFingerprint == "" || !trust.Registered {
+		t.Fatalf("wrong server trust: %+v", trust)
+	}
+	if !hasNote(TopicServerCertChanged) {
+		t.Fatalf("no warning of the certificate change")
+	}
+	if hasNote(TopicServerKeyChanged) {
+		t.Fatalf("warning of an unchanged key")
+	}
+
+	// Import a bundle for an unregistered DEX from a file.
+	const otherHost = "otherdex.tld:7232"
+	otherCert := newCert()
+	bundle := &ServerBundle{
+		Host:   otherHost,
+		Cert:   string(otherCert),
+		PubKey: otherKey.PubKey().SerializeCompressed(),
+	}
+	tmpDir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(tmpDir)
+	bundlePath := filepath.Join(tmpDir, "bundle.json")
+	writeBundle := func() {
+		t.Helper()
+		b, _ := json.Marshal(bundle)
+		if err := ioutil.WriteFile(bundlePath, b, 0600); err != nil {
+			t.Fatalf("error writing bundle: %v", err)
+		}
+	}
+	writeBundle()
+	trust, err = tCore.ImportServerBundle(tPW, bundlePath)
+	if err != nil {
+		t.Fatalf("ImportServerBundle error: %v", err)
+	}
+	if trust.Registered || trust.Host != otherHost {
+		t.Fatalf("wrong server trust: %+v", trust)
+	}
+	trusts, err := tCore.ServerTrust()
+	if err != nil {
+		t.Fatalf("ServerTrust error: %v", err)
+	}
+	if len(trusts) != 2 || trusts[0].Host != otherHost || trusts[1].Host != tDexHost {
+		t.Fatalf("wrong server trusts")
+	}
+	ai := tCore.pinnedAccountInfo(otherHost, nil)
+	if !bytes.Equal(ai.Cert, otherCert) || !ai.DEXPubKey.IsEqual(otherKey.PubKey()) {
+		t.Fatalf("pins not used for a new connection")
+	}
+	if ai = tCore.pinnedAccountInfo(otherHost, cert); !bytes.Equal(ai.Cert, cert) {
+		t.Fatalf("provided certificate not used for a new connection")
+	}
+
+	// The pins are loaded from the database.
+	tCore.pins = nil
+	tCore.loadPins()
+	if tCore.pins[otherHost] == nil {
+		t.Fatalf("pins not loaded")
+	}
+
+	// A changed pin warns the user.
+	bundle.PubKey = tDexKey.SerializeCompressed()
+	writeBundle()
+	if _, err = tCore.ImportServerBundle(tPW, bundlePath); err != nil {
+		t.Fatalf("ImportServerBundle error: %v", err)
+	}
+	if !hasNote(TopicServerKeyChanged) {
+		t.Fatalf("no warning of the key change")
+	}
+
+	// Import a bundle for a registered DEX from a URL.
+	bundle = &ServerBundle{
+		Host: tDexHost,
+		Cert: string(newCert()),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(bundle)
+	}))
+	defer srv.Close()
+	rig.queueConfig()
+	if trust, err = tCore.ImportServerBundle(tPW, srv.URL); err != nil {
+		t.Fatalf("ImportServerBundle error: %v", err)
+	}
+	if !trust.Registered || !bytes.Equal(rig.db.certUpdate, []byte(bundle.Cert)) {
+		t.Fatalf("registered DEX not updated from bundle")
+	}
+
+	// Registering at the DEX removes its pins.
+	tCore.unpinServer(otherHost)
+	if len(tCore.pins) != 0 {
+		t.Fatalf("pins not removed")
 	}
 }
@@ -0,0 +1,271 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+)
+
+// depositAddressesKey is the app-level db key for the user's deposit
+// addresses.
+const depositAddressesKey = "depositAddresses"
+
+var (
+	// depositInterval is how often the deposit addresses are checked for
+	// incoming deposits.
+	depositInterval = time.Minute
+)
+
+// DepositAddress is an address generated for receiving deposits to a wallet,
+// with the deposits that it has received.
+type DepositAddress struct {
+	AssetID uint32 `json:"assetID"`
+	Address string `json:"address"`
+	Label   string `json:"label"`
+	// Stamp is the time the address was generated, in milliseconds.
+	Stamp    uint64     `json:"stamp"`
+	Deposits []*Deposit `json:"deposits"`
+}
+
+// Deposit is a coin received by a deposit address. Deposits are recorded when
+// they are first seen by the wallet, and their confirmations are updated until
+// they are spent.
+type Deposit struct {
+	CoinID        dex.Bytes `json:"coinID"`
+	Coin          string    `json:"coin"`
+	Value         uint64    `json:"value"`
+	Confirmations uint32    `json:"confs"`
+	// Stamp is the time the deposit was first seen, in milliseconds.
+	Stamp uint64 `json:"stamp"`
+}
+
+// copy makes a deep copy of the DepositAddress.
+func (a *DepositAddress) copy() *DepositAddress {
+	addr := *a
+	addr.Deposits = make([]*Deposit, 0, len(a.Deposits))
+	for _, dep := range a.Deposits {
+		d := *dep
+		addr.Deposits = append(addr.Deposits, &d)
+	}
+	return &addr
+}
+
+// NewDepositAddress generates a new deposit address for the asset's wallet,
+// with a label for the user's reference. The address is saved to the
+// database, and its deposits are tracked if the wallet supports it.
+func (c *Core) NewDepositAddress(assetID uint32, label string) (*DepositAddress, error) {
+	wallet, err := c.connectedWallet(assetID)
+	if err != nil {
+		return nil, err
+	}
+	addrStr, err := wallet.Address()
+	if err != nil {
+		return nil, fmt.Errorf("error getting new %s address: %v", unbip(assetID), err)
+	}
+	addr := &DepositAddress{
+		AssetID:  assetID,
+		Address:  addrStr,
+		Label:    label,
+		Stamp:    encode.UnixMilliU(time.Now()),
+		Deposits: []*Deposit{},
+	}
+	c.depositMtx.Lock()
+	defer c.depositMtx.Unlock()
+	c.depositAddrs[assetID] = append(c.depositAddrs[assetID], addr)
+	if err := c.storeDepositAddresses(); err != nil {
+		addrs := c.depositAddrs[assetID]
+		c.depositAddrs[assetID] = addrs[:len(addrs)-1]
+		return nil, err
+	}
+	return addr.copy(), nil
+}
+
+// DepositAddresses returns the deposit addresses generated for the asset's
+// wallet, newest first, with their deposits. If the wallet is connected, the
+// deposits are refreshed first.
+func (c *Core) DepositAddresses(assetID uint32) []*DepositAddress {
+	if wallet, found := c.wallet(assetID); found && wallet.connected() {
+		c.checkDeposits(assetID, wallet)
+	}
+	c.depositMtx.RLock()
+	defer c.depositMtx.RUnlock()
+	stored := c.depositAddrs[assetID]
+	addrs := make([]*DepositAddress, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		addrs = append(addrs, stored[i].copy())
+	}
+	return addrs
+}
+
+// SetDepositLabel sets the label of the asset's deposit address.
+func (c *Core) SetDepositLabel(assetID uint32, address, label string) error {
+	c.depositMtx.Lock()
+	defer c.depositMtx.Unlock()
+	for _, addr := range c.depositAddrs[assetID] {
+		if addr.Address != address {
+			continue
+		}
+		oldLabel := addr.Label
+		addr.Label = label
+		if err := c.storeDepositAddresses(); err != nil {
+			addr.Label = oldLabel
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown %s deposit address %s", unbip(assetID), address)
+}
+
+// storeDepositAddresses saves the deposit addresses to the database. The
+// depositMtx must be locked.
+func (c *Core) storeDepositAddresses() error {
+	b, err := json.Marshal(c.depositAddrs)
+	if err != nil {
+		return fmt.Errorf("error encoding deposit addresses: %v", err)
+	}
+	if err = c.db.Store(depositAddressesKey, b); err != nil {
+		return fmt.Errorf("error saving deposit addresses: %v", err)
+	}
+	return nil
+}
+
+// loadDepositAddresses loads the user's deposit addresses from the database.
+func (c *Core) loadDepositAddresses() {
+	exists, err := c.db.ValueExists(depositAddressesKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(depositAddressesKey)
+	if err != nil {
+		log.Errorf("error loading deposit addresses: %v", err)
+		return
+	}
+	addrs := make(map[uint32][]*DepositAddress)
+	if err = json.Unmarshal(b, &addrs); err != nil {
+		log.Errorf("error decoding deposit addresses: %v", err)
+		return
+	}
+	c.depositMtx.Lock()
+	c.depositAddrs = addrs
+	c.depositMtx.Unlock()
+}
+
+// runDeposits checks the deposit addresses for incoming deposits until the
+// context is canceled.
+func (c *Core) runDeposits(ctx context.Context) {
+	ticker := time.NewTicker(depositInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.depositMtx.RLock()
+			assetIDs := make([]uint32, 0, len(c.depositAddrs))
+			for assetID := range c.depositAddrs {
+				assetIDs = append(assetIDs, assetID)
+			}
+			c.depositMtx.RUnlock()
+			for _, assetID := range assetIDs {
+				wallet, found := c.wallet(assetID)
+				if !found || !wallet.connected() {
+					continue
+				}
+				c.checkDeposits(assetID, wallet)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkDeposits records new deposits to the asset's deposit addresses and
+// updates the confirmations of known deposits. The user is notified of new
+// deposits and when an unconfirmed deposit is first confirmed. Wallets that
+// are not an asset.DepositTracker are not checked.
+func (c *Core) checkDeposits(assetID uint32, wallet *xcWallet) {
+	tracker, ok := wallet.Wallet.(asset.DepositTracker)
+	if !ok {
+		return
+	}
+	c.depositMtx.RLock()
+	addrStrs := make([]string, 0, len(c.depositAddrs[assetID]))
+	for _, addr := range c.depositAddrs[assetID] {
+		addrStrs = append(addrStrs, addr.Address)
+	}
+	c.depositMtx.RUnlock()
+	if len(addrStrs) == 0 {
+		return
+	}
+	deposits, err := tracker.AddressDeposits(addrStrs)
+	if err != nil {
+		log.Errorf("error checking %s deposits: %v", unbip(assetID), err)
+		return
+	}
+
+	c.depositMtx.Lock()
+	var notes []Notification
+	var updated, balanceChanged bool
+	addrs := make(map[string]*DepositAddress, len(c.depositAddrs[assetID]))
+	for _, addr := range c.depositAddrs[assetID] {
+		addrs[addr.Address] = addr
+	}
+	for _, dep := range deposits {
+		addr := addrs[dep.Address]
+		if addr == nil {
+			continue
+		}
+		coin := coinIDString(assetID, dep.CoinID)
+		var known *Deposit
+		for _, d := range addr.Deposits {
+			if bytes.Equal(d.CoinID, dep.CoinID) {
+				known = d
+				break
+			}
+		}
+		if known == nil {
+			addr.Deposits = append(addr.Deposits, &Deposit{
+				CoinID:        dep.CoinID,
+				Coin:          coin,
+				Value:         dep.Value,
+				Confirmations: dep.Confirmations,
+				Stamp:         encode.UnixMilliU(time.Now()),
+			})
+			updated, balanceChanged = true, true
+			notes = append(notes, newDepositNote(TopicDepositReceived, db.Success, addr.copy(),
+				float64(dep.Value)/conversionFactor, unbip(assetID), addr.Address, coin, dep.Confirmations))
+			continue
+		}
+		if dep.Confirmations == known.Confirmations {
+			continue
+		}
+		if known.Confirmations == 0 {
+			balanceChanged = true
+			notes = append(notes, newDepositNote(TopicDepositConfirmed, db.Success, addr.copy(),
+				float64(dep.Value)/conversionFactor, unbip(assetID), addr.Address, coin))
+		}
+		known.Confirmations = dep.Confirmations
+		updated = true
+	}
+	if updated {
+		if err := c.storeDepositAddresses(); err != nil {
+			log.Errorf("error storing %s deposits: %v", unbip(assetID), err)
+		}
+	}
+	c.depositMtx.Unlock()
+
+	for _, note := range notes {
+		c.notify(note)
+	}
+	if balanceChanged {
+		c.updateAssetBalance(assetID)
+	}
+}
@@ -0,0 +1,120 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+)
+
+// devicePromptTimeout is how long a hardware wallet waits for the user to
+// respond to a PIN or passphrase prompt.
+var devicePromptTimeout = 2 * time.Minute
+
+// DevicePrompt is a request for the user to interact with a hardware wallet.
+// Prompts of kind asset.PromptPIN and asset.PromptPassphrase await a response
+// via RespondDevicePrompt.
+type DevicePrompt struct {
+	ID      string `json:"id"`
+	AssetID uint32 `json:"assetID"`
+	Kind    string `json:"kind"`
+	Device  string `json:"device"`
+	Message string `json:"message"`
+	Stamp   uint64 `json:"stamp"`
+}
+
+// DevicePromptNote is a notification that a hardware wallet needs the user's
+// attention.
+type DevicePromptNote struct {
+	db.Notification
+	Prompt *DevicePrompt `json:"prompt"`
+}
+
+func newDevicePromptNote(prompt *DevicePrompt) *DevicePromptNote {
+	return &DevicePromptNote{
+		Notification: db.NewNotification(NoteTypeDevicePrompt, prompt.Device, prompt.Message, db.Poke),
+		Prompt:       prompt,
+	}
+}
+
+// pendingPrompt is a device prompt awaiting the user's response.
+type pendingPrompt struct {
+	prompt *DevicePrompt
+	resp   chan string
+}
+
+// devicePrompter creates the asset.Prompter for the asset's wallet. The user
+// is notified of every prompt. The Prompter waits for the response to a PIN or
+// passphrase prompt, up to devicePromptTimeout.
+func (c *Core) devicePrompter(assetID uint32) asset.Prompter {
+	return func(p *asset.DevicePrompt) (string, error) {
+		prompt := &DevicePrompt{
+			ID:      hex.EncodeToString(encode.RandomBytes(8)),
+			AssetID: assetID,
+			Kind:    p.Kind,
+			Device:  p.Device,
+			Message: p.Message,
+			Stamp:   encode.UnixMilliU(time.Now()),
+		}
+		if p.Kind == asset.PromptConfirm {
+			c.notify(newDevicePromptNote(prompt))
+			return "", nil
+		}
+		pending := &pendingPrompt{
+			prompt: prompt,
+			resp:   make(chan string, 1),
+		}
+		c.promptMtx.Lock()
+		c.prompts[prompt.ID] = pending
+		c.promptMtx.Unlock()
+		defer func() {
+			c.promptMtx.Lock()
+			delete(c.prompts, prompt.ID)
+			c.promptMtx.Unlock()
+		}()
+		c.notify(newDevicePromptNote(prompt))
+		select {
+		case resp := <-pending.resp:
+			return resp, nil
+		case <-time.After(devicePromptTimeout):
+			return "", fmt.Errorf("timed out waiting for %s %s", p.Device, p.Kind)
+		case <-c.ctx.Done():
+			return "", fmt.Errorf("shutting down")
+		}
+	}
+}
+
+// DevicePrompts lists the hardware wallet prompts awaiting a response.
+func (c *Core) DevicePrompts() []*DevicePrompt {
+	c.promptMtx.Lock()
+	defer c.promptMtx.Unlock()
+	prompts := make([]*DevicePrompt, 0, len(c.prompts))
+	for _, pending := range c.prompts {
+		prompts = append(prompts, pending.prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Stamp < prompts[j].Stamp })
+	return prompts
+}
+
+// RespondDevicePrompt provides the user's PIN or passphrase for the hardware
+// wallet prompt.
+func (c *Core) RespondDevicePrompt(id string, response []byte) error {
+	c.promptMtx.Lock()
+	pending, found := c.prompts[id]
+	if found {
+		delete(c.prompts, id)
+	}
+	c.promptMtx.Unlock()
+	if !found {
+		return fmt.Errorf("no pending device prompt %s", id)
+	}
+	pending.resp <- string(response)
+	return nil
+}
@@ -0,0 +1,138 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/order"
+)
+
+// cancelOnDisconnectKey is the app-level db key for the cancel-on-disconnect
+// settings.
+const cancelOnDisconnectKey = "cancelOnDisconnect"
+
+// CancelOnDisconnect returns the cancel-on-disconnect settings, the number of
+// epochs keyed by DEX host.
+func (c *Core) CancelOnDisconnect() map[string]uint32 {
+	c.codMtx.RLock()
+	defer c.codMtx.RUnlock()
+	settings := make(map[string]uint32, len(c.cancelEpochs))
+	for host, epochs := range c.cancelEpochs {
+		settings[host] = epochs
+	}
+	return settings
+}
+
+// SetCancelOnDisconnect sets the number of epochs that the connection to the
+// DEX server may be lost before the user's standing orders are canceled, and
+// saves the setting to the database. Zero disables the setting. The DEX server
+// does not cancel orders for a disconnected client, so the cancel orders are
+// sent as soon as the connection is restored, before any other orders can be
+// matched in a market the user could not see.
+func (c *Core) SetCancelOnDisconnect(dexAddr string, epochs uint32) error {
+	if dexAddr == "" {
+		return newError(emptyHostErr, "no dex address specified")
+	}
+	host := addrHost(dexAddr)
+
+	c.codMtx.Lock()
+	defer c.codMtx.Unlock()
+	settings := make(map[string]uint32, len(c.cancelEpochs)+1)
+	for k, v := range c.cancelEpochs {
+		settings[k] = v
+	}
+	if epochs == 0 {
+		delete(settings, host)
+	} else {
+		settings[host] = epochs
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error encoding cancel-on-disconnect settings: %v", err)
+	}
+	if err = c.db.Store(cancelOnDisconnectKey, b); err != nil {
+		return fmt.Errorf("error saving cancel-on-disconnect settings: %v", err)
+	}
+	c.cancelEpochs = settings
+	return nil
+}
+
+// loadCancelOnDisconnect loads the cancel-on-disconnect settings from the
+// database.
+func (c *Core) loadCancelOnDisconnect() {
+	exists, err := c.db.ValueExists(cancelOnDisconnectKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(cancelOnDisconnectKey)
+	if err != nil {
+		log.Errorf("error loading cancel-on-disconnect settings: %v", err)
+		return
+	}
+	settings := make(map[string]uint32)
+	if err = json.Unmarshal(b, &settings); err != nil {
+		log.Errorf("error decoding cancel-on-disconnect settings: %v", err)
+		return
+	}
+	c.codMtx.Lock()
+	c.cancelEpochs = settings
+	c.codMtx.Unlock()
+}
+
+// cancelStaleOrders cancels the user's standing orders in each market where
+// the connection was lost for at least the set number of the market's epochs.
+// cancelStaleOrders should be called once the connection is re-authenticated
+// and the orders are resynced.
+func (c *Core) cancelStaleOrders(dc *dexConnection, downtime time.Duration) {
+	c.codMtx.RLock()
+	epochs := c.cancelEpochs[dc.acct.host]
+	c.codMtx.RUnlock()
+	if epochs == 0 {
+		return
+	}
+
+	var oids []order.OrderID
+	dc.tradeMtx.RLock()
+	for oid, tracker := range dc.trades {
+		if tracker.Type() != order.LimitOrderType {
+			continue
+		}
+		if downtime < time.Duration(epochs)*time.Duration(tracker.epochLen)*time.Millisecond {
+			continue
+		}
+		tracker.mtx.RLock()
+		standing := tracker.metaData.Status == order.OrderStatusBooked && tracker.cancel == nil
+		tracker.mtx.RUnlock()
+		if standing {
+			oids = append(oids, oid)
+		}
+	}
+	dc.tradeMtx.RUnlock()
+	if len(oids) == 0 {
+		return
+	}
+
+	sort.Slice(oids, func(i, j int) bool {
+		return oids[i].String() < oids[j].String()
+	})
+	var canceled int
+	for _, oid := range oids {
+		if err := c.cancelOrder(oid); err != nil {
+			log.Errorf("error canceling order %s after disconnect: %v", oid, err)
+			continue
+		}
+		canceled++
+	}
+	lvl := db.WarningLevel
+	if canceled < len(oids) {
+		lvl = db.ErrorLevel
+	}
+	c.notify(newOrderNote(TopicOrdersCanceledOnDrop, lvl, nil,
+		canceled, len(oids), dc.acct.host, downtime.Round(time.Second)))
+}
@@ -22,12 +22,16 @@ const (
 	emptyHostErr
 	connectionErr
 	acctKeyErr
+	accountLabelErr
 )
 
-// Error is an error message and an error code.
+// Error is an error message and an error code. The format string and
+// arguments of the message are kept so that it can be translated.
 type Error struct {
-	s    string
-	code int
+	s      string
+	code   int
+	format string
+	args   []interface{}
 }
 
 // Error returns the error string. Satisfies the error interface.
@@ -38,8 +42,10 @@ func (e *Error) Error() string {
 // newError is a constructor for a new Error.
 func newError(code int, s string, a ...interface{}) error {
 	return &Error{
-		s:    fmt.Sprintf(s, a...),
-		code: code,
+		s:      fmt.Sprintf(s, a...),
+		code:   code,
+		format: s,
+		args:   a,
 	}
 }
 
@@ -0,0 +1,302 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+)
+
+// Trade history export formats.
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// ExportedOrder is a completed order in a TradeHistory.
+type ExportedOrder struct {
+	Host     string   `json:"host"`
+	MarketID string   `json:"market"`
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Sell     bool     `json:"sell"`
+	Stamp    uint64   `json:"stamp"`
+	Qty      uint64   `json:"qty"`
+	Rate     uint64   `json:"rate"` // limit only
+	Filled   uint64   `json:"filled"`
+	Status   string   `json:"status"`
+	Notes    string   `json:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ExportedMatch is a settled or refunded match in a TradeHistory. Sent and
+// Received are the amounts that actually changed hands, in atoms of SentAsset
+// and ReceivedAsset. A refunded match has nothing sent or received. SwapFee is
+// the network fee for the swap transaction, in atoms of SentAsset, estimated
+// from the match's fee rate and the asset's swap size. Tags are the tags of the
+// match and its order, and Notes are the match's notes, or its order's notes
+// if the match has none.
+type ExportedMatch struct {
+	Host          string   `json:"host"`
+	MarketID      string   `json:"market"`
+	OrderID       string   `json:"orderID"`
+	MatchID       string   `json:"matchID"`
+	Side          string   `json:"side"`
+	Sell          bool     `json:"sell"`
+	Stamp         uint64   `json:"stamp"`
+	Rate          uint64   `json:"rate"`
+	Qty           uint64   `json:"qty"`
+	SentAsset     string   `json:"sentAsset"`
+	Sent          uint64   `json:"sent"`
+	ReceivedAsset string   `json:"receivedAsset"`
+	Received      uint64   `json:"received"`
+	SwapFee       uint64   `json:"swapFee"`
+	Refunded      bool     `json:"refunded"`
+	Notes         string   `json:"notes,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// AssetPL is the realized profit or loss in a single asset. Net is Received
+// less Sent and Fees, and may be negative. FiatNet is Net valued at the
+// current FiatRate, if known.
+type AssetPL struct {
+	AssetID  uint32  `json:"assetID"`
+	Symbol   string  `json:"symbol"`
+	Sent     uint64  `json:"sent"`
+	Received uint64  `json:"received"`
+	Fees     uint64  `json:"fees"`
+	Net      int64   `json:"net"`
+	FiatRate float64 `json:"fiatRate,omitempty"`
+	FiatNet  float64 `json:"fiatNet,omitempty"`
+}
+
+// TradeHistory is the user's completed orders and their matches from every
+// DEX, with the realized amounts per asset. Currency is the fiat currency of
+// the assets' fiat values, if a RateSource is configured.
+type TradeHistory struct {
+	Orders   []*ExportedOrder `json:"orders"`
+	Matches  []*ExportedMatch `json:"matches"`
+	Assets   []*AssetPL       `json:"assets"`
+	Currency string           `json:"currency,omitempty"`
+}
+
+// TradeHistory loads the completed orders and matches for every account from
+// the database. Orders that are still in the epoch queue or booked are not
+// included. Only matches that were settled or refunded are included.
+func (c *Core) TradeHistory() (*TradeHistory, error) {
+	accts, err := c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving accounts: %v", err)
+	}
+
+	history := &TradeHistory{
+		Orders:  make([]*ExportedOrder, 0),
+		Matches: make([]*ExportedMatch, 0),
+		Assets:  make([]*AssetPL, 0),
+	}
+	pls := make(map[uint32]*AssetPL)
+	assetPL := func(assetID uint32) *AssetPL {
+		pl, found := pls[assetID]
+		if !found {
+			pl = &AssetPL{
+				AssetID: assetID,
+				Symbol:  unbip(assetID),
+			}
+			pls[assetID] = pl
+		}
+		return pl
+	}
+
+	for _, acct := range accts {
+		ords, err := c.db.AccountOrders(acct.Host, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving orders for %s: %v", acct.Host, err)
+		}
+		for _, mOrd := range ords {
+			ord := mOrd.Order
+			if ord.Type() == order.CancelOrderType {
+				continue
+			}
+			switch mOrd.MetaData.Status {
+			case order.OrderStatusEpoch, order.OrderStatusBooked, order.OrderStatusUnknown:
+				continue
+			}
+			trade := ord.Trade()
+			var rate uint64
+			if lo, ok := ord.(*order.LimitOrder); ok {
+				rate = lo.Rate
+			}
+			history.Orders = append(history.Orders, &ExportedOrder{
+				Host:     acct.Host,
+				MarketID: marketName(ord.Base(), ord.Quote()),
+				ID:       ord.ID().String(),
+				Type:     ord.Type().String(),
+				Sell:     trade.Sell,
+				Stamp:    uint64(ord.Time()),
+				Qty:      trade.Quantity,
+				Rate:     rate,
+				Filled:   trade.Filled(),
+				Status:   mOrd.MetaData.Status.String(),
+				Notes:    mOrd.MetaData.Notes,
+				Tags:     mOrd.MetaData.Tags,
+			})
+
+			matches, err := c.db.MatchesForOrder(ord.ID())
+			if err != nil {
+				return nil, fmt.Errorf("error retrieving matches for order %s: %v", ord.ID(), err)
+			}
+			for _, mMatch := range matches {
+				em := c.exportedMatch(acct.Host, ord, mMatch)
+				if em == nil {
+					continue
+				}
+				em.Notes = mMatch.MetaData.Notes
+				if em.Notes == "" {
+					em.Notes = mOrd.MetaData.Notes
+				}
+				em.Tags = mergeTags(mOrd.MetaData.Tags, mMatch.MetaData.Tags)
+				history.Matches = append(history.Matches, em)
+				fromID, toID := ord.Quote(), ord.Base()
+				if em.Sell {
+					fromID, toID = toID, fromID
+				}
+				from, to := assetPL(fromID), assetPL(toID)
+				from.Sent += em.Sent
+				from.Fees += em.SwapFee
+				to.Received += em.Received
+			}
+		}
+	}
+
+	sort.Slice(history.Orders, func(i, j int) bool {
+		return history.Orders[i].Stamp < history.Orders[j].Stamp
+	})
+	sort.Slice(history.Matches, func(i, j int) bool {
+		return history.Matches[i].Stamp < history.Matches[j].Stamp
+	})
+	var rates map[uint32]float64
+	history.Currency, rates = c.currentFiatRates()
+	for assetID, pl := range pls {
+		pl.Net = int64(pl.Received) - int64(pl.Sent) - int64(pl.Fees)
+		if rate, found := rates[assetID]; found {
+			pl.FiatRate = rate
+			pl.FiatNet = float64(pl.Net) / conversionFactor * rate
+		}
+		history.Assets = append(history.Assets, pl)
+	}
+	sort.Slice(history.Assets, func(i, j int) bool {
+		return history.Assets[i].AssetID < history.Assets[j].AssetID
+	})
+	return history, nil
+}
+
+// exportedMatch creates an ExportedMatch for a settled or refunded match, or
+// returns nil if the match is neither. The maker has settled once they have
+// redeemed, and the taker once the match is complete.
+func (c *Core) exportedMatch(host string, ord order.Order, mMatch *db.MetaMatch) *ExportedMatch {
+	match, proof := mMatch.Match, &mMatch.MetaData.Proof
+	refunded := proof.RefundCoin != nil
+	settled := match.Status == order.MatchComplete ||
+		(match.Side == order.Maker && match.Status >= order.MakerRedeemed)
+	if !refunded && !settled {
+		return nil
+	}
+
+	sell := ord.Trade().Sell
+	fromID, toID := ord.Quote(), ord.Base()
+	quoteQty := calc.BaseToQuote(match.Rate, match.Quantity)
+	sent, received := quoteQty, match.Quantity
+	if sell {
+		fromID, toID = toID, fromID
+		sent, received = received, sent
+	}
+	em := &ExportedMatch{
+		Host:          host,
+		MarketID:      marketName(ord.Base(), ord.Quote()),
+		OrderID:       match.OrderID.String(),
+		MatchID:       match.MatchID.String(),
+		Side:          match.Side.String(),
+		Sell:          sell,
+		Stamp:         proof.Auth.MatchStamp,
+		Rate:          match.Rate,
+		Qty:           match.Quantity,
+		SentAsset:     unbip(fromID),
+		ReceivedAsset: unbip(toID),
+		Refunded:      refunded,
+	}
+	if proof.Script != nil {
+		em.SwapFee = match.FeeRateSwap * c.swapSize(host, fromID)
+	}
+	if !refunded {
+		em.Sent, em.Received = sent, received
+	}
+	return em
+}
+
+// swapSize is the size of a swap transaction for the asset, as reported by the
+// DEX, or zero if it is not known.
+func (c *Core) swapSize(host string, assetID uint32) uint64 {
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return 0
+	}
+	if a := dc.asset(assetID); a != nil {
+		return a.SwapSize
+	}
+	return 0
+}
+
+// WriteCSV writes the matches as CSV with a header row. Amounts and rates are
+// written in whole units of the asset.
+func (h *TradeHistory) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"host", "market", "order_id", "match_id", "side",
+		"sell", "time", "rate", "quantity", "sent_asset", "sent",
+		"received_asset", "received", "swap_fee", "refunded", "notes", "tags"})
+	if err != nil {
+		return err
+	}
+	for _, m := range h.Matches {
+		err = writer.Write([]string{
+			m.Host,
+			m.MarketID,
+			m.OrderID,
+			m.MatchID,
+			m.Side,
+			strconv.FormatBool(m.Sell),
+			strconv.FormatUint(m.Stamp, 10),
+			formatAtoms(m.Rate),
+			formatAtoms(m.Qty),
+			m.SentAsset,
+			formatAtoms(m.Sent),
+			m.ReceivedAsset,
+			formatAtoms(m.Received),
+			formatAtoms(m.SwapFee),
+			strconv.FormatBool(m.Refunded),
+			m.Notes,
+			strings.Join(m.Tags, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatAtoms formats the integer quantity of atoms as a decimal number of
+// whole units.
+func formatAtoms(v uint64) string {
+	return fmt.Sprintf("%d.%08d", v/conversionFactor, v%conversionFactor)
+}
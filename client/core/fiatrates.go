@@ -0,0 +1,421 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex/order"
+)
+
+// The built-in fiat rate sources. All report USD rates.
+const (
+	CoinGecko   = "coingecko"
+	CoinPaprika = "coinpaprika"
+	Binance     = "binance"
+)
+
+const (
+	// DefaultFiatRateExpiry is how long a MedianRates caches rates.
+	DefaultFiatRateExpiry = 5 * time.Minute
+	// fiatRateRefreshInterval is how often Core refreshes its fiat rates.
+	fiatRateRefreshInterval = 5 * time.Minute
+	// fiatRequestTimeout is the timeout of a request to a built-in fiat rate
+	// source.
+	fiatRequestTimeout = 10 * time.Second
+)
+
+var (
+	// The URLs of the built-in fiat rate sources. These are variables to
+	// facilitate testing.
+	coinGeckoURL   = "https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd"
+	coinPaprikaURL = "https://api.coinpaprika.com/v1/tickers/%s?quotes=USD"
+	binanceURL     = "https://api.binance.com/api/v3/ticker/price?symbol=%s"
+
+	// coinGeckoIDs, coinPaprikaIDs, and binanceSymbols map asset symbols to
+	// the source's coin IDs or trading pairs.
+	coinGeckoIDs = map[string]string{
+		"btc": "bitcoin",
+		"dcr": "decred",
+		"eth": "ethereum",
+		"ltc": "litecoin",
+	}
+	coinPaprikaIDs = map[string]string{
+		"btc": "btc-bitcoin",
+		"dcr": "dcr-decred",
+		"eth": "eth-ethereum",
+		"ltc": "ltc-litecoin",
+	}
+	binanceSymbols = map[string]string{
+		"btc": "BTCUSDT",
+		"dcr": "DCRUSDT",
+		"eth": "ETHUSDT",
+		"ltc": "LTCUSDT",
+	}
+)
+
+// rateFetcher fetches the USD rates of the assets.
+type rateFetcher func(ctx context.Context, assetIDs []uint32) (map[uint32]float64, error)
+
+// builtInFetchers are the built-in fiat rate sources by name.
+var builtInFetchers = map[string]rateFetcher{
+	CoinGecko:   fetchCoinGeckoRates,
+	CoinPaprika: fetchCoinPaprikaRates,
+	Binance:     fetchBinanceRates,
+}
+
+// httpRateSource is a RateSource that fetches USD rates from a web API.
+type httpRateSource struct {
+	name  string
+	fetch rateFetcher
+}
+
+// Currency is the fiat currency code. Satisfies the RateSource interface.
+func (s *httpRateSource) Currency() string {
+	return "USD"
+}
+
+// FiatRates fetches the rates of the assets. Satisfies the RateSource
+// interface.
+func (s *httpRateSource) FiatRates(assetIDs []uint32) (map[uint32]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fiatRequestTimeout)
+	defer cancel()
+	rates, err := s.fetch(ctx, assetIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.name, err)
+	}
+	return rates, nil
+}
+
+// BuiltInRateSources creates the named built-in fiat rate sources. If no
+// names are provided, every built-in source is created.
+func BuiltInRateSources(names ...string) ([]RateSource, error) {
+	if len(names) == 0 {
+		for name := range builtInFetchers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	sources := make([]RateSource, 0, len(names))
+	for _, name := range names {
+		fetch, found := builtInFetchers[strings.ToLower(name)]
+		if !found {
+			return nil, fmt.Errorf("unknown fiat rate source %q", name)
+		}
+		sources = append(sources, &httpRateSource{name: strings.ToLower(name), fetch: fetch})
+	}
+	return sources, nil
+}
+
+// cachedRate is a fiat rate and the time it was fetched.
+type cachedRate struct {
+	rate  float64
+	stamp time.Time
+}
+
+// MedianRates is a RateSource that aggregates several RateSources of the same
+// currency. The rate of an asset is the median of the rates reported by the
+// sources, and is cached until it expires. A source that fails is skipped.
+type MedianRates struct {
+	sources  []RateSource
+	currency string
+	expiry   time.Duration
+
+	mtx   sync.Mutex
+	cache map[uint32]*cachedRate
+}
+
+// NewMedianRates is the constructor for a MedianRates. Every source must
+// report the same currency.
+func NewMedianRates(sources []RateSource, expiry time.Duration) (*MedianRates, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no fiat rate sources")
+	}
+	currency := sources[0].Currency()
+	for _, src := range sources[1:] {
+		if src.Currency() != currency {
+			return nil, fmt.Errorf("fiat rate sources report different currencies, %s and %s",
+				currency, src.Currency())
+		}
+	}
+	return &MedianRates{
+		sources:  sources,
+		currency: currency,
+		expiry:   expiry,
+		cache:    make(map[uint32]*cachedRate),
+	}, nil
+}
+
+// Currency is the fiat currency code. Satisfies the RateSource interface.
+func (m *MedianRates) Currency() string {
+	return m.currency
+}
+
+// FiatRates returns the median rate of each asset. Cached rates are used until
+// they expire. An error is returned only if every source fails and no rates
+// are cached. Satisfies the RateSource interface.
+func (m *MedianRates) FiatRates(assetIDs []uint32) (map[uint32]float64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	rates := make(map[uint32]float64, len(assetIDs))
+	var expired []uint32
+	for _, assetID := range assetIDs {
+		cached, found := m.cache[assetID]
+		if found && time.Since(cached.stamp) < m.expiry {
+			rates[assetID] = cached.rate
+			continue
+		}
+		expired = append(expired, assetID)
+	}
+	if len(expired) == 0 {
+		return rates, nil
+	}
+
+	type result struct {
+		rates map[uint32]float64
+		err   error
+	}
+	results := make([]*result, len(m.sources))
+	var wg sync.WaitGroup
+	for i, src := range m.sources {
+		wg.Add(1)
+		go func(i int, src RateSource) {
+			defer wg.Done()
+			rates, err := src.FiatRates(expired)
+			results[i] = &result{rates, err}
+		}(i, src)
+	}
+	wg.Wait()
+
+	reported := make(map[uint32][]float64, len(expired))
+	errs := newErrorSet("fiat rates: ")
+	for _, res := range results {
+		if res.err != nil {
+			errs.addErr(res.err)
+			continue
+		}
+		for assetID, rate := range res.rates {
+			if rate > 0 {
+				reported[assetID] = append(reported[assetID], rate)
+			}
+		}
+	}
+	if err := errs.ifany(); err != nil {
+		if len(errs.errs) == len(m.sources) && len(rates) == 0 {
+			return nil, err
+		}
+		log.Warn(err)
+	}
+	now := time.Now()
+	for assetID, rs := range reported {
+		rate := median(rs)
+		m.cache[assetID] = &cachedRate{rate: rate, stamp: now}
+		rates[assetID] = rate
+	}
+	return rates, nil
+}
+
+// median is the median of the values. The values are sorted in place.
+func median(vals []float64) float64 {
+	sort.Float64s(vals)
+	mid := len(vals) / 2
+	if len(vals)%2 == 0 {
+		return (vals[mid-1] + vals[mid]) / 2
+	}
+	return vals[mid]
+}
+
+// NewFiatRateSource creates a MedianRates from the named built-in fiat rate
+// sources, or from every built-in source if no names are provided.
+func NewFiatRateSource(names ...string) (*MedianRates, error) {
+	sources, err := BuiltInRateSources(names...)
+	if err != nil {
+		return nil, err
+	}
+	return NewMedianRates(sources, DefaultFiatRateExpiry)
+}
+
+// getJSON retrieves the JSON-encoded response of the GET request to the URL.
+func getJSON(ctx context.Context, url string, thing interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(thing)
+}
+
+// fetchCoinGeckoRates fetches the USD rates of the assets from CoinGecko in a
+// single request.
+func fetchCoinGeckoRates(ctx context.Context, assetIDs []uint32) (map[uint32]float64, error) {
+	assets := make(map[string]uint32, len(assetIDs))
+	ids := make([]string, 0, len(assetIDs))
+	for _, assetID := range assetIDs {
+		if id, found := coinGeckoIDs[unbip(assetID)]; found {
+			assets[id] = assetID
+			ids = append(ids, id)
+		}
+	}
+	rates := make(map[uint32]float64, len(ids))
+	if len(ids) == 0 {
+		return rates, nil
+	}
+	var resp map[string]struct {
+		USD float64 `json:"usd"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf(coinGeckoURL, strings.Join(ids, ",")), &resp); err != nil {
+		return nil, err
+	}
+	for id, price := range resp {
+		if assetID, found := assets[id]; found {
+			rates[assetID] = price.USD
+		}
+	}
+	return rates, nil
+}
+
+// fetchCoinPaprikaRates fetches the USD rates of the assets from CoinPaprika,
+// one request per asset.
+func fetchCoinPaprikaRates(ctx context.Context, assetIDs []uint32) (map[uint32]float64, error) {
+	rates := make(map[uint32]float64, len(assetIDs))
+	for _, assetID := range assetIDs {
+		id, found := coinPaprikaIDs[unbip(assetID)]
+		if !found {
+			continue
+		}
+		var resp struct {
+			Quotes map[string]struct {
+				Price float64 `json:"price"`
+			} `json:"quotes"`
+		}
+		if err := getJSON(ctx, fmt.Sprintf(coinPaprikaURL, id), &resp); err != nil {
+			return nil, err
+		}
+		if quote, found := resp.Quotes["USD"]; found {
+			rates[assetID] = quote.Price
+		}
+	}
+	return rates, nil
+}
+
+// fetchBinanceRates fetches the USDT rates of the assets from Binance, one
+// request per asset. USDT is taken to be equivalent to USD.
+func fetchBinanceRates(ctx context.Context, assetIDs []uint32) (map[uint32]float64, error) {
+	rates := make(map[uint32]float64, len(assetIDs))
+	for _, assetID := range assetIDs {
+		pair, found := binanceSymbols[unbip(assetID)]
+		if !found {
+			continue
+		}
+		var resp struct {
+			Price string `json:"price"`
+		}
+		if err := getJSON(ctx, fmt.Sprintf(binanceURL, pair), &resp); err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(resp.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s price %q: %v", pair, resp.Price, err)
+		}
+		rates[assetID] = price
+	}
+	return rates, nil
+}
+
+// runFiatRates refreshes the fiat rates of the supported assets until the
+// context is canceled.
+func (c *Core) runFiatRates(ctx context.Context) {
+	c.refreshFiatRates()
+	ticker := time.NewTicker(fiatRateRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refreshFiatRates()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshFiatRates fetches the fiat rates of the supported assets from the
+// RateSource and stores them for the fiat values of balances, orders, and the
+// trade history. Subscribers are notified of the new rates.
+func (c *Core) refreshFiatRates() {
+	assets := asset.Assets()
+	assetIDs := make([]uint32, 0, len(assets))
+	for assetID := range assets {
+		assetIDs = append(assetIDs, assetID)
+	}
+	rates, err := c.rateSource.FiatRates(assetIDs)
+	if err != nil {
+		log.Errorf("error retrieving fiat rates: %v", err)
+		return
+	}
+	c.fiatMtx.Lock()
+	c.fiatRates = rates
+	c.fiatMtx.Unlock()
+	c.refreshUser()
+	c.notify(newFiatRatesNote(c.rateSource.Currency(), rates))
+}
+
+// currentFiatRates returns the fiat currency and a copy of the last fiat rates
+// retrieved by refreshFiatRates. The currency is empty if no RateSource is
+// configured.
+func (c *Core) currentFiatRates() (string, map[uint32]float64) {
+	if c.rateSource == nil {
+		return "", nil
+	}
+	c.fiatMtx.RLock()
+	defer c.fiatMtx.RUnlock()
+	rates := make(map[uint32]float64, len(c.fiatRates))
+	for assetID, rate := range c.fiatRates {
+		rates[assetID] = rate
+	}
+	return c.rateSource.Currency(), rates
+}
+
+// fiatValue is the fiat value of the quantity of the asset, in atoms, at the
+// last retrieved rate. The value is zero if the rate is not known.
+func (c *Core) fiatValue(assetID uint32, qty uint64) float64 {
+	c.fiatMtx.RLock()
+	rate := c.fiatRates[assetID]
+	c.fiatMtx.RUnlock()
+	return float64(qty) / conversionFactor * rate
+}
+
+// walletState is the wallet's WalletState with the fiat rate of the asset.
+func (c *Core) walletState(w *xcWallet) *WalletState {
+	state := w.state()
+	c.fiatMtx.RLock()
+	state.FiatRate = c.fiatRates[w.AssetID]
+	c.fiatMtx.RUnlock()
+	return state
+}
+
+// setOrderFiatValue sets the fiat value of the order's quantity. The quantity
+// of a market buy order is in units of the quote asset.
+func (c *Core) setOrderFiatValue(corder *Order, base, quote uint32) {
+	assetID := base
+	if corder.Type == order.MarketOrderType && !corder.Sell {
+		assetID = quote
+	}
+	corder.FiatValue = c.fiatValue(assetID, corder.Qty)
+}
@@ -0,0 +1,196 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex"
+)
+
+// fundingStrategiesKey is the app-level db key for the user's default order
+// funding strategies.
+const fundingStrategiesKey = "fundingStrategies"
+
+// validFundingStrategy checks that the strategy is known.
+func validFundingStrategy(strategy asset.FundingStrategy) bool {
+	switch strategy {
+	case asset.FundDefault, asset.FundMinInputs, asset.FundMinChange,
+		asset.FundOldest, asset.FundManual:
+		return true
+	}
+	return false
+}
+
+// FundingStrategies returns the user's default order funding strategies, keyed
+// by asset ID.
+func (c *Core) FundingStrategies() map[uint32]asset.FundingStrategy {
+	c.fundingMtx.RLock()
+	defer c.fundingMtx.RUnlock()
+	strategies := make(map[uint32]asset.FundingStrategy, len(c.fundingStrategies))
+	for assetID, strategy := range c.fundingStrategies {
+		strategies[assetID] = strategy
+	}
+	return strategies
+}
+
+// SetFundingStrategy sets the default coin selection strategy for orders
+// funded by the asset's wallet, and saves the strategies to the database. The
+// manual strategy cannot be a default, since the coins are chosen for each
+// order. FundDefault restores the wallet's own coin selection.
+func (c *Core) SetFundingStrategy(assetID uint32, strategy asset.FundingStrategy) error {
+	if strategy == asset.FundManual || !validFundingStrategy(strategy) {
+		return fmt.Errorf("invalid default funding strategy %q", strategy)
+	}
+	if strategy != asset.FundDefault {
+		wallet, found := c.wallet(assetID)
+		if !found {
+			return fmt.Errorf("no %s wallet", unbip(assetID))
+		}
+		if _, ok := wallet.Wallet.(asset.CoinSelector); !ok {
+			return fmt.Errorf("%s wallet does not support coin selection", unbip(assetID))
+		}
+	}
+
+	c.fundingMtx.Lock()
+	defer c.fundingMtx.Unlock()
+	strategies := make(map[uint32]asset.FundingStrategy, len(c.fundingStrategies)+1)
+	for k, v := range c.fundingStrategies {
+		strategies[k] = v
+	}
+	if strategy == asset.FundDefault {
+		delete(strategies, assetID)
+	} else {
+		strategies[assetID] = strategy
+	}
+	b, err := json.Marshal(strategies)
+	if err != nil {
+		return fmt.Errorf("error encoding funding strategies: %v", err)
+	}
+	if err = c.db.Store(fundingStrategiesKey, b); err != nil {
+		return fmt.Errorf("error saving funding strategies: %v", err)
+	}
+	c.fundingStrategies = strategies
+	return nil
+}
+
+// loadFundingStrategies loads the user's default order funding strategies from
+// the database.
+func (c *Core) loadFundingStrategies() {
+	exists, err := c.db.ValueExists(fundingStrategiesKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(fundingStrategiesKey)
+	if err != nil {
+		log.Errorf("error loading funding strategies: %v", err)
+		return
+	}
+	strategies := make(map[uint32]asset.FundingStrategy)
+	if err = json.Unmarshal(b, &strategies); err != nil {
+		log.Errorf("error decoding funding strategies: %v", err)
+		return
+	}
+	c.fundingMtx.Lock()
+	c.fundingStrategies = strategies
+	c.fundingMtx.Unlock()
+}
+
+// WalletCoin is an unspent coin in a wallet, as listed for manual coin control.
+type WalletCoin struct {
+	*asset.WalletCoin
+	// OrderID is the ID of the active order funded by the coin, if any.
+	OrderID string `json:"orderID,omitempty"`
+}
+
+// WalletCoins lists the unspent coins of the asset's wallet with the name, with
+// their labels and lock status, so that the user can choose the coins that
+// fund an order. An empty name selects the asset's default wallet. Locked
+// coins that fund an active order are marked with the order ID.
+func (c *Core) WalletCoins(assetID uint32, walletName string) ([]*WalletCoin, error) {
+	wallet, found := c.namedWallet(assetID, walletName)
+	if !found {
+		return nil, fmt.Errorf("no %s wallet", unbip(assetID))
+	}
+	lister, ok := wallet.Wallet.(asset.CoinLister)
+	if !ok {
+		return nil, fmt.Errorf("%s wallet does not support coin control", wallet.label())
+	}
+	if !wallet.connected() {
+		return nil, fmt.Errorf("%s wallet is not connected", wallet.label())
+	}
+	walletCoins, err := lister.ListCoins()
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s coins: %v", wallet.label(), err)
+	}
+
+	// Find the orders funded by the locked coins.
+	orderIDs := make(map[string]string)
+	c.connMtx.RLock()
+	conns := make([]*dexConnection, 0, len(c.conns))
+	for _, dc := range c.conns {
+		conns = append(conns, dc)
+	}
+	c.connMtx.RUnlock()
+	for _, dc := range conns {
+		dc.tradeMtx.RLock()
+		for _, t := range dc.trades {
+			if t.wallets.fromWallet != wallet {
+				continue
+			}
+			t.mtx.RLock()
+			for coinID := range t.coins {
+				orderIDs[coinID] = t.ID().String()
+			}
+			t.mtx.RUnlock()
+		}
+		dc.tradeMtx.RUnlock()
+	}
+
+	coins := make([]*WalletCoin, 0, len(walletCoins))
+	for _, coin := range walletCoins {
+		wc := &WalletCoin{WalletCoin: coin}
+		if coin.Locked {
+			wc.OrderID = orderIDs[hex.EncodeToString(coin.ID)]
+		}
+		coins = append(coins, wc)
+	}
+	return coins, nil
+}
+
+// fundOrder funds an order from the wallet. The coins are selected with the
+// form's funding strategy, or with the wallet's default strategy if the form
+// does not specify one. Coins chosen in the form select the manual strategy.
+func (c *Core) fundOrder(wallet *xcWallet, value uint64, nfo *dex.Asset, form *TradeForm) (asset.Coins, error) {
+	strategy := form.Funding
+	if strategy == asset.FundDefault {
+		if len(form.Coins) > 0 {
+			strategy = asset.FundManual
+		} else {
+			c.fundingMtx.RLock()
+			strategy = c.fundingStrategies[wallet.AssetID]
+			c.fundingMtx.RUnlock()
+		}
+	}
+	if !validFundingStrategy(strategy) {
+		return nil, fmt.Errorf("unknown funding strategy %q", strategy)
+	}
+	if strategy != asset.FundManual && len(form.Coins) > 0 {
+		return nil, fmt.Errorf("coins chosen for funding strategy %q", strategy)
+	}
+	if strategy == asset.FundDefault {
+		return wallet.FundOrder(value, nfo)
+	}
+	selector, ok := wallet.Wallet.(asset.CoinSelector)
+	if !ok {
+		return nil, fmt.Errorf("%s wallet does not support coin selection", unbip(wallet.AssetID))
+	}
+	return selector.FundOrderWith(value, nfo, &asset.FundingOptions{
+		Strategy: strategy,
+		CoinIDs:  form.Coins,
+	})
+}
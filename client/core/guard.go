@@ -0,0 +1,252 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// tradeGuardKey is the app-level db key for the user's trade guard settings.
+const tradeGuardKey = "tradeGuard"
+
+// Trade guards that can stop an order.
+const (
+	GuardRateDeviation = "ratedeviation"
+	GuardDuplicate     = "duplicate"
+)
+
+// TradeGuard is the protection against mistyped and accidentally repeated
+// orders placed with Trade. Scheduled orders are not guarded, and bots confirm
+// their orders.
+type TradeGuard struct {
+	// WarnDeviation is the percentage by which a limit order's rate may
+	// differ from the market's mid-gap rate before the order must be
+	// confirmed. Zero disables the warning.
+	WarnDeviation float64 `json:"warnDeviation"`
+	// BlockDeviation is the percentage by which a limit order's rate may
+	// differ from the market's mid-gap rate before the order is refused, even
+	// if confirmed. Zero disables the block.
+	BlockDeviation float64 `json:"blockDeviation"`
+	// DuplicateWindow is the number of seconds after an order is placed during
+	// which an identical order must be confirmed. Zero disables the check.
+	DuplicateWindow uint32 `json:"duplicateWindow"`
+}
+
+// defaultTradeGuard is the trade guard for users that have not changed it.
+var defaultTradeGuard = TradeGuard{
+	WarnDeviation:   10,
+	DuplicateWindow: 10,
+}
+
+// TradeGuardError is the error returned by Trade when an order is stopped by
+// a trade guard. Unless the order is Blocked, it may be placed by submitting
+// it again with TradeForm.Confirmed set.
+type TradeGuardError struct {
+	Guard   string `json:"guard"`
+	Blocked bool   `json:"blocked"`
+	// Rate, MidGap and Deviation describe a rate deviation.
+	Rate      uint64  `json:"rate,omitempty"`
+	MidGap    uint64  `json:"midGap,omitempty"`
+	Deviation float64 `json:"deviation,omitempty"`
+	// OrderID is the ID of the earlier order duplicated by the order.
+	OrderID string `json:"orderID,omitempty"`
+}
+
+// Error satisfies the error interface.
+func (e *TradeGuardError) Error() string {
+	switch e.Guard {
+	case GuardRateDeviation:
+		if e.Blocked {
+			return fmt.Sprintf("order rate %.8f is %.1f%% from the mid-gap rate %.8f, which is beyond the allowed deviation",
+				fmtRate(e.Rate), e.Deviation, fmtRate(e.MidGap))
+		}
+		return fmt.Sprintf("order rate %.8f is %.1f%% from the mid-gap rate %.8f. confirm to place the order",
+			fmtRate(e.Rate), e.Deviation, fmtRate(e.MidGap))
+	case GuardDuplicate:
+		return fmt.Sprintf("order is identical to order %s, which was just placed. confirm to place the order", e.OrderID)
+	}
+	return "order stopped by trade guard " + e.Guard
+}
+
+// fmtRate converts a message-rate to a conventional rate.
+func fmtRate(rate uint64) float64 {
+	return float64(rate) / 1e8
+}
+
+// recentTrade is an order recently submitted with Trade, which is compared to
+// new orders to detect duplicates. The order ID is empty until the order is
+// placed.
+type recentTrade struct {
+	host    string
+	mkt     string
+	isLimit bool
+	sell    bool
+	qty     uint64
+	rate    uint64
+	stamp   time.Time
+	orderID string
+}
+
+// matches is whether the form describes the same order as the recent trade.
+func (t *recentTrade) matches(form *TradeForm) bool {
+	if t.host != form.acctHost() || t.mkt != marketName(form.Base, form.Quote) ||
+		t.isLimit != form.IsLimit || t.sell != form.Sell || t.qty != form.Qty {
+		return false
+	}
+	return !form.IsLimit || t.rate == form.Rate
+}
+
+// TradeGuard returns the user's trade guard settings.
+func (c *Core) TradeGuard() TradeGuard {
+	c.guardMtx.Lock()
+	defer c.guardMtx.Unlock()
+	return c.tradeGuard
+}
+
+// SetTradeGuard changes the user's trade guard settings, and saves them to the
+// database.
+func (c *Core) SetTradeGuard(guard TradeGuard) error {
+	if guard.WarnDeviation < 0 || guard.BlockDeviation < 0 {
+		return fmt.Errorf("negative trade guard deviation")
+	}
+	if guard.WarnDeviation > 0 && guard.BlockDeviation > 0 && guard.BlockDeviation < guard.WarnDeviation {
+		return fmt.Errorf("trade guard block deviation %.1f%% is less than the warning deviation %.1f%%",
+			guard.BlockDeviation, guard.WarnDeviation)
+	}
+	b, err := json.Marshal(guard)
+	if err != nil {
+		return fmt.Errorf("error encoding trade guard: %v", err)
+	}
+	c.guardMtx.Lock()
+	defer c.guardMtx.Unlock()
+	if err = c.db.Store(tradeGuardKey, b); err != nil {
+		return fmt.Errorf("error saving trade guard: %v", err)
+	}
+	c.tradeGuard = guard
+	return nil
+}
+
+// loadTradeGuard loads the user's trade guard settings from the database. The
+// default settings are used if there are none.
+func (c *Core) loadTradeGuard() {
+	c.guardMtx.Lock()
+	c.tradeGuard = defaultTradeGuard
+	c.guardMtx.Unlock()
+	exists, err := c.db.ValueExists(tradeGuardKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(tradeGuardKey)
+	if err != nil {
+		log.Errorf("error loading trade guard: %v", err)
+		return
+	}
+	var guard TradeGuard
+	if err = json.Unmarshal(b, &guard); err != nil {
+		log.Errorf("error decoding trade guard: %v", err)
+		return
+	}
+	c.guardMtx.Lock()
+	c.tradeGuard = guard
+	c.guardMtx.Unlock()
+}
+
+// guardTrade checks the order against the user's trade guards. If the order
+// passes, it is reserved as a recent trade so that a duplicate submitted
+// concurrently is caught too, and the returned function must be called with
+// the result of placing the order.
+func (c *Core) guardTrade(form *TradeForm) (func(*Order), error) {
+	if form.IsLimit {
+		if err := c.checkRateDeviation(form); err != nil {
+			return nil, err
+		}
+	}
+
+	c.guardMtx.Lock()
+	defer c.guardMtx.Unlock()
+	window := time.Duration(c.tradeGuard.DuplicateWindow) * time.Second
+	if window == 0 {
+		return func(*Order) {}, nil
+	}
+	now := time.Now()
+	recent := c.recentTrades[:0]
+	for _, t := range c.recentTrades {
+		if now.Sub(t.stamp) < window {
+			recent = append(recent, t)
+		}
+	}
+	c.recentTrades = recent
+	if !form.Confirmed {
+		for _, t := range c.recentTrades {
+			if t.matches(form) {
+				return nil, &TradeGuardError{
+					Guard:   GuardDuplicate,
+					OrderID: t.orderID,
+				}
+			}
+		}
+	}
+	trade := &recentTrade{
+		host:    form.acctHost(),
+		mkt:     marketName(form.Base, form.Quote),
+		isLimit: form.IsLimit,
+		sell:    form.Sell,
+		qty:     form.Qty,
+		rate:    form.Rate,
+		stamp:   now,
+	}
+	c.recentTrades = append(c.recentTrades, trade)
+	return func(ord *Order) {
+		c.guardMtx.Lock()
+		defer c.guardMtx.Unlock()
+		if ord != nil {
+			trade.orderID = ord.ID
+			return
+		}
+		for i, t := range c.recentTrades {
+			if t == trade {
+				c.recentTrades = append(c.recentTrades[:i], c.recentTrades[i+1:]...)
+				return
+			}
+		}
+	}, nil
+}
+
+// checkRateDeviation checks the rate of the limit order against the market's
+// mid-gap rate. There is no check if the market's book is not synced or is
+// empty.
+func (c *Core) checkRateDeviation(form *TradeForm) error {
+	guard := c.TradeGuard()
+	if guard.WarnDeviation == 0 && guard.BlockDeviation == 0 {
+		return nil
+	}
+	c.connMtx.RLock()
+	dc, found := c.conns[form.acctHost()]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil
+	}
+	midGap, err := dc.midGap(form.Base, form.Quote)
+	if err != nil || midGap == 0 {
+		return nil
+	}
+	deviation := math.Abs(float64(form.Rate)-float64(midGap)) / float64(midGap) * 100
+	guardErr := &TradeGuardError{
+		Guard:     GuardRateDeviation,
+		Rate:      form.Rate,
+		MidGap:    midGap,
+		Deviation: deviation,
+	}
+	if guard.BlockDeviation > 0 && deviation > guard.BlockDeviation {
+		guardErr.Blocked = true
+		return guardErr
+	}
+	if guard.WarnDeviation > 0 && deviation > guard.WarnDeviation && !form.Confirmed {
+		return guardErr
+	}
+	return nil
+}
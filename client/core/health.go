@@ -0,0 +1,166 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+)
+
+const (
+	// maxWalletErrors is the number of recent errors kept for each wallet.
+	maxWalletErrors = 10
+)
+
+var (
+	// walletHealthInterval is how often the wallets' sync status is refreshed.
+	walletHealthInterval = 30 * time.Second
+)
+
+// WalletError is an error reported by a wallet, such as a failure of its
+// block monitoring, or a failure to get its sync status.
+type WalletError struct {
+	Stamp time.Time `json:"stamp"`
+	Error string    `json:"error"`
+}
+
+// WalletHealth is the health of a wallet. Sync is nil if the wallet is not
+// connected, or does not report its sync status. Stamp is the time of the
+// last sync status refresh.
+type WalletHealth struct {
+	AssetID uint32            `json:"assetID"`
+	Symbol  string            `json:"symbol"`
+	Name    string            `json:"name"`
+	Running bool              `json:"running"`
+	Sync    *asset.SyncStatus `json:"sync,omitempty"`
+	Stamp   time.Time         `json:"stamp"`
+	Errors  []*WalletError    `json:"errors"`
+}
+
+// recordError adds the error to the wallet's recent errors.
+func (w *xcWallet) recordError(err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.errs = append(w.errs, &WalletError{
+		Stamp: time.Now(),
+		Error: err.Error(),
+	})
+	if len(w.errs) > maxWalletErrors {
+		w.errs = w.errs[len(w.errs)-maxWalletErrors:]
+	}
+}
+
+// refreshSyncStatus gets the wallet's sync status if the wallet is a
+// SyncReporter. A nil status is returned if it is not.
+func (w *xcWallet) refreshSyncStatus() (*asset.SyncStatus, error) {
+	sr, ok := w.Wallet.(asset.SyncReporter)
+	if !ok {
+		return nil, nil
+	}
+	status, err := sr.SyncStatus()
+	if err != nil {
+		err = fmt.Errorf("error getting sync status: %w", err)
+		w.recordError(err)
+		return nil, err
+	}
+	w.mtx.Lock()
+	w.syncStatus, w.syncStamp = status, time.Now()
+	w.mtx.Unlock()
+	return status, nil
+}
+
+// health returns the wallet's last refreshed health.
+func (w *xcWallet) health() *WalletHealth {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	running := w.connector.On()
+	h := &WalletHealth{
+		AssetID: w.AssetID,
+		Symbol:  unbip(w.AssetID),
+		Name:    w.Name,
+		Running: running,
+		Stamp:   w.syncStamp,
+		Errors:  make([]*WalletError, len(w.errs)),
+	}
+	if running && w.syncStatus != nil {
+		status := *w.syncStatus
+		h.Sync = &status
+	}
+	copy(h.Errors, w.errs)
+	return h
+}
+
+// checkSynced refreshes the wallet's sync status, and returns an error if the
+// wallet is not synced. A wallet that does not report its sync status is
+// assumed to be synced.
+func (w *xcWallet) checkSynced() error {
+	status, err := w.refreshSyncStatus()
+	if err != nil {
+		return fmt.Errorf("%s wallet sync status unknown: %v", w.label(), err)
+	}
+	if status != nil && !status.Synced {
+		if status.Peers == 0 {
+			return fmt.Errorf("%s wallet has no network peers. Trading is disabled until the wallet is synced", w.label())
+		}
+		return fmt.Errorf("%s wallet is not synced (%.1f%%, block %d of %d). Trading is disabled until the wallet is synced",
+			w.label(), status.Progress*100, status.Height, status.TargetHeight)
+	}
+	return nil
+}
+
+// WalletHealth returns the health of each wallet, sorted by asset ID and
+// wallet name. The sync status is refreshed in the background every
+// walletHealthInterval.
+func (c *Core) WalletHealth() []*WalletHealth {
+	c.walletMtx.RLock()
+	health := make([]*WalletHealth, 0, len(c.wallets))
+	for _, wallet := range c.wallets {
+		health = append(health, wallet.health())
+	}
+	c.walletMtx.RUnlock()
+	sort.Slice(health, func(i, j int) bool {
+		if health[i].AssetID != health[j].AssetID {
+			return health[i].AssetID < health[j].AssetID
+		}
+		return health[i].Name < health[j].Name
+	})
+	return health
+}
+
+// runWalletHealth refreshes the sync status of the connected wallets every
+// walletHealthInterval until the context is canceled.
+func (c *Core) runWalletHealth(ctx context.Context) {
+	ticker := time.NewTicker(walletHealthInterval)
+	defer ticker.Stop()
+	for {
+		c.refreshWalletHealth()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshWalletHealth refreshes the sync status of the connected wallets.
+func (c *Core) refreshWalletHealth() {
+	c.walletMtx.RLock()
+	wallets := make([]*xcWallet, 0, len(c.wallets))
+	for _, wallet := range c.wallets {
+		wallets = append(wallets, wallet)
+	}
+	c.walletMtx.RUnlock()
+	for _, wallet := range wallets {
+		if !wallet.connected() {
+			continue
+		}
+		if _, err := wallet.refreshSyncStatus(); err != nil {
+			log.Errorf("%s wallet health: %v", wallet.label(), err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+)
+
+// HistoryRecovery summarizes the orders and matches that RecoverHistory added
+// to the database.
+type HistoryRecovery struct {
+	Host    string `json:"host"`
+	Orders  int    `json:"orders"`
+	Matches int    `json:"matches"`
+	// BrokenRule is non-zero if the server reports that the account was closed
+	// for violating a rule of conduct.
+	BrokenRule uint8 `json:"brokenRule"`
+}
+
+// RecoverHistory rebuilds the local history of the account at the DEX from
+// the server's record of the account's orders and matches on every market,
+// such as after the loss of the database. Orders and matches that are already
+// in the database are not modified. The recovered orders are history only.
+// They are not tracked, and any recovered swap that is still in progress must
+// be handled with RecoverableSwaps and RefundSwap.
+func (c *Core) RecoverHistory(host string) (*HistoryRecovery, error) {
+	host = addrHost(host)
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+	if !dc.acct.authed() {
+		return nil, fmt.Errorf("not logged in to %s", host)
+	}
+	if err := dc.checkRoute(msgjson.AccountExportRoute); err != nil {
+		return nil, err
+	}
+
+	rec := &HistoryRecovery{Host: host}
+	var matches []*msgjson.MatchStatusResult
+	mktOf := make(map[order.OrderID]*Market)
+	sells := make(map[order.OrderID]bool)
+	for _, mkt := range dc.markets() {
+		for page := uint32(0); ; page++ {
+			res := new(msgjson.AccountExportResult)
+			err := sendRequest(dc.WsConn, msgjson.AccountExportRoute, &msgjson.AccountExportRequest{
+				Base:  mkt.BaseID,
+				Quote: mkt.QuoteID,
+				Page:  page,
+			}, res)
+			if err != nil {
+				return nil, fmt.Errorf("error exporting %s history from %s: %v", mkt.Name, host, err)
+			}
+			rec.BrokenRule = res.BrokenRule
+			for _, exp := range res.Orders {
+				ord, err := order.DecodeOrder(exp.Order)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding order from %s: %v", host, err)
+				}
+				if ord.User() != dc.acct.ID() {
+					return nil, fmt.Errorf("%s exported order %v for another account", host, ord.ID())
+				}
+				oid := ord.ID()
+				mktOf[oid] = mkt
+				if trade := ord.Trade(); trade != nil {
+					sells[oid] = trade.Sell
+				}
+				if _, err = c.db.Order(oid); err == nil {
+					continue
+				}
+				err = c.db.UpdateOrder(&db.MetaOrder{
+					MetaData: &db.OrderMetaData{
+						Status: order.OrderStatus(exp.Status),
+						Host:   host,
+					},
+					Order: ord,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("error storing order %v: %v", oid, err)
+				}
+				rec.Orders++
+			}
+			matches = append(matches, res.Matches...)
+			if !res.More {
+				break
+			}
+		}
+	}
+
+	// The orders are all stored before the matches, since a match may be
+	// exported on an earlier page than its order.
+	for _, res := range matches {
+		if res.Match == nil || len(res.Match.OrderID) != order.OrderIDSize ||
+			len(res.MatchID) != order.MatchIDSize {
+			return nil, fmt.Errorf("%s exported an invalid match", host)
+		}
+		if err := dc.acct.checkSig(res.Match.Serialize(), res.Match.Sig); err != nil {
+			return nil, fmt.Errorf("invalid signature on match %s from %s: %v", res.MatchID, host, err)
+		}
+		var oid order.OrderID
+		copy(oid[:], res.Match.OrderID)
+		mkt := mktOf[oid]
+		if mkt == nil {
+			return nil, fmt.Errorf("%s exported match %s for an unknown order %v", host, res.MatchID, oid)
+		}
+		var mid order.MatchID
+		copy(mid[:], res.MatchID)
+		known, err := c.db.MatchesForOrder(oid)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving matches for order %v: %v", oid, err)
+		}
+		var isKnown bool
+		for _, m := range known {
+			if m.Match.MatchID == mid {
+				isKnown = true
+				break
+			}
+		}
+		if isKnown {
+			continue
+		}
+		if err = c.db.UpdateMatch(recoveredMatch(host, mkt, res, oid, mid, sells[oid])); err != nil {
+			return nil, fmt.Errorf("error storing match %v: %v", mid, err)
+		}
+		rec.Matches++
+	}
+
+	log.Infof("Recovered %d orders and %d matches from %s", rec.Orders, rec.Matches, host)
+	return rec, nil
+}
+
+// recoveredMatch creates a MetaMatch from the server's record of the match.
+// The counterparty's swap and redemption are recorded, but the user's own
+// swap and redemption are not part of the server's record.
+func recoveredMatch(host string, mkt *Market, res *msgjson.MatchStatusResult, oid order.OrderID,
+	mid order.MatchID, sell bool) *db.MetaMatch {
+
+	msgMatch := res.Match
+	side := order.MatchSide(msgMatch.Side)
+	feeRateSwap := msgMatch.FeeRateQuote
+	if sell {
+		feeRateSwap = msgMatch.FeeRateBase
+	}
+	proof := db.MatchProof{
+		Auth: db.MatchAuth{
+			MatchSig:   msgMatch.Sig,
+			MatchStamp: msgMatch.ServerTime,
+		},
+	}
+	if audit := res.Audit; audit != nil {
+		proof.CounterScript = audit.Contract
+		proof.Auth.AuditSig = audit.Sig
+		proof.Auth.AuditStamp = audit.Time
+		if side == order.Maker {
+			proof.TakerSwap = []byte(audit.CoinID)
+		} else {
+			proof.MakerSwap = []byte(audit.CoinID)
+		}
+	}
+	if redemption := res.Redemption; redemption != nil {
+		proof.Auth.RedemptionSig = redemption.Sig
+		proof.Auth.RedemptionStamp = redemption.Time
+		if side == order.Maker {
+			proof.TakerRedeem = []byte(redemption.CoinID)
+		} else {
+			proof.MakerRedeem = []byte(redemption.CoinID)
+			proof.Secret = redemption.Secret
+		}
+	}
+	return &db.MetaMatch{
+		MetaData: &db.MatchMetaData{
+			Status: order.MatchStatus(res.Status),
+			Proof:  proof,
+			DEX:    host,
+			Base:   mkt.BaseID,
+			Quote:  mkt.QuoteID,
+		},
+		Match: &order.UserMatch{
+			OrderID:     oid,
+			MatchID:     mid,
+			Quantity:    msgMatch.Quantity,
+			Rate:        msgMatch.Rate,
+			Address:     msgMatch.Address,
+			Status:      order.MatchStatus(res.Status),
+			Side:        side,
+			FeeRateSwap: feeRateSwap,
+		},
+	}
+}
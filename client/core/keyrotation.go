@@ -0,0 +1,71 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+)
+
+// dexKeys are the DEX signing keys learned from a verified config response.
+type dexKeys struct {
+	// pubKey is the key that the DEX signs with. If the known key has been
+	// rotated out, pubKey is the new key, and rotated is true.
+	pubKey  *secp256k1.PublicKey
+	rotated bool
+	// nextPubKey and rotation describe a pending key rotation. nextPubKey is
+	// nil if no rotation is pending.
+	nextPubKey *secp256k1.PublicKey
+	rotation   time.Time
+}
+
+// verifyConfig checks the DEX's signature on its config response. knownKey is
+// the DEX key stored with the account, and is nil for a DEX that the client is
+// not registered with, in which case the config's key is accepted. A config
+// signed by a different key is only accepted if the config announces a key
+// rotation from the known key to the signing key, signed by both keys.
+func verifyConfig(cfg *msgjson.ConfigResult, knownKey *secp256k1.PublicKey) (*dexKeys, error) {
+	cfgKey, err := checkSigS256(cfg.Serialize(), cfg.DEXPubKey, cfg.Sig)
+	if err != nil {
+		return nil, fmt.Errorf("config signature validation error: %w", err)
+	}
+
+	var oldKey, newKey *secp256k1.PublicKey
+	var activation time.Time
+	if kr := cfg.KeyRotation; kr != nil {
+		sigMsg := kr.Serialize()
+		oldKey, err = checkSigS256(sigMsg, kr.OldPubKey, kr.OldSig)
+		if err != nil {
+			return nil, fmt.Errorf("key rotation old key signature validation error: %w", err)
+		}
+		newKey, err = checkSigS256(sigMsg, kr.NewPubKey, kr.NewSig)
+		if err != nil {
+			return nil, fmt.Errorf("key rotation new key signature validation error: %w", err)
+		}
+		if !cfgKey.IsEqual(oldKey) && !cfgKey.IsEqual(newKey) {
+			return nil, fmt.Errorf("config signed by a key that is not part of the announced key rotation")
+		}
+		activation = encode.UnixTimeMilli(int64(kr.Activation))
+	}
+
+	keys := &dexKeys{
+		pubKey: cfgKey,
+	}
+	// Until the config is signed by the new key, the rotation is pending.
+	if oldKey != nil && cfgKey.IsEqual(oldKey) {
+		keys.nextPubKey, keys.rotation = newKey, activation
+	}
+	if knownKey == nil || knownKey.IsEqual(cfgKey) {
+		return keys, nil
+	}
+	if oldKey != nil && knownKey.IsEqual(oldKey) && cfgKey.IsEqual(newKey) {
+		keys.rotated = true
+		return keys, nil
+	}
+	return nil, fmt.Errorf("config signed by unknown key %x", cfg.DEXPubKey)
+}
@@ -0,0 +1,332 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"decred.org/dcrdex/client/db"
+)
+
+// Topic identifies a kind of notification, and is the key of the
+// notification's translation in each locale's catalog.
+type Topic string
+
+// The notification topics. Each has a subject and a details template in the
+// origin locale. Topics of Data notifications, whose subjects are category
+// IDs for the frontend, are never translated.
+const (
+	TopicFeePaymentInProgress     Topic = "FeePaymentInProgress"
+	TopicRegUpdate                Topic = "RegUpdate"
+	TopicFeePaymentError          Topic = "FeePaymentError"
+	TopicAccountRegistered        Topic = "AccountRegistered"
+	TopicAccountUnlockError       Topic = "AccountUnlockError"
+	TopicFeeCoinError             Topic = "FeeCoinError"
+	TopicWalletConnectionWarning  Topic = "WalletConnectionWarning"
+	TopicWalletUnlockError        Topic = "WalletUnlockError"
+	TopicDexAuthError             Topic = "DexAuthError"
+	TopicIncompleteRegistration   Topic = "IncompleteRegistration"
+	TopicOrderLoadFailure         Topic = "OrderLoadFailure"
+	TopicOrderResumeFailure       Topic = "OrderResumeFailure"
+	TopicBuyOrderPlaced           Topic = "BuyOrderPlaced"
+	TopicSellOrderPlaced          Topic = "SellOrderPlaced"
+	TopicWalletMissing            Topic = "WalletMissing"
+	TopicMatchErrorCoin           Topic = "MatchErrorCoin"
+	TopicMatchErrorContract       Topic = "MatchErrorContract"
+	TopicMatchRecoveryError       Topic = "MatchRecoveryError"
+	TopicNoFundingCoins           Topic = "NoFundingCoins"
+	TopicOrderCoinError           Topic = "OrderCoinError"
+	TopicOrderCoinFetchError      Topic = "OrderCoinFetchError"
+	TopicMatchRevoked             Topic = "MatchRevoked"
+	TopicOrderRevoked             Topic = "OrderRevoked"
+	TopicOrderRevokedData         Topic = "OrderRevokedData"
+	TopicPreimageSent             Topic = "PreimageSent"
+	TopicCancelPreimageSent       Topic = "CancelPreimageSent"
+	TopicMissingMatches           Topic = "MissingMatches"
+	TopicMatchResolutionError     Topic = "MatchResolutionError"
+	TopicOrderCanceled            Topic = "OrderCanceled"
+	TopicOrderCanceledData        Topic = "OrderCanceledData"
+	TopicMatchesMade              Topic = "MatchesMade"
+	TopicSwapSendError            Topic = "SwapSendError"
+	TopicSwapsInitiated           Topic = "SwapsInitiated"
+	TopicRedemptionError          Topic = "RedemptionError"
+	TopicMatchComplete            Topic = "MatchComplete"
+	TopicRefundFailure            Topic = "RefundFailure"
+	TopicMatchesRefunded          Topic = "MatchesRefunded"
+	TopicMatchRefunded            Topic = "MatchRefunded"
+	TopicRedemptionFeeBumpError   Topic = "RedemptionFeeBumpError"
+	TopicRedemptionFeeBumped      Topic = "RedemptionFeeBumped"
+	TopicRefundAvailable          Topic = "RefundAvailable"
+	TopicRefundConfirmed          Topic = "RefundConfirmed"
+	TopicOrdersCanceledOnDrop     Topic = "OrdersCanceledOnDrop"
+	TopicTradesReconciled         Topic = "TradesReconciled"
+	TopicTradesReconciledWithErrs Topic = "TradesReconciledWithErrs"
+	TopicOrderStatusUpdated       Topic = "OrderStatusUpdated"
+	TopicScheduledOrderFailed     Topic = "ScheduledOrderFailed"
+	TopicScheduledOrderPlaced     Topic = "ScheduledOrderPlaced"
+	TopicDEXConnected             Topic = "DEXConnected"
+	TopicDEXDisconnected          Topic = "DEXDisconnected"
+	TopicDEXResynced              Topic = "DEXResynced"
+	TopicDEXResyncedWithErrs      Topic = "DEXResyncedWithErrs"
+	TopicWithdrawError            Topic = "WithdrawError"
+	TopicWithdrawSent             Topic = "WithdrawSent"
+	TopicMultiWithdrawSent        Topic = "MultiWithdrawSent"
+	TopicAccountReopened          Topic = "AccountReopened"
+	TopicAccountClosed            Topic = "AccountClosed"
+	TopicPriceAlert               Topic = "PriceAlert"
+	TopicOrderFilledAlert         Topic = "OrderFilledAlert"
+	TopicSwapNeedsAttention       Topic = "SwapNeedsAttention"
+	TopicCoinsConsolidated        Topic = "CoinsConsolidated"
+	TopicCoinsSplit               Topic = "CoinsSplit"
+	TopicDepositReceived          Topic = "DepositReceived"
+	TopicDepositConfirmed         Topic = "DepositConfirmed"
+	TopicPaperOrderMatched        Topic = "PaperOrderMatched"
+	TopicPaperOrderFinished       Topic = "PaperOrderFinished"
+	TopicRefundError              Topic = "RefundError"
+	TopicSwapRefunded             Topic = "SwapRefunded"
+	TopicUpgradeRequired          Topic = "UpgradeRequired"
+	TopicUpgradeDeadlinePassed    Topic = "UpgradeDeadlinePassed"
+	TopicServerCertChanged        Topic = "ServerCertChanged"
+	TopicServerKeyChanged         Topic = "ServerKeyChanged"
+	TopicLotSizeChanged           Topic = "LotSizeChanged"
+	TopicOrderLotSizeInvalid      Topic = "OrderLotSizeInvalid"
+	TopicOrderLotSizeUneconomical Topic = "OrderLotSizeUneconomical"
+	TopicOrderLotSizeCanceled     Topic = "OrderLotSizeCanceled"
+	TopicOrderLotSizeReplaced     Topic = "OrderLotSizeReplaced"
+	TopicMakerSwapConfirmed       Topic = "MakerSwapConfirmed"
+	TopicTakerSwapConfirmed       Topic = "TakerSwapConfirmed"
+	TopicMakerRedeemed            Topic = "MakerRedeemed"
+	TopicTakerRedeemed            Topic = "TakerRedeemed"
+)
+
+// translation is the subject and details template of a Topic in a locale. The
+// template is formatted with the notification's arguments, and may use
+// explicit argument indexes, e.g. %[2]s, to reorder them. The subject is
+// formatted too if it has any verbs, in which case it must use explicit
+// indexes.
+type translation struct {
+	subject  string
+	template string
+}
+
+// locale is a message catalog. Topics and errors that are missing from the
+// catalog are rendered in the origin locale.
+type locale struct {
+	name string
+	// sell and buy are the words for the order sides.
+	sell, buy string
+	topics    map[Topic]*translation
+	// errors maps the format strings of the origin locale's errors to
+	// translated format strings.
+	errors map[string]string
+}
+
+// originLang is the language of the strings in the code.
+const originLang = "en-US"
+
+// originLocale is the catalog of the origin language.
+var originLocale = &locale{
+	name: originLang,
+	sell: "sell",
+	buy:  "buy",
+	topics: map[Topic]*translation{
+		TopicFeePaymentInProgress:     {"Fee payment in progress", "Waiting for %d confirmations before trading at %s"},
+		TopicRegUpdate:                {"regupdate", "Fee payment confirmations %v/%v"},
+		TopicFeePaymentError:          {"Fee payment error", "Error encountered while paying fees to %s: %v"},
+		TopicAccountRegistered:        {"Account registered", "You may now trade at %s"},
+		TopicAccountUnlockError:       {"Account unlock error", "error unlocking account for %s: %v"},
+		TopicFeeCoinError:             {"Fee coin error", "Empty fee coin for %s."},
+		TopicWalletConnectionWarning:  {"Wallet connection warning", "Incomplete registration detected for %s, but failed to connect to the Decred wallet"},
+		TopicWalletUnlockError:        {"Wallet unlock error", "Connected to Decred wallet to complete registration at %s, but failed to unlock: %v"},
+		TopicDexAuthError:             {"DEX auth error", "%s: %v"},
+		TopicIncompleteRegistration:   {"Incomplete registration", "Unlock your Decred wallet to complete registration for %s"},
+		TopicOrderLoadFailure:         {"Order load failure", "Some orders failed to load from the database: %v"},
+		TopicOrderResumeFailure:       {"Order resumption error", "Some active orders failed to resume: %v"},
+		TopicBuyOrderPlaced:           {"Order placed", "buying %.8f %s (%s)"},
+		TopicSellOrderPlaced:          {"Order placed", "selling %.8f %s (%s)"},
+		TopicWalletMissing:            {"Wallet missing", "Wallet retrieval error for active order %s: %v"},
+		TopicMatchErrorCoin:           {"Match status error", "Match %s for order %s is in state %s, but has no maker swap coin."},
+		TopicMatchErrorContract:       {"Match status error", "Match %s for order %s is in state %s, but has no maker swap contract."},
+		TopicMatchRecoveryError:       {"Match recovery error", "Error auditing counter-parties swap contract during swap recovery on order %s: %v"},
+		TopicNoFundingCoins:           {"No funding coins", "Order %s has no %s funding coins"},
+		TopicOrderCoinError:           {"Order coin error", "No coins for loaded order %s %s: %v"},
+		TopicOrderCoinFetchError:      {"Order coin error", "Source coins retrieval error for %s %s: %v"},
+		TopicMatchRevoked:             {"Match revoked", "Match %s has been revoked"},
+		TopicOrderRevoked:             {"Order revoked", "%s order on %s-%s at %s has been revoked (%s)"},
+		TopicOrderRevokedData:         {"revoke", ""},
+		TopicPreimageSent:             {"Preimage sent", "match cycle has begun for order %s"},
+		TopicCancelPreimageSent:       {"Preimage sent", "match cycle has begun for cancellation order for trade %s"},
+		TopicMissingMatches:           {"Missing matches", "%d matches for order %s were not reported by %q and are in a failed state"},
+		TopicMatchResolutionError:     {"Match resolution error", "%d matches reported by %s were not found for %s."},
+		TopicOrderCanceled:            {"Order canceled", "%s order on %s-%s at %s has been canceled (%s)"},
+		TopicOrderCanceledData:        {"cancel", ""},
+		TopicMatchesMade:              {"Matches made", "%s order on %s-%s %.1f%% filled (%s)"},
+		TopicSwapSendError:            {"Swap error", "Error encountered sending a swap output(s) worth %.8f %s on order %s"},
+		TopicSwapsInitiated:           {"Swaps initiated", "Sent swaps worth %.8f %s on order %s"},
+		TopicRedemptionError:          {"Redemption error", "Error encountered sending redemptions worth %.8f %s on order %s"},
+		TopicMatchComplete:            {"Match complete", "Redeemed %.8f %s on order %s"},
+		TopicRefundFailure:            {"Refund Failure", "Refunded %.8f %s on order %s, with some errors"},
+		TopicMatchesRefunded:          {"Matches Refunded", "Refunded %.8f %s on order %s"},
+		TopicMatchRefunded:            {"Matches Refunded", "Refunded %s swap for match %s on order %s with %s"},
+		TopicRedemptionFeeBumpError:   {"Redemption fee bump error", "Error bumping the fee of unconfirmed %s redemption %s for match %s on order %s: %v"},
+		TopicRedemptionFeeBumped:      {"Redemption fee bumped", "Raised the fee rate of unconfirmed %s redemption %s for match %s on order %s to %d with transaction %s"},
+		TopicRefundAvailable:          {"Refund available", "The lock time of the %s swap for match %s on order %s has expired. Automatic refunds are disabled, so the swap must be refunded manually."},
+		TopicRefundConfirmed:          {"Refund confirmed", "Refund %s for match %s on order %s has been confirmed"},
+		TopicOrdersCanceledOnDrop:     {"Orders canceled on disconnect", "Canceled %d of %d standing orders on %s after the connection was lost for %s"},
+		TopicTradesReconciled:         {"Trades reconciled", "Reconciled trades with %s at login. %d orders updated, %d matches updated, %d matches revoked."},
+		TopicTradesReconciledWithErrs: {"Trades reconciled", "Reconciled trades with %s at login. %d orders updated, %d matches updated, %d matches revoked. Some trades could not be reconciled. See the log for details."},
+		TopicOrderStatusUpdated:       {"Order status updated", "Order %s is %s"},
+		TopicScheduledOrderFailed:     {"Scheduled order failed", "Failed to place scheduled order to %s %.8f %s on %s: %v"},
+		TopicScheduledOrderPlaced:     {"Scheduled order placed", "Placed scheduled order %s on %s"},
+		TopicDEXConnected:             {"DEX connected", "DEX at %s has connected"},
+		TopicDEXDisconnected:          {"DEX disconnected", "DEX at %s has disconnected"},
+		TopicDEXResynced:              {"DEX resynced", "Resynced with %s after reconnecting. %d order books refreshed, %d orders updated, %d matches updated, %d matches revoked."},
+		TopicDEXResyncedWithErrs:      {"DEX resynced", "Resynced with %s after reconnecting. %d order books refreshed, %d orders updated, %d matches updated, %d matches revoked. Some data could not be resynced. See the log for details."},
+		TopicWithdrawError:            {"Withdraw error", "Error encountered during %s withdraw: %v"},
+		TopicWithdrawSent:             {"Withdraw sent", "Withdraw of %s has completed successfully. Coin ID = %s"},
+		TopicMultiWithdrawSent:        {"Withdraw sent", "Withdraw of %s to %d addresses has completed successfully. Coin IDs = %v"},
+		TopicAccountReopened:          {"Account reopened", "Your account at %s has been reopened. %s"},
+		TopicAccountClosed:            {"Account closed", "Your account at %s has been closed for %s: %s. %s"},
+		TopicPriceAlert:               {"Price alert", "The %s market rate on %s is %.8f, crossing the alert rate of %.8f"},
+		TopicOrderFilledAlert:         {"Order filled", "Order %s on %s is %.1f%% filled (%.8f of %.8f %s)"},
+		TopicSwapNeedsAttention:       {"Swap needs attention", "Match %s for order %s on %s has been at status %s for %d minutes"},
+		TopicCoinsConsolidated:        {"Coins consolidated", "Consolidated %s coins into %s"},
+		TopicCoinsSplit:               {"Coins split", "Created %d %s coins of %.8f for orders"},
+		TopicDepositReceived:          {"Deposit received", "Received %.8f %s to %s in %s, %d confirmations"},
+		TopicDepositConfirmed:         {"Deposit confirmed", "Deposit of %.8f %s to %s in %s has been confirmed"},
+		TopicPaperOrderMatched:        {"Paper order matched", "Paper order %s on %s matched %.8f %s"},
+		TopicPaperOrderFinished:       {"Paper order %[3]s", "Paper order %s on %s is %s"},
+		TopicRefundError:              {"Refund error", "Error refunding %s swap %s for match %s: %v"},
+		TopicSwapRefunded:             {"Swap refunded", "Refunded %s swap %s for match %s with %s"},
+		TopicUpgradeRequired:          {"Upgrade required", "DEX %s will require API version %d from %v. This client speaks version %d.%s"},
+		TopicUpgradeDeadlinePassed:    {"Upgrade deadline passed", "DEX %s requires API version %d since %v. This client speaks version %d, and cannot trade.%s"},
+		TopicServerCertChanged:        {"Server certificate changed", "The TLS certificate pinned for %s has changed from %s to %s"},
+		TopicServerKeyChanged:         {"Server signing key changed", "The signing key pinned for %s has changed from %s to %s"},
+		TopicLotSizeChanged:           {"Lot size changed", "DEX %s changed the %s lot size from %.8f to %.8f, and the rate step from %.8f to %.8f"},
+		TopicOrderLotSizeInvalid:      {"Order invalid after lot size change", "Order %s on %s does not conform to the new lot size of %.8f %s or rate step of %.8f"},
+		TopicOrderLotSizeUneconomical: {"Order uneconomical after lot size change", "The fees to swap one lot of order %s on %s could be as much as %.1f%% of its value"},
+		TopicOrderLotSizeCanceled:     {"Order canceled after lot size change", "Canceled order %s on %s"},
+		TopicOrderLotSizeReplaced:     {"Order replaced after lot size change", "Canceled order %s on %s, and scheduled a replacement to %s %.8f %s at a rate of %.8f"},
+		TopicMakerSwapConfirmed:       {"Maker swap confirmed", "The maker's %s swap %s for match %s on order %s is confirmed"},
+		TopicTakerSwapConfirmed:       {"Taker swap confirmed", "The taker's %s swap %s for match %s on order %s is confirmed"},
+		TopicMakerRedeemed:            {"Maker redeemed", "The maker redeemed the taker's swap with %s redemption %s for match %s on order %s"},
+		TopicTakerRedeemed:            {"Taker redeemed", "The taker redeemed the maker's swap with %s redemption %s for match %s on order %s"},
+	},
+}
+
+// locales are the catalogs of the supported languages, keyed by BCP 47
+// language tag.
+var locales = map[string]*locale{
+	originLang: originLocale,
+	esLang:     esLocale,
+}
+
+// Locales lists the BCP 47 tags of the supported languages.
+func Locales() []string {
+	langs := make([]string, 0, len(locales))
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// MatchLocale finds the supported language for a BCP 47 language tag, e.g. from
+// an HTTP Accept-Language header. A tag that is not supported matches a
+// supported language with the same primary language subtag, so "es-MX" matches
+// "es-ES".
+func MatchLocale(lang string) (string, bool) {
+	lang = strings.TrimSpace(lang)
+	for name := range locales {
+		if strings.EqualFold(name, lang) {
+			return name, true
+		}
+	}
+	primary := strings.SplitN(lang, "-", 2)[0]
+	for _, name := range Locales() {
+		if strings.EqualFold(strings.SplitN(name, "-", 2)[0], primary) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// localeFor is the catalog of the language, or the origin locale if the
+// language is not supported.
+func localeFor(lang string) *locale {
+	if name, found := MatchLocale(lang); found {
+		return locales[name]
+	}
+	return originLocale
+}
+
+// side is an order side argument of a notification, which is rendered in the
+// locale's word for the side. The word is capitalized if title is true.
+type side struct {
+	sell  bool
+	title bool
+}
+
+// format renders the subject and details of the topic with the arguments.
+func (loc *locale) format(topic Topic, args ...interface{}) (subject, details string) {
+	tr, found := loc.topics[topic]
+	if !found {
+		tr, found = originLocale.topics[topic]
+		if !found {
+			return string(topic), fmt.Sprint(args...)
+		}
+	}
+	rendered := make([]interface{}, len(args))
+	for i, arg := range args {
+		rendered[i] = arg
+		if s, ok := arg.(side); ok {
+			word := loc.buy
+			if s.sell {
+				word = loc.sell
+			}
+			if s.title {
+				word = strings.Title(word)
+			}
+			rendered[i] = word
+		}
+	}
+	subject = tr.subject
+	if strings.Contains(subject, "%") {
+		subject = fmt.Sprintf(subject, rendered...)
+	}
+	if tr.template != "" {
+		details = fmt.Sprintf(tr.template, rendered...)
+	}
+	return subject, details
+}
+
+// TranslateNote renders the subject and details of a notification in the
+// language. Notifications without a topic, and those retrieved from the
+// database, which do not keep their arguments, are not translated.
+func TranslateNote(n Notification, lang string) (subject, details string) {
+	note := n.DBNote()
+	if note.Topic == "" || note.Args == nil || note.Severeness < db.Poke {
+		return n.Subject(), n.Details()
+	}
+	return localeFor(lang).format(Topic(note.Topic), note.Args...)
+}
+
+// TranslateError renders the error in the language if it is an Error with a
+// format string in the language's catalog.
+func TranslateError(err error, lang string) string {
+	var e *Error
+	if !errors.As(err, &e) || e.format == "" {
+		return err.Error()
+	}
+	format, found := localeFor(lang).errors[e.format]
+	if !found {
+		return err.Error()
+	}
+	msg := fmt.Sprintf(format, e.args...)
+	// The Error may be wrapped with more context.
+	return strings.Replace(err.Error(), e.s, msg, 1)
+}
@@ -0,0 +1,106 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+const esLang = "es-ES"
+
+// esLocale is the Spanish catalog.
+var esLocale = &locale{
+	name: esLang,
+	sell: "venta",
+	buy:  "compra",
+	topics: map[Topic]*translation{
+		TopicFeePaymentInProgress:     {"Pago de la tarifa en curso", "Esperando %d confirmaciones antes de operar en %s"},
+		TopicFeePaymentError:          {"Error en el pago de la tarifa", "Error al pagar la tarifa a %s: %v"},
+		TopicAccountRegistered:        {"Cuenta registrada", "Ya puede operar en %s"},
+		TopicAccountUnlockError:       {"Error al desbloquear la cuenta", "Error al desbloquear la cuenta de %s: %v"},
+		TopicFeeCoinError:             {"Error en la moneda de la tarifa", "La moneda de la tarifa de %s está vacía."},
+		TopicWalletConnectionWarning:  {"Aviso de conexión de la billetera", "Se detectó un registro incompleto en %s, pero no se pudo conectar a la billetera de Decred"},
+		TopicWalletUnlockError:        {"Error al desbloquear la billetera", "Conectado a la billetera de Decred para completar el registro en %s, pero no se pudo desbloquear: %v"},
+		TopicDexAuthError:             {"Error de autenticación con el DEX", "%s: %v"},
+		TopicIncompleteRegistration:   {"Registro incompleto", "Desbloquee su billetera de Decred para completar el registro en %s"},
+		TopicOrderLoadFailure:         {"Error al cargar órdenes", "Algunas órdenes no se pudieron cargar de la base de datos: %v"},
+		TopicOrderResumeFailure:       {"Error al reanudar órdenes", "Algunas órdenes activas no se pudieron reanudar: %v"},
+		TopicBuyOrderPlaced:           {"Orden realizada", "comprando %.8f %s (%s)"},
+		TopicSellOrderPlaced:          {"Orden realizada", "vendiendo %.8f %s (%s)"},
+		TopicWalletMissing:            {"Falta la billetera", "Error al obtener la billetera de la orden activa %s: %v"},
+		TopicMatchErrorCoin:           {"Error de estado del emparejamiento", "El emparejamiento %s de la orden %s está en el estado %s, pero no tiene la moneda del swap del creador."},
+		TopicMatchErrorContract:       {"Error de estado del emparejamiento", "El emparejamiento %s de la orden %s está en el estado %s, pero no tiene el contrato del swap del creador."},
+		TopicMatchRecoveryError:       {"Error de recuperación del emparejamiento", "Error al auditar el contrato de swap de la contraparte durante la recuperación de la orden %s: %v"},
+		TopicNoFundingCoins:           {"Sin monedas de financiación", "La orden %s no tiene monedas de financiación de %s"},
+		TopicOrderCoinError:           {"Error en las monedas de la orden", "No hay monedas para la orden cargada %s %s: %v"},
+		TopicOrderCoinFetchError:      {"Error en las monedas de la orden", "Error al obtener las monedas de origen de %s %s: %v"},
+		TopicMatchRevoked:             {"Emparejamiento revocado", "Se ha revocado el emparejamiento %s"},
+		TopicOrderRevoked:             {"Orden revocada", "Se ha revocado la orden de %s en %s-%s en %s (%s)"},
+		TopicPreimageSent:             {"Preimagen enviada", "ha comenzado el ciclo de emparejamiento de la orden %s"},
+		TopicCancelPreimageSent:       {"Preimagen enviada", "ha comenzado el ciclo de emparejamiento de la cancelación de la orden %s"},
+		TopicMissingMatches:           {"Emparejamientos ausentes", "%d emparejamientos de la orden %s no fueron reportados por %q y han fallado"},
+		TopicMatchResolutionError:     {"Error de resolución de emparejamientos", "No se encontraron %d emparejamientos reportados por %s para %s."},
+		TopicOrderCanceled:            {"Orden cancelada", "Se ha cancelado la orden de %s en %s-%s en %s (%s)"},
+		TopicMatchesMade:              {"Emparejamientos realizados", "Orden de %s en %s-%s completada al %.1f%% (%s)"},
+		TopicSwapSendError:            {"Error de swap", "Error al enviar salidas de swap por %.8f %s de la orden %s"},
+		TopicSwapsInitiated:           {"Swaps iniciados", "Se enviaron swaps por %.8f %s de la orden %s"},
+		TopicRedemptionError:          {"Error de canje", "Error al enviar canjes por %.8f %s de la orden %s"},
+		TopicMatchComplete:            {"Emparejamiento completado", "Se canjearon %.8f %s de la orden %s"},
+		TopicRefundFailure:            {"Error de reembolso", "Se reembolsaron %.8f %s de la orden %s, con algunos errores"},
+		TopicMatchesRefunded:          {"Emparejamientos reembolsados", "Se reembolsaron %.8f %s de la orden %s"},
+		TopicMatchRefunded:            {"Emparejamientos reembolsados", "Se reembolsó el swap de %s del emparejamiento %s de la orden %s con %s"},
+		TopicRedemptionFeeBumpError:   {"Error al aumentar la tarifa del canje", "Error al aumentar la tarifa del canje sin confirmar de %s %s del emparejamiento %s de la orden %s: %v"},
+		TopicRedemptionFeeBumped:      {"Tarifa del canje aumentada", "Se aumentó la tarifa del canje sin confirmar de %s %s del emparejamiento %s de la orden %s a %d con la transacción %s"},
+		TopicRefundAvailable:          {"Reembolso disponible", "El tiempo de bloqueo del swap de %s del emparejamiento %s de la orden %s ha expirado. Los reembolsos automáticos están desactivados, así que el swap debe reembolsarse manualmente."},
+		TopicRefundConfirmed:          {"Reembolso confirmado", "Se ha confirmado el reembolso %s del emparejamiento %s de la orden %s"},
+		TopicOrdersCanceledOnDrop:     {"Órdenes canceladas por desconexión", "Se cancelaron %d de %d órdenes en %s tras perder la conexión durante %s"},
+		TopicTradesReconciled:         {"Operaciones conciliadas", "Operaciones conciliadas con %s al iniciar sesión. %d órdenes actualizadas, %d emparejamientos actualizados, %d emparejamientos revocados."},
+		TopicTradesReconciledWithErrs: {"Operaciones conciliadas", "Operaciones conciliadas con %s al iniciar sesión. %d órdenes actualizadas, %d emparejamientos actualizados, %d emparejamientos revocados. Algunas operaciones no se pudieron conciliar. Consulte el registro para más detalles."},
+		TopicOrderStatusUpdated:       {"Estado de la orden actualizado", "La orden %s está %s"},
+		TopicScheduledOrderFailed:     {"Error en la orden programada", "No se pudo realizar la orden programada de %s de %.8f %s en %s: %v"},
+		TopicScheduledOrderPlaced:     {"Orden programada realizada", "Se realizó la orden programada %s en %s"},
+		TopicDEXConnected:             {"DEX conectado", "El DEX en %s se ha conectado"},
+		TopicDEXDisconnected:          {"DEX desconectado", "El DEX en %s se ha desconectado"},
+		TopicDEXResynced:              {"DEX resincronizado", "Resincronizado con %s tras reconectar. %d libros de órdenes actualizados, %d órdenes actualizadas, %d emparejamientos actualizados, %d emparejamientos revocados."},
+		TopicDEXResyncedWithErrs:      {"DEX resincronizado", "Resincronizado con %s tras reconectar. %d libros de órdenes actualizados, %d órdenes actualizadas, %d emparejamientos actualizados, %d emparejamientos revocados. Algunos datos no se pudieron resincronizar. Consulte el registro para más detalles."},
+		TopicWithdrawError:            {"Error de retiro", "Error durante el retiro de %s: %v"},
+		TopicWithdrawSent:             {"Retiro enviado", "El retiro de %s se ha completado. ID de la moneda = %s"},
+		TopicMultiWithdrawSent:        {"Retiro enviado", "El retiro de %s a %d direcciones se ha completado. IDs de las monedas = %v"},
+		TopicAccountReopened:          {"Cuenta reabierta", "Su cuenta en %s ha sido reabierta. %s"},
+		TopicAccountClosed:            {"Cuenta cerrada", "Su cuenta en %s ha sido cerrada por %s: %s. %s"},
+		TopicPriceAlert:               {"Alerta de precio", "La cotización del mercado %s en %s es %.8f, y ha cruzado la cotización de alerta de %.8f"},
+		TopicOrderFilledAlert:         {"Orden completada", "La orden %s en %s está completada al %.1f%% (%.8f de %.8f %s)"},
+		TopicSwapNeedsAttention:       {"El swap requiere atención", "El emparejamiento %s de la orden %s en %s lleva en el estado %s durante %d minutos"},
+		TopicCoinsConsolidated:        {"Monedas consolidadas", "Se consolidaron las monedas de %s en %s"},
+		TopicCoinsSplit:               {"Monedas divididas", "Se crearon %d monedas de %s de %.8f para órdenes"},
+		TopicDepositReceived:          {"Depósito recibido", "Se recibieron %.8f %s en %s en %s, %d confirmaciones"},
+		TopicDepositConfirmed:         {"Depósito confirmado", "Se ha confirmado el depósito de %.8f %s en %s en %s"},
+		TopicPaperOrderMatched:        {"Orden simulada emparejada", "La orden simulada %s en %s emparejó %.8f %s"},
+		TopicPaperOrderFinished:       {"Orden simulada: %[3]s", "La orden simulada %s en %s está %s"},
+		TopicRefundError:              {"Error de reembolso", "Error al reembolsar el swap de %s %s del emparejamiento %s: %v"},
+		TopicSwapRefunded:             {"Swap reembolsado", "Se reembolsó el swap de %s %s del emparejamiento %s con %s"},
+		TopicUpgradeRequired:          {"Actualización necesaria", "El DEX %s requerirá la versión %d de la API desde %v. Este cliente usa la versión %d.%s"},
+		TopicUpgradeDeadlinePassed:    {"Plazo de actualización vencido", "El DEX %s requiere la versión %d de la API desde %v. Este cliente usa la versión %d y no puede operar.%s"},
+		TopicLotSizeChanged:           {"Tamaño de lote cambiado", "El DEX %s cambió el tamaño de lote de %s de %.8f a %.8f, y el paso de cotización de %.8f a %.8f"},
+		TopicOrderLotSizeInvalid:      {"Orden no válida tras el cambio de lote", "La orden %s en %s no se ajusta al nuevo tamaño de lote de %.8f %s o al paso de cotización de %.8f"},
+		TopicOrderLotSizeUneconomical: {"Orden poco rentable tras el cambio de lote", "Las tarifas del swap de un lote de la orden %s en %s podrían llegar al %.1f%% de su valor"},
+		TopicOrderLotSizeCanceled:     {"Orden cancelada tras el cambio de lote", "Se canceló la orden %s en %s"},
+		TopicOrderLotSizeReplaced:     {"Orden reemplazada tras el cambio de lote", "Se canceló la orden %s en %s, y se programó un reemplazo para %s %.8f %s a una cotización de %.8f"},
+		TopicMakerSwapConfirmed:       {"Swap del creador confirmado", "El swap de %s %s del creador del emparejamiento %s de la orden %s está confirmado"},
+		TopicTakerSwapConfirmed:       {"Swap del tomador confirmado", "El swap de %s %s del tomador del emparejamiento %s de la orden %s está confirmado"},
+		TopicMakerRedeemed:            {"El creador canjeó", "El creador canjeó el swap del tomador con el canje de %s %s del emparejamiento %s de la orden %s"},
+		TopicTakerRedeemed:            {"El tomador canjeó", "El tomador canjeó el swap del creador con el canje de %s %s del emparejamiento %s de la orden %s"},
+		TopicServerCertChanged:        {"Certificado del servidor cambiado", "El certificado TLS fijado para %s ha cambiado de %s a %s"},
+		TopicServerKeyChanged:         {"Clave de firma del servidor cambiada", "La clave de firma fijada para %s ha cambiado de %s a %s"},
+	},
+	errors: map[string]string{
+		"already registered at %s":                                                                "ya está registrado en %s",
+		"DEX policy signature validation error: %v":                                               "error al validar la firma de la política del DEX: %v",
+		"DEX policy for %s is not signed by the account's DEX key":                                "la política del DEX de %s no está firmada con la clave del DEX de la cuenta",
+		"no dex address specified":                                                                "no se especificó la dirección del DEX",
+		"cannot connect to %s wallet to pay fee: %v":                                              "no se puede conectar a la billetera de %s para pagar la tarifa: %v",
+		"failed to unlock %s wallet: %v":                                                          "no se pudo desbloquear la billetera de %s: %v",
+		"dex server does not support %s asset":                                                    "el servidor del DEX no admite el activo %s",
+		"DEX signature validation error: %v":                                                      "error al validar la firma del DEX: %v",
+		"zero registration fees not allowed":                                                      "no se permiten tarifas de registro nulas",
+		"error paying registration fee: %v":                                                       "error al pagar la tarifa de registro: %v",
+		"registration fee provided to Register does not match the DEX registration fee. %d != %d": "la tarifa de registro indicada no coincide con la tarifa de registro del DEX. %d != %d",
+	},
+}
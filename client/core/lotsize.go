@@ -0,0 +1,341 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+)
+
+// lotSizePolicyKey is the app-level db key for the user's lot size change
+// policy.
+const lotSizePolicyKey = "lotSizePolicy"
+
+// maxLotFeePercent is the largest percentage of the value of one lot that the
+// worst-case swap fee may be before a standing order is flagged as
+// uneconomical.
+const maxLotFeePercent = 10
+
+// LotSizePolicy is what the client does with the user's standing orders that
+// are flagged when a DEX changes the lot size or rate step of an asset.
+type LotSizePolicy string
+
+const (
+	// LotSizeNotify only notifies the user of flagged orders. This is the
+	// default policy.
+	LotSizeNotify LotSizePolicy = "notify"
+	// LotSizeCancel cancels the flagged orders.
+	LotSizeCancel LotSizePolicy = "cancel"
+	// LotSizeReplace cancels the flagged orders, and schedules a replacement
+	// for each invalid order with its remaining quantity rounded down to the
+	// new lot size, and its rate rounded to the new rate step in the user's
+	// favor. The replacement is placed after the cancel order is processed.
+	// Uneconomical orders are only canceled, since a replacement would be
+	// just as uneconomical.
+	LotSizeReplace LotSizePolicy = "replace"
+)
+
+// Issues of a standing order after a lot size or rate step change, reported
+// as Order.LotSizeIssue.
+const (
+	// LotSizeIssueInvalid is an order whose remaining quantity or visible
+	// quantity is not a multiple of the new lot size, or whose rate is not a
+	// multiple of the new rate step.
+	LotSizeIssueInvalid = "invalid"
+	// LotSizeIssueUneconomical is an order for which the worst-case fee of a
+	// one-lot swap is more than maxLotFeePercent of the lot's value.
+	LotSizeIssueUneconomical = "uneconomical"
+)
+
+// LotSizePolicy returns the user's lot size change policy.
+func (c *Core) LotSizePolicy() LotSizePolicy {
+	c.lotPolicyMtx.RLock()
+	defer c.lotPolicyMtx.RUnlock()
+	if c.lotPolicy == "" {
+		return LotSizeNotify
+	}
+	return c.lotPolicy
+}
+
+// SetLotSizePolicy sets what is done with the user's standing orders that
+// become invalid or uneconomical when a DEX changes the lot size or rate step
+// of an asset, and saves the setting to the database.
+func (c *Core) SetLotSizePolicy(policy LotSizePolicy) error {
+	switch policy {
+	case LotSizeNotify, LotSizeCancel, LotSizeReplace:
+	default:
+		return fmt.Errorf("unknown lot size policy %q", policy)
+	}
+	c.lotPolicyMtx.Lock()
+	defer c.lotPolicyMtx.Unlock()
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("error encoding lot size policy: %v", err)
+	}
+	if err = c.db.Store(lotSizePolicyKey, b); err != nil {
+		return fmt.Errorf("error saving lot size policy: %v", err)
+	}
+	c.lotPolicy = policy
+	return nil
+}
+
+// loadLotSizePolicy loads the user's lot size change policy from the database.
+func (c *Core) loadLotSizePolicy() {
+	exists, err := c.db.ValueExists(lotSizePolicyKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(lotSizePolicyKey)
+	if err != nil {
+		log.Errorf("error loading lot size policy: %v", err)
+		return
+	}
+	var policy LotSizePolicy
+	if err = json.Unmarshal(b, &policy); err != nil {
+		log.Errorf("error decoding lot size policy: %v", err)
+		return
+	}
+	c.lotPolicyMtx.Lock()
+	c.lotPolicy = policy
+	c.lotPolicyMtx.Unlock()
+}
+
+// refreshConfig requests the DEX's current configuration, and updates the
+// asset configurations. If the lot size or rate step of any asset changed, the
+// user is notified, and the user's standing orders are checked. Only the asset
+// configurations are updated. Any other change to the markets is learned when
+// the client next connects.
+func (c *Core) refreshConfig(dc *dexConnection) error {
+	cfg := new(msgjson.ConfigResult)
+	err := sendRequest(dc.WsConn, msgjson.ConfigRoute, nil, cfg)
+	if err != nil {
+		return fmt.Errorf("error fetching DEX server config: %v", err)
+	}
+	keys, err := verifyConfig(cfg, dc.acct.dexKey())
+	if err != nil {
+		return fmt.Errorf("error verifying DEX server config: %w", err)
+	}
+	host := dc.acct.host
+	if keys.rotated {
+		log.Infof("DEX %s has rotated its signing key to %x.", host, cfg.DEXPubKey)
+		if err = c.db.UpdateDEXPubKey(host, keys.pubKey); err != nil {
+			log.Errorf("Failed to save the new signing key for DEX %s: %v", host, err)
+		}
+	}
+	dc.acct.setDEXKey(keys.pubKey, keys.nextPubKey, keys.rotation)
+
+	dc.assetsMtx.Lock()
+	assets := make(map[uint32]*dex.Asset, len(dc.assets))
+	for id, a := range dc.assets {
+		assets[id] = a
+	}
+	var changed []*dex.Asset
+	oldAssets := make(map[uint32]*dex.Asset)
+	for _, ai := range cfg.Assets {
+		a := convertAssetInfo(ai)
+		if old, found := assets[a.ID]; found && (old.LotSize != a.LotSize || old.RateStep != a.RateStep) {
+			changed = append(changed, a)
+			oldAssets[a.ID] = old
+		}
+		assets[a.ID] = a
+	}
+	dc.assets = assets
+	dc.assetsMtx.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	changedIDs := make(map[uint32]bool, len(changed))
+	for _, a := range changed {
+		old := oldAssets[a.ID]
+		log.Infof("DEX %s changed the %s lot size from %d to %d, and rate step from %d to %d.",
+			host, a.Symbol, old.LotSize, a.LotSize, old.RateStep, a.RateStep)
+		c.notify(newOrderNote(TopicLotSizeChanged, db.WarningLevel, nil, host, unbip(a.ID),
+			float64(old.LotSize)/conversionFactor, float64(a.LotSize)/conversionFactor,
+			float64(old.RateStep)/conversionFactor, float64(a.RateStep)/conversionFactor))
+		changedIDs[a.ID] = true
+	}
+	dc.refreshMarkets()
+	c.checkLotSizes(dc, changedIDs)
+	return nil
+}
+
+// lotSizeIssue checks the limit order against the DEX's current lot size and
+// rate step, returning LotSizeIssueInvalid, LotSizeIssueUneconomical, or an
+// empty string if the order is fine.
+func (dc *dexConnection) lotSizeIssue(lo *order.LimitOrder) string {
+	base, quote := dc.asset(lo.BaseAsset), dc.asset(lo.QuoteAsset)
+	if base == nil || quote == nil || base.LotSize == 0 {
+		return ""
+	}
+	if lo.Remaining()%base.LotSize != 0 || lo.Visible%base.LotSize != 0 ||
+		(quote.RateStep > 0 && lo.Rate%quote.RateStep != 0) {
+		return LotSizeIssueInvalid
+	}
+	if dc.lotFeePercent(lo) > maxLotFeePercent {
+		return LotSizeIssueUneconomical
+	}
+	return ""
+}
+
+// lotFeePercent is the worst-case fee of a one-lot swap for the limit order,
+// as a percentage of the lot's value. The order's assets must be known.
+func (dc *dexConnection) lotFeePercent(lo *order.LimitOrder) float64 {
+	base, quote := dc.asset(lo.BaseAsset), dc.asset(lo.QuoteAsset)
+	from, lotValue := quote, calc.BaseToQuote(lo.Rate, base.LotSize)
+	if lo.Sell {
+		from, lotValue = base, base.LotSize
+	}
+	if lotValue == 0 {
+		return 100
+	}
+	return float64(from.SwapSize*from.MaxFeeRate) * 100 / float64(lotValue)
+}
+
+// checkLotSizes checks the user's standing limit orders on markets of the
+// changed assets, flags the orders that are invalid or uneconomical with the
+// new lot sizes and rate steps, and applies the user's LotSizePolicy to them.
+// Orders that are no longer flagged have their flag cleared.
+func (c *Core) checkLotSizes(dc *dexConnection, changed map[uint32]bool) {
+	type flaggedOrder struct {
+		tracker *trackedTrade
+		lo      *order.LimitOrder
+		issue   string
+	}
+	var flagged []*flaggedOrder
+	dc.tradeMtx.RLock()
+	for _, tracker := range dc.trades {
+		lo, ok := tracker.Order.(*order.LimitOrder)
+		if !ok || (!changed[lo.BaseAsset] && !changed[lo.QuoteAsset]) {
+			continue
+		}
+		tracker.mtx.Lock()
+		status := tracker.metaData.Status
+		standing := (status == order.OrderStatusEpoch || status == order.OrderStatusBooked) &&
+			lo.Force == order.StandingTiF && tracker.cancel == nil
+		var issue string
+		if standing {
+			issue = dc.lotSizeIssue(lo)
+		}
+		tracker.lotSizeIssue = issue
+		tracker.mtx.Unlock()
+		if issue != "" {
+			flagged = append(flagged, &flaggedOrder{tracker, lo, issue})
+		}
+	}
+	dc.tradeMtx.RUnlock()
+	if len(flagged) == 0 {
+		return
+	}
+
+	sort.Slice(flagged, func(i, j int) bool {
+		return flagged[i].tracker.ID().String() < flagged[j].tracker.ID().String()
+	})
+	policy := c.LotSizePolicy()
+	host := dc.acct.host
+	for _, f := range flagged {
+		tracker, lo := f.tracker, f.lo
+		corder, _ := tracker.coreOrder()
+		base, quote := dc.asset(lo.BaseAsset), dc.asset(lo.QuoteAsset)
+		if f.issue == LotSizeIssueInvalid {
+			c.notify(newOrderNote(TopicOrderLotSizeInvalid, db.WarningLevel, corder, tracker.token(), host,
+				float64(base.LotSize)/conversionFactor, unbip(base.ID), float64(quote.RateStep)/conversionFactor))
+		} else {
+			c.notify(newOrderNote(TopicOrderLotSizeUneconomical, db.WarningLevel, corder, tracker.token(), host,
+				dc.lotFeePercent(lo)))
+		}
+		if policy == LotSizeNotify {
+			continue
+		}
+
+		if err := c.cancelOrder(tracker.ID()); err != nil {
+			log.Errorf("error canceling order %s after a lot size change: %v", tracker.ID(), err)
+			continue
+		}
+		if policy == LotSizeCancel || f.issue != LotSizeIssueInvalid {
+			c.notify(newOrderNote(TopicOrderLotSizeCanceled, db.WarningLevel, corder, tracker.token(), host))
+			continue
+		}
+
+		qty, rate := replacementOrder(lo, base.LotSize, quote.RateStep)
+		if qty == 0 || rate == 0 {
+			c.notify(newOrderNote(TopicOrderLotSizeCanceled, db.WarningLevel, corder, tracker.token(), host))
+			continue
+		}
+		// The funding coins of the canceled order are not returned until the
+		// cancel order is matched at the end of its epoch, so the replacement
+		// is placed an epoch later.
+		now := time.Now()
+		sched := &db.ScheduledOrder{
+			Host:    host,
+			IsLimit: true,
+			Sell:    lo.Sell,
+			Base:    lo.BaseAsset,
+			Quote:   lo.QuoteAsset,
+			Qty:     qty,
+			Rate:    rate,
+			Time:    encode.UnixMilliU(now.Add(2 * time.Duration(tracker.epochLen) * time.Millisecond)),
+			Stamp:   encode.UnixMilliU(now),
+		}
+		if err := c.db.SaveScheduledOrder(sched); err != nil {
+			log.Errorf("error scheduling the replacement of order %s: %v", tracker.ID(), err)
+			c.notify(newOrderNote(TopicOrderLotSizeCanceled, db.WarningLevel, corder, tracker.token(), host))
+			continue
+		}
+		c.notify(newOrderNote(TopicOrderLotSizeReplaced, db.WarningLevel, corder, tracker.token(), host,
+			side{sell: lo.Sell}, float64(qty)/conversionFactor, unbip(base.ID), float64(rate)/conversionFactor))
+	}
+}
+
+// replacementOrder is the quantity and rate of the replacement of the limit
+// order, with the remaining quantity rounded down to the lot size, and the
+// rate rounded to the rate step in the user's favor, down for a buy and up for
+// a sell.
+func replacementOrder(lo *order.LimitOrder, lotSize, rateStep uint64) (qty, rate uint64) {
+	remaining := lo.Remaining()
+	qty = remaining - remaining%lotSize
+	rate = lo.Rate
+	if rateStep == 0 {
+		return qty, rate
+	}
+	if over := rate % rateStep; over != 0 {
+		rate -= over
+		if lo.Sell {
+			rate += rateStep
+		}
+	}
+	return qty, rate
+}
+
+// handleTradeResumptionMsg is called when a trade resumption notification is
+// received. The market is resumed, and the config is requested, since the
+// configuration of the market or its assets may have changed while it was
+// suspended.
+func handleTradeResumptionMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error {
+	var rs msgjson.TradeResumption
+	err := msg.Unmarshal(&rs)
+	if err != nil {
+		return fmt.Errorf("trade resumption unmarshal error: %v", err)
+	}
+	if err = dc.resume(rs.MarketID); err != nil {
+		return err
+	}
+	dc.refreshMarkets()
+	go func() {
+		if err := c.refreshConfig(dc); err != nil {
+			log.Errorf("error refreshing the config of %s after market %s resumed: %v",
+				dc.acct.host, rs.MarketID, err)
+		}
+	}()
+	return nil
+}
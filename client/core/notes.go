@@ -0,0 +1,148 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"decred.org/dcrdex/dex/order"
+)
+
+const (
+	// maxNotesLen is the maximum length of the notes of an order or match.
+	maxNotesLen = 1000
+	// maxTags is the maximum number of tags of an order or match.
+	maxTags = 20
+	// maxTagLen is the maximum length of a tag.
+	maxTagLen = 32
+)
+
+// checkNotes checks the lengths of the notes and tags of an order or match.
+// Tags are trimmed, and empty and duplicate tags are dropped.
+func checkNotes(notes string, tags []string) ([]string, error) {
+	if len(notes) > maxNotesLen {
+		return nil, fmt.Errorf("notes longer than %d characters", maxNotesLen)
+	}
+	cleaned := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLen {
+			return nil, fmt.Errorf("tag %q longer than %d characters", tag, maxTagLen)
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	if len(cleaned) > maxTags {
+		return nil, fmt.Errorf("more than %d tags", maxTags)
+	}
+	return cleaned, nil
+}
+
+// SetOrderNotes sets the user's notes and tags for the order with the
+// hex-encoded order ID, replacing any previous notes and tags. Tags are
+// trimmed, and empty and duplicate tags are dropped.
+func (c *Core) SetOrderNotes(orderID, notes string, tags []string) error {
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return err
+	}
+	tags, err = checkNotes(notes, tags)
+	if err != nil {
+		return err
+	}
+	if err = c.db.SetOrderNotes(oid, notes, tags); err != nil {
+		return fmt.Errorf("error storing notes for order %s: %v", oid, err)
+	}
+	if _, tracker, _ := c.findDEXOrder(oid); tracker != nil {
+		tracker.mtx.Lock()
+		tracker.metaData.Notes, tracker.metaData.Tags = notes, tags
+		tracker.mtx.Unlock()
+	}
+	return nil
+}
+
+// SetMatchNotes sets the user's notes and tags for the match with the
+// hex-encoded match ID of the order with the hex-encoded order ID, replacing
+// any previous notes and tags. Tags are trimmed, and empty and duplicate tags
+// are dropped.
+func (c *Core) SetMatchNotes(orderID, matchID, notes string, tags []string) error {
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return err
+	}
+	midB, err := hex.DecodeString(matchID)
+	if err != nil || len(midB) != order.MatchIDSize {
+		return fmt.Errorf("invalid match ID %q", matchID)
+	}
+	var mid order.MatchID
+	copy(mid[:], midB)
+	tags, err = checkNotes(notes, tags)
+	if err != nil {
+		return err
+	}
+	if err = c.db.SetMatchNotes(oid, mid, notes, tags); err != nil {
+		return fmt.Errorf("error storing notes for match %s: %v", mid, err)
+	}
+	if _, tracker, _ := c.findDEXOrder(oid); tracker != nil {
+		tracker.matchMtx.Lock()
+		if match, found := tracker.matches[mid]; found {
+			match.MetaData.Notes, match.MetaData.Tags = notes, tags
+		}
+		tracker.matchMtx.Unlock()
+	}
+	return nil
+}
+
+// selectsNotes is true if the filter's Tags and Search select the notes and
+// tags.
+func (f *OrderFilter) selectsNotes(notes string, tags []string) bool {
+	if len(f.Tags) > 0 && !hasAnyTag(tags, f.Tags) {
+		return false
+	}
+	if f.Search == "" {
+		return true
+	}
+	search := strings.ToLower(f.Search)
+	if strings.Contains(strings.ToLower(notes), search) {
+		return true
+	}
+	for _, tag := range tags {
+		if strings.Contains(strings.ToLower(tag), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTags combines the tags of an order and its match without duplicates.
+func mergeTags(orderTags, matchTags []string) []string {
+	if len(matchTags) == 0 {
+		return orderTags
+	}
+	tags := append([]string(nil), orderTags...)
+	for _, tag := range matchTags {
+		if !hasAnyTag(tags, []string{tag}) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// hasAnyTag is true if any of the wanted tags is in tags.
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -4,17 +4,94 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/msgjson"
 )
 
-// notify sends a notification to all subscribers. If the notification is of
-// sufficient severity, it is stored in the database.
+const (
+	// noteRoutesKey is the app-level db key for the user's notification
+	// routes.
+	noteRoutesKey = "noteRoutes"
+
+	NoteTypeFeePayment   = "feepayment"
+	NoteTypeWithdraw     = "withdraw"
+	NoteTypeOrder        = "order"
+	NoteTypePaper        = "paper"
+	NoteTypeEpoch        = "epoch"
+	NoteTypeConnEvent    = "conn"
+	NoteTypeBalance      = "balance"
+	NoteTypeFiatRates    = "fiatrates"
+	NoteTypeAlert        = "alert"
+	NoteTypeDevicePrompt = "deviceprompt"
+	NoteTypeRecovery     = "recovery"
+	NoteTypeCoins        = "coins"
+	NoteTypeDeposit      = "deposit"
+	NoteTypePenalty      = "penalty"
+	NoteTypeUpgrade      = "upgrade"
+	NoteTypeTrust        = "trust"
+)
+
+// noteTypes are the notification types that can be routed with
+// (*Core).SetNoteRoute.
+var noteTypes = map[string]bool{
+	NoteTypeFeePayment:   true,
+	NoteTypeWithdraw:     true,
+	NoteTypeOrder:        true,
+	NoteTypePaper:        true,
+	NoteTypeEpoch:        true,
+	NoteTypeConnEvent:    true,
+	NoteTypeBalance:      true,
+	NoteTypeFiatRates:    true,
+	NoteTypeAlert:        true,
+	NoteTypeDevicePrompt: true,
+	NoteTypeRecovery:     true,
+	NoteTypeCoins:        true,
+	NoteTypeDeposit:      true,
+	NoteTypePenalty:      true,
+	NoteTypeUpgrade:      true,
+	NoteTypeTrust:        true,
+}
+
+// NoteRoute is the user's routing for a notification type. If Severity is not
+// db.Ignorable, it overrides the severity of every notification of the type.
+// LogOnly notifications are logged and sent to the feed for state updates, but
+// are not stored or displayed. Desktop requests an OS notification from the
+// UI. If Webhook is set, notifications of severity Poke and above are posted
+// to it.
+type NoteRoute struct {
+	Severity db.Severity `json:"severity"`
+	LogOnly  bool        `json:"logonly"`
+	Desktop  bool        `json:"desktop"`
+	Webhook  string      `json:"webhook"`
+}
+
+// notify sends a notification to all subscribers, after applying the user's
+// routing for the notification type. If the notification is of sufficient
+// severity and not routed to the log only, it is stored in the database.
 func (c *Core) notify(n Notification) {
-	if n.Severity() >= db.Success {
-		c.db.SaveNotification(n.DBNote())
+	route := c.noteRoute(n.Type())
+	note := n.DBNote()
+	if note.Topic != "" && c.locale != nil && c.locale != originLocale {
+		note.SubjectText, note.DetailText = c.locale.format(Topic(note.Topic), note.Args...)
+		note.Id = note.ID()
+	}
+	if route.Severity != db.Ignorable {
+		note.Severeness = route.Severity
+	}
+	note.LogOnly = route.LogOnly
+	note.Desktop = route.Desktop
+
+	if n.Severity() >= db.Success && !route.LogOnly {
+		c.db.SaveNotification(note)
+	}
+	if route.Webhook != "" && n.Severity() >= db.Poke {
+		go postWebhook(route.Webhook, n)
 	}
 
 	logFun := log.Warnf // default in case the Severity level is unknown to notify
@@ -54,6 +131,124 @@ func (c *Core) NotificationFeed() <-chan Notification {
 	return ch
 }
 
+// Notifications returns the n most recent stored notifications.
+func (c *Core) Notifications(n int) ([]*db.Notification, error) {
+	return c.db.NotificationsN(n)
+}
+
+// NoteRoutes returns the user's notification routes, keyed by notification
+// type. Types without a route are handled with their default severity.
+func (c *Core) NoteRoutes() map[string]*NoteRoute {
+	c.routeMtx.RLock()
+	defer c.routeMtx.RUnlock()
+	routes := make(map[string]*NoteRoute, len(c.noteRoutes))
+	for noteType, route := range c.noteRoutes {
+		r := *route
+		routes[noteType] = &r
+	}
+	return routes
+}
+
+// SetNoteRoute sets the routing for a notification type and saves the routes
+// to the database. A nil route restores the default handling.
+func (c *Core) SetNoteRoute(noteType string, route *NoteRoute) error {
+	if !noteTypes[noteType] {
+		return fmt.Errorf("unknown notification type %q", noteType)
+	}
+	if route != nil {
+		if route.Severity > db.ErrorLevel {
+			return fmt.Errorf("invalid severity %d", route.Severity)
+		}
+		if route.Webhook != "" {
+			if err := checkWebhook(route.Webhook); err != nil {
+				return err
+			}
+		}
+		r := *route
+		route = &r
+	}
+
+	c.routeMtx.Lock()
+	defer c.routeMtx.Unlock()
+	routes := make(map[string]*NoteRoute, len(c.noteRoutes)+1)
+	for k, v := range c.noteRoutes {
+		routes[k] = v
+	}
+	if route == nil {
+		delete(routes, noteType)
+	} else {
+		routes[noteType] = route
+	}
+	b, err := json.Marshal(routes)
+	if err != nil {
+		return fmt.Errorf("error encoding notification routes: %v", err)
+	}
+	if err = c.db.Store(noteRoutesKey, b); err != nil {
+		return fmt.Errorf("error saving notification routes: %v", err)
+	}
+	c.noteRoutes = routes
+	return nil
+}
+
+// noteRoute is the route for the notification type. The zero value is the
+// default routing.
+func (c *Core) noteRoute(noteType string) NoteRoute {
+	c.routeMtx.RLock()
+	defer c.routeMtx.RUnlock()
+	if route, found := c.noteRoutes[noteType]; found {
+		return *route
+	}
+	return NoteRoute{}
+}
+
+// loadNoteRoutes loads the user's notification routes from the database.
+func (c *Core) loadNoteRoutes() {
+	exists, err := c.db.ValueExists(noteRoutesKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(noteRoutesKey)
+	if err != nil {
+		log.Errorf("error loading notification routes: %v", err)
+		return
+	}
+	routes := make(map[string]*NoteRoute)
+	if err = json.Unmarshal(b, &routes); err != nil {
+		log.Errorf("error decoding notification routes: %v", err)
+		return
+	}
+	c.routeMtx.Lock()
+	c.noteRoutes = routes
+	c.routeMtx.Unlock()
+}
+
+// checkWebhook checks that the webhook is an http or https URL.
+func checkWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", webhook)
+	}
+	return nil
+}
+
+// postWebhook posts the JSON-encoded notification to the webhook.
+func postWebhook(webhook string, n Notification) {
+	b, err := json.Marshal(n)
+	if err != nil {
+		log.Errorf("error encoding %s notification for webhook: %v", n.Type(), err)
+		return
+	}
+	resp, err := webhookClient.Post(webhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Errorf("error sending %s notification to webhook %s: %v", n.Type(), webhook, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Errorf("webhook %s responded to %s notification with status %s", webhook, n.Type(), resp.Status)
+	}
+}
+
 // AckNotes sets the acknowledgement field for the notifications.
 func (c *Core) AckNotes(ids []dex.Bytes) {
 	for _, id := range ids {
@@ -104,15 +299,24 @@ type FeePaymentNote struct {
 	Dex           string  `json:"dex,omitempty"`
 }
 
-func newFeePaymentNote(subject, details string, severity db.Severity, dexAddr string) *FeePaymentNote {
+// newTopicNote constructs a db.Notification of the topic, rendered in the
+// origin locale. Core.notify renders it again in the Core's locale.
+func newTopicNote(noteType string, topic Topic, severity db.Severity, args []interface{}) db.Notification {
+	subject, details := originLocale.format(topic, args...)
+	note := db.NewNotification(noteType, subject, details, severity)
+	note.Topic, note.Args = string(topic), args
+	return note
+}
+
+func newFeePaymentNote(topic Topic, severity db.Severity, dexAddr string, args ...interface{}) *FeePaymentNote {
 	return &FeePaymentNote{
-		Notification: db.NewNotification("feepayment", subject, details, severity),
+		Notification: newTopicNote(NoteTypeFeePayment, topic, severity, args),
 		Dex:          addrHost(dexAddr),
 	}
 }
 
-func newFeePaymentNoteWithConfirmations(subject, details string, severity db.Severity, currConfs uint32, dexAddr string) *FeePaymentNote {
-	feePmtNt := newFeePaymentNote(subject, details, severity, dexAddr)
+func newFeePaymentNoteWithConfirmations(topic Topic, severity db.Severity, currConfs uint32, dexAddr string, args ...interface{}) *FeePaymentNote {
+	feePmtNt := newFeePaymentNote(topic, severity, dexAddr, args...)
 	feePmtNt.Confirmations = &currConfs
 	return feePmtNt
 }
@@ -122,9 +326,9 @@ type WithdrawNote struct {
 	db.Notification
 }
 
-func newWithdrawNote(subject, details string, severity db.Severity) *WithdrawNote {
+func newWithdrawNote(topic Topic, severity db.Severity, args ...interface{}) *WithdrawNote {
 	return &WithdrawNote{
-		Notification: db.NewNotification("withdraw", subject, details, severity),
+		Notification: newTopicNote(NoteTypeWithdraw, topic, severity, args),
 	}
 }
 
@@ -134,9 +338,23 @@ type OrderNote struct {
 	Order *Order `json:"order"`
 }
 
-func newOrderNote(subject, details string, severity db.Severity, corder *Order) *OrderNote {
+func newOrderNote(topic Topic, severity db.Severity, corder *Order, args ...interface{}) *OrderNote {
 	return &OrderNote{
-		Notification: db.NewNotification("order", subject, details, severity),
+		Notification: newTopicNote(NoteTypeOrder, topic, severity, args),
+		Order:        corder,
+	}
+}
+
+// PaperNote is a notification regarding a simulated order in a paper trading
+// session.
+type PaperNote struct {
+	db.Notification
+	Order *Order `json:"order"`
+}
+
+func newPaperNote(topic Topic, severity db.Severity, corder *Order, args ...interface{}) *PaperNote {
+	return &PaperNote{
+		Notification: newTopicNote(NoteTypePaper, topic, severity, args),
 		Order:        corder,
 	}
 }
@@ -163,7 +381,7 @@ func newEpochNotification(host, mktID string, epochIdx uint64) *EpochNotificatio
 	return &EpochNotification{
 		Host:         host,
 		MarketID:     mktID,
-		Notification: db.NewNotification("epoch", "", "", db.Data),
+		Notification: db.NewNotification(NoteTypeEpoch, "", "", db.Data),
 		Epoch:        epochIdx,
 	}
 }
@@ -180,9 +398,9 @@ type ConnEventNote struct {
 	Connected bool   `json:"connected"`
 }
 
-func newConnEventNote(subject, host string, connected bool, details string, severity db.Severity) *ConnEventNote {
+func newConnEventNote(topic Topic, host string, connected bool, severity db.Severity, args ...interface{}) *ConnEventNote {
 	return &ConnEventNote{
-		Notification: db.NewNotification("conn", subject, details, severity),
+		Notification: newTopicNote(NoteTypeConnEvent, topic, severity, args),
 		Host:         host,
 		Connected:    connected,
 	}
@@ -192,13 +410,101 @@ func newConnEventNote(subject, host string, connected bool, details string, seve
 type BalanceNote struct {
 	db.Notification
 	AssetID uint32      `json:"assetID"`
+	Wallet  string      `json:"wallet"`
 	Balance *db.Balance `json:"balance"`
 }
 
-func newBalanceNote(assetID uint32, bal *db.Balance) *BalanceNote {
+func newBalanceNote(assetID uint32, wallet string, bal *db.Balance) *BalanceNote {
 	return &BalanceNote{
-		Notification: db.NewNotification("balance", "balance updated", "", db.Data),
+		Notification: db.NewNotification(NoteTypeBalance, "balance updated", "", db.Data),
 		AssetID:      assetID,
+		Wallet:       wallet,
 		Balance:      bal,
 	}
 }
+
+// CoinNote is a notification regarding coin management for a wallet.
+type CoinNote struct {
+	db.Notification
+	AssetID uint32 `json:"assetID"`
+}
+
+func newCoinNote(topic Topic, severity db.Severity, assetID uint32, args ...interface{}) *CoinNote {
+	return &CoinNote{
+		Notification: newTopicNote(NoteTypeCoins, topic, severity, args),
+		AssetID:      assetID,
+	}
+}
+
+// DepositNote is a notification regarding a deposit to one of the user's
+// deposit addresses.
+type DepositNote struct {
+	db.Notification
+	Address *DepositAddress `json:"address"`
+}
+
+func newDepositNote(topic Topic, severity db.Severity, addr *DepositAddress, args ...interface{}) *DepositNote {
+	return &DepositNote{
+		Notification: newTopicNote(NoteTypeDeposit, topic, severity, args),
+		Address:      addr,
+	}
+}
+
+// PenaltyNote is a notification that the user's account at a DEX was closed for
+// a rule violation, or reopened.
+type PenaltyNote struct {
+	db.Notification
+	Penalty *Penalty `json:"penalty"`
+}
+
+func newPenaltyNote(topic Topic, severity db.Severity, penalty *Penalty, args ...interface{}) *PenaltyNote {
+	return &PenaltyNote{
+		Notification: newTopicNote(NoteTypePenalty, topic, severity, args),
+		Penalty:      penalty,
+	}
+}
+
+// UpgradeNote is a warning that a DEX requires a newer API version than the
+// client speaks.
+type UpgradeNote struct {
+	db.Notification
+	Host    string           `json:"host"`
+	Upgrade *msgjson.Upgrade `json:"upgrade"`
+}
+
+func newUpgradeNote(topic Topic, severity db.Severity, host string, upgrade *msgjson.Upgrade, args ...interface{}) *UpgradeNote {
+	return &UpgradeNote{
+		Notification: newTopicNote(NoteTypeUpgrade, topic, severity, args),
+		Host:         host,
+		Upgrade:      upgrade,
+	}
+}
+
+// TrustNote is a warning that the TLS certificate or signing key pinned for a
+// DEX has changed.
+type TrustNote struct {
+	db.Notification
+	Host string `json:"host"`
+}
+
+func newTrustNote(topic Topic, host string, args ...interface{}) *TrustNote {
+	return &TrustNote{
+		Notification: newTopicNote(NoteTypeTrust, topic, db.WarningLevel, args),
+		Host:         host,
+	}
+}
+
+// FiatRatesNote is an update to the fiat exchange rates.
+type FiatRatesNote struct {
+	db.Notification
+	Currency  string             `json:"currency"`
+	FiatRates map[uint32]float64 `json:"fiatRates"`
+}
+
+func newFiatRatesNote(currency string, rates map[uint32]float64) *FiatRatesNote {
+	return &FiatRatesNote{
+		Notification: db.NewNotification(NoteTypeFiatRates, "fiat rates updated", "", db.Data),
+		Currency:     currency,
+		FiatRates:    rates,
+	}
+}
@@ -0,0 +1,324 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// OrderFilter selects orders by DEX host, market, status, and time. The zero
+// value selects every order.
+type OrderFilter struct {
+	Host string `json:"host"`
+	// Market is the market ID, e.g. dcr_btc.
+	Market   string              `json:"market"`
+	Statuses []order.OrderStatus `json:"statuses"`
+	// Since and Until bound the order time, or the match time when selecting
+	// matches, in milliseconds. Zero is unbounded.
+	Since uint64 `json:"since"`
+	Until uint64 `json:"until"`
+	// N limits the results to the newest N. Zero is unlimited.
+	N int `json:"n"`
+	// Tags selects orders with any of the tags. Search selects orders with
+	// notes or tags that contain the text, ignoring case. When selecting
+	// matches, the notes and tags of both the match and its order are
+	// searched.
+	Tags   []string `json:"tags"`
+	Search string   `json:"search"`
+}
+
+// hasStatus is true if the filter selects orders with the status.
+func (f *OrderFilter) hasStatus(status order.OrderStatus) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, s := range f.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange is true if the time stamp is within the filter's time range.
+func (f *OrderFilter) inRange(stamp uint64) bool {
+	return stamp >= f.Since && (f.Until == 0 || stamp <= f.Until)
+}
+
+// Orders lists the user's trade orders from the database that match the
+// filter, newest first. Active orders reflect the current state of the trade.
+// Cancel orders are not included.
+func (c *Core) Orders(filter *OrderFilter) ([]*Order, error) {
+	accts, err := c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving accounts: %v", err)
+	}
+	orders := make([]*Order, 0)
+	for _, acct := range accts {
+		if filter.Host != "" && acct.Host != filter.Host {
+			continue
+		}
+		ords, err := c.db.AccountOrders(acct.Host, 0, filter.Since)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving orders for %s: %v", acct.Host, err)
+		}
+		for _, mOrd := range ords {
+			ord := mOrd.Order
+			if ord.Type() == order.CancelOrderType {
+				continue
+			}
+			if filter.Market != "" && marketName(ord.Base(), ord.Quote()) != filter.Market {
+				continue
+			}
+			if !filter.hasStatus(mOrd.MetaData.Status) || !filter.inRange(uint64(ord.Time())) ||
+				!filter.selectsNotes(mOrd.MetaData.Notes, mOrd.MetaData.Tags) {
+				continue
+			}
+			corder, err := c.coreOrder(acct.Host, mOrd)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, corder)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		oi, oj := orders[i], orders[j]
+		if oi.Stamp != oj.Stamp {
+			return oi.Stamp > oj.Stamp
+		}
+		return oi.ID < oj.ID
+	})
+	if filter.N > 0 && len(orders) > filter.N {
+		orders = orders[:filter.N]
+	}
+	return orders, nil
+}
+
+// Order retrieves the order with the hex-encoded order ID.
+func (c *Core) Order(orderID string) (*Order, error) {
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return nil, err
+	}
+	mOrd, err := c.db.Order(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving order %s: %v", oid, err)
+	}
+	return c.coreOrder(mOrd.MetaData.Host, mOrd)
+}
+
+// Matches lists the matches of the user's orders that match the filter, newest
+// first. The filter's time range applies to the match time rather than the
+// order time.
+func (c *Core) Matches(filter *OrderFilter) ([]*OrderMatch, error) {
+	orderFilter := *filter
+	orderFilter.Since, orderFilter.Until, orderFilter.N = 0, 0, 0
+	orderFilter.Tags, orderFilter.Search = nil, ""
+	orders, err := c.Orders(&orderFilter)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]*OrderMatch, 0)
+	for _, corder := range orders {
+		for _, match := range corder.Matches {
+			if !filter.inRange(match.Stamp) {
+				continue
+			}
+			if !filter.selectsNotes(corder.Notes+"\n"+match.Notes, mergeTags(corder.Tags, match.Tags)) {
+				continue
+			}
+			matches = append(matches, &OrderMatch{
+				Host:    corder.Host,
+				OrderID: corder.ID,
+				Sell:    corder.Sell,
+				Match:   match,
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Stamp > matches[j].Stamp
+	})
+	if filter.N > 0 && len(matches) > filter.N {
+		matches = matches[:filter.N]
+	}
+	return matches, nil
+}
+
+// CancelAll cancels the user's booked limit orders that match the filter's
+// host and market. The IDs of the canceled orders are returned, along with an
+// error if any cancellation failed.
+func (c *Core) CancelAll(pw []byte, filter *OrderFilter) ([]string, error) {
+	if _, err := c.encryptionKey(pw); err != nil {
+		return nil, fmt.Errorf("CancelAll password error: %v", err)
+	}
+	var ids []string
+	c.connMtx.RLock()
+	for host, dc := range c.conns {
+		if filter.Host != "" && host != filter.Host {
+			continue
+		}
+		dc.tradeMtx.RLock()
+		for oid, tracker := range dc.trades {
+			if tracker.Type() != order.LimitOrderType || (filter.Market != "" && tracker.mktID != filter.Market) {
+				continue
+			}
+			tracker.mtx.RLock()
+			cancellable := tracker.metaData.Status == order.OrderStatusBooked && tracker.cancel == nil
+			tracker.mtx.RUnlock()
+			if cancellable {
+				ids = append(ids, oid.String())
+			}
+		}
+		dc.tradeMtx.RUnlock()
+	}
+	c.connMtx.RUnlock()
+
+	sort.Strings(ids)
+	errs := newErrorSet("CancelAll: ")
+	canceled := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err := c.Cancel(pw, id); err != nil {
+			errs.add("error canceling order %s: %v", id, err)
+			continue
+		}
+		canceled = append(canceled, id)
+	}
+	return canceled, errs.ifany()
+}
+
+// coreOrder converts the database order to an *Order. The current state of an
+// active order is taken from its trackedTrade. The fiat value of the order is
+// set if the rates are known.
+func (c *Core) coreOrder(host string, mOrd *db.MetaOrder) (*Order, error) {
+	ord := mOrd.Order
+	oid := ord.ID()
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if found {
+		dc.tradeMtx.RLock()
+		tracker, active := dc.trades[oid]
+		dc.tradeMtx.RUnlock()
+		if active {
+			corder, _ := tracker.coreOrder()
+			c.setOrderFiatValue(corder, ord.Base(), ord.Quote())
+			return corder, nil
+		}
+	}
+
+	trade := ord.Trade()
+	mktID := marketName(ord.Base(), ord.Quote())
+	corder := &Order{
+		Host:     host,
+		MarketID: mktID,
+		Type:     ord.Type(),
+		ID:       oid.String(),
+		Stamp:    uint64(ord.Time()),
+		Sig:      mOrd.MetaData.Proof.DEXSig,
+		Status:   mOrd.MetaData.Status,
+		Qty:      trade.Quantity,
+		Sell:     trade.Sell,
+		Filled:   trade.Filled(),
+		Canceled: mOrd.MetaData.Status == order.OrderStatusCanceled,
+		Notes:    mOrd.MetaData.Notes,
+		Tags:     mOrd.MetaData.Tags,
+	}
+	if found {
+		corder.Epoch = dc.marketEpoch(mktID, encode.UnixTimeMilli(ord.Time()))
+	}
+	if lo, ok := ord.(*order.LimitOrder); ok {
+		corder.Rate = lo.Rate
+		corder.TimeInForce = lo.Force
+	}
+	matches, err := c.db.MatchesForOrder(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving matches for order %s: %v", oid, err)
+	}
+	for _, mMatch := range matches {
+		match := mMatch.Match
+		corder.Matches = append(corder.Matches, &Match{
+			MatchID: match.MatchID.String(),
+			Status:  match.Status,
+			Rate:    match.Rate,
+			Qty:     match.Quantity,
+			Side:    match.Side,
+			Stamp:   mMatch.MetaData.Proof.Auth.MatchStamp,
+			Notes:   mMatch.MetaData.Notes,
+			Tags:    mMatch.MetaData.Tags,
+		})
+	}
+	c.setOrderFiatValue(corder, ord.Base(), ord.Quote())
+	return corder, nil
+}
+
+// CloneOrder places a new order with the same parameters as the completed,
+// canceled, or revoked order with the hex-encoded order ID. A non-zero rate
+// replaces the rate of a limit order. The same wallets are used to fund the
+// new order.
+func (c *Core) CloneOrder(pw []byte, orderID string, rate uint64) (*Order, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("CloneOrder password error: %v", err)
+	}
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return nil, err
+	}
+	mOrd, err := c.db.Order(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving order %s: %v", oid, err)
+	}
+	form, err := cloneTradeForm(mOrd, rate)
+	if err != nil {
+		return nil, err
+	}
+	if _, tracker, _ := c.findDEXOrder(oid); tracker != nil {
+		return nil, fmt.Errorf("order %s is still active", oid)
+	}
+	return c.trade(crypter, form)
+}
+
+// cloneTradeForm creates a TradeForm that re-places the order. A non-zero rate
+// replaces the rate of a limit order.
+func cloneTradeForm(mOrd *db.MetaOrder, rate uint64) (*TradeForm, error) {
+	ord := mOrd.Order
+	switch mOrd.MetaData.Status {
+	case order.OrderStatusEpoch, order.OrderStatusBooked:
+		return nil, fmt.Errorf("order %s is still active", ord.ID())
+	}
+	form := &TradeForm{
+		Host:        mOrd.MetaData.Host,
+		Base:        ord.Base(),
+		Quote:       ord.Quote(),
+		BaseWallet:  mOrd.MetaData.BaseWallet,
+		QuoteWallet: mOrd.MetaData.QuoteWallet,
+		Notes:       mOrd.MetaData.Notes,
+		Tags:        mOrd.MetaData.Tags,
+	}
+	switch o := ord.(type) {
+	case *order.LimitOrder:
+		form.IsLimit = true
+		form.Sell = o.Sell
+		form.Qty = o.Quantity
+		form.Rate = o.Rate
+		form.TifNow = o.Force == order.ImmediateTiF
+		if rate > 0 {
+			form.Rate = rate
+		}
+	case *order.MarketOrder:
+		if rate > 0 {
+			return nil, fmt.Errorf("cannot set a rate for market order %s", ord.ID())
+		}
+		form.Sell = o.Sell
+		form.Qty = o.Quantity
+	default:
+		return nil, fmt.Errorf("cannot clone order %s of type %s", ord.ID(), ord.Type())
+	}
+	return form, nil
+}
@@ -0,0 +1,554 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	orderbook "decred.org/dcrdex/client/order"
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// paperInterval is how often the paper orders are matched against the order
+// books.
+var paperInterval = time.Second
+
+// PaperForm is the information necessary to start a paper trading session.
+// The Cert is only needed if the client is not registered with the DEX at
+// Host. Balances are the simulated starting balances, in atoms, keyed by asset
+// ID.
+type PaperForm struct {
+	Host     string            `json:"host"`
+	Cert     string            `json:"cert"`
+	Balances map[uint32]uint64 `json:"balances"`
+}
+
+// PaperBalance is a simulated balance in a paper trading session.
+type PaperBalance struct {
+	Available uint64 `json:"available"`
+	Locked    uint64 `json:"locked"`
+}
+
+// PaperAccount is the state of a paper trading session.
+type PaperAccount struct {
+	Host     string                   `json:"host"`
+	Balances map[uint32]*PaperBalance `json:"balances"`
+	Orders   []*Order                 `json:"orders"`
+}
+
+// paperOrder is a simulated order. locked is the remaining amount of the
+// funding asset that is locked by the order. For a market buy, Qty and Filled
+// are in units of the quote asset.
+type paperOrder struct {
+	*Order
+	base, quote uint32
+	fundAsset   uint32
+	locked      uint64
+	cancelEpoch uint64
+}
+
+// remaining is the unfilled quantity of the order.
+func (po *paperOrder) remaining() uint64 {
+	return po.Qty - po.Filled
+}
+
+// isMarketBuy is true if the order is a market buy order.
+func (po *paperOrder) isMarketBuy() bool {
+	return po.Type == order.MarketOrderType && !po.Sell
+}
+
+// order is a copy of the *Order that is safe to return to the caller.
+func (po *paperOrder) order() *Order {
+	corder := *po.Order
+	corder.Matches = append([]*Match(nil), po.Matches...)
+	return &corder
+}
+
+// paperSession is a paper trading session on a single DEX. The dexConnection
+// is either that of a registered account, or a watch-only connection that is
+// owned by the session. consumed is the quantity of each booked order, by
+// token, that has been taken by paper orders, so that an order on the real
+// book is not filled more than once.
+type paperSession struct {
+	dc       *dexConnection
+	ownConn  bool
+	balances map[uint32]*PaperBalance
+	orders   map[order.OrderID]*paperOrder
+	feeds    map[string]*BookFeed
+	consumed map[string]uint64
+}
+
+// balance gets the balance for the asset, creating it if necessary.
+func (ps *paperSession) balance(assetID uint32) *PaperBalance {
+	bal, found := ps.balances[assetID]
+	if !found {
+		bal = new(PaperBalance)
+		ps.balances[assetID] = bal
+	}
+	return bal
+}
+
+// StartPaperTrading starts a paper trading session, in which orders are
+// simulated against the DEX's live order books without touching the wallets
+// or placing orders with the server. Only one session can run at a time. The
+// client does not need to be registered with the DEX.
+func (c *Core) StartPaperTrading(form *PaperForm) error {
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	if c.paper != nil {
+		return fmt.Errorf("paper trading session already running on %s", c.paper.dc.acct.host)
+	}
+
+	host := addrHost(form.Host)
+	c.connMtx.RLock()
+	dc, registered := c.conns[host]
+	c.connMtx.RUnlock()
+	if !registered {
+		var err error
+		dc, err = c.connectDEX(&db.AccountInfo{
+			Host: host,
+			Cert: []byte(form.Cert),
+		})
+		if err != nil {
+			return codedError(connectionErr, err)
+		}
+	}
+
+	balances := make(map[uint32]*PaperBalance, len(form.Balances))
+	for assetID, bal := range form.Balances {
+		if dc.asset(assetID) == nil {
+			if !registered {
+				dc.connMaster.Disconnect()
+			}
+			return fmt.Errorf("asset %s is not supported by %s", unbip(assetID), host)
+		}
+		balances[assetID] = &PaperBalance{Available: bal}
+	}
+
+	c.paper = &paperSession{
+		dc:       dc,
+		ownConn:  !registered,
+		balances: balances,
+		orders:   make(map[order.OrderID]*paperOrder),
+		feeds:    make(map[string]*BookFeed),
+		consumed: make(map[string]uint64),
+	}
+	log.Infof("Started paper trading session on %s", host)
+	return nil
+}
+
+// StopPaperTrading ends the paper trading session. The simulated orders and
+// balances are discarded.
+func (c *Core) StopPaperTrading() error {
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	if c.paper == nil {
+		return fmt.Errorf("no paper trading session")
+	}
+	c.stopPaper()
+	return nil
+}
+
+// stopPaper closes the session's book feeds and watch-only connection. The
+// paperMtx must be locked.
+func (c *Core) stopPaper() {
+	ps := c.paper
+	if ps == nil {
+		return
+	}
+	closeBookFeeds(ps.feeds, nil)
+	if ps.ownConn {
+		ps.dc.connMaster.Disconnect()
+	}
+	c.paper = nil
+	log.Infof("Stopped paper trading session on %s", ps.dc.acct.host)
+}
+
+// PaperTrade places a simulated order in the paper trading session. The order
+// is matched against the order book once its epoch closes, taking the booked
+// orders at their rates. Any remaining quantity of a standing limit order is
+// then booked, and is matched whenever the order book crosses its rate. Swap
+// fees are not simulated.
+func (c *Core) PaperTrade(form *TradeForm) (*Order, error) {
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	ps := c.paper
+	if ps == nil {
+		return nil, fmt.Errorf("no paper trading session")
+	}
+	dc := ps.dc
+	host := form.acctHost()
+	if host != dc.acct.host {
+		return nil, fmt.Errorf("paper trading session is on %s, not %s", dc.acct.host, host)
+	}
+	mktID := marketName(form.Base, form.Quote)
+	mkt := dc.market(mktID)
+	if mkt == nil {
+		return nil, fmt.Errorf("order placed for unknown market")
+	}
+	if mkt.Suspended() {
+		return nil, fmt.Errorf("suspended market")
+	}
+	if form.Qty == 0 {
+		return nil, fmt.Errorf("zero quantity not allowed")
+	}
+	if form.IsLimit && form.Rate == 0 {
+		return nil, fmt.Errorf("zero-rate order not allowed")
+	}
+	baseAsset, quoteAsset := dc.asset(form.Base), dc.asset(form.Quote)
+	if (form.IsLimit || form.Sell) && form.Qty%baseAsset.LotSize != 0 {
+		return nil, fmt.Errorf("quantity %d is not a multiple of the lot size %d", form.Qty, baseAsset.LotSize)
+	}
+	if form.IsLimit && form.Rate%quoteAsset.RateStep != 0 {
+		return nil, fmt.Errorf("rate %d is not a multiple of the rate step %d", form.Rate, quoteAsset.RateStep)
+	}
+
+	fundAsset, lock := form.Quote, form.Qty
+	if form.Sell {
+		fundAsset = form.Base
+	} else if form.IsLimit {
+		lock = calc.BaseToQuote(form.Rate, form.Qty)
+	}
+	bal := ps.balance(fundAsset)
+	if bal.Available < lock {
+		return nil, fmt.Errorf("insufficient paper %s balance. %d available, %d required",
+			unbip(fundAsset), bal.Available, lock)
+	}
+
+	// The order book must be synced to match the order.
+	if _, found := ps.feeds[mktID]; !found {
+		_, feed, err := c.syncBook(dc, form.Base, form.Quote)
+		if err != nil {
+			return nil, fmt.Errorf("error syncing %s order book: %v", mktID, err)
+		}
+		ps.feeds[mktID] = feed
+		go discardBookUpdates(c.ctx, feed)
+	}
+
+	bal.Available -= lock
+	bal.Locked += lock
+
+	var oid order.OrderID
+	copy(oid[:], encode.RandomBytes(order.OrderIDSize))
+	stamp := encode.UnixMilliU(time.Now())
+	corder := &Order{
+		Host:     host,
+		MarketID: mktID,
+		Type:     order.MarketOrderType,
+		ID:       oid.String(),
+		Stamp:    stamp,
+		Status:   order.OrderStatusEpoch,
+		Epoch:    stamp / mkt.EpochLen,
+		Qty:      form.Qty,
+		Sell:     form.Sell,
+	}
+	if form.IsLimit {
+		corder.Type = order.LimitOrderType
+		corder.Rate = form.Rate
+		corder.TimeInForce = order.StandingTiF
+		if form.TifNow {
+			corder.TimeInForce = order.ImmediateTiF
+		}
+	}
+	po := &paperOrder{
+		Order:     corder,
+		base:      form.Base,
+		quote:     form.Quote,
+		fundAsset: fundAsset,
+		locked:    lock,
+	}
+	ps.orders[oid] = po
+	return po.order(), nil
+}
+
+// PaperCancel cancels a simulated order. As with a real cancel order, the
+// cancellation takes effect when the current epoch closes.
+func (c *Core) PaperCancel(orderID string) error {
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return err
+	}
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	ps := c.paper
+	if ps == nil {
+		return fmt.Errorf("no paper trading session")
+	}
+	po, found := ps.orders[oid]
+	if !found {
+		return fmt.Errorf("unknown paper order %s", orderID)
+	}
+	if po.Type != order.LimitOrderType || po.TimeInForce != order.StandingTiF {
+		return fmt.Errorf("only standing limit orders can be canceled")
+	}
+	if po.Status != order.OrderStatusEpoch && po.Status != order.OrderStatusBooked {
+		return fmt.Errorf("paper order %s is not active", orderID)
+	}
+	if po.Cancelling {
+		return fmt.Errorf("paper order %s is already being canceled", orderID)
+	}
+	po.Cancelling = true
+	po.cancelEpoch = encode.UnixMilliU(time.Now()) / ps.dc.market(po.MarketID).EpochLen
+	return nil
+}
+
+// PaperAccount gets the state of the paper trading session.
+func (c *Core) PaperAccount() (*PaperAccount, error) {
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	ps := c.paper
+	if ps == nil {
+		return nil, fmt.Errorf("no paper trading session")
+	}
+	acct := &PaperAccount{
+		Host:     ps.dc.acct.host,
+		Balances: make(map[uint32]*PaperBalance, len(ps.balances)),
+		Orders:   make([]*Order, 0, len(ps.orders)),
+	}
+	for assetID, bal := range ps.balances {
+		acct.Balances[assetID] = &PaperBalance{
+			Available: bal.Available,
+			Locked:    bal.Locked,
+		}
+	}
+	for _, po := range ps.sortedOrders() {
+		acct.Orders = append(acct.Orders, po.order())
+	}
+	return acct, nil
+}
+
+// sortedOrders is the session's orders, sorted by time.
+func (ps *paperSession) sortedOrders() []*paperOrder {
+	ords := make([]*paperOrder, 0, len(ps.orders))
+	for _, po := range ps.orders {
+		ords = append(ords, po)
+	}
+	sort.Slice(ords, func(i, j int) bool {
+		if ords[i].Stamp == ords[j].Stamp {
+			return ords[i].ID < ords[j].ID
+		}
+		return ords[i].Stamp < ords[j].Stamp
+	})
+	return ords
+}
+
+// runPaper matches the paper orders every paperInterval until the context is
+// canceled.
+func (c *Core) runPaper(ctx context.Context) {
+	ticker := time.NewTicker(paperInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkPaperOrders(encode.UnixMilliU(time.Now()))
+		case <-ctx.Done():
+			c.paperMtx.Lock()
+			c.stopPaper()
+			c.paperMtx.Unlock()
+			return
+		}
+	}
+}
+
+// checkPaperOrders processes the paper orders whose epochs have closed, and
+// matches the booked paper orders, in order of time priority. now is the time
+// in milliseconds.
+func (c *Core) checkPaperOrders(now uint64) {
+	c.paperMtx.Lock()
+	defer c.paperMtx.Unlock()
+	ps := c.paper
+	if ps == nil {
+		return
+	}
+	for _, po := range ps.sortedOrders() {
+		if po.Status != order.OrderStatusEpoch && po.Status != order.OrderStatusBooked {
+			continue
+		}
+		mkt := ps.dc.market(po.MarketID)
+		if mkt == nil {
+			continue
+		}
+		epoch := now / mkt.EpochLen
+		if po.Status == order.OrderStatusEpoch && po.Epoch >= epoch {
+			// The order's epoch has not closed yet.
+			continue
+		}
+		if po.Cancelling && po.cancelEpoch < epoch {
+			c.finishPaperOrder(ps, po, order.OrderStatusCanceled)
+			continue
+		}
+		c.matchPaperOrder(ps, po, now)
+		if po.Status != order.OrderStatusEpoch {
+			continue
+		}
+		// The order has been through epoch processing.
+		switch {
+		case po.Type == order.MarketOrderType || po.remaining() == 0:
+			c.finishPaperOrder(ps, po, order.OrderStatusExecuted)
+		case po.TimeInForce == order.ImmediateTiF:
+			c.finishPaperOrder(ps, po, order.OrderStatusExecuted)
+		default:
+			po.Status = order.OrderStatusBooked
+		}
+	}
+	ps.pruneConsumed()
+}
+
+// matchPaperOrder fills the paper order against the opposite side of the
+// market's order book. An order in epoch processing is the taker, and takes the
+// booked orders at their rates. A booked order is the maker, and is filled at
+// its own rate by booked orders that cross it.
+func (c *Core) matchPaperOrder(ps *paperSession, po *paperOrder, now uint64) {
+	ps.dc.booksMtx.RLock()
+	book, found := ps.dc.books[po.MarketID]
+	ps.dc.booksMtx.RUnlock()
+	if !found {
+		log.Warnf("no synced order book for paper order %s", po.ID)
+		return
+	}
+	buys, sells, _ := book.Orders()
+	side := sells
+	if po.Sell {
+		side = buys
+	}
+	lotSize := ps.dc.asset(po.base).LotSize
+	matchSide := order.Taker
+	if po.Status == order.OrderStatusBooked {
+		matchSide = order.Maker
+	}
+
+	var filled uint64
+	for _, bo := range side {
+		if po.remaining() == 0 {
+			break
+		}
+		if po.Type == order.LimitOrderType {
+			if (po.Sell && bo.Rate < po.Rate) || (!po.Sell && bo.Rate > po.Rate) {
+				break
+			}
+		}
+		tkn := token(bo.OrderID[:])
+		if ps.consumed[tkn] >= bo.Quantity {
+			continue
+		}
+		rate := bo.Rate
+		if matchSide == order.Maker {
+			rate = po.Rate
+		}
+		qty := bo.Quantity - ps.consumed[tkn]
+		want := po.remaining()
+		if po.isMarketBuy() {
+			want = calc.QuoteToBase(rate, po.locked)
+		}
+		if want < qty {
+			qty = want
+		}
+		qty -= qty % lotSize
+		if qty == 0 {
+			break
+		}
+		ps.consumed[tkn] += qty
+		ps.settlePaperMatch(po, rate, qty)
+		var mid order.MatchID
+		copy(mid[:], encode.RandomBytes(order.MatchIDSize))
+		po.Matches = append(po.Matches, &Match{
+			MatchID: mid.String(),
+			Status:  order.MatchComplete,
+			Rate:    rate,
+			Qty:     qty,
+			Side:    matchSide,
+			Stamp:   now,
+		})
+		filled += qty
+	}
+	if filled > 0 {
+		c.notify(newPaperNote(TopicPaperOrderMatched, db.Poke, po.order(),
+			po.ID, po.MarketID, float64(filled)/conversionFactor, unbip(po.base)))
+	}
+}
+
+// settlePaperMatch updates the paper order and balances for a match of qty
+// units of the base asset at the rate.
+func (ps *paperSession) settlePaperMatch(po *paperOrder, rate, qty uint64) {
+	quoteQty := calc.BaseToQuote(rate, qty)
+	if po.Sell {
+		ps.spend(po, qty)
+		ps.balance(po.quote).Available += quoteQty
+		po.Filled += qty
+		return
+	}
+	if po.isMarketBuy() {
+		ps.spend(po, quoteQty)
+		po.Filled += quoteQty
+	} else {
+		// The difference between the locked amount at the order's rate and the
+		// amount at the match rate is returned to the available balance.
+		ps.spend(po, quoteQty)
+		ps.unlock(po, calc.BaseToQuote(po.Rate, qty)-quoteQty)
+		po.Filled += qty
+	}
+	ps.balance(po.base).Available += qty
+}
+
+// spend removes up to amt of the order's locked funds from the balance.
+func (ps *paperSession) spend(po *paperOrder, amt uint64) uint64 {
+	if amt > po.locked {
+		amt = po.locked
+	}
+	po.locked -= amt
+	ps.balance(po.fundAsset).Locked -= amt
+	return amt
+}
+
+// unlock moves up to amt of the order's locked funds back to the available
+// balance.
+func (ps *paperSession) unlock(po *paperOrder, amt uint64) {
+	ps.balance(po.fundAsset).Available += ps.spend(po, amt)
+}
+
+// finishPaperOrder sets the final status of the paper order, and returns any
+// remaining locked funds.
+func (c *Core) finishPaperOrder(ps *paperSession, po *paperOrder, status order.OrderStatus) {
+	ps.unlock(po, po.locked)
+	po.Status = status
+	po.Cancelling = false
+	if status == order.OrderStatusCanceled {
+		po.Canceled = true
+	}
+	c.notify(newPaperNote(TopicPaperOrderFinished, db.Poke, po.order(), po.ID, po.MarketID, status))
+}
+
+// pruneConsumed removes the consumed quantities of orders that are no longer
+// on the synced order books.
+func (ps *paperSession) pruneConsumed() {
+	if len(ps.consumed) == 0 {
+		return
+	}
+	booked := make(map[string]bool)
+	ps.dc.booksMtx.RLock()
+	for mkt := range ps.feeds {
+		book, found := ps.dc.books[mkt]
+		if !found {
+			continue
+		}
+		buys, sells, _ := book.Orders()
+		for _, ords := range [][]*orderbook.Order{buys, sells} {
+			for _, o := range ords {
+				booked[token(o.OrderID[:])] = true
+			}
+		}
+	}
+	ps.dc.booksMtx.RUnlock()
+	for tkn := range ps.consumed {
+		if !booked[tkn] {
+			delete(ps.consumed, tkn)
+		}
+	}
+}
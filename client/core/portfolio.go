@@ -0,0 +1,181 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"sort"
+
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+)
+
+// RateSource is a source of fiat exchange rates.
+type RateSource interface {
+	// Currency is the fiat currency code, e.g. USD.
+	Currency() string
+	// FiatRates returns the fiat value of one whole unit of each asset.
+	// Assets without a known rate are omitted from the returned map.
+	FiatRates(assetIDs []uint32) (map[uint32]float64, error)
+}
+
+// AssetPortfolio is the user's holdings of a single asset. Available, Immature,
+// and Locked are the wallet's balance, where Locked includes funds reserved for
+// open orders. OrderLocked is the value committed to open orders that has not
+// yet been swapped. SwapLocked is the value in swap contracts that have been
+// broadcast but not yet redeemed or refunded. Total is the wallet balance plus
+// SwapLocked.
+type AssetPortfolio struct {
+	AssetID     uint32  `json:"assetID"`
+	Symbol      string  `json:"symbol"`
+	Available   uint64  `json:"available"`
+	Immature    uint64  `json:"immature"`
+	Locked      uint64  `json:"locked"`
+	OrderLocked uint64  `json:"orderlocked"`
+	SwapLocked  uint64  `json:"swaplocked"`
+	Total       uint64  `json:"total"`
+	FiatRate    float64 `json:"fiatrate,omitempty"`
+	FiatValue   float64 `json:"fiatvalue,omitempty"`
+}
+
+// Portfolio is a summary of the user's holdings of every asset with a wallet
+// or active trades. The fiat fields are only set if a RateSource is
+// configured.
+type Portfolio struct {
+	Assets    []*AssetPortfolio `json:"assets"`
+	Currency  string            `json:"currency,omitempty"`
+	FiatValue float64           `json:"fiatvalue,omitempty"`
+}
+
+// Portfolio aggregates the wallet balances, the value committed to open orders,
+// and the value in active swaps for each asset. If a RateSource was configured,
+// the fiat value of each asset and the total are included. A failure to
+// retrieve fiat rates is logged, and the portfolio is returned without them.
+func (c *Core) Portfolio() *Portfolio {
+	assets := make(map[uint32]*AssetPortfolio)
+	assetPortfolio := func(assetID uint32) *AssetPortfolio {
+		ap, found := assets[assetID]
+		if !found {
+			ap = &AssetPortfolio{
+				AssetID: assetID,
+				Symbol:  unbip(assetID),
+			}
+			assets[assetID] = ap
+		}
+		return ap
+	}
+
+	c.walletMtx.RLock()
+	// The balances of an asset's wallets are combined.
+	for _, wallet := range c.wallets {
+		ap := assetPortfolio(wallet.AssetID)
+		if bal := wallet.cachedBalance(); bal != nil {
+			ap.Available += bal.Available
+			ap.Immature += bal.Immature
+			ap.Locked += bal.Locked
+		}
+	}
+	c.walletMtx.RUnlock()
+
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		dc.tradeMtx.RLock()
+		for _, tracker := range dc.trades {
+			fromID, orderLocked, swapLocked := tracker.lockedFunds()
+			if orderLocked == 0 && swapLocked == 0 {
+				continue
+			}
+			ap := assetPortfolio(fromID)
+			ap.OrderLocked += orderLocked
+			ap.SwapLocked += swapLocked
+		}
+		dc.tradeMtx.RUnlock()
+	}
+	c.connMtx.RUnlock()
+
+	portfolio := &Portfolio{
+		Assets: make([]*AssetPortfolio, 0, len(assets)),
+	}
+	assetIDs := make([]uint32, 0, len(assets))
+	for assetID, ap := range assets {
+		ap.Total = ap.Available + ap.Immature + ap.Locked + ap.SwapLocked
+		portfolio.Assets = append(portfolio.Assets, ap)
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Slice(portfolio.Assets, func(i, j int) bool {
+		return portfolio.Assets[i].AssetID < portfolio.Assets[j].AssetID
+	})
+
+	if c.rateSource == nil || len(assetIDs) == 0 {
+		return portfolio
+	}
+	rates, err := c.rateSource.FiatRates(assetIDs)
+	if err != nil {
+		log.Errorf("error retrieving fiat rates: %v", err)
+		return portfolio
+	}
+	portfolio.Currency = c.rateSource.Currency()
+	for _, ap := range portfolio.Assets {
+		rate, found := rates[ap.AssetID]
+		if !found {
+			continue
+		}
+		ap.FiatRate = rate
+		ap.FiatValue = float64(ap.Total) / conversionFactor * rate
+		portfolio.FiatValue += ap.FiatValue
+	}
+	return portfolio
+}
+
+// lockedFunds returns the ID of the asset the trade is funded with, the value
+// committed to the order that has not been swapped, and the value in swap
+// contracts that the counterparty has not yet redeemed and that have not been
+// refunded.
+func (t *trackedTrade) lockedFunds() (fromID uint32, orderLocked, swapLocked uint64) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	t.matchMtx.RLock()
+	defer t.matchMtx.RUnlock()
+
+	trade := t.Trade()
+	fromID = t.Quote()
+	if trade.Sell {
+		fromID = t.Base()
+	}
+
+	fromValue := func(qty, rate uint64) uint64 {
+		if trade.Sell {
+			return qty
+		}
+		return calc.BaseToQuote(rate, qty)
+	}
+
+	// The unfilled portion of a standing order. The remaining quantity of a
+	// market buy order is already in units of the quote asset.
+	switch t.metaData.Status {
+	case order.OrderStatusEpoch, order.OrderStatusBooked:
+		if t.Type() == order.MarketOrderType && !trade.Sell {
+			orderLocked = trade.Remaining()
+		} else {
+			orderLocked = fromValue(trade.Remaining(), t.rate())
+		}
+	}
+
+	for _, match := range t.matches {
+		dbMatch, _, proof, _ := match.parts()
+		switch {
+		case proof.RefundCoin != nil, proof.IsRevoked && proof.Script == nil:
+			// Refunded, or revoked before we swapped.
+		case proof.Script == nil:
+			// Matched but not yet swapped. The funds are still reserved.
+			orderLocked += fromValue(dbMatch.Quantity, dbMatch.Rate)
+		case dbMatch.Status >= order.MatchComplete,
+			dbMatch.Side == order.Taker && dbMatch.Status >= order.MakerRedeemed:
+			// The counterparty has redeemed our contract. The taker's contract
+			// is redeemed by the maker, and the maker's by the taker.
+		default:
+			swapLocked += fromValue(dbMatch.Quantity, dbMatch.Rate)
+		}
+	}
+	return fromID, orderLocked, swapLocked
+}
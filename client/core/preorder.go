@@ -0,0 +1,181 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/dex/calc"
+)
+
+// PreOrder estimates the on-chain fees for both assets of the order, the value
+// that will be locked to fund it, the worst-case time until a refund, and the
+// effect of rounding the quantity to whole lots. The estimates are bounded by
+// the best case, where the order is filled by a single match, and the worst
+// case, where each lot is matched separately. If the DEX charges a trade fee,
+// the fee on the quantity received is estimated too. An estimate for a market
+// order uses the DEX's order book, which must be synced.
+func (c *Core) PreOrder(form *TradeForm) (*OrderEstimate, error) {
+	host := form.acctHost()
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", form.Host)
+	}
+	mktID := marketName(form.Base, form.Quote)
+	mkt := dc.market(mktID)
+	if mkt == nil {
+		return nil, fmt.Errorf("unknown market")
+	}
+	if form.Qty == 0 {
+		return nil, fmt.Errorf("zero quantity")
+	}
+	if form.IsLimit && form.Rate == 0 {
+		return nil, fmt.Errorf("zero-rate order not allowed")
+	}
+	wallets, err := c.namedWalletSet(dc, form.Base, form.Quote, form.BaseWallet, form.QuoteWallet, form.Sell)
+	if err != nil {
+		return nil, err
+	}
+	lotSize := wallets.baseAsset.LotSize
+
+	est := &OrderEstimate{Qty: form.Qty}
+	var baseQty uint64
+	if form.IsLimit || form.Sell {
+		est.Lots = form.Qty / lotSize
+		est.Qty = est.Lots * lotSize
+		est.Remainder = form.Qty - est.Qty
+		baseQty = est.Qty
+	} else {
+		// The quantity of a market buy is in units of the quote asset, so
+		// the number of lots depends on the book.
+		baseQty, err = c.marketBuyQty(dc, mktID, form.Qty)
+		if err != nil {
+			return nil, err
+		}
+		est.Lots = baseQty / lotSize
+	}
+	if est.Lots == 0 {
+		return nil, fmt.Errorf("order quantity is less than the lot size of %d", lotSize)
+	}
+
+	// Swaps are sent at the server's maximum fee rate. Assume a single input,
+	// as in OrderFunding.
+	fromAsset := wallets.fromAsset
+	fundQty := est.Qty
+	if form.IsLimit && !form.Sell {
+		fundQty = calc.BaseToQuote(form.Rate, fundQty)
+	}
+	est.Locked = calc.RequiredOrderFunds(fundQty, fromAsset.SwapSize-fromAsset.SwapSizeBase, fromAsset)
+	swapFee := fromAsset.SwapSize * fromAsset.MaxFeeRate
+	est.Swap = &FeeEstimate{
+		AssetID: fromAsset.ID,
+		FeeRate: fromAsset.MaxFeeRate,
+		Best:    swapFee,
+		Worst:   swapFee * est.Lots,
+	}
+
+	// Redemptions are sent at the wallet's fee rate if it can estimate the
+	// fee, and otherwise at most the server's maximum fee rate.
+	toAsset, toWallet := wallets.toAsset, wallets.toWallet
+	feeRate, redeemFee := toAsset.MaxFeeRate, toAsset.SwapSize*toAsset.MaxFeeRate
+	if estimator, ok := toWallet.Wallet.(asset.RedeemEstimator); ok && toWallet.connected() {
+		feeRate, redeemFee = estimator.RedeemFee()
+	}
+	est.Redeem = &FeeEstimate{
+		AssetID: toAsset.ID,
+		FeeRate: feeRate,
+		Best:    redeemFee,
+		Worst:   redeemFee * est.Lots,
+	}
+
+	est.RefundAfter = uint64(c.refundDelay(dc, form) / time.Millisecond)
+
+	// The trade fee is paid on the quantity received, which is the quote
+	// asset for a sell.
+	if mkt.TradeFee > 0 {
+		recvQty := baseQty
+		if form.Sell {
+			if form.IsLimit {
+				recvQty = calc.BaseToQuote(form.Rate, baseQty)
+			} else if recvQty, err = c.marketSellQty(dc, mktID, baseQty); err != nil {
+				return nil, err
+			}
+		}
+		est.TradeFee = &TradeFeeEstimate{
+			AssetID: toAsset.ID,
+			Rate:    mkt.TradeFee,
+			Fee:     calc.TradeFee(recvQty, mkt.TradeFee),
+		}
+	}
+	return est, nil
+}
+
+// refundDelay is the worst-case time from the order's match until a refund of
+// its swap is possible. A maker's swap is refundable after the maker lock
+// time. A taker waits up to the broadcast timeout for the maker's swap, and the
+// taker's swap is refundable after the taker lock time. A standing limit order
+// may be either.
+func (c *Core) refundDelay(dc *dexConnection, form *TradeForm) time.Duration {
+	taker := time.Millisecond*time.Duration(dc.cfg.BroadcastTimeout) + c.lockTimeTaker
+	if !form.IsLimit || form.TifNow {
+		return taker
+	}
+	if c.lockTimeMaker > taker {
+		return c.lockTimeMaker
+	}
+	return taker
+}
+
+// marketBuyQty estimates the quantity of the base asset that a market buy of
+// quoteQty would receive, from the sell side of the synced book.
+func (c *Core) marketBuyQty(dc *dexConnection, mktID string, quoteQty uint64) (uint64, error) {
+	dc.booksMtx.RLock()
+	book, found := dc.books[mktID]
+	dc.booksMtx.RUnlock()
+	if !found {
+		return 0, fmt.Errorf("no synced order book for market %s", mktID)
+	}
+	_, sells, _ := book.Orders()
+	if len(sells) == 0 {
+		return 0, fmt.Errorf("no sell orders on the %s book", mktID)
+	}
+	var baseQty uint64
+	for _, ord := range sells {
+		cost := calc.BaseToQuote(ord.Rate, ord.Quantity)
+		if cost >= quoteQty {
+			return baseQty + calc.QuoteToBase(ord.Rate, quoteQty), nil
+		}
+		quoteQty -= cost
+		baseQty += ord.Quantity
+	}
+	return baseQty, nil
+}
+
+// marketSellQty estimates the quantity of the quote asset that a market sell
+// of baseQty would receive, from the buy side of the synced book.
+func (c *Core) marketSellQty(dc *dexConnection, mktID string, baseQty uint64) (uint64, error) {
+	dc.booksMtx.RLock()
+	book, found := dc.books[mktID]
+	dc.booksMtx.RUnlock()
+	if !found {
+		return 0, fmt.Errorf("no synced order book for market %s", mktID)
+	}
+	buys, _, _ := book.Orders()
+	if len(buys) == 0 {
+		return 0, fmt.Errorf("no buy orders on the %s book", mktID)
+	}
+	var quoteQty uint64
+	for _, ord := range buys {
+		if ord.Quantity >= baseQty {
+			return quoteQty + calc.BaseToQuote(ord.Rate, baseQty), nil
+		}
+		baseQty -= ord.Quantity
+		quoteQty += calc.BaseToQuote(ord.Rate, ord.Quantity)
+	}
+	return quoteQty, nil
+}
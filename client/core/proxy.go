@@ -0,0 +1,113 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// proxiesKey is the app-level db key for the DEX servers' proxy settings.
+const proxiesKey = "proxies"
+
+// ProxyConfig is the SOCKS5 proxy used to connect to a DEX server.
+type ProxyConfig struct {
+	// Addr is the proxy's host:port address, e.g. 127.0.0.1:9050 for Tor.
+	Addr string `json:"addr"`
+	User string `json:"user"`
+	Pass string `json:"pass"`
+	// Isolate uses random proxy credentials for each connection, so that Tor
+	// uses a separate circuit for each connection. User and Pass are ignored.
+	Isolate bool `json:"isolate"`
+}
+
+// isOnion is true if the host is a Tor onion service.
+func isOnion(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.HasSuffix(host, ".onion")
+}
+
+// Proxies returns the proxy settings, keyed by DEX host.
+func (c *Core) Proxies() map[string]*ProxyConfig {
+	c.proxyMtx.RLock()
+	defer c.proxyMtx.RUnlock()
+	proxies := make(map[string]*ProxyConfig, len(c.proxies))
+	for host, proxy := range c.proxies {
+		p := *proxy
+		proxies[host] = &p
+	}
+	return proxies
+}
+
+// SetProxy sets the proxy used to connect to the DEX server and saves the
+// settings to the database. A nil proxy removes the setting. The setting may be
+// made before registering with the DEX, and applies to new connections. An
+// existing connection is not affected until the next login.
+func (c *Core) SetProxy(dexAddr string, proxy *ProxyConfig) error {
+	if dexAddr == "" {
+		return newError(emptyHostErr, "no dex address specified")
+	}
+	// Proxies are set for the DEX host, and apply to all accounts there.
+	_, host := splitAccountHost(addrHost(dexAddr))
+	if proxy != nil {
+		if _, _, err := net.SplitHostPort(proxy.Addr); err != nil {
+			return fmt.Errorf("invalid proxy address %q: %v", proxy.Addr, err)
+		}
+		p := *proxy
+		proxy = &p
+	}
+
+	c.proxyMtx.Lock()
+	defer c.proxyMtx.Unlock()
+	proxies := make(map[string]*ProxyConfig, len(c.proxies)+1)
+	for k, v := range c.proxies {
+		proxies[k] = v
+	}
+	if proxy == nil {
+		delete(proxies, host)
+	} else {
+		proxies[host] = proxy
+	}
+	b, err := json.Marshal(proxies)
+	if err != nil {
+		return fmt.Errorf("error encoding proxy settings: %v", err)
+	}
+	if err = c.db.Store(proxiesKey, b); err != nil {
+		return fmt.Errorf("error saving proxy settings: %v", err)
+	}
+	c.proxies = proxies
+	return nil
+}
+
+// proxy is the proxy for the DEX host, or nil if there is none.
+func (c *Core) proxy(host string) *ProxyConfig {
+	c.proxyMtx.RLock()
+	defer c.proxyMtx.RUnlock()
+	return c.proxies[host]
+}
+
+// loadProxies loads the proxy settings from the database.
+func (c *Core) loadProxies() {
+	exists, err := c.db.ValueExists(proxiesKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(proxiesKey)
+	if err != nil {
+		log.Errorf("error loading proxy settings: %v", err)
+		return
+	}
+	proxies := make(map[string]*ProxyConfig)
+	if err = json.Unmarshal(b, &proxies); err != nil {
+		log.Errorf("error decoding proxy settings: %v", err)
+		return
+	}
+	c.proxyMtx.Lock()
+	c.proxies = proxies
+	c.proxyMtx.Unlock()
+}
@@ -0,0 +1,264 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// RecoverableSwap is a swap contract that the user broadcast and that is still
+// unspent on-chain, i.e. it has not been redeemed or refunded.
+type RecoverableSwap struct {
+	Host    string    `json:"host"`
+	OrderID string    `json:"orderID"`
+	MatchID string    `json:"matchID"`
+	AssetID uint32    `json:"assetID"`
+	Coin    string    `json:"coin"`
+	CoinID  dex.Bytes `json:"coinID"`
+	Value   uint64    `json:"value"`
+	// Expiration is the contract's locktime, in milliseconds.
+	Expiration uint64 `json:"expiration"`
+	// Refundable is true if the locktime has expired.
+	Refundable bool `json:"refundable"`
+	// Tracked is true if a loaded trade is monitoring the match, in which case
+	// the swap will be refunded automatically if it is not redeemed, unless
+	// automatic refunds are disabled.
+	Tracked bool `json:"tracked"`
+	// Error is set if the contract could not be checked, e.g. because the
+	// wallet could not be connected.
+	Error string `json:"error,omitempty"`
+}
+
+// RecoveryNote is a notification regarding the recovery of a swap that the
+// normal trade flow lost track of.
+type RecoveryNote struct {
+	db.Notification
+	MatchID string `json:"matchID"`
+}
+
+func newRecoveryNote(topic Topic, severity db.Severity, matchID string, args ...interface{}) *RecoveryNote {
+	return &RecoveryNote{
+		Notification: newTopicNote(NoteTypeRecovery, topic, severity, args),
+		MatchID:      matchID,
+	}
+}
+
+// RecoverableSwaps checks the contracts of every incomplete match in the
+// database, and lists those that are still unspent on-chain. Swaps that are
+// not Tracked, or all swaps if automatic refunds are disabled, can be refunded
+// with RefundSwap once Refundable.
+func (c *Core) RecoverableSwaps() ([]*RecoverableSwap, error) {
+	matches, err := c.db.ActiveMatches()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving matches: %v", err)
+	}
+	swaps := make([]*RecoverableSwap, 0)
+	for _, m := range matches {
+		swapCoin := ourSwapCoin(m)
+		if swapCoin == nil {
+			continue
+		}
+		assetID, walletName, err := c.swapAsset(m)
+		if err != nil {
+			log.Errorf("RecoverableSwaps: %v", err)
+			continue
+		}
+		swap := &RecoverableSwap{
+			Host:    m.MetaData.DEX,
+			OrderID: m.Match.OrderID.String(),
+			MatchID: m.Match.MatchID.String(),
+			AssetID: assetID,
+			Coin:    coinIDString(assetID, swapCoin),
+			CoinID:  dex.Bytes(swapCoin),
+			Tracked: c.trackingMatch(m),
+		}
+		wallet, err := c.connectedNamedWallet(assetID, walletName)
+		if err != nil {
+			swap.Error = err.Error()
+			swaps = append(swaps, swap)
+			continue
+		}
+		auditInfo, err := wallet.AuditContract(dex.Bytes(swapCoin), m.MetaData.Proof.Script)
+		if err != nil {
+			if errors.Is(err, asset.CoinNotFoundError) {
+				// Redeemed or refunded.
+				continue
+			}
+			swap.Error = fmt.Sprintf("error checking contract: %v", err)
+			swaps = append(swaps, swap)
+			continue
+		}
+		swap.Value = auditInfo.Coin().Value()
+		swap.Expiration = encode.UnixMilliU(auditInfo.Expiration())
+		swap.Refundable, err = wallet.LocktimeExpired(m.MetaData.Proof.Script)
+		if err != nil {
+			swap.Error = fmt.Sprintf("error checking locktime: %v", err)
+		}
+		swaps = append(swaps, swap)
+	}
+	return swaps, nil
+}
+
+// RefundSwap refunds the user's swap for a match that is no longer monitored
+// by a loaded trade, or for any match if automatic refunds are disabled. The
+// contract's locktime must have expired. The refund coin is returned.
+func (c *Core) RefundSwap(pw []byte, matchID string) (string, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return "", fmt.Errorf("RefundSwap password error: %v", err)
+	}
+	midB, err := hex.DecodeString(matchID)
+	if err != nil || len(midB) != order.MatchIDSize {
+		return "", fmt.Errorf("invalid match ID %q", matchID)
+	}
+	var mid order.MatchID
+	copy(mid[:], midB)
+	matches, err := c.db.ActiveMatches()
+	if err != nil {
+		return "", fmt.Errorf("error retrieving matches: %v", err)
+	}
+	var m *db.MetaMatch
+	for _, dbMatch := range matches {
+		if dbMatch.Match.MatchID == mid {
+			m = dbMatch
+			break
+		}
+	}
+	if m == nil {
+		return "", fmt.Errorf("no incomplete match %s", matchID)
+	}
+	swapCoin := ourSwapCoin(m)
+	if swapCoin == nil {
+		return "", fmt.Errorf("match %s has no swap to refund", matchID)
+	}
+	if tracker, match := c.trackedMatch(m); match != nil {
+		if c.autoRefundEnabled() {
+			return "", fmt.Errorf("match %s is being monitored by an active trade, and will be refunded automatically", matchID)
+		}
+		refundStr, err := tracker.refundTracked(match)
+		if err != nil {
+			return "", err
+		}
+		c.updateAssetBalance(tracker.wallets.fromAsset.ID)
+		return refundStr, nil
+	}
+	assetID, walletName, err := c.swapAsset(m)
+	if err != nil {
+		return "", err
+	}
+	wallet, found := c.namedWallet(assetID, walletName)
+	if !found {
+		return "", fmt.Errorf("%s wallet not found", walletLabel(assetID, walletName))
+	}
+	if err := c.connectAndUnlock(crypter, wallet); err != nil {
+		return "", err
+	}
+	contract := m.MetaData.Proof.Script
+	expired, err := wallet.LocktimeExpired(contract)
+	if err != nil {
+		return "", fmt.Errorf("error checking contract locktime: %v", err)
+	}
+	if !expired {
+		return "", fmt.Errorf("contract for match %s has not expired", matchID)
+	}
+	refundCoin, err := wallet.Refund(dex.Bytes(swapCoin), contract)
+	if err != nil {
+		c.notify(newRecoveryNote(TopicRefundError, db.ErrorLevel, matchID,
+			unbip(assetID), coinIDString(assetID, swapCoin), matchID, err))
+		return "", err
+	}
+	m.MetaData.Proof.RefundCoin = order.CoinID(refundCoin)
+	if err := c.db.UpdateMatch(m); err != nil {
+		log.Errorf("error storing refund of match %s: %v", matchID, err)
+	}
+	refundStr := coinIDString(assetID, refundCoin)
+	c.notify(newRecoveryNote(TopicSwapRefunded, db.Success, matchID,
+		unbip(assetID), coinIDString(assetID, swapCoin), matchID, refundStr))
+	c.updateAssetBalance(assetID)
+	return refundStr, nil
+}
+
+// ourSwapCoin is the coin ID of the user's swap for the match, or nil if the
+// user has not sent a swap or the swap has already been refunded.
+func ourSwapCoin(m *db.MetaMatch) order.CoinID {
+	proof := &m.MetaData.Proof
+	if len(proof.Script) == 0 || len(proof.RefundCoin) > 0 {
+		return nil
+	}
+	swapCoin := proof.MakerSwap
+	if m.Match.Side == order.Taker {
+		swapCoin = proof.TakerSwap
+	}
+	if len(swapCoin) == 0 {
+		return nil
+	}
+	return swapCoin
+}
+
+// swapAsset is the asset that the user sends for the match, and the name of
+// the order's wallet for the asset.
+func (c *Core) swapAsset(m *db.MetaMatch) (uint32, string, error) {
+	dbOrder, err := c.db.Order(m.Match.OrderID)
+	if err != nil {
+		return 0, "", fmt.Errorf("error retrieving order %s for match %s: %v", m.Match.OrderID, m.Match.MatchID, err)
+	}
+	if dbOrder.Order.Trade().Sell {
+		return m.MetaData.Base, dbOrder.MetaData.BaseWallet, nil
+	}
+	return m.MetaData.Quote, dbOrder.MetaData.QuoteWallet, nil
+}
+
+// trackingMatch is true if a loaded trade is monitoring the match.
+func (c *Core) trackingMatch(m *db.MetaMatch) bool {
+	_, match := c.trackedMatch(m)
+	return match != nil
+}
+
+// trackedMatch finds the loaded trade that is monitoring the match, and its
+// matchTracker. The matchTracker is nil if the match is not being monitored.
+func (c *Core) trackedMatch(m *db.MetaMatch) (*trackedTrade, *matchTracker) {
+	c.connMtx.RLock()
+	dc, found := c.conns[m.MetaData.DEX]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, nil
+	}
+	dc.tradeMtx.RLock()
+	tracker, found := dc.trades[m.Match.OrderID]
+	dc.tradeMtx.RUnlock()
+	if !found {
+		return nil, nil
+	}
+	tracker.matchMtx.RLock()
+	defer tracker.matchMtx.RUnlock()
+	return tracker, tracker.matches[m.Match.MatchID]
+}
+
+// refundTracked refunds the swap of a monitored match whose lock time has
+// expired, for when automatic refunds are disabled. The refund coin ID is
+// returned as a string.
+func (t *trackedTrade) refundTracked(match *matchTracker) (string, error) {
+	t.matchMtx.Lock()
+	defer t.matchMtx.Unlock()
+	if !t.isRefundable(match) {
+		return "", fmt.Errorf("match %s is not refundable", match.id)
+	}
+	if _, err := t.refundMatches([]*matchTracker{match}); err != nil {
+		return "", err
+	}
+	corder, _ := t.coreOrderInternal()
+	refundAsset := t.wallets.fromAsset.ID
+	refundStr := coinIDString(refundAsset, match.MetaData.Proof.RefundCoin)
+	t.notify(newOrderNote(TopicMatchRefunded, db.WarningLevel, corder,
+		unbip(refundAsset), match.id, t.token(), refundStr))
+	return refundStr, nil
+}
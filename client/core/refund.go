@@ -0,0 +1,122 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+)
+
+// autoRefundKey is the app-level db key for the user's automatic refund
+// setting.
+const autoRefundKey = "autoRefund"
+
+// AutoRefund returns whether the user's expired swap contracts are refunded
+// automatically. Automatic refunds are enabled by default.
+func (c *Core) AutoRefund() bool {
+	return c.autoRefundEnabled()
+}
+
+// SetAutoRefund enables or disables the automatic refund of the user's swap
+// contracts once their lock times expire, and saves the setting to the
+// database. With automatic refunds disabled, the user is notified when a swap
+// becomes refundable, and must refund it with RefundSwap.
+func (c *Core) SetAutoRefund(enabled bool) error {
+	c.autoRefundMtx.Lock()
+	defer c.autoRefundMtx.Unlock()
+	b, err := json.Marshal(enabled)
+	if err != nil {
+		return fmt.Errorf("error encoding automatic refund setting: %v", err)
+	}
+	if err = c.db.Store(autoRefundKey, b); err != nil {
+		return fmt.Errorf("error saving automatic refund setting: %v", err)
+	}
+	c.noAutoRefund = !enabled
+	return nil
+}
+
+// autoRefundEnabled is the user's automatic refund setting.
+func (c *Core) autoRefundEnabled() bool {
+	c.autoRefundMtx.RLock()
+	defer c.autoRefundMtx.RUnlock()
+	return !c.noAutoRefund
+}
+
+// loadAutoRefund loads the user's automatic refund setting from the database.
+func (c *Core) loadAutoRefund() {
+	exists, err := c.db.ValueExists(autoRefundKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(autoRefundKey)
+	if err != nil {
+		log.Errorf("error loading automatic refund setting: %v", err)
+		return
+	}
+	var enabled bool
+	if err = json.Unmarshal(b, &enabled); err != nil {
+		log.Errorf("error decoding automatic refund setting: %v", err)
+		return
+	}
+	c.autoRefundMtx.Lock()
+	c.noAutoRefund = !enabled
+	c.autoRefundMtx.Unlock()
+}
+
+// autoRefundEnabled is whether the trade's expired swaps should be refunded
+// automatically.
+func (t *trackedTrade) autoRefundEnabled() bool {
+	return t.autoRefund == nil || t.autoRefund()
+}
+
+// notifyRefundable notifies the user once that the match's swap can be
+// refunded, when automatic refunds are disabled. notifyRefundable should be
+// called with the matchMtx locked.
+func (t *trackedTrade) notifyRefundable(match *matchTracker) {
+	if match.refundNotified {
+		return
+	}
+	match.refundNotified = true
+	corder, _ := t.coreOrderInternal()
+	t.notify(newOrderNote(TopicRefundAvailable, db.WarningLevel, corder,
+		unbip(t.wallets.fromAsset.ID), match.id, t.token()))
+}
+
+// checkRefunds checks the confirmations of refunds that are not yet known to
+// be confirmed, notifying the user of refunds broadcast in this session once
+// they confirm. The return value indicates whether any refund was confirmed,
+// and the balance should be updated. checkRefunds should be called with the
+// matchMtx locked.
+func (t *trackedTrade) checkRefunds() bool {
+	wallet := t.wallets.fromWallet
+	var confirmed bool
+	for _, match := range t.matches {
+		refundCoin := dex.Bytes(match.MetaData.Proof.RefundCoin)
+		if len(refundCoin) == 0 || match.refundConfirmed {
+			continue
+		}
+		confs, err := wallet.Confirmations(refundCoin)
+		if err != nil {
+			log.Debugf("error getting confirmations for refund %s for match %s: %v",
+				coinIDString(wallet.AssetID, refundCoin), match.id, err)
+			continue
+		}
+		if confs == 0 {
+			continue
+		}
+		match.refundConfirmed = true
+		confirmed = true
+		if !match.refundSent {
+			// Refunded before a restart.
+			continue
+		}
+		corder, _ := t.coreOrderInternal()
+		t.notify(newOrderNote(TopicRefundConfirmed, db.Success, corder,
+			coinIDString(wallet.AssetID, refundCoin), match.id, t.token()))
+	}
+	return confirmed
+}
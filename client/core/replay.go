@@ -0,0 +1,187 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
+)
+
+// EpochVerification is the result of VerifyEpoch. SeedOK and ResultOK indicate
+// that replaying the epoch produced the shuffle seed and the matching results
+// reported by the server. MissingMatches are the IDs of matches of the order
+// in the replay that are not in the database. Fair is true if all checks
+// passed.
+type EpochVerification struct {
+	Host           string   `json:"host"`
+	MarketID       string   `json:"marketID"`
+	OrderID        string   `json:"orderID"`
+	Epoch          uint64   `json:"epoch"`
+	QueueSize      int      `json:"queueSize"`
+	BookOrders     int      `json:"bookOrders"`
+	Matches        int      `json:"matches"`
+	SeedOK         bool     `json:"seedOK"`
+	ResultOK       bool     `json:"resultOK"`
+	MissingMatches []string `json:"missingMatches"`
+	Fair           bool     `json:"fair"`
+}
+
+// VerifyEpoch requests the matching inputs of the epoch of the order with the
+// hex-encoded order ID from the server, and replays the epoch through the
+// matching algorithm to verify that the order was treated fairly. The other
+// orders in the epoch are redacted by the server. The server only retains
+// recent epochs, so the epoch should be verified soon after the order is
+// matched. An error is returned if the server's data is inconsistent with the
+// order or the epoch's preimage commitments.
+func (c *Core) VerifyEpoch(orderID string) (*EpochVerification, error) {
+	oid, err := order.IDFromHex(orderID)
+	if err != nil {
+		return nil, err
+	}
+	mOrd, err := c.db.Order(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving order %s: %v", oid, err)
+	}
+	ord, host := mOrd.Order, mOrd.MetaData.Host
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+	if !dc.acct.authed() {
+		return nil, fmt.Errorf("not logged in to %s", host)
+	}
+	if err := dc.checkRoute(msgjson.EpochReplayRoute); err != nil {
+		return nil, err
+	}
+	mktID := marketName(ord.Base(), ord.Quote())
+	mkt := dc.market(mktID)
+	if mkt == nil || mkt.EpochLen == 0 {
+		return nil, fmt.Errorf("unknown market %s", mktID)
+	}
+	epochIdx := uint64(ord.Time()) / mkt.EpochLen
+
+	res := new(msgjson.EpochReplayResult)
+	err = sendRequest(dc.WsConn, msgjson.EpochReplayRoute, &msgjson.EpochReplayRequest{
+		Base:    ord.Base(),
+		Quote:   ord.Quote(),
+		Epoch:   epochIdx,
+		OrderID: oid[:],
+	}, res)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting epoch %d replay from %s: %v", epochIdx, host, err)
+	}
+
+	decode := func(ro *msgjson.ReplayOrder) (order.Order, error) {
+		if len(ro.OrderID) != order.OrderIDSize {
+			return nil, fmt.Errorf("invalid order ID length %d", len(ro.OrderID))
+		}
+		var roid order.OrderID
+		copy(roid[:], ro.OrderID)
+		return order.DecodeRedactedOrder(ro.Order, roid)
+	}
+
+	bookOrders := make([]*order.LimitOrder, 0, len(res.Book))
+	for _, ro := range res.Book {
+		ord, err := decode(ro)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding book order from %s: %v", host, err)
+		}
+		lo, ok := ord.(*order.LimitOrder)
+		if !ok {
+			return nil, fmt.Errorf("%s sent non-limit book order %v", host, ord.ID())
+		}
+		bookOrders = append(bookOrders, lo)
+	}
+
+	// The order must be in the queue as it was submitted, and every preimage
+	// must match the order's commitment. Queue orders are unfilled.
+	rOrd := order.RedactOrder(ord)
+	if trade := rOrd.Trade(); trade != nil {
+		trade.SetFill(0)
+	}
+	redacted := order.EncodeOrder(rOrd)
+	var inQueue bool
+	queue := make([]*matcher.OrderRevealed, 0, len(res.Queue))
+	for _, ro := range res.Queue {
+		qOrd, err := decode(ro)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding queue order from %s: %v", host, err)
+		}
+		if len(ro.Preimage) != order.PreimageSize {
+			return nil, fmt.Errorf("%s sent invalid preimage for order %v", host, qOrd.ID())
+		}
+		var pi order.Preimage
+		copy(pi[:], ro.Preimage)
+		if pi.Commit() != qOrd.Commitment() {
+			return nil, fmt.Errorf("%s sent preimage that does not match commitment for order %v", host, qOrd.ID())
+		}
+		if qOrd.ID() == oid {
+			if !bytes.Equal(ro.Order, redacted) {
+				return nil, fmt.Errorf("%s sent altered order %v", host, oid)
+			}
+			if len(mOrd.MetaData.Proof.Preimage) > 0 && !bytes.Equal(mOrd.MetaData.Proof.Preimage, pi[:]) {
+				return nil, fmt.Errorf("%s sent wrong preimage for order %v", host, oid)
+			}
+			inQueue = true
+		}
+		queue = append(queue, &matcher.OrderRevealed{
+			Order:    qOrd,
+			Preimage: pi,
+		})
+	}
+	if !inQueue {
+		return nil, fmt.Errorf("%s did not include order %v in epoch %d", host, oid, epochIdx)
+	}
+
+	seed, matchSets, result, err := replay.ReplayEpoch(res.LotSize, res.TradeFee, res.OddLotQty, bookOrders, queue)
+	if err != nil {
+		return nil, fmt.Errorf("error replaying epoch %d: %v", epochIdx, err)
+	}
+
+	ver := &EpochVerification{
+		Host:           host,
+		MarketID:       mktID,
+		OrderID:        oid.String(),
+		Epoch:          epochIdx,
+		QueueSize:      len(queue),
+		BookOrders:     len(bookOrders),
+		SeedOK:         bytes.Equal(seed, res.Seed),
+		ResultOK:       bytes.Equal(result, res.Result),
+		MissingMatches: make([]string, 0),
+	}
+
+	// Every replayed match of the order should be known. Cancel orders do not
+	// result in trade matches.
+	known, err := c.db.MatchesForOrder(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving matches for order %v: %v", oid, err)
+	}
+	knownIDs := make(map[order.MatchID]bool, len(known))
+	for _, m := range known {
+		knownIDs[m.Match.MatchID] = true
+	}
+	for _, ms := range matchSets {
+		if _, isCancel := ms.Taker.(*order.CancelOrder); isCancel {
+			continue
+		}
+		for _, match := range ms.Matches() {
+			if match.Taker.ID() != oid && match.Maker.ID() != oid {
+				continue
+			}
+			ver.Matches++
+			if mid := match.ID(); !knownIDs[mid] {
+				ver.MissingMatches = append(ver.MissingMatches, mid.String())
+			}
+		}
+	}
+	ver.Fair = ver.SeedOK && ver.ResultOK && len(ver.MissingMatches) == 0
+	return ver, nil
+}
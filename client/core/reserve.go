@@ -0,0 +1,288 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// Reservations accounts for the value of the asset that is reserved for
+// orders and locked in swap contracts, using the last known balance of the
+// asset's default wallet.
+func (c *Core) Reservations(assetID uint32) (*Reservations, error) {
+	wallet, found := c.wallet(assetID)
+	if !found {
+		return nil, fmt.Errorf("no %s wallet", unbip(assetID))
+	}
+	return c.reservations(wallet), nil
+}
+
+// reservations is the Reservations for the orders funded by the wallet.
+func (c *Core) reservations(wallet *xcWallet) *Reservations {
+	assetID := wallet.AssetID
+	wallet.mtx.RLock()
+	bal := wallet.balance
+	wallet.mtx.RUnlock()
+	if bal == nil {
+		bal = new(db.Balance)
+	}
+
+	res := &Reservations{
+		AssetID: assetID,
+		Balance: bal,
+		Orders:  make([]*OrderReservation, 0),
+	}
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		dc.tradeMtx.RLock()
+		for _, tracker := range dc.trades {
+			if tracker.fromAssetID() != assetID || tracker.wallets == nil || tracker.wallets.fromWallet != wallet {
+				continue
+			}
+			ord := tracker.reservation()
+			if ord == nil {
+				continue
+			}
+			res.Orders = append(res.Orders, ord)
+			res.OrderLocked += ord.Locked
+			for _, match := range ord.Matches {
+				if match.Contract != "" {
+					res.Contracts += match.Value
+				}
+			}
+		}
+		dc.tradeMtx.RUnlock()
+	}
+	c.connMtx.RUnlock()
+
+	sort.Slice(res.Orders, func(i, j int) bool {
+		return res.Orders[i].OrderID < res.Orders[j].OrderID
+	})
+	if bal.Locked > res.OrderLocked {
+		res.Untracked = bal.Locked - res.OrderLocked
+	}
+	return res
+}
+
+// OrderFunding checks whether the wallet's available balance can fund the
+// order, and explains why not if it can't.
+func (c *Core) OrderFunding(form *TradeForm) (*OrderFunding, error) {
+	host := form.acctHost()
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", form.Host)
+	}
+	if dc.market(marketName(form.Base, form.Quote)) == nil {
+		return nil, fmt.Errorf("unknown market")
+	}
+	wallets, err := c.namedWalletSet(dc, form.Base, form.Quote, form.BaseWallet, form.QuoteWallet, form.Sell)
+	if err != nil {
+		return nil, err
+	}
+	return c.orderFunding(wallets, form)
+}
+
+// orderFunding is the OrderFunding for the wallet set. The wallet balance is
+// requested from the wallet, which must be connected.
+func (c *Core) orderFunding(wallets *walletSet, form *TradeForm) (*OrderFunding, error) {
+	wallet, fromAsset := wallets.fromWallet, wallets.fromAsset
+	if !wallet.connected() {
+		return nil, fmt.Errorf("%s wallet is not connected", wallet.label())
+	}
+	bal, err := wallet.Balance()
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s balance: %v", unbip(fromAsset.ID), err)
+	}
+	if form.Qty == 0 {
+		return nil, fmt.Errorf("zero quantity")
+	}
+	fundQty := form.Qty
+	if form.IsLimit && !form.Sell {
+		fundQty = calc.BaseToQuote(form.Rate, fundQty)
+	}
+	if fundQty < fromAsset.LotSize {
+		return nil, fmt.Errorf("order funds of %d are less than the %s lot size of %d",
+			fundQty, unbip(fromAsset.ID), fromAsset.LotSize)
+	}
+	// Assume a single input. More inputs add fees that the wallet will
+	// include when the order is funded.
+	funding := &OrderFunding{
+		AssetID:   fromAsset.ID,
+		Required:  calc.RequiredOrderFunds(fundQty, fromAsset.SwapSize-fromAsset.SwapSizeBase, fromAsset),
+		Available: bal.Available,
+		Locked:    bal.Locked,
+		Immature:  bal.Immature,
+	}
+	funding.OK = funding.Available >= funding.Required
+	if funding.OK {
+		return funding, nil
+	}
+
+	symbol := unbip(fromAsset.ID)
+	reasons := []string{fmt.Sprintf("the order needs %.8f %s with fees, but only %.8f is available",
+		float64(funding.Required)/conversionFactor, symbol, float64(funding.Available)/conversionFactor)}
+	if bal.Locked > 0 {
+		var n int
+		for _, ord := range c.reservations(wallet).Orders {
+			if ord.Locked > 0 {
+				n++
+			}
+		}
+		reasons = append(reasons, fmt.Sprintf("%.8f is locked for %d other orders",
+			float64(bal.Locked)/conversionFactor, n))
+	}
+	if bal.Immature > 0 {
+		reasons = append(reasons, fmt.Sprintf("%.8f is immature and needs more confirmations",
+			float64(bal.Immature)/conversionFactor))
+	}
+	funding.Reason = strings.Join(reasons, "; ")
+	return funding, nil
+}
+
+// fromAssetID is the ID of the asset that the order funds swaps with.
+func (t *trackedTrade) fromAssetID() uint32 {
+	if t.Trade().Sell {
+		return t.Base()
+	}
+	return t.Quote()
+}
+
+// reservation is the OrderReservation for the order, or nil if nothing is
+// reserved for the order.
+func (t *trackedTrade) reservation() *OrderReservation {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	t.matchMtx.RLock()
+	defer t.matchMtx.RUnlock()
+
+	assetID := t.fromAssetID()
+	trade := t.Trade()
+	status := t.metaData.Status
+	active := status == order.OrderStatusEpoch || status == order.OrderStatusBooked
+	res := &OrderReservation{
+		Host:     t.dc.acct.host,
+		OrderID:  t.ID().String(),
+		MarketID: t.mktID,
+		Status:   status,
+		Sell:     trade.Sell,
+		Coins:    make([]*ReservedCoin, 0),
+		Matches:  make([]*MatchReservation, 0),
+	}
+
+	// The funding coins are locked until they are spent by the first swap or
+	// returned. Later swaps are funded by the change, which is not locked.
+	usingChange := len(t.metaData.ChangeCoin) > 0
+	if !t.coinsReturned {
+		coinIDs := trade.Coins
+		if usingChange {
+			coinIDs = []order.CoinID{t.metaData.ChangeCoin}
+		}
+		for _, coinID := range coinIDs {
+			coin, found := t.coins[hex.EncodeToString(coinID)]
+			if !found {
+				continue
+			}
+			res.Coins = append(res.Coins, &ReservedCoin{
+				ID:    coinIDString(assetID, coinID),
+				Value: coin.Value(),
+			})
+			if !usingChange {
+				res.Locked += coin.Value()
+			}
+		}
+	}
+
+	if active {
+		remaining := trade.Remaining()
+		if !trade.Sell && t.Type() == order.LimitOrderType {
+			remaining = calc.BaseToQuote(t.rate(), remaining)
+		}
+		res.Pending += remaining
+	}
+
+	var awaitingSwap bool
+	for _, match := range t.matches {
+		mr := t.matchReservation(match)
+		if mr == nil {
+			continue
+		}
+		if mr.Contract == "" {
+			awaitingSwap = true
+			res.Pending += mr.Value
+		}
+		res.Matches = append(res.Matches, mr)
+	}
+	sort.Slice(res.Matches, func(i, j int) bool {
+		return res.Matches[i].MatchID < res.Matches[j].MatchID
+	})
+
+	switch {
+	case len(res.Coins) == 0:
+	case active:
+		res.Release = "Reserved until the order is filled and swapped, or canceled"
+	case awaitingSwap:
+		res.Release = "Reserved until the swaps for the order's matches are sent"
+	case usingChange:
+		res.Release = "Change from the order's last swap, not locked in the wallet"
+	default:
+		res.Release = "Returned to the wallet one epoch after the order's epoch"
+	}
+	if len(res.Coins) == 0 && res.Pending == 0 && len(res.Matches) == 0 {
+		return nil
+	}
+	return res
+}
+
+// matchReservation is the MatchReservation for the match, or nil if the
+// user's swap for the match has been redeemed or refunded, or will never be
+// sent. matchReservation should be called with the matchMtx locked.
+func (t *trackedTrade) matchReservation(match *matchTracker) *MatchReservation {
+	dbMatch, metaData, proof, auth := match.parts()
+	swapStatus, spentStatus := order.TakerSwapCast, order.MakerRedeemed
+	swapCoin := proof.TakerSwap
+	lockTime := t.lockTimeTaker
+	if dbMatch.Side == order.Maker {
+		swapStatus, spentStatus = order.MakerSwapCast, order.MatchComplete
+		swapCoin = proof.MakerSwap
+		lockTime = t.lockTimeMaker
+	}
+	swapped := dbMatch.Status >= swapStatus && len(swapCoin) > 0
+	if !swapped && (proof.IsRevoked || match.failErr != nil) {
+		return nil
+	}
+	if swapped && (dbMatch.Status >= spentStatus || len(proof.RefundCoin) > 0) {
+		return nil
+	}
+
+	value := dbMatch.Quantity
+	if !match.trade.Sell {
+		value = calc.BaseToQuote(dbMatch.Rate, dbMatch.Quantity)
+	}
+	mr := &MatchReservation{
+		MatchID: match.id.String(),
+		Status:  metaData.Status,
+		Side:    dbMatch.Side,
+		Value:   value,
+		Release: "Reserved in the order's funding coins until the swap is sent",
+	}
+	if swapped {
+		mr.Contract = coinIDString(t.fromAssetID(), swapCoin)
+		matchTime := encode.UnixTimeMilli(int64(auth.MatchStamp))
+		mr.LockTime = encode.UnixMilliU(matchTime.Add(lockTime))
+		mr.Release = fmt.Sprintf("Locked in the swap contract until the counterparty redeems, or refundable after %s",
+			matchTime.Add(lockTime).UTC().Format(time.RFC3339))
+	}
+	return mr
+}
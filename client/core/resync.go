@@ -0,0 +1,361 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+)
+
+// maxStatusRequests is the maximum number of orders or matches that the server
+// will report in a single order_status or match_status request.
+const maxStatusRequests = 256
+
+// resync brings the order books, orders, and matches of the DEX connection up
+// to date after the connection is re-established. extras are the matches
+// reported by the server in the 'connect' response that are not known to an
+// active trade. The user is notified of the result.
+func (c *Core) resync(dc *dexConnection, extras []*msgjson.Match) {
+	host := dc.acct.host
+	errs := newErrorSet("resync with %s: ", host)
+	books, err := c.resyncBooks(dc)
+	if err != nil {
+		errs.addErr(err)
+	}
+	orders, err := c.resyncOrders(dc)
+	if err != nil {
+		errs.addErr(err)
+	}
+	matches, revoked, err := c.resyncMatches(dc, extras)
+	if err != nil {
+		errs.addErr(err)
+	}
+
+	topic, severity := TopicDEXResynced, db.Success
+	if err := errs.ifany(); err != nil {
+		log.Error(err)
+		topic, severity = TopicDEXResyncedWithErrs, db.WarningLevel
+	}
+	c.notify(newConnEventNote(topic, host, true, severity, host, books, orders, matches, revoked))
+	c.refreshUser()
+}
+
+// reconcileTrades brings the trades loaded from the database at login up to
+// date with the server's record of their orders and matches, so that a client
+// restored from an old database resolves the state of each active order rather
+// than waiting on notifications that were already sent. extras are the matches
+// reported by the server in the 'connect' response that are not known to an
+// active trade. The user is only notified if anything changed.
+func (c *Core) reconcileTrades(dc *dexConnection, extras []*msgjson.Match) {
+	host := dc.acct.host
+	errs := newErrorSet("reconcile with %s: ", host)
+	orders, err := c.resyncOrders(dc)
+	if err != nil {
+		errs.addErr(err)
+	}
+	matches, revoked, err := c.resyncMatches(dc, extras)
+	if err != nil {
+		errs.addErr(err)
+	}
+	err = errs.ifany()
+	if err == nil && orders+matches+revoked == 0 {
+		return
+	}
+	topic, severity := TopicTradesReconciled, db.Success
+	if err != nil {
+		log.Error(err)
+		topic, severity = TopicTradesReconciledWithErrs, db.WarningLevel
+	}
+	c.notify(newOrderNote(topic, severity, nil, host, orders, matches, revoked))
+}
+
+// resyncBooks re-subscribes to the order books of the DEX connection, and sends
+// the fresh order books to the subscribers. The number of order books
+// refreshed is returned.
+func (c *Core) resyncBooks(dc *dexConnection) (int, error) {
+	dc.booksMtx.RLock()
+	books := make(map[string]*bookie, len(dc.books))
+	for mkt, booky := range dc.books {
+		books[mkt] = booky
+	}
+	dc.booksMtx.RUnlock()
+
+	errs := newErrorSet("resyncBooks: ")
+	var refreshed int
+	for mkt, booky := range books {
+		market := dc.market(mkt)
+		if market == nil {
+			errs.add("unknown market %s", mkt)
+			continue
+		}
+		// Notes received before the snapshot are cached and applied by Sync.
+		booky.Reset()
+		snapshot := new(msgjson.OrderBook)
+		err := sendRequest(dc.WsConn, msgjson.OrderBookRoute, &msgjson.OrderBookSubscription{
+			Base:  market.BaseID,
+			Quote: market.QuoteID,
+		}, snapshot)
+		if err != nil {
+			errs.add("error subscribing to %s order book: %v", mkt, err)
+			continue
+		}
+		if err := booky.Sync(snapshot); err != nil {
+			errs.add("error syncing %s order book: %v", mkt, err)
+			continue
+		}
+		booky.send(&BookUpdate{
+			Action:   FreshBookAction,
+			Host:     dc.acct.host,
+			MarketID: mkt,
+			Payload: &MarketOrderBook{
+				Base:  market.BaseID,
+				Quote: market.QuoteID,
+				Book:  booky.book(),
+			},
+		})
+		refreshed++
+	}
+	return refreshed, errs.ifany()
+}
+
+// resyncOrders updates the status of the active orders of the DEX connection
+// to the status reported by the server. The number of orders updated is
+// returned.
+func (c *Core) resyncOrders(dc *dexConnection) (int, error) {
+	if err := dc.checkRoute(msgjson.OrderStatusRoute); err != nil {
+		return 0, err
+	}
+	dc.tradeMtx.RLock()
+	trackers := make(map[order.OrderID]*trackedTrade, len(dc.trades))
+	reqs := make([]*msgjson.OrderStatusRequest, 0, len(dc.trades))
+	for oid, tracker := range dc.trades {
+		trackers[oid] = tracker
+		reqs = append(reqs, &msgjson.OrderStatusRequest{
+			Base:    tracker.Base(),
+			Quote:   tracker.Quote(),
+			OrderID: tracker.ID().Bytes(),
+		})
+	}
+	dc.tradeMtx.RUnlock()
+
+	var statuses []*msgjson.OrderStatus
+	for len(reqs) > 0 {
+		n := maxStatusRequests
+		if len(reqs) < n {
+			n = len(reqs)
+		}
+		var batch []*msgjson.OrderStatus
+		err := sendRequest(dc.WsConn, msgjson.OrderStatusRoute, reqs[:n], &batch)
+		if err != nil {
+			return 0, fmt.Errorf("order_status request error: %v", err)
+		}
+		statuses = append(statuses, batch...)
+		reqs = reqs[n:]
+	}
+
+	errs := newErrorSet("resyncOrders: ")
+	var updated int
+	for _, st := range statuses {
+		var oid order.OrderID
+		copy(oid[:], st.ID)
+		tracker := trackers[oid]
+		if tracker == nil {
+			continue
+		}
+		srvStatus := order.OrderStatus(st.Status)
+		tracker.mtx.RLock()
+		status := tracker.metaData.Status
+		tracker.mtx.RUnlock()
+		if status == srvStatus {
+			continue
+		}
+		log.Infof("Order %s status %s updated to %s reported by %s", oid, status, srvStatus, dc.acct.host)
+		if srvStatus == order.OrderStatusRevoked {
+			corder, _ := tracker.coreOrder()
+			c.revokeOrder(dc, tracker, corder, errs)
+			updated++
+			continue
+		}
+		tracker.mtx.Lock()
+		tracker.metaData.Status = srvStatus
+		err := tracker.db.UpdateOrder(tracker.metaOrder())
+		tracker.mtx.Unlock()
+		if err != nil {
+			errs.add("error updating order %s: %v", oid, err)
+		}
+		corder, _ := tracker.coreOrder()
+		tracker.notify(newOrderNote(TopicOrderStatusUpdated, db.Poke, corder, tracker.token(), srvStatus))
+		updated++
+	}
+	if updated > 0 {
+		dc.refreshMarkets()
+	}
+	return updated, errs.ifany()
+}
+
+// resyncMatches reconciles the incomplete matches of the DEX connection, and
+// the extra matches reported by the server, with the server's record of the
+// matches. Unknown matches are negotiated, and audit and redemption requests
+// that were missed while disconnected are replayed. Matches that the server
+// is no longer negotiating are revoked. The number of matches updated and the
+// number revoked are returned.
+func (c *Core) resyncMatches(dc *dexConnection, extras []*msgjson.Match) (int, int, error) {
+	if err := dc.checkRoute(msgjson.MatchStatusRoute); err != nil {
+		return 0, 0, err
+	}
+	trackers := make(map[order.MatchID]*trackedTrade)
+	var reqs []*msgjson.MatchStatusRequest
+	addReq := func(tracker *trackedTrade, mid order.MatchID) {
+		if trackers[mid] != nil {
+			return
+		}
+		trackers[mid] = tracker
+		reqs = append(reqs, &msgjson.MatchStatusRequest{
+			Base:    tracker.Base(),
+			Quote:   tracker.Quote(),
+			MatchID: mid[:],
+		})
+	}
+	dc.tradeMtx.RLock()
+	for _, tracker := range dc.trades {
+		tracker.matchMtx.RLock()
+		for mid, match := range tracker.matches {
+			if match.Match.Status == order.MatchComplete || match.MetaData.Proof.IsRevoked {
+				continue
+			}
+			addReq(tracker, mid)
+		}
+		tracker.matchMtx.RUnlock()
+	}
+	dc.tradeMtx.RUnlock()
+	for _, extra := range extras {
+		var oid order.OrderID
+		copy(oid[:], extra.OrderID)
+		tracker, _, isCancel := dc.findOrder(oid)
+		if tracker == nil || isCancel || len(extra.MatchID) != order.MatchIDSize {
+			continue
+		}
+		var mid order.MatchID
+		copy(mid[:], extra.MatchID)
+		addReq(tracker, mid)
+	}
+
+	var results []*msgjson.MatchStatusResult
+	for len(reqs) > 0 {
+		n := maxStatusRequests
+		if len(reqs) < n {
+			n = len(reqs)
+		}
+		var batch []*msgjson.MatchStatusResult
+		err := sendRequest(dc.WsConn, msgjson.MatchStatusRoute, reqs[:n], &batch)
+		if err != nil {
+			return 0, 0, fmt.Errorf("match_status request error: %v", err)
+		}
+		results = append(results, batch...)
+		reqs = reqs[n:]
+	}
+
+	errs := newErrorSet("resyncMatches: ")
+	var updated, revoked int
+	ticks := make(map[order.OrderID]*trackedTrade)
+	for _, res := range results {
+		var mid order.MatchID
+		copy(mid[:], res.MatchID)
+		tracker := trackers[mid]
+		if tracker == nil || res.Match == nil {
+			continue
+		}
+		tracker.matchMtx.RLock()
+		match, found := tracker.matches[mid]
+		tracker.matchMtx.RUnlock()
+		var changed bool
+		if !found {
+			if err := dc.acct.checkSig(res.Match.Serialize(), res.Match.Sig); err != nil {
+				errs.add("match %s signature error: %v", mid, err)
+				continue
+			}
+			// The match is negotiated as if the match request had been
+			// received, and any missed audit or redemption is replayed below.
+			msgMatch := *res.Match
+			msgMatch.Status = uint8(order.NewlyMatched)
+			if err := tracker.negotiate([]*msgjson.Match{&msgMatch}); err != nil {
+				errs.add("error negotiating match %s: %v", mid, err)
+				continue
+			}
+			tracker.matchMtx.RLock()
+			match, found = tracker.matches[mid]
+			tracker.matchMtx.RUnlock()
+			if !found {
+				continue
+			}
+			changed = true
+		}
+		replayed, err := tracker.replayMatch(match, res)
+		if err != nil {
+			errs.addErr(err)
+		}
+		changed = changed || replayed
+		if !res.Active && order.MatchStatus(res.Status) < order.MatchComplete {
+			tracker.matchMtx.Lock()
+			match.MetaData.Proof.IsRevoked = true
+			err := tracker.db.UpdateMatch(&match.MetaMatch)
+			tracker.matchMtx.Unlock()
+			if err != nil {
+				errs.add("error updating match %s: %v", mid, err)
+			}
+			log.Warnf("Match %s for order %s revoked by %s while disconnected", mid, tracker.ID(), dc.acct.host)
+			revoked++
+		} else if changed {
+			updated++
+		}
+		ticks[tracker.ID()] = tracker
+	}
+
+	counts := make(assetCounter)
+	for _, tracker := range ticks {
+		newCounts, err := tracker.tick()
+		if err != nil {
+			errs.addErr(err)
+		}
+		counts.absorb(newCounts)
+	}
+	if len(counts) > 0 {
+		dc.refreshMarkets()
+		c.updateBalances(counts)
+	}
+	return updated, revoked, errs.ifany()
+}
+
+// replayMatch records the audit and redemption in the server's match status
+// that the match has not yet processed, without acknowledging them to the
+// server. replayMatch returns true if either was recorded.
+func (t *trackedTrade) replayMatch(match *matchTracker, res *msgjson.MatchStatusResult) (bool, error) {
+	t.matchMtx.RLock()
+	status, side := match.Match.Status, match.Match.Side
+	t.matchMtx.RUnlock()
+	var replayed bool
+	// The maker audits the taker's swap after sending their own swap. The
+	// taker audits the maker's swap before sending theirs.
+	if res.Audit != nil && (side == order.Maker && status == order.MakerSwapCast ||
+		side == order.Taker && status == order.NewlyMatched) {
+		if err := t.recordAudit(res.Audit); err != nil {
+			return replayed, err
+		}
+		replayed = true
+	}
+	t.matchMtx.RLock()
+	status = match.Match.Status
+	t.matchMtx.RUnlock()
+	if res.Redemption != nil && (side == order.Maker && status == order.MakerRedeemed ||
+		side == order.Taker && status == order.TakerSwapCast) {
+		if err := t.recordRedemption(res.Redemption); err != nil {
+			return replayed, err
+		}
+		replayed = true
+	}
+	return replayed, nil
+}
@@ -0,0 +1,301 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+)
+
+// routeVenue is a DEX server that can take part in a routed order.
+type routeVenue struct {
+	host    string
+	lotSize uint64
+	// lotFee is the worst-case swap fee per lot, in atoms of the asset being
+	// sold.
+	lotFee uint64
+}
+
+// routeLevel is a book order on one of the route's venues.
+type routeLevel struct {
+	venue *routeVenue
+	qty   uint64
+	rate  uint64
+	// effRate is the rate adjusted for the venue's swap fees.
+	effRate float64
+}
+
+// effectiveRate adjusts the rate for the worst-case swap fees of one lot. A
+// sell's fees are paid in the base asset, which lowers the effective rate, and
+// a buy's fees are paid in the quote asset, which raises it.
+func effectiveRate(rate, lotSize, lotFee uint64, sell bool) float64 {
+	if sell {
+		return float64(rate) * float64(lotSize) / float64(lotSize+lotFee)
+	}
+	return float64(rate) + float64(lotFee)*conversionFactor/float64(lotSize)
+}
+
+// avgRate is the average rate of a fill of base atoms that was worth quote
+// atoms.
+func avgRate(base, quote uint64) uint64 {
+	if base == 0 {
+		return 0
+	}
+	// The rate is quote * atomsPerCoin / base, which is QuoteToBase with the
+	// base quantity in place of the rate.
+	return calc.QuoteToBase(base, quote)
+}
+
+// routeVenues finds the DEX servers with a synced book for the market pair,
+// and the book orders that an order from the form could match, sorted best
+// first after fees.
+func (c *Core) routeVenues(form *RouteForm) ([]*routeVenue, []*routeLevel) {
+	hosts, books := c.syncedBooks(form.Base, form.Quote)
+	allowed := make(map[string]bool, len(form.Hosts))
+	for _, host := range form.Hosts {
+		allowed[addrHost(host)] = true
+	}
+
+	venues := make([]*routeVenue, 0, len(hosts))
+	levels := make([]*routeLevel, 0)
+	for _, host := range hosts {
+		if len(allowed) > 0 && !allowed[host] {
+			continue
+		}
+		c.connMtx.RLock()
+		dc, found := c.conns[host]
+		c.connMtx.RUnlock()
+		if !found || !dc.acct.authed() || dc.suspended(marketName(form.Base, form.Quote)) {
+			continue
+		}
+		base, quote := dc.asset(form.Base), dc.asset(form.Quote)
+		if base == nil || quote == nil || base.LotSize == 0 {
+			continue
+		}
+		fromAsset := quote
+		if form.Sell {
+			fromAsset = base
+		}
+		venue := &routeVenue{
+			host:    host,
+			lotSize: base.LotSize,
+			lotFee:  fromAsset.SwapSize * fromAsset.MaxFeeRate,
+		}
+		venues = append(venues, venue)
+
+		buys, sells, _ := books[host].Orders()
+		// A sell order matches the buy side of the book.
+		side := sells
+		if form.Sell {
+			side = buys
+		}
+		for _, ord := range side {
+			if form.Rate > 0 {
+				if form.Sell && ord.Rate < form.Rate || !form.Sell && ord.Rate > form.Rate {
+					continue
+				}
+			}
+			levels = append(levels, &routeLevel{
+				venue:   venue,
+				qty:     ord.Quantity,
+				rate:    ord.Rate,
+				effRate: effectiveRate(ord.Rate, venue.lotSize, venue.lotFee, form.Sell),
+			})
+		}
+	}
+
+	// The stable sort keeps each venue's time priority, and breaks ties between
+	// venues by host name.
+	sort.SliceStable(levels, func(i, j int) bool {
+		if form.Sell {
+			return levels[i].effRate > levels[j].effRate
+		}
+		return levels[i].effRate < levels[j].effRate
+	})
+	return venues, levels
+}
+
+// PlanRoute splits an order across the DEX servers that host the market pair.
+// The book orders of every server with a synced book are taken best first,
+// after adjusting for each server's swap fees, and each server's share is a
+// multiple of its lot size. If the form has a rate and is not an immediate
+// order, the quantity that the books cannot fill is booked at the rate on the
+// server with the best fill. Any quantity that cannot be assigned to a server
+// is reported as Unrouted. Sync must be called for each DEX that should be
+// included.
+func (c *Core) PlanRoute(form *RouteForm) (*RoutePlan, error) {
+	if form.Qty == 0 {
+		return nil, fmt.Errorf("zero quantity")
+	}
+	if form.Rate == 0 && !form.TifNow {
+		return nil, fmt.Errorf("a routed order without a rate must be immediate")
+	}
+	venues, levels := c.routeVenues(form)
+	if len(venues) == 0 {
+		return nil, fmt.Errorf("no synced order books for market %s", marketName(form.Base, form.Quote))
+	}
+
+	legs := make(map[string]*RouteLeg)
+	quoteQtys := make(map[string]uint64)
+	hosts := make([]string, 0, len(venues))
+	remaining := form.Qty
+	addLeg := func(venue *routeVenue, qty, rate uint64) {
+		leg, found := legs[venue.host]
+		if !found {
+			leg = &RouteLeg{
+				Host:    venue.host,
+				LotSize: venue.lotSize,
+			}
+			legs[venue.host] = leg
+			hosts = append(hosts, venue.host)
+		}
+		quoteQtys[venue.host] += calc.BaseToQuote(rate, qty)
+		leg.Qty += qty
+		leg.Fees += qty / venue.lotSize * venue.lotFee
+		// Without a rate, a leg's limit rate is its worst book rate.
+		if form.Rate == 0 && (leg.Rate == 0 || form.Sell && rate < leg.Rate || !form.Sell && rate > leg.Rate) {
+			leg.Rate = rate
+		}
+		remaining -= qty
+	}
+
+	for _, level := range levels {
+		lots := remaining / level.venue.lotSize
+		if lots == 0 {
+			continue
+		}
+		qty := level.qty
+		if qty > lots*level.venue.lotSize {
+			qty = lots * level.venue.lotSize
+		}
+		addLeg(level.venue, qty, level.rate)
+		if remaining == 0 {
+			break
+		}
+	}
+
+	// Book the rest on the venue with the best fill, or on the first venue if
+	// nothing fills.
+	if remaining > 0 && form.Rate > 0 && !form.TifNow {
+		venue := venues[0]
+		if len(hosts) > 0 {
+			for _, v := range venues {
+				if v.host == hosts[0] {
+					venue = v
+				}
+			}
+		}
+		if qty := remaining / venue.lotSize * venue.lotSize; qty > 0 {
+			addLeg(venue, qty, form.Rate)
+		}
+	}
+
+	plan := &RoutePlan{
+		Sell:     form.Sell,
+		BaseID:   form.Base,
+		QuoteID:  form.Quote,
+		Qty:      form.Qty,
+		Legs:     make([]*RouteLeg, 0, len(hosts)),
+		Unrouted: remaining,
+	}
+	var quoteQty uint64
+	for _, host := range hosts {
+		leg := legs[host]
+		if form.Rate > 0 {
+			leg.Rate = form.Rate
+		}
+		leg.AvgRate = avgRate(leg.Qty, quoteQtys[host])
+		plan.Legs = append(plan.Legs, leg)
+		quoteQty += quoteQtys[host]
+	}
+	plan.AvgRate = avgRate(form.Qty-remaining, quoteQty)
+	return plan, nil
+}
+
+// RouteTrade plans a route for the order and places an order on each DEX
+// server in the plan. The legs are placed as limit orders at the leg's rate. A
+// leg that cannot be placed is reported in the fill's Errors, and an error is
+// returned only if no leg could be placed.
+func (c *Core) RouteTrade(pw []byte, form *RouteForm) (*RouteFill, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("RouteTrade password error: %v", err)
+	}
+	plan, err := c.PlanRoute(form)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Legs) == 0 {
+		return nil, fmt.Errorf("no liquidity to route the order")
+	}
+
+	orders := make([]*Order, 0, len(plan.Legs))
+	errs := make(map[string]string)
+	for _, leg := range plan.Legs {
+		corder, err := c.trade(crypter, &TradeForm{
+			Host:        leg.Host,
+			IsLimit:     true,
+			Sell:        form.Sell,
+			Base:        form.Base,
+			Quote:       form.Quote,
+			Qty:         leg.Qty,
+			Rate:        leg.Rate,
+			TifNow:      form.TifNow,
+			BaseWallet:  form.BaseWallet,
+			QuoteWallet: form.QuoteWallet,
+		})
+		if err != nil {
+			log.Errorf("error placing routed order on %s: %v", leg.Host, err)
+			errs[leg.Host] = err.Error()
+			continue
+		}
+		orders = append(orders, corder)
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("no routed orders placed: %v", errs)
+	}
+	fill := routeFill(orders)
+	if len(errs) > 0 {
+		fill.Errors = errs
+	}
+	return fill, nil
+}
+
+// RouteStatus reports the consolidated fill of the orders that were placed for
+// a routed order.
+func (c *Core) RouteStatus(orderIDs []string) (*RouteFill, error) {
+	orders := make([]*Order, 0, len(orderIDs))
+	for _, oid := range orderIDs {
+		corder, err := c.Order(oid)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, corder)
+	}
+	return routeFill(orders), nil
+}
+
+// routeFill sums the quantities and fills of the orders.
+func routeFill(orders []*Order) *RouteFill {
+	fill := &RouteFill{
+		Orders:   orders,
+		Complete: true,
+	}
+	var quoteQty uint64
+	for _, corder := range orders {
+		fill.Qty += corder.Qty
+		fill.Filled += corder.Filled
+		for _, match := range corder.Matches {
+			quoteQty += calc.BaseToQuote(match.Rate, match.Qty)
+		}
+		if corder.Status < order.OrderStatusExecuted {
+			fill.Complete = false
+		}
+	}
+	fill.AvgRate = avgRate(fill.Filled, quoteQty)
+	return fill
+}
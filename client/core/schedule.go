@@ -0,0 +1,295 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+)
+
+// schedInterval is how often the scheduled orders are checked.
+var schedInterval = 5 * time.Second
+
+// ScheduleForm is a TradeForm with the conditions for placing the order. At
+// least one condition must be set, and the order is placed once all of the
+// set conditions are met. Time is a UNIX timestamp in milliseconds before
+// which the order will not be placed. If TriggerRate is set, the order is
+// placed once the market rate is at or above TriggerRate if Above is true, or
+// at or below TriggerRate if Above is false. The DEX does not report trade
+// prices, so the market rate is the mid-gap rate of the order book.
+type ScheduleForm struct {
+	TradeForm
+	Time        uint64 `json:"time"`
+	TriggerRate uint64 `json:"triggerrate"`
+	Above       bool   `json:"above"`
+}
+
+// ScheduledOrder is an order that will be placed when its conditions are met.
+type ScheduledOrder struct {
+	ScheduleForm
+	ID    string `json:"id"`
+	Stamp uint64 `json:"stamp"`
+}
+
+// ScheduleOrder validates the order and stores it to be placed by the client
+// when its conditions are met. Scheduled orders survive restarts, but are only
+// placed while the user is logged in.
+func (c *Core) ScheduleOrder(pw []byte, form *ScheduleForm) (*ScheduledOrder, error) {
+	// Check the user password.
+	_, err := c.encryptionKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("ScheduleOrder password error: %v", err)
+	}
+	host := form.acctHost()
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", form.Host)
+	}
+	if dc.market(marketName(form.Base, form.Quote)) == nil {
+		return nil, fmt.Errorf("order scheduled for unknown market")
+	}
+
+	now := encode.UnixMilliU(time.Now())
+	switch {
+	case form.Time == 0 && form.TriggerRate == 0:
+		return nil, fmt.Errorf("no time or price condition")
+	case form.Time != 0 && form.Time <= now:
+		return nil, fmt.Errorf("scheduled time is in the past")
+	case form.Qty == 0:
+		return nil, fmt.Errorf("zero quantity not allowed")
+	case form.IsLimit && form.Rate == 0:
+		return nil, fmt.Errorf("zero-rate order not allowed")
+	case form.BaseWallet != "" || form.QuoteWallet != "":
+		return nil, fmt.Errorf("scheduled orders use the assets' default wallets")
+	}
+	wallets, err := c.walletSet(dc, form.Base, form.Quote, form.Sell)
+	if err != nil {
+		return nil, err
+	}
+	// The quantity of a market buy order is in units of the quote asset.
+	if (form.IsLimit || form.Sell) && form.Qty%wallets.baseAsset.LotSize != 0 {
+		return nil, fmt.Errorf("quantity %d is not a multiple of the lot size %d",
+			form.Qty, wallets.baseAsset.LotSize)
+	}
+
+	dbSched := &db.ScheduledOrder{
+		Host:        host,
+		IsLimit:     form.IsLimit,
+		Sell:        form.Sell,
+		Base:        form.Base,
+		Quote:       form.Quote,
+		Qty:         form.Qty,
+		Rate:        form.Rate,
+		TifNow:      form.TifNow,
+		Time:        form.Time,
+		TriggerRate: form.TriggerRate,
+		Above:       form.Above,
+		Stamp:       now,
+	}
+	err = c.db.SaveScheduledOrder(dbSched)
+	if err != nil {
+		return nil, fmt.Errorf("error saving scheduled order: %v", err)
+	}
+	return scheduledOrder(dbSched), nil
+}
+
+// ScheduledOrders lists the orders that have not yet been placed, oldest
+// first.
+func (c *Core) ScheduledOrders() ([]*ScheduledOrder, error) {
+	dbScheds, err := c.db.ScheduledOrders()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving scheduled orders: %v", err)
+	}
+	scheds := make([]*ScheduledOrder, 0, len(dbScheds))
+	for _, dbSched := range dbScheds {
+		scheds = append(scheds, scheduledOrder(dbSched))
+	}
+	sort.Slice(scheds, func(i, j int) bool {
+		return scheds[i].Stamp < scheds[j].Stamp
+	})
+	return scheds, nil
+}
+
+// CancelScheduledOrder removes a scheduled order that has not yet been placed.
+func (c *Core) CancelScheduledOrder(id string) error {
+	idB, err := hex.DecodeString(id)
+	if err != nil {
+		return fmt.Errorf("invalid scheduled order ID %q: %v", id, err)
+	}
+	c.schedMtx.Lock()
+	defer c.schedMtx.Unlock()
+	return c.db.DeleteScheduledOrder(idB)
+}
+
+// scheduledOrder converts the *db.ScheduledOrder to a *ScheduledOrder.
+func scheduledOrder(dbSched *db.ScheduledOrder) *ScheduledOrder {
+	return &ScheduledOrder{
+		ScheduleForm: ScheduleForm{
+			TradeForm: TradeForm{
+				Host:    dbSched.Host,
+				IsLimit: dbSched.IsLimit,
+				Sell:    dbSched.Sell,
+				Base:    dbSched.Base,
+				Quote:   dbSched.Quote,
+				Qty:     dbSched.Qty,
+				Rate:    dbSched.Rate,
+				TifNow:  dbSched.TifNow,
+			},
+			Time:        dbSched.Time,
+			TriggerRate: dbSched.TriggerRate,
+			Above:       dbSched.Above,
+		},
+		ID:    dbSched.ID().String(),
+		Stamp: dbSched.Stamp,
+	}
+}
+
+// runScheduler checks the scheduled orders every schedInterval until the
+// context is canceled.
+func (c *Core) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkScheduledOrders(ctx)
+		case <-ctx.Done():
+			c.schedMtx.Lock()
+			closeBookFeeds(c.schedFeeds, nil)
+			c.schedMtx.Unlock()
+			return
+		}
+	}
+}
+
+// checkScheduledOrders places any scheduled orders whose conditions are met.
+// The order books are synced for markets with price-triggered orders. An order
+// is not placed until the account is logged in and both wallets are unlocked.
+// A scheduled order that fails to place is removed, and the user is notified.
+func (c *Core) checkScheduledOrders(ctx context.Context) {
+	c.schedMtx.Lock()
+	defer c.schedMtx.Unlock()
+	scheds, err := c.db.ScheduledOrders()
+	if err != nil {
+		log.Errorf("error retrieving scheduled orders: %v", err)
+		return
+	}
+
+	now := encode.UnixMilliU(time.Now())
+	feeds := make(map[string]bool)
+	for _, sched := range scheds {
+		c.connMtx.RLock()
+		dc, found := c.conns[sched.Host]
+		c.connMtx.RUnlock()
+		if !found || !dc.acct.authed() {
+			continue
+		}
+		if sched.TriggerRate > 0 {
+			feeds[c.syncBookFeed(ctx, c.schedFeeds, sched.Host, sched.Base, sched.Quote)] = true
+		}
+		if !c.schedTriggered(dc, sched, now) {
+			continue
+		}
+		wallets, err := c.walletSet(dc, sched.Base, sched.Quote, sched.Sell)
+		if err == nil && (!wallets.fromWallet.unlocked() || !wallets.toWallet.unlocked()) {
+			log.Tracef("scheduled order %s waiting for unlocked wallets", sched.ID())
+			continue
+		}
+
+		corder, err := c.trade(nil, &scheduledOrder(sched).TradeForm)
+		if delErr := c.db.DeleteScheduledOrder(sched.ID()); delErr != nil {
+			log.Errorf("error deleting scheduled order %s: %v", sched.ID(), delErr)
+		}
+		if err != nil {
+			c.notify(newOrderNote(TopicScheduledOrderFailed, db.ErrorLevel, nil,
+				side{sell: sched.Sell}, float64(sched.Qty)/conversionFactor, unbip(sched.Base), sched.Host, err))
+			continue
+		}
+		c.notify(newOrderNote(TopicScheduledOrderPlaced, db.Success, corder, corder.ID, sched.Host))
+	}
+
+	// Stop syncing the books that are no longer needed.
+	closeBookFeeds(c.schedFeeds, feeds)
+}
+
+// schedTriggered checks whether the scheduled order's conditions are met.
+func (c *Core) schedTriggered(dc *dexConnection, sched *db.ScheduledOrder, now uint64) bool {
+	if sched.Time > now {
+		return false
+	}
+	if sched.TriggerRate == 0 {
+		return true
+	}
+	rate, err := dc.midGap(sched.Base, sched.Quote)
+	if err != nil {
+		return false
+	}
+	if sched.Above {
+		return rate >= sched.TriggerRate
+	}
+	return rate <= sched.TriggerRate
+}
+
+// midGap is the mid-gap rate of the market's synced order book.
+func (dc *dexConnection) midGap(base, quote uint32) (uint64, error) {
+	mkt := marketName(base, quote)
+	dc.booksMtx.RLock()
+	book, found := dc.books[mkt]
+	dc.booksMtx.RUnlock()
+	if !found {
+		return 0, fmt.Errorf("no synced book for market %s", mkt)
+	}
+	return book.MidGap()
+}
+
+// syncBookFeed subscribes to the market's order book if there is not already a
+// feed for it in feeds, and returns the key for the feed. The feed's updates
+// are discarded, since only the book itself is needed. The caller is
+// responsible for synchronizing access to feeds.
+func (c *Core) syncBookFeed(ctx context.Context, feeds map[string]*BookFeed, host string, base, quote uint32) string {
+	key := host + "|" + marketName(base, quote)
+	if _, found := feeds[key]; found {
+		return key
+	}
+	_, feed, err := c.Sync(host, base, quote)
+	if err != nil {
+		log.Errorf("error syncing %s order book: %v", key, err)
+		return key
+	}
+	feeds[key] = feed
+	go discardBookUpdates(ctx, feed)
+	return key
+}
+
+// discardBookUpdates empties the feed's channel until the feed is closed or
+// the context is canceled.
+func discardBookUpdates(ctx context.Context, feed *BookFeed) {
+	for {
+		select {
+		case <-feed.C:
+		case <-feed.off:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeBookFeeds closes and removes the feeds whose keys are not in keep.
+func closeBookFeeds(feeds map[string]*BookFeed, keep map[string]bool) {
+	for key, feed := range feeds {
+		if !keep[key] {
+			feed.Close()
+			delete(feeds, key)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/encrypt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+)
+
+const (
+	// appSeedKey is the database key of the encrypted application seed.
+	appSeedKey = "appSeed"
+	// appSeedSize is the size of the application seed, in bytes.
+	appSeedSize = 32
+	// builtInWalletKey is the wallet setting that selects the built-in SPV
+	// wallet of an asset. The keys of a built-in wallet are derived from the
+	// application seed.
+	builtInWalletKey = "spv"
+	// electrumWalletKey is the wallet setting with the Electrum server of a
+	// built-in wallet that syncs with an Electrum server instead of with P2P
+	// peers. Its keys are also derived from the application seed.
+	electrumWalletKey = "electrum"
+)
+
+// storeAppSeed encrypts and stores the application seed.
+func (c *Core) storeAppSeed(crypter encrypt.Crypter, seed []byte) error {
+	encSeed, err := crypter.Encrypt(seed)
+	if err != nil {
+		return fmt.Errorf("error encrypting application seed: %v", err)
+	}
+	if err := c.db.Store(appSeedKey, encSeed); err != nil {
+		return fmt.Errorf("error storing application seed: %v", err)
+	}
+	return nil
+}
+
+// appSeed retrieves and decrypts the application seed. A client initialized
+// before the application seed was introduced is given a new seed, which does
+// not derive the keys of the client's existing DEX accounts. See
+// unrestorableAccounts.
+func (c *Core) appSeed(crypter encrypt.Crypter) ([]byte, error) {
+	exists, err := c.db.ValueExists(appSeedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error checking for application seed: %v", err)
+	}
+	if !exists {
+		seed := encode.RandomBytes(appSeedSize)
+		if err := c.storeAppSeed(crypter, seed); err != nil {
+			return nil, err
+		}
+		log.Infof("Created a new application seed")
+		return seed, nil
+	}
+	encSeed, err := c.db.Get(appSeedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving application seed: %v", err)
+	}
+	seed, err := crypter.Decrypt(encSeed)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting application seed: %v", err)
+	}
+	return seed, nil
+}
+
+// ExportSeed returns the application seed as a list of words, which can be
+// used with RestoreFromSeed to recover the client's DEX account keys and the
+// keys of its built-in wallets. A client that registered with a DEX before the
+// application seed was introduced has account keys that were not derived from
+// the seed, so RestoreFromSeed could not recover them. The export is refused
+// for such a client, which must be backed up with ExportBackup instead.
+func (c *Core) ExportSeed(pw []byte) (string, error) {
+	crypter, err := c.encryptionKey(pw)
+	if err != nil {
+		return "", fmt.Errorf("ExportSeed password error: %v", err)
+	}
+	defer crypter.Close()
+	seed, err := c.appSeed(crypter)
+	if err != nil {
+		return "", err
+	}
+	defer encode.ClearBytes(seed)
+	hosts, err := c.unrestorableAccounts(crypter, seed)
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) > 0 {
+		return "", fmt.Errorf("the application seed cannot restore the accounts at %s, "+
+			"which were created before the seed. Use a backup export instead",
+			strings.Join(hosts, ", "))
+	}
+	return encode.SeedToWords(seed), nil
+}
+
+// unrestorableAccounts lists the hosts of the DEX accounts with private keys
+// that are not derived from the application seed.
+func (c *Core) unrestorableAccounts(crypter encrypt.Crypter, seed []byte) ([]string, error) {
+	accts, err := c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving accounts: %v", err)
+	}
+	var hosts []string
+	for _, acct := range accts {
+		if len(acct.EncKey) == 0 {
+			continue
+		}
+		keyB, err := crypter.Decrypt(acct.EncKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting %s account key: %v", acct.Host, err)
+		}
+		if !bytes.Equal(keyB, accountKey(seed, acct.Host).Serialize()) {
+			hosts = append(hosts, acct.Host)
+		}
+		encode.ClearBytes(keyB)
+	}
+	return hosts, nil
+}
+
+// RestoreFromSeed initializes the client with the application password and the
+// application seed words from ExportSeed. Built-in wallets created after the
+// restore have the same keys as those created by the original client. The
+// client must not already be initialized.
+func (c *Core) RestoreFromSeed(pw []byte, words string) error {
+	seed, err := encode.WordsToSeed(words)
+	if err != nil {
+		return fmt.Errorf("invalid seed: %v", err)
+	}
+	defer encode.ClearBytes(seed)
+	if len(seed) != appSeedSize {
+		return fmt.Errorf("invalid seed length %d", len(seed))
+	}
+	return c.initializeClient(pw, seed)
+}
+
+// deriveSeedKey derives a 32-byte key from the application seed. Keys for
+// different purposes are derived with different labels.
+func deriveSeedKey(seed []byte, label string) []byte {
+	mac := hmac.New(sha512.New, seed)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)[:32]
+}
+
+// accountKey derives the account private key for the DEX host.
+func accountKey(seed []byte, host string) *secp256k1.PrivateKey {
+	privKey, _ := secp256k1.PrivKeyFromBytes(deriveSeedKey(seed, "dex account "+host))
+	return privKey
+}
+
+// walletSeed derives the HD seed of the asset's built-in wallet.
+func walletSeed(seed []byte, assetID uint32) []byte {
+	return deriveSeedKey(seed, "wallet "+strconv.FormatUint(uint64(assetID), 10))
+}
+
+// builtInWallet is true if the wallet settings select the asset's built-in
+// wallet, either in SPV mode or synced with an Electrum server.
+func builtInWallet(settings map[string]string) bool {
+	if settings[electrumWalletKey] != "" {
+		return true
+	}
+	on, _ := strconv.ParseBool(settings[builtInWalletKey])
+	return on
+}
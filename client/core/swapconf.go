@@ -0,0 +1,107 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// swapConfsKey is the app-level db key for the user's swap confirmation
+// settings.
+const swapConfsKey = "swapConfs"
+
+// SwapConfs returns the user's swap confirmation settings, the number of
+// confirmations required on a counterparty's swap contract before acting on
+// it, keyed by asset ID. Assets without a setting use each DEX server's swap
+// confirmation requirement.
+func (c *Core) SwapConfs() map[uint32]uint32 {
+	c.swapConfMtx.RLock()
+	defer c.swapConfMtx.RUnlock()
+	settings := make(map[uint32]uint32, len(c.swapConfs))
+	for assetID, confs := range c.swapConfs {
+		settings[assetID] = confs
+	}
+	return settings
+}
+
+// SetSwapConfs sets the number of confirmations required on a counterparty's
+// swap contract for the asset before the user's swap or redemption is
+// broadcast, and saves the setting to the database. The setting cannot be
+// lower than the swap confirmation requirement of any connected DEX server
+// that supports the asset, and a DEX server that later raises its requirement
+// takes precedence. Zero restores the default of the DEX server's
+// requirement.
+func (c *Core) SetSwapConfs(assetID uint32, confs uint32) error {
+	if confs > 0 {
+		if minConfs := c.minSwapConfs(assetID); confs < minConfs {
+			return fmt.Errorf("%d confirmations is below the DEX minimum of %d for %s",
+				confs, minConfs, unbip(assetID))
+		}
+	}
+
+	c.swapConfMtx.Lock()
+	defer c.swapConfMtx.Unlock()
+	settings := make(map[uint32]uint32, len(c.swapConfs)+1)
+	for k, v := range c.swapConfs {
+		settings[k] = v
+	}
+	if confs == 0 {
+		delete(settings, assetID)
+	} else {
+		settings[assetID] = confs
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error encoding swap confirmation settings: %v", err)
+	}
+	if err = c.db.Store(swapConfsKey, b); err != nil {
+		return fmt.Errorf("error saving swap confirmation settings: %v", err)
+	}
+	c.swapConfs = settings
+	return nil
+}
+
+// minSwapConfs is the highest swap confirmation requirement for the asset of
+// the connected DEX servers.
+func (c *Core) minSwapConfs(assetID uint32) uint32 {
+	var minConfs uint32
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	for _, dc := range c.conns {
+		if a := dc.asset(assetID); a != nil && a.SwapConf > minConfs {
+			minConfs = a.SwapConf
+		}
+	}
+	return minConfs
+}
+
+// swapConfsSetting is the user's swap confirmation setting for the asset, or
+// zero if there is none.
+func (c *Core) swapConfsSetting(assetID uint32) uint32 {
+	c.swapConfMtx.RLock()
+	defer c.swapConfMtx.RUnlock()
+	return c.swapConfs[assetID]
+}
+
+// loadSwapConfs loads the user's swap confirmation settings from the database.
+func (c *Core) loadSwapConfs() {
+	exists, err := c.db.ValueExists(swapConfsKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(swapConfsKey)
+	if err != nil {
+		log.Errorf("error loading swap confirmation settings: %v", err)
+		return
+	}
+	settings := make(map[uint32]uint32)
+	if err = json.Unmarshal(b, &settings); err != nil {
+		log.Errorf("error decoding swap confirmation settings: %v", err)
+		return
+	}
+	c.swapConfMtx.Lock()
+	c.swapConfs = settings
+	c.swapConfMtx.Unlock()
+}
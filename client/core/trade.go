@@ -7,8 +7,8 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +26,10 @@ import (
 // waiter. This could be thought of as the maximum allowable backend latency.
 var txWaitExpiration = time.Minute
 
+// redeemBumpDelay is how long our redemption may remain unconfirmed before its
+// fee is bumped, and the minimum time between subsequent bumps.
+var redeemBumpDelay = 30 * time.Minute
+
 // ExpirationErr indicates that the wait.TickerQueue has expired a waiter, e.g.
 // a reported coin was not found before txWaitExpiration.
 type ExpirationErr string
@@ -42,6 +46,20 @@ type matchTracker struct {
 	trade       *order.Trade
 	counterSwap asset.AuditInfo
 	id          order.MatchID
+	// redeemCheck is the time that our redemption was sent or its fee was last
+	// bumped. redeemConfirmed is set once the redemption has a confirmation.
+	redeemCheck     time.Time
+	redeemConfirmed bool
+	// refundSent is set when our refund is broadcast in this session, and
+	// refundConfirmed once the refund has a confirmation. refundNotified is
+	// set once the user is notified that a refund must be done manually.
+	refundSent      bool
+	refundConfirmed bool
+	refundNotified  bool
+	// swapConfirmed is the time that the most recent swap was first seen with
+	// the DEX's required confirmations, which starts the broadcast timeout for
+	// the next swap step. It is reset when the status changes.
+	swapConfirmed time.Time
 }
 
 // The status is part of both the UserMatch and the MatchMetaData used to
@@ -49,6 +67,7 @@ type matchTracker struct {
 func (match *matchTracker) setStatus(status order.MatchStatus) {
 	match.Match.Status = status
 	match.MetaData.Status = status
+	match.swapConfirmed = time.Time{}
 }
 
 // parts is a getter for pointers to commonly used struct fields in the
@@ -92,6 +111,19 @@ type trackedTrade struct {
 	matches       map[order.MatchID]*matchTracker
 	notify        func(Notification)
 	epochLen      uint64
+	// swapConfs returns the user's swap confirmation setting for an asset, or
+	// zero if there is none. swapConfs may be nil, in which case the DEX
+	// server's swap confirmation requirement is used.
+	swapConfs func(assetID uint32) uint32
+	// autoRefund returns whether expired swaps should be refunded
+	// automatically. autoRefund may be nil, in which case they are.
+	autoRefund func() bool
+	// coinsReturned is set once the funding coins are returned to the wallet
+	// after the order finished without using them.
+	coinsReturned bool
+	// lotSizeIssue is set if the order is invalid or uneconomical after a
+	// lot size or rate step change. See checkLotSizes.
+	lotSizeIssue string
 }
 
 // newTrackedTrade is a constructor for a trackedTrade.
@@ -148,34 +180,43 @@ func (t *trackedTrade) coreOrderInternal() (*Order, *Order) {
 
 	orderEpoch := t.dc.marketEpoch(t.mktID, prefix.ServerTime)
 	var tif order.TimeInForce
+	var visible uint64
 	if lo, ok := t.Order.(*order.LimitOrder); ok {
-		tif = lo.Force
+		tif, visible = lo.Force, lo.Visible
 	}
 	corder := &Order{
-		Host:        t.dc.acct.host,
-		MarketID:    t.mktID,
-		Type:        prefix.OrderType,
-		ID:          t.ID().String(),
-		Stamp:       encode.UnixMilliU(prefix.ServerTime),
-		Sig:         t.metaData.Proof.DEXSig,
-		Status:      t.metaData.Status,
-		Epoch:       orderEpoch,
-		Rate:        t.rate(),
-		Qty:         trade.Quantity,
-		Sell:        trade.Sell,
-		Filled:      trade.Filled(),
-		Cancelling:  cancelling,
-		Canceled:    canceled,
-		TimeInForce: tif,
+		Host:         t.dc.acct.host,
+		MarketID:     t.mktID,
+		Type:         prefix.OrderType,
+		ID:           t.ID().String(),
+		Stamp:        encode.UnixMilliU(prefix.ServerTime),
+		Sig:          t.metaData.Proof.DEXSig,
+		Status:       t.metaData.Status,
+		Epoch:        orderEpoch,
+		Rate:         t.rate(),
+		Qty:          trade.Quantity,
+		Sell:         trade.Sell,
+		Filled:       trade.Filled(),
+		Cancelling:   cancelling,
+		Canceled:     canceled,
+		TimeInForce:  tif,
+		Visible:      visible,
+		LotSizeIssue: t.lotSizeIssue,
+		Notes:        t.metaData.Notes,
+		Tags:         t.metaData.Tags,
 	}
 	for _, match := range t.matches {
 		dbMatch := match.Match
 		corder.Matches = append(corder.Matches, &Match{
-			MatchID: match.id.String(),
-			Status:  dbMatch.Status,
-			Rate:    dbMatch.Rate,
-			Qty:     dbMatch.Quantity,
-			Side:    dbMatch.Side,
+			MatchID:   match.id.String(),
+			Status:    dbMatch.Status,
+			Rate:      dbMatch.Rate,
+			Qty:       dbMatch.Quantity,
+			Side:      dbMatch.Side,
+			Stamp:     match.MetaData.Proof.Auth.MatchStamp,
+			Deadlines: t.matchDeadlines(match),
+			Notes:     match.MetaData.Notes,
+			Tags:      match.MetaData.Tags,
 		})
 	}
 	var cancelOrder *Order
@@ -209,8 +250,9 @@ func (t *trackedTrade) cancelTrade(co *order.CancelOrder, preImg order.Preimage)
 }
 
 // readConnectMatches resolves the matches reported by the server in the
-// 'connect' response against those in the match tracker map.
-func (t *trackedTrade) readConnectMatches(msgMatches []*msgjson.Match) {
+// 'connect' response against those in the match tracker map. The reported
+// matches that are not in the match tracker map are returned.
+func (t *trackedTrade) readConnectMatches(msgMatches []*msgjson.Match) []*msgjson.Match {
 	ids := make(map[order.MatchID]bool)
 	var extras []*msgjson.Match
 	var missing []order.MatchID
@@ -236,21 +278,19 @@ func (t *trackedTrade) readConnectMatches(msgMatches []*msgjson.Match) {
 
 	host := t.dc.acct.host
 	if len(missing) > 0 {
-		details := fmt.Sprintf("%d matches for order %s were not reported by %q and are in a failed state",
-			len(missing), t.ID(), host)
 		corder, _ := t.coreOrder()
-		t.notify(newOrderNote("Missing matches", details, db.ErrorLevel, corder))
+		t.notify(newOrderNote(TopicMissingMatches, db.ErrorLevel, corder, len(missing), t.ID(), host))
 		for _, mid := range missing {
 			log.Errorf("%s did not report active match %s on order %s", host, mid, t.ID())
 		}
 	}
 	if len(extras) > 0 {
-		details := fmt.Sprintf("%d matches reported by %s were not found for %s.", len(extras), host, t.token())
-		t.notify(newOrderNote("Match resolution error", details, db.ErrorLevel, corder))
+		t.notify(newOrderNote(TopicMatchResolutionError, db.ErrorLevel, corder, len(extras), host, t.token()))
 		for _, extra := range extras {
 			log.Errorf("%s reported match %s which is not a known active match for order %s", host, extra.MatchID, extra.OrderID)
 		}
 	}
+	return extras
 }
 
 // negotiate creates and stores matchTrackers for the []*msgjson.Match, and
@@ -374,23 +414,21 @@ func (t *trackedTrade) negotiate(msgMatches []*msgjson.Match) error {
 
 	// Send notifications.
 	if includesCancellation {
-		details := fmt.Sprintf("%s order on %s-%s at %s has been canceled (%s)",
-			strings.Title(sellString(trade.Sell)), unbip(t.Base()), unbip(t.Quote()), t.dc.acct.host, t.token())
-		t.notify(newOrderNote("Order canceled", details, db.Success, corder))
+		t.notify(newOrderNote(TopicOrderCanceled, db.Success, corder, side{trade.Sell, true},
+			unbip(t.Base()), unbip(t.Quote()), t.dc.acct.host, t.token()))
 		// Also send out a data notification with the cancel order information.
-		t.notify(newOrderNote("cancel", "", db.Data, cancelOrder))
+		t.notify(newOrderNote(TopicOrderCanceledData, db.Data, cancelOrder))
 		// Set the order status for both orders.
 		t.metaData.Status = order.OrderStatusCanceled
 		t.db.UpdateOrderStatus(t.cancel.ID(), order.OrderStatusExecuted)
-		// TODO: If the order's backing coins/change are unused, unlock them,
-		// otherwise they have been or will be spent by a swap.
+		// If the order's funding coins are unused, they are returned to the
+		// wallet by tick, otherwise they have been or will be spent by a swap.
 	}
 	if includesTrades {
 		fillRatio := float64(trade.Filled()) / float64(trade.Quantity)
-		details := fmt.Sprintf("%s order on %s-%s %.1f%% filled (%s)",
-			strings.Title(sellString(trade.Sell)), unbip(t.Base()), unbip(t.Quote()), fillRatio*100, t.token())
 		log.Debugf("trade order %v matched with %d orders", t.ID(), len(msgMatches))
-		t.notify(newOrderNote("Matches made", details, db.Poke, corder))
+		t.notify(newOrderNote(TopicMatchesMade, db.Poke, corder, side{trade.Sell, true},
+			unbip(t.Base()), unbip(t.Quote()), fillRatio*100, t.token()))
 	}
 	_, err = t.tick()
 	return err
@@ -418,6 +456,102 @@ func (t *trackedTrade) processCancelMatch(msgMatch *msgjson.Match) error {
 	return nil
 }
 
+// matchDeadlines computes the deadlines of the match's current swap step from
+// the DEX's broadcast timeout and the swap lock times. The deadlines are nil if
+// the match is complete or revoked. matchDeadlines should be called with the
+// matchMtx locked.
+func (t *trackedTrade) matchDeadlines(match *matchTracker) *MatchDeadlines {
+	dbMatch, _, proof, auth := match.parts()
+	if dbMatch.Status == order.MatchComplete || proof.IsRevoked || match.failErr != nil {
+		return nil
+	}
+
+	bTimeout := time.Duration(t.dc.cfg.BroadcastTimeout) * time.Millisecond
+	actBy := func(start time.Time) uint64 {
+		if start.IsZero() {
+			return 0
+		}
+		return encode.UnixMilliU(start.Add(bTimeout))
+	}
+
+	dl := new(MatchDeadlines)
+	actor := order.Maker
+	switch dbMatch.Status {
+	case order.NewlyMatched:
+		dl.Step = "maker swap"
+		dl.ActBy = actBy(encode.UnixTimeMilli(int64(auth.MatchStamp)))
+	case order.MakerSwapCast:
+		dl.Step, actor = "taker swap", order.Taker
+		dl.ActBy = actBy(match.swapConfirmed)
+	case order.TakerSwapCast:
+		dl.Step = "maker redeem"
+		dl.ActBy = actBy(match.swapConfirmed)
+	case order.MakerRedeemed:
+		dl.Step, actor = "taker redeem", order.Taker
+		// The server starts the taker's timeout when it relays the maker's
+		// redemption.
+		stamp := auth.RedemptionStamp
+		if dbMatch.Side == order.Maker {
+			stamp = auth.RedeemStamp
+		}
+		if stamp > 0 {
+			dl.ActBy = actBy(encode.UnixTimeMilli(int64(stamp)))
+		}
+	}
+	dl.OurStep = dbMatch.Side == actor
+
+	if len(proof.Script) > 0 {
+		lockTime := t.lockTimeTaker
+		if dbMatch.Side == order.Maker {
+			lockTime = t.lockTimeMaker
+		}
+		dl.RefundAt = encode.UnixMilliU(encode.UnixTimeMilli(int64(auth.MatchStamp)).Add(lockTime))
+	}
+	if match.counterSwap != nil {
+		dl.CounterRefundAt = encode.UnixMilliU(match.counterSwap.Expiration())
+	}
+	return dl
+}
+
+// checkSwapConfirmed records the time that the most recent swap reached the
+// DEX's required confirmations, starting the broadcast timeout of the next
+// swap step. checkSwapConfirmed should be called with the matchMtx locked.
+func (t *trackedTrade) checkSwapConfirmed(match *matchTracker) {
+	dbMatch, metaData, proof, _ := match.parts()
+	if !match.swapConfirmed.IsZero() || proof.IsRevoked {
+		return
+	}
+
+	var confs uint32
+	var err error
+	var swapAsset *dex.Asset
+	switch {
+	case metaData.Status != order.MakerSwapCast && metaData.Status != order.TakerSwapCast:
+		return
+	case (dbMatch.Side == order.Maker) == (metaData.Status == order.MakerSwapCast):
+		// Our swap is the latest.
+		coinID := proof.MakerSwap
+		if dbMatch.Side == order.Taker {
+			coinID = proof.TakerSwap
+		}
+		swapAsset = t.wallets.fromAsset
+		confs, err = t.wallets.fromWallet.Confirmations(dex.Bytes(coinID))
+	default:
+		if match.counterSwap == nil {
+			return
+		}
+		swapAsset = t.wallets.toAsset
+		confs, err = match.counterSwap.Coin().Confirmations()
+	}
+	if err != nil {
+		log.Debugf("error getting swap confirmations for match %s: %v", match.id, err)
+		return
+	}
+	if confs >= swapAsset.SwapConf {
+		match.swapConfirmed = time.Now()
+	}
+}
+
 // isSwappable will be true if the match is ready for a swap transaction to be
 // broadcast.
 func (t *trackedTrade) isSwappable(match *matchTracker) bool {
@@ -443,8 +577,7 @@ func (t *trackedTrade) isSwappable(match *matchTracker) bool {
 				match.id, t.UID(), coin)
 			return false
 		}
-		assetCfg := t.wallets.fromAsset
-		return confs >= assetCfg.SwapConf
+		return confs >= t.counterSwapConfs()
 	}
 	if dbMatch.Side == order.Maker && metaData.Status == order.NewlyMatched {
 		return true
@@ -452,6 +585,21 @@ func (t *trackedTrade) isSwappable(match *matchTracker) bool {
 	return false
 }
 
+// counterSwapConfs is the number of confirmations required on the
+// counterparty's swap contract, which is on the asset being received. The
+// user's setting for the asset is used if it is above the DEX server's
+// requirement.
+func (t *trackedTrade) counterSwapConfs() uint32 {
+	assetCfg := t.wallets.toAsset
+	confs := assetCfg.SwapConf
+	if t.swapConfs != nil {
+		if userConfs := t.swapConfs(assetCfg.ID); userConfs > confs {
+			confs = userConfs
+		}
+	}
+	return confs
+}
+
 // isRedeemable will be true if the match is ready for our redemption to be
 // broadcast.
 func (t *trackedTrade) isRedeemable(match *matchTracker) bool {
@@ -475,8 +623,7 @@ func (t *trackedTrade) isRedeemable(match *matchTracker) bool {
 				match.id, t.UID(), coin)
 			return false
 		}
-		assetCfg := t.wallets.toAsset
-		return confs >= assetCfg.SwapConf
+		return confs >= t.counterSwapConfs()
 	}
 	if dbMatch.Side == order.Taker && metaData.Status == order.MakerRedeemed {
 		return true
@@ -485,11 +632,11 @@ func (t *trackedTrade) isRedeemable(match *matchTracker) bool {
 }
 
 // isRefundable will be true if all of the following are true:
-// - We have broadcasted a swap contract (matchProof.Script != nil).
-// - Neither party has redeemed (matchStatus < order.MakerRedeemed).
-//   For Maker, this means we've not redeemed. For Taker, this means we've
-//   not been notified of Maker's redeem.
-// - Our swap's locktime has expired.
+//   - We have broadcasted a swap contract (matchProof.Script != nil).
+//   - Neither party has redeemed (matchStatus < order.MakerRedeemed).
+//     For Maker, this means we've not redeemed. For Taker, this means we've
+//     not been notified of Maker's redeem.
+//   - Our swap's locktime has expired.
 //
 // Those checks are skipped and isRefundable is false if we've already
 // executed a refund or our refund-to wallet is locked.
@@ -538,6 +685,7 @@ func (t *trackedTrade) tick() (assetCounter, error) {
 	t.matchMtx.Lock()
 	defer t.matchMtx.Unlock()
 	for _, match := range t.matches {
+		t.checkSwapConfirmed(match)
 		switch {
 		case t.isSwappable(match):
 			swaps = append(swaps, match)
@@ -550,7 +698,11 @@ func (t *trackedTrade) tick() (assetCounter, error) {
 			quoteReceived += calc.BaseToQuote(match.Match.Rate, match.Match.Quantity)
 
 		case t.isRefundable(match):
-			refunds = append(refunds, match)
+			if t.autoRefundEnabled() {
+				refunds = append(refunds, match)
+			} else {
+				t.notifyRefundable(match)
+			}
 		}
 	}
 
@@ -571,13 +723,11 @@ func (t *trackedTrade) tick() (assetCounter, error) {
 		corder, _ := t.coreOrderInternal()
 		if err != nil {
 			errs.addErr(err)
-			details := fmt.Sprintf("Error encountered sending a swap output(s) worth %.8f %s on order %s",
-				float64(qty)/conversionFactor, unbip(fromID), t.token())
-			t.notify(newOrderNote("Swap error", details, db.ErrorLevel, corder))
+			t.notify(newOrderNote(TopicSwapSendError, db.ErrorLevel, corder,
+				float64(qty)/conversionFactor, unbip(fromID), t.token()))
 		} else {
-			details := fmt.Sprintf("Sent swaps worth %.8f %s on order %s",
-				float64(qty)/conversionFactor, unbip(fromID), t.token())
-			t.notify(newOrderNote("Swaps initiated", details, db.Poke, corder))
+			t.notify(newOrderNote(TopicSwapsInitiated, db.Poke, corder,
+				float64(qty)/conversionFactor, unbip(fromID), t.token()))
 		}
 	}
 
@@ -592,32 +742,127 @@ func (t *trackedTrade) tick() (assetCounter, error) {
 		corder, _ := t.coreOrderInternal()
 		if err != nil {
 			errs.addErr(err)
-			details := fmt.Sprintf("Error encountered sending redemptions worth %.8f %s on order %s",
-				float64(qty)/conversionFactor, unbip(toAsset), t.token())
-			t.notify(newOrderNote("Redemption error", details, db.ErrorLevel, corder))
+			t.notify(newOrderNote(TopicRedemptionError, db.ErrorLevel, corder,
+				float64(qty)/conversionFactor, unbip(toAsset), t.token()))
 		} else {
-			details := fmt.Sprintf("Redeemed %.8f %s on order %s",
-				float64(qty)/conversionFactor, unbip(toAsset), t.token())
-			t.notify(newOrderNote("Match complete", details, db.Poke, corder))
+			t.notify(newOrderNote(TopicMatchComplete, db.Poke, corder,
+				float64(qty)/conversionFactor, unbip(toAsset), t.token()))
 		}
 	}
 
 	if len(refunds) > 0 {
 		refunded, err := t.refundMatches(refunds)
 		corder, _ := t.coreOrderInternal()
-		details := fmt.Sprintf("Refunded %.8f %s on order %s",
-			float64(refunded)/conversionFactor, unbip(fromID), t.token())
+		topic, severity := TopicMatchesRefunded, db.WarningLevel
 		if err != nil {
 			errs.addErr(err)
-			t.notify(newOrderNote("Refund Failure", details+", with some errors", db.ErrorLevel, corder))
-		} else {
-			t.notify(newOrderNote("Matches Refunded", details, db.WarningLevel, corder))
+			topic, severity = TopicRefundFailure, db.ErrorLevel
 		}
+		t.notify(newOrderNote(topic, severity, corder,
+			float64(refunded)/conversionFactor, unbip(fromID), t.token()))
+	}
+
+	t.bumpRedemptionFees()
+
+	if t.checkRefunds() {
+		counts.add(fromID, 1)
+	}
+
+	if t.maybeReturnCoins() {
+		counts.add(fromID, 1)
 	}
 
 	return counts, errs.ifany()
 }
 
+// maybeReturnCoins returns the funding coins to the wallet if the order is no
+// longer active and never sent a swap, so the coins will not be spent. Orders
+// are given an extra epoch after their own for any late match requests.
+// maybeReturnCoins should be called with the matchMtx locked. The return value
+// indicates whether the coins were returned.
+func (t *trackedTrade) maybeReturnCoins() bool {
+	if t.coinsReturned || len(t.metaData.ChangeCoin) > 0 || len(t.coins) == 0 {
+		return false
+	}
+	switch t.metaData.Status {
+	case order.OrderStatusExecuted, order.OrderStatusCanceled:
+	default:
+		return false
+	}
+	for _, match := range t.matches {
+		if !match.MetaData.Proof.IsRevoked {
+			return false
+		}
+	}
+	if t.epochLen > 0 && encode.UnixMilliU(time.Now()) < (uint64(t.Time())/t.epochLen+2)*t.epochLen {
+		return false
+	}
+	coins := make(asset.Coins, 0, len(t.coins))
+	for _, coin := range t.coins {
+		coins = append(coins, coin)
+	}
+	if err := t.wallets.fromWallet.ReturnCoins(coins); err != nil {
+		log.Warnf("unable to return funding coins for order %s: %v", t.ID(), err)
+	}
+	t.coinsReturned = true
+	log.Debugf("returned %d unused funding coins for order %s", len(coins), t.ID())
+	return true
+}
+
+// bumpRedemptionFees raises the fee rate of any of our redemptions that have
+// been unconfirmed for longer than redeemBumpDelay, if the wallet is an
+// asset.FeeBumper. The wallet limits the fee rate to the user's configured fee
+// limit. bumpRedemptionFees should be called with the matchMtx locked.
+func (t *trackedTrade) bumpRedemptionFees() {
+	wallet := t.wallets.toWallet
+	bumper, ok := wallet.Wallet.(asset.FeeBumper)
+	if !ok {
+		return
+	}
+	for _, match := range t.matches {
+		dbMatch, _, proof, _ := match.parts()
+		redeemCoin := dex.Bytes(proof.TakerRedeem)
+		if dbMatch.Side == order.Maker {
+			redeemCoin = dex.Bytes(proof.MakerRedeem)
+		}
+		if len(redeemCoin) == 0 || match.redeemConfirmed {
+			continue
+		}
+		// The redemption time is not stored, so after a restart, start the
+		// delay now.
+		if match.redeemCheck.IsZero() {
+			match.redeemCheck = time.Now()
+			continue
+		}
+		if time.Since(match.redeemCheck) < redeemBumpDelay {
+			continue
+		}
+		confs, err := wallet.Confirmations(redeemCoin)
+		if err != nil {
+			log.Errorf("error getting confirmations for redemption %s for match %s: %v",
+				coinIDString(wallet.AssetID, redeemCoin), match.id, err)
+			continue
+		}
+		if confs > 0 {
+			match.redeemConfirmed = true
+			continue
+		}
+		match.redeemCheck = time.Now()
+		coin, feeRate, err := bumper.BumpRedemptionFee(redeemCoin)
+		if err == asset.NoFeeBumpError {
+			continue
+		}
+		corder, _ := t.coreOrderInternal()
+		if err != nil {
+			t.notify(newOrderNote(TopicRedemptionFeeBumpError, db.ErrorLevel, corder,
+				unbip(wallet.AssetID), coinIDString(wallet.AssetID, redeemCoin), match.id, t.token(), err))
+			continue
+		}
+		t.notify(newOrderNote(TopicRedemptionFeeBumped, db.WarningLevel, corder,
+			unbip(wallet.AssetID), coinIDString(wallet.AssetID, redeemCoin), match.id, t.token(), feeRate, coin))
+	}
+}
+
 // resendPendingRequests checks all matches for this order to re-attempt
 // sending the `init` or `redeem` request where necessary.
 func (t *trackedTrade) resendPendingRequests() error {
@@ -881,6 +1126,7 @@ func (t *trackedTrade) finalizeRedeemAction(match *matchTracker, coinID []byte)
 		match.setStatus(order.MakerRedeemed)
 		proof.MakerRedeem = coinID
 	}
+	match.redeemCheck = time.Now()
 	if err := t.db.UpdateMatch(&match.MetaMatch); err != nil {
 		errs.add("error storing redeem details in database for match %s, coin %s: %v",
 			match.id, coinIDString(t.wallets.toAsset.ID, coinID), err)
@@ -944,6 +1190,7 @@ func (t *trackedTrade) refundMatches(matches []*matchTracker) (uint64, error) {
 			refundedQty += calc.BaseToQuote(match.Match.Rate, match.Match.Quantity)
 		}
 		proof.RefundCoin = []byte(refundCoin)
+		match.refundSent = true
 		err = t.db.UpdateMatch(&match.MetaMatch)
 		if err != nil {
 			errs.add("error storing match info in database: %v", err)
@@ -955,6 +1202,24 @@ func (t *trackedTrade) refundMatches(matches []*matchTracker) (uint64, error) {
 
 // processAudit processes the audit request from the server.
 func (t *trackedTrade) processAudit(msgID uint64, audit *msgjson.Audit) error {
+	err := t.recordAudit(audit)
+	if err != nil {
+		return err
+	}
+	// Respond to DEX.
+	var mid order.MatchID
+	copy(mid[:], audit.MatchID)
+	err = t.dc.ack(msgID, mid, audit)
+	if err != nil {
+		return fmt.Errorf("processAudit - order %s, match %s - Audit error: %v", t.ID(), mid, err)
+	}
+	return nil
+}
+
+// recordAudit audits the counterparty's contract and records the audit. The
+// server is not sent an acknowledgement, so recordAudit can also be used for
+// an audit that the server reports after a reconnect.
+func (t *trackedTrade) recordAudit(audit *msgjson.Audit) error {
 	// Find the match and check the server's signature.
 	var mid order.MatchID
 	copy(mid[:], audit.MatchID)
@@ -1060,17 +1325,29 @@ func (t *trackedTrade) processAudit(msgID uint64, audit *msgjson.Audit) error {
 	if err != nil {
 		return errs.add("error updating database: %v", err)
 	}
+	return nil
+}
 
-	// Respond to DEX.
-	err = t.dc.ack(msgID, match.id, audit)
+// processRedemption processes the redemption request from the server.
+func (t *trackedTrade) processRedemption(msgID uint64, redemption *msgjson.Redemption) error {
+	err := t.recordRedemption(redemption)
 	if err != nil {
-		return errs.add("Audit error: %v", err)
+		return err
+	}
+	// Respond to the DEX.
+	var mid order.MatchID
+	copy(mid[:], redemption.MatchID)
+	err = t.dc.ack(msgID, mid, redemption)
+	if err != nil {
+		return fmt.Errorf("processRedemption order %s, match %s - Audit - %v", t.ID(), mid, err)
 	}
 	return nil
 }
 
-// processRedemption processes the redemption request from the server.
-func (t *trackedTrade) processRedemption(msgID uint64, redemption *msgjson.Redemption) error {
+// recordRedemption validates and records the counterparty's redemption. The
+// server is not sent an acknowledgement, so recordRedemption can also be used
+// for a redemption that the server reports after a reconnect.
+func (t *trackedTrade) recordRedemption(redemption *msgjson.Redemption) error {
 	var mid order.MatchID
 	copy(mid[:], redemption.MatchID)
 	errs := newErrorSet("processRedemption order %s, match %s - ", t.ID(), mid)
@@ -1091,11 +1368,6 @@ func (t *trackedTrade) processRedemption(msgID uint64, redemption *msgjson.Redem
 		// Log, but don't quit.
 		errs.add("server redemption signature error: %v", err)
 	}
-	// Respond to the DEX.
-	err = t.dc.ack(msgID, match.id, redemption)
-	if err != nil {
-		return errs.add("Audit - %v", err)
-	}
 
 	// Update the database.
 	dbMatch, _, proof, auth := match.parts()
@@ -1140,6 +1412,34 @@ func (t *trackedTrade) processRedemption(msgID uint64, redemption *msgjson.Redem
 	return nil
 }
 
+// processReceipt validates and stores the server's signed settlement receipt
+// for a completed match.
+func (t *trackedTrade) processReceipt(receipt *msgjson.SettlementReceipt) error {
+	var mid order.MatchID
+	copy(mid[:], receipt.MatchID)
+	errs := newErrorSet("processReceipt order %s, match %s - ", t.ID(), mid)
+	t.matchMtx.Lock()
+	defer t.matchMtx.Unlock()
+	match, found := t.matches[mid]
+	if !found {
+		return errs.add("match not known")
+	}
+	if err := t.dc.acct.checkSig(receipt.Serialize(), receipt.Sig); err != nil {
+		return errs.add("server receipt signature error: %v", err)
+	}
+	receiptB, err := json.Marshal(receipt)
+	if err != nil {
+		return errs.add("error encoding receipt: %v", err)
+	}
+	_, _, proof, _ := match.parts()
+	proof.Receipt = receiptB
+	if err = t.db.UpdateMatch(&match.MetaMatch); err != nil {
+		return errs.add("error storing match info in database: %v", err)
+	}
+	log.Debugf("Stored settlement receipt for match %v of order %v", mid, t.ID())
+	return nil
+}
+
 // processEpoch processes the new epoch index. If an existing trade has an epoch
 // index < the new index and has a status of OrderStatusEpoch, the order will
 // be assumed to have matched without error, and the status set to executed or
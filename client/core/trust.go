@@ -0,0 +1,472 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+	"github.com/decred/go-socks/socks"
+)
+
+const (
+	// pinsKey is the app-level db key for the certificates and signing keys
+	// pinned for DEX servers that the client is not registered with.
+	pinsKey = "serverPins"
+	// maxBundleSize is the largest server bundle read from a file or URL.
+	maxBundleSize = 1 << 16
+	// bundleTimeout is the time allowed to fetch a server bundle from a URL.
+	bundleTimeout = 30 * time.Second
+)
+
+// ServerBundle is the connection information of a DEX server, as published by
+// its operator. Cert is the PEM-encoded TLS certificate, and is empty for a
+// server with a certificate signed by a trusted authority. PubKey is the DEX's
+// signing key.
+type ServerBundle struct {
+	Host   string    `json:"host"`
+	Cert   string    `json:"cert"`
+	PubKey dex.Bytes `json:"pubkey"`
+}
+
+// ServerTrust is the TLS certificate and signing key pinned for a DEX server.
+type ServerTrust struct {
+	Host string `json:"host"`
+	// Registered is true if the client has an account at the DEX. The pins of
+	// an unregistered DEX were imported from a server bundle, and are used to
+	// register.
+	Registered bool   `json:"registered"`
+	Cert       string `json:"cert"`
+	// CertFingerprint is the hex-encoded SHA-256 hash of the certificate.
+	CertFingerprint string    `json:"certFingerprint"`
+	CertExpiry      time.Time `json:"certExpiry"`
+	PubKey          dex.Bytes `json:"pubkey"`
+}
+
+// newServerTrust is the ServerTrust of the certificate and key.
+func newServerTrust(host string, registered bool, cert []byte, pubKey dex.Bytes) *ServerTrust {
+	trust := &ServerTrust{
+		Host:       host,
+		Registered: registered,
+		Cert:       string(cert),
+		PubKey:     pubKey,
+	}
+	if c, err := parseCert(cert); err == nil {
+		trust.CertFingerprint = certFingerprint(c)
+		trust.CertExpiry = c.NotAfter
+	}
+	return trust
+}
+
+// parseCert decodes a PEM-encoded certificate.
+func parseCert(cert []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM-encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certFingerprint is the hex-encoded SHA-256 hash of the certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(h[:])
+}
+
+// describeCert identifies the certificate in a warning.
+func describeCert(cert []byte) string {
+	if len(cert) == 0 {
+		return "none"
+	}
+	c, err := parseCert(cert)
+	if err != nil {
+		return "an invalid certificate"
+	}
+	return certFingerprint(c)
+}
+
+// describeKey identifies the signing key in a warning.
+func describeKey(pubKey *secp256k1.PublicKey) string {
+	if pubKey == nil {
+		return "none"
+	}
+	return hex.EncodeToString(pubKey.SerializeCompressed())
+}
+
+// ServerTrust returns the certificates and signing keys pinned for the DEX
+// servers, sorted by host. The accounts at a DEX share its pins, so each DEX is
+// listed once.
+func (c *Core) ServerTrust() ([]*ServerTrust, error) {
+	accts, err := c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error loading accounts: %w", err)
+	}
+	trusts := make(map[string]*ServerTrust, len(accts))
+	for _, acct := range accts {
+		_, host := splitAccountHost(addrHost(acct.Host))
+		if trusts[host] != nil {
+			continue
+		}
+		var pubKey dex.Bytes
+		if acct.DEXPubKey != nil {
+			pubKey = acct.DEXPubKey.SerializeCompressed()
+		}
+		trusts[host] = newServerTrust(host, true, acct.Cert, pubKey)
+	}
+	c.pinMtx.RLock()
+	for host, pin := range c.pins {
+		if trusts[host] == nil {
+			trusts[host] = newServerTrust(host, false, []byte(pin.Cert), pin.PubKey)
+		}
+	}
+	c.pinMtx.RUnlock()
+
+	list := make([]*ServerTrust, 0, len(trusts))
+	for _, trust := range trusts {
+		list = append(list, trust)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+	return list, nil
+}
+
+// UpdateServerTrust replaces the TLS certificate and signing key pinned for a
+// DEX that the client is registered with, for all of its accounts at the DEX.
+// An empty cert or pubKey keeps the pinned value. The new pins are verified by
+// connecting to the DEX with them first, so the DEX must be reachable, present
+// the certificate, and sign its config with the key. The user is warned of
+// each change. A connected account keeps its connection until the client is
+// restarted.
+func (c *Core) UpdateServerTrust(appPW []byte, dexAddr string, cert []byte, pubKey dex.Bytes) (*ServerTrust, error) {
+	if _, err := c.encryptionKey(appPW); err != nil {
+		return nil, codedError(passwordErr, err)
+	}
+	if dexAddr == "" {
+		return nil, newError(emptyHostErr, "no dex address specified")
+	}
+	_, host := splitAccountHost(addrHost(dexAddr))
+	if len(cert) > 0 {
+		if _, err := parseCert(cert); err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+	}
+	var dexKey *secp256k1.PublicKey
+	if len(pubKey) > 0 {
+		var err error
+		dexKey, err = secp256k1.ParsePubKey(pubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEX signing key: %w", err)
+		}
+	}
+
+	allAccts, err := c.db.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error loading accounts: %w", err)
+	}
+	var accts []*db.AccountInfo
+	for _, acct := range allAccts {
+		if _, h := splitAccountHost(addrHost(acct.Host)); h == host {
+			accts = append(accts, acct)
+		}
+	}
+	if len(accts) == 0 {
+		return nil, fmt.Errorf("not registered at %s", host)
+	}
+	oldCert, oldKey := accts[0].Cert, accts[0].DEXPubKey
+	newCert, newKey := oldCert, oldKey
+	if len(cert) > 0 {
+		newCert = cert
+	}
+	if dexKey != nil {
+		newKey = dexKey
+	}
+
+	// Verify the new pins with a connection to the DEX. The verified key
+	// is the DEX's current key, which differs from newKey if the DEX has
+	// rotated its key.
+	dc, err := c.connectDEX(&db.AccountInfo{
+		Host:      host,
+		Cert:      newCert,
+		DEXPubKey: newKey,
+	})
+	if err != nil {
+		return nil, codedError(connectionErr, err)
+	}
+	dc.connMaster.Disconnect()
+	dc.acct.dexKeyMtx.RLock()
+	newKey, nextKey, rotation := dc.acct.dexPubKey, dc.acct.nextPubKey, dc.acct.rotation
+	dc.acct.dexKeyMtx.RUnlock()
+
+	for _, acct := range accts {
+		certChanged := !bytes.Equal(acct.Cert, newCert)
+		keyChanged := acct.DEXPubKey == nil || !acct.DEXPubKey.IsEqual(newKey)
+		if certChanged {
+			if err = c.db.UpdateCert(acct.Host, newCert); err != nil {
+				return nil, fmt.Errorf("error saving the certificate of %s: %w", acct.Host, err)
+			}
+		}
+		if keyChanged {
+			if err = c.db.UpdateDEXPubKey(acct.Host, newKey); err != nil {
+				return nil, fmt.Errorf("error saving the DEX signing key of %s: %w", acct.Host, err)
+			}
+		}
+		c.connMtx.RLock()
+		conn := c.conns[addrHost(acct.Host)]
+		c.connMtx.RUnlock()
+		if conn == nil {
+			continue
+		}
+		if certChanged {
+			conn.acct.setCert(newCert)
+		}
+		if keyChanged {
+			conn.acct.setDEXKey(newKey, nextKey, rotation)
+		}
+	}
+
+	if !bytes.Equal(oldCert, newCert) {
+		c.notifyTrustChange(newTrustNote(TopicServerCertChanged, host, host, describeCert(oldCert), describeCert(newCert)))
+	}
+	if oldKey == nil || !oldKey.IsEqual(newKey) {
+		c.notifyTrustChange(newTrustNote(TopicServerKeyChanged, host, host, describeKey(oldKey), describeKey(newKey)))
+	}
+	return newServerTrust(host, true, newCert, newKey.SerializeCompressed()), nil
+}
+
+// notifyTrustChange logs and sends the warning of a changed pin.
+func (c *Core) notifyTrustChange(note *TrustNote) {
+	log.Warn(note.Details())
+	c.notify(note)
+}
+
+// ImportServerBundle imports the connection bundle of a DEX server from a file
+// path or an http(s) URL. A URL of an onion service is fetched through the
+// proxy set for its host. If the client is registered at the DEX, its pins are
+// updated with UpdateServerTrust. Otherwise, the bundle's certificate and key
+// are pinned for the DEX, and are used to register.
+func (c *Core) ImportServerBundle(appPW []byte, source string) (*ServerTrust, error) {
+	if _, err := c.encryptionKey(appPW); err != nil {
+		return nil, codedError(passwordErr, err)
+	}
+	bundle, err := c.readServerBundle(source)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Host == "" {
+		return nil, newError(emptyHostErr, "no dex address specified")
+	}
+	_, host := splitAccountHost(addrHost(bundle.Host))
+	if c.isRegisteredDEX(host) {
+		return c.UpdateServerTrust(appPW, host, []byte(bundle.Cert), bundle.PubKey)
+	}
+	return c.pinServer(host, bundle)
+}
+
+// readServerBundle reads a server bundle from a file or URL.
+func (c *Core) readServerBundle(source string) (*ServerBundle, error) {
+	var r io.Reader
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		client := &http.Client{Timeout: bundleTimeout}
+		if proxy := c.proxy(u.Host); proxy != nil {
+			p := &socks.Proxy{
+				Addr:         proxy.Addr,
+				Username:     proxy.User,
+				Password:     proxy.Pass,
+				TorIsolation: proxy.Isolate,
+			}
+			client.Transport = &http.Transport{Dial: p.Dial}
+		}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching server bundle: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error fetching server bundle: %s", resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("error opening server bundle: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading server bundle: %w", err)
+	}
+	if len(b) > maxBundleSize {
+		return nil, fmt.Errorf("server bundle larger than %d bytes", maxBundleSize)
+	}
+	bundle := new(ServerBundle)
+	if err = json.Unmarshal(b, bundle); err != nil {
+		return nil, fmt.Errorf("error decoding server bundle: %w", err)
+	}
+	return bundle, nil
+}
+
+// isRegisteredDEX checks whether the client has an account at the DEX host.
+func (c *Core) isRegisteredDEX(host string) bool {
+	accts, err := c.db.Accounts()
+	if err != nil {
+		log.Errorf("error loading accounts: %v", err)
+	}
+	for _, acct := range accts {
+		if _, h := splitAccountHost(addrHost(acct.Host)); h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// pinServer pins the bundle's certificate and key for a DEX that the client is
+// not registered with, warning the user of any change to an existing pin.
+func (c *Core) pinServer(host string, bundle *ServerBundle) (*ServerTrust, error) {
+	cert := []byte(bundle.Cert)
+	if len(cert) > 0 {
+		if _, err := parseCert(cert); err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+	}
+	var dexKey *secp256k1.PublicKey
+	if len(bundle.PubKey) > 0 {
+		var err error
+		dexKey, err = secp256k1.ParsePubKey(bundle.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEX signing key: %w", err)
+		}
+	}
+	pin := &ServerBundle{
+		Host: host,
+		Cert: bundle.Cert,
+	}
+	if dexKey != nil {
+		pin.PubKey = dexKey.SerializeCompressed()
+	}
+
+	c.pinMtx.Lock()
+	old := c.pins[host]
+	pins := make(map[string]*ServerBundle, len(c.pins)+1)
+	for k, v := range c.pins {
+		pins[k] = v
+	}
+	pins[host] = pin
+	if err := c.storePins(pins); err != nil {
+		c.pinMtx.Unlock()
+		return nil, err
+	}
+	c.pins = pins
+	c.pinMtx.Unlock()
+
+	if old != nil {
+		if old.Cert != pin.Cert {
+			c.notifyTrustChange(newTrustNote(TopicServerCertChanged, host, host,
+				describeCert([]byte(old.Cert)), describeCert(cert)))
+		}
+		if !bytes.Equal(old.PubKey, pin.PubKey) {
+			oldKey, _ := secp256k1.ParsePubKey(old.PubKey)
+			c.notifyTrustChange(newTrustNote(TopicServerKeyChanged, host, host,
+				describeKey(oldKey), describeKey(dexKey)))
+		}
+	}
+	return newServerTrust(host, false, cert, pin.PubKey), nil
+}
+
+// unpinServer removes the pins of a DEX that the client has registered with,
+// since the account's certificate and key replace them.
+func (c *Core) unpinServer(host string) {
+	c.pinMtx.Lock()
+	defer c.pinMtx.Unlock()
+	if c.pins[host] == nil {
+		return
+	}
+	pins := make(map[string]*ServerBundle, len(c.pins))
+	for k, v := range c.pins {
+		if k != host {
+			pins[k] = v
+		}
+	}
+	if err := c.storePins(pins); err != nil {
+		log.Errorf("error removing the pins of %s: %v", host, err)
+		return
+	}
+	c.pins = pins
+}
+
+// storePins saves the pins to the database.
+func (c *Core) storePins(pins map[string]*ServerBundle) error {
+	b, err := json.Marshal(pins)
+	if err != nil {
+		return fmt.Errorf("error encoding server pins: %v", err)
+	}
+	if err = c.db.Store(pinsKey, b); err != nil {
+		return fmt.Errorf("error saving server pins: %v", err)
+	}
+	return nil
+}
+
+// pinnedAccountInfo is the account info for a new connection to the DEX of the
+// account host. A cert provided by the user is used, or else the certificate of
+// another account at the DEX, or else the pinned certificate. The pinned
+// signing key is required of the DEX.
+func (c *Core) pinnedAccountInfo(acctHost string, cert []byte) *db.AccountInfo {
+	ai := &db.AccountInfo{
+		Host: acctHost,
+		Cert: cert,
+	}
+	if len(ai.Cert) == 0 {
+		ai.Cert = c.dexCert(acctHost)
+	}
+	_, host := splitAccountHost(acctHost)
+	c.pinMtx.RLock()
+	pin := c.pins[host]
+	c.pinMtx.RUnlock()
+	if pin == nil {
+		return ai
+	}
+	if len(ai.Cert) == 0 {
+		ai.Cert = []byte(pin.Cert)
+	}
+	if len(pin.PubKey) > 0 {
+		ai.DEXPubKey, _ = secp256k1.ParsePubKey(pin.PubKey)
+	}
+	return ai
+}
+
+// loadPins loads the server pins from the database.
+func (c *Core) loadPins() {
+	exists, err := c.db.ValueExists(pinsKey)
+	if err != nil || !exists {
+		return
+	}
+	b, err := c.db.Get(pinsKey)
+	if err != nil {
+		log.Errorf("error loading server pins: %v", err)
+		return
+	}
+	pins := make(map[string]*ServerBundle)
+	if err = json.Unmarshal(b, &pins); err != nil {
+		log.Errorf("error decoding server pins: %v", err)
+		return
+	}
+	c.pinMtx.Lock()
+	c.pins = pins
+	c.pinMtx.Unlock()
+}
@@ -15,6 +15,7 @@ import (
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/encrypt"
+	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
@@ -66,7 +67,10 @@ func (set *errorSet) Error() string {
 // If ConfigText is not provided, and a file exists at the `asset.DefaultConfigPath`,
 // that file will be parsed for wallet connection settings.
 type WalletForm struct {
-	AssetID    uint32
+	AssetID uint32
+	// Name distinguishes a new wallet from the asset's existing wallets. The
+	// asset's first wallet has no name.
+	Name       string
 	Account    string
 	ConfigText string
 }
@@ -75,19 +79,26 @@ type WalletForm struct {
 type WalletState struct {
 	Symbol  string      `json:"symbol"`
 	AssetID uint32      `json:"assetID"`
+	Name    string      `json:"name"`
 	Open    bool        `json:"open"`
 	Running bool        `json:"running"`
 	Balance *db.Balance `json:"balance"`
 	Address string      `json:"address"`
 	FeeRate uint64      `json:"feerate"`
 	Units   string      `json:"units"`
+	// FiatRate is the fiat value of one whole unit of the asset, if known.
+	FiatRate float64 `json:"fiatrate,omitempty"`
 }
 
 // User is information about the user's wallets and DEX accounts.
 type User struct {
 	Exchanges   map[string]*Exchange       `json:"exchanges"`
 	Initialized bool                       `json:"inited"`
+	LoggedIn    bool                       `json:"loggedIn"`
 	Assets      map[uint32]*SupportedAsset `json:"assets"`
+	// Currency and FiatRates are only set if a RateSource is configured.
+	Currency  string             `json:"currency,omitempty"`
+	FiatRates map[uint32]float64 `json:"fiatRates,omitempty"`
 }
 
 // SupportedAsset is data about an asset and possibly the wallet associated
@@ -105,6 +116,9 @@ type RegisterForm struct {
 	AppPass encode.PassBytes `json:"appPass"`
 	Fee     uint64           `json:"fee"`
 	Cert    string           `json:"cert"`
+	// Account is the label of an additional account to register at the DEX.
+	// An empty label registers the default account.
+	Account string `json:"account"`
 }
 
 // Match represents a match on an order. An order may have many matches.
@@ -114,6 +128,37 @@ type Match struct {
 	Rate    uint64            `json:"rate"`
 	Qty     uint64            `json:"qty"`
 	Side    order.MatchSide   `json:"side"`
+	// Stamp is the match time, in milliseconds.
+	Stamp uint64 `json:"stamp"`
+	// Deadlines are the deadlines of the match's current swap step. Deadlines
+	// is nil if the match is complete or revoked.
+	Deadlines *MatchDeadlines `json:"deadlines,omitempty"`
+	// Notes and Tags are the user's annotations of the match.
+	Notes string   `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// MatchDeadlines are the deadlines of an active match's current swap step, for
+// displaying countdowns. Times are in milliseconds, and a zero time is not yet
+// known or does not apply.
+type MatchDeadlines struct {
+	// Step is the swap step that is awaited, e.g. "taker swap". OurStep is
+	// true if the user must take the step, otherwise the counterparty must.
+	Step    string `json:"step"`
+	OurStep bool   `json:"ourStep"`
+	// ActBy is when the step's broadcast timeout expires. The server revokes
+	// the match after ActBy if the step has not been taken, penalizing the
+	// party at fault. For the swap steps following another swap, ActBy is
+	// zero until that swap has the DEX's required confirmations.
+	ActBy uint64 `json:"actBy"`
+	// RefundAt is when the lock time of the user's swap contract expires and
+	// the contract can be refunded. RefundAt is zero if the user has not
+	// swapped.
+	RefundAt uint64 `json:"refundAt"`
+	// CounterRefundAt is when the counterparty's swap contract can be
+	// refunded by the counterparty. The user must redeem before then.
+	// CounterRefundAt is zero until the counterparty's swap is audited.
+	CounterRefundAt uint64 `json:"counterRefundAt"`
 }
 
 // Order is core's general type for an order. An order may be a market, limit,
@@ -135,23 +180,75 @@ type Order struct {
 	Canceled    bool              `json:"canceled"`
 	Rate        uint64            `json:"rate"`               // limit only
 	TimeInForce order.TimeInForce `json:"tif"`                // limit only
+	Visible     uint64            `json:"visible,omitempty"`  // iceberg limit only
 	TargetID    string            `json:"targetID,omitempty"` // cancel only
+	// LotSizeIssue is LotSizeIssueInvalid or LotSizeIssueUneconomical if
+	// the order was flagged after the DEX changed the lot size or rate step.
+	LotSizeIssue string `json:"lotSizeIssue,omitempty"`
+	// FiatValue is the fiat value of Qty at the current rate, if known.
+	FiatValue float64 `json:"fiatValue,omitempty"`
+	// Notes and Tags are the user's annotations of the order, e.g. to
+	// distinguish the orders placed by a bot.
+	Notes string   `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// Candle is the OHLC data for one bin of a market's candlestick chart. Stamps
+// are in milliseconds. MatchVolume and QuoteVolume are in atoms of the base and
+// quote asset respectively, and rates are in atoms of the quote asset per unit
+// of the base asset.
+type Candle struct {
+	StartStamp  uint64 `json:"startStamp"`
+	EndStamp    uint64 `json:"endStamp"`
+	MatchVolume uint64 `json:"matchVolume"`
+	QuoteVolume uint64 `json:"quoteVolume"`
+	HighRate    uint64 `json:"highRate"`
+	LowRate     uint64 `json:"lowRate"`
+	StartRate   uint64 `json:"startRate"`
+	EndRate     uint64 `json:"endRate"`
+}
+
+// DepthPoint is a point on a depth chart. Qty is the quantity at Rate, and
+// Depth is the cumulative quantity at Rate or better.
+type DepthPoint struct {
+	Rate  float64 `json:"rate"`
+	Qty   float64 `json:"qty"`
+	Depth float64 `json:"depth"`
+}
+
+// DepthChart is the depth chart data for a market's order book. Each side is
+// sorted best rate first. MidGap is zero if either side is empty.
+type DepthChart struct {
+	Host    string        `json:"host"`
+	BaseID  uint32        `json:"baseid"`
+	QuoteID uint32        `json:"quoteid"`
+	Buys    []*DepthPoint `json:"buys"`
+	Sells   []*DepthPoint `json:"sells"`
+	MidGap  float64       `json:"midgap"`
 }
 
 // Market is market info.
 type Market struct {
-	Name            string   `json:"name"`
-	BaseID          uint32   `json:"baseid"`
-	BaseSymbol      string   `json:"basesymbol"`
-	QuoteID         uint32   `json:"quoteid"`
-	QuoteSymbol     string   `json:"quotesymbol"`
-	EpochLen        uint64   `json:"epochlen"`
-	StartEpoch      uint64   `json:"startepoch"`
-	MarketBuyBuffer float64  `json:"buybuffer"`
-	Orders          []*Order `json:"orders"`
-	pendingSuspend  *time.Timer
-	suspended       bool
-	mtx             sync.Mutex
+	Name            string  `json:"name"`
+	BaseID          uint32  `json:"baseid"`
+	BaseSymbol      string  `json:"basesymbol"`
+	QuoteID         uint32  `json:"quoteid"`
+	QuoteSymbol     string  `json:"quotesymbol"`
+	EpochLen        uint64  `json:"epochlen"`
+	StartEpoch      uint64  `json:"startepoch"`
+	MarketBuyBuffer float64 `json:"buybuffer"`
+	TradeFee        uint32  `json:"tradefee"` // basis points
+	// MinOrderValue is the server's minimum order value in units of the
+	// quote asset. Zero means there is no minimum beyond the lot size.
+	MinOrderValue uint64 `json:"minvalue,omitempty"`
+	// OddLotPolicy is the server's treatment of a remainder of a partially
+	// filled order that is less than the minimum order quantity, "keep" or
+	// "release". Released orders are executed.
+	OddLotPolicy   string   `json:"oddlot,omitempty"`
+	Orders         []*Order `json:"orders"`
+	pendingSuspend *time.Timer
+	suspended      bool
+	mtx            sync.Mutex
 }
 
 // Display returns an ID string suitable for displaying in a UI.
@@ -180,13 +277,45 @@ func (m *Market) setSuspended(state bool) {
 
 // Exchange represents a single DEX with any number of markets.
 type Exchange struct {
+	// Host is the account host. Account is the account's label, which is
+	// empty for the default account.
 	Host          string                `json:"host"`
+	Account       string                `json:"account,omitempty"`
 	Markets       map[string]*Market    `json:"markets"`
 	Assets        map[uint32]*dex.Asset `json:"assets"`
 	FeePending    bool                  `json:"feePending"`
 	Connected     bool                  `json:"connected"`
 	ConfsRequired uint32                `json:"confsrequired"`
 	RegConfirms   *uint32               `json:"confs,omitempty"`
+	// APIVersion is the API version negotiated with the DEX. Upgrade is set
+	// if the DEX requires a newer API version than the client speaks.
+	APIVersion uint16           `json:"apiVersion"`
+	Upgrade    *msgjson.Upgrade `json:"upgrade,omitempty"`
+}
+
+// Penalty is a DEX's notice that the user's account was closed for violating
+// the Rule, or reopened if Banned is false. MatchIDs are the matches that are
+// evidence of the violation, if any. Appeal describes how to appeal a ban.
+type Penalty struct {
+	Host     string   `json:"host"`
+	Rule     string   `json:"rule"`
+	Banned   bool     `json:"banned"`
+	Time     uint64   `json:"stamp"`
+	Details  string   `json:"details"`
+	MatchIDs []string `json:"matchIDs,omitempty"`
+	Appeal   string   `json:"appeal,omitempty"`
+}
+
+// Policy is the operator's signed policy document for a DEX, as retrieved by
+// GetPolicy.
+type Policy struct {
+	Host      string    `json:"host"`
+	PubKey    dex.Bytes `json:"pubkey"`
+	Published time.Time `json:"published"`
+	Fees      string    `json:"fees"`
+	Penalties string    `json:"penalties"`
+	Contact   string    `json:"contact"`
+	Terms     string    `json:"terms"`
 }
 
 // newDisplayID creates a display-friendly market ID for a base/quote ID pair.
@@ -208,6 +337,7 @@ type MiniOrder struct {
 	Sell     bool    `json:"sell"`
 	Token    string  `json:"token"`
 	MarketID string  `json:"marketID"`
+	Host     string  `json:"host,omitempty"` // aggregated books only
 }
 
 // RemainingUpdate is an update to the quantity for an order on the order book.
@@ -224,12 +354,116 @@ type OrderBook struct {
 	Epoch []*MiniOrder `json:"epoch"`
 }
 
+// AggregateOrderBook is the combined order book for a market pair across every
+// DEX server with a synced book. Each order is tagged with the host of the DEX
+// it is booked on. BuyVenue is the host with the lowest sell order, and
+// SellVenue is the host with the highest buy order.
+type AggregateOrderBook struct {
+	BaseID    uint32       `json:"baseid"`
+	QuoteID   uint32       `json:"quoteid"`
+	Hosts     []string     `json:"hosts"`
+	Sells     []*MiniOrder `json:"sells"`
+	Buys      []*MiniOrder `json:"buys"`
+	BuyVenue  string       `json:"buyVenue"`
+	SellVenue string       `json:"sellVenue"`
+}
+
+// VenueHint is an estimate of how an order of a given quantity would fill
+// against the book of a single DEX. Qty is the quantity that the book can fill,
+// and Rate is the average rate of the fill.
+type VenueHint struct {
+	Host     string  `json:"host"`
+	Qty      float64 `json:"qty"`
+	Rate     float64 `json:"rate"`
+	Complete bool    `json:"complete"`
+}
+
+// RouteForm is an order to be split across the DEX servers that host a market
+// pair. Qty is in atoms of the base asset. A non-zero Rate is the worst rate
+// that any part of the order will accept. An order without a Rate must be
+// immediate. Hosts limits the route to the listed DEX servers.
+type RouteForm struct {
+	Sell        bool     `json:"sell"`
+	Base        uint32   `json:"base"`
+	Quote       uint32   `json:"quote"`
+	Qty         uint64   `json:"qty"`
+	Rate        uint64   `json:"rate"`
+	TifNow      bool     `json:"tifnow"`
+	Hosts       []string `json:"hosts"`
+	BaseWallet  string   `json:"baseWallet"`
+	QuoteWallet string   `json:"quoteWallet"`
+}
+
+// RouteLeg is the part of a routed order that is placed on one DEX server. Rate
+// is the limit rate of the leg's order, and AvgRate is the estimated average
+// rate of its fill. Fees is the worst-case swap fees for the leg, in atoms of
+// the asset being sold.
+type RouteLeg struct {
+	Host    string `json:"host"`
+	Qty     uint64 `json:"qty"`
+	LotSize uint64 `json:"lotSize"`
+	Rate    uint64 `json:"rate"`
+	AvgRate uint64 `json:"avgRate"`
+	Fees    uint64 `json:"fees"`
+}
+
+// RoutePlan is how an order would be split across DEX servers. Unrouted is the
+// quantity that could not be assigned to any server.
+type RoutePlan struct {
+	Sell     bool        `json:"sell"`
+	BaseID   uint32      `json:"baseid"`
+	QuoteID  uint32      `json:"quoteid"`
+	Qty      uint64      `json:"qty"`
+	Legs     []*RouteLeg `json:"legs"`
+	AvgRate  uint64      `json:"avgRate"`
+	Unrouted uint64      `json:"unrouted"`
+}
+
+// RouteFill is the consolidated fill of the orders placed for a routed order.
+// Complete is true when none of the orders can be filled further. Errors are
+// the errors for legs that could not be placed, keyed by host.
+type RouteFill struct {
+	Orders   []*Order          `json:"orders"`
+	Qty      uint64            `json:"qty"`
+	Filled   uint64            `json:"filled"`
+	AvgRate  uint64            `json:"avgRate"`
+	Complete bool              `json:"complete"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// AggregateOrders is the user's orders and matches for a market pair across all
+// DEX servers. Orders are sorted newest first.
+type AggregateOrders struct {
+	BaseID  uint32        `json:"baseid"`
+	QuoteID uint32        `json:"quoteid"`
+	Orders  []*Order      `json:"orders"`
+	Matches []*OrderMatch `json:"matches"`
+}
+
+// OrderMatch is a Match along with the host and ID of the order it belongs to.
+type OrderMatch struct {
+	Host    string `json:"host"`
+	OrderID string `json:"orderID"`
+	Sell    bool   `json:"sell"`
+	*Match
+}
+
 const (
 	BookOrderAction   = "book_order"
 	EpochOrderAction  = "epoch_order"
 	UnbookOrderAction = "unbook_order"
+	// FreshBookAction is the action of a BookUpdate with a full order book,
+	// which is sent when the order book is resynced after a reconnect.
+	FreshBookAction = "book"
 )
 
+// MarketOrderBook is the payload of a FreshBookAction BookUpdate.
+type MarketOrderBook struct {
+	Base  uint32     `json:"base"`
+	Quote uint32     `json:"quote"`
+	Book  *OrderBook `json:"book"`
+}
+
 // BookUpdate is an order book update.
 type BookUpdate struct {
 	Action   string      `json:"action"`
@@ -241,17 +475,23 @@ type BookUpdate struct {
 // dexAccount is the core type to represent the client's account information for
 // a DEX.
 type dexAccount struct {
-	host      string
-	encKey    []byte
-	keyMtx    sync.RWMutex
-	privKey   *secp256k1.PrivateKey
-	id        account.AccountID
-	dexPubKey *secp256k1.PublicKey
-	feeCoin   []byte
-	cert      []byte
-	isPaid    bool
-	authMtx   sync.RWMutex
-	isAuthed  bool
+	host    string
+	encKey  []byte
+	keyMtx  sync.RWMutex
+	privKey *secp256k1.PrivateKey
+	id      account.AccountID
+	// dexKeyMtx guards the DEX's TLS certificate, dexPubKey, and the pending
+	// key rotation, which replaces dexPubKey with nextPubKey at the rotation
+	// time.
+	dexKeyMtx  sync.RWMutex
+	cert       []byte
+	dexPubKey  *secp256k1.PublicKey
+	nextPubKey *secp256k1.PublicKey
+	rotation   time.Time
+	feeCoin    []byte
+	isPaid     bool
+	authMtx    sync.RWMutex
+	isAuthed   bool
 }
 
 // newDEXAccount is a constructor for a new *dexAccount.
@@ -269,13 +509,52 @@ func newDEXAccount(acctInfo *db.AccountInfo) *dexAccount {
 func (a *dexAccount) dbInfo() *db.AccountInfo {
 	return &db.AccountInfo{
 		Host:      a.host,
-		Cert:      a.cert,
+		Cert:      a.certificate(),
 		EncKey:    a.encKey,
-		DEXPubKey: a.dexPubKey,
+		DEXPubKey: a.dexKey(),
 		FeeCoin:   a.feeCoin,
 	}
 }
 
+// dexKey is the DEX's signing key.
+func (a *dexAccount) dexKey() *secp256k1.PublicKey {
+	a.dexKeyMtx.RLock()
+	defer a.dexKeyMtx.RUnlock()
+	return a.dexPubKey
+}
+
+// certificate is the DEX's TLS certificate.
+func (a *dexAccount) certificate() []byte {
+	a.dexKeyMtx.RLock()
+	defer a.dexKeyMtx.RUnlock()
+	return a.cert
+}
+
+// setCert sets the DEX's TLS certificate.
+func (a *dexAccount) setCert(cert []byte) {
+	a.dexKeyMtx.Lock()
+	a.cert = cert
+	a.dexKeyMtx.Unlock()
+}
+
+// isDEXKey checks if the key is the DEX's signing key, or the key that replaces
+// it in a pending key rotation.
+func (a *dexAccount) isDEXKey(pubKey *secp256k1.PublicKey) bool {
+	a.dexKeyMtx.RLock()
+	defer a.dexKeyMtx.RUnlock()
+	return (a.dexPubKey != nil && pubKey.IsEqual(a.dexPubKey)) ||
+		(a.nextPubKey != nil && pubKey.IsEqual(a.nextPubKey))
+}
+
+// setDEXKey sets the DEX's signing key and any pending key rotation.
+func (a *dexAccount) setDEXKey(pubKey, nextPubKey *secp256k1.PublicKey, rotation time.Time) {
+	a.dexKeyMtx.Lock()
+	defer a.dexKeyMtx.Unlock()
+	a.dexPubKey = pubKey
+	a.nextPubKey = nextPubKey
+	a.rotation = rotation
+}
+
 // ID returns the account ID.
 func (a *dexAccount) ID() account.AccountID {
 	a.keyMtx.RLock()
@@ -283,9 +562,9 @@ func (a *dexAccount) ID() account.AccountID {
 	return a.id
 }
 
-// setupEncryption creates and returns a new privkey for the account.
-// Returns existing privkey if previously set up.
-func (a *dexAccount) setupEncryption(crypter encrypt.Crypter) (*secp256k1.PrivateKey, error) {
+// setupEncryption encrypts and stores the privkey for the account, and returns
+// it. Returns existing privkey if previously set up.
+func (a *dexAccount) setupEncryption(crypter encrypt.Crypter, privKey *secp256k1.PrivateKey) (*secp256k1.PrivateKey, error) {
 	// Check if privKey exists. Check a.encKey instead of a.privKey
 	// as a.privKey will be nil if the account is locked.
 	a.keyMtx.RLock()
@@ -297,11 +576,6 @@ func (a *dexAccount) setupEncryption(crypter encrypt.Crypter) (*secp256k1.Privat
 	}
 	a.keyMtx.RUnlock()
 
-	// Create a new private key for the account.
-	privKey, err := secp256k1.GeneratePrivateKey()
-	if err != nil {
-		return nil, fmt.Errorf("error creating acct private key: %v", err)
-	}
 	// Encrypt the private key.
 	encKey, err := crypter.Encrypt(privKey.Serialize())
 	if err != nil {
@@ -405,10 +679,146 @@ func (a *dexAccount) sign(msg []byte) ([]byte, error) {
 	return sig.Serialize(), nil
 }
 
-// checkSig checks the signature against the message and the DEX pubkey.
+// checkSig checks the signature against the message and the DEX pubkey. If the
+// DEX has announced a key rotation and the rotation time has passed, a
+// signature by the new key is also accepted, and the new key replaces the old.
 func (a *dexAccount) checkSig(msg []byte, sig []byte) error {
-	_, err := checkSigS256(msg, a.dexPubKey.Serialize(), sig)
-	return err
+	a.dexKeyMtx.RLock()
+	pubKey, nextPubKey, rotation := a.dexPubKey, a.nextPubKey, a.rotation
+	a.dexKeyMtx.RUnlock()
+	_, err := checkSigS256(msg, pubKey.Serialize(), sig)
+	if err == nil || nextPubKey == nil || time.Now().Before(rotation) {
+		return err
+	}
+	if _, errNext := checkSigS256(msg, nextPubKey.Serialize(), sig); errNext != nil {
+		return err
+	}
+	a.dexKeyMtx.Lock()
+	if a.nextPubKey == nextPubKey {
+		a.dexPubKey, a.nextPubKey = nextPubKey, nil
+		log.Infof("DEX %s signing key rotated to %x.", a.host, nextPubKey.SerializeCompressed())
+	}
+	a.dexKeyMtx.Unlock()
+	return nil
+}
+
+// ReservedCoin is a coin locked in the wallet for an order.
+type ReservedCoin struct {
+	ID    string `json:"id"`
+	Value uint64 `json:"value"`
+}
+
+// MatchReservation is the value of a match that is reserved for, or locked in,
+// the user's swap contract.
+type MatchReservation struct {
+	MatchID string            `json:"matchID"`
+	Status  order.MatchStatus `json:"status"`
+	Side    order.MatchSide   `json:"side"`
+	// Value is the value of the user's swap.
+	Value uint64 `json:"value"`
+	// Contract is the user's swap contract coin, if it has been sent.
+	Contract string `json:"contract,omitempty"`
+	// LockTime is when the contract can be refunded if it is not redeemed, in
+	// milliseconds. LockTime is zero until the swap is sent.
+	LockTime uint64 `json:"lockTime,omitempty"`
+	// Release describes when the value will be released.
+	Release string `json:"release"`
+}
+
+// OrderReservation is the value of an asset reserved for an order.
+type OrderReservation struct {
+	Host     string            `json:"host"`
+	OrderID  string            `json:"orderID"`
+	MarketID string            `json:"market"`
+	Status   order.OrderStatus `json:"status"`
+	Sell     bool              `json:"sell"`
+	// Coins are the coins locked in the wallet for the order.
+	Coins []*ReservedCoin `json:"coins"`
+	// Locked is the total value of the Coins.
+	Locked uint64 `json:"locked"`
+	// Pending is the value still to be sent in swaps, for the unmatched
+	// quantity of an active order and for matches awaiting the user's swap.
+	Pending uint64 `json:"pending"`
+	// Release describes when the Coins will be released.
+	Release string `json:"release"`
+	// Matches are the order's matches that have not been redeemed or refunded.
+	Matches []*MatchReservation `json:"matches"`
+}
+
+// Reservations is an accounting of the value of an asset that is reserved for
+// orders and locked in swap contracts.
+type Reservations struct {
+	AssetID uint32 `json:"assetID"`
+	// Balance is the wallet balance.
+	Balance *db.Balance `json:"balance"`
+	// Orders are the orders that fund swaps with the asset.
+	Orders []*OrderReservation `json:"orders"`
+	// OrderLocked is the total value of the coins locked for the Orders.
+	OrderLocked uint64 `json:"orderLocked"`
+	// Contracts is the total value in the user's unredeemed and unrefunded
+	// swap contracts.
+	Contracts uint64 `json:"contracts"`
+	// Untracked is the value that the wallet reports as locked, but that is not
+	// locked for any order, e.g. coins locked by other software.
+	Untracked uint64 `json:"untracked"`
+}
+
+// OrderFunding is a check of whether a new order can be funded.
+type OrderFunding struct {
+	AssetID uint32 `json:"assetID"`
+	// Required is the estimated value needed to fund the order, including swap
+	// fees at the asset's maximum fee rate.
+	Required uint64 `json:"required"`
+	// Available, Locked and Immature are from the wallet balance.
+	Available uint64 `json:"available"`
+	Locked    uint64 `json:"locked"`
+	Immature  uint64 `json:"immature"`
+	// OK is true if the available balance covers the Required value.
+	OK bool `json:"ok"`
+	// Reason explains why the order cannot be funded when OK is false.
+	Reason string `json:"reason,omitempty"`
+}
+
+// FeeEstimate is the estimated on-chain fees for one asset of an order. Best is
+// for an order that is filled by a single match, and Worst is for an order
+// that is filled one lot at a time. FeeRate is in atoms/byte.
+type FeeEstimate struct {
+	AssetID uint32 `json:"assetID"`
+	FeeRate uint64 `json:"feeRate"`
+	Best    uint64 `json:"best"`
+	Worst   uint64 `json:"worst"`
+}
+
+// OrderEstimate is the PreOrder estimate for an order.
+type OrderEstimate struct {
+	// Lots is the number of lots in the order. The number of lots in a market
+	// buy is estimated from the book.
+	Lots uint64 `json:"lots"`
+	// Qty is the order quantity rounded down to a whole number of lots, and
+	// Remainder is the quantity that was dropped. A market buy is not rounded.
+	Qty       uint64 `json:"qty"`
+	Remainder uint64 `json:"remainder"`
+	// Swap is the estimated fees for the swaps, which are paid from the funds
+	// locked for the order. Redeem is the estimated fees for the redemptions.
+	Swap   *FeeEstimate `json:"swap"`
+	Redeem *FeeEstimate `json:"redeem"`
+	// Locked is the value that will be locked to fund the order, including
+	// the worst-case swap fees.
+	Locked uint64 `json:"locked"`
+	// RefundAfter is the worst-case time in milliseconds from a match until
+	// the order's swap can be refunded if the counterparty does not act.
+	RefundAfter uint64 `json:"refundAfter"`
+	// TradeFee is the estimated trade fee charged by the server, which is
+	// invoiced separately. The fee is paid in the asset received.
+	TradeFee *TradeFeeEstimate `json:"tradeFee,omitempty"`
+}
+
+// TradeFeeEstimate is an estimate of the server's trade fee on an order. Rate
+// is in basis points of the quantity received.
+type TradeFeeEstimate struct {
+	AssetID uint32 `json:"assetID"`
+	Rate    uint32 `json:"rate"`
+	Fee     uint64 `json:"fee"`
 }
 
 // TradeForm is used to place a market or limit order
@@ -421,6 +831,32 @@ type TradeForm struct {
 	Qty     uint64 `json:"qty"`
 	Rate    uint64 `json:"rate"`
 	TifNow  bool   `json:"tifnow"`
+	// BaseWallet and QuoteWallet select the wallets to use for the order by
+	// name. An empty name selects the asset's default wallet.
+	BaseWallet  string `json:"baseWallet"`
+	QuoteWallet string `json:"quoteWallet"`
+	// Account selects the account at the DEX by label. An empty label selects
+	// the account of the Host, which may be an account host.
+	Account string `json:"account"`
+	// Funding is the coin selection strategy for funding the order. An empty
+	// strategy selects the wallet's default strategy, or the manual strategy
+	// if Coins are chosen.
+	Funding asset.FundingStrategy `json:"funding,omitempty"`
+	// Coins are the IDs of the coins that fund the order with the manual
+	// funding strategy.
+	Coins []dex.Bytes `json:"coins,omitempty"`
+	// Notes and Tags annotate the order. They may be changed later with
+	// SetOrderNotes.
+	Notes string   `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// Confirmed is set when the user has confirmed an order that was stopped
+	// by a trade guard warning. See TradeGuardError.
+	Confirmed bool `json:"confirmed,omitempty"`
+	// Visible makes a standing limit order an iceberg order, showing only
+	// Visible of the remaining quantity in the order book. It must be a
+	// multiple of the lot size, and less than Qty. Zero shows the full
+	// quantity.
+	Visible uint64 `json:"visible,omitempty"`
 }
 
 // marketName is a string ID constructed from the asset IDs.
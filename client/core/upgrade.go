@@ -0,0 +1,82 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+)
+
+// apiVersion is the API version negotiated with the DEX, which is the lower of
+// the server's advertised version and the client's version. It is zero for a
+// server that predates versioning.
+func (dc *dexConnection) apiVersion() uint16 {
+	if dc.cfg.APIVersion < msgjson.APIVersion {
+		return dc.cfg.APIVersion
+	}
+	return msgjson.APIVersion
+}
+
+// checkRoute checks that the negotiated API version supports requests on the
+// route. Servers that predate versioning are not checked, since the routes
+// they support are unknown.
+func (dc *dexConnection) checkRoute(route string) error {
+	if dc.cfg.APIVersion == 0 {
+		return nil
+	}
+	if required := msgjson.RouteVersions[route]; dc.apiVersion() < required {
+		return fmt.Errorf("DEX %s does not support %s requests: server API version %d, version %d required",
+			dc.acct.host, route, dc.cfg.APIVersion, required)
+	}
+	return nil
+}
+
+// requiredUpgrade is the protocol upgrade announced by the DEX if the client's
+// API version is older than the version it will require, or nil if no upgrade
+// is required.
+func (dc *dexConnection) requiredUpgrade() *msgjson.Upgrade {
+	if u := dc.cfg.Upgrade; u != nil && u.APIVersion > msgjson.APIVersion {
+		return u
+	}
+	return nil
+}
+
+// checkUpgrade returns an error if the DEX requires a newer API version than
+// the client speaks, and the upgrade deadline has passed.
+func (dc *dexConnection) checkUpgrade() error {
+	u := dc.requiredUpgrade()
+	if u == nil {
+		return nil
+	}
+	deadline := encode.UnixTimeMilli(int64(u.Deadline))
+	if time.Now().Before(deadline) {
+		return nil
+	}
+	return fmt.Errorf("DEX %s requires API version %d since %v, but this client speaks version %d. Upgrade the client",
+		dc.acct.host, u.APIVersion, deadline, msgjson.APIVersion)
+}
+
+// notifyUpgrade warns the user of a protocol upgrade required by the DEX.
+func (c *Core) notifyUpgrade(dc *dexConnection) {
+	u := dc.requiredUpgrade()
+	if u == nil {
+		return
+	}
+	deadline := encode.UnixTimeMilli(int64(u.Deadline))
+	topic, severity := TopicUpgradeRequired, db.WarningLevel
+	if !time.Now().Before(deadline) {
+		topic, severity = TopicUpgradeDeadlinePassed, db.ErrorLevel
+	}
+	var notes string
+	if u.Notes != "" {
+		notes = " " + u.Notes
+	}
+	note := newUpgradeNote(topic, severity, dc.acct.host, u, dc.acct.host, u.APIVersion, deadline, msgjson.APIVersion, notes)
+	log.Warn(note.Details())
+	c.notify(note)
+}
@@ -5,6 +5,7 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -19,13 +20,39 @@ type xcWallet struct {
 	connector *dex.ConnectionMaster
 	Account   string
 	AssetID   uint32
+	Name      string
 	mtx       sync.RWMutex
 	lockTime  time.Time
 	hookedUp  bool
+	builtIn   bool
 	balance   *db.Balance
 	encPW     []byte
 	address   string
 	dbID      []byte
+	// syncStatus is the wallet's last reported sync status, refreshed at
+	// syncStamp, and errs are its recent errors. See health.go.
+	syncStatus *asset.SyncStatus
+	syncStamp  time.Time
+	errs       []*WalletError
+}
+
+// id is the wallet's ID in the Core's wallet registry.
+func (w *xcWallet) id() string {
+	return db.WalletSID(w.AssetID, w.Name)
+}
+
+// label is the asset's symbol, followed by the wallet's name in parentheses if
+// the wallet is named.
+func (w *xcWallet) label() string {
+	return walletLabel(w.AssetID, w.Name)
+}
+
+// walletLabel is the label of the asset's wallet with the name.
+func walletLabel(assetID uint32, name string) string {
+	if name == "" {
+		return unbip(assetID)
+	}
+	return fmt.Sprintf("%s (%s)", unbip(assetID), name)
 }
 
 // Unlock unlocks the wallet.
@@ -63,6 +90,7 @@ func (w *xcWallet) state() *WalletState {
 	return &WalletState{
 		Symbol:  unbip(w.AssetID),
 		AssetID: w.AssetID,
+		Name:    w.Name,
 		Open:    w.lockTime.After(time.Now()),
 		Running: w.connector.On(),
 		Balance: w.balance,
@@ -72,6 +100,13 @@ func (w *xcWallet) state() *WalletState {
 	}
 }
 
+// cachedBalance returns the last retrieved wallet balance, which may be nil.
+func (w *xcWallet) cachedBalance() *db.Balance {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	return w.balance
+}
+
 // setBalance sets the wallet balance.
 func (w *xcWallet) setBalance(bal *db.Balance) {
 	w.mtx.Lock()
@@ -16,6 +16,7 @@ import (
 	dexdb "decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/order"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 	"go.etcd.io/bbolt"
 )
 
@@ -38,6 +39,9 @@ var (
 	matchesBucket  = []byte("matches")
 	walletsBucket  = []byte("wallets")
 	notesBucket    = []byte("notes")
+	schedBucket    = []byte("scheduled")
+	alertsBucket   = []byte("alerts")
+	candlesBucket  = []byte("candles")
 	feeProofKey    = []byte("feecoin")
 	statusKey      = []byte("status")
 	baseKey        = []byte("base")
@@ -54,7 +58,10 @@ var (
 	balanceKey     = []byte("balance")
 	walletKey      = []byte("wallet")
 	changeKey      = []byte("change")
+	baseWalletKey  = []byte("basewallet")
+	quoteWalletKey = []byte("quotewallet")
 	noteKey        = []byte("note")
+	tagsKey        = []byte("tags")
 	stampKey       = []byte("stamp")
 	severityKey    = []byte("severity")
 	ackKey         = []byte("ack")
@@ -87,7 +94,7 @@ func NewDB(dbPath string) (dexdb.DB, error) {
 	}
 
 	return bdb, bdb.makeTopLevelBuckets([][]byte{appBucket, accountsBucket,
-		ordersBucket, matchesBucket, walletsBucket, notesBucket})
+		ordersBucket, matchesBucket, walletsBucket, notesBucket, schedBucket, alertsBucket, candlesBucket})
 }
 
 // Run waits for context cancellation and closes the database.
@@ -257,6 +264,51 @@ func (db *BoltDB) AccountPaid(proof *dexdb.AccountProof) error {
 	})
 }
 
+// UpdateDEXPubKey replaces the DEX's signing key for the account.
+func (db *BoltDB) UpdateDEXPubKey(host string, pubKey *secp256k1.PublicKey) error {
+	if pubKey == nil {
+		return fmt.Errorf("nil DEXPubKey not allowed")
+	}
+	acctKey := []byte(host)
+	return db.acctsUpdate(func(accts *bbolt.Bucket) error {
+		acct := accts.Bucket(acctKey)
+		if acct == nil {
+			return fmt.Errorf("account not found for %s", host)
+		}
+		acctB := acct.Get(accountKey)
+		if acctB == nil {
+			return fmt.Errorf("empty account found for %s", host)
+		}
+		ai, err := dexdb.DecodeAccountInfo(bCopy(acctB))
+		if err != nil {
+			return err
+		}
+		ai.DEXPubKey = pubKey
+		return acct.Put(accountKey, ai.Encode())
+	})
+}
+
+// UpdateCert replaces the DEX's TLS certificate for the account.
+func (db *BoltDB) UpdateCert(host string, cert []byte) error {
+	acctKey := []byte(host)
+	return db.acctsUpdate(func(accts *bbolt.Bucket) error {
+		acct := accts.Bucket(acctKey)
+		if acct == nil {
+			return fmt.Errorf("account not found for %s", host)
+		}
+		acctB := acct.Get(accountKey)
+		if acctB == nil {
+			return fmt.Errorf("empty account found for %s", host)
+		}
+		ai, err := dexdb.DecodeAccountInfo(bCopy(acctB))
+		if err != nil {
+			return err
+		}
+		ai.Cert = cert
+		return acct.Put(accountKey, ai.Encode())
+	})
+}
+
 // acctsView is a convenience function for reading from the account bucket.
 func (db *BoltDB) acctsView(f bucketFunc) error {
 	return db.withBucket(accountsBucket, db.View, f)
@@ -291,6 +343,8 @@ func (db *BoltDB) UpdateOrder(m *dexdb.MetaOrder) error {
 			put(updateTimeKey, uint64Bytes(timeNow())).
 			put(proofKey, md.Proof.Encode()).
 			put(changeKey, md.ChangeCoin).
+			put(baseWalletKey, []byte(md.BaseWallet)).
+			put(quoteWalletKey, []byte(md.QuoteWallet)).
 			put(orderKey, order.EncodeOrder(ord)).
 			err()
 	})
@@ -442,12 +496,20 @@ func decodeOrderBucket(oid []byte, oBkt *bbolt.Bucket) (*dexdb.MetaOrder, error)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding order proof for %x: %v", oid, err)
 	}
+	tags, err := dexdb.DecodeTags(oBkt.Get(tagsKey))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding tags for order %x: %v", oid, err)
+	}
 	return &dexdb.MetaOrder{
 		MetaData: &dexdb.OrderMetaData{
-			Proof:      *proof,
-			Status:     order.OrderStatus(intCoder.Uint16(oBkt.Get(statusKey))),
-			Host:       string(oBkt.Get(dexKey)),
-			ChangeCoin: oBkt.Get(changeKey),
+			Proof:       *proof,
+			Status:      order.OrderStatus(intCoder.Uint16(oBkt.Get(statusKey))),
+			Host:        string(oBkt.Get(dexKey)),
+			ChangeCoin:  oBkt.Get(changeKey),
+			BaseWallet:  string(oBkt.Get(baseWalletKey)),
+			QuoteWallet: string(oBkt.Get(quoteWalletKey)),
+			Notes:       string(oBkt.Get(noteKey)),
+			Tags:        tags,
 		},
 		Order: ord,
 	}, nil
@@ -476,6 +538,21 @@ func (db *BoltDB) UpdateOrderStatus(oid order.OrderID, status order.OrderStatus)
 	})
 }
 
+// SetOrderNotes sets the user's notes and tags for an order, replacing any
+// previous notes and tags.
+func (db *BoltDB) SetOrderNotes(oid order.OrderID, notes string, tags []string) error {
+	return db.ordersUpdate(func(master *bbolt.Bucket) error {
+		oBkt := master.Bucket(oid[:])
+		if oBkt == nil {
+			return fmt.Errorf("SetOrderNotes - order %s not found", oid)
+		}
+		return newBucketPutter(oBkt).
+			put(noteKey, []byte(notes)).
+			put(tagsKey, dexdb.EncodeTags(tags)).
+			err()
+	})
+}
+
 // ordersView is a convenience function for reading from the order bucket.
 func (db *BoltDB) ordersView(f bucketFunc) error {
 	return db.withBucket(ordersBucket, db.View, f)
@@ -577,6 +654,10 @@ func (db *BoltDB) filteredMatches(filter func(*bbolt.Bucket) bool) ([]*dexdb.Met
 				if len(statusB) != 1 {
 					return fmt.Errorf("expected status length 1, got %d", len(statusB))
 				}
+				tags, err := dexdb.DecodeTags(mBkt.Get(tagsKey))
+				if err != nil {
+					return fmt.Errorf("error decoding tags for match %x: %v", k, err)
+				}
 				matches = append(matches, &dexdb.MetaMatch{
 					MetaData: &dexdb.MatchMetaData{
 						Proof:  *proof,
@@ -584,6 +665,8 @@ func (db *BoltDB) filteredMatches(filter func(*bbolt.Bucket) bool) ([]*dexdb.Met
 						DEX:    string(mBkt.Get(dexKey)),
 						Base:   intCoder.Uint32(mBkt.Get(baseKey)),
 						Quote:  intCoder.Uint32(mBkt.Get(quoteKey)),
+						Notes:  string(mBkt.Get(noteKey)),
+						Tags:   tags,
 					},
 					Match: match,
 				})
@@ -594,6 +677,22 @@ func (db *BoltDB) filteredMatches(filter func(*bbolt.Bucket) bool) ([]*dexdb.Met
 	})
 }
 
+// SetMatchNotes sets the user's notes and tags for the order's match,
+// replacing any previous notes and tags.
+func (db *BoltDB) SetMatchNotes(oid order.OrderID, mid order.MatchID, notes string, tags []string) error {
+	metaID := (&dexdb.MetaMatch{Match: &order.UserMatch{OrderID: oid, MatchID: mid}}).ID()
+	return db.matchesUpdate(func(master *bbolt.Bucket) error {
+		mBkt := master.Bucket(metaID)
+		if mBkt == nil {
+			return fmt.Errorf("SetMatchNotes - match %s of order %s not found", mid, oid)
+		}
+		return newBucketPutter(mBkt).
+			put(noteKey, []byte(notes)).
+			put(tagsKey, dexdb.EncodeTags(tags)).
+			err()
+	})
+}
+
 // matchesView is a convenience function for reading from the match bucket.
 func (db *BoltDB) matchesView(f bucketFunc) error {
 	return db.withBucket(matchesBucket, db.View, f)
@@ -755,6 +854,145 @@ func (db *BoltDB) notesUpdate(f bucketFunc) error {
 	return db.withBucket(notesBucket, db.Update, f)
 }
 
+// SaveScheduledOrder saves the scheduled order.
+func (db *BoltDB) SaveScheduledOrder(sched *dexdb.ScheduledOrder) error {
+	return db.schedUpdate(func(master *bbolt.Bucket) error {
+		return master.Put(sched.ID(), sched.Encode())
+	})
+}
+
+// ScheduledOrders retrieves all scheduled orders.
+func (db *BoltDB) ScheduledOrders() ([]*dexdb.ScheduledOrder, error) {
+	scheds := make([]*dexdb.ScheduledOrder, 0)
+	return scheds, db.schedView(func(master *bbolt.Bucket) error {
+		return master.ForEach(func(_, v []byte) error {
+			sched, err := dexdb.DecodeScheduledOrder(v)
+			if err != nil {
+				return err
+			}
+			scheds = append(scheds, sched)
+			return nil
+		})
+	})
+}
+
+// DeleteScheduledOrder deletes the scheduled order with the specified ID.
+func (db *BoltDB) DeleteScheduledOrder(id []byte) error {
+	return db.schedUpdate(func(master *bbolt.Bucket) error {
+		if master.Get(id) == nil {
+			return fmt.Errorf("scheduled order %x not found", id)
+		}
+		return master.Delete(id)
+	})
+}
+
+// schedView is a convenience function to read from the scheduled orders
+// bucket.
+func (db *BoltDB) schedView(f bucketFunc) error {
+	return db.withBucket(schedBucket, db.View, f)
+}
+
+// schedUpdate is a convenience function for updating the scheduled orders
+// bucket.
+func (db *BoltDB) schedUpdate(f bucketFunc) error {
+	return db.withBucket(schedBucket, db.Update, f)
+}
+
+// SaveAlert saves the alert.
+func (db *BoltDB) SaveAlert(alert *dexdb.Alert) error {
+	return db.alertsUpdate(func(master *bbolt.Bucket) error {
+		return master.Put(alert.ID(), alert.Encode())
+	})
+}
+
+// Alerts retrieves all alerts.
+func (db *BoltDB) Alerts() ([]*dexdb.Alert, error) {
+	alerts := make([]*dexdb.Alert, 0)
+	return alerts, db.alertsView(func(master *bbolt.Bucket) error {
+		return master.ForEach(func(_, v []byte) error {
+			alert, err := dexdb.DecodeAlert(v)
+			if err != nil {
+				return err
+			}
+			alerts = append(alerts, alert)
+			return nil
+		})
+	})
+}
+
+// DeleteAlert deletes the alert with the specified ID.
+func (db *BoltDB) DeleteAlert(id []byte) error {
+	return db.alertsUpdate(func(master *bbolt.Bucket) error {
+		if master.Get(id) == nil {
+			return fmt.Errorf("alert %x not found", id)
+		}
+		return master.Delete(id)
+	})
+}
+
+// alertsView is a convenience function to read from the alerts bucket.
+func (db *BoltDB) alertsView(f bucketFunc) error {
+	return db.withBucket(alertsBucket, db.View, f)
+}
+
+// alertsUpdate is a convenience function for updating the alerts bucket.
+func (db *BoltDB) alertsUpdate(f bucketFunc) error {
+	return db.withBucket(alertsBucket, db.Update, f)
+}
+
+// StoreCandles caches candles for the market on the DEX at host, with bins of
+// binSize milliseconds. Each market and bin size has a nested bucket of candles
+// keyed by start stamp, so a stored candle with the same start stamp is
+// overwritten.
+func (db *BoltDB) StoreCandles(host string, base, quote uint32, binSize uint64, candles []*dexdb.Candle) error {
+	return db.candlesUpdate(func(master *bbolt.Bucket) error {
+		series, err := master.CreateBucketIfNotExists(dexdb.CandleSeries(host, base, quote, binSize))
+		if err != nil {
+			return fmt.Errorf("error creating candle series bucket: %v", err)
+		}
+		for _, candle := range candles {
+			err = series.Put(uint64Bytes(candle.StartStamp), candle.Encode())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Candles retrieves the cached candles for the market on the DEX at host, with
+// bins of binSize milliseconds and start stamps from start to end, inclusive.
+func (db *BoltDB) Candles(host string, base, quote uint32, binSize, start, end uint64) ([]*dexdb.Candle, error) {
+	candles := make([]*dexdb.Candle, 0)
+	return candles, db.candlesView(func(master *bbolt.Bucket) error {
+		series := master.Bucket(dexdb.CandleSeries(host, base, quote, binSize))
+		if series == nil {
+			return nil
+		}
+		// Keys are big-endian start stamps, so the cursor iterates in time order.
+		cursor := series.Cursor()
+		endB := uint64Bytes(end)
+		for k, v := cursor.Seek(uint64Bytes(start)); k != nil && bytes.Compare(k, endB) <= 0; k, v = cursor.Next() {
+			candle, err := dexdb.DecodeCandle(v)
+			if err != nil {
+				return err
+			}
+			candles = append(candles, candle)
+		}
+		return nil
+	})
+}
+
+// candlesView is a convenience function to read from the candles bucket.
+func (db *BoltDB) candlesView(f bucketFunc) error {
+	return db.withBucket(candlesBucket, db.View, f)
+}
+
+// candlesUpdate is a convenience function for updating the candles bucket.
+func (db *BoltDB) candlesUpdate(f bucketFunc) error {
+	return db.withBucket(candlesBucket, db.Update, f)
+}
+
 // Newest buckets gets the nested buckets with the hightest timestamp from the
 // specified master bucket. The nested bucket should have an encoded uint64 at
 // the timeKey. An optional filter function can be used to reject buckets.
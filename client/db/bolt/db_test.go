@@ -1,12 +1,14 @@
 package bolt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -221,6 +223,38 @@ func TestAccounts(t *testing.T) {
 	if !reAcct.Paid {
 		t.Fatalf("Account not marked as paid after account proof set")
 	}
+
+	// Update the DEX key.
+	newKey := dbtest.RandomAccountInfo().DEXPubKey
+	if err = boltdb.UpdateDEXPubKey(zerothHost, newKey); err != nil {
+		t.Fatalf("UpdateDEXPubKey error: %v", err)
+	}
+	reAcct, _ = boltdb.Account(zerothHost)
+	if !reAcct.DEXPubKey.IsEqual(newKey) {
+		t.Fatalf("DEX key not updated")
+	}
+	if !reAcct.Paid || !bytes.Equal(reAcct.EncKey, zerothAcct.EncKey) {
+		t.Fatalf("account changed by DEX key update")
+	}
+	if err = boltdb.UpdateDEXPubKey("unknownhost", newKey); err == nil {
+		t.Fatalf("no error updating the DEX key of an unknown account")
+	}
+
+	// Update the certificate.
+	newCert := []byte("new certificate")
+	if err = boltdb.UpdateCert(zerothHost, newCert); err != nil {
+		t.Fatalf("UpdateCert error: %v", err)
+	}
+	reAcct, _ = boltdb.Account(zerothHost)
+	if !bytes.Equal(reAcct.Cert, newCert) {
+		t.Fatalf("certificate not updated")
+	}
+	if !reAcct.DEXPubKey.IsEqual(newKey) || !bytes.Equal(reAcct.EncKey, zerothAcct.EncKey) {
+		t.Fatalf("account changed by certificate update")
+	}
+	if err = boltdb.UpdateCert("unknownhost", newCert); err == nil {
+		t.Fatalf("no error updating the certificate of an unknown account")
+	}
 }
 
 func TestWallets(t *testing.T) {
@@ -240,8 +274,12 @@ func TestWallets(t *testing.T) {
 	wallets = make([]*db.Wallet, 0, numToDo)
 	walletMap := make(map[string]*db.Wallet)
 	tStart := time.Now()
-	nTimes(numToDo, func(int) {
+	nTimes(numToDo, func(i int) {
 		w := dbtest.RandomWallet()
+		// Give every other wallet a name.
+		if i%2 == 0 {
+			w.Name = "spv"
+		}
 		wallets = append(wallets, w)
 		walletMap[w.SID()] = w
 		boltdb.UpdateWallet(w)
@@ -498,10 +536,19 @@ func TestOrders(t *testing.T) {
 	}
 	m.MetaData.Proof.DEXSig = sig
 
+	// The names of the order's wallets are saved.
+	m.MetaData.BaseWallet = "spv"
 	err = boltdb.UpdateOrder(m)
 	if err != nil {
 		t.Fatalf("error after order fixed: %v", err)
 	}
+	mord, err = boltdb.Order(m.Order.ID())
+	if err != nil {
+		t.Fatalf("error retrieving order: %v", err)
+	}
+	if mord.MetaData.BaseWallet != "spv" || mord.MetaData.QuoteWallet != "" {
+		t.Fatalf("wrong wallet names %q and %q", mord.MetaData.BaseWallet, mord.MetaData.QuoteWallet)
+	}
 
 	// Set the change coin for an order.
 	activeOrder := activeOrders[0].Order
@@ -533,6 +580,31 @@ func TestOrders(t *testing.T) {
 	if err == nil {
 		t.Fatalf("no error encountered for updating unknown order change coin")
 	}
+
+	// Set the notes and tags, which are not replaced by UpdateOrder.
+	err = boltdb.SetOrderNotes(m.Order.ID(), "manual", []string{"bot", "dca"})
+	if err != nil {
+		t.Fatalf("error setting order notes: %v", err)
+	}
+	if err = boltdb.UpdateOrder(m); err != nil {
+		t.Fatalf("error updating order: %v", err)
+	}
+	mord, _ = boltdb.Order(m.Order.ID())
+	if mord.MetaData.Notes != "manual" || !reflect.DeepEqual(mord.MetaData.Tags, []string{"bot", "dca"}) {
+		t.Fatalf("wrong notes %q and tags %v", mord.MetaData.Notes, mord.MetaData.Tags)
+	}
+	err = boltdb.SetOrderNotes(m.Order.ID(), "", nil)
+	if err != nil {
+		t.Fatalf("error clearing order notes: %v", err)
+	}
+	mord, _ = boltdb.Order(m.Order.ID())
+	if mord.MetaData.Notes != "" || len(mord.MetaData.Tags) != 0 {
+		t.Fatalf("notes %q and tags %v not cleared", mord.MetaData.Notes, mord.MetaData.Tags)
+	}
+	err = boltdb.SetOrderNotes(ordertest.RandomOrderID(), "manual", nil)
+	if err == nil {
+		t.Fatalf("no error encountered for setting unknown order notes")
+	}
 }
 
 func TestMatches(t *testing.T) {
@@ -619,6 +691,26 @@ func TestMatches(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error after fixing match: %v", err)
 	}
+
+	// Set the notes and tags, which are not replaced by UpdateMatch.
+	err = boltdb.SetMatchNotes(m.Match.OrderID, m.Match.MatchID, "hedged", []string{"arb"})
+	if err != nil {
+		t.Fatalf("error setting match notes: %v", err)
+	}
+	if err = boltdb.UpdateMatch(m); err != nil {
+		t.Fatalf("error updating match: %v", err)
+	}
+	matches, err := boltdb.MatchesForOrder(m.Match.OrderID)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("error retrieving matches: %v, %d", err, len(matches))
+	}
+	if md := matches[0].MetaData; md.Notes != "hedged" || !reflect.DeepEqual(md.Tags, []string{"arb"}) {
+		t.Fatalf("wrong notes %q and tags %v", md.Notes, md.Tags)
+	}
+	err = boltdb.SetMatchNotes(m.Match.OrderID, ordertest.RandomMatchID(), "hedged", nil)
+	if err == nil {
+		t.Fatalf("no error encountered for setting unknown match notes")
+	}
 }
 
 var randU32 = func() uint32 { return uint32(rand.Int31()) }
@@ -695,3 +787,177 @@ func TestNotifications(t *testing.T) {
 	}
 
 }
+
+func TestScheduledOrders(t *testing.T) {
+	boltdb := newTestDB(t)
+	scheds, err := boltdb.ScheduledOrders()
+	if err != nil {
+		t.Fatalf("error fetching empty scheduled orders: %v", err)
+	}
+	if len(scheds) != 0 {
+		t.Fatalf("expected no scheduled orders, got %d", len(scheds))
+	}
+
+	numToDo := 100
+	if testing.Short() {
+		numToDo = 5
+	}
+	saved := make(map[string]*db.ScheduledOrder, numToDo)
+	nTimes(numToDo, func(int) {
+		sched := dbtest.RandomScheduledOrder()
+		err := boltdb.SaveScheduledOrder(sched)
+		if err != nil {
+			t.Fatalf("SaveScheduledOrder error: %v", err)
+		}
+		saved[sched.ID().String()] = sched
+	})
+
+	scheds, err = boltdb.ScheduledOrders()
+	if err != nil {
+		t.Fatalf("ScheduledOrders error: %v", err)
+	}
+	if len(scheds) != numToDo {
+		t.Fatalf("expected %d scheduled orders, got %d", numToDo, len(scheds))
+	}
+	for _, sched := range scheds {
+		orig, found := saved[sched.ID().String()]
+		if !found {
+			t.Fatalf("unknown scheduled order %s", sched.ID())
+		}
+		dbtest.MustCompareScheduledOrders(t, sched, orig)
+	}
+
+	err = boltdb.DeleteScheduledOrder(scheds[0].ID())
+	if err != nil {
+		t.Fatalf("DeleteScheduledOrder error: %v", err)
+	}
+	err = boltdb.DeleteScheduledOrder(scheds[0].ID())
+	if err == nil {
+		t.Fatalf("no error for deleting unknown scheduled order")
+	}
+	scheds, _ = boltdb.ScheduledOrders()
+	if len(scheds) != numToDo-1 {
+		t.Fatalf("expected %d scheduled orders after delete, got %d", numToDo-1, len(scheds))
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	boltdb := newTestDB(t)
+	alerts, err := boltdb.Alerts()
+	if err != nil {
+		t.Fatalf("error fetching empty alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alerts, got %d", len(alerts))
+	}
+
+	numToDo := 100
+	if testing.Short() {
+		numToDo = 5
+	}
+	saved := make(map[string]*db.Alert, numToDo)
+	nTimes(numToDo, func(int) {
+		alert := dbtest.RandomAlert()
+		err := boltdb.SaveAlert(alert)
+		if err != nil {
+			t.Fatalf("SaveAlert error: %v", err)
+		}
+		saved[alert.ID().String()] = alert
+	})
+
+	alerts, err = boltdb.Alerts()
+	if err != nil {
+		t.Fatalf("Alerts error: %v", err)
+	}
+	if len(alerts) != numToDo {
+		t.Fatalf("expected %d alerts, got %d", numToDo, len(alerts))
+	}
+	for _, alert := range alerts {
+		orig, found := saved[alert.ID().String()]
+		if !found {
+			t.Fatalf("unknown alert %s", alert.ID())
+		}
+		dbtest.MustCompareAlerts(t, alert, orig)
+	}
+
+	err = boltdb.DeleteAlert(alerts[0].ID())
+	if err != nil {
+		t.Fatalf("DeleteAlert error: %v", err)
+	}
+	err = boltdb.DeleteAlert(alerts[0].ID())
+	if err == nil {
+		t.Fatalf("no error for deleting unknown alert")
+	}
+	alerts, _ = boltdb.Alerts()
+	if len(alerts) != numToDo-1 {
+		t.Fatalf("expected %d alerts after delete, got %d", numToDo-1, len(alerts))
+	}
+}
+
+func TestCandles(t *testing.T) {
+	boltdb := newTestDB(t)
+	host := "somedex.tld"
+	var binSize uint64 = 60000
+	candles, err := boltdb.Candles(host, 42, 0, binSize, 0, ^uint64(0))
+	if err != nil {
+		t.Fatalf("error fetching empty candles: %v", err)
+	}
+	if len(candles) != 0 {
+		t.Fatalf("expected no candles, got %d", len(candles))
+	}
+
+	numToDo := 100
+	saved := make([]*db.Candle, 0, numToDo)
+	for i := 0; i < numToDo; i++ {
+		saved = append(saved, dbtest.RandomCandle(uint64(i)*binSize, binSize))
+	}
+	err = boltdb.StoreCandles(host, 42, 0, binSize, saved)
+	if err != nil {
+		t.Fatalf("StoreCandles error: %v", err)
+	}
+	// Another bin size and market are stored separately.
+	err = boltdb.StoreCandles(host, 42, 0, binSize*5, []*db.Candle{dbtest.RandomCandle(0, binSize*5)})
+	if err != nil {
+		t.Fatalf("StoreCandles error for second bin size: %v", err)
+	}
+	err = boltdb.StoreCandles(host, 0, 42, binSize, []*db.Candle{dbtest.RandomCandle(binSize, binSize)})
+	if err != nil {
+		t.Fatalf("StoreCandles error for second market: %v", err)
+	}
+
+	candles, err = boltdb.Candles(host, 42, 0, binSize, 0, ^uint64(0))
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if len(candles) != numToDo {
+		t.Fatalf("expected %d candles, got %d", numToDo, len(candles))
+	}
+	for i, candle := range candles {
+		dbtest.MustCompareCandles(t, candle, saved[i])
+	}
+
+	// The range is inclusive of both ends.
+	candles, _ = boltdb.Candles(host, 42, 0, binSize, 10*binSize, 19*binSize)
+	if len(candles) != 10 {
+		t.Fatalf("expected 10 candles in range, got %d", len(candles))
+	}
+	dbtest.MustCompareCandles(t, candles[0], saved[10])
+	dbtest.MustCompareCandles(t, candles[9], saved[19])
+
+	// A candle with the same start stamp is overwritten.
+	update := dbtest.RandomCandle(saved[5].StartStamp, binSize)
+	err = boltdb.StoreCandles(host, 42, 0, binSize, []*db.Candle{update})
+	if err != nil {
+		t.Fatalf("StoreCandles error for update: %v", err)
+	}
+	candles, _ = boltdb.Candles(host, 42, 0, binSize, 0, ^uint64(0))
+	if len(candles) != numToDo {
+		t.Fatalf("expected %d candles after update, got %d", numToDo, len(candles))
+	}
+	dbtest.MustCompareCandles(t, candles[5], update)
+
+	candles, _ = boltdb.Candles("otherdex.tld", 42, 0, binSize, 0, ^uint64(0))
+	if len(candles) != 0 {
+		t.Fatalf("expected no candles for unknown host, got %d", len(candles))
+	}
+}
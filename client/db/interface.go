@@ -6,6 +6,7 @@ package db
 import (
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/order"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
 // DB is an interface that must be satisfied by a DEX client persistent storage
@@ -30,6 +31,11 @@ type DB interface {
 	CreateAccount(ai *AccountInfo) error
 	// AccountPaid marks the account as paid.
 	AccountPaid(proof *AccountProof) error
+	// UpdateDEXPubKey replaces the DEX's signing key for the account, after the
+	// DEX has rotated its key.
+	UpdateDEXPubKey(host string, pubKey *secp256k1.PublicKey) error
+	// UpdateCert replaces the DEX's TLS certificate for the account.
+	UpdateCert(host string, cert []byte) error
 	// UpdateOrder saves the order information in the database. Any existing
 	// order info will be overwritten without indication.
 	UpdateOrder(m *MetaOrder) error
@@ -61,6 +67,9 @@ type DB interface {
 	SetChangeCoin(order.OrderID, order.CoinID) error
 	// UpdateOrderStatus sets the order status for an order.
 	UpdateOrderStatus(oid order.OrderID, status order.OrderStatus) error
+	// SetOrderNotes sets the user's notes and tags for an order, replacing
+	// any previous notes and tags.
+	SetOrderNotes(oid order.OrderID, notes string, tags []string) error
 	// UpdateMatch updates the match information in the database. Any existing
 	// entry for the match will be overwritten without indication.
 	UpdateMatch(m *MetaMatch) error
@@ -72,6 +81,9 @@ type DB interface {
 	ActiveDEXMatches(dex string) ([]*MetaMatch, error)
 	// MatchesForOrder gets the matches for the order ID.
 	MatchesForOrder(oid order.OrderID) ([]*MetaMatch, error)
+	// SetMatchNotes sets the user's notes and tags for the order's match,
+	// replacing any previous notes and tags.
+	SetMatchNotes(oid order.OrderID, mid order.MatchID, notes string, tags []string) error
 	// Update wallets adds a wallet to the database, or updates the wallet
 	// credentials if the wallet already exists. A wallet is specified by the
 	// pair (asset ID, account name).
@@ -88,4 +100,24 @@ type DB interface {
 	NotificationsN(int) ([]*Notification, error)
 	// AckNotification sets the acknowledgement for a notification.
 	AckNotification(id []byte) error
+	// SaveScheduledOrder saves the scheduled order.
+	SaveScheduledOrder(*ScheduledOrder) error
+	// ScheduledOrders retrieves all scheduled orders.
+	ScheduledOrders() ([]*ScheduledOrder, error)
+	// DeleteScheduledOrder deletes the scheduled order with the specified ID.
+	DeleteScheduledOrder(id []byte) error
+	// SaveAlert saves the alert.
+	SaveAlert(*Alert) error
+	// Alerts retrieves all alerts.
+	Alerts() ([]*Alert, error)
+	// DeleteAlert deletes the alert with the specified ID.
+	DeleteAlert(id []byte) error
+	// StoreCandles caches candles for the market on the DEX at host, with bins
+	// of binSize milliseconds. A stored candle with the same start stamp is
+	// overwritten.
+	StoreCandles(host string, base, quote uint32, binSize uint64, candles []*Candle) error
+	// Candles retrieves the cached candles for the market on the DEX at host,
+	// with bins of binSize milliseconds and start stamps from start to end,
+	// inclusive. The candles are sorted by start stamp.
+	Candles(host string, base, quote uint32, binSize, start, end uint64) ([]*Candle, error)
 }
@@ -131,6 +131,9 @@ func RandomMatchProof(sparsity float64) *db.MatchProof {
 	if !doZero() {
 		proof.Auth.RedemptionStamp = rand.Uint64()
 	}
+	if !doZero() {
+		proof.Receipt = randBytes(300)
+	}
 	return proof
 }
 
@@ -145,6 +148,52 @@ func RandomNotification(maxTime uint64) *db.Notification {
 	}
 }
 
+func RandomScheduledOrder() *db.ScheduledOrder {
+	return &db.ScheduledOrder{
+		Host:        randString(50),
+		IsLimit:     rand.Intn(2) == 0,
+		Sell:        rand.Intn(2) == 0,
+		Base:        rand.Uint32(),
+		Quote:       rand.Uint32(),
+		Qty:         rand.Uint64(),
+		Rate:        rand.Uint64(),
+		TifNow:      rand.Intn(2) == 0,
+		Time:        rand.Uint64(),
+		TriggerRate: rand.Uint64(),
+		Above:       rand.Intn(2) == 0,
+		Stamp:       rand.Uint64(),
+	}
+}
+
+func RandomCandle(startStamp, binSize uint64) *db.Candle {
+	return &db.Candle{
+		StartStamp:  startStamp,
+		EndStamp:    startStamp + binSize,
+		MatchVolume: rand.Uint64(),
+		QuoteVolume: rand.Uint64(),
+		HighRate:    rand.Uint64(),
+		LowRate:     rand.Uint64(),
+		StartRate:   rand.Uint64(),
+		EndRate:     rand.Uint64(),
+	}
+}
+
+func RandomAlert() *db.Alert {
+	return &db.Alert{
+		Kind:    randString(5),
+		Host:    randString(50),
+		Base:    rand.Uint32(),
+		Quote:   rand.Uint32(),
+		Rate:    rand.Uint64(),
+		Above:   rand.Intn(2) == 0,
+		OrderID: randBytes(32),
+		Minutes: rand.Uint32(),
+		Webhook: randString(50),
+		Desktop: rand.Intn(2) == 0,
+		Stamp:   rand.Uint64(),
+	}
+}
+
 type testKiller interface {
 	Fatalf(string, ...interface{})
 }
@@ -208,6 +257,9 @@ func MustCompareMatchProof(t testKiller, m1, m2 *db.MatchProof) {
 	if !bytes.Equal(m1.TakerRedeem, m2.TakerRedeem) {
 		t.Fatalf("TakerRedeem mismatch. %x != %x", m1.TakerRedeem, m2.TakerRedeem)
 	}
+	if !bytes.Equal(m1.Receipt, m2.Receipt) {
+		t.Fatalf("Receipt mismatch. %x != %x", m1.Receipt, m2.Receipt)
+	}
 	MustCompareMatchAuth(t, &m1.Auth, &m2.Auth)
 }
 
@@ -246,6 +298,9 @@ func MustCompareWallets(t testKiller, w1, w2 *db.Wallet) {
 	if w1.AssetID != w2.AssetID {
 		t.Fatalf("AssetID mismatch. %d != %d", w1.AssetID, w2.AssetID)
 	}
+	if w1.Name != w2.Name {
+		t.Fatalf("Name mismatch. %s != %s", w1.Name, w2.Name)
+	}
 	if w1.Account != w2.Account {
 		t.Fatalf("Account mismatch. %s != %s", w1.Account, w2.Account)
 	}
@@ -315,3 +370,30 @@ func MustCompareNotifications(t testKiller, n1, n2 *db.Notification) {
 		t.Fatalf("ID mismatch. %s != %s", n1.ID(), n2.ID())
 	}
 }
+
+func MustCompareScheduledOrders(t testKiller, s1, s2 *db.ScheduledOrder) {
+	if *s1 != *s2 {
+		t.Fatalf("ScheduledOrder mismatch. %+v != %+v", s1, s2)
+	}
+	if !bytes.Equal(s1.ID(), s2.ID()) {
+		t.Fatalf("ID mismatch. %s != %s", s1.ID(), s2.ID())
+	}
+}
+
+func MustCompareCandles(t testKiller, c1, c2 *db.Candle) {
+	if *c1 != *c2 {
+		t.Fatalf("Candle mismatch. %+v != %+v", c1, c2)
+	}
+}
+
+func MustCompareAlerts(t testKiller, a1, a2 *db.Alert) {
+	if a1.Kind != a2.Kind || a1.Host != a2.Host || a1.Base != a2.Base || a1.Quote != a2.Quote ||
+		a1.Rate != a2.Rate || a1.Above != a2.Above || !bytes.Equal(a1.OrderID, a2.OrderID) ||
+		a1.Minutes != a2.Minutes || a1.Webhook != a2.Webhook || a1.Desktop != a2.Desktop ||
+		a1.Stamp != a2.Stamp {
+		t.Fatalf("Alert mismatch. %+v != %+v", a1, a2)
+	}
+	if !bytes.Equal(a1.ID(), a2.ID()) {
+		t.Fatalf("ID mismatch. %s != %s", a1.ID(), a2.ID())
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/dex/order"
+	ordertest "decred.org/dcrdex/dex/order/test"
 )
 
 func TestAccountInfo(t *testing.T) {
@@ -74,6 +76,44 @@ func TestOrderProof(t *testing.T) {
 	t.Logf("encoded, decoded, and compared %d OrderProof in %d ms", spins, time.Since(tStart)/time.Millisecond)
 }
 
+func TestScheduledOrder(t *testing.T) {
+	sched := RandomScheduledOrder()
+	reSched, err := db.DecodeScheduledOrder(sched.Encode())
+	if err != nil {
+		t.Fatalf("DecodeScheduledOrder error: %v", err)
+	}
+	MustCompareScheduledOrders(t, sched, reSched)
+
+	_, err = db.DecodeScheduledOrder(sched.Encode()[:20])
+	if err == nil {
+		t.Fatalf("no error for truncated blob")
+	}
+}
+
+func TestAlert(t *testing.T) {
+	alert := RandomAlert()
+	reAlert, err := db.DecodeAlert(alert.Encode())
+	if err != nil {
+		t.Fatalf("DecodeAlert error: %v", err)
+	}
+	MustCompareAlerts(t, alert, reAlert)
+
+	// Optional fields can be empty.
+	alert.OrderID = nil
+	alert.Webhook = ""
+	alert.Host = ""
+	reAlert, err = db.DecodeAlert(alert.Encode())
+	if err != nil {
+		t.Fatalf("DecodeAlert error for empty fields: %v", err)
+	}
+	MustCompareAlerts(t, alert, reAlert)
+
+	_, err = db.DecodeAlert(alert.Encode()[:20])
+	if err == nil {
+		t.Fatalf("no error for truncated blob")
+	}
+}
+
 func nTimes(n int, f func(int)) {
 	for i := 0; i < n; i++ {
 		f(i)
@@ -140,3 +180,88 @@ func TestAccountsBackupAndRestore(t *testing.T) {
 		}
 	}
 }
+
+func TestClientBackup(t *testing.T) {
+	bkp := new(db.ClientBackup)
+	for i := 0; i < 3; i++ {
+		acct := RandomAccountInfo()
+		acct.Paid = i%2 == 0
+		bkp.Accounts = append(bkp.Accounts, acct)
+		bkp.Wallets = append(bkp.Wallets, RandomWallet())
+	}
+	lo, _ := ordertest.RandomLimitOrder()
+	mo, _ := ordertest.RandomMarketOrder()
+	co, _ := ordertest.RandomCancelOrder()
+	for _, ord := range []order.Order{lo, mo, co} {
+		bkp.Orders = append(bkp.Orders, &db.MetaOrder{
+			MetaData: &db.OrderMetaData{
+				Status: order.OrderStatusExecuted,
+				Host:   randString(20),
+				Proof: db.OrderProof{
+					DEXSig:   randBytes(73),
+					Preimage: randBytes(32),
+				},
+				ChangeCoin: randBytes(36),
+			},
+			Order: ord,
+		})
+		bkp.Matches = append(bkp.Matches, &db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Status: order.MakerRedeemed,
+				Proof:  *RandomMatchProof(0.5),
+				DEX:    randString(20),
+				Base:   42,
+				Quote:  0,
+			},
+			Match: ordertest.RandomUserMatch(),
+		})
+	}
+
+	reBkp, err := db.DecodeClientBackup(bkp.Encode())
+	if err != nil {
+		t.Fatalf("error decoding ClientBackup: %v", err)
+	}
+	if len(reBkp.Accounts) != len(bkp.Accounts) || len(reBkp.Orders) != len(bkp.Orders) ||
+		len(reBkp.Matches) != len(bkp.Matches) || len(reBkp.Wallets) != len(bkp.Wallets) {
+		t.Fatalf("record count mismatch")
+	}
+	for i, acct := range bkp.Accounts {
+		MustCompareAccountInfo(t, acct, reBkp.Accounts[i])
+		if acct.Paid != reBkp.Accounts[i].Paid {
+			t.Fatalf("Paid mismatch. %t != %t", acct.Paid, reBkp.Accounts[i].Paid)
+		}
+	}
+	for i, mord := range bkp.Orders {
+		reOrd := reBkp.Orders[i]
+		if reOrd.MetaData.Status != mord.MetaData.Status || reOrd.MetaData.Host != mord.MetaData.Host {
+			t.Fatalf("order metadata mismatch")
+		}
+		if !bytes.Equal(reOrd.MetaData.ChangeCoin, mord.MetaData.ChangeCoin) {
+			t.Fatalf("ChangeCoin mismatch. %x != %x", reOrd.MetaData.ChangeCoin, mord.MetaData.ChangeCoin)
+		}
+		MustCompareOrderProof(t, &mord.MetaData.Proof, &reOrd.MetaData.Proof)
+		ordertest.MustCompareOrders(t, mord.Order, reOrd.Order)
+	}
+	for i, m := range bkp.Matches {
+		reMatch := reBkp.Matches[i]
+		md, reMD := m.MetaData, reMatch.MetaData
+		if md.Status != reMD.Status || md.DEX != reMD.DEX || md.Base != reMD.Base || md.Quote != reMD.Quote {
+			t.Fatalf("match metadata mismatch")
+		}
+		MustCompareMatchProof(t, &md.Proof, &reMD.Proof)
+		ordertest.MustCompareUserMatch(t, m.Match, reMatch.Match)
+	}
+	for i, w := range bkp.Wallets {
+		reWallet := reBkp.Wallets[i]
+		if reWallet.Balance != nil {
+			t.Fatalf("wallet balance was backed up")
+		}
+		reWallet.Balance = w.Balance
+		MustCompareWallets(t, reWallet, w)
+	}
+
+	// Truncating the records is an error.
+	if _, err := db.DecodeClientBackup(bkp.Encode()[:200]); err == nil {
+		t.Fatalf("no error for truncated backup")
+	}
+}
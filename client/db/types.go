@@ -180,6 +180,14 @@ type OrderMetaData struct {
 	// from the initiation transaction is used to fund the next match. The
 	// change from that matches ini tx funds the next match, etc.
 	ChangeCoin order.CoinID
+	// BaseWallet and QuoteWallet are the names of the wallets used for the
+	// order. An empty name is the asset's unnamed wallet.
+	BaseWallet  string
+	QuoteWallet string
+	// Notes and Tags are the user's annotations of the order. They are set
+	// with SetOrderNotes, and are not stored by UpdateOrder.
+	Notes string
+	Tags  []string
 }
 
 // MetaMatch is the match and its metadata.
@@ -207,6 +215,10 @@ type MatchMetaData struct {
 	Base uint32
 	// Quote is the quote asset of the exchange market.
 	Quote uint32
+	// Notes and Tags are the user's annotations of the match. They are set
+	// with SetMatchNotes, and are not stored by UpdateMatch.
+	Notes string
+	Tags  []string
 }
 
 // MatchAuth holds the DEX signatures and timestamps associated with the
@@ -236,8 +248,11 @@ type MatchProof struct {
 	TakerSwap     order.CoinID
 	TakerRedeem   order.CoinID
 	RefundCoin    order.CoinID
-	Auth          MatchAuth
-	IsRevoked     bool
+	// Receipt is the JSON-encoded settlement receipt for a completed match,
+	// including the server's signature.
+	Receipt   []byte
+	Auth      MatchAuth
+	IsRevoked bool
 }
 
 // Encode encodes the MatchProof to a versioned blob.
@@ -247,7 +262,7 @@ func (p *MatchProof) Encode() []byte {
 	if p.IsRevoked {
 		isRevoked = encode.ByteTrue
 	}
-	return dbBytes{0}.
+	return dbBytes{1}.
 		AddData(p.Script).
 		AddData(p.CounterScript).
 		AddData(p.SecretHash).
@@ -267,7 +282,8 @@ func (p *MatchProof) Encode() []byte {
 		AddData(uint64Bytes(auth.RedeemStamp)).
 		AddData(auth.RedemptionSig).
 		AddData(uint64Bytes(auth.RedemptionStamp)).
-		AddData(isRevoked)
+		AddData(isRevoked).
+		AddData(p.Receipt)
 }
 
 // DecodeMatchProof decodes the versioned blob to a *MatchProof.
@@ -279,6 +295,8 @@ func DecodeMatchProof(b []byte) (*MatchProof, error) {
 	switch ver {
 	case 0:
 		return decodeMatchProof_v0(pushes)
+	case 1:
+		return decodeMatchProof_v1(pushes)
 	}
 	return nil, fmt.Errorf("unknown MatchProof version %d", ver)
 }
@@ -313,6 +331,20 @@ func decodeMatchProof_v0(pushes [][]byte) (*MatchProof, error) {
 	}, nil
 }
 
+// decodeMatchProof_v1 decodes a version 1 MatchProof, which adds the
+// settlement receipt to version 0.
+func decodeMatchProof_v1(pushes [][]byte) (*MatchProof, error) {
+	if len(pushes) != 21 {
+		return nil, fmt.Errorf("decodeMatchProof_v1: expected 21 pushes, got %d", len(pushes))
+	}
+	proof, err := decodeMatchProof_v0(pushes[:20])
+	if err != nil {
+		return nil, err
+	}
+	proof.Receipt = pushes[20]
+	return proof, nil
+}
+
 // OrderProof is information related to order authentication and matching.
 type OrderProof struct {
 	DEXSig   []byte
@@ -347,6 +379,34 @@ func decodeOrderProof_v0(pushes [][]byte) (*OrderProof, error) {
 	}, nil
 }
 
+// EncodeTags serializes the tags of an order or match.
+func EncodeTags(tags []string) []byte {
+	b := dbBytes{0}
+	for _, tag := range tags {
+		b = b.AddData([]byte(tag))
+	}
+	return b
+}
+
+// DecodeTags decodes the versioned blob of tags. An empty blob is no tags.
+func DecodeTags(b []byte) ([]string, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	if ver != 0 {
+		return nil, fmt.Errorf("unknown tags version %d", ver)
+	}
+	tags := make([]string, 0, len(pushes))
+	for _, push := range pushes {
+		tags = append(tags, string(push))
+	}
+	return tags, nil
+}
+
 // encodeAssetBalance serializes an asset.Balance.
 func encodeAssetBalance(bal *asset.Balance) []byte {
 	return dbBytes{0}.
@@ -425,7 +485,10 @@ func decodeBalance_v0(pushes [][]byte) (*Balance, error) {
 
 // Wallet is information necessary to create an asset.Wallet.
 type Wallet struct {
-	AssetID     uint32
+	AssetID uint32
+	// Name distinguishes the wallets of an asset. The asset's first wallet has
+	// no name.
+	Name        string
 	Account     string
 	Settings    map[string]string
 	Balance     *Balance
@@ -435,12 +498,13 @@ type Wallet struct {
 
 // Encode encodes the Wallet to a versioned blob.
 func (w *Wallet) Encode() []byte {
-	return dbBytes{0}.
+	return dbBytes{1}.
 		AddData(uint32Bytes(w.AssetID)).
 		AddData([]byte(w.Account)).
 		AddData(config.Data(w.Settings)).
 		AddData(w.EncryptedPW).
-		AddData([]byte(w.Address))
+		AddData([]byte(w.Address)).
+		AddData([]byte(w.Name))
 }
 
 // DecodeWallet decodes the versioned blob to a *Wallet.
@@ -452,6 +516,8 @@ func DecodeWallet(b []byte) (*Wallet, error) {
 	switch ver {
 	case 0:
 		return decodeWallet_v0(pushes)
+	case 1:
+		return decodeWallet_v1(pushes)
 	}
 	return nil, fmt.Errorf("unknown DecodeWallet version %d", ver)
 }
@@ -475,14 +541,39 @@ func decodeWallet_v0(pushes [][]byte) (*Wallet, error) {
 	}, nil
 }
 
-// ID is the byte-encoded asset ID for this wallet.
+// decodeWallet_v1 decodes a version 1 wallet, which adds the wallet name to
+// version 0.
+func decodeWallet_v1(pushes [][]byte) (*Wallet, error) {
+	if len(pushes) != 6 {
+		return nil, fmt.Errorf("decodeWallet_v1: expected 6 pushes, got %d", len(pushes))
+	}
+	w, err := decodeWallet_v0(pushes[:5])
+	if err != nil {
+		return nil, err
+	}
+	w.Name = string(pushes[5])
+	return w, nil
+}
+
+// ID is the byte-encoded asset ID for this wallet, followed by the wallet name
+// if the wallet has one.
 func (w *Wallet) ID() []byte {
-	return uint32Bytes(w.AssetID)
+	return append(uint32Bytes(w.AssetID), w.Name...)
 }
 
-// SID is a string respresentation of the wallet's asset ID.
+// SID is a string respresentation of the wallet's ID, the asset ID for the
+// asset's unnamed wallet, or the asset ID and name separated by a colon.
 func (w *Wallet) SID() string {
-	return strconv.Itoa(int(w.AssetID))
+	return WalletSID(w.AssetID, w.Name)
+}
+
+// WalletSID is the string representation of the ID of the asset's wallet with
+// the name.
+func WalletSID(assetID uint32, name string) string {
+	if name == "" {
+		return strconv.Itoa(int(assetID))
+	}
+	return strconv.Itoa(int(assetID)) + ":" + name
 }
 
 type dbBytes = encode.BuildyBytes
@@ -584,6 +675,226 @@ func RestoreAccountBackup(path string) (*AccountBackup, error) {
 	return ab, nil
 }
 
+// ClientBackup is the accounts, order history, and wallet configuration of the
+// client database, for restoring on another machine. Wallet balances are not
+// included. The account keys and wallet passwords remain encrypted with the
+// application encryption key.
+type ClientBackup struct {
+	Accounts []*AccountInfo
+	Orders   []*MetaOrder
+	Matches  []*MetaMatch
+	Wallets  []*Wallet
+}
+
+// Encode encodes the ClientBackup to a versioned blob. Records are encoded as
+// individual pushes, following the number of each type of record.
+func (cb *ClientBackup) Encode() []byte {
+	b := dbBytes{0}.
+		AddData(uint32Bytes(uint32(len(cb.Accounts)))).
+		AddData(uint32Bytes(uint32(len(cb.Orders)))).
+		AddData(uint32Bytes(uint32(len(cb.Matches)))).
+		AddData(uint32Bytes(uint32(len(cb.Wallets))))
+	for _, acct := range cb.Accounts {
+		b = b.AddData(dbBytes{0}.AddData(acct.Encode()).AddData(boolBytes(acct.Paid)))
+	}
+	for _, ord := range cb.Orders {
+		b = b.AddData(encodeBackupOrder(ord))
+	}
+	for _, match := range cb.Matches {
+		b = b.AddData(encodeBackupMatch(match))
+	}
+	for _, wallet := range cb.Wallets {
+		b = b.AddData(wallet.Encode())
+	}
+	return b
+}
+
+// DecodeClientBackup decodes the versioned blob to a *ClientBackup.
+func DecodeClientBackup(b []byte) (*ClientBackup, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeClientBackup_v0(pushes)
+	}
+	return nil, fmt.Errorf("unknown ClientBackup version %d", ver)
+}
+
+func decodeClientBackup_v0(pushes [][]byte) (*ClientBackup, error) {
+	if len(pushes) < 4 {
+		return nil, fmt.Errorf("decodeClientBackup_v0: expected at least 4 pushes, got %d", len(pushes))
+	}
+	counts := make([]int, 4)
+	nRecords := 0
+	for i := range counts {
+		if len(pushes[i]) != 4 {
+			return nil, fmt.Errorf("decodeClientBackup_v0: push %d is supposed to be length 4. got %d", i, len(pushes[i]))
+		}
+		counts[i] = int(intCoder.Uint32(pushes[i]))
+		nRecords += counts[i]
+	}
+	records := pushes[4:]
+	if len(records) != nRecords {
+		return nil, fmt.Errorf("decodeClientBackup_v0: expected %d records, got %d", nRecords, len(records))
+	}
+	next := func(n int) [][]byte {
+		recs := records[:n]
+		records = records[n:]
+		return recs
+	}
+	cb := &ClientBackup{
+		Accounts: make([]*AccountInfo, 0, counts[0]),
+		Orders:   make([]*MetaOrder, 0, counts[1]),
+		Matches:  make([]*MetaMatch, 0, counts[2]),
+		Wallets:  make([]*Wallet, 0, counts[3]),
+	}
+	for _, b := range next(counts[0]) {
+		acct, err := decodeBackupAccount(b)
+		if err != nil {
+			return nil, err
+		}
+		cb.Accounts = append(cb.Accounts, acct)
+	}
+	for _, b := range next(counts[1]) {
+		ord, err := decodeBackupOrder(b)
+		if err != nil {
+			return nil, err
+		}
+		cb.Orders = append(cb.Orders, ord)
+	}
+	for _, b := range next(counts[2]) {
+		match, err := decodeBackupMatch(b)
+		if err != nil {
+			return nil, err
+		}
+		cb.Matches = append(cb.Matches, match)
+	}
+	for _, b := range next(counts[3]) {
+		wallet, err := DecodeWallet(b)
+		if err != nil {
+			return nil, err
+		}
+		cb.Wallets = append(cb.Wallets, wallet)
+	}
+	return cb, nil
+}
+
+// decodeBackupAccount decodes the versioned blob into an *AccountInfo with the
+// Paid flag set.
+func decodeBackupAccount(b []byte) (*AccountInfo, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	if ver != 0 {
+		return nil, fmt.Errorf("unknown backup account version %d", ver)
+	}
+	if len(pushes) != 2 {
+		return nil, fmt.Errorf("decodeBackupAccount: expected 2 pushes, got %d", len(pushes))
+	}
+	acct, err := DecodeAccountInfo(pushes[0])
+	if err != nil {
+		return nil, err
+	}
+	acct.Paid = bytes.Equal(pushes[1], encode.ByteTrue)
+	return acct, nil
+}
+
+// encodeBackupOrder encodes the *MetaOrder to a versioned blob.
+func encodeBackupOrder(mord *MetaOrder) []byte {
+	md := mord.MetaData
+	return dbBytes{0}.
+		AddData(encode.Uint16Bytes(uint16(md.Status))).
+		AddData([]byte(md.Host)).
+		AddData(md.Proof.Encode()).
+		AddData(md.ChangeCoin).
+		AddData(order.EncodeOrder(mord.Order))
+}
+
+// decodeBackupOrder decodes the versioned blob into a *MetaOrder.
+func decodeBackupOrder(b []byte) (*MetaOrder, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	if ver != 0 {
+		return nil, fmt.Errorf("unknown backup order version %d", ver)
+	}
+	if len(pushes) != 5 {
+		return nil, fmt.Errorf("decodeBackupOrder: expected 5 pushes, got %d", len(pushes))
+	}
+	if len(pushes[0]) != 2 {
+		return nil, fmt.Errorf("decodeBackupOrder: expected length 2 status, got %d", len(pushes[0]))
+	}
+	proof, err := DecodeOrderProof(pushes[2])
+	if err != nil {
+		return nil, err
+	}
+	ord, err := order.DecodeOrder(pushes[4])
+	if err != nil {
+		return nil, err
+	}
+	return &MetaOrder{
+		MetaData: &OrderMetaData{
+			Status:     order.OrderStatus(intCoder.Uint16(pushes[0])),
+			Host:       string(pushes[1]),
+			Proof:      *proof,
+			ChangeCoin: pushes[3],
+		},
+		Order: ord,
+	}, nil
+}
+
+// encodeBackupMatch encodes the *MetaMatch to a versioned blob.
+func encodeBackupMatch(m *MetaMatch) []byte {
+	md := m.MetaData
+	return dbBytes{0}.
+		AddData([]byte{byte(md.Status)}).
+		AddData(md.Proof.Encode()).
+		AddData([]byte(md.DEX)).
+		AddData(uint32Bytes(md.Base)).
+		AddData(uint32Bytes(md.Quote)).
+		AddData(order.EncodeMatch(m.Match))
+}
+
+// decodeBackupMatch decodes the versioned blob into a *MetaMatch.
+func decodeBackupMatch(b []byte) (*MetaMatch, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	if ver != 0 {
+		return nil, fmt.Errorf("unknown backup match version %d", ver)
+	}
+	if len(pushes) != 6 {
+		return nil, fmt.Errorf("decodeBackupMatch: expected 6 pushes, got %d", len(pushes))
+	}
+	if len(pushes[0]) != 1 || len(pushes[3]) != 4 || len(pushes[4]) != 4 {
+		return nil, fmt.Errorf("decodeBackupMatch: incorrect status/base/quote length %d/%d/%d",
+			len(pushes[0]), len(pushes[3]), len(pushes[4]))
+	}
+	proof, err := DecodeMatchProof(pushes[1])
+	if err != nil {
+		return nil, err
+	}
+	match, err := order.DecodeMatch(pushes[5])
+	if err != nil {
+		return nil, err
+	}
+	return &MetaMatch{
+		MetaData: &MatchMetaData{
+			Status: order.MatchStatus(pushes[0][0]),
+			Proof:  *proof,
+			DEX:    string(pushes[2]),
+			Base:   intCoder.Uint32(pushes[3]),
+			Quote:  intCoder.Uint32(pushes[4]),
+		},
+		Match: match,
+	}, nil
+}
+
 // Notification is information for the user that is typically meant for display,
 // and is persisted for recall across sessions.
 type Notification struct {
@@ -594,6 +905,15 @@ type Notification struct {
 	TimeStamp   uint64    `json:"stamp"`
 	Ack         bool      `json:"acked"`
 	Id          dex.Bytes `json:"id"`
+	// LogOnly and Desktop are display hints set by the client's notification
+	// routing. They are not stored.
+	LogOnly bool `json:"logonly,omitempty"`
+	Desktop bool `json:"desktop,omitempty"`
+	// Topic identifies the kind of notification, and Args are the arguments
+	// of its subject and details, so that they can be rendered in another
+	// language. They are not stored.
+	Topic string        `json:"topic,omitempty"`
+	Args  []interface{} `json:"-"`
 }
 
 // NewNotification is a constructor for a Notification.
@@ -720,6 +1040,250 @@ func (n *Notification) Encode() []byte {
 		AddData(uint64Bytes(n.TimeStamp))
 }
 
+// ScheduledOrder is an order that the client will place when its conditions
+// are met. If Time is set, the order is placed no earlier than Time. If
+// TriggerRate is set, the order is placed once the market rate reaches
+// TriggerRate from below if Above is true, or from above if Above is false.
+type ScheduledOrder struct {
+	Host        string
+	IsLimit     bool
+	Sell        bool
+	Base        uint32
+	Quote       uint32
+	Qty         uint64
+	Rate        uint64
+	TifNow      bool
+	Time        uint64
+	TriggerRate uint64
+	Above       bool
+	Stamp       uint64
+}
+
+// ID is a unique ID based on a hash of the scheduled order data.
+func (s *ScheduledOrder) ID() dex.Bytes {
+	return noteKey(s.Encode())
+}
+
+// Encode encodes the ScheduledOrder to a versioned blob.
+func (s *ScheduledOrder) Encode() []byte {
+	return dbBytes{0}.
+		AddData([]byte(s.Host)).
+		AddData(boolBytes(s.IsLimit)).
+		AddData(boolBytes(s.Sell)).
+		AddData(uint32Bytes(s.Base)).
+		AddData(uint32Bytes(s.Quote)).
+		AddData(uint64Bytes(s.Qty)).
+		AddData(uint64Bytes(s.Rate)).
+		AddData(boolBytes(s.TifNow)).
+		AddData(uint64Bytes(s.Time)).
+		AddData(uint64Bytes(s.TriggerRate)).
+		AddData(boolBytes(s.Above)).
+		AddData(uint64Bytes(s.Stamp))
+}
+
+// DecodeScheduledOrder decodes the versioned blob to a *ScheduledOrder.
+func DecodeScheduledOrder(b []byte) (*ScheduledOrder, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeScheduledOrder_v0(pushes)
+	}
+	return nil, fmt.Errorf("unknown DecodeScheduledOrder version %d", ver)
+}
+
+func decodeScheduledOrder_v0(pushes [][]byte) (*ScheduledOrder, error) {
+	if len(pushes) != 12 {
+		return nil, fmt.Errorf("decodeScheduledOrder_v0: expected 12 pushes, got %d", len(pushes))
+	}
+	for _, i := range []int{3, 4} {
+		if len(pushes[i]) != 4 {
+			return nil, fmt.Errorf("decodeScheduledOrder_v0: push %d is supposed to be length 4. got %d", i, len(pushes[i]))
+		}
+	}
+	for _, i := range []int{5, 6, 8, 9, 11} {
+		if len(pushes[i]) != 8 {
+			return nil, fmt.Errorf("decodeScheduledOrder_v0: push %d is supposed to be length 8. got %d", i, len(pushes[i]))
+		}
+	}
+	return &ScheduledOrder{
+		Host:        string(pushes[0]),
+		IsLimit:     bytes.Equal(pushes[1], encode.ByteTrue),
+		Sell:        bytes.Equal(pushes[2], encode.ByteTrue),
+		Base:        intCoder.Uint32(pushes[3]),
+		Quote:       intCoder.Uint32(pushes[4]),
+		Qty:         intCoder.Uint64(pushes[5]),
+		Rate:        intCoder.Uint64(pushes[6]),
+		TifNow:      bytes.Equal(pushes[7], encode.ByteTrue),
+		Time:        intCoder.Uint64(pushes[8]),
+		TriggerRate: intCoder.Uint64(pushes[9]),
+		Above:       bytes.Equal(pushes[10], encode.ByteTrue),
+		Stamp:       intCoder.Uint64(pushes[11]),
+	}, nil
+}
+
+// Alert is a user-defined condition that the client notifies the user about.
+// Kind determines which of the other fields are used. A price alert is for the
+// market Host, Base, and Quote, and fires once the market rate reaches Rate,
+// from below if Above is true, or from above if Above is false. An order fill
+// alert fires when the order with OrderID is filled. A swap alert fires when a
+// match on Host, or on any DEX if Host is empty, has been waiting on a swap
+// step for longer than Minutes. Webhook is an optional URL that receives the
+// notification, and Desktop requests a desktop notification from the UI.
+type Alert struct {
+	Kind    string
+	Host    string
+	Base    uint32
+	Quote   uint32
+	Rate    uint64
+	Above   bool
+	OrderID []byte
+	Minutes uint32
+	Webhook string
+	Desktop bool
+	Stamp   uint64
+}
+
+// ID is a unique ID based on a hash of the alert data.
+func (a *Alert) ID() dex.Bytes {
+	return noteKey(a.Encode())
+}
+
+// Encode encodes the Alert to a versioned blob.
+func (a *Alert) Encode() []byte {
+	return dbBytes{0}.
+		AddData([]byte(a.Kind)).
+		AddData([]byte(a.Host)).
+		AddData(uint32Bytes(a.Base)).
+		AddData(uint32Bytes(a.Quote)).
+		AddData(uint64Bytes(a.Rate)).
+		AddData(boolBytes(a.Above)).
+		AddData(a.OrderID).
+		AddData(uint32Bytes(a.Minutes)).
+		AddData([]byte(a.Webhook)).
+		AddData(boolBytes(a.Desktop)).
+		AddData(uint64Bytes(a.Stamp))
+}
+
+// DecodeAlert decodes the versioned blob to an *Alert.
+func DecodeAlert(b []byte) (*Alert, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeAlert_v0(pushes)
+	}
+	return nil, fmt.Errorf("unknown DecodeAlert version %d", ver)
+}
+
+func decodeAlert_v0(pushes [][]byte) (*Alert, error) {
+	if len(pushes) != 11 {
+		return nil, fmt.Errorf("decodeAlert_v0: expected 11 pushes, got %d", len(pushes))
+	}
+	for _, i := range []int{2, 3, 7} {
+		if len(pushes[i]) != 4 {
+			return nil, fmt.Errorf("decodeAlert_v0: push %d is supposed to be length 4. got %d", i, len(pushes[i]))
+		}
+	}
+	for _, i := range []int{4, 10} {
+		if len(pushes[i]) != 8 {
+			return nil, fmt.Errorf("decodeAlert_v0: push %d is supposed to be length 8. got %d", i, len(pushes[i]))
+		}
+	}
+	return &Alert{
+		Kind:    string(pushes[0]),
+		Host:    string(pushes[1]),
+		Base:    intCoder.Uint32(pushes[2]),
+		Quote:   intCoder.Uint32(pushes[3]),
+		Rate:    intCoder.Uint64(pushes[4]),
+		Above:   bytes.Equal(pushes[5], encode.ByteTrue),
+		OrderID: pushes[6],
+		Minutes: intCoder.Uint32(pushes[7]),
+		Webhook: string(pushes[8]),
+		Desktop: bytes.Equal(pushes[9], encode.ByteTrue),
+		Stamp:   intCoder.Uint64(pushes[10]),
+	}, nil
+}
+
+// Candle is cached OHLC data for one bin of a market's candlestick chart. Stamps
+// are in milliseconds, and MatchVolume and QuoteVolume are in atoms of the base
+// and quote asset respectively.
+type Candle struct {
+	StartStamp  uint64
+	EndStamp    uint64
+	MatchVolume uint64
+	QuoteVolume uint64
+	HighRate    uint64
+	LowRate     uint64
+	StartRate   uint64
+	EndRate     uint64
+}
+
+// Encode encodes the Candle to a versioned blob.
+func (c *Candle) Encode() []byte {
+	return dbBytes{0}.
+		AddData(uint64Bytes(c.StartStamp)).
+		AddData(uint64Bytes(c.EndStamp)).
+		AddData(uint64Bytes(c.MatchVolume)).
+		AddData(uint64Bytes(c.QuoteVolume)).
+		AddData(uint64Bytes(c.HighRate)).
+		AddData(uint64Bytes(c.LowRate)).
+		AddData(uint64Bytes(c.StartRate)).
+		AddData(uint64Bytes(c.EndRate))
+}
+
+// DecodeCandle decodes the versioned blob to a *Candle.
+func DecodeCandle(b []byte) (*Candle, error) {
+	ver, pushes, err := encode.DecodeBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	switch ver {
+	case 0:
+		return decodeCandle_v0(pushes)
+	}
+	return nil, fmt.Errorf("unknown DecodeCandle version %d", ver)
+}
+
+func decodeCandle_v0(pushes [][]byte) (*Candle, error) {
+	if len(pushes) != 8 {
+		return nil, fmt.Errorf("decodeCandle_v0: expected 8 pushes, got %d", len(pushes))
+	}
+	for i, push := range pushes {
+		if len(push) != 8 {
+			return nil, fmt.Errorf("decodeCandle_v0: push %d is supposed to be length 8. got %d", i, len(push))
+		}
+	}
+	return &Candle{
+		StartStamp:  intCoder.Uint64(pushes[0]),
+		EndStamp:    intCoder.Uint64(pushes[1]),
+		MatchVolume: intCoder.Uint64(pushes[2]),
+		QuoteVolume: intCoder.Uint64(pushes[3]),
+		HighRate:    intCoder.Uint64(pushes[4]),
+		LowRate:     intCoder.Uint64(pushes[5]),
+		StartRate:   intCoder.Uint64(pushes[6]),
+		EndRate:     intCoder.Uint64(pushes[7]),
+	}, nil
+}
+
+// CandleSeries is a unique key for a market's candles with bins of binSize
+// milliseconds on the DEX at host.
+func CandleSeries(host string, base, quote uint32, binSize uint64) []byte {
+	return []byte(fmt.Sprintf("%s|%d-%d|%d", host, base, quote, binSize))
+}
+
+// boolBytes encodes the bool as a single byte.
+func boolBytes(b bool) []byte {
+	if b {
+		return encode.ByteTrue
+	}
+	return encode.ByteFalse
+}
+
 // noteKeySize must be <= 32.
 const noteKeySize = 8
 
@@ -0,0 +1,491 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package mm provides market maker bots that provide liquidity to DEX markets
+// through the client core. Each bot quotes a single market using a pluggable
+// Strategy, and places and cancels standing limit orders to follow the
+// Strategy's targets, within the budget and exposure limits of the bot's
+// configuration.
+package mm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+	"github.com/decred/slog"
+)
+
+// defaultInterval is how often a bot updates its orders if the market's epoch
+// duration is not known.
+const defaultInterval = 10 * time.Second
+
+// OrderTag is the tag of the orders placed by bots, which distinguishes them
+// from manual orders in the order history.
+const OrderTag = "bot"
+
+var _ clientCore = (*core.Core)(nil)
+
+// clientCore is satisfied by core.Core.
+type clientCore interface {
+	Exchanges() map[string]*core.Exchange
+	Sync(host string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error)
+	Book(host string, base, quote uint32) (*core.OrderBook, error)
+	Trade(pw []byte, form *core.TradeForm) (*core.Order, error)
+	Cancel(pw []byte, orderID string) error
+}
+
+// BotConfig is the configuration of a market maker bot. Spread is the
+// distance between the bot's buy and sell rates, as a fraction of the center
+// rate chosen by the Strategy, and Lots is the size of the order on each side.
+// BaseBudget and QuoteBudget are the most the bot will commit to open sell and
+// buy orders, respectively, in atoms. A zero budget disables that side.
+// MaxExposure limits the net quantity of base asset, in atoms, that the bot
+// can accumulate or sell off through filled orders. Once reached, the bot
+// only quotes the side that reduces its position. A zero MaxExposure is no
+// limit. An order is replaced when the Strategy's target rate drifts from the
+// order's rate by more than DriftTolerance, as a fraction of the target rate.
+// DriftTolerance defaults to a quarter of the Spread.
+type BotConfig struct {
+	Host           string  `json:"host"`
+	Base           uint32  `json:"base"`
+	Quote          uint32  `json:"quote"`
+	Strategy       string  `json:"strategy"`
+	Spread         float64 `json:"spread"`
+	Lots           uint64  `json:"lots"`
+	BaseBudget     uint64  `json:"basebudget"`
+	QuoteBudget    uint64  `json:"quotebudget"`
+	MaxExposure    uint64  `json:"maxexposure"`
+	DriftTolerance float64 `json:"drifttolerance"`
+}
+
+// BotStatus is the state of a running bot. Position is the net quantity of
+// base asset acquired through filled orders. BaseCommitted and QuoteCommitted
+// are the amounts locked in the bot's open orders. Error is the most recent
+// error encountered by the bot, if any.
+type BotStatus struct {
+	ID             uint64     `json:"id"`
+	Config         *BotConfig `json:"config"`
+	Position       int64      `json:"position"`
+	BaseCommitted  uint64     `json:"basecommitted"`
+	QuoteCommitted uint64     `json:"quotecommitted"`
+	Orders         []string   `json:"orders"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// MarketMaker runs market maker bots.
+type MarketMaker struct {
+	core  clientCore
+	rates core.RateSource
+	log   slog.Logger
+
+	mtx    sync.Mutex
+	ctx    context.Context
+	bots   map[uint64]*bot
+	nextID uint64
+	wg     sync.WaitGroup
+}
+
+// New is the constructor for a MarketMaker. rates is the source of fiat
+// exchange rates for the oracle-pegged strategy, and may be nil.
+func New(c clientCore, rates core.RateSource, logger slog.Logger) *MarketMaker {
+	return &MarketMaker{
+		core:  c,
+		rates: rates,
+		log:   logger,
+		bots:  make(map[uint64]*bot),
+	}
+}
+
+// Run runs the MarketMaker until the context is canceled. Bots can only be
+// started while the MarketMaker is running, and are stopped when Run returns.
+func (m *MarketMaker) Run(ctx context.Context) {
+	m.mtx.Lock()
+	m.ctx = ctx
+	m.mtx.Unlock()
+	<-ctx.Done()
+	m.wg.Wait()
+	m.mtx.Lock()
+	m.bots = make(map[uint64]*bot)
+	m.ctx = nil
+	m.mtx.Unlock()
+}
+
+// Start validates the configuration and starts a bot. The app password is
+// retained by the bot to place and cancel orders until the bot is stopped.
+func (m *MarketMaker) Start(pw []byte, cfg *BotConfig) (uint64, error) {
+	switch {
+	case cfg.Lots == 0:
+		return 0, fmt.Errorf("zero lots not allowed")
+	case cfg.Spread <= 0 || cfg.Spread >= 1:
+		return 0, fmt.Errorf("spread must be between 0 and 1")
+	case cfg.DriftTolerance < 0 || cfg.DriftTolerance >= 1:
+		return 0, fmt.Errorf("drift tolerance must be between 0 and 1")
+	case cfg.BaseBudget == 0 && cfg.QuoteBudget == 0:
+		return 0, fmt.Errorf("no budget")
+	}
+	xc, found := m.core.Exchanges()[cfg.Host]
+	if !found {
+		return 0, fmt.Errorf("unknown DEX %s", cfg.Host)
+	}
+	var mkt *core.Market
+	for _, mk := range xc.Markets {
+		if mk.BaseID == cfg.Base && mk.QuoteID == cfg.Quote {
+			mkt = mk
+			break
+		}
+	}
+	baseAsset := xc.Assets[cfg.Base]
+	if mkt == nil || baseAsset == nil {
+		return 0, fmt.Errorf("unknown market %d-%d on %s", cfg.Base, cfg.Quote, cfg.Host)
+	}
+	strategy, err := newStrategy(cfg, m.rates)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.ctx == nil {
+		return 0, fmt.Errorf("market maker is not running")
+	}
+	_, feed, err := m.core.Sync(cfg.Host, cfg.Base, cfg.Quote)
+	if err != nil {
+		return 0, fmt.Errorf("error syncing order book: %v", err)
+	}
+	interval := time.Duration(mkt.EpochLen) * time.Millisecond
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	m.nextID++
+	cfgCopy := *cfg
+	ctx, cancel := context.WithCancel(m.ctx)
+	b := &bot{
+		id:       m.nextID,
+		cfg:      &cfgCopy,
+		strategy: strategy,
+		core:     m.core,
+		log:      m.log,
+		pw:       append([]byte(nil), pw...),
+		lotSize:  baseAsset.LotSize,
+		rateStep: baseAsset.RateStep,
+		orders:   make(map[string]*core.Order),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	m.bots[b.id] = b
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		b.run(ctx, feed, interval)
+	}()
+	m.log.Infof("Started %s bot %d on %s market %s", cfg.Strategy, b.id, cfg.Host, mkt.Name)
+	return b.id, nil
+}
+
+// Stop stops the bot and cancels its open orders.
+func (m *MarketMaker) Stop(id uint64) error {
+	m.mtx.Lock()
+	b, found := m.bots[id]
+	delete(m.bots, id)
+	m.mtx.Unlock()
+	if !found {
+		return fmt.Errorf("unknown bot %d", id)
+	}
+	b.cancel()
+	<-b.done
+	m.log.Infof("Stopped bot %d", id)
+	return nil
+}
+
+// Bots lists the running bots, ordered by ID.
+func (m *MarketMaker) Bots() []*BotStatus {
+	m.mtx.Lock()
+	bots := make([]*bot, 0, len(m.bots))
+	for _, b := range m.bots {
+		bots = append(bots, b)
+	}
+	m.mtx.Unlock()
+	sort.Slice(bots, func(i, j int) bool {
+		return bots[i].id < bots[j].id
+	})
+	statuses := make([]*BotStatus, 0, len(bots))
+	for _, b := range bots {
+		statuses = append(statuses, b.status())
+	}
+	return statuses
+}
+
+// bot is a market maker for a single market.
+type bot struct {
+	id       uint64
+	cfg      *BotConfig
+	strategy Strategy
+	core     clientCore
+	log      slog.Logger
+	pw       []byte
+	lotSize  uint64
+	rateStep uint64
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mtx      sync.RWMutex
+	orders   map[string]*core.Order
+	position int64
+	lastErr  error
+}
+
+// run updates the bot's orders every interval until the context is canceled,
+// then cancels the bot's open orders. The book feed keeps the order book
+// synced, but its updates are not otherwise used.
+func (b *bot) run(ctx context.Context, feed *core.BookFeed, interval time.Duration) {
+	defer func() {
+		feed.Close()
+		b.shutdown()
+		close(b.done)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	b.step()
+	for {
+		select {
+		case <-feed.C:
+		case <-ticker.C:
+			b.step()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// step refreshes the bot's orders and position, then cancels the orders that
+// no longer match the strategy's targets, and places new orders for the
+// targets that are not yet met.
+func (b *bot) step() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.refresh()
+
+	book, err := b.core.Book(b.cfg.Host, b.cfg.Base, b.cfg.Quote)
+	if err != nil {
+		b.setErr(fmt.Errorf("error retrieving order book: %v", err))
+		return
+	}
+	targets, err := b.strategy.Targets(&MarketState{
+		Host:     b.cfg.Host,
+		Base:     b.cfg.Base,
+		Quote:    b.cfg.Quote,
+		LotSize:  b.lotSize,
+		RateStep: b.rateStep,
+		Book:     book,
+		Position: b.position,
+	})
+	if err != nil {
+		b.setErr(fmt.Errorf("strategy error: %v", err))
+		return
+	}
+	targets = b.limitExposure(targets)
+	b.lastErr = nil
+
+	tolerance := b.cfg.DriftTolerance
+	if tolerance == 0 {
+		tolerance = b.cfg.Spread / 4
+	}
+	met := make([]bool, len(targets))
+	for oid, ord := range b.orders {
+		if ord.Cancelling {
+			continue
+		}
+		keep := false
+		for i, t := range targets {
+			if !met[i] && t.Sell == ord.Sell && withinTolerance(ord.Rate, t.Rate, tolerance) {
+				met[i], keep = true, true
+				break
+			}
+		}
+		if keep {
+			continue
+		}
+		if err := b.core.Cancel(b.pw, oid); err != nil {
+			b.setErr(fmt.Errorf("error canceling order %s: %v", oid, err))
+			continue
+		}
+		ord.Cancelling = true
+	}
+
+	baseCommitted, quoteCommitted := b.committed()
+	for i, t := range targets {
+		if met[i] || t.Lots == 0 {
+			continue
+		}
+		qty := t.Lots * b.lotSize
+		if t.Sell {
+			if baseCommitted+qty > b.cfg.BaseBudget {
+				continue
+			}
+		} else if quoteCommitted+calc.BaseToQuote(t.Rate, qty) > b.cfg.QuoteBudget {
+			continue
+		}
+		ord, err := b.core.Trade(b.pw, &core.TradeForm{
+			Host:    b.cfg.Host,
+			IsLimit: true,
+			Sell:    t.Sell,
+			Base:    b.cfg.Base,
+			Quote:   b.cfg.Quote,
+			Qty:     qty,
+			Rate:    t.Rate,
+			Notes:   fmt.Sprintf("%s bot %d", b.cfg.Strategy, b.id),
+			Tags:    []string{OrderTag},
+			// The bot's orders are priced by its strategy and often
+			// repeat, so the trade guard's warnings don't apply.
+			Confirmed: true,
+		})
+		if err != nil {
+			b.setErr(fmt.Errorf("error placing order: %v", err))
+			continue
+		}
+		b.orders[ord.ID] = ord
+		if t.Sell {
+			baseCommitted += qty
+		} else {
+			quoteCommitted += calc.BaseToQuote(t.Rate, qty)
+		}
+	}
+}
+
+// refresh updates the bot's orders from the core, and adds any new fills to
+// the bot's position. Orders that are no longer active are dropped. refresh
+// should be called with the mtx locked.
+func (b *bot) refresh() {
+	current := make(map[string]*core.Order)
+	if xc, found := b.core.Exchanges()[b.cfg.Host]; found {
+		for _, mkt := range xc.Markets {
+			if mkt.BaseID != b.cfg.Base || mkt.QuoteID != b.cfg.Quote {
+				continue
+			}
+			for _, ord := range mkt.Orders {
+				if _, found := b.orders[ord.ID]; found {
+					current[ord.ID] = ord
+				}
+			}
+		}
+	}
+	for oid, ord := range b.orders {
+		update, found := current[oid]
+		if !found {
+			delete(b.orders, oid)
+			continue
+		}
+		if update.Filled > ord.Filled {
+			fill := int64(update.Filled - ord.Filled)
+			if ord.Sell {
+				fill = -fill
+			}
+			b.position += fill
+		}
+		if update.Status > order.OrderStatusBooked {
+			delete(b.orders, oid)
+			continue
+		}
+		// A cancel request that has not yet been processed is not reflected in
+		// the core's order.
+		o := *update
+		o.Cancelling = o.Cancelling || ord.Cancelling
+		b.orders[oid] = &o
+	}
+}
+
+// limitExposure removes the targets that would increase the bot's position
+// beyond the MaxExposure.
+func (b *bot) limitExposure(targets []*OrderTarget) []*OrderTarget {
+	maxExposure := int64(b.cfg.MaxExposure)
+	if maxExposure == 0 {
+		return targets
+	}
+	limited := make([]*OrderTarget, 0, len(targets))
+	for _, t := range targets {
+		if (t.Sell && b.position <= -maxExposure) || (!t.Sell && b.position >= maxExposure) {
+			continue
+		}
+		limited = append(limited, t)
+	}
+	return limited
+}
+
+// committed sums the unfilled quantities of the bot's open sell orders, in
+// base asset, and open buy orders, in quote asset. committed should be called
+// with the mtx locked.
+func (b *bot) committed() (base, quote uint64) {
+	for _, ord := range b.orders {
+		remaining := ord.Qty - ord.Filled
+		if ord.Sell {
+			base += remaining
+		} else {
+			quote += calc.BaseToQuote(ord.Rate, remaining)
+		}
+	}
+	return
+}
+
+// setErr logs and records the error. setErr should be called with the mtx
+// locked.
+func (b *bot) setErr(err error) {
+	b.log.Errorf("bot %d: %v", b.id, err)
+	b.lastErr = err
+}
+
+// shutdown cancels the bot's open orders and clears the password.
+func (b *bot) shutdown() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for oid, ord := range b.orders {
+		if ord.Cancelling {
+			continue
+		}
+		if err := b.core.Cancel(b.pw, oid); err != nil {
+			b.log.Errorf("bot %d: error canceling order %s: %v", b.id, oid, err)
+		}
+	}
+	for i := range b.pw {
+		b.pw[i] = 0
+	}
+}
+
+// status creates a BotStatus for the bot.
+func (b *bot) status() *BotStatus {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	base, quote := b.committed()
+	cfg := *b.cfg
+	status := &BotStatus{
+		ID:             b.id,
+		Config:         &cfg,
+		Position:       b.position,
+		BaseCommitted:  base,
+		QuoteCommitted: quote,
+		Orders:         make([]string, 0, len(b.orders)),
+	}
+	for oid := range b.orders {
+		status.Orders = append(status.Orders, oid)
+	}
+	sort.Strings(status.Orders)
+	if b.lastErr != nil {
+		status.Error = b.lastErr.Error()
+	}
+	return status
+}
+
+// withinTolerance checks whether rate is within tolerance of the target, as a
+// fraction of the target.
+func withinTolerance(rate, target uint64, tolerance float64) bool {
+	diff := float64(rate) - float64(target)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= float64(target)*tolerance
+}
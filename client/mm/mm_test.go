@@ -0,0 +1,403 @@
+package mm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
+	"github.com/decred/slog"
+)
+
+const (
+	tHost    = "somedex.tld:7232"
+	tBase    = 42
+	tQuote   = 0
+	tLotSize = 1e8
+)
+
+var (
+	tLogger = slog.NewBackend(os.Stdout).Logger("TEST")
+	tPW     = []byte("pass")
+)
+
+type tCore struct {
+	mtx       sync.Mutex
+	book      *core.OrderBook
+	orders    map[string]*core.Order
+	trades    []*core.TradeForm
+	cancels   []string
+	tradeErr  error
+	nextOrder int
+}
+
+func newTCore() *tCore {
+	return &tCore{
+		book: &core.OrderBook{
+			Buys:  []*core.MiniOrder{{Qty: 1, Rate: 0.009}},
+			Sells: []*core.MiniOrder{{Qty: 1, Rate: 0.011}},
+		},
+		orders: make(map[string]*core.Order),
+	}
+}
+
+func (c *tCore) Exchanges() map[string]*core.Exchange {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	mkt := &core.Market{
+		Name:     "dcr_btc",
+		BaseID:   tBase,
+		QuoteID:  tQuote,
+		EpochLen: 60000,
+	}
+	for _, ord := range c.orders {
+		o := *ord
+		mkt.Orders = append(mkt.Orders, &o)
+	}
+	return map[string]*core.Exchange{
+		tHost: {
+			Host:    tHost,
+			Markets: map[string]*core.Market{mkt.Name: mkt},
+			Assets: map[uint32]*dex.Asset{
+				tBase:  {ID: tBase, LotSize: tLotSize, RateStep: 1e3},
+				tQuote: {ID: tQuote, LotSize: 1e3, RateStep: 1e3},
+			},
+		},
+	}
+}
+
+func (c *tCore) Sync(host string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error) {
+	return c.book, core.NewBookFeed(func(*core.BookFeed) {}), nil
+}
+
+func (c *tCore) Book(host string, base, quote uint32) (*core.OrderBook, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.book, nil
+}
+
+func (c *tCore) Trade(pw []byte, form *core.TradeForm) (*core.Order, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.tradeErr != nil {
+		return nil, c.tradeErr
+	}
+	c.nextOrder++
+	ord := &core.Order{
+		Host:   form.Host,
+		ID:     fmt.Sprintf("%064d", c.nextOrder),
+		Status: order.OrderStatusEpoch,
+		Qty:    form.Qty,
+		Sell:   form.Sell,
+		Rate:   form.Rate,
+	}
+	c.orders[ord.ID] = ord
+	c.trades = append(c.trades, form)
+	o := *ord
+	return &o, nil
+}
+
+func (c *tCore) Cancel(pw []byte, oid string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cancels = append(c.cancels, oid)
+	if ord, found := c.orders[oid]; found {
+		ord.Cancelling = true
+	}
+	return nil
+}
+
+func (c *tCore) fill(oid string, qty uint64, status order.OrderStatus) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	ord := c.orders[oid]
+	ord.Filled += qty
+	ord.Status = status
+}
+
+func (c *tCore) setBook(buy, sell float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.book = &core.OrderBook{
+		Buys:  []*core.MiniOrder{{Qty: 1, Rate: buy}},
+		Sells: []*core.MiniOrder{{Qty: 1, Rate: sell}},
+	}
+}
+
+type tRates struct {
+	rates map[uint32]float64
+}
+
+func (r *tRates) Currency() string { return "USD" }
+
+func (r *tRates) FiatRates([]uint32) (map[uint32]float64, error) {
+	return r.rates, nil
+}
+
+func tConfig() *BotConfig {
+	return &BotConfig{
+		Host:        tHost,
+		Base:        tBase,
+		Quote:       tQuote,
+		Strategy:    SpreadStrategy,
+		Spread:      0.02,
+		Lots:        1,
+		BaseBudget:  2 * tLotSize,
+		QuoteBudget: 2e6,
+	}
+}
+
+func newTestBot(t *testing.T, c *tCore, cfg *BotConfig, rates core.RateSource) *bot {
+	t.Helper()
+	strategy, err := newStrategy(cfg, rates)
+	if err != nil {
+		t.Fatalf("newStrategy error: %v", err)
+	}
+	return &bot{
+		id:       1,
+		cfg:      cfg,
+		strategy: strategy,
+		core:     c,
+		log:      tLogger,
+		pw:       tPW,
+		lotSize:  tLotSize,
+		rateStep: 1e3,
+		orders:   make(map[string]*core.Order),
+	}
+}
+
+func TestSpreadTargets(t *testing.T) {
+	targets := spreadTargets(1e6, 0.02, 3, 1e3)
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	sell, buy := targets[0], targets[1]
+	if !sell.Sell || sell.Rate != 1_010_000 || sell.Lots != 3 {
+		t.Fatalf("wrong sell target %+v", sell)
+	}
+	if buy.Sell || buy.Rate != 990_000 || buy.Lots != 3 {
+		t.Fatalf("wrong buy target %+v", buy)
+	}
+
+	// Rates are rounded away from the center.
+	targets = spreadTargets(1_000_500, 0.002, 1, 1e3)
+	if targets[0].Rate != 1_002_000 || targets[1].Rate != 999_000 {
+		t.Fatalf("wrong rounding. sell = %d, buy = %d", targets[0].Rate, targets[1].Rate)
+	}
+
+	_, err := midGap(&core.OrderBook{})
+	if err == nil {
+		t.Fatalf("no error for empty book")
+	}
+	mid, err := midGap(&core.OrderBook{Sells: []*core.MiniOrder{{Rate: 0.02}, {Rate: 0.01}}})
+	if err != nil {
+		t.Fatalf("midGap error: %v", err)
+	}
+	if mid != 1e6 {
+		t.Fatalf("wrong one-sided mid-gap %f", mid)
+	}
+}
+
+func TestBotStep(t *testing.T) {
+	c := newTCore()
+	b := newTestBot(t, c, tConfig(), nil)
+
+	// The first step places a buy and a sell around the 0.01 mid-gap.
+	b.step()
+	if len(c.trades) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(c.trades))
+	}
+	for _, form := range c.trades {
+		if form.Qty != tLotSize || !form.IsLimit {
+			t.Fatalf("wrong order %+v", form)
+		}
+		if (form.Sell && form.Rate != 1_010_000) || (!form.Sell && form.Rate != 990_000) {
+			t.Fatalf("wrong rate %d for sell = %t", form.Rate, form.Sell)
+		}
+	}
+
+	// No change in the market. Nothing to do.
+	b.step()
+	if len(c.trades) != 2 || len(c.cancels) != 0 {
+		t.Fatalf("orders changed for unchanged market")
+	}
+
+	// A move within the drift tolerance doesn't replace the orders.
+	c.setBook(0.00901, 0.01101)
+	b.step()
+	if len(c.trades) != 2 || len(c.cancels) != 0 {
+		t.Fatalf("orders changed within tolerance")
+	}
+
+	// A bigger move cancels both orders. There is enough base budget for the
+	// new sell, but the quote budget is still committed to the canceled buy.
+	c.setBook(0.0109, 0.0111)
+	b.step()
+	if len(c.cancels) != 2 {
+		t.Fatalf("expected 2 cancels, got %d", len(c.cancels))
+	}
+	if len(c.trades) != 3 || !c.trades[2].Sell {
+		t.Fatalf("expected a new sell only")
+	}
+
+	// Once the cancels are processed, the buy is placed.
+	for _, oid := range c.cancels {
+		c.fill(oid, 0, order.OrderStatusCanceled)
+	}
+	b.step()
+	if len(c.trades) != 4 || c.trades[3].Sell || c.trades[3].Rate != 1_089_000 {
+		t.Fatalf("expected a new buy")
+	}
+
+	// Fill the new buy. The position is updated and another buy is placed.
+	var buyID string
+	for oid, ord := range b.orders {
+		if !ord.Sell {
+			buyID = oid
+		}
+	}
+	c.fill(buyID, tLotSize, order.OrderStatusExecuted)
+	b.step()
+	if b.position != tLotSize {
+		t.Fatalf("wrong position %d", b.position)
+	}
+	if len(c.trades) != 5 || c.trades[4].Sell {
+		t.Fatalf("replacement buy not placed")
+	}
+	if len(b.orders) != 2 {
+		t.Fatalf("expected 2 open orders, got %d", len(b.orders))
+	}
+
+	// Stopping cancels the open orders.
+	b.shutdown()
+	if len(c.cancels) != 4 {
+		t.Fatalf("expected 4 cancels after shutdown, got %d", len(c.cancels))
+	}
+}
+
+func TestBotLimits(t *testing.T) {
+	// No quote budget, so no buys.
+	c := newTCore()
+	cfg := tConfig()
+	cfg.QuoteBudget = 0
+	b := newTestBot(t, c, cfg, nil)
+	b.step()
+	if len(c.trades) != 1 || !c.trades[0].Sell {
+		t.Fatalf("expected a single sell")
+	}
+
+	// Max exposure reached on the short side, so no sells.
+	c = newTCore()
+	cfg = tConfig()
+	cfg.MaxExposure = tLotSize
+	b = newTestBot(t, c, cfg, nil)
+	b.position = -tLotSize
+	b.step()
+	if len(c.trades) != 1 || c.trades[0].Sell {
+		t.Fatalf("expected a single buy")
+	}
+
+	// Trade errors are recorded.
+	c = newTCore()
+	c.tradeErr = fmt.Errorf("test error")
+	b = newTestBot(t, c, tConfig(), nil)
+	b.step()
+	if b.status().Error == "" {
+		t.Fatalf("no error recorded")
+	}
+}
+
+func TestOracleStrategy(t *testing.T) {
+	cfg := tConfig()
+	cfg.Strategy = OracleStrategy
+	if _, err := newStrategy(cfg, nil); err == nil {
+		t.Fatalf("no error for oracle strategy without rate source")
+	}
+
+	// 2 USD / 100 USD = 0.02, regardless of the book.
+	c := newTCore()
+	rates := &tRates{rates: map[uint32]float64{tBase: 2, tQuote: 100}}
+	b := newTestBot(t, c, cfg, rates)
+	b.step()
+	if len(c.trades) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(c.trades))
+	}
+	for _, form := range c.trades {
+		if (form.Sell && form.Rate != 2_020_000) || (!form.Sell && form.Rate != 1_980_000) {
+			t.Fatalf("wrong rate %d for sell = %t", form.Rate, form.Sell)
+		}
+	}
+
+	// No rate for the quote asset.
+	delete(rates.rates, tQuote)
+	b.step()
+	if b.status().Error == "" {
+		t.Fatalf("no error for missing rate")
+	}
+}
+
+func TestMarketMaker(t *testing.T) {
+	c := newTCore()
+	m := New(c, nil, tLogger)
+
+	if _, err := m.Start(tPW, tConfig()); err == nil {
+		t.Fatalf("no error starting bot before Run")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		m.Run(ctx)
+		wg.Done()
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	bad := tConfig()
+	bad.Strategy = "nope"
+	if _, err := m.Start(tPW, bad); err == nil {
+		t.Fatalf("no error for unknown strategy")
+	}
+	bad = tConfig()
+	bad.Base = 1
+	if _, err := m.Start(tPW, bad); err == nil {
+		t.Fatalf("no error for unknown market")
+	}
+	bad = tConfig()
+	bad.Spread = 0
+	if _, err := m.Start(tPW, bad); err == nil {
+		t.Fatalf("no error for zero spread")
+	}
+
+	id, err := m.Start(tPW, tConfig())
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	bots := m.Bots()
+	if len(bots) != 1 || bots[0].ID != id {
+		t.Fatalf("bot not listed")
+	}
+	if err = m.Stop(id); err != nil {
+		t.Fatalf("Stop error: %v", err)
+	}
+	if len(m.Bots()) != 0 {
+		t.Fatalf("bot still listed after Stop")
+	}
+	if err = m.Stop(id); err == nil {
+		t.Fatalf("no error stopping a stopped bot")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.cancels) != 2 {
+		t.Fatalf("expected 2 cancels after stop, got %d", len(c.cancels))
+	}
+}
@@ -0,0 +1,200 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package mm
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"decred.org/dcrdex/client/core"
+)
+
+const (
+	// SpreadStrategy is the name of the basic spread maker, which quotes
+	// around the mid-gap rate of the market's order book.
+	SpreadStrategy = "spread"
+	// OracleStrategy is the name of the oracle-pegged maker, which quotes
+	// around the exchange rate reported by the fiat rate source.
+	OracleStrategy = "oracle"
+
+	conversionFactor = 1e8
+)
+
+// MarketState is the state of the market and the bot's position that is
+// provided to a Strategy. Book is the current order book. Position is the net
+// quantity of the base asset acquired by the bot's filled orders, in atoms.
+// A negative Position means the bot has sold more than it has bought.
+type MarketState struct {
+	Host     string
+	Base     uint32
+	Quote    uint32
+	LotSize  uint64
+	RateStep uint64
+	Book     *core.OrderBook
+	Position int64
+}
+
+// OrderTarget is a standing limit order that a Strategy wants on the book.
+type OrderTarget struct {
+	Sell bool
+	Rate uint64
+	Lots uint64
+}
+
+// Strategy decides where a bot's orders should be. Targets is called once per
+// epoch, and the bot places and cancels orders to match the returned targets,
+// subject to the bot's budget and exposure limits.
+type Strategy interface {
+	Targets(mkt *MarketState) ([]*OrderTarget, error)
+}
+
+// StrategyConstructor creates a Strategy for the bot configuration. rates is
+// the MarketMaker's fiat rate source, and may be nil.
+type StrategyConstructor func(cfg *BotConfig, rates core.RateSource) (Strategy, error)
+
+var (
+	strategyMtx sync.RWMutex
+	strategies  = map[string]StrategyConstructor{
+		SpreadStrategy: newSpreadMaker,
+		OracleStrategy: newOracleMaker,
+	}
+)
+
+// Register makes a Strategy available by name. Register panics if a strategy
+// with the same name is already registered. Register is intended to be called
+// from a package init function.
+func Register(name string, constructor StrategyConstructor) {
+	strategyMtx.Lock()
+	defer strategyMtx.Unlock()
+	if _, found := strategies[name]; found {
+		panic(fmt.Sprintf("strategy %q already registered", name))
+	}
+	strategies[name] = constructor
+}
+
+// newStrategy creates the named Strategy.
+func newStrategy(cfg *BotConfig, rates core.RateSource) (Strategy, error) {
+	strategyMtx.RLock()
+	constructor, found := strategies[cfg.Strategy]
+	strategyMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown strategy %q", cfg.Strategy)
+	}
+	return constructor(cfg, rates)
+}
+
+// spreadMaker places Lots on each side of the mid-gap rate of the order book,
+// Spread apart.
+type spreadMaker struct {
+	spread float64
+	lots   uint64
+}
+
+func newSpreadMaker(cfg *BotConfig, _ core.RateSource) (Strategy, error) {
+	return &spreadMaker{
+		spread: cfg.Spread,
+		lots:   cfg.Lots,
+	}, nil
+}
+
+// Targets returns a buy and sell target around the mid-gap rate. If only one
+// side of the book has orders, the best rate on that side is used.
+func (s *spreadMaker) Targets(mkt *MarketState) ([]*OrderTarget, error) {
+	mid, err := midGap(mkt.Book)
+	if err != nil {
+		return nil, err
+	}
+	return spreadTargets(mid, s.spread, s.lots, mkt.RateStep), nil
+}
+
+// oracleMaker places Lots on each side of the exchange rate reported by the
+// fiat rate source, Spread apart. The oracle rate is independent of the order
+// book, so the bot can seed an empty market.
+type oracleMaker struct {
+	rates  core.RateSource
+	spread float64
+	lots   uint64
+}
+
+func newOracleMaker(cfg *BotConfig, rates core.RateSource) (Strategy, error) {
+	if rates == nil {
+		return nil, fmt.Errorf("the %s strategy requires a fiat rate source", OracleStrategy)
+	}
+	return &oracleMaker{
+		rates:  rates,
+		spread: cfg.Spread,
+		lots:   cfg.Lots,
+	}, nil
+}
+
+// Targets returns a buy and sell target around the ratio of the fiat rates of
+// the base and quote assets.
+func (s *oracleMaker) Targets(mkt *MarketState) ([]*OrderTarget, error) {
+	fiatRates, err := s.rates.FiatRates([]uint32{mkt.Base, mkt.Quote})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving fiat rates: %v", err)
+	}
+	baseRate, quoteRate := fiatRates[mkt.Base], fiatRates[mkt.Quote]
+	if baseRate <= 0 || quoteRate <= 0 {
+		return nil, fmt.Errorf("no %s rate for assets %d and %d", s.rates.Currency(), mkt.Base, mkt.Quote)
+	}
+	mid := baseRate / quoteRate * conversionFactor
+	return spreadTargets(mid, s.spread, s.lots, mkt.RateStep), nil
+}
+
+// midGap is the rate halfway between the best buy and best sell orders, in
+// atoms of quote asset per whole unit of base asset.
+func midGap(book *core.OrderBook) (float64, error) {
+	if book == nil {
+		return 0, fmt.Errorf("no order book")
+	}
+	var bestBuy, bestSell float64
+	for _, o := range book.Buys {
+		if o.Rate > bestBuy {
+			bestBuy = o.Rate
+		}
+	}
+	for _, o := range book.Sells {
+		if bestSell == 0 || o.Rate < bestSell {
+			bestSell = o.Rate
+		}
+	}
+	switch {
+	case bestBuy > 0 && bestSell > 0:
+		return (bestBuy + bestSell) / 2 * conversionFactor, nil
+	case bestBuy > 0:
+		return bestBuy * conversionFactor, nil
+	case bestSell > 0:
+		return bestSell * conversionFactor, nil
+	}
+	return 0, fmt.Errorf("empty order book")
+}
+
+// spreadTargets creates a buy and sell target spread apart around mid. The
+// sell rate is rounded up and the buy rate down to a multiple of the rate step.
+func spreadTargets(mid, spread float64, lots, rateStep uint64) []*OrderTarget {
+	if rateStep == 0 {
+		rateStep = 1
+	}
+	// Round to the nearest atom before stepping, so that floating point error
+	// doesn't push a rate that is already on a step to the next one.
+	buyRate := uint64(math.Round(mid*(1-spread/2))) / rateStep * rateStep
+	sellRate := uint64(math.Round(mid * (1 + spread/2)))
+	if rem := sellRate % rateStep; rem != 0 {
+		sellRate += rateStep - rem
+	}
+	targets := []*OrderTarget{{
+		Sell: true,
+		Rate: sellRate,
+		Lots: lots,
+	}}
+	if buyRate > 0 {
+		targets = append(targets, &OrderTarget{
+			Rate: buyRate,
+			Lots: lots,
+		})
+	}
+	return targets
+}
@@ -229,6 +229,16 @@ func (ob *OrderBook) Sync(snapshot *msgjson.OrderBook) error {
 	return nil
 }
 
+// Reset marks the order book as unsynced so that it can be synced with a new
+// snapshot, e.g. after the connection to the server is re-established. Order
+// notes received before the next Sync are cached.
+func (ob *OrderBook) Reset() {
+	ob.noteQueueMtx.Lock()
+	ob.noteQueue = make([]*cachedOrderNote, 0, defaultQueueCapacity)
+	ob.noteQueueMtx.Unlock()
+	ob.setSynced(false)
+}
+
 // book is the workhorse of the exported Book function. It allows booking
 // cached and uncached order notes.
 func (ob *OrderBook) book(note *msgjson.BookOrderNote, cached bool) error {
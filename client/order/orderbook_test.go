@@ -260,6 +260,47 @@ func TestOrderBookSync(t *testing.T) {
 	}
 }
 
+func TestOrderBookReset(t *testing.T) {
+	ob := NewOrderBook()
+	err := ob.Sync(makeOrderBookMsg(2, "ob", []*msgjson.BookOrderNote{
+		makeBookOrderNote(1, "ob", [32]byte{'b'}, msgjson.BuyOrderNum, 10, 1, 2),
+		makeBookOrderNote(2, "ob", [32]byte{'c'}, msgjson.SellOrderNum, 10, 2, 5),
+	}))
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+
+	ob.Reset()
+	if ob.isSynced() {
+		t.Fatalf("order book still synced after Reset")
+	}
+
+	// Notes are cached until the next Sync.
+	err = ob.Book(makeBookOrderNote(4, "ob", [32]byte{'d'}, msgjson.BuyOrderNum, 10, 1, 6))
+	if err != nil {
+		t.Fatalf("Book error: %v", err)
+	}
+	if len(ob.noteQueue) != 1 {
+		t.Fatalf("expected 1 cached note, got %d", len(ob.noteQueue))
+	}
+
+	err = ob.Sync(makeOrderBookMsg(3, "ob", []*msgjson.BookOrderNote{
+		makeBookOrderNote(3, "ob", [32]byte{'e'}, msgjson.SellOrderNum, 10, 3, 5),
+	}))
+	if err != nil {
+		t.Fatalf("Sync after Reset error: %v", err)
+	}
+	if !ob.isSynced() {
+		t.Fatalf("order book not synced")
+	}
+	if len(ob.orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(ob.orders))
+	}
+	if len(ob.noteQueue) != 0 {
+		t.Fatalf("expected empty note queue, got %d", len(ob.noteQueue))
+	}
+}
+
 func TestOrderBookBook(t *testing.T) {
 	tests := []struct {
 		label     string
@@ -6,40 +6,75 @@ package rpcserver
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"sort"
 	"strings"
 
+	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
 )
 
 // routes
 const (
-	cancelRoute      = "cancel"
-	closeWalletRoute = "closewallet"
-	exchangesRoute   = "exchanges"
-	helpRoute        = "help"
-	initRoute        = "init"
-	loginRoute       = "login"
-	logoutRoute      = "logout"
-	newWalletRoute   = "newwallet"
-	openWalletRoute  = "openwallet"
-	getFeeRoute      = "getfee"
-	registerRoute    = "register"
-	tradeRoute       = "trade"
-	versionRoute     = "version"
-	walletsRoute     = "wallets"
-	withdrawRoute    = "withdraw"
+	appSeedRoute          = "appseed"
+	batchRoute            = "batch"
+	botsRoute             = "bots"
+	cancelRoute           = "cancel"
+	cancelAllRoute        = "cancelall"
+	cloneOrderRoute       = "cloneorder"
+	closeWalletRoute      = "closewallet"
+	devicePromptsRoute    = "deviceprompts"
+	estimateWithdrawRoute = "estimatewithdraw"
+	exchangesRoute        = "exchanges"
+	exportBackupRoute     = "exportbackup"
+	exportTradesRoute     = "exporttrades"
+	helpRoute             = "help"
+	initRoute             = "init"
+	loginRoute            = "login"
+	logoutRoute           = "logout"
+	matchesRoute          = "matches"
+	newWalletRoute        = "newwallet"
+	openWalletRoute       = "openwallet"
+	orderRoute            = "order"
+	ordersRoute           = "orders"
+	getFeeRoute           = "getfee"
+	portfolioRoute        = "portfolio"
+	recoverableSwapsRoute = "recoverableswaps"
+	recoverHistoryRoute   = "recoverhistory"
+	refundSwapRoute       = "refundswap"
+	registerRoute         = "register"
+	rescanWalletRoute     = "rescanwallet"
+	restoreBackupRoute    = "restorebackup"
+	restoreSeedRoute      = "restoreseed"
+	respondPromptRoute    = "respondprompt"
+	sendManyRoute         = "sendmany"
+	startBotRoute         = "startbot"
+	stopBotRoute          = "stopbot"
+	tradeRoute            = "trade"
+	verifyEpochRoute      = "verifyepoch"
+	versionRoute          = "version"
+	walletCoinsRoute      = "walletcoins"
+	walletsRoute          = "wallets"
+	withdrawRoute         = "withdraw"
 )
 
 const (
-	initializedStr    = "app initialized"
-	walletCreatedStr  = "%s wallet created and unlocked"
-	walletLockedStr   = "%s wallet locked"
-	walletUnlockedStr = "%s wallet unlocked"
-	canceledOrderStr  = "canceled order %s"
-	logoutStr         = "goodbye"
+	initializedStr     = "app initialized"
+	walletCreatedStr   = "%s wallet created and unlocked"
+	walletLockedStr    = "%s wallet locked"
+	walletUnlockedStr  = "%s wallet unlocked"
+	canceledOrderStr   = "canceled order %s"
+	logoutStr          = "goodbye"
+	botStoppedStr      = "stopped bot %d"
+	noMarketMakerStr   = "market maker is not available"
+	promptAnsweredStr  = "responded to device prompt %s"
+	walletRescannedStr = "%s wallet rescanned from block %d"
+	backupExportedStr  = "backup written to %s"
+	backupRestoredStr  = "backup restored from %s"
+	seedRestoredStr    = "app initialized from seed"
 )
 
 // createResponse creates a msgjson response payload.
@@ -63,21 +98,64 @@ func usage(route string, err error) *msgjson.ResponsePayload {
 
 // routes maps routes to a handler function.
 var routes = map[string]func(s *RPCServer, params *RawParams) *msgjson.ResponsePayload{
-	cancelRoute:      handleCancel,
-	closeWalletRoute: handleCloseWallet,
-	exchangesRoute:   handleExchanges,
-	helpRoute:        handleHelp,
-	initRoute:        handleInit,
-	loginRoute:       handleLogin,
-	logoutRoute:      handleLogout,
-	newWalletRoute:   handleNewWallet,
-	openWalletRoute:  handleOpenWallet,
-	getFeeRoute:      handleGetFee,
-	registerRoute:    handleRegister,
-	tradeRoute:       handleTrade,
-	versionRoute:     handleVersion,
-	walletsRoute:     handleWallets,
-	withdrawRoute:    handleWithdraw,
+	appSeedRoute:          handleAppSeed,
+	batchRoute:            handleBatch,
+	botsRoute:             handleBots,
+	cancelRoute:           handleCancel,
+	cancelAllRoute:        handleCancelAll,
+	cloneOrderRoute:       handleCloneOrder,
+	closeWalletRoute:      handleCloseWallet,
+	devicePromptsRoute:    handleDevicePrompts,
+	estimateWithdrawRoute: handleEstimateWithdraw,
+	exchangesRoute:        handleExchanges,
+	exportBackupRoute:     handleExportBackup,
+	exportTradesRoute:     handleExportTrades,
+	helpRoute:             handleHelp,
+	initRoute:             handleInit,
+	loginRoute:            handleLogin,
+	logoutRoute:           handleLogout,
+	matchesRoute:          handleMatches,
+	newWalletRoute:        handleNewWallet,
+	openWalletRoute:       handleOpenWallet,
+	orderRoute:            handleOrder,
+	ordersRoute:           handleOrders,
+	getFeeRoute:           handleGetFee,
+	portfolioRoute:        handlePortfolio,
+	recoverableSwapsRoute: handleRecoverableSwaps,
+	recoverHistoryRoute:   handleRecoverHistory,
+	refundSwapRoute:       handleRefundSwap,
+	registerRoute:         handleRegister,
+	rescanWalletRoute:     handleRescanWallet,
+	restoreBackupRoute:    handleRestoreBackup,
+	restoreSeedRoute:      handleRestoreSeed,
+	respondPromptRoute:    handleRespondPrompt,
+	sendManyRoute:         handleSendMany,
+	startBotRoute:         handleStartBot,
+	stopBotRoute:          handleStopBot,
+	tradeRoute:            handleTrade,
+	verifyEpochRoute:      handleVerifyEpoch,
+	versionRoute:          handleVersion,
+	walletCoinsRoute:      handleWalletCoins,
+	walletsRoute:          handleWallets,
+	withdrawRoute:         handleWithdraw,
+}
+
+// readOnlyRoutes are the routes that are available before login when the
+// server is in read-only mode. These do not require the app password or any
+// decrypted keys, and do not change the state of the client, except for init
+// and login, which upgrade the client to full functionality.
+var readOnlyRoutes = map[string]bool{
+	exchangesRoute:    true,
+	exportTradesRoute: true,
+	helpRoute:         true,
+	initRoute:         true,
+	loginRoute:        true,
+	matchesRoute:      true,
+	orderRoute:        true,
+	ordersRoute:       true,
+	portfolioRoute:    true,
+	versionRoute:      true,
+	walletsRoute:      true,
 }
 
 // handleHelp handles requests for help. Returns general help for all commands
@@ -225,6 +303,37 @@ func handleWallets(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 	return createResponse(walletsRoute, walletsStates, nil)
 }
 
+// handlePortfolio handles requests for portfolio. Returns the balances, funds
+// locked in orders, and funds in active swaps for each asset.
+func handlePortfolio(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	portfolio := s.core.Portfolio()
+	return createResponse(portfolioRoute, portfolio, nil)
+}
+
+// handleExportTrades handles requests for exporttrades. Returns the trade
+// history as a JSON object, or as a CSV string.
+func handleExportTrades(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	format, err := parseExportTradesArgs(params)
+	if err != nil {
+		return usage(exportTradesRoute, err)
+	}
+	history, err := s.core.TradeHistory()
+	if err != nil {
+		resErr := msgjson.NewError(msgjson.RPCExportError, err.Error())
+		return createResponse(exportTradesRoute, nil, resErr)
+	}
+	if format == core.ExportJSON {
+		return createResponse(exportTradesRoute, history, nil)
+	}
+	var sb strings.Builder
+	if err = history.WriteCSV(&sb); err != nil {
+		resErr := msgjson.NewError(msgjson.RPCExportError, err.Error())
+		return createResponse(exportTradesRoute, nil, resErr)
+	}
+	res := sb.String()
+	return createResponse(exportTradesRoute, &res, nil)
+}
+
 // handleGetFee handles requests for getfee.
 // *msgjson.ResponsePayload.Error is empty if successful. Requires the address
 // of a dex and returns the dex fee.
@@ -377,6 +486,54 @@ func handleTrade(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(tradeRoute, &tradeRes, nil)
 }
 
+// handleStartBot handles requests for startbot. Returns the ID of the new bot.
+func handleStartBot(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseStartBotArgs(params)
+	if err != nil {
+		return usage(startBotRoute, err)
+	}
+	defer form.AppPass.Clear()
+	if s.mm == nil {
+		resErr := msgjson.NewError(msgjson.RPCMarketMakerError, noMarketMakerStr)
+		return createResponse(startBotRoute, nil, resErr)
+	}
+	id, err := s.mm.Start(form.AppPass, form.Config)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to start bot: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCMarketMakerError, errMsg)
+		return createResponse(startBotRoute, nil, resErr)
+	}
+	return createResponse(startBotRoute, id, nil)
+}
+
+// handleStopBot handles requests for stopbot. The bot's orders are canceled.
+func handleStopBot(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	id, err := parseStopBotArgs(params)
+	if err != nil {
+		return usage(stopBotRoute, err)
+	}
+	if s.mm == nil {
+		resErr := msgjson.NewError(msgjson.RPCMarketMakerError, noMarketMakerStr)
+		return createResponse(stopBotRoute, nil, resErr)
+	}
+	if err := s.mm.Stop(id); err != nil {
+		errMsg := fmt.Sprintf("unable to stop bot %d: %v", id, err)
+		resErr := msgjson.NewError(msgjson.RPCMarketMakerError, errMsg)
+		return createResponse(stopBotRoute, nil, resErr)
+	}
+	return createResponse(stopBotRoute, fmt.Sprintf(botStoppedStr, id), nil)
+}
+
+// handleBots handles requests for bots. Returns the status of the running
+// market maker bots.
+func handleBots(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	if s.mm == nil {
+		resErr := msgjson.NewError(msgjson.RPCMarketMakerError, noMarketMakerStr)
+		return createResponse(botsRoute, nil, resErr)
+	}
+	return createResponse(botsRoute, s.mm.Bots(), nil)
+}
+
 // handleCancel handles requests for cancel. *msgjson.ResponsePayload.Error is
 // empty if successful.
 func handleCancel(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
@@ -394,6 +551,206 @@ func handleCancel(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(cancelRoute, &res, nil)
 }
 
+// handleCancelAll handles requests for cancelall. *msgjson.ResponsePayload.Error
+// is empty if every booked order was canceled.
+func handleCancelAll(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseCancelAllArgs(params)
+	if err != nil {
+		return usage(cancelAllRoute, err)
+	}
+	defer form.AppPass.Clear()
+	canceled, err := s.core.CancelAll(form.AppPass, form.Filter)
+	if err != nil && canceled == nil {
+		errMsg := fmt.Sprintf("unable to cancel orders: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCCancelError, errMsg)
+		return createResponse(cancelAllRoute, nil, resErr)
+	}
+	res := &cancelAllResponse{Canceled: canceled}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return createResponse(cancelAllRoute, res, nil)
+}
+
+// handleCloneOrder handles requests for cloneorder. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handleCloneOrder(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseCloneOrderArgs(params)
+	if err != nil {
+		return usage(cloneOrderRoute, err)
+	}
+	defer form.AppPass.Clear()
+	res, err := s.core.CloneOrder(form.AppPass, form.OrderID, form.Rate)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to re-place order %q: %v", form.OrderID, err)
+		resErr := msgjson.NewError(msgjson.RPCTradeError, errMsg)
+		return createResponse(cloneOrderRoute, nil, resErr)
+	}
+	tradeRes := &tradeResponse{
+		OrderID: res.ID,
+		Sig:     res.Sig.String(),
+		Stamp:   res.Stamp,
+	}
+	return createResponse(cloneOrderRoute, &tradeRes, nil)
+}
+
+// handleOrders handles requests for orders. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleOrders(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	filter, err := parseOrdersArgs(params)
+	if err != nil {
+		return usage(ordersRoute, err)
+	}
+	orders, err := s.core.Orders(filter)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve orders: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCOrdersError, errMsg)
+		return createResponse(ordersRoute, nil, resErr)
+	}
+	return createResponse(ordersRoute, orders, nil)
+}
+
+// handleOrder handles requests for order. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleOrder(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	orderID, err := parseOrderArgs(params)
+	if err != nil {
+		return usage(orderRoute, err)
+	}
+	ord, err := s.core.Order(orderID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve order %q: %v", orderID, err)
+		resErr := msgjson.NewError(msgjson.RPCOrdersError, errMsg)
+		return createResponse(orderRoute, nil, resErr)
+	}
+	return createResponse(orderRoute, ord, nil)
+}
+
+// handleMatches handles requests for matches. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleMatches(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	filter, err := parseOrdersArgs(params)
+	if err != nil {
+		return usage(matchesRoute, err)
+	}
+	matches, err := s.core.Matches(filter)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve matches: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCOrdersError, errMsg)
+		return createResponse(matchesRoute, nil, resErr)
+	}
+	return createResponse(matchesRoute, matches, nil)
+}
+
+// batchCmdHandler is a command that can be included in a batch request.
+type batchCmdHandler struct {
+	handle  func(s *RPCServer, params *RawParams) *msgjson.ResponsePayload
+	parse   func(params *RawParams) error
+	appPass bool
+}
+
+// batchRoutes are the commands that can be included in a batch request. The
+// parse function checks the command's arguments before any command in the batch
+// is executed.
+var batchRoutes = map[string]*batchCmdHandler{
+	cancelRoute: {
+		handle:  handleCancel,
+		parse:   func(p *RawParams) error { _, err := parseCancelArgs(p); return err },
+		appPass: true,
+	},
+	cancelAllRoute: {
+		handle:  handleCancelAll,
+		parse:   func(p *RawParams) error { _, err := parseCancelAllArgs(p); return err },
+		appPass: true,
+	},
+	cloneOrderRoute: {
+		handle:  handleCloneOrder,
+		parse:   func(p *RawParams) error { _, err := parseCloneOrderArgs(p); return err },
+		appPass: true,
+	},
+	tradeRoute: {
+		handle:  handleTrade,
+		parse:   func(p *RawParams) error { _, err := parseTradeArgs(p); return err },
+		appPass: true,
+	},
+	orderRoute: {
+		handle: handleOrder,
+		parse:  func(p *RawParams) error { _, err := parseOrderArgs(p); return err },
+	},
+	ordersRoute: {
+		handle: handleOrders,
+		parse:  func(p *RawParams) error { _, err := parseOrdersArgs(p); return err },
+	},
+	matchesRoute: {
+		handle: handleMatches,
+		parse:  func(p *RawParams) error { _, err := parseOrdersArgs(p); return err },
+	},
+	portfolioRoute: {
+		handle: handlePortfolio,
+		parse:  func(p *RawParams) error { return checkNArgs(p, []int{0}, []int{0}) },
+	},
+	walletsRoute: {
+		handle: handleWallets,
+		parse:  func(p *RawParams) error { return checkNArgs(p, []int{0}, []int{0}) },
+	},
+}
+
+// handleBatch handles requests for batch. The arguments of every command are
+// checked before any command is executed, and the commands are executed in
+// order. Execution stops at the first command that fails, and the remaining
+// commands are skipped. Commands that already succeeded are not reversed.
+// Batches are executed one at a time.
+func handleBatch(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseBatchArgs(params)
+	if err != nil {
+		return usage(batchRoute, err)
+	}
+	defer form.AppPass.Clear()
+
+	cmdParams := make([]*RawParams, len(form.Cmds))
+	for i, cmd := range form.Cmds {
+		h, found := batchRoutes[cmd.Cmd]
+		if !found {
+			return usage(batchRoute, fmt.Errorf("%w: command %d: %q cannot be batched", errArgs, i, cmd.Cmd))
+		}
+		p := &RawParams{Args: cmd.Args}
+		if h.appPass {
+			if len(form.AppPass) == 0 {
+				return usage(batchRoute, fmt.Errorf("%w: command %d: %s requires the app password", errArgs, i, cmd.Cmd))
+			}
+			p.PWArgs = []encode.PassBytes{form.AppPass}
+		}
+		if err := h.parse(p); err != nil {
+			return usage(batchRoute, fmt.Errorf("command %d (%s): %v", i, cmd.Cmd, err))
+		}
+		cmdParams[i] = p
+	}
+
+	s.batchMtx.Lock()
+	defer s.batchMtx.Unlock()
+	res := &batchResponse{Results: make([]*batchResult, len(form.Cmds))}
+	var failed bool
+	for i, cmd := range form.Cmds {
+		if failed {
+			res.Results[i] = &batchResult{Cmd: cmd.Cmd, Skipped: true}
+			continue
+		}
+		p := cmdParams[i]
+		if len(p.PWArgs) > 0 {
+			// The handler clears its password, so give it a copy.
+			p.PWArgs = []encode.PassBytes{append(encode.PassBytes(nil), form.AppPass...)}
+		}
+		payload := batchRoutes[cmd.Cmd].handle(s, p)
+		res.Results[i] = &batchResult{
+			Cmd:    cmd.Cmd,
+			Result: payload.Result,
+			Error:  payload.Error,
+		}
+		failed = payload.Error != nil
+	}
+	return createResponse(batchRoute, res, nil)
+}
+
 // handleWithdraw handles requests for withdraw. *msgjson.ResponsePayload.Error
 // is empty if successful.
 func handleWithdraw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
@@ -402,7 +759,24 @@ func handleWithdraw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 		return usage(withdrawRoute, err)
 	}
 	defer form.AppPass.Clear()
-	coin, err := s.core.Withdraw(form.AppPass, form.AssetID, form.Value, form.Address)
+	var coin asset.Coin
+	if form.FeeRate > 0 || form.TargetConfs > 0 {
+		var coins []asset.Coin
+		coins, err = s.core.SendWithdrawal(form.AppPass, form.AssetID, &asset.Withdrawal{
+			Outputs: []*asset.WithdrawalOutput{{
+				Address: form.Address,
+				Value:   form.Value,
+			}},
+			FeeRate:     form.FeeRate,
+			TargetConfs: form.TargetConfs,
+			Subtract:    true,
+		})
+		if err == nil {
+			coin = coins[0]
+		}
+	} else {
+		coin, err = s.core.Withdraw(form.AppPass, form.AssetID, form.Value, form.Address)
+	}
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to withdraw: %v", err)
 		resErr := msgjson.NewError(msgjson.RPCWithdrawError, errMsg)
@@ -412,6 +786,241 @@ func handleWithdraw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(withdrawRoute, &res, nil)
 }
 
+// handleSendMany handles requests for sendmany. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handleSendMany(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseSendManyArgs(params)
+	if err != nil {
+		return usage(sendManyRoute, err)
+	}
+	defer form.AppPass.Clear()
+	coins, err := s.core.SendWithdrawal(form.AppPass, form.AssetID, form.Withdrawal)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to withdraw: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCWithdrawError, errMsg)
+		return createResponse(sendManyRoute, nil, resErr)
+	}
+	res := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		res = append(res, coin.String())
+	}
+	return createResponse(sendManyRoute, res, nil)
+}
+
+// handleEstimateWithdraw handles requests for estimatewithdraw. Returns the
+// fee rate, size, and fee of the withdrawal without sending it.
+func handleEstimateWithdraw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseEstimateWithdrawArgs(params)
+	if err != nil {
+		return usage(estimateWithdrawRoute, err)
+	}
+	est, err := s.core.EstimateWithdrawal(form.AssetID, form.Withdrawal)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to estimate withdrawal: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCWithdrawError, errMsg)
+		return createResponse(estimateWithdrawRoute, nil, resErr)
+	}
+	return createResponse(estimateWithdrawRoute, est, nil)
+}
+
+// handleDevicePrompts handles requests for deviceprompts. Returns the hardware
+// wallet prompts awaiting a response.
+func handleDevicePrompts(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(devicePromptsRoute, s.core.DevicePrompts(), nil)
+}
+
+// handleRespondPrompt handles requests for respondprompt.
+// *msgjson.ResponsePayload.Error is empty if successful.
+func handleRespondPrompt(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseRespondPromptArgs(params)
+	if err != nil {
+		return usage(respondPromptRoute, err)
+	}
+	defer form.Response.Clear()
+	if err := s.core.RespondDevicePrompt(form.ID, form.Response); err != nil {
+		errMsg := fmt.Sprintf("unable to respond to device prompt %s: %v", form.ID, err)
+		resErr := msgjson.NewError(msgjson.RPCDeviceError, errMsg)
+		return createResponse(respondPromptRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(promptAnsweredStr, form.ID)
+	return createResponse(respondPromptRoute, &res, nil)
+}
+
+// handleRescanWallet handles requests for rescanwallet.
+// *msgjson.ResponsePayload.Error is empty if successful.
+func handleRescanWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseRescanWalletArgs(params)
+	if err != nil {
+		return usage(rescanWalletRoute, err)
+	}
+	if err := s.core.RescanWallet(form.AssetID, form.Height); err != nil {
+		errMsg := fmt.Sprintf("unable to rescan %s wallet: %v", dex.BipIDSymbol(form.AssetID), err)
+		resErr := msgjson.NewError(msgjson.RPCRecoveryError, errMsg)
+		return createResponse(rescanWalletRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(walletRescannedStr, dex.BipIDSymbol(form.AssetID), form.Height)
+	return createResponse(rescanWalletRoute, &res, nil)
+}
+
+// handleWalletCoins handles requests for walletcoins. Returns the unspent coins
+// of a wallet, for choosing the coins that fund an order.
+func handleWalletCoins(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseWalletCoinsArgs(params)
+	if err != nil {
+		return usage(walletCoinsRoute, err)
+	}
+	coins, err := s.core.WalletCoins(form.AssetID, form.WalletName)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to list %s coins: %v", dex.BipIDSymbol(form.AssetID), err)
+		resErr := msgjson.NewError(msgjson.RPCCoinsError, errMsg)
+		return createResponse(walletCoinsRoute, nil, resErr)
+	}
+	return createResponse(walletCoinsRoute, coins, nil)
+}
+
+// handleRecoverableSwaps handles requests for recoverableswaps. Returns the
+// user's swap contracts that are still unspent on-chain.
+func handleRecoverableSwaps(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	swaps, err := s.core.RecoverableSwaps()
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to check swaps: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCRecoveryError, errMsg)
+		return createResponse(recoverableSwapsRoute, nil, resErr)
+	}
+	return createResponse(recoverableSwapsRoute, swaps, nil)
+}
+
+// handleRecoverHistory handles requests for recoverhistory. Returns the number
+// of orders and matches recovered from the server.
+func handleRecoverHistory(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	host, err := parseRecoverHistoryArgs(params)
+	if err != nil {
+		return usage(recoverHistoryRoute, err)
+	}
+	rec, err := s.core.RecoverHistory(host)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to recover history from %s: %v", host, err)
+		resErr := msgjson.NewError(msgjson.RPCRecoveryError, errMsg)
+		return createResponse(recoverHistoryRoute, nil, resErr)
+	}
+	return createResponse(recoverHistoryRoute, rec, nil)
+}
+
+// handleVerifyEpoch handles requests for verifyepoch. Returns the results of
+// replaying the matching of the order's epoch.
+func handleVerifyEpoch(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	orderID, err := parseOrderArgs(params)
+	if err != nil {
+		return usage(verifyEpochRoute, err)
+	}
+	ver, err := s.core.VerifyEpoch(orderID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to verify the epoch of order %s: %v", orderID, err)
+		resErr := msgjson.NewError(msgjson.RPCOrdersError, errMsg)
+		return createResponse(verifyEpochRoute, nil, resErr)
+	}
+	return createResponse(verifyEpochRoute, ver, nil)
+}
+
+// handleRefundSwap handles requests for refundswap. Returns the refund coin.
+func handleRefundSwap(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseRefundSwapArgs(params)
+	if err != nil {
+		return usage(refundSwapRoute, err)
+	}
+	defer form.AppPass.Clear()
+	refundCoin, err := s.core.RefundSwap(form.AppPass, form.MatchID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to refund match %s: %v", form.MatchID, err)
+		resErr := msgjson.NewError(msgjson.RPCRecoveryError, errMsg)
+		return createResponse(refundSwapRoute, nil, resErr)
+	}
+	return createResponse(refundSwapRoute, &refundCoin, nil)
+}
+
+// handleExportBackup handles requests for exportbackup. The encrypted backup
+// is written to the specified file. *msgjson.ResponsePayload.Error is empty if
+// successful.
+func handleExportBackup(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseBackupArgs(params)
+	if err != nil {
+		return usage(exportBackupRoute, err)
+	}
+	defer form.AppPass.Clear()
+	archive, err := s.core.ExportBackup(form.AppPass)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to export backup: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(exportBackupRoute, nil, resErr)
+	}
+	if err := ioutil.WriteFile(form.Path, archive, 0600); err != nil {
+		errMsg := fmt.Sprintf("unable to write backup file: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(exportBackupRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(backupExportedStr, form.Path)
+	return createResponse(exportBackupRoute, &res, nil)
+}
+
+// handleRestoreBackup handles requests for restorebackup. The encrypted backup
+// is read from the specified file. *msgjson.ResponsePayload.Error is empty if
+// successful.
+func handleRestoreBackup(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseBackupArgs(params)
+	if err != nil {
+		return usage(restoreBackupRoute, err)
+	}
+	defer form.AppPass.Clear()
+	archive, err := ioutil.ReadFile(form.Path)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to read backup file: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(restoreBackupRoute, nil, resErr)
+	}
+	if err := s.core.RestoreBackup(form.AppPass, archive); err != nil {
+		errMsg := fmt.Sprintf("unable to restore backup: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(restoreBackupRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(backupRestoredStr, form.Path)
+	return createResponse(restoreBackupRoute, &res, nil)
+}
+
+// handleAppSeed handles requests for appseed. *msgjson.ResponsePayload.Result
+// is the application seed words.
+func handleAppSeed(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	appPass, err := parseAppSeedArgs(params)
+	if err != nil {
+		return usage(appSeedRoute, err)
+	}
+	defer appPass.Clear()
+	words, err := s.core.ExportSeed(appPass)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to export seed: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(appSeedRoute, nil, resErr)
+	}
+	return createResponse(appSeedRoute, &words, nil)
+}
+
+// handleRestoreSeed handles requests for restoreseed. *msgjson.ResponsePayload.Result
+// is a string containing a message that the client was initialized.
+func handleRestoreSeed(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseRestoreSeedArgs(params)
+	if err != nil {
+		return usage(restoreSeedRoute, err)
+	}
+	defer form.AppPass.Clear()
+	defer form.Seed.Clear()
+	if err := s.core.RestoreFromSeed(form.AppPass, string(form.Seed)); err != nil {
+		errMsg := fmt.Sprintf("unable to restore from seed: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCBackupError, errMsg)
+		return createResponse(restoreSeedRoute, nil, resErr)
+	}
+	res := seedRestoredStr
+	return createResponse(restoreSeedRoute, &res, nil)
+}
+
 // handleLogout logs out the DEX client. *msgjson.ResponsePayload.Error is empty
 // if successful.
 func handleLogout(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
@@ -495,14 +1104,14 @@ type helpMsg struct {
 // helpMsgs are a map of routes to help messages. They are broken down into six
 // sections.
 // In descending order:
-// 1. Password argument example inputs. These are arguments the caller may not
-//    want to echo listed in order of input.
-// 2. Argument example inputs. These are non-sensitive arguments listed in order
-//    of input.
-// 3. A description of the command.
-// 4. An extensive breakdown of the password arguments.
-// 5. An extensive breakdown of the arguements.
-// 6. An extensive breakdown of the returned values.
+//  1. Password argument example inputs. These are arguments the caller may not
+//     want to echo listed in order of input.
+//  2. Argument example inputs. These are non-sensitive arguments listed in order
+//     of input.
+//  3. A description of the command.
+//  4. An extensive breakdown of the password arguments.
+//  5. An extensive breakdown of the arguements.
+//  6. An extensive breakdown of the returned values.
 var helpMsgs = map[string]helpMsg{
 	helpRoute: {
 		pwArgsShort: ``,                           // password args example input
@@ -600,10 +1209,78 @@ var helpMsgs = map[string]helpMsg{
         "units" (string): Unit of measure for amounts.
       },...
     ]`,
+	},
+	exportTradesRoute: {
+		argsShort: `("format")`,
+		cmdSummary: `Export the completed orders and their settled and refunded matches,
+with the amounts sent, received, and paid in swap fees for each asset.`,
+		argsLong: `Args:
+    format (string): Optional. "json" or "csv". Default is "json".`,
+		returns: `Returns:
+    csv: string: One row for each match, with amounts in whole coins.
+    json: obj: The trade history.
+    {
+      "orders" (array): The completed orders.
+      "matches" (array): [
+        {
+          "host" (string): The DEX address.
+          "market" (string): The market ID.
+          "orderID" (string): The order ID.
+          "matchID" (string): The match ID.
+          "side" (string): Maker or Taker.
+          "sell" (bool): Whether the order was a sell order.
+          "stamp" (int): The match time. Milliseconds since 00:00:00 Jan 1 1970.
+          "rate" (int): The match rate.
+          "qty" (int): The match quantity.
+          "sentAsset" (string): The symbol of the asset sent.
+          "sent" (int): The amount sent.
+          "receivedAsset" (string): The symbol of the asset received.
+          "received" (int): The amount received.
+          "swapFee" (int): The estimated swap transaction fee in the asset sent.
+          "refunded" (bool): Whether the swap was refunded.
+        },...
+      ]
+      "assets" (array): [
+        {
+          "assetID" (int): The asset's BIP-44 registered coin index.
+          "symbol" (string): The coin symbol.
+          "sent" (int): The total amount sent.
+          "received" (int): The total amount received.
+          "fees" (int): The total estimated swap fees.
+          "net" (int): Received less sent and fees.
+        },...
+      ]
+    }`,
+	},
+	portfolioRoute: {
+		cmdSummary: `Summarize the holdings of each asset, including funds locked
+in open orders and active swaps. Fiat values are included if a rate source is
+configured.`,
+		returns: `Returns:
+    obj: The portfolio.
+    {
+      "assets" (array): [
+        {
+          "assetID" (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+            See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
+          "symbol" (string): The coin symbol.
+          "available" (int): The wallet balance available for funding orders.
+          "immature" (int): Wallet balance that requires confirmations before use.
+          "locked" (int): The wallet's locked balance.
+          "orderlocked" (int): The value committed to open orders and not yet swapped.
+          "swaplocked" (int): The value in unredeemed swap contracts.
+          "total" (int): The wallet balance plus the value in swap contracts.
+          "fiatrate" (float): Optional. The fiat value of one coin.
+          "fiatvalue" (float): Optional. The fiat value of the total.
+        },...
+      ]
+      "currency" (string): Optional. The fiat currency code.
+      "fiatvalue" (float): Optional. The fiat value of all assets.
+    }`,
 	},
 	registerRoute: {
 		pwArgsShort: `"appPass"`,
-		argsShort:   `"addr" fee ("cert")`,
+		argsShort:   `"addr" fee ("cert" "account")`,
 		cmdSummary: `Register for DEX. An ok response does not mean that registration is complete.
 Registration is complete after the fee transaction has been confirmed.`,
 		pwArgsLong: `Password Args:
@@ -611,7 +1288,11 @@ Registration is complete after the fee transaction has been confirmed.`,
 		argsLong: `Args:
     addr (string): The DEX address to register for.
     fee (int): The DEX fee.
-    cert (string): Optional. The TLS certificate path.`,
+    cert (string): Optional. The TLS certificate path. An additional account
+      uses the certificate of the DEX's other accounts if none is given.
+    account (string): Optional. The label of an additional account to register
+      at the DEX, e.g. to separate a bot's funds from manual trading. Omit to
+      register the default account.`,
 		returns: `Returns:
     {
       "feeID" (string): The fee transactions's txid and output index.
@@ -713,7 +1394,7 @@ Registration is complete after the fee transaction has been confirmed.`,
 	},
 	tradeRoute: {
 		pwArgsShort: `"appPass"`,
-		argsShort:   `"host" isLimit sell base quote qty rate immediate`,
+		argsShort:   `"host" isLimit sell base quote qty rate immediate ("account" "funding" "coins" confirmed visible)`,
 		cmdSummary:  `Make an order to buy or sell an asset.`,
 		pwArgsLong: `Password Args:
     appPass (string): The DEX client password.`,
@@ -726,7 +1407,23 @@ Registration is complete after the fee transaction has been confirmed.`,
     qty (int): The number of units to buy/sell. Must be a multiple of the lot size.
     rate (int): The atoms quote asset to pay/accept per unit base asset. e.g.
       156000 satoshi/DCR for the DCR(base)_BTC(quote).
-    immediate (bool): Require immediate match. Do not book the order.`,
+    immediate (bool): Require immediate match. Do not book the order.
+    account (string): Optional. The label of the account at the DEX to trade
+      with. Omit for the default account. The host may instead be given as an
+      account host, e.g. "bot@dex.example.com:7232".
+    funding (string): Optional. The coin selection strategy for funding the
+      order, one of "mininputs" (spend the largest coins), "minchange" (lock
+      the least value), or "oldest" (spend the oldest coins). Omit or use ""
+      for the wallet's default strategy.
+    coins (string): Optional. A comma-separated list of the hex IDs of the
+      coins to fund the order with, as listed by walletcoins. Omit the funding
+      strategy or use "manual" when choosing coins.
+    confirmed (bool): Optional. Place the order even though it is far from the
+      mid-gap rate or repeats a recent order. Default is false. An order
+      beyond the trade guard's block deviation is refused regardless.
+    visible (int): Optional. Make a standing limit order an iceberg order that
+      shows only this many units in the order book. Must be a multiple of the
+      lot size and less than qty. Default is 0, which shows the full quantity.`,
 		returns: `Returns:
     obj: The order details.
     {
@@ -746,11 +1443,425 @@ Registration is complete after the fee transaction has been confirmed.`,
     orderID (string): The hex ID of the order to cancel`,
 		returns: `Returns:
     string: The message "` + fmt.Sprintf(canceledOrderStr, "[order ID]") + `"`,
+	},
+	cancelAllRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `("host=[DEX address]" "market=[market ID]")`,
+		cmdSummary:  `Cancel all booked limit orders, optionally on one DEX or market.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    host (string): Optional. host=[DEX address]. Only orders on the DEX.
+    market (string): Optional. market=[market ID]. Only orders on the market,
+      e.g. market=dcr_btc.`,
+		returns: `Returns:
+    obj: The canceled orders.
+    {
+      "canceled" (array): The IDs of the orders that were canceled.
+      "error" (string): The cancellation errors, if any orders could not be
+        canceled.
+    }`,
+	},
+	cloneOrderRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"orderID" (rate)`,
+		cmdSummary:  `Place a new order with the same parameters as a completed or canceled order.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order to re-place.
+    rate (int): Optional. A new rate for a limit order, in atoms quote asset
+      per unit base asset. Default is the original order's rate.`,
+		returns: `Returns:
+    obj: The new order details.
+    {
+      "orderid" (string): The order's unique hex identifier.
+      "sig" (string): The DEX's signature of the order information.
+      "stamp" (int): The time the order was signed in milliseconds since 00:00:00
+        Jan 1 1970.
+    }`,
+	},
+	batchRoute: {
+		pwArgsShort: `("appPass")`,
+		argsShort:   `"commands"`,
+		cmdSummary: `Execute several commands in order, e.g. cancel orders and then place
+    new ones. The arguments of every command are checked before any command is
+    executed, and batches are executed one at a time. Execution stops at the
+    first command that fails, and the remaining commands are skipped. Commands
+    that already succeeded are not reversed. The commands that can be batched
+    are cancel, cancelall, cloneorder, trade, order, orders, matches,
+    portfolio, and wallets.`,
+		pwArgsLong: `Password Args:
+    appPass (string): Optional. The DEX client password. Required if any
+      command requires it.`,
+		argsLong: `Args:
+    commands (string): A JSON array of commands. The app password is supplied
+      by the batch, not in the args. At most 50 commands.
+      [{"cmd": "cancel", "args": ["[orderID]"]}, ...]`,
+		returns: `Returns:
+    obj: The result of each command, in order.
+    {
+      "results" (array): [
+        {
+          "cmd" (string): The command.
+          "result" (any): The command's result, if it succeeded.
+          "error" (obj): The command's error, if it failed.
+          "skipped" (bool): Whether the command was skipped because an earlier
+            command failed.
+        },...
+      ]
+    }`,
+	},
+	ordersRoute: {
+		argsShort:  `("host=[DEX address]" "market=[market ID]" "status=[statuses]" "since=[ms]" "until=[ms]" "n=[number]")`,
+		cmdSummary: `List orders, newest first. Cancel orders are not included.`,
+		argsLong: `Args:
+    host (string): Optional. host=[DEX address]. Only orders on the DEX.
+    market (string): Optional. market=[market ID]. Only orders on the market,
+      e.g. market=dcr_btc.
+    status (string): Optional. status=[statuses]. A comma-separated list of
+      order statuses. Valid statuses are epoch, booked, executed, canceled,
+      and revoked, e.g. status=booked,executed.
+    since (int): Optional. since=[ms]. Only orders placed at or after the time, in
+      milliseconds since 00:00:00 Jan 1 1970.
+    until (int): Optional. until=[ms]. Only orders placed at or before the time, in
+      milliseconds since 00:00:00 Jan 1 1970.
+    n (int): Optional. n=[number]. Only the newest n orders.`,
+		returns: `Returns:
+    array: The orders.
+    [
+      {
+      "host" (string): The DEX address.
+      "market" (string): The market ID.
+      "type" (int): The order type. 1 is limit, 2 is market.
+      "id" (string): The order ID.
+      "stamp" (int): The order time in milliseconds since 00:00:00 Jan 1 1970.
+      "sig" (string): The DEX's signature of the order.
+      "status" (int): The order status. 1 is epoch, 2 is booked, 3 is executed,
+        4 is canceled, and 5 is revoked.
+      "epoch" (int): The order's epoch index.
+      "qty" (int): The order quantity in atoms of the base asset.
+      "sell" (bool): Whether the order is a sell order.
+      "filled" (int): The filled quantity in atoms of the base asset.
+      "matches" (array): The order's matches. See the matches command.
+      "cancelling" (bool): Whether a cancel order is pending.
+      "canceled" (bool): Whether the order was canceled.
+      "rate" (int): The limit order rate in atoms of the quote asset per unit
+        of the base asset.
+      "tif" (int): The limit order time in force. 0 is immediate, 1 is
+        standing.
+      },...
+    ]`,
+	},
+	orderRoute: {
+		argsShort:  `"orderID"`,
+		cmdSummary: `Show an order.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order.`,
+		returns: `Returns:
+    obj: The order.
+    {
+      "host" (string): The DEX address.
+    "market" (string): The market ID.
+    "type" (int): The order type. 1 is limit, 2 is market.
+    "id" (string): The order ID.
+    "stamp" (int): The order time in milliseconds since 00:00:00 Jan 1 1970.
+    "sig" (string): The DEX's signature of the order.
+    "status" (int): The order status. 1 is epoch, 2 is booked, 3 is executed,
+      4 is canceled, and 5 is revoked.
+    "epoch" (int): The order's epoch index.
+    "qty" (int): The order quantity in atoms of the base asset.
+    "sell" (bool): Whether the order is a sell order.
+    "filled" (int): The filled quantity in atoms of the base asset.
+    "matches" (array): The order's matches. See the matches command.
+    "cancelling" (bool): Whether a cancel order is pending.
+    "canceled" (bool): Whether the order was canceled.
+    "rate" (int): The limit order rate in atoms of the quote asset per unit
+      of the base asset.
+    "tif" (int): The limit order time in force. 0 is immediate, 1 is
+      standing.
+    }`,
+	},
+	matchesRoute: {
+		argsShort: `("host=[DEX address]" "market=[market ID]" "status=[statuses]" "since=[ms]" "until=[ms]" "n=[number]")`,
+		cmdSummary: `List the matches of orders, newest first. The host, market, and status
+    filters select the orders. The time filters select the matches.`,
+		argsLong: `Args:
+    host (string): Optional. host=[DEX address]. Only orders on the DEX.
+    market (string): Optional. market=[market ID]. Only orders on the market,
+      e.g. market=dcr_btc.
+    status (string): Optional. status=[statuses]. A comma-separated list of
+      order statuses. Valid statuses are epoch, booked, executed, canceled,
+      and revoked, e.g. status=booked,executed.
+    since (int): Optional. since=[ms]. Only matches made at or after the time, in
+      milliseconds since 00:00:00 Jan 1 1970.
+    until (int): Optional. until=[ms]. Only matches made at or before the time, in
+      milliseconds since 00:00:00 Jan 1 1970.
+    n (int): Optional. n=[number]. Only the newest n matches.`,
+		returns: `Returns:
+    array: The matches.
+    [
+      {
+        "host" (string): The DEX address.
+        "orderID" (string): The order ID.
+        "sell" (bool): Whether the order is a sell order.
+        "matchID" (string): The match ID.
+        "status" (int): The match status. 0 is newly matched, 1 is maker swap
+          cast, 2 is taker swap cast, 3 is maker redeemed, and 4 is complete.
+        "rate" (int): The match rate.
+        "qty" (int): The match quantity in atoms of the base asset.
+        "side" (int): 0 is maker, 1 is taker.
+        "stamp" (int): The match time in milliseconds since 00:00:00 Jan 1
+          1970.
+      },...
+    ]`,
+	},
+	startBotRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"host" base quote "strategy" spread lots baseBudget quoteBudget (maxExposure driftTolerance)`,
+		cmdSummary: `Start a market maker bot. The bot keeps a buy and a sell order on the
+    market, and replaces them as the market moves. The app password is kept by
+    the bot until it is stopped.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    host (string): The DEX to trade on.
+    base (int): The BIP-44 coin index for the market's base asset.
+    quote (int): The BIP-44 coin index for the market's quote asset.
+    strategy (string): "spread" to quote around the order book's mid-gap rate,
+      or "oracle" to quote around the fiat exchange rate.
+    spread (float): The distance between the buy and sell rates, as a fraction
+      of the center rate. e.g. 0.02 for 2%.
+    lots (int): The number of lots to offer on each side.
+    baseBudget (int): The most base asset to commit to sell orders, in atoms.
+      0 disables selling.
+    quoteBudget (int): The most quote asset to commit to buy orders, in atoms.
+      0 disables buying.
+    maxExposure (int): Optional. The largest net quantity of base asset, in
+      atoms, the bot may buy or sell. 0, the default, is no limit.
+    driftTolerance (float): Optional. How far the target rate can move from an
+      order's rate, as a fraction of the target rate, before the order is
+      replaced. Default is a quarter of the spread.`,
+		returns: `Returns:
+    int: The bot ID.`,
+	},
+	stopBotRoute: {
+		argsShort:  `id`,
+		cmdSummary: `Stop a market maker bot and cancel its orders.`,
+		argsLong: `Args:
+    id (int): The bot ID.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(botStoppedStr, 0) + `"`,
+	},
+	botsRoute: {
+		cmdSummary: `List the running market maker bots.`,
+		returns: `Returns:
+    array: An array of bot statuses.
+    [
+      {
+        "id" (int): The bot ID.
+        "config" (obj): The bot's startbot arguments.
+        "position" (int): The net quantity of base asset bought by the bot's
+          filled orders, in atoms. Negative if more was sold than bought.
+        "basecommitted" (int): The base asset locked in open sell orders.
+        "quotecommitted" (int): The quote asset locked in open buy orders.
+        "orders" ([string]): The IDs of the bot's open orders.
+        "error" (string): The bot's most recent error, if any.
+      },...
+    ]`,
+	},
+	rescanWalletRoute: {
+		argsShort:  `assetID height`,
+		cmdSummary: `Rescan the blockchain for wallet transactions that a built-in wallet missed.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
+    height (int): The block height to start the rescan from.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(walletRescannedStr, "[symbol]", 0) + `"`,
+	},
+	walletCoinsRoute: {
+		argsShort:  `assetID ("walletName")`,
+		cmdSummary: `List a wallet's unspent coins, for choosing the coins that fund an order.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
+    walletName (string): Optional. The name of the wallet. Omit for the
+      asset's default wallet.`,
+		returns: `Returns:
+    array: An array of coins.
+    [
+      {
+        "id" (string): The hex coin ID.
+        "string" (string): The coin in the asset's format, e.g. txid:vout.
+        "value" (int): The coin value in atoms.
+        "confs" (int): The number of confirmations.
+        "address" (string): The address the coin pays to.
+        "label" (string): The wallet's label for the address.
+        "locked" (bool): Whether the coin is locked. Locked coins cannot fund
+          another order.
+        "orderID" (string): The ID of the active order funded by the coin, if
+          any.
+      },...
+    ]`,
+	},
+	recoverableSwapsRoute: {
+		cmdSummary: `List the swap contracts sent by the client that have not been redeemed or
+    refunded. Swaps that are not tracked by an active trade must be refunded
+    with refundswap.`,
+		returns: `Returns:
+    array: An array of swaps.
+    [
+      {
+        "host" (string): The DEX host.
+        "orderID" (string): The order ID.
+        "matchID" (string): The match ID.
+        "assetID" (int): The asset ID of the swap.
+        "coin" (string): The contract coin.
+        "coinID" (string): The hex contract coin ID.
+        "value" (int): The contract value in atoms.
+        "expiration" (int): The contract locktime, in milliseconds.
+        "refundable" (bool): Whether the locktime has expired.
+        "tracked" (bool): Whether an active trade will refund the swap.
+        "error" (string): An error checking the contract, if any.
+      },...
+    ]`,
+	},
+	recoverHistoryRoute: {
+		argsShort: `"host"`,
+		cmdSummary: `Rebuild the order and match history of the DEX account from the server's
+    records, such as after the loss of the client database. Orders and matches
+    that are already in the database are not modified. Must be logged in.`,
+		argsLong: `Args:
+    host (string): The DEX address.`,
+		returns: `Returns:
+    obj: The recovery summary.
+    {
+      "host" (string): The DEX host.
+      "orders" (int): The number of orders added to the database.
+      "matches" (int): The number of matches added to the database.
+      "brokenRule" (int): The rule the account was closed for violating, or 0.
+    }`,
+	},
+	verifyEpochRoute: {
+		argsShort: `"orderID"`,
+		cmdSummary: `Verify that an order was matched fairly by replaying the matching of its
+    epoch with the epoch queue and matching inputs provided by the server. The
+    other orders in the epoch are redacted. The server only retains recent
+    epochs. Must be logged in.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order.`,
+		returns: `Returns:
+    obj: The verification results.
+    {
+      "host" (string): The DEX host.
+      "marketID" (string): The market ID.
+      "orderID" (string): The order ID.
+      "epoch" (int): The order's epoch index.
+      "queueSize" (int): The number of orders in the epoch queue.
+      "bookOrders" (int): The number of book orders matched or canceled.
+      "matches" (int): The number of replayed matches of the order.
+      "seedOK" (bool): Whether the replayed shuffle seed matches the server's.
+      "resultOK" (bool): Whether the replayed results match the server's.
+      "missingMatches" (array): The IDs of replayed matches of the order that
+        are not in the database.
+      "fair" (bool): Whether all checks passed.
+    }`,
+	},
+	refundSwapRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"matchID"`,
+		cmdSummary:  `Refund an expired swap contract that is not tracked by an active trade.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    matchID (string): The hex ID of the match, as listed by recoverableswaps.`,
+		returns: `Returns:
+    string: The refund coin.`,
+	},
+	exportBackupRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"path"`,
+		cmdSummary: `Write an encrypted backup of the client's accounts, order history, and
+    wallet configuration to a file. The backup does not include the keys of
+    external wallets. Restore the backup with restorebackup.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password. The same password is required
+      to restore the backup.`,
+		argsLong: `Args:
+    path (string): The file to write the backup to.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(backupExportedStr, "[path]") + `"`,
+	},
+	restoreBackupRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"path"`,
+		cmdSummary: `Initialize the client from a backup written by exportbackup. The client
+    must not already be initialized.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password when the backup was exported.
+      The password becomes the client's password.`,
+		argsLong: `Args:
+    path (string): The backup file.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(backupRestoredStr, "[path]") + `"`,
+	},
+	appSeedRoute: {
+		pwArgsShort: `"appPass"`,
+		cmdSummary: `Show the application seed words. The keys of the client's DEX accounts
+    and built-in wallets are derived from the seed. Write the words down, and
+    keep them secret. Restore the seed with restoreseed. The seed is not shown
+    if the client has DEX accounts that were created before the seed, since
+    their keys can't be restored from it. Use exportbackup for such a client.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		returns: `Returns:
+    string: The seed words.`,
+	},
+	restoreSeedRoute: {
+		pwArgsShort: `"appPass" "seed"`,
+		cmdSummary: `Initialize the client with the application seed words shown by appseed.
+    Built-in wallets created after the restore have the same keys as before.
+    The client must not already be initialized.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The new DEX client password.
+    seed (string): The seed words, separated by spaces.`,
+		returns: `Returns:
+    string: The message "` + seedRestoredStr + `"`,
+	},
+	devicePromptsRoute: {
+		cmdSummary: `List the hardware wallet prompts that are waiting for a PIN or
+    passphrase.`,
+		returns: `Returns:
+    array: An array of prompts.
+    [
+      {
+        "id" (string): The prompt ID.
+        "assetID" (int): The asset ID of the wallet using the device.
+        "kind" (string): The requested input, "pin" or "passphrase".
+        "device" (string): The name of the device.
+        "message" (string): The prompt message.
+        "stamp" (int): The time of the prompt, in milliseconds.
+      },...
+    ]`,
+	},
+	respondPromptRoute: {
+		pwArgsShort: `"response"`,
+		argsShort:   `"id"`,
+		cmdSummary: `Respond to a hardware wallet prompt listed by deviceprompts. The device
+    waits for the response for two minutes.`,
+		pwArgsLong: `Password Args:
+    response (string): The requested PIN or passphrase.`,
+		argsLong: `Args:
+    id (string): The prompt ID.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(promptAnsweredStr, "[id]") + `"`,
 	},
 	withdrawRoute: {
 		pwArgsShort: `"appPass"`,
-		argsShort:   `assetID value "address"`,
-		cmdSummary:  `Withdraw value from an exchange wallet to address.`,
+		argsShort:   `assetID value "address" ("feerate=N" "confs=N")`,
+		cmdSummary: `Withdraw value from an exchange wallet to address. If a fee rate or
+    target confirmation count is given, the fee is subtracted from value.`,
 		pwArgsLong: `Password Args:
     appPass (string): The DEX client password.`,
 		argsLong: `Args:
@@ -759,9 +1870,55 @@ Registration is complete after the fee transaction has been confirmed.`,
       https://github.com/satoshilabs/slips/blob/master/slip-0044.md
     value (int): The amount to withdraw in units of the asset's smallest
       denomination (e.g. satoshis, atoms, etc.)"
-    address (string): The address to which withdrawn funds are sent.`,
+    address (string): The address to which withdrawn funds are sent.
+    feerate (int): Optional. The fee rate in units of the asset's smallest
+      denomination per byte.
+    confs (int): Optional. The target confirmation count used to estimate
+      the fee rate. Cannot be used with feerate.`,
 		returns: `Returns:
     string: "[coin ID]"`,
+	},
+	sendManyRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `assetID "address:value,..." ("feerate=N" "confs=N" "subtract=bool")`,
+		cmdSummary:  `Withdraw from an exchange wallet to several addresses in one transaction.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+    outputs (string): Comma-separated address:value pairs. Values are in
+      units of the asset's smallest denomination.
+    feerate (int): Optional. The fee rate in units of the asset's smallest
+      denomination per byte.
+    confs (int): Optional. The target confirmation count used to estimate
+      the fee rate. Cannot be used with feerate.
+    subtract (bool): Optional. Subtract the fee from the first output.`,
+		returns: `Returns:
+    array: The coin IDs of the outputs, in order.
+    [
+      "[coin ID]",...
+    ]`,
+	},
+	estimateWithdrawRoute: {
+		argsShort:  `assetID "address:value,..." ("feerate=N" "confs=N" "subtract=bool")`,
+		cmdSummary: `Estimate the fee of a withdrawal without sending it. See sendmany.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+    outputs (string): Comma-separated address:value pairs. Values are in
+      units of the asset's smallest denomination.
+    feerate (int): Optional. The fee rate in units of the asset's smallest
+      denomination per byte.
+    confs (int): Optional. The target confirmation count used to estimate
+      the fee rate. Cannot be used with feerate.
+    subtract (bool): Optional. Subtract the fee from the first output.`,
+		returns: `Returns:
+    obj: The withdrawal estimate.
+    {
+      "feeRate" (int): The fee rate per byte.
+      "size" (int): The transaction size in bytes.
+      "fee" (int): The total fee.
+      "inputs" (int): The number of inputs spent.
+    }`,
 	},
 	logoutRoute: {
 		cmdSummary: `Logout the DEX cleint.`,
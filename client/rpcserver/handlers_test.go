@@ -4,14 +4,20 @@
 package rpcserver
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
@@ -388,6 +394,88 @@ func TestHandleWallets(t *testing.T) {
 	}
 }
 
+func TestHandlePortfolio(t *testing.T) {
+	tc := &TCore{
+		portfolio: &core.Portfolio{
+			Assets: []*core.AssetPortfolio{{
+				AssetID:    42,
+				Symbol:     "dcr",
+				Available:  5,
+				SwapLocked: 2,
+				Total:      7,
+			}},
+		},
+	}
+	r := &RPCServer{core: tc}
+	payload := handlePortfolio(r, nil)
+	res := new(core.Portfolio)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Assets) != 1 || res.Assets[0].Total != 7 || res.Assets[0].SwapLocked != 2 {
+		t.Fatalf("wrong portfolio %+v", res.Assets)
+	}
+}
+
+func TestHandleExportTrades(t *testing.T) {
+	history := &core.TradeHistory{
+		Matches: []*core.ExportedMatch{{
+			Host:          "dex:1234",
+			MarketID:      "dcr_btc",
+			Side:          "Taker",
+			Qty:           1e8,
+			Rate:          2e7,
+			SentAsset:     "dcr",
+			Sent:          1e8,
+			ReceivedAsset: "btc",
+			Received:      2e7,
+		}},
+	}
+	tests := []struct {
+		name            string
+		args            []string
+		tradeHistoryErr error
+		wantErrCode     int
+	}{{
+		name:        "ok json",
+		wantErrCode: -1,
+	}, {
+		name:        "ok csv",
+		args:        []string{"csv"},
+		wantErrCode: -1,
+	}, {
+		name:        "bad format",
+		args:        []string{"xml"},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:            "core.TradeHistory error",
+		tradeHistoryErr: errors.New("error"),
+		wantErrCode:     msgjson.RPCExportError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			tradeHistory:    history,
+			tradeHistoryErr: test.tradeHistoryErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleExportTrades(r, &RawParams{Args: test.args})
+		if len(test.args) > 0 && test.args[0] == "csv" {
+			res := ""
+			if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(res, "dex:1234,dcr_btc") {
+				t.Fatalf("%s: unexpected csv %q", test.name, res)
+			}
+			continue
+		}
+		res := new(core.TradeHistory)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestHandleRegister(t *testing.T) {
 	pw := encode.PassBytes("password123")
 	params := &RawParams{
@@ -685,90 +773,282 @@ func TestHandleTrade(t *testing.T) {
 	}
 }
 
-func TestHandleCancel(t *testing.T) {
+func TestHandleStartBot(t *testing.T) {
 	params := &RawParams{
-		PWArgs: []encode.PassBytes{encode.PassBytes("123")},
-		Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
-	}
+		PWArgs: []encode.PassBytes{encode.PassBytes("123")}, // 0. AppPass
+		Args: []string{
+			"1.2.3.4:3000", // 0. DEX
+			"42",           // 1. Base
+			"0",            // 2. Quote
+			"spread",       // 3. Strategy
+			"0.02",         // 4. Spread
+			"1",            // 5. Lots
+			"100000000",    // 6. BaseBudget
+			"1000000",      // 7. QuoteBudget
+		}}
 	tests := []struct {
 		name        string
 		params      *RawParams
-		cancelErr   error
+		mm          *TMarketMaker
 		wantErrCode int
 	}{{
 		name:        "ok",
 		params:      params,
+		mm:          &TMarketMaker{startID: 1},
 		wantErrCode: -1,
 	}, {
-		name:        "core.Cancel error",
+		name:        "mm.Start error",
 		params:      params,
-		cancelErr:   errors.New("error"),
-		wantErrCode: msgjson.RPCCancelError,
+		mm:          &TMarketMaker{startErr: errors.New("error")},
+		wantErrCode: msgjson.RPCMarketMakerError,
+	}, {
+		name:        "no market maker",
+		params:      params,
+		wantErrCode: msgjson.RPCMarketMakerError,
 	}, {
 		name:        "bad params",
 		params:      &RawParams{},
+		mm:          &TMarketMaker{},
 		wantErrCode: msgjson.RPCArgumentsError,
 	}}
 	for _, test := range tests {
-		tc := &TCore{cancelErr: test.cancelErr}
-		r := &RPCServer{core: tc}
-		payload := handleCancel(r, test.params)
+		r := &RPCServer{core: &TCore{}}
+		if test.mm != nil {
+			r.mm = test.mm
+		}
+		payload := handleStartBot(r, test.params)
+		var id uint64
+		if err := verifyResponse(payload, &id, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && id != test.mm.startID {
+			t.Fatalf("%s: wrong bot ID %d", test.name, id)
+		}
+	}
+}
+
+func TestHandleStopBot(t *testing.T) {
+	params := &RawParams{Args: []string{"1"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		mm          *TMarketMaker
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		mm:          &TMarketMaker{},
+		wantErrCode: -1,
+	}, {
+		name:        "mm.Stop error",
+		params:      params,
+		mm:          &TMarketMaker{stopErr: errors.New("error")},
+		wantErrCode: msgjson.RPCMarketMakerError,
+	}, {
+		name:        "no market maker",
+		params:      params,
+		wantErrCode: msgjson.RPCMarketMakerError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"one"}},
+		mm:          &TMarketMaker{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		r := &RPCServer{core: &TCore{}}
+		if test.mm != nil {
+			r.mm = test.mm
+		}
+		payload := handleStopBot(r, test.params)
 		res := ""
 		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
-			t.Fatal(err)
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != fmt.Sprintf(botStoppedStr, 1) {
+			t.Fatalf("%s: wrong response %q", test.name, res)
 		}
 	}
 }
 
-// tCoin satifies the asset.Coin interface.
-type tCoin struct{}
+func TestHandleBots(t *testing.T) {
+	tmm := &TMarketMaker{bots: []*mm.BotStatus{{ID: 1, Config: &mm.BotConfig{Strategy: mm.SpreadStrategy}}}}
+	r := &RPCServer{core: &TCore{}, mm: tmm}
+	payload := handleBots(r, nil)
+	var res []*mm.BotStatus
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].ID != 1 || res[0].Config.Strategy != mm.SpreadStrategy {
+		t.Fatalf("wrong bots %+v", res)
+	}
 
-func (tCoin) ID() dex.Bytes {
-	return nil
+	r.mm = nil
+	payload = handleBots(r, nil)
+	if err := verifyResponse(payload, &res, msgjson.RPCMarketMakerError); err != nil {
+		t.Fatal(err)
+	}
 }
-func (tCoin) String() string {
-	return ""
+
+func TestHandleDevicePrompts(t *testing.T) {
+	tc := &TCore{prompts: []*core.DevicePrompt{{ID: "abcd", Kind: "pin"}}}
+	r := &RPCServer{core: tc}
+	payload := handleDevicePrompts(r, nil)
+	var res []*core.DevicePrompt
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].ID != "abcd" || res[0].Kind != "pin" {
+		t.Fatalf("wrong prompts %+v", res)
+	}
 }
-func (tCoin) Value() uint64 {
-	return 0
+
+func TestHandleRespondPrompt(t *testing.T) {
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("1234")},
+		Args:   []string{"abcd"},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		respondErr  error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.RespondDevicePrompt error",
+		params:      params,
+		respondErr:  errors.New("error"),
+		wantErrCode: msgjson.RPCDeviceError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"abcd"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{respondPromptErr: test.respondErr}
+		r := &RPCServer{core: tc}
+		payload := handleRespondPrompt(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != fmt.Sprintf(promptAnsweredStr, "abcd") {
+			t.Fatalf("%s: wrong response %q", test.name, res)
+		}
+	}
 }
-func (tCoin) Confirmations() (uint32, error) {
-	return 0, nil
+
+func TestHandleRescanWallet(t *testing.T) {
+	params := &RawParams{Args: []string{"42", "100"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		rescanErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.RescanWallet error",
+		params:      params,
+		rescanErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCRecoveryError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		r := &RPCServer{core: &TCore{rescanErr: test.rescanErr}}
+		payload := handleRescanWallet(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != fmt.Sprintf(walletRescannedStr, "dcr", 100) {
+			t.Fatalf("%s: wrong response %q", test.name, res)
+		}
+	}
 }
-func (tCoin) Redeem() dex.Bytes {
-	return nil
+
+func TestHandleWalletCoins(t *testing.T) {
+	coins := []*core.WalletCoin{{
+		WalletCoin: &asset.WalletCoin{ID: dex.Bytes{0x01}, Value: 5e8, Locked: true},
+		OrderID:    "abcd",
+	}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		coinsErr    error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: -1,
+	}, {
+		name:        "ok with wallet name",
+		params:      &RawParams{Args: []string{"42", "trading"}},
+		wantErrCode: -1,
+	}, {
+		name:        "core.WalletCoins error",
+		params:      &RawParams{Args: []string{"42"}},
+		coinsErr:    errors.New("error"),
+		wantErrCode: msgjson.RPCCoinsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"dcr"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		r := &RPCServer{core: &TCore{walletCoins: coins, walletCoinsErr: test.coinsErr}}
+		payload := handleWalletCoins(r, test.params)
+		var res []*core.WalletCoin
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && (len(res) != 1 || res[0].OrderID != "abcd" || !res[0].Locked) {
+			t.Fatalf("%s: wrong response %+v", test.name, res)
+		}
+	}
 }
 
-func TestHandleWithdraw(t *testing.T) {
-	pw := encode.PassBytes("password123")
-	params := &RawParams{
-		PWArgs: []encode.PassBytes{pw},
-		Args: []string{
-			"42",
-			"1000",
-			"abc",
-		},
+func TestHandleRecoverableSwaps(t *testing.T) {
+	tc := &TCore{recoverableSwaps: []*core.RecoverableSwap{{MatchID: "abcd", Refundable: true}}}
+	r := &RPCServer{core: tc}
+	payload := handleRecoverableSwaps(r, nil)
+	var res []*core.RecoverableSwap
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].MatchID != "abcd" || !res[0].Refundable {
+		t.Fatalf("wrong swaps %+v", res)
+	}
+	tc.recoverableErr = errors.New("error")
+	payload = handleRecoverableSwaps(r, nil)
+	if err := verifyResponse(payload, &res, msgjson.RPCRecoveryError); err != nil {
+		t.Fatal(err)
 	}
+}
+
+func TestHandleRecoverHistory(t *testing.T) {
+	params := &RawParams{Args: []string{"dex.example:7232"}}
 	tests := []struct {
 		name        string
 		params      *RawParams
-		walletState *core.WalletState
-		coin        asset.Coin
-		withdrawErr error
+		recoverErr  error
 		wantErrCode int
 	}{{
 		name:        "ok",
 		params:      params,
-		walletState: &core.WalletState{},
-		coin:        tCoin{},
 		wantErrCode: -1,
 	}, {
-		name:        "core.Withdraw error",
+		name:        "core.RecoverHistory error",
 		params:      params,
-		walletState: &core.WalletState{},
-		coin:        tCoin{},
-		withdrawErr: errors.New("error"),
-		wantErrCode: msgjson.RPCWithdrawError,
+		recoverErr:  errors.New("error"),
+		wantErrCode: msgjson.RPCRecoveryError,
 	}, {
 		name:        "bad params",
 		params:      &RawParams{},
@@ -776,41 +1056,685 @@ func TestHandleWithdraw(t *testing.T) {
 	}}
 	for _, test := range tests {
 		tc := &TCore{
-			walletState: test.walletState,
-			coin:        test.coin,
-			withdrawErr: test.withdrawErr,
+			historyRecovery:   &core.HistoryRecovery{Host: "dex.example:7232", Orders: 3, Matches: 2},
+			recoverHistoryErr: test.recoverErr,
 		}
 		r := &RPCServer{core: tc}
-		payload := handleWithdraw(r, test.params)
+		payload := handleRecoverHistory(r, test.params)
+		res := new(core.HistoryRecovery)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && (res.Orders != 3 || res.Matches != 2) {
+			t.Fatalf("%s: wrong response %+v", test.name, res)
+		}
+	}
+}
+
+func TestHandleRefundSwap(t *testing.T) {
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("123")},
+		Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		refundErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.RefundSwap error",
+		params:      params,
+		refundErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCRecoveryError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{PWArgs: params.PWArgs, Args: []string{"abcd"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		r := &RPCServer{core: &TCore{refundCoin: "refund:0", refundErr: test.refundErr}}
+		payload := handleRefundSwap(r, test.params)
 		res := ""
 		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
-			t.Fatal(err)
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != "refund:0" {
+			t.Fatalf("%s: wrong response %q", test.name, res)
 		}
 	}
 }
 
-func TestHandleLogout(t *testing.T) {
+func TestHandleCancel(t *testing.T) {
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("123")},
+		Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+	}
 	tests := []struct {
 		name        string
-		logoutErr   error
+		params      *RawParams
+		cancelErr   error
 		wantErrCode int
 	}{{
 		name:        "ok",
+		params:      params,
 		wantErrCode: -1,
 	}, {
-		name:        "core.Logout error",
-		logoutErr:   errors.New("error"),
-		wantErrCode: msgjson.RPCLogoutError,
+		name:        "core.Cancel error",
+		params:      params,
+		cancelErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCCancelError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
 	}}
 	for _, test := range tests {
-		tc := &TCore{
-			logoutErr: test.logoutErr,
-		}
+		tc := &TCore{cancelErr: test.cancelErr}
 		r := &RPCServer{core: tc}
-		payload := handleLogout(r, nil)
+		payload := handleCancel(r, test.params)
 		res := ""
 		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
 			t.Fatal(err)
 		}
 	}
 }
+
+func TestHandleCloneOrder(t *testing.T) {
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("123")},
+		Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e", "1000"},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		tradeErr    error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.CloneOrder error",
+		params:      params,
+		tradeErr:    errors.New("error"),
+		wantErrCode: msgjson.RPCTradeError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{order: new(core.Order), tradeErr: test.tradeErr}
+		r := &RPCServer{core: tc}
+		payload := handleCloneOrder(r, test.params)
+		res := new(tradeResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandleCancelAll(t *testing.T) {
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("123")},
+		Args:   []string{"market=dcr_btc"},
+	}
+	tests := []struct {
+		name         string
+		params       *RawParams
+		canceled     []string
+		cancelAllErr error
+		wantErrCode  int
+		wantErrMsg   bool
+	}{{
+		name:        "ok",
+		params:      params,
+		canceled:    []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+		wantErrCode: -1,
+	}, {
+		name:         "some orders not canceled",
+		params:       params,
+		canceled:     []string{},
+		cancelAllErr: errors.New("error"),
+		wantErrCode:  -1,
+		wantErrMsg:   true,
+	}, {
+		name:         "core.CancelAll error",
+		params:       params,
+		cancelAllErr: errors.New("error"),
+		wantErrCode:  msgjson.RPCCancelError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{canceled: test.canceled, cancelAllErr: test.cancelAllErr}
+		r := &RPCServer{core: tc}
+		payload := handleCancelAll(r, test.params)
+		res := new(cancelAllResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+		if test.wantErrCode == -1 && (res.Error != "") != test.wantErrMsg {
+			t.Fatalf("%s: unexpected error message %q", test.name, res.Error)
+		}
+	}
+}
+
+func TestHandleBatch(t *testing.T) {
+	oid := "fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"
+	cmds := `[{"cmd":"cancel","args":["` + oid + `"]},{"cmd":"orders","args":["status=booked"]}]`
+	tests := []struct {
+		name        string
+		params      *RawParams
+		cancelErr   error
+		wantErrCode int
+		wantSkipped bool
+	}{{
+		name:        "ok",
+		params:      &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("123")}, Args: []string{cmds}},
+		wantErrCode: -1,
+	}, {
+		name:        "failed command skips the rest",
+		params:      &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("123")}, Args: []string{cmds}},
+		cancelErr:   errors.New("error"),
+		wantErrCode: -1,
+		wantSkipped: true,
+	}, {
+		name:        "no password",
+		params:      &RawParams{Args: []string{cmds}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "command cannot be batched",
+		params:      &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("123")}, Args: []string{`[{"cmd":"batch"}]`}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name: "bad command args",
+		params: &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("123")},
+			Args: []string{`[{"cmd":"orders"},{"cmd":"cancel","args":["abc"]}]`}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{cancelErr: test.cancelErr}
+		r := &RPCServer{core: tc}
+		payload := handleBatch(r, test.params)
+		res := new(batchResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if len(res.Results) != 2 {
+			t.Fatalf("%s: expected 2 results, got %d", test.name, len(res.Results))
+		}
+		if (res.Results[0].Error != nil) != (test.cancelErr != nil) {
+			t.Fatalf("%s: wrong cancel error %v", test.name, res.Results[0].Error)
+		}
+		if res.Results[1].Skipped != test.wantSkipped {
+			t.Fatalf("%s: expected skipped = %t", test.name, test.wantSkipped)
+		}
+		if !test.wantSkipped && res.Results[1].Error != nil {
+			t.Fatalf("%s: unexpected orders error %v", test.name, res.Results[1].Error)
+		}
+		// The batch's password is cleared.
+		if !bytes.Equal(test.params.PWArgs[0], make([]byte, 3)) {
+			t.Fatalf("%s: password not cleared", test.name)
+		}
+	}
+}
+
+func TestHandleOrders(t *testing.T) {
+	params := &RawParams{Args: []string{"status=booked,executed", "n=10"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		ordersErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "no filter",
+		params:      &RawParams{},
+		wantErrCode: -1,
+	}, {
+		name:        "core.Orders error",
+		params:      params,
+		ordersErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCOrdersError,
+	}, {
+		name:        "bad filter",
+		params:      &RawParams{Args: []string{"status=filled"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{orders: []*core.Order{{ID: "abc"}}, ordersErr: test.ordersErr}
+		r := &RPCServer{core: tc}
+		payload := handleOrders(r, test.params)
+		var res []*core.Order
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandleOrder(t *testing.T) {
+	params := &RawParams{Args: []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		ordersErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.Order error",
+		params:      params,
+		ordersErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCOrdersError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{order: &core.Order{ID: params.Args[0]}, ordersErr: test.ordersErr}
+		r := &RPCServer{core: tc}
+		payload := handleOrder(r, test.params)
+		res := new(core.Order)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandleVerifyEpoch(t *testing.T) {
+	params := &RawParams{Args: []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		verifyErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.VerifyEpoch error",
+		params:      params,
+		verifyErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCOrdersError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			epochVerification: &core.EpochVerification{OrderID: params.Args[0], Fair: true},
+			verifyEpochErr:    test.verifyErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleVerifyEpoch(r, test.params)
+		res := new(core.EpochVerification)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && !res.Fair {
+			t.Fatalf("%s: wrong response %+v", test.name, res)
+		}
+	}
+}
+
+func TestHandleMatches(t *testing.T) {
+	params := &RawParams{Args: []string{"host=dex.example.com", "since=1585000000000"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		ordersErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.Matches error",
+		params:      params,
+		ordersErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCOrdersError,
+	}, {
+		name:        "bad filter",
+		params:      &RawParams{Args: []string{"since"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{matches: []*core.OrderMatch{{Match: &core.Match{MatchID: "abc"}}}, ordersErr: test.ordersErr}
+		r := &RPCServer{core: tc}
+		payload := handleMatches(r, test.params)
+		var res []*core.OrderMatch
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tCoin satifies the asset.Coin interface.
+type tCoin struct{}
+
+func (tCoin) ID() dex.Bytes {
+	return nil
+}
+func (tCoin) String() string {
+	return ""
+}
+func (tCoin) Value() uint64 {
+	return 0
+}
+func (tCoin) Confirmations() (uint32, error) {
+	return 0, nil
+}
+func (tCoin) Redeem() dex.Bytes {
+	return nil
+}
+
+func TestHandleWithdraw(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{pw},
+		Args: []string{
+			"42",
+			"1000",
+			"abc",
+		},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		walletState *core.WalletState
+		coin        asset.Coin
+		withdrawErr error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		walletState: &core.WalletState{},
+		coin:        tCoin{},
+		wantErrCode: -1,
+	}, {
+		name:        "core.Withdraw error",
+		params:      params,
+		walletState: &core.WalletState{},
+		coin:        tCoin{},
+		withdrawErr: errors.New("error"),
+		wantErrCode: msgjson.RPCWithdrawError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			walletState: test.walletState,
+			coin:        test.coin,
+			withdrawErr: test.withdrawErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleWithdraw(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A fee rate sends the withdrawal with the fee subtracted.
+	tc := &TCore{withdrawCoins: []asset.Coin{tCoin{}}}
+	r := &RPCServer{core: tc}
+	params.Args = append(params.Args, "feerate=20")
+	payload := handleWithdraw(r, params)
+	res := ""
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if tc.withdrawal == nil || tc.withdrawal.FeeRate != 20 || !tc.withdrawal.Subtract ||
+		len(tc.withdrawal.Outputs) != 1 || tc.withdrawal.Outputs[0].Value != 1000 {
+		t.Fatalf("wrong withdrawal %+v", tc.withdrawal)
+	}
+}
+
+func TestHandleSendMany(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{pw},
+		Args:   []string{"42", "abc:1000,def:2000", "confs=3"},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		withdrawErr error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.SendWithdrawal error",
+		params:      params,
+		withdrawErr: errors.New("error"),
+		wantErrCode: msgjson.RPCWithdrawError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			withdrawCoins: []asset.Coin{tCoin{}, tCoin{}},
+			withdrawErr:   test.withdrawErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleSendMany(r, test.params)
+		var res []string
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && len(res) != 2 {
+			t.Fatalf("%s: expected 2 coin IDs, got %d", test.name, len(res))
+		}
+	}
+}
+
+func TestHandleEstimateWithdraw(t *testing.T) {
+	params := &RawParams{Args: []string{"42", "abc:1000", "feerate=10", "subtract=true"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		withdrawErr error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.EstimateWithdrawal error",
+		params:      params,
+		withdrawErr: errors.New("error"),
+		wantErrCode: msgjson.RPCWithdrawError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			withdrawEst: &asset.WithdrawalEstimate{FeeRate: 10, Size: 200, Fee: 2000, Inputs: 1},
+			withdrawErr: test.withdrawErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleEstimateWithdraw(r, test.params)
+		res := new(asset.WithdrawalEstimate)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res.Fee != 2000 {
+			t.Fatalf("%s: wrong fee %d", test.name, res.Fee)
+		}
+	}
+}
+
+func TestHandleLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		logoutErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		wantErrCode: -1,
+	}, {
+		name:        "core.Logout error",
+		logoutErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCLogoutError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			logoutErr: test.logoutErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleLogout(r, nil)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandleExportAndRestoreBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuptest")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dexc.backup")
+	pw := encode.PassBytes("123")
+	params := &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{path}}
+	backup := []byte{0x00, 0x01, 0x02}
+
+	tests := []struct {
+		name        string
+		params      *RawParams
+		backupErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core error",
+		params:      params,
+		backupErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCBackupError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{PWArgs: params.PWArgs},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{backup: backup, backupErr: test.backupErr}
+		r := &RPCServer{core: tc}
+		payload := handleExportBackup(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: exportbackup: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != fmt.Sprintf(backupExportedStr, path) {
+			t.Fatalf("%s: wrong exportbackup response %q", test.name, res)
+		}
+		payload = handleRestoreBackup(r, test.params)
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: restorebackup: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 {
+			if res != fmt.Sprintf(backupRestoredStr, path) {
+				t.Fatalf("%s: wrong restorebackup response %q", test.name, res)
+			}
+			if !bytes.Equal(tc.restoredBackup, backup) {
+				t.Fatalf("%s: wrong backup restored %x", test.name, tc.restoredBackup)
+			}
+		}
+	}
+
+	// Missing backup file.
+	r := &RPCServer{core: new(TCore)}
+	params.Args = []string{filepath.Join(dir, "missing")}
+	payload := handleRestoreBackup(r, params)
+	res := ""
+	if err := verifyResponse(payload, &res, msgjson.RPCBackupError); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleAppSeedAndRestoreSeed(t *testing.T) {
+	pw := encode.PassBytes("123")
+	seed := "topmost Istanbul Pluto"
+	seedParams := &RawParams{PWArgs: []encode.PassBytes{pw}}
+	restoreParams := &RawParams{PWArgs: []encode.PassBytes{pw, encode.PassBytes(seed)}}
+	tests := []struct {
+		name          string
+		seedParams    *RawParams
+		restoreParams *RawParams
+		seedErr       error
+		wantErrCode   int
+	}{{
+		name:          "ok",
+		seedParams:    seedParams,
+		restoreParams: restoreParams,
+		wantErrCode:   -1,
+	}, {
+		name:          "core error",
+		seedParams:    seedParams,
+		restoreParams: restoreParams,
+		seedErr:       errors.New("error"),
+		wantErrCode:   msgjson.RPCBackupError,
+	}, {
+		name:          "bad params",
+		seedParams:    &RawParams{},
+		restoreParams: seedParams,
+		wantErrCode:   msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{seed: seed, seedErr: test.seedErr}
+		r := &RPCServer{core: tc}
+		payload := handleAppSeed(r, test.seedParams)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: appseed: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != seed {
+			t.Fatalf("%s: wrong appseed response %q", test.name, res)
+		}
+		payload = handleRestoreSeed(r, test.restoreParams)
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: restoreseed: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 {
+			if res != seedRestoredStr {
+				t.Fatalf("%s: wrong restoreseed response %q", test.name, res)
+			}
+			if tc.restoredSeed != seed {
+				t.Fatalf("%s: wrong seed restored %q", test.name, tc.restoredSeed)
+			}
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/dcrd/certgen"
@@ -45,7 +46,9 @@ const (
 
 var (
 	// Check that core.Core satisfies ClientCore.
-	_   ClientCore = (*core.Core)(nil)
+	_ ClientCore = (*core.Core)(nil)
+	// Check that mm.MarketMaker satisfies MarketMaker.
+	_   MarketMaker = (*mm.MarketMaker)(nil)
 	log slog.Logger
 	// errUnknownCmd is wrapped when the command is not know.
 	errUnknownCmd = errors.New("unknown command")
@@ -56,22 +59,52 @@ type ClientCore interface {
 	AssetBalance(assetID uint32) (*db.Balance, error)
 	Book(host string, base, quote uint32) (orderBook *core.OrderBook, err error)
 	Cancel(appPass []byte, orderID string) error
+	CancelAll(appPass []byte, filter *core.OrderFilter) ([]string, error)
+	CloneOrder(appPass []byte, orderID string, rate uint64) (*core.Order, error)
 	CloseWallet(assetID uint32) error
 	CreateWallet(appPass, walletPass []byte, form *core.WalletForm) error
+	DevicePrompts() []*core.DevicePrompt
+	EstimateWithdrawal(assetID uint32, w *asset.Withdrawal) (*asset.WithdrawalEstimate, error)
 	Exchanges() (exchanges map[string]*core.Exchange)
+	ExportBackup(appPass []byte) ([]byte, error)
+	ExportSeed(appPass []byte) (string, error)
 	InitializeClient(appPass []byte) error
+	LoggedIn() bool
 	Login(appPass []byte) (*core.LoginResult, error)
 	Logout() error
+	Matches(filter *core.OrderFilter) ([]*core.OrderMatch, error)
+	NotificationFeed() <-chan core.Notification
 	OpenWallet(assetID uint32, appPass []byte) error
+	Order(orderID string) (*core.Order, error)
+	Orders(filter *core.OrderFilter) ([]*core.Order, error)
 	GetFee(addr, cert string) (fee uint64, err error)
+	Portfolio() *core.Portfolio
+	TradeHistory() (*core.TradeHistory, error)
+	RecoverableSwaps() ([]*core.RecoverableSwap, error)
+	RecoverHistory(host string) (*core.HistoryRecovery, error)
+	RefundSwap(appPass []byte, matchID string) (refundCoin string, err error)
 	Register(form *core.RegisterForm) (*core.RegisterResult, error)
+	RescanWallet(assetID, height uint32) error
+	RestoreBackup(appPass, archive []byte) error
+	RestoreFromSeed(appPass []byte, seed string) error
+	RespondDevicePrompt(id string, response []byte) error
+	SendWithdrawal(appPass []byte, assetID uint32, w *asset.Withdrawal) ([]asset.Coin, error)
 	Sync(dex string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error)
 	Trade(appPass []byte, form *core.TradeForm) (order *core.Order, err error)
+	VerifyEpoch(orderID string) (*core.EpochVerification, error)
+	WalletCoins(assetID uint32, walletName string) ([]*core.WalletCoin, error)
 	WalletState(assetID uint32) (walletState *core.WalletState)
 	Wallets() (walletsStates []*core.WalletState)
 	Withdraw(appPass []byte, assetID uint32, value uint64, addr string) (asset.Coin, error)
 }
 
+// MarketMaker is satisfied by mm.MarketMaker.
+type MarketMaker interface {
+	Start(appPass []byte, cfg *mm.BotConfig) (id uint64, err error)
+	Stop(id uint64) error
+	Bots() []*mm.BotStatus
+}
+
 // marketSyncer is used to synchronize market subscriptions. The marketSyncer
 // manages a map of clients who are subscribed to the market, and distributes
 // order book updates when received.
@@ -117,14 +150,17 @@ out:
 type RPCServer struct {
 	ctx       context.Context
 	core      ClientCore
+	mm        MarketMaker
 	addr      string
 	tlsConfig *tls.Config
 	srv       *http.Server
 	authsha   [32]byte
 	mtx       sync.RWMutex
+	batchMtx  sync.Mutex
 	syncers   map[string]*marketSyncer
 	clients   map[int32]*wsClient
 	wg        sync.WaitGroup
+	readOnly  bool
 }
 
 // genCertPair generates a key/cert pair to the paths provided.
@@ -197,10 +233,15 @@ func (s *RPCServer) handleJSON(w http.ResponseWriter, r *http.Request) {
 	s.parseHTTPRequest(w, req)
 }
 
-// Config holds variables neede to create a new RPC Server.
+// Config holds variables neede to create a new RPC Server. MM is optional.
+// Without it, the market maker bot routes return an error.
 type Config struct {
 	Core                        ClientCore
 	Addr, User, Pass, Cert, Key string
+	MM                          MarketMaker
+	// ReadOnly limits the server to the readOnlyRoutes until the user logs in,
+	// so that monitoring tools may run without the app password.
+	ReadOnly bool
 }
 
 // SetLogger sets the logger for the RPCServer package.
@@ -245,11 +286,13 @@ func New(cfg *Config) (*RPCServer, error) {
 	// Make the server.
 	s := &RPCServer{
 		core:      cfg.Core,
+		mm:        cfg.MM,
 		srv:       httpServer,
 		addr:      cfg.Addr,
 		tlsConfig: tlsConfig,
 		syncers:   make(map[string]*marketSyncer),
 		clients:   make(map[int32]*wsClient),
+		readOnly:  cfg.ReadOnly,
 	}
 
 	// Create authsha to verify requests against.
@@ -297,6 +340,12 @@ func (s *RPCServer) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 		}
 	}()
 
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.readNotifications(ctx)
+	}()
+
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
@@ -314,6 +363,20 @@ func (s *RPCServer) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 	return &s.wg, nil
 }
 
+// readNotifications reads from the Core notification channel and relays to
+// subscribed websocket clients.
+func (s *RPCServer) readNotifications(ctx context.Context) {
+	ch := s.core.NotificationFeed()
+	for {
+		select {
+		case n := <-ch:
+			s.notifySubscribers(n)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // handleRequest sends the request to the correct handler function if able.
 func (s *RPCServer) handleRequest(req *msgjson.Message) *msgjson.ResponsePayload {
 	payload := new(msgjson.ResponsePayload)
@@ -331,6 +394,14 @@ func (s *RPCServer) handleRequest(req *msgjson.Message) *msgjson.ResponsePayload
 		return payload
 	}
 
+	// In read-only mode, only the read-only routes are available until login.
+	if s.readOnly && !readOnlyRoutes[req.Route] && !s.core.LoggedIn() {
+		log.Debugf("%s refused in read-only mode", req.Route)
+		payload.Error = msgjson.NewError(msgjson.RPCReadOnlyError,
+			fmt.Sprintf("%s is not available in read-only mode. login first", req.Route))
+		return payload
+	}
+
 	params := new(RawParams)
 	err := req.Unmarshal(params)
 	if err != nil {
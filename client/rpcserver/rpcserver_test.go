@@ -21,6 +21,7 @@ import (
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/slog"
@@ -55,12 +56,44 @@ type TCore struct {
 	exchanges           map[string]*core.Exchange
 	loginErr            error
 	loginResult         *core.LoginResult
+	loggedIn            bool
 	order               *core.Order
 	tradeErr            error
 	cancelErr           error
 	coin                asset.Coin
 	withdrawErr         error
+	withdrawal          *asset.Withdrawal
+	withdrawEst         *asset.WithdrawalEstimate
+	withdrawCoins       []asset.Coin
 	logoutErr           error
+	portfolio           *core.Portfolio
+	tradeHistory        *core.TradeHistory
+	tradeHistoryErr     error
+	prompts             []*core.DevicePrompt
+	respondPromptErr    error
+	rescanErr           error
+	walletCoins         []*core.WalletCoin
+	walletCoinsErr      error
+	recoverableSwaps    []*core.RecoverableSwap
+	historyRecovery     *core.HistoryRecovery
+	recoverHistoryErr   error
+	epochVerification   *core.EpochVerification
+	verifyEpochErr      error
+	recoverableErr      error
+	refundCoin          string
+	refundErr           error
+	backup              []byte
+	backupErr           error
+	restoredBackup      []byte
+	seed                string
+	seedErr             error
+	restoredSeed        string
+	orders              []*core.Order
+	matches             []*core.OrderMatch
+	ordersErr           error
+	canceled            []string
+	cancelAllErr        error
+	noteFeed            chan core.Notification
 }
 
 func (c *TCore) Balance(uint32) (uint64, error) {
@@ -75,6 +108,24 @@ func (c *TCore) AssetBalance(uint32) (*db.Balance, error) {
 func (c *TCore) Cancel(pw []byte, sid string) error {
 	return c.cancelErr
 }
+func (c *TCore) CloneOrder(pw []byte, orderID string, rate uint64) (*core.Order, error) {
+	return c.order, c.tradeErr
+}
+func (c *TCore) CancelAll(pw []byte, filter *core.OrderFilter) ([]string, error) {
+	return c.canceled, c.cancelAllErr
+}
+func (c *TCore) Orders(filter *core.OrderFilter) ([]*core.Order, error) {
+	return c.orders, c.ordersErr
+}
+func (c *TCore) Order(orderID string) (*core.Order, error) {
+	return c.order, c.ordersErr
+}
+func (c *TCore) Matches(filter *core.OrderFilter) ([]*core.OrderMatch, error) {
+	return c.matches, c.ordersErr
+}
+func (c *TCore) NotificationFeed() <-chan core.Notification {
+	return c.noteFeed
+}
 func (c *TCore) CreateWallet(appPW, walletPW []byte, form *core.WalletForm) error {
 	return c.createWalletErr
 }
@@ -85,6 +136,9 @@ func (c *TCore) Exchanges() (exchanges map[string]*core.Exchange) { return c.exc
 func (c *TCore) InitializeClient(pw []byte) error {
 	return c.initializeClientErr
 }
+func (c *TCore) LoggedIn() bool {
+	return c.loggedIn
+}
 func (c *TCore) Login(appPass []byte) (*core.LoginResult, error) {
 	return c.loginResult, c.loginErr
 }
@@ -97,6 +151,12 @@ func (c *TCore) OpenWallet(assetID uint32, pw []byte) error {
 func (c *TCore) GetFee(url, cert string) (uint64, error) {
 	return c.regFee, c.getFeeErr
 }
+func (c *TCore) Portfolio() *core.Portfolio {
+	return c.portfolio
+}
+func (c *TCore) TradeHistory() (*core.TradeHistory, error) {
+	return c.tradeHistory, c.tradeHistoryErr
+}
 func (c *TCore) Register(*core.RegisterForm) (*core.RegisterResult, error) {
 	return c.registerResult, c.registerErr
 }
@@ -112,9 +172,72 @@ func (c *TCore) Wallets() []*core.WalletState {
 func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 	return c.walletState
 }
+func (c *TCore) DevicePrompts() []*core.DevicePrompt {
+	return c.prompts
+}
+func (c *TCore) RespondDevicePrompt(id string, response []byte) error {
+	return c.respondPromptErr
+}
+func (c *TCore) RescanWallet(assetID, height uint32) error {
+	return c.rescanErr
+}
+func (c *TCore) WalletCoins(assetID uint32, walletName string) ([]*core.WalletCoin, error) {
+	return c.walletCoins, c.walletCoinsErr
+}
+func (c *TCore) ExportBackup(appPass []byte) ([]byte, error) {
+	return c.backup, c.backupErr
+}
+func (c *TCore) RestoreBackup(appPass, archive []byte) error {
+	c.restoredBackup = archive
+	return c.backupErr
+}
+func (c *TCore) ExportSeed(appPass []byte) (string, error) {
+	return c.seed, c.seedErr
+}
+func (c *TCore) RestoreFromSeed(appPass []byte, seed string) error {
+	c.restoredSeed = seed
+	return c.seedErr
+}
+func (c *TCore) RecoverableSwaps() ([]*core.RecoverableSwap, error) {
+	return c.recoverableSwaps, c.recoverableErr
+}
+func (c *TCore) RecoverHistory(host string) (*core.HistoryRecovery, error) {
+	return c.historyRecovery, c.recoverHistoryErr
+}
+func (c *TCore) VerifyEpoch(orderID string) (*core.EpochVerification, error) {
+	return c.epochVerification, c.verifyEpochErr
+}
+func (c *TCore) RefundSwap(appPass []byte, matchID string) (string, error) {
+	return c.refundCoin, c.refundErr
+}
 func (c *TCore) Withdraw(pw []byte, assetID uint32, value uint64, addr string) (asset.Coin, error) {
 	return c.coin, c.withdrawErr
 }
+func (c *TCore) EstimateWithdrawal(assetID uint32, w *asset.Withdrawal) (*asset.WithdrawalEstimate, error) {
+	c.withdrawal = w
+	return c.withdrawEst, c.withdrawErr
+}
+func (c *TCore) SendWithdrawal(pw []byte, assetID uint32, w *asset.Withdrawal) ([]asset.Coin, error) {
+	c.withdrawal = w
+	return c.withdrawCoins, c.withdrawErr
+}
+
+type TMarketMaker struct {
+	startID  uint64
+	startErr error
+	stopErr  error
+	bots     []*mm.BotStatus
+}
+
+func (m *TMarketMaker) Start(pw []byte, cfg *mm.BotConfig) (uint64, error) {
+	return m.startID, m.startErr
+}
+func (m *TMarketMaker) Stop(id uint64) error {
+	return m.stopErr
+}
+func (m *TMarketMaker) Bots() []*mm.BotStatus {
+	return m.bots
+}
 
 type TWriter struct {
 	b []byte
@@ -248,7 +371,7 @@ func newTServer(t *testing.T, start bool, user, pass string) (*RPCServer,
 	defer os.Remove(cert)
 	defer os.Remove(key)
 	cfg := &Config{c, fmt.Sprintf("localhost:%d", tPort), user, pass, cert,
-		key}
+		key, nil, false}
 	s, err := New(cfg)
 	if err != nil {
 		t.Errorf("error creating server: %v", err)
@@ -324,7 +447,7 @@ func TestConnectBindError(t *testing.T) {
 	defer os.Remove(cert)
 	defer os.Remove(key)
 	cfg := &Config{c, fmt.Sprintf("localhost:%d", tPort), "", "", cert,
-		key}
+		key, nil, false}
 	s, err := New(cfg)
 	if err != nil {
 		t.Fatalf("error creating server: %v", err)
@@ -411,6 +534,92 @@ func TestLoadMarket(t *testing.T) {
 	ensureGood()
 }
 
+func TestNotificationSubscription(t *testing.T) {
+	link := newLink()
+	s, _, shutdown, _ := newTServer(t, false, "", "")
+	defer shutdown()
+	_, err := link.cl.Connect(tCtx)
+	if err != nil {
+		t.Fatalf("WSLink Start: %v", err)
+	}
+	defer link.cl.Disconnect()
+	s.mtx.Lock()
+	s.clients[link.cl.cid] = link.cl
+	s.mtx.Unlock()
+
+	extractMessage := func() *msgjson.Message {
+		select {
+		case msgB := <-link.conn.respReady:
+			msg := new(msgjson.Message)
+			json.Unmarshal(msgB, &msg)
+			return msg
+		case <-time.NewTimer(time.Millisecond * 100).C:
+		}
+		return nil
+	}
+
+	subscribe := func(route string, sub *noteSubscription) {
+		t.Helper()
+		req, _ := msgjson.NewRequest(1, route, sub)
+		if msgErr := s.handleMessage(link.cl, req); msgErr != nil {
+			t.Fatalf("%q error: %d: %s", route, msgErr.Code, msgErr.Message)
+		}
+		if msg := extractMessage(); msg == nil || msg.Type != msgjson.Response {
+			t.Fatalf("no response to %q", route)
+		}
+	}
+
+	orderNote := db.NewNotification("order", "order placed", "", db.Success)
+	balanceNote := db.NewNotification("balance", "balance updated", "", db.Data)
+	ensureNote := func(n core.Notification, wantNote bool) {
+		t.Helper()
+		s.notifySubscribers(n)
+		msg := extractMessage()
+		if !wantNote {
+			if msg != nil {
+				t.Fatalf("unexpected message for %s notification", n.Type())
+			}
+			return
+		}
+		if msg == nil {
+			t.Fatalf("no message for %s notification", n.Type())
+		}
+		if msg.Route != notifyRoute {
+			t.Fatalf("wrong route. expected %s, got %s", notifyRoute, msg.Route)
+		}
+		note := new(db.Notification)
+		if err := msg.Unmarshal(note); err != nil {
+			t.Fatalf("error unmarshaling notification: %v", err)
+		}
+		if note.Type() != n.Type() {
+			t.Fatalf("wrong notification type. expected %s, got %s", n.Type(), note.Type())
+		}
+	}
+
+	// No notifications before subscribing.
+	ensureNote(&orderNote, false)
+
+	// Subscribe to order notifications only.
+	subscribe("subscribe", &noteSubscription{Types: []string{"order"}})
+	ensureNote(&orderNote, true)
+	ensureNote(&balanceNote, false)
+
+	// Subscribe to everything.
+	subscribe("subscribe", nil)
+	ensureNote(&orderNote, true)
+	ensureNote(&balanceNote, true)
+
+	// Unsubscribe.
+	subscribe("unsubscribe", nil)
+	ensureNote(&orderNote, false)
+
+	// Bad payload.
+	req, _ := msgjson.NewRequest(1, "subscribe", []string{"order"})
+	if msgErr := s.handleMessage(link.cl, req); msgErr == nil {
+		t.Fatalf("no error for bad subscribe payload")
+	}
+}
+
 func TestHandleMessage(t *testing.T) {
 	link := newLink()
 	s, _, shutdown, _ := newTServer(t, false, "", "")
@@ -548,6 +757,41 @@ func TestParseHTTPRequest(t *testing.T) {
 	ensureMsgErr("bad params", msgjson.RPCParseError)
 }
 
+func TestReadOnly(t *testing.T) {
+	s, tc, shutdown, _ := newTServer(t, false, "", "")
+	defer shutdown()
+	s.readOnly = true
+
+	request := func(route string) *msgjson.Error {
+		t.Helper()
+		msg, _ := msgjson.NewRequest(1, route, nil)
+		return s.handleRequest(msg).Error
+	}
+
+	// Read-only routes are available before login.
+	if resErr := request(versionRoute); resErr != nil {
+		t.Fatalf("error for read-only route before login: %v", resErr)
+	}
+	if resErr := request(exchangesRoute); resErr != nil {
+		t.Fatalf("error for read-only route before login: %v", resErr)
+	}
+	// Other routes are not.
+	if resErr := request(logoutRoute); resErr == nil || resErr.Code != msgjson.RPCReadOnlyError {
+		t.Fatalf("expected read-only error before login, got %v", resErr)
+	}
+	// All routes are available after login.
+	tc.loggedIn = true
+	if resErr := request(logoutRoute); resErr != nil {
+		t.Fatalf("error after login: %v", resErr)
+	}
+	// And without read-only mode.
+	tc.loggedIn = false
+	s.readOnly = false
+	if resErr := request(logoutRoute); resErr != nil {
+		t.Fatalf("error without read-only mode: %v", resErr)
+	}
+}
+
 type authMiddlewareTest struct {
 	name, user, pass, header string
 	hasAuth, wantErr         bool
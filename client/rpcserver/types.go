@@ -5,12 +5,17 @@ package rpcserver
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
+	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
 )
 
@@ -79,18 +84,121 @@ type tradeForm struct {
 	SrvForm *core.TradeForm
 }
 
+// startBotForm combines the application password and the bot configuration.
+type startBotForm struct {
+	AppPass encode.PassBytes
+	Config  *mm.BotConfig
+}
+
 // cancelForm is information necessary to cancel a trade.
 type cancelForm struct {
 	AppPass encode.PassBytes `json:"appPass"`
 	OrderID string           `json:"orderID"`
 }
 
+// rescanWalletForm is information necessary to rescan a wallet.
+type walletCoinsForm struct {
+	AssetID    uint32 `json:"assetID"`
+	WalletName string `json:"walletName"`
+}
+
+type rescanWalletForm struct {
+	AssetID uint32 `json:"assetID"`
+	Height  uint32 `json:"height"`
+}
+
+// refundSwapForm is information necessary to refund a swap.
+type refundSwapForm struct {
+	AppPass encode.PassBytes `json:"appPass"`
+	MatchID string           `json:"matchID"`
+}
+
+// backupForm is information necessary to export or restore a backup.
+type backupForm struct {
+	AppPass encode.PassBytes `json:"appPass"`
+	Path    string           `json:"path"`
+}
+
+// restoreSeedForm is information necessary to restore the client from the
+// application seed.
+type restoreSeedForm struct {
+	AppPass encode.PassBytes `json:"appPass"`
+	Seed    encode.PassBytes `json:"seed"`
+}
+
+// respondPromptForm is the response to a hardware wallet prompt.
+type respondPromptForm struct {
+	Response encode.PassBytes `json:"response"`
+	ID       string           `json:"id"`
+}
+
 // withdrawForm is information necessary to withdraw funds.
 type withdrawForm struct {
 	AppPass encode.PassBytes `json:"appPass"`
 	AssetID uint32           `json:"assetID"`
 	Value   uint64           `json:"value"`
 	Address string           `json:"address"`
+	// FeeRate and TargetConfs are optional. If either is set, the fee is
+	// subtracted from value.
+	FeeRate     uint64 `json:"feeRate"`
+	TargetConfs uint32 `json:"targetConfs"`
+}
+
+// withdrawalForm is information necessary to estimate or send a withdrawal
+// to one or more addresses.
+type withdrawalForm struct {
+	AppPass    encode.PassBytes
+	AssetID    uint32
+	Withdrawal *asset.Withdrawal
+}
+
+// cancelAllForm is information necessary to cancel all booked orders.
+type cancelAllForm struct {
+	AppPass encode.PassBytes
+	Filter  *core.OrderFilter
+}
+
+// cloneOrderForm is information necessary to re-place a completed or canceled
+// order. A non-zero Rate replaces the rate of a limit order.
+type cloneOrderForm struct {
+	AppPass encode.PassBytes
+	OrderID string
+	Rate    uint64
+}
+
+// cancelAllResponse is used when responding to the cancelall route.
+type cancelAllResponse struct {
+	Canceled []string `json:"canceled"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// maxBatchCmds is the most commands allowed in a batch request.
+const maxBatchCmds = 50
+
+// batchCmd is a command in a batch request. The app password is supplied by
+// the batch.
+type batchCmd struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+}
+
+// batchForm is information necessary to execute a batch of commands.
+type batchForm struct {
+	AppPass encode.PassBytes
+	Cmds    []*batchCmd
+}
+
+// batchResult is the result of one command in a batch.
+type batchResult struct {
+	Cmd     string          `json:"cmd"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *msgjson.Error  `json:"error,omitempty"`
+	Skipped bool            `json:"skipped,omitempty"`
+}
+
+// batchResponse is used when responding to the batch route.
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
 }
 
 // checkNArgs checks that args and pwArgs are the correct length.
@@ -125,6 +233,14 @@ func checkUIntArg(arg, name string, bitSize int) (uint64, error) {
 	return i, nil
 }
 
+func checkFloatArg(arg, name string) (float64, error) {
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return f, fmt.Errorf("%w: cannot parse %s: %v", errArgs, name, err)
+	}
+	return f, nil
+}
+
 func checkBoolArg(arg, name string) (bool, error) {
 	b, err := strconv.ParseBool(arg)
 	if err != nil {
@@ -222,29 +338,47 @@ func parseGetFeeArgs(params *RawParams) (host, cert string, err error) {
 	return params.Args[0], params.Args[1], nil
 }
 
+func parseExportTradesArgs(params *RawParams) (string, error) {
+	if err := checkNArgs(params, []int{0}, []int{0, 1}); err != nil {
+		return "", err
+	}
+	if len(params.Args) == 0 {
+		return core.ExportJSON, nil
+	}
+	format := params.Args[0]
+	if format != core.ExportJSON && format != core.ExportCSV {
+		return "", fmt.Errorf("%w: unknown format %q", errArgs, format)
+	}
+	return format, nil
+}
+
 func parseRegisterArgs(params *RawParams) (*core.RegisterForm, error) {
-	if err := checkNArgs(params, []int{1}, []int{2, 3}); err != nil {
+	if err := checkNArgs(params, []int{1}, []int{2, 4}); err != nil {
 		return nil, err
 	}
 	fee, err := checkUIntArg(params.Args[1], "fee", 64)
 	if err != nil {
 		return nil, err
 	}
-	cert := ""
+	var cert, acct string
 	if len(params.Args) > 2 {
 		cert = params.Args[2]
 	}
+	if len(params.Args) > 3 {
+		acct = params.Args[3]
+	}
 	req := &core.RegisterForm{
 		AppPass: params.PWArgs[0],
 		Addr:    params.Args[0],
 		Fee:     fee,
 		Cert:    cert,
+		Account: acct,
 	}
 	return req, nil
 }
 
 func parseTradeArgs(params *RawParams) (*tradeForm, error) {
-	if err := checkNArgs(params, []int{1}, []int{8}); err != nil {
+	if err := checkNArgs(params, []int{1}, []int{8, 13}); err != nil {
 		return nil, err
 	}
 	isLimit, err := checkBoolArg(params.Args[1], "isLimit")
@@ -288,9 +422,102 @@ func parseTradeArgs(params *RawParams) (*tradeForm, error) {
 			TifNow:  tifnow,
 		},
 	}
+	if len(params.Args) > 8 {
+		req.SrvForm.Account = params.Args[8]
+	}
+	if len(params.Args) > 9 {
+		req.SrvForm.Funding = asset.FundingStrategy(params.Args[9])
+	}
+	if len(params.Args) > 10 && params.Args[10] != "" {
+		for _, s := range strings.Split(params.Args[10], ",") {
+			coinID, err := hex.DecodeString(strings.TrimSpace(s))
+			if err != nil || len(coinID) == 0 {
+				return nil, fmt.Errorf("%w: invalid coin ID %q", errArgs, s)
+			}
+			req.SrvForm.Coins = append(req.SrvForm.Coins, coinID)
+		}
+	}
+	if len(params.Args) > 11 {
+		req.SrvForm.Confirmed, err = checkBoolArg(params.Args[11], "confirmed")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(params.Args) > 12 {
+		req.SrvForm.Visible, err = checkUIntArg(params.Args[12], "visible", 64)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return req, nil
 }
 
+func parseStartBotArgs(params *RawParams) (*startBotForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{8, 10}); err != nil {
+		return nil, err
+	}
+	base, err := checkUIntArg(params.Args[1], "base", 32)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := checkUIntArg(params.Args[2], "quote", 32)
+	if err != nil {
+		return nil, err
+	}
+	spread, err := checkFloatArg(params.Args[4], "spread")
+	if err != nil {
+		return nil, err
+	}
+	lots, err := checkUIntArg(params.Args[5], "lots", 64)
+	if err != nil {
+		return nil, err
+	}
+	baseBudget, err := checkUIntArg(params.Args[6], "baseBudget", 64)
+	if err != nil {
+		return nil, err
+	}
+	quoteBudget, err := checkUIntArg(params.Args[7], "quoteBudget", 64)
+	if err != nil {
+		return nil, err
+	}
+	var maxExposure uint64
+	if len(params.Args) > 8 {
+		maxExposure, err = checkUIntArg(params.Args[8], "maxExposure", 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var drift float64
+	if len(params.Args) > 9 {
+		drift, err = checkFloatArg(params.Args[9], "driftTolerance")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &startBotForm{
+		AppPass: params.PWArgs[0],
+		Config: &mm.BotConfig{
+			Host:           params.Args[0],
+			Base:           uint32(base),
+			Quote:          uint32(quote),
+			Strategy:       params.Args[3],
+			Spread:         spread,
+			Lots:           lots,
+			BaseBudget:     baseBudget,
+			QuoteBudget:    quoteBudget,
+			MaxExposure:    maxExposure,
+			DriftTolerance: drift,
+		},
+	}, nil
+}
+
+func parseStopBotArgs(params *RawParams) (uint64, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	return checkUIntArg(params.Args[0], "id", 64)
+}
+
 func parseCancelArgs(params *RawParams) (*cancelForm, error) {
 	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
 		return nil, err
@@ -305,8 +532,90 @@ func parseCancelArgs(params *RawParams) (*cancelForm, error) {
 	return &cancelForm{AppPass: params.PWArgs[0], OrderID: id}, nil
 }
 
+func parseBackupArgs(params *RawParams) (*backupForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
+		return nil, err
+	}
+	if params.Args[0] == "" {
+		return nil, fmt.Errorf("%w: empty path", errArgs)
+	}
+	return &backupForm{AppPass: params.PWArgs[0], Path: params.Args[0]}, nil
+}
+
+func parseAppSeedArgs(params *RawParams) (encode.PassBytes, error) {
+	if err := checkNArgs(params, []int{1}, []int{0}); err != nil {
+		return nil, err
+	}
+	return params.PWArgs[0], nil
+}
+
+func parseRestoreSeedArgs(params *RawParams) (*restoreSeedForm, error) {
+	if err := checkNArgs(params, []int{2}, []int{0}); err != nil {
+		return nil, err
+	}
+	return &restoreSeedForm{AppPass: params.PWArgs[0], Seed: params.PWArgs[1]}, nil
+}
+
+func parseRescanWalletArgs(params *RawParams) (*rescanWalletForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{2}); err != nil {
+		return nil, err
+	}
+	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
+	if err != nil {
+		return nil, err
+	}
+	height, err := checkUIntArg(params.Args[1], "height", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &rescanWalletForm{AssetID: uint32(assetID), Height: uint32(height)}, nil
+}
+
+func parseWalletCoinsArgs(params *RawParams) (*walletCoinsForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{1, 2}); err != nil {
+		return nil, err
+	}
+	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
+	if err != nil {
+		return nil, err
+	}
+	form := &walletCoinsForm{AssetID: uint32(assetID)}
+	if len(params.Args) > 1 {
+		form.WalletName = params.Args[1]
+	}
+	return form, nil
+}
+
+func parseRecoverHistoryArgs(params *RawParams) (string, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return "", err
+	}
+	return params.Args[0], nil
+}
+
+func parseRefundSwapArgs(params *RawParams) (*refundSwapForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
+		return nil, err
+	}
+	id := params.Args[0]
+	if len(id) != orderIdLen {
+		return nil, fmt.Errorf("%w: matchID has incorrect length", errArgs)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return nil, fmt.Errorf("%w: invalid match id hex", errArgs)
+	}
+	return &refundSwapForm{AppPass: params.PWArgs[0], MatchID: id}, nil
+}
+
+func parseRespondPromptArgs(params *RawParams) (*respondPromptForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
+		return nil, err
+	}
+	return &respondPromptForm{Response: params.PWArgs[0], ID: params.Args[0]}, nil
+}
+
 func parseWithdrawArgs(params *RawParams) (*withdrawForm, error) {
-	if err := checkNArgs(params, []int{1}, []int{3}); err != nil {
+	if err := checkNArgs(params, []int{1}, []int{3, 5}); err != nil {
 		return nil, err
 	}
 	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
@@ -323,5 +632,255 @@ func parseWithdrawArgs(params *RawParams) (*withdrawForm, error) {
 		Value:   value,
 		Address: params.Args[2],
 	}
+	w := new(asset.Withdrawal)
+	if err := parseWithdrawalOptions(params.Args[3:], w, false); err != nil {
+		return nil, err
+	}
+	req.FeeRate, req.TargetConfs = w.FeeRate, w.TargetConfs
 	return req, nil
 }
+
+func parseSendManyArgs(params *RawParams) (*withdrawalForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{2, 5}); err != nil {
+		return nil, err
+	}
+	form, err := parseWithdrawalArgs(params.Args)
+	if err != nil {
+		return nil, err
+	}
+	form.AppPass = params.PWArgs[0]
+	return form, nil
+}
+
+func parseEstimateWithdrawArgs(params *RawParams) (*withdrawalForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{2, 5}); err != nil {
+		return nil, err
+	}
+	return parseWithdrawalArgs(params.Args)
+}
+
+// parseWithdrawalArgs parses the asset ID, the outputs, and the options of a
+// withdrawal to one or more addresses.
+func parseWithdrawalArgs(args []string) (*withdrawalForm, error) {
+	assetID, err := checkUIntArg(args[0], "assetID", 32)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := parseWithdrawalOutputs(args[1])
+	if err != nil {
+		return nil, err
+	}
+	w := &asset.Withdrawal{Outputs: outputs}
+	if err := parseWithdrawalOptions(args[2:], w, true); err != nil {
+		return nil, err
+	}
+	return &withdrawalForm{AssetID: uint32(assetID), Withdrawal: w}, nil
+}
+
+// parseWithdrawalOutputs parses a comma-separated list of address:value pairs.
+func parseWithdrawalOutputs(arg string) ([]*asset.WithdrawalOutput, error) {
+	var outputs []*asset.WithdrawalOutput
+	for _, pair := range strings.Split(arg, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("%w: output %q is not of the form address:value", errArgs, pair)
+		}
+		value, err := checkUIntArg(pair[i+1:], "value", 64)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &asset.WithdrawalOutput{
+			Address: pair[:i],
+			Value:   value,
+		})
+	}
+	return outputs, nil
+}
+
+// parseWithdrawalOptions parses the key=value withdrawal options feerate,
+// confs, and, if allowSubtract, subtract into the withdrawal.
+func parseWithdrawalOptions(args []string, w *asset.Withdrawal, allowSubtract bool) error {
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return fmt.Errorf("%w: option %q is not of the form key=value", errArgs, arg)
+		}
+		key, val := kv[0], kv[1]
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate option %q", errArgs, key)
+		}
+		seen[key] = true
+		switch {
+		case key == "feerate":
+			feeRate, err := checkUIntArg(val, key, 64)
+			if err != nil {
+				return err
+			}
+			w.FeeRate = feeRate
+		case key == "confs":
+			confs, err := checkUIntArg(val, key, 32)
+			if err != nil {
+				return err
+			}
+			w.TargetConfs = uint32(confs)
+		case key == "subtract" && allowSubtract:
+			subtract, err := checkBoolArg(val, key)
+			if err != nil {
+				return err
+			}
+			w.Subtract = subtract
+		default:
+			return fmt.Errorf("%w: unknown option %q", errArgs, key)
+		}
+	}
+	if w.FeeRate > 0 && w.TargetConfs > 0 {
+		return fmt.Errorf("%w: feerate and confs cannot both be set", errArgs)
+	}
+	return nil
+}
+
+func parseOrdersArgs(params *RawParams) (*core.OrderFilter, error) {
+	if err := checkNArgs(params, []int{0}, []int{0, 6}); err != nil {
+		return nil, err
+	}
+	return parseOrderFilter(params.Args)
+}
+
+func parseOrderArgs(params *RawParams) (string, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return "", err
+	}
+	id := params.Args[0]
+	if len(id) != orderIdLen {
+		return "", fmt.Errorf("%w: orderID has incorrect length", errArgs)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", fmt.Errorf("%w: invalid order id hex", errArgs)
+	}
+	return id, nil
+}
+
+func parseCancelAllArgs(params *RawParams) (*cancelAllForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{0, 2}); err != nil {
+		return nil, err
+	}
+	filter, err := parseOrderFilter(params.Args)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter.Statuses) > 0 || filter.Since > 0 || filter.Until > 0 || filter.N > 0 {
+		return nil, fmt.Errorf("%w: only host and market filters are allowed", errArgs)
+	}
+	return &cancelAllForm{AppPass: params.PWArgs[0], Filter: filter}, nil
+}
+
+func parseBatchArgs(params *RawParams) (*batchForm, error) {
+	if err := checkNArgs(params, []int{0, 1}, []int{1}); err != nil {
+		return nil, err
+	}
+	var cmds []*batchCmd
+	if err := json.Unmarshal([]byte(params.Args[0]), &cmds); err != nil {
+		return nil, fmt.Errorf("%w: cannot parse commands: %v", errArgs, err)
+	}
+	if len(cmds) == 0 || len(cmds) > maxBatchCmds {
+		return nil, fmt.Errorf("%w: wanted between 1 and %d commands but got %d", errArgs, maxBatchCmds, len(cmds))
+	}
+	for i, cmd := range cmds {
+		if cmd == nil || cmd.Cmd == "" {
+			return nil, fmt.Errorf("%w: command %d has no cmd", errArgs, i)
+		}
+	}
+	form := &batchForm{Cmds: cmds}
+	if len(params.PWArgs) > 0 {
+		form.AppPass = params.PWArgs[0]
+	}
+	return form, nil
+}
+
+func parseCloneOrderArgs(params *RawParams) (*cloneOrderForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1, 2}); err != nil {
+		return nil, err
+	}
+	id := params.Args[0]
+	if len(id) != orderIdLen {
+		return nil, fmt.Errorf("%w: orderID has incorrect length", errArgs)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return nil, fmt.Errorf("%w: invalid order id hex", errArgs)
+	}
+	form := &cloneOrderForm{AppPass: params.PWArgs[0], OrderID: id}
+	if len(params.Args) > 1 {
+		rate, err := checkUIntArg(params.Args[1], "rate", 64)
+		if err != nil {
+			return nil, err
+		}
+		form.Rate = rate
+	}
+	return form, nil
+}
+
+// parseOrderFilter parses filter arguments of the form key=value. The keys are
+// host, market, status, since, until, and n. status is a comma-separated list
+// of order statuses.
+func parseOrderFilter(args []string) (*core.OrderFilter, error) {
+	filter := new(core.OrderFilter)
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("%w: filter %q is not of the form key=value", errArgs, arg)
+		}
+		key, val := kv[0], kv[1]
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate filter %q", errArgs, key)
+		}
+		seen[key] = true
+		switch key {
+		case "host":
+			filter.Host = val
+		case "market":
+			filter.Market = val
+		case "status":
+			for _, name := range strings.Split(val, ",") {
+				status, err := parseOrderStatus(name)
+				if err != nil {
+					return nil, err
+				}
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		case "since", "until":
+			stamp, err := checkUIntArg(val, key, 64)
+			if err != nil {
+				return nil, err
+			}
+			if key == "since" {
+				filter.Since = stamp
+			} else {
+				filter.Until = stamp
+			}
+		case "n":
+			n, err := checkUIntArg(val, key, 31)
+			if err != nil {
+				return nil, err
+			}
+			filter.N = int(n)
+		default:
+			return nil, fmt.Errorf("%w: unknown filter %q", errArgs, key)
+		}
+	}
+	if filter.Until > 0 && filter.Until < filter.Since {
+		return nil, fmt.Errorf("%w: until is before since", errArgs)
+	}
+	return filter, nil
+}
+
+// parseOrderStatus parses the order status name, e.g. booked.
+func parseOrderStatus(name string) (order.OrderStatus, error) {
+	for status := order.OrderStatusEpoch; status <= order.OrderStatusRevoked; status++ {
+		if status.String() == name {
+			return status, nil
+		}
+	}
+	return order.OrderStatusUnknown, fmt.Errorf("%w: unknown order status %q", errArgs, name)
+}
@@ -7,9 +7,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
+	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
 )
 
 func TestCheckNArgs(t *testing.T) {
@@ -248,7 +253,7 @@ func TestParseRegisterArgs(t *testing.T) {
 	paramsWithFee := func(fee string) *RawParams {
 		pw := encode.PassBytes("password123")
 		pwArgs := []encode.PassBytes{pw}
-		args := []string{"dex", fee, "cert"}
+		args := []string{"dex", fee, "cert", "bot"}
 		return &RawParams{PWArgs: pwArgs, Args: args}
 	}
 	tests := []struct {
@@ -284,6 +289,9 @@ func TestParseRegisterArgs(t *testing.T) {
 		if fmt.Sprint(reg.Cert) != test.params.Args[2] {
 			t.Fatalf("cert doesn't match")
 		}
+		if reg.Account != test.params.Args[3] {
+			t.Fatalf("account doesn't match")
+		}
 	}
 }
 
@@ -327,6 +335,45 @@ func TestParseHelpArgs(t *testing.T) {
 	}
 }
 
+func TestParseExportTradesArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr error
+	}{{
+		name: "ok default",
+		want: core.ExportJSON,
+	}, {
+		name: "ok csv",
+		args: []string{"csv"},
+		want: core.ExportCSV,
+	}, {
+		name:    "unknown format",
+		args:    []string{"xml"},
+		wantErr: errArgs,
+	}, {
+		name:    "too many args",
+		args:    []string{"csv", "json"},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		format, err := parseExportTradesArgs(&RawParams{Args: test.args})
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %s", err, test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error %v for test %s", err, test.name)
+		}
+		if format != test.want {
+			t.Fatalf("wrong format %s for test %s", format, test.name)
+		}
+	}
+}
+
 func TestTradeArgs(t *testing.T) {
 	pw := encode.PassBytes("password123")
 	goodParams := &RawParams{
@@ -386,6 +433,57 @@ func TestTradeArgs(t *testing.T) {
 		name:    "tifnow not bool",
 		params:  paramsWith(7, "blue"),
 		wantErr: errArgs,
+	}, {
+		name: "ok with account",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "bot"),
+		},
+	}, {
+		name: "ok with funding strategy",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "minchange"),
+		},
+	}, {
+		name: "ok with coins",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "0a0b, 0c"),
+		},
+	}, {
+		name: "bad coin ID",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "0a0b,zz"),
+		},
+		wantErr: errArgs,
+	}, {
+		name: "ok confirmed",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "", "true"),
+		},
+	}, {
+		name: "confirmed not bool",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "", "yes"),
+		},
+		wantErr: errArgs,
+	}, {
+		name: "ok iceberg",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "", "false", "100000000"),
+		},
+	}, {
+		name: "visible not uint64",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   append(append([]string(nil), goodParams.Args...), "", "", "", "false", "-1"),
+		},
+		wantErr: errArgs,
 	}}
 	for _, test := range tests {
 		reg, err := parseTradeArgs(test.params)
@@ -423,6 +521,129 @@ func TestTradeArgs(t *testing.T) {
 		if fmt.Sprint(reg.SrvForm.TifNow) != test.params.Args[7] {
 			t.Fatalf("TifNow doesn't match")
 		}
+		if len(test.params.Args) > 8 && reg.SrvForm.Account != test.params.Args[8] {
+			t.Fatalf("Account doesn't match")
+		}
+		if len(test.params.Args) > 9 && string(reg.SrvForm.Funding) != test.params.Args[9] {
+			t.Fatalf("Funding doesn't match")
+		}
+		if len(test.params.Args) > 10 && test.params.Args[10] != "" && len(reg.SrvForm.Coins) != 2 {
+			t.Fatalf("Coins don't match")
+		}
+		if len(test.params.Args) > 11 && fmt.Sprint(reg.SrvForm.Confirmed) != test.params.Args[11] {
+			t.Fatalf("Confirmed doesn't match")
+		}
+		if len(test.params.Args) > 12 && fmt.Sprint(reg.SrvForm.Visible) != test.params.Args[12] {
+			t.Fatalf("Visible doesn't match")
+		}
+	}
+}
+
+func TestParseStartBotArgs(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	goodParams := &RawParams{
+		PWArgs: []encode.PassBytes{pw}, // 0. AppPass
+		Args: []string{
+			"1.2.3.4:3000", // 0. Host
+			"42",           // 1. Base
+			"0",            // 2. Quote
+			"oracle",       // 3. Strategy
+			"0.02",         // 4. Spread
+			"2",            // 5. Lots
+			"100000000",    // 6. BaseBudget
+			"1000000",      // 7. QuoteBudget
+			"300000000",    // 8. MaxExposure
+			"0.001",        // 9. DriftTolerance
+		}}
+	paramsWith := func(idx int, thing string) *RawParams {
+		newParams := &RawParams{
+			PWArgs: make([]encode.PassBytes, 1),
+			Args:   make([]string, 10),
+		}
+		copy(newParams.PWArgs, goodParams.PWArgs)
+		copy(newParams.Args, goodParams.Args)
+		newParams.Args[idx] = thing
+		return newParams
+	}
+	tests := []struct {
+		name    string
+		params  *RawParams
+		wantErr error
+	}{{
+		name:   "ok",
+		params: goodParams,
+	}, {
+		name: "ok without optional args",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   goodParams.Args[:8],
+		},
+	}, {
+		name:    "base not uint32",
+		params:  paramsWith(1, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "quote not uint32",
+		params:  paramsWith(2, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "spread not float",
+		params:  paramsWith(4, "blue"),
+		wantErr: errArgs,
+	}, {
+		name:    "lots not uint64",
+		params:  paramsWith(5, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "baseBudget not uint64",
+		params:  paramsWith(6, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "quoteBudget not uint64",
+		params:  paramsWith(7, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "maxExposure not uint64",
+		params:  paramsWith(8, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "driftTolerance not float",
+		params:  paramsWith(9, "blue"),
+		wantErr: errArgs,
+	}, {
+		name: "too few args",
+		params: &RawParams{
+			PWArgs: goodParams.PWArgs,
+			Args:   goodParams.Args[:7],
+		},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseStartBotArgs(test.params)
+		if err != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %s",
+					err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("no error for test %s", test.name)
+		}
+		if !bytes.Equal(form.AppPass, test.params.PWArgs[0]) {
+			t.Fatalf("AppPass doesn't match")
+		}
+		cfg := form.Config
+		if cfg.Host != test.params.Args[0] || fmt.Sprint(cfg.Base) != test.params.Args[1] ||
+			fmt.Sprint(cfg.Quote) != test.params.Args[2] || cfg.Strategy != test.params.Args[3] {
+			t.Fatalf("market doesn't match for test %s", test.name)
+		}
+		if cfg.Spread != 0.02 || cfg.Lots != 2 || cfg.BaseBudget != 1e8 || cfg.QuoteBudget != 1e6 {
+			t.Fatalf("wrong config for test %s: %+v", test.name, cfg)
+		}
+		if len(test.params.Args) == 10 && (cfg.MaxExposure != 3e8 || cfg.DriftTolerance != 0.001) {
+			t.Fatalf("wrong limits for test %s: %+v", test.name, cfg)
+		}
 	}
 }
 
@@ -466,6 +687,222 @@ func TestParseCancelArgs(t *testing.T) {
 	}
 }
 
+func TestParseCloneOrderArgs(t *testing.T) {
+	oid := "fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"
+	paramsWithArgs := func(args ...string) *RawParams {
+		pw := encode.PassBytes("password123")
+		pwArgs := []encode.PassBytes{pw}
+		return &RawParams{PWArgs: pwArgs, Args: args}
+	}
+	tests := []struct {
+		name     string
+		params   *RawParams
+		wantRate uint64
+		wantErr  error
+	}{{
+		name:   "ok",
+		params: paramsWithArgs(oid),
+	}, {
+		name:     "ok with rate",
+		params:   paramsWithArgs(oid, "1000"),
+		wantRate: 1000,
+	}, {
+		name:    "order ID incorrect length",
+		params:  paramsWithArgs(oid[2:]),
+		wantErr: errArgs,
+	}, {
+		name:    "order ID not hex",
+		params:  paramsWithArgs("z" + oid[1:]),
+		wantErr: errArgs,
+	}, {
+		name:    "bad rate",
+		params:  paramsWithArgs(oid, "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "too many args",
+		params:  paramsWithArgs(oid, "1000", "1"),
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseCloneOrderArgs(test.params)
+		if err != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q",
+					err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if !bytes.Equal(form.AppPass, test.params.PWArgs[0]) {
+			t.Fatalf("appPass doesn't match")
+		}
+		if form.OrderID != oid || form.Rate != test.wantRate {
+			t.Fatalf("wrong form for test %q", test.name)
+		}
+	}
+}
+
+func TestParseOrderFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantFilter *core.OrderFilter
+		wantErr    error
+	}{{
+		name:       "empty",
+		wantFilter: &core.OrderFilter{},
+	}, {
+		name: "all filters",
+		args: []string{"host=dex.example.com", "market=dcr_btc", "status=booked,executed",
+			"since=1585000000000", "until=1586000000000", "n=5"},
+		wantFilter: &core.OrderFilter{
+			Host:     "dex.example.com",
+			Market:   "dcr_btc",
+			Statuses: []order.OrderStatus{order.OrderStatusBooked, order.OrderStatusExecuted},
+			Since:    1585000000000,
+			Until:    1586000000000,
+			N:        5,
+		},
+	}, {
+		name:    "not key=value",
+		args:    []string{"booked"},
+		wantErr: errArgs,
+	}, {
+		name:    "empty value",
+		args:    []string{"market="},
+		wantErr: errArgs,
+	}, {
+		name:    "unknown key",
+		args:    []string{"side=buy"},
+		wantErr: errArgs,
+	}, {
+		name:    "duplicate key",
+		args:    []string{"n=1", "n=2"},
+		wantErr: errArgs,
+	}, {
+		name:    "unknown status",
+		args:    []string{"status=booked,filled"},
+		wantErr: errArgs,
+	}, {
+		name:    "bad time",
+		args:    []string{"since=yesterday"},
+		wantErr: errArgs,
+	}, {
+		name:    "until before since",
+		args:    []string{"since=2", "until=1"},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		filter, err := parseOrderFilter(test.args)
+		if err != nil {
+			if test.wantErr == nil || !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q", err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if !reflect.DeepEqual(filter, test.wantFilter) {
+			t.Fatalf("%s: wanted filter %+v, got %+v", test.name, test.wantFilter, filter)
+		}
+	}
+}
+
+func TestParseCancelAllArgs(t *testing.T) {
+	pwArgs := []encode.PassBytes{encode.PassBytes("password123")}
+	tests := []struct {
+		name    string
+		params  *RawParams
+		wantErr error
+	}{{
+		name:   "ok",
+		params: &RawParams{PWArgs: pwArgs},
+	}, {
+		name:   "ok with filters",
+		params: &RawParams{PWArgs: pwArgs, Args: []string{"host=dex.example.com", "market=dcr_btc"}},
+	}, {
+		name:    "status filter",
+		params:  &RawParams{PWArgs: pwArgs, Args: []string{"status=booked"}},
+		wantErr: errArgs,
+	}, {
+		name:    "no password",
+		params:  &RawParams{},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseCancelAllArgs(test.params)
+		if err != nil {
+			if test.wantErr == nil || !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q", err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if !bytes.Equal(form.AppPass, test.params.PWArgs[0]) {
+			t.Fatalf("appPass doesn't match")
+		}
+	}
+}
+
+func TestParseBatchArgs(t *testing.T) {
+	pwArgs := []encode.PassBytes{encode.PassBytes("password123")}
+	cmds := `[{"cmd":"cancel","args":["abc"]},{"cmd":"orders"}]`
+	tests := []struct {
+		name    string
+		params  *RawParams
+		wantErr error
+	}{{
+		name:   "ok",
+		params: &RawParams{PWArgs: pwArgs, Args: []string{cmds}},
+	}, {
+		name:   "ok without password",
+		params: &RawParams{Args: []string{cmds}},
+	}, {
+		name:    "not json",
+		params:  &RawParams{PWArgs: pwArgs, Args: []string{"cancel"}},
+		wantErr: errArgs,
+	}, {
+		name:    "no commands",
+		params:  &RawParams{PWArgs: pwArgs, Args: []string{"[]"}},
+		wantErr: errArgs,
+	}, {
+		name:    "too many commands",
+		params:  &RawParams{PWArgs: pwArgs, Args: []string{"[" + strings.Repeat(`{"cmd":"orders"},`, maxBatchCmds) + `{"cmd":"orders"}]`}},
+		wantErr: errArgs,
+	}, {
+		name:    "no cmd",
+		params:  &RawParams{PWArgs: pwArgs, Args: []string{`[{"args":["abc"]}]`}},
+		wantErr: errArgs,
+	}, {
+		name:    "no args",
+		params:  &RawParams{PWArgs: pwArgs},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseBatchArgs(test.params)
+		if err != nil {
+			if test.wantErr == nil || !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q", err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if len(form.Cmds) != 2 || form.Cmds[0].Cmd != "cancel" || len(form.Cmds[0].Args) != 1 {
+			t.Fatalf("wrong commands for test %q", test.name)
+		}
+		if len(test.params.PWArgs) > 0 && !bytes.Equal(form.AppPass, test.params.PWArgs[0]) {
+			t.Fatalf("appPass doesn't match")
+		}
+	}
+}
+
 func TestParseWithdrawArgs(t *testing.T) {
 	paramsWithArgs := func(id, value string) *RawParams {
 		pw := encode.PassBytes("password123")
@@ -512,3 +949,74 @@ func TestParseWithdrawArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestParseWithdrawalArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *asset.Withdrawal
+		wantErr error
+	}{{
+		name: "ok",
+		args: []string{"42", "abc:1000,def:2000"},
+		want: &asset.Withdrawal{Outputs: []*asset.WithdrawalOutput{
+			{Address: "abc", Value: 1000},
+			{Address: "def", Value: 2000},
+		}},
+	}, {
+		name: "ok with options",
+		args: []string{"42", "abc:1000", "confs=6", "subtract=true"},
+		want: &asset.Withdrawal{
+			Outputs:     []*asset.WithdrawalOutput{{Address: "abc", Value: 1000}},
+			TargetConfs: 6,
+			Subtract:    true,
+		},
+	}, {
+		name:    "output without value",
+		args:    []string{"42", "abc"},
+		wantErr: errArgs,
+	}, {
+		name:    "bad value",
+		args:    []string{"42", "abc:1.5"},
+		wantErr: errArgs,
+	}, {
+		name:    "feerate and confs",
+		args:    []string{"42", "abc:1000", "feerate=10", "confs=2"},
+		wantErr: errArgs,
+	}, {
+		name:    "duplicate option",
+		args:    []string{"42", "abc:1000", "feerate=10", "feerate=11"},
+		wantErr: errArgs,
+	}, {
+		name:    "unknown option",
+		args:    []string{"42", "abc:1000", "fee=10"},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseEstimateWithdrawArgs(&RawParams{Args: test.args})
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("%s: expected error %v, got %v", test.name, test.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if form.AssetID != 42 {
+			t.Fatalf("%s: wrong asset ID %d", test.name, form.AssetID)
+		}
+		if !reflect.DeepEqual(form.Withdrawal, test.want) {
+			t.Fatalf("%s: wrong withdrawal %+v", test.name, form.Withdrawal)
+		}
+	}
+
+	// Subtract is not an option of withdraw.
+	_, err := parseWithdrawArgs(&RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("password123")},
+		Args:   []string{"42", "1000", "abc", "subtract=true"},
+	})
+	if !errors.Is(err, errArgs) {
+		t.Fatalf("expected errArgs for withdraw subtract option, got %v", err)
+	}
+}
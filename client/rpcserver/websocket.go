@@ -18,7 +18,12 @@ import (
 	"decred.org/dcrdex/dex/ws"
 )
 
-const updateWalletRoute = "updatewallet"
+const (
+	updateWalletRoute = "updatewallet"
+	// notifyRoute is the route of the core notifications sent to subscribed
+	// websocket clients.
+	notifyRoute = "notify"
+)
 
 var (
 	// Time allowed to read the next pong message from the peer. The
@@ -38,6 +43,19 @@ type wsClient struct {
 	mtx      sync.Mutex
 	cid      int32
 	feedLoop *dex.StartStopWaiter
+	// noteTypes is the set of notification types the client is subscribed to.
+	// A nil map is no subscription, and an empty map is every type.
+	noteTypes map[string]bool
+}
+
+// wantsNote is true if the client is subscribed to notifications of the type.
+func (cl *wsClient) wantsNote(noteType string) bool {
+	cl.mtx.Lock()
+	defer cl.mtx.Unlock()
+	if cl.noteTypes == nil {
+		return false
+	}
+	return len(cl.noteTypes) == 0 || cl.noteTypes[noteType]
 }
 
 func newWSClient(ip string, conn ws.Connection, hndlr func(msg *msgjson.Message) *msgjson.Error) *wsClient {
@@ -123,8 +141,17 @@ func (s *RPCServer) handleMessage(conn *wsClient, msg *msgjson.Message) *msgjson
 // wsHandlers is the map used by the server to locate the router handler for a
 // request.
 var wsHandlers = map[string]func(*RPCServer, *wsClient, *msgjson.Message) *msgjson.Error{
-	"loadmarket": wsLoadMarket,
-	"unmarket":   wsUnmarket,
+	"loadmarket":  wsLoadMarket,
+	"unmarket":    wsUnmarket,
+	"subscribe":   wsSubscribe,
+	"unsubscribe": wsUnsubscribe,
+}
+
+// noteSubscription is sent by websocket clients to subscribe to the core
+// notification feed. Types are the notification types to receive, e.g. order,
+// balance, or conn. If Types is empty, every notification is received.
+type noteSubscription struct {
+	Types []string `json:"types"`
 }
 
 // marketLoad is sent by websocket clients to subscribe to a market and request
@@ -163,6 +190,23 @@ func (s *RPCServer) notify(route string, payload interface{}) {
 	}
 }
 
+// notifySubscribers sends the core notification to the websocket clients that
+// are subscribed to its type.
+func (s *RPCServer) notifySubscribers(n core.Notification) {
+	msg, err := msgjson.NewNotification(notifyRoute, n)
+	if err != nil {
+		log.Errorf("notification encoding error: %v", err)
+		return
+	}
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, cl := range s.clients {
+		if cl.wantsNote(n.Type()) {
+			cl.Send(msg)
+		}
+	}
+}
+
 func (s *RPCServer) notifyWalletUpdate(assetID uint32) {
 	walletUpdate := s.core.WalletState(assetID)
 	s.notify(updateWalletRoute, walletUpdate)
@@ -247,3 +291,45 @@ func wsUnmarket(_ *RPCServer, cl *wsClient, _ *msgjson.Message) *msgjson.Error {
 	}
 	return nil
 }
+
+// wsSubscribe is the handler for the 'subscribe' websocket endpoint. Subscribes
+// the client to the core notifications of the requested types, replacing any
+// previous subscription. Notifications are sent on the 'notify' route.
+func wsSubscribe(_ *RPCServer, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	sub := new(noteSubscription)
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, sub); err != nil {
+			errMsg := fmt.Sprintf("error unmarshaling subscribe payload: %v", err)
+			log.Errorf(errMsg)
+			return msgjson.NewError(msgjson.RPCParseError, errMsg)
+		}
+	}
+	noteTypes := make(map[string]bool, len(sub.Types))
+	for _, noteType := range sub.Types {
+		noteTypes[noteType] = true
+	}
+	cl.mtx.Lock()
+	cl.noteTypes = noteTypes
+	cl.mtx.Unlock()
+	return wsRespond(cl, msg.ID, true)
+}
+
+// wsUnsubscribe is the handler for the 'unsubscribe' websocket endpoint.
+// Unsubscribes the client from the core notifications.
+func wsUnsubscribe(_ *RPCServer, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	cl.mtx.Lock()
+	cl.noteTypes = nil
+	cl.mtx.Unlock()
+	return wsRespond(cl, msg.ID, true)
+}
+
+// wsRespond sends a response with the result to the websocket client.
+func wsRespond(cl *wsClient, id uint64, result interface{}) *msgjson.Error {
+	res, err := msgjson.NewResponse(id, result, nil)
+	if err != nil {
+		log.Errorf("error encoding response: %v", err)
+		return msgjson.NewError(msgjson.RPCInternal, "error encoding response: "+err.Error())
+	}
+	cl.Send(res)
+	return nil
+}
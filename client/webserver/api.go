@@ -4,13 +4,19 @@
 package webserver
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/msgjson"
 )
 
 // apiGetFee is the handler for the '/getfee' API request.
@@ -21,7 +27,7 @@ func (s *WebServer) apiGetFee(w http.ResponseWriter, r *http.Request) {
 	}
 	fee, err := s.core.GetFee(form.Addr, form.Cert)
 	if err != nil {
-		s.writeAPIError(w, err.Error())
+		s.writeAPIError(w, r, "%v", err)
 		return
 	}
 	resp := struct {
@@ -34,6 +40,27 @@ func (s *WebServer) apiGetFee(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, resp, s.indent)
 }
 
+// apiGetPolicy is the handler for the '/getpolicy' API request.
+func (s *WebServer) apiGetPolicy(w http.ResponseWriter, r *http.Request) {
+	form := new(registration)
+	if !readPost(w, r, form) {
+		return
+	}
+	policy, err := s.core.GetPolicy(form.Addr, form.Cert)
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	resp := struct {
+		OK     bool         `json:"ok"`
+		Policy *core.Policy `json:"policy,omitempty"`
+	}{
+		OK:     true,
+		Policy: policy,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
 // apiRegister is the handler for the '/register' API request.
 func (s *WebServer) apiRegister(w http.ResponseWriter, r *http.Request) {
 	reg := new(registration)
@@ -44,7 +71,7 @@ func (s *WebServer) apiRegister(w http.ResponseWriter, r *http.Request) {
 	dcrID, _ := dex.BipSymbolID("dcr")
 	wallet := s.core.WalletState(dcrID)
 	if wallet == nil {
-		s.writeAPIError(w, "No Decred wallet")
+		s.writeAPIError(w, r, "No Decred wallet")
 		return
 	}
 
@@ -53,9 +80,10 @@ func (s *WebServer) apiRegister(w http.ResponseWriter, r *http.Request) {
 		Cert:    reg.Cert,
 		AppPass: reg.Password,
 		Fee:     reg.Fee,
+		Account: reg.Account,
 	})
 	if err != nil {
-		s.writeAPIError(w, "registration error: %v", err)
+		s.writeAPIError(w, r, "registration error: %v", err)
 		return
 	}
 	// There was no error paying the fee, but we must wait on confirmations
@@ -74,19 +102,21 @@ func (s *WebServer) apiNewWallet(w http.ResponseWriter, r *http.Request) {
 	if !readPost(w, r, form) {
 		return
 	}
+	// Additional wallets for the asset must be named.
 	has := s.core.WalletState(form.AssetID) != nil
-	if has {
-		s.writeAPIError(w, "already have a wallet for %s", unbip(form.AssetID))
+	if has && form.Name == "" {
+		s.writeAPIError(w, r, "already have a wallet for %s", unbip(form.AssetID))
 		return
 	}
 	// Wallet does not exist yet. Try to create it.
 	err := s.core.CreateWallet(form.AppPW, form.Pass, &core.WalletForm{
 		AssetID:    form.AssetID,
+		Name:       form.Name,
 		Account:    form.Account,
 		ConfigText: form.Config,
 	})
 	if err != nil {
-		s.writeAPIError(w, "error creating %s wallet: %v", unbip(form.AssetID), err)
+		s.writeAPIError(w, r, "error creating %s wallet: %v", unbip(form.AssetID), err)
 		return
 	}
 	s.notifyWalletUpdate(form.AssetID)
@@ -103,12 +133,12 @@ func (s *WebServer) apiOpenWallet(w http.ResponseWriter, r *http.Request) {
 	}
 	status := s.core.WalletState(form.AssetID)
 	if status == nil {
-		s.writeAPIError(w, "No wallet for %d -> %s", form.AssetID, unbip(form.AssetID))
+		s.writeAPIError(w, r, "No wallet for %d -> %s", form.AssetID, unbip(form.AssetID))
 		return
 	}
-	err := s.core.OpenWallet(form.AssetID, form.Pass)
+	err := s.core.OpenNamedWallet(form.AssetID, form.Name, form.Pass)
 	if err != nil {
-		s.writeAPIError(w, "error unlocking %s wallet: %v", unbip(form.AssetID), err)
+		s.writeAPIError(w, r, "error unlocking %s wallet: %v", unbip(form.AssetID), err)
 		return
 	}
 	s.notifyWalletUpdate(form.AssetID)
@@ -126,7 +156,7 @@ func (s *WebServer) apiConnectWallet(w http.ResponseWriter, r *http.Request) {
 	}
 	err := s.core.ConnectWallet(form.AssetID)
 	if err != nil {
-		s.writeAPIError(w, "error connecting to %s wallet: %v", unbip(form.AssetID), err)
+		s.writeAPIError(w, r, "error connecting to %s wallet: %v", unbip(form.AssetID), err)
 		return
 	}
 	s.notifyWalletUpdate(form.AssetID)
@@ -142,7 +172,22 @@ func (s *WebServer) apiTrade(w http.ResponseWriter, r *http.Request) {
 	}
 	ord, err := s.core.Trade(form.Pass, form.Order)
 	if err != nil {
-		s.writeAPIError(w, "error placing order: %v", err)
+		// A trade guard error is returned with its details so that the
+		// frontend can ask the user to confirm the order.
+		var guardErr *core.TradeGuardError
+		if errors.As(err, &guardErr) {
+			log.Infof("order stopped by trade guard: %v", err)
+			writeJSON(w, &struct {
+				OK    bool                  `json:"ok"`
+				Msg   string                `json:"msg"`
+				Guard *core.TradeGuardError `json:"guard"`
+			}{
+				Msg:   guardErr.Error(),
+				Guard: guardErr,
+			}, s.indent)
+			return
+		}
+		s.writeAPIError(w, r, "error placing order: %v", err)
 		return
 	}
 	resp := &struct {
@@ -164,7 +209,622 @@ func (s *WebServer) apiCancel(w http.ResponseWriter, r *http.Request) {
 	}
 	err := s.core.Cancel(form.Pass, form.OrderID)
 	if err != nil {
-		s.writeAPIError(w, "error cancelling order %s: %v", form.OrderID, err)
+		s.writeAPIError(w, r, "error cancelling order %s: %v", form.OrderID, err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiCloneOrder is the handler for the '/cloneorder' API request.
+func (s *WebServer) apiCloneOrder(w http.ResponseWriter, r *http.Request) {
+	form := new(cloneOrderForm)
+	defer form.Pass.Clear()
+	if !readPost(w, r, form) {
+		return
+	}
+	ord, err := s.core.CloneOrder(form.Pass, form.OrderID, form.Rate)
+	if err != nil {
+		s.writeAPIError(w, r, "error re-placing order %s: %v", form.OrderID, err)
+		return
+	}
+	resp := &struct {
+		OK    bool        `json:"ok"`
+		Order *core.Order `json:"order"`
+	}{
+		OK:    true,
+		Order: ord,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiStartBot is the handler for the '/startbot' API request.
+func (s *WebServer) apiStartBot(w http.ResponseWriter, r *http.Request) {
+	form := new(startBotForm)
+	defer form.Pass.Clear()
+	if !readPost(w, r, form) {
+		return
+	}
+	if s.mm == nil {
+		s.writeAPIError(w, r, "market maker is not available")
+		return
+	}
+	if form.Config == nil {
+		s.writeAPIError(w, r, "no bot configuration")
+		return
+	}
+	id, err := s.mm.Start(form.Pass, form.Config)
+	if err != nil {
+		s.writeAPIError(w, r, "error starting bot: %v", err)
+		return
+	}
+	resp := &struct {
+		OK bool   `json:"ok"`
+		ID uint64 `json:"id"`
+	}{
+		OK: true,
+		ID: id,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiStopBot is the handler for the '/stopbot' API request.
+func (s *WebServer) apiStopBot(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		ID uint64 `json:"id"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	if s.mm == nil {
+		s.writeAPIError(w, r, "market maker is not available")
+		return
+	}
+	err := s.mm.Stop(form.ID)
+	if err != nil {
+		s.writeAPIError(w, r, "error stopping bot %d: %v", form.ID, err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiBots is the handler for the '/bots' API request.
+func (s *WebServer) apiBots(w http.ResponseWriter, r *http.Request) {
+	if s.mm == nil {
+		s.writeAPIError(w, r, "market maker is not available")
+		return
+	}
+	response := struct {
+		OK   bool            `json:"ok"`
+		Bots []*mm.BotStatus `json:"bots"`
+	}{
+		OK:   true,
+		Bots: s.mm.Bots(),
+	}
+	writeJSON(w, response, s.indent)
+}
+
+// apiAddAlert is the handler for the '/addalert' API request.
+func (s *WebServer) apiAddAlert(w http.ResponseWriter, r *http.Request) {
+	form := new(core.AlertForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	alert, err := s.core.AddAlert(form)
+	if err != nil {
+		s.writeAPIError(w, r, "error adding alert: %v", err)
+		return
+	}
+	resp := &struct {
+		OK    bool        `json:"ok"`
+		Alert *core.Alert `json:"alert"`
+	}{
+		OK:    true,
+		Alert: alert,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiAlerts is the handler for the '/alerts' API request.
+func (s *WebServer) apiAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := s.core.Alerts()
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving alerts: %v", err)
+		return
+	}
+	resp := &struct {
+		OK     bool          `json:"ok"`
+		Alerts []*core.Alert `json:"alerts"`
+	}{
+		OK:     true,
+		Alerts: alerts,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiRemoveAlert is the handler for the '/removealert' API request.
+func (s *WebServer) apiRemoveAlert(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		ID string `json:"id"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.RemoveAlert(form.ID)
+	if err != nil {
+		s.writeAPIError(w, r, "error removing alert %s: %v", form.ID, err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiNoteRoutes is the handler for the '/noteroutes' API request.
+func (s *WebServer) apiNoteRoutes(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK     bool                       `json:"ok"`
+		Routes map[string]*core.NoteRoute `json:"routes"`
+	}{
+		OK:     true,
+		Routes: s.core.NoteRoutes(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetNoteRoute is the handler for the '/setnoteroute' API request. A null
+// route restores the default handling for the notification type.
+func (s *WebServer) apiSetNoteRoute(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Type  string          `json:"type"`
+		Route *core.NoteRoute `json:"route"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetNoteRoute(form.Type, form.Route)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting %s notification route: %v", form.Type, err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiCoinPolicies is the handler for the '/coinpolicies' API request.
+func (s *WebServer) apiCoinPolicies(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK       bool                        `json:"ok"`
+		Policies map[uint32]*core.CoinPolicy `json:"policies"`
+	}{
+		OK:       true,
+		Policies: s.core.CoinPolicies(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetCoinPolicy is the handler for the '/setcoinpolicy' API request. A null
+// policy disables coin management for the wallet.
+func (s *WebServer) apiSetCoinPolicy(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32           `json:"assetID"`
+		Policy  *core.CoinPolicy `json:"policy"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetCoinPolicy(form.AssetID, form.Policy)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting coin policy: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiFundingStrategies is the handler for the '/fundingstrategies' API
+// request.
+func (s *WebServer) apiFundingStrategies(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK         bool                             `json:"ok"`
+		Strategies map[uint32]asset.FundingStrategy `json:"strategies"`
+	}{
+		OK:         true,
+		Strategies: s.core.FundingStrategies(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetFundingStrategy is the handler for the '/setfundingstrategy' API
+// request. An empty strategy restores the wallet's own coin selection.
+func (s *WebServer) apiSetFundingStrategy(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID  uint32                `json:"assetID"`
+		Strategy asset.FundingStrategy `json:"strategy"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetFundingStrategy(form.AssetID, form.Strategy)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting funding strategy: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiWalletCoins is the handler for the '/walletcoins' API request. The coins
+// may be chosen to fund an order with the coins field of the trade form.
+func (s *WebServer) apiWalletCoins(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID    uint32 `json:"assetID"`
+		WalletName string `json:"walletName"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	coins, err := s.core.WalletCoins(form.AssetID, form.WalletName)
+	if err != nil {
+		s.writeAPIError(w, r, "error listing coins: %v", err)
+		return
+	}
+	resp := &struct {
+		OK    bool               `json:"ok"`
+		Coins []*core.WalletCoin `json:"coins"`
+	}{
+		OK:    true,
+		Coins: coins,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiProxies is the handler for the '/proxies' API request. Proxy passwords
+// are not sent.
+func (s *WebServer) apiProxies(w http.ResponseWriter, r *http.Request) {
+	proxies := s.core.Proxies()
+	for _, proxy := range proxies {
+		proxy.Pass = ""
+	}
+	resp := &struct {
+		OK      bool                         `json:"ok"`
+		Proxies map[string]*core.ProxyConfig `json:"proxies"`
+	}{
+		OK:      true,
+		Proxies: proxies,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetProxy is the handler for the '/setproxy' API request. A null proxy
+// removes the DEX server's proxy setting.
+func (s *WebServer) apiSetProxy(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Host  string            `json:"host"`
+		Proxy *core.ProxyConfig `json:"proxy"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetProxy(form.Host, form.Proxy)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting proxy: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiCancelOnDisconnect is the handler for the '/cancelondisconnect' API
+// request.
+func (s *WebServer) apiCancelOnDisconnect(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK       bool              `json:"ok"`
+		Settings map[string]uint32 `json:"settings"`
+	}{
+		OK:       true,
+		Settings: s.core.CancelOnDisconnect(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetCancelOnDisconnect is the handler for the '/setcancelondisconnect' API
+// request. Zero epochs disables cancel-on-disconnect for the DEX server.
+func (s *WebServer) apiSetCancelOnDisconnect(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Host   string `json:"host"`
+		Epochs uint32 `json:"epochs"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetCancelOnDisconnect(form.Host, form.Epochs)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting cancel-on-disconnect: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiSwapConfs is the handler for the '/swapconfs' API request.
+func (s *WebServer) apiSwapConfs(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK       bool              `json:"ok"`
+		Settings map[uint32]uint32 `json:"settings"`
+	}{
+		OK:       true,
+		Settings: s.core.SwapConfs(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetSwapConfs is the handler for the '/setswapconfs' API request. Zero
+// confirmations restores the DEX servers' swap confirmation requirements for
+// the asset.
+func (s *WebServer) apiSetSwapConfs(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32 `json:"assetID"`
+		Confs   uint32 `json:"confs"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetSwapConfs(form.AssetID, form.Confs)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting swap confirmations: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiAutoRefund is the handler for the '/autorefund' API request.
+func (s *WebServer) apiAutoRefund(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK      bool `json:"ok"`
+		Enabled bool `json:"enabled"`
+	}{
+		OK:      true,
+		Enabled: s.core.AutoRefund(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetAutoRefund is the handler for the '/setautorefund' API request.
+func (s *WebServer) apiSetAutoRefund(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Enabled bool `json:"enabled"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetAutoRefund(form.Enabled)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting automatic refunds: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiLotSizePolicy is the handler for the '/lotsizepolicy' API request.
+func (s *WebServer) apiLotSizePolicy(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK     bool               `json:"ok"`
+		Policy core.LotSizePolicy `json:"policy"`
+	}{
+		OK:     true,
+		Policy: s.core.LotSizePolicy(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetLotSizePolicy is the handler for the '/setlotsizepolicy' API request.
+func (s *WebServer) apiSetLotSizePolicy(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Policy core.LotSizePolicy `json:"policy"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetLotSizePolicy(form.Policy)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting lot size policy: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiTradeGuard is the handler for the '/tradeguard' API request.
+func (s *WebServer) apiTradeGuard(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK    bool            `json:"ok"`
+		Guard core.TradeGuard `json:"guard"`
+	}{
+		OK:    true,
+		Guard: s.core.TradeGuard(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetTradeGuard is the handler for the '/settradeguard' API request.
+func (s *WebServer) apiSetTradeGuard(w http.ResponseWriter, r *http.Request) {
+	form := new(core.TradeGuard)
+	if !readPost(w, r, form) {
+		return
+	}
+	err := s.core.SetTradeGuard(*form)
+	if err != nil {
+		s.writeAPIError(w, r, "error setting trade guard: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiReservations is the handler for the '/reservations' API request.
+func (s *WebServer) apiReservations(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32 `json:"assetID"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	res, err := s.core.Reservations(form.AssetID)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving %s reservations: %v", unbip(form.AssetID), err)
+		return
+	}
+	resp := &struct {
+		OK           bool               `json:"ok"`
+		Reservations *core.Reservations `json:"reservations"`
+	}{
+		OK:           true,
+		Reservations: res,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiNewDepositAddress is the handler for the '/newdepositaddress' API
+// request.
+func (s *WebServer) apiNewDepositAddress(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32 `json:"assetID"`
+		Label   string `json:"label"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	addr, err := s.core.NewDepositAddress(form.AssetID, form.Label)
+	if err != nil {
+		s.writeAPIError(w, r, "error generating %s deposit address: %v", unbip(form.AssetID), err)
+		return
+	}
+	resp := &struct {
+		OK      bool                 `json:"ok"`
+		Address *core.DepositAddress `json:"address"`
+	}{
+		OK:      true,
+		Address: addr,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiDepositAddresses is the handler for the '/depositaddresses' API request.
+func (s *WebServer) apiDepositAddresses(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32 `json:"assetID"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	resp := &struct {
+		OK        bool                   `json:"ok"`
+		Addresses []*core.DepositAddress `json:"addresses"`
+	}{
+		OK:        true,
+		Addresses: s.core.DepositAddresses(form.AssetID),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSetNotes is the handler for the '/setnotes' API request. The notes and
+// tags are set for the match if a match ID is given, else for the order.
+func (s *WebServer) apiSetNotes(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		OrderID string   `json:"orderID"`
+		MatchID string   `json:"matchID"`
+		Notes   string   `json:"notes"`
+		Tags    []string `json:"tags"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	var err error
+	if form.MatchID != "" {
+		err = s.core.SetMatchNotes(form.OrderID, form.MatchID, form.Notes, form.Tags)
+	} else {
+		err = s.core.SetOrderNotes(form.OrderID, form.Notes, form.Tags)
+	}
+	if err != nil {
+		s.writeAPIError(w, r, "error setting notes: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiSetDepositLabel is the handler for the '/setdepositlabel' API request.
+func (s *WebServer) apiSetDepositLabel(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		AssetID uint32 `json:"assetID"`
+		Address string `json:"address"`
+		Label   string `json:"label"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	if err := s.core.SetDepositLabel(form.AssetID, form.Address, form.Label); err != nil {
+		s.writeAPIError(w, r, "error setting deposit address label: %v", err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiOrderFunding is the handler for the '/orderfunding' API request.
+func (s *WebServer) apiOrderFunding(w http.ResponseWriter, r *http.Request) {
+	form := new(core.TradeForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	funding, err := s.core.OrderFunding(form)
+	if err != nil {
+		s.writeAPIError(w, r, "error checking order funding: %v", err)
+		return
+	}
+	resp := &struct {
+		OK      bool               `json:"ok"`
+		Funding *core.OrderFunding `json:"funding"`
+	}{
+		OK:      true,
+		Funding: funding,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiPreOrder is the handler for the '/preorder' API request.
+func (s *WebServer) apiPreOrder(w http.ResponseWriter, r *http.Request) {
+	form := new(core.TradeForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	est, err := s.core.PreOrder(form)
+	if err != nil {
+		s.writeAPIError(w, r, "error estimating order: %v", err)
+		return
+	}
+	resp := &struct {
+		OK       bool                `json:"ok"`
+		Estimate *core.OrderEstimate `json:"estimate"`
+	}{
+		OK:       true,
+		Estimate: est,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiDevicePrompts is the handler for the '/deviceprompts' API request.
+func (s *WebServer) apiDevicePrompts(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK      bool                 `json:"ok"`
+		Prompts []*core.DevicePrompt `json:"prompts"`
+	}{
+		OK:      true,
+		Prompts: s.core.DevicePrompts(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiDevicePrompt is the handler for the '/deviceprompt' API request, which
+// responds to a hardware wallet's PIN or passphrase prompt.
+func (s *WebServer) apiDevicePrompt(w http.ResponseWriter, r *http.Request) {
+	form := new(devicePromptForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	defer form.Response.Clear()
+	err := s.core.RespondDevicePrompt(form.ID, form.Response)
+	if err != nil {
+		s.writeAPIError(w, r, "error responding to device prompt: %v", err)
 		return
 	}
 	writeJSON(w, simpleAck(), s.indent)
@@ -180,7 +840,7 @@ func (s *WebServer) apiCloseWallet(w http.ResponseWriter, r *http.Request) {
 	}
 	err := s.core.CloseWallet(form.AssetID)
 	if err != nil {
-		s.writeAPIError(w, "error locking %s wallet: %v", unbip(form.AssetID), err)
+		s.writeAPIError(w, r, "error locking %s wallet: %v", unbip(form.AssetID), err)
 		return
 	}
 	s.notifyWalletUpdate(form.AssetID)
@@ -196,12 +856,88 @@ func (s *WebServer) apiInit(w http.ResponseWriter, r *http.Request) {
 	}
 	err := s.core.InitializeClient(login.Pass)
 	if err != nil {
-		s.writeAPIError(w, "initialization error: %v", err)
+		s.writeAPIError(w, r, "initialization error: %v", err)
 		return
 	}
 	s.actuallyLogin(w, r, login)
 }
 
+// apiExportBackup handles the 'exportbackup' API request. The encrypted backup
+// is sent as a file attachment.
+func (s *WebServer) apiExportBackup(w http.ResponseWriter, r *http.Request) {
+	login := new(loginForm)
+	if !readPost(w, r, login) {
+		return
+	}
+	defer login.Pass.Clear()
+	archive, err := s.core.ExportBackup(login.Pass)
+	if err != nil {
+		s.writeAPIError(w, r, "backup error: %v", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=dexc.backup")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(archive); err != nil {
+		log.Errorf("error writing backup: %v", err)
+	}
+}
+
+// apiRestoreBackup handles the 'restorebackup' API request. The client is
+// initialized from the backup, and the user is logged in.
+func (s *WebServer) apiRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	form := new(restoreBackupForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	defer form.Pass.Clear()
+	err := s.core.RestoreBackup(form.Pass, form.Backup)
+	if err != nil {
+		s.writeAPIError(w, r, "restore error: %v", err)
+		return
+	}
+	s.actuallyLogin(w, r, &loginForm{Pass: form.Pass})
+}
+
+// apiAppSeed handles the 'appseed' API request.
+func (s *WebServer) apiAppSeed(w http.ResponseWriter, r *http.Request) {
+	login := new(loginForm)
+	if !readPost(w, r, login) {
+		return
+	}
+	defer login.Pass.Clear()
+	words, err := s.core.ExportSeed(login.Pass)
+	if err != nil {
+		s.writeAPIError(w, r, "seed error: %v", err)
+		return
+	}
+	resp := &struct {
+		OK   bool   `json:"ok"`
+		Seed string `json:"seed"`
+	}{
+		OK:   true,
+		Seed: words,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiRestoreSeed handles the 'restoreseed' API request. The client is
+// initialized from the application seed, and the user is logged in.
+func (s *WebServer) apiRestoreSeed(w http.ResponseWriter, r *http.Request) {
+	form := new(restoreSeedForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	defer form.Pass.Clear()
+	defer form.Seed.Clear()
+	err := s.core.RestoreFromSeed(form.Pass, string(form.Seed))
+	if err != nil {
+		s.writeAPIError(w, r, "restore error: %v", err)
+		return
+	}
+	s.actuallyLogin(w, r, &loginForm{Pass: form.Pass})
+}
+
 // apiLogin handles the 'login' API request.
 func (s *WebServer) apiLogin(w http.ResponseWriter, r *http.Request) {
 	login := new(loginForm)
@@ -212,14 +948,19 @@ func (s *WebServer) apiLogin(w http.ResponseWriter, r *http.Request) {
 	s.actuallyLogin(w, r, login)
 }
 
-// apiLogout handles the 'logout' API request.
+// apiLogout handles the 'logout' API request. The requesting device's session
+// is revoked.
 func (s *WebServer) apiLogout(w http.ResponseWriter, r *http.Request) {
 	err := s.core.Logout()
 	if err != nil {
-		s.writeAPIError(w, "logout error: %v", err)
+		s.writeAPIError(w, r, "logout error: %v", err)
 		return
 	}
 
+	if sess := s.authSession(r); sess != nil {
+		s.revokeSession(sess.id)
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     authCK,
 		Path:     "/",
@@ -246,7 +987,7 @@ func (s *WebServer) apiGetBalance(w http.ResponseWriter, r *http.Request) {
 	}
 	bal, err := s.core.AssetBalance(form.AssetID)
 	if err != nil {
-		s.writeAPIError(w, "balance error: %v", err)
+		s.writeAPIError(w, r, "balance error: %v", err)
 		return
 	}
 	resp := &struct {
@@ -269,12 +1010,12 @@ func (s *WebServer) apiWithdraw(w http.ResponseWriter, r *http.Request) {
 	}
 	state := s.core.WalletState(form.AssetID)
 	if state == nil {
-		s.writeAPIError(w, "no wallet found for %s", unbip(form.AssetID))
+		s.writeAPIError(w, r, "no wallet found for %s", unbip(form.AssetID))
 		return
 	}
 	coin, err := s.core.Withdraw(form.Pass, form.AssetID, form.Value, form.Address)
 	if err != nil {
-		s.writeAPIError(w, "withdraw error: %v", err)
+		s.writeAPIError(w, r, "withdraw error: %v", err)
 		return
 	}
 	resp := struct {
@@ -291,17 +1032,22 @@ func (s *WebServer) apiWithdraw(w http.ResponseWriter, r *http.Request) {
 func (s *WebServer) actuallyLogin(w http.ResponseWriter, r *http.Request, login *loginForm) {
 	loginResult, err := s.core.Login(login.Pass)
 	if err != nil {
-		s.writeAPIError(w, "login error: %v", err)
+		s.writeAPIError(w, r, "login error: %v", err)
 		return
 	}
 
 	user := extractUserInfo(r)
 	if !user.Authed {
-		authToken := s.authorize()
+		name := login.Name
+		if name == "" {
+			name = r.UserAgent()
+		}
+		authToken := s.authorize(name)
 		http.SetCookie(w, &http.Cookie{
-			Name:  authCK,
-			Value: authToken,
-			Path:  "/",
+			Name:    authCK,
+			Value:   authToken,
+			Path:    "/",
+			Expires: time.Now().Add(sessionLifetime),
 			// The client should only send the cookie with first-party requests.
 			// Cross-site requests should not include the auth cookie.
 			// https://tools.ietf.org/html/draft-ietf-httpbis-cookie-same-site-00#section-4.1.1
@@ -334,14 +1080,172 @@ func (s *WebServer) apiUser(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, response, s.indent)
 }
 
+// apiPortfolio handles the 'portfolio' API request.
+func (s *WebServer) apiPortfolio(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		OK        bool            `json:"ok"`
+		Portfolio *core.Portfolio `json:"portfolio"`
+	}{
+		OK:        true,
+		Portfolio: s.core.Portfolio(),
+	}
+	writeJSON(w, response, s.indent)
+}
+
+// apiWalletHealth handles the 'wallethealth' API request.
+func (s *WebServer) apiWalletHealth(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		OK      bool                 `json:"ok"`
+		Wallets []*core.WalletHealth `json:"wallets"`
+	}{
+		OK:      true,
+		Wallets: s.core.WalletHealth(),
+	}
+	writeJSON(w, response, s.indent)
+}
+
+// apiCandles is the handler for the '/candles' API request. The market is
+// specified by the host, base, and quote query parameters, and the candle
+// duration by the bin parameter, e.g. 1h. The optional start and end
+// parameters are millisecond stamps.
+func (s *WebServer) apiCandles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	host, base, quote, err := parseMarketQuery(q)
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	binSize, err := time.ParseDuration(q.Get("bin"))
+	if err != nil {
+		s.writeAPIError(w, r, "invalid bin size %q", q.Get("bin"))
+		return
+	}
+	var start, end uint64
+	if startStr := q.Get("start"); startStr != "" {
+		if start, err = strconv.ParseUint(startStr, 10, 64); err != nil {
+			s.writeAPIError(w, r, "invalid start %q", startStr)
+			return
+		}
+	}
+	if endStr := q.Get("end"); endStr != "" {
+		if end, err = strconv.ParseUint(endStr, 10, 64); err != nil {
+			s.writeAPIError(w, r, "invalid end %q", endStr)
+			return
+		}
+	}
+	candles, err := s.core.Candles(host, base, quote, binSize, start, end)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving candles: %v", err)
+		return
+	}
+	resp := &struct {
+		OK      bool           `json:"ok"`
+		Candles []*core.Candle `json:"candles"`
+	}{
+		OK:      true,
+		Candles: candles,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiDepth is the handler for the '/depth' API request. The market is
+// specified by the host, base, and quote query parameters, and its order book
+// must be synced.
+func (s *WebServer) apiDepth(w http.ResponseWriter, r *http.Request) {
+	host, base, quote, err := parseMarketQuery(r.URL.Query())
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	chart, err := s.core.Depth(host, base, quote)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving depth chart: %v", err)
+		return
+	}
+	resp := &struct {
+		OK    bool             `json:"ok"`
+		Depth *core.DepthChart `json:"depth"`
+	}{
+		OK:    true,
+		Depth: chart,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiSpots is the handler for the '/spots' API request. The DEX is specified
+// by the host query parameter.
+func (s *WebServer) apiSpots(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	spots, err := s.core.Spots(host)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving spots: %v", err)
+		return
+	}
+	resp := &struct {
+		OK    bool                     `json:"ok"`
+		Spots map[string]*msgjson.Spot `json:"spots"`
+	}{
+		OK:    true,
+		Spots: spots,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// parseMarketQuery parses the host, base, and quote query parameters.
+func parseMarketQuery(q url.Values) (host string, base, quote uint32, err error) {
+	b, err := strconv.ParseUint(q.Get("base"), 10, 32)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid base asset ID %q", q.Get("base"))
+	}
+	qt, err := strconv.ParseUint(q.Get("quote"), 10, 32)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid quote asset ID %q", q.Get("quote"))
+	}
+	return q.Get("host"), uint32(b), uint32(qt), nil
+}
+
+// apiExportTrades handles the 'exporttrades' API request. The trade history is
+// sent as a file download, formatted according to the format query parameter,
+// which is either csv or json.
+func (s *WebServer) apiExportTrades(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = core.ExportCSV
+	}
+	if format != core.ExportCSV && format != core.ExportJSON {
+		s.writeAPIError(w, r, "unknown export format %q", format)
+		return
+	}
+	history, err := s.core.TradeHistory()
+	if err != nil {
+		s.writeAPIError(w, r, "export error: %v", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=dex-trades."+format)
+	if format == core.ExportJSON {
+		writeJSON(w, history, true)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err = history.WriteCSV(w); err != nil {
+		log.Errorf("error writing trade history CSV: %v", err)
+	}
+}
+
 // writeAPIError logs the formatted error and sends a standardResponse with the
 // error message.
-func (s *WebServer) writeAPIError(w http.ResponseWriter, format string, a ...interface{}) {
-	errMsg := fmt.Sprintf(format, a...)
-	log.Error(errMsg)
+func (s *WebServer) writeAPIError(w http.ResponseWriter, r *http.Request, format string, a ...interface{}) {
+	log.Errorf(format, a...)
+	// Render errors from core in the language of the request.
+	for i, arg := range a {
+		if err, ok := arg.(error); ok {
+			a[i] = localizedError(r, err)
+		}
+	}
 	resp := &standardResponse{
 		OK:  false,
-		Msg: errMsg,
+		Msg: fmt.Sprintf(format, a...),
 	}
 	writeJSON(w, resp, s.indent)
 }
@@ -0,0 +1,402 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package webserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+	"github.com/go-chi/chi"
+)
+
+// The versioned JSON API is served under apiV1Root for third-party frontends.
+// Every request must include one of the configured API keys, or an API token
+// created with the /api/newapitoken request, in an
+// "Authorization: Bearer <key>" header. Responses are JSON objects with an "ok" field. If "ok" is false,
+// the "msg" field describes the error.
+//
+//	GET  /api/v1/markets
+//	  The known DEX servers and their markets.
+//	  {"ok": true, "exchanges": {"[host]": Exchange,...}}
+//	  Additional accounts at a DEX are listed separately, keyed by account
+//	  host, e.g. bot@dex.example.com:7232.
+//	GET  /api/v1/accounts?host=[host]
+//	  The account hosts of the user's accounts at the DEX, default first.
+//	  {"ok": true, "accounts": ["[account host]",...]}
+//	GET  /api/v1/book?host=[host]&base=[assetID]&quote=[assetID]
+//	  The order book of a market.
+//	  {"ok": true, "book": OrderBook}
+//	GET  /api/v1/orders?host=&market=&status=&since=&until=&n=
+//	  The user's orders, newest first. All filters are optional. status is a
+//	  comma-separated list of order statuses, e.g. booked,executed. since and
+//	  until are in milliseconds. n limits the number of orders.
+//	  {"ok": true, "orders": [Order,...]}
+//	POST /api/v1/orders
+//	  Place an order. The body is {"pw": "[app pass]", "order": TradeForm}.
+//	  The TradeForm's account field selects an additional account by label.
+//	  {"ok": true, "order": Order}
+//	GET  /api/v1/orders/{orderID}
+//	  {"ok": true, "order": Order}
+//	POST /api/v1/orders/{orderID}/cancel
+//	  Cancel an order. The body is {"pw": "[app pass]"}.
+//	  {"ok": true}
+//	GET  /api/v1/trades?host=&market=&since=&until=&n=
+//	  The matches of the user's orders, newest first. The filters are as for
+//	  orders, but since and until apply to the match time.
+//	  {"ok": true, "trades": [OrderMatch,...]}
+//	GET  /api/v1/wallets
+//	  {"ok": true, "wallets": [WalletState,...]}
+//	POST /api/v1/wallets/{assetID}/withdraw
+//	  The body is {"pw": "[app pass]", "value": [int], "address": "[address]"}.
+//	  {"ok": true, "coin": "[coin ID]"}
+//	GET  /api/v1/notifications?since=[ms]
+//	  The most recent notifications, oldest first, optionally only those
+//	  newer than since.
+//	  {"ok": true, "notifications": [Notification,...]}
+const apiV1Root = "/api/v1"
+
+// maxAPINotes is the number of recent notifications kept for the
+// /api/v1/notifications request.
+const maxAPINotes = 100
+
+// apiKeyHashes hashes the API keys for constant-time comparison.
+func apiKeyHashes(keys []string) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, 0, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		hashes = append(hashes, sha256.Sum256([]byte(key)))
+	}
+	return hashes
+}
+
+// registerAPIV1 adds the versioned API routes to the /api router.
+func (s *WebServer) registerAPIV1(r chi.Router) {
+	r.Route(strings.TrimPrefix(apiV1Root, "/api"), func(v1 chi.Router) {
+		v1.Use(s.requireAPIKey)
+		v1.Get("/markets", s.apiV1Markets)
+		v1.Get("/accounts", s.apiV1Accounts)
+		v1.Get("/book", s.apiV1Book)
+		v1.Get("/orders", s.apiV1Orders)
+		v1.Post("/orders", s.apiTrade)
+		v1.Get("/orders/{orderID}", s.apiV1Order)
+		v1.Post("/orders/{orderID}/cancel", s.apiV1Cancel)
+		v1.Get("/trades", s.apiV1Trades)
+		v1.Get("/wallets", s.apiV1Wallets)
+		v1.Post("/wallets/{assetID}/withdraw", s.apiV1Withdraw)
+		v1.Get("/notifications", s.apiV1Notifications)
+	})
+}
+
+// requireAPIKey ensures that the request has a valid API key or API token in
+// the Authorization header.
+func (s *WebServer) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		key := strings.TrimPrefix(auth, "Bearer ")
+		if key == "" || key == auth || (!s.validAPIKey(key) && s.session(key, true) == nil) {
+			log.Warnf("API authentication failure from ip: %s", r.RemoteAddr)
+			w.Header().Add("WWW-Authenticate", `Bearer realm="dexc api"`)
+			writeJSONWithStatus(w, &standardResponse{Msg: http.StatusText(http.StatusUnauthorized)},
+				http.StatusUnauthorized, s.indent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAPIKey checks the key against the configured API keys.
+func (s *WebServer) validAPIKey(key string) bool {
+	keySHA := sha256.Sum256([]byte(key))
+	var valid bool
+	for i := range s.apiKeys {
+		if subtle.ConstantTimeCompare(s.apiKeys[i][:], keySHA[:]) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// apiV1Markets is the handler for the '/api/v1/markets' API request.
+func (s *WebServer) apiV1Markets(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK        bool                      `json:"ok"`
+		Exchanges map[string]*core.Exchange `json:"exchanges"`
+	}{
+		OK:        true,
+		Exchanges: s.core.Exchanges(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Accounts is the handler for the '/api/v1/accounts' API request.
+func (s *WebServer) apiV1Accounts(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		s.writeAPIError(w, r, "no host specified")
+		return
+	}
+	resp := &struct {
+		OK       bool     `json:"ok"`
+		Accounts []string `json:"accounts"`
+	}{
+		OK:       true,
+		Accounts: s.core.DEXAccounts(host),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Book is the handler for the '/api/v1/book' API request.
+func (s *WebServer) apiV1Book(w http.ResponseWriter, r *http.Request) {
+	host, base, quote, err := parseMarketQuery(r.URL.Query())
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	book, err := s.core.Book(host, base, quote)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving order book: %v", err)
+		return
+	}
+	resp := &struct {
+		OK   bool            `json:"ok"`
+		Book *core.OrderBook `json:"book"`
+	}{
+		OK:   true,
+		Book: book,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Orders is the handler for the '/api/v1/orders' API request.
+func (s *WebServer) apiV1Orders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	orders, err := s.core.Orders(filter)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving orders: %v", err)
+		return
+	}
+	resp := &struct {
+		OK     bool          `json:"ok"`
+		Orders []*core.Order `json:"orders"`
+	}{
+		OK:     true,
+		Orders: orders,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Order is the handler for the '/api/v1/orders/{orderID}' API request.
+func (s *WebServer) apiV1Order(w http.ResponseWriter, r *http.Request) {
+	oid := chi.URLParam(r, "orderID")
+	ord, err := s.core.Order(oid)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving order %s: %v", oid, err)
+		return
+	}
+	resp := &struct {
+		OK    bool        `json:"ok"`
+		Order *core.Order `json:"order"`
+	}{
+		OK:    true,
+		Order: ord,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Cancel is the handler for the '/api/v1/orders/{orderID}/cancel' API
+// request.
+func (s *WebServer) apiV1Cancel(w http.ResponseWriter, r *http.Request) {
+	form := new(cancelForm)
+	defer form.Pass.Clear()
+	if !readPost(w, r, form) {
+		return
+	}
+	oid := chi.URLParam(r, "orderID")
+	err := s.core.Cancel(form.Pass, oid)
+	if err != nil {
+		s.writeAPIError(w, r, "error cancelling order %s: %v", oid, err)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiV1Trades is the handler for the '/api/v1/trades' API request.
+func (s *WebServer) apiV1Trades(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		s.writeAPIError(w, r, "%v", err)
+		return
+	}
+	matches, err := s.core.Matches(filter)
+	if err != nil {
+		s.writeAPIError(w, r, "error retrieving trades: %v", err)
+		return
+	}
+	resp := &struct {
+		OK     bool               `json:"ok"`
+		Trades []*core.OrderMatch `json:"trades"`
+	}{
+		OK:     true,
+		Trades: matches,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Wallets is the handler for the '/api/v1/wallets' API request.
+func (s *WebServer) apiV1Wallets(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK      bool                `json:"ok"`
+		Wallets []*core.WalletState `json:"wallets"`
+	}{
+		OK:      true,
+		Wallets: s.core.Wallets(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Withdraw is the handler for the '/api/v1/wallets/{assetID}/withdraw'
+// API request.
+func (s *WebServer) apiV1Withdraw(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Value   uint64           `json:"value"`
+		Address string           `json:"address"`
+		Pass    encode.PassBytes `json:"pw"`
+	}{}
+	defer form.Pass.Clear()
+	if !readPost(w, r, form) {
+		return
+	}
+	assetID, err := strconv.ParseUint(chi.URLParam(r, "assetID"), 10, 32)
+	if err != nil {
+		s.writeAPIError(w, r, "invalid asset ID %q", chi.URLParam(r, "assetID"))
+		return
+	}
+	if s.core.WalletState(uint32(assetID)) == nil {
+		s.writeAPIError(w, r, "no wallet found for %s", unbip(uint32(assetID)))
+		return
+	}
+	coin, err := s.core.Withdraw(form.Pass, uint32(assetID), form.Value, form.Address)
+	if err != nil {
+		s.writeAPIError(w, r, "withdraw error: %v", err)
+		return
+	}
+	resp := &struct {
+		OK   bool   `json:"ok"`
+		Coin string `json:"coin"`
+	}{
+		OK:   true,
+		Coin: coin.String(),
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiV1Notifications is the handler for the '/api/v1/notifications' API
+// request.
+func (s *WebServer) apiV1Notifications(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			s.writeAPIError(w, r, "invalid since %q", sinceStr)
+			return
+		}
+	}
+	lang := requestLocale(r)
+	notes := make([]interface{}, 0)
+	s.notesMtx.RLock()
+	for _, n := range s.notes {
+		if n.Time() > since {
+			notes = append(notes, localizedNote(n, lang))
+		}
+	}
+	s.notesMtx.RUnlock()
+	resp := &struct {
+		OK    bool          `json:"ok"`
+		Notes []interface{} `json:"notifications"`
+	}{
+		OK:    true,
+		Notes: notes,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// storeNote keeps the notification for the '/api/v1/notifications' API
+// request, discarding the oldest if there are more than maxAPINotes.
+func (s *WebServer) storeNote(n core.Notification) {
+	s.notesMtx.Lock()
+	defer s.notesMtx.Unlock()
+	s.notes = append(s.notes, n)
+	if len(s.notes) > maxAPINotes {
+		s.notes = s.notes[len(s.notes)-maxAPINotes:]
+	}
+}
+
+// parseOrderFilter parses the order filter from the URL query.
+func parseOrderFilter(q url.Values) (*core.OrderFilter, error) {
+	filter := &core.OrderFilter{
+		Host:   q.Get("host"),
+		Market: q.Get("market"),
+	}
+	if statuses := q.Get("status"); statuses != "" {
+		for _, name := range strings.Split(statuses, ",") {
+			status, err := parseOrderStatus(name)
+			if err != nil {
+				return nil, err
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	parseUint := func(key string, bitSize int) (uint64, error) {
+		val := q.Get(key)
+		if val == "" {
+			return 0, nil
+		}
+		i, err := strconv.ParseUint(val, 10, bitSize)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", key, val)
+		}
+		return i, nil
+	}
+	var err error
+	if filter.Since, err = parseUint("since", 64); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseUint("until", 64); err != nil {
+		return nil, err
+	}
+	n, err := parseUint("n", 31)
+	if err != nil {
+		return nil, err
+	}
+	filter.N = int(n)
+	if filter.Until > 0 && filter.Until < filter.Since {
+		return nil, fmt.Errorf("until is before since")
+	}
+	return filter, nil
+}
+
+// parseOrderStatus parses the order status from its string representation.
+func parseOrderStatus(name string) (order.OrderStatus, error) {
+	for status := order.OrderStatusEpoch; status <= order.OrderStatusRevoked; status++ {
+		if status.String() == name {
+			return status, nil
+		}
+	}
+	return order.OrderStatusUnknown, fmt.Errorf("unknown order status %q", name)
+}
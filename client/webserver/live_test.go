@@ -299,6 +299,7 @@ func (c *TCore) trySend(u *core.BookUpdate) {
 }
 
 func (c *TCore) Exchanges() map[string]*core.Exchange { return tExchanges }
+func (c *TCore) DEXAccounts(dexAddr string) []string  { return []string{dexAddr} }
 
 func (c *TCore) InitializeClient(pw []byte) error {
 	randomDelay()
@@ -309,6 +310,16 @@ func (c *TCore) GetFee(host, cert string) (uint64, error) {
 	return 1e8, nil
 }
 
+func (c *TCore) GetPolicy(host, cert string) (*core.Policy, error) {
+	return &core.Policy{
+		Host:      host,
+		Published: time.Now(),
+		Fees:      "1 DCR registration fee.",
+		Penalties: "Accounts that fail to act on matches are closed.",
+		Contact:   "operator@dex.example",
+	}, nil
+}
+
 func (c *TCore) Register(r *core.RegisterForm) (*core.RegisterResult, error) {
 	randomDelay()
 	c.reg = r
@@ -316,6 +327,7 @@ func (c *TCore) Register(r *core.RegisterForm) (*core.RegisterResult, error) {
 }
 func (c *TCore) Login([]byte) (*core.LoginResult, error) { return &core.LoginResult{}, nil }
 func (c *TCore) Logout() error                           { return nil }
+func (c *TCore) LoggedIn() bool                          { return true }
 
 func (c *TCore) Sync(dexAddr string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error) {
 	c.midGap = randomMagnitude(-2, 4)
@@ -477,6 +489,109 @@ func (c *TCore) AssetBalance(assetID uint32) (*db.Balance, error) {
 
 func (c *TCore) AckNotes(ids []dex.Bytes) {}
 
+func (c *TCore) AddAlert(form *core.AlertForm) (*core.Alert, error) {
+	return &core.Alert{
+		AlertForm: *form,
+		ID:        hex.EncodeToString(encode.RandomBytes(32)),
+		Stamp:     encode.UnixMilliU(time.Now()),
+	}, nil
+}
+func (c *TCore) Alerts() ([]*core.Alert, error)                       { return nil, nil }
+func (c *TCore) NoteRoutes() map[string]*core.NoteRoute               { return nil }
+func (c *TCore) RemoveAlert(id string) error                          { return nil }
+func (c *TCore) DevicePrompts() []*core.DevicePrompt                  { return nil }
+func (c *TCore) RespondDevicePrompt(id string, response []byte) error { return nil }
+func (c *TCore) ExportBackup(pw []byte) ([]byte, error)               { return nil, nil }
+func (c *TCore) RestoreBackup(pw, archive []byte) error               { return nil }
+func (c *TCore) ExportSeed(pw []byte) (string, error)                 { return "", nil }
+func (c *TCore) RestoreFromSeed(pw []byte, seed string) error         { return nil }
+
+func (c *TCore) SetNoteRoute(noteType string, route *core.NoteRoute) error {
+	return nil
+}
+
+func (c *TCore) CoinPolicies() map[uint32]*core.CoinPolicy { return nil }
+func (c *TCore) Proxies() map[string]*core.ProxyConfig     { return nil }
+func (c *TCore) SetProxy(dexAddr string, proxy *core.ProxyConfig) error {
+	return nil
+}
+func (c *TCore) CancelOnDisconnect() map[string]uint32 { return nil }
+func (c *TCore) SetCancelOnDisconnect(dexAddr string, epochs uint32) error {
+	return nil
+}
+func (c *TCore) SwapConfs() map[uint32]uint32 { return nil }
+func (c *TCore) SetSwapConfs(assetID uint32, confs uint32) error {
+	return nil
+}
+func (c *TCore) AutoRefund() bool { return true }
+func (c *TCore) SetAutoRefund(enabled bool) error {
+	return nil
+}
+func (c *TCore) LotSizePolicy() core.LotSizePolicy { return core.LotSizeNotify }
+func (c *TCore) SetLotSizePolicy(policy core.LotSizePolicy) error {
+	return nil
+}
+func (c *TCore) TradeGuard() core.TradeGuard { return core.TradeGuard{} }
+func (c *TCore) SetTradeGuard(guard core.TradeGuard) error {
+	return nil
+}
+func (c *TCore) NewDepositAddress(assetID uint32, label string) (*core.DepositAddress, error) {
+	return &core.DepositAddress{AssetID: assetID, Address: ordertest.RandomAddress(), Label: label}, nil
+}
+func (c *TCore) DepositAddresses(assetID uint32) []*core.DepositAddress { return nil }
+func (c *TCore) SetOrderNotes(orderID, notes string, tags []string) error {
+	return nil
+}
+func (c *TCore) SetMatchNotes(orderID, matchID, notes string, tags []string) error {
+	return nil
+}
+func (c *TCore) SetDepositLabel(assetID uint32, address, label string) error {
+	return nil
+}
+func (c *TCore) Reservations(assetID uint32) (*core.Reservations, error) {
+	return &core.Reservations{AssetID: assetID, Balance: &db.Balance{}}, nil
+}
+func (c *TCore) OrderFunding(form *core.TradeForm) (*core.OrderFunding, error) {
+	return &core.OrderFunding{OK: true}, nil
+}
+func (c *TCore) PreOrder(form *core.TradeForm) (*core.OrderEstimate, error) {
+	lotSize := tExchanges[form.Host].Assets[form.Base].LotSize
+	lots := form.Qty / lotSize
+	if !form.IsLimit && !form.Sell {
+		lots = uint64(rand.Intn(10) + 1)
+	}
+	fromID, toID := form.Quote, form.Base
+	if form.Sell {
+		fromID, toID = form.Base, form.Quote
+	}
+	return &core.OrderEstimate{
+		Lots:        lots,
+		Qty:         lots * lotSize,
+		Remainder:   form.Qty % lotSize,
+		Swap:        &core.FeeEstimate{AssetID: fromID, FeeRate: 10, Best: 2500, Worst: 2500 * lots},
+		Redeem:      &core.FeeEstimate{AssetID: toID, FeeRate: 5, Best: 1200, Worst: 1200 * lots},
+		Locked:      form.Qty + 2500*lots,
+		RefundAfter: uint64(20 * time.Hour / time.Millisecond),
+	}, nil
+}
+func (c *TCore) SetCoinPolicy(assetID uint32, policy *core.CoinPolicy) error {
+	return nil
+}
+func (c *TCore) FundingStrategies() map[uint32]asset.FundingStrategy { return nil }
+func (c *TCore) WalletCoins(assetID uint32, walletName string) ([]*core.WalletCoin, error) {
+	return []*core.WalletCoin{{
+		WalletCoin: &asset.WalletCoin{
+			ID:            encode.RandomBytes(36),
+			String:        "coin",
+			Value:         uint64(rand.Intn(10)+1) * 1e8,
+			Confirmations: uint32(rand.Intn(100)),
+		},
+	}}, nil
+}
+func (c *TCore) SetFundingStrategy(assetID uint32, strategy asset.FundingStrategy) error {
+	return nil
+}
+
 var configOpts = []*config.Option{
 	{
 		DisplayName: "RPC Server",
@@ -553,6 +668,10 @@ func (c *TCore) CreateWallet(appPW, walletPW []byte, form *core.WalletForm) erro
 	return nil
 }
 
+func (c *TCore) OpenNamedWallet(assetID uint32, name string, pw []byte) error {
+	return c.OpenWallet(assetID, pw)
+}
+
 func (c *TCore) OpenWallet(assetID uint32, pw []byte) error {
 	c.mtx.RLock()
 	defer c.mtx.RUnlock()
@@ -629,6 +748,53 @@ func (c *TCore) User() *core.User {
 	return user
 }
 
+func (c *TCore) Portfolio() *core.Portfolio {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	portfolio := new(core.Portfolio)
+	for assetID, bal := range c.balances {
+		ap := &core.AssetPortfolio{
+			AssetID:     assetID,
+			Symbol:      unbip(assetID),
+			Available:   bal.Available,
+			Immature:    bal.Immature,
+			Locked:      bal.Locked,
+			OrderLocked: bal.Locked,
+			Total:       bal.Available + bal.Immature + bal.Locked,
+		}
+		portfolio.Assets = append(portfolio.Assets, ap)
+	}
+	return portfolio
+}
+
+func (c *TCore) WalletHealth() []*core.WalletHealth {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	health := make([]*core.WalletHealth, 0, len(c.wallets))
+	for assetID := range c.wallets {
+		health = append(health, &core.WalletHealth{
+			AssetID: assetID,
+			Symbol:  unbip(assetID),
+			Running: true,
+			Sync: &asset.SyncStatus{
+				Synced:       true,
+				Progress:     1,
+				Height:       1e6,
+				TargetHeight: 1e6,
+				BlockTime:    time.Now().Add(-time.Minute),
+				Peers:        8,
+			},
+			Stamp:  time.Now(),
+			Errors: []*core.WalletError{},
+		})
+	}
+	return health
+}
+
+func (c *TCore) TradeHistory() (*core.TradeHistory, error) {
+	return &core.TradeHistory{}, nil
+}
+
 func (c *TCore) SupportedAssets() map[uint32]*core.SupportedAsset {
 	c.mtx.RLock()
 	defer c.mtx.RUnlock()
@@ -663,6 +829,97 @@ func (c *TCore) Trade(pw []byte, form *core.TradeForm) (*core.Order, error) {
 	}, nil
 }
 
+func (c *TCore) CloneOrder(pw []byte, orderID string, rate uint64) (*core.Order, error) {
+	for _, xc := range tExchanges {
+		for _, mkt := range xc.Markets {
+			for _, ord := range mkt.Orders {
+				if ord.ID != orderID {
+					continue
+				}
+				form := &core.TradeForm{
+					Host:    xc.Host,
+					IsLimit: ord.Type == order.LimitOrderType,
+					Sell:    ord.Sell,
+					Base:    mkt.BaseID,
+					Quote:   mkt.QuoteID,
+					Qty:     ord.Qty,
+					Rate:    ord.Rate,
+				}
+				if rate > 0 {
+					form.Rate = rate
+				}
+				return c.Trade(pw, form)
+			}
+		}
+	}
+	return nil, fmt.Errorf("order %s not found", orderID)
+}
+
+func (c *TCore) Book(dex string, base, quote uint32) (*core.OrderBook, error) {
+	return c.book(), nil
+}
+func (c *TCore) Candles(host string, base, quote uint32, binSize time.Duration, start, end uint64) ([]*core.Candle, error) {
+	binMs := uint64(binSize / time.Millisecond)
+	if binMs == 0 {
+		return nil, fmt.Errorf("invalid bin size %s", binSize)
+	}
+	if end == 0 {
+		end = encode.UnixMilliU(time.Now())
+	}
+	if start == 0 || end-start > binMs*200 {
+		start = end - binMs*200
+	}
+	start -= start % binMs
+	// A random walk toward the mid-gap.
+	rate := c.midGap * (0.8 + rand.Float64()*0.4)
+	candles := make([]*core.Candle, 0)
+	for stamp := start; stamp <= end; stamp += binMs {
+		endRate := rate * (1 + (rand.Float64()-0.5)*0.04)
+		endRate += (c.midGap - endRate) * 0.02
+		high := math.Max(rate, endRate) * (1 + rand.Float64()*0.01)
+		low := math.Min(rate, endRate) * (1 - rand.Float64()*0.01)
+		vol := uint64(rand.Float64() * c.maxQty * 1e8)
+		candles = append(candles, &core.Candle{
+			StartStamp:  stamp,
+			EndStamp:    stamp + binMs,
+			MatchVolume: vol,
+			QuoteVolume: uint64(float64(vol) * endRate),
+			HighRate:    uint64(high * 1e8),
+			LowRate:     uint64(low * 1e8),
+			StartRate:   uint64(rate * 1e8),
+			EndRate:     uint64(endRate * 1e8),
+		})
+		rate = endRate
+	}
+	return candles, nil
+}
+func (c *TCore) Depth(host string, base, quote uint32) (*core.DepthChart, error) {
+	book := c.book()
+	depth := func(ords []*core.MiniOrder) []*core.DepthPoint {
+		pts := make([]*core.DepthPoint, 0, len(ords))
+		var sum float64
+		for _, ord := range ords {
+			sum += ord.Qty
+			pts = append(pts, &core.DepthPoint{Rate: ord.Rate, Qty: ord.Qty, Depth: sum})
+		}
+		return pts
+	}
+	return &core.DepthChart{
+		Host:    host,
+		BaseID:  base,
+		QuoteID: quote,
+		Buys:    depth(book.Buys),
+		Sells:   depth(book.Sells),
+		MidGap:  c.midGap,
+	}, nil
+}
+func (c *TCore) Spots(host string) (map[string]*msgjson.Spot, error)    { return nil, nil }
+func (c *TCore) Orders(filter *core.OrderFilter) ([]*core.Order, error) { return nil, nil }
+func (c *TCore) Order(orderID string) (*core.Order, error)              { return nil, nil }
+func (c *TCore) Matches(filter *core.OrderFilter) ([]*core.OrderMatch, error) {
+	return nil, nil
+}
+
 func (c *TCore) Cancel(pw []byte, sid string) error {
 	for _, xc := range tExchanges {
 		for _, mkt := range xc.Markets {
@@ -756,7 +1013,7 @@ func TestServer(t *testing.T) {
 		tCore.Register(new(core.RegisterForm))
 	}
 
-	s, err := New(tCore, ":54321", logger, true)
+	s, err := New(tCore, nil, ":54321", logger, true, nil, false)
 	if err != nil {
 		t.Fatalf("error creating server: %v", err)
 	}
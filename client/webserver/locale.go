@@ -0,0 +1,115 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"decred.org/dcrdex/client/core"
+)
+
+// requestLocale is the language selected for the request. The lang cookie,
+// which is set by the frontend, takes precedence over the Accept-Language
+// header. An empty string is returned if neither names a supported language,
+// in which case text is left in the Core's language.
+func requestLocale(r *http.Request) string {
+	if cookie, err := r.Cookie(langCK); err == nil {
+		if lang, found := core.MatchLocale(cookie.Value); found {
+			return lang
+		}
+	}
+	for _, tag := range acceptLanguages(r.Header.Get("Accept-Language")) {
+		if lang, found := core.MatchLocale(tag); found {
+			return lang
+		}
+	}
+	return ""
+}
+
+// acceptLanguages parses the language tags of an Accept-Language header,
+// ordered by preference.
+func acceptLanguages(header string) []string {
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+	var tags []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weightedTag{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	langs := make([]string, 0, len(tags))
+	for _, t := range tags {
+		langs = append(langs, t.tag)
+	}
+	return langs
+}
+
+// localizedNote is the notification with its subject and details rendered in
+// the language. The notification is returned as is if the language is empty.
+func localizedNote(n core.Notification, lang string) interface{} {
+	if lang == "" {
+		return n
+	}
+	subject, details := core.TranslateNote(n, lang)
+	if subject == n.Subject() && details == n.Details() {
+		return n
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		log.Errorf("error encoding notification: %v", err)
+		return n
+	}
+	fields := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(b, &fields); err != nil {
+		log.Errorf("error decoding notification: %v", err)
+		return n
+	}
+	fields["subject"], _ = json.Marshal(subject)
+	fields["details"], _ = json.Marshal(details)
+	return fields
+}
+
+// localizedError renders the error in the language of the request.
+func localizedError(r *http.Request, err error) string {
+	return core.TranslateError(err, requestLocale(r))
+}
+
+// apiLocales is the handler for the '/locales' API request, which lists the
+// supported languages and the language selected for the request.
+func (s *WebServer) apiLocales(w http.ResponseWriter, r *http.Request) {
+	resp := &struct {
+		OK       bool     `json:"ok"`
+		Locales  []string `json:"locales"`
+		Selected string   `json:"selected,omitempty"`
+	}{
+		OK:       true,
+		Locales:  core.Locales(),
+		Selected: requestLocale(r),
+	}
+	writeJSON(w, resp, s.indent)
+}
@@ -73,6 +73,41 @@ func (s *WebServer) requireLogin(next http.Handler) http.Handler {
 	})
 }
 
+// requireAuth ensures that the API request is from an authorized browser
+// session. Unauthorized requests receive a 401 error.
+func (s *WebServer) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !extractUserInfo(r).Authed {
+			writeJSONWithStatus(w, &standardResponse{Msg: http.StatusText(http.StatusUnauthorized)},
+				http.StatusUnauthorized, s.indent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readOnlyPaths are the API requests other than GET requests that are allowed
+// before login in read-only mode. These initialize the app or log in.
+var readOnlyPaths = map[string]bool{
+	"/api/init":        true,
+	"/api/login":       true,
+	"/api/restoreseed": true,
+}
+
+// readOnlyMiddleware refuses API requests that may change the state of the
+// client until the user logs in, if the server is in read-only mode. GET
+// requests are always allowed. Refused requests receive a 403 error.
+func (s *WebServer) readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly && r.Method != http.MethodGet && !readOnlyPaths[r.URL.Path] && !s.core.LoggedIn() {
+			writeJSONWithStatus(w, &standardResponse{Msg: "not available in read-only mode. login first"},
+				http.StatusForbidden, s.indent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // requireDEXConnection ensures that the user has completely registered with at
 // least 1 DEX before allowing the incoming request to proceed. Redirects to the
 // register page if the user has not connected any DEX.
@@ -0,0 +1,269 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+)
+
+const (
+	// sessionLifetime is how long a browser login session lasts.
+	sessionLifetime = 7 * 24 * time.Hour
+	// maxSessionNameLen is the longest session name kept. Longer names, such
+	// as long User-Agent strings, are truncated.
+	maxSessionNameLen = 64
+	// maxRevoked is the number of revoked sessions kept in the revocation
+	// list.
+	maxRevoked = 100
+)
+
+// session is a browser login session or a long-lived API token. Only the
+// hash of the session's token is stored.
+type session struct {
+	id       string
+	name     string
+	apiToken bool
+	created  time.Time
+	expires  time.Time // zero for an API token that doesn't expire
+	lastUsed time.Time
+}
+
+// expired is true if the session has an expiration that has passed.
+func (sess *session) expired(now time.Time) bool {
+	return !sess.expires.IsZero() && now.After(sess.expires)
+}
+
+// info is the sessionInfo for the session.
+func (sess *session) info(current bool) *sessionInfo {
+	nfo := &sessionInfo{
+		ID:       sess.id,
+		Name:     sess.name,
+		APIToken: sess.apiToken,
+		Created:  encode.UnixMilliU(sess.created),
+		LastUsed: encode.UnixMilliU(sess.lastUsed),
+		Current:  current,
+	}
+	if !sess.expires.IsZero() {
+		nfo.Expires = encode.UnixMilliU(sess.expires)
+	}
+	return nfo
+}
+
+// sessionInfo is information about an active session. Times are in unix
+// milliseconds. Expires is zero for an API token that doesn't expire.
+type sessionInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	APIToken bool   `json:"apiToken"`
+	Created  uint64 `json:"created"`
+	Expires  uint64 `json:"expires"`
+	LastUsed uint64 `json:"lastUsed"`
+	Current  bool   `json:"current"`
+}
+
+// revokedSession is an entry in the revocation list.
+type revokedSession struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	APIToken bool   `json:"apiToken"`
+	Revoked  uint64 `json:"revoked"`
+}
+
+// tokenHash is the key of the token's session in the WebServer's session
+// registry.
+func tokenHash(token string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(token))
+}
+
+// newSession creates, stores, and returns a new session token. A lifetime of
+// zero creates a session that doesn't expire.
+func (s *WebServer) newSession(name string, lifetime time.Duration, apiToken bool) (string, *session) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	h := tokenHash(token)
+	if len(name) > maxSessionNameLen {
+		name = name[:maxSessionNameLen]
+	}
+	now := time.Now()
+	sess := &session{
+		id:       hex.EncodeToString(h[:8]),
+		name:     name,
+		apiToken: apiToken,
+		created:  now,
+		lastUsed: now,
+	}
+	if lifetime > 0 {
+		sess.expires = now.Add(lifetime)
+	}
+	s.mtx.Lock()
+	s.sessions[h] = sess
+	s.mtx.Unlock()
+	return token, sess
+}
+
+// authorize creates, stores, and returns a new browser session token to
+// identify the user. Any number of devices can be authorized at a time, each
+// with its own named session.
+func (s *WebServer) authorize(name string) string {
+	token, _ := s.newSession(name, sessionLifetime, false)
+	return token
+}
+
+// session retrieves the active session for the token, and marks it used. An
+// expired session is removed. If apiToken is true, only API tokens are
+// returned, otherwise only browser sessions.
+func (s *WebServer) session(token string, apiToken bool) *session {
+	if token == "" {
+		return nil
+	}
+	h := tokenHash(token)
+	now := time.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	sess, found := s.sessions[h]
+	if !found || sess.apiToken != apiToken {
+		return nil
+	}
+	if sess.expired(now) {
+		delete(s.sessions, h)
+		return nil
+	}
+	sess.lastUsed = now
+	return sess
+}
+
+// authSession is the browser session of the request's auth cookie, if any.
+func (s *WebServer) authSession(r *http.Request) *session {
+	cookie, err := r.Cookie(authCK)
+	switch err {
+	case nil:
+		return s.session(cookie.Value, false)
+	case http.ErrNoCookie:
+	default:
+		log.Errorf("authToken retrieval error: %v", err)
+	}
+	return nil
+}
+
+// isAuthed checks if the incoming request is from an authorized user/device.
+// Requires the auth token cookie to be set in the request and for the token
+// to belong to an active browser session.
+func (s *WebServer) isAuthed(r *http.Request) bool {
+	return s.authSession(r) != nil
+}
+
+// revokeSession ends the session with the ID and adds it to the revocation
+// list. revokeSession returns false if there is no active session with the ID.
+func (s *WebServer) revokeSession(id string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for h, sess := range s.sessions {
+		if sess.id != id {
+			continue
+		}
+		delete(s.sessions, h)
+		s.revoked = append(s.revoked, &revokedSession{
+			ID:       sess.id,
+			Name:     sess.name,
+			APIToken: sess.apiToken,
+			Revoked:  encode.UnixMilliU(time.Now()),
+		})
+		if len(s.revoked) > maxRevoked {
+			s.revoked = s.revoked[len(s.revoked)-maxRevoked:]
+		}
+		return true
+	}
+	return false
+}
+
+// activeSessions removes expired sessions and returns the active sessions,
+// newest first, and the revocation list. The session with currentID is
+// marked current.
+func (s *WebServer) activeSessions(currentID string) ([]*sessionInfo, []*revokedSession) {
+	now := time.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	sessions := make([]*sessionInfo, 0, len(s.sessions))
+	for h, sess := range s.sessions {
+		if sess.expired(now) {
+			delete(s.sessions, h)
+			continue
+		}
+		sessions = append(sessions, sess.info(sess.id == currentID))
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created > sessions[j].Created
+	})
+	return sessions, append([]*revokedSession(nil), s.revoked...)
+}
+
+// apiSessions is the handler for the '/sessions' API request.
+func (s *WebServer) apiSessions(w http.ResponseWriter, r *http.Request) {
+	var currentID string
+	if sess := s.authSession(r); sess != nil {
+		currentID = sess.id
+	}
+	sessions, revoked := s.activeSessions(currentID)
+	resp := &struct {
+		OK       bool              `json:"ok"`
+		Sessions []*sessionInfo    `json:"sessions"`
+		Revoked  []*revokedSession `json:"revoked"`
+	}{
+		OK:       true,
+		Sessions: sessions,
+		Revoked:  revoked,
+	}
+	writeJSON(w, resp, s.indent)
+}
+
+// apiRevokeSession is the handler for the '/revokesession' API request.
+func (s *WebServer) apiRevokeSession(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		ID string `json:"id"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	if !s.revokeSession(form.ID) {
+		s.writeAPIError(w, r, "no active session %q", form.ID)
+		return
+	}
+	writeJSON(w, simpleAck(), s.indent)
+}
+
+// apiNewAPIToken is the handler for the '/newapitoken' API request. The token
+// is only returned once and can't be retrieved later. Zero hours creates a
+// token that lasts until it is revoked or the app is restarted.
+func (s *WebServer) apiNewAPIToken(w http.ResponseWriter, r *http.Request) {
+	form := &struct {
+		Name  string `json:"name"`
+		Hours uint32 `json:"hours"`
+	}{}
+	if !readPost(w, r, form) {
+		return
+	}
+	if form.Name == "" {
+		s.writeAPIError(w, r, "no API token name")
+		return
+	}
+	token, sess := s.newSession(form.Name, time.Duration(form.Hours)*time.Hour, true)
+	resp := &struct {
+		OK      bool         `json:"ok"`
+		Token   string       `json:"token"`
+		Session *sessionInfo `json:"session"`
+	}{
+		OK:      true,
+		Token:   token,
+		Session: sess.info(false),
+	}
+	writeJSON(w, resp, s.indent)
+}
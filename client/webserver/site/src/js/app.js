@@ -295,6 +295,18 @@ export default class Application {
     }
   }
 
+  /*
+   * handleDevicePrompt asks the user for the PIN or passphrase requested by a
+   * hardware wallet. Confirmation prompts are only displayed.
+   */
+  async handleDevicePrompt (prompt) {
+    if (prompt.kind !== 'pin' && prompt.kind !== 'passphrase') return
+    const response = window.prompt(`${prompt.device}: ${prompt.message}`)
+    if (response === null) return
+    const res = await postJSON('/api/deviceprompt', { id: prompt.id, response: response })
+    this.checkResponse(res)
+  }
+
   /*
    * setNotes sets the current notification cache and populates the notification
    * display.
@@ -325,16 +337,27 @@ export default class Application {
         break
       }
       case 'balance': {
+        // Only the asset's default wallet is shown.
         const wallet = this.user.assets[note.assetID].wallet
-        if (wallet) wallet.balance = note.balance
+        if (wallet && wallet.name === note.wallet) wallet.balance = note.balance
         break
       }
       case 'feepayment':
         this.handleFeePaymentNote(note)
+        break
+      case 'alert':
+        if (note.alert.desktop && !note.desktop) desktopNotify(note)
+        break
+      case 'deviceprompt':
+        this.handleDevicePrompt(note.prompt)
     }
 
+    // The user's notification routing may request an OS notification.
+    if (note.desktop) desktopNotify(note)
     // Inform the page.
     this.loadedPage.notify(note)
+    // Log-only notifications update state, but are not displayed.
+    if (note.logonly) return
     // Discard data notifications.
     if (note.severity < ntfn.POKE) return
     // Poke notifications have their own display.
@@ -473,3 +496,20 @@ function setSeverityClass (el, severity) {
   if (cls) el.classList.add(cls)
   el.style.display = cls ? 'block' : 'none'
 }
+
+/*
+ * desktopNotify shows the notification with the browser's Notification API,
+ * asking for permission first if the user hasn't decided yet.
+ */
+function desktopNotify (note) {
+  if (!window.Notification) return
+  const show = () => new window.Notification(note.subject, { body: note.details })
+  if (window.Notification.permission === 'granted') {
+    show()
+    return
+  }
+  if (window.Notification.permission === 'denied') return
+  window.Notification.requestPermission().then(permission => {
+    if (permission === 'granted') show()
+  })
+}
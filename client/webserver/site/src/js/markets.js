@@ -66,13 +66,16 @@ export default class MarketsPage extends BasePage {
       'tifBox', 'submitBttn', 'qtyField', 'rateField', 'orderErr',
       'baseWalletIcons', 'quoteWalletIcons', 'lotSize', 'rateStep', 'lotField',
       'tifNow', 'mktBuyBox', 'mktBuyLots', 'mktBuyField', 'minMktBuy', 'qtyBox',
-      'loaderMsg', 'balanceTable', 'orderPreview',
+      'loaderMsg', 'balanceTable', 'orderPreview', 'coinControl', 'coinList',
+      'coinRowTmpl',
       // Wallet unlock form
       'forms', 'openForm', 'uwAppPass',
       // Order submission is verified with the user's password.
       'verifyForm', 'vSide', 'vQty', 'vBase', 'vRate',
       'vTotal', 'vQuote', 'vPass', 'vSubmit', 'verifyLimit', 'verifyMarket',
-      'vmTotal', 'vmAsset', 'vmLots', 'mktBuyScore',
+      'vmTotal', 'vmAsset', 'vmLots', 'mktBuyScore', 'vEstimate', 'vRounded',
+      'vRoundedQty', 'vRoundedLots', 'vRemainder', 'vLocked', 'vSwapFees',
+      'vRedeemFees', 'vRefund', 'vTradeFeeRow', 'vTradeFeeRate', 'vTradeFee',
       // Create wallet form
       'walletForm', 'acctName',
       // Active orders
@@ -109,7 +112,7 @@ export default class MarketsPage extends BasePage {
     }
 
     // Prepare templates for the buy and sell tables and the user's order table.
-    cleanTemplates(page.rowTemplate, page.liveTemplate)
+    cleanTemplates(page.rowTemplate, page.liveTemplate, page.coinRowTmpl)
 
     // Prepare the list of markets.
     this.marketList = new MarketList(page.marketList)
@@ -186,6 +189,7 @@ export default class MarketsPage extends BasePage {
     bind(page.mktBuyField, 'keyup', () => { this.marketBuyChanged() })
     bind(page.rateField, 'change', () => { this.rateFieldChanged() })
     bind(page.rateField, 'keyup', () => { this.previewOrder(true) })
+    bind(page.coinControl, 'change', () => { this.refreshCoinList() })
 
     // Market search input bindings.
     bind(page.marketSearch, 'change', () => { this.filterMarkets() })
@@ -249,6 +253,7 @@ export default class MarketsPage extends BasePage {
    */
   setOrderVisibility () {
     const page = this.page
+    if (page.coinControl.checked) this.refreshCoinList()
     if (this.isLimit()) {
       Doc.show(page.priceBox, page.tifBox, page.qtyBox)
       Doc.hide(page.mktBuyBox)
@@ -419,10 +424,56 @@ export default class MarketsPage extends BasePage {
       quote: market.quote.id,
       qty: asAtoms(qtyField.value),
       rate: asAtoms(page.rateField.value), // message-rate
-      tifnow: page.tifNow.checked
+      tifnow: page.tifNow.checked,
+      funding: page.coinControl.checked ? 'manual' : '',
+      coins: this.chosenCoins()
     }
   }
 
+  /*
+   * refreshCoinList lists the unlocked coins of the wallet that funds the
+   * order, so that the user can choose the coins that fund it. The list is
+   * hidden when coin control is not enabled.
+   */
+  async refreshCoinList () {
+    const page = this.page
+    Doc.empty(page.coinList)
+    if (!page.coinControl.checked) {
+      Doc.hide(page.coinList)
+      return
+    }
+    const market = this.market
+    const asset = this.isSell() ? market.base : market.quote
+    const res = await postJSON('/api/walletcoins', { assetID: asset.id })
+    if (!app.checkResponse(res)) {
+      page.coinControl.checked = false
+      Doc.hide(page.coinList)
+      return
+    }
+    for (const coin of res.coins) {
+      if (coin.locked) continue
+      const row = page.coinRowTmpl.cloneNode(true)
+      const check = tmplElement(row, 'check')
+      check.value = coin.id
+      tmplElement(row, 'value').textContent = `${Doc.formatCoinValue(coin.value / 1e8)} ${asset.symbol.toUpperCase()}`
+      tmplElement(row, 'label').textContent = coin.label || coin.address
+      tmplElement(row, 'confs').textContent = `${coin.confs} confs`
+      page.coinList.appendChild(row)
+    }
+    Doc.show(page.coinList)
+  }
+
+  /*
+   * chosenCoins is the IDs of the coins chosen to fund the order, or undefined
+   * if coin control is not enabled.
+   */
+  chosenCoins () {
+    const page = this.page
+    if (!page.coinControl.checked) return undefined
+    const checks = page.coinList.querySelectorAll('input[type=checkbox]:checked')
+    return Array.from(checks).map(check => check.value)
+  }
+
   /**
    * previewOrder shows quote amount when rate or quantity input are changed
    */
@@ -669,6 +720,42 @@ export default class MarketsPage extends BasePage {
       }
     }
     this.showForm(page.verifyForm)
+    this.showEstimate(order)
+  }
+
+  /*
+   * showEstimate requests the pre-order estimate of fees, locked funds, and lot
+   * rounding, and displays it on the order verification form. The estimate is
+   * left hidden if it is not available.
+   */
+  async showEstimate (order) {
+    const page = this.page
+    Doc.hide(page.vEstimate, page.vRounded, page.vTradeFeeRow)
+    const res = await postJSON('/api/preorder', order)
+    if (!res.requestSuccessful || !res.ok) return
+    const est = res.estimate
+    const symbol = assetID => app.assets[assetID].symbol.toUpperCase()
+    const feeRange = fees => {
+      const best = Doc.formatCoinValue(fees.best / 1e8)
+      if (fees.best === fees.worst) return `${best} ${symbol(fees.assetID)}`
+      return `${best} - ${Doc.formatCoinValue(fees.worst / 1e8)} ${symbol(fees.assetID)}`
+    }
+    if (est.remainder > 0) {
+      page.vRoundedQty.textContent = Doc.formatCoinValue(est.qty / 1e8)
+      page.vRoundedLots.textContent = est.lots
+      page.vRemainder.textContent = Doc.formatCoinValue(est.remainder / 1e8)
+      Doc.show(page.vRounded)
+    }
+    page.vLocked.textContent = `${Doc.formatCoinValue(est.locked / 1e8)} ${symbol(est.swap.assetID)}`
+    page.vSwapFees.textContent = feeRange(est.swap)
+    page.vRedeemFees.textContent = feeRange(est.redeem)
+    page.vRefund.textContent = `${(est.refundAfter / 3600000).toFixed(1)} hours`
+    if (est.tradeFee) {
+      page.vTradeFeeRate.textContent = (est.tradeFee.rate / 100).toFixed(2)
+      page.vTradeFee.textContent = `${Doc.formatCoinValue(est.tradeFee.fee / 1e8)} ${symbol(est.tradeFee.assetID)}`
+      Doc.show(page.vTradeFeeRow)
+    }
+    Doc.show(page.vEstimate)
   }
 
   /* showCancel shows a form to confirm submission of a cancel order. */
@@ -814,6 +901,12 @@ export default class MarketsPage extends BasePage {
     }
     if (!this.validateOrder(order)) return
     var res = await postJSON('/api/trade', req)
+    // An order stopped by a trade guard warning is resubmitted if the user
+    // confirms it.
+    if (res.guard && !res.guard.blocked && window.confirm(res.msg)) {
+      order.confirmed = true
+      res = await postJSON('/api/trade', req)
+    }
     app.loaded()
     if (!app.checkResponse(res)) return
     // If the wallets are not open locally, they must have been opened during
@@ -25,7 +25,8 @@ export default class RegistrationPage extends BasePage {
       'dexAddrForm', 'dexAddr', 'certFile', 'selectedCert', 'removeCert', 'addCert',
       'submitDEXAddr', 'dexAddrErr',
       // Form 5: Confirm DEX registration and pay fee
-      'confirmRegForm', 'feeDisplay', 'appPass', 'submitConfirm', 'regErr'
+      'confirmRegForm', 'feeDisplay', 'appPass', 'submitConfirm', 'regErr',
+      'policyBox', 'policyFees', 'policyPenalties', 'policyContact', 'policyTerms'
     ])
 
     // SET APP PASSWORD
@@ -141,6 +142,22 @@ export default class RegistrationPage extends BasePage {
     this.fee = res.fee
 
     page.feeDisplay.textContent = Doc.formatCoinValue(res.fee / 1e8)
+
+    // Show the operator's signed policy document, if the DEX publishes one.
+    Doc.hide(page.policyBox)
+    res = await postJSON('/api/getpolicy', {
+      addr: addr,
+      cert: cert
+    })
+    if (res.ok) {
+      const policy = res.policy
+      page.policyFees.textContent = policy.fees
+      page.policyPenalties.textContent = policy.penalties
+      page.policyContact.textContent = policy.contact
+      page.policyTerms.textContent = policy.terms
+      Doc.show(page.policyBox)
+    }
+
     await this.changeForm(page.dexAddrForm, page.confirmRegForm)
   }
 
@@ -26,7 +26,9 @@ export default class WalletsPage extends BasePage {
       // Unlock wallet form
       'openForm',
       // Deposit
-      'deposit', 'depositName', 'depositAddress',
+      'deposit', 'depositName', 'depositAddress', 'depositLabel',
+      'newDepositAddr', 'depositErr', 'depositHistory', 'depositAddrList',
+      'depositAddrTmpl',
       // Withdraw
       'withdrawForm', 'withdrawLogo', 'withdrawName', 'withdrawAddr',
       'withdrawAmt', 'withdrawAvail', 'submitWithdraw', 'withdrawFee',
@@ -64,6 +66,8 @@ export default class WalletsPage extends BasePage {
     page.oneMarket.remove()
     page.markets.removeAttribute('id')
     page.markets.remove()
+    page.depositAddrTmpl.removeAttribute('id')
+    page.depositAddrTmpl.remove()
 
     // Methods to switch the item displayed on the right side, with a little
     // fade-in animation.
@@ -82,6 +86,9 @@ export default class WalletsPage extends BasePage {
     // Bind the withdraw form.
     forms.bind(page.withdrawForm, page.submitWithdraw, () => { this.withdraw() })
 
+    // Generate a new labeled deposit address.
+    bind(page.newDepositAddr, 'click', () => { this.newDepositAddress() })
+
     // Bind the row clicks, which shows the available markets for the asset.
     for (const rowInfo of Object.values(rowInfos)) {
       bind(rowInfo.tr, 'click', () => {
@@ -115,7 +122,8 @@ export default class WalletsPage extends BasePage {
     this.showMarkets(firstRow.assetID)
 
     this.notifiers = {
-      balance: note => { this.handleBalanceNote(note) }
+      balance: note => { this.handleBalanceNote(note) },
+      deposit: note => { this.handleDepositNote(note) }
     }
   }
 
@@ -203,7 +211,7 @@ export default class WalletsPage extends BasePage {
     this.animation = this.showBox(page.openForm, page.walletPass)
   }
 
-  /* Display a deposit address. */
+  /* Display a deposit address and the deposit history. */
   async showDeposit (assetID) {
     const page = this.page
     const box = page.deposit
@@ -214,9 +222,58 @@ export default class WalletsPage extends BasePage {
       return
     }
     await this.hideBox()
+    box.dataset.assetID = assetID
     page.depositName.textContent = asset.info.name
     page.depositAddress.textContent = wallet.address
-    this.animation = this.showBox(box, page.walletPass)
+    page.depositLabel.value = ''
+    Doc.hide(page.depositErr)
+    await this.showDepositHistory(assetID)
+    this.animation = this.showBox(box, page.depositLabel)
+  }
+
+  /* showDepositHistory lists the asset's deposit addresses and deposits. */
+  async showDepositHistory (assetID) {
+    const page = this.page
+    Doc.empty(page.depositAddrList)
+    Doc.hide(page.depositHistory)
+    var res = await postJSON('/api/depositaddresses', { assetID: assetID })
+    if (!app.checkResponse(res) || !res.addresses || res.addresses.length === 0) return
+    for (const addr of res.addresses) {
+      const row = page.depositAddrTmpl.cloneNode(true)
+      const tmpl = name => row.querySelector(`[data-tmpl="${name}"]`)
+      tmpl('label').textContent = addr.label || '(no label)'
+      tmpl('address').textContent = addr.address
+      for (const dep of addr.deposits) {
+        const div = document.createElement('div')
+        div.classList.add('grey')
+        const status = dep.confs > 0 ? `${dep.confs} confirmations` : 'unconfirmed'
+        div.textContent = `${(dep.value / 1e8).toFixed(8)} in ${dep.coin}, ${status}`
+        tmpl('deposits').appendChild(div)
+      }
+      page.depositAddrList.appendChild(row)
+    }
+    Doc.show(page.depositHistory)
+  }
+
+  /* newDepositAddress generates a labeled deposit address. */
+  async newDepositAddress () {
+    const page = this.page
+    Doc.hide(page.depositErr)
+    const assetID = parseInt(page.deposit.dataset.assetID)
+    app.loading(page.deposit)
+    var res = await postJSON('/api/newdepositaddress', {
+      assetID: assetID,
+      label: page.depositLabel.value
+    })
+    app.loaded()
+    if (!app.checkResponse(res)) {
+      page.depositErr.textContent = res.msg
+      Doc.show(page.depositErr)
+      return
+    }
+    page.depositAddress.textContent = res.address.address
+    page.depositLabel.value = ''
+    await this.showDepositHistory(assetID)
   }
 
   /* Show the form to withdraw funds. */
@@ -309,8 +366,20 @@ export default class WalletsPage extends BasePage {
     rowInfo.stateIcons.locked()
   }
 
+  /*
+   * handleDepositNote refreshes the deposit history if the deposit box is
+   * showing the asset's deposits.
+   */
+  handleDepositNote (note) {
+    const box = this.page.deposit
+    if (this.displayed !== box || parseInt(box.dataset.assetID) !== note.address.assetID) return
+    this.showDepositHistory(note.address.assetID)
+  }
+
   /* handleBalance handles notifications updating a wallet's balance. */
   handleBalanceNote (note) {
+    const wallet = app.user.assets[note.assetID].wallet
+    if (!wallet || wallet.name !== note.wallet) return
     const td = this.page.walletTable.querySelector(`[data-balance-target="${note.assetID}"]`)
     td.textContent = (note.balance.available / 1e8).toFixed(8)
   }
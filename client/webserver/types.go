@@ -5,6 +5,8 @@ package webserver
 
 import (
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/mm"
+	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 )
 
@@ -21,9 +23,25 @@ func simpleAck() *standardResponse {
 	}
 }
 
-// The loginForm is sent by the client to log in to a DEX.
+// The loginForm is sent by the client to log in to a DEX. Name optionally
+// names the new session. The default is the browser's User-Agent.
 type loginForm struct {
 	Pass encode.PassBytes `json:"pass"`
+	Name string           `json:"name"`
+}
+
+// restoreBackupForm is the backup to restore and the app password with which
+// it was exported.
+type restoreBackupForm struct {
+	Pass   encode.PassBytes `json:"pass"`
+	Backup dex.Bytes        `json:"backup"`
+}
+
+// restoreSeedForm is the application seed to restore, and the new app
+// password.
+type restoreSeedForm struct {
+	Pass encode.PassBytes `json:"pass"`
+	Seed encode.PassBytes `json:"seed"`
 }
 
 // registration is used to register a new DEX account.
@@ -32,11 +50,14 @@ type registration struct {
 	Cert     string           `json:"cert"`
 	Password encode.PassBytes `json:"pass"`
 	Fee      uint64           `json:"fee"`
+	Account  string           `json:"account"`
 }
 
 // newWalletForm is information necessary to create a new wallet.
 type newWalletForm struct {
 	AssetID uint32 `json:"assetID"`
+	// Name is required for each of the asset's wallets after the first.
+	Name string `json:"name"`
 	// These are only used if the Decred wallet does not already exist. In that
 	// case, these parameters will be used to create the wallet.
 	Account string           `json:"account"`
@@ -48,19 +69,40 @@ type newWalletForm struct {
 // openWalletForm is information necessary to open a wallet.
 type openWalletForm struct {
 	AssetID uint32           `json:"assetID"`
+	Name    string           `json:"name"` // Empty for the default wallet.
 	Pass    encode.PassBytes `json:"pass"` // Application password.
 }
 
+// devicePromptForm is the user's response to a hardware wallet prompt.
+type devicePromptForm struct {
+	ID       string           `json:"id"`
+	Response encode.PassBytes `json:"response"`
+}
+
 type tradeForm struct {
 	Pass  encode.PassBytes `json:"pw"`
 	Order *core.TradeForm  `json:"order"`
 }
 
+// startBotForm is sent to start a market maker bot.
+type startBotForm struct {
+	Pass   encode.PassBytes `json:"pw"`
+	Config *mm.BotConfig    `json:"config"`
+}
+
 type cancelForm struct {
 	Pass    encode.PassBytes `json:"pw"`
 	OrderID string           `json:"orderID"`
 }
 
+// cloneOrderForm is sent to re-place a completed or canceled order. A non-zero
+// Rate replaces the rate of a limit order.
+type cloneOrderForm struct {
+	Pass    encode.PassBytes `json:"pw"`
+	OrderID string           `json:"orderID"`
+	Rate    uint64           `json:"rate"`
+}
+
 // withdrawForm is sent to initiate a withdraw.
 type withdrawForm struct {
 	AssetID uint32           `json:"assetID"`
@@ -5,8 +5,7 @@ package webserver
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +23,7 @@ import (
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/slog"
@@ -45,6 +45,8 @@ const (
 	darkModeCK = "darkMode"
 	// authCK is the authorization token cookie key.
 	authCK = "dexauth"
+	// langCK is the cookie key for the language of the session.
+	langCK = "lang"
 	// ctxKeyUserInfo is used in the authorization middleware for saving user
 	// info in http request contexts.
 	ctxKeyUserInfo = contextKey("userinfo")
@@ -62,30 +64,89 @@ var (
 // clientCore is satisfied by core.Core.
 type clientCore interface {
 	Exchanges() map[string]*core.Exchange
+	DEXAccounts(dexAddr string) []string
 	Register(*core.RegisterForm) (*core.RegisterResult, error)
 	Login(pw []byte) (*core.LoginResult, error)
 	InitializeClient(pw []byte) error
 	Sync(dex string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error)
+	Book(dex string, base, quote uint32) (*core.OrderBook, error)
+	Candles(host string, base, quote uint32, binSize time.Duration, start, end uint64) ([]*core.Candle, error)
+	Depth(host string, base, quote uint32) (*core.DepthChart, error)
+	Spots(host string) (map[string]*msgjson.Spot, error)
 	AssetBalance(assetID uint32) (*db.Balance, error)
 	WalletState(assetID uint32) *core.WalletState
 	CreateWallet(appPW, walletPW []byte, form *core.WalletForm) error
-	OpenWallet(assetID uint32, pw []byte) error
+	OpenNamedWallet(assetID uint32, name string, pw []byte) error
 	CloseWallet(assetID uint32) error
 	ConnectWallet(assetID uint32) error
 	Wallets() []*core.WalletState
 	User() *core.User
+	Portfolio() *core.Portfolio
+	WalletHealth() []*core.WalletHealth
+	TradeHistory() (*core.TradeHistory, error)
 	GetFee(url, cert string) (uint64, error)
+	GetPolicy(url, cert string) (*core.Policy, error)
 	SupportedAssets() map[uint32]*core.SupportedAsset
 	Withdraw(pw []byte, assetID uint32, value uint64, address string) (asset.Coin, error)
 	Trade(pw []byte, form *core.TradeForm) (*core.Order, error)
 	Cancel(pw []byte, sid string) error
+	CloneOrder(pw []byte, orderID string, rate uint64) (*core.Order, error)
+	SetOrderNotes(orderID, notes string, tags []string) error
+	SetMatchNotes(orderID, matchID, notes string, tags []string) error
+	Orders(filter *core.OrderFilter) ([]*core.Order, error)
+	Order(orderID string) (*core.Order, error)
+	Matches(filter *core.OrderFilter) ([]*core.OrderMatch, error)
 	NotificationFeed() <-chan core.Notification
 	AckNotes([]dex.Bytes)
+	AddAlert(form *core.AlertForm) (*core.Alert, error)
+	Alerts() ([]*core.Alert, error)
+	NoteRoutes() map[string]*core.NoteRoute
+	SetNoteRoute(noteType string, route *core.NoteRoute) error
+	CoinPolicies() map[uint32]*core.CoinPolicy
+	SetCoinPolicy(assetID uint32, policy *core.CoinPolicy) error
+	FundingStrategies() map[uint32]asset.FundingStrategy
+	SetFundingStrategy(assetID uint32, strategy asset.FundingStrategy) error
+	WalletCoins(assetID uint32, walletName string) ([]*core.WalletCoin, error)
+	Reservations(assetID uint32) (*core.Reservations, error)
+	Proxies() map[string]*core.ProxyConfig
+	CancelOnDisconnect() map[string]uint32
+	SetCancelOnDisconnect(dexAddr string, epochs uint32) error
+	SwapConfs() map[uint32]uint32
+	SetSwapConfs(assetID uint32, confs uint32) error
+	AutoRefund() bool
+	SetAutoRefund(enabled bool) error
+	LotSizePolicy() core.LotSizePolicy
+	SetLotSizePolicy(policy core.LotSizePolicy) error
+	TradeGuard() core.TradeGuard
+	SetTradeGuard(guard core.TradeGuard) error
+	NewDepositAddress(assetID uint32, label string) (*core.DepositAddress, error)
+	DepositAddresses(assetID uint32) []*core.DepositAddress
+	SetDepositLabel(assetID uint32, address, label string) error
+	SetProxy(dexAddr string, proxy *core.ProxyConfig) error
+	OrderFunding(form *core.TradeForm) (*core.OrderFunding, error)
+	PreOrder(form *core.TradeForm) (*core.OrderEstimate, error)
+	RemoveAlert(id string) error
+	DevicePrompts() []*core.DevicePrompt
+	RespondDevicePrompt(id string, response []byte) error
+	ExportBackup(pw []byte) ([]byte, error)
+	RestoreBackup(pw, archive []byte) error
+	ExportSeed(pw []byte) (string, error)
+	RestoreFromSeed(pw []byte, seed string) error
 	Logout() error
+	LoggedIn() bool
 }
 
 var _ clientCore = (*core.Core)(nil)
 
+// marketMaker is satisfied by mm.MarketMaker.
+type marketMaker interface {
+	Start(pw []byte, cfg *mm.BotConfig) (uint64, error)
+	Stop(id uint64) error
+	Bots() []*mm.BotStatus
+}
+
+var _ marketMaker = (*mm.MarketMaker)(nil)
+
 // marketSyncer is used to synchronize market subscriptions. The marketSyncer
 // manages a map of clients who are subscribed to the market, and distributes
 // order book updates when received.
@@ -111,7 +172,17 @@ out:
 	for {
 		select {
 		case update := <-m.feed.C:
-			note, err := msgjson.NewNotification(update.Action, update)
+			var payload interface{} = update
+			// A fresh order book is sent like the response to 'loadmarket'.
+			if mktBook, ok := update.Payload.(*core.MarketOrderBook); ok && update.Action == core.FreshBookAction {
+				payload = &marketResponse{
+					Host:  update.Host,
+					Book:  mktBook.Book,
+					Base:  mktBook.Base,
+					Quote: mktBook.Quote,
+				}
+			}
+			note, err := msgjson.NewNotification(update.Action, payload)
 			if err != nil {
 				log.Errorf("error encoding notification message: %v", err)
 				break out
@@ -130,20 +201,31 @@ out:
 // WebServer is a single-client http and websocket server enabling a browser
 // interface to the DEX client.
 type WebServer struct {
-	ctx            context.Context
-	core           clientCore
-	addr           string
-	srv            *http.Server
-	html           *templates
-	indent         bool
-	mtx            sync.RWMutex
-	validAuthToken string
-	syncers        map[string]*marketSyncer
-	clients        map[int32]*wsClient
+	ctx      context.Context
+	core     clientCore
+	mm       marketMaker
+	addr     string
+	srv      *http.Server
+	html     *templates
+	indent   bool
+	mtx      sync.RWMutex
+	sessions map[[sha256.Size]byte]*session
+	revoked  []*revokedSession
+	syncers  map[string]*marketSyncer
+	clients  map[int32]*wsClient
+	apiKeys  [][sha256.Size]byte
+	readOnly bool
+	notesMtx sync.RWMutex
+	notes    []core.Notification
 }
 
-// New is the constructor for a new WebServer.
-func New(core clientCore, addr string, logger slog.Logger, reloadHTML bool) (*WebServer, error) {
+// New is the constructor for a new WebServer. The marketMaker is optional. If
+// it is nil, the market maker bot API requests return an error. The versioned
+// JSON API accepts the provided apiKeys and any API tokens created by a
+// logged in user. In readOnly mode, API requests that are not GET requests are
+// refused until the user logs in, so that the markets, order books and order
+// history may be monitored without the app password.
+func New(core clientCore, mm marketMaker, addr string, logger slog.Logger, reloadHTML bool, apiKeys []string, readOnly bool) (*WebServer, error) {
 	log = logger
 
 	folderExists := func(fp string) bool {
@@ -186,12 +268,16 @@ func New(core clientCore, addr string, logger slog.Logger, reloadHTML bool) (*We
 
 	// Make the server here so its methods can be registered.
 	s := &WebServer{
-		core:    core,
-		srv:     httpServer,
-		addr:    addr,
-		html:    tmpl,
-		syncers: make(map[string]*marketSyncer),
-		clients: make(map[int32]*wsClient),
+		core:     core,
+		mm:       mm,
+		srv:      httpServer,
+		addr:     addr,
+		html:     tmpl,
+		syncers:  make(map[string]*marketSyncer),
+		clients:  make(map[int32]*wsClient),
+		sessions: make(map[[sha256.Size]byte]*session),
+		apiKeys:  apiKeyHashes(apiKeys),
+		readOnly: readOnly,
 	}
 
 	// Middleware
@@ -236,20 +322,77 @@ func New(core clientCore, addr string, logger slog.Logger, reloadHTML bool) (*We
 	// api endpoints
 	mux.Route("/api", func(r chi.Router) {
 		r.Use(middleware.AllowContentType("application/json"))
+		r.Use(s.readOnlyMiddleware)
 		r.Post("/getfee", s.apiGetFee)
+		r.Post("/getpolicy", s.apiGetPolicy)
 		r.Post("/newwallet", s.apiNewWallet)
 		r.Post("/openwallet", s.apiOpenWallet)
 		r.Post("/closewallet", s.apiCloseWallet)
 		r.Post("/register", s.apiRegister)
 		r.Post("/init", s.apiInit)
 		r.Post("/login", s.apiLogin)
+		r.Post("/exportbackup", s.apiExportBackup)
+		r.Post("/restorebackup", s.apiRestoreBackup)
+		r.Post("/appseed", s.apiAppSeed)
+		r.Post("/restoreseed", s.apiRestoreSeed)
 		r.Post("/withdraw", s.apiWithdraw)
 		r.Get("/user", s.apiUser)
+		r.Get("/portfolio", s.apiPortfolio)
+		r.Get("/wallethealth", s.apiWalletHealth)
+		r.Get("/candles", s.apiCandles)
+		r.Get("/depth", s.apiDepth)
+		r.Get("/spots", s.apiSpots)
+		r.Get("/exporttrades", s.apiExportTrades)
 		r.Post("/connectwallet", s.apiConnectWallet)
 		r.Post("/trade", s.apiTrade)
 		r.Post("/cancel", s.apiCancel)
+		r.Post("/cloneorder", s.apiCloneOrder)
+		r.Post("/setnotes", s.apiSetNotes)
 		r.Post("/logout", s.apiLogout)
+		r.Post("/startbot", s.apiStartBot)
+		r.Post("/stopbot", s.apiStopBot)
+		r.Get("/bots", s.apiBots)
+		r.Post("/addalert", s.apiAddAlert)
+		r.Get("/alerts", s.apiAlerts)
+		r.Get("/noteroutes", s.apiNoteRoutes)
+		r.Post("/setnoteroute", s.apiSetNoteRoute)
+		r.Get("/coinpolicies", s.apiCoinPolicies)
+		r.Post("/setcoinpolicy", s.apiSetCoinPolicy)
+		r.Get("/fundingstrategies", s.apiFundingStrategies)
+		r.Post("/setfundingstrategy", s.apiSetFundingStrategy)
+		r.Post("/walletcoins", s.apiWalletCoins)
+		r.Post("/reservations", s.apiReservations)
+		r.Post("/newdepositaddress", s.apiNewDepositAddress)
+		r.Post("/depositaddresses", s.apiDepositAddresses)
+		r.Post("/setdepositlabel", s.apiSetDepositLabel)
+		r.Get("/proxies", s.apiProxies)
+		r.Post("/setproxy", s.apiSetProxy)
+		r.Get("/cancelondisconnect", s.apiCancelOnDisconnect)
+		r.Post("/setcancelondisconnect", s.apiSetCancelOnDisconnect)
+		r.Get("/swapconfs", s.apiSwapConfs)
+		r.Post("/setswapconfs", s.apiSetSwapConfs)
+		r.Get("/autorefund", s.apiAutoRefund)
+		r.Post("/setautorefund", s.apiSetAutoRefund)
+		r.Get("/lotsizepolicy", s.apiLotSizePolicy)
+		r.Post("/setlotsizepolicy", s.apiSetLotSizePolicy)
+		r.Get("/tradeguard", s.apiTradeGuard)
+		r.Post("/settradeguard", s.apiSetTradeGuard)
+		r.Post("/orderfunding", s.apiOrderFunding)
+		r.Post("/preorder", s.apiPreOrder)
+		r.Post("/removealert", s.apiRemoveAlert)
+		r.Get("/deviceprompts", s.apiDevicePrompts)
+		r.Post("/deviceprompt", s.apiDevicePrompt)
 		r.Post("/balance", s.apiGetBalance)
+		r.Get("/locales", s.apiLocales)
+
+		r.Group(func(apiAuth chi.Router) {
+			apiAuth.Use(s.requireAuth)
+			apiAuth.Get("/sessions", s.apiSessions)
+			apiAuth.Post("/revokesession", s.apiRevokeSession)
+			apiAuth.Post("/newapitoken", s.apiNewAPIToken)
+		})
+
+		s.registerAPIV1(r)
 	})
 
 	// Files
@@ -310,39 +453,6 @@ func (s *WebServer) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 	return &wg, nil
 }
 
-// authorize creates, stores, and returns a new auth token to identify the
-// user. Only one token can be active at a time = only 1 authorized device
-// at a time. `WebServer.validAuthToken` is set to the newly created token,
-// effectively deauthorizing previously authenticated devices.
-func (s *WebServer) authorize() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	token := hex.EncodeToString(b)
-	s.mtx.Lock()
-	s.validAuthToken = token
-	s.mtx.Unlock()
-	return token
-}
-
-// isAuthed checks if the incoming request is from an authorized user/device.
-// Requires the auth token cookie to be set in the request and for the token
-// to match `WebServer.validAuthToken`.
-func (s *WebServer) isAuthed(r *http.Request) bool {
-	var authToken string
-	cookie, err := r.Cookie(authCK)
-	switch err {
-	case nil:
-		authToken = cookie.Value
-	case http.ErrNoCookie:
-	default:
-		log.Errorf("authToken retrieval error: %v", err)
-	}
-
-	s.mtx.RLock()
-	defer s.mtx.RUnlock()
-	return authToken != "" && authToken == s.validAuthToken
-}
-
 // readNotifications reads from the Core notification channel and relays to
 // websocket clients.
 func (s *WebServer) readNotifications(ctx context.Context) {
@@ -350,7 +460,8 @@ func (s *WebServer) readNotifications(ctx context.Context) {
 	for {
 		select {
 		case n := <-ch:
-			s.notify(notifyRoute, n)
+			s.notifyNote(n)
+			s.storeNote(n)
 			// log.Trace("%s: %s: %s", n.Severity(), n.Subject(), n.Details())
 		case <-ctx.Done():
 			return
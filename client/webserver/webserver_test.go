@@ -3,13 +3,16 @@
 package webserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -17,6 +20,7 @@ import (
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
 	"decred.org/dcrdex/client/db"
+	"decred.org/dcrdex/client/mm"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
@@ -57,6 +61,7 @@ func (c *tCoin) Redeem() dex.Bytes {
 }
 
 type TCore struct {
+	loggedIn        bool
 	balanceErr      error
 	syncBook        *core.OrderBook
 	syncFeed        *core.BookFeed
@@ -66,6 +71,7 @@ type TCore struct {
 	logoutErr       error
 	initErr         error
 	getFeeErr       error
+	getPolicyErr    error
 	createWalletErr error
 	openWalletErr   error
 	closeWalletErr  error
@@ -73,9 +79,58 @@ type TCore struct {
 	notHas          bool
 	notRunning      bool
 	notOpen         bool
+	portfolio       *core.Portfolio
+	walletHealth    []*core.WalletHealth
+	tradeHistory    *core.TradeHistory
+	tradeHistoryErr error
+	alerts          []*core.Alert
+	alertErr        error
+	noteRoutes      map[string]*core.NoteRoute
+	routeErr        error
+	coinPolicies    map[uint32]*core.CoinPolicy
+	coinPolicyErr   error
+	reservations    *core.Reservations
+	funding         *core.OrderFunding
+	reserveErr      error
+	estimate        *core.OrderEstimate
+	proxies         map[string]*core.ProxyConfig
+	proxyErr        error
+	cancelEpochs    map[string]uint32
+	codErr          error
+	swapConfs       map[uint32]uint32
+	autoRefund      bool
+	depositAddrs    []*core.DepositAddress
+	depositErr      error
+	autoRefundErr   error
+	lotPolicy       core.LotSizePolicy
+	lotPolicyErr    error
+	tradeErr        error
+	tradeGuardErr   error
+	swapConfErr     error
+	prompts         []*core.DevicePrompt
+	promptErr       error
+	backup          []byte
+	backupErr       error
+	seed            string
+	seedErr         error
+	orders          []*core.Order
+	ordersErr       error
+	orderFilter     *core.OrderFilter
+	candles         []*core.Candle
+	candlesErr      error
+	candleBin       time.Duration
+	depth           *core.DepthChart
+	depthErr        error
+	cloneID         string
+	cloneRate       uint64
+	cloneErr        error
+	notesMatchID    string
+	notesTags       []string
+	notesErr        error
 }
 
 func (c *TCore) Exchanges() map[string]*core.Exchange                        { return nil }
+func (c *TCore) DEXAccounts(dexAddr string) []string                         { return []string{dexAddr} }
 func (c *TCore) GetFee(string, string) (uint64, error)                       { return 1e8, c.getFeeErr }
 func (c *TCore) Register(r *core.RegisterForm) (*core.RegisterResult, error) { return nil, c.regErr }
 func (c *TCore) InitializeClient(pw []byte) error                            { return c.initErr }
@@ -83,10 +138,21 @@ func (c *TCore) Login(pw []byte) (*core.LoginResult, error)                  { r
 func (c *TCore) Sync(dex string, base, quote uint32) (*core.OrderBook, *core.BookFeed, error) {
 	return c.syncBook, c.syncFeed, c.syncErr
 }
+func (c *TCore) GetPolicy(string, string) (*core.Policy, error) {
+	return &core.Policy{Contact: "ops"}, c.getPolicyErr
+}
 func (c *TCore) Book(dex string, base, quote uint32) (*core.OrderBook, error) {
 	return &core.OrderBook{}, nil
 }
 func (c *TCore) AssetBalance(assetID uint32) (*db.Balance, error) { return nil, c.balanceErr }
+func (c *TCore) Candles(host string, base, quote uint32, binSize time.Duration, start, end uint64) ([]*core.Candle, error) {
+	c.candleBin = binSize
+	return c.candles, c.candlesErr
+}
+func (c *TCore) Depth(host string, base, quote uint32) (*core.DepthChart, error) {
+	return c.depth, c.depthErr
+}
+func (c *TCore) Spots(host string) (map[string]*msgjson.Spot, error) { return nil, nil }
 func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 	if c.notHas {
 		return nil
@@ -98,14 +164,19 @@ func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 		Running: !c.notRunning,
 	}
 }
+func (c *TCore) OpenNamedWallet(assetID uint32, name string, pw []byte) error {
+	return c.openWalletErr
+}
 func (c *TCore) CreateWallet(appPW, walletPW []byte, form *core.WalletForm) error {
 	return c.createWalletErr
 }
-func (c *TCore) OpenWallet(assetID uint32, pw []byte) error { return c.openWalletErr }
-func (c *TCore) CloseWallet(assetID uint32) error           { return c.closeWalletErr }
-func (c *TCore) ConnectWallet(assetID uint32) error         { return nil }
-func (c *TCore) Wallets() []*core.WalletState               { return nil }
-func (c *TCore) User() *core.User                           { return nil }
+func (c *TCore) CloseWallet(assetID uint32) error          { return c.closeWalletErr }
+func (c *TCore) ConnectWallet(assetID uint32) error        { return nil }
+func (c *TCore) Wallets() []*core.WalletState              { return nil }
+func (c *TCore) User() *core.User                          { return nil }
+func (c *TCore) Portfolio() *core.Portfolio                { return c.portfolio }
+func (c *TCore) WalletHealth() []*core.WalletHealth        { return c.walletHealth }
+func (c *TCore) TradeHistory() (*core.TradeHistory, error) { return c.tradeHistory, c.tradeHistoryErr }
 func (c *TCore) SupportedAssets() map[uint32]*core.SupportedAsset {
 	return make(map[uint32]*core.SupportedAsset)
 }
@@ -113,6 +184,9 @@ func (c *TCore) Withdraw(pw []byte, assetID uint32, value uint64, address string
 	return &tCoin{id: []byte{0xde, 0xc7, 0xed}}, c.withdrawErr
 }
 func (c *TCore) Trade(pw []byte, form *core.TradeForm) (*core.Order, error) {
+	if c.tradeErr != nil {
+		return nil, c.tradeErr
+	}
 	oType := order.LimitOrderType
 	if !form.IsLimit {
 		oType = order.MarketOrderType
@@ -128,11 +202,158 @@ func (c *TCore) Trade(pw []byte, form *core.TradeForm) (*core.Order, error) {
 
 func (c *TCore) Cancel(pw []byte, sid string) error { return nil }
 
+func (c *TCore) CloneOrder(pw []byte, orderID string, rate uint64) (*core.Order, error) {
+	c.cloneID, c.cloneRate = orderID, rate
+	return nil, c.cloneErr
+}
+
+func (c *TCore) SetOrderNotes(orderID, notes string, tags []string) error {
+	c.notesMatchID, c.notesTags = "", tags
+	return c.notesErr
+}
+
+func (c *TCore) SetMatchNotes(orderID, matchID, notes string, tags []string) error {
+	c.notesMatchID, c.notesTags = matchID, tags
+	return c.notesErr
+}
+
+func (c *TCore) Orders(filter *core.OrderFilter) ([]*core.Order, error) {
+	c.orderFilter = filter
+	return c.orders, c.ordersErr
+}
+
+func (c *TCore) Order(orderID string) (*core.Order, error) {
+	if len(c.orders) == 0 {
+		return nil, c.ordersErr
+	}
+	return c.orders[0], c.ordersErr
+}
+
+func (c *TCore) Matches(filter *core.OrderFilter) ([]*core.OrderMatch, error) {
+	c.orderFilter = filter
+	return nil, c.ordersErr
+}
+
 func (c *TCore) NotificationFeed() <-chan core.Notification { return make(chan core.Notification, 1) }
 
 func (c *TCore) AckNotes(ids []dex.Bytes) {}
 
-func (c *TCore) Logout() error { return c.logoutErr }
+func (c *TCore) Logout() error  { return c.logoutErr }
+func (c *TCore) LoggedIn() bool { return c.loggedIn }
+
+func (c *TCore) AddAlert(form *core.AlertForm) (*core.Alert, error) {
+	if c.alertErr != nil {
+		return nil, c.alertErr
+	}
+	return &core.Alert{AlertForm: *form, ID: "abcd"}, nil
+}
+
+func (c *TCore) Alerts() ([]*core.Alert, error) { return c.alerts, c.alertErr }
+
+func (c *TCore) RemoveAlert(id string) error { return c.alertErr }
+
+func (c *TCore) NoteRoutes() map[string]*core.NoteRoute { return c.noteRoutes }
+
+func (c *TCore) SetNoteRoute(noteType string, route *core.NoteRoute) error { return c.routeErr }
+
+func (c *TCore) CoinPolicies() map[uint32]*core.CoinPolicy { return c.coinPolicies }
+
+func (c *TCore) SetCoinPolicy(assetID uint32, policy *core.CoinPolicy) error {
+	return c.coinPolicyErr
+}
+
+func (c *TCore) FundingStrategies() map[uint32]asset.FundingStrategy { return nil }
+
+func (c *TCore) SetFundingStrategy(assetID uint32, strategy asset.FundingStrategy) error {
+	return nil
+}
+
+func (c *TCore) WalletCoins(assetID uint32, walletName string) ([]*core.WalletCoin, error) {
+	return nil, nil
+}
+
+func (c *TCore) Proxies() map[string]*core.ProxyConfig { return c.proxies }
+
+func (c *TCore) SetProxy(dexAddr string, proxy *core.ProxyConfig) error {
+	return c.proxyErr
+}
+
+func (c *TCore) CancelOnDisconnect() map[string]uint32 { return c.cancelEpochs }
+
+func (c *TCore) SetCancelOnDisconnect(dexAddr string, epochs uint32) error {
+	return c.codErr
+}
+
+func (c *TCore) SwapConfs() map[uint32]uint32 { return c.swapConfs }
+
+func (c *TCore) SetSwapConfs(assetID uint32, confs uint32) error {
+	return c.swapConfErr
+}
+
+func (c *TCore) AutoRefund() bool { return c.autoRefund }
+
+func (c *TCore) SetAutoRefund(enabled bool) error {
+	return c.autoRefundErr
+}
+
+func (c *TCore) LotSizePolicy() core.LotSizePolicy { return c.lotPolicy }
+
+func (c *TCore) SetLotSizePolicy(policy core.LotSizePolicy) error {
+	return c.lotPolicyErr
+}
+
+func (c *TCore) TradeGuard() core.TradeGuard { return core.TradeGuard{WarnDeviation: 10} }
+
+func (c *TCore) SetTradeGuard(guard core.TradeGuard) error {
+	return c.tradeGuardErr
+}
+
+func (c *TCore) NewDepositAddress(assetID uint32, label string) (*core.DepositAddress, error) {
+	if c.depositErr != nil {
+		return nil, c.depositErr
+	}
+	return &core.DepositAddress{AssetID: assetID, Address: "addr", Label: label}, nil
+}
+
+func (c *TCore) DepositAddresses(assetID uint32) []*core.DepositAddress { return c.depositAddrs }
+
+func (c *TCore) SetDepositLabel(assetID uint32, address, label string) error {
+	return c.depositErr
+}
+
+func (c *TCore) Reservations(assetID uint32) (*core.Reservations, error) {
+	return c.reservations, c.reserveErr
+}
+
+func (c *TCore) OrderFunding(form *core.TradeForm) (*core.OrderFunding, error) {
+	return c.funding, c.reserveErr
+}
+
+func (c *TCore) PreOrder(form *core.TradeForm) (*core.OrderEstimate, error) {
+	return c.estimate, c.reserveErr
+}
+
+func (c *TCore) DevicePrompts() []*core.DevicePrompt { return c.prompts }
+
+func (c *TCore) RespondDevicePrompt(id string, response []byte) error { return c.promptErr }
+
+func (c *TCore) ExportBackup(pw []byte) ([]byte, error) { return c.backup, c.backupErr }
+
+func (c *TCore) RestoreBackup(pw, archive []byte) error { return c.backupErr }
+func (c *TCore) ExportSeed(pw []byte) (string, error)   { return c.seed, c.seedErr }
+func (c *TCore) RestoreFromSeed(pw []byte, seed string) error {
+	return c.seedErr
+}
+
+type TMarketMaker struct {
+	startErr error
+	stopErr  error
+	bots     []*mm.BotStatus
+}
+
+func (m *TMarketMaker) Start(pw []byte, cfg *mm.BotConfig) (uint64, error) { return 1, m.startErr }
+func (m *TMarketMaker) Stop(id uint64) error                               { return m.stopErr }
+func (m *TMarketMaker) Bots() []*mm.BotStatus                              { return m.bots }
 
 type TWriter struct {
 	b []byte
@@ -256,7 +477,7 @@ func newTServer(t *testing.T, start bool) (*WebServer, *TCore, func(), error) {
 	c := &TCore{}
 	var shutdown func()
 	ctx, killCtx := context.WithCancel(tCtx)
-	s, err := New(c, fmt.Sprintf("localhost:%d", tPort), tLogger, false)
+	s, err := New(c, nil, fmt.Sprintf("localhost:%d", tPort), tLogger, false, nil, false)
 	if err != nil {
 		t.Errorf("error creating server: %v", err)
 	}
@@ -326,7 +547,7 @@ func TestConnectBindError(t *testing.T) {
 	_, _, shutdown, _ := newTServer(t, true)
 	defer shutdown()
 
-	s, err := New(&TCore{}, fmt.Sprintf("localhost:%d", tPort), tLogger, false)
+	s, err := New(&TCore{}, nil, fmt.Sprintf("localhost:%d", tPort), tLogger, false, nil, false)
 	if err != nil {
 		t.Fatalf("error creating server: %v", err)
 	}
@@ -545,6 +766,96 @@ func TestAPIInit(t *testing.T) {
 	tCore.initErr = nil
 }
 
+func TestAPIExportBackup(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+	tCore.backup = []byte{0x01, 0x02, 0x03}
+
+	body := &loginForm{
+		Pass: encode.PassBytes("def"),
+	}
+	reader.msg, _ = json.Marshal(body)
+	req, _ := http.NewRequest("POST", "/", reader)
+	s.apiExportBackup(writer, req)
+	if !bytes.Equal(writer.b, tCore.backup) {
+		t.Fatalf("wrong backup %x", writer.b)
+	}
+
+	tCore.backupErr = tErr
+	ensureResponse(t, s, s.apiExportBackup, `{"ok":false,"msg":"backup error: test error"}`, reader, writer, body)
+}
+
+func TestAPIRestoreBackup(t *testing.T) {
+	writer := new(TWriter)
+	var body interface{}
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	ensure := func(want string) {
+		ensureResponse(t, s, s.apiRestoreBackup, want, reader, writer, body)
+	}
+
+	body = &restoreBackupForm{
+		Pass:   encode.PassBytes("def"),
+		Backup: dex.Bytes{0x01, 0x02, 0x03},
+	}
+	ensure(`{"ok":true,"notes":null}`)
+
+	// Restore error
+	tCore.backupErr = tErr
+	ensure(`{"ok":false,"msg":"restore error: test error"}`)
+	tCore.backupErr = nil
+}
+
+func TestAPIAppSeed(t *testing.T) {
+	writer := new(TWriter)
+	var body interface{}
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	ensure := func(want string) {
+		ensureResponse(t, s, s.apiAppSeed, want, reader, writer, body)
+	}
+
+	tCore.seed = "topmost Istanbul Pluto"
+	body = &loginForm{
+		Pass: encode.PassBytes("def"),
+	}
+	ensure(`{"ok":true,"seed":"topmost Istanbul Pluto"}`)
+
+	// Seed error
+	tCore.seedErr = tErr
+	ensure(`{"ok":false,"msg":"seed error: test error"}`)
+	tCore.seedErr = nil
+}
+
+func TestAPIRestoreSeed(t *testing.T) {
+	writer := new(TWriter)
+	var body interface{}
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	ensure := func(want string) {
+		ensureResponse(t, s, s.apiRestoreSeed, want, reader, writer, body)
+	}
+
+	body = &restoreSeedForm{
+		Pass: encode.PassBytes("def"),
+		Seed: encode.PassBytes("topmost Istanbul Pluto"),
+	}
+	ensure(`{"ok":true,"notes":null}`)
+
+	// Restore error
+	tCore.seedErr = tErr
+	ensure(`{"ok":false,"msg":"restore error: test error"}`)
+	tCore.seedErr = nil
+}
+
 func TestAPIGetFee(t *testing.T) {
 	writer := new(TWriter)
 	var body interface{}
@@ -565,6 +876,26 @@ func TestAPIGetFee(t *testing.T) {
 	tCore.getFeeErr = nil
 }
 
+func TestAPIGetPolicy(t *testing.T) {
+	writer := new(TWriter)
+	var body interface{}
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	ensure := func(want string) {
+		ensureResponse(t, s, s.apiGetPolicy, want, reader, writer, body)
+	}
+
+	body = &registration{Addr: "somedexaddress.org"}
+	ensure(`{"ok":true,"policy":{"host":"","pubkey":"","published":"0001-01-01T00:00:00Z","fees":"","penalties":"","contact":"ops","terms":""}}`)
+
+	// getPolicy error
+	tCore.getPolicyErr = tErr
+	ensure(`{"ok":false,"msg":"test error"}`)
+	tCore.getPolicyErr = nil
+}
+
 func TestAPINewWallet(t *testing.T) {
 	writer := new(TWriter)
 	var body interface{}
@@ -585,6 +916,11 @@ func TestAPINewWallet(t *testing.T) {
 
 	tCore.notHas = false
 	ensure(`{"ok":false,"msg":"already have a wallet for btc"}`)
+
+	// Another wallet for the asset can be created with a name.
+	body.(*newWalletForm).Name = "spv"
+	ensure(`{"ok":true}`)
+	body.(*newWalletForm).Name = ""
 	tCore.notHas = true
 
 	tCore.createWalletErr = tErr
@@ -643,7 +979,7 @@ func TestClientMap(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		s.websocketHandler(conn, "someip")
+		s.websocketHandler(conn, "someip", "")
 		wg.Done()
 	}()
 
@@ -689,6 +1025,553 @@ func TestAPILogout(t *testing.T) {
 	tCore.logoutErr = nil
 }
 
+func TestReadOnlyMiddleware(t *testing.T) {
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+	s.readOnly = true
+
+	var called bool
+	handler := s.readOnlyMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	ensure := func(method, path string, wantCalled bool) {
+		t.Helper()
+		called = false
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, path, nil)
+		handler.ServeHTTP(w, r)
+		if called != wantCalled {
+			t.Fatalf("%s %s: wanted called = %t, got %t", method, path, wantCalled, called)
+		}
+		if !wantCalled && w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: wanted code %d, got %d", method, path, http.StatusForbidden, w.Code)
+		}
+	}
+
+	// Before login, only GET requests and login are allowed.
+	ensure(http.MethodGet, "/api/v1/orders", true)
+	ensure(http.MethodPost, "/api/login", true)
+	ensure(http.MethodPost, "/api/trade", false)
+	ensure(http.MethodPost, "/api/v1/orders", false)
+
+	// After login, everything is allowed.
+	tCore.loggedIn = true
+	ensure(http.MethodPost, "/api/trade", true)
+
+	// Without read-only mode, everything is allowed.
+	tCore.loggedIn = false
+	s.readOnly = false
+	ensure(http.MethodPost, "/api/trade", true)
+}
+
+func TestAPIPortfolio(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.portfolio = &core.Portfolio{
+		Assets: []*core.AssetPortfolio{{
+			AssetID:   42,
+			Symbol:    "dcr",
+			Available: 5,
+			Total:     5,
+		}},
+	}
+	ensureResponse(t, s, s.apiPortfolio, `{"ok":true,"portfolio":{"assets":[{"assetID":42,"symbol":"dcr","available":5,"immature":0,"locked":0,"orderlocked":0,"swaplocked":0,"total":5}]}}`, reader, writer, nil)
+}
+
+func TestAPIWalletHealth(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.walletHealth = []*core.WalletHealth{{
+		AssetID: 42,
+		Symbol:  "dcr",
+		Running: true,
+		Errors:  []*core.WalletError{},
+	}}
+	ensureResponse(t, s, s.apiWalletHealth, `{"ok":true,"wallets":[{"assetID":42,"symbol":"dcr","name":"","running":true,"stamp":"0001-01-01T00:00:00Z","errors":[]}]}`, reader, writer, nil)
+}
+
+func TestAPIBots(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, _, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	startBody := &startBotForm{
+		Pass:   encode.PassBytes("abc"),
+		Config: &mm.BotConfig{Strategy: mm.SpreadStrategy},
+	}
+	stopBody := &struct {
+		ID uint64 `json:"id"`
+	}{ID: 1}
+	noMM := `{"ok":false,"msg":"market maker is not available"}`
+	ensureResponse(t, s, s.apiStartBot, noMM, reader, writer, startBody)
+	ensureResponse(t, s, s.apiStopBot, noMM, reader, writer, stopBody)
+	ensureResponse(t, s, s.apiBots, noMM, reader, writer, nil)
+
+	tMM := &TMarketMaker{bots: []*mm.BotStatus{{ID: 1, Orders: []string{}}}}
+	s.mm = tMM
+	ensureResponse(t, s, s.apiStartBot, `{"ok":true,"id":1}`, reader, writer, startBody)
+	ensureResponse(t, s, s.apiStopBot, `{"ok":true}`, reader, writer, stopBody)
+	ensureResponse(t, s, s.apiBots, `{"ok":true,"bots":[{"id":1,"config":null,"position":0,"basecommitted":0,"quotecommitted":0,"orders":[]}]}`, reader, writer, nil)
+
+	tMM.startErr = tErr
+	ensureResponse(t, s, s.apiStartBot, `{"ok":false,"msg":"error starting bot: test error"}`, reader, writer, startBody)
+	tMM.stopErr = tErr
+	ensureResponse(t, s, s.apiStopBot, `{"ok":false,"msg":"error stopping bot 1: test error"}`, reader, writer, stopBody)
+}
+
+func TestAPIAlerts(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	addBody := &core.AlertForm{
+		Kind:    core.AlertSwap,
+		Minutes: 30,
+	}
+	removeBody := &struct {
+		ID string `json:"id"`
+	}{ID: "abcd"}
+	ensureResponse(t, s, s.apiAddAlert, `{"ok":true,"alert":{"kind":"swap","host":"","base":0,"quote":0,"rate":0,"above":false,"orderID":"","minutes":30,"webhook":"","desktop":false,"id":"abcd","stamp":0}}`, reader, writer, addBody)
+	tCore.alerts = []*core.Alert{}
+	ensureResponse(t, s, s.apiAlerts, `{"ok":true,"alerts":[]}`, reader, writer, nil)
+	ensureResponse(t, s, s.apiRemoveAlert, `{"ok":true}`, reader, writer, removeBody)
+
+	tCore.alertErr = tErr
+	ensureResponse(t, s, s.apiAddAlert, `{"ok":false,"msg":"error adding alert: test error"}`, reader, writer, addBody)
+	ensureResponse(t, s, s.apiAlerts, `{"ok":false,"msg":"error retrieving alerts: test error"}`, reader, writer, nil)
+	ensureResponse(t, s, s.apiRemoveAlert, `{"ok":false,"msg":"error removing alert abcd: test error"}`, reader, writer, removeBody)
+}
+
+func TestAPINoteRoutes(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.noteRoutes = map[string]*core.NoteRoute{
+		core.NoteTypeOrder: {Severity: db.WarningLevel, Desktop: true},
+	}
+	ensureResponse(t, s, s.apiNoteRoutes, `{"ok":true,"routes":{"order":{"severity":4,"logonly":false,"desktop":true,"webhook":""}}}`, reader, writer, nil)
+
+	setBody := &struct {
+		Type  string          `json:"type"`
+		Route *core.NoteRoute `json:"route"`
+	}{
+		Type:  core.NoteTypeBalance,
+		Route: &core.NoteRoute{LogOnly: true},
+	}
+	ensureResponse(t, s, s.apiSetNoteRoute, `{"ok":true}`, reader, writer, setBody)
+	tCore.routeErr = tErr
+	ensureResponse(t, s, s.apiSetNoteRoute, `{"ok":false,"msg":"error setting balance notification route: test error"}`, reader, writer, setBody)
+}
+
+func TestAPICoinPolicies(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.coinPolicies = map[uint32]*core.CoinPolicy{
+		42: {MaxFeeRate: 20, SplitLots: 2, SplitCoins: 3},
+	}
+	ensureResponse(t, s, s.apiCoinPolicies, `{"ok":true,"policies":{"42":{"maxFeeRate":20,"dustThreshold":0,"minConsolidate":0,"maxInputs":0,"splitLots":2,"splitCoins":3}}}`, reader, writer, nil)
+
+	setBody := &struct {
+		AssetID uint32           `json:"assetID"`
+		Policy  *core.CoinPolicy `json:"policy"`
+	}{
+		AssetID: 42,
+		Policy:  &core.CoinPolicy{MaxFeeRate: 20, DustThreshold: 1e5, MinConsolidate: 5},
+	}
+	ensureResponse(t, s, s.apiSetCoinPolicy, `{"ok":true}`, reader, writer, setBody)
+	tCore.coinPolicyErr = tErr
+	ensureResponse(t, s, s.apiSetCoinPolicy, `{"ok":false,"msg":"error setting coin policy: test error"}`, reader, writer, setBody)
+}
+
+func TestAPIProxies(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.proxies = map[string]*core.ProxyConfig{
+		"abc.onion": {Addr: "127.0.0.1:9050", User: "user", Pass: "pass"},
+	}
+	ensureResponse(t, s, s.apiProxies, `{"ok":true,"proxies":{"abc.onion":{"addr":"127.0.0.1:9050","user":"user","pass":"","isolate":false}}}`, reader, writer, nil)
+
+	setBody := &struct {
+		Host  string            `json:"host"`
+		Proxy *core.ProxyConfig `json:"proxy"`
+	}{
+		Host:  "abc.onion",
+		Proxy: &core.ProxyConfig{Addr: "127.0.0.1:9050", Isolate: true},
+	}
+	ensureResponse(t, s, s.apiSetProxy, `{"ok":true}`, reader, writer, setBody)
+	tCore.proxyErr = tErr
+	ensureResponse(t, s, s.apiSetProxy, `{"ok":false,"msg":"error setting proxy: test error"}`, reader, writer, setBody)
+}
+
+func TestAPICancelOnDisconnect(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.cancelEpochs = map[string]uint32{"somedex.com": 3}
+	ensureResponse(t, s, s.apiCancelOnDisconnect, `{"ok":true,"settings":{"somedex.com":3}}`, reader, writer, nil)
+
+	setBody := &struct {
+		Host   string `json:"host"`
+		Epochs uint32 `json:"epochs"`
+	}{
+		Host:   "somedex.com",
+		Epochs: 5,
+	}
+	ensureResponse(t, s, s.apiSetCancelOnDisconnect, `{"ok":true}`, reader, writer, setBody)
+	tCore.codErr = tErr
+	ensureResponse(t, s, s.apiSetCancelOnDisconnect, `{"ok":false,"msg":"error setting cancel-on-disconnect: test error"}`, reader, writer, setBody)
+}
+
+func TestAPISwapConfs(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.swapConfs = map[uint32]uint32{42: 3}
+	ensureResponse(t, s, s.apiSwapConfs, `{"ok":true,"settings":{"42":3}}`, reader, writer, nil)
+
+	setBody := &struct {
+		AssetID uint32 `json:"assetID"`
+		Confs   uint32 `json:"confs"`
+	}{
+		AssetID: 42,
+		Confs:   2,
+	}
+	ensureResponse(t, s, s.apiSetSwapConfs, `{"ok":true}`, reader, writer, setBody)
+	tCore.swapConfErr = tErr
+	ensureResponse(t, s, s.apiSetSwapConfs, `{"ok":false,"msg":"error setting swap confirmations: test error"}`, reader, writer, setBody)
+}
+
+func TestAPIAutoRefund(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.autoRefund = true
+	ensureResponse(t, s, s.apiAutoRefund, `{"ok":true,"enabled":true}`, reader, writer, nil)
+
+	setBody := &struct {
+		Enabled bool `json:"enabled"`
+	}{
+		Enabled: false,
+	}
+	ensureResponse(t, s, s.apiSetAutoRefund, `{"ok":true}`, reader, writer, setBody)
+	tCore.autoRefundErr = tErr
+	ensureResponse(t, s, s.apiSetAutoRefund, `{"ok":false,"msg":"error setting automatic refunds: test error"}`, reader, writer, setBody)
+}
+
+func TestAPILotSizePolicy(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.lotPolicy = core.LotSizeReplace
+	ensureResponse(t, s, s.apiLotSizePolicy, `{"ok":true,"policy":"replace"}`, reader, writer, nil)
+
+	setBody := &struct {
+		Policy core.LotSizePolicy `json:"policy"`
+	}{
+		Policy: core.LotSizeCancel,
+	}
+	ensureResponse(t, s, s.apiSetLotSizePolicy, `{"ok":true}`, reader, writer, setBody)
+	tCore.lotPolicyErr = tErr
+	ensureResponse(t, s, s.apiSetLotSizePolicy, `{"ok":false,"msg":"error setting lot size policy: test error"}`, reader, writer, setBody)
+}
+
+func TestAPITradeGuard(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	ensureResponse(t, s, s.apiTradeGuard, `{"ok":true,"guard":{"warnDeviation":10,"blockDeviation":0,"duplicateWindow":0}}`, reader, writer, nil)
+
+	setBody := &core.TradeGuard{WarnDeviation: 5, DuplicateWindow: 10}
+	ensureResponse(t, s, s.apiSetTradeGuard, `{"ok":true}`, reader, writer, setBody)
+	tCore.tradeGuardErr = tErr
+	ensureResponse(t, s, s.apiSetTradeGuard, `{"ok":false,"msg":"error setting trade guard: test error"}`, reader, writer, setBody)
+
+	// An order stopped by a trade guard is returned with the guard's details.
+	tCore.tradeErr = &core.TradeGuardError{Guard: core.GuardDuplicate, OrderID: "abcd"}
+	tradeBody := &tradeForm{Order: &core.TradeForm{}}
+	ensureResponse(t, s, s.apiTrade, `{"ok":false,"msg":"order is identical to order abcd, which was just placed. confirm to place the order","guard":{"guard":"duplicate","blocked":false,"orderID":"abcd"}}`, reader, writer, tradeBody)
+	tCore.tradeErr = tErr
+	ensureResponse(t, s, s.apiTrade, `{"ok":false,"msg":"error placing order: test error"}`, reader, writer, tradeBody)
+}
+
+func TestAPIDepositAddresses(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	newBody := &struct {
+		AssetID uint32 `json:"assetID"`
+		Label   string `json:"label"`
+	}{
+		AssetID: 42,
+		Label:   "savings",
+	}
+	ensureResponse(t, s, s.apiNewDepositAddress, `{"ok":true,"address":{"assetID":42,"address":"addr","label":"savings","stamp":0,"deposits":null}}`, reader, writer, newBody)
+
+	tCore.depositAddrs = []*core.DepositAddress{{
+		AssetID: 42,
+		Address: "addr",
+		Deposits: []*core.Deposit{{
+			Coin:          "coin:0",
+			Value:         1e8,
+			Confirmations: 2,
+		}},
+	}}
+	listBody := &struct {
+		AssetID uint32 `json:"assetID"`
+	}{
+		AssetID: 42,
+	}
+	ensureResponse(t, s, s.apiDepositAddresses, `{"ok":true,"addresses":[{"assetID":42,"address":"addr","label":"","stamp":0,"deposits":[{"coinID":"","coin":"coin:0","value":100000000,"confs":2,"stamp":0}]}]}`, reader, writer, listBody)
+
+	labelBody := &struct {
+		AssetID uint32 `json:"assetID"`
+		Address string `json:"address"`
+		Label   string `json:"label"`
+	}{
+		AssetID: 42,
+		Address: "addr",
+		Label:   "trading",
+	}
+	ensureResponse(t, s, s.apiSetDepositLabel, `{"ok":true}`, reader, writer, labelBody)
+
+	tCore.depositErr = tErr
+	ensureResponse(t, s, s.apiNewDepositAddress, `{"ok":false,"msg":"error generating dcr deposit address: test error"}`, reader, writer, newBody)
+	ensureResponse(t, s, s.apiSetDepositLabel, `{"ok":false,"msg":"error setting deposit address label: test error"}`, reader, writer, labelBody)
+}
+
+func TestAPICloneOrder(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	body := &struct {
+		Pass    string `json:"pw"`
+		OrderID string `json:"orderID"`
+		Rate    uint64 `json:"rate"`
+	}{
+		Pass:    "abc",
+		OrderID: "0102",
+		Rate:    5,
+	}
+	ensureResponse(t, s, s.apiCloneOrder, `{"ok":true,"order":null}`, reader, writer, body)
+	if tCore.cloneID != "0102" || tCore.cloneRate != 5 {
+		t.Fatalf("wrong clone arguments %q, %d", tCore.cloneID, tCore.cloneRate)
+	}
+	tCore.cloneErr = tErr
+	ensureResponse(t, s, s.apiCloneOrder, `{"ok":false,"msg":"error re-placing order 0102: test error"}`, reader, writer, body)
+}
+
+func TestAPISetNotes(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	body := &struct {
+		OrderID string   `json:"orderID"`
+		MatchID string   `json:"matchID"`
+		Notes   string   `json:"notes"`
+		Tags    []string `json:"tags"`
+	}{
+		OrderID: "0102",
+		Notes:   "manual",
+		Tags:    []string{"hedge"},
+	}
+	ensureResponse(t, s, s.apiSetNotes, `{"ok":true}`, reader, writer, body)
+	if tCore.notesMatchID != "" || len(tCore.notesTags) != 1 {
+		t.Fatalf("wrong order notes arguments %q, %v", tCore.notesMatchID, tCore.notesTags)
+	}
+	body.MatchID = "0304"
+	ensureResponse(t, s, s.apiSetNotes, `{"ok":true}`, reader, writer, body)
+	if tCore.notesMatchID != "0304" {
+		t.Fatalf("match notes not set")
+	}
+	tCore.notesErr = tErr
+	ensureResponse(t, s, s.apiSetNotes, `{"ok":false,"msg":"error setting notes: test error"}`, reader, writer, body)
+}
+
+func TestAPIReservations(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.reservations = &core.Reservations{AssetID: 42, OrderLocked: 5, Untracked: 1}
+	tCore.funding = &core.OrderFunding{AssetID: 42, Required: 10, Available: 5, Reason: "not enough"}
+	resBody := &struct {
+		AssetID uint32 `json:"assetID"`
+	}{AssetID: 42}
+	fundBody := &core.TradeForm{Host: "abc", Sell: true, Base: 42, Quote: 0, Qty: 1e8}
+	ensureResponse(t, s, s.apiReservations, `{"ok":true,"reservations":{"assetID":42,"balance":null,"orders":null,"orderLocked":5,"contracts":0,"untracked":1}}`, reader, writer, resBody)
+	ensureResponse(t, s, s.apiOrderFunding, `{"ok":true,"funding":{"assetID":42,"required":10,"available":5,"locked":0,"immature":0,"ok":false,"reason":"not enough"}}`, reader, writer, fundBody)
+
+	tCore.reserveErr = tErr
+	ensureResponse(t, s, s.apiReservations, `{"ok":false,"msg":"error retrieving dcr reservations: test error"}`, reader, writer, resBody)
+	ensureResponse(t, s, s.apiOrderFunding, `{"ok":false,"msg":"error checking order funding: test error"}`, reader, writer, fundBody)
+}
+
+func TestAPIPreOrder(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.estimate = &core.OrderEstimate{
+		Lots:        2,
+		Qty:         2e8,
+		Remainder:   5,
+		Swap:        &core.FeeEstimate{AssetID: 42, FeeRate: 10, Best: 2, Worst: 4},
+		Redeem:      &core.FeeEstimate{AssetID: 0, FeeRate: 5, Best: 1, Worst: 2},
+		Locked:      200000004,
+		RefundAfter: 1000,
+	}
+	body := &core.TradeForm{Host: "abc", IsLimit: true, Sell: true, Base: 42, Quote: 0, Qty: 2e8 + 5, Rate: 1e6}
+	ensureResponse(t, s, s.apiPreOrder, `{"ok":true,"estimate":{"lots":2,"qty":200000000,"remainder":5,`+
+		`"swap":{"assetID":42,"feeRate":10,"best":2,"worst":4},"redeem":{"assetID":0,"feeRate":5,"best":1,"worst":2},`+
+		`"locked":200000004,"refundAfter":1000}}`, reader, writer, body)
+
+	tCore.reserveErr = tErr
+	ensureResponse(t, s, s.apiPreOrder, `{"ok":false,"msg":"error estimating order: test error"}`, reader, writer, body)
+}
+
+func TestAPIDevicePrompts(t *testing.T) {
+	writer := new(TWriter)
+	reader := new(TReader)
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.prompts = []*core.DevicePrompt{{ID: "abcd", Kind: "pin", Device: "Test Device"}}
+	ensureResponse(t, s, s.apiDevicePrompts, `{"ok":true,"prompts":[{"id":"abcd","assetID":0,"kind":"pin","device":"Test Device","message":"","stamp":0}]}`, reader, writer, nil)
+
+	body := &devicePromptForm{ID: "abcd", Response: encode.PassBytes("1234")}
+	ensureResponse(t, s, s.apiDevicePrompt, `{"ok":true}`, reader, writer, body)
+	tCore.promptErr = tErr
+	body = &devicePromptForm{ID: "abcd", Response: encode.PassBytes("1234")}
+	ensureResponse(t, s, s.apiDevicePrompt, `{"ok":false,"msg":"error responding to device prompt: test error"}`, reader, writer, body)
+}
+
+func TestAPIExportTrades(t *testing.T) {
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	tCore.tradeHistory = &core.TradeHistory{
+		Matches: []*core.ExportedMatch{{
+			Host:     "somedex.org",
+			MarketID: "dcr_btc",
+		}},
+	}
+	export := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/exporttrades"+query, nil)
+		s.apiExportTrades(w, r)
+		return w
+	}
+
+	w := export("")
+	if w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("wrong content type %s", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "somedex.org,dcr_btc") {
+		t.Fatalf("unexpected csv: %s", w.Body.String())
+	}
+
+	w = export("?format=json")
+	if w.Header().Get("Content-Disposition") != "attachment; filename=dex-trades.json" {
+		t.Fatalf("wrong content disposition %s", w.Header().Get("Content-Disposition"))
+	}
+	history := new(core.TradeHistory)
+	if err := json.Unmarshal(w.Body.Bytes(), history); err != nil {
+		t.Fatalf("error decoding json: %v", err)
+	}
+	if len(history.Matches) != 1 {
+		t.Fatalf("wrong number of matches %d", len(history.Matches))
+	}
+
+	w = export("?format=xml")
+	if !strings.Contains(w.Body.String(), `"ok":false`) {
+		t.Fatalf("no error for unknown format")
+	}
+
+	tCore.tradeHistoryErr = tErr
+	w = export("")
+	if !strings.Contains(w.Body.String(), "export error: test error") {
+		t.Fatalf("no error for TradeHistory error")
+	}
+}
+
+func TestAPICharts(t *testing.T) {
+	s, tCore, shutdown, _ := newTServer(t, false)
+	defer shutdown()
+
+	get := func(f http.HandlerFunc, path string) string {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		f(w, r)
+		return strings.TrimSpace(w.Body.String())
+	}
+	check := func(f http.HandlerFunc, path, want string) {
+		t.Helper()
+		if got := get(f, path); got != want {
+			t.Fatalf("%s: wrong response. expected %s, got %s", path, want, got)
+		}
+	}
+
+	const mkt = "host=somedex.org&base=42&quote=0"
+	tCore.candles = []*core.Candle{{StartStamp: 1, EndStamp: 2, HighRate: 3}}
+	check(s.apiCandles, "/api/candles?"+mkt+"&bin=1h&start=1",
+		`{"ok":true,"candles":[{"startStamp":1,"endStamp":2,"matchVolume":0,"quoteVolume":0,"highRate":3,"lowRate":0,"startRate":0,"endRate":0}]}`)
+	if tCore.candleBin != time.Hour {
+		t.Fatalf("wrong bin size %s", tCore.candleBin)
+	}
+	check(s.apiCandles, "/api/candles?host=somedex.org&base=x&quote=0&bin=1h",
+		`{"ok":false,"msg":"invalid base asset ID \"x\""}`)
+	check(s.apiCandles, "/api/candles?"+mkt+"&bin=hour",
+		`{"ok":false,"msg":"invalid bin size \"hour\""}`)
+	check(s.apiCandles, "/api/candles?"+mkt+"&bin=1h&end=z",
+		`{"ok":false,"msg":"invalid end \"z\""}`)
+	tCore.candlesErr = tErr
+	check(s.apiCandles, "/api/candles?"+mkt+"&bin=1h",
+		`{"ok":false,"msg":"error retrieving candles: test error"}`)
+
+	tCore.depth = &core.DepthChart{Host: "somedex.org", BaseID: 42, MidGap: 1.5}
+	check(s.apiDepth, "/api/depth?"+mkt,
+		`{"ok":true,"depth":{"host":"somedex.org","baseid":42,"quoteid":0,"buys":null,"sells":null,"midgap":1.5}}`)
+	tCore.depthErr = tErr
+	check(s.apiDepth, "/api/depth?"+mkt,
+		`{"ok":false,"msg":"error retrieving depth chart: test error"}`)
+
+	check(s.apiSpots, "/api/spots?host=somedex.org", `{"ok":true,"spots":null}`)
+}
+
 func TestApiGetBalance(t *testing.T) {
 	writer := new(TWriter)
 	reader := new(TReader)
@@ -705,3 +1588,241 @@ func TestApiGetBalance(t *testing.T) {
 	ensure(`{"ok":false,"msg":"balance error: test error"}`)
 	tCore.balanceErr = nil
 }
+
+func TestAPIV1(t *testing.T) {
+	tCore := &TCore{}
+	s, err := New(tCore, nil, fmt.Sprintf("localhost:%d", tPort), tLogger, false, []string{"abc", "def"}, false)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	ensure := func(method, path, key, body, want string, wantCode int) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, req)
+		if w.Code != wantCode {
+			t.Fatalf("%s %s: expected status %d, got %d", method, path, wantCode, w.Code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != want {
+			t.Fatalf("%s %s: wrong response. expected %s, got %s", method, path, want, got)
+		}
+	}
+
+	// Missing and invalid keys.
+	unauthorized := `{"ok":false,"msg":"Unauthorized"}`
+	ensure("GET", "/api/v1/wallets", "", "", unauthorized, http.StatusUnauthorized)
+	ensure("GET", "/api/v1/wallets", "abcd", "", unauthorized, http.StatusUnauthorized)
+
+	// Any configured key is accepted.
+	ensure("GET", "/api/v1/wallets", "abc", "", `{"ok":true,"wallets":null}`, http.StatusOK)
+	ensure("GET", "/api/v1/wallets", "def", "", `{"ok":true,"wallets":null}`, http.StatusOK)
+
+	// Accounts at a DEX.
+	ensure("GET", "/api/v1/accounts?host=abc", "abc", "", `{"ok":true,"accounts":["abc"]}`, http.StatusOK)
+	ensure("GET", "/api/v1/accounts", "abc", "", `{"ok":false,"msg":"no host specified"}`, http.StatusOK)
+
+	// Order filters.
+	ensure("GET", "/api/v1/orders?host=abc&status=booked,executed&n=5", "abc", "",
+		`{"ok":true,"orders":null}`, http.StatusOK)
+	f := tCore.orderFilter
+	if f.Host != "abc" || f.N != 5 || len(f.Statuses) != 2 || f.Statuses[0] != order.OrderStatusBooked ||
+		f.Statuses[1] != order.OrderStatusExecuted {
+		t.Fatalf("wrong order filter %+v", f)
+	}
+	ensure("GET", "/api/v1/orders?status=bogus", "abc", "",
+		`{"ok":false,"msg":"unknown order status \"bogus\""}`, http.StatusOK)
+	ensure("GET", "/api/v1/trades?since=10&until=5", "abc", "",
+		`{"ok":false,"msg":"until is before since"}`, http.StatusOK)
+
+	// Withdraw
+	ensure("POST", "/api/v1/wallets/42/withdraw", "abc", `{"value":1,"address":"abc","pw":"def"}`,
+		`{"ok":true,"coin":"dec7ed"}`, http.StatusOK)
+	ensure("POST", "/api/v1/wallets/x/withdraw", "abc", `{"value":1,"address":"abc","pw":"def"}`,
+		`{"ok":false,"msg":"invalid asset ID \"x\""}`, http.StatusOK)
+
+	// Notifications are kept up to maxAPINotes, and filtered by time.
+	for i := 0; i < maxAPINotes+5; i++ {
+		note := db.NewNotification("test", "subject", "details", db.Success)
+		s.storeNote(&core.FeePaymentNote{Notification: note})
+	}
+	if len(s.notes) != maxAPINotes {
+		t.Fatalf("expected %d notes, got %d", maxAPINotes, len(s.notes))
+	}
+	since := s.notes[len(s.notes)-1].Time()
+	ensure("GET", fmt.Sprintf("/api/v1/notifications?since=%d", since), "abc", "",
+		`{"ok":true,"notifications":[]}`, http.StatusOK)
+
+	// Without keys, only API tokens are accepted.
+	s, _ = New(tCore, nil, fmt.Sprintf("localhost:%d", tPort), tLogger, false, nil, false)
+	ensure("GET", "/api/v1/wallets", "abc", "", unauthorized, http.StatusUnauthorized)
+	token, _ := s.newSession("bot", time.Hour, true)
+	ensure("GET", "/api/v1/wallets", token, "", `{"ok":true,"wallets":null}`, http.StatusOK)
+	// A browser session token is not an API token.
+	ensure("GET", "/api/v1/wallets", s.authorize("browser"), "", unauthorized, http.StatusUnauthorized)
+}
+
+func TestSessions(t *testing.T) {
+	s, _, shutdown, err := newTServer(t, false)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	defer shutdown()
+
+	do := func(method, path, authToken, body string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if authToken != "" {
+			req.AddCookie(&http.Cookie{Name: authCK, Value: authToken})
+		}
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, req)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	// The settings API requires a session.
+	if code, _ := do("GET", "/api/sessions", "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without a session, got %d", http.StatusUnauthorized, code)
+	}
+
+	// Multiple devices can be logged in at once.
+	phone := s.authorize("phone")
+	laptop := s.authorize("laptop")
+	if !s.isAuthed(withAuthCookie(phone)) || !s.isAuthed(withAuthCookie(laptop)) {
+		t.Fatalf("sessions not authorized")
+	}
+
+	// Create an API token.
+	code, body := do("POST", "/api/newapitoken", laptop, `{"name":"bot","hours":1}`)
+	if code != http.StatusOK {
+		t.Fatalf("newapitoken status %d", code)
+	}
+	tokenResp := new(struct {
+		Token   string       `json:"token"`
+		Session *sessionInfo `json:"session"`
+	})
+	if err := json.Unmarshal([]byte(body), tokenResp); err != nil {
+		t.Fatalf("error decoding newapitoken response: %v", err)
+	}
+	if tokenResp.Token == "" || !tokenResp.Session.APIToken || tokenResp.Session.Expires == 0 {
+		t.Fatalf("wrong API token response %s", body)
+	}
+	// An API token is not a browser session.
+	if s.isAuthed(withAuthCookie(tokenResp.Token)) {
+		t.Fatalf("API token accepted as a browser session")
+	}
+	_, body = do("POST", "/api/newapitoken", laptop, `{"hours":1}`)
+	if body != `{"ok":false,"msg":"no API token name"}` {
+		t.Fatalf("no error for unnamed API token: %s", body)
+	}
+
+	getSessions := func(authToken string) ([]*sessionInfo, []*revokedSession) {
+		t.Helper()
+		code, body := do("GET", "/api/sessions", authToken, "")
+		if code != http.StatusOK {
+			t.Fatalf("sessions status %d", code)
+		}
+		resp := new(struct {
+			Sessions []*sessionInfo    `json:"sessions"`
+			Revoked  []*revokedSession `json:"revoked"`
+		})
+		if err := json.Unmarshal([]byte(body), resp); err != nil {
+			t.Fatalf("error decoding sessions response: %v", err)
+		}
+		return resp.Sessions, resp.Revoked
+	}
+	sessions, _ := getSessions(laptop)
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(sessions))
+	}
+	var phoneID string
+	for _, sess := range sessions {
+		if sess.Current != (sess.Name == "laptop") {
+			t.Fatalf("wrong current session %q", sess.Name)
+		}
+		if sess.Name == "phone" {
+			phoneID = sess.ID
+		}
+	}
+
+	// Revoke the phone's session.
+	if _, body = do("POST", "/api/revokesession", laptop, `{"id":"`+phoneID+`"}`); body != `{"ok":true}` {
+		t.Fatalf("revokesession error: %s", body)
+	}
+	if s.isAuthed(withAuthCookie(phone)) {
+		t.Fatalf("revoked session still authorized")
+	}
+	if _, body = do("POST", "/api/revokesession", laptop, `{"id":"`+phoneID+`"}`); !strings.Contains(body, "no active session") {
+		t.Fatalf("no error for revoking an inactive session: %s", body)
+	}
+	sessions, revoked := getSessions(laptop)
+	if len(sessions) != 2 || len(revoked) != 1 || revoked[0].ID != phoneID {
+		t.Fatalf("wrong sessions after revocation: %d sessions, %d revoked", len(sessions), len(revoked))
+	}
+
+	// Expired sessions are removed.
+	s.mtx.Lock()
+	for _, sess := range s.sessions {
+		if sess.apiToken {
+			sess.expires = time.Now().Add(-time.Second)
+		}
+	}
+	s.mtx.Unlock()
+	if s.session(tokenResp.Token, true) != nil {
+		t.Fatalf("expired API token accepted")
+	}
+	if sessions, _ = getSessions(laptop); len(sessions) != 1 {
+		t.Fatalf("expected 1 session after expiration, got %d", len(sessions))
+	}
+
+	// The revocation list is limited.
+	for i := 0; i < maxRevoked+5; i++ {
+		_, sess := s.newSession("temp", time.Hour, false)
+		s.revokeSession(sess.id)
+	}
+	if _, revoked = getSessions(laptop); len(revoked) != maxRevoked {
+		t.Fatalf("expected %d revoked sessions, got %d", maxRevoked, len(revoked))
+	}
+}
+
+func withAuthCookie(authToken string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: authCK, Value: authToken})
+	return req
+}
+
+func TestRequestLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		accept string
+		want   string
+	}{
+		{"none", "", "", ""},
+		{"unsupported", "", "fr-FR,de;q=0.8", ""},
+		{"accept exact", "", "es-ES", "es-ES"},
+		{"accept primary", "", "fr;q=0.9,es-MX;q=0.8", "es-ES"},
+		{"accept weights", "", "es;q=0.5,en-GB", "en-US"},
+		{"cookie", "es-ES", "en-US", "es-ES"},
+		{"bad cookie", "xx", "es", "es-ES"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: langCK, Value: tt.cookie})
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept-Language", tt.accept)
+		}
+		if got := requestLocale(req); got != tt.want {
+			t.Fatalf("%s: wanted %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -37,6 +37,9 @@ type wsClient struct {
 	mtx      sync.RWMutex
 	cid      int32
 	feedLoop *dex.StartStopWaiter
+	// lang is the language that notifications are sent in. If empty, they
+	// are sent in the Core's language.
+	lang string
 }
 
 func newWSClient(ip string, conn ws.Connection, hndlr func(msg *msgjson.Message) *msgjson.Error) *wsClient {
@@ -61,13 +64,13 @@ func (s *WebServer) handleWS(w http.ResponseWriter, r *http.Request) {
 		log.Errorf("ws connection error: %v", err)
 		return
 	}
-	go s.websocketHandler(wsConn, ip)
+	go s.websocketHandler(wsConn, ip, requestLocale(r))
 }
 
 // websocketHandler handles a new websocket client by creating a new wsClient,
 // starting it, and blocking until the connection closes. This method should be
 // run as a goroutine.
-func (s *WebServer) websocketHandler(conn ws.Connection, ip string) {
+func (s *WebServer) websocketHandler(conn ws.Connection, ip, lang string) {
 	log.Debugf("New websocket client %s", ip)
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
@@ -76,6 +79,7 @@ func (s *WebServer) websocketHandler(conn ws.Connection, ip string) {
 	cl = newWSClient(ip, conn, func(msg *msgjson.Message) *msgjson.Error {
 		return s.handleMessage(cl, msg)
 	})
+	cl.lang = lang
 	s.mtx.Lock()
 	s.clients[cl.cid] = cl
 	s.mtx.Unlock()
@@ -114,6 +118,27 @@ func (s *WebServer) notify(route string, payload interface{}) {
 	}
 }
 
+// notifyNote sends a Core notification to the websocket clients, each in the
+// language of its session.
+func (s *WebServer) notifyNote(n core.Notification) {
+	msgs := make(map[string]*msgjson.Message)
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, cl := range s.clients {
+		msg, found := msgs[cl.lang]
+		if !found {
+			var err error
+			msg, err = msgjson.NewNotification(notifyRoute, localizedNote(n, cl.lang))
+			if err != nil {
+				log.Errorf("notification encoding error: %v", err)
+				return
+			}
+			msgs[cl.lang] = msg
+		}
+		cl.Send(msg)
+	}
+}
+
 func (s *WebServer) notifyWalletUpdate(assetID uint32) {
 	walletUpdate := s.core.WalletState(assetID)
 	s.notify(updateWalletRoute, walletUpdate)
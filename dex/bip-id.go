@@ -588,6 +588,7 @@ var bipIDs = map[uint32]string{
 	37992:    "fxtc",
 	39321:    "ama",
 	49344:    "stash",
+	60001:    "usdc.eth", // ERC-20 token, not a BIP-0044 coin
 	65536:    "keth",
 	88888:    "ryo[c0ban]",
 	99999:    "wicc",
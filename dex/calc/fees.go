@@ -26,3 +26,10 @@ func RequiredOrderFunds(swapVal, inputsSize uint64, coin *dex.Asset) uint64 {
 	fee := totalBytes * coin.MaxFeeRate
 	return swapVal + fee
 }
+
+// TradeFee calculates the operator's trade fee on qty at a fee rate in basis
+// points (1/100th of a percent).
+func TradeFee(qty uint64, bps uint32) uint64 {
+	// Split the quantity to avoid overflowing the product.
+	return qty/10000*uint64(bps) + qty%10000*uint64(bps)/10000
+}
@@ -0,0 +1,181 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package encode
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// SeedToWords encodes the seed as a string of PGP words, one for each byte,
+// followed by a checksum word. Bytes at even positions are encoded with the
+// two-syllable word list and bytes at odd positions with the three-syllable
+// list, so that swapped words are detected.
+func SeedToWords(seed []byte) string {
+	words := make([]string, 0, len(seed)+1)
+	for i, b := range seed {
+		words = append(words, byteToWord(b, i))
+	}
+	words = append(words, byteToWord(seedChecksum(seed), len(seed)))
+	return strings.Join(words, " ")
+}
+
+// WordsToSeed decodes a seed from a string of PGP words created with
+// SeedToWords. Words are separated by whitespace and are not case-sensitive.
+func WordsToSeed(words string) ([]byte, error) {
+	fields := strings.Fields(strings.ToLower(words))
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("too few words")
+	}
+	seed := make([]byte, 0, len(fields))
+	for i, word := range fields {
+		b, err := wordToByte(word, i)
+		if err != nil {
+			return nil, err
+		}
+		seed = append(seed, b)
+	}
+	seed, checksum := seed[:len(seed)-1], seed[len(seed)-1]
+	if checksum != seedChecksum(seed) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return seed, nil
+}
+
+// seedChecksum is the first byte of the seed's hash.
+func seedChecksum(seed []byte) byte {
+	h := sha256.Sum256(seed)
+	return h[0]
+}
+
+// byteToWord is the word for the byte at position i.
+func byteToWord(b byte, i int) string {
+	if i%2 == 0 {
+		return evenWords[b]
+	}
+	return oddWords[b]
+}
+
+// wordToByte is the byte for the lower-case word at position i.
+func wordToByte(word string, i int) (byte, error) {
+	idxs := evenWordIndex
+	if i%2 != 0 {
+		idxs = oddWordIndex
+	}
+	if b, found := idxs[word]; found {
+		return b, nil
+	}
+	_, isEven := evenWordIndex[word]
+	_, isOdd := oddWordIndex[word]
+	if isEven || isOdd {
+		return 0, fmt.Errorf("word %d (%s) is out of place", i+1, word)
+	}
+	return 0, fmt.Errorf("unknown word %d (%s)", i+1, word)
+}
+
+var evenWordIndex, oddWordIndex = wordIndex(&evenWords), wordIndex(&oddWords)
+
+// wordIndex maps the lower-case words to their bytes.
+func wordIndex(words *[256]string) map[string]byte {
+	idxs := make(map[string]byte, len(words))
+	for i, word := range words {
+		idxs[strings.ToLower(word)] = byte(i)
+	}
+	return idxs
+}
+
+// evenWords is the PGP word list of two-syllable words, for bytes at even
+// positions.
+var evenWords = [256]string{
+	"aardvark", "absurd", "accrue", "acme", "adrift", "adult", "afflict",
+	"ahead", "aimless", "Algol", "allow", "alone", "ammo", "ancient", "apple",
+	"artist", "assume", "Athens", "atlas", "Aztec", "baboon", "backfield",
+	"backward", "banjo", "beaming", "bedlamp", "beehive", "beeswax", "befriend",
+	"Belfast", "berserk", "billiard", "bison", "blackjack", "blockade",
+	"blowtorch", "bluebird", "bombast", "bookshelf", "brackish", "breadline",
+	"breakup", "brickyard", "briefcase", "Burbank", "button", "buzzard",
+	"cement", "chairlift", "chatter", "checkup", "chisel", "choking", "chopper",
+	"Christmas", "clamshell", "classic", "classroom", "cleanup", "clockwork",
+	"cobra", "commence", "concert", "cowbell", "crackdown", "cranky",
+	"crowfoot", "crucial", "crumpled", "crusade", "cubic", "dashboard",
+	"deadbolt", "deckhand", "dogsled", "dragnet", "drainage", "dreadful",
+	"drifter", "dropper", "drumbeat", "drunken", "Dupont", "dwelling", "eating",
+	"edict", "egghead", "eightball", "endorse", "endow", "enlist", "erase",
+	"escape", "exceed", "eyeglass", "eyetooth", "facial", "fallout", "flagpole",
+	"flatfoot", "flytrap", "fracture", "framework", "freedom", "frighten",
+	"gazelle", "Geiger", "glitter", "glucose", "goggles", "goldfish", "gremlin",
+	"guidance", "hamlet", "highchair", "hockey", "indoors", "indulge",
+	"inverse", "involve", "island", "jawbone", "keyboard", "kickoff", "kiwi",
+	"klaxon", "locale", "lockup", "merit", "minnow", "miser", "Mohawk", "mural",
+	"music", "necklace", "Neptune", "newborn", "nightbird", "Oakland", "obtuse",
+	"offload", "optic", "orca", "payday", "peachy", "pheasant", "physique",
+	"playhouse", "Pluto", "preclude", "prefer", "preshrunk", "printer",
+	"prowler", "pupil", "puppy", "python", "quadrant", "quiver", "quota",
+	"ragtime", "ratchet", "rebirth", "reform", "regain", "reindeer", "rematch",
+	"repay", "retouch", "revenge", "reward", "rhythm", "ribcage", "ringbolt",
+	"robust", "rocker", "ruffled", "sailboat", "sawdust", "scallion", "scenic",
+	"scorecard", "Scotland", "seabird", "select", "sentence", "shadow",
+	"shamrock", "showgirl", "skullcap", "skydive", "slingshot", "slowdown",
+	"snapline", "snapshot", "snowcap", "snowslide", "solo", "southward",
+	"soybean", "spaniel", "spearhead", "spellbind", "spheroid", "spigot",
+	"spindle", "spyglass", "stagehand", "stagnate", "stairway", "standard",
+	"stapler", "steamship", "sterling", "stockman", "stopwatch", "stormy",
+	"sugar", "surmount", "suspense", "sweatband", "swelter", "tactics", "talon",
+	"tapeworm", "tempest", "tiger", "tissue", "tonic", "topmost", "tracker",
+	"transit", "trauma", "treadmill", "Trojan", "trouble", "tumor", "tunnel",
+	"tycoon", "uncut", "unearth", "unwind", "uproot", "upset", "upshot",
+	"vapor", "village", "virus", "Vulcan", "waffle", "wallet", "watchword",
+	"wayside", "willow", "woodlark", "Zulu",
+}
+
+// oddWords is the PGP word list of three-syllable words, for bytes at odd
+// positions.
+var oddWords = [256]string{
+	"adroitness", "adviser", "aftermath", "aggregate", "alkali", "almighty",
+	"amulet", "amusement", "antenna", "applicant", "Apollo", "armistice",
+	"article", "asteroid", "Atlantic", "atmosphere", "autopsy", "Babylon",
+	"backwater", "barbecue", "belowground", "bifocals", "bodyguard",
+	"bookseller", "borderline", "bottomless", "Bradbury", "bravado",
+	"Brazilian", "breakaway", "Burlington", "businessman", "butterfat",
+	"Camelot", "candidate", "cannonball", "Capricorn", "caravan", "caretaker",
+	"celebrate", "cellulose", "certify", "chambermaid", "Cherokee", "Chicago",
+	"clergyman", "coherence", "combustion", "commando", "company", "component",
+	"concurrent", "confidence", "conformist", "congregate", "consensus",
+	"consulting", "corporate", "corrosion", "councilman", "crossover",
+	"crucifix", "cumbersome", "customer", "Dakota", "decadence", "December",
+	"decimal", "designing", "detector", "detergent", "determine", "dictator",
+	"dinosaur", "direction", "disable", "disbelief", "disruptive", "distortion",
+	"document", "embezzle", "enchanting", "enrollment", "enterprise",
+	"equation", "equipment", "escapade", "Eskimo", "everyday", "examine",
+	"existence", "exodus", "fascinate", "filament", "finicky", "forever",
+	"fortitude", "frequency", "gadgetry", "Galveston", "getaway", "glossary",
+	"gossamer", "graduate", "gravity", "guitarist", "hamburger", "Hamilton",
+	"handiwork", "hazardous", "headwaters", "hemisphere", "hesitate",
+	"hideaway", "holiness", "hurricane", "hydraulic", "impartial", "impetus",
+	"inception", "indigo", "inertia", "infancy", "inferno", "informant",
+	"insincere", "insurgent", "integrate", "intention", "inventive", "Istanbul",
+	"Jamaica", "Jupiter", "leprosy", "letterhead", "liberty", "maritime",
+	"matchmaker", "maverick", "Medusa", "megaton", "microscope", "microwave",
+	"midsummer", "millionaire", "miracle", "misnomer", "molasses", "molecule",
+	"Montana", "monument", "mosquito", "narrative", "nebula", "newsletter",
+	"Norwegian", "October", "Ohio", "onlooker", "opulent", "Orlando",
+	"outfielder", "Pacific", "pandemic", "Pandora", "paperweight", "paragon",
+	"paragraph", "paramount", "passenger", "pedigree", "Pegasus", "penetrate",
+	"perceptive", "performance", "pharmacy", "phonetic", "photograph",
+	"pioneer", "pocketful", "politeness", "positive", "potato", "processor",
+	"provincial", "proximate", "puberty", "publisher", "pyramid", "quantity",
+	"racketeer", "rebellion", "recipe", "recover", "repellent", "replica",
+	"reproduce", "resistor", "responsive", "retraction", "retrieval",
+	"retrospect", "revenue", "revival", "revolver", "sandalwood", "sardonic",
+	"Saturday", "savagery", "scavenger", "sensation", "sociable", "souvenir",
+	"specialist", "speculate", "stethoscope", "stupendous", "supportive",
+	"surrender", "suspicious", "sympathy", "tambourine", "telephone",
+	"therapist", "tobacco", "tolerance", "tomorrow", "torpedo", "tradition",
+	"travesty", "trombonist", "truncated", "typewriter", "ultimate",
+	"undaunted", "underfoot", "unicorn", "unify", "universe", "unravel",
+	"upcoming", "vacancy", "vagabond", "vertigo", "Virginia", "visitor",
+	"vocalist", "voyager", "warranty", "Waterloo", "whimsical", "Wichita",
+	"Wilmington", "Wyoming", "yesteryear", "Yucatan",
+}
@@ -0,0 +1,44 @@
+package encode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeedWords(t *testing.T) {
+	// The PGP word list's own example.
+	words := SeedToWords([]byte{0xe5, 0x82, 0x94})
+	if !strings.HasPrefix(words, "topmost Istanbul Pluto ") {
+		t.Fatalf("wrong words %q", words)
+	}
+
+	for i := 0; i < 100; i++ {
+		seed := RandomBytes(32)
+		words := SeedToWords(seed)
+		if n := len(strings.Fields(words)); n != 33 {
+			t.Fatalf("expected 33 words, got %d", n)
+		}
+		reSeed, err := WordsToSeed("  " + strings.ToUpper(words) + "\n")
+		if err != nil {
+			t.Fatalf("WordsToSeed error: %v", err)
+		}
+		if !bEqual(seed, reSeed) {
+			t.Fatalf("wrong seed. %x != %x", reSeed, seed)
+		}
+	}
+
+	seed := []byte{0x00, 0x01, 0x02, 0x03}
+	fields := strings.Fields(SeedToWords(seed))
+	swapped := append([]string{fields[1], fields[0]}, fields[2:]...)
+	changed := append([]string{evenWords[0xff]}, fields[1:]...)
+	for name, words := range map[string]string{
+		"too few words": fields[0],
+		"unknown word":  "dexterity " + strings.Join(fields[1:], " "),
+		"swapped words": strings.Join(swapped, " "),
+		"bad checksum":  strings.Join(changed, " "),
+	} {
+		if _, err := WordsToSeed(words); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
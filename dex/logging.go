@@ -4,8 +4,12 @@
 package dex
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/decred/slog"
 )
@@ -116,3 +120,262 @@ func (lm *LoggerMaker) bestLevel(lvls ...string) slog.Level {
 	}
 	return lvl
 }
+
+// Log attribute keys that ParseLogLine moves into the fields of a
+// JSONLogRecord.
+const (
+	LogAttrMarket  = "market"
+	LogAttrAccount = "account"
+	LogAttrMatch   = "match"
+)
+
+// attrLogger is a Logger that appends key=value attributes to each message.
+type attrLogger struct {
+	Logger
+	keyvals []interface{}
+}
+
+// LoggerWithAttrs creates a Logger that appends the attributes to each message
+// written by logger, in the form " [key=value ...]". keyvals alternate between
+// keys, e.g. LogAttrAccount, and values, which are formatted with %v. The
+// attributes are only formatted for messages that are logged.
+func LoggerWithAttrs(logger Logger, keyvals ...interface{}) Logger {
+	if al, ok := logger.(*attrLogger); ok {
+		return &attrLogger{
+			Logger:  al.Logger,
+			keyvals: append(append([]interface{}(nil), al.keyvals...), keyvals...),
+		}
+	}
+	return &attrLogger{Logger: logger, keyvals: keyvals}
+}
+
+// suffix formats the attributes.
+func (l *attrLogger) suffix() string {
+	var b strings.Builder
+	b.WriteString(" [")
+	for i := 0; i+1 < len(l.keyvals); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v=%v", l.keyvals[i], l.keyvals[i+1])
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// logf appends the attributes to a formatted message.
+func (l *attrLogger) logf(lvl slog.Level, logf func(string, ...interface{}), format string, args []interface{}) {
+	if l.Level() > lvl {
+		return
+	}
+	logf(format+"%s", append(args, l.suffix())...)
+}
+
+// log appends the attributes to a message. The message is formatted the way
+// slog formats the arguments to its non-f methods.
+func (l *attrLogger) log(lvl slog.Level, log func(...interface{}), args []interface{}) {
+	if l.Level() > lvl {
+		return
+	}
+	log(strings.TrimSuffix(fmt.Sprintln(args...), "\n") + l.suffix())
+}
+
+// Tracef is part of the Logger interface.
+func (l *attrLogger) Tracef(format string, args ...interface{}) {
+	l.logf(slog.LevelTrace, l.Logger.Tracef, format, args)
+}
+
+// Debugf is part of the Logger interface.
+func (l *attrLogger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, l.Logger.Debugf, format, args)
+}
+
+// Infof is part of the Logger interface.
+func (l *attrLogger) Infof(format string, args ...interface{}) {
+	l.logf(slog.LevelInfo, l.Logger.Infof, format, args)
+}
+
+// Warnf is part of the Logger interface.
+func (l *attrLogger) Warnf(format string, args ...interface{}) {
+	l.logf(slog.LevelWarn, l.Logger.Warnf, format, args)
+}
+
+// Errorf is part of the Logger interface.
+func (l *attrLogger) Errorf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, l.Logger.Errorf, format, args)
+}
+
+// Criticalf is part of the Logger interface.
+func (l *attrLogger) Criticalf(format string, args ...interface{}) {
+	l.logf(slog.LevelCritical, l.Logger.Criticalf, format, args)
+}
+
+// Trace is part of the Logger interface.
+func (l *attrLogger) Trace(args ...interface{}) {
+	l.log(slog.LevelTrace, l.Logger.Trace, args)
+}
+
+// Debug is part of the Logger interface.
+func (l *attrLogger) Debug(args ...interface{}) {
+	l.log(slog.LevelDebug, l.Logger.Debug, args)
+}
+
+// Info is part of the Logger interface.
+func (l *attrLogger) Info(args ...interface{}) {
+	l.log(slog.LevelInfo, l.Logger.Info, args)
+}
+
+// Warn is part of the Logger interface.
+func (l *attrLogger) Warn(args ...interface{}) {
+	l.log(slog.LevelWarn, l.Logger.Warn, args)
+}
+
+// Error is part of the Logger interface.
+func (l *attrLogger) Error(args ...interface{}) {
+	l.log(slog.LevelError, l.Logger.Error, args)
+}
+
+// Critical is part of the Logger interface.
+func (l *attrLogger) Critical(args ...interface{}) {
+	l.log(slog.LevelCritical, l.Logger.Critical, args)
+}
+
+// JSONLogRecord is a structured log record. Records are created from the
+// formatted lines produced by a slog.Backend, so the subsystem loggers do not
+// need to be aware of the output format.
+type JSONLogRecord struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Market    string `json:"market,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Match     string `json:"match,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// jsonLogAttrs maps the log attribute keys to the JSONLogRecord field that the
+// value should populate.
+var jsonLogAttrs = map[string]func(*JSONLogRecord, string){
+	LogAttrMarket:  func(r *JSONLogRecord, v string) { r.Market = v },
+	LogAttrAccount: func(r *JSONLogRecord, v string) { r.Account = v },
+	LogAttrMatch:   func(r *JSONLogRecord, v string) { r.Match = v },
+}
+
+// ParseLogLine parses a single line written by a slog.Backend, e.g.
+//
+//	2020-03-30 10:04:05.123 [INF] SWAP: step [account=0123 match=abcd]
+//
+// into a JSONLogRecord. The market, account, and match fields are populated
+// from the attributes appended by a logger created with LoggerWithAttrs, and
+// the attributes are removed from the message. Lines that do not have the
+// expected header are returned as the message of a record with an empty
+// subsystem. The message of an entry may span several lines.
+func ParseLogLine(line string) *JSONLogRecord {
+	line = strings.TrimRight(line, "\r\n")
+	rec := new(JSONLogRecord)
+	// The header is "date time [LVL] TAG: ".
+	lvlStart := strings.Index(line, " [")
+	if lvlStart < 0 || len(line) < lvlStart+7 || line[lvlStart+5] != ']' {
+		rec.Message = line
+		return rec
+	}
+	rec.Time = line[:lvlStart]
+	rec.Level = line[lvlStart+2 : lvlStart+5]
+	rest := line[lvlStart+7:]
+	tagEnd := strings.Index(rest, ": ")
+	if tagEnd < 0 {
+		rec.Subsystem = strings.TrimSuffix(rest, ":")
+		return rec
+	}
+	rec.Subsystem, rec.Message = rest[:tagEnd], rest[tagEnd+2:]
+	// The tag may include the file:line callsite if requested.
+	if sp := strings.IndexByte(rec.Subsystem, ' '); sp > 0 {
+		rec.Subsystem = rec.Subsystem[:sp]
+	}
+	if !strings.HasSuffix(rec.Message, "]") {
+		return rec
+	}
+	attrStart := strings.LastIndex(rec.Message, " [")
+	if attrStart < 0 {
+		return rec
+	}
+	attrs := strings.Fields(rec.Message[attrStart+2 : len(rec.Message)-1])
+	for _, attr := range attrs {
+		eq := strings.IndexByte(attr, '=')
+		if eq <= 0 || jsonLogAttrs[attr[:eq]] == nil {
+			// Not an attribute list.
+			return rec
+		}
+	}
+	for _, attr := range attrs {
+		eq := strings.IndexByte(attr, '=')
+		jsonLogAttrs[attr[:eq]](rec, attr[eq+1:])
+	}
+	rec.Message = rec.Message[:attrStart]
+	return rec
+}
+
+// JSONLogWriter is an io.Writer that converts the formatted lines written by a
+// slog.Backend into newline-delimited JSON records before writing them to the
+// underlying io.Writer.
+type JSONLogWriter struct {
+	w io.Writer
+}
+
+// NewJSONLogWriter is the constructor for a *JSONLogWriter.
+func NewJSONLogWriter(w io.Writer) *JSONLogWriter {
+	return &JSONLogWriter{w: w}
+}
+
+// Write converts each line of p to a JSON record and writes it to the
+// underlying writer. The returned byte count is relative to p, so that callers
+// are not confused by the length of the JSON encoding.
+func (jw *JSONLogWriter) Write(p []byte) (int, error) {
+	b, err := JSONLogLines(p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = jw.w.Write(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logTimeFormat is the format of the timestamp that begins each entry written
+// by a slog.Backend.
+const logTimeFormat = "2006-01-02 15:04:05.000"
+
+// hasLogHeader checks whether the line begins with the timestamp and level of a
+// slog entry, rather than continuing the message of the previous entry.
+func hasLogHeader(line string) bool {
+	n := len(logTimeFormat)
+	if len(line) < n+6 || line[n:n+2] != " [" || line[n+5] != ']' {
+		return false
+	}
+	_, err := time.Parse(logTimeFormat, line[:n])
+	return err == nil
+}
+
+// JSONLogLines converts the slog-formatted lines in p to newline-delimited JSON
+// records. The lines of a multi-line message are kept in the message of a
+// single record, so a line without a header is appended to the preceding one.
+func JSONLogLines(p []byte) ([]byte, error) {
+	var entries []string
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if n := len(entries); n > 0 && !hasLogHeader(line) {
+			entries[n-1] += "\n" + line
+			continue
+		}
+		entries = append(entries, line)
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		b, err := json.Marshal(ParseLogLine(entry))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,160 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/decred/slog"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want JSONLogRecord
+	}{
+		{
+			name: "plain",
+			line: "2020-03-30 10:04:05.123 [INF] MAIN: Starting dcrdex\n",
+			want: JSONLogRecord{
+				Time:      "2020-03-30 10:04:05.123",
+				Level:     "INF",
+				Subsystem: "MAIN",
+				Message:   "Starting dcrdex",
+			},
+		},
+		{
+			name: "attributes",
+			line: "2020-03-30 10:04:05.123 [WRN] SWAP: revoking match abcd [market=dcr_btc account=0123 match=abcd]",
+			want: JSONLogRecord{
+				Time:      "2020-03-30 10:04:05.123",
+				Level:     "WRN",
+				Subsystem: "SWAP",
+				Market:    "dcr_btc",
+				Account:   "0123",
+				Match:     "abcd",
+				Message:   "revoking match abcd",
+			},
+		},
+		{
+			name: "attributes with callsite",
+			line: "2020-03-30 10:04:05.123 [DBG] MKT market.go:100: epoch closed [market=dcr_btc]",
+			want: JSONLogRecord{
+				Time:      "2020-03-30 10:04:05.123",
+				Level:     "DBG",
+				Subsystem: "MKT",
+				Market:    "dcr_btc",
+				Message:   "epoch closed",
+			},
+		},
+		{
+			name: "not attributes",
+			line: "2020-03-30 10:04:05.123 [INF] MAIN: user=0123 loaded [1 2]",
+			want: JSONLogRecord{
+				Time:      "2020-03-30 10:04:05.123",
+				Level:     "INF",
+				Subsystem: "MAIN",
+				Message:   "user=0123 loaded [1 2]",
+			},
+		},
+		{
+			name: "asset sublogger",
+			line: "2020-03-30 10:04:05.123 [INF] ASSET[btc]: connected",
+			want: JSONLogRecord{
+				Time:      "2020-03-30 10:04:05.123",
+				Level:     "INF",
+				Subsystem: "ASSET[btc]",
+				Message:   "connected",
+			},
+		},
+		{
+			name: "no header",
+			line: "panic: oops",
+			want: JSONLogRecord{
+				Message: "panic: oops",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		rec := ParseLogLine(tt.line)
+		if *rec != tt.want {
+			t.Errorf("%s: wanted %+v, got %+v", tt.name, tt.want, *rec)
+		}
+	}
+}
+
+func TestJSONLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	be := slog.NewBackend(NewJSONLogWriter(&buf))
+	logger := be.Logger("AUTH")
+	logger.SetLevel(slog.LevelInfo)
+	acctLogger := LoggerWithAttrs(logger, LogAttrAccount, "beef")
+	acctLogger.Infof("registered %d%%", 100)
+	acctLogger.Debugf("not logged")
+	LoggerWithAttrs(acctLogger, LogAttrMatch, "cafe").Info("match", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	recs := make([]*JSONLogRecord, 0, len(lines))
+	for _, line := range lines {
+		rec := new(JSONLogRecord)
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			t.Fatalf("Unmarshal error: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+	if rec := recs[0]; rec.Level != "INF" || rec.Subsystem != "AUTH" || rec.Account != "beef" ||
+		rec.Message != "registered 100%" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	if rec := recs[1]; rec.Account != "beef" || rec.Match != "cafe" || rec.Message != "match 1" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
+
+func TestJSONLogLinesMultiline(t *testing.T) {
+	p := []byte("2020-03-30 10:04:05.123 [ERR] SWAP: failed:\n" +
+		"  first [1 2]\n" +
+		"  second [market=dcr_btc]\n" +
+		"2020-03-30 10:04:05.124 [INF] SWAP: done\n")
+	b, err := JSONLogLines(p)
+	if err != nil {
+		t.Fatalf("JSONLogLines error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d: %q", len(lines), b)
+	}
+	want := []JSONLogRecord{
+		{
+			Time:      "2020-03-30 10:04:05.123",
+			Level:     "ERR",
+			Subsystem: "SWAP",
+			Market:    "dcr_btc",
+			Message:   "failed:\n  first [1 2]\n  second",
+		},
+		{
+			Time:      "2020-03-30 10:04:05.124",
+			Level:     "INF",
+			Subsystem: "SWAP",
+			Message:   "done",
+		},
+	}
+	for i, line := range lines {
+		var rec JSONLogRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Unmarshal error: %v", err)
+		}
+		if rec != want[i] {
+			t.Errorf("record %d: wanted %+v, got %+v", i, want[i], rec)
+		}
+	}
+}
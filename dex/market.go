@@ -6,6 +6,7 @@ package dex
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // MarketInfo specifies a market that the Archiver must support.
@@ -16,6 +17,55 @@ type MarketInfo struct {
 	LotSize         uint64
 	EpochDuration   uint64 // msec
 	MarketBuyBuffer float64
+	// TradeFee is the operator's fee on each match, in basis points of the
+	// quantity received by each party.
+	TradeFee uint32
+	// MaxOrderAge is how long an order may remain booked before it is
+	// revoked. Zero means no limit.
+	MaxOrderAge time.Duration
+	// OrderQueueSize is the number of submitted orders that may be waiting
+	// for the market to stamp them. Zero means the default size.
+	OrderQueueSize uint32
+	// MaxEpochOrders is the maximum number of trade orders in an epoch. Zero
+	// means no limit.
+	MaxEpochOrders uint32
+	// MaxEpochBacklog is the number of closed epochs that may be waiting to be
+	// matched before trade orders are refused. Zero means the default.
+	MaxEpochBacklog uint32
+	// MinOrderValue is the minimum value of an order, in units of the quote
+	// asset. It is converted to a minimum order quantity at the spot rate.
+	// Zero means that orders only need to be at least one lot.
+	MinOrderValue uint64
+	// OddLotPolicy is how the remainder of a partially filled limit order is
+	// treated when it is less than the minimum order quantity. An empty
+	// policy is OddLotKeep.
+	OddLotPolicy OddLotPolicy
+}
+
+// OddLotPolicy is a market's treatment of odd lots. An odd lot is the
+// remainder of a partially filled limit order that is less than the market's
+// minimum order quantity. Since remainders are whole lots, only markets with a
+// minimum order value have odd lots.
+type OddLotPolicy string
+
+const (
+	// OddLotKeep keeps odd lots on the book, with the order's funds locked
+	// until the remainder is filled or canceled.
+	OddLotKeep OddLotPolicy = "keep"
+	// OddLotRelease rounds the order down to its filled quantity, taking the
+	// odd lot off the book and releasing its funds. The order is executed.
+	OddLotRelease OddLotPolicy = "release"
+)
+
+// ParseOddLotPolicy parses an odd lot policy. An empty string is OddLotKeep.
+func ParseOddLotPolicy(s string) (OddLotPolicy, error) {
+	switch policy := OddLotPolicy(strings.ToLower(s)); policy {
+	case "", OddLotKeep:
+		return OddLotKeep, nil
+	case OddLotRelease:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown odd lot policy %q", s)
 }
 
 func marketName(base, quote string) string {
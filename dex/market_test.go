@@ -87,3 +87,25 @@ func TestNewMarketInfoFromSymbols(t *testing.T) {
 		t.Errorf("NewMarketInfoFromSymbols succeeded for non-existent quote asset")
 	}
 }
+
+func TestParseOddLotPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    OddLotPolicy
+		wantErr bool
+	}{
+		{"", OddLotKeep, false},
+		{"keep", OddLotKeep, false},
+		{"Release", OddLotRelease, false},
+		{"round", "", true},
+	}
+	for _, tt := range tests {
+		policy, err := ParseOddLotPolicy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseOddLotPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if policy != tt.want {
+			t.Errorf("ParseOddLotPolicy(%q) = %q, want %q", tt.in, policy, tt.want)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package msgjson
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"math/rand"
 	"testing"
+
+	"github.com/decred/dcrd/crypto/blake256"
 )
 
 func TestMatch(t *testing.T) {
@@ -252,6 +255,306 @@ func TestRevokeMatch(t *testing.T) {
 	}
 }
 
+func TestRevokeOrder(t *testing.T) {
+	// serialization: order id (32)
+	oid, _ := hex.DecodeString("47b903b6e71a1fff3ec1be25b23228bf2e8682b1502dc451f7a9aa32556123f2")
+	revoke := &RevokeOrder{
+		OrderID: oid,
+	}
+
+	b := revoke.Serialize()
+	if !bytes.Equal(b, oid) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", oid, b)
+	}
+
+	revB, err := json.Marshal(revoke)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var revokeBack RevokeOrder
+	err = json.Unmarshal(revB, &revokeBack)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !bytes.Equal(revokeBack.OrderID, revoke.OrderID) {
+		t.Fatal(revokeBack.OrderID, revoke.OrderID)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	// serialization: blake256 hash of
+	//   pubkey (33) + timestamp (8) + 4 * (length (4) + text)
+	pk, _ := hex.DecodeString("02a7b10d5ad1a6a3d2f87afd6e2ae1fa2fa4e5b8a65d9c7ea2f4a9c3a4c6d2b1e7")
+	policy := &Policy{
+		DEXPubKey: pk,
+		Stamp:     1234567890123,
+		Fees:      "1 DCR",
+		Penalties: "",
+		Contact:   "ops@dex.example",
+		Terms:     "be nice",
+	}
+
+	exp := make([]byte, 0, 33+8+4*4+5+15+7)
+	exp = append(exp, pk...)
+	exp = append(exp, 0, 0, 0x01, 0x1f, 0x71, 0xfb, 0x04, 0xcb)
+	exp = append(exp, 0, 0, 0, 5)
+	exp = append(exp, "1 DCR"...)
+	exp = append(exp, 0, 0, 0, 0)
+	exp = append(exp, 0, 0, 0, 15)
+	exp = append(exp, "ops@dex.example"...)
+	exp = append(exp, 0, 0, 0, 7)
+	exp = append(exp, "be nice"...)
+	h := blake256.Sum256(exp)
+
+	b := policy.Serialize()
+	if !bytes.Equal(b, h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", exp, b)
+	}
+
+	// Moving text between fields must change the serialization.
+	shifted := *policy
+	shifted.Fees, shifted.Penalties = "", "1 DCR"
+	if bytes.Equal(shifted.Serialize(), b) {
+		t.Fatalf("serialization does not distinguish fields")
+	}
+
+	policyB, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var policyBack Policy
+	err = json.Unmarshal(policyB, &policyBack)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !bytes.Equal(policyBack.Serialize(), b) {
+		t.Fatalf("wrong serialization after unmarshal")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	// serialization: blake256 hash of
+	//   old pubkey (33) + new pubkey (33) + activation (8)
+	oldPK, _ := hex.DecodeString("02a7b10d5ad1a6a3d2f87afd6e2ae1fa2fa4e5b8a65d9c7ea2f4a9c3a4c6d2b1e7")
+	newPK, _ := hex.DecodeString("03c3cba2e8e7b9ed4c2f2e5c3e9f1d8b7a6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f")
+	kr := &KeyRotation{
+		OldPubKey:  oldPK,
+		NewPubKey:  newPK,
+		Activation: 1234567890123,
+	}
+
+	exp := make([]byte, 0, 74)
+	exp = append(exp, oldPK...)
+	exp = append(exp, newPK...)
+	exp = append(exp, 0, 0, 0x01, 0x1f, 0x71, 0xfb, 0x04, 0xcb)
+	h := blake256.Sum256(exp)
+
+	if !bytes.Equal(kr.Serialize(), h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", h, kr.Serialize())
+	}
+}
+
+func TestConfigResult(t *testing.T) {
+	pk, _ := hex.DecodeString("02a7b10d5ad1a6a3d2f87afd6e2ae1fa2fa4e5b8a65d9c7ea2f4a9c3a4c6d2b1e7")
+	persist := true
+	cfg := &ConfigResult{
+		CancelMax:        0.8,
+		BroadcastTimeout: 60000,
+		RegFeeConfirms:   4,
+		Fee:              1e8,
+		DEXPubKey:        pk,
+		Assets: []*Asset{{
+			Symbol:   "dcr",
+			ID:       42,
+			LotSize:  1e8,
+			RateStep: 1e5,
+			SwapConf: 1,
+		}, {
+			Symbol:   "btc",
+			ID:       0,
+			LotSize:  1e5,
+			RateStep: 100,
+			SwapConf: 2,
+		}},
+		Markets: []*Market{{
+			Name:            "dcr_btc",
+			Base:            42,
+			Quote:           0,
+			EpochLen:        10000,
+			MarketBuyBuffer: 1.5,
+			MarketStatus: MarketStatus{
+				StartEpoch: 5,
+			},
+		}},
+	}
+	b := cfg.Serialize()
+	if len(b) != 32 {
+		t.Fatalf("serialization is not a hash. length %d", len(b))
+	}
+
+	cfgB, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	var cfgBack ConfigResult
+	err = json.Unmarshal(cfgB, &cfgBack)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !bytes.Equal(cfgBack.Serialize(), b) {
+		t.Fatalf("wrong serialization after unmarshal")
+	}
+
+	// A market suspension must change the serialization.
+	cfg.Markets[0].FinalEpoch = 10
+	cfg.Markets[0].Persist = &persist
+	suspended := cfg.Serialize()
+	if bytes.Equal(suspended, b) {
+		t.Fatalf("serialization does not commit to the market status")
+	}
+
+	// So must a key rotation.
+	cfg.KeyRotation = &KeyRotation{
+		OldPubKey:  pk,
+		NewPubKey:  pk,
+		Activation: 1234567890123,
+		OldSig:     []byte{0x01},
+		NewSig:     []byte{0x02},
+	}
+	rotated := cfg.Serialize()
+	if bytes.Equal(rotated, suspended) {
+		t.Fatalf("serialization does not commit to the key rotation")
+	}
+	cfg.KeyRotation.NewSig = []byte{0x03}
+	if bytes.Equal(cfg.Serialize(), rotated) {
+		t.Fatalf("serialization does not commit to the key rotation signatures")
+	}
+
+	// And the API version and upgrade.
+	rotated = cfg.Serialize()
+	cfg.APIVersion = APIVersion
+	versioned := cfg.Serialize()
+	if bytes.Equal(versioned, rotated) {
+		t.Fatalf("serialization does not commit to the API version")
+	}
+	cfg.Upgrade = &Upgrade{
+		APIVersion: APIVersion + 1,
+		Deadline:   1234567890123,
+		Notes:      "upgrade to v0.3",
+	}
+	upgrade := cfg.Serialize()
+	if bytes.Equal(upgrade, versioned) {
+		t.Fatalf("serialization does not commit to the upgrade")
+	}
+	cfg.Upgrade.Deadline++
+	if bytes.Equal(cfg.Serialize(), upgrade) {
+		t.Fatalf("serialization does not commit to the upgrade deadline")
+	}
+}
+
+func TestAppeal(t *testing.T) {
+	// serialization: blake256 hash of
+	//   account ID (32) + timestamp (8) + message (varies)
+	acctID, _ := hex.DecodeString("14ae3cbc703587122d68ac6fa9194dfdc8466fb5dec9f47d2805374adff3e016")
+	appeal := &Appeal{
+		AccountID: acctID,
+		Message:   "my wallet crashed",
+	}
+	appeal.Stamp(1585005151210)
+
+	exp := make([]byte, 0, 40+len(appeal.Message))
+	exp = append(exp, acctID...)
+	exp = append(exp, 0, 0, 0x01, 0x71, 0x09, 0xab, 0x43, 0xea)
+	exp = append(exp, "my wallet crashed"...)
+	h := blake256.Sum256(exp)
+
+	b := appeal.Serialize()
+	if !bytes.Equal(b, h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", h, b)
+	}
+
+	appealB, err := json.Marshal(appeal)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var appealBack Appeal
+	err = json.Unmarshal(appealB, &appealBack)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !bytes.Equal(appealBack.Serialize(), b) {
+		t.Fatalf("wrong serialization after unmarshal")
+	}
+}
+
+func TestAppealDecision(t *testing.T) {
+	// serialization: blake256 hash of
+	//   account ID (32) + accepted (1) + reason (varies)
+	acctID, _ := hex.DecodeString("14ae3cbc703587122d68ac6fa9194dfdc8466fb5dec9f47d2805374adff3e016")
+	decision := &AppealDecision{
+		AccountID: acctID,
+		Reason:    "repeat offender",
+	}
+
+	exp := make([]byte, 0, 33+len(decision.Reason))
+	exp = append(exp, acctID...)
+	exp = append(exp, 0)
+	exp = append(exp, "repeat offender"...)
+	h := blake256.Sum256(exp)
+
+	b := decision.Serialize()
+	if !bytes.Equal(b, h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", h, b)
+	}
+
+	decision.Accepted = true
+	if bytes.Equal(decision.Serialize(), b) {
+		t.Fatalf("serialization does not commit to the decision")
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	// serialization: blake256 hash of
+	//   account ID (32) + rule (1) + banned (1) + time (8) + details (varies) +
+	//   match IDs (32 each) + appeal (varies)
+	acctID, _ := hex.DecodeString("14ae3cbc703587122d68ac6fa9194dfdc8466fb5dec9f47d2805374adff3e016")
+	mid, _ := hex.DecodeString("6ea1227b03d7bf05ce1e23f3edf57368f69ba9ee0cc069f09ab0952a36d964c5")
+	penalty := &Penalty{
+		AccountID: acctID,
+		Rule:      1,
+		Banned:    true,
+		Time:      1600000000000,
+		Details:   "failed to act",
+		MatchIDs:  []Bytes{mid},
+		Appeal:    "appeal",
+	}
+
+	exp := make([]byte, 0, 42+len(penalty.Details)+32+len(penalty.Appeal))
+	exp = append(exp, acctID...)
+	exp = append(exp, 1, 1)
+	exp = append(exp, uint64Bytes(1600000000000)...)
+	exp = append(exp, "failed to act"...)
+	exp = append(exp, mid...)
+	exp = append(exp, "appeal"...)
+	h := blake256.Sum256(exp)
+
+	b := penalty.Serialize()
+	if !bytes.Equal(b, h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", h, b)
+	}
+
+	penalty.MatchIDs = nil
+	if bytes.Equal(penalty.Serialize(), b) {
+		t.Fatalf("serialization does not commit to the evidence")
+	}
+}
+
 func TestRedeem(t *testing.T) {
 	// Redeem serialization is orderid (32) + matchid (32) + coin ID (36) +
 	// secret (32) = 132
@@ -374,6 +677,72 @@ func TestRedemption(t *testing.T) {
 	}
 }
 
+func TestSettlementReceipt(t *testing.T) {
+	// serialization: blake256 hash of
+	//   matchid (32) + maker orderid (32) + taker orderid (32) +
+	// base (4) + quote (4) + qty (8) + rate (8) + 4 * (length (4) + coin ID) +
+	// timestamp (8)
+	mid := bytes.Repeat([]byte{0x01}, 32)
+	makerOID := bytes.Repeat([]byte{0x02}, 32)
+	takerOID := bytes.Repeat([]byte{0x03}, 32)
+	receipt := &SettlementReceipt{
+		MatchID:      mid,
+		MakerOrderID: makerOID,
+		TakerOrderID: takerOID,
+		Base:         42,
+		Quote:        0,
+		Quantity:     1e8,
+		Rate:         2e6,
+		MakerSwap:    []byte{0x0a, 0x0b},
+		TakerSwap:    []byte{0x0c},
+		MakerRedeem:  []byte{0x0d, 0x0e, 0x0f},
+		TakerRedeem:  []byte{},
+		Time:         1570706834,
+	}
+
+	exp := make([]byte, 0, 136+4*4+6)
+	exp = append(exp, mid...)
+	exp = append(exp, makerOID...)
+	exp = append(exp, takerOID...)
+	exp = append(exp, 0, 0, 0, 42)
+	exp = append(exp, 0, 0, 0, 0)
+	exp = append(exp, 0, 0, 0, 0, 0x05, 0xf5, 0xe1, 0x00)
+	exp = append(exp, 0, 0, 0, 0, 0, 0x1e, 0x84, 0x80)
+	exp = append(exp, 0, 0, 0, 2, 0x0a, 0x0b)
+	exp = append(exp, 0, 0, 0, 1, 0x0c)
+	exp = append(exp, 0, 0, 0, 3, 0x0d, 0x0e, 0x0f)
+	exp = append(exp, 0, 0, 0, 0)
+	exp = append(exp, 0, 0, 0, 0, 0x5d, 0x9f, 0x15, 0x92)
+	h := blake256.Sum256(exp)
+
+	b := receipt.Serialize()
+	if !bytes.Equal(b, h[:]) {
+		t.Fatalf("unexpected serialization. Wanted %x, got %x", exp, b)
+	}
+
+	// Moving bytes between coin IDs must change the serialization.
+	shifted := *receipt
+	shifted.TakerSwap, shifted.MakerRedeem = []byte{0x0c, 0x0d}, []byte{0x0e, 0x0f}
+	if bytes.Equal(shifted.Serialize(), b) {
+		t.Fatalf("serialization does not distinguish coin IDs")
+	}
+
+	receiptB, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var receiptBack SettlementReceipt
+	err = json.Unmarshal(receiptB, &receiptBack)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !bytes.Equal(receiptBack.Serialize(), b) {
+		t.Fatalf("wrong serialization after unmarshal")
+	}
+}
+
 func TestPrefix(t *testing.T) {
 	// serialization: account ID (32) + base asset (4) + quote asset (4) +
 	// order type (1), client time (8), server time (8) = 57 bytes
@@ -578,6 +947,33 @@ func TestLimit(t *testing.T) {
 	if limitBack.TiF != limit.TiF {
 		t.Fatal(limitBack.TiF, limit.TiF)
 	}
+
+	// The visible quantity of an iceberg order precedes the address.
+	limit.Visible = 200_000_000
+	b = limit.Serialize()
+	b = b[len(b)-len(exp)-8:]
+	icebergExp := append([]byte{
+		// Rate 8 bytes
+		0x00, 0x00, 0x00, 0x00, 0x14, 0xdc, 0x93, 0x80,
+		// Time-in-force 1 byte
+		0x01,
+		// Visible 8 bytes
+		0x00, 0x00, 0x00, 0x00, 0x0b, 0xeb, 0xc2, 0x00,
+	}, exp[9:]...)
+	if !bytes.Equal(icebergExp, b) {
+		t.Fatal(icebergExp, b)
+	}
+	limitB, err = json.Marshal(limit)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	limitBack = LimitOrder{}
+	if err = json.Unmarshal(limitB, &limitBack); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if limitBack.Visible != limit.Visible {
+		t.Fatal(limitBack.Visible, limit.Visible)
+	}
 }
 
 func TestMarket(t *testing.T) {
@@ -1107,3 +1503,39 @@ func randomCoin() *Coin {
 		Redeem:  randomBytes(25),
 	}
 }
+
+func TestPoW(t *testing.T) {
+	data := []byte("register")
+	const difficulty = 12
+	nonce, err := SolvePoW(context.Background(), data, difficulty)
+	if err != nil {
+		t.Fatalf("SolvePoW error: %v", err)
+	}
+	if !CheckPoW(data, nonce, difficulty) {
+		t.Fatalf("solution %d does not check", nonce)
+	}
+	// The solution is bound to the data.
+	if CheckPoW([]byte("connect"), nonce, difficulty) && CheckPoW([]byte("other"), nonce, difficulty) {
+		t.Fatalf("solution checks for other data")
+	}
+	if !CheckPoW(data, nonce+1, 0) {
+		t.Fatalf("zero difficulty not satisfied")
+	}
+
+	if _, err := SolvePoW(context.Background(), data, MaxPoWBits+1); err == nil {
+		t.Fatalf("no error for excessive difficulty")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SolvePoW(ctx, data, MaxPoWBits); err == nil {
+		t.Fatalf("no error for canceled context")
+	}
+
+	parsed, ok := PoWRequired(NewPoWRequiredError(20))
+	if !ok || parsed != 20 {
+		t.Fatalf("wrong difficulty parsed: %d, %t", parsed, ok)
+	}
+	if _, ok := PoWRequired(NewError(SignatureError, "proof of work required: 20 bits")); ok {
+		t.Fatalf("difficulty parsed from wrong error code")
+	}
+}
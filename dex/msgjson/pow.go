@@ -0,0 +1,84 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package msgjson
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// MaxPoWBits is the highest proof-of-work difficulty that a server may
+// require. A client should refuse to solve a harder puzzle.
+const MaxPoWBits = 24
+
+// powRequiredFmt is the message format of a PoWRequiredError. The difficulty
+// is parsed from the message by PoWRequired.
+const powRequiredFmt = "proof of work required: %d bits"
+
+// NewPoWRequiredError creates the error sent in response to a 'register' or
+// 'connect' request without a valid proof of work, when the server requires
+// one with the difficulty.
+func NewPoWRequiredError(difficulty uint8) *Error {
+	return NewError(PoWRequiredError, fmt.Sprintf(powRequiredFmt, difficulty))
+}
+
+// PoWRequired parses the difficulty from a PoWRequiredError. The bool is false
+// if the error is not a PoWRequiredError.
+func PoWRequired(msgErr *Error) (uint8, bool) {
+	if msgErr == nil || msgErr.Code != PoWRequiredError {
+		return 0, false
+	}
+	var difficulty uint8
+	if _, err := fmt.Sscanf(msgErr.Message, powRequiredFmt, &difficulty); err != nil {
+		return 0, false
+	}
+	return difficulty, true
+}
+
+// powHash is the SHA-256 hash of the data followed by the big-endian nonce.
+func powHash(data []byte, nonce uint64) [32]byte {
+	b := make([]byte, len(data)+8)
+	copy(b, data)
+	binary.BigEndian.PutUint64(b[len(data):], nonce)
+	return sha256.Sum256(b)
+}
+
+// leadingZeros is the number of leading zero bits of the hash.
+func leadingZeros(h [32]byte) int {
+	var n int
+	for i := 0; i < len(h); i += 8 {
+		z := bits.LeadingZeros64(binary.BigEndian.Uint64(h[i : i+8]))
+		n += z
+		if z < 64 {
+			break
+		}
+	}
+	return n
+}
+
+// CheckPoW checks that the hash of the data and nonce has at least difficulty
+// leading zero bits. The data is the serialization of the signed message, so a
+// solution cannot be reused for another account or time.
+func CheckPoW(data []byte, nonce uint64, difficulty uint8) bool {
+	return leadingZeros(powHash(data, nonce)) >= int(difficulty)
+}
+
+// SolvePoW finds a nonce for which the hash of the data and nonce has at least
+// difficulty leading zero bits. The search takes about 2^difficulty hashes.
+func SolvePoW(ctx context.Context, data []byte, difficulty uint8) (uint64, error) {
+	if difficulty > MaxPoWBits {
+		return 0, fmt.Errorf("proof of work difficulty %d exceeds the maximum of %d", difficulty, MaxPoWBits)
+	}
+	for nonce := uint64(0); ; nonce++ {
+		if nonce%(1<<16) == 0 && ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if CheckPoW(data, nonce, difficulty) {
+			return nonce, nil
+		}
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 
 	"decred.org/dcrdex/dex"
+	"github.com/decred/dcrd/crypto/blake256"
 )
 
 // Error codes
@@ -62,8 +64,48 @@ const (
 	AccountClosedError                // 46
 	MarketNotRunningError             // 47
 	TryAgainLaterError                // 48
+	AppealError                       // 49
+	RPCExportError                    // 50
+	RPCMarketMakerError               // 51
+	RPCDeviceError                    // 52
+	RPCRecoveryError                  // 53
+	RPCBackupError                    // 54
+	RPCOrdersError                    // 55
+	ServerDrainingError               // 56
+	InsufficientFundsError            // 57
+	LotSizeError                      // 58
+	RateStepError                     // 59
+	MarketSuspendedError              // 60
+	AccountSuspendedError             // 61
+	EpochReplayError                  // 62
+	RPCReadOnlyError                  // 63
+	MarketOverloadedError             // 64
+	RPCCoinsError                     // 65
+	PoWRequiredError                  // 66
+	OrderValueError                   // 67
+	OrderLimitError                   // 68
+	RegistrationDeniedError           // 69
 )
 
+// APIVersion is the current version of the DEX protocol. Version 1 added the
+// client-originating routes listed in RouteVersions.
+const APIVersion uint16 = 1
+
+// RouteVersions are the API versions that introduced client-originating
+// request routes. Routes that are not listed are part of version 0. A client
+// should not send a request on a route that is newer than the API version
+// advertised by the server.
+var RouteVersions = map[string]uint16{
+	AppealRoute:        1,
+	PolicyRoute:        1,
+	OrderStatusRoute:   1,
+	MatchStatusRoute:   1,
+	CandlesRoute:       1,
+	MatchReceiptRoute:  1,
+	AccountExportRoute: 1,
+	EpochReplayRoute:   1,
+}
+
 // Routes are destinations for a "payload" of data. The type of data being
 // delivered, and what kind of action is expected from the receiving party, is
 // completely dependent on the route. The route designation is a string sent as
@@ -90,6 +132,10 @@ const (
 	// RevokeMatchRoute is a DEX-originating request-type message informing a
 	// client that a match has been revoked.
 	RevokeMatchRoute = "revoke_match"
+	// RevokeOrderRoute is a DEX-originating notification-type message
+	// informing a client that a booked or epoch order has been revoked, such as
+	// when its market is delisted.
+	RevokeOrderRoute = "revoke_order"
 	// LimitRoute is the client-originating request-type message placing a limit
 	// order.
 	LimitRoute = "limit"
@@ -130,6 +176,19 @@ const (
 	// ConfigRoute is the client-originating request-type message requesting the
 	// DEX configuration information.
 	ConfigRoute = "config"
+	// AppealRoute is the client-originating request-type message appealing the
+	// penalty that closed the client's account.
+	AppealRoute = "appeal"
+	// AppealDecisionRoute is the DEX-originating notification-type message
+	// informing the client of the operator's decision on their appeal.
+	AppealDecisionRoute = "appeal_decision"
+	// PenaltyRoute is the DEX-originating notification-type message informing
+	// the client that its account was closed for a rule violation, or that a
+	// closed account was reopened.
+	PenaltyRoute = "penalty"
+	// PolicyRoute is the client-originating request-type message requesting
+	// the operator's signed policy document.
+	PolicyRoute = "policy"
 	// MatchProofRoute is the DEX-originating notification-type message
 	// delivering match cycle results to the client.
 	MatchProofRoute = "match_proof"
@@ -144,6 +203,41 @@ const (
 	// client of an upcoming trade resumption. This is part of the
 	// subscription-based orderbook notification feed.
 	ResumptionRoute = "resumption"
+	// OrderStatusRoute is the client-originating request-type message
+	// requesting the server's status of the client's orders, such as after a
+	// lost connection.
+	OrderStatusRoute = "order_status"
+	// MatchStatusRoute is the client-originating request-type message
+	// requesting the server's record of the client's matches, including the
+	// counterparty's swap and redemption, such as after a lost connection.
+	MatchStatusRoute = "match_status"
+	// CandlesRoute is the client-originating request-type message requesting
+	// the OHLC candlestick data for a market over a time range.
+	CandlesRoute = "candles"
+	// SpotsRoute is the DEX-originating notification-type message carrying the
+	// current spot prices for the DEX's markets.
+	SpotsRoute = "spots"
+	// ReceiptRoute is the DEX-originating notification-type message delivering
+	// a signed settlement receipt to both parties of a completed match.
+	ReceiptRoute = "receipt"
+	// SettlementProgressRoute is the DEX-originating notification-type message
+	// informing both parties of a match that the match reached a settlement
+	// milestone.
+	SettlementProgressRoute = "settlement_progress"
+	// MatchReceiptRoute is the client-originating request-type message
+	// requesting the signed settlement receipts for the client's completed
+	// matches.
+	MatchReceiptRoute = "match_receipt"
+	// AccountExportRoute is the client-originating request-type message
+	// requesting one page of the server's record of the client's orders and
+	// matches on a market, such as to rebuild the client's history after a
+	// database loss.
+	AccountExportRoute = "account_export"
+	// EpochReplayRoute is the client-originating request-type message
+	// requesting the ordered epoch queue and matching inputs for a past epoch
+	// that included one of the client's orders, so that the client can re-run
+	// the matching algorithm to verify the results.
+	EpochReplayRoute = "epoch_replay"
 )
 
 type Bytes = dex.Bytes
@@ -199,6 +293,12 @@ func (e Error) String() string {
 	return fmt.Sprintf("error code %d: %s", e.Code, e.Message)
 }
 
+// Error satisfies the error interface, allowing the code to be retrieved from a
+// returned error with errors.As.
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: %d: %s", e.Code, e.Message)
+}
+
 // NewError is a constructor for an Error.
 func NewError(code int, msg string) *Error {
 	return &Error{
@@ -346,14 +446,14 @@ func (msg *Message) Unmarshal(payload interface{}) error {
 }
 
 // UnmarshalResult is a convenience method for decoding the Result field of a
-// ResponsePayload.
+// ResponsePayload. If the response has an error, the *Error is returned.
 func (msg *Message) UnmarshalResult(result interface{}) error {
 	resp, err := msg.Response()
 	if err != nil {
 		return err
 	}
 	if resp.Error != nil {
-		return fmt.Errorf("rpc error: %d: %s", resp.Error.Code, resp.Error.Message)
+		return resp.Error
 	}
 	return json.Unmarshal(resp.Result, result)
 }
@@ -465,6 +565,22 @@ func (rev *RevokeMatch) Serialize() []byte {
 	return append(s, rev.MatchID...)
 }
 
+// RevokeOrder are the params for a DEX-originating RevokeOrderRoute
+// notification.
+type RevokeOrder struct {
+	Signature
+	OrderID Bytes `json:"orderid"`
+}
+
+var _ Signable = (*RevokeOrder)(nil)
+
+// Serialize serializes the RevokeOrder data.
+func (rev *RevokeOrder) Serialize() []byte {
+	// RevokeOrder serialization is order id (32) = 32 bytes
+	s := make([]byte, 0, 32)
+	return append(s, rev.OrderID...)
+}
+
 // Redeem are the params for a client-originating RedeemRoute request.
 type Redeem struct {
 	Signature
@@ -579,23 +695,30 @@ func (t *Trade) Serialize() []byte {
 }
 
 // LimitOrder is the payload for the LimitRoute, which places a limit order.
+// Visible is set for an iceberg order, and is the most of the order's
+// remaining quantity that is shown in order book feeds.
 type LimitOrder struct {
 	Prefix
 	Trade
-	Rate uint64 `json:"rate"`
-	TiF  uint8  `json:"timeinforce"`
+	Rate    uint64 `json:"rate"`
+	TiF     uint8  `json:"timeinforce"`
+	Visible uint64 `json:"visible,omitempty"`
 }
 
 // Serialize serializes the Limit data.
 func (l *LimitOrder) Serialize() []byte {
 	// serialization: prefix (89) + trade (variable) + rate (8)
-	// + time-in-force (1) + address (~35) = 133 + len(trade)
+	// + time-in-force (1) + visible (0 or 8) + address (~35)
+	// = 141 + len(trade)
 	trade := l.Trade.Serialize()
-	b := make([]byte, 0, 133+len(trade))
+	b := make([]byte, 0, 141+len(trade))
 	b = append(b, l.Prefix.Serialize()...)
 	b = append(b, trade...)
 	b = append(b, uint64Bytes(l.Rate)...)
 	b = append(b, l.TiF)
+	if l.Visible > 0 {
+		b = append(b, uint64Bytes(l.Visible)...)
+	}
 	return append(b, []byte(l.Trade.Address)...)
 }
 
@@ -749,6 +872,13 @@ type Connect struct {
 	AccountID  Bytes  `json:"accountid"`
 	APIVersion uint16 `json:"apiver"`
 	Time       uint64 `json:"timestamp"`
+	// UserAgent identifies the client software, e.g. dexc/0.1.0. It is
+	// informational, and is not part of the signed serialization.
+	UserAgent string `json:"useragent,omitempty"`
+	// Nonce is the proof-of-work solution for the serialized Connect, when
+	// the server requires one. See CheckPoW. It is not part of the signed
+	// serialization.
+	Nonce uint64 `json:"nonce,omitempty"`
 }
 
 // Serialize serializes the Connect data.
@@ -766,11 +896,354 @@ type ConnectResult struct {
 	Matches []*Match `json:"matches"`
 }
 
+// OrderStatusRequest identifies one of the client's orders. The payload of the
+// OrderStatusRoute request is a []*OrderStatusRequest.
+type OrderStatusRequest struct {
+	Base    uint32 `json:"base"`
+	Quote   uint32 `json:"quote"`
+	OrderID Bytes  `json:"orderid"`
+}
+
+// OrderStatus is the server's status of an order. The result of the
+// OrderStatusRoute request is a []*OrderStatus. Orders that are not known to
+// the server are not included.
+type OrderStatus struct {
+	ID     Bytes  `json:"id"`
+	Status uint16 `json:"status"`
+}
+
+// MatchStatusRequest identifies one of the client's matches. The payload of
+// the MatchStatusRoute request is a []*MatchStatusRequest.
+type MatchStatusRequest struct {
+	Base    uint32 `json:"base"`
+	Quote   uint32 `json:"quote"`
+	MatchID Bytes  `json:"matchid"`
+}
+
+// MatchStatusResult is the server's record of a match. The result of the
+// MatchStatusRoute request is a []*MatchStatusResult. Matches that are not
+// known to the server are not included. The Match, Audit, and Redemption are
+// signed by the server, and are the messages that the client would have
+// received in the MatchRoute, AuditRoute, and RedemptionRoute requests. Audit
+// and Redemption are for the counterparty's swap and redemption, and are nil
+// if the counterparty has not sent them.
+type MatchStatusResult struct {
+	MatchID    Bytes       `json:"matchid"`
+	Status     uint8       `json:"status"`
+	Active     bool        `json:"active"`
+	Match      *Match      `json:"match"`
+	Audit      *Audit      `json:"audit,omitempty"`
+	Redemption *Redemption `json:"redemption,omitempty"`
+}
+
+// SettlementReceipt is the server's signed proof that a match was fully
+// settled. The receipt is the payload of the ReceiptRoute notification, and
+// the result of the MatchReceiptRoute request is a []*SettlementReceipt, for
+// which the payload is a []*MatchStatusRequest. Matches that are not complete
+// are not included in the result. Time is the time that the server accepted
+// the taker's redemption, completing the match.
+type SettlementReceipt struct {
+	Signature
+	MatchID      Bytes  `json:"matchid"`
+	MakerOrderID Bytes  `json:"makerorderid"`
+	TakerOrderID Bytes  `json:"takerorderid"`
+	Base         uint32 `json:"base"`
+	Quote        uint32 `json:"quote"`
+	Quantity     uint64 `json:"qty"`
+	Rate         uint64 `json:"rate"`
+	MakerSwap    Bytes  `json:"makerswap"`
+	TakerSwap    Bytes  `json:"takerswap"`
+	MakerRedeem  Bytes  `json:"makerredeem"`
+	TakerRedeem  Bytes  `json:"takerredeem"`
+	Time         uint64 `json:"timestamp"`
+}
+
+var _ Signable = (*SettlementReceipt)(nil)
+
+// Serialize serializes the SettlementReceipt data. Since a signature only
+// commits to a 32-byte message, the serialization is the blake256 hash of the
+// receipt's encoding, match ID (32) + maker order ID (32) + taker order ID (32)
+// + base (4) + quote (4) + quantity (8) + rate (8) + 4 * (length (4) + coin ID
+// (varies)) + timestamp (8).
+func (r *SettlementReceipt) Serialize() []byte {
+	coinIDs := []Bytes{r.MakerSwap, r.TakerSwap, r.MakerRedeem, r.TakerRedeem}
+	sz := 136
+	for _, coinID := range coinIDs {
+		sz += 4 + len(coinID)
+	}
+	b := make([]byte, 0, sz)
+	b = append(b, r.MatchID...)
+	b = append(b, r.MakerOrderID...)
+	b = append(b, r.TakerOrderID...)
+	b = append(b, uint32Bytes(r.Base)...)
+	b = append(b, uint32Bytes(r.Quote)...)
+	b = append(b, uint64Bytes(r.Quantity)...)
+	b = append(b, uint64Bytes(r.Rate)...)
+	for _, coinID := range coinIDs {
+		b = append(b, uint32Bytes(uint32(len(coinID)))...)
+		b = append(b, coinID...)
+	}
+	b = append(b, uint64Bytes(r.Time)...)
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
+// Settlement milestones reported with the SettlementProgressRoute
+// notification.
+const (
+	// MakerSwapConfirmed is reached when the maker's swap has the swap
+	// asset's required confirmations. CoinID is the maker's swap.
+	MakerSwapConfirmed = "makerswapconfirmed"
+	// TakerSwapConfirmed is reached when the taker's swap has the swap
+	// asset's required confirmations. CoinID is the taker's swap.
+	TakerSwapConfirmed = "takerswapconfirmed"
+	// MakerRedeemed is reached when the server accepts the maker's
+	// redemption of the taker's swap. CoinID is the maker's redemption.
+	MakerRedeemed = "makerredeemed"
+	// TakerRedeemed is reached when the server accepts the taker's
+	// redemption of the maker's swap, completing the match. CoinID is the
+	// taker's redemption.
+	TakerRedeemed = "takerredeemed"
+)
+
+// SettlementProgress is the payload of the SettlementProgressRoute
+// notification. AssetID is the asset of the transaction with CoinID. Time is
+// when the server saw the milestone, in milliseconds. The notifications are not
+// queued for disconnected clients, which learn the status of their matches
+// with a match_status request when they reconnect.
+type SettlementProgress struct {
+	MatchID      Bytes  `json:"matchid"`
+	MakerOrderID Bytes  `json:"makerorderid"`
+	TakerOrderID Bytes  `json:"takerorderid"`
+	Milestone    string `json:"milestone"`
+	AssetID      uint32 `json:"assetid"`
+	CoinID       Bytes  `json:"coinid"`
+	Time         uint64 `json:"timestamp"`
+}
+
+// AccountExportRequest is the payload for the AccountExportRoute request.
+// Pages are numbered from zero.
+type AccountExportRequest struct {
+	Base  uint32 `json:"base"`
+	Quote uint32 `json:"quote"`
+	Page  uint32 `json:"page"`
+}
+
+// ExportedOrder is one of the client's orders in an AccountExportResult. Order
+// is the order's binary encoding, as produced by order.EncodeOrder.
+type ExportedOrder struct {
+	Order  Bytes  `json:"order"`
+	Status uint16 `json:"status"`
+}
+
+// AccountExportResult is the result of the AccountExportRoute request, with
+// one page of the client's orders and matches on the requested market. The
+// matches are the same signed records returned by the MatchStatusRoute.
+// BrokenRule is the rule that the account was closed for violating, or zero if
+// the account is open. More indicates that there are more pages.
+type AccountExportResult struct {
+	Orders     []*ExportedOrder     `json:"orders"`
+	Matches    []*MatchStatusResult `json:"matches"`
+	BrokenRule uint8                `json:"brokenrule"`
+	More       bool                 `json:"more"`
+}
+
+// EpochReplayRequest is the payload for the EpochReplayRoute request. OrderID
+// is the client's order in the requested epoch.
+type EpochReplayRequest struct {
+	Base    uint32 `json:"base"`
+	Quote   uint32 `json:"quote"`
+	Epoch   uint64 `json:"epoch"`
+	OrderID Bytes  `json:"orderid"`
+}
+
+// ReplayOrder is an order in an EpochReplayResult. Order is the binary encoding
+// of the order with the account ID, funding coins, and swap address removed, as
+// produced by order.RedactOrder, so the OrderID of the original order is
+// provided. Preimage is set for epoch queue orders.
+type ReplayOrder struct {
+	OrderID  Bytes `json:"oid"`
+	Order    Bytes `json:"order"`
+	Preimage Bytes `json:"pimg,omitempty"`
+}
+
+// EpochReplayResult is the result of the EpochReplayRoute request. Queue is the
+// epoch queue with the revealed preimages. Book is the
+// book orders from before the epoch that were matched or canceled during the
+// epoch, with their pre-epoch fill amounts. Replaying the queue against a book
+// with these orders, with the market's TradeFee rate and the OddLotQty below
+// which partial fill remainders were released, must produce the Seed and the
+// Result digest of the matches and order updates.
+type EpochReplayResult struct {
+	MarketID  string         `json:"marketid"`
+	Epoch     uint64         `json:"epoch"`
+	Duration  uint64         `json:"duration"`
+	LotSize   uint64         `json:"lotsize"`
+	TradeFee  uint32         `json:"tradefee"`
+	OddLotQty uint64         `json:"oddlotqty"`
+	Book      []*ReplayOrder `json:"book"`
+	Queue     []*ReplayOrder `json:"queue"`
+	Seed      Bytes          `json:"seed"`
+	Result    Bytes          `json:"result"`
+}
+
+// CandlesRequest is the payload for a client-originating request to the
+// CandlesRoute. BinSize is the candle duration, and Start and End bound the
+// candles' start stamps. All are in milliseconds. A zero End requests candles
+// up to the present.
+type CandlesRequest struct {
+	Base    uint32 `json:"base"`
+	Quote   uint32 `json:"quote"`
+	BinSize uint64 `json:"binsize"`
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end"`
+}
+
+// Candle is the OHLC data for a market over one bin. The result of the
+// CandlesRoute request is a []*Candle, sorted by StartStamp. Bins with no
+// matches are omitted. The last candle may be for a bin that is still in
+// progress.
+type Candle struct {
+	StartStamp  uint64 `json:"startStamp"`
+	EndStamp    uint64 `json:"endStamp"`
+	MatchVolume uint64 `json:"matchVolume"`
+	QuoteVolume uint64 `json:"quoteVolume"`
+	HighRate    uint64 `json:"highRate"`
+	LowRate     uint64 `json:"lowRate"`
+	StartRate   uint64 `json:"startRate"`
+	EndRate     uint64 `json:"endRate"`
+}
+
+// Spot is a market's spot price. The payload of the SpotsRoute notification is
+// a []*Spot. Rate is the rate of the most recent match, Change24 is the
+// fractional rate change over the last 24 hours, and Vol24 is the base asset
+// volume matched over the last 24 hours.
+type Spot struct {
+	Stamp      uint64  `json:"stamp"`
+	BaseID     uint32  `json:"baseID"`
+	QuoteID    uint32  `json:"quoteID"`
+	Rate       uint64  `json:"rate"`
+	BookVolume uint64  `json:"bookVolume"`
+	Change24   float64 `json:"change24"`
+	Vol24      uint64  `json:"vol24"`
+}
+
+// Appeal is the payload for a client-originating AppealRoute request.
+type Appeal struct {
+	Signature
+	AccountID Bytes  `json:"accountid"`
+	Message   string `json:"message"`
+	Time      uint64 `json:"timestamp"`
+}
+
+var _ Stampable = (*Appeal)(nil)
+
+// Stamp sets the appeal timestamp.
+func (a *Appeal) Stamp(t uint64) {
+	a.Time = t
+}
+
+// Serialize serializes the Appeal data. The message is of arbitrary length,
+// but only 32 bytes are committed to by a signature, so the serialization is
+// the blake256 hash of account ID (32) + timestamp (8) + message (varies).
+func (a *Appeal) Serialize() []byte {
+	b := make([]byte, 0, 40+len(a.Message))
+	b = append(b, a.AccountID...)
+	b = append(b, uint64Bytes(a.Time)...)
+	b = append(b, a.Message...)
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
+// Appeal statuses, as reported in AppealResult.
+const (
+	AppealPending  = "pending"
+	AppealAccepted = "accepted"
+	AppealRejected = "rejected"
+)
+
+// AppealResult is the result for the AppealRoute request. The status is one of
+// AppealPending, AppealAccepted, or AppealRejected. A rejected appeal is
+// final, and resubmitting it returns the operator's reason.
+type AppealResult struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// AppealDecision is the payload for a DEX-originating AppealDecisionRoute
+// notification.
+type AppealDecision struct {
+	Signature
+	AccountID Bytes  `json:"accountid"`
+	Accepted  bool   `json:"accepted"`
+	Reason    string `json:"reason"`
+}
+
+var _ Signable = (*AppealDecision)(nil)
+
+// Serialize serializes the AppealDecision data, which is the blake256 hash of
+// account ID (32) + accepted (1) + reason (varies).
+func (d *AppealDecision) Serialize() []byte {
+	b := make([]byte, 0, 33+len(d.Reason))
+	b = append(b, d.AccountID...)
+	if d.Accepted {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, d.Reason...)
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
+// Penalty is the payload for a DEX-originating PenaltyRoute notification.
+// Banned is true if the account was closed for violating the Rule, and false if
+// the account was reopened. MatchIDs reference the matches that are evidence of
+// the violation, if any. Appeal describes how to appeal a ban.
+type Penalty struct {
+	Signature
+	AccountID Bytes   `json:"accountid"`
+	Rule      uint8   `json:"rule"`
+	Banned    bool    `json:"banned"`
+	Time      uint64  `json:"timestamp"`
+	Details   string  `json:"details"`
+	MatchIDs  []Bytes `json:"matchids,omitempty"`
+	Appeal    string  `json:"appeal,omitempty"`
+}
+
+var _ Signable = (*Penalty)(nil)
+
+// Serialize serializes the Penalty data, which is the blake256 hash of account
+// ID (32) + rule (1) + banned (1) + time (8) + details (varies) + match IDs
+// (32 each) + appeal (varies).
+func (p *Penalty) Serialize() []byte {
+	b := make([]byte, 0, 42+len(p.Details)+32*len(p.MatchIDs)+len(p.Appeal))
+	b = append(b, p.AccountID...)
+	b = append(b, p.Rule)
+	if p.Banned {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, uint64Bytes(p.Time)...)
+	b = append(b, p.Details...)
+	for _, mid := range p.MatchIDs {
+		b = append(b, mid...)
+	}
+	b = append(b, p.Appeal...)
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
 // Register is the payload for the RegisterRoute request.
 type Register struct {
 	Signature
 	PubKey Bytes  `json:"pubkey"`
 	Time   uint64 `json:"timestamp"`
+	// Nonce is the proof-of-work solution for the serialized Register, when
+	// the server requires one. See CheckPoW. It is not part of the signed
+	// serialization.
+	Nonce uint64 `json:"nonce,omitempty"`
 }
 
 // Serialize serializes the Register data.
@@ -850,7 +1323,17 @@ type Market struct {
 	Quote           uint32  `json:"quote"`
 	EpochLen        uint64  `json:"epochlen"`
 	MarketBuyBuffer float64 `json:"buybuffer"`
-	MarketStatus    `json:"status"`
+	// TradeFee is the operator's trade fee rate in basis points of the
+	// quantity received by each party of a match.
+	TradeFee uint32 `json:"tradefee"`
+	// MinOrderValue is the minimum value of an order in units of the quote
+	// asset. Zero means there is no minimum beyond the lot size.
+	MinOrderValue uint64 `json:"minvalue,omitempty"`
+	// OddLotPolicy is how the remainder of a partially filled limit order
+	// that is less than the minimum order quantity is treated, either "keep"
+	// or "release". See dex.OddLotPolicy.
+	OddLotPolicy string `json:"oddlot,omitempty"`
+	MarketStatus `json:"status"`
 }
 
 // Asset describes an asset and its variables, and is returned as part of a
@@ -866,14 +1349,166 @@ type Asset struct {
 	SwapConf     uint16 `json:"swapconf"`
 }
 
-// ConfigResult is the successful result for the ConfigRoute.
+// ConfigResult is the successful result for the ConfigRoute. It is signed by
+// the DEX's signing key, which is included so that the config can be verified
+// before registration. If the operator has scheduled a signing key rotation,
+// KeyRotation announces it.
 type ConfigResult struct {
-	CancelMax        float64   `json:"cancelmax"`
-	BroadcastTimeout uint64    `json:"btimeout"`
-	RegFeeConfirms   uint16    `json:"regfeeconfirms"`
-	Assets           []*Asset  `json:"assets"`
-	Markets          []*Market `json:"markets"`
-	Fee              uint64    `json:"fee"`
+	Signature
+	CancelMax        float64      `json:"cancelmax"`
+	BroadcastTimeout uint64       `json:"btimeout"`
+	RegFeeConfirms   uint16       `json:"regfeeconfirms"`
+	Assets           []*Asset     `json:"assets"`
+	Markets          []*Market    `json:"markets"`
+	Fee              uint64       `json:"fee"`
+	DEXPubKey        Bytes        `json:"pubkey"`
+	KeyRotation      *KeyRotation `json:"keyrotation,omitempty"`
+	// APIVersion is the version of the DEX protocol spoken by the server. It
+	// is zero for servers that predate versioning.
+	APIVersion uint16 `json:"apiver,omitempty"`
+	// Upgrade announces a required protocol upgrade.
+	Upgrade *Upgrade `json:"upgrade,omitempty"`
+}
+
+var _ Signable = (*ConfigResult)(nil)
+
+// Serialize serializes the ConfigResult data. Since a signature only commits
+// to a 32-byte message, the serialization is the blake256 hash of the config's
+// encoding, cancelmax (8) + btimeout (8) + regfeeconfirms (2) + fee (8) +
+// pubkey (33) + assets + markets + key rotation, where each asset is
+// symbol length (4) + symbol (varies) + id (4) + lotsize (8) + ratestep (8) +
+// maxfeerate (8) + swapsize (8) + swapsizebase (8) + swapconf (2), each
+// market is name length (4) + name (varies) + base (4) + quote (4) +
+// epochlen (8) + buybuffer (8) + tradefee (4) + startepoch (8) +
+// finalepoch (8) + persist (1), and the key rotation, if set, is the rotation
+// serialization (32) + old sig length (4) + old sig (varies) + new sig length
+// (4) + new sig (varies). The API version (2), if not zero, and the upgrade, if
+// set, as API version (2) + deadline (8) + notes length (4) + notes (varies),
+// are appended last.
+func (c *ConfigResult) Serialize() []byte {
+	b := make([]byte, 0, 59+len(c.Assets)*64+len(c.Markets)*64+150)
+	b = append(b, uint64Bytes(math.Float64bits(c.CancelMax))...)
+	b = append(b, uint64Bytes(c.BroadcastTimeout)...)
+	b = append(b, uint16Bytes(c.RegFeeConfirms)...)
+	b = append(b, uint64Bytes(c.Fee)...)
+	b = append(b, c.DEXPubKey...)
+	b = append(b, uint32Bytes(uint32(len(c.Assets)))...)
+	for _, a := range c.Assets {
+		b = append(b, uint32Bytes(uint32(len(a.Symbol)))...)
+		b = append(b, a.Symbol...)
+		b = append(b, uint32Bytes(a.ID)...)
+		b = append(b, uint64Bytes(a.LotSize)...)
+		b = append(b, uint64Bytes(a.RateStep)...)
+		b = append(b, uint64Bytes(a.MaxFeeRate)...)
+		b = append(b, uint64Bytes(a.SwapSize)...)
+		b = append(b, uint64Bytes(a.SwapSizeBase)...)
+		b = append(b, uint16Bytes(a.SwapConf)...)
+	}
+	b = append(b, uint32Bytes(uint32(len(c.Markets)))...)
+	for _, m := range c.Markets {
+		b = append(b, uint32Bytes(uint32(len(m.Name)))...)
+		b = append(b, m.Name...)
+		b = append(b, uint32Bytes(m.Base)...)
+		b = append(b, uint32Bytes(m.Quote)...)
+		b = append(b, uint64Bytes(m.EpochLen)...)
+		b = append(b, uint64Bytes(math.Float64bits(m.MarketBuyBuffer))...)
+		b = append(b, uint32Bytes(m.TradeFee)...)
+		b = append(b, uint64Bytes(m.StartEpoch)...)
+		b = append(b, uint64Bytes(m.FinalEpoch)...)
+		var persist byte
+		if m.Persist != nil && *m.Persist {
+			persist = 1
+		}
+		b = append(b, persist)
+	}
+	if kr := c.KeyRotation; kr != nil {
+		b = append(b, kr.Serialize()...)
+		b = append(b, uint32Bytes(uint32(len(kr.OldSig)))...)
+		b = append(b, kr.OldSig...)
+		b = append(b, uint32Bytes(uint32(len(kr.NewSig)))...)
+		b = append(b, kr.NewSig...)
+	}
+	if c.APIVersion > 0 {
+		b = append(b, uint16Bytes(c.APIVersion)...)
+	}
+	if u := c.Upgrade; u != nil {
+		b = append(b, uint16Bytes(u.APIVersion)...)
+		b = append(b, uint64Bytes(u.Deadline)...)
+		b = append(b, uint32Bytes(uint32(len(u.Notes)))...)
+		b = append(b, u.Notes...)
+	}
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
+// Upgrade announces that the server will require clients to speak at least
+// APIVersion from the Deadline, a UNIX time in milliseconds. Notes describe the
+// upgrade for users.
+type Upgrade struct {
+	APIVersion uint16 `json:"apiver"`
+	Deadline   uint64 `json:"deadline"`
+	Notes      string `json:"notes,omitempty"`
+}
+
+// KeyRotation announces that the DEX's signing key is replaced by a new key at
+// the activation time. The rotation is signed by both the old key, endorsing
+// the new key, and the new key, proving that the operator holds it. A client
+// that knows the old key accepts the new key once the activation time has
+// passed.
+type KeyRotation struct {
+	OldPubKey  Bytes  `json:"oldpubkey"`
+	NewPubKey  Bytes  `json:"newpubkey"`
+	Activation uint64 `json:"activation"`
+	OldSig     Bytes  `json:"oldsig"`
+	NewSig     Bytes  `json:"newsig"`
+}
+
+// Serialize serializes the KeyRotation data, which is signed by both keys. The
+// serialization is the blake256 hash of old pubkey (33) + new pubkey (33) +
+// activation (8).
+func (kr *KeyRotation) Serialize() []byte {
+	b := make([]byte, 0, 74)
+	b = append(b, kr.OldPubKey...)
+	b = append(b, kr.NewPubKey...)
+	b = append(b, uint64Bytes(kr.Activation)...)
+	h := blake256.Sum256(b)
+	return h[:]
+}
+
+// Policy is the result of a client-originating PolicyRoute request. It is the
+// operator's published policy document, signed by the DEX's signing key. The
+// key is included so that the document can be verified before registration,
+// at which point the key is learned from the RegisterResult.
+type Policy struct {
+	Signature
+	DEXPubKey Bytes  `json:"pubkey"`
+	Stamp     uint64 `json:"timestamp"`
+	Fees      string `json:"fees"`
+	Penalties string `json:"penalties"`
+	Contact   string `json:"contact"`
+	Terms     string `json:"terms"`
+}
+
+var _ Signable = (*Policy)(nil)
+
+// Serialize serializes the Policy data. Since a signature only commits to a
+// 32-byte message, the serialization is the blake256 hash of the document's
+// encoding, pubkey (33) + timestamp (8) + 4 * (length (4) + text (varies)).
+func (p *Policy) Serialize() []byte {
+	texts := []string{p.Fees, p.Penalties, p.Contact, p.Terms}
+	sz := len(p.DEXPubKey) + 8
+	for _, text := range texts {
+		sz += 4 + len(text)
+	}
+	b := make([]byte, 0, sz)
+	b = append(b, p.DEXPubKey...)
+	b = append(b, uint64Bytes(p.Stamp)...)
+	for _, text := range texts {
+		b = append(b, uint32Bytes(uint32(len(text)))...)
+		b = append(b, text...)
+	}
+	h := blake256.Sum256(b)
+	return h[:]
 }
 
 // Convert uint64 to 8 bytes.
@@ -40,6 +40,10 @@ type Config struct {
 	RPCPass string `ini:"rpcpassword, JSON-RPC Password, bitcoin's 'rpcpassword' setting"`
 	RPCBind string `ini:"rpcbind, JSON-RPC Address, <addr> or <addr>:<port> (default 'localhost')"`
 	RPCPort int    `ini:"rpcport, JSON-RPC Port, Port for RPC connections (if not set in Address)"`
+	// Proxy settings are only used by the client's wallet connection.
+	Proxy     string `ini:"proxy, Proxy Address, SOCKS5 proxy <host>:<port> for a remote RPC connection (optional)"`
+	ProxyUser string `ini:"proxyuser, Proxy Username, SOCKS5 proxy username (optional)"`
+	ProxyPass string `ini:"proxypass, Proxy Password, SOCKS5 proxy password (optional)"`
 }
 
 // LoadConfigFromPath loads the configuration settings from the specified filepath.
@@ -0,0 +1,330 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// The swap contracts are ETHSwapV0.sol and ERC20SwapV0.sol in the contracts
+// directory. Both have the same interface. The data for their functions, and
+// for the ERC-20 functions used by the client, is encoded here according to
+// the Solidity contract ABI.
+
+const wordSize = 32
+
+// SwapStep is the state of a swap in the contract.
+type SwapStep uint8
+
+const (
+	// SSNone is the state of a swap that has not been initiated.
+	SSNone SwapStep = iota
+	// SSInitiated is the state of an initiated swap.
+	SSInitiated
+	// SSRedeemed is the state of a redeemed swap.
+	SSRedeemed
+	// SSRefunded is the state of a refunded swap.
+	SSRefunded
+)
+
+// String satisfies the Stringer interface.
+func (ss SwapStep) String() string {
+	switch ss {
+	case SSNone:
+		return "none"
+	case SSInitiated:
+		return "initiated"
+	case SSRedeemed:
+		return "redeemed"
+	case SSRefunded:
+		return "refunded"
+	}
+	return "unknown"
+}
+
+// selector is the first four bytes of the hash of the function signature.
+func selector(sig string) []byte {
+	return Keccak256([]byte(sig))[:4]
+}
+
+var (
+	initiateSelector  = selector("initiate((uint256,bytes32,address,uint256)[])")
+	redeemSelector    = selector("redeem((bytes32,bytes32)[])")
+	refundSelector    = selector("refund(bytes32)")
+	swapSelector      = selector("swap(bytes32)")
+	approveSelector   = selector("approve(address,uint256)")
+	allowanceSelector = selector("allowance(address,address)")
+	balanceOfSelector = selector("balanceOf(address)")
+	transferSelector  = selector("transfer(address,uint256)")
+
+	// MaxUint256 is the largest uint256, used for unlimited token approvals.
+	MaxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+)
+
+// Initiation is the data for one swap in an initiate transaction.
+type Initiation struct {
+	// RefundTimestamp is the unix time after which the initiator can refund.
+	RefundTimestamp uint64
+	SecretHash      [32]byte
+	Participant     Address
+	// Value is in contract units, e.g. wei.
+	Value *big.Int
+}
+
+// Redemption is the data for one redemption in a redeem transaction.
+type Redemption struct {
+	Secret     [32]byte
+	SecretHash [32]byte
+}
+
+// SwapState is the state of a swap as returned by the contract's swap
+// function.
+type SwapState struct {
+	// Secret is only set after the swap is redeemed.
+	Secret          [32]byte
+	Value           *big.Int
+	BlockHeight     uint64
+	RefundTimestamp uint64
+	Initiator       Address
+	Participant     Address
+	State           SwapStep
+}
+
+func uintWord(v *big.Int) []byte {
+	b := make([]byte, wordSize)
+	vb := v.Bytes()
+	copy(b[wordSize-len(vb):], vb)
+	return b
+}
+
+func uint64Word(v uint64) []byte {
+	return uintWord(new(big.Int).SetUint64(v))
+}
+
+func addressWord(addr Address) []byte {
+	b := make([]byte, wordSize)
+	copy(b[wordSize-AddressLength:], addr[:])
+	return b
+}
+
+// words splits ABI-encoded data into 32-byte words.
+func words(b []byte) ([][]byte, error) {
+	if len(b)%wordSize != 0 {
+		return nil, fmt.Errorf("data length %d is not a multiple of %d", len(b), wordSize)
+	}
+	ws := make([][]byte, 0, len(b)/wordSize)
+	for i := 0; i < len(b); i += wordSize {
+		ws = append(ws, b[i:i+wordSize])
+	}
+	return ws, nil
+}
+
+func wordUint64(w []byte) (uint64, error) {
+	v := new(big.Int).SetBytes(w)
+	if !v.IsUint64() {
+		return 0, errors.New("value overflows uint64")
+	}
+	return v.Uint64(), nil
+}
+
+func wordAddress(w []byte) (Address, error) {
+	var addr Address
+	if !bytes.Equal(w[:wordSize-AddressLength], make([]byte, wordSize-AddressLength)) {
+		return addr, errors.New("invalid address word")
+	}
+	copy(addr[:], w[wordSize-AddressLength:])
+	return addr, nil
+}
+
+// packTupleArray encodes a function call with a single argument that is a
+// dynamic array of static tuples.
+func packTupleArray(sel []byte, tuples [][][]byte) []byte {
+	b := make([]byte, 0, 4+wordSize*2+len(tuples)*4*wordSize)
+	b = append(b, sel...)
+	b = append(b, uint64Word(wordSize)...)
+	b = append(b, uint64Word(uint64(len(tuples)))...)
+	for _, t := range tuples {
+		for _, w := range t {
+			b = append(b, w...)
+		}
+	}
+	return b
+}
+
+// parseTupleArray decodes the data of a function call with a single argument
+// that is a dynamic array of static tuples with tupleSize words.
+func parseTupleArray(sel, data []byte, tupleSize int) ([][][]byte, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], sel) {
+		return nil, errors.New("wrong function selector")
+	}
+	ws, err := words(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	if len(ws) < 2 {
+		return nil, errors.New("data too short")
+	}
+	offset, err := wordUint64(ws[0])
+	if err != nil || offset != wordSize {
+		return nil, errors.New("unexpected array offset")
+	}
+	n, err := wordUint64(ws[1])
+	if err != nil || n == 0 || uint64(len(ws)-2) != n*uint64(tupleSize) {
+		return nil, errors.New("array length does not match the data length")
+	}
+	tuples := make([][][]byte, 0, n)
+	for i := 2; i < len(ws); i += tupleSize {
+		tuples = append(tuples, ws[i:i+tupleSize])
+	}
+	return tuples, nil
+}
+
+// PackInitiateData encodes the data for the swap contract's initiate
+// function.
+func PackInitiateData(inits []*Initiation) []byte {
+	tuples := make([][][]byte, 0, len(inits))
+	for _, init := range inits {
+		tuples = append(tuples, [][]byte{
+			uint64Word(init.RefundTimestamp),
+			init.SecretHash[:],
+			addressWord(init.Participant),
+			uintWord(init.Value),
+		})
+	}
+	return packTupleArray(initiateSelector, tuples)
+}
+
+// ParseInitiateData decodes the data of a call to the swap contract's
+// initiate function.
+func ParseInitiateData(data []byte) ([]*Initiation, error) {
+	tuples, err := parseTupleArray(initiateSelector, data, 4)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing initiate data: %v", err)
+	}
+	inits := make([]*Initiation, 0, len(tuples))
+	for _, t := range tuples {
+		refundTime, err := wordUint64(t[0])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing refund timestamp: %v", err)
+		}
+		participant, err := wordAddress(t[2])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing participant: %v", err)
+		}
+		init := &Initiation{
+			RefundTimestamp: refundTime,
+			Participant:     participant,
+			Value:           new(big.Int).SetBytes(t[3]),
+		}
+		copy(init.SecretHash[:], t[1])
+		inits = append(inits, init)
+	}
+	return inits, nil
+}
+
+// PackRedeemData encodes the data for the swap contract's redeem function.
+func PackRedeemData(redeems []*Redemption) []byte {
+	tuples := make([][][]byte, 0, len(redeems))
+	for _, r := range redeems {
+		tuples = append(tuples, [][]byte{r.Secret[:], r.SecretHash[:]})
+	}
+	return packTupleArray(redeemSelector, tuples)
+}
+
+// ParseRedeemData decodes the data of a call to the swap contract's redeem
+// function.
+func ParseRedeemData(data []byte) ([]*Redemption, error) {
+	tuples, err := parseTupleArray(redeemSelector, data, 2)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing redeem data: %v", err)
+	}
+	redeems := make([]*Redemption, 0, len(tuples))
+	for _, t := range tuples {
+		r := new(Redemption)
+		copy(r.Secret[:], t[0])
+		copy(r.SecretHash[:], t[1])
+		redeems = append(redeems, r)
+	}
+	return redeems, nil
+}
+
+// PackRefundData encodes the data for the swap contract's refund function.
+func PackRefundData(secretHash [32]byte) []byte {
+	return append(append([]byte{}, refundSelector...), secretHash[:]...)
+}
+
+// PackSwapData encodes the data for a call to the swap contract's swap view
+// function.
+func PackSwapData(secretHash [32]byte) []byte {
+	return append(append([]byte{}, swapSelector...), secretHash[:]...)
+}
+
+// ParseSwapState decodes the result of a call to the swap contract's swap
+// function.
+func ParseSwapState(b []byte) (*SwapState, error) {
+	ws, err := words(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(ws) != 7 {
+		return nil, fmt.Errorf("expected 7 words in swap state, got %d", len(ws))
+	}
+	s := &SwapState{Value: new(big.Int).SetBytes(ws[1])}
+	copy(s.Secret[:], ws[0])
+	if s.BlockHeight, err = wordUint64(ws[2]); err != nil {
+		return nil, fmt.Errorf("error parsing block height: %v", err)
+	}
+	if s.RefundTimestamp, err = wordUint64(ws[3]); err != nil {
+		return nil, fmt.Errorf("error parsing refund timestamp: %v", err)
+	}
+	if s.Initiator, err = wordAddress(ws[4]); err != nil {
+		return nil, fmt.Errorf("error parsing initiator: %v", err)
+	}
+	if s.Participant, err = wordAddress(ws[5]); err != nil {
+		return nil, fmt.Errorf("error parsing participant: %v", err)
+	}
+	state, err := wordUint64(ws[6])
+	if err != nil || state > uint64(SSRefunded) {
+		return nil, fmt.Errorf("invalid swap state %x", ws[6])
+	}
+	s.State = SwapStep(state)
+	return s, nil
+}
+
+// PackApproveData encodes the data for an ERC-20 approve call.
+func PackApproveData(spender Address, amount *big.Int) []byte {
+	b := append([]byte{}, approveSelector...)
+	b = append(b, addressWord(spender)...)
+	return append(b, uintWord(amount)...)
+}
+
+// PackAllowanceData encodes the data for an ERC-20 allowance call.
+func PackAllowanceData(owner, spender Address) []byte {
+	b := append([]byte{}, allowanceSelector...)
+	b = append(b, addressWord(owner)...)
+	return append(b, addressWord(spender)...)
+}
+
+// PackBalanceOfData encodes the data for an ERC-20 balanceOf call.
+func PackBalanceOfData(owner Address) []byte {
+	return append(append([]byte{}, balanceOfSelector...), addressWord(owner)...)
+}
+
+// PackTransferData encodes the data for an ERC-20 transfer call.
+func PackTransferData(to Address, amount *big.Int) []byte {
+	b := append([]byte{}, transferSelector...)
+	b = append(b, addressWord(to)...)
+	return append(b, uintWord(amount)...)
+}
+
+// ParseUint256 decodes a call result that is a single uint256.
+func ParseUint256(b []byte) (*big.Int, error) {
+	if len(b) != wordSize {
+		return nil, fmt.Errorf("expected %d bytes, got %d", wordSize, len(b))
+	}
+	return new(big.Int).SetBytes(b), nil
+}
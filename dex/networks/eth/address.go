@@ -0,0 +1,68 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// AddressLength is the length of an Ethereum address.
+const AddressLength = 20
+
+// Address is an Ethereum account or contract address.
+type Address [AddressLength]byte
+
+// Keccak256 is the legacy Keccak-256 hash used by Ethereum.
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// HexToAddress parses a 0x-prefixed hex address. If the address has mixed
+// case, the EIP-55 checksum is verified.
+func HexToAddress(s string) (Address, error) {
+	var addr Address
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return addr, fmt.Errorf("address %q is missing the 0x prefix", s)
+	}
+	h := s[2:]
+	if len(h) != 2*AddressLength {
+		return addr, fmt.Errorf("address %q has the wrong length", s)
+	}
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return addr, fmt.Errorf("address %q is not hex: %v", s, err)
+	}
+	copy(addr[:], b)
+	if h != strings.ToLower(h) && h != strings.ToUpper(h) && addr.String()[2:] != h {
+		return addr, fmt.Errorf("address %q has an invalid checksum", s)
+	}
+	return addr, nil
+}
+
+// String is the EIP-55 mixed-case checksum encoding of the address.
+func (a Address) String() string {
+	h := []byte(hex.EncodeToString(a[:]))
+	hash := Keccak256(h)
+	for i, c := range h {
+		if c < 'a' {
+			continue
+		}
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if nibble&0x0f >= 8 {
+			h[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(h)
+}
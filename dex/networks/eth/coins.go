@@ -0,0 +1,82 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Ethereum has no UTXOs, so coin IDs identify one of three things:
+//   - funding coin: an amount reserved from an account balance, encoded as the
+//     20-byte account address and the 8-byte big-endian amount in atoms.
+//   - swap coin: a swap in an initiate transaction, encoded as the 32-byte
+//     transaction hash and the swap's 32-byte secret hash.
+//   - transaction coin: any other transaction, encoded as the 32-byte
+//     transaction hash.
+const (
+	FundingCoinIDSize = AddressLength + 8
+	TxCoinIDSize      = 32
+	SwapCoinIDSize    = 64
+)
+
+// EncodeFundingCoinID encodes the ID of a funding coin.
+func EncodeFundingCoinID(addr Address, value uint64) []byte {
+	b := make([]byte, FundingCoinIDSize)
+	copy(b, addr[:])
+	binary.BigEndian.PutUint64(b[AddressLength:], value)
+	return b
+}
+
+// DecodeFundingCoinID decodes the ID of a funding coin.
+func DecodeFundingCoinID(coinID []byte) (Address, uint64, error) {
+	var addr Address
+	if len(coinID) != FundingCoinIDSize {
+		return addr, 0, fmt.Errorf("funding coin ID wrong length. expected %d, got %d", FundingCoinIDSize, len(coinID))
+	}
+	copy(addr[:], coinID)
+	return addr, binary.BigEndian.Uint64(coinID[AddressLength:]), nil
+}
+
+// EncodeSwapCoinID encodes the ID of a swap coin.
+func EncodeSwapCoinID(txHash, secretHash [32]byte) []byte {
+	return append(append(make([]byte, 0, SwapCoinIDSize), txHash[:]...), secretHash[:]...)
+}
+
+// DecodeSwapCoinID decodes the ID of a swap coin into the transaction hash and
+// the secret hash.
+func DecodeSwapCoinID(coinID []byte) (txHash, secretHash [32]byte, err error) {
+	if len(coinID) != SwapCoinIDSize {
+		return txHash, secretHash, fmt.Errorf("swap coin ID wrong length. expected %d, got %d", SwapCoinIDSize, len(coinID))
+	}
+	copy(txHash[:], coinID[:32])
+	copy(secretHash[:], coinID[32:])
+	return txHash, secretHash, nil
+}
+
+// DecodeTxCoinID decodes the transaction hash of a transaction coin or swap
+// coin.
+func DecodeTxCoinID(coinID []byte) ([32]byte, error) {
+	var txHash [32]byte
+	if len(coinID) != TxCoinIDSize && len(coinID) != SwapCoinIDSize {
+		return txHash, fmt.Errorf("coin ID wrong length for a transaction: %d", len(coinID))
+	}
+	copy(txHash[:], coinID)
+	return txHash, nil
+}
+
+// CoinIDString is a human-readable representation of a coin ID.
+func CoinIDString(coinID []byte) (string, error) {
+	switch len(coinID) {
+	case FundingCoinIDSize:
+		addr, value, _ := DecodeFundingCoinID(coinID)
+		return fmt.Sprintf("%s:%d", addr, value), nil
+	case TxCoinIDSize:
+		return "0x" + hex.EncodeToString(coinID), nil
+	case SwapCoinIDSize:
+		return fmt.Sprintf("0x%x:%x", coinID[:32], coinID[32:]), nil
+	}
+	return "", fmt.Errorf("invalid coin ID length %d", len(coinID))
+}
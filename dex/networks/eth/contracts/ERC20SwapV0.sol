@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: BlueOak-1.0.0
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+pragma solidity = 0.8.6;
+
+interface IERC20 {
+    function transfer(address recipient, uint256 amount) external returns (bool);
+    function transferFrom(address sender, address recipient, uint256 amount) external returns (bool);
+}
+
+// ERC20Swap is an atomic swap contract for a single ERC-20 token. It has the
+// same interface as ETHSwap, but the initiator must first approve the contract
+// to transfer the swapped tokens.
+contract ERC20Swap {
+    enum State { Empty, Filled, Redeemed, Refunded }
+
+    struct Swap {
+        bytes32 secret;
+        uint256 value;
+        uint initBlockNumber;
+        uint refundBlockTimestamp;
+        address initiator;
+        address participant;
+        State state;
+    }
+
+    struct Initiation {
+        uint refundTimestamp;
+        bytes32 secretHash;
+        address participant;
+        uint value;
+    }
+
+    struct Redemption {
+        bytes32 secret;
+        bytes32 secretHash;
+    }
+
+    address public immutable token;
+
+    mapping(bytes32 => Swap) public swaps;
+
+    constructor(address _token) {
+        token = _token;
+    }
+
+    modifier senderIsOrigin() {
+        require(tx.origin == msg.sender, "sender != origin");
+        _;
+    }
+
+    // swap returns the state of a swap.
+    function swap(bytes32 secretHash) public view returns (Swap memory) {
+        return swaps[secretHash];
+    }
+
+    // initiate creates swaps, transferring their total value from the sender.
+    function initiate(Initiation[] calldata initiations) public senderIsOrigin() {
+        uint initVal = 0;
+        for (uint i = 0; i < initiations.length; i++) {
+            Initiation calldata init = initiations[i];
+            Swap storage swapToUpdate = swaps[init.secretHash];
+
+            require(init.value > 0, "0 val");
+            require(init.refundTimestamp > 0, "0 refundTimestamp");
+            require(swapToUpdate.state == State.Empty, "dup swap");
+
+            swapToUpdate.initBlockNumber = block.number;
+            swapToUpdate.refundBlockTimestamp = init.refundTimestamp;
+            swapToUpdate.initiator = msg.sender;
+            swapToUpdate.participant = init.participant;
+            swapToUpdate.value = init.value;
+            swapToUpdate.state = State.Filled;
+
+            initVal += init.value;
+        }
+        require(IERC20(token).transferFrom(msg.sender, address(this), initVal), "transfer from failed");
+    }
+
+    // redeem redeems swaps for which the sender is the participant and
+    // transfers their total value to the sender.
+    function redeem(Redemption[] calldata redemptions) public senderIsOrigin() {
+        uint amountToRedeem = 0;
+        for (uint i = 0; i < redemptions.length; i++) {
+            Redemption calldata redemption = redemptions[i];
+            Swap storage swapToRedeem = swaps[redemption.secretHash];
+
+            require(swapToRedeem.state == State.Filled, "bad state");
+            require(swapToRedeem.participant == msg.sender, "bad participant");
+            require(sha256(abi.encodePacked(redemption.secret)) == redemption.secretHash, "bad secret");
+
+            swapToRedeem.state = State.Redeemed;
+            swapToRedeem.secret = redemption.secret;
+            amountToRedeem += swapToRedeem.value;
+        }
+        require(IERC20(token).transfer(msg.sender, amountToRedeem), "transfer failed");
+    }
+
+    // refund refunds an expired swap to its initiator.
+    function refund(bytes32 secretHash) public senderIsOrigin() {
+        Swap storage swapToRefund = swaps[secretHash];
+
+        require(swapToRefund.state == State.Filled, "bad state");
+        require(swapToRefund.initiator == msg.sender, "sender not initiator");
+        require(block.timestamp >= swapToRefund.refundBlockTimestamp, "not expired");
+
+        swapToRefund.state = State.Refunded;
+        require(IERC20(token).transfer(msg.sender, swapToRefund.value), "transfer failed");
+    }
+}
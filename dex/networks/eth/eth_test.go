@@ -0,0 +1,210 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	// EIP-55 test vectors.
+	for _, s := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		addr, err := HexToAddress(s)
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", s, err)
+		}
+		if addr.String() != s {
+			t.Fatalf("wrong checksum encoding. wanted %s, got %s", s, addr)
+		}
+		if _, err := HexToAddress(strings.ToLower(s)); err != nil {
+			t.Fatalf("error parsing lower case %s: %v", s, err)
+		}
+		// Flip the case of one letter.
+		bad := []byte(s)
+		for i := 2; i < len(bad); i++ {
+			if bad[i] >= 'a' {
+				bad[i] -= 'a' - 'A'
+				break
+			}
+		}
+		if _, err := HexToAddress(string(bad)); err == nil {
+			t.Fatalf("no error for bad checksum %s", bad)
+		}
+	}
+
+	for _, s := range []string{
+		"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAzz",
+	} {
+		if _, err := HexToAddress(s); err == nil {
+			t.Fatalf("no error for invalid address %s", s)
+		}
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	for _, tt := range []struct {
+		sel  []byte
+		want string
+	}{
+		{approveSelector, "095ea7b3"},
+		{allowanceSelector, "dd62ed3e"},
+		{balanceOfSelector, "70a08231"},
+		{transferSelector, "a9059cbb"},
+	} {
+		if hex.EncodeToString(tt.sel) != tt.want {
+			t.Fatalf("wrong selector. wanted %s, got %x", tt.want, tt.sel)
+		}
+	}
+}
+
+func TestInitiateData(t *testing.T) {
+	inits := []*Initiation{
+		{RefundTimestamp: 1600000000, Value: big.NewInt(5e17)},
+		{RefundTimestamp: 1600000001, Value: new(big.Int).Lsh(big.NewInt(1), 100)},
+	}
+	inits[0].SecretHash[0] = 1
+	inits[0].Participant[19] = 2
+	inits[1].SecretHash[31] = 3
+	inits[1].Participant[0] = 4
+	data := PackInitiateData(inits)
+	if len(data) != 4+(2+2*4)*wordSize {
+		t.Fatalf("wrong data length %d", len(data))
+	}
+	parsed, err := ParseInitiateData(data)
+	if err != nil {
+		t.Fatalf("ParseInitiateData error: %v", err)
+	}
+	if len(parsed) != len(inits) {
+		t.Fatalf("expected %d initiations, got %d", len(inits), len(parsed))
+	}
+	for i, init := range inits {
+		p := parsed[i]
+		if p.RefundTimestamp != init.RefundTimestamp || p.SecretHash != init.SecretHash ||
+			p.Participant != init.Participant || p.Value.Cmp(init.Value) != 0 {
+			t.Fatalf("initiation %d mismatch", i)
+		}
+	}
+
+	// Wrong selector.
+	if _, err := ParseInitiateData(PackRedeemData([]*Redemption{{}})); err == nil {
+		t.Fatalf("no error for redeem data")
+	}
+	// Truncated.
+	if _, err := ParseInitiateData(data[:len(data)-wordSize]); err == nil {
+		t.Fatalf("no error for truncated data")
+	}
+	// Bad address padding.
+	bad := append([]byte{}, data...)
+	bad[4+4*wordSize] = 1
+	if _, err := ParseInitiateData(bad); err == nil {
+		t.Fatalf("no error for bad address word")
+	}
+}
+
+func TestRedeemData(t *testing.T) {
+	redeems := []*Redemption{{}, {}}
+	redeems[0].Secret[0] = 1
+	redeems[1].SecretHash[1] = 2
+	parsed, err := ParseRedeemData(PackRedeemData(redeems))
+	if err != nil {
+		t.Fatalf("ParseRedeemData error: %v", err)
+	}
+	if len(parsed) != 2 || *parsed[0] != *redeems[0] || *parsed[1] != *redeems[1] {
+		t.Fatalf("redemption mismatch")
+	}
+}
+
+func TestParseSwapState(t *testing.T) {
+	var secret [32]byte
+	secret[5] = 5
+	var initiator, participant Address
+	initiator[0] = 1
+	participant[19] = 2
+	b := bytes.Join([][]byte{
+		secret[:],
+		uint64Word(1e18),
+		uint64Word(100),
+		uint64Word(1600000000),
+		addressWord(initiator),
+		addressWord(participant),
+		uint64Word(uint64(SSRedeemed)),
+	}, nil)
+	s, err := ParseSwapState(b)
+	if err != nil {
+		t.Fatalf("ParseSwapState error: %v", err)
+	}
+	if s.Secret != secret || s.Value.Uint64() != 1e18 || s.BlockHeight != 100 ||
+		s.RefundTimestamp != 1600000000 || s.Initiator != initiator ||
+		s.Participant != participant || s.State != SSRedeemed {
+		t.Fatalf("swap state mismatch: %+v", s)
+	}
+
+	if _, err := ParseSwapState(b[:6*wordSize]); err == nil {
+		t.Fatalf("no error for short swap state")
+	}
+	copy(b[6*wordSize:], uint64Word(9))
+	if _, err := ParseSwapState(b); err == nil {
+		t.Fatalf("no error for invalid state")
+	}
+}
+
+func TestCoinIDs(t *testing.T) {
+	var addr Address
+	addr[3] = 3
+	addr2, v, err := DecodeFundingCoinID(EncodeFundingCoinID(addr, 12345))
+	if err != nil || addr2 != addr || v != 12345 {
+		t.Fatalf("funding coin ID round trip failed: %v", err)
+	}
+
+	var txHash, secretHash [32]byte
+	txHash[0] = 1
+	secretHash[31] = 2
+	swapID := EncodeSwapCoinID(txHash, secretHash)
+	txHash2, secretHash2, err := DecodeSwapCoinID(swapID)
+	if err != nil || txHash2 != txHash || secretHash2 != secretHash {
+		t.Fatalf("swap coin ID round trip failed: %v", err)
+	}
+	if h, err := DecodeTxCoinID(swapID); err != nil || h != txHash {
+		t.Fatalf("DecodeTxCoinID failed for swap coin ID: %v", err)
+	}
+	if h, err := DecodeTxCoinID(txHash[:]); err != nil || h != txHash {
+		t.Fatalf("DecodeTxCoinID failed: %v", err)
+	}
+	if _, err := DecodeTxCoinID(EncodeFundingCoinID(addr, 1)); err == nil {
+		t.Fatalf("no error decoding funding coin ID as a transaction")
+	}
+
+	for _, id := range [][]byte{EncodeFundingCoinID(addr, 1), swapID, txHash[:]} {
+		if _, err := CoinIDString(id); err != nil {
+			t.Fatalf("CoinIDString error: %v", err)
+		}
+	}
+	if _, err := CoinIDString(make([]byte, 10)); err == nil {
+		t.Fatalf("no error for invalid coin ID")
+	}
+}
+
+func TestUnits(t *testing.T) {
+	if WeiPerGwei.Uint64() != 1e9 {
+		t.Fatalf("wrong wei per gwei %s", WeiPerGwei)
+	}
+	if UnitsPerAtom(6).Uint64() != 1 {
+		t.Fatalf("wrong units per atom for 6 decimals")
+	}
+	wei := FromAtoms(5, WeiPerGwei)
+	if wei.Uint64() != 5e9 {
+		t.Fatalf("wrong wei %s", wei)
+	}
+	if ToAtoms(new(big.Int).Add(wei, big.NewInt(1)), WeiPerGwei) != 5 {
+		t.Fatalf("wrong atoms")
+	}
+}
@@ -0,0 +1,72 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"math/big"
+
+	"decred.org/dcrdex/dex"
+)
+
+const (
+	// BipID is the BIP-0044 asset ID for Ether.
+	BipID = 60
+	// GweiDecimals is the number of decimal places in a gwei, the atomic unit
+	// of ETH used by the DEX. Contract values are in wei.
+	GweiDecimals = 9
+	// ETHDecimals is the number of decimal places in an Ether.
+	ETHDecimals = 18
+)
+
+// Gases are the gas limits for the swap contract's transactions. The
+// initiation and redemption limits are per swap, so a transaction with n
+// swaps is given n times the limit.
+type Gases struct {
+	Swap   uint64
+	Redeem uint64
+	Refund uint64
+}
+
+var (
+	// ETHGases are the gas limits for the ETH swap contract.
+	ETHGases = &Gases{
+		Swap:   135000,
+		Redeem: 65000,
+		Refund: 60000,
+	}
+	// TransferGas is the gas limit for sending ETH.
+	TransferGas uint64 = 21000
+	// ApproveGas is the gas limit for an ERC-20 approve transaction.
+	ApproveGas uint64 = 65000
+	// TokenTransferGas is the gas limit for an ERC-20 transfer transaction.
+	TokenTransferGas uint64 = 65000
+
+	// ContractAddresses are the addresses of the ETH swap contract. A network
+	// with no known deployment requires the contract address to be configured.
+	ContractAddresses = map[dex.Network]string{}
+)
+
+// UnitsPerAtom is the number of contract units, e.g. wei, in one atom of an
+// asset with the specified number of decimal places. DEX atoms have at most
+// GweiDecimals decimal places so that any reasonable quantity fits in a
+// uint64.
+func UnitsPerAtom(decimals uint8) *big.Int {
+	if decimals <= GweiDecimals {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-GweiDecimals)), nil)
+}
+
+// WeiPerGwei is the number of wei in a gwei.
+var WeiPerGwei = UnitsPerAtom(ETHDecimals)
+
+// ToAtoms converts contract units to atoms, truncating any remainder.
+func ToAtoms(v, unitsPerAtom *big.Int) uint64 {
+	return new(big.Int).Quo(v, unitsPerAtom).Uint64()
+}
+
+// FromAtoms converts atoms to contract units.
+func FromAtoms(v uint64, unitsPerAtom *big.Int) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(v), unitsPerAtom)
+}
@@ -0,0 +1,58 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package eth
+
+import (
+	"math/big"
+
+	"decred.org/dcrdex/dex"
+)
+
+// Token is an ERC-20 token that can be swapped with the ERC-20 swap contract.
+// Each token has its own swap contract.
+type Token struct {
+	// Name is the display name of the token.
+	Name string
+	// Units is the display name of the token's atomic unit.
+	Units string
+	// Decimals is the number of decimal places in the token's contract units.
+	Decimals uint8
+	// Addresses are the token's contract addresses.
+	Addresses map[dex.Network]string
+	// SwapContracts are the addresses of the token's swap contract. A
+	// network with no known deployment requires the contract address to be
+	// configured.
+	SwapContracts map[dex.Network]string
+	// Gases are the gas limits for the token's swap contract.
+	Gases *Gases
+}
+
+// UnitsPerAtom is the number of token contract units in one atom.
+func (t *Token) UnitsPerAtom() *big.Int {
+	return UnitsPerAtom(t.Decimals)
+}
+
+// tokenGases are the gas limits for the ERC-20 swap contract, which are
+// higher than for ETH because of the token transfers.
+var tokenGases = &Gases{
+	Swap:   170000,
+	Redeem: 90000,
+	Refund: 85000,
+}
+
+// Tokens are the supported ERC-20 tokens, keyed by asset ID. A token's asset
+// ID and symbol are listed with the BIP IDs. A token's symbol is suffixed with
+// the symbol of its network.
+var Tokens = map[uint32]*Token{
+	60001: {
+		Name:     "USD Coin",
+		Units:    "micro-USDC",
+		Decimals: 6,
+		Addresses: map[dex.Network]string{
+			dex.Mainnet: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+		SwapContracts: map[dex.Network]string{},
+		Gases:         tokenGases,
+	},
+}
@@ -148,6 +148,7 @@ type Match struct {
 	// The following fields are not part of the serialization of Match.
 	FeeRateBase  uint64
 	FeeRateQuote uint64
+	TradeFee     uint32 // basis points, paid by each party in the asset received
 	Epoch        EpochID
 	Status       MatchStatus
 	Sigs         Signatures
@@ -206,14 +207,16 @@ func (match *Match) ID() MatchID {
 // slice is for convenience since each rate must be the same as the Maker's
 // rate. However, a amount in Amounts may be less than the full quantity of the
 // corresponding Maker order, indicating a partial fill of the Maker. The sum
-// of the amounts, Total, is provided for convenience.
+// of the amounts, Total, is provided for convenience. TradeFee is the market's
+// trade fee rate in basis points, and is zero for a cancel order's MatchSet.
 type MatchSet struct {
-	Epoch   EpochID
-	Taker   Order
-	Makers  []*LimitOrder
-	Amounts []uint64
-	Rates   []uint64
-	Total   uint64
+	Epoch    EpochID
+	Taker    Order
+	Makers   []*LimitOrder
+	Amounts  []uint64
+	Rates    []uint64
+	Total    uint64
+	TradeFee uint32
 }
 
 // Matches converts the MatchSet to a []*Match.
@@ -221,6 +224,7 @@ func (set *MatchSet) Matches() []*Match {
 	matches := make([]*Match, 0, len(set.Makers))
 	for i, maker := range set.Makers {
 		match := newMatch(set.Taker, maker, set.Amounts[i], set.Rates[i], set.Epoch)
+		match.TradeFee = set.TradeFee
 		matches = append(matches, match)
 	}
 	return matches
@@ -553,12 +553,15 @@ func (o *MarketOrder) Serialize() []byte {
 var _ Order = (*MarketOrder)(nil)
 
 // LimitOrder defines a limit order in terms of a MarketOrder and limit-specific
-// data including rate (price) and time in force.
+// data including rate (price) and time in force. An iceberg order has a
+// non-zero Visible quantity, which is the most of its remaining quantity that
+// is shown in order book feeds. The full remaining quantity is matched.
 type LimitOrder struct {
 	P
 	T
-	Rate  uint64 // price as atoms of quote asset, applied per 1e8 units of the base asset
-	Force TimeInForce
+	Rate    uint64 // price as atoms of quote asset, applied per 1e8 units of the base asset
+	Force   TimeInForce
+	Visible uint64 // zero if not an iceberg order
 }
 
 // ID computes the order ID.
@@ -583,7 +586,11 @@ func (o *LimitOrder) String() string {
 
 // serializeSize returns the length of the serialized LimitOrder.
 func (o *LimitOrder) serializeSize() int {
-	return o.P.serializeSize() + o.T.serializeSize() + 8 + 1
+	sz := o.P.serializeSize() + o.T.serializeSize() + 8 + 1
+	if o.Visible > 0 {
+		sz += 8
+	}
+	return sz
 }
 
 // Serialize marshals the LimitOrder into a []byte.
@@ -602,6 +609,13 @@ func (o *LimitOrder) Serialize() []byte {
 
 	// Time in force
 	b[offset] = uint8(o.Force)
+	offset++
+
+	// Visible quantity of an iceberg order. It is omitted for other orders so
+	// that their IDs are unchanged.
+	if o.Visible > 0 {
+		binary.BigEndian.PutUint64(b[offset:offset+8], o.Visible)
+	}
 	return b
 }
 
@@ -613,6 +627,17 @@ func (o *LimitOrder) Price() uint64 {
 	return o.Rate
 }
 
+// Displayed is the quantity of the order that is shown in order book feeds.
+// This is the remaining quantity, up to the Visible quantity of an iceberg
+// order.
+func (o *LimitOrder) Displayed() uint64 {
+	rem := o.Remaining()
+	if o.Visible > 0 && rem > o.Visible {
+		return o.Visible
+	}
+	return rem
+}
+
 // CancelOrder defines a cancel order in terms of an order Prefix and the ID of
 // the order to be canceled.
 type CancelOrder struct {
@@ -658,6 +683,54 @@ func (o *CancelOrder) Serialize() []byte {
 // Ensure CancelOrder is an Order.
 var _ Order = (*CancelOrder)(nil)
 
+// RedactOrder returns a copy of the order with the account ID, funding coins,
+// and swap address removed, such as for sharing with other users. The ID of
+// the original order is retained, since it is not computable from the redacted
+// order. The copy of a trade includes the fill amount at the time of the call.
+func RedactOrder(ord Order) Order {
+	oid := ord.ID()
+	redactPrefix := func(p *Prefix) Prefix {
+		return Prefix{
+			BaseAsset:  p.BaseAsset,
+			QuoteAsset: p.QuoteAsset,
+			OrderType:  p.OrderType,
+			ClientTime: p.ClientTime,
+			ServerTime: p.ServerTime,
+			Commit:     p.Commit,
+			id:         &oid,
+		}
+	}
+	redactTrade := func(t *Trade) Trade {
+		return Trade{
+			Sell:     t.Sell,
+			Quantity: t.Quantity,
+			FillAmt:  t.Filled(),
+		}
+	}
+	switch o := ord.(type) {
+	case *LimitOrder:
+		return &LimitOrder{
+			P:       redactPrefix(&o.P),
+			T:       redactTrade(&o.T),
+			Rate:    o.Rate,
+			Force:   o.Force,
+			Visible: o.Visible,
+		}
+	case *MarketOrder:
+		return &MarketOrder{
+			P: redactPrefix(&o.P),
+			T: redactTrade(&o.T),
+		}
+	case *CancelOrder:
+		return &CancelOrder{
+			P:             redactPrefix(&o.P),
+			TargetOrderID: o.TargetOrderID,
+		}
+	default:
+		panic("RedactOrder: unknown order type")
+	}
+}
+
 // ValidateOrder ensures that the order with the given status for the specified
 // market is sensible. The ServerTime may not be set yet, so the OrderID cannot
 // be computed.
@@ -725,6 +798,20 @@ func ValidateOrder(ord Order, status OrderStatus, lotSize uint64) error {
 		if ot.Quantity%lotSize != 0 || ot.Remaining()%lotSize != 0 {
 			return fmt.Errorf("limit order fails lot size requirement %d %% %d = %d", ot.Quantity, lotSize, ot.Quantity%lotSize)
 		}
+
+		// An iceberg order must be a standing order that shows a whole number
+		// of lots, but less than its full quantity.
+		if ot.Visible > 0 {
+			if ot.Force != StandingTiF {
+				return fmt.Errorf("iceberg order must be a standing order")
+			}
+			if ot.Visible%lotSize != 0 {
+				return fmt.Errorf("iceberg order visible quantity fails lot size requirement %d %% %d = %d", ot.Visible, lotSize, ot.Visible%lotSize)
+			}
+			if ot.Visible >= ot.Quantity {
+				return fmt.Errorf("iceberg order visible quantity %d is not less than quantity %d", ot.Visible, ot.Quantity)
+			}
+		}
 	default:
 		// cannot validate an unknown order type
 		return fmt.Errorf("unknown order type")
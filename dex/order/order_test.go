@@ -525,6 +525,56 @@ func TestLimitOrder_ID(t *testing.T) {
 	}
 }
 
+func TestLimitOrder_Visible(t *testing.T) {
+	newOrder := func(visible uint64) *LimitOrder {
+		return &LimitOrder{
+			P: Prefix{
+				AccountID:  acct0,
+				BaseAsset:  AssetDCR,
+				QuoteAsset: AssetBTC,
+				OrderType:  LimitOrderType,
+				ClientTime: time.Unix(1566497653, 0),
+				ServerTime: time.Unix(1566497656, 0),
+			},
+			T: Trade{
+				Coins: []CoinID{
+					utxoCoinID("01516d9c7ffbe260b811dc04462cedd3f8969ce3a3ffe6231ae870775a92e9b0", 1),
+				},
+				Quantity: 10e8,
+				Address:  "DcqXswjTPnUcd4FRCkX4vRJxmVtfgGVa5ui",
+			},
+			Rate:    13241324,
+			Force:   StandingTiF,
+			Visible: visible,
+		}
+	}
+
+	lo, iceberg := newOrder(0), newOrder(2e8)
+	if iceberg.ID() == lo.ID() {
+		t.Fatalf("visible quantity not committed to in the order ID")
+	}
+	b := iceberg.Serialize()
+	if len(b) != iceberg.serializeSize() || len(b) != lo.serializeSize()+8 {
+		t.Fatalf("wrong iceberg order serialization size %d", len(b))
+	}
+	if !bytes.Equal(b[:len(b)-8], lo.Serialize()) {
+		t.Fatalf("iceberg order serialization does not extend the limit order serialization")
+	}
+
+	for _, tt := range []struct {
+		fill, want uint64
+	}{{0, 2e8}, {7e8, 2e8}, {9e8, 1e8}, {10e8, 0}} {
+		iceberg.SetFill(tt.fill)
+		if got := iceberg.Displayed(); got != tt.want {
+			t.Fatalf("displayed %d with fill %d, want %d", got, tt.fill, tt.want)
+		}
+		lo.SetFill(tt.fill)
+		if got := lo.Displayed(); got != lo.Remaining() {
+			t.Fatalf("displayed %d of a limit order with %d remaining", got, lo.Remaining())
+		}
+	}
+}
+
 func TestCancelOrder_ID(t *testing.T) {
 	limitOrderID0, _ := hex.DecodeString("8490aca39a672a79a1d93d70b531bee2297c56040e970cac6d2be755c932508a")
 	var limitOrderID OrderID
@@ -727,3 +777,70 @@ func TestPreimage_Commit(t *testing.T) {
 		})
 	}
 }
+
+func TestRedactOrder(t *testing.T) {
+	lo := &LimitOrder{
+		P: Prefix{
+			AccountID:  acct0,
+			BaseAsset:  AssetDCR,
+			QuoteAsset: AssetBTC,
+			OrderType:  LimitOrderType,
+			ClientTime: time.Unix(1566497653, 0),
+			ServerTime: time.Unix(1566497656, 0),
+			Commit:     preimage0.Commit(),
+		},
+		T: Trade{
+			Coins: []CoinID{
+				utxoCoinID("01516d9c7ffbe260b811dc04462cedd3f8969ce3a3ffe6231ae870775a92e9b0", 1),
+			},
+			Quantity: 132413241324,
+			Address:  "DcqXswjTPnUcd4FRCkX4vRJxmVtfgGVa5ui",
+			FillAmt:  1324,
+		},
+		Rate:  13241324,
+		Force: StandingTiF,
+	}
+	co := &CancelOrder{
+		P: Prefix{
+			AccountID:  acct0,
+			BaseAsset:  AssetDCR,
+			QuoteAsset: AssetBTC,
+			OrderType:  CancelOrderType,
+			ClientTime: time.Unix(1566497693, 0),
+			ServerTime: time.Unix(1566497696, 0),
+		},
+		TargetOrderID: lo.ID(),
+	}
+
+	for _, ord := range []Order{lo, co} {
+		redacted := RedactOrder(ord)
+		if redacted.ID() != ord.ID() {
+			t.Fatalf("redacted order ID %v, want %v", redacted.ID(), ord.ID())
+		}
+		if redacted.User() != (account.AccountID{}) {
+			t.Fatalf("account ID not redacted")
+		}
+		if redacted.Commitment() != ord.Commitment() {
+			t.Fatalf("commitment not retained")
+		}
+		if trade := redacted.Trade(); trade != nil {
+			if len(trade.Coins) > 0 || trade.Address != "" {
+				t.Fatalf("trade not redacted")
+			}
+			if trade.Filled() != ord.Trade().Filled() {
+				t.Fatalf("redacted fill %d, want %d", trade.Filled(), ord.Trade().Filled())
+			}
+		}
+
+		decoded, err := DecodeRedactedOrder(EncodeOrder(redacted), ord.ID())
+		if err != nil {
+			t.Fatalf("DecodeRedactedOrder error: %v", err)
+		}
+		if decoded.ID() != ord.ID() {
+			t.Fatalf("decoded order ID %v, want %v", decoded.ID(), ord.ID())
+		}
+		if !bytes.Equal(decoded.Serialize(), redacted.Serialize()) {
+			t.Fatalf("decoded redacted order differs")
+		}
+	}
+}
@@ -213,14 +213,17 @@ func EncodeOrder(ord Order) []byte {
 		if o.Force == ImmediateTiF {
 			tif = orderTifImmediate
 		}
+		flags := encode.BuildyBytes{}.
+			AddData(uint64B(o.Rate)).
+			AddData(tif)
+		if o.Visible > 0 {
+			flags = flags.AddData(uint64B(o.Visible))
+		}
 		return encode.BuildyBytes{0}.
 			AddData(orderTypeLimit).
 			AddData(EncodePrefix(&o.P)).
 			AddData(EncodeTrade(&o.T)).
-			AddData(encode.BuildyBytes{}.
-				AddData(uint64B(o.Rate)).
-				AddData(tif),
-			)
+			AddData(flags)
 	case *MarketOrder:
 		return encode.BuildyBytes{0}.
 			AddData(orderTypeMarket).
@@ -250,6 +253,18 @@ func DecodeOrder(b []byte) (ord Order, err error) {
 	return nil, fmt.Errorf("unknown Order version %d", ver)
 }
 
+// DecodeRedactedOrder decodes the byte-encoded order created from the output
+// of RedactOrder. The ID of a redacted order is not computable, so the ID of
+// the original order must be provided.
+func DecodeRedactedOrder(b []byte, oid OrderID) (Order, error) {
+	ord, err := DecodeOrder(b)
+	if err != nil {
+		return nil, err
+	}
+	ord.Prefix().id = &oid
+	return ord, nil
+}
+
 // decodeOrder_v0 decodes the version 0 payload into an Order.
 func decodeOrder_v0(pushes [][]byte) (Order, error) {
 	if len(pushes) == 0 {
@@ -276,19 +291,25 @@ func decodeOrder_v0(pushes [][]byte) (Order, error) {
 		if err != nil {
 			return nil, fmt.Errorf("decodeOrder_v0: error extracting limit flags: %d", err)
 		}
-		if len(flags) != 2 {
-			return nil, fmt.Errorf("decodeOrder_v0: expected 2 error flags, got %d", len(flags))
+		// The visible quantity is only encoded for iceberg orders.
+		if len(flags) != 2 && len(flags) != 3 {
+			return nil, fmt.Errorf("decodeOrder_v0: expected 2 or 3 limit flags, got %d", len(flags))
 		}
 		rateB, tifB := flags[0], flags[1]
 		tif := ImmediateTiF
 		if bEqual(tifB, orderTifStanding) {
 			tif = StandingTiF
 		}
+		var visible uint64
+		if len(flags) == 3 {
+			visible = intCoder.Uint64(flags[2])
+		}
 		return &LimitOrder{
-			P:     *prefix,
-			T:     *trade.Copy(),
-			Rate:  intCoder.Uint64(rateB),
-			Force: tif,
+			P:       *prefix,
+			T:       *trade.Copy(),
+			Rate:    intCoder.Uint64(rateB),
+			Force:   tif,
+			Visible: visible,
 		}, nil
 
 	case bEqual(oType, orderTypeMarket):
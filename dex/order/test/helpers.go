@@ -329,6 +329,9 @@ func MustCompareLimitOrders(t testKiller, l1, l2 *order.LimitOrder) {
 	if l1.Force != l2.Force {
 		t.Fatalf("time-in-force mismatch. %d != %d", l1.Force, l2.Force)
 	}
+	if l1.Visible != l2.Visible {
+		t.Fatalf("visible quantity mismatch. %d != %d", l1.Visible, l2.Visible)
+	}
 }
 
 // MustCompareMarketOrders compares the MarketOrders field-by-field and calls
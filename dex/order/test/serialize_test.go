@@ -41,6 +41,14 @@ func testOrders(t *testing.T) {
 
 	MustCompareLimitOrders(t, lo, reLO)
 
+	// An iceberg order.
+	lo.Visible = lo.Quantity / 2
+	reOrder, err = order.DecodeOrder(order.EncodeOrder(lo))
+	if err != nil {
+		t.Fatalf("error decoding iceberg order: %v", err)
+	}
+	MustCompareLimitOrders(t, lo, reOrder.(*order.LimitOrder))
+
 	mo, _ := RandomMarketOrder()
 	mo.Coins = []order.CoinID{randB(36), randB(36), randB(38)}
 	// Not setting the server time on this one.
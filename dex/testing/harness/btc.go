@@ -0,0 +1,232 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	btcRPCUser    = "user"
+	btcRPCPass    = "pass"
+	btcWalletPass = "abc"
+)
+
+// btcNodeCfg describes a bitcoind node and the named wallet that is created
+// in its wallet directory.
+type btcNodeCfg struct {
+	name       string
+	rpcPort    int
+	listenPort int
+	seed       string
+	miningAddr string
+	wallet     string
+	walletSeed string
+	walletAddr string
+}
+
+var btcNodeCfgs = []*btcNodeCfg{
+	{
+		name:       "alpha",
+		rpcPort:    30556,
+		listenPort: 30575,
+		seed:       "cMndqchcXSCUQDDZQSKU2cUHbPb5UfFL9afspxsBELeE6qx6ac9n",
+		miningAddr: "2MzNGEV9CBZBptm25CZ4rm2TrKF8gfVU8XA",
+		wallet:     "gamma",
+		walletSeed: "cR6gasj1RtB9Qv9j2kVej2XzQmXPmZBcn8KzUmxSSCQoz3TqTNMg",
+		walletAddr: "2N9Lwbw6DKoNSyTB9xL4e9LXftuPP7XU214",
+	},
+	{
+		name:       "beta",
+		rpcPort:    30557,
+		listenPort: 30576,
+		seed:       "cRHosJjgZ2UWsEAeHYYUFa8Z6viHYXm94GguGtpzMo6qwKBC1DSq",
+		miningAddr: "2NC2bYfZ9GX3gnDZB8CL7pYLytNKMfVxYDX",
+		wallet:     "delta",
+		walletSeed: "cURsyTZ8icuTHwWxSfTC2Geu2F6dMRtnzt1gvSaxHdc9Zf6eviJN",
+		walletAddr: "2NBeBjSW2W5yhRK23F7rZMtqKiDsLCKmifa",
+	},
+}
+
+// BTC is a Bitcoin regtest harness with two bitcoind nodes, each with a named
+// trading wallet.
+type BTC struct {
+	dir   string
+	nodes map[string]*Process
+}
+
+// newBTC creates a new BTC harness rooted at dir.
+func newBTC(dir string) *BTC {
+	b := &BTC{
+		dir:   dir,
+		nodes: make(map[string]*Process),
+	}
+	for _, n := range btcNodeCfgs {
+		nodeDir := filepath.Join(dir, n.name)
+		b.nodes[n.name] = newProcess("bitcoind", nodeDir, "bitcoind",
+			"-rpcuser="+btcRPCUser, "-rpcpassword="+btcRPCPass,
+			"-rpcport="+strconv.Itoa(n.rpcPort), "-datadir="+nodeDir,
+			"-txindex=1", "-regtest=1", "-port="+strconv.Itoa(n.listenPort),
+			"-fallbackfee=0.00001")
+	}
+	return b
+}
+
+// Start starts the nodes, seeds the node wallets, creates and funds the named
+// trading wallets.
+func (b *BTC) Start(ctx context.Context) error {
+	for _, n := range btcNodeCfgs {
+		if err := b.nodes[n.name].Start(nil); err != nil {
+			return err
+		}
+		if err := b.writeNodeConf(n); err != nil {
+			return err
+		}
+	}
+	ok := tryUntil(ctx, 30*time.Second, func() bool {
+		_, err := b.Ctl(ctx, "alpha", "", "getblockchaininfo")
+		return err == nil
+	})
+	if !ok {
+		return fmt.Errorf("bitcoind did not start")
+	}
+	alphaAddr := "127.0.0.1:" + strconv.Itoa(btcNodeCfgs[0].listenPort)
+	if _, err := b.Ctl(ctx, "beta", "", "addnode", alphaAddr, "add"); err != nil {
+		return err
+	}
+	if err := b.Mine(ctx, "alpha", 1); err != nil {
+		return err
+	}
+
+	blocks := map[string]int{"alpha": 30, "beta": 110}
+	for _, n := range btcNodeCfgs {
+		if _, err := b.Ctl(ctx, n.name, "", "sethdseed", "true", n.seed); err != nil {
+			return err
+		}
+		if err := b.Mine(ctx, n.name, blocks[n.name]); err != nil {
+			return err
+		}
+		if _, err := b.Ctl(ctx, n.name, "", "createwallet", n.wallet); err != nil {
+			return err
+		}
+		if _, err := b.Ctl(ctx, n.name, n.wallet, "sethdseed", "true", n.walletSeed); err != nil {
+			return err
+		}
+		if _, err := b.Ctl(ctx, n.name, n.wallet, "encryptwallet", btcWalletPass); err != nil {
+			return err
+		}
+	}
+
+	for _, amt := range []int{10, 18, 5, 7, 1, 15, 3, 25} {
+		for _, n := range btcNodeCfgs {
+			if _, err := b.Ctl(ctx, "alpha", "", "sendtoaddress", n.walletAddr, strconv.Itoa(amt)); err != nil {
+				return err
+			}
+		}
+		if err := b.Mine(ctx, "alpha", 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops the nodes.
+func (b *BTC) Stop() {
+	for _, n := range b.nodes {
+		n.Stop(10 * time.Second)
+	}
+}
+
+// Node is the named bitcoind process.
+func (b *BTC) Node(name string) *Process {
+	return b.nodes[name]
+}
+
+func (b *BTC) nodeConfPath(name string) string {
+	return filepath.Join(b.dir, name+".conf")
+}
+
+// NodeConfig is the path to the named node's config file, suitable for use in
+// a dcrdex markets.json file or as a client wallet config.
+func (b *BTC) NodeConfig(name string) string {
+	return b.nodeConfPath(name)
+}
+
+// WalletConfig is the config text for the named node's wallet.
+func (b *BTC) WalletConfig(node string) (string, error) {
+	c, err := ioutil.ReadFile(b.nodeConfPath(node))
+	return string(c), err
+}
+
+// WalletName is the name of the trading wallet on the named node.
+func (b *BTC) WalletName(node string) string {
+	for _, n := range btcNodeCfgs {
+		if n.name == node {
+			return n.wallet
+		}
+	}
+	return ""
+}
+
+// WalletPass is the passphrase of the trading wallets.
+func (b *BTC) WalletPass() []byte {
+	return []byte(btcWalletPass)
+}
+
+func (b *BTC) writeNodeConf(n *btcNodeCfg) error {
+	conf := fmt.Sprintf("rpcuser=%s\nrpcpassword=%s\ndatadir=%s\ntxindex=1\nregtest=1\nrpcport=%d\n",
+		btcRPCUser, btcRPCPass, filepath.Join(b.dir, n.name), n.rpcPort)
+	return ioutil.WriteFile(b.nodeConfPath(n.name), []byte(conf), 0600)
+}
+
+// Ctl runs a bitcoin-cli command against the named node. If wallet is not
+// empty, the command is directed at the named wallet.
+func (b *BTC) Ctl(ctx context.Context, node, wallet string, args ...string) (string, error) {
+	for _, n := range btcNodeCfgs {
+		if n.name == node {
+			base := []string{"-rpcwallet=" + wallet, "-rpcport=" + strconv.Itoa(n.rpcPort),
+				"-regtest=1", "-rpcuser=" + btcRPCUser, "-rpcpassword=" + btcRPCPass}
+			return run(ctx, b.dir, "bitcoin-cli", append(base, args...)...)
+		}
+	}
+	return "", fmt.Errorf("unknown btc node %q", node)
+}
+
+// Mine mines n blocks on the named node.
+func (b *BTC) Mine(ctx context.Context, node string, n int) error {
+	for _, c := range btcNodeCfgs {
+		if c.name == node {
+			_, err := b.Ctl(ctx, node, "", "generatetoaddress", strconv.Itoa(n), c.miningAddr)
+			return err
+		}
+	}
+	return fmt.Errorf("unknown btc node %q", node)
+}
+
+// Reorg disconnects the nodes, mines one block on alpha and three on beta,
+// and reconnects them, forcing alpha to reorganize.
+func (b *BTC) Reorg(ctx context.Context) error {
+	alphaAddr := "127.0.0.1:" + strconv.Itoa(btcNodeCfgs[0].listenPort)
+	if _, err := b.Ctl(ctx, "beta", "", "disconnectnode", alphaAddr); err != nil {
+		return err
+	}
+	time.Sleep(time.Second)
+	if err := b.Mine(ctx, "alpha", 1); err != nil {
+		return err
+	}
+	if err := b.Mine(ctx, "beta", 3); err != nil {
+		return err
+	}
+	time.Sleep(2 * time.Second)
+	_, err := b.Ctl(ctx, "beta", "", "addnode", alphaAddr, "onetry")
+	time.Sleep(2 * time.Second)
+	return err
+}
@@ -0,0 +1,206 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/client/asset/btc"
+	"decred.org/dcrdex/client/asset/dcr"
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
+)
+
+const (
+	dcrID = dcr.BipID
+	btcID = btc.BipID
+)
+
+// Client is a DEX client backed by a core.Core with a DCR and a BTC wallet
+// from the harness.
+type Client struct {
+	Name    string
+	Core    *core.Core
+	appPass []byte
+	h       *Harness
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewClient creates and starts a client that uses the named DCR wallet and
+// the trading wallet of the named BTC node, registers with the harness's DEX
+// and waits for the registration fee to be confirmed.
+func (h *Harness) NewClient(ctx context.Context, name, dcrWallet, btcNode string) (*Client, error) {
+	c, err := core.New(&core.Config{
+		DBPath: filepath.Join(h.Root, "clients", name, "dexc.db"),
+		Net:    dex.Simnet,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cl := &Client{
+		Name:    name,
+		Core:    c,
+		appPass: []byte(name),
+		h:       h,
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	cl.cancel = cancel
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		c.Run(runCtx)
+	}()
+	h.clients = append(h.clients, cl)
+
+	if err = c.InitializeClient(cl.appPass); err != nil {
+		return nil, err
+	}
+
+	dcrCfg, err := h.DCR.WalletConfig(dcrWallet)
+	if err != nil {
+		return nil, err
+	}
+	err = c.CreateWallet(cl.appPass, h.DCR.WalletPass(), &core.WalletForm{
+		AssetID:    dcrID,
+		Account:    "default",
+		ConfigText: dcrCfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating dcr wallet: %v", err)
+	}
+	btcCfg, err := h.BTC.WalletConfig(btcNode)
+	if err != nil {
+		return nil, err
+	}
+	err = c.CreateWallet(cl.appPass, h.BTC.WalletPass(), &core.WalletForm{
+		AssetID:    btcID,
+		Account:    h.BTC.WalletName(btcNode),
+		ConfigText: btcCfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating btc wallet: %v", err)
+	}
+
+	return cl, cl.register(ctx)
+}
+
+// register pays the registration fee, mines the fee confirmations, and waits
+// for the account to be registered.
+func (cl *Client) register(ctx context.Context) error {
+	cert, err := cl.h.DEX.Cert()
+	if err != nil {
+		return err
+	}
+	fee, err := cl.Core.GetFee(cl.h.DEX.Addr(), cert)
+	if err != nil {
+		return err
+	}
+	feed := cl.Core.NotificationFeed()
+	res, err := cl.Core.Register(&core.RegisterForm{
+		Addr:    cl.h.DEX.Addr(),
+		Cert:    cert,
+		AppPass: cl.appPass,
+		Fee:     fee,
+	})
+	if err != nil {
+		return err
+	}
+	if err = cl.h.Mine(ctx, dcrID, int(res.ReqConfirms)); err != nil {
+		return err
+	}
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case n := <-feed:
+			if n.Type() == "feepayment" && n.Subject() == "Account registered" {
+				return nil
+			}
+		case <-timeout:
+			return fmt.Errorf("%s: registration not confirmed", cl.Name)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Stop stops the client's core.
+func (cl *Client) Stop() {
+	if cl.cancel != nil {
+		cl.cancel()
+		cl.wg.Wait()
+		cl.cancel = nil
+	}
+}
+
+// Trade places a limit order on the DCR-BTC market.
+func (cl *Client) Trade(sell bool, qty, rate uint64) (*core.Order, error) {
+	return cl.Core.Trade(cl.appPass, &core.TradeForm{
+		Host:    cl.h.DEX.Addr(),
+		IsLimit: true,
+		Sell:    sell,
+		Base:    dcrID,
+		Quote:   btcID,
+		Qty:     qty,
+		Rate:    rate,
+	})
+}
+
+// Order finds the order with the given ID.
+func (cl *Client) Order(oid string) *core.Order {
+	for _, xc := range cl.Core.Exchanges() {
+		for _, mkt := range xc.Markets {
+			for _, ord := range mkt.Orders {
+				if ord.ID == oid {
+					return ord
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MatchesReached is true if the order has at least one match and every match
+// has reached the status.
+func (cl *Client) MatchesReached(oid string, status order.MatchStatus) bool {
+	ord := cl.Order(oid)
+	if ord == nil || len(ord.Matches) == 0 {
+		return false
+	}
+	for _, m := range ord.Matches {
+		if m.Status < status {
+			return false
+		}
+	}
+	return true
+}
+
+// MineUntil mines a block on each chain every few seconds until the condition
+// is met or the timeout expires.
+func (h *Harness) MineUntil(ctx context.Context, timeout time.Duration, cond func() bool) error {
+	ok := tryUntil(ctx, timeout, func() bool {
+		if cond() {
+			return true
+		}
+		if err := h.Mine(ctx, dcrID, 1); err != nil {
+			return false
+		}
+		if err := h.Mine(ctx, btcID, 1); err != nil {
+			return false
+		}
+		time.Sleep(2 * time.Second)
+		return cond()
+	})
+	if !ok {
+		return fmt.Errorf("condition not met after %s", timeout)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dcrRPCUser    = "user"
+	dcrRPCPass    = "pass"
+	dcrWalletPass = "123"
+
+	dcrAlphaMiningAddr = "SspUvSyDGSzvPz2NfdZ5LW15uq6rmuGZyhL"
+	dcrBetaMiningAddr  = "SsiuwSRYvH7pqWmRxFJWR8Vmqc3AWsjmK2Y"
+)
+
+// dcrNodeCfg describes a dcrd node.
+type dcrNodeCfg struct {
+	name       string
+	rpcPort    int
+	listenPort int
+	miningAddr string
+	connect    string
+}
+
+// dcrWalletCfg describes a dcrwallet and the node it connects to.
+type dcrWalletCfg struct {
+	name    string
+	seed    string
+	rpcPort int
+	node    string
+	address string
+}
+
+var (
+	dcrNodeCfgs = []*dcrNodeCfg{
+		{name: "alpha", rpcPort: 29570, listenPort: 29571, miningAddr: dcrAlphaMiningAddr},
+		{name: "beta", rpcPort: 29569, listenPort: 29559, miningAddr: dcrBetaMiningAddr, connect: "127.0.0.1:29571"},
+	}
+
+	dcrWalletCfgs = []*dcrWalletCfg{
+		{
+			name:    "alpha",
+			seed:    "b280922d2cffda44648346412c5ec97f429938105003730414f10b01e1402eac",
+			rpcPort: 29567,
+			node:    "alpha",
+			address: dcrAlphaMiningAddr,
+		},
+		{
+			name:    "trading1",
+			seed:    "31cc0eeb220aa5b1f1ab3b6c47529d737976af1a556b156a665408f1711c962f",
+			rpcPort: 29581,
+			node:    "alpha",
+			address: "SsZFpS6iDJS2dEFa4i4azCTgjbv6zrEbos9",
+		},
+		{
+			name:    "trading2",
+			seed:    "3db72efa55b9e6cce9c27dde9bea848c6199004f9b1ae2add3b04389495edb9c",
+			rpcPort: 29582,
+			node:    "alpha",
+			address: "SsYeg8owvUbE2crm61E3ePcvNtAestYfZYR",
+		},
+	}
+)
+
+// DCR is a Decred simnet harness with two dcrd nodes and a set of dcrwallets.
+type DCR struct {
+	dir     string
+	nodes   map[string]*Process
+	wallets map[string]*Process
+	cfgs    map[string]*dcrWalletCfg
+}
+
+// newDCR creates a new DCR harness rooted at dir.
+func newDCR(dir string) *DCR {
+	d := &DCR{
+		dir:     dir,
+		nodes:   make(map[string]*Process),
+		wallets: make(map[string]*Process),
+		cfgs:    make(map[string]*dcrWalletCfg),
+	}
+	for _, n := range dcrNodeCfgs {
+		nodeDir := filepath.Join(dir, n.name)
+		args := []string{
+			"--simnet", "--appdata=" + nodeDir,
+			"--rpcuser=" + dcrRPCUser, "--rpcpass=" + dcrRPCPass,
+			"--rpclisten=127.0.0.1:" + strconv.Itoa(n.rpcPort),
+			"--listen=127.0.0.1:" + strconv.Itoa(n.listenPort),
+			"--miningaddr=" + n.miningAddr,
+			"--txindex", "--debuglevel=debug",
+		}
+		if n.connect != "" {
+			args = append(args, "--connect="+n.connect)
+		}
+		d.nodes[n.name] = newProcess("dcrd", nodeDir, "dcrd", args...)
+	}
+	for _, w := range dcrWalletCfgs {
+		walletDir := filepath.Join(dir, w.name)
+		d.cfgs[w.name] = w
+		d.wallets[w.name] = newProcess("dcrwallet", walletDir, "dcrwallet", "-C", d.walletConfPath(w.name))
+	}
+	return d
+}
+
+// Start starts the nodes, creates and starts the wallets, mines enough blocks
+// for the wallets to be funded and sends funds to the trading wallets.
+func (d *DCR) Start(ctx context.Context) error {
+	for _, n := range dcrNodeCfgs {
+		if err := d.nodes[n.name].Start(nil); err != nil {
+			return err
+		}
+		if err := d.writeNodeConf(n); err != nil {
+			return err
+		}
+	}
+	time.Sleep(3 * time.Second)
+
+	for _, w := range dcrWalletCfgs {
+		if err := d.createWallet(ctx, w); err != nil {
+			return err
+		}
+	}
+	// Give the wallets time to sync.
+	time.Sleep(5 * time.Second)
+
+	if err := d.Mine(ctx, "alpha", 160); err != nil {
+		return err
+	}
+	for _, amt := range []int{10, 18, 5, 7, 1, 15, 3, 25} {
+		for _, w := range dcrWalletCfgs[1:] {
+			if _, err := d.Wallet(ctx, "alpha", "sendtoaddress", w.address, strconv.Itoa(amt)); err != nil {
+				return err
+			}
+		}
+		if err := d.Mine(ctx, "alpha", 1); err != nil {
+			return err
+		}
+	}
+	_, err := d.Wallet(ctx, "alpha", "createnewaccount", "server_fees")
+	return err
+}
+
+// Stop stops all wallets and nodes.
+func (d *DCR) Stop() {
+	for _, w := range d.wallets {
+		w.Stop(10 * time.Second)
+	}
+	for _, n := range d.nodes {
+		n.Stop(10 * time.Second)
+	}
+}
+
+// Node is the named dcrd node process.
+func (d *DCR) Node(name string) *Process {
+	return d.nodes[name]
+}
+
+func (d *DCR) nodeConfPath(name string) string {
+	return filepath.Join(d.dir, name, "dcrd.conf")
+}
+
+func (d *DCR) walletConfPath(name string) string {
+	return filepath.Join(d.dir, name, "w-"+name+".conf")
+}
+
+// NodeConfig is the path to the named node's config file, suitable for use in
+// a dcrdex markets.json file.
+func (d *DCR) NodeConfig(name string) string {
+	return d.nodeConfPath(name)
+}
+
+// WalletConfig is the config text for the named wallet, suitable for use in
+// a client WalletForm.
+func (d *DCR) WalletConfig(name string) (string, error) {
+	b, err := ioutil.ReadFile(d.walletConfPath(name))
+	return string(b), err
+}
+
+// WalletPass is the private passphrase of all harness wallets.
+func (d *DCR) WalletPass() []byte {
+	return []byte(dcrWalletPass)
+}
+
+func (d *DCR) writeNodeConf(n *dcrNodeCfg) error {
+	conf := fmt.Sprintf("rpcuser=%s\nrpcpass=%s\nrpccert=%s\nrpclisten=127.0.0.1:%d\n",
+		dcrRPCUser, dcrRPCPass, filepath.Join(d.dir, n.name, "rpc.cert"), n.rpcPort)
+	return ioutil.WriteFile(d.nodeConfPath(n.name), []byte(conf), 0600)
+}
+
+// createWallet writes the wallet's config, creates the wallet from its seed,
+// and starts it.
+func (d *DCR) createWallet(ctx context.Context, w *dcrWalletCfg) error {
+	walletDir := filepath.Join(d.dir, w.name)
+	var nodePort int
+	for _, n := range dcrNodeCfgs {
+		if n.name == w.node {
+			nodePort = n.rpcPort
+		}
+	}
+	conf := strings.Join([]string{
+		"simnet=1",
+		"nogrpc=1",
+		"appdata=" + walletDir,
+		"logdir=" + filepath.Join(walletDir, "log"),
+		"debuglevel=debug",
+		"username=" + dcrRPCUser,
+		"password=" + dcrRPCPass,
+		"rpclisten=127.0.0.1:" + strconv.Itoa(w.rpcPort),
+		"rpccert=" + filepath.Join(walletDir, "rpc.cert"),
+		"pass=" + dcrWalletPass,
+		"rpcconnect=127.0.0.1:" + strconv.Itoa(nodePort),
+		"cafile=" + filepath.Join(d.dir, w.node, "rpc.cert"),
+	}, "\n") + "\n"
+	if err := ioutil.WriteFile(d.walletConfPath(w.name), []byte(conf), 0600); err != nil {
+		return err
+	}
+
+	// Answer the wallet creation prompts: passphrase twice, no public
+	// encryption, use an existing seed, the seed, and a blank line.
+	answers := strings.Join([]string{dcrWalletPass, dcrWalletPass, "n", "y", w.seed, "", ""}, "\n")
+	create := newProcess("dcrwallet-create", walletDir, "dcrwallet", "-C", d.walletConfPath(w.name), "--create")
+	if err := create.Start(strings.NewReader(answers)); err != nil {
+		return err
+	}
+	if err := create.Wait(); err != nil {
+		return fmt.Errorf("error creating %s wallet: %v", w.name, err)
+	}
+	if err := d.wallets[w.name].Start(nil); err != nil {
+		return err
+	}
+	ok := tryUntil(ctx, 30*time.Second, func() bool {
+		_, err := d.Wallet(ctx, w.name, "getbalance")
+		return err == nil
+	})
+	if !ok {
+		return fmt.Errorf("%s wallet did not start", w.name)
+	}
+	return nil
+}
+
+// Ctl runs a dcrctl command against the named node.
+func (d *DCR) Ctl(ctx context.Context, node string, args ...string) (string, error) {
+	for _, n := range dcrNodeCfgs {
+		if n.name == node {
+			base := []string{"--simnet", "-u", dcrRPCUser, "-P", dcrRPCPass,
+				"-s", "127.0.0.1:" + strconv.Itoa(n.rpcPort),
+				"-c", filepath.Join(d.dir, node, "rpc.cert")}
+			return run(ctx, d.dir, "dcrctl", append(base, args...)...)
+		}
+	}
+	return "", fmt.Errorf("unknown dcr node %q", node)
+}
+
+// Wallet runs a dcrctl wallet command against the named wallet.
+func (d *DCR) Wallet(ctx context.Context, wallet string, args ...string) (string, error) {
+	w, found := d.cfgs[wallet]
+	if !found {
+		return "", fmt.Errorf("unknown dcr wallet %q", wallet)
+	}
+	base := []string{"--simnet", "--wallet", "-u", dcrRPCUser, "-P", dcrRPCPass,
+		"-s", "127.0.0.1:" + strconv.Itoa(w.rpcPort),
+		"-c", filepath.Join(d.dir, wallet, "rpc.cert")}
+	return run(ctx, d.dir, "dcrctl", append(base, args...)...)
+}
+
+// Mine mines n blocks on the named node.
+func (d *DCR) Mine(ctx context.Context, node string, n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := d.Ctl(ctx, node, "generate", "1"); err != nil {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// Reorg disconnects the beta node from alpha, mines one block on alpha and
+// three blocks on beta, and reconnects the nodes, forcing alpha to reorganize.
+func (d *DCR) Reorg(ctx context.Context) error {
+	alphaAddr := "127.0.0.1:" + strconv.Itoa(dcrNodeCfgs[0].listenPort)
+	if _, err := d.Ctl(ctx, "beta", "addnode", alphaAddr, "remove"); err != nil {
+		return err
+	}
+	time.Sleep(time.Second)
+	if err := d.Mine(ctx, "alpha", 1); err != nil {
+		return err
+	}
+	if err := d.Mine(ctx, "beta", 3); err != nil {
+		return err
+	}
+	time.Sleep(2 * time.Second)
+	_, err := d.Ctl(ctx, "beta", "addnode", alphaAddr, "add")
+	time.Sleep(2 * time.Second)
+	return err
+}
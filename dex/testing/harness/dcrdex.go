@@ -0,0 +1,258 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	dexRPCAddr       = "127.0.0.1:27273"
+	dexAdminAddr     = "127.0.0.1:26542"
+	dexAdminPass     = "adminpass"
+	dexSigningPass   = "keypass"
+	dexRegFeeXPub    = "spubVWHTkHRefqHpw3qMN2VTWqnT9rMuqDZrbdgxp8QzGBvXCYfdUuk2VKFnsPHE1CBBNSZjrwHTx2LrM9LbXbGH7gb9qLCYc3JUkLW883SeQ2H"
+	dexEpochMs       = 6000
+	dexLockTimeTaker = "30s"
+	dexLockTimeMaker = "1m"
+)
+
+// DBConfig is the PostgreSQL configuration for the harness's dcrdex instance.
+// The database is dropped and re-created when the harness starts.
+type DBConfig struct {
+	Name string
+	User string
+	Pass string
+	Host string
+}
+
+// DEXServer is a dcrdex instance built from this source tree.
+type DEXServer struct {
+	dir    string
+	db     *DBConfig
+	proc   *Process
+	faults bool
+	extra  []string
+}
+
+// newDEXServer creates a DEXServer rooted at dir. If faults is true, dcrdex is
+// built with the fault build tag. Any extra arguments are passed to dcrdex on
+// the command line.
+func newDEXServer(dir string, db *DBConfig, faults bool, extra ...string) *DEXServer {
+	return &DEXServer{
+		dir:    dir,
+		db:     db,
+		faults: faults,
+		extra:  extra,
+	}
+}
+
+// Addr is the address of the DEX's client RPC server.
+func (s *DEXServer) Addr() string {
+	return dexRPCAddr
+}
+
+// Cert is the TLS certificate of the DEX's RPC server.
+func (s *DEXServer) Cert() (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, "rpc.cert"))
+	return string(b), err
+}
+
+// Process is the dcrdex process.
+func (s *DEXServer) Process() *Process {
+	return s.proc
+}
+
+// ArmFault arms a fault injection point in dcrdex. See the server/fault
+// package for the available points. The harness must have been configured with
+// Faults.
+func (s *DEXServer) ArmFault(ctx context.Context, point string, count int, delay time.Duration) error {
+	_, err := s.Admin(ctx, http.MethodGet, fmt.Sprintf("fault/%s?count=%d&delay=%s", point, count, delay))
+	return err
+}
+
+// ClearFault disarms a fault injection point in dcrdex.
+func (s *DEXServer) ClearFault(ctx context.Context, point string) error {
+	_, err := s.Admin(ctx, http.MethodGet, fmt.Sprintf("fault/%s?clear=true", point))
+	return err
+}
+
+// build compiles dcrdex with the simnet swap locktimes shortened so that
+// refunds can be exercised in reasonable time.
+func (s *DEXServer) build(ctx context.Context) (string, error) {
+	_, thisFile, _, _ := runtime.Caller(0)
+	srcDir := filepath.Join(filepath.Dir(thisFile), "..", "..", "..", "server", "cmd", "dcrdex")
+	exe := filepath.Join(s.dir, "dcrdex")
+	ldflags := fmt.Sprintf("-X 'decred.org/dcrdex/dex.testLockTimeTaker=%s' -X 'decred.org/dcrdex/dex.testLockTimeMaker=%s'",
+		dexLockTimeTaker, dexLockTimeMaker)
+	args := []string{"build", "-o", exe, "-ldflags", ldflags}
+	if s.faults {
+		args = append(args, "-tags", "fault")
+	}
+	if _, err := run(ctx, srcDir, "go", args...); err != nil {
+		return "", err
+	}
+	return exe, nil
+}
+
+// resetDB drops and re-creates the test database.
+func (s *DEXServer) resetDB(ctx context.Context) error {
+	host, port := s.db.Host, "5432"
+	if !strings.HasPrefix(host, "/") {
+		if i := strings.LastIndex(host, ":"); i > 0 {
+			host, port = host[:i], host[i+1:]
+		}
+	}
+	cmd := exec.CommandContext(ctx, "psql", "-h", host, "-p", port, "-U", s.db.User, "-d", "postgres",
+		"-c", "DROP DATABASE IF EXISTS "+s.db.Name, "-c", "CREATE DATABASE "+s.db.Name)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+s.db.Pass)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error resetting database: %v: %s", err, string(out))
+	}
+	return nil
+}
+
+// writeConfig writes markets.json and dcrdex.conf for the DCR-BTC market.
+func (s *DEXServer) writeConfig(dcrNodeConf, btcNodeConf string) error {
+	markets := fmt.Sprintf(`{
+    "markets": [
+        {
+            "base": "DCR_simnet",
+            "quote": "BTC_simnet",
+            "epochDuration": %d,
+            "marketBuyBuffer": 1.2
+        }
+    ],
+    "assets": {
+        "DCR_simnet": {
+            "bip44symbol": "dcr",
+            "network": "simnet",
+            "lotSize": 100000000,
+            "rateStep": 100000,
+            "maxFeeRate": 10,
+            "swapConf": 1,
+            "configPath": %q
+        },
+        "BTC_simnet": {
+            "bip44symbol": "btc",
+            "network": "simnet",
+            "lotSize": 100000,
+            "rateStep": 100,
+            "maxFeeRate": 100,
+            "swapConf": 1,
+            "configPath": %q
+        }
+    }
+}
+`, dexEpochMs, dcrNodeConf, btcNodeConf)
+	if err := ioutil.WriteFile(filepath.Join(s.dir, "markets.json"), []byte(markets), 0600); err != nil {
+		return err
+	}
+
+	conf := strings.Join([]string{
+		"regfeexpub=" + dexRegFeeXPub,
+		"pgdbname=" + s.db.Name,
+		"pguser=" + s.db.User,
+		"pgpass=" + s.db.Pass,
+		"pghost=" + s.db.Host,
+		"simnet=1",
+		"rpclisten=" + dexRPCAddr,
+		"debuglevel=trace",
+		"regfeeconfirms=1",
+		"signingkeypass=" + dexSigningPass,
+		"adminsrvon=1",
+		"adminsrvpass=" + dexAdminPass,
+		"adminsrvaddr=" + dexAdminAddr,
+	}, "\n") + "\n"
+	return ioutil.WriteFile(filepath.Join(s.dir, "dcrdex.conf"), []byte(conf), 0600)
+}
+
+// Start builds dcrdex, prepares the database and configuration, and starts
+// the server, waiting for the admin server to respond.
+func (s *DEXServer) Start(ctx context.Context, dcrNodeConf, btcNodeConf string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	exe, err := s.build(ctx)
+	if err != nil {
+		return err
+	}
+	if err = s.resetDB(ctx); err != nil {
+		return err
+	}
+	if err = s.writeConfig(dcrNodeConf, btcNodeConf); err != nil {
+		return err
+	}
+	s.proc = newProcess("dcrdex", s.dir, exe, append([]string{"--appdata=" + s.dir}, s.extra...)...)
+	return s.Restart(ctx)
+}
+
+// Restart starts a stopped dcrdex process without resetting its database,
+// allowing tests to exercise swap state resumption.
+func (s *DEXServer) Restart(ctx context.Context) error {
+	if err := s.proc.Start(nil); err != nil {
+		return err
+	}
+	ok := tryUntil(ctx, time.Minute, func() bool {
+		_, err := s.Admin(ctx, http.MethodGet, "ping")
+		return err == nil
+	})
+	if !ok {
+		return fmt.Errorf("dcrdex did not start. see %s", filepath.Join(s.dir, "dcrdex.out"))
+	}
+	return nil
+}
+
+// Stop stops dcrdex.
+func (s *DEXServer) Stop() error {
+	if s.proc == nil {
+		return nil
+	}
+	return s.proc.Stop(30 * time.Second)
+}
+
+// Admin sends a request to the admin server and returns the response body.
+func (s *DEXServer) Admin(ctx context.Context, method, path string) ([]byte, error) {
+	cert, err := s.Cert()
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(cert))
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		Timeout:   10 * time.Second,
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "https://"+dexAdminAddr+"/api/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("u", dexAdminPass)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
@@ -0,0 +1,128 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package harness provides a Go simnet harness for end-to-end testing of the
+// DEX. The harness starts DCR and BTC nodes and wallets, a dcrdex server built
+// from this source tree, and any number of clients, and exposes controls for
+// mining, reorgs, and stopping or restarting any of the processes. The
+// package is only built with the live build tag, and requires dcrd,
+// dcrwallet, dcrctl, bitcoind, bitcoin-cli, and psql to be on the PATH, and a
+// PostgreSQL server that the configured user can create databases on.
+//
+//	go test -v -tags=live -timeout=30m ./dex/testing/harness
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// Config is the harness configuration.
+type Config struct {
+	// Root is the directory under which all harness data is written. It is
+	// removed when the harness starts. Defaults to ~/dextest-go.
+	Root string
+	// DB is the PostgreSQL configuration for dcrdex.
+	DB *DBConfig
+	// DEXArgs are additional command line arguments for dcrdex.
+	DEXArgs []string
+	// Faults builds dcrdex with the fault build tag, allowing tests to arm
+	// fault injection points with DEXServer.ArmFault.
+	Faults bool
+}
+
+// Harness is a running simnet environment.
+type Harness struct {
+	Root string
+	DCR  *DCR
+	BTC  *BTC
+	DEX  *DEXServer
+
+	clients []*Client
+}
+
+// DefaultConfig is a Config with the default root directory and a database
+// setup that matches the dcrdex harness script.
+func DefaultConfig() (*Config, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		Root: filepath.Join(usr.HomeDir, "dextest-go"),
+		DB: &DBConfig{
+			Name: "dcrdex_simnet_harness",
+			User: "dcrdex",
+			Host: "127.0.0.1:5432",
+		},
+		Faults: true,
+	}, nil
+}
+
+// New creates a new Harness. Start must be called to start the processes.
+func New(cfg *Config) (*Harness, error) {
+	if cfg.Root == "" || cfg.DB == nil {
+		return nil, fmt.Errorf("harness root and db config are required")
+	}
+	return &Harness{
+		Root: cfg.Root,
+		DCR:  newDCR(filepath.Join(cfg.Root, "dcr")),
+		BTC:  newBTC(filepath.Join(cfg.Root, "btc")),
+		DEX:  newDEXServer(filepath.Join(cfg.Root, "dcrdex"), cfg.DB, cfg.Faults, cfg.DEXArgs...),
+	}, nil
+}
+
+// Start starts the nodes, wallets, and dcrdex. If Start returns an error,
+// Stop should still be called to shut down any processes that were started.
+func (h *Harness) Start(ctx context.Context) error {
+	if err := os.RemoveAll(h.Root); err != nil {
+		return err
+	}
+	if err := h.DCR.Start(ctx); err != nil {
+		return fmt.Errorf("dcr harness error: %v", err)
+	}
+	if err := h.BTC.Start(ctx); err != nil {
+		return fmt.Errorf("btc harness error: %v", err)
+	}
+	if err := h.DEX.Start(ctx, h.DCR.NodeConfig("alpha"), h.BTC.NodeConfig("alpha")); err != nil {
+		return fmt.Errorf("dcrdex error: %v", err)
+	}
+	return nil
+}
+
+// Stop stops all clients and processes.
+func (h *Harness) Stop() {
+	for _, c := range h.clients {
+		c.Stop()
+	}
+	h.DEX.Stop()
+	h.BTC.Stop()
+	h.DCR.Stop()
+}
+
+// Mine mines n blocks on the alpha node of the asset's network.
+func (h *Harness) Mine(ctx context.Context, assetID uint32, n int) error {
+	switch assetID {
+	case dcrID:
+		return h.DCR.Mine(ctx, "alpha", n)
+	case btcID:
+		return h.BTC.Mine(ctx, "alpha", n)
+	}
+	return fmt.Errorf("unknown asset %d", assetID)
+}
+
+// Reorg forces a reorganization on the asset's network.
+func (h *Harness) Reorg(ctx context.Context, assetID uint32) error {
+	switch assetID {
+	case dcrID:
+		return h.DCR.Reorg(ctx)
+	case btcID:
+		return h.BTC.Reorg(ctx)
+	}
+	return fmt.Errorf("unknown asset %d", assetID)
+}
@@ -0,0 +1,158 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
+	"github.com/decred/slog"
+)
+
+var (
+	tHarness *Harness
+	tMaker   *Client
+	tTaker   *Client
+	tCtx     context.Context
+)
+
+func TestMain(m *testing.M) {
+	core.UseLoggerMaker(&dex.LoggerMaker{
+		Backend:      slog.NewBackend(os.Stdout),
+		DefaultLevel: slog.LevelError,
+	})
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	var cancel context.CancelFunc
+	tCtx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg, err := DefaultConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		return 1
+	}
+	if pass := os.Getenv("DEXTEST_PGPASS"); pass != "" {
+		cfg.DB.Pass = pass
+	}
+	tHarness, err = New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "harness error:", err)
+		return 1
+	}
+	defer tHarness.Stop()
+	if err = tHarness.Start(tCtx); err != nil {
+		fmt.Fprintln(os.Stderr, "harness start error:", err)
+		return 1
+	}
+	tMaker, err = tHarness.NewClient(tCtx, "maker", "trading1", "alpha")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "maker client error:", err)
+		return 1
+	}
+	tTaker, err = tHarness.NewClient(tCtx, "taker", "trading2", "beta")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "taker client error:", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// placeMatchingOrders books a sell order from the maker and a buy order from
+// the taker in the following epoch, and waits for them to match.
+func placeMatchingOrders(t *testing.T, qty, rate uint64) (makerOID, takerOID string) {
+	t.Helper()
+	makerOrd, err := tMaker.Trade(true, qty, rate)
+	if err != nil {
+		t.Fatalf("maker order error: %v", err)
+	}
+	time.Sleep(dexEpochMs * time.Millisecond)
+	takerOrd, err := tTaker.Trade(false, qty, rate)
+	if err != nil {
+		t.Fatalf("taker order error: %v", err)
+	}
+	matched := tryUntil(tCtx, 3*dexEpochMs*time.Millisecond, func() bool {
+		return tMaker.MatchesReached(makerOrd.ID, order.NewlyMatched) &&
+			tTaker.MatchesReached(takerOrd.ID, order.NewlyMatched)
+	})
+	if !matched {
+		t.Fatalf("orders not matched")
+	}
+	return makerOrd.ID, takerOrd.ID
+}
+
+func TestTradeSuccess(t *testing.T) {
+	makerOID, takerOID := placeMatchingOrders(t, 2e8, 1.5e4)
+	err := tHarness.MineUntil(tCtx, 2*time.Minute, func() bool {
+		return tMaker.MatchesReached(makerOID, order.MatchComplete) &&
+			tTaker.MatchesReached(takerOID, order.MatchComplete)
+	})
+	if err != nil {
+		t.Fatalf("trade did not complete: %v", err)
+	}
+}
+
+// TestServerRestart stops dcrdex after the match is made, restarts it, and
+// checks that the swap resumes and completes.
+func TestServerRestart(t *testing.T) {
+	makerOID, takerOID := placeMatchingOrders(t, 1e8, 1e4)
+	if err := tHarness.DEX.Stop(); err != nil {
+		t.Fatalf("error stopping dcrdex: %v", err)
+	}
+	if err := tHarness.DEX.Restart(tCtx); err != nil {
+		t.Fatalf("error restarting dcrdex: %v", err)
+	}
+	err := tHarness.MineUntil(tCtx, 3*time.Minute, func() bool {
+		return tMaker.MatchesReached(makerOID, order.MatchComplete) &&
+			tTaker.MatchesReached(takerOID, order.MatchComplete)
+	})
+	if err != nil {
+		t.Fatalf("trade did not complete after restart: %v", err)
+	}
+}
+
+// TestDBError fails the storage of the next order, and checks that the order
+// is rejected while the following order is accepted.
+func TestDBError(t *testing.T) {
+	if err := tHarness.DEX.ArmFault(tCtx, "dberror", 1, 0); err != nil {
+		t.Fatalf("error arming fault: %v", err)
+	}
+	if _, err := tMaker.Trade(true, 1e8, 1e4); err == nil {
+		t.Fatalf("no error for order with injected db error")
+	}
+	ord, err := tMaker.Trade(true, 1e8, 1e4)
+	if err != nil {
+		t.Fatalf("order error after fault cleared: %v", err)
+	}
+	if err = tMaker.Core.Cancel(tMaker.appPass, ord.ID); err != nil {
+		t.Fatalf("cancel error: %v", err)
+	}
+}
+
+// TestBlockDelay delays the server's block processing throughout a trade and
+// checks that the trade still completes.
+func TestBlockDelay(t *testing.T) {
+	if err := tHarness.DEX.ArmFault(tCtx, "blockdelay", 0, 3*time.Second); err != nil {
+		t.Fatalf("error arming fault: %v", err)
+	}
+	defer tHarness.DEX.ClearFault(tCtx, "blockdelay")
+	makerOID, takerOID := placeMatchingOrders(t, 1e8, 1e4)
+	err := tHarness.MineUntil(tCtx, 3*time.Minute, func() bool {
+		return tMaker.MatchesReached(makerOID, order.MatchComplete) &&
+			tTaker.MatchesReached(takerOID, order.MatchComplete)
+	})
+	if err != nil {
+		t.Fatalf("trade did not complete with delayed blocks: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// +build live
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Process is a child process managed by the harness. The process's combined
+// output is written to a log file in the process's directory.
+type Process struct {
+	Name    string
+	Dir     string
+	exe     string
+	args    []string
+	cmd     *exec.Cmd
+	logFile *os.File
+	done    chan struct{}
+	err     error
+}
+
+// newProcess creates a Process that will run exe with args from dir. The
+// process is not started until Start is called.
+func newProcess(name, dir, exe string, args ...string) *Process {
+	return &Process{
+		Name: name,
+		Dir:  dir,
+		exe:  exe,
+		args: args,
+	}
+}
+
+// Start starts the process. Any input in stdin is provided to the process's
+// standard input. A stopped process may be started again.
+func (p *Process) Start(stdin io.Reader) error {
+	if p.Running() {
+		return fmt.Errorf("%s already running", p.Name)
+	}
+	if err := os.MkdirAll(p.Dir, 0700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(p.Dir, p.Name+".out"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(p.exe, p.args...)
+	cmd.Dir = p.Dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Stdin = stdin
+	if err = cmd.Start(); err != nil {
+		logFile.Close()
+		return fmt.Errorf("error starting %s: %v", p.Name, err)
+	}
+	p.cmd, p.logFile, p.err = cmd, logFile, nil
+	p.done = make(chan struct{})
+	go func() {
+		p.err = cmd.Wait()
+		logFile.Close()
+		close(p.done)
+	}()
+	return nil
+}
+
+// Running is true if the process has been started and has not exited.
+func (p *Process) Running() bool {
+	if p.done == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Wait waits for the process to exit and returns the process's exit error.
+func (p *Process) Wait() error {
+	if p.done == nil {
+		return nil
+	}
+	<-p.done
+	return p.err
+}
+
+// Stop sends an interrupt to the process, and kills it if it has not exited
+// within the timeout.
+func (p *Process) Stop(timeout time.Duration) error {
+	if !p.Running() {
+		return nil
+	}
+	if err := p.cmd.Process.Signal(syscall.SIGINT); err != nil {
+		return p.Kill()
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(timeout):
+		return p.Kill()
+	}
+}
+
+// Kill kills the process without allowing it to shut down cleanly.
+func (p *Process) Kill() error {
+	if !p.Running() {
+		return nil
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-p.done
+	return nil
+}
+
+// run runs the command to completion and returns its trimmed output.
+func run(ctx context.Context, dir, exe string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %v: %s", exe, strings.Join(args, " "), err, string(out))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// tryUntil calls f every 250 ms until it returns true, the timeout expires, or
+// the context is canceled.
+func tryUntil(ctx context.Context, timeout time.Duration, f func() bool) bool {
+	expire := time.After(timeout)
+	for {
+		if f() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-expire:
+			return false
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
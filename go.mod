@@ -5,8 +5,11 @@ go 1.13
 require (
 	github.com/btcsuite/btcd v0.20.1-beta.0.20200615134404-e4f59022a387
 	github.com/btcsuite/btcutil v1.0.2
+	github.com/btcsuite/btcwallet/walletdb v1.0.0
 	github.com/davecgh/go-spew v1.1.1
+	github.com/decred/dcrd/addrmgr v1.0.2
 	github.com/decred/dcrd/blockchain/stake/v2 v2.0.2
+	github.com/decred/dcrd/blockchain/standalone v1.1.0
 	github.com/decred/dcrd/certgen v1.1.0
 	github.com/decred/dcrd/chaincfg/chainhash v1.0.2
 	github.com/decred/dcrd/chaincfg/v2 v2.3.0
@@ -16,22 +19,30 @@ require (
 	github.com/decred/dcrd/dcrec/secp256k1/v2 v2.0.0
 	github.com/decred/dcrd/dcrjson/v3 v3.0.1
 	github.com/decred/dcrd/dcrutil/v2 v2.0.1
+	github.com/decred/dcrd/gcs v1.1.0
 	github.com/decred/dcrd/hdkeychain/v2 v2.1.0
 	github.com/decred/dcrd/rpc/jsonrpc/types/v2 v2.0.0
 	github.com/decred/dcrd/rpcclient/v5 v5.0.0
 	github.com/decred/dcrd/txscript/v2 v2.1.0
 	github.com/decred/dcrd/wire v1.3.0
+	github.com/decred/dcrwallet/errors/v2 v2.0.0
+	github.com/decred/dcrwallet/p2p/v2 v2.0.0
 	github.com/decred/dcrwallet/rpc/jsonrpc/types v1.4.0
 	github.com/decred/dcrwallet/wallet/v3 v3.1.1-0.20191230143837-6a86dc4676f0
+	github.com/decred/go-socks v1.1.0
 	github.com/decred/slog v1.0.0
 	github.com/go-chi/chi v4.0.2+incompatible
+	github.com/golang/protobuf v1.3.2
 	github.com/gorilla/websocket v1.4.1
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/lib/pq v1.2.0
+	github.com/lightninglabs/neutrino v0.11.0
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	go.etcd.io/bbolt v1.3.5
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58
+	golang.org/x/sys v0.0.0-20200202164722-d101bd2416d5
+	google.golang.org/grpc v1.27.1
 	gopkg.in/go-ini/ini.v1 v1.55.0
 	gopkg.in/ini.v1 v1.55.0
 )
@@ -107,3 +107,20 @@ const (
 	// definition in this list.
 	MaxRule
 )
+
+// String is a short description of the rule.
+func (r Rule) String() string {
+	switch r {
+	case NoRule:
+		return "no rule"
+	case FailureToAct:
+		return "failure to act"
+	case CancellationRatio:
+		return "cancellation ratio"
+	case LowFees:
+		return "low fees"
+	case PreimageReveal:
+		return "preimage reveal"
+	}
+	return fmt.Sprintf("unknown rule %d", uint8(r))
+}
@@ -83,3 +83,53 @@ func TestNewID(t *testing.T) {
 		}
 	}
 }
+
+func TestPenaltyPolicy(t *testing.T) {
+	if err := DefaultPenaltyPolicy().Validate(); err != nil {
+		t.Fatalf("invalid default policy: %v", err)
+	}
+	tests := []struct {
+		name    string
+		policy  *PenaltyPolicy
+		wantErr bool
+	}{{
+		name: "ok",
+		policy: &PenaltyPolicy{
+			BanScore: 10,
+			Penalties: []*Penalty{
+				{Rule: LowFees, Score: 2},
+				{Rule: PreimageReveal, LotLimit: 1, LimitHours: 12},
+			},
+		},
+	}, {
+		name:    "unknown rule",
+		policy:  &PenaltyPolicy{Penalties: []*Penalty{{Rule: MaxRule, Ban: true}}},
+		wantErr: true,
+	}, {
+		name:    "duplicate rule",
+		policy:  &PenaltyPolicy{Penalties: []*Penalty{{Rule: LowFees}, {Rule: LowFees, Ban: true}}},
+		wantErr: true,
+	}, {
+		name:    "score without ban score",
+		policy:  &PenaltyPolicy{Penalties: []*Penalty{{Rule: LowFees, Score: 2}}},
+		wantErr: true,
+	}, {
+		name:    "lot limit without duration",
+		policy:  &PenaltyPolicy{Penalties: []*Penalty{{Rule: LowFees, LotLimit: 1}}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		err := tt.policy.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr = %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	policy := &PenaltyPolicy{Penalties: []*Penalty{{Rule: LowFees, LotLimit: 1, LimitHours: 1}}}
+	if pen := policy.Penalty(LowFees); pen.Ban || pen.LotLimit != 1 {
+		t.Fatalf("wrong penalty for a rule in the policy: %+v", pen)
+	}
+	if pen := policy.Penalty(FailureToAct); !pen.Ban {
+		t.Fatalf("rule not in the policy does not ban")
+	}
+}
@@ -0,0 +1,91 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package account
+
+import "fmt"
+
+// Penalty is the consequence of breaking a rule. A penalty with no ban, score,
+// or lot limit only notifies the account of the violation.
+type Penalty struct {
+	Rule Rule `json:"rule"`
+	// Ban closes the account.
+	Ban bool `json:"ban"`
+	// Score is added to the account's violation score. The account is closed
+	// when its score reaches the policy's BanScore.
+	Score uint32 `json:"score"`
+	// LotLimit temporarily limits the quantity of the account's trade orders
+	// to this many lots, for LimitHours. Zero means no limit.
+	LotLimit   uint64 `json:"lotlimit"`
+	LimitHours uint32 `json:"limithours"`
+}
+
+// PenaltyPolicy is the mapping of broken rules to their penalties. Rules that
+// are not in the policy close the account.
+type PenaltyPolicy struct {
+	// BanScore is the violation score at which an account is closed. Zero
+	// means accounts are only closed by penalties that ban.
+	BanScore  uint32     `json:"banscore"`
+	Penalties []*Penalty `json:"penalties"`
+}
+
+// DefaultPenaltyPolicy is the policy of closing the account for any broken
+// rule.
+func DefaultPenaltyPolicy() *PenaltyPolicy {
+	policy := new(PenaltyPolicy)
+	for rule := NoRule + 1; rule < MaxRule; rule++ {
+		policy.Penalties = append(policy.Penalties, &Penalty{
+			Rule: rule,
+			Ban:  true,
+		})
+	}
+	return policy
+}
+
+// Validate checks that the policy's rules are known and unique, and that its
+// penalties are consistent.
+func (p *PenaltyPolicy) Validate() error {
+	seen := make(map[Rule]bool, len(p.Penalties))
+	for _, pen := range p.Penalties {
+		if pen.Rule == NoRule || pen.Rule >= MaxRule {
+			return fmt.Errorf("unknown rule %d", uint8(pen.Rule))
+		}
+		if seen[pen.Rule] {
+			return fmt.Errorf("duplicate penalty for rule %v", pen.Rule)
+		}
+		seen[pen.Rule] = true
+		if pen.Score > 0 && p.BanScore == 0 {
+			return fmt.Errorf("penalty for rule %v has a score, but the policy has no ban score", pen.Rule)
+		}
+		if (pen.LotLimit > 0) != (pen.LimitHours > 0) {
+			return fmt.Errorf("penalty for rule %v must set both the lot limit and its duration", pen.Rule)
+		}
+	}
+	return nil
+}
+
+// Penalty is the penalty for breaking the rule.
+func (p *PenaltyPolicy) Penalty(rule Rule) *Penalty {
+	for _, pen := range p.Penalties {
+		if pen.Rule == rule {
+			return pen
+		}
+	}
+	return &Penalty{
+		Rule: rule,
+		Ban:  true,
+	}
+}
+
+// Copy is a deep copy of the policy.
+func (p *PenaltyPolicy) Copy() *PenaltyPolicy {
+	policy := &PenaltyPolicy{
+		BanScore:  p.BanScore,
+		Penalties: make([]*Penalty, 0, len(p.Penalties)),
+	}
+	for _, pen := range p.Penalties {
+		penCopy := *pen
+		policy.Penalties = append(policy.Penalties, &penCopy)
+	}
+	return policy
+}
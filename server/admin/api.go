@@ -7,18 +7,41 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/calc"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/fault"
+	"decred.org/dcrdex/server/market"
 	"github.com/go-chi/chi"
 )
 
 const (
 	pongStr = "pong"
+
+	// maxNotesBody is the largest accepted account notes request body.
+	maxNotesBody = 1 << 16
+	// maxBulkAccounts is the most accounts in a bulk ban or forgive request.
+	maxBulkAccounts = 1000
+	// maxBulkBody is the largest accepted bulk ban or forgive request body.
+	maxBulkBody = 1 << 17
+	// maxAPIKeyBody is the largest accepted API key request body.
+	maxAPIKeyBody = 1 << 12
+	// maxPolicyBody is the largest accepted penalty policy request body.
+	maxPolicyBody = 1 << 14
+	// defaultTimelineEvents is the number of account timeline events returned
+	// when the request does not specify it.
+	defaultTimelineEvents = 100
+	// maxTimelineEvents is the most account timeline events in a response.
+	maxTimelineEvents = 1000
 )
 
 // writeJSON marshals the provided interface and writes the bytes to the
@@ -44,6 +67,66 @@ func (_ *Server) apiPing(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, pongStr)
 }
 
+// apiStandby is the handler for the '/standby' API request.
+func (s *Server) apiStandby(w http.ResponseWriter, _ *http.Request) {
+	if s.standby == nil {
+		// Not a standby server.
+		writeJSON(w, new(StandbyStatus))
+		return
+	}
+	writeJSON(w, s.standby.StandbyStatus())
+}
+
+// apiPromote is the handler for the POST '/promote' API request. A standby
+// server starts the DEX with the latest swap state from the primary. Promotion
+// fails while the primary holds the database lease.
+func (s *Server) apiPromote(w http.ResponseWriter, _ *http.Request) {
+	if s.standby == nil {
+		http.Error(w, "not a standby server", http.StatusBadRequest)
+		return
+	}
+	s.coreMtx.Lock()
+	defer s.coreMtx.Unlock()
+	if s.core != nil {
+		http.Error(w, "already promoted", http.StatusBadRequest)
+		return
+	}
+	core, err := s.standby.Promote()
+	if err != nil {
+		log.Errorf("Failed to promote standby server: %v", err)
+		http.Error(w, fmt.Sprintf("failed to promote: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.core = core
+	writeJSON(w, s.standby.StandbyStatus())
+}
+
+// apiShutdown is the handler for the '/shutdown' API request. The DEX is
+// drained, and shut down once all active swaps are done. Repeated requests
+// report the progress of the drain.
+func (s *Server) apiShutdown(w http.ResponseWriter, r *http.Request) {
+	log.Warnf("Graceful shutdown requested by %s", r.RemoteAddr)
+	drained := s.core.Drain()
+	s.shutdownOnce.Do(func() {
+		go func() {
+			<-drained
+			if s.shutdown != nil {
+				log.Infof("DEX drained. Shutting down.")
+				s.shutdown()
+			}
+		}()
+	})
+	res := &ShutdownResult{
+		Draining: true,
+	}
+	select {
+	case <-drained:
+		res.Drained = true
+	default:
+	}
+	writeJSON(w, res)
+}
+
 // apiConfig is the handler for the '/config' API request.
 func (s *Server) apiConfig(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, s.core.ConfigMsg())
@@ -60,6 +143,9 @@ func (s *Server) apiMarkets(w http.ResponseWriter, r *http.Request) {
 			ActiveEpoch:   status.ActiveEpoch,
 			StartEpoch:    status.StartEpoch,
 			SuspendEpoch:  status.SuspendEpoch,
+			EpochBacklog:  status.EpochBacklog,
+			ShedOrders:    status.ShedOrders,
+			MinOrderQty:   status.MinOrderQty,
 		}
 		if status.SuspendEpoch != 0 {
 			persist := status.PersistBook
@@ -87,6 +173,9 @@ func (s *Server) apiMarketInfo(w http.ResponseWriter, r *http.Request) {
 		ActiveEpoch:   status.ActiveEpoch,
 		StartEpoch:    status.ActiveEpoch,
 		SuspendEpoch:  status.SuspendEpoch,
+		EpochBacklog:  status.EpochBacklog,
+		ShedOrders:    status.ShedOrders,
+		MinOrderQty:   status.MinOrderQty,
 	}
 	if status.SuspendEpoch != 0 {
 		persist := status.PersistBook
@@ -95,6 +184,138 @@ func (s *Server) apiMarketInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, mktStatus)
 }
 
+// durationMs converts a time.Duration to milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// apiTimings is the handler for the '/market/{marketName}/timings' API
+// request.
+func (s *Server) apiTimings(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	status := s.core.MarketStatus(mkt)
+	timings := s.core.EpochTimings(mkt)
+	if status == nil || timings == nil {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+
+	res := &MarketTimings{
+		Market:        mkt,
+		EpochDuration: status.EpochDuration,
+		Stages:        make([]*StageTiming, 0, market.NumEpochStages),
+		Recent:        make([]*EpochTiming, 0, len(timings.Recent)),
+	}
+	for i, st := range timings.Stages {
+		stage := &StageTiming{
+			Stage: market.EpochStages[i],
+			Count: st.Count,
+			Max:   durationMs(st.Max),
+		}
+		if st.Count > 0 {
+			stage.Avg = durationMs(st.Total) / float64(st.Count)
+		}
+		res.Stages = append(res.Stages, stage)
+	}
+	for _, et := range timings.Recent {
+		epoch := &EpochTiming{
+			Epoch:  et.Epoch,
+			Orders: et.Orders,
+			Stages: make(map[string]float64, market.NumEpochStages),
+			Total:  durationMs(et.Total()),
+		}
+		for i, d := range et.Stages {
+			epoch.Stages[market.EpochStages[i]] = durationMs(d)
+		}
+		res.Recent = append(res.Recent, epoch)
+	}
+	writeJSON(w, res)
+}
+
+// apiMetrics is the handler for the '/metrics' API request. The epoch
+// processing timings of every market are written in the Prometheus text
+// exposition format.
+func (s *Server) apiMetrics(w http.ResponseWriter, _ *http.Request) {
+	allTimings := s.core.AllEpochTimings()
+	mkts := make([]string, 0, len(allTimings))
+	for mkt := range allTimings {
+		mkts = append(mkts, mkt)
+	}
+	sort.Strings(mkts)
+
+	var b strings.Builder
+	b.WriteString("# HELP dcrdex_epoch_stage_seconds Time spent in each stage of epoch processing.\n")
+	b.WriteString("# TYPE dcrdex_epoch_stage_seconds summary\n")
+	for _, mkt := range mkts {
+		for i, st := range allTimings[mkt].Stages {
+			labels := fmt.Sprintf(`{market=%q,stage=%q}`, mkt, market.EpochStages[i])
+			fmt.Fprintf(&b, "dcrdex_epoch_stage_seconds_sum%s %g\n", labels, st.Total.Seconds())
+			fmt.Fprintf(&b, "dcrdex_epoch_stage_seconds_count%s %d\n", labels, st.Count)
+		}
+	}
+	b.WriteString("# HELP dcrdex_epoch_stage_max_seconds Longest time spent in each stage of epoch processing.\n")
+	b.WriteString("# TYPE dcrdex_epoch_stage_max_seconds gauge\n")
+	for _, mkt := range mkts {
+		for i, st := range allTimings[mkt].Stages {
+			fmt.Fprintf(&b, "dcrdex_epoch_stage_max_seconds{market=%q,stage=%q} %g\n",
+				mkt, market.EpochStages[i], st.Max.Seconds())
+		}
+	}
+	b.WriteString("# HELP dcrdex_epoch_last_seconds Total processing time of the most recent epoch.\n")
+	b.WriteString("# TYPE dcrdex_epoch_last_seconds gauge\n")
+	for _, mkt := range mkts {
+		recent := allTimings[mkt].Recent
+		if len(recent) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "dcrdex_epoch_last_seconds{market=%q} %g\n", mkt, recent[len(recent)-1].Total().Seconds())
+	}
+	b.WriteString("# HELP dcrdex_comms_rejected_requests_total Client requests rejected by payload validation.\n")
+	b.WriteString("# TYPE dcrdex_comms_rejected_requests_total counter\n")
+	for _, rc := range s.core.MessageRejects() {
+		fmt.Fprintf(&b, "dcrdex_comms_rejected_requests_total{route=%q,reason=%q} %d\n", rc.Route, rc.Reason, rc.Count)
+	}
+	b.WriteString("# HELP dcrdex_connected_clients Authenticated clients currently connected.\n")
+	b.WriteString("# TYPE dcrdex_connected_clients gauge\n")
+	fmt.Fprintf(&b, "dcrdex_connected_clients %d\n", s.core.ConnectedClients())
+	b.WriteString("# HELP dcrdex_swap_failures_total Matches revoked because a party failed to act.\n")
+	b.WriteString("# TYPE dcrdex_swap_failures_total counter\n")
+	for _, f := range s.core.SwapFailures() {
+		mkt, err := dex.MarketName(f.Base, f.Quote)
+		if err != nil {
+			mkt = fmt.Sprintf("%d_%d", f.Base, f.Quote)
+		}
+		fmt.Fprintf(&b, "dcrdex_swap_failures_total{market=%q,status=%q} %d\n", mkt, f.Status, f.Count)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		log.Errorf("Error writing metrics: %v", err)
+	}
+}
+
+// apiActivity is the handler for the '/activity' API request.
+func (s *Server) apiActivity(w http.ResponseWriter, _ *http.Request) {
+	res := &Activity{
+		ConnectedClients: s.core.ConnectedClients(),
+		ActiveMatches:    s.core.ActiveMatches(),
+		SwapFailures:     make([]*SwapFailureCount, 0),
+	}
+	for _, f := range s.core.SwapFailures() {
+		mkt, err := dex.MarketName(f.Base, f.Quote)
+		if err != nil {
+			mkt = fmt.Sprintf("%d_%d", f.Base, f.Quote)
+		}
+		res.SwapFailures = append(res.SwapFailures, &SwapFailureCount{
+			Market: mkt,
+			Status: f.Status.String(),
+			Count:  f.Count,
+		})
+	}
+	writeJSON(w, res)
+}
+
 // hander for route '/market/{marketName}/suspend?t=EPOCH-MS&persist=BOOL'
 func (s *Server) apiSuspend(w http.ResponseWriter, r *http.Request) {
 	// Ensure the market exists and is running.
@@ -153,6 +374,237 @@ func (s *Server) apiSuspend(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// apiResume is the handler for the '/market/{marketName}/resume?t=EPOCH-MS' API
+// request. The market resumes with the first epoch that starts at or after the
+// time in the "t" query, or with the next epoch if not specified.
+func (s *Server) apiResume(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	found, running := s.core.MarketRunning(mkt)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+	if running {
+		http.Error(w, fmt.Sprintf("market %q is running", mkt), http.StatusBadRequest)
+		return
+	}
+
+	var resTime time.Time
+	if tResumeStr := r.URL.Query().Get("t"); tResumeStr != "" {
+		resTimeMs, err := strconv.ParseInt(tResumeStr, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid resume time %q: %v", tResumeStr, err), http.StatusBadRequest)
+			return
+		}
+		resTime = encode.UnixTimeMilli(resTimeMs)
+	}
+
+	resEpoch, err := s.core.ResumeMarket(mkt, resTime)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to resume market %q: %v", mkt, err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, &ResumeResult{
+		Market:     mkt,
+		StartEpoch: resEpoch.Idx,
+		StartTime:  APITime{resEpoch.Start},
+	})
+}
+
+// apiForceEpoch is the handler for the POST '/market/{marketName}/forceepoch'
+// API request. It ends preimage collection for a closed epoch that is blocking
+// the market's epoch processing. The request is a dry run that only reports
+// the stalled epoch unless the "dryrun" query is false, in which case the
+// "epoch" query must give the index of the stalled epoch reported by the dry
+// run. With the "skip" query true, none of the epoch's orders are matched.
+func (s *Server) apiForceEpoch(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	found, running := s.core.MarketRunning(mkt)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+	if !running {
+		http.Error(w, fmt.Sprintf("market %q not running", mkt), http.StatusBadRequest)
+		return
+	}
+
+	parseBool := func(key string, def bool) (bool, bool) {
+		str := r.URL.Query().Get(key)
+		if str == "" {
+			return def, true
+		}
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s boolean %q: %v", key, str, err), http.StatusBadRequest)
+			return false, false
+		}
+		return b, true
+	}
+	dryRun, ok := parseBool("dryrun", true)
+	if !ok {
+		return
+	}
+	skip, ok := parseBool("skip", false)
+	if !ok {
+		return
+	}
+	var epochIdx int64
+	if !dryRun {
+		epochStr := r.URL.Query().Get("epoch")
+		if epochStr == "" {
+			http.Error(w, "the epoch reported by a dry run must be specified", http.StatusBadRequest)
+			return
+		}
+		var err error
+		epochIdx, err = strconv.ParseInt(epochStr, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid epoch %q: %v", epochStr, err), http.StatusBadRequest)
+			return
+		}
+		log.Warnf("Force epoch %d on market %s (skip = %v) requested by %s", epochIdx, mkt, skip, r.RemoteAddr)
+	}
+
+	fe, err := s.core.ForceEpoch(mkt, epochIdx, skip, dryRun)
+	if err != nil {
+		if !dryRun {
+			log.Warnf("Force epoch %d on market %s failed: %v", epochIdx, mkt, err)
+		}
+		http.Error(w, fmt.Sprintf("failed to force epoch on market %q: %v", mkt, err), http.StatusBadRequest)
+		return
+	}
+	res := &ForceEpochResult{
+		Market:  mkt,
+		Epoch:   fe.Epoch,
+		Orders:  fe.Orders,
+		DryRun:  fe.DryRun,
+		Skipped: fe.Skipped,
+	}
+	for _, oid := range fe.Revoked {
+		res.Revoked = append(res.Revoked, oid.String())
+	}
+	if !dryRun {
+		log.Warnf("Forced epoch %d on market %s. %d of %d orders revoked: %v",
+			fe.Epoch, mkt, len(res.Revoked), fe.Orders, res.Revoked)
+	}
+	writeJSON(w, res)
+}
+
+// apiPurgeStale is the handler for the '/market/{marketName}/purgestale' API
+// request. Booked orders older than the required age are revoked.
+func (s *Server) apiPurgeStale(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	found, running := s.core.MarketRunning(mkt)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+	if !running {
+		http.Error(w, fmt.Sprintf("market %q not running", mkt), http.StatusBadRequest)
+		return
+	}
+	ageStr := r.URL.Query().Get("age")
+	if ageStr == "" {
+		http.Error(w, "the minimum age of orders to purge must be specified", http.StatusBadRequest)
+		return
+	}
+	maxAge, err := time.ParseDuration(ageStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid age %q: %v", ageStr, err), http.StatusBadRequest)
+		return
+	}
+	if maxAge <= 0 {
+		http.Error(w, fmt.Sprintf("age must be positive, got %v", maxAge), http.StatusBadRequest)
+		return
+	}
+	log.Infof("Purge of orders older than %v on market %s requested by %s", maxAge, mkt, r.RemoteAddr)
+
+	revoked, err := s.core.PurgeStaleOrders(mkt, maxAge)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to purge stale orders on market %q: %v", mkt, err), http.StatusBadRequest)
+		return
+	}
+	res := &PurgeStaleResult{
+		Market:  mkt,
+		MaxAge:  maxAge.String(),
+		Revoked: make([]string, 0, len(revoked)),
+	}
+	for _, oid := range revoked {
+		res.Revoked = append(res.Revoked, oid.String())
+	}
+	writeJSON(w, res)
+}
+
+// apiVerifyBook is the handler for the '/market/{marketName}/verify' API
+// request. The market's book is checked against the stored booked orders and
+// the funding coins' status. Discrepancies are reported but not repaired.
+func (s *Server) apiVerifyBook(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	if found, _ := s.core.MarketRunning(mkt); !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+	log.Infof("Book verification for market %s requested by %s", mkt, r.RemoteAddr)
+
+	res, err := s.core.VerifyBook(mkt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to verify book for market %q: %v", mkt, err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// apiExportMarket is the handler for the '/market/{marketName}/export' API
+// request. The response is an image of the market's orders and settling
+// matches that may be imported by another server on startup with the
+// --importmarket option.
+func (s *Server) apiExportMarket(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	found, running := s.core.MarketRunning(mkt)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+	if running {
+		log.Warnf("Exporting running market %s. Suspend the market for a consistent export.", mkt)
+	}
+	log.Infof("Export of market %s requested by %s", mkt, r.RemoteAddr)
+
+	exp, err := s.core.ExportMarket(mkt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to export market %q: %v", mkt, err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, exp)
+}
+
+// apiDelist is the handler for the DELETE '/market/{marketName}' API request.
+// The market is suspended as soon as possible, and removed when its booked
+// orders are revoked and its active swaps settle. The delisting is not
+// persisted, so the market must also be removed from the market config file,
+// or it is listed again when the server restarts.
+func (s *Server) apiDelist(w http.ResponseWriter, r *http.Request) {
+	mkt := strings.ToLower(chi.URLParam(r, marketNameKey))
+	found, _ := s.core.MarketRunning(mkt)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown market %q", mkt), http.StatusBadRequest)
+		return
+	}
+
+	suspEpoch, err := s.core.DelistMarket(mkt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to delist market %q: %v", mkt, err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, &DelistResult{
+		Market:      mkt,
+		FinalEpoch:  suspEpoch.Idx,
+		SuspendTime: APITime{suspEpoch.End},
+	})
+}
+
 // apiAccounts is the handler for the '/accounts' API request.
 func (s *Server) apiAccounts(w http.ResponseWriter, _ *http.Request) {
 	accts, err := s.core.Accounts()
@@ -185,7 +637,61 @@ func (s *Server) apiAccountInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, acctInfo)
 }
 
-// apiBan is the handler for the '/account/{accountID}/ban?rule=RULE' API request.
+// apiSetAccountNotes is the handler for the 'PATCH /account/{accountID}' API
+// request. The body is an AccountNotesUpdate. Tags are trimmed, and empty and
+// duplicate tags are dropped. The updated account is returned.
+func (s *Server) apiSetAccountNotes(w http.ResponseWriter, r *http.Request) {
+	acctIDStr := chi.URLParam(r, accountIDKey)
+	acctIDSlice, err := hex.DecodeString(acctIDStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	var update AccountNotesUpdate
+	if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNotesBody)).Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode notes: %v", err), http.StatusBadRequest)
+		return
+	}
+	var acctID account.AccountID
+	copy(acctID[:], acctIDSlice)
+	acctInfo, err := s.core.AccountInfo(acctID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve account: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if acctInfo == nil {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return
+	}
+	if update.Notes != nil {
+		acctInfo.Notes = *update.Notes
+	}
+	if update.Tags != nil {
+		tags := make([]string, 0, len(*update.Tags))
+		seen := make(map[string]bool, len(*update.Tags))
+		for _, tag := range *update.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		acctInfo.Tags = tags
+	}
+	if err = s.core.SetAccountNotes(acctID, acctInfo.Notes, acctInfo.Tags); err != nil {
+		http.Error(w, fmt.Sprintf("failed to set account notes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, acctInfo)
+}
+
+// apiBan is the handler for the '/account/{accountID}/ban?rule=RULE&reason=REASON'
+// API request. The optional reason is sent to the client with the penalty.
 func (s *Server) apiBan(w http.ResponseWriter, r *http.Request) {
 	acctIDStr := chi.URLParam(r, accountIDKey)
 	acctIDSlice, err := hex.DecodeString(acctIDStr)
@@ -211,9 +717,13 @@ func (s *Server) apiBan(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad rule: not known or not punishable", http.StatusBadRequest)
 		return
 	}
+	details := "banned by the operator"
+	if reason := r.URL.Query().Get(reasonToken); reason != "" {
+		details += ": " + reason
+	}
 	var acctID account.AccountID
 	copy(acctID[:], acctIDSlice)
-	if err := s.core.Penalize(acctID, account.Rule(ruleInt)); err != nil {
+	if err := s.core.Penalize(acctID, account.Rule(ruleInt), details); err != nil {
 		http.Error(w, fmt.Sprintf("failed to ban account: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -224,3 +734,721 @@ func (s *Server) apiBan(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, res)
 }
+
+// bulkAccounts decodes the body of a bulk ban or forgive request, and looks up
+// each of the accounts. The request is rejected, and no account is changed,
+// unless every account ID is valid and known. Duplicate IDs are ignored. An
+// error response is written if ok is false.
+func (s *Server) bulkAccounts(w http.ResponseWriter, r *http.Request) (req *BulkPenaltyRequest, accts []*db.Account, ok bool) {
+	req = new(BulkPenaltyRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkBody)).Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode request: %v", err), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if len(req.Accounts) == 0 {
+		http.Error(w, "no accounts specified", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if len(req.Accounts) > maxBulkAccounts {
+		http.Error(w, fmt.Sprintf("too many accounts. max is %d", maxBulkAccounts), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	seen := make(map[account.AccountID]bool, len(req.Accounts))
+	var unknown []string
+	for _, acctIDStr := range req.Accounts {
+		acctIDSlice, err := hex.DecodeString(acctIDStr)
+		if err != nil || len(acctIDSlice) != account.HashSize {
+			http.Error(w, fmt.Sprintf("invalid account id %q", acctIDStr), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		var acctID account.AccountID
+		copy(acctID[:], acctIDSlice)
+		if seen[acctID] {
+			continue
+		}
+		seen[acctID] = true
+		acct, err := s.core.AccountInfo(acctID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to retrieve account %v: %v", acctID, err), http.StatusInternalServerError)
+			return nil, nil, false
+		}
+		if acct == nil {
+			unknown = append(unknown, acctIDStr)
+			continue
+		}
+		accts = append(accts, acct)
+	}
+	if len(unknown) > 0 {
+		http.Error(w, fmt.Sprintf("unknown accounts: %s", strings.Join(unknown, ", ")), http.StatusNotFound)
+		return nil, nil, false
+	}
+	return req, accts, true
+}
+
+// apiBulkBan is the handler for the '/accounts/ban' API request. Every account
+// in the request is banned for the rule, and sent the reason. Accounts that
+// are already closed are unchanged.
+func (s *Server) apiBulkBan(w http.ResponseWriter, r *http.Request) {
+	req, accts, ok := s.bulkAccounts(w, r)
+	if !ok {
+		return
+	}
+	rule := account.Rule(req.Rule)
+	if rule == account.NoRule || rule >= account.MaxRule {
+		http.Error(w, "bad rule: not known or not punishable", http.StatusBadRequest)
+		return
+	}
+	details := "banned by the operator"
+	if req.Reason != "" {
+		details += ": " + req.Reason
+	}
+	results := make([]*BulkPenaltyResult, 0, len(accts))
+	for _, acct := range accts {
+		res := &BulkPenaltyResult{AccountID: acct.AccountID.String()}
+		results = append(results, res)
+		if acct.BrokenRule != account.NoRule {
+			res.Status = BulkUnchanged
+			continue
+		}
+		if err := s.core.Penalize(acct.AccountID, rule, details); err != nil {
+			res.Status, res.Error = BulkFailed, err.Error()
+			continue
+		}
+		res.Status = BulkBanned
+	}
+	log.Infof("Bulk ban of %d accounts for rule %v", len(accts), rule)
+	writeJSON(w, &BulkPenaltyResults{
+		Time:    APITime{time.Now()},
+		Results: results,
+	})
+}
+
+// apiBulkForgive is the handler for the '/accounts/forgive' API request. Every
+// closed account in the request is reopened, and sent the reason. Accounts
+// that are not closed are unchanged.
+func (s *Server) apiBulkForgive(w http.ResponseWriter, r *http.Request) {
+	req, accts, ok := s.bulkAccounts(w, r)
+	if !ok {
+		return
+	}
+	results := make([]*BulkPenaltyResult, 0, len(accts))
+	for _, acct := range accts {
+		res := &BulkPenaltyResult{AccountID: acct.AccountID.String()}
+		results = append(results, res)
+		if acct.BrokenRule == account.NoRule {
+			res.Status = BulkUnchanged
+			continue
+		}
+		if err := s.core.Forgive(acct.AccountID, req.Reason); err != nil {
+			res.Status, res.Error = BulkFailed, err.Error()
+			continue
+		}
+		res.Status = BulkForgiven
+	}
+	log.Infof("Bulk forgiveness of %d accounts", len(accts))
+	writeJSON(w, &BulkPenaltyResults{
+		Time:    APITime{time.Now()},
+		Results: results,
+	})
+}
+
+// apiAppeals is the handler for the '/appeals' API request.
+func (s *Server) apiAppeals(w http.ResponseWriter, _ *http.Request) {
+	appeals, err := s.core.Appeals()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve appeals: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, appeals)
+}
+
+// apiClientVersionStats is the handler for the '/clientversions?since=MS' API
+// request. The client software versions seen since the time, or ever by
+// default, are listed with the number of accounts and connections, and the
+// number of clients currently connected with each version.
+func (s *Server) apiClientVersionStats(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid since time %q: %v", sinceStr, err), http.StatusBadRequest)
+			return
+		}
+	}
+	stats, err := s.core.ClientVersionStats(encode.UnixTimeMilli(since))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve client versions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		stats = []*db.ClientVersionStats{}
+	}
+	writeJSON(w, stats)
+}
+
+// apiClientVersions is the handler for the '/account/{accountID}/clientversions'
+// API request. The client software versions that the account has connected
+// with are listed, most recently seen first.
+func (s *Server) apiClientVersions(w http.ResponseWriter, r *http.Request) {
+	acctIDStr := chi.URLParam(r, accountIDKey)
+	acctIDSlice, err := hex.DecodeString(acctIDStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	var acctID account.AccountID
+	copy(acctID[:], acctIDSlice)
+	versions, err := s.core.ClientVersions(acctID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve client versions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []*db.ClientVersion{}
+	}
+	writeJSON(w, versions)
+}
+
+// apiAccountTimeline is the handler for the
+// '/account/{accountID}/timeline?since=MS&until=MS&offset=N&n=N' API request.
+// The account's connections, orders, cancels, matches, penalties, and trade
+// fees are listed in chronological order. All query parameters are optional.
+func (s *Server) apiAccountTimeline(w http.ResponseWriter, r *http.Request) {
+	acctIDStr := chi.URLParam(r, accountIDKey)
+	acctIDSlice, err := hex.DecodeString(acctIDStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	var acctID account.AccountID
+	copy(acctID[:], acctIDSlice)
+
+	query := r.URL.Query()
+	parseInt := func(key string, def int64) (int64, error) {
+		str := query.Get(key)
+		if str == "" {
+			return def, nil
+		}
+		v, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid %s %q", key, str)
+		}
+		return v, nil
+	}
+	var since, until, offset, n int64
+	for _, p := range []struct {
+		key string
+		v   *int64
+		def int64
+	}{
+		{"since", &since, 0},
+		{"until", &until, 0},
+		{"offset", &offset, 0},
+		{"n", &n, defaultTimelineEvents},
+	} {
+		if *p.v, err = parseInt(p.key, p.def); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if n == 0 || n > maxTimelineEvents {
+		http.Error(w, fmt.Sprintf("n must be from 1 to %d", maxTimelineEvents), http.StatusBadRequest)
+		return
+	}
+	var untilTime time.Time
+	if until != 0 {
+		if until <= since {
+			http.Error(w, "until time must be after since time", http.StatusBadRequest)
+			return
+		}
+		untilTime = encode.UnixTimeMilli(until)
+	}
+	timeline, err := s.core.AccountTimeline(acctID, encode.UnixTimeMilli(since), untilTime, int(offset), int(n))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to assemble account timeline: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, timeline)
+}
+
+// apiAPIKeys is the handler for the '/apikeys' API request. All issued HTTP
+// data API keys are listed with their usage, including revoked keys.
+func (s *Server) apiAPIKeys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.core.APIKeys())
+}
+
+// apiIssueAPIKey is the handler for the 'POST /apikeys' API request. The body
+// is an APIKeyRequest. The issued key is returned.
+func (s *Server) apiIssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIKeyBody)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode API key request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.RateLimit == 0 {
+		http.Error(w, "rate limit not specified", http.StatusBadRequest)
+		return
+	}
+	key, err := s.core.IssueAPIKey(strings.TrimSpace(req.Label), req.Markets, req.RateLimit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to issue API key: %v", err), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, key)
+}
+
+// apiRevokeAPIKey is the handler for the 'DELETE /apikey/{key}' API request.
+func (s *Server) apiRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, apiKeyKey)
+	if err := s.core.RevokeAPIKey(key); err != nil {
+		http.Error(w, fmt.Sprintf("failed to revoke API key: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPoW is the handler for the '/pow' API request. The proof-of-work
+// difficulty required of 'register' and 'connect' requests is returned.
+func (s *Server) apiPoW(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, &PoWResult{Difficulty: s.core.PoWDifficulty()})
+}
+
+// apiSetPoW is the handler for the 'POST /pow?bits=BITS' API request. The
+// proof-of-work difficulty required of 'register' and 'connect' requests is
+// set to BITS leading zero bits, e.g. during a flood of connection attempts.
+// Zero disables the requirement.
+func (s *Server) apiSetPoW(w http.ResponseWriter, r *http.Request) {
+	bitsStr := r.URL.Query().Get("bits")
+	bits, err := strconv.ParseUint(bitsStr, 10, 8)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid proof of work bits %q: %v", bitsStr, err), http.StatusBadRequest)
+		return
+	}
+	if err = s.core.SetPoWDifficulty(uint8(bits)); err != nil {
+		http.Error(w, fmt.Sprintf("failed to set proof of work difficulty: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Infof("Proof of work difficulty set to %d bits by %s", bits, r.RemoteAddr)
+	writeJSON(w, &PoWResult{Difficulty: s.core.PoWDifficulty()})
+}
+
+// apiPenaltyPolicy is the handler for the '/policy' API request. The mapping of
+// broken rules to their penalties is returned.
+func (s *Server) apiPenaltyPolicy(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.core.PenaltyPolicy())
+}
+
+// apiSetPenaltyPolicy is the handler for the 'PUT /policy' API request. The
+// body is the new penalty policy, which replaces the mapping of broken rules to
+// their penalties for subsequent violations.
+func (s *Server) apiSetPenaltyPolicy(w http.ResponseWriter, r *http.Request) {
+	policy := new(account.PenaltyPolicy)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPolicyBody)).Decode(policy); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode penalty policy: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.core.SetPenaltyPolicy(policy); err != nil {
+		http.Error(w, fmt.Sprintf("failed to set penalty policy: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Infof("Penalty policy set by %s", r.RemoteAddr)
+	writeJSON(w, s.core.PenaltyPolicy())
+}
+
+// apiPreimageMisses is the handler for the '/account/{accountID}/preimagemisses'
+// API request. The report lists the circumstances of each of the account's
+// missed preimage requests, with totals by reason.
+func (s *Server) apiPreimageMisses(w http.ResponseWriter, r *http.Request) {
+	acctIDStr := chi.URLParam(r, accountIDKey)
+	acctIDSlice, err := hex.DecodeString(acctIDStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	var acctID account.AccountID
+	copy(acctID[:], acctIDSlice)
+	misses, err := s.core.PreimageMisses(acctID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve preimage misses: %v", err), http.StatusInternalServerError)
+		return
+	}
+	report := &PreimageMissReport{
+		AccountID: acctIDStr,
+		Misses:    len(misses),
+		Records:   make([]*PreimageMiss, 0, len(misses)),
+	}
+	var latencySum, latencyCount int64
+	for _, miss := range misses {
+		switch miss.Reason {
+		case db.PreimageTimeout:
+			report.Timeouts++
+		case db.PreimageDisconnected:
+			report.Disconnects++
+		case db.PreimageInvalid:
+			report.Invalid++
+		}
+		if miss.Connected {
+			report.ConnectedMisses++
+		}
+		if miss.Latency > 0 {
+			latencySum += miss.Latency
+			latencyCount++
+		}
+		mktName, err := dex.MarketName(miss.Base, miss.Quote)
+		if err != nil {
+			mktName = fmt.Sprintf("%d_%d", miss.Base, miss.Quote)
+		}
+		report.Records = append(report.Records, &PreimageMiss{
+			OrderID:    miss.OrderID.String(),
+			Commitment: miss.Commitment.String(),
+			Market:     mktName,
+			EpochIdx:   miss.EpochIdx,
+			EpochDur:   miss.EpochDur,
+			Reason:     miss.Reason.String(),
+			Connected:  miss.Connected,
+			LatencyMs:  miss.Latency,
+			Time:       APITime{encode.UnixTimeMilli(miss.Stamp)},
+		})
+	}
+	if latencyCount > 0 {
+		report.MeanLatencyMs = latencySum / latencyCount
+	}
+	writeJSON(w, report)
+}
+
+// apiFees is the handler for the '/fees?start=START-MS&end=END-MS' API
+// request. The trade fees accrued in the period are totaled for each account
+// and asset. The period starts at the UNIX epoch and ends now by default.
+func (s *Server) apiFees(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	invoices, err := s.core.FeeInvoices(start, end)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve fee invoices: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, invoices)
+}
+
+// apiTradeReport is the handler for the '/tradereport?start=START&end=END' API
+// request. The trades completed in the period are reported in a FIX-style
+// trade capture format. The period starts at the UNIX epoch and ends now by
+// default.
+func (s *Server) apiTradeReport(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matches, err := s.core.CompletedMatches(start, end)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve completed matches: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, newTradeReport(start, end, matches))
+}
+
+// newTradeReport creates the trade report of the completed matches made in the
+// period.
+func newTradeReport(start, end time.Time, matches []*db.CompletedMatch) *TradeReport {
+	const atomsPerCoin = 1e8
+	toCoin := func(v uint64) float64 {
+		return float64(v) / atomsPerCoin
+	}
+	reportTime := func(t time.Time) string {
+		return t.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+	reportDate := func(t time.Time) string {
+		return t.UTC().Format("2006-01-02")
+	}
+	report := &TradeReport{
+		Start:  reportTime(start),
+		End:    reportTime(end),
+		Trades: make([]*TradeCapture, 0, len(matches)),
+	}
+	for _, m := range matches {
+		baseSymbol, quoteSymbol := strings.ToUpper(dex.BipIDSymbol(m.Base)), strings.ToUpper(dex.BipIDSymbol(m.Quote))
+		gross := calc.BaseToQuote(m.Rate, m.Quantity)
+		makerSide, takerSide := TradeSideSell, TradeSideBuy
+		if m.TakerSell {
+			makerSide, takerSide = TradeSideBuy, TradeSideSell
+		}
+		made, settled := encode.UnixTimeMilli(m.Made), encode.UnixTimeMilli(m.Settled)
+		report.Trades = append(report.Trades, &TradeCapture{
+			TradeReportID: m.ID.String(),
+			Symbol:        baseSymbol + "/" + quoteSymbol,
+			SecurityType:  "CRYPTO",
+			Currency:      quoteSymbol,
+			LastQty:       toCoin(m.Quantity),
+			LastPx:        toCoin(m.Rate),
+			GrossTradeAmt: toCoin(gross),
+			QtyAtoms:      m.Quantity,
+			RateAtoms:     m.Rate,
+			GrossAtoms:    gross,
+			TradeDate:     reportDate(made),
+			TransactTime:  reportTime(made),
+			SettlDate:     reportDate(settled),
+			SettlTime:     reportTime(settled),
+			Sides: []*TradeCaptureSide{{
+				Side:    makerSide,
+				OrderID: m.Maker.String(),
+				PartyID: m.MakerAcct.String(),
+			}, {
+				Side:               takerSide,
+				OrderID:            m.Taker.String(),
+				PartyID:            m.TakerAcct.String(),
+				AggressorIndicator: true,
+			}},
+		})
+	}
+	return report
+}
+
+// parsePeriod parses the optional start and end query parameters of a request,
+// in UNIX milliseconds. The period starts at the UNIX epoch and ends now by
+// default.
+func parsePeriod(r *http.Request) (start, end time.Time, err error) {
+	parseTime := func(key string, def time.Time) (time.Time, error) {
+		tStr := r.URL.Query().Get(key)
+		if tStr == "" {
+			return def, nil
+		}
+		tMs, err := strconv.ParseInt(tStr, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s time %q: %v", key, tStr, err)
+		}
+		return encode.UnixTimeMilli(tMs), nil
+	}
+	start, err = parseTime("start", encode.UnixTimeMilli(0))
+	if err != nil {
+		return
+	}
+	end, err = parseTime("end", time.Now())
+	if err != nil {
+		return
+	}
+	if !end.After(start) {
+		err = fmt.Errorf("end time must be after start time")
+	}
+	return
+}
+
+// apiAcceptAppeal is the handler for the
+// '/account/{accountID}/appeal/accept?reason=REASON' API request. The account
+// is reopened. The reason is optional.
+func (s *Server) apiAcceptAppeal(w http.ResponseWriter, r *http.Request) {
+	s.decideAppeal(w, r, true)
+}
+
+// apiRejectAppeal is the handler for the
+// '/account/{accountID}/appeal/reject?reason=REASON' API request. A reason is
+// required, and is relayed to the client.
+func (s *Server) apiRejectAppeal(w http.ResponseWriter, r *http.Request) {
+	s.decideAppeal(w, r, false)
+}
+
+func (s *Server) decideAppeal(w http.ResponseWriter, r *http.Request, accept bool) {
+	acctIDStr := chi.URLParam(r, accountIDKey)
+	acctIDSlice, err := hex.DecodeString(acctIDStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	reason := r.URL.Query().Get(reasonToken)
+	if !accept && reason == "" {
+		http.Error(w, "reason not specified", http.StatusBadRequest)
+		return
+	}
+	var acctID account.AccountID
+	copy(acctID[:], acctIDSlice)
+	if err := s.core.DecideAppeal(acctID, accept, reason); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decide appeal: %v", err), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, &AppealResult{
+		AccountID:    acctIDStr,
+		Accepted:     accept,
+		Reason:       reason,
+		DecisionTime: APITime{time.Now()},
+	})
+}
+
+// registrationAccount parses the account ID of a '/account/{accountID}/registration'
+// API request. If the account ID is invalid, an error response is written and
+// ok is false.
+func registrationAccount(w http.ResponseWriter, r *http.Request) (acctID account.AccountID, ok bool) {
+	acctIDSlice, err := hex.DecodeString(chi.URLParam(r, accountIDKey))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode accout id: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(acctIDSlice) != account.HashSize {
+		http.Error(w, "account id has incorrect length", http.StatusBadRequest)
+		return
+	}
+	copy(acctID[:], acctIDSlice)
+	return acctID, true
+}
+
+// writeRegistration writes the RegistrationResult for the account.
+func (s *Server) writeRegistration(w http.ResponseWriter, acctID account.AccountID) {
+	approval := s.core.RegistrationApproval(acctID)
+	res := &RegistrationResult{
+		AccountID: acctID.String(),
+		Override:  approval.Override,
+	}
+	if approval.Cached != nil {
+		res.Approved = &approval.Cached.Approved
+		res.Reason = approval.Cached.Reason
+		if approval.CacheExpiry != nil {
+			res.CacheExpiry = &APITime{*approval.CacheExpiry}
+		}
+	}
+	writeJSON(w, res)
+}
+
+// apiRegistration is the handler for the '/account/{accountID}/registration'
+// API request. The admin override and cached registration hook decision for
+// the account are returned.
+func (s *Server) apiRegistration(w http.ResponseWriter, r *http.Request) {
+	acctID, ok := registrationAccount(w, r)
+	if !ok {
+		return
+	}
+	s.writeRegistration(w, acctID)
+}
+
+// apiSetRegistration is the handler for the
+// 'POST /account/{accountID}/registration?approve=BOOL' API request. The
+// account's registration is approved or denied regardless of the registration
+// hook, until the override is cleared or the server restarts.
+func (s *Server) apiSetRegistration(w http.ResponseWriter, r *http.Request) {
+	acctID, ok := registrationAccount(w, r)
+	if !ok {
+		return
+	}
+	approveStr := r.URL.Query().Get(approveToken)
+	approve, err := strconv.ParseBool(approveStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid approve value %q", approveStr), http.StatusBadRequest)
+		return
+	}
+	s.core.SetRegistrationOverride(acctID, approve)
+	log.Infof("Registration override of account %v set to approve=%t by %s", acctID, approve, r.RemoteAddr)
+	s.writeRegistration(w, acctID)
+}
+
+// apiClearRegistration is the handler for the
+// 'DELETE /account/{accountID}/registration' API request. The account's admin
+// override and cached registration hook decision are removed, so that the
+// hook decides the account's next registration.
+func (s *Server) apiClearRegistration(w http.ResponseWriter, r *http.Request) {
+	acctID, ok := registrationAccount(w, r)
+	if !ok {
+		return
+	}
+	s.core.ClearRegistrationOverride(acctID)
+	log.Infof("Registration override of account %v cleared by %s", acctID, r.RemoteAddr)
+	s.writeRegistration(w, acctID)
+}
+
+// apiFaults is the handler for the '/faults' API request. It lists the armed
+// fault injection points.
+func (s *Server) apiFaults(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, &FaultsResult{
+		Enabled: fault.Enabled,
+		Armed:   fault.Armed(),
+	})
+}
+
+// apiFault is the handler for the
+// '/fault/{point}?count=COUNT&delay=DURATION&clear=BOOL' API request. The
+// fault is armed unless clear is true. Faults are only available when dcrdex is
+// built with the fault build tag.
+func (s *Server) apiFault(w http.ResponseWriter, r *http.Request) {
+	point := fault.Point(strings.ToLower(chi.URLParam(r, faultPointKey)))
+	if !fault.ValidPoint(point) {
+		http.Error(w, fmt.Sprintf("unknown fault point %q", point), http.StatusBadRequest)
+		return
+	}
+
+	if clearStr := r.URL.Query().Get("clear"); clearStr != "" {
+		clear, err := strconv.ParseBool(clearStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid clear boolean %q: %v", clearStr, err), http.StatusBadRequest)
+			return
+		}
+		if clear {
+			fault.Clear(point)
+			writeJSON(w, &FaultsResult{
+				Enabled: fault.Enabled,
+				Armed:   fault.Armed(),
+			})
+			return
+		}
+	}
+
+	var count int
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		var err error
+		count, err = strconv.Atoi(countStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid count %q: %v", countStr, err), http.StatusBadRequest)
+			return
+		}
+	}
+	var delay time.Duration
+	if delayStr := r.URL.Query().Get("delay"); delayStr != "" {
+		var err error
+		delay, err = time.ParseDuration(delayStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid delay %q: %v", delayStr, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := fault.Arm(point, count, delay); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, &FaultsResult{
+		Enabled: fault.Enabled,
+		Armed:   fault.Armed(),
+	})
+}
+
+// apiTrace is the handler for the '/trace/{id}' API request. The id may be the
+// correlation ID of a client request, or an order or match ID, for which the
+// retained log events of the linked requests are returned. Tracing must be
+// enabled with a SrvConfig.Tracer.
+func (s *Server) apiTrace(w http.ResponseWriter, r *http.Request) {
+	if s.tracer == nil {
+		http.Error(w, "tracing is disabled", http.StatusBadRequest)
+		return
+	}
+	id := strings.ToLower(chi.URLParam(r, traceIDKey))
+	writeJSON(w, &TraceResult{
+		ID:     id,
+		Events: s.tracer.Query(id),
+	})
+}
@@ -0,0 +1,256 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package admin
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// dashboardHeader is the header that the dashboard sets on the POST requests
+// of its controls. A browser only sends a custom header with a cross-origin
+// request after a CORS preflight, which the admin server never approves, so a
+// page on another origin cannot make these requests with the operator's
+// cached credentials.
+const dashboardHeader = "X-DEX-Dashboard"
+
+// sameOrigin responds with a 403 status to requests that a browser reports as
+// coming from a page on another origin, with either the Origin header or the
+// Sec-Fetch-Site header. Browsers attach the cached Basic auth credentials to
+// such requests, so they could otherwise change server state on behalf of the
+// operator. Requests from other clients do not have these headers.
+func sameOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				log.Warnf("admin request from %s with cross-origin %q", r.RemoteAddr, origin)
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+				return
+			}
+		}
+		switch site := r.Header.Get("Sec-Fetch-Site"); site {
+		case "", "same-origin", "none":
+		default:
+			log.Warnf("admin request from %s with fetch site %q", r.RemoteAddr, site)
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireDashboardHeader responds with a 403 status to requests without the
+// dashboardHeader.
+func requireDashboardHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(dashboardHeader) == "" {
+			http.Error(w, "missing "+dashboardHeader+" header", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dashboard is the handler for the '/dashboard' request. The page polls the
+// admin API for market statuses, epoch timings, and activity, and provides
+// controls to suspend and resume markets and to ban accounts. The browser
+// sends the credentials with which the page was loaded with each API request.
+// The controls use POST requests with the dashboardHeader.
+func (s *Server) dashboard(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, dashboardHTML); err != nil {
+		log.Errorf("Error writing dashboard: %v", err)
+	}
+}
+
+// dashboardHTML is the dashboard page. It has no external resources, and it
+// refreshes every five seconds.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DEX Admin Dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f0f0f0; }
+.num { text-align: right; }
+.running { color: #080; }
+.stopped { color: #b00; }
+#error { color: #b00; }
+#summary span { margin-right: 2em; }
+</style>
+</head>
+<body>
+<h1>DEX Admin Dashboard</h1>
+<div id="summary">
+<span>Connected clients: <b id="clients">-</b></span>
+<span>Active matches: <b id="matches">-</b></span>
+<span>Updated: <span id="updated">-</span></span>
+</div>
+<p id="error"></p>
+
+<h2>Markets</h2>
+<table>
+<thead><tr><th>Market</th><th>Status</th><th>Epoch (ms)</th><th>Active epoch</th>
+<th>Final epoch</th><th>Backlog</th><th>Shed orders</th><th></th></tr></thead>
+<tbody id="markets"></tbody>
+</table>
+
+<h2>Epoch timings</h2>
+<table>
+<thead><tr><th>Market</th><th>Stage</th><th>Count</th><th>Avg (ms)</th><th>Max (ms)</th><th>Last epoch (ms)</th></tr></thead>
+<tbody id="timings"></tbody>
+</table>
+
+<h2>Swap failures</h2>
+<table>
+<thead><tr><th>Market</th><th>Status</th><th>Revoked matches</th></tr></thead>
+<tbody id="failures"></tbody>
+</table>
+
+<h2>Ban account</h2>
+<form id="ban">
+<input id="banacct" size="66" placeholder="account ID">
+<input id="banrule" type="number" min="1" value="1" style="width: 4em" title="rule">
+<input id="banreason" placeholder="reason">
+<button type="submit">Ban</button>
+</form>
+
+<script>
+var pollMS = 5000;
+
+function cell(row, text, cls) {
+  var td = document.createElement("td");
+  td.textContent = text;
+  if (cls) td.className = cls;
+  row.appendChild(td);
+  return td;
+}
+
+function showError(msg) {
+  document.getElementById("error").textContent = msg;
+}
+
+function api(path, method) {
+  var opts = {credentials: "same-origin"};
+  if (method) {
+    opts.method = method;
+    opts.headers = {"` + dashboardHeader + `": "1"};
+  }
+  return fetch("/api" + path, opts).then(function(res) {
+    return res.text().then(function(body) {
+      if (!res.ok) throw new Error(path + ": " + res.status + " " + body.trim());
+      return JSON.parse(body);
+    });
+  });
+}
+
+function act(path, confirmMsg) {
+  if (!window.confirm(confirmMsg)) return;
+  api(path, "POST").then(function(res) {
+    showError("");
+    window.alert(JSON.stringify(res, null, 2));
+    refresh();
+  }).catch(function(err) { showError(err.message); });
+}
+
+function renderMarkets(mkts) {
+  var tbody = document.getElementById("markets");
+  tbody.textContent = "";
+  Object.keys(mkts).sort().forEach(function(name) {
+    var m = mkts[name];
+    var row = document.createElement("tr");
+    cell(row, name);
+    cell(row, m.running ? "running" : "suspended", m.running ? "running" : "stopped");
+    cell(row, m.epochlen, "num");
+    cell(row, m.activeepoch, "num");
+    cell(row, m.finalepoch || "", "num");
+    cell(row, m.epochbacklog || 0, "num");
+    cell(row, m.shedorders || 0, "num");
+    var btn = document.createElement("button");
+    if (m.running) {
+      btn.textContent = "Suspend";
+      btn.onclick = function() { act("/market/" + name + "/suspend", "Suspend " + name + " at the end of the current epoch?"); };
+    } else {
+      btn.textContent = "Resume";
+      btn.onclick = function() { act("/market/" + name + "/resume", "Resume " + name + " with the next epoch?"); };
+    }
+    cell(row, "").appendChild(btn);
+    tbody.appendChild(row);
+  });
+  return Object.keys(mkts).sort();
+}
+
+function renderTimings(all) {
+  var tbody = document.getElementById("timings");
+  tbody.textContent = "";
+  all.forEach(function(t) {
+    var last = t.recent.length ? t.recent[t.recent.length - 1].totalms.toFixed(1) : "";
+    t.stages.forEach(function(st, i) {
+      var row = document.createElement("tr");
+      cell(row, i == 0 ? t.market : "");
+      cell(row, st.stage);
+      cell(row, st.count, "num");
+      cell(row, st.avgms.toFixed(2), "num");
+      cell(row, st.maxms.toFixed(2), "num");
+      cell(row, i == 0 ? last : "", "num");
+      tbody.appendChild(row);
+    });
+  });
+}
+
+function renderActivity(a) {
+  document.getElementById("clients").textContent = a.connectedclients;
+  document.getElementById("matches").textContent = a.activematches;
+  var tbody = document.getElementById("failures");
+  tbody.textContent = "";
+  if (a.swapfailures.length == 0) {
+    var row = document.createElement("tr");
+    cell(row, "none").colSpan = 3;
+    tbody.appendChild(row);
+  }
+  a.swapfailures.forEach(function(f) {
+    var row = document.createElement("tr");
+    cell(row, f.market);
+    cell(row, f.status);
+    cell(row, f.count, "num");
+    tbody.appendChild(row);
+  });
+}
+
+function refresh() {
+  Promise.all([api("/markets"), api("/activity")]).then(function(res) {
+    var names = renderMarkets(res[0]);
+    renderActivity(res[1]);
+    return Promise.all(names.map(function(name) { return api("/market/" + name + "/timings"); }));
+  }).then(function(timings) {
+    renderTimings(timings);
+    document.getElementById("updated").textContent = new Date().toLocaleTimeString();
+    showError("");
+  }).catch(function(err) { showError(err.message); });
+}
+
+document.getElementById("ban").onsubmit = function(e) {
+  e.preventDefault();
+  var acct = document.getElementById("banacct").value.trim();
+  var rule = document.getElementById("banrule").value;
+  var reason = document.getElementById("banreason").value.trim();
+  var path = "/account/" + encodeURIComponent(acct) + "/ban?rule=" + encodeURIComponent(rule);
+  if (reason) path += "&reason=" + encodeURIComponent(reason);
+  act(path, "Ban account " + acct + "?");
+};
+
+refresh();
+setInterval(refresh, pollMS);
+</script>
+</body>
+</html>
+`
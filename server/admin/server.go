@@ -12,14 +12,20 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"sync"
 	"time"
 
+	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/auth"
+	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/market"
+	"decred.org/dcrdex/server/swap"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/slog"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -34,6 +40,11 @@ const (
 	marketNameKey = "market"
 	accountIDKey  = "account"
 	ruleToken     = "rule"
+	approveToken  = "approve"
+	reasonToken   = "reason"
+	faultPointKey = "point"
+	traceIDKey    = "id"
+	apiKeyKey     = "key"
 )
 
 var (
@@ -44,28 +55,125 @@ var (
 type SvrCore interface {
 	Accounts() ([]*db.Account, error)
 	AccountInfo(account.AccountID) (*db.Account, error)
+	SetAccountNotes(aid account.AccountID, notes string, tags []string) error
 	ConfigMsg() json.RawMessage
 	MarketRunning(mktName string) (found, running bool)
 	MarketStatus(mktName string) *market.Status
 	MarketStatuses() map[string]*market.Status
+	EpochTimings(mktName string) *market.Timings
+	AllEpochTimings() map[string]*market.Timings
 	SuspendMarket(name string, tSusp time.Time, persistBooks bool) *market.SuspendEpoch
-	Penalize(aid account.AccountID, rule account.Rule) error
+	ResumeMarket(name string, asSoonAs time.Time) (*market.ResumeEpoch, error)
+	DelistMarket(name string) (*market.SuspendEpoch, error)
+	ForceEpoch(name string, epochIdx int64, skip, dryRun bool) (*market.ForcedEpoch, error)
+	PurgeStaleOrders(name string, maxAge time.Duration) ([]order.OrderID, error)
+	VerifyBook(name string) (*market.BookVerification, error)
+	ExportMarket(name string) (*market.Export, error)
+	Penalize(aid account.AccountID, rule account.Rule, details string) error
+	Forgive(aid account.AccountID, reason string) error
+	Appeals() ([]*db.Appeal, error)
+	DecideAppeal(aid account.AccountID, accept bool, reason string) error
+	PreimageMisses(aid account.AccountID) ([]*db.PreimageMiss, error)
+	FeeInvoices(start, end time.Time) ([]*db.FeeInvoice, error)
+	CompletedMatches(start, end time.Time) ([]*db.CompletedMatch, error)
+	Drain() <-chan struct{}
+	ClientVersions(aid account.AccountID) ([]*db.ClientVersion, error)
+	AccountTimeline(aid account.AccountID, since, until time.Time, offset, n int) (*db.Timeline, error)
+	ClientVersionStats(since time.Time) ([]*db.ClientVersionStats, error)
+	APIKeys() []*comms.APIKey
+	IssueAPIKey(label string, markets []string, rateLimit uint32) (*comms.APIKey, error)
+	RevokeAPIKey(key string) error
+	PoWDifficulty() uint8
+	SetPoWDifficulty(difficulty uint8) error
+	PenaltyPolicy() *account.PenaltyPolicy
+	MessageRejects() []*comms.RejectCount
+	ConnectedClients() int
+	ActiveMatches() int
+	SwapFailures() []*swap.SwapFailures
+	SetPenaltyPolicy(policy *account.PenaltyPolicy) error
+	SetRegistrationOverride(aid account.AccountID, approve bool)
+	ClearRegistrationOverride(aid account.AccountID)
+	RegistrationApproval(aid account.AccountID) *auth.RegistrationApproval
+}
+
+// Standby is satisfied by a standby server that can be promoted to run the
+// DEX. See server/dex.Standby.
+type Standby interface {
+	Promote() (SvrCore, error)
+	StandbyStatus() *StandbyStatus
 }
 
 // Server is a multi-client https server.
 type Server struct {
+	// coreMtx guards core, which is nil until a standby server is promoted.
+	coreMtx   sync.RWMutex
 	core      SvrCore
-	addr      string
+	standby   Standby
+	listeners []*Listener
 	tlsConfig *tls.Config
 	srv       *http.Server
 	authSHA   [32]byte
+	// shutdown is called once the DEX is drained after a shutdown request.
+	shutdown     func()
+	shutdownOnce sync.Once
+	tracer       *trace.Tracer
+}
+
+// Listener is an address on which the admin server listens, with the
+// networks from which requests are allowed on that address. For example, the
+// admin server may listen on a public address but only accept requests from a
+// VPN subnet.
+type Listener struct {
+	Addr string
+	// AllowNets are the networks from which requests are allowed. Requests
+	// from all addresses are allowed if AllowNets is empty.
+	AllowNets []*net.IPNet
 }
 
-// SrvConfig holds variables needed to create a new Server.
+// allows checks if the remote address is in one of the allowed networks.
+func (l *Listener) allows(remoteAddr string) bool {
+	if len(l.AllowNets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range l.AllowNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerCtxKey is the context key of the *Listener on which a request was
+// received.
+type listenerCtxKey struct{}
+
+// SrvConfig holds variables needed to create a new Server. For a standby
+// server, Core is nil and Standby is set.
 type SrvConfig struct {
-	Core            SvrCore
-	Addr, Cert, Key string
-	AuthSHA         [32]byte
+	Core    SvrCore
+	Standby Standby
+	// Addr is an address on which to listen, allowing requests from all
+	// networks. Listeners are additional addresses, each with its own policy.
+	Addr      string
+	Listeners []*Listener
+	Cert, Key string
+	AuthSHA   [32]byte
+	// Shutdown is called to shut down the DEX when it is drained after a
+	// request to the shutdown endpoint. It may be nil.
+	Shutdown func()
+	// Dashboard enables the operator dashboard web page at /dashboard.
+	Dashboard bool
+	// Tracer is the DEX's tracer of client requests, which is queried by the
+	// trace request. It is nil if tracing is disabled.
+	Tracer *trace.Tracer
 }
 
 // UseLogger sets the logger for the admin package.
@@ -97,59 +205,156 @@ func NewServer(cfg *SrvConfig) (*Server, error) {
 		MinVersion:   tls.VersionTLS12,
 	}
 
+	var listeners []*Listener
+	if cfg.Addr != "" {
+		listeners = append(listeners, &Listener{Addr: cfg.Addr})
+	}
+	listeners = append(listeners, cfg.Listeners...)
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listen address")
+	}
+
 	// Create an HTTP router.
 	mux := chi.NewRouter()
 	httpServer := &http.Server{
 		Handler:      mux,
 		ReadTimeout:  rpcTimeoutSeconds * time.Second, // slow requests should not hold connections opened
 		WriteTimeout: rpcTimeoutSeconds * time.Second, // hung responses must die
+		// The Listener is available to the handlers in the request context.
+		BaseContext: func(l net.Listener) context.Context {
+			ctx := context.Background()
+			if pl, ok := l.(*policyListener); ok {
+				ctx = context.WithValue(ctx, listenerCtxKey{}, pl.policy)
+			}
+			return ctx
+		},
 	}
 
 	// Make the server.
 	s := &Server{
 		core:      cfg.Core,
+		standby:   cfg.Standby,
 		srv:       httpServer,
-		addr:      cfg.Addr,
+		listeners: listeners,
 		tlsConfig: tlsConfig,
 		authSHA:   cfg.AuthSHA,
+		shutdown:  cfg.Shutdown,
+		tracer:    cfg.Tracer,
 	}
 
-	// Middleware
+	// Middleware. The listener policy is checked before RealIP, which would
+	// take the client's address from the request headers.
 	mux.Use(middleware.Recoverer)
+	mux.Use(listenerPolicy)
 	mux.Use(middleware.RealIP)
 	mux.Use(oneTimeConnection)
 	mux.Use(s.authMiddleware)
 
+	if cfg.Dashboard {
+		mux.Get("/dashboard", s.dashboard)
+	}
+
 	// api endpoints
 	mux.Route("/api", func(r chi.Router) {
+		r.Use(sameOrigin)
 		r.Use(middleware.AllowContentType("application/json"))
 		r.Get("/ping", s.apiPing)
-		r.Get("/config", s.apiConfig)
-		r.Get("/accounts", s.apiAccounts)
-		r.Route("/account/{"+accountIDKey+"}", func(rm chi.Router) {
-			rm.Get("/", s.apiAccountInfo)
-			rm.Get("/ban", s.apiBan)
-		})
-		r.Get("/markets", s.apiMarkets)
-		r.Route("/market/{"+marketNameKey+"}", func(rm chi.Router) {
-			rm.Get("/", s.apiMarketInfo)
-			rm.Get("/suspend", s.apiSuspend)
+		r.Get("/standby", s.apiStandby)
+		r.Post("/promote", s.apiPromote)
+		r.Get("/faults", s.apiFaults)
+		r.Get("/fault/{"+faultPointKey+"}", s.apiFault)
+		r.Get("/trace/{"+traceIDKey+"}", s.apiTrace)
+		// The remaining routes require a running DEX.
+		r.Group(func(rc chi.Router) {
+			rc.Use(s.coreMiddleware)
+			rc.Get("/config", s.apiConfig)
+			rc.Get("/accounts", s.apiAccounts)
+			rc.Post("/accounts/ban", s.apiBulkBan)
+			rc.Post("/accounts/forgive", s.apiBulkForgive)
+			rc.Get("/appeals", s.apiAppeals)
+			rc.Get("/fees", s.apiFees)
+			rc.Get("/tradereport", s.apiTradeReport)
+			rc.Get("/clientversions", s.apiClientVersionStats)
+			rc.Get("/apikeys", s.apiAPIKeys)
+			rc.Post("/apikeys", s.apiIssueAPIKey)
+			rc.Get("/pow", s.apiPoW)
+			rc.Post("/pow", s.apiSetPoW)
+			rc.Get("/policy", s.apiPenaltyPolicy)
+			rc.Put("/policy", s.apiSetPenaltyPolicy)
+			rc.Delete("/apikey/{"+apiKeyKey+"}", s.apiRevokeAPIKey)
+			rc.Route("/account/{"+accountIDKey+"}", func(rm chi.Router) {
+				rm.Get("/", s.apiAccountInfo)
+				rm.Patch("/", s.apiSetAccountNotes)
+				rm.Get("/ban", s.apiBan)
+				rm.With(requireDashboardHeader).Post("/ban", s.apiBan)
+				rm.Get("/appeal/accept", s.apiAcceptAppeal)
+				rm.Get("/appeal/reject", s.apiRejectAppeal)
+				rm.Get("/preimagemisses", s.apiPreimageMisses)
+				rm.Get("/clientversions", s.apiClientVersions)
+				rm.Get("/timeline", s.apiAccountTimeline)
+				rm.Get("/registration", s.apiRegistration)
+				rm.Post("/registration", s.apiSetRegistration)
+				rm.Delete("/registration", s.apiClearRegistration)
+			})
+			rc.Get("/markets", s.apiMarkets)
+			rc.Get("/metrics", s.apiMetrics)
+			rc.Get("/activity", s.apiActivity)
+			rc.Post("/shutdown", s.apiShutdown)
+			rc.Route("/market/{"+marketNameKey+"}", func(rm chi.Router) {
+				rm.Get("/", s.apiMarketInfo)
+				rm.Get("/suspend", s.apiSuspend)
+				rm.With(requireDashboardHeader).Post("/suspend", s.apiSuspend)
+				rm.Get("/resume", s.apiResume)
+				rm.With(requireDashboardHeader).Post("/resume", s.apiResume)
+				rm.Get("/timings", s.apiTimings)
+				rm.Post("/forceepoch", s.apiForceEpoch)
+				rm.Post("/purgestale", s.apiPurgeStale)
+				rm.Get("/verify", s.apiVerifyBook)
+				rm.Get("/export", s.apiExportMarket)
+				rm.Delete("/", s.apiDelist)
+			})
 		})
 	})
 
 	return s, nil
 }
 
+// policyListener is a net.Listener with its Listener policy.
+type policyListener struct {
+	net.Listener
+	policy *Listener
+}
+
+// listen creates the TLS listener for each Listener. If any address cannot be
+// bound, the listeners that were created are closed.
+func (s *Server) listen() ([]*policyListener, error) {
+	var listeners []*policyListener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+	for _, policy := range s.listeners {
+		listener, err := tls.Listen("tcp", policy.Addr, s.tlsConfig)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("can't listen on %s: %w", policy.Addr, err)
+		}
+		listeners = append(listeners, &policyListener{listener, policy})
+	}
+	return listeners, nil
+}
+
 // Run starts the server.
 func (s *Server) Run(ctx context.Context) {
-	// Create listener.
-	listener, err := tls.Listen("tcp", s.addr, s.tlsConfig)
+	// Create listeners.
+	listeners, err := s.listen()
 	if err != nil {
-		log.Errorf("can't listen on %s. admin server quitting: %v", s.addr, err)
+		log.Errorf("admin server quitting: %v", err)
 		return
 	}
 
-	// Close the listener on context cancellation.
+	// Close the listeners on context cancellation.
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -161,9 +366,15 @@ func (s *Server) Run(ctx context.Context) {
 			log.Errorf("HTTP server Shutdown: %v", err)
 		}
 	}()
-	log.Infof("admin server listening on %s", s.addr)
-	if err := s.srv.Serve(listener); err != http.ErrServerClosed {
-		log.Warnf("unexpected (http.Server).Serve error: %v", err)
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func(listener *policyListener) {
+			defer wg.Done()
+			log.Infof("admin server listening on %s", listener.Addr())
+			if err := s.srv.Serve(listener); err != http.ErrServerClosed {
+				log.Warnf("unexpected (http.Server).Serve error: %v", err)
+			}
+		}(listener)
 	}
 
 	// Wait for Shutdown.
@@ -171,6 +382,34 @@ func (s *Server) Run(ctx context.Context) {
 	log.Infof("admin server off")
 }
 
+// coreMiddleware responds with a 503 status if the DEX is not running, as is
+// the case for a standby server that has not been promoted.
+func (s *Server) coreMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.coreMtx.RLock()
+		core := s.core
+		s.coreMtx.RUnlock()
+		if core == nil {
+			http.Error(w, "server is in standby mode", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listenerPolicy responds with a 403 status to requests from networks that
+// are not allowed on the listener on which the request was received.
+func listenerPolicy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy, ok := r.Context().Value(listenerCtxKey{}).(*Listener); ok && !policy.allows(r.RemoteAddr) {
+			log.Warnf("admin request from disallowed address %s on %s", r.RemoteAddr, policy.Addr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // oneTimeConnection sets fields in the header and request that indicate this
 // connection should not be reused.
 func oneTimeConnection(next http.Handler) http.Handler {
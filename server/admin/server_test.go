@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -24,9 +25,16 @@ import (
 
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/auth"
+	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/fault"
 	"decred.org/dcrdex/server/market"
+	"decred.org/dcrdex/server/swap"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/certgen"
 	"github.com/decred/slog"
 	"github.com/go-chi/chi"
@@ -51,7 +59,59 @@ type TCore struct {
 	accountsErr error
 	account     *db.Account
 	accountErr  error
+	notes       *db.Account
+	notesErr    error
+	acctsByID   map[account.AccountID]*db.Account
+	penalized   []account.AccountID
 	penalizeErr error
+	forgiven    []account.AccountID
+	forgiveErr  error
+	delistErr   error
+	appeals     []*db.Appeal
+	appealsErr  error
+	decideErr   error
+	decided     *bool
+	invoices    []*db.FeeInvoice
+	invoicesErr error
+	invStart    time.Time
+	invEnd      time.Time
+	completed   []*db.CompletedMatch
+	policy      *account.PenaltyPolicy
+	rejects     []*comms.RejectCount
+	forced      *market.ForcedEpoch
+	forceErr    error
+	forceDryRun bool
+	timings     map[string]*market.Timings
+	drained     chan struct{}
+	drains      int
+	purged      []order.OrderID
+	purgeAge    time.Duration
+	verified    *market.BookVerification
+	verifyErr   error
+	exported    *market.Export
+	exportErr   error
+	piMisses    []*db.PreimageMiss
+	piMissesErr error
+	versions    []*db.ClientVersion
+	verStats    []*db.ClientVersionStats
+	verErr      error
+	verSince    time.Time
+	timeline    *db.Timeline
+	timelineErr error
+	tlSince     time.Time
+	tlUntil     time.Time
+	tlOffset    int
+	tlN         int
+	apiKeys     []*comms.APIKey
+	issueErr    error
+	revokeErr   error
+	revoked     string
+	powBits     uint8
+	resumeErr   error
+	clients     int
+	active      int
+	failures    []*swap.SwapFailures
+	regApproval *auth.RegistrationApproval
 }
 
 func (c *TCore) ConfigMsg() json.RawMessage { return nil }
@@ -71,6 +131,53 @@ func (c *TCore) SuspendMarket(name string, tSusp time.Time, persistBooks bool) *
 	return tMkt.suspend
 }
 
+func (c *TCore) ResumeMarket(name string, asSoonAs time.Time) (*market.ResumeEpoch, error) {
+	if c.resumeErr != nil {
+		return nil, c.resumeErr
+	}
+	tMkt := c.markets[name]
+	if tMkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	tMkt.running = true
+	return &market.ResumeEpoch{
+		Idx:   encode.UnixMilli(asSoonAs),
+		Start: asSoonAs,
+	}, nil
+}
+
+func (c *TCore) DelistMarket(name string) (*market.SuspendEpoch, error) {
+	if c.delistErr != nil {
+		return nil, c.delistErr
+	}
+	tMkt := c.markets[name]
+	if tMkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	return c.SuspendMarket(name, time.Time{}, false), nil
+}
+
+func (c *TCore) ForceEpoch(name string, epochIdx int64, skip, dryRun bool) (*market.ForcedEpoch, error) {
+	c.forceDryRun = dryRun
+	if c.forceErr != nil {
+		return nil, c.forceErr
+	}
+	fe := *c.forced
+	fe.DryRun = dryRun
+	if !dryRun {
+		fe.Skipped = skip
+	}
+	return &fe, nil
+}
+
+func (c *TCore) EpochTimings(mktName string) *market.Timings {
+	return c.timings[mktName]
+}
+
+func (c *TCore) AllEpochTimings() map[string]*market.Timings {
+	return c.timings
+}
+
 func (c *TCore) market(name string) *TMarket {
 	if c.markets == nil {
 		return nil
@@ -141,12 +248,136 @@ func (w *tResponseWriter) WriteHeader(statusCode int) {
 }
 
 func (c *TCore) Accounts() ([]*db.Account, error) { return c.accounts, c.accountsErr }
-func (c *TCore) AccountInfo(_ account.AccountID) (*db.Account, error) {
+func (c *TCore) AccountInfo(aid account.AccountID) (*db.Account, error) {
+	if c.acctsByID != nil {
+		return c.acctsByID[aid], c.accountErr
+	}
 	return c.account, c.accountErr
 }
-func (c *TCore) Penalize(_ account.AccountID, _ account.Rule) error {
+func (c *TCore) SetAccountNotes(aid account.AccountID, notes string, tags []string) error {
+	c.notes = &db.Account{AccountID: aid, Notes: notes, Tags: tags}
+	return c.notesErr
+}
+func (c *TCore) Penalize(aid account.AccountID, _ account.Rule, _ string) error {
+	c.penalized = append(c.penalized, aid)
 	return c.penalizeErr
 }
+func (c *TCore) Forgive(aid account.AccountID, _ string) error {
+	c.forgiven = append(c.forgiven, aid)
+	return c.forgiveErr
+}
+func (c *TCore) Appeals() ([]*db.Appeal, error) {
+	return c.appeals, c.appealsErr
+}
+func (c *TCore) DecideAppeal(_ account.AccountID, accept bool, _ string) error {
+	c.decided = &accept
+	return c.decideErr
+}
+func (c *TCore) PreimageMisses(account.AccountID) ([]*db.PreimageMiss, error) {
+	return c.piMisses, c.piMissesErr
+}
+func (c *TCore) PurgeStaleOrders(name string, maxAge time.Duration) ([]order.OrderID, error) {
+	c.purgeAge = maxAge
+	return c.purged, nil
+}
+
+func (c *TCore) VerifyBook(name string) (*market.BookVerification, error) {
+	return c.verified, c.verifyErr
+}
+
+func (c *TCore) ExportMarket(name string) (*market.Export, error) {
+	return c.exported, c.exportErr
+}
+
+func (c *TCore) Drain() <-chan struct{} {
+	c.drains++
+	return c.drained
+}
+
+func (c *TCore) ClientVersions(account.AccountID) ([]*db.ClientVersion, error) {
+	return c.versions, c.verErr
+}
+
+func (c *TCore) AccountTimeline(_ account.AccountID, since, until time.Time, offset, n int) (*db.Timeline, error) {
+	c.tlSince, c.tlUntil, c.tlOffset, c.tlN = since, until, offset, n
+	return c.timeline, c.timelineErr
+}
+
+func (c *TCore) ClientVersionStats(since time.Time) ([]*db.ClientVersionStats, error) {
+	c.verSince = since
+	return c.verStats, c.verErr
+}
+
+func (c *TCore) APIKeys() []*comms.APIKey { return c.apiKeys }
+
+func (c *TCore) IssueAPIKey(label string, markets []string, rateLimit uint32) (*comms.APIKey, error) {
+	if c.issueErr != nil {
+		return nil, c.issueErr
+	}
+	key := &comms.APIKey{Key: "abcd", Label: label, Markets: markets, RateLimit: rateLimit}
+	c.apiKeys = append(c.apiKeys, key)
+	return key, nil
+}
+
+func (c *TCore) RevokeAPIKey(key string) error {
+	c.revoked = key
+	return c.revokeErr
+}
+
+func (c *TCore) PoWDifficulty() uint8 { return c.powBits }
+
+func (c *TCore) SetPoWDifficulty(difficulty uint8) error {
+	if difficulty > msgjson.MaxPoWBits {
+		return fmt.Errorf("difficulty %d too high", difficulty)
+	}
+	c.powBits = difficulty
+	return nil
+}
+
+func (c *TCore) SetRegistrationOverride(aid account.AccountID, approve bool) {
+	c.regApproval = &auth.RegistrationApproval{Override: &approve}
+}
+
+func (c *TCore) ClearRegistrationOverride(aid account.AccountID) {
+	c.regApproval = new(auth.RegistrationApproval)
+}
+
+func (c *TCore) RegistrationApproval(aid account.AccountID) *auth.RegistrationApproval {
+	if c.regApproval == nil {
+		return new(auth.RegistrationApproval)
+	}
+	return c.regApproval
+}
+
+func (c *TCore) FeeInvoices(start, end time.Time) ([]*db.FeeInvoice, error) {
+	c.invStart, c.invEnd = start, end
+	return c.invoices, c.invoicesErr
+}
+
+func (c *TCore) PenaltyPolicy() *account.PenaltyPolicy {
+	if c.policy == nil {
+		return account.DefaultPenaltyPolicy()
+	}
+	return c.policy
+}
+
+func (c *TCore) SetPenaltyPolicy(policy *account.PenaltyPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	c.policy = policy
+	return nil
+}
+
+func (c *TCore) MessageRejects() []*comms.RejectCount { return c.rejects }
+func (c *TCore) ConnectedClients() int                { return c.clients }
+func (c *TCore) ActiveMatches() int                   { return c.active }
+func (c *TCore) SwapFailures() []*swap.SwapFailures   { return c.failures }
+
+func (c *TCore) CompletedMatches(start, end time.Time) ([]*db.CompletedMatch, error) {
+	c.invStart, c.invEnd = start, end
+	return c.completed, c.invoicesErr
+}
 
 // genCertPair generates a key/cert pair to the paths provided.
 func genCertPair(certFile, keyFile string) error {
@@ -177,7 +408,7 @@ var tPort = 5555
 
 // If start is true, the Server's Run goroutine is started, and the shutdown
 // func must be called when finished with the Server.
-func newTServer(t *testing.T, start bool, authSHA [32]byte) (*Server, func()) {
+func newTServer(t *testing.T, start bool, authSHA [32]byte, dashboard bool) (*Server, func()) {
 	tmp, err := ioutil.TempDir("", "admin")
 	if err != nil {
 		t.Fatal(err)
@@ -191,11 +422,12 @@ func newTServer(t *testing.T, start bool, authSHA [32]byte) (*Server, func()) {
 	}
 
 	s, err := NewServer(&SrvConfig{
-		Core:    new(TCore),
-		Addr:    fmt.Sprintf("localhost:%d", tPort),
-		Cert:    cert,
-		Key:     key,
-		AuthSHA: authSHA,
+		Core:      new(TCore),
+		Addr:      fmt.Sprintf("localhost:%d", tPort),
+		Cert:      cert,
+		Key:       key,
+		AuthSHA:   authSHA,
+		Dashboard: dashboard,
 	})
 	if err != nil {
 		t.Fatalf("error creating Server: %v", err)
@@ -657,10 +889,135 @@ func TestSuspend(t *testing.T) {
 	}
 }
 
+func TestResume(t *testing.T) {
+	core := &TCore{
+		markets: make(map[string]*TMarket),
+	}
+	srv := &Server{
+		core: core,
+	}
+
+	mux := chi.NewRouter()
+	mux.Get("/market/{"+marketNameKey+"}/resume", srv.apiResume)
+
+	name := "dcr_btc"
+	request := func(query string, wantCode int) *ResumeResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/market/"+name+"/resume"+query, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != wantCode {
+			t.Fatalf("apiResume returned code %d, expected %d", w.Code, wantCode)
+		}
+		if wantCode != http.StatusOK {
+			return nil
+		}
+		res := new(ResumeResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("failed to unmarshal result: %v", err)
+		}
+		return res
+	}
+
+	// Non-existent market.
+	request("", http.StatusBadRequest)
+
+	// Running market.
+	tMkt := &TMarket{running: true}
+	core.markets[name] = tMkt
+	request("", http.StatusBadRequest)
+
+	// Bad resume time.
+	tMkt.running = false
+	request("?t=QWERT", http.StatusBadRequest)
+
+	// Resume error.
+	core.resumeErr = errors.New("not suspended")
+	request("", http.StatusBadRequest)
+	core.resumeErr = nil
+
+	// ASAP.
+	res := request("", http.StatusOK)
+	if res.Market != name || res.StartEpoch != encode.UnixMilli(time.Time{}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if !tMkt.running {
+		t.Fatalf("market not resumed")
+	}
+
+	// At a specified time.
+	tMkt.running = false
+	resTime := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	res = request(fmt.Sprintf("?t=%d", encode.UnixMilli(resTime)), http.StatusOK)
+	if res.StartEpoch != encode.UnixMilli(resTime) || !res.StartTime.Equal(resTime) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestListenerPolicy(t *testing.T) {
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	_, loopback6, _ := net.ParseCIDR("::1/128")
+	policy := &Listener{
+		Addr:      "10.0.0.1:6542",
+		AllowNets: []*net.IPNet{internal, loopback6},
+	}
+	lp := listenerPolicy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name, remoteAddr string
+		policy           *Listener
+		wantCode         int
+	}{{
+		name:       "allowed ipv4",
+		remoteAddr: "10.1.2.3:50000",
+		policy:     policy,
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "allowed ipv6",
+		remoteAddr: "[::1]:50000",
+		policy:     policy,
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "disallowed",
+		remoteAddr: "192.168.1.2:50000",
+		policy:     policy,
+		wantCode:   http.StatusForbidden,
+	}, {
+		name:       "unparseable address",
+		remoteAddr: "localhost",
+		policy:     policy,
+		wantCode:   http.StatusForbidden,
+	}, {
+		name:       "no allowed networks",
+		remoteAddr: "192.168.1.2:50000",
+		policy:     &Listener{Addr: ":6542"},
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "no listener policy",
+		remoteAddr: "192.168.1.2:50000",
+		wantCode:   http.StatusOK,
+	}}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.policy != nil {
+			r = r.WithContext(context.WithValue(r.Context(), listenerCtxKey{}, test.policy))
+		}
+		w := httptest.NewRecorder()
+		lp.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: expected code %d, got %d", test.name, test.wantCode, w.Code)
+		}
+	}
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	pass := "password123"
 	authSHA := sha256.Sum256([]byte(pass))
-	s, _ := newTServer(t, false, authSHA)
+	s, _ := newTServer(t, false, authSHA, false)
 	am := s.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
@@ -715,6 +1072,99 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestDashboard(t *testing.T) {
+	pass := "password123"
+	authSHA := sha256.Sum256([]byte(pass))
+
+	request := func(s *Server, pass string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/dashboard", nil)
+		r.RemoteAddr = "localhost"
+		r.SetBasicAuth("", pass)
+		s.srv.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Disabled.
+	s, _ := newTServer(t, false, authSHA, false)
+	if w := request(s, pass); w.Code != http.StatusNotFound {
+		t.Fatalf("expected code %d with the dashboard disabled, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Enabled, but not authenticated.
+	s, _ = newTServer(t, false, authSHA, true)
+	if w := request(s, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected code %d without authentication, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w := request(s, pass)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("wrong content type %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "/activity") {
+		t.Fatalf("dashboard page does not poll activity")
+	}
+}
+
+func TestDashboardCSRF(t *testing.T) {
+	pass := "password123"
+	authSHA := sha256.Sum256([]byte(pass))
+	s, _ := newTServer(t, false, authSHA, true)
+	name := "dcr_btc"
+	s.core = &TCore{
+		markets: map[string]*TMarket{
+			name: {
+				running: true,
+				suspend: &market.SuspendEpoch{},
+			},
+		},
+	}
+
+	request := func(method string, hdrs map[string]string) int {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, "https://localhost/api/market/"+name+"/suspend", nil)
+		r.RemoteAddr = "localhost"
+		r.SetBasicAuth("", pass)
+		for k, v := range hdrs {
+			r.Header.Set(k, v)
+		}
+		s.srv.Handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		hdrs   map[string]string
+		code   int
+	}{
+		{"api client", "GET", nil, http.StatusOK},
+		{"dashboard", "POST", map[string]string{
+			dashboardHeader:  "1",
+			"Origin":         "https://localhost",
+			"Sec-Fetch-Site": "same-origin",
+		}, http.StatusOK},
+		{"typed url", "GET", map[string]string{"Sec-Fetch-Site": "none"}, http.StatusOK},
+		{"post without header", "POST", map[string]string{"Origin": "https://localhost"}, http.StatusForbidden},
+		{"cross-origin post", "POST", map[string]string{
+			dashboardHeader: "1",
+			"Origin":        "https://evil.example",
+		}, http.StatusForbidden},
+		{"cross-origin get", "GET", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"null origin", "GET", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"cross-site get", "GET", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same-site get", "GET", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := request(tt.method, tt.hdrs); code != tt.code {
+			t.Errorf("%s: expected code %d, got %d", tt.name, tt.code, code)
+		}
+	}
+}
+
 func TestAccounts(t *testing.T) {
 	core := &TCore{
 		accounts: []*db.Account{},
@@ -928,6 +1378,104 @@ func TestAccountInfo(t *testing.T) {
 	}
 }
 
+func TestSetAccountNotes(t *testing.T) {
+	core := new(TCore)
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Route("/account/{"+accountIDKey+"}", func(rm chi.Router) {
+		rm.Patch("/", srv.apiSetAccountNotes)
+	})
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	tests := []struct {
+		name, acctID, body string
+		account            *db.Account
+		accountErr         error
+		notesErr           error
+		wantCode           int
+		wantNotes          string
+		wantTags           []string
+	}{{
+		name:      "ok",
+		acctID:    acctIDStr,
+		body:      `{"notes": "refunded 2021-06", "tags": [" suspected bot", "vip", "", "vip"]}`,
+		account:   &db.Account{Notes: "old", Tags: []string{"old"}},
+		wantCode:  http.StatusOK,
+		wantNotes: "refunded 2021-06",
+		wantTags:  []string{"suspected bot", "vip"},
+	}, {
+		name:      "ok notes only",
+		acctID:    acctIDStr,
+		body:      `{"notes": "refunded 2021-06"}`,
+		account:   &db.Account{Notes: "old", Tags: []string{"old"}},
+		wantCode:  http.StatusOK,
+		wantNotes: "refunded 2021-06",
+		wantTags:  []string{"old"},
+	}, {
+		name:      "ok clear tags",
+		acctID:    acctIDStr,
+		body:      `{"tags": []}`,
+		account:   &db.Account{Notes: "old", Tags: []string{"old"}},
+		wantCode:  http.StatusOK,
+		wantNotes: "old",
+		wantTags:  []string{},
+	}, {
+		name:     "bad body",
+		acctID:   acctIDStr,
+		body:     `{"tags": "vip"}`,
+		account:  &db.Account{},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad account id",
+		acctID:   acctIDStr[2:],
+		body:     `{}`,
+		account:  &db.Account{},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "unknown account",
+		acctID:   acctIDStr,
+		body:     `{}`,
+		wantCode: http.StatusNotFound,
+	}, {
+		name:       "core.AccountInfo error",
+		acctID:     acctIDStr,
+		body:       `{}`,
+		accountErr: errors.New("error"),
+		wantCode:   http.StatusInternalServerError,
+	}, {
+		name:     "core.SetAccountNotes error",
+		acctID:   acctIDStr,
+		body:     `{}`,
+		account:  &db.Account{},
+		notesErr: errors.New("error"),
+		wantCode: http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.account, core.accountErr = test.account, test.accountErr
+		core.notes, core.notesErr = nil, test.notesErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("PATCH", "https://localhost/account/"+test.acctID, strings.NewReader(test.body))
+		r.RemoteAddr = "localhost"
+
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiSetAccountNotes returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		if core.notes == nil {
+			t.Fatalf("%q: notes not stored", test.name)
+		}
+		if core.notes.Notes != test.wantNotes || !reflect.DeepEqual(core.notes.Tags, test.wantTags) {
+			t.Fatalf("%q: stored notes %q and tags %v, expected %q and %v", test.name,
+				core.notes.Notes, core.notes.Tags, test.wantNotes, test.wantTags)
+		}
+	}
+}
+
 func TestBan(t *testing.T) {
 	core := new(TCore)
 	srv := &Server{
@@ -1008,17 +1556,1750 @@ func TestBan(t *testing.T) {
 	}
 }
 
-func TestAPITimeMarshalJSON(t *testing.T) {
-	now := APITime{time.Now()}
-	b, err := json.Marshal(now)
-	if err != nil {
-		t.Fatalf("unable to marshal api time: %v", err)
+func TestFees(t *testing.T) {
+	core := &TCore{
+		invoices: []*db.FeeInvoice{{
+			AssetID: 42,
+			Amount:  2500,
+			Matches: 2,
+		}},
 	}
-	var res APITime
-	if err := json.Unmarshal(b, &res); err != nil {
-		t.Fatalf("unable to unmarshal api time: %v", err)
+	srv := &Server{
+		core: core,
 	}
-	if !res.Equal(now.Time) {
-		t.Fatal("unmarshalled time not equal")
+	mux := chi.NewRouter()
+	mux.Get("/fees", srv.apiFees)
+
+	tests := []struct {
+		name, query string
+		invoicesErr error
+		wantCode    int
+		wantStart   int64
+		wantEnd     int64
+	}{{
+		name:      "ok",
+		query:     "?start=1000&end=2000",
+		wantCode:  http.StatusOK,
+		wantStart: 1000,
+		wantEnd:   2000,
+	}, {
+		name:     "default period",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "bad start",
+		query:    "?start=abc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "end before start",
+		query:    "?start=2000&end=1000",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:        "core error",
+		invoicesErr: errors.New("error"),
+		wantCode:    http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.invoicesErr = test.invoicesErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/fees"+test.query, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiFees returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if !strings.Contains(w.Body.String(), `"amount": 2500`) {
+			t.Fatalf("%q: unexpected fees response %s", test.name, w.Body.String())
+		}
+		if test.wantEnd == 0 {
+			if core.invStart.Unix() != 0 || time.Since(core.invEnd) > time.Minute {
+				t.Fatalf("%q: wrong default period %v to %v", test.name, core.invStart, core.invEnd)
+			}
+			continue
+		}
+		if encode.UnixMilli(core.invStart) != test.wantStart || encode.UnixMilli(core.invEnd) != test.wantEnd {
+			t.Fatalf("%q: wrong period %v to %v", test.name, core.invStart, core.invEnd)
+		}
+	}
+}
+
+func TestTradeReport(t *testing.T) {
+	core := &TCore{
+		completed: []*db.CompletedMatch{{
+			MatchData: db.MatchData{
+				ID:        order.MatchID{0x01},
+				Taker:     order.OrderID{0x02},
+				TakerAcct: account.AccountID{0x03},
+				TakerSell: true,
+				Maker:     order.OrderID{0x04},
+				MakerAcct: account.AccountID{0x05},
+				Quantity:  2e8,
+				Rate:      5e6,
+			},
+			Base:    42,
+			Quote:   0,
+			Made:    1600000000000,
+			Settled: 1600000600000,
+		}},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/tradereport", srv.apiTradeReport)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/tradereport?start=1000&end=1600000001000", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiTradeReport returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	if encode.UnixMilli(core.invStart) != 1000 || encode.UnixMilli(core.invEnd) != 1600000001000 {
+		t.Fatalf("wrong period %v to %v", core.invStart, core.invEnd)
+	}
+	report := new(TradeReport)
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Fatalf("error decoding trade report: %v", err)
+	}
+	if len(report.Trades) != 1 {
+		t.Fatalf("expected 1 trade, got %d", len(report.Trades))
+	}
+	trade := report.Trades[0]
+	if trade.Symbol != "DCR/BTC" || trade.Currency != "BTC" {
+		t.Fatalf("wrong symbol %s, currency %s", trade.Symbol, trade.Currency)
+	}
+	if trade.LastQty != 2 || trade.LastPx != 0.05 || trade.GrossTradeAmt != 0.1 || trade.GrossAtoms != 1e7 {
+		t.Fatalf("wrong amounts: %+v", trade)
+	}
+	if trade.TransactTime != "2020-09-13T12:26:40.000Z" || trade.SettlDate != "2020-09-13" {
+		t.Fatalf("wrong times: transact %s, settle %s", trade.TransactTime, trade.SettlDate)
+	}
+	if len(trade.Sides) != 2 {
+		t.Fatalf("expected 2 sides, got %d", len(trade.Sides))
+	}
+	maker, taker := trade.Sides[0], trade.Sides[1]
+	if maker.Side != TradeSideBuy || maker.AggressorIndicator || maker.PartyID != (account.AccountID{0x05}).String() {
+		t.Fatalf("wrong maker side: %+v", maker)
+	}
+	if taker.Side != TradeSideSell || !taker.AggressorIndicator || taker.OrderID != (order.OrderID{0x02}).String() {
+		t.Fatalf("wrong taker side: %+v", taker)
+	}
+
+	// Bad period and core error.
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "https://localhost/tradereport?start=2000&end=1000", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("apiTradeReport returned code %d for a bad period, expected %d", w.Code, http.StatusBadRequest)
+	}
+	core.invoicesErr = errors.New("error")
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "https://localhost/tradereport", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("apiTradeReport returned code %d for a core error, expected %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestPenaltyPolicy(t *testing.T) {
+	core := new(TCore)
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/policy", srv.apiPenaltyPolicy)
+	mux.Put("/policy", srv.apiSetPenaltyPolicy)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/policy", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiPenaltyPolicy returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	policy := new(account.PenaltyPolicy)
+	if err := json.Unmarshal(w.Body.Bytes(), policy); err != nil {
+		t.Fatalf("error decoding penalty policy: %v", err)
+	}
+	if len(policy.Penalties) != int(account.MaxRule)-1 {
+		t.Fatalf("expected %d penalties, got %d", account.MaxRule-1, len(policy.Penalties))
+	}
+
+	tests := []struct {
+		name, body string
+		wantCode   int
+	}{{
+		name:     "ok",
+		body:     `{"banscore":10,"penalties":[{"rule":4,"score":5,"lotlimit":2,"limithours":24}]}`,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "bad json",
+		body:     `{"banscore":`,
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "invalid policy",
+		body:     `{"penalties":[{"rule":4,"score":5}]}`,
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("PUT", "https://localhost/policy", strings.NewReader(test.body))
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiSetPenaltyPolicy returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+	}
+	if core.policy == nil || core.policy.BanScore != 10 || core.policy.Penalties[0].Rule != account.PreimageReveal ||
+		core.policy.Penalties[0].LotLimit != 2 {
+		t.Fatalf("wrong penalty policy set: %+v", core.policy)
+	}
+}
+
+func TestClientVersions(t *testing.T) {
+	core := &TCore{
+		versions: []*db.ClientVersion{{UserAgent: "dexc/0.1.0", Connects: 2}},
+		verStats: []*db.ClientVersionStats{{UserAgent: "dexc/0.1.0", Accounts: 5, Connected: 1}},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/clientversions", srv.apiClientVersionStats)
+	mux.Get("/account/{"+accountIDKey+"}/clientversions", srv.apiClientVersions)
+
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	tests := []struct {
+		name, path string
+		verErr     error
+		wantCode   int
+		wantBody   string
+		wantSince  int64
+	}{{
+		name:     "stats",
+		path:     "/clientversions",
+		wantCode: http.StatusOK,
+		wantBody: `"accounts": 5`,
+	}, {
+		name:      "stats since",
+		path:      "/clientversions?since=1600000000000",
+		wantCode:  http.StatusOK,
+		wantBody:  `"connected": 1`,
+		wantSince: 1600000000000,
+	}, {
+		name:     "bad since",
+		path:     "/clientversions?since=yesterday",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "stats error",
+		path:     "/clientversions",
+		verErr:   errors.New("error"),
+		wantCode: http.StatusInternalServerError,
+	}, {
+		name:     "account",
+		path:     "/account/" + acctIDStr + "/clientversions",
+		wantCode: http.StatusOK,
+		wantBody: `"connects": 2`,
+	}, {
+		name:     "bad account id",
+		path:     "/account/" + acctIDStr[2:] + "/clientversions",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "account error",
+		path:     "/account/" + acctIDStr + "/clientversions",
+		verErr:   errors.New("error"),
+		wantCode: http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.verErr, core.verSince = test.verErr, time.Time{}
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost"+test.path, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		if !strings.Contains(w.Body.String(), test.wantBody) {
+			t.Fatalf("%q: unexpected response %s", test.name, w.Body.String())
+		}
+		if test.wantSince != 0 && encode.UnixMilli(core.verSince) != test.wantSince {
+			t.Fatalf("%q: wrong since time %v", test.name, core.verSince)
+		}
+	}
+}
+
+func TestAccountTimeline(t *testing.T) {
+	core := &TCore{
+		timeline: &db.Timeline{
+			Total: 1,
+			Events: []*db.TimelineEvent{{
+				Stamp:   1600000000000,
+				Type:    db.TimelineOrder,
+				Market:  "dcr_btc",
+				Details: "limit sell",
+			}},
+		},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/account/{"+accountIDKey+"}/timeline", srv.apiAccountTimeline)
+
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	path := "/account/" + acctIDStr + "/timeline"
+	tests := []struct {
+		name, path  string
+		timelineErr error
+		wantCode    int
+		wantSince   int64
+		wantUntil   int64
+		wantOffset  int
+		wantN       int
+	}{{
+		name:     "defaults",
+		path:     path,
+		wantCode: http.StatusOK,
+		wantN:    defaultTimelineEvents,
+	}, {
+		name:       "paged period",
+		path:       path + "?since=1500000000000&until=1700000000000&offset=100&n=50",
+		wantCode:   http.StatusOK,
+		wantSince:  1500000000000,
+		wantUntil:  1700000000000,
+		wantOffset: 100,
+		wantN:      50,
+	}, {
+		name:     "bad account id",
+		path:     "/account/" + acctIDStr[2:] + "/timeline",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad since",
+		path:     path + "?since=yesterday",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "negative offset",
+		path:     path + "?offset=-1",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "zero n",
+		path:     path + "?n=0",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "n too large",
+		path:     path + "?n=1001",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "until before since",
+		path:     path + "?since=1700000000000&until=1500000000000",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:        "core error",
+		path:        path,
+		timelineErr: errors.New("error"),
+		wantCode:    http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.timelineErr = test.timelineErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost"+test.path, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		if !strings.Contains(w.Body.String(), `"market": "dcr_btc"`) {
+			t.Fatalf("%q: unexpected response %s", test.name, w.Body.String())
+		}
+		if encode.UnixMilli(core.tlSince) != test.wantSince {
+			t.Fatalf("%q: wrong since time %v", test.name, core.tlSince)
+		}
+		if test.wantUntil == 0 && !core.tlUntil.IsZero() ||
+			test.wantUntil != 0 && encode.UnixMilli(core.tlUntil) != test.wantUntil {
+			t.Fatalf("%q: wrong until time %v", test.name, core.tlUntil)
+		}
+		if core.tlOffset != test.wantOffset || core.tlN != test.wantN {
+			t.Fatalf("%q: wrong page %d, %d", test.name, core.tlOffset, core.tlN)
+		}
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	core := new(TCore)
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/apikeys", srv.apiAPIKeys)
+	mux.Post("/apikeys", srv.apiIssueAPIKey)
+	mux.Delete("/apikey/{"+apiKeyKey+"}", srv.apiRevokeAPIKey)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, "https://localhost"+path, strings.NewReader(body))
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	issueTests := []struct {
+		name, body string
+		issueErr   error
+		wantCode   int
+	}{{
+		name:     "ok",
+		body:     `{"label": " partner ", "markets": ["dcr_btc"], "ratelimit": 600}`,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "no rate limit",
+		body:     `{"label": "partner"}`,
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad body",
+		body:     `{"markets": "dcr_btc"}`,
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "core.IssueAPIKey error",
+		body:     `{"ratelimit": 600}`,
+		issueErr: errors.New("unknown market"),
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, test := range issueTests {
+		core.apiKeys, core.issueErr = nil, test.issueErr
+		w := do("POST", "/apikeys", test.body)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiIssueAPIKey returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+	}
+
+	core.apiKeys, core.issueErr = nil, nil
+	do("POST", "/apikeys", issueTests[0].body)
+	if len(core.apiKeys) != 1 {
+		t.Fatalf("API key not issued")
+	}
+	if key := core.apiKeys[0]; key.Label != "partner" || key.RateLimit != 600 ||
+		!reflect.DeepEqual(key.Markets, []string{"dcr_btc"}) {
+		t.Fatalf("unexpected API key %+v", key)
+	}
+
+	w := do("GET", "/apikeys", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiAPIKeys returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), `"label": "partner"`) {
+		t.Fatalf("unexpected API keys response %s", w.Body.String())
+	}
+
+	w = do("DELETE", "/apikey/abcd", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("apiRevokeAPIKey returned code %d, expected %d", w.Code, http.StatusNoContent)
+	}
+	if core.revoked != "abcd" {
+		t.Fatalf("wrong key revoked: %q", core.revoked)
+	}
+	core.revokeErr = errors.New("unknown API key")
+	w = do("DELETE", "/apikey/abcd", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("apiRevokeAPIKey returned code %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPoW(t *testing.T) {
+	core := new(TCore)
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/pow", srv.apiPoW)
+	mux.Post("/pow", srv.apiSetPoW)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, "https://localhost"+path, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name, path string
+		wantCode   int
+		wantBits   uint8
+	}{{
+		name:     "ok",
+		path:     "/pow?bits=16",
+		wantCode: http.StatusOK,
+		wantBits: 16,
+	}, {
+		name:     "disable",
+		path:     "/pow?bits=0",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "no bits",
+		path:     "/pow",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad bits",
+		path:     "/pow?bits=256",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "core.SetPoWDifficulty error",
+		path:     "/pow?bits=25",
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		core.powBits = 0
+		w := do("POST", test.path)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiSetPoW returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if core.powBits != test.wantBits {
+			t.Fatalf("%q: wrong difficulty %d, expected %d", test.name, core.powBits, test.wantBits)
+		}
+	}
+
+	core.powBits = 12
+	w := do("GET", "/pow")
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiPoW returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	res := new(PoWResult)
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if res.Difficulty != 12 {
+		t.Fatalf("wrong difficulty %d", res.Difficulty)
+	}
+}
+
+func newBool(b bool) *bool {
+	return &b
+}
+
+func TestRegistration(t *testing.T) {
+	core := new(TCore)
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Route("/account/{"+accountIDKey+"}", func(rm chi.Router) {
+		rm.Get("/registration", srv.apiRegistration)
+		rm.Post("/registration", srv.apiSetRegistration)
+		rm.Delete("/registration", srv.apiClearRegistration)
+	})
+
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	do := func(method, path string) (*httptest.ResponseRecorder, *RegistrationResult) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, "https://localhost"+path, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			return w, nil
+		}
+		res := new(RegistrationResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if res.AccountID != acctIDStr {
+			t.Fatalf("wrong account ID %s", res.AccountID)
+		}
+		return w, res
+	}
+
+	tests := []struct {
+		name, method, path string
+		wantCode           int
+		wantOverride       *bool
+	}{{
+		name:     "bad account ID",
+		method:   "GET",
+		path:     "/account/zz/registration",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "short account ID",
+		method:   "POST",
+		path:     "/account/0a99/registration?approve=true",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "no approve",
+		method:   "POST",
+		path:     "/account/" + acctIDStr + "/registration",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad approve",
+		method:   "POST",
+		path:     "/account/" + acctIDStr + "/registration?approve=maybe",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:         "approve",
+		method:       "POST",
+		path:         "/account/" + acctIDStr + "/registration?approve=true",
+		wantCode:     http.StatusOK,
+		wantOverride: newBool(true),
+	}, {
+		name:         "get",
+		method:       "GET",
+		path:         "/account/" + acctIDStr + "/registration",
+		wantCode:     http.StatusOK,
+		wantOverride: newBool(true),
+	}, {
+		name:         "deny",
+		method:       "POST",
+		path:         "/account/" + acctIDStr + "/registration?approve=false",
+		wantCode:     http.StatusOK,
+		wantOverride: newBool(false),
+	}, {
+		name:     "clear",
+		method:   "DELETE",
+		path:     "/account/" + acctIDStr + "/registration",
+		wantCode: http.StatusOK,
+	}}
+	for _, test := range tests {
+		w, res := do(test.method, test.path)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if res == nil {
+			continue
+		}
+		if (res.Override == nil) != (test.wantOverride == nil) ||
+			(res.Override != nil && *res.Override != *test.wantOverride) {
+			t.Fatalf("%q: wrong override %v, expected %v", test.name, res.Override, test.wantOverride)
+		}
+	}
+
+	// The cached decision of the registration hook is reported.
+	expire := time.Now().Add(time.Hour)
+	core.regApproval = &auth.RegistrationApproval{
+		Cached:      &auth.RegistrationDecision{Reason: "unverified"},
+		CacheExpiry: &expire,
+	}
+	_, res := do("GET", "/account/"+acctIDStr+"/registration")
+	if res.Approved == nil || *res.Approved || res.Reason != "unverified" || res.CacheExpiry == nil {
+		t.Fatalf("wrong cached decision %+v", res)
+	}
+}
+
+func TestAppeals(t *testing.T) {
+	core := &TCore{
+		appeals: []*db.Appeal{{
+			BrokenRule: account.FailureToAct,
+			Message:    "my wallet crashed",
+			Status:     db.AppealPending,
+		}},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/appeals", srv.apiAppeals)
+	mux.Route("/account/{"+accountIDKey+"}/appeal", func(rm chi.Router) {
+		rm.Get("/accept", srv.apiAcceptAppeal)
+		rm.Get("/reject", srv.apiRejectAppeal)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/appeals", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiAppeals returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), `"pending"`) {
+		t.Fatalf("unexpected appeals response %s", w.Body.String())
+	}
+
+	core.appealsErr = errors.New("error")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("apiAppeals returned code %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	tests := []struct {
+		name, path string
+		decideErr  error
+		wantCode   int
+		wantAccept bool
+	}{{
+		name:       "accept",
+		path:       acctIDStr + "/appeal/accept",
+		wantCode:   http.StatusOK,
+		wantAccept: true,
+	}, {
+		name:     "reject",
+		path:     acctIDStr + "/appeal/reject?" + reasonToken + "=repeat%20offender",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "reject without reason",
+		path:     acctIDStr + "/appeal/reject",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "account id not hex",
+		path:     "nothex/appeal/accept",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "account id wrong length",
+		path:     acctIDStr[2:] + "/appeal/accept",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:      "core.DecideAppeal error",
+		path:      acctIDStr + "/appeal/accept",
+		decideErr: errors.New("no pending appeal"),
+		wantCode:  http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		core.decideErr = test.decideErr
+		core.decided = nil
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/account/"+test.path, nil)
+		r.RemoteAddr = "localhost"
+
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if core.decided == nil || *core.decided != test.wantAccept {
+			t.Fatalf("%q: wrong decision", test.name)
+		}
+		res := new(AppealResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: unexpected response %v: %v", test.name, w.Body.String(), err)
+		}
+		if res.Accepted != test.wantAccept {
+			t.Fatalf("%q: wrong result", test.name)
+		}
+	}
+}
+
+func TestPreimageMisses(t *testing.T) {
+	core := &TCore{
+		piMisses: []*db.PreimageMiss{{
+			Base:      42,
+			Quote:     0,
+			Reason:    db.PreimageTimeout,
+			Connected: true,
+			Latency:   100,
+		}, {
+			Base:    42,
+			Quote:   0,
+			Reason:  db.PreimageDisconnected,
+			Latency: 0, // not measured
+		}, {
+			Base:      42,
+			Quote:     0,
+			Reason:    db.PreimageInvalid,
+			Connected: true,
+			Latency:   300,
+		}},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/account/{"+accountIDKey+"}/preimagemisses", srv.apiPreimageMisses)
+
+	acctIDStr := "0a9912205b2cbab0c25c2de30bda9074de0ae23b065489a99199bad763f102cc"
+	tests := []struct {
+		name, acctID string
+		coreErr      error
+		wantCode     int
+	}{{
+		name:     "ok",
+		acctID:   acctIDStr,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "account id not hex",
+		acctID:   "nothex",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "account id wrong length",
+		acctID:   acctIDStr[2:],
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "core.PreimageMisses error",
+		acctID:   acctIDStr,
+		coreErr:  errors.New("error"),
+		wantCode: http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.piMissesErr = test.coreErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/account/"+test.acctID+"/preimagemisses", nil)
+		r.RemoteAddr = "localhost"
+
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		res := new(PreimageMissReport)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: unexpected response %v: %v", test.name, w.Body.String(), err)
+		}
+		if res.Misses != 3 || res.Timeouts != 1 || res.Disconnects != 1 || res.Invalid != 1 {
+			t.Fatalf("%q: wrong miss counts: %+v", test.name, res)
+		}
+		if res.ConnectedMisses != 2 {
+			t.Fatalf("%q: expected 2 connected misses, got %d", test.name, res.ConnectedMisses)
+		}
+		if res.MeanLatencyMs != 200 {
+			t.Fatalf("%q: expected mean latency 200, got %d", test.name, res.MeanLatencyMs)
+		}
+		if len(res.Records) != 3 || res.Records[0].Market != "dcr_btc" || res.Records[1].Reason != "disconnected" {
+			t.Fatalf("%q: unexpected records: %s", test.name, w.Body.String())
+		}
+	}
+}
+
+func TestFault(t *testing.T) {
+	srv := new(Server)
+	mux := chi.NewRouter()
+	mux.Get("/faults", srv.apiFaults)
+	mux.Get("/fault/{"+faultPointKey+"}", srv.apiFault)
+	defer fault.Clear(fault.DropAck)
+
+	// The fault can only be armed in builds with the fault tag.
+	okIfEnabled := http.StatusBadRequest
+	if fault.Enabled {
+		okIfEnabled = http.StatusOK
+	}
+
+	tests := []struct {
+		name, query string
+		wantCode    int
+		wantArmed   int
+	}{{
+		name:      "ok",
+		query:     "dropack?count=2",
+		wantCode:  okIfEnabled,
+		wantArmed: 1,
+	}, {
+		name:      "ok with delay",
+		query:     "dropack?delay=5s",
+		wantCode:  okIfEnabled,
+		wantArmed: 1,
+	}, {
+		name:     "unknown point",
+		query:    "nonsense",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad count",
+		query:    "dropack?count=x",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "negative count",
+		query:    "dropack?count=-1",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad delay",
+		query:    "dropack?delay=5",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad clear",
+		query:    "dropack?clear=maybe",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:      "clear",
+		query:     "dropack?clear=true",
+		wantCode:  http.StatusOK,
+		wantArmed: 0,
+	}}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/fault/"+test.query, nil)
+		r.RemoteAddr = "localhost"
+
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiFault returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		res := new(FaultsResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: unexpected response %v: %v", test.name, w.Body.String(), err)
+		}
+		if len(res.Armed) != test.wantArmed {
+			t.Fatalf("%q: expected %d armed faults, got %d", test.name, test.wantArmed, len(res.Armed))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/faults", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiFaults returned code %d", w.Code)
+	}
+	res := new(FaultsResult)
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("unexpected response %v: %v", w.Body.String(), err)
+	}
+	if res.Enabled != fault.Enabled {
+		t.Fatalf("wrong enabled flag")
+	}
+}
+
+func TestAPITimeMarshalJSON(t *testing.T) {
+	now := APITime{time.Now()}
+	b, err := json.Marshal(now)
+	if err != nil {
+		t.Fatalf("unable to marshal api time: %v", err)
+	}
+	var res APITime
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("unable to unmarshal api time: %v", err)
+	}
+	if !res.Equal(now.Time) {
+		t.Fatal("unmarshalled time not equal")
+	}
+}
+
+type TStandby struct {
+	core       SvrCore
+	promoteErr error
+	promoted   bool
+}
+
+func (s *TStandby) Promote() (SvrCore, error) {
+	if s.promoteErr != nil {
+		return nil, s.promoteErr
+	}
+	s.promoted = true
+	return s.core, nil
+}
+
+func (s *TStandby) StandbyStatus() *StandbyStatus {
+	return &StandbyStatus{
+		Standby:  true,
+		Promoted: s.promoted,
+	}
+}
+
+func TestStandby(t *testing.T) {
+	standby := &TStandby{
+		core:       &TCore{markets: make(map[string]*TMarket)},
+		promoteErr: errors.New("no promotion"),
+	}
+	srv := &Server{
+		standby: standby,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/standby", srv.apiStandby)
+	mux.Post("/promote", srv.apiPromote)
+	mux.With(srv.coreMiddleware).Get("/markets", srv.apiMarkets)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		r, _ := http.NewRequest(method, "https://localhost/"+path, nil)
+		r.RemoteAddr = "localhost"
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		return request("GET", path)
+	}
+	promote := func() *httptest.ResponseRecorder {
+		t.Helper()
+		return request("POST", "promote")
+	}
+	checkStatus := func(wantPromoted bool) {
+		t.Helper()
+		w := get("standby")
+		if w.Code != http.StatusOK {
+			t.Fatalf("standby returned code %d", w.Code)
+		}
+		status := new(StandbyStatus)
+		if err := json.Unmarshal(w.Body.Bytes(), status); err != nil {
+			t.Fatalf("failed to unmarshal standby status: %v", err)
+		}
+		if !status.Standby || status.Promoted != wantPromoted {
+			t.Fatalf("wrong standby status %+v", status)
+		}
+	}
+
+	// Routes requiring the DEX are unavailable until promotion.
+	checkStatus(false)
+	if w := get("markets"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("markets returned code %d before promotion, expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// Promotion is not a GET request.
+	if w := get("promote"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET promote returned code %d, expected %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	checkStatus(false)
+
+	// Promotion error, e.g. the primary still holds the database lease.
+	if w := promote(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("promote returned code %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+	checkStatus(false)
+
+	// Promote.
+	standby.promoteErr = nil
+	if w := promote(); w.Code != http.StatusOK {
+		t.Fatalf("promote returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	checkStatus(true)
+	if w := get("markets"); w.Code != http.StatusOK {
+		t.Fatalf("markets returned code %d after promotion, expected %d", w.Code, http.StatusOK)
+	}
+
+	// Already promoted.
+	if w := promote(); w.Code != http.StatusBadRequest {
+		t.Fatalf("second promote returned code %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Not a standby server.
+	srv = &Server{core: standby.core}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "https://localhost/promote", nil)
+	srv.apiPromote(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("promote of primary returned code %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDelist(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				running: true,
+				dur:     1234,
+				suspend: &market.SuspendEpoch{},
+			},
+		},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Delete("/market/{"+marketNameKey+"}", srv.apiDelist)
+
+	tests := []struct {
+		name, mkt string
+		delistErr error
+		wantCode  int
+	}{{
+		name:     "ok",
+		mkt:      "dcr_btc",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "ok, case insensitive",
+		mkt:      "DCR_BTC",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "unknown market",
+		mkt:      "dcr_ltc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:      "delist error",
+		mkt:       "dcr_btc",
+		delistErr: errors.New("already delisting"),
+		wantCode:  http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		core.delistErr = test.delistErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("DELETE", "https://localhost/market/"+test.mkt, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiDelist returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		res := new(DelistResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: failed to unmarshal result: %v", test.name, err)
+		}
+		if res.Market != "dcr_btc" {
+			t.Errorf("%q: wrong market %q", test.name, res.Market)
+		}
+	}
+}
+
+func TestForceEpoch(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				running: true,
+				dur:     1234,
+			},
+			"dcr_ltc": {
+				dur: 1234,
+			},
+		},
+		forced: &market.ForcedEpoch{
+			Epoch:   5,
+			Orders:  1,
+			Revoked: []order.OrderID{{0x01}},
+		},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Post("/market/{"+marketNameKey+"}/forceepoch", srv.apiForceEpoch)
+
+	tests := []struct {
+		name, mkt, query string
+		forceErr         error
+		wantCode         int
+		wantDryRun       bool
+	}{{
+		name:       "ok, dry run by default",
+		mkt:        "dcr_btc",
+		wantCode:   http.StatusOK,
+		wantDryRun: true,
+	}, {
+		name:     "ok",
+		mkt:      "dcr_btc",
+		query:    "?dryrun=false&epoch=5&skip=true",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "no epoch",
+		mkt:      "dcr_btc",
+		query:    "?dryrun=false",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad epoch",
+		mkt:      "dcr_btc",
+		query:    "?dryrun=false&epoch=x",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad dryrun",
+		mkt:      "dcr_btc",
+		query:    "?dryrun=maybe",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad skip",
+		mkt:      "dcr_btc",
+		query:    "?skip=maybe",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "unknown market",
+		mkt:      "btc_ltc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "market not running",
+		mkt:      "dcr_ltc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "force error",
+		mkt:      "dcr_btc",
+		query:    "?dryrun=false&epoch=4",
+		forceErr: errors.New("epoch 4 is not the stalled epoch 5"),
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		core.forceErr = test.forceErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "https://localhost/market/"+test.mkt+"/forceepoch"+test.query, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiForceEpoch returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if core.forceDryRun != test.wantDryRun {
+			t.Fatalf("%q: expected dry run %v", test.name, test.wantDryRun)
+		}
+		res := new(ForceEpochResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: failed to unmarshal result: %v", test.name, err)
+		}
+		if res.Market != "dcr_btc" || res.Epoch != 5 || res.DryRun != test.wantDryRun {
+			t.Errorf("%q: unexpected result %+v", test.name, res)
+		}
+		if !test.wantDryRun && (!res.Skipped || len(res.Revoked) != 1) {
+			t.Errorf("%q: unexpected result %+v", test.name, res)
+		}
+	}
+}
+
+func tTimings() map[string]*market.Timings {
+	et := &market.EpochTiming{
+		Epoch:  7,
+		Orders: 2,
+	}
+	et.Stages[market.StagePreimages] = 300 * time.Millisecond
+	et.Stages[market.StageMatching] = 20 * time.Millisecond
+	timings := &market.Timings{
+		Recent: []*market.EpochTiming{et},
+	}
+	timings.Stages[market.StagePreimages] = market.StageTiming{
+		Count: 2,
+		Total: 500 * time.Millisecond,
+		Max:   300 * time.Millisecond,
+	}
+	return map[string]*market.Timings{"dcr_btc": timings}
+}
+
+func TestTimings(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				running: true,
+				dur:     1234,
+			},
+		},
+		timings: tTimings(),
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/market/{"+marketNameKey+"}/timings", srv.apiTimings)
+
+	// Unknown market.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/market/dcr_ltc/timings", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("apiTimings returned code %d for unknown market, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "https://localhost/market/DCR_BTC/timings", nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiTimings returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	res := new(MarketTimings)
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if res.Market != "dcr_btc" || res.EpochDuration != 1234 || len(res.Stages) != market.NumEpochStages {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	preimages := res.Stages[market.StagePreimages]
+	if preimages.Stage != "preimages" || preimages.Count != 2 || preimages.Avg != 250 || preimages.Max != 300 {
+		t.Fatalf("unexpected preimages stage timing %+v", preimages)
+	}
+	if len(res.Recent) != 1 || res.Recent[0].Total != 320 || res.Recent[0].Stages["matching"] != 20 {
+		t.Fatalf("unexpected recent timings %+v", res.Recent)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := &Server{
+		core: &TCore{
+			timings: tTimings(),
+			rejects: []*comms.RejectCount{{Route: "limit", Reason: "schema", Count: 3}},
+			clients: 4,
+			failures: []*swap.SwapFailures{{
+				Base:   42,
+				Quote:  0,
+				Status: order.MakerSwapCast,
+				Count:  2,
+			}},
+		},
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/metrics", nil)
+	r.RemoteAddr = "localhost"
+	srv.apiMetrics(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiMetrics returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`dcrdex_epoch_stage_seconds_sum{market="dcr_btc",stage="preimages"} 0.5`,
+		`dcrdex_epoch_stage_seconds_count{market="dcr_btc",stage="preimages"} 2`,
+		`dcrdex_epoch_stage_max_seconds{market="dcr_btc",stage="preimages"} 0.3`,
+		`dcrdex_epoch_last_seconds{market="dcr_btc"} 0.32`,
+		`dcrdex_comms_rejected_requests_total{route="limit",reason="schema"} 3`,
+		`dcrdex_connected_clients 4`,
+		`dcrdex_swap_failures_total{market="dcr_btc",status="MakerSwapCast"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestActivity(t *testing.T) {
+	srv := &Server{
+		core: &TCore{
+			clients: 3,
+			active:  5,
+			failures: []*swap.SwapFailures{{
+				Base:   42,
+				Quote:  0,
+				Status: order.NewlyMatched,
+				Count:  1,
+			}, {
+				Base:   42,
+				Quote:  0,
+				Status: order.MakerRedeemed,
+				Count:  2,
+			}},
+		},
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/activity", nil)
+	r.RemoteAddr = "localhost"
+	srv.apiActivity(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apiActivity returned code %d, expected %d", w.Code, http.StatusOK)
+	}
+	res := new(Activity)
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	want := &Activity{
+		ConnectedClients: 3,
+		ActiveMatches:    5,
+		SwapFailures: []*SwapFailureCount{
+			{Market: "dcr_btc", Status: "NewlyMatched", Count: 1},
+			{Market: "dcr_btc", Status: "MakerRedeemed", Count: 2},
+		},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("unexpected activity %+v", res)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	core := &TCore{
+		drained: make(chan struct{}),
+	}
+	shutdown := make(chan struct{}, 2)
+	srv := &Server{
+		core: core,
+		shutdown: func() {
+			shutdown <- struct{}{}
+		},
+	}
+	mux := chi.NewRouter()
+	mux.Post("/shutdown", srv.apiShutdown)
+
+	request := func() *ShutdownResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "https://localhost/shutdown", nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("apiShutdown returned code %d, expected %d", w.Code, http.StatusOK)
+		}
+		res := new(ShutdownResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("failed to unmarshal result: %v", err)
+		}
+		return res
+	}
+
+	// Still draining.
+	res := request()
+	if !res.Draining || res.Drained {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	res = request()
+	if !res.Draining || res.Drained {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if core.drains != 2 {
+		t.Fatalf("expected 2 drain calls, got %d", core.drains)
+	}
+	select {
+	case <-shutdown:
+		t.Fatalf("shut down before drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Drained. Shutdown is called only once.
+	close(core.drained)
+	select {
+	case <-shutdown:
+	case <-time.After(time.Second):
+		t.Fatalf("not shut down after drained")
+	}
+	res = request()
+	if !res.Draining || !res.Drained {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	select {
+	case <-shutdown:
+		t.Fatalf("shut down more than once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPurgeStale(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				running: true,
+				dur:     1234,
+			},
+			"dcr_ltc": {
+				dur: 1234,
+			},
+		},
+		purged: []order.OrderID{{0x01}, {0x02}},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Post("/market/{"+marketNameKey+"}/purgestale", srv.apiPurgeStale)
+
+	tests := []struct {
+		name, mkt, query string
+		wantCode         int
+	}{{
+		name:     "ok",
+		mkt:      "dcr_btc",
+		query:    "?age=720h",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "no age",
+		mkt:      "dcr_btc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "bad age",
+		mkt:      "dcr_btc",
+		query:    "?age=30days",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "negative age",
+		mkt:      "dcr_btc",
+		query:    "?age=-1h",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "unknown market",
+		mkt:      "btc_ltc",
+		query:    "?age=720h",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "market not running",
+		mkt:      "dcr_ltc",
+		query:    "?age=720h",
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "https://localhost/market/"+test.mkt+"/purgestale"+test.query, nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiPurgeStale returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if core.purgeAge != 720*time.Hour {
+			t.Fatalf("%q: wrong age %v", test.name, core.purgeAge)
+		}
+		res := new(PurgeStaleResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: failed to unmarshal result: %v", test.name, err)
+		}
+		if res.Market != "dcr_btc" || res.MaxAge != "720h0m0s" || len(res.Revoked) != 2 {
+			t.Errorf("%q: unexpected result %+v", test.name, res)
+		}
+	}
+}
+
+func TestVerifyBook(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				dur: 1234,
+			},
+		},
+		verified: &market.BookVerification{
+			Market:       "dcr_btc",
+			BookedOrders: 2,
+			StoredOrders: 1,
+			Discrepancies: []*market.BookDiscrepancy{{
+				OrderID: "01",
+				Issue:   "order is on the book but not stored as booked",
+			}},
+		},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/market/{"+marketNameKey+"}/verify", srv.apiVerifyBook)
+
+	tests := []struct {
+		name, mkt string
+		verifyErr error
+		wantCode  int
+	}{{
+		name:     "ok",
+		mkt:      "dcr_btc",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "unknown market",
+		mkt:      "btc_ltc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:      "verify error",
+		mkt:       "dcr_btc",
+		verifyErr: errors.New("db error"),
+		wantCode:  http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.verifyErr = test.verifyErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/market/"+test.mkt+"/verify", nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiVerifyBook returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		res := new(market.BookVerification)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: failed to unmarshal result: %v", test.name, err)
+		}
+		if res.BookedOrders != 2 || len(res.Discrepancies) != 1 {
+			t.Errorf("%q: unexpected result %+v", test.name, res)
+		}
+	}
+}
+
+func TestExportMarket(t *testing.T) {
+	core := &TCore{
+		markets: map[string]*TMarket{
+			"dcr_btc": {
+				dur: 1234,
+			},
+		},
+		exported: &market.Export{
+			Version:    market.ExportVersion,
+			Market:     "dcr_btc",
+			BookOrders: []dex.Bytes{{0x01}, {0x02}},
+			Matches:    1,
+			Swaps:      dex.Bytes{0x03},
+		},
+	}
+	srv := &Server{
+		core: core,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/market/{"+marketNameKey+"}/export", srv.apiExportMarket)
+
+	tests := []struct {
+		name, mkt string
+		exportErr error
+		wantCode  int
+	}{{
+		name:     "ok",
+		mkt:      "dcr_btc",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "unknown market",
+		mkt:      "btc_ltc",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:      "export error",
+		mkt:       "dcr_btc",
+		exportErr: errors.New("encoding error"),
+		wantCode:  http.StatusInternalServerError,
+	}}
+	for _, test := range tests {
+		core.exportErr = test.exportErr
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/market/"+test.mkt+"/export", nil)
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: apiExportMarket returned code %d, expected %d", test.name, w.Code, test.wantCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		res := new(market.Export)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: failed to unmarshal result: %v", test.name, err)
+		}
+		if res.Version != market.ExportVersion || len(res.BookOrders) != 2 || res.Matches != 1 {
+			t.Errorf("%q: unexpected result %+v", test.name, res)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	tracer := trace.NewTracer(100)
+	srv := &Server{tracer: tracer}
+	mux := chi.NewRouter()
+	mux.Get("/trace/{"+traceIDKey+"}", srv.apiTrace)
+
+	cid := trace.NewID()
+	oid := "ab" + strings.Repeat("00", 31)
+	tracer.Logf(log, "COMM", cid, "limit request")
+	tracer.Link(cid, oid)
+	tracer.KeyLogf(log, "MKT", oid, "order booked")
+
+	tests := []struct {
+		name, id   string
+		wantEvents int
+	}{{
+		name:       "correlation ID",
+		id:         string(cid),
+		wantEvents: 2,
+	}, {
+		name:       "order ID",
+		id:         strings.ToUpper(oid),
+		wantEvents: 2,
+	}, {
+		name: "unknown",
+		id:   "deadbeef",
+	}}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "https://localhost/trace/"+test.id, nil)
+		r.RemoteAddr = "localhost"
+
+		mux.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: apiTrace returned code %d", test.name, w.Code)
+		}
+		res := new(TraceResult)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("%q: unexpected response %v: %v", test.name, w.Body.String(), err)
+		}
+		if len(res.Events) != test.wantEvents {
+			t.Fatalf("%q: expected %d events, got %d", test.name, test.wantEvents, len(res.Events))
+		}
+	}
+
+	// Tracing is disabled without a tracer.
+	srv.tracer = nil
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "https://localhost/trace/"+string(cid), nil)
+	r.RemoteAddr = "localhost"
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("apiTrace returned code %d with tracing disabled", w.Code)
+	}
+}
+
+func TestBulkPenalties(t *testing.T) {
+	acct := func(b byte, rule account.Rule) *db.Account {
+		var aid account.AccountID
+		aid[0] = b
+		return &db.Account{AccountID: aid, BrokenRule: rule}
+	}
+	open1, open2 := acct(1, account.NoRule), acct(2, account.NoRule)
+	closed := acct(3, account.FailureToAct)
+	unknown := acct(4, account.NoRule)
+	core := &TCore{
+		acctsByID: map[account.AccountID]*db.Account{
+			open1.AccountID:  open1,
+			open2.AccountID:  open2,
+			closed.AccountID: closed,
+		},
+	}
+	srv := &Server{core: core}
+	mux := chi.NewRouter()
+	mux.Post("/accounts/ban", srv.apiBulkBan)
+	mux.Post("/accounts/forgive", srv.apiBulkForgive)
+
+	do := func(path string, req *BulkPenaltyRequest) (int, *BulkPenaltyResults) {
+		t.Helper()
+		b, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "https://localhost"+path, strings.NewReader(string(b)))
+		r.RemoteAddr = "localhost"
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		res := new(BulkPenaltyResults)
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("unexpected response %v: %v", w.Body.String(), err)
+		}
+		return w.Code, res
+	}
+	ids := func(accts ...*db.Account) []string {
+		s := make([]string, 0, len(accts))
+		for _, a := range accts {
+			s = append(s, a.AccountID.String())
+		}
+		return s
+	}
+	statuses := func(res *BulkPenaltyResults) string {
+		s := make([]string, 0, len(res.Results))
+		for _, r := range res.Results {
+			s = append(s, r.Status)
+		}
+		return strings.Join(s, ",")
+	}
+
+	// Nothing is changed unless every account is valid and known.
+	for _, req := range []*BulkPenaltyRequest{
+		{Rule: 1},
+		{Accounts: []string{"abc"}, Rule: 1},
+		{Accounts: ids(open1, unknown), Rule: 1},
+		{Accounts: ids(open1), Rule: uint8(account.MaxRule)},
+	} {
+		if code, _ := do("/accounts/ban", req); code == http.StatusOK {
+			t.Fatalf("no error for bad request %+v", req)
+		}
+	}
+	if len(core.penalized) != 0 {
+		t.Fatalf("accounts penalized for bad requests")
+	}
+
+	// Ban, ignoring duplicates and closed accounts.
+	_, res := do("/accounts/ban", &BulkPenaltyRequest{Accounts: ids(open1, open2, open1, closed), Rule: 1, Reason: "wash trading"})
+	if got := statuses(res); got != "banned,banned,unchanged" || len(core.penalized) != 2 {
+		t.Fatalf("wrong ban results %s", got)
+	}
+
+	// Per-account failures are reported.
+	core.forgiveErr = errors.New("db failure")
+	_, res = do("/accounts/forgive", &BulkPenaltyRequest{Accounts: ids(open1, closed)})
+	if got := statuses(res); got != "unchanged,failed" || res.Results[1].Error != "db failure" {
+		t.Fatalf("wrong forgive results %s", got)
+	}
+	core.forgiveErr = nil
+	_, res = do("/accounts/forgive", &BulkPenaltyRequest{Accounts: ids(closed)})
+	if got := statuses(res); got != "forgiven" {
+		t.Fatalf("wrong forgive results %s", got)
 	}
 }
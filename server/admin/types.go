@@ -2,6 +2,9 @@ package admin
 
 import (
 	"time"
+
+	"decred.org/dcrdex/server/fault"
+	"decred.org/dcrdex/server/trace"
 )
 
 // MarketStatus summarizes the operational status of a market.
@@ -13,6 +16,41 @@ type MarketStatus struct {
 	StartEpoch    int64  `json:"startepoch"`
 	SuspendEpoch  int64  `json:"finalepoch,omitempty"`
 	PersistBook   *bool  `json:"persistbook,omitempty"`
+	// EpochBacklog is the number of closed epochs waiting to be matched.
+	EpochBacklog int `json:"epochbacklog,omitempty"`
+	// ShedOrders is the number of orders refused because the market was
+	// overloaded.
+	ShedOrders uint64 `json:"shedorders,omitempty"`
+	// MinOrderQty is the current minimum order quantity, in units of the
+	// base asset, from the market's minimum order value.
+	MinOrderQty uint64 `json:"minorderqty,omitempty"`
+}
+
+// StageTiming summarizes the time spent in a stage of epoch processing. The
+// times are in milliseconds.
+type StageTiming struct {
+	Stage string  `json:"stage"`
+	Count uint64  `json:"count"`
+	Avg   float64 `json:"avgms"`
+	Max   float64 `json:"maxms"`
+}
+
+// EpochTiming is the time spent in each stage of processing an epoch. The
+// times are in milliseconds.
+type EpochTiming struct {
+	Epoch  int64              `json:"epoch"`
+	Orders int                `json:"orders"`
+	Stages map[string]float64 `json:"stages"`
+	Total  float64            `json:"totalms"`
+}
+
+// MarketTimings are the epoch processing timings of a market. Recent are the
+// timings of the most recent epochs, oldest first.
+type MarketTimings struct {
+	Market        string         `json:"market"`
+	EpochDuration uint64         `json:"epochlen"`
+	Stages        []*StageTiming `json:"stages"`
+	Recent        []*EpochTiming `json:"recent"`
 }
 
 // APITime marshals and unmarshals a time value in time.RFC3339Nano format.
@@ -30,6 +68,66 @@ type SuspendResult struct {
 	SuspendTime APITime `json:"supendtime"`
 }
 
+// ResumeResult describes the result of a market resume request. The market
+// resumes with StartEpoch, which begins at StartTime.
+type ResumeResult struct {
+	Market     string  `json:"market"`
+	StartEpoch int64   `json:"startepoch"`
+	StartTime  APITime `json:"starttime"`
+}
+
+// DelistResult describes the result of a market delist request. The market is
+// suspended after FinalEpoch, at SuspendTime, and is removed after its active
+// swaps have settled.
+type DelistResult struct {
+	Market      string  `json:"market"`
+	FinalEpoch  int64   `json:"finalepoch"`
+	SuspendTime APITime `json:"suspendtime"`
+}
+
+// SwapFailureCount is the number of matches on a market that were revoked
+// because a party failed to act at a match status.
+type SwapFailureCount struct {
+	Market string `json:"market"`
+	Status string `json:"status"`
+	Count  uint64 `json:"count"`
+}
+
+// Activity is a summary of the DEX's current activity. SwapFailures are counted
+// since startup.
+type Activity struct {
+	ConnectedClients int                 `json:"connectedclients"`
+	ActiveMatches    int                 `json:"activematches"`
+	SwapFailures     []*SwapFailureCount `json:"swapfailures"`
+}
+
+// ForceEpochResult describes a closed epoch that is stuck in preimage
+// collection. For a dry run, nothing is done to the epoch. Otherwise, Revoked
+// lists the orders that were revoked without being matched.
+type ForceEpochResult struct {
+	Market  string   `json:"market"`
+	Epoch   int64    `json:"epoch"`
+	Orders  int      `json:"orders"`
+	DryRun  bool     `json:"dryrun"`
+	Skipped bool     `json:"skipped"`
+	Revoked []string `json:"revoked,omitempty"`
+}
+
+// ShutdownResult is the response to a graceful shutdown request. Drained is
+// true when all active swaps are already done.
+type ShutdownResult struct {
+	Draining bool `json:"draining"`
+	Drained  bool `json:"drained"`
+}
+
+// PurgeStaleResult lists the booked orders that were revoked for being older
+// than MaxAge.
+type PurgeStaleResult struct {
+	Market  string   `json:"market"`
+	MaxAge  string   `json:"maxage"`
+	Revoked []string `json:"revoked"`
+}
+
 // RFC3339Milli is the RFC3339 time formatting with millisecond precision.
 const RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
 
@@ -60,3 +158,180 @@ type BanResult struct {
 	BrokenRule byte    `json:"brokenrule"`
 	BanTime    APITime `json:"bantime"`
 }
+
+// BulkPenaltyRequest is the body of the bulk ban and forgive requests. Accounts
+// are hex-encoded account IDs. Rule is required for a ban. The optional Reason
+// is sent to each client.
+type BulkPenaltyRequest struct {
+	Accounts []string `json:"accounts"`
+	Rule     uint8    `json:"rule,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// The possible BulkPenaltyResult statuses.
+const (
+	BulkBanned    = "banned"
+	BulkForgiven  = "forgiven"
+	BulkUnchanged = "unchanged"
+	BulkFailed    = "failed"
+)
+
+// BulkPenaltyResult is the outcome of a bulk ban or forgive for one account.
+// Error is set if the Status is BulkFailed.
+type BulkPenaltyResult struct {
+	AccountID string `json:"accountid"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkPenaltyResults holds the results of a bulk ban or forgive.
+type BulkPenaltyResults struct {
+	Time    APITime              `json:"time"`
+	Results []*BulkPenaltyResult `json:"results"`
+}
+
+// AppealResult holds the result of a decision on a penalty appeal.
+type AppealResult struct {
+	AccountID    string  `json:"accountid"`
+	Accepted     bool    `json:"accepted"`
+	Reason       string  `json:"reason,omitempty"`
+	DecisionTime APITime `json:"decisiontime"`
+}
+
+// RegistrationResult describes how 'register' requests for an account are
+// decided. Override is the admin override, if any. Approved and Reason are the
+// cached decision of the registration hook, if any.
+type RegistrationResult struct {
+	AccountID   string   `json:"accountid"`
+	Override    *bool    `json:"override,omitempty"`
+	Approved    *bool    `json:"approved,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	CacheExpiry *APITime `json:"cacheexpiry,omitempty"`
+}
+
+// AccountNotesUpdate is the body of a request to edit an account's operator
+// notes and tags. A nil field leaves the current value unchanged.
+type AccountNotesUpdate struct {
+	Notes *string   `json:"notes"`
+	Tags  *[]string `json:"tags"`
+}
+
+// PreimageMissReport summarizes an account's missed preimage requests. The
+// misses are counted by reason, and ConnectedMisses counts the misses where the
+// client was still connected when the miss was determined. MeanLatencyMs is
+// the mean round-trip time over the misses with a measured latency.
+type PreimageMissReport struct {
+	AccountID       string          `json:"accountid"`
+	Misses          int             `json:"misses"`
+	Timeouts        int             `json:"timeouts"`
+	Disconnects     int             `json:"disconnects"`
+	Invalid         int             `json:"invalid"`
+	ConnectedMisses int             `json:"connectedmisses"`
+	MeanLatencyMs   int64           `json:"meanlatencyms"`
+	Records         []*PreimageMiss `json:"records"`
+}
+
+// PreimageMiss describes the circumstances of a missed preimage request.
+type PreimageMiss struct {
+	OrderID    string  `json:"orderid"`
+	Commitment string  `json:"commitment"`
+	Market     string  `json:"market"`
+	EpochIdx   int64   `json:"epochidx"`
+	EpochDur   int64   `json:"epochdur"`
+	Reason     string  `json:"reason"`
+	Connected  bool    `json:"connected"`
+	LatencyMs  int64   `json:"latencyms"`
+	Time       APITime `json:"time"`
+}
+
+// FaultsResult lists the armed fault injection points. Enabled is false unless
+// dcrdex was built with the fault build tag.
+type FaultsResult struct {
+	Enabled bool           `json:"enabled"`
+	Armed   []*fault.Fault `json:"armed"`
+}
+
+// TraceResult is the log events traced for a client request correlation ID, or
+// for an order or match ID.
+type TraceResult struct {
+	ID     string         `json:"id"`
+	Events []*trace.Event `json:"events"`
+}
+
+// StandbyStatus describes a standby server. Standby is false for a server that
+// was not started in standby mode. StateFile is the primary's swap state
+// checkpoint that will be restored on promotion, and StateTime is when it was
+// written.
+type StandbyStatus struct {
+	Standby     bool     `json:"standby"`
+	Promoted    bool     `json:"promoted"`
+	Promoting   bool     `json:"promoting"`
+	StateFile   string   `json:"statefile,omitempty"`
+	StateTime   *APITime `json:"statetime,omitempty"`
+	LiveMatches int      `json:"livematches"`
+	StateError  string   `json:"stateerror,omitempty"`
+}
+
+// APIKeyRequest is the body of a request to issue an HTTP data API key. The key
+// is valid for all markets if Markets is empty, and is limited to RateLimit
+// requests per minute.
+type APIKeyRequest struct {
+	Label     string   `json:"label"`
+	Markets   []string `json:"markets"`
+	RateLimit uint32   `json:"ratelimit"`
+}
+
+// PoWResult is the proof-of-work difficulty required of 'register' and
+// 'connect' requests, in leading zero bits. Zero means no proof of work is
+// required.
+type PoWResult struct {
+	Difficulty uint8 `json:"difficulty"`
+}
+
+// Trade report side codes, as in the FIX Side field.
+const (
+	TradeSideBuy  = "1"
+	TradeSideSell = "2"
+)
+
+// TradeReport is a report of the trades completed in a period, for operators
+// required to report trades to a regulator. Each trade is reported in the
+// layout of a FIX TradeCaptureReport, with JSON field names from the FIX field
+// names. Quantities and prices are in conventional units, e.g. DCR rather than
+// atoms, with the exact amounts in atoms alongside. Times are UTC in the ISO
+// 8601 format.
+type TradeReport struct {
+	Start  string          `json:"start"`
+	End    string          `json:"end"`
+	Trades []*TradeCapture `json:"trades"`
+}
+
+// TradeCapture is the report of a trade, which is a match with a completed
+// swap. The trade is made at the end of the epoch in which the orders were
+// matched, and is settled when the taker redeems.
+type TradeCapture struct {
+	TradeReportID string              `json:"tradeReportID"`
+	Symbol        string              `json:"symbol"`
+	SecurityType  string              `json:"securityType"`
+	Currency      string              `json:"currency"`
+	LastQty       float64             `json:"lastQty"`
+	LastPx        float64             `json:"lastPx"`
+	GrossTradeAmt float64             `json:"grossTradeAmt"`
+	QtyAtoms      uint64              `json:"qtyAtoms"`
+	RateAtoms     uint64              `json:"rateAtoms"`
+	GrossAtoms    uint64              `json:"grossAtoms"`
+	TradeDate     string              `json:"tradeDate"`
+	TransactTime  string              `json:"transactTime"`
+	SettlDate     string              `json:"settlDate"`
+	SettlTime     string              `json:"settlTime"`
+	Sides         []*TradeCaptureSide `json:"sides"`
+}
+
+// TradeCaptureSide is a party to a trade, identified by its account ID. The
+// taker is the aggressor.
+type TradeCaptureSide struct {
+	Side               string `json:"side"`
+	OrderID            string `json:"orderID"`
+	PartyID            string `json:"partyID"`
+	AggressorIndicator bool   `json:"aggressorIndicator"`
+}
@@ -0,0 +1,117 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package btc
+
+import (
+	"decred.org/dcrdex/server/asset"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// maxScanBlocks is the most blocks that BatchConfirmations will scan for the
+// mempool transactions of the coins. If more blocks have been mined since the
+// transactions were last looked up, they are looked up individually.
+const maxScanBlocks = 6
+
+var _ asset.BatchConfirmer = (*Backend)(nil)
+
+// coinTXIO gets the TXIO of a coin created by the Backend, or nil if the coin
+// is of an unknown type.
+func coinTXIO(coin asset.Coin) *TXIO {
+	switch c := coin.(type) {
+	case *Contract:
+		return &c.TXIO
+	case *UTXO:
+		return &c.TXIO
+	case *Output:
+		return &c.TXIO
+	case *Input:
+		return &c.TXIO
+	}
+	return nil
+}
+
+// BatchConfirmations returns the number of confirmations for each of the
+// coins. A coin in a mined transaction is checked against the block cache, as
+// with its Confirmations method. Rather than requesting each transaction that
+// was in mempool at the last lookup, the blocks mined since are requested once
+// each and scanned for the transactions. This is part of the
+// asset.BatchConfirmer interface.
+func (btc *Backend) BatchConfirmations(coins []asset.Coin) ([]int64, []error) {
+	confs := make([]int64, len(coins))
+	errs := make([]error, len(coins))
+	tip := btc.blockCache.tip()
+
+	// Find the mempool transactions and the height to start the scan from.
+	pending := make(map[chainhash.Hash][]int)
+	scanFrom := tip.height + 1
+	for i, coin := range coins {
+		txio := coinTXIO(coin)
+		if txio == nil || txio.btc != btc || txio.height != 0 || txio.lastLookup == nil ||
+			*txio.lastLookup == tip.hash {
+			confs[i], errs[i] = coin.Confirmations()
+			continue
+		}
+		lastBlock, found := btc.blockCache.block(txio.lastLookup)
+		if !found || lastBlock.orphaned || lastBlock.height >= tip.height ||
+			tip.height-lastBlock.height > maxScanBlocks {
+			confs[i], errs[i] = coin.Confirmations()
+			continue
+		}
+		if lastBlock.height+1 < scanFrom {
+			scanFrom = lastBlock.height + 1
+		}
+		pending[txio.tx.hash] = append(pending[txio.tx.hash], i)
+	}
+	if len(pending) == 0 {
+		return confs, errs
+	}
+
+	// Scan the new mainchain blocks for the transactions.
+	mined := make(map[chainhash.Hash]*cachedBlock)
+	scanned := true
+	for height := scanFrom; height <= tip.height && len(mined) < len(pending); height++ {
+		blk, found := btc.blockCache.atHeight(height)
+		if !found {
+			btc.log.Debugf("No mainchain block at height %d to scan. Checking %d transactions individually.",
+				height, len(pending))
+			scanned = false
+			break
+		}
+		block, err := btc.node.GetBlockVerbose(&blk.hash)
+		if err != nil {
+			btc.log.Debugf("Error retrieving block %s to scan: %v. Checking %d transactions individually.",
+				blk.hash, err, len(pending))
+			scanned = false
+			break
+		}
+		for _, txid := range block.Tx {
+			txHash, err := chainhash.NewHashFromStr(txid)
+			if err != nil {
+				continue
+			}
+			if _, found := pending[*txHash]; found {
+				mined[*txHash] = blk
+			}
+		}
+	}
+
+	tipHash := tip.hash
+	for txHash, idxs := range pending {
+		blk := mined[txHash]
+		for _, i := range idxs {
+			if !scanned {
+				confs[i], errs[i] = coins[i].Confirmations()
+				continue
+			}
+			txio := coinTXIO(coins[i])
+			txio.lastLookup = &tipHash
+			if blk != nil {
+				txio.height = blk.height
+				txio.blockHash = blk.hash
+				confs[i] = int64(tip.height - blk.height + 1)
+			}
+		}
+	}
+	return confs, errs
+}
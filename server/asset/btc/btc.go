@@ -18,6 +18,7 @@ import (
 	"decred.org/dcrdex/dex"
 	dexbtc "decred.org/dcrdex/dex/networks/btc"
 	"decred.org/dcrdex/server/asset"
+	"decred.org/dcrdex/server/fault"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -100,6 +101,7 @@ type Backend struct {
 
 // Check that Backend satisfies the Backend interface.
 var _ asset.Backend = (*Backend)(nil)
+var _ asset.Preflighter = (*Backend)(nil)
 
 // NewBackend is the exported constructor by which the DEX will import the
 // backend. The configPath can be an empty string, in which case the standard
@@ -167,6 +169,32 @@ func NewBTCClone(name, configPath string, logger dex.Logger, network dex.Network
 	return btc, nil
 }
 
+// Preflight checks that the node has the transaction index enabled, which is
+// required to retrieve arbitrary transactions. Part of the asset.Preflighter
+// interface.
+func (btc *Backend) Preflight() error {
+	blockHash, err := btc.node.GetBlockHash(1)
+	if err != nil {
+		return fmt.Errorf("error getting block 1 hash: %v", err)
+	}
+	block, err := btc.node.GetBlockVerbose(blockHash)
+	if err != nil {
+		return fmt.Errorf("error getting block %s: %v", blockHash, err)
+	}
+	if len(block.Tx) == 0 {
+		return fmt.Errorf("no transactions in block %s", blockHash)
+	}
+	txHash, err := chainhash.NewHashFromStr(block.Tx[0])
+	if err != nil {
+		return fmt.Errorf("invalid tx hash %q in block %s: %v", block.Tx[0], blockHash, err)
+	}
+	if _, err = btc.node.GetRawTransactionVerbose(txHash); err != nil {
+		return fmt.Errorf("failed to retrieve transaction %s, %s node must be run with txindex=1: %v",
+			txHash, btc.name, err)
+	}
+	return nil
+}
+
 // Contract is part of the asset.Backend interface. An asset.Contract is an
 // output that has been validated as a swap contract for the passed redeem
 // script. A spendable output is one that can be spent in the next block. Every
@@ -689,6 +717,10 @@ func (btc *Backend) Run(ctx context.Context) {
 		if err != nil {
 			btc.log.Errorf("error adding new best block to cache: %v", err)
 		}
+		if fault.Hit(fault.Reorg) {
+			btc.log.Warnf("Fault injection: reporting block %d as a reorg", block.Height)
+			reorg = true
+		}
 		btc.signalMtx.RLock()
 		btc.log.Debugf("Notifying %d %s asset consumers of new block at height %d",
 			len(btc.blockChans), btc.name, block.Height)
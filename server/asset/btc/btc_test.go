@@ -24,6 +24,7 @@ import (
 
 	"decred.org/dcrdex/dex"
 	dexbtc "decred.org/dcrdex/dex/networks/btc"
+	"decred.org/dcrdex/server/asset"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -1225,6 +1226,88 @@ func TestReorg(t *testing.T) {
 	}
 }
 
+// TestBatchConfirmations checks that mempool transactions are found by scanning
+// the new blocks, without looking up the transactions.
+func TestBatchConfirmations(t *testing.T) {
+	btc, shutdown := testBackend()
+	defer shutdown()
+
+	btc.blockCache.mtx.Lock()
+	cleanTestChain()
+	newBC := newBlockCache()
+	btc.blockCache.blocks = newBC.blocks
+	btc.blockCache.mainchain = newBC.mainchain
+	btc.blockCache.best = newBC.best
+	btc.blockCache.mtx.Unlock()
+
+	const tipHeight = uint32(50)
+	testAddBlockVerbose(nil, nil, 1, tipHeight)
+	time.Sleep(blockPollDelay)
+
+	// Two mempool transactions.
+	addMempoolUTXO := func() (*UTXO, *chainhash.Hash) {
+		msg := testMakeMsgTx(false)
+		txHash := randomHash()
+		testAddTxOut(msg.tx, msg.vout, txHash, nil, 0, 0)
+		utxo, err := btc.utxo(txHash, msg.vout, nil)
+		if err != nil {
+			t.Fatalf("error getting mempool utxo: %v", err)
+		}
+		return utxo, txHash
+	}
+	minedUTXO, minedHash := addMempoolUTXO()
+	mempoolUTXO, mempoolHash := addMempoolUTXO()
+	coins := []asset.Coin{minedUTXO, mempoolUTXO}
+
+	// Mine one of them, and remove both from the node so that an individual
+	// lookup would fail.
+	blockHash := testAddBlockVerbose(nil, nil, 1, tipHeight+1)
+	testChainMtx.Lock()
+	testChain.blocks[*blockHash].Tx = []string{randomHash().String(), minedHash.String()}
+	delete(testChain.txRaws, *minedHash)
+	delete(testChain.txRaws, *mempoolHash)
+	testChainMtx.Unlock()
+	time.Sleep(blockPollDelay)
+
+	checkConfs := func(wantMined, wantMempool int64) {
+		t.Helper()
+		confs, errs := btc.BatchConfirmations(coins)
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("error for coin %d: %v", i, err)
+			}
+		}
+		if confs[0] != wantMined || confs[1] != wantMempool {
+			t.Fatalf("wrong confirmations. wanted [%d %d], got %v", wantMined, wantMempool, confs)
+		}
+	}
+	checkConfs(1, 0)
+	if minedUTXO.height != tipHeight+1 || minedUTXO.blockHash != *blockHash {
+		t.Fatalf("mined transaction block not recorded")
+	}
+
+	// Another block. The mined transaction is checked with the block cache, and
+	// the mempool transaction is found in the new block.
+	blockHash = testAddBlockVerbose(nil, nil, 1, tipHeight+2)
+	testChainMtx.Lock()
+	testChain.blocks[*blockHash].Tx = []string{mempoolHash.String()}
+	testChainMtx.Unlock()
+	time.Sleep(blockPollDelay)
+	checkConfs(2, 1)
+
+	// Without scannable blocks, the transactions are looked up individually.
+	mempoolUTXO, _ = addMempoolUTXO()
+	coins = []asset.Coin{mempoolUTXO}
+	testAddBlockVerbose(nil, nil, 1, tipHeight+3)
+	time.Sleep(blockPollDelay)
+	btc.blockCache.mtx.Lock()
+	delete(btc.blockCache.mainchain, tipHeight+3)
+	btc.blockCache.mtx.Unlock()
+	if _, errs := btc.BatchConfirmations(coins); errs[0] != nil {
+		t.Fatalf("error looking up transaction individually: %v", errs[0])
+	}
+}
+
 // TestAuxiliary checks the UTXO convenience functions like TxHash, Vout, and
 // TxID.
 func TestAuxiliary(t *testing.T) {
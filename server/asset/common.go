@@ -57,6 +57,25 @@ type Backend interface {
 	FeeRate() (uint64, error)
 }
 
+// Preflighter is an optional interface that a Backend may implement to check
+// that its node is configured as the backend requires, such as having the
+// transaction index enabled. Preflight is called after Setup but before the
+// backend is run.
+type Preflighter interface {
+	Preflight() error
+}
+
+// BatchConfirmer is an optional interface that a Backend may implement to check
+// the confirmations of many coins at once with fewer node requests than
+// calling each coin's Confirmations method, such as when the swap contracts
+// affected by a new block are audited.
+type BatchConfirmer interface {
+	// BatchConfirmations returns the number of confirmations and any error for
+	// each of the coins, which were created by the Backend. The results have
+	// the same meaning as the values returned by (Coin).Confirmations.
+	BatchConfirmations(coins []Coin) ([]int64, []error)
+}
+
 // Coin represents a transaction input or output.
 type Coin interface {
 	// Confirmations returns the number of confirmations for a Coin's
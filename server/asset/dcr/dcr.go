@@ -20,6 +20,7 @@ import (
 	"decred.org/dcrdex/dex"
 	dexdcr "decred.org/dcrdex/dex/networks/dcr"
 	"decred.org/dcrdex/server/asset"
+	"decred.org/dcrdex/server/fault"
 	"github.com/decred/dcrd/blockchain/stake/v2"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrjson/v3"
@@ -99,6 +100,7 @@ type Backend struct {
 
 // Check that Backend satisfies the Backend interface.
 var _ asset.Backend = (*Backend)(nil)
+var _ asset.Preflighter = (*Backend)(nil)
 
 // NewBackend is the exported constructor by which the DEX will import the
 // Backend. The provided context.Context should be canceled when the DEX
@@ -155,6 +157,32 @@ func NewBackend(configPath string, logger dex.Logger, network dex.Network) (*Bac
 	return dcr, nil
 }
 
+// Preflight checks that dcrd has the transaction index enabled, which is
+// required to retrieve arbitrary transactions. Part of the asset.Preflighter
+// interface.
+func (dcr *Backend) Preflight() error {
+	blockHash, err := dcr.node.GetBlockHash(1)
+	if err != nil {
+		return fmt.Errorf("error getting block 1 hash: %v", err)
+	}
+	block, err := dcr.node.GetBlockVerbose(blockHash, false)
+	if err != nil {
+		return fmt.Errorf("error getting block %s: %v", blockHash, err)
+	}
+	if len(block.Tx) == 0 {
+		return fmt.Errorf("no transactions in block %s", blockHash)
+	}
+	txHash, err := chainhash.NewHashFromStr(block.Tx[0])
+	if err != nil {
+		return fmt.Errorf("invalid tx hash %q in block %s: %v", block.Tx[0], blockHash, err)
+	}
+	if _, err = dcr.node.GetRawTransactionVerbose(txHash); err != nil {
+		return fmt.Errorf("failed to retrieve transaction %s, dcrd must be run with --txindex: %v",
+			txHash, err)
+	}
+	return nil
+}
+
 // InitTxSize is an asset.Backend method that must produce the max size of a
 // standardized atomic swap initialization transaction.
 func (btc *Backend) InitTxSize() uint32 {
@@ -485,6 +513,10 @@ func (dcr *Backend) Run(ctx context.Context) {
 		if err != nil {
 			dcr.log.Errorf("error adding new best block to cache: %v", err)
 		}
+		if fault.Hit(fault.Reorg) {
+			dcr.log.Warnf("Fault injection: reporting block %d as a reorg", block.Height)
+			reorg = true
+		}
 		dcr.signalMtx.Lock()
 		dcr.log.Debugf("Notifying %d dcr asset consumers of new block at height %d",
 			len(dcr.blockChans), block.Height)
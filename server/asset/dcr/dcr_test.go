@@ -1426,3 +1426,23 @@ func TestDriver_DecodeCoinID(t *testing.T) {
 		})
 	}
 }
+
+func TestPreflight(t *testing.T) {
+	cleanTestChain()
+	dcr := unconnectedDCR(testLogger)
+	dcr.node = testNode{}
+
+	// Block 1 with a coinbase transaction that is not indexed.
+	blockHash := testAddBlockVerbose(nil, 2, 1, 1)
+	coinbase := testMsgTxRegular(dcrec.STEcdsaSecp256k1).tx
+	txHash := coinbase.TxHash()
+	testChain.blocks[*blockHash].Tx = []string{txHash.String()}
+	if err := dcr.Preflight(); err == nil {
+		t.Fatalf("no error for missing transaction index")
+	}
+
+	testAddTxVerbose(coinbase, &txHash, blockHash, 1, 2)
+	if err := dcr.Preflight(); err != nil {
+		t.Fatalf("Preflight error: %v", err)
+	}
+}
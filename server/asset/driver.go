@@ -59,3 +59,11 @@ func Setup(name, configPath string, logger dex.Logger, network dex.Network) (Bac
 	}
 	return drv.Setup(configPath, logger, network)
 }
+
+// IsRegistered checks whether a driver is registered for the named asset.
+func IsRegistered(name string) bool {
+	driversMtx.Lock()
+	defer driversMtx.Unlock()
+	_, ok := drivers[name]
+	return ok
+}
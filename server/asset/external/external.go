@@ -0,0 +1,424 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package external provides an asset.Backend for a backend that runs in a
+// separate process, possibly written in another language, and is reached with
+// gRPC. Node integrations that are experimental can then fail without taking
+// down the server. The Service serves any asset.Backend to the server, and the
+// gRPC service is defined in backend.proto.
+package external
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/server/asset"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// dialTimeout is the timeout for connecting to the backend.
+	dialTimeout = 10 * time.Second
+	// retryInterval is how long to wait before resubscribing to block updates
+	// after a lost connection.
+	retryInterval = 5 * time.Second
+	// keepaliveInterval is how often the connection to the backend is checked
+	// with a ping, so that a lost connection is noticed while waiting for
+	// block updates.
+	keepaliveInterval = time.Minute
+)
+
+// callTimeout is how long to wait for the backend to respond to a call. The
+// swapper and order router call the backend synchronously, so a hung backend
+// must not block them indefinitely.
+var callTimeout = 30 * time.Second
+
+// blocksStreamDesc describes the Blocks stream for the client.
+var blocksStreamDesc = grpc.StreamDesc{
+	StreamName:    "Blocks",
+	ServerStreams: true,
+}
+
+var (
+	backendsMtx sync.Mutex
+	// backends are the latest backends set up for each asset, used to decode
+	// coin IDs.
+	backends = make(map[string]*Backend)
+)
+
+// coinMethods are the methods that request each kind of coin.
+var coinMethods = map[string]string{
+	KindContract:   "Contract",
+	KindRedemption: "Redemption",
+	KindFunding:    "FundingCoin",
+}
+
+// Backend is an asset.Backend that forwards calls to an external backend.
+type Backend struct {
+	addr           string
+	log            dex.Logger
+	initTxSize     uint32
+	initTxSizeBase uint32
+
+	conn *grpc.ClientConn
+
+	seqMtx   sync.Mutex
+	blockSeq uint64
+
+	// The BlockChannel method is the only way to get a block update channel.
+	// signalMtx locks the blockChans map.
+	signalMtx  sync.RWMutex
+	blockChans map[chan *asset.BlockUpdate]struct{}
+}
+
+// Check that Backend satisfies the interfaces.
+var _ asset.Backend = (*Backend)(nil)
+var _ asset.Preflighter = (*Backend)(nil)
+
+// Setup connects to the external backend for the named asset at the address.
+// If no driver is registered for the asset, a driver is registered that
+// decodes coin IDs with the external backend. The backend must be run with Run
+// to receive block updates. The connection is closed when Run returns.
+func Setup(name, addr string, logger dex.Logger) (*Backend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                keepaliveInterval,
+			Timeout:             callTimeout,
+			PermitWithoutStream: true,
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to external backend at %s: %w", addr, err)
+	}
+	be := &Backend{
+		addr:       addr,
+		log:        logger,
+		conn:       conn,
+		blockChans: make(map[chan *asset.BlockUpdate]struct{}),
+	}
+	info := new(InfoReply)
+	if err := be.call("Info", &Empty{}, info); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error requesting external backend info from %s: %w", addr, err)
+	}
+	be.blockSeq = info.BlockSeq
+	be.initTxSize = info.InitTxSize
+	be.initTxSizeBase = info.InitTxSizeBase
+	logger.Infof("Connected to external backend at %s", addr)
+	backendsMtx.Lock()
+	backends[name] = be
+	backendsMtx.Unlock()
+	if !asset.IsRegistered(name) {
+		asset.Register(name, &decoder{name})
+	}
+	return be, nil
+}
+
+// call calls the method of the external backend, waiting up to callTimeout for
+// the response.
+func (be *Backend) call(method string, req, reply proto.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	return translateErr(be.conn.Invoke(ctx, "/"+ServiceName+"/"+method, req, reply))
+}
+
+// translateErr translates the backend's NOT_FOUND status to
+// asset.CoinNotFoundError.
+func translateErr(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return asset.CoinNotFoundError
+	}
+	return err
+}
+
+// Run sends the block updates from the external backend to the block channels
+// until the context is canceled, and then closes the connection. A lost
+// connection is reported on the block channels as an asset.ConnectionError,
+// and the updates are requested again every retryInterval.
+func (be *Backend) Run(ctx context.Context) {
+	defer be.conn.Close()
+	var lost bool
+	for {
+		subscribed, err := be.watchBlocks(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			lost = false
+		}
+		if !lost {
+			lost = true
+			be.log.Errorf("Lost connection to external backend at %s: %v", be.addr, err)
+			be.signal(&asset.BlockUpdate{
+				Err: asset.NewConnectionError("external backend connection error: %v", err),
+			})
+		} else {
+			be.log.Debugf("Failed to resubscribe to external backend block updates: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// watchBlocks subscribes to block updates after the last received update, and
+// sends them to the block channels until the stream fails or the context is
+// canceled. The bool is true if the subscription was established.
+func (be *Backend) watchBlocks(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := be.conn.NewStream(ctx, &blocksStreamDesc, "/"+ServiceName+"/Blocks")
+	if err != nil {
+		return false, err
+	}
+	be.seqMtx.Lock()
+	after := be.blockSeq
+	be.seqMtx.Unlock()
+	if err := stream.SendMsg(&BlocksArgs{After: after}); err != nil {
+		return false, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return false, err
+	}
+	for {
+		update := new(BlockUpdate)
+		if err := stream.RecvMsg(update); err != nil {
+			return true, err
+		}
+		be.seqMtx.Lock()
+		be.blockSeq = update.Seq
+		be.seqMtx.Unlock()
+		bu := &asset.BlockUpdate{Reorg: update.Reorg}
+		if update.Err != "" {
+			bu.Err = asset.NewConnectionError("%s", update.Err)
+		}
+		be.signal(bu)
+	}
+}
+
+// signal sends the block update to the block channels without blocking.
+func (be *Backend) signal(bu *asset.BlockUpdate) {
+	be.signalMtx.RLock()
+	defer be.signalMtx.RUnlock()
+	for c := range be.blockChans {
+		select {
+		case c <- bu:
+		default:
+			be.log.Errorf("Failed to send block update on blocking channel")
+		}
+	}
+}
+
+// BlockChannel creates and returns a new channel on which to receive block
+// updates.
+func (be *Backend) BlockChannel(size int) <-chan *asset.BlockUpdate {
+	c := make(chan *asset.BlockUpdate, size)
+	be.signalMtx.Lock()
+	defer be.signalMtx.Unlock()
+	be.blockChans[c] = struct{}{}
+	return c
+}
+
+// Preflight is part of the asset.Preflighter interface. The external backend
+// runs its own checks, if any.
+func (be *Backend) Preflight() error {
+	return be.call("Preflight", &Empty{}, new(BoolReply))
+}
+
+// Contract is part of the asset.Backend interface.
+func (be *Backend) Contract(coinID, redeemScript []byte) (asset.Contract, error) {
+	c, err := be.coin(KindContract, &CoinArgs{CoinID: coinID, Script: redeemScript})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Redemption is part of the asset.Backend interface.
+func (be *Backend) Redemption(redemptionID, contractID []byte) (asset.Coin, error) {
+	c, err := be.coin(KindRedemption, &CoinArgs{CoinID: redemptionID, ContractID: contractID})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FundingCoin is part of the asset.Backend interface.
+func (be *Backend) FundingCoin(coinID, redeemScript []byte) (asset.FundingCoin, error) {
+	c, err := be.coin(KindFunding, &CoinArgs{CoinID: coinID, Script: redeemScript})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// coin requests the coin of the kind from the backend.
+func (be *Backend) coin(kind string, args *CoinArgs) (*coin, error) {
+	reply := new(CoinReply)
+	if err := be.call(coinMethods[kind], args, reply); err != nil {
+		return nil, err
+	}
+	return &coin{be: be, kind: kind, args: args, reply: reply}, nil
+}
+
+// ValidateSecret is part of the asset.Backend interface.
+func (be *Backend) ValidateSecret(secret, contract []byte) bool {
+	reply := new(BoolReply)
+	if err := be.call("ValidateSecret", &SecretArgs{Secret: secret, Contract: contract}, reply); err != nil {
+		be.log.Errorf("ValidateSecret error: %v", err)
+		return false
+	}
+	return reply.OK
+}
+
+// InitTxSize is part of the asset.Backend interface.
+func (be *Backend) InitTxSize() uint32 {
+	return be.initTxSize
+}
+
+// InitTxSizeBase is part of the asset.Backend interface.
+func (be *Backend) InitTxSizeBase() uint32 {
+	return be.initTxSizeBase
+}
+
+// CheckAddress is part of the asset.Backend interface.
+func (be *Backend) CheckAddress(addr string) bool {
+	reply := new(BoolReply)
+	if err := be.call("CheckAddress", &AddressArgs{Address: addr}, reply); err != nil {
+		be.log.Errorf("CheckAddress error: %v", err)
+		return false
+	}
+	return reply.OK
+}
+
+// ValidateCoinID is part of the asset.Backend interface.
+func (be *Backend) ValidateCoinID(coinID []byte) (string, error) {
+	reply := new(StringReply)
+	err := be.call("ValidateCoinID", &CoinIDArgs{CoinID: coinID}, reply)
+	return reply.Value, err
+}
+
+// ValidateContract is part of the asset.Backend interface.
+func (be *Backend) ValidateContract(contract []byte) error {
+	return be.call("ValidateContract", &ContractArgs{Contract: contract}, new(BoolReply))
+}
+
+// VerifyUnspentCoin is part of the asset.Backend interface.
+func (be *Backend) VerifyUnspentCoin(coinID []byte) error {
+	return be.call("VerifyUnspentCoin", &CoinIDArgs{CoinID: coinID}, new(BoolReply))
+}
+
+// FeeRate is part of the asset.Backend interface.
+func (be *Backend) FeeRate() (uint64, error) {
+	reply := new(FeeRateReply)
+	err := be.call("FeeRate", &Empty{}, reply)
+	return reply.FeeRate, err
+}
+
+// coin is a coin of an external backend, which satisfies asset.Contract and
+// asset.FundingCoin. Only the methods that are valid for the kind of coin
+// should be used.
+type coin struct {
+	be    *Backend
+	kind  string
+	args  *CoinArgs
+	reply *CoinReply
+}
+
+var _ asset.Contract = (*coin)(nil)
+var _ asset.FundingCoin = (*coin)(nil)
+
+// Confirmations is part of the asset.Coin interface.
+func (c *coin) Confirmations() (int64, error) {
+	reply := new(ConfsReply)
+	if err := c.be.call("Confirmations", &ConfsArgs{Kind: c.kind, Coin: c.args}, reply); err != nil {
+		return -1, err
+	}
+	return reply.Confs, nil
+}
+
+// ID is part of the asset.Coin interface.
+func (c *coin) ID() []byte {
+	return c.reply.ID
+}
+
+// TxID is part of the asset.Coin interface.
+func (c *coin) TxID() string {
+	return c.reply.TxID
+}
+
+// String is part of the asset.Coin interface.
+func (c *coin) String() string {
+	return c.reply.CoinString
+}
+
+// Value is part of the asset.Coin interface.
+func (c *coin) Value() uint64 {
+	return c.reply.Value
+}
+
+// FeeRate is part of the asset.Coin interface.
+func (c *coin) FeeRate() uint64 {
+	return c.reply.FeeRate
+}
+
+// Auth is part of the asset.FundingCoin interface.
+func (c *coin) Auth(pubkeys, sigs [][]byte, msg []byte) error {
+	args := &AuthArgs{
+		Coin:    c.args,
+		PubKeys: pubkeys,
+		Sigs:    sigs,
+		Msg:     msg,
+	}
+	return c.be.call("Auth", args, new(BoolReply))
+}
+
+// SpendSize is part of the asset.FundingCoin interface.
+func (c *coin) SpendSize() uint32 {
+	return c.reply.SpendSize
+}
+
+// SwapAddress is part of the asset.Contract interface.
+func (c *coin) SwapAddress() string {
+	return c.reply.SwapAddress
+}
+
+// RedeemScript is part of the asset.Contract interface.
+func (c *coin) RedeemScript() []byte {
+	return c.reply.RedeemScript
+}
+
+// LockTime is part of the asset.Contract interface.
+func (c *coin) LockTime() time.Time {
+	return time.Unix(0, int64(c.reply.LockTime)*int64(time.Millisecond))
+}
+
+// decoder is an asset.Driver that decodes coin IDs with the latest external
+// backend set up for an asset. Backends are set up with Setup rather than the
+// driver.
+type decoder struct {
+	name string
+}
+
+// Setup is part of the asset.Driver interface.
+func (d *decoder) Setup(string, dex.Logger, dex.Network) (asset.Backend, error) {
+	return nil, fmt.Errorf("external backend for %s must be set up with its address", d.name)
+}
+
+// DecodeCoinID is part of the asset.Driver interface.
+func (d *decoder) DecodeCoinID(coinID []byte) (string, error) {
+	backendsMtx.Lock()
+	be := backends[d.name]
+	backendsMtx.Unlock()
+	return be.ValidateCoinID(coinID)
+}
@@ -0,0 +1,287 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package external
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/server/asset"
+	"github.com/decred/slog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var tLogger = slog.NewBackend(os.Stdout).Logger("TEST")
+
+type tCoin struct {
+	id    []byte
+	confs int64
+}
+
+func (c *tCoin) Confirmations() (int64, error) { return c.confs, nil }
+func (c *tCoin) ID() []byte                    { return c.id }
+func (c *tCoin) TxID() string                  { return fmt.Sprintf("%x", c.id) }
+func (c *tCoin) String() string                { return fmt.Sprintf("%x:0", c.id) }
+func (c *tCoin) Value() uint64                 { return 5e8 }
+func (c *tCoin) FeeRate() uint64               { return 10 }
+
+type tContract struct {
+	tCoin
+	lockTime time.Time
+}
+
+func (c *tContract) SwapAddress() string  { return "swapaddr" }
+func (c *tContract) RedeemScript() []byte { return []byte{0x01, 0x02} }
+func (c *tContract) LockTime() time.Time  { return c.lockTime }
+
+type tFundingCoin struct {
+	tCoin
+	authErr error
+}
+
+func (c *tFundingCoin) Auth(pubkeys, sigs [][]byte, msg []byte) error {
+	if len(pubkeys) != 1 || len(sigs) != 1 || !bytes.Equal(msg, []byte("msg")) {
+		return fmt.Errorf("bad auth args")
+	}
+	return c.authErr
+}
+func (c *tFundingCoin) SpendSize() uint32 { return 166 }
+
+type tBackend struct {
+	mtx       sync.Mutex
+	coins     map[string]*tCoin
+	blockChan chan *asset.BlockUpdate
+	feeErr    error
+	lockTime  time.Time
+	// hang, if set, blocks FeeRate until it is closed.
+	hang chan struct{}
+}
+
+func (be *tBackend) Run(ctx context.Context) { <-ctx.Done() }
+
+func (be *tBackend) getCoin(coinID []byte) (*tCoin, error) {
+	be.mtx.Lock()
+	defer be.mtx.Unlock()
+	c, found := be.coins[string(coinID)]
+	if !found {
+		return nil, asset.CoinNotFoundError
+	}
+	return c, nil
+}
+
+func (be *tBackend) Contract(coinID, redeemScript []byte) (asset.Contract, error) {
+	c, err := be.getCoin(coinID)
+	if err != nil {
+		return nil, err
+	}
+	return &tContract{tCoin: *c, lockTime: be.lockTime}, nil
+}
+
+func (be *tBackend) ValidateSecret(secret, contract []byte) bool {
+	return bytes.Equal(secret, contract)
+}
+
+func (be *tBackend) Redemption(redemptionID, contractID []byte) (asset.Coin, error) {
+	return be.getCoin(redemptionID)
+}
+
+func (be *tBackend) FundingCoin(coinID, redeemScript []byte) (asset.FundingCoin, error) {
+	c, err := be.getCoin(coinID)
+	if err != nil {
+		return nil, err
+	}
+	return &tFundingCoin{tCoin: *c}, nil
+}
+
+func (be *tBackend) BlockChannel(size int) <-chan *asset.BlockUpdate { return be.blockChan }
+func (be *tBackend) InitTxSize() uint32                              { return 224 }
+func (be *tBackend) InitTxSizeBase() uint32                          { return 58 }
+func (be *tBackend) CheckAddress(addr string) bool                   { return addr == "good" }
+func (be *tBackend) ValidateCoinID(coinID []byte) (string, error) {
+	if len(coinID) != 4 {
+		return "", fmt.Errorf("bad coin ID length %d", len(coinID))
+	}
+	return fmt.Sprintf("%x:0", coinID), nil
+}
+func (be *tBackend) ValidateContract(contract []byte) error { return nil }
+func (be *tBackend) VerifyUnspentCoin(coinID []byte) error {
+	_, err := be.getCoin(coinID)
+	return err
+}
+func (be *tBackend) FeeRate() (uint64, error) {
+	if be.hang != nil {
+		<-be.hang
+	}
+	return 12, be.feeErr
+}
+
+// serve serves the backend with a Service, and sets up and runs an external
+// Backend for it. The returned function stops both.
+func serve(t *testing.T, tBE *tBackend) (*Backend, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	svc := NewService(tBE, tLogger)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := svc.Serve(ctx, ln); err != nil {
+			t.Errorf("Serve error: %v", err)
+		}
+	}()
+
+	stop := func() {
+		cancel()
+		wg.Wait()
+	}
+
+	be, err := Setup("tbe", ln.Addr().String(), tLogger)
+	if err != nil {
+		stop()
+		t.Fatalf("Setup error: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		be.Run(ctx)
+	}()
+	return be, stop
+}
+
+func TestExternalBackend(t *testing.T) {
+	coinID := []byte{0x0a, 0x0b, 0x0c, 0x0d}
+	tBE := &tBackend{
+		coins:     map[string]*tCoin{string(coinID): {id: coinID, confs: 3}},
+		blockChan: make(chan *asset.BlockUpdate, 1),
+		lockTime:  time.Unix(1600000000, 0),
+	}
+	be, stop := serve(t, tBE)
+	defer stop()
+
+	if be.InitTxSize() != 224 || be.InitTxSizeBase() != 58 {
+		t.Fatalf("wrong tx sizes %d, %d", be.InitTxSize(), be.InitTxSizeBase())
+	}
+	if err := be.Preflight(); err != nil {
+		t.Fatalf("Preflight error: %v", err)
+	}
+
+	// Coins.
+	contract, err := be.Contract(coinID, nil)
+	if err != nil {
+		t.Fatalf("Contract error: %v", err)
+	}
+	if !bytes.Equal(contract.ID(), coinID) || contract.Value() != 5e8 || contract.FeeRate() != 10 ||
+		contract.SwapAddress() != "swapaddr" || !contract.LockTime().Equal(tBE.lockTime) ||
+		!bytes.Equal(contract.RedeemScript(), []byte{0x01, 0x02}) {
+		t.Fatalf("wrong contract")
+	}
+	confs, err := contract.Confirmations()
+	if err != nil || confs != 3 {
+		t.Fatalf("wrong confirmations %d, %v", confs, err)
+	}
+	tBE.mtx.Lock()
+	tBE.coins[string(coinID)].confs = 4
+	tBE.mtx.Unlock()
+	// The service remembers the contract it returned, which has 3 confs.
+	if confs, _ = contract.Confirmations(); confs != 3 {
+		t.Fatalf("expected remembered contract to have 3 confirmations, got %d", confs)
+	}
+
+	fundingCoin, err := be.FundingCoin(coinID, nil)
+	if err != nil {
+		t.Fatalf("FundingCoin error: %v", err)
+	}
+	if fundingCoin.SpendSize() != 166 {
+		t.Fatalf("wrong spend size %d", fundingCoin.SpendSize())
+	}
+	if err := fundingCoin.Auth([][]byte{{0x01}}, [][]byte{{0x02}}, []byte("msg")); err != nil {
+		t.Fatalf("Auth error: %v", err)
+	}
+	if err := fundingCoin.Auth(nil, nil, []byte("msg")); err == nil {
+		t.Fatalf("no error for bad auth")
+	}
+
+	if _, err := be.Redemption([]byte{0x01}, coinID); err != asset.CoinNotFoundError {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	if err := be.VerifyUnspentCoin([]byte{0x01}); !errors.Is(err, asset.CoinNotFoundError) {
+		t.Fatalf("expected CoinNotFoundError, got %v", err)
+	}
+	if err := be.VerifyUnspentCoin(coinID); err != nil {
+		t.Fatalf("VerifyUnspentCoin error: %v", err)
+	}
+
+	// Other methods.
+	if !be.ValidateSecret([]byte{0x01}, []byte{0x01}) || be.ValidateSecret([]byte{0x01}, []byte{0x02}) {
+		t.Fatalf("wrong ValidateSecret result")
+	}
+	if !be.CheckAddress("good") || be.CheckAddress("bad") {
+		t.Fatalf("wrong CheckAddress result")
+	}
+	if s, err := asset.DecodeCoinID("tbe", coinID); err != nil || s != "0a0b0c0d:0" {
+		t.Fatalf("wrong decoded coin ID %q, %v", s, err)
+	}
+	if _, err := be.ValidateCoinID([]byte{0x01}); err == nil {
+		t.Fatalf("no error for bad coin ID")
+	}
+	if rate, err := be.FeeRate(); err != nil || rate != 12 {
+		t.Fatalf("wrong fee rate %d, %v", rate, err)
+	}
+	tBE.feeErr = fmt.Errorf("no estimate")
+	if _, err := be.FeeRate(); err == nil {
+		t.Fatalf("no fee rate error")
+	}
+
+	// Block updates.
+	blockChan := be.BlockChannel(2)
+	tBE.blockChan <- &asset.BlockUpdate{Reorg: true}
+	select {
+	case bu := <-blockChan:
+		if !bu.Reorg || bu.Err != nil {
+			t.Fatalf("wrong block update %+v", bu)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no block update")
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	defer func(d time.Duration) { callTimeout = d }(callTimeout)
+	callTimeout = 200 * time.Millisecond
+
+	tBE := &tBackend{
+		blockChan: make(chan *asset.BlockUpdate, 1),
+		hang:      make(chan struct{}),
+	}
+	be, stop := serve(t, tBE)
+	defer stop()
+	defer close(tBE.hang)
+
+	// A hung call fails at the timeout.
+	start := time.Now()
+	_, err := be.FeeRate()
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected a deadline exceeded error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("hung call returned after %v", elapsed)
+	}
+
+	// Other calls are not blocked by the hung call.
+	if !be.CheckAddress("good") {
+		t.Fatalf("CheckAddress failed with a hung call")
+	}
+}
@@ -0,0 +1,462 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package external
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/server/asset"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBlockUpdates is the number of recent block updates kept for Blocks.
+	maxBlockUpdates = 64
+	// coinExpiration is how long an unused coin is remembered for
+	// Confirmations and Auth.
+	coinExpiration = 24 * time.Hour
+)
+
+// Service serves an asset.Backend to the DEX server. A backend written in Go
+// can be run in its own process with a Service.
+type Service struct {
+	be  asset.Backend
+	log dex.Logger
+
+	blockMtx    sync.Mutex
+	blockSeq    uint64
+	blocks      []*BlockUpdate
+	blockSignal chan struct{}
+
+	coinMtx sync.Mutex
+	coins   map[string]*cachedCoin
+}
+
+// cachedCoin is a coin requested by the server, which is remembered so that
+// its confirmations can be counted.
+type cachedCoin struct {
+	coin    asset.Coin
+	lastUse time.Time
+}
+
+// NewService is the constructor for a Service.
+func NewService(be asset.Backend, logger dex.Logger) *Service {
+	return &Service{
+		be:          be,
+		log:         logger,
+		blockSignal: make(chan struct{}),
+		coins:       make(map[string]*cachedCoin),
+	}
+}
+
+// Serve serves the backend on the listener until the context is canceled. The
+// backend should be run separately.
+func (s *Service) Serve(ctx context.Context, ln net.Listener) error {
+	srv := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             keepaliveInterval / 2,
+		PermitWithoutStream: true,
+	}))
+	srv.RegisterService(&serviceDesc, &handler{s})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.watchBlocks(ctx, s.be.BlockChannel(32))
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	defer wg.Wait()
+
+	if err := srv.Serve(ln); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("serve error: %w", err)
+	}
+	return nil
+}
+
+// watchBlocks records the block updates from the backend and prunes unused
+// coins until the context is canceled.
+func (s *Service) watchBlocks(ctx context.Context, blockChan <-chan *asset.BlockUpdate) {
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case bu := <-blockChan:
+			update := &BlockUpdate{Reorg: bu.Reorg}
+			if bu.Err != nil {
+				update.Err = bu.Err.Error()
+			}
+			s.blockMtx.Lock()
+			s.blockSeq++
+			update.Seq = s.blockSeq
+			s.blocks = append(s.blocks, update)
+			if len(s.blocks) > maxBlockUpdates {
+				s.blocks = s.blocks[len(s.blocks)-maxBlockUpdates:]
+			}
+			close(s.blockSignal)
+			s.blockSignal = make(chan struct{})
+			s.blockMtx.Unlock()
+		case <-pruneTicker.C:
+			s.pruneCoins(time.Now().Add(-coinExpiration))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneCoins forgets the coins not used since the cutoff.
+func (s *Service) pruneCoins(cutoff time.Time) {
+	s.coinMtx.Lock()
+	defer s.coinMtx.Unlock()
+	for k, c := range s.coins {
+		if c.lastUse.Before(cutoff) {
+			delete(s.coins, k)
+		}
+	}
+}
+
+// blocksAfter returns the latest block sequence number, the updates after the
+// sequence number, and a channel that is closed on the next update.
+func (s *Service) blocksAfter(after uint64) (uint64, []*BlockUpdate, <-chan struct{}) {
+	s.blockMtx.Lock()
+	defer s.blockMtx.Unlock()
+	var updates []*BlockUpdate
+	for _, update := range s.blocks {
+		if update.Seq > after {
+			updates = append(updates, update)
+		}
+	}
+	return s.blockSeq, updates, s.blockSignal
+}
+
+// coinKey is the key of a coin in the coins map.
+func coinKey(kind string, args *CoinArgs) string {
+	return kind + ":" + hex.EncodeToString(args.CoinID) + ":" + hex.EncodeToString(args.ContractID)
+}
+
+// coin returns the coin of the kind, requesting it from the backend if it is
+// not remembered.
+func (s *Service) coin(kind string, args *CoinArgs) (asset.Coin, error) {
+	k := coinKey(kind, args)
+	s.coinMtx.Lock()
+	cc, found := s.coins[k]
+	if found {
+		cc.lastUse = time.Now()
+	}
+	s.coinMtx.Unlock()
+	if found {
+		return cc.coin, nil
+	}
+
+	var c asset.Coin
+	var err error
+	switch kind {
+	case KindContract:
+		c, err = s.be.Contract(args.CoinID, args.Script)
+	case KindRedemption:
+		c, err = s.be.Redemption(args.CoinID, args.ContractID)
+	case KindFunding:
+		c, err = s.be.FundingCoin(args.CoinID, args.Script)
+	default:
+		return nil, fmt.Errorf("unknown coin kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.coinMtx.Lock()
+	s.coins[k] = &cachedCoin{coin: c, lastUse: time.Now()}
+	s.coinMtx.Unlock()
+	return c, nil
+}
+
+// serviceErr converts the backend error for the response, such that
+// asset.CoinNotFoundError is sent with the NOT_FOUND status.
+func serviceErr(err error) error {
+	if errors.Is(err, asset.CoinNotFoundError) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return err
+}
+
+// coinReply describes the coin for the response.
+func coinReply(c asset.Coin) *CoinReply {
+	reply := &CoinReply{
+		ID:         c.ID(),
+		TxID:       c.TxID(),
+		CoinString: c.String(),
+		Value:      c.Value(),
+		FeeRate:    c.FeeRate(),
+	}
+	switch c := c.(type) {
+	case asset.Contract:
+		reply.SwapAddress = c.SwapAddress()
+		reply.RedeemScript = c.RedeemScript()
+		reply.LockTime = uint64(c.LockTime().UnixNano() / int64(time.Millisecond))
+	case asset.FundingCoin:
+		reply.SpendSize = c.SpendSize()
+	}
+	return reply
+}
+
+// backendServer is the server API of the gRPC service.
+type backendServer interface {
+	Info() (*InfoReply, error)
+	Preflight() (*BoolReply, error)
+	Coin(kind string, args *CoinArgs) (*CoinReply, error)
+	Confirmations(args *ConfsArgs) (*ConfsReply, error)
+	Auth(args *AuthArgs) (*BoolReply, error)
+	ValidateSecret(args *SecretArgs) (*BoolReply, error)
+	CheckAddress(args *AddressArgs) (*BoolReply, error)
+	ValidateCoinID(args *CoinIDArgs) (*StringReply, error)
+	ValidateContract(args *ContractArgs) (*BoolReply, error)
+	VerifyUnspentCoin(args *CoinIDArgs) (*BoolReply, error)
+	FeeRate() (*FeeRateReply, error)
+	Blocks(stream grpc.ServerStream) error
+}
+
+// handler provides the gRPC methods of a Service.
+type handler struct {
+	s *Service
+}
+
+var _ backendServer = (*handler)(nil)
+
+// unary describes a unary method. newReq creates the method's request message,
+// and serve serves the decoded request.
+func unary(name string, newReq func() proto.Message, serve func(srv backendServer, req proto.Message) (proto.Message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return serve(srv.(backendServer), req)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + ServiceName + "/" + name,
+			}
+			return interceptor(ctx, req, info, func(_ context.Context, req interface{}) (interface{}, error) {
+				return serve(srv.(backendServer), req.(proto.Message))
+			})
+		},
+	}
+}
+
+// serviceDesc describes the gRPC service defined in backend.proto.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*backendServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Info", func() proto.Message { return new(Empty) }, func(srv backendServer, _ proto.Message) (proto.Message, error) {
+			return srv.Info()
+		}),
+		unary("Preflight", func() proto.Message { return new(Empty) }, func(srv backendServer, _ proto.Message) (proto.Message, error) {
+			return srv.Preflight()
+		}),
+		unary("Contract", func() proto.Message { return new(CoinArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.Coin(KindContract, req.(*CoinArgs))
+		}),
+		unary("Redemption", func() proto.Message { return new(CoinArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.Coin(KindRedemption, req.(*CoinArgs))
+		}),
+		unary("FundingCoin", func() proto.Message { return new(CoinArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.Coin(KindFunding, req.(*CoinArgs))
+		}),
+		unary("Confirmations", func() proto.Message { return new(ConfsArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.Confirmations(req.(*ConfsArgs))
+		}),
+		unary("Auth", func() proto.Message { return new(AuthArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.Auth(req.(*AuthArgs))
+		}),
+		unary("ValidateSecret", func() proto.Message { return new(SecretArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.ValidateSecret(req.(*SecretArgs))
+		}),
+		unary("CheckAddress", func() proto.Message { return new(AddressArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.CheckAddress(req.(*AddressArgs))
+		}),
+		unary("ValidateCoinID", func() proto.Message { return new(CoinIDArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.ValidateCoinID(req.(*CoinIDArgs))
+		}),
+		unary("ValidateContract", func() proto.Message { return new(ContractArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.ValidateContract(req.(*ContractArgs))
+		}),
+		unary("VerifyUnspentCoin", func() proto.Message { return new(CoinIDArgs) }, func(srv backendServer, req proto.Message) (proto.Message, error) {
+			return srv.VerifyUnspentCoin(req.(*CoinIDArgs))
+		}),
+		unary("FeeRate", func() proto.Message { return new(Empty) }, func(srv backendServer, _ proto.Message) (proto.Message, error) {
+			return srv.FeeRate()
+		}),
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Blocks",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(backendServer).Blocks(stream)
+		},
+		ServerStreams: true,
+	}},
+	Metadata: "backend.proto",
+}
+
+// Info responds with the backend's transaction sizes and the latest block
+// update sequence number.
+func (h *handler) Info() (*InfoReply, error) {
+	h.s.blockMtx.Lock()
+	seq := h.s.blockSeq
+	h.s.blockMtx.Unlock()
+	return &InfoReply{
+		InitTxSize:     h.s.be.InitTxSize(),
+		InitTxSizeBase: h.s.be.InitTxSizeBase(),
+		BlockSeq:       seq,
+	}, nil
+}
+
+// Preflight runs the backend's preflight checks, if it has any.
+func (h *handler) Preflight() (*BoolReply, error) {
+	if pf, is := h.s.be.(asset.Preflighter); is {
+		if err := pf.Preflight(); err != nil {
+			return nil, err
+		}
+	}
+	return &BoolReply{OK: true}, nil
+}
+
+// Coin requests the coin of the kind from the backend, and describes it in the
+// reply. Contract, Redemption and FundingCoin are served by Coin.
+func (h *handler) Coin(kind string, args *CoinArgs) (*CoinReply, error) {
+	// Always request the coin again, since the backend may have stopped
+	// considering it valid.
+	h.s.coinMtx.Lock()
+	delete(h.s.coins, coinKey(kind, args))
+	h.s.coinMtx.Unlock()
+	c, err := h.s.coin(kind, args)
+	if err != nil {
+		return nil, serviceErr(err)
+	}
+	return coinReply(c), nil
+}
+
+// Confirmations responds with the number of confirmations of the coin.
+func (h *handler) Confirmations(args *ConfsArgs) (*ConfsReply, error) {
+	if args.Coin == nil {
+		return nil, status.Error(codes.InvalidArgument, "no coin")
+	}
+	c, err := h.s.coin(args.Kind, args.Coin)
+	if err != nil {
+		return nil, serviceErr(err)
+	}
+	n, err := c.Confirmations()
+	if err != nil {
+		return nil, serviceErr(err)
+	}
+	return &ConfsReply{Confs: n}, nil
+}
+
+// Auth checks that the owner of the public keys can spend the funding coin.
+func (h *handler) Auth(args *AuthArgs) (*BoolReply, error) {
+	if args.Coin == nil {
+		return nil, status.Error(codes.InvalidArgument, "no coin")
+	}
+	c, err := h.s.coin(KindFunding, args.Coin)
+	if err != nil {
+		return nil, serviceErr(err)
+	}
+	fc, is := c.(asset.FundingCoin)
+	if !is {
+		return nil, fmt.Errorf("not a funding coin")
+	}
+	if err := fc.Auth(args.PubKeys, args.Sigs, args.Msg); err != nil {
+		return nil, err
+	}
+	return &BoolReply{OK: true}, nil
+}
+
+// ValidateSecret responds with whether the secret satisfies the contract.
+func (h *handler) ValidateSecret(args *SecretArgs) (*BoolReply, error) {
+	return &BoolReply{OK: h.s.be.ValidateSecret(args.Secret, args.Contract)}, nil
+}
+
+// CheckAddress responds with whether the address is parseable.
+func (h *handler) CheckAddress(args *AddressArgs) (*BoolReply, error) {
+	return &BoolReply{OK: h.s.be.CheckAddress(args.Address)}, nil
+}
+
+// ValidateCoinID responds with the human-readable coin ID.
+func (h *handler) ValidateCoinID(args *CoinIDArgs) (*StringReply, error) {
+	str, err := h.s.be.ValidateCoinID(args.CoinID)
+	if err != nil {
+		return nil, err
+	}
+	return &StringReply{Value: str}, nil
+}
+
+// ValidateContract checks that the swap contract is constructed properly.
+func (h *handler) ValidateContract(args *ContractArgs) (*BoolReply, error) {
+	if err := h.s.be.ValidateContract(args.Contract); err != nil {
+		return nil, err
+	}
+	return &BoolReply{OK: true}, nil
+}
+
+// VerifyUnspentCoin checks that the coin is unspent.
+func (h *handler) VerifyUnspentCoin(args *CoinIDArgs) (*BoolReply, error) {
+	if err := h.s.be.VerifyUnspentCoin(args.CoinID); err != nil {
+		return nil, serviceErr(err)
+	}
+	return &BoolReply{OK: true}, nil
+}
+
+// FeeRate responds with the backend's fee rate.
+func (h *handler) FeeRate() (*FeeRateReply, error) {
+	rate, err := h.s.be.FeeRate()
+	if err != nil {
+		return nil, err
+	}
+	return &FeeRateReply{FeeRate: rate}, nil
+}
+
+// Blocks streams the block updates after the requested sequence number until
+// the stream is closed. If the requested sequence number is ahead of the
+// Service's, as it is after the Service restarts, only new updates are sent.
+func (h *handler) Blocks(stream grpc.ServerStream) error {
+	args := new(BlocksArgs)
+	if err := stream.RecvMsg(args); err != nil {
+		return err
+	}
+	after := args.After
+	if seq, _, _ := h.s.blocksAfter(after); after > seq {
+		after = seq
+	}
+	for {
+		seq, updates, signal := h.s.blocksAfter(after)
+		for _, update := range updates {
+			if err := stream.SendMsg(update); err != nil {
+				return err
+			}
+		}
+		after = seq
+		select {
+		case <-signal:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package external
+
+import "github.com/golang/protobuf/proto"
+
+// The external backend protocol is the gRPC service defined in backend.proto,
+// so that a backend may be written in any language with gRPC support. The
+// messages are defined below with their protobuf field tags rather than
+// generated, and are encoded with gRPC's default protobuf codec. A backend
+// that cannot find a requested coin must respond with the NOT_FOUND status.
+//
+// The methods mirror the asset.Backend interface, with the methods of the
+// coins returned by Contract, Redemption and FundingCoin served separately by
+// Confirmations and Auth.
+//
+//   Method            Request       Response
+//   Info              Empty         InfoReply
+//   Preflight         Empty         BoolReply
+//   Contract          CoinArgs      CoinReply
+//   Redemption        CoinArgs      CoinReply
+//   FundingCoin       CoinArgs      CoinReply
+//   Confirmations     ConfsArgs     ConfsReply
+//   Auth              AuthArgs      BoolReply
+//   ValidateSecret    SecretArgs    BoolReply
+//   CheckAddress      AddressArgs   BoolReply
+//   ValidateCoinID    CoinIDArgs    StringReply
+//   ValidateContract  ContractArgs  BoolReply
+//   VerifyUnspentCoin CoinIDArgs    BoolReply
+//   FeeRate           Empty         FeeRateReply
+//   Blocks            BlocksArgs    stream of BlockUpdate
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "external.Backend"
+
+// The kinds of coin that may be identified in ConfsArgs.
+const (
+	KindContract   = "contract"
+	KindRedemption = "redemption"
+	KindFunding    = "funding"
+)
+
+// Empty is the request of methods that take no arguments.
+type Empty struct{}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+
+// InfoReply is the response to Info.
+type InfoReply struct {
+	InitTxSize     uint32 `protobuf:"varint,1,opt,name=init_tx_size,json=initTxSize,proto3"`
+	InitTxSizeBase uint32 `protobuf:"varint,2,opt,name=init_tx_size_base,json=initTxSizeBase,proto3"`
+	// BlockSeq is the sequence number of the latest block update. See Blocks.
+	BlockSeq uint64 `protobuf:"varint,3,opt,name=block_seq,json=blockSeq,proto3"`
+}
+
+func (m *InfoReply) Reset()         { *m = InfoReply{} }
+func (m *InfoReply) String() string { return proto.CompactTextString(m) }
+func (*InfoReply) ProtoMessage()    {}
+
+// CoinArgs identifies a coin for Contract, Redemption and FundingCoin.
+type CoinArgs struct {
+	CoinID []byte `protobuf:"bytes,1,opt,name=coin_id,json=coinId,proto3"`
+	// Script is the redeem script of a contract or funding coin.
+	Script []byte `protobuf:"bytes,2,opt,name=script,proto3"`
+	// ContractID is the ID of the contract spent by a redemption.
+	ContractID []byte `protobuf:"bytes,3,opt,name=contract_id,json=contractId,proto3"`
+}
+
+func (m *CoinArgs) Reset()         { *m = CoinArgs{} }
+func (m *CoinArgs) String() string { return proto.CompactTextString(m) }
+func (*CoinArgs) ProtoMessage()    {}
+
+// CoinReply describes a coin. The fields that are specific to contracts and
+// funding coins are empty for other kinds of coin.
+type CoinReply struct {
+	ID         []byte `protobuf:"bytes,1,opt,name=id,proto3"`
+	TxID       string `protobuf:"bytes,2,opt,name=tx_id,json=txId,proto3"`
+	CoinString string `protobuf:"bytes,3,opt,name=coin_string,json=coinString,proto3"`
+	Value      uint64 `protobuf:"varint,4,opt,name=value,proto3"`
+	FeeRate    uint64 `protobuf:"varint,5,opt,name=fee_rate,json=feeRate,proto3"`
+	// SpendSize is the size of the input spending a funding coin.
+	SpendSize uint32 `protobuf:"varint,6,opt,name=spend_size,json=spendSize,proto3"`
+	// SwapAddress, RedeemScript and LockTime describe a contract. LockTime is
+	// in milliseconds since the UNIX epoch.
+	SwapAddress  string `protobuf:"bytes,7,opt,name=swap_address,json=swapAddress,proto3"`
+	RedeemScript []byte `protobuf:"bytes,8,opt,name=redeem_script,json=redeemScript,proto3"`
+	LockTime     uint64 `protobuf:"varint,9,opt,name=lock_time,json=lockTime,proto3"`
+}
+
+func (m *CoinReply) Reset()         { *m = CoinReply{} }
+func (m *CoinReply) String() string { return proto.CompactTextString(m) }
+func (*CoinReply) ProtoMessage()    {}
+
+// ConfsArgs identifies the coin for Confirmations. The coin is identified by
+// the same arguments with which it was requested, so that a backend that does
+// not remember the coin can request it again.
+type ConfsArgs struct {
+	Kind string    `protobuf:"bytes,1,opt,name=kind,proto3"`
+	Coin *CoinArgs `protobuf:"bytes,2,opt,name=coin,proto3"`
+}
+
+func (m *ConfsArgs) Reset()         { *m = ConfsArgs{} }
+func (m *ConfsArgs) String() string { return proto.CompactTextString(m) }
+func (*ConfsArgs) ProtoMessage()    {}
+
+// ConfsReply is the response to Confirmations.
+type ConfsReply struct {
+	Confs int64 `protobuf:"varint,1,opt,name=confs,proto3"`
+}
+
+func (m *ConfsReply) Reset()         { *m = ConfsReply{} }
+func (m *ConfsReply) String() string { return proto.CompactTextString(m) }
+func (*ConfsReply) ProtoMessage()    {}
+
+// AuthArgs are the arguments of Auth, which checks that the owner of the
+// public keys can spend the funding coin.
+type AuthArgs struct {
+	Coin    *CoinArgs `protobuf:"bytes,1,opt,name=coin,proto3"`
+	PubKeys [][]byte  `protobuf:"bytes,2,rep,name=pub_keys,json=pubKeys,proto3"`
+	Sigs    [][]byte  `protobuf:"bytes,3,rep,name=sigs,proto3"`
+	Msg     []byte    `protobuf:"bytes,4,opt,name=msg,proto3"`
+}
+
+func (m *AuthArgs) Reset()         { *m = AuthArgs{} }
+func (m *AuthArgs) String() string { return proto.CompactTextString(m) }
+func (*AuthArgs) ProtoMessage()    {}
+
+// SecretArgs are the arguments of ValidateSecret.
+type SecretArgs struct {
+	Secret   []byte `protobuf:"bytes,1,opt,name=secret,proto3"`
+	Contract []byte `protobuf:"bytes,2,opt,name=contract,proto3"`
+}
+
+func (m *SecretArgs) Reset()         { *m = SecretArgs{} }
+func (m *SecretArgs) String() string { return proto.CompactTextString(m) }
+func (*SecretArgs) ProtoMessage()    {}
+
+// AddressArgs are the arguments of CheckAddress.
+type AddressArgs struct {
+	Address string `protobuf:"bytes,1,opt,name=address,proto3"`
+}
+
+func (m *AddressArgs) Reset()         { *m = AddressArgs{} }
+func (m *AddressArgs) String() string { return proto.CompactTextString(m) }
+func (*AddressArgs) ProtoMessage()    {}
+
+// CoinIDArgs are the arguments of ValidateCoinID and VerifyUnspentCoin.
+type CoinIDArgs struct {
+	CoinID []byte `protobuf:"bytes,1,opt,name=coin_id,json=coinId,proto3"`
+}
+
+func (m *CoinIDArgs) Reset()         { *m = CoinIDArgs{} }
+func (m *CoinIDArgs) String() string { return proto.CompactTextString(m) }
+func (*CoinIDArgs) ProtoMessage()    {}
+
+// ContractArgs are the arguments of ValidateContract.
+type ContractArgs struct {
+	Contract []byte `protobuf:"bytes,1,opt,name=contract,proto3"`
+}
+
+func (m *ContractArgs) Reset()         { *m = ContractArgs{} }
+func (m *ContractArgs) String() string { return proto.CompactTextString(m) }
+func (*ContractArgs) ProtoMessage()    {}
+
+// BoolReply is the response of methods with a boolean result.
+type BoolReply struct {
+	OK bool `protobuf:"varint,1,opt,name=ok,proto3"`
+}
+
+func (m *BoolReply) Reset()         { *m = BoolReply{} }
+func (m *BoolReply) String() string { return proto.CompactTextString(m) }
+func (*BoolReply) ProtoMessage()    {}
+
+// StringReply is the response to ValidateCoinID.
+type StringReply struct {
+	Value string `protobuf:"bytes,1,opt,name=value,proto3"`
+}
+
+func (m *StringReply) Reset()         { *m = StringReply{} }
+func (m *StringReply) String() string { return proto.CompactTextString(m) }
+func (*StringReply) ProtoMessage()    {}
+
+// FeeRateReply is the response to FeeRate.
+type FeeRateReply struct {
+	FeeRate uint64 `protobuf:"varint,1,opt,name=fee_rate,json=feeRate,proto3"`
+}
+
+func (m *FeeRateReply) Reset()         { *m = FeeRateReply{} }
+func (m *FeeRateReply) String() string { return proto.CompactTextString(m) }
+func (*FeeRateReply) ProtoMessage()    {}
+
+// BlocksArgs are the arguments of Blocks, which streams the block updates with
+// a sequence number after After, starting with those the backend still has.
+type BlocksArgs struct {
+	After uint64 `protobuf:"varint,1,opt,name=after,proto3"`
+}
+
+func (m *BlocksArgs) Reset()         { *m = BlocksArgs{} }
+func (m *BlocksArgs) String() string { return proto.CompactTextString(m) }
+func (*BlocksArgs) ProtoMessage()    {}
+
+// BlockUpdate is a block update with its sequence number.
+type BlockUpdate struct {
+	Seq   uint64 `protobuf:"varint,1,opt,name=seq,proto3"`
+	Reorg bool   `protobuf:"varint,2,opt,name=reorg,proto3"`
+	Err   string `protobuf:"bytes,3,opt,name=err,proto3"`
+}
+
+func (m *BlockUpdate) Reset()         { *m = BlockUpdate{} }
+func (m *BlockUpdate) String() string { return proto.CompactTextString(m) }
+func (*BlockUpdate) ProtoMessage()    {}
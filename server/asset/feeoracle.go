@@ -0,0 +1,240 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package asset
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+)
+
+const (
+	// DefaultFeeOracleTimeout is the HTTP request timeout used when a
+	// FeeOracleConfig does not specify one.
+	DefaultFeeOracleTimeout = 5 * time.Second
+	// DefaultFeeOracleMaxAge is the maximum age of a signed oracle fee rate
+	// used when a FeeOracleConfig does not specify one.
+	DefaultFeeOracleMaxAge = 10 * time.Minute
+	// feeOracleCacheTime is how long a verified oracle rate is reused before
+	// the oracle is queried again.
+	feeOracleCacheTime = time.Minute
+	// maxFeeOracleResponse limits the size of the oracle's response body.
+	maxFeeOracleResponse = 1 << 12
+)
+
+// FeeOracleConfig is the configuration for an external fee rate oracle used as
+// a fallback when an asset backend's own fee estimate fails or is outside of
+// the configured sanity bounds.
+type FeeOracleConfig struct {
+	// URL is the HTTPS endpoint that returns a signed FeeOracleResponse.
+	URL string `json:"url"`
+	// PubKey is the hex-encoded secp256k1 public key of the oracle's signing
+	// key.
+	PubKey string `json:"pubkey"`
+	// MinFeeRate and MaxFeeRate are the inclusive bounds, in atoms/byte, for
+	// a fee rate to be considered sane. A MaxFeeRate of zero means no upper
+	// bound. MinFeeRate is treated as at least 1.
+	MinFeeRate uint64 `json:"minFeeRate"`
+	MaxFeeRate uint64 `json:"maxFeeRate"`
+	// Timeout is the HTTP request timeout in milliseconds.
+	Timeout uint64 `json:"timeoutMs"`
+	// MaxAge is the maximum age of the oracle's stamp in seconds.
+	MaxAge uint64 `json:"maxAgeSec"`
+}
+
+// FeeOracleResponse is the JSON-encoded response from a fee oracle. Sig is a
+// hex-encoded DER signature of the SHA-256 hash of the message returned by
+// FeeOracleMsg.
+type FeeOracleResponse struct {
+	FeeRate uint64 `json:"feerate"`
+	Stamp   uint64 `json:"stamp"`
+	Sig     string `json:"sig"`
+}
+
+// FeeOracleMsg is the serialized message signed by a fee oracle. It is the
+// asset symbol followed by the big-endian fee rate and stamp (unix ms).
+func FeeOracleMsg(symbol string, feeRate, stamp uint64) []byte {
+	b := make([]byte, len(symbol)+16)
+	copy(b, symbol)
+	binary.BigEndian.PutUint64(b[len(symbol):], feeRate)
+	binary.BigEndian.PutUint64(b[len(symbol)+8:], stamp)
+	return b
+}
+
+// FeeOracle fetches and verifies signed fee rates from an external HTTPS
+// service.
+type FeeOracle struct {
+	symbol   string
+	url      string
+	pubKey   *secp256k1.PublicKey
+	minRate  uint64
+	maxRate  uint64
+	maxAge   time.Duration
+	client   *http.Client
+	log      dex.Logger
+	cacheMtx sync.Mutex
+	rate     uint64
+	fetched  time.Time
+}
+
+// NewFeeOracle is the constructor for a FeeOracle. The URL must use the https
+// scheme.
+func NewFeeOracle(symbol string, cfg *FeeOracleConfig, logger dex.Logger) (*FeeOracle, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee oracle URL %q: %v", cfg.URL, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("fee oracle URL %q must use https", cfg.URL)
+	}
+	pkB, err := hex.DecodeString(cfg.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding fee oracle pubkey: %v", err)
+	}
+	pubKey, err := secp256k1.ParsePubKey(pkB)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing fee oracle pubkey: %v", err)
+	}
+	minRate := cfg.MinFeeRate
+	if minRate == 0 {
+		minRate = 1
+	}
+	if cfg.MaxFeeRate != 0 && cfg.MaxFeeRate < minRate {
+		return nil, fmt.Errorf("fee oracle max fee rate %d < min fee rate %d",
+			cfg.MaxFeeRate, minRate)
+	}
+	timeout := DefaultFeeOracleTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	maxAge := DefaultFeeOracleMaxAge
+	if cfg.MaxAge > 0 {
+		maxAge = time.Duration(cfg.MaxAge) * time.Second
+	}
+	return &FeeOracle{
+		symbol:  symbol,
+		url:     cfg.URL,
+		pubKey:  pubKey,
+		minRate: minRate,
+		maxRate: cfg.MaxFeeRate,
+		maxAge:  maxAge,
+		client:  &http.Client{Timeout: timeout},
+		log:     logger,
+	}, nil
+}
+
+// Sane checks that the fee rate is within the oracle's sanity bounds.
+func (o *FeeOracle) Sane(feeRate uint64) bool {
+	return feeRate >= o.minRate && (o.maxRate == 0 || feeRate <= o.maxRate)
+}
+
+// FeeRate returns a verified fee rate from the oracle. A recently fetched rate
+// is reused rather than querying the oracle on every call.
+func (o *FeeOracle) FeeRate() (uint64, error) {
+	o.cacheMtx.Lock()
+	defer o.cacheMtx.Unlock()
+	if o.rate > 0 && time.Since(o.fetched) < feeOracleCacheTime {
+		return o.rate, nil
+	}
+	rate, err := o.fetch()
+	if err != nil {
+		return 0, err
+	}
+	o.rate, o.fetched = rate, time.Now()
+	return rate, nil
+}
+
+// fetch requests a new fee rate from the oracle and validates the signature,
+// stamp, and sanity bounds.
+func (o *FeeOracle) fetch() (uint64, error) {
+	resp, err := o.client.Get(o.url)
+	if err != nil {
+		return 0, fmt.Errorf("fee oracle request error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("fee oracle returned status %d", resp.StatusCode)
+	}
+	var res FeeOracleResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxFeeOracleResponse)).Decode(&res)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding fee oracle response: %v", err)
+	}
+	return o.verify(&res, time.Now())
+}
+
+// verify checks the oracle's signature, the age of its stamp, and that the fee
+// rate is within the sanity bounds.
+func (o *FeeOracle) verify(res *FeeOracleResponse, now time.Time) (uint64, error) {
+	sigB, err := hex.DecodeString(res.Sig)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding fee oracle signature: %v", err)
+	}
+	sig, err := secp256k1.ParseDERSignature(sigB)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing fee oracle signature: %v", err)
+	}
+	hash := sha256.Sum256(FeeOracleMsg(o.symbol, res.FeeRate, res.Stamp))
+	if !sig.Verify(hash[:], o.pubKey) {
+		return 0, fmt.Errorf("fee oracle signature verification failed")
+	}
+	stamp := time.Unix(0, int64(res.Stamp)*int64(time.Millisecond))
+	if age := now.Sub(stamp); age > o.maxAge || age < -o.maxAge {
+		return 0, fmt.Errorf("fee oracle stamp %v outside of allowed age %v", stamp, o.maxAge)
+	}
+	if !o.Sane(res.FeeRate) {
+		return 0, fmt.Errorf("fee oracle rate %d outside of sanity bounds [%d, %d]",
+			res.FeeRate, o.minRate, o.maxRate)
+	}
+	return res.FeeRate, nil
+}
+
+// feeOracleBackend is a Backend with its FeeRate method backed by a FeeOracle
+// when the node's estimate is unavailable or not sane.
+type feeOracleBackend struct {
+	Backend
+	oracle *FeeOracle
+}
+
+// WithFeeOracle wraps the Backend so that FeeRate falls back to the FeeOracle
+// when the Backend's own estimate errors or is outside the oracle's sanity
+// bounds. If the oracle also fails, the Backend's own result is returned.
+func WithFeeOracle(be Backend, oracle *FeeOracle) Backend {
+	return &feeOracleBackend{
+		Backend: be,
+		oracle:  oracle,
+	}
+}
+
+// FeeRate returns the node's fee rate estimate if it is sane, otherwise the
+// oracle's rate.
+func (be *feeOracleBackend) FeeRate() (uint64, error) {
+	feeRate, err := be.Backend.FeeRate()
+	if err == nil && be.oracle.Sane(feeRate) {
+		return feeRate, nil
+	}
+	oracleRate, oErr := be.oracle.FeeRate()
+	if oErr != nil {
+		be.oracle.log.Warnf("Fee oracle fallback for %s failed: %v", be.oracle.symbol, oErr)
+		return feeRate, err
+	}
+	if err != nil {
+		be.oracle.log.Infof("Using fee oracle rate %d for %s after node estimate error: %v",
+			oracleRate, be.oracle.symbol, err)
+	} else {
+		be.oracle.log.Infof("Using fee oracle rate %d for %s instead of node estimate %d",
+			oracleRate, be.oracle.symbol, feeRate)
+	}
+	return oracleRate, nil
+}
@@ -0,0 +1,138 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package asset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+	"github.com/decred/slog"
+)
+
+var tLogger = slog.NewBackend(os.Stdout).Logger("TEST")
+
+type tFeeBackend struct {
+	Backend
+	rate uint64
+	err  error
+}
+
+func (be *tFeeBackend) FeeRate() (uint64, error) {
+	return be.rate, be.err
+}
+
+func signedOracleResponse(t *testing.T, priv *secp256k1.PrivateKey, symbol string, rate uint64, stamp time.Time) *FeeOracleResponse {
+	t.Helper()
+	ms := uint64(stamp.UnixNano() / int64(time.Millisecond))
+	hash := sha256.Sum256(FeeOracleMsg(symbol, rate, ms))
+	sig, err := priv.Sign(hash[:])
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	return &FeeOracleResponse{
+		FeeRate: rate,
+		Stamp:   ms,
+		Sig:     hex.EncodeToString(sig.Serialize()),
+	}
+}
+
+func TestFeeOracle(t *testing.T) {
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey error: %v", err)
+	}
+	var res *FeeOracleResponse
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	cfg := &FeeOracleConfig{
+		URL:        srv.URL,
+		PubKey:     hex.EncodeToString(priv.PubKey().SerializeCompressed()),
+		MinFeeRate: 2,
+		MaxFeeRate: 100,
+	}
+
+	// Plain http is rejected.
+	httpCfg := *cfg
+	httpCfg.URL = "http://127.0.0.1"
+	if _, err := NewFeeOracle("btc", &httpCfg, tLogger); err == nil {
+		t.Fatalf("no error for http fee oracle URL")
+	}
+
+	oracle, err := NewFeeOracle("btc", cfg, tLogger)
+	if err != nil {
+		t.Fatalf("NewFeeOracle error: %v", err)
+	}
+	oracle.client = srv.Client()
+
+	node := &tFeeBackend{rate: 10}
+	be := WithFeeOracle(node, oracle)
+
+	// A sane node estimate is used without querying the oracle.
+	rate, err := be.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	if rate != 10 {
+		t.Fatalf("expected node rate 10, got %d", rate)
+	}
+
+	// An insane node estimate falls back to the oracle.
+	node.rate = 5000
+	res = signedOracleResponse(t, priv, "btc", 20, time.Now())
+	rate, err = be.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	if rate != 20 {
+		t.Fatalf("expected oracle rate 20, got %d", rate)
+	}
+
+	// A node error falls back to the cached oracle rate.
+	node.err = errors.New("estimatesmartfee failed")
+	res = signedOracleResponse(t, priv, "btc", 30, time.Now())
+	rate, err = be.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	if rate != 20 {
+		t.Fatalf("expected cached oracle rate 20, got %d", rate)
+	}
+
+	// Expire the cache. Bad oracle responses return the node's result.
+	expire := func() { oracle.fetched = time.Time{} }
+	badResponses := map[string]*FeeOracleResponse{
+		"wrong symbol": signedOracleResponse(t, priv, "ltc", 30, time.Now()),
+		"stale stamp":  signedOracleResponse(t, priv, "btc", 30, time.Now().Add(-time.Hour)),
+		"insane rate":  signedOracleResponse(t, priv, "btc", 1000, time.Now()),
+	}
+	for name, badRes := range badResponses {
+		expire()
+		res = badRes
+		if _, err = be.FeeRate(); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+
+	// A valid response after the cache expires is fetched.
+	expire()
+	res = signedOracleResponse(t, priv, "btc", 30, time.Now())
+	rate, err = be.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	if rate != 30 {
+		t.Fatalf("expected oracle rate 30, got %d", rate)
+	}
+}
@@ -0,0 +1,214 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
+)
+
+// maxAppealLength is the maximum length of an appeal message, in bytes.
+const maxAppealLength = 2048
+
+// appealResult creates the AppealResult for the stored appeal.
+func appealResult(appeal *db.Appeal) *msgjson.AppealResult {
+	res := &msgjson.AppealResult{Reason: appeal.Reason}
+	switch appeal.Status {
+	case db.AppealAccepted:
+		res.Status = msgjson.AppealAccepted
+	case db.AppealRejected:
+		res.Status = msgjson.AppealRejected
+	default:
+		res.Status = msgjson.AppealPending
+	}
+	return res
+}
+
+// handleAppeal handles a client's appeal of the penalty that closed their
+// account. Only a closed account may appeal, and only one appeal may be pending
+// at a time. A rejected appeal is final, so the response to a subsequent appeal
+// is the rejection.
+func (auth *AuthManager) handleAppeal(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	appeal := new(msgjson.Appeal)
+	err := json.Unmarshal(msg.Payload, appeal)
+	if err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: "error parsing appeal: " + err.Error(),
+		}
+	}
+	if !bytes.Equal(appeal.AccountID, user[:]) {
+		return &msgjson.Error{
+			Code:    msgjson.IDMismatchError,
+			Message: "appeal account ID does not match the connection",
+		}
+	}
+	if len(appeal.Message) > maxAppealLength {
+		return &msgjson.Error{
+			Code:    msgjson.AppealError,
+			Message: fmt.Sprintf("appeal message exceeds %d bytes", maxAppealLength),
+		}
+	}
+	if err = auth.Auth(user, appeal.Serialize(), appeal.SigBytes()); err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.SignatureError,
+			Message: "signature error: " + err.Error(),
+		}
+	}
+
+	acctInfo, err := auth.storage.AccountInfo(user)
+	if err != nil {
+		log.Errorf("AccountInfo(%v): %v", user, err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "DB error",
+		}
+	}
+	if acctInfo.BrokenRule == account.NoRule {
+		return &msgjson.Error{
+			Code:    msgjson.AppealError,
+			Message: "account is not closed",
+		}
+	}
+
+	existing, err := auth.storage.Appeal(user)
+	if err != nil {
+		log.Errorf("Appeal(%v): %v", user, err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "DB error",
+		}
+	}
+
+	var res *msgjson.AppealResult
+	switch {
+	case existing != nil && existing.Status == db.AppealPending:
+		return &msgjson.Error{
+			Code:    msgjson.AppealError,
+			Message: "an appeal is already pending",
+		}
+	case existing != nil && existing.Status == db.AppealRejected:
+		res = appealResult(existing)
+	default:
+		// No appeal, or an accepted appeal of an earlier penalty.
+		stored := &db.Appeal{
+			AccountID:  user,
+			BrokenRule: acctInfo.BrokenRule,
+			Message:    appeal.Message,
+			Sig:        appeal.Sig,
+			Submitted:  encode.UnixMilli(unixMsNow()),
+			Status:     db.AppealPending,
+		}
+		if err = auth.storage.StoreAppeal(stored); err != nil {
+			log.Errorf("StoreAppeal(%v): %v", user, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "DB error",
+			}
+		}
+		log.Infof("User %v appealed penalty for rule %d", user, acctInfo.BrokenRule)
+		res = appealResult(stored)
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, res, nil)
+	if err != nil {
+		log.Errorf("error creating appeal response: %v", err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "internal error",
+		}
+	}
+	if err = auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send appeal response to user %v: %v", user, err)
+	}
+	return nil
+}
+
+// Appeals returns all penalty appeals.
+func (auth *AuthManager) Appeals() ([]*db.Appeal, error) {
+	return auth.storage.Appeals()
+}
+
+// DecideAppeal accepts or rejects the user's pending appeal. An accepted appeal
+// reopens the account. The decision is relayed to the user, immediately if
+// they are connected, or when they next connect.
+func (auth *AuthManager) DecideAppeal(user account.AccountID, accept bool, reason string) error {
+	appeal, err := auth.storage.Appeal(user)
+	if err != nil {
+		return err
+	}
+	if appeal == nil || appeal.Status != db.AppealPending {
+		return fmt.Errorf("no pending appeal for account %v", user)
+	}
+
+	status := db.AppealRejected
+	if accept {
+		// The appeal decision informs the user that the account is reopened.
+		if err = auth.forgive(user); err != nil {
+			return err
+		}
+		status = db.AppealAccepted
+	}
+	if err = auth.storage.DecideAppeal(user, status, reason); err != nil {
+		return err
+	}
+	log.Infof("Appeal by user %v %s", user, status)
+
+	decision := &msgjson.AppealDecision{
+		AccountID: user[:],
+		Accepted:  accept,
+		Reason:    reason,
+	}
+	if err = auth.Sign(decision); err != nil {
+		return fmt.Errorf("error signing appeal decision: %v", err)
+	}
+	note, err := msgjson.NewNotification(msgjson.AppealDecisionRoute, decision)
+	if err != nil {
+		return fmt.Errorf("error creating appeal decision notification: %v", err)
+	}
+	auth.SendWhenConnected(user, note, DefaultConnectTimeout, func() {
+		log.Infof("Appeal decision for user %v not delivered", user)
+	})
+	return nil
+}
+
+// Forgive reopens an account that was closed for a rule violation, allowing
+// the user to place orders again. The user is sent a penalty notification that
+// the account is reopened, with the optional reason.
+func (auth *AuthManager) Forgive(user account.AccountID, reason string) error {
+	if err := auth.forgive(user); err != nil {
+		return err
+	}
+	details := "account reopened by the operator"
+	if reason != "" {
+		details += ": " + reason
+	}
+	auth.notifyPenalty(&msgjson.Penalty{
+		AccountID: user[:],
+		Details:   details,
+	})
+	return nil
+}
+
+// forgive reopens the account without notifying the user. The account's
+// violation score is reset and any lot limit is lifted.
+func (auth *AuthManager) forgive(user account.AccountID) error {
+	if err := auth.storage.ForgiveAccount(user); err != nil {
+		return err
+	}
+	auth.clearPenalties(user)
+	if client := auth.user(user); client != nil {
+		client.mtx.Lock()
+		client.suspended = false
+		client.mtx.Unlock()
+	}
+	log.Debugf("user %v forgiven", user)
+	return nil
+}
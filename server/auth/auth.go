@@ -16,6 +16,8 @@ import (
 	"decred.org/dcrdex/dex/wait"
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/comms"
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
@@ -39,6 +41,31 @@ type Storage interface {
 	CreateAccount(*account.Account) (string, error)
 	AccountRegAddr(account.AccountID) (string, error)
 	PayAccount(account.AccountID, []byte) error
+	// AccountInfo retrieves the account data, including any broken rule.
+	AccountInfo(account.AccountID) (*db.Account, error)
+	// ForgiveAccount reopens an account that was closed for a rule violation.
+	ForgiveAccount(account.AccountID) error
+	// StoreAppeal stores a penalty appeal, replacing any previous appeal.
+	StoreAppeal(*db.Appeal) error
+	// Appeal retrieves the account's penalty appeal, or nil if there is none.
+	Appeal(account.AccountID) (*db.Appeal, error)
+	// Appeals retrieves all penalty appeals.
+	Appeals() ([]*db.Appeal, error)
+	// DecideAppeal records the decision on the account's pending appeal.
+	DecideAppeal(account.AccountID, db.AppealStatus, string) error
+	// Order retrieves an order and its status.
+	Order(oid order.OrderID, base, quote uint32) (order.Order, order.OrderStatus, error)
+	// MatchByID retrieves the match.
+	MatchByID(mid order.MatchID, base, quote uint32) (*db.MatchData, error)
+	// SwapData retrieves the swap negotiation data for the match.
+	SwapData(mid db.MarketMatchID) (order.MatchStatus, *db.SwapData, error)
+	// UserOrders retrieves all of the account's orders on a market.
+	UserOrders(ctx context.Context, aid account.AccountID, base, quote uint32) ([]order.Order, []order.OrderStatus, error)
+	// UserMatches retrieves all of the account's matches on a market.
+	UserMatches(aid account.AccountID, base, quote uint32) ([]*db.MatchData, error)
+	// RecordClientVersion records a connection with a client software
+	// version.
+	RecordClientVersion(aid account.AccountID, userAgent string, apiVer uint16, stamp int64) error
 }
 
 // Signer signs messages. It is likely a secp256k1.PrivateKey.
@@ -57,6 +84,7 @@ type FeeChecker func(coinID []byte) (addr string, val uint64, confs int64, err e
 type respHandler struct {
 	f      func(comms.Link, *msgjson.Message)
 	expire *time.Timer
+	sent   time.Time
 }
 
 // clientInfo represents a DEX client, including account information and last
@@ -67,7 +95,19 @@ type clientInfo struct {
 	conn         comms.Link
 	respHandlers map[uint64]*respHandler
 	recentOrders *latestOrders
-	suspended    bool // penalized, disallow new orders
+	suspended    bool          // penalized, disallow new orders
+	lastRTT      time.Duration // round-trip time of the most recent response
+	version      ClientVersion
+}
+
+// maxUserAgentLen is the longest client user agent that is recorded. Longer
+// user agents are truncated.
+const maxUserAgentLen = 64
+
+// ClientVersion identifies the client software version of a connection.
+type ClientVersion struct {
+	UserAgent  string
+	APIVersion uint16
 }
 
 func (client *clientInfo) cancelRatio() float64 {
@@ -115,6 +155,7 @@ func (client *clientInfo) logReq(id uint64, f func(comms.Link, *msgjson.Message)
 	client.respHandlers[id] = &respHandler{
 		f:      f,
 		expire: time.AfterFunc(expireTime, doExpire),
+		sent:   time.Now(),
 	}
 }
 
@@ -154,8 +195,34 @@ type AuthManager struct {
 	regFee       uint64
 	checkFee     FeeChecker
 	feeConfs     int64
+	// appealContact is included in the appeal instructions of penalties.
+	appealContact string
+	tracer        *trace.Tracer
 	// latencyQ is a queue for coin waiters to deal with latency.
 	latencyQ *wait.TickerQueue
+	// powBits is the proof-of-work difficulty required of 'register' and
+	// 'connect' requests. It is accessed atomically. powUsed are the solutions
+	// that have been accepted, which may not be used again. See pow.go.
+	powBits   uint32
+	powMtx    sync.Mutex
+	powUsed   map[powSolution]time.Time
+	powPruned time.Time
+
+	// regHook decides registrations, subject to the admin overrides. Its
+	// decisions are cached for regCacheTTL. See reghook.go.
+	regHook      RegistrationHook
+	regCacheTTL  time.Duration
+	regMtx       sync.Mutex
+	regCache     map[account.AccountID]*cachedDecision
+	regOverrides map[account.AccountID]bool
+
+	// penaltyPolicy maps broken rules to their penalties. The violation
+	// scores and lot limits of accounts are not persisted, so they are reset
+	// when the server restarts. See penalty.go.
+	penaltyMtx    sync.Mutex
+	penaltyPolicy *account.PenaltyPolicy
+	scores        map[account.AccountID]uint32
+	lotLimits     map[account.AccountID]*lotLimit
 
 	pendingRequestsMtx sync.Mutex
 	pendingRequests    map[account.AccountID]map[uint64]*timedRequest
@@ -182,10 +249,37 @@ type Config struct {
 
 	CancelThreshold float64
 	Anarchy         bool
+	// AppealContact is the operator's contact information, included in the
+	// appeal instructions of penalty notifications.
+	AppealContact string
+	// PoWDifficulty is the initial proof-of-work difficulty required of
+	// 'register' and 'connect' requests. Zero disables the requirement. See
+	// SetPoWDifficulty.
+	PoWDifficulty uint8
+	// PenaltyPolicy is the initial mapping of broken rules to their
+	// penalties. If nil, any broken rule closes the account. See
+	// SetPenaltyPolicy.
+	PenaltyPolicy *account.PenaltyPolicy
+	// RegistrationHook, if set, decides whether each account may register.
+	// If nil, registration is open. See SetRegistrationOverride.
+	RegistrationHook RegistrationHook
+	// RegistrationCacheTTL is how long a decision of the RegistrationHook is
+	// reused for an account. Zero uses DefaultRegistrationCacheTTL.
+	RegistrationCacheTTL time.Duration
+	// Tracer records the events of client requests. It may be nil.
+	Tracer *trace.Tracer
 }
 
 // NewAuthManager is the constructor for an AuthManager.
 func NewAuthManager(cfg *Config) *AuthManager {
+	penaltyPolicy := cfg.PenaltyPolicy
+	if penaltyPolicy == nil {
+		penaltyPolicy = account.DefaultPenaltyPolicy()
+	}
+	regCacheTTL := cfg.RegistrationCacheTTL
+	if regCacheTTL == 0 {
+		regCacheTTL = DefaultRegistrationCacheTTL
+	}
 	auth := &AuthManager{
 		anarchy:         cfg.Anarchy,
 		users:           make(map[account.AccountID]*clientInfo),
@@ -196,14 +290,31 @@ func NewAuthManager(cfg *Config) *AuthManager {
 		checkFee:        cfg.FeeChecker,
 		feeConfs:        cfg.FeeConfs,
 		cancelThresh:    cfg.CancelThreshold,
+		appealContact:   cfg.AppealContact,
+		tracer:          cfg.Tracer,
 		latencyQ:        wait.NewTickerQueue(recheckInterval),
+		penaltyPolicy:   penaltyPolicy.Copy(),
+		scores:          make(map[account.AccountID]uint32),
+		lotLimits:       make(map[account.AccountID]*lotLimit),
+		regHook:         cfg.RegistrationHook,
+		regCacheTTL:     regCacheTTL,
+		regCache:        make(map[account.AccountID]*cachedDecision),
+		regOverrides:    make(map[account.AccountID]bool),
 		pendingRequests: make(map[account.AccountID]map[uint64]*timedRequest),
 		pendingMessages: make(map[account.AccountID]map[uint64]*timedMessage),
 	}
+	if err := auth.SetPoWDifficulty(cfg.PoWDifficulty); err != nil {
+		log.Errorf("Proof of work disabled: %v", err)
+	}
 
 	comms.Route(msgjson.ConnectRoute, auth.handleConnect)
 	comms.Route(msgjson.RegisterRoute, auth.handleRegister)
 	comms.Route(msgjson.NotifyFeeRoute, auth.handleNotifyFee)
+	auth.Route(msgjson.AppealRoute, auth.handleAppeal)
+	auth.Route(msgjson.OrderStatusRoute, auth.handleOrderStatus)
+	auth.Route(msgjson.MatchStatusRoute, auth.handleMatchStatus)
+	auth.Route(msgjson.MatchReceiptRoute, auth.handleMatchReceipt)
+	auth.Route(msgjson.AccountExportRoute, auth.handleAccountExport)
 	return auth
 }
 
@@ -233,7 +344,7 @@ func (auth *AuthManager) recordOrderDone(user account.AccountID, oid order.Order
 
 	client := auth.users[user]
 	if client == nil {
-		log.Errorf("unknown client for user %v", user)
+		acctLogger(user).Errorf("unknown client for user %v", user)
 		return
 	}
 
@@ -245,7 +356,7 @@ func (auth *AuthManager) recordOrderDone(user account.AccountID, oid order.Order
 	})
 	client.mtx.Unlock()
 
-	log.Debugf("Recorded order %v that has finished processing: user=%v, time=%v, target=%v",
+	acctLogger(user).Debugf("Recorded order %v that has finished processing: user=%v, time=%v, target=%v",
 		oid, user, tMS, target)
 
 	// TODO: decide when and where to count and penalize
@@ -271,6 +382,7 @@ func (auth *AuthManager) Route(route string, handler func(account.AccountID, *ms
 				Message: "cannot use route '" + route + "' on an unauthorized connection",
 			}
 		}
+		auth.tracer.Logf(log, "AUTH", auth.tracer.Of(msg), "%s request from user %v", route, client.acct.ID)
 		return handler(client.acct.ID, msg)
 	})
 }
@@ -412,7 +524,7 @@ func (auth *AuthManager) send(user account.AccountID, msg *msgjson.Message, conn
 			}, connectTimeout)
 			return nil
 		}
-		log.Errorf("Send requested for unknown user %v", user)
+		acctLogger(user).Errorf("Send requested for unknown user %v", user)
 		return fmt.Errorf("unknown user")
 	}
 
@@ -561,7 +673,7 @@ func (auth *AuthManager) request(user account.AccountID, msg *msgjson.Message, f
 			}, connectTimeout)
 			return nil
 		}
-		log.Errorf("Send requested for unknown user %v", user)
+		acctLogger(user).Errorf("Send requested for unknown user %v", user)
 		return fmt.Errorf("unknown user")
 	}
 	// log.Tracef("Registering '%s' request ID %d for user %v (auth clientInfo)", msg.Route, msg.ID, user)
@@ -629,8 +741,11 @@ func (auth *AuthManager) RequestWithTimeout(user account.AccountID, msg *msgjson
 }
 
 // Penalize signals that a user has broken a rule of community conduct, and that
-// their account should be penalized.
-func (auth *AuthManager) Penalize(user account.AccountID, rule account.Rule) error {
+// their account should be penalized according to the penalty policy. The
+// details describe the violation, and the matchIDs reference the matches that
+// are evidence of it, if any. The user is sent a penalty notification,
+// immediately if they are connected, or when they next connect.
+func (auth *AuthManager) Penalize(user account.AccountID, rule account.Rule, details string, matchIDs ...order.MatchID) error {
 	if auth.anarchy {
 		err := fmt.Errorf("user %v penalized for rule %v, but not enforcing it", user, rule)
 		log.Error(err)
@@ -639,27 +754,102 @@ func (auth *AuthManager) Penalize(user account.AccountID, rule account.Rule) err
 
 	// TODO: option to close permanently or suspend for a certain time.
 
-	client := auth.user(user)
-	if client != nil {
-		client.suspend()
-	}
+	ban, consequence := auth.applyPenalty(user, rule)
+	if ban {
+		client := auth.user(user)
+		if client != nil {
+			client.suspend()
+		}
 
-	if err := auth.storage.CloseAccount(user /*client.acct.ID*/, rule); err != nil {
-		log.Error(err)
-		return err
+		if err := auth.storage.CloseAccount(user /*client.acct.ID*/, rule); err != nil {
+			log.Error(err)
+			return err
+		}
 	}
 
-	log.Debugf("user %v penalized for rule %v", user, rule)
+	acctLogger(user).Debugf("user %v penalized for rule %v (%s): %s", user, rule, consequence, details)
 
 	// We do NOT want to do disconnect if the user has active swaps.  However,
 	// we do not want the user to initiate a swap or place a new order, so there
 	// should be appropriate checks on order submission and match/swap
 	// initiation (TODO).
 
-	// TODO: notify client of penalty / account status change?
+	mids := make([]msgjson.Bytes, 0, len(matchIDs))
+	for i := range matchIDs {
+		mids = append(mids, matchIDs[i][:])
+	}
+	penalty := &msgjson.Penalty{
+		AccountID: user[:],
+		Rule:      uint8(rule),
+		Banned:    ban,
+		Details:   details,
+		MatchIDs:  mids,
+	}
+	if ban {
+		penalty.Appeal = auth.appealInstructions()
+	} else {
+		// The notification of a lesser penalty describes its consequences.
+		penalty.Details += " (" + consequence + ")"
+	}
+	auth.notifyPenalty(penalty)
 	return nil
 }
 
+// appealInstructions describes how a penalized user may appeal.
+func (auth *AuthManager) appealInstructions() string {
+	s := "Submit an appeal with the 'appeal' request, explaining the circumstances of the violation. " +
+		"The account is reopened if the appeal is accepted."
+	if auth.appealContact != "" {
+		s += " Operator contact: " + auth.appealContact
+	}
+	return s
+}
+
+// notifyPenalty signs and sends the penalty notification to the user,
+// immediately if they are connected, or when they next connect.
+func (auth *AuthManager) notifyPenalty(penalty *msgjson.Penalty) {
+	var user account.AccountID
+	copy(user[:], penalty.AccountID)
+	penalty.Time = encode.UnixMilliU(time.Now())
+	if err := auth.Sign(penalty); err != nil {
+		acctLogger(user).Errorf("error signing penalty notification for user %v: %v", user, err)
+		return
+	}
+	note, err := msgjson.NewNotification(msgjson.PenaltyRoute, penalty)
+	if err != nil {
+		acctLogger(user).Errorf("error creating penalty notification for user %v: %v", user, err)
+		return
+	}
+	auth.SendWhenConnected(user, note, DefaultConnectTimeout, func() {
+		acctLogger(user).Infof("Penalty notification for user %v not delivered", user)
+	})
+}
+
+// ConnState reports whether the user is connected, and the round-trip time of
+// the user's most recent response to a server request. The round-trip time is
+// zero if the user is not connected or has not responded to a request on the
+// current connection.
+func (auth *AuthManager) ConnState(user account.AccountID) (connected bool, lastRTT time.Duration) {
+	client := auth.user(user)
+	if client == nil {
+		return false, 0
+	}
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	return true, client.lastRTT
+}
+
+// ConnectedVersions counts the connected clients by client software version.
+func (auth *AuthManager) ConnectedVersions() map[ClientVersion]int {
+	auth.connMtx.RLock()
+	defer auth.connMtx.RUnlock()
+	versions := make(map[ClientVersion]int)
+	for _, client := range auth.users {
+		versions[client.version]++
+	}
+	return versions
+}
+
 // user gets the clientInfo for the specified account ID.
 func (auth *AuthManager) user(user account.AccountID) *clientInfo {
 	auth.connMtx.RLock()
@@ -709,6 +899,10 @@ func (auth *AuthManager) handleConnect(conn comms.Link, msg *msgjson.Message) *m
 			Message: "authentication error. invalid account ID",
 		}
 	}
+	// Check any required proof of work before the account is looked up.
+	if msgErr := auth.checkPoW(connect.Serialize(), connect.Time, connect.Nonce); msgErr != nil {
+		return msgErr
+	}
 	var user account.AccountID
 	copy(user[:], connect.AccountID[:])
 	acctInfo, paid, open := auth.storage.Account(user)
@@ -817,12 +1011,25 @@ func (auth *AuthManager) handleConnect(conn comms.Link, msg *msgjson.Message) *m
 			Message: "DB error",
 		}
 	}
+	version := ClientVersion{
+		UserAgent:  connect.UserAgent,
+		APIVersion: connect.APIVersion,
+	}
+	if len(version.UserAgent) > maxUserAgentLen {
+		version.UserAgent = version.UserAgent[:maxUserAgentLen]
+	}
+	err = auth.storage.RecordClientVersion(acctInfo.ID, version.UserAgent, version.APIVersion,
+		encode.UnixMilli(time.Now()))
+	if err != nil {
+		acctLogger(acctInfo.ID).Errorf("Failed to record client version for user %v: %v", acctInfo.ID, err)
+	}
 	client = &clientInfo{
 		acct:         acctInfo,
 		conn:         conn,
 		respHandlers: respHandlers,
 		recentOrders: latestFinished,
 		suspended:    !open,
+		version:      version,
 	}
 	if cancelRatio := client.cancelRatio(); !auth.anarchy && cancelRatio > auth.cancelThresh {
 		// Account should already be closed, but perhaps the server crashed
@@ -831,13 +1038,13 @@ func (auth *AuthManager) handleConnect(conn comms.Link, msg *msgjson.Message) *m
 		// The account might now be closed if the cancellation ratio was
 		// exceeded while the server was running in anarchy mode.
 		auth.storage.CloseAccount(acctInfo.ID, account.CancellationRatio)
-		log.Debugf("Suspended account %v (cancellation ratio = %f) connected.",
+		acctLogger(acctInfo.ID).Debugf("Suspended account %v (cancellation ratio = %f) connected.",
 			acctInfo.ID, cancelRatio)
 	}
 
 	pendingReqs, pendingMsgs := auth.addClient(client)
-	log.Debugf("User %s connected from %s with %d pending requests and %d pending responses/notifications.",
-		acctInfo.ID, conn.IP(), len(pendingReqs), len(pendingMsgs))
+	acctLogger(acctInfo.ID).Debugf("User %s connected from %s (%q, API version %d) with %d pending requests and %d pending responses/notifications.",
+		acctInfo.ID, conn.IP(), version.UserAgent, version.APIVersion, len(pendingReqs), len(pendingMsgs))
 
 	// Send pending requests for this user.
 	for _, pr := range pendingReqs {
@@ -933,6 +1140,9 @@ func (auth *AuthManager) handleResponse(conn comms.Link, msg *msgjson.Message) {
 		}
 		return
 	}
+	client.mtx.Lock()
+	client.lastRTT = time.Since(handler.sent)
+	client.mtx.Unlock()
 	handler.f(conn, msg)
 }
 
@@ -10,6 +10,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -20,6 +22,7 @@ import (
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/comms"
+	"decred.org/dcrdex/server/db"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
@@ -52,6 +55,21 @@ type TStorage struct {
 	unpaid   bool
 	closed   bool
 	ratio    ratioData
+	rule     account.Rule
+	appeal   *db.Appeal
+	forgiven bool
+	ord      order.Order
+	ordErr   error
+	match    *db.MatchData
+	swap     *db.SwapData
+	matchErr error
+
+	userOrders  []order.Order
+	userMatches []*db.MatchData
+	userErr     error
+
+	versionAcct account.AccountID
+	version     ClientVersion
 }
 
 func (s *TStorage) CloseAccount(id account.AccountID, _ account.Rule) error {
@@ -67,6 +85,54 @@ func (s *TStorage) ActiveMatches(account.AccountID) ([]*order.UserMatch, error)
 func (s *TStorage) CreateAccount(*account.Account) (string, error)   { return s.acctAddr, s.acctErr }
 func (s *TStorage) AccountRegAddr(account.AccountID) (string, error) { return s.regAddr, s.regErr }
 func (s *TStorage) PayAccount(account.AccountID, []byte) error       { return s.payErr }
+func (s *TStorage) AccountInfo(aid account.AccountID) (*db.Account, error) {
+	return &db.Account{AccountID: aid, BrokenRule: s.rule}, nil
+}
+func (s *TStorage) ForgiveAccount(account.AccountID) error {
+	s.forgiven = true
+	s.rule = account.NoRule
+	return nil
+}
+func (s *TStorage) StoreAppeal(appeal *db.Appeal) error {
+	s.appeal = appeal
+	return nil
+}
+func (s *TStorage) Appeal(account.AccountID) (*db.Appeal, error) { return s.appeal, nil }
+func (s *TStorage) Appeals() ([]*db.Appeal, error) {
+	if s.appeal == nil {
+		return nil, nil
+	}
+	return []*db.Appeal{s.appeal}, nil
+}
+func (s *TStorage) DecideAppeal(_ account.AccountID, status db.AppealStatus, reason string) error {
+	s.appeal.Status = status
+	s.appeal.Reason = reason
+	return nil
+}
+func (s *TStorage) Order(order.OrderID, uint32, uint32) (order.Order, order.OrderStatus, error) {
+	return s.ord, order.OrderStatusBooked, s.ordErr
+}
+func (s *TStorage) MatchByID(order.MatchID, uint32, uint32) (*db.MatchData, error) {
+	return s.match, s.matchErr
+}
+func (s *TStorage) SwapData(db.MarketMatchID) (order.MatchStatus, *db.SwapData, error) {
+	return s.match.Status, s.swap, s.matchErr
+}
+func (s *TStorage) UserOrders(_ context.Context, _ account.AccountID, _, _ uint32) ([]order.Order, []order.OrderStatus, error) {
+	statuses := make([]order.OrderStatus, len(s.userOrders))
+	for i := range statuses {
+		statuses[i] = order.OrderStatusExecuted
+	}
+	return s.userOrders, statuses, s.userErr
+}
+func (s *TStorage) UserMatches(account.AccountID, uint32, uint32) ([]*db.MatchData, error) {
+	return s.userMatches, s.userErr
+}
+func (s *TStorage) RecordClientVersion(aid account.AccountID, userAgent string, apiVer uint16, _ int64) error {
+	s.versionAcct = aid
+	s.version = ClientVersion{UserAgent: userAgent, APIVersion: apiVer}
+	return nil
+}
 func (s *TStorage) setRatioData(dat *ratioData) {
 	s.ratio = *dat
 }
@@ -208,6 +274,7 @@ func tNewConnect(user *tUser) *msgjson.Connect {
 		AccountID:  user.acctID[:],
 		APIVersion: 0,
 		Time:       encode.UnixMilliU(unixMsNow()),
+		UserAgent:  "dexc/0.1.0",
 	}
 }
 
@@ -394,6 +461,15 @@ func TestConnect(t *testing.T) {
 		t.Fatal("active match Side mismatch: ", msgMatch.Side, " != ", userMatch.Side)
 	}
 
+	// The client version is recorded and counted.
+	wantVersion := ClientVersion{UserAgent: "dexc/0.1.0"}
+	if rig.storage.versionAcct != user.acctID || rig.storage.version != wantVersion {
+		t.Fatalf("wrong client version recorded for %v: %+v", rig.storage.versionAcct, rig.storage.version)
+	}
+	if rig.mgr.ConnectedVersions()[wantVersion] == 0 {
+		t.Fatalf("connected client version not counted")
+	}
+
 	// Send a request to the client.
 	type tPayload struct {
 		A int
@@ -696,12 +772,14 @@ func TestPenalize(t *testing.T) {
 
 	// Cannot set account as suspended in the clients map if they are not
 	// connected, but should still suspend in DB.
-	rig.mgr.Penalize(foreigner.acctID, 0)
+	rig.mgr.Penalize(foreigner.acctID, 0, "")
 	var zeroAcct account.AccountID
 	// if rig.storage.closedID != zeroAcct {
 	// 	t.Fatalf("foreigner penalty stored")
 	// }
-	rig.mgr.Penalize(user.acctID, 0)
+	var mid order.MatchID
+	copy(mid[:], randBytes(32))
+	rig.mgr.Penalize(user.acctID, account.FailureToAct, "failed to act", mid)
 	if rig.storage.closedID != user.acctID {
 		t.Fatalf("penalty not stored")
 	}
@@ -710,12 +788,128 @@ func TestPenalize(t *testing.T) {
 		t.Fatalf("penalized user should not be banished")
 	}
 
+	// The user is notified of the penalty, with the evidence and appeal
+	// instructions.
+	note := user.conn.getSend()
+	if note == nil || note.Route != msgjson.PenaltyRoute {
+		t.Fatalf("no penalty notification sent")
+	}
+	penalty := new(msgjson.Penalty)
+	if err := note.Unmarshal(penalty); err != nil {
+		t.Fatalf("error decoding penalty: %v", err)
+	}
+	if !penalty.Banned || penalty.Rule != uint8(account.FailureToAct) || penalty.Details != "failed to act" ||
+		len(penalty.MatchIDs) != 1 || !bytes.Equal(penalty.MatchIDs[0], mid[:]) || penalty.Appeal == "" ||
+		len(penalty.Sig) == 0 {
+		t.Fatalf("wrong penalty notification %+v", penalty)
+	}
+
+	// Forgiveness is also sent.
+	if err := rig.mgr.Forgive(user.acctID, ""); err != nil {
+		t.Fatalf("Forgive error: %v", err)
+	}
+	note = user.conn.getSend()
+	if note == nil || note.Route != msgjson.PenaltyRoute {
+		t.Fatalf("no forgiveness notification sent")
+	}
+	penalty = new(msgjson.Penalty)
+	note.Unmarshal(penalty)
+	if penalty.Banned {
+		t.Fatalf("forgiveness notification has banned status")
+	}
+
 	// The user should remain in the map to finish their work.
 	if rig.mgr.user(user.acctID) == nil {
 		t.Fatalf("penalized user should not be removed from map")
 	}
 }
 
+func TestPenaltyPolicy(t *testing.T) {
+	user := tNewUser(t)
+	connectUser(t, user)
+	defer rig.mgr.SetPenaltyPolicy(account.DefaultPenaltyPolicy())
+
+	if err := rig.mgr.SetPenaltyPolicy(&account.PenaltyPolicy{
+		Penalties: []*account.Penalty{{Rule: account.LowFees, Score: 1}},
+	}); err == nil {
+		t.Fatalf("no error for a score without a ban score")
+	}
+	policy := &account.PenaltyPolicy{
+		BanScore: 5,
+		Penalties: []*account.Penalty{{
+			Rule:       account.PreimageReveal,
+			Score:      3,
+			LotLimit:   2,
+			LimitHours: 24,
+		}},
+	}
+	if err := rig.mgr.SetPenaltyPolicy(policy); err != nil {
+		t.Fatalf("SetPenaltyPolicy error: %v", err)
+	}
+	if got := rig.mgr.PenaltyPolicy(); got.BanScore != 5 || len(got.Penalties) != 1 {
+		t.Fatalf("wrong penalty policy %+v", got)
+	}
+
+	// The first violation limits the user's orders without closing the
+	// account.
+	var zeroAcct account.AccountID
+	rig.storage.closedID = zeroAcct
+	rig.mgr.Penalize(user.acctID, account.PreimageReveal, "no preimage")
+	if rig.storage.closedID == user.acctID {
+		t.Fatalf("account closed before reaching the ban score")
+	}
+	if _, suspended := rig.mgr.Suspended(user.acctID); suspended {
+		t.Fatalf("user suspended before reaching the ban score")
+	}
+	if lots := rig.mgr.LotLimit(user.acctID); lots != 2 {
+		t.Fatalf("expected a lot limit of 2, got %d", lots)
+	}
+	note := user.conn.getSend()
+	if note == nil || note.Route != msgjson.PenaltyRoute {
+		t.Fatalf("no penalty notification sent")
+	}
+	penalty := new(msgjson.Penalty)
+	note.Unmarshal(penalty)
+	if penalty.Banned || penalty.Appeal != "" || !strings.Contains(penalty.Details, "3 of 5") {
+		t.Fatalf("wrong penalty notification %+v", penalty)
+	}
+
+	// The second reaches the ban score.
+	rig.mgr.Penalize(user.acctID, account.PreimageReveal, "no preimage")
+	if rig.storage.closedID != user.acctID {
+		t.Fatalf("account not closed at the ban score")
+	}
+	rig.storage.closedID = zeroAcct
+	note = user.conn.getSend()
+	penalty = new(msgjson.Penalty)
+	note.Unmarshal(penalty)
+	if !penalty.Banned || penalty.Appeal == "" {
+		t.Fatalf("wrong penalty notification %+v", penalty)
+	}
+
+	// Rules that are not in the policy close the account.
+	rig.mgr.Penalize(user.acctID, account.FailureToAct, "failed to act")
+	if rig.storage.closedID != user.acctID {
+		t.Fatalf("account not closed for a rule without a penalty")
+	}
+	user.conn.getSend()
+
+	// Forgiveness resets the score and lifts the limit.
+	if err := rig.mgr.Forgive(user.acctID, ""); err != nil {
+		t.Fatalf("Forgive error: %v", err)
+	}
+	user.conn.getSend()
+	if lots := rig.mgr.LotLimit(user.acctID); lots != 0 {
+		t.Fatalf("lot limit not lifted by forgiveness")
+	}
+	rig.storage.closedID = zeroAcct
+	rig.mgr.Penalize(user.acctID, account.PreimageReveal, "no preimage")
+	if rig.storage.closedID == user.acctID {
+		t.Fatalf("violation score not reset by forgiveness")
+	}
+	user.conn.getSend()
+}
+
 func TestConnectErrors(t *testing.T) {
 	user := tNewUser(t)
 	rig.storage.acct = nil
@@ -859,6 +1053,20 @@ func TestHandleResponse(t *testing.T) {
 		t.Fatalf("wrong response handler left after cleanup cycle")
 	}
 	client.mtx.Unlock()
+
+	// A handled response sets the round-trip time reported by ConnState.
+	if connected, rtt := rig.mgr.ConnState(user.acctID); !connected || rtt != 0 {
+		t.Fatalf("expected connected with no round-trip time, got %v, %v", connected, rtt)
+	}
+	time.Sleep(time.Millisecond)
+	newResp, _ := msgjson.NewResponse(newID, 10, nil)
+	rig.mgr.handleResponse(user.conn, newResp)
+	if connected, rtt := rig.mgr.ConnState(user.acctID); !connected || rtt < time.Millisecond {
+		t.Fatalf("expected connected with a round-trip time, got %v, %v", connected, rtt)
+	}
+	if connected, _ := rig.mgr.ConnState(foreigner.acctID); connected {
+		t.Fatalf("unknown user reported as connected")
+	}
 }
 
 func TestHandleRegister(t *testing.T) {
@@ -949,6 +1157,225 @@ func TestHandleRegister(t *testing.T) {
 	}
 }
 
+func TestProofOfWork(t *testing.T) {
+	user := tNewUser(t)
+	ensureErr := makeEnsureErr(t)
+	const difficulty = 8
+
+	if err := rig.mgr.SetPoWDifficulty(msgjson.MaxPoWBits + 1); err == nil {
+		t.Fatalf("no error for excessive difficulty")
+	}
+	if err := rig.mgr.SetPoWDifficulty(difficulty); err != nil {
+		t.Fatalf("SetPoWDifficulty error: %v", err)
+	}
+	defer rig.mgr.SetPoWDifficulty(0)
+	if rig.mgr.PoWDifficulty() != difficulty {
+		t.Fatalf("wrong difficulty %d", rig.mgr.PoWDifficulty())
+	}
+
+	regMsg := func(reg *msgjson.Register) *msgjson.Message {
+		sig, _ := user.privKey.Sign(reg.Serialize())
+		reg.SetSig(sig.Serialize())
+		msg, _ := msgjson.NewRequest(comms.NextID(), msgjson.RegisterRoute, reg)
+		return msg
+	}
+	reg := &msgjson.Register{
+		PubKey: user.privKey.PubKey().SerializeCompressed(),
+		Time:   encode.UnixMilliU(unixMsNow()),
+	}
+
+	// No proof of work.
+	msgErr := rig.mgr.handleRegister(user.conn, regMsg(reg))
+	ensureErr(msgErr, "missing proof of work", msgjson.PoWRequiredError)
+	if d, ok := msgjson.PoWRequired(msgErr); !ok || d != difficulty {
+		t.Fatalf("wrong difficulty in error: %d, %t", d, ok)
+	}
+
+	// A solution for a stale time stamp.
+	stale := &msgjson.Register{
+		PubKey: reg.PubKey,
+		Time:   encode.UnixMilliU(unixMsNow().Add(-2 * powTimeWindow)),
+	}
+	stale.Nonce, _ = msgjson.SolvePoW(context.Background(), stale.Serialize(), difficulty)
+	ensureErr(rig.mgr.handleRegister(user.conn, regMsg(stale)), "stale time stamp", msgjson.ClockRangeError)
+
+	// A valid solution.
+	rig.signer.sig = user.randomSignature()
+	reg.Nonce, _ = msgjson.SolvePoW(context.Background(), reg.Serialize(), difficulty)
+	if msgErr := rig.mgr.handleRegister(user.conn, regMsg(reg)); msgErr != nil {
+		t.Fatalf("error for registration with proof of work: %v", msgErr)
+	}
+	if user.conn.getSend() == nil {
+		t.Fatalf("no register response")
+	}
+
+	// The solution cannot be used again.
+	ensureErr(rig.mgr.handleRegister(user.conn, regMsg(reg)), "replayed register", msgjson.AuthenticationError)
+
+	// A connect request without proof of work is rejected before the account
+	// is looked up.
+	rig.storage.acct = nil
+	msgErr = rig.mgr.handleConnect(user.conn, queueUser(t, user))
+	ensureErr(msgErr, "connect without proof of work", msgjson.PoWRequiredError)
+
+	// A connect request with a valid solution is accepted once.
+	rig.storage.acct = &account.Account{ID: user.acctID, PubKey: user.privKey.PubKey()}
+	connect := tNewConnect(user)
+	connect.Nonce, _ = msgjson.SolvePoW(context.Background(), connect.Serialize(), difficulty)
+	sig, _ := user.privKey.Sign(connect.Serialize())
+	connect.SetSig(sig.Serialize())
+	connectMsg, _ := msgjson.NewRequest(comms.NextID(), msgjson.ConnectRoute, connect)
+	if msgErr := rig.mgr.handleConnect(user.conn, connectMsg); msgErr != nil {
+		t.Fatalf("error for connect with proof of work: %v", msgErr)
+	}
+	user.conn.getSend()
+	ensureErr(rig.mgr.handleConnect(user.conn, connectMsg), "replayed connect", msgjson.AuthenticationError)
+
+	// Disabled.
+	rig.mgr.SetPoWDifficulty(0)
+	rig.storage.acct = &account.Account{ID: user.acctID, PubKey: user.privKey.PubKey()}
+	if msgErr = rig.mgr.handleConnect(user.conn, queueUser(t, user)); msgErr != nil {
+		t.Fatalf("connect error with proof of work disabled: %v", msgErr)
+	}
+	user.conn.getSend()
+}
+
+type tRegHook struct {
+	calls    int
+	req      *RegistrationRequest
+	decision *RegistrationDecision
+	err      error
+}
+
+func (h *tRegHook) ApproveRegistration(_ context.Context, req *RegistrationRequest) (*RegistrationDecision, error) {
+	h.calls++
+	h.req = req
+	return h.decision, h.err
+}
+
+func TestRegistrationHook(t *testing.T) {
+	user := tNewUser(t)
+	ensureErr := makeEnsureErr(t)
+	rig.signer.sig = user.randomSignature()
+	aid := account.NewID(user.privKey.PubKey().SerializeCompressed())
+
+	hook := &tRegHook{decision: &RegistrationDecision{Reason: "not on the list"}}
+	rig.mgr.regHook = hook
+	defer func() {
+		rig.mgr.regHook = nil
+		rig.mgr.ClearRegistrationOverride(aid)
+	}()
+
+	regMsg := func() *msgjson.Message {
+		reg := &msgjson.Register{
+			PubKey: user.privKey.PubKey().SerializeCompressed(),
+			Time:   encode.UnixMilliU(unixMsNow()),
+		}
+		sig, _ := user.privKey.Sign(reg.Serialize())
+		reg.SetSig(sig.Serialize())
+		msg, _ := msgjson.NewRequest(comms.NextID(), msgjson.RegisterRoute, reg)
+		return msg
+	}
+	ensureRegistered := func(tag string) {
+		t.Helper()
+		if msgErr := rig.mgr.handleRegister(user.conn, regMsg()); msgErr != nil {
+			t.Fatalf("%s: registration error: %s", tag, msgErr.Message)
+		}
+		if user.conn.getSend() == nil {
+			t.Fatalf("%s: no register response", tag)
+		}
+	}
+
+	// Denied by the hook.
+	msgErr := rig.mgr.handleRegister(user.conn, regMsg())
+	ensureErr(msgErr, "hook denial", msgjson.RegistrationDeniedError)
+	if !strings.Contains(msgErr.Message, "not on the list") {
+		t.Fatalf("denial reason not in message %q", msgErr.Message)
+	}
+	if hook.req.AccountID != aid || hook.req.IP != user.conn.IP() {
+		t.Fatalf("wrong registration request %+v", hook.req)
+	}
+
+	// The denial is cached.
+	hook.decision = &RegistrationDecision{Approved: true}
+	ensureErr(rig.mgr.handleRegister(user.conn, regMsg()), "cached denial", msgjson.RegistrationDeniedError)
+	if hook.calls != 1 {
+		t.Fatalf("hook called %d times, expected 1", hook.calls)
+	}
+	approval := rig.mgr.RegistrationApproval(aid)
+	if approval.Override != nil || approval.Cached == nil || approval.Cached.Approved {
+		t.Fatalf("wrong registration approval %+v", approval)
+	}
+
+	// An admin override approves the registration without the hook.
+	rig.mgr.SetRegistrationOverride(aid, true)
+	ensureRegistered("approved override")
+	if hook.calls != 1 {
+		t.Fatalf("hook called %d times with override", hook.calls)
+	}
+
+	// And can deny it.
+	rig.mgr.SetRegistrationOverride(aid, false)
+	ensureErr(rig.mgr.handleRegister(user.conn, regMsg()), "denied override", msgjson.RegistrationDeniedError)
+
+	// Clearing the override clears the cached decision.
+	rig.mgr.ClearRegistrationOverride(aid)
+	ensureRegistered("hook approval")
+	if hook.calls != 2 {
+		t.Fatalf("hook called %d times, expected 2", hook.calls)
+	}
+
+	// A hook error refuses the registration, and is not cached.
+	rig.mgr.ClearRegistrationOverride(aid)
+	hook.err = fmt.Errorf("test error")
+	ensureErr(rig.mgr.handleRegister(user.conn, regMsg()), "hook error", msgjson.RPCInternalError)
+	hook.err = nil
+	ensureRegistered("after hook error")
+	if hook.calls != 4 {
+		t.Fatalf("hook called %d times, expected 4", hook.calls)
+	}
+}
+
+func TestHTTPRegistrationHook(t *testing.T) {
+	aid := account.AccountID{0x01}
+	var status int
+	var gotBody map[string]string
+	respBody := `{"approved":false,"reason":"unverified"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody = nil
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(status)
+		w.Write([]byte(respBody))
+	}))
+	defer srv.Close()
+
+	hook := NewHTTPRegistrationHook(srv.URL)
+	req := &RegistrationRequest{AccountID: aid, PubKey: []byte{0x02, 0x03}, IP: "10.0.0.1"}
+
+	status = http.StatusOK
+	decision, err := hook.ApproveRegistration(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ApproveRegistration error: %v", err)
+	}
+	if decision.Approved || decision.Reason != "unverified" {
+		t.Fatalf("wrong decision %+v", decision)
+	}
+	if gotBody["accountid"] != aid.String() || gotBody["pubkey"] != "0203" || gotBody["ip"] != "10.0.0.1" {
+		t.Fatalf("wrong request body %v", gotBody)
+	}
+
+	status = http.StatusInternalServerError
+	if _, err = hook.ApproveRegistration(context.Background(), req); err == nil {
+		t.Fatalf("no error for bad status")
+	}
+
+	status = http.StatusOK
+	respBody = "?"
+	if _, err = hook.ApproveRegistration(context.Background(), req); err == nil {
+		t.Fatalf("no error for bad response body")
+	}
+}
+
 func TestHandleNotifyFee(t *testing.T) {
 	user := tNewUser(t)
 	userAcct := &account.Account{ID: user.acctID, PubKey: user.privKey.PubKey()}
@@ -1156,3 +1583,523 @@ func TestAuthManager_RecordCancel_RecordCompletedOrder(t *testing.T) {
 	client.mtx.Unlock()
 	checkOrd(ord, coid, true, encode.UnixMilli(tCompleted))
 }
+
+func TestAppeal(t *testing.T) {
+	resetStorage()
+	defer resetStorage()
+	user := tNewUser(t)
+	rig.signer.sig = user.randomSignature()
+	connectUser(t, user)
+	ensureErr := makeEnsureErr(t)
+
+	newAppeal := func(msg string) *msgjson.Message {
+		appeal := &msgjson.Appeal{
+			AccountID: user.acctID[:],
+			Message:   msg,
+		}
+		appeal.Stamp(encode.UnixMilliU(unixMsNow()))
+		sig, _ := user.privKey.Sign(appeal.Serialize())
+		appeal.SetSig(sig.Serialize())
+		req, _ := msgjson.NewRequest(comms.NextID(), msgjson.AppealRoute, appeal)
+		return req
+	}
+
+	getResult := func() *msgjson.AppealResult {
+		t.Helper()
+		respMsg := user.conn.getSend()
+		if respMsg == nil {
+			t.Fatalf("no appeal response")
+		}
+		resp, _ := respMsg.Response()
+		if resp.Error != nil {
+			t.Fatalf("appeal response error: %v", resp.Error)
+		}
+		res := new(msgjson.AppealResult)
+		if err := json.Unmarshal(resp.Result, res); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		return res
+	}
+
+	// Account not closed.
+	rpcErr := rig.mgr.handleAppeal(user.acctID, newAppeal("please"))
+	ensureErr(rpcErr, "open account", msgjson.AppealError)
+
+	rig.storage.rule = account.FailureToAct
+
+	// Bad signature.
+	req := newAppeal("please")
+	appeal := new(msgjson.Appeal)
+	json.Unmarshal(req.Payload, appeal)
+	appeal.Message = "altered"
+	req, _ = msgjson.NewRequest(req.ID, msgjson.AppealRoute, appeal)
+	rpcErr = rig.mgr.handleAppeal(user.acctID, req)
+	ensureErr(rpcErr, "bad signature", msgjson.SignatureError)
+
+	// Wrong account.
+	rpcErr = rig.mgr.handleAppeal(newAccountID(), newAppeal("please"))
+	ensureErr(rpcErr, "wrong account", msgjson.IDMismatchError)
+
+	// Too long.
+	rpcErr = rig.mgr.handleAppeal(user.acctID, newAppeal(strings.Repeat("a", maxAppealLength+1)))
+	ensureErr(rpcErr, "long message", msgjson.AppealError)
+
+	// Success.
+	rpcErr = rig.mgr.handleAppeal(user.acctID, newAppeal("please"))
+	if rpcErr != nil {
+		t.Fatalf("appeal error: %v", rpcErr)
+	}
+	if res := getResult(); res.Status != msgjson.AppealPending {
+		t.Fatalf("wrong appeal status %q", res.Status)
+	}
+	appeals, _ := rig.mgr.Appeals()
+	if len(appeals) != 1 || appeals[0].Message != "please" || appeals[0].BrokenRule != account.FailureToAct {
+		t.Fatalf("appeal not stored")
+	}
+
+	// Already pending.
+	rpcErr = rig.mgr.handleAppeal(user.acctID, newAppeal("please"))
+	ensureErr(rpcErr, "pending appeal", msgjson.AppealError)
+
+	// Reject it. The decision is relayed to the user.
+	if err := rig.mgr.DecideAppeal(user.acctID, false, "no way"); err != nil {
+		t.Fatalf("DecideAppeal error: %v", err)
+	}
+	if rig.storage.forgiven {
+		t.Fatalf("account forgiven for rejected appeal")
+	}
+	note := user.conn.getSend()
+	if note == nil || note.Route != msgjson.AppealDecisionRoute {
+		t.Fatalf("no appeal decision notification")
+	}
+	decision := new(msgjson.AppealDecision)
+	json.Unmarshal(note.Payload, decision)
+	if decision.Accepted || decision.Reason != "no way" {
+		t.Fatalf("wrong appeal decision")
+	}
+
+	// Can't decide twice.
+	if err := rig.mgr.DecideAppeal(user.acctID, true, ""); err == nil {
+		t.Fatalf("no error deciding a decided appeal")
+	}
+
+	// A new appeal gets the rejection.
+	rpcErr = rig.mgr.handleAppeal(user.acctID, newAppeal("pretty please"))
+	if rpcErr != nil {
+		t.Fatalf("appeal error: %v", rpcErr)
+	}
+	if res := getResult(); res.Status != msgjson.AppealRejected || res.Reason != "no way" {
+		t.Fatalf("wrong result for rejected appeal: %+v", res)
+	}
+
+	// Start over, and accept the appeal.
+	rig.storage.appeal = nil
+	rig.mgr.handleAppeal(user.acctID, newAppeal("please"))
+	getResult()
+	client := rig.mgr.user(user.acctID)
+	client.suspend()
+	if err := rig.mgr.DecideAppeal(user.acctID, true, "okay"); err != nil {
+		t.Fatalf("DecideAppeal error: %v", err)
+	}
+	if !rig.storage.forgiven {
+		t.Fatalf("account not forgiven")
+	}
+	if client.isSuspended() {
+		t.Fatalf("forgiven client still suspended")
+	}
+	note = user.conn.getSend()
+	if note == nil || note.Route != msgjson.AppealDecisionRoute {
+		t.Fatalf("no appeal decision notification")
+	}
+}
+
+func TestOrderStatus(t *testing.T) {
+	resetStorage()
+	defer resetStorage()
+	user := tNewUser(t)
+	rig.signer.sig = user.randomSignature()
+	connectUser(t, user)
+	ensureErr := makeEnsureErr(t)
+
+	lo := &order.LimitOrder{P: order.Prefix{AccountID: user.acctID, ServerTime: time.Now()}}
+	oid := lo.ID()
+	newReq := func(oid []byte) *msgjson.Message {
+		req, _ := msgjson.NewRequest(comms.NextID(), msgjson.OrderStatusRoute, []*msgjson.OrderStatusRequest{{
+			Base:    42,
+			Quote:   0,
+			OrderID: oid,
+		}})
+		return req
+	}
+	getResult := func() []*msgjson.OrderStatus {
+		t.Helper()
+		respMsg := user.conn.getSend()
+		if respMsg == nil {
+			t.Fatalf("no order_status response")
+		}
+		resp, _ := respMsg.Response()
+		var res []*msgjson.OrderStatus
+		if err := json.Unmarshal(resp.Result, &res); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		return res
+	}
+
+	// Bad order ID.
+	rpcErr := rig.mgr.handleOrderStatus(user.acctID, newReq(oid[:8]))
+	ensureErr(rpcErr, "bad order ID", msgjson.RPCParseError)
+
+	// DB error.
+	rig.storage.ordErr = fmt.Errorf("test error")
+	rpcErr = rig.mgr.handleOrderStatus(user.acctID, newReq(oid[:]))
+	ensureErr(rpcErr, "DB error", msgjson.RPCInternalError)
+
+	// Unknown order.
+	rig.storage.ordErr = db.ArchiveError{Code: db.ErrUnknownOrder}
+	if rpcErr = rig.mgr.handleOrderStatus(user.acctID, newReq(oid[:])); rpcErr != nil {
+		t.Fatalf("order_status error for unknown order: %v", rpcErr)
+	}
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("unknown order reported")
+	}
+	rig.storage.ordErr = nil
+
+	// Another user's order.
+	rig.storage.ord = &order.LimitOrder{P: order.Prefix{AccountID: newAccountID()}}
+	rig.mgr.handleOrderStatus(user.acctID, newReq(oid[:]))
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("another user's order reported")
+	}
+
+	// Success.
+	rig.storage.ord = lo
+	if rpcErr = rig.mgr.handleOrderStatus(user.acctID, newReq(oid[:])); rpcErr != nil {
+		t.Fatalf("order_status error: %v", rpcErr)
+	}
+	res := getResult()
+	if len(res) != 1 || !bytes.Equal(res[0].ID, oid[:]) || res[0].Status != uint16(order.OrderStatusBooked) {
+		t.Fatalf("wrong order status %+v", res)
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	resetStorage()
+	defer resetStorage()
+	user := tNewUser(t)
+	rig.signer.sig = user.randomSignature()
+	connectUser(t, user)
+	ensureErr := makeEnsureErr(t)
+
+	var mid order.MatchID
+	copy(mid[:], randBytes(32))
+	rig.storage.match = &db.MatchData{
+		ID:        mid,
+		Taker:     order.OrderID{0x01},
+		TakerAcct: user.acctID,
+		TakerAddr: "takeraddr",
+		Maker:     order.OrderID{0x02},
+		MakerAcct: newAccountID(),
+		MakerAddr: "makeraddr",
+		Epoch:     order.EpochID{Idx: 10, Dur: 1000},
+		Quantity:  1e8,
+		Rate:      2e6,
+		BaseRate:  10,
+		QuoteRate: 20,
+		Active:    true,
+		Status:    order.MakerRedeemed,
+	}
+	rig.storage.swap = &db.SwapData{
+		ContractA:       randBytes(100),
+		ContractACoinID: randBytes(36),
+		ContractATime:   12000,
+		ContractB:       randBytes(100),
+		ContractBCoinID: randBytes(36),
+		ContractBTime:   13000,
+		RedeemACoinID:   randBytes(36),
+		RedeemASecret:   randBytes(32),
+		RedeemATime:     14000,
+	}
+	newReq := func(mid []byte) *msgjson.Message {
+		req, _ := msgjson.NewRequest(comms.NextID(), msgjson.MatchStatusRoute, []*msgjson.MatchStatusRequest{{
+			Base:    42,
+			Quote:   0,
+			MatchID: mid,
+		}})
+		return req
+	}
+	getResult := func() []*msgjson.MatchStatusResult {
+		t.Helper()
+		respMsg := user.conn.getSend()
+		if respMsg == nil {
+			t.Fatalf("no match_status response")
+		}
+		resp, _ := respMsg.Response()
+		var res []*msgjson.MatchStatusResult
+		if err := json.Unmarshal(resp.Result, &res); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		return res
+	}
+
+	// Bad match ID.
+	rpcErr := rig.mgr.handleMatchStatus(user.acctID, newReq(mid[:8]))
+	ensureErr(rpcErr, "bad match ID", msgjson.RPCParseError)
+
+	// Unknown match.
+	rig.storage.matchErr = db.ArchiveError{Code: db.ErrUnknownMatch}
+	if rpcErr = rig.mgr.handleMatchStatus(user.acctID, newReq(mid[:])); rpcErr != nil {
+		t.Fatalf("match_status error for unknown match: %v", rpcErr)
+	}
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("unknown match reported")
+	}
+	rig.storage.matchErr = nil
+
+	// Another user's match.
+	rig.storage.match.TakerAcct = newAccountID()
+	rig.mgr.handleMatchStatus(user.acctID, newReq(mid[:]))
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("another user's match reported")
+	}
+	rig.storage.match.TakerAcct = user.acctID
+
+	// The taker gets the maker's contract and redemption.
+	if rpcErr = rig.mgr.handleMatchStatus(user.acctID, newReq(mid[:])); rpcErr != nil {
+		t.Fatalf("match_status error: %v", rpcErr)
+	}
+	res := getResult()
+	if len(res) != 1 {
+		t.Fatalf("expected 1 match status, got %d", len(res))
+	}
+	ms := res[0]
+	if !ms.Active || ms.Status != uint8(order.MakerRedeemed) || !bytes.Equal(ms.MatchID, mid[:]) {
+		t.Fatalf("wrong match status %+v", ms)
+	}
+	m := ms.Match
+	if !bytes.Equal(m.OrderID, rig.storage.match.Taker[:]) {
+		t.Fatalf("wrong match order ID %s", m.OrderID)
+	}
+	if m.Side != uint8(order.Taker) || m.Address != "makeraddr" || m.ServerTime != 11000 ||
+		m.FeeRateBase != 10 || m.FeeRateQuote != 20 || len(m.Sig) == 0 {
+		t.Fatalf("wrong match %+v", m)
+	}
+	if ms.Audit == nil || !bytes.Equal(ms.Audit.CoinID, rig.storage.swap.ContractACoinID) ||
+		ms.Audit.Time != 12000 || len(ms.Audit.Sig) == 0 {
+		t.Fatalf("wrong audit %+v", ms.Audit)
+	}
+	if ms.Redemption == nil || !bytes.Equal(ms.Redemption.Secret, rig.storage.swap.RedeemASecret) ||
+		ms.Redemption.Time != 14000 {
+		t.Fatalf("wrong redemption %+v", ms.Redemption)
+	}
+
+	// The maker gets the taker's contract, but the taker hasn't redeemed.
+	rig.storage.match.MakerAcct, rig.storage.match.TakerAcct = user.acctID, newAccountID()
+	rig.mgr.handleMatchStatus(user.acctID, newReq(mid[:]))
+	ms = getResult()[0]
+	if ms.Match.Side != uint8(order.Maker) || ms.Match.Address != "takeraddr" {
+		t.Fatalf("wrong maker match %+v", ms.Match)
+	}
+	if ms.Audit == nil || !bytes.Equal(ms.Audit.CoinID, rig.storage.swap.ContractBCoinID) {
+		t.Fatalf("wrong maker audit %+v", ms.Audit)
+	}
+	if ms.Redemption != nil {
+		t.Fatalf("redemption reported before the taker redeemed")
+	}
+}
+
+func TestMatchReceipt(t *testing.T) {
+	resetStorage()
+	defer resetStorage()
+	user := tNewUser(t)
+	rig.signer.sig = user.randomSignature()
+	connectUser(t, user)
+	ensureErr := makeEnsureErr(t)
+
+	var mid order.MatchID
+	copy(mid[:], randBytes(32))
+	rig.storage.match = &db.MatchData{
+		ID:        mid,
+		Taker:     order.OrderID{0x01},
+		TakerAcct: user.acctID,
+		Maker:     order.OrderID{0x02},
+		MakerAcct: newAccountID(),
+		Quantity:  1e8,
+		Rate:      2e6,
+		Status:    order.MakerRedeemed,
+	}
+	rig.storage.swap = &db.SwapData{
+		ContractACoinID: randBytes(36),
+		ContractBCoinID: randBytes(36),
+		RedeemACoinID:   randBytes(36),
+		RedeemBCoinID:   randBytes(36),
+		RedeemBTime:     15000,
+	}
+	newReq := func(mid []byte) *msgjson.Message {
+		req, _ := msgjson.NewRequest(comms.NextID(), msgjson.MatchReceiptRoute, []*msgjson.MatchStatusRequest{{
+			Base:    42,
+			Quote:   0,
+			MatchID: mid,
+		}})
+		return req
+	}
+	getResult := func() []*msgjson.SettlementReceipt {
+		t.Helper()
+		respMsg := user.conn.getSend()
+		if respMsg == nil {
+			t.Fatalf("no match_receipt response")
+		}
+		resp, _ := respMsg.Response()
+		var res []*msgjson.SettlementReceipt
+		if err := json.Unmarshal(resp.Result, &res); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		return res
+	}
+
+	// Bad match ID.
+	rpcErr := rig.mgr.handleMatchReceipt(user.acctID, newReq(mid[:8]))
+	ensureErr(rpcErr, "bad match ID", msgjson.RPCParseError)
+
+	// Not yet complete.
+	if rpcErr = rig.mgr.handleMatchReceipt(user.acctID, newReq(mid[:])); rpcErr != nil {
+		t.Fatalf("match_receipt error for incomplete match: %v", rpcErr)
+	}
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("receipt issued for incomplete match")
+	}
+	rig.storage.match.Status = order.MatchComplete
+
+	// Another user's match.
+	rig.storage.match.TakerAcct = newAccountID()
+	rig.mgr.handleMatchReceipt(user.acctID, newReq(mid[:]))
+	if res := getResult(); len(res) != 0 {
+		t.Fatalf("receipt issued for another user's match")
+	}
+	rig.storage.match.TakerAcct = user.acctID
+
+	// DB error.
+	rig.storage.matchErr = fmt.Errorf("test error")
+	rpcErr = rig.mgr.handleMatchReceipt(user.acctID, newReq(mid[:]))
+	ensureErr(rpcErr, "DB error", msgjson.RPCInternalError)
+	rig.storage.matchErr = nil
+
+	if rpcErr = rig.mgr.handleMatchReceipt(user.acctID, newReq(mid[:])); rpcErr != nil {
+		t.Fatalf("match_receipt error: %v", rpcErr)
+	}
+	res := getResult()
+	if len(res) != 1 {
+		t.Fatalf("expected 1 receipt, got %d", len(res))
+	}
+	r := res[0]
+	swap := rig.storage.swap
+	if !bytes.Equal(r.MatchID, mid[:]) || !bytes.Equal(r.TakerOrderID, rig.storage.match.Taker[:]) ||
+		!bytes.Equal(r.MakerOrderID, rig.storage.match.Maker[:]) || r.Base != 42 ||
+		r.Quantity != 1e8 || r.Rate != 2e6 || r.Time != 15000 || len(r.Sig) == 0 {
+		t.Fatalf("wrong receipt %+v", r)
+	}
+	if !bytes.Equal(r.MakerSwap, swap.ContractACoinID) || !bytes.Equal(r.TakerSwap, swap.ContractBCoinID) ||
+		!bytes.Equal(r.MakerRedeem, swap.RedeemACoinID) || !bytes.Equal(r.TakerRedeem, swap.RedeemBCoinID) {
+		t.Fatalf("wrong receipt coin IDs %+v", r)
+	}
+}
+
+func TestAccountExport(t *testing.T) {
+	resetStorage()
+	defer resetStorage()
+	user := tNewUser(t)
+	rig.signer.sig = user.randomSignature()
+	connectUser(t, user)
+	ensureErr := makeEnsureErr(t)
+
+	// One and a half pages of orders, in reverse time order.
+	nOrders := exportPageSize * 3 / 2
+	now := time.Now()
+	for i := 0; i < nOrders; i++ {
+		rig.storage.userOrders = append(rig.storage.userOrders, &order.LimitOrder{P: order.Prefix{
+			AccountID:  user.acctID,
+			ServerTime: now.Add(-time.Duration(i) * time.Second),
+		}})
+	}
+	// A single match.
+	var mid order.MatchID
+	copy(mid[:], randBytes(32))
+	rig.storage.match = &db.MatchData{
+		ID:        mid,
+		Taker:     order.OrderID{0x01},
+		TakerAcct: user.acctID,
+		Maker:     order.OrderID{0x02},
+		MakerAcct: newAccountID(),
+		Status:    order.MatchComplete,
+	}
+	rig.storage.userMatches = []*db.MatchData{rig.storage.match}
+	rig.storage.swap = &db.SwapData{}
+	rig.storage.rule = account.FailureToAct
+
+	newReq := func(page uint32) *msgjson.Message {
+		req, _ := msgjson.NewRequest(comms.NextID(), msgjson.AccountExportRoute, &msgjson.AccountExportRequest{
+			Base:  42,
+			Quote: 0,
+			Page:  page,
+		})
+		return req
+	}
+	getResult := func() *msgjson.AccountExportResult {
+		t.Helper()
+		respMsg := user.conn.getSend()
+		if respMsg == nil {
+			t.Fatalf("no account_export response")
+		}
+		resp, _ := respMsg.Response()
+		res := new(msgjson.AccountExportResult)
+		if err := json.Unmarshal(resp.Result, res); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		return res
+	}
+
+	// DB error.
+	rig.storage.userErr = fmt.Errorf("test error")
+	rpcErr := rig.mgr.handleAccountExport(user.acctID, newReq(0))
+	ensureErr(rpcErr, "DB error", msgjson.RPCInternalError)
+	rig.storage.userErr = nil
+
+	// First page.
+	if rpcErr = rig.mgr.handleAccountExport(user.acctID, newReq(0)); rpcErr != nil {
+		t.Fatalf("account_export error: %v", rpcErr)
+	}
+	res := getResult()
+	if len(res.Orders) != exportPageSize || len(res.Matches) != 1 || !res.More ||
+		res.BrokenRule != uint8(account.FailureToAct) {
+		t.Fatalf("wrong first page: %d orders, %d matches, more = %t, rule = %d",
+			len(res.Orders), len(res.Matches), res.More, res.BrokenRule)
+	}
+	// The oldest order is first.
+	ord, err := order.DecodeOrder(res.Orders[0].Order)
+	if err != nil {
+		t.Fatalf("error decoding order: %v", err)
+	}
+	if ord.ID() != rig.storage.userOrders[nOrders-1].ID() {
+		t.Fatalf("orders not sorted by time")
+	}
+	if res.Orders[0].Status != uint16(order.OrderStatusExecuted) {
+		t.Fatalf("wrong order status %d", res.Orders[0].Status)
+	}
+	if !bytes.Equal(res.Matches[0].MatchID, mid[:]) || len(res.Matches[0].Match.Sig) == 0 {
+		t.Fatalf("wrong match %+v", res.Matches[0])
+	}
+
+	// Last page.
+	rig.mgr.handleAccountExport(user.acctID, newReq(1))
+	res = getResult()
+	if len(res.Orders) != nOrders-exportPageSize || len(res.Matches) != 0 || res.More {
+		t.Fatalf("wrong last page: %d orders, %d matches, more = %t",
+			len(res.Orders), len(res.Matches), res.More)
+	}
+
+	// Past the end.
+	rig.mgr.handleAccountExport(user.acctID, newReq(5))
+	res = getResult()
+	if len(res.Orders) != 0 || res.More {
+		t.Fatalf("wrong page past the end")
+	}
+}
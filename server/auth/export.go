@@ -0,0 +1,147 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
+)
+
+const (
+	// exportPageSize is the maximum number of orders and the maximum number of
+	// matches in one page of an account_export response.
+	exportPageSize = 100
+	// exportTimeout is the time limit for retrieving a user's orders.
+	exportTimeout = 30 * time.Second
+)
+
+// handleAccountExport handles a client's request for one page of the server's
+// record of their orders and matches on a market. The orders are sorted by
+// server time, and the matches by epoch, so that the pages are stable while
+// the account is not trading.
+func (auth *AuthManager) handleAccountExport(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	req := new(msgjson.AccountExportRequest)
+	err := msg.Unmarshal(req)
+	if err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: "error parsing account_export request: " + err.Error(),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	orders, statuses, err := auth.storage.UserOrders(ctx, user, req.Base, req.Quote)
+	if err != nil {
+		log.Errorf("UserOrders(%v, %d, %d): %v", user, req.Base, req.Quote, err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "DB error",
+		}
+	}
+	matches, err := auth.storage.UserMatches(user, req.Base, req.Quote)
+	if err != nil {
+		log.Errorf("UserMatches(%v, %d, %d): %v", user, req.Base, req.Quote, err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "DB error",
+		}
+	}
+	acctInfo, err := auth.storage.AccountInfo(user)
+	if err != nil {
+		log.Errorf("AccountInfo(%v): %v", user, err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "DB error",
+		}
+	}
+
+	res := &msgjson.AccountExportResult{
+		Orders:     make([]*msgjson.ExportedOrder, 0, exportPageSize),
+		Matches:    make([]*msgjson.MatchStatusResult, 0, exportPageSize),
+		BrokenRule: uint8(acctInfo.BrokenRule),
+	}
+
+	ordIdx := make([]int, len(orders))
+	for i := range ordIdx {
+		ordIdx[i] = i
+	}
+	sort.SliceStable(ordIdx, func(i, j int) bool {
+		return orders[ordIdx[i]].Time() < orders[ordIdx[j]].Time()
+	})
+	start, end, more := pageBounds(len(orders), req.Page)
+	res.More = more
+	for _, i := range ordIdx[start:end] {
+		res.Orders = append(res.Orders, &msgjson.ExportedOrder{
+			Order:  order.EncodeOrder(orders[i]),
+			Status: uint16(statuses[i]),
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Epoch.Idx != matches[j].Epoch.Idx {
+			return matches[i].Epoch.Idx < matches[j].Epoch.Idx
+		}
+		return bytes.Compare(matches[i].ID[:], matches[j].ID[:]) < 0
+	})
+	start, end, more = pageBounds(len(matches), req.Page)
+	res.More = res.More || more
+	for _, match := range matches[start:end] {
+		_, swap, err := auth.storage.SwapData(db.MarketMatchID{
+			MatchID: match.ID,
+			Base:    req.Base,
+			Quote:   req.Quote,
+		})
+		if err != nil {
+			log.Errorf("SwapData(%v): %v", match.ID, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "DB error",
+			}
+		}
+		ms, err := auth.matchStatus(user, match, swap)
+		if err != nil {
+			log.Errorf("error signing match status for %v: %v", match.ID, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "internal error",
+			}
+		}
+		res.Matches = append(res.Matches, ms)
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, res, nil)
+	if err != nil {
+		log.Errorf("error creating account_export response: %v", err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "internal error",
+		}
+	}
+	if err = auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send account_export response to user %v: %v", user, err)
+	}
+	return nil
+}
+
+// pageBounds gives the slice bounds of the page for a list of n items, and
+// whether there are items after the page.
+func pageBounds(n int, page uint32) (start, end int, more bool) {
+	start = int(page) * exportPageSize
+	if start > n {
+		return n, n, false
+	}
+	end = start + exportPageSize
+	if end >= n {
+		return start, n, false
+	}
+	return start, end, true
+}
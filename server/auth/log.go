@@ -4,6 +4,8 @@
 package auth
 
 import (
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/server/account"
 	"github.com/decred/slog"
 )
 
@@ -21,3 +23,8 @@ func DisableLog() {
 func UseLogger(logger slog.Logger) {
 	log = logger
 }
+
+// acctLogger is the package logger with the account as a log attribute.
+func acctLogger(user account.AccountID) dex.Logger {
+	return dex.LoggerWithAttrs(log, dex.LogAttrAccount, user)
+}
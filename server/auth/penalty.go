@@ -0,0 +1,104 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/server/account"
+)
+
+// lotLimit is a temporary limit on the quantity of an account's trade orders.
+type lotLimit struct {
+	lots   uint64
+	expiry time.Time
+}
+
+// PenaltyPolicy returns the mapping of broken rules to their penalties.
+func (auth *AuthManager) PenaltyPolicy() *account.PenaltyPolicy {
+	auth.penaltyMtx.Lock()
+	defer auth.penaltyMtx.Unlock()
+	return auth.penaltyPolicy.Copy()
+}
+
+// SetPenaltyPolicy changes the mapping of broken rules to their penalties. The
+// new policy applies to subsequent violations. Existing violation scores and
+// lot limits are kept.
+func (auth *AuthManager) SetPenaltyPolicy(policy *account.PenaltyPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid penalty policy: %w", err)
+	}
+	auth.penaltyMtx.Lock()
+	auth.penaltyPolicy = policy.Copy()
+	auth.penaltyMtx.Unlock()
+	log.Infof("Penalty policy set with ban score %d and %d rule penalties.", policy.BanScore, len(policy.Penalties))
+	return nil
+}
+
+// LotLimit is the number of lots to which the quantity of the user's trade
+// orders is limited as a penalty. Zero means there is no limit.
+func (auth *AuthManager) LotLimit(user account.AccountID) uint64 {
+	auth.penaltyMtx.Lock()
+	defer auth.penaltyMtx.Unlock()
+	limit := auth.lotLimits[user]
+	if limit == nil {
+		return 0
+	}
+	if time.Now().After(limit.expiry) {
+		delete(auth.lotLimits, user)
+		return 0
+	}
+	return limit.lots
+}
+
+// applyPenalty adds the penalty's score to the user's violation score and
+// applies its lot limit, returning whether the account is to be closed and a
+// description of the consequences. A lot limit does not replace a stricter
+// limit that is already in effect.
+func (auth *AuthManager) applyPenalty(user account.AccountID, rule account.Rule) (ban bool, consequence string) {
+	auth.penaltyMtx.Lock()
+	defer auth.penaltyMtx.Unlock()
+	pen := auth.penaltyPolicy.Penalty(rule)
+	ban = pen.Ban
+	if pen.Score > 0 {
+		score := auth.scores[user] + pen.Score
+		auth.scores[user] = score
+		consequence = fmt.Sprintf("violation score is now %d of %d", score, auth.penaltyPolicy.BanScore)
+		if score >= auth.penaltyPolicy.BanScore {
+			ban = true
+		}
+	}
+	if ban {
+		return true, "account closed"
+	}
+	if pen.LotLimit > 0 {
+		expiry := time.Now().Add(time.Duration(pen.LimitHours) * time.Hour)
+		limit := auth.lotLimits[user]
+		if limit == nil || pen.LotLimit < limit.lots {
+			limit = &lotLimit{lots: pen.LotLimit}
+			auth.lotLimits[user] = limit
+		}
+		if expiry.After(limit.expiry) {
+			limit.expiry = expiry
+		}
+		if consequence != "" {
+			consequence += ", "
+		}
+		consequence += fmt.Sprintf("orders limited to %d lots until %s", limit.lots,
+			limit.expiry.UTC().Format(time.RFC3339))
+	}
+	if consequence == "" {
+		consequence = "warning"
+	}
+	return false, consequence
+}
+
+// clearPenalties resets the user's violation score and lifts any lot limit.
+func (auth *AuthManager) clearPenalties(user account.AccountID) {
+	auth.penaltyMtx.Lock()
+	delete(auth.scores, user)
+	delete(auth.lotLimits, user)
+	auth.penaltyMtx.Unlock()
+}
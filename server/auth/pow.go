@@ -0,0 +1,99 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+)
+
+// powTimeWindow is how far the time stamp of a 'register' or 'connect' request
+// may be from the server's time while a proof of work is required, so that
+// solutions cannot be stockpiled ahead of an attack.
+const powTimeWindow = 10 * time.Minute
+
+// powPruneInterval is how often expired solutions are removed from the record
+// of used proofs of work.
+const powPruneInterval = time.Minute
+
+// powSolution identifies a proof of work by the hash of the request data and
+// the nonce.
+type powSolution struct {
+	dataHash [32]byte
+	nonce    uint64
+}
+
+// PoWDifficulty is the number of leading zero bits required of the proof of
+// work on 'register' and 'connect' requests. Zero means no proof of work is
+// required.
+func (auth *AuthManager) PoWDifficulty() uint8 {
+	return uint8(atomic.LoadUint32(&auth.powBits))
+}
+
+// SetPoWDifficulty sets the proof-of-work difficulty required of 'register'
+// and 'connect' requests, e.g. while the server is flooded with registration
+// or connection attempts. Zero disables the requirement. Clients learn the
+// difficulty from the error response to a request without a valid proof of
+// work.
+func (auth *AuthManager) SetPoWDifficulty(difficulty uint8) error {
+	if difficulty > msgjson.MaxPoWBits {
+		return fmt.Errorf("proof of work difficulty %d exceeds the maximum of %d", difficulty, msgjson.MaxPoWBits)
+	}
+	if old := atomic.SwapUint32(&auth.powBits, uint32(difficulty)); old != uint32(difficulty) {
+		log.Infof("Proof of work difficulty for register and connect requests set to %d bits (was %d).", difficulty, old)
+	}
+	return nil
+}
+
+// checkPoW checks the proof of work of a request with the serialized data and
+// time stamp, if one is required.
+func (auth *AuthManager) checkPoW(data []byte, stamp, nonce uint64) *msgjson.Error {
+	difficulty := auth.PoWDifficulty()
+	if difficulty == 0 {
+		return nil
+	}
+	if d := time.Since(encode.UnixTimeMilli(int64(stamp))); d > powTimeWindow || d < -powTimeWindow {
+		return msgjson.NewError(msgjson.ClockRangeError,
+			fmt.Sprintf("request time stamp is more than %v from the server's time", powTimeWindow))
+	}
+	if !msgjson.CheckPoW(data, nonce, difficulty) {
+		return msgjson.NewPoWRequiredError(difficulty)
+	}
+	// A solution may only be used once. It is remembered until its time stamp
+	// is outside the window, after which it would be rejected anyway.
+	expiry := encode.UnixTimeMilli(int64(stamp)).Add(powTimeWindow)
+	if !auth.usePoW(powSolution{sha256.Sum256(data), nonce}, expiry) {
+		return msgjson.NewError(msgjson.AuthenticationError, "proof of work already used")
+	}
+	return nil
+}
+
+// usePoW records the use of a proof of work until the expiry, returning false
+// if it has already been used.
+func (auth *AuthManager) usePoW(sol powSolution, expiry time.Time) bool {
+	auth.powMtx.Lock()
+	defer auth.powMtx.Unlock()
+	now := time.Now()
+	if auth.powUsed == nil {
+		auth.powUsed = make(map[powSolution]time.Time)
+	}
+	if now.Sub(auth.powPruned) > powPruneInterval {
+		for s, exp := range auth.powUsed {
+			if exp.Before(now) {
+				delete(auth.powUsed, s)
+			}
+		}
+		auth.powPruned = now
+	}
+	if _, used := auth.powUsed[sol]; used {
+		return false
+	}
+	auth.powUsed[sol] = expiry
+	return true
+}
@@ -0,0 +1,240 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/server/account"
+)
+
+const (
+	// DefaultRegistrationCacheTTL is how long the decision of a
+	// RegistrationHook is reused for an account if Config.RegistrationCacheTTL
+	// is not set.
+	DefaultRegistrationCacheTTL = time.Hour
+	// regHookTimeout is how long a RegistrationHook has to decide.
+	regHookTimeout = 10 * time.Second
+	// maxRegHookResponse is the largest response body read from an HTTP
+	// registration hook.
+	maxRegHookResponse = 1 << 16
+	// regCachePruneSize is the number of cached decisions at which expired
+	// decisions are removed from the cache.
+	regCachePruneSize = 10000
+)
+
+// RegistrationRequest describes a 'register' request for a RegistrationHook.
+type RegistrationRequest struct {
+	AccountID account.AccountID
+	PubKey    []byte
+	// IP is the client's IP address.
+	IP string
+}
+
+// RegistrationDecision is a RegistrationHook's decision on a registration.
+type RegistrationDecision struct {
+	Approved bool `json:"approved"`
+	// Reason is an explanation of a denial, which is sent to the client.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RegistrationHook decides whether an account may register, e.g. to apply the
+// operator's allow-list or identity verification policy. A RegistrationHook
+// is consulted for each 'register' request for an account that has no cached
+// decision and no admin override. A registration is refused if the hook
+// returns an error, so that an unavailable hook does not open registration.
+type RegistrationHook interface {
+	ApproveRegistration(ctx context.Context, req *RegistrationRequest) (*RegistrationDecision, error)
+}
+
+// HTTPRegistrationHook is a RegistrationHook that posts each registration to
+// an operator's HTTP endpoint. The request body is a JSON object with the
+// hex-encoded "accountid" and "pubkey", and the client's "ip". The endpoint
+// responds with a JSON RegistrationDecision and status 200. Any other status
+// is an error.
+type HTTPRegistrationHook struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPRegistrationHook is the constructor for an HTTPRegistrationHook that
+// posts to the URL.
+func NewHTTPRegistrationHook(url string) *HTTPRegistrationHook {
+	return &HTTPRegistrationHook{
+		url:    url,
+		client: &http.Client{Timeout: regHookTimeout},
+	}
+}
+
+// ApproveRegistration posts the registration to the hook's URL, and returns
+// the endpoint's decision. Part of the RegistrationHook interface.
+func (h *HTTPRegistrationHook) ApproveRegistration(ctx context.Context, reg *RegistrationRequest) (*RegistrationDecision, error) {
+	body, err := json.Marshal(&struct {
+		AccountID string `json:"accountid"`
+		PubKey    string `json:"pubkey"`
+		IP        string `json:"ip"`
+	}{
+		AccountID: reg.AccountID.String(),
+		PubKey:    hex.EncodeToString(reg.PubKey),
+		IP:        reg.IP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registration hook responded with status %s", resp.Status)
+	}
+	decision := new(RegistrationDecision)
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxRegHookResponse)).Decode(decision); err != nil {
+		return nil, fmt.Errorf("error decoding registration hook response: %w", err)
+	}
+	return decision, nil
+}
+
+// cachedDecision is a RegistrationHook's decision, and when it expires.
+type cachedDecision struct {
+	decision *RegistrationDecision
+	expire   time.Time
+}
+
+// RegistrationApproval describes how 'register' requests for an account are
+// decided.
+type RegistrationApproval struct {
+	// Override is the admin override, nil if there is none.
+	Override *bool `json:"override,omitempty"`
+	// Cached is the cached decision of the RegistrationHook, nil if there is
+	// none.
+	Cached *RegistrationDecision `json:"cached,omitempty"`
+	// CacheExpiry is when the cached decision expires.
+	CacheExpiry *time.Time `json:"cacheexpiry,omitempty"`
+}
+
+// SetRegistrationOverride approves or denies the registration of an account
+// regardless of the RegistrationHook, e.g. to resolve a case that the hook
+// could not decide. An override applies even if there is no hook. Overrides
+// are not persisted, so they are lost when the server restarts.
+func (auth *AuthManager) SetRegistrationOverride(aid account.AccountID, approve bool) {
+	auth.regMtx.Lock()
+	auth.regOverrides[aid] = approve
+	auth.regMtx.Unlock()
+	log.Infof("Registration of account %v %s by admin override.", aid, approvedStr(approve))
+}
+
+// ClearRegistrationOverride removes the admin override of an account, and
+// any cached decision, so that the RegistrationHook decides its next
+// registration.
+func (auth *AuthManager) ClearRegistrationOverride(aid account.AccountID) {
+	auth.regMtx.Lock()
+	delete(auth.regOverrides, aid)
+	delete(auth.regCache, aid)
+	auth.regMtx.Unlock()
+	log.Infof("Registration override of account %v cleared.", aid)
+}
+
+// RegistrationApproval returns the admin override and cached decision for
+// the registration of an account.
+func (auth *AuthManager) RegistrationApproval(aid account.AccountID) *RegistrationApproval {
+	auth.regMtx.Lock()
+	defer auth.regMtx.Unlock()
+	approval := new(RegistrationApproval)
+	if approve, found := auth.regOverrides[aid]; found {
+		approval.Override = &approve
+	}
+	if cached, found := auth.regCache[aid]; found && time.Now().Before(cached.expire) {
+		decision := *cached.decision
+		expire := cached.expire
+		approval.Cached, approval.CacheExpiry = &decision, &expire
+	}
+	return approval
+}
+
+// approveRegistration checks that the registration of an account is allowed,
+// first by any admin override, then by any cached decision, and finally by
+// the RegistrationHook. Registration is open if there is no hook.
+func (auth *AuthManager) approveRegistration(aid account.AccountID, pubKey []byte, ip string) *msgjson.Error {
+	auth.regMtx.Lock()
+	approve, overridden := auth.regOverrides[aid]
+	cached := auth.regCache[aid]
+	auth.regMtx.Unlock()
+
+	if overridden {
+		if !approve {
+			return msgjson.NewError(msgjson.RegistrationDeniedError, "registration denied by the operator")
+		}
+		return nil
+	}
+	if auth.regHook == nil {
+		return nil
+	}
+
+	var decision *RegistrationDecision
+	if cached != nil && time.Now().Before(cached.expire) {
+		decision = cached.decision
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), regHookTimeout)
+		defer cancel()
+		var err error
+		decision, err = auth.regHook.ApproveRegistration(ctx, &RegistrationRequest{
+			AccountID: aid,
+			PubKey:    pubKey,
+			IP:        ip,
+		})
+		if err == nil && decision == nil {
+			err = fmt.Errorf("no decision")
+		}
+		if err != nil {
+			log.Errorf("Registration hook error for account %v: %v", aid, err)
+			return msgjson.NewError(msgjson.RPCInternalError, "registration cannot be approved at this time")
+		}
+		now := time.Now()
+		auth.regMtx.Lock()
+		if len(auth.regCache) >= regCachePruneSize {
+			for id, c := range auth.regCache {
+				if now.After(c.expire) {
+					delete(auth.regCache, id)
+				}
+			}
+		}
+		auth.regCache[aid] = &cachedDecision{
+			decision: decision,
+			expire:   now.Add(auth.regCacheTTL),
+		}
+		auth.regMtx.Unlock()
+		log.Debugf("Registration of account %v from %s %s by hook.", aid, ip, approvedStr(decision.Approved))
+	}
+
+	if !decision.Approved {
+		msg := "registration denied"
+		if decision.Reason != "" {
+			msg += ": " + decision.Reason
+		}
+		return msgjson.NewError(msgjson.RegistrationDeniedError, msg)
+	}
+	return nil
+}
+
+func approvedStr(approved bool) string {
+	if approved {
+		return "approved"
+	}
+	return "denied"
+}
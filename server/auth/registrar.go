@@ -35,6 +35,11 @@ func (auth *AuthManager) handleRegister(conn comms.Link, msg *msgjson.Message) *
 		}
 	}
 
+	// Check any required proof of work before doing any other work.
+	if msgErr := auth.checkPoW(register.Serialize(), register.Time, register.Nonce); msgErr != nil {
+		return msgErr
+	}
+
 	// Create account.Account from pubkey.
 	acct, err := account.NewAccountFromPubKey(register.PubKey)
 	if err != nil {
@@ -54,6 +59,11 @@ func (auth *AuthManager) handleRegister(conn comms.Link, msg *msgjson.Message) *
 		}
 	}
 
+	// Check the operator's registration policy.
+	if msgErr := auth.approveRegistration(acct.ID, register.PubKey, conn.IP()); msgErr != nil {
+		return msgErr
+	}
+
 	// Register account and get a fee payment address.
 	feeAddr, err := auth.storage.CreateAccount(acct)
 	if err != nil {
@@ -0,0 +1,306 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
+)
+
+// maxStatusRequests is the maximum number of orders or matches in a single
+// order_status, match_status, or match_receipt request.
+const maxStatusRequests = 256
+
+// handleOrderStatus handles a client's request for the status of their orders.
+// Orders that are unknown or belong to another account are not included in the
+// response.
+func (auth *AuthManager) handleOrderStatus(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	var reqs []*msgjson.OrderStatusRequest
+	err := json.Unmarshal(msg.Payload, &reqs)
+	if err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: "error parsing order_status request: " + err.Error(),
+		}
+	}
+	if len(reqs) > maxStatusRequests {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: fmt.Sprintf("too many orders requested. max is %d", maxStatusRequests),
+		}
+	}
+	statuses := make([]*msgjson.OrderStatus, 0, len(reqs))
+	for _, req := range reqs {
+		if len(req.OrderID) != order.OrderIDSize {
+			return &msgjson.Error{
+				Code:    msgjson.RPCParseError,
+				Message: fmt.Sprintf("invalid order ID %s", req.OrderID),
+			}
+		}
+		var oid order.OrderID
+		copy(oid[:], req.OrderID)
+		ord, status, err := auth.storage.Order(oid, req.Base, req.Quote)
+		if err != nil {
+			if db.IsErrOrderUnknown(err) {
+				continue
+			}
+			log.Errorf("Order(%v): %v", oid, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "DB error",
+			}
+		}
+		if ord.User() != user {
+			continue
+		}
+		statuses = append(statuses, &msgjson.OrderStatus{
+			ID:     oid[:],
+			Status: uint16(status),
+		})
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, statuses, nil)
+	if err != nil {
+		log.Errorf("error creating order_status response: %v", err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "internal error",
+		}
+	}
+	if err = auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send order_status response to user %v: %v", user, err)
+	}
+	return nil
+}
+
+// handleMatchStatus handles a client's request for the server's record of
+// their matches. The response includes the signed match, audit, and redemption
+// messages for the user's side of the match, so that the client can process
+// any that it missed. Matches that are unknown or that the user is not a party
+// to are not included in the response.
+func (auth *AuthManager) handleMatchStatus(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	var reqs []*msgjson.MatchStatusRequest
+	err := json.Unmarshal(msg.Payload, &reqs)
+	if err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: "error parsing match_status request: " + err.Error(),
+		}
+	}
+	if len(reqs) > maxStatusRequests {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: fmt.Sprintf("too many matches requested. max is %d", maxStatusRequests),
+		}
+	}
+	results := make([]*msgjson.MatchStatusResult, 0, len(reqs))
+	for _, req := range reqs {
+		if len(req.MatchID) != order.MatchIDSize {
+			return &msgjson.Error{
+				Code:    msgjson.RPCParseError,
+				Message: fmt.Sprintf("invalid match ID %s", req.MatchID),
+			}
+		}
+		var mid order.MatchID
+		copy(mid[:], req.MatchID)
+		match, err := auth.storage.MatchByID(mid, req.Base, req.Quote)
+		if err == nil && match.MakerAcct != user && match.TakerAcct != user {
+			continue
+		}
+		var swap *db.SwapData
+		if err == nil {
+			_, swap, err = auth.storage.SwapData(db.MarketMatchID{
+				MatchID: mid,
+				Base:    req.Base,
+				Quote:   req.Quote,
+			})
+		}
+		if err != nil {
+			if db.IsErrMatchUnknown(err) {
+				continue
+			}
+			log.Errorf("match status for %v: %v", mid, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "DB error",
+			}
+		}
+		res, err := auth.matchStatus(user, match, swap)
+		if err != nil {
+			log.Errorf("error signing match status for %v: %v", mid, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "internal error",
+			}
+		}
+		results = append(results, res)
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, results, nil)
+	if err != nil {
+		log.Errorf("error creating match_status response: %v", err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "internal error",
+		}
+	}
+	if err = auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send match_status response to user %v: %v", user, err)
+	}
+	return nil
+}
+
+// matchStatus creates the MatchStatusResult for the user's side of the match.
+// The maker is the swap initiator, so the maker's counterparty data is the
+// taker's contract and redemption, and vice versa.
+func (auth *AuthManager) matchStatus(user account.AccountID, match *db.MatchData, swap *db.SwapData) (*msgjson.MatchStatusResult, error) {
+	oid, side, addr := match.Maker, order.Maker, match.TakerAddr
+	counterContract, counterContractCoin, counterContractTime := swap.ContractB, swap.ContractBCoinID, swap.ContractBTime
+	counterRedeemCoin, counterRedeemTime := swap.RedeemBCoinID, swap.RedeemBTime
+	if match.MakerAcct != user {
+		oid, side, addr = match.Taker, order.Taker, match.MakerAddr
+		counterContract, counterContractCoin, counterContractTime = swap.ContractA, swap.ContractACoinID, swap.ContractATime
+		counterRedeemCoin, counterRedeemTime = swap.RedeemACoinID, swap.RedeemATime
+	}
+	res := &msgjson.MatchStatusResult{
+		MatchID: match.ID[:],
+		Status:  uint8(match.Status),
+		Active:  match.Active,
+		Match: &msgjson.Match{
+			OrderID:  oid[:],
+			MatchID:  match.ID[:],
+			Quantity: match.Quantity,
+			Rate:     match.Rate,
+			// The match time is not stored, so the end of the matching epoch
+			// is used.
+			ServerTime:   (match.Epoch.Idx + 1) * match.Epoch.Dur,
+			Address:      addr,
+			FeeRateBase:  match.BaseRate,
+			FeeRateQuote: match.QuoteRate,
+			Status:       uint8(match.Status),
+			Side:         uint8(side),
+		},
+	}
+	signables := []msgjson.Signable{res.Match}
+	if len(counterContractCoin) > 0 {
+		res.Audit = &msgjson.Audit{
+			OrderID:  oid[:],
+			MatchID:  match.ID[:],
+			Time:     uint64(counterContractTime),
+			CoinID:   counterContractCoin,
+			Contract: counterContract,
+		}
+		signables = append(signables, res.Audit)
+	}
+	if len(counterRedeemCoin) > 0 {
+		res.Redemption = &msgjson.Redemption{
+			Redeem: msgjson.Redeem{
+				OrderID: oid[:],
+				MatchID: match.ID[:],
+				CoinID:  counterRedeemCoin,
+				Secret:  swap.RedeemASecret,
+			},
+			Time: uint64(counterRedeemTime),
+		}
+		signables = append(signables, res.Redemption)
+	}
+	if err := auth.Sign(signables...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// handleMatchReceipt handles a client's request for the signed settlement
+// receipts of their completed matches. Matches that are unknown, not complete,
+// or that the user is not a party to are not included in the response.
+func (auth *AuthManager) handleMatchReceipt(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	var reqs []*msgjson.MatchStatusRequest
+	err := json.Unmarshal(msg.Payload, &reqs)
+	if err != nil {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: "error parsing match_receipt request: " + err.Error(),
+		}
+	}
+	if len(reqs) > maxStatusRequests {
+		return &msgjson.Error{
+			Code:    msgjson.RPCParseError,
+			Message: fmt.Sprintf("too many matches requested. max is %d", maxStatusRequests),
+		}
+	}
+	receipts := make([]*msgjson.SettlementReceipt, 0, len(reqs))
+	for _, req := range reqs {
+		if len(req.MatchID) != order.MatchIDSize {
+			return &msgjson.Error{
+				Code:    msgjson.RPCParseError,
+				Message: fmt.Sprintf("invalid match ID %s", req.MatchID),
+			}
+		}
+		var mid order.MatchID
+		copy(mid[:], req.MatchID)
+		match, err := auth.storage.MatchByID(mid, req.Base, req.Quote)
+		if err == nil && (match.MakerAcct != user && match.TakerAcct != user ||
+			match.Status != order.MatchComplete) {
+			continue
+		}
+		var swap *db.SwapData
+		if err == nil {
+			_, swap, err = auth.storage.SwapData(db.MarketMatchID{
+				MatchID: mid,
+				Base:    req.Base,
+				Quote:   req.Quote,
+			})
+		}
+		if err != nil {
+			if db.IsErrMatchUnknown(err) {
+				continue
+			}
+			log.Errorf("match receipt for %v: %v", mid, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "DB error",
+			}
+		}
+		receipt := &msgjson.SettlementReceipt{
+			MatchID:      match.ID[:],
+			MakerOrderID: match.Maker[:],
+			TakerOrderID: match.Taker[:],
+			Base:         req.Base,
+			Quote:        req.Quote,
+			Quantity:     match.Quantity,
+			Rate:         match.Rate,
+			MakerSwap:    swap.ContractACoinID,
+			TakerSwap:    swap.ContractBCoinID,
+			MakerRedeem:  swap.RedeemACoinID,
+			TakerRedeem:  swap.RedeemBCoinID,
+			Time:         uint64(swap.RedeemBTime),
+		}
+		if err = auth.Sign(receipt); err != nil {
+			log.Errorf("error signing settlement receipt for %v: %v", mid, err)
+			return &msgjson.Error{
+				Code:    msgjson.RPCInternalError,
+				Message: "internal error",
+			}
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, receipts, nil)
+	if err != nil {
+		log.Errorf("error creating match_receipt response: %v", err)
+		return &msgjson.Error{
+			Code:    msgjson.RPCInternalError,
+			Message: "internal error",
+		}
+	}
+	if err = auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send match_receipt response to user %v: %v", user, err)
+	}
+	return nil
+}
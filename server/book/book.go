@@ -21,13 +21,17 @@ const (
 // which all inserted orders must have a quantity that is a multiple. The buy
 // and sell sides of the order book are contained in separate priority queues to
 // allow constant time access to the best orders, and log time insertion and
-// removal of orders.
+// removal of orders. Each side also keeps the aggregate quantity of its orders
+// at each price level, so that depth queries scale with the number of price
+// levels rather than the number of orders.
 type Book struct {
-	mtx     sync.RWMutex
-	lotSize uint64
-	halfCap uint32
-	buys    *OrderPQ
-	sells   *OrderPQ
+	mtx        sync.RWMutex
+	lotSize    uint64
+	halfCap    uint32
+	buys       *OrderPQ
+	sells      *OrderPQ
+	buyLevels  *levelSet
+	sellLevels *levelSet
 }
 
 // New creates a new order book with the given lot size, and optional order
@@ -39,10 +43,12 @@ func New(lotSize uint64, halfCapacity ...uint32) *Book {
 		halfCap = halfCapacity[0]
 	}
 	return &Book{
-		lotSize: lotSize,
-		halfCap: halfCap,
-		buys:    NewMaxOrderPQ(halfCap),
-		sells:   NewMinOrderPQ(halfCap),
+		lotSize:    lotSize,
+		halfCap:    halfCap,
+		buys:       NewMaxOrderPQ(halfCap),
+		sells:      NewMinOrderPQ(halfCap),
+		buyLevels:  newBuyLevels(),
+		sellLevels: newSellLevels(),
 	}
 }
 
@@ -50,7 +56,9 @@ func New(lotSize uint64, halfCapacity ...uint32) *Book {
 func (b *Book) Clear() {
 	b.mtx.Lock()
 	b.buys = NewMaxOrderPQ(b.halfCap)
-	b.sells = NewMaxOrderPQ(b.halfCap)
+	b.sells = NewMinOrderPQ(b.halfCap)
+	b.buyLevels = newBuyLevels()
+	b.sellLevels = newSellLevels()
 	b.mtx.Unlock()
 }
 
@@ -111,10 +119,15 @@ func (b *Book) Insert(o *order.LimitOrder) bool {
 	}
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
+	pq, levels := b.buys, b.buyLevels
 	if o.Sell {
-		return b.sells.Insert(o)
+		pq, levels = b.sells, b.sellLevels
 	}
-	return b.buys.Insert(o)
+	if !pq.Insert(o) {
+		return false
+	}
+	levels.add(o)
+	return true
 }
 
 // Remove attempts to remove the order with the given OrderID from the book.
@@ -122,14 +135,35 @@ func (b *Book) Remove(oid order.OrderID) (*order.LimitOrder, bool) {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
 	if removed, ok := b.sells.RemoveOrderID(oid); ok {
+		b.sellLevels.remove(removed)
 		return removed, true
 	}
 	if removed, ok := b.buys.RemoveOrderID(oid); ok {
+		b.buyLevels.remove(removed)
 		return removed, true
 	}
 	return nil, false
 }
 
+// Fill partially fills a booked order, updating the quantity of its price
+// level. The order remains on the book. Booked orders should not be filled by
+// other means. Fill returns false if the order is not on the book.
+func (b *Book) Fill(o *order.LimitOrder, amt uint64) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pq, levels := b.buys, b.buyLevels
+	if o.Sell {
+		pq, levels = b.sells, b.sellLevels
+	}
+	if !pq.HaveOrder(o.ID()) {
+		return false
+	}
+	shown := o.Displayed()
+	o.AddFill(amt)
+	levels.fill(o.Rate, shown-o.Displayed())
+	return true
+}
+
 // HaveOrder checks if an order is in either the buy or sell side of the book.
 func (b *Book) HaveOrder(oid order.OrderID) bool {
 	b.mtx.RLock()
@@ -167,3 +201,66 @@ func (b *Book) BuyOrders() []*order.LimitOrder {
 func (b *Book) BuyOrdersN(N int) []*order.LimitOrder {
 	return b.buys.OrdersN(N)
 }
+
+// SellLevels copies out the N best sell price levels, sorted. If N <= 0, all
+// levels are returned.
+func (b *Book) SellLevels(N int) []PriceLevel {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.sellLevels.levelsN(N)
+}
+
+// BuyLevels copies out the N best buy price levels, sorted. If N <= 0, all
+// levels are returned.
+func (b *Book) BuyLevels(N int) []PriceLevel {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.buyLevels.levelsN(N)
+}
+
+// LevelCounts returns the number of buy and sell price levels.
+func (b *Book) LevelCounts() (buys, sells int) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return len(b.buyLevels.rates), len(b.sellLevels.rates)
+}
+
+// Level returns the price level at the given rate on the specified side of the
+// book. The returned PriceLevel has a zero Count if there are no orders at the
+// rate.
+func (b *Book) Level(sell bool, rate uint64) PriceLevel {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	if sell {
+		return b.sellLevels.level(rate)
+	}
+	return b.buyLevels.level(rate)
+}
+
+// SellQuantityWithin returns the total remaining quantity of the sell orders
+// with a rate at or below the given rate. This is the base asset quantity that
+// a buy order at the rate could match.
+func (b *Book) SellQuantityWithin(rate uint64) uint64 {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.sellLevels.quantityWithin(rate)
+}
+
+// BuyQuantityWithin returns the total remaining quantity of the buy orders
+// with a rate at or above the given rate. This is the base asset quantity that
+// a sell order at the rate could match.
+func (b *Book) BuyQuantityWithin(rate uint64) uint64 {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.buyLevels.quantityWithin(rate)
+}
+
+// LevelDeltas returns the buy and sell price levels that changed since the
+// last call, each sorted from the best rate. A level that was emptied has a
+// zero Count and Quantity. The deltas may be used to broadcast book updates by
+// price level rather than by order.
+func (b *Book) LevelDeltas() (buys, sells []PriceLevel) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buyLevels.drainChanged(), b.sellLevels.drainChanged()
+}
@@ -265,3 +265,121 @@ func TestBook(t *testing.T) {
 		t.Errorf("buy side was not empty after Clear")
 	}
 }
+
+func TestBookLevels(t *testing.T) {
+	startLogger()
+
+	b := newBook(t)
+	nBuys, nSells := b.LevelCounts()
+	if nBuys != 8 || nSells != 9 {
+		t.Fatalf("Incorrect number of price levels. Got %d buy and %d sell, expected 8 and 9",
+			nBuys, nSells)
+	}
+	b.LevelDeltas() // drain the inserts
+
+	buyLevels := b.BuyLevels(3)
+	expBuys := []PriceLevel{
+		{Rate: 4500000, Quantity: 1 * LotSize, Count: 1},
+		{Rate: 4300000, Quantity: 6 * LotSize, Count: 2},
+		{Rate: 4000000, Quantity: 10 * LotSize, Count: 1},
+	}
+	if len(buyLevels) != len(expBuys) {
+		t.Fatalf("Incorrect number of buy levels. Got %d, expected %d",
+			len(buyLevels), len(expBuys))
+	}
+	for i := range expBuys {
+		if buyLevels[i] != expBuys[i] {
+			t.Errorf("Buy level %d incorrect. Got %+v, expected %+v", i, buyLevels[i], expBuys[i])
+		}
+	}
+
+	if sellLevels := b.SellLevels(0); len(sellLevels) != 9 {
+		t.Fatalf("Incorrect number of sell levels. Got %d, expected 9", len(sellLevels))
+	} else if sellLevels[0].Rate != bestSellOrder.Rate || sellLevels[8].Rate != 6200000 {
+		t.Errorf("Sell levels not sorted. Best rate %d, worst rate %d",
+			sellLevels[0].Rate, sellLevels[8].Rate)
+	}
+
+	if qty := b.SellQuantityWithin(4700000); qty != 17*LotSize {
+		t.Errorf("Incorrect sell quantity. Got %d, expected %d", qty, 17*LotSize)
+	}
+	if qty := b.BuyQuantityWithin(4300000); qty != 7*LotSize {
+		t.Errorf("Incorrect buy quantity. Got %d, expected %d", qty, 7*LotSize)
+	}
+
+	// Partially fill the older order at 4700000, and remove the newer one.
+	older, newer := bookSellOrders[8], bookSellOrders[7]
+	if !b.Fill(older, 3*LotSize) {
+		t.Fatalf("Failed to fill booked order")
+	}
+	if older.Remaining() != 7*LotSize {
+		t.Errorf("Incorrect remaining quantity. Got %d, expected %d", older.Remaining(), 7*LotSize)
+	}
+	if _, ok := b.Remove(newer.ID()); !ok {
+		t.Fatalf("Failed to remove order")
+	}
+	lvl := b.Level(true, 4700000)
+	if lvl.Quantity != 7*LotSize || lvl.Count != 1 {
+		t.Errorf("Incorrect level after fill and remove. Got %+v", lvl)
+	}
+
+	// Removing the only order at the best buy rate empties the level.
+	if _, ok := b.Remove(bestBuyOrder.ID()); !ok {
+		t.Fatalf("Failed to remove order")
+	}
+	if best := b.BuyLevels(1); best[0].Rate != 4300000 {
+		t.Errorf("Incorrect best buy level rate. Got %d, expected 4300000", best[0].Rate)
+	}
+
+	buyDeltas, sellDeltas := b.LevelDeltas()
+	if len(buyDeltas) != 1 || buyDeltas[0] != (PriceLevel{Rate: bestBuyOrder.Rate}) {
+		t.Errorf("Incorrect buy level deltas: %+v", buyDeltas)
+	}
+	if len(sellDeltas) != 1 || sellDeltas[0] != lvl {
+		t.Errorf("Incorrect sell level deltas: %+v", sellDeltas)
+	}
+	if buyDeltas, sellDeltas = b.LevelDeltas(); len(buyDeltas)+len(sellDeltas) != 0 {
+		t.Errorf("Level deltas not drained")
+	}
+
+	// An order not on the book cannot be filled.
+	if b.Fill(newer, LotSize) {
+		t.Errorf("Filled an order that is not on the book")
+	}
+	resetMakers()
+}
+
+func TestBookLevelsIceberg(t *testing.T) {
+	startLogger()
+
+	b := New(LotSize, DefaultBookHalfCapacity)
+	lo := newLimitOrder(true, 4500000, 10, order.StandingTiF, 0)
+	lo.Visible = 3 * LotSize
+	other := newLimitOrder(true, 4500000, 2, order.StandingTiF, 1)
+	for _, o := range []*order.LimitOrder{lo, other} {
+		if !b.Insert(o) {
+			t.Fatalf("Failed to insert order %v", o)
+		}
+	}
+
+	// Only the visible quantity of the iceberg order is shown until less than
+	// that remains.
+	for _, tt := range []struct {
+		fill, want uint64
+	}{{0, 5}, {5, 5}, {1, 5}, {2, 4}, {2, 2}} {
+		if tt.fill > 0 && !b.Fill(lo, tt.fill*LotSize) {
+			t.Fatalf("Failed to fill booked order")
+		}
+		if lvl := b.Level(true, 4500000); lvl.Quantity != tt.want*LotSize || lvl.Count != 2 {
+			t.Fatalf("Incorrect level after filling %d lots. Got %+v, expected quantity %d",
+				lo.Filled()/LotSize, lvl, tt.want*LotSize)
+		}
+	}
+
+	if _, ok := b.Remove(lo.ID()); !ok {
+		t.Fatalf("Failed to remove order")
+	}
+	if lvl := b.Level(true, 4500000); lvl.Quantity != 2*LotSize || lvl.Count != 1 {
+		t.Fatalf("Incorrect level after removing the iceberg order. Got %+v", lvl)
+	}
+}
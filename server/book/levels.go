@@ -0,0 +1,155 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package book
+
+import (
+	"sort"
+
+	"decred.org/dcrdex/dex/order"
+)
+
+// PriceLevel is the aggregate of the booked orders on one side of the book at
+// a given rate. Quantity is the total displayed quantity of the orders, in
+// atoms of the base asset, which excludes the hidden quantity of iceberg
+// orders. A PriceLevel with a zero Count indicates that the level was emptied.
+type PriceLevel struct {
+	Rate     uint64
+	Quantity uint64
+	Count    int
+}
+
+// levelSet maintains the price levels of one side of the book, sorted from the
+// best rate to the worst rate, and the rates of the levels that changed since
+// they were last drained.
+type levelSet struct {
+	better  func(ri, rj uint64) bool
+	rates   []uint64 // sorted, best first
+	levels  map[uint64]*PriceLevel
+	changed map[uint64]struct{}
+}
+
+func newLevelSet(better func(ri, rj uint64) bool) *levelSet {
+	return &levelSet{
+		better:  better,
+		levels:  make(map[uint64]*PriceLevel),
+		changed: make(map[uint64]struct{}),
+	}
+}
+
+// newBuyLevels creates a levelSet with the highest rate first.
+func newBuyLevels() *levelSet {
+	return newLevelSet(func(ri, rj uint64) bool { return ri > rj })
+}
+
+// newSellLevels creates a levelSet with the lowest rate first.
+func newSellLevels() *levelSet {
+	return newLevelSet(func(ri, rj uint64) bool { return ri < rj })
+}
+
+// search returns the index of the rate in the sorted rates, or the index where
+// it would be inserted.
+func (ls *levelSet) search(rate uint64) int {
+	return sort.Search(len(ls.rates), func(i int) bool {
+		return !ls.better(ls.rates[i], rate)
+	})
+}
+
+// add adds the order's displayed quantity to the level at its rate, creating
+// the level if needed.
+func (ls *levelSet) add(lo *order.LimitOrder) {
+	ls.changed[lo.Rate] = struct{}{}
+	lvl, found := ls.levels[lo.Rate]
+	if !found {
+		lvl = &PriceLevel{Rate: lo.Rate}
+		ls.levels[lo.Rate] = lvl
+		i := ls.search(lo.Rate)
+		ls.rates = append(ls.rates, 0)
+		copy(ls.rates[i+1:], ls.rates[i:])
+		ls.rates[i] = lo.Rate
+	}
+	lvl.Quantity += lo.Displayed()
+	lvl.Count++
+}
+
+// remove removes the order's displayed quantity from the level at its rate,
+// deleting the level if it has no more orders.
+func (ls *levelSet) remove(lo *order.LimitOrder) {
+	lvl, found := ls.levels[lo.Rate]
+	if !found {
+		log.Errorf("No price level at rate %d for order %v.", lo.Rate, lo)
+		return
+	}
+	ls.changed[lo.Rate] = struct{}{}
+	lvl.Quantity -= lo.Displayed()
+	lvl.Count--
+	if lvl.Count > 0 {
+		return
+	}
+	delete(ls.levels, lo.Rate)
+	i := ls.search(lo.Rate)
+	ls.rates = append(ls.rates[:i], ls.rates[i+1:]...)
+}
+
+// fill removes the reduction in an order's displayed quantity from the level at
+// the given rate.
+func (ls *levelSet) fill(rate, amt uint64) {
+	lvl, found := ls.levels[rate]
+	if !found {
+		log.Errorf("No price level at rate %d to fill.", rate)
+		return
+	}
+	ls.changed[rate] = struct{}{}
+	lvl.Quantity -= amt
+}
+
+// levelsN copies out the N best price levels. If N <= 0, all levels are
+// returned.
+func (ls *levelSet) levelsN(N int) []PriceLevel {
+	if N <= 0 || N > len(ls.rates) {
+		N = len(ls.rates)
+	}
+	levels := make([]PriceLevel, 0, N)
+	for _, rate := range ls.rates[:N] {
+		levels = append(levels, *ls.levels[rate])
+	}
+	return levels
+}
+
+// level returns the price level at the given rate. The zero PriceLevel for the
+// rate is returned if there are no orders at the rate.
+func (ls *levelSet) level(rate uint64) PriceLevel {
+	if lvl, found := ls.levels[rate]; found {
+		return *lvl
+	}
+	return PriceLevel{Rate: rate}
+}
+
+// quantityWithin returns the total remaining quantity of the levels with rates
+// no worse than the given rate.
+func (ls *levelSet) quantityWithin(rate uint64) (qty uint64) {
+	for _, r := range ls.rates {
+		if ls.better(rate, r) {
+			break
+		}
+		qty += ls.levels[r].Quantity
+	}
+	return
+}
+
+// drainChanged returns the current state of the levels that changed since the
+// last call, sorted from best to worst rate, and resets the changed levels.
+func (ls *levelSet) drainChanged() []PriceLevel {
+	if len(ls.changed) == 0 {
+		return nil
+	}
+	deltas := make([]PriceLevel, 0, len(ls.changed))
+	for rate := range ls.changed {
+		deltas = append(deltas, ls.level(rate))
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		return ls.better(deltas[i].Rate, deltas[j].Rate)
+	})
+	ls.changed = make(map[uint64]struct{})
+	return deltas
+}
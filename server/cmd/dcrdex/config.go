@@ -6,6 +6,7 @@ package main
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -16,6 +17,8 @@ import (
 	"time"
 
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/server/comms"
 	"github.com/decred/dcrd/dcrutil/v2"
 	flags "github.com/jessevdk/go-flags"
 )
@@ -28,6 +31,7 @@ const (
 	defaultDataDirname         = "data"
 	defaultLogLevel            = "info"
 	defaultLogDirname          = "logs"
+	defaultLogFormat           = "text"
 	defaultMarketsConfFilename = "markets.json"
 	defaultMaxLogZips          = 16
 	defaultPGHost              = "127.0.0.1:5432"
@@ -42,6 +46,10 @@ const (
 	defaultRegFeeConfirms   = 4
 	defaultRegFeeAmount     = 1e8
 	defaultBroadcastTimeout = time.Minute
+
+	// dbLeaseTTL is how long the database lease of a running server lasts
+	// without renewal.
+	dbLeaseTTL = 30 * time.Second
 )
 
 var (
@@ -69,17 +77,39 @@ type dexConf struct {
 	RegFeeAmount     uint64
 	CancelThreshold  float64
 	Anarchy          bool
+	PoWBits          uint8
+	RegHookURL       string
+	RegHookCacheTTL  time.Duration
 	DEXPrivKeyPath   string
 	RPCCert          string
 	RPCKey           string
 	RPCListen        []string
+	WSListen         []string
+	DataListen       []string
+	ReusePort        bool
 	BroadcastTimeout time.Duration
 	AltDNSNames      []string
 	LogMaker         *dex.LoggerMaker
 	SigningKeyPW     []byte
 	AdminSrvOn       bool
-	AdminSrvAddr     string
+	AdminSrvAddrs    []string
+	AdminSrvAllow    []*net.IPNet
 	AdminSrvPW       []byte
+	AdminDashboard   bool
+	RecordDir        string
+	TraceEvents      int
+	Checkpoint       time.Duration
+	Standby          bool
+	FollowDir        string
+	PolicyFile       string
+	PenaltyFile      string
+	MarketImports    []string
+	NextKeyPath      string
+	KeyRotation      time.Time
+	UpgradeAPIVer    uint16
+	UpgradeDeadline  time.Time
+	UpgradeNotes     string
+	Preflight        bool
 }
 
 type flagsData struct {
@@ -90,6 +120,7 @@ type flagsData struct {
 	LogDir      string `long:"logdir" description:"Directory to log output."`
 	DebugLevel  string `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
 	MaxLogZips  int    `long:"maxlogzips" description:"The number of zipped log files created by the log rotator to be retained. Setting to 0 will keep all."`
+	LogFormat   string `long:"logformat" description:"Log output format {text, json}. The json format writes one record per line with subsystem and level fields, and market, account, and match fields for log lines about a market, account, or match."`
 	ShowVersion bool   `short:"V" long:"version" description:"Display version information and exit"`
 
 	Testnet bool `long:"testnet" description:"Use the test network (default mainnet)"`
@@ -98,6 +129,9 @@ type flagsData struct {
 	RPCCert     string   `long:"rpccert" description:"RPC server TLS certificate file"`
 	RPCKey      string   `long:"rpckey" description:"RPC server TLS private key file"`
 	RPCListen   []string `long:"rpclisten" description:"IP addresses on which the RPC server should listen for incoming connections"`
+	WSListen    []string `long:"wslisten" description:"IP addresses on which the RPC server should listen for websocket connections only, without the HTTP data API (e.g. the local address of an onion service)"`
+	DataListen  []string `long:"datalisten" description:"IP addresses on which the RPC server should serve only the HTTP data API, without websocket connections"`
+	ReusePort   bool     `long:"reuseport" description:"Set SO_REUSEPORT on the RPC listeners, so that a new dcrdex process can bind the same addresses while this one is drained with the admin server's drain request. Listening sockets passed by systemd socket activation are used automatically."`
 	AltDNSNames []string `long:"altdnsnames" description:"A list of hostnames to include in the RPC certificate (X509v3 Subject Alternative Name)"`
 
 	MarketsConfPath  string        `long:"marketsconfpath" description:"Path to the markets configuration JSON file."`
@@ -107,20 +141,46 @@ type flagsData struct {
 	RegFeeAmount     uint64        `long:"regfeeamount" description:"The registration fee amount in atoms."`
 	CancelThreshold  float64       `long:"cancelthresh" description:"Cancellation ratio threshold (cancels/completed)."`
 	Anarchy          bool          `long:"anarchy" description:"Do not enforce any rules."`
+	PoWBits          uint8         `long:"powbits" description:"Proof-of-work difficulty, in leading zero bits, required of register and connect requests to resist connection floods. Clients solve the puzzle automatically. May be changed with the admin server. Disabled if 0 (max 24)."`
+	RegHookURL       string        `long:"reghookurl" description:"URL of an HTTP endpoint that approves or denies each account registration, e.g. to apply an allow-list or identity verification policy. The endpoint is posted the account ID, pubkey, and IP address as JSON, and responds with {\"approved\": bool, \"reason\": string}. Registrations are refused if the endpoint cannot be reached. Decisions may be overridden with the admin server. Registration is open if not set."`
+	RegHookCacheTTL  time.Duration `long:"reghookcachettl" description:"How long a decision of the registration hook is reused for an account (default: 1h)."`
 	DEXPrivKeyPath   string        `long:"dexprivkeypath" description:"The path to a file containing the DEX private key for message signing."`
 
 	HTTPProfile bool   `long:"httpprof" short:"p" description:"Start HTTP profiler."`
 	CPUProfile  string `long:"cpuprofile" description:"File for CPU profiling."`
 
-	PGDBName           string `long:"pgdbname" description:"PostgreSQL DB name."`
-	PGUser             string `long:"pguser" description:"PostgreSQL DB user."`
-	PGPass             string `long:"pgpass" description:"PostgreSQL DB password."`
-	PGHost             string `long:"pghost" description:"PostgreSQL server host:port or UNIX socket (e.g. /run/postgresql)."`
-	ShowPGConfig       bool   `long:"showpgconfig" description:"Logs the PostgreSQL db configuration on system start up."`
-	SigningKeyPassword string `long:"signingkeypass" description:"Password for encrypting/decrypting the dex privkey. INSECURE. Do not set unless absolutely necessary."`
-	AdminSrvOn         bool   `long:"adminsrvon" description:"Turn on the admin server"`
-	AdminSrvAddr       string `long:"adminsrvaddr" description:"Administration HTTPS server address (default: 127.0.0.1:6542)"`
-	AdminSrvPassword   string `long:"adminsrvpass" description:"Admin server password. INSECURE. Do not set unless absolutely necessary."`
+	PGDBName           string   `long:"pgdbname" description:"PostgreSQL DB name."`
+	PGUser             string   `long:"pguser" description:"PostgreSQL DB user."`
+	PGPass             string   `long:"pgpass" description:"PostgreSQL DB password."`
+	PGHost             string   `long:"pghost" description:"PostgreSQL server host:port or UNIX socket (e.g. /run/postgresql)."`
+	ShowPGConfig       bool     `long:"showpgconfig" description:"Logs the PostgreSQL db configuration on system start up."`
+	SigningKeyPassword string   `long:"signingkeypass" description:"Password for encrypting/decrypting the dex privkey. INSECURE. Do not set unless absolutely necessary."`
+	AdminSrvOn         bool     `long:"adminsrvon" description:"Turn on the admin server"`
+	AdminSrvAddr       []string `long:"adminsrvaddr" description:"Administration HTTPS server address. May be specified multiple times. (default: 127.0.0.1:6542)"`
+	AdminSrvAllow      []string `long:"adminsrvallow" description:"Network in CIDR notation (e.g. 10.0.0.0/8) from which admin server requests are allowed. May be specified multiple times. Requests from all networks are allowed if not set."`
+	AdminSrvPassword   string   `long:"adminsrvpass" description:"Admin server password. INSECURE. Do not set unless absolutely necessary."`
+	AdminDashboard     bool     `long:"admindashboard" description:"Serve the operator dashboard web page at /dashboard on the admin server."`
+	RecordDir          string   `long:"recorddir" description:"Directory in which to record the order flow of each market for offline replay. Relative paths are relative to the appdata directory. Order flow is not recorded if not set."`
+	TraceEvents        int      `long:"traceevents" description:"Number of recent client request log events to retain for the admin server's trace request. Tracing is disabled if not set."`
+
+	Checkpoint time.Duration `long:"checkpoint" description:"How often to save the swap state to the data directory for a standby server to follow (e.g. 30s). Disabled if not set."`
+	Standby    bool          `long:"standby" description:"Run as a hot standby for a primary server that uses the same DB. The standby follows the primary's swap state checkpoints, and starts the DEX when promoted with the admin server's promote request. Promotion is refused while the primary holds its database lease, which it renews while running and gives up on shutdown. Requires the admin server."`
+	FollowDir  string        `long:"followdir" description:"Directory in which the primary server saves its swap state checkpoints, for use with --standby (default: the data directory)."`
+
+	PolicyFile string `long:"policyfile" description:"Path to a JSON file with the operator's policy document (fees, penalties, contact, and terms), which is signed with the DEX private key and published to clients. Relative paths are relative to the appdata directory."`
+
+	PenaltyPolicyFile string `long:"penaltypolicy" description:"Path to a JSON file with the penalty policy, which maps broken rules to their penalties. The policy may be changed while running with the admin server's policy request. Any broken rule closes the account if not set. Relative paths are relative to the appdata directory."`
+
+	ImportMarkets []string `long:"importmarket" description:"Path to a market export from the admin server's market export request, with orders and settling matches to import into the configured market of the same name on startup. May be specified multiple times. Relative paths are relative to the appdata directory."`
+
+	NextKeyPath string `long:"nextkeypath" description:"Path to a file containing the DEX private key that replaces the dexprivkeypath key at the keyrotation time. The rotation is announced to clients, which accept the new key automatically. The file is created if it does not exist, encrypted with the signing key password. Relative paths are relative to the appdata directory."`
+	KeyRotation string `long:"keyrotation" description:"The time at which the nextkeypath key replaces the current DEX signing key, in RFC3339 format (e.g. 2021-01-02T15:04:05Z). Required with --nextkeypath."`
+
+	UpgradeAPIVersion uint16 `long:"upgradeapiver" description:"Announce to clients that they must speak at least this API version from the upgradedeadline. Requires --upgradedeadline."`
+	UpgradeDeadline   string `long:"upgradedeadline" description:"The time from which the upgradeapiver API version is required, in RFC3339 format (e.g. 2021-01-02T15:04:05Z)."`
+	UpgradeNotes      string `long:"upgradenotes" description:"Notes on the announced upgrade for users, e.g. where to download the new client."`
+
+	Preflight bool `long:"preflight" description:"Check the markets configuration, database, and asset nodes, print a report, and exit without starting the DEX. The same checks are always run on startup."`
 }
 
 // cleanAndExpandPath expands environment variables and leading ~ in the passed
@@ -257,6 +317,7 @@ func loadConfig() (*dexConf, *procOpts, error) {
 		RPCCert:          defaultRPCCertFilename,
 		RPCKey:           defaultRPCKeyFilename,
 		DebugLevel:       defaultLogLevel,
+		LogFormat:        defaultLogFormat,
 		PGDBName:         defaultPGDBName,
 		PGUser:           defaultPGUser,
 		PGHost:           defaultPGHost,
@@ -450,18 +511,108 @@ func loadConfig() (*dexConf, *procOpts, error) {
 	if !filepath.IsAbs(cfg.DEXPrivKeyPath) {
 		cfg.DEXPrivKeyPath = filepath.Join(cfg.AppDataDir, cfg.DEXPrivKeyPath)
 	}
+	if cfg.RecordDir != "" {
+		cfg.RecordDir = cleanAndExpandPath(cfg.RecordDir)
+		if !filepath.IsAbs(cfg.RecordDir) {
+			cfg.RecordDir = filepath.Join(cfg.AppDataDir, cfg.RecordDir)
+		}
+	}
 
-	// Validate each RPC listen host:port.
-	var RPCListen []string
-	if len(cfg.RPCListen) == 0 {
-		RPCListen = []string{defaultRPCHost + ":" + defaultRPCPort}
+	if cfg.PolicyFile != "" {
+		cfg.PolicyFile = cleanAndExpandPath(cfg.PolicyFile)
+		if !filepath.IsAbs(cfg.PolicyFile) {
+			cfg.PolicyFile = filepath.Join(cfg.AppDataDir, cfg.PolicyFile)
+		}
+	}
+
+	if cfg.PenaltyPolicyFile != "" {
+		cfg.PenaltyPolicyFile = cleanAndExpandPath(cfg.PenaltyPolicyFile)
+		if !filepath.IsAbs(cfg.PenaltyPolicyFile) {
+			cfg.PenaltyPolicyFile = filepath.Join(cfg.AppDataDir, cfg.PenaltyPolicyFile)
+		}
 	}
-	for i := range cfg.RPCListen {
-		listen, err := normalizeNetworkAddress(cfg.RPCListen[i], defaultRPCHost, defaultRPCPort)
+
+	for i, path := range cfg.ImportMarkets {
+		path = cleanAndExpandPath(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.AppDataDir, path)
+		}
+		cfg.ImportMarkets[i] = path
+	}
+
+	var keyRotation time.Time
+	if (cfg.NextKeyPath == "") != (cfg.KeyRotation == "") {
+		return loadConfigError(fmt.Errorf("--nextkeypath and --keyrotation must be set together"))
+	}
+	if cfg.NextKeyPath != "" {
+		cfg.NextKeyPath = cleanAndExpandPath(cfg.NextKeyPath)
+		if !filepath.IsAbs(cfg.NextKeyPath) {
+			cfg.NextKeyPath = filepath.Join(cfg.AppDataDir, cfg.NextKeyPath)
+		}
+		if cfg.NextKeyPath == cfg.DEXPrivKeyPath {
+			return loadConfigError(fmt.Errorf("--nextkeypath must not be the dexprivkeypath"))
+		}
+		keyRotation, err = time.Parse(time.RFC3339, cfg.KeyRotation)
 		if err != nil {
-			return loadConfigError(err)
+			return loadConfigError(fmt.Errorf("invalid keyrotation time %q: %v", cfg.KeyRotation, err))
+		}
+	}
+
+	var upgradeDeadline time.Time
+	if (cfg.UpgradeAPIVersion == 0) != (cfg.UpgradeDeadline == "") {
+		return loadConfigError(fmt.Errorf("--upgradeapiver and --upgradedeadline must be set together"))
+	}
+	if cfg.UpgradeDeadline != "" {
+		upgradeDeadline, err = time.Parse(time.RFC3339, cfg.UpgradeDeadline)
+		if err != nil {
+			return loadConfigError(fmt.Errorf("invalid upgradedeadline time %q: %v", cfg.UpgradeDeadline, err))
+		}
+	}
+
+	if cfg.Standby {
+		if !cfg.AdminSrvOn {
+			return loadConfigError(fmt.Errorf("--standby requires --adminsrvon"))
+		}
+		if cfg.FollowDir == "" {
+			cfg.FollowDir = cfg.DataDir
 		}
-		RPCListen = append(RPCListen, listen)
+		cfg.FollowDir = cleanAndExpandPath(cfg.FollowDir)
+	}
+	if cfg.Checkpoint < 0 {
+		return loadConfigError(fmt.Errorf("invalid checkpoint interval %v", cfg.Checkpoint))
+	}
+	if cfg.TraceEvents < 0 {
+		return loadConfigError(fmt.Errorf("invalid number of trace events %d", cfg.TraceEvents))
+	}
+
+	// Validate each RPC listen host:port.
+	normalizeListeners := func(addrs []string) ([]string, error) {
+		listen := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			addr, err := normalizeNetworkAddress(addr, defaultRPCHost, defaultRPCPort)
+			if err != nil {
+				return nil, err
+			}
+			listen = append(listen, addr)
+		}
+		return listen, nil
+	}
+	RPCListen, err := normalizeListeners(cfg.RPCListen)
+	if err != nil {
+		return loadConfigError(err)
+	}
+	WSListen, err := normalizeListeners(cfg.WSListen)
+	if err != nil {
+		return loadConfigError(err)
+	}
+	DataListen, err := normalizeListeners(cfg.DataListen)
+	if err != nil {
+		return loadConfigError(err)
+	}
+	// With systemd socket activation, the inherited sockets are served even
+	// if no address is configured.
+	if len(RPCListen)+len(WSListen)+len(DataListen) == 0 && !comms.SocketActivated() {
+		RPCListen = []string{defaultRPCHost + ":" + defaultRPCPort}
 	}
 
 	// Initialize log rotation. After log rotation has been initialized, the
@@ -469,6 +620,13 @@ func loadConfig() (*dexConf, *procOpts, error) {
 	if cfg.MaxLogZips < 0 {
 		cfg.MaxLogZips = 0
 	}
+	switch cfg.LogFormat {
+	case "text":
+	case "json":
+		jsonLogs = true
+	default:
+		return loadConfigError(fmt.Errorf("invalid log format %q", cfg.LogFormat))
+	}
 	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename), cfg.MaxLogZips)
 
 	log.Infof("App data folder: %s", cfg.AppDataDir)
@@ -500,17 +658,40 @@ func loadConfig() (*dexConf, *procOpts, error) {
 		dbPort = uint16(port)
 	}
 
-	adminSrvAddr := defaultAdminSrvAddr
-	if cfg.AdminSrvAddr != "" {
-		_, port, err := net.SplitHostPort(cfg.AdminSrvAddr)
+	adminSrvAddrs := []string{defaultAdminSrvAddr}
+	if len(cfg.AdminSrvAddr) > 0 {
+		adminSrvAddrs = cfg.AdminSrvAddr
+	}
+	for _, addr := range adminSrvAddrs {
+		_, port, err := net.SplitHostPort(addr)
 		if err != nil {
-			return loadConfigError(fmt.Errorf("invalid admin server host %q: %v", cfg.AdminSrvAddr, err))
+			return loadConfigError(fmt.Errorf("invalid admin server host %q: %v", addr, err))
 		}
 		_, err = strconv.ParseUint(port, 10, 16)
 		if err != nil {
 			return loadConfigError(fmt.Errorf("invalid admin server port %q: %v", port, err))
 		}
-		adminSrvAddr = cfg.AdminSrvAddr
+	}
+	if cfg.PoWBits > msgjson.MaxPoWBits {
+		return loadConfigError(fmt.Errorf("--powbits %d exceeds the maximum of %d", cfg.PoWBits, msgjson.MaxPoWBits))
+	}
+	if cfg.RegHookURL != "" {
+		hookURL, err := url.Parse(cfg.RegHookURL)
+		if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") || hookURL.Host == "" {
+			return loadConfigError(fmt.Errorf("invalid --reghookurl %q", cfg.RegHookURL))
+		}
+	}
+	if cfg.RegHookCacheTTL < 0 {
+		return loadConfigError(fmt.Errorf("negative --reghookcachettl %v", cfg.RegHookCacheTTL))
+	}
+
+	adminSrvAllow := make([]*net.IPNet, 0, len(cfg.AdminSrvAllow))
+	for _, cidr := range cfg.AdminSrvAllow {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return loadConfigError(fmt.Errorf("invalid admin server allowed network %q: %v", cidr, err))
+		}
+		adminSrvAllow = append(adminSrvAllow, ipNet)
 	}
 
 	// If using {netname} then replace it with the network name.
@@ -531,17 +712,39 @@ func loadConfig() (*dexConf, *procOpts, error) {
 		RegFeeXPub:       cfg.RegFeeXPub,
 		CancelThreshold:  cfg.CancelThreshold,
 		Anarchy:          cfg.Anarchy,
+		PoWBits:          cfg.PoWBits,
+		RegHookURL:       cfg.RegHookURL,
+		RegHookCacheTTL:  cfg.RegHookCacheTTL,
 		DEXPrivKeyPath:   cfg.DEXPrivKeyPath,
 		RPCCert:          cfg.RPCCert,
 		RPCKey:           cfg.RPCKey,
 		RPCListen:        RPCListen,
+		WSListen:         WSListen,
+		DataListen:       DataListen,
+		ReusePort:        cfg.ReusePort,
 		BroadcastTimeout: cfg.BroadcastTimeout,
 		AltDNSNames:      cfg.AltDNSNames,
 		LogMaker:         logMaker,
 		SigningKeyPW:     []byte(cfg.SigningKeyPassword),
-		AdminSrvAddr:     adminSrvAddr,
+		AdminSrvAddrs:    adminSrvAddrs,
+		AdminSrvAllow:    adminSrvAllow,
 		AdminSrvOn:       cfg.AdminSrvOn,
 		AdminSrvPW:       []byte(cfg.AdminSrvPassword),
+		AdminDashboard:   cfg.AdminDashboard,
+		RecordDir:        cfg.RecordDir,
+		TraceEvents:      cfg.TraceEvents,
+		Checkpoint:       cfg.Checkpoint,
+		Standby:          cfg.Standby,
+		FollowDir:        cfg.FollowDir,
+		PolicyFile:       cfg.PolicyFile,
+		PenaltyFile:      cfg.PenaltyPolicyFile,
+		MarketImports:    cfg.ImportMarkets,
+		NextKeyPath:      cfg.NextKeyPath,
+		KeyRotation:      keyRotation,
+		UpgradeAPIVer:    cfg.UpgradeAPIVersion,
+		UpgradeDeadline:  upgradeDeadline,
+		UpgradeNotes:     cfg.UpgradeNotes,
+		Preflight:        cfg.Preflight,
 	}
 
 	opts := &procOpts{
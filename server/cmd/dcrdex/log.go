@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/wait"
 	"decred.org/dcrdex/dex/ws"
 	"decred.org/dcrdex/server/admin"
@@ -18,6 +19,7 @@ import (
 	dexsrv "decred.org/dcrdex/server/dex"
 	"decred.org/dcrdex/server/market"
 	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
 	"decred.org/dcrdex/server/swap"
 	"github.com/decred/slog"
 	"github.com/jrick/logrotate/rotator"
@@ -27,13 +29,26 @@ import (
 // the write-end pipe of an initialized log rotator.
 type logWriter struct{}
 
-// Write writes the data in p to standard out and the log rotator.
+// Write writes the data in p to standard out and the log rotator. If JSON
+// logging is enabled, the formatted lines are first converted to JSON records.
 func (logWriter) Write(p []byte) (n int, err error) {
+	out := p
+	if jsonLogs {
+		out, err = dex.JSONLogLines(p)
+		if err != nil {
+			return 0, err
+		}
+	}
 	if logRotator == nil {
-		return os.Stdout.Write(p)
+		_, err = os.Stdout.Write(out)
+	} else {
+		os.Stdout.Write(out)
+		_, err = logRotator.Write(out)
+	}
+	if err != nil {
+		return 0, err
 	}
-	os.Stdout.Write(p)
-	return logRotator.Write(p)
+	return len(p), nil
 }
 
 // Loggers per subsystem. A single backend logger is created and all subsystem
@@ -54,6 +69,10 @@ var (
 	// It should be closed on application shutdown.
 	logRotator *rotator.Rotator
 
+	// jsonLogs indicates that log lines should be written as JSON records
+	// rather than plain text. It is set from the logformat config option.
+	jsonLogs bool
+
 	log           = backendLog.Logger("MAIN")
 	dbLogger      = backendLog.Logger("DB")
 	dexmanLogger  = backendLog.Logger("DEX")
@@ -65,6 +84,7 @@ var (
 	matcherLogger = backendLog.Logger("MTCH")
 	waiterLogger  = backendLog.Logger("CHWT")
 	adminLogger   = backendLog.Logger("ADMN")
+	replayLogger  = backendLog.Logger("RPLY")
 )
 
 func init() {
@@ -79,6 +99,7 @@ func init() {
 	matcher.UseLogger(matcherLogger)
 	wait.UseLogger(waiterLogger)
 	admin.UseLogger(adminLogger)
+	replay.UseLogger(replayLogger)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -97,6 +118,7 @@ var subsystemLoggers = map[string]slog.Logger{
 	"BOOK":  bookLogger,
 	"MTCH":  matcherLogger,
 	"ADMN":  adminLogger,
+	"RPLY":  replayLogger,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -19,12 +19,16 @@ import (
 
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/admin"
 	_ "decred.org/dcrdex/server/asset/btc" // register btc asset
 	_ "decred.org/dcrdex/server/asset/dcr" // register dcr asset
 	_ "decred.org/dcrdex/server/asset/ltc" // register ltc asset
+	"decred.org/dcrdex/server/auth"
 	dexsrv "decred.org/dcrdex/server/dex"
+	"decred.org/dcrdex/server/market"
 	"decred.org/dcrdex/server/swap"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
@@ -44,7 +48,7 @@ func mainCore(ctx context.Context) error {
 	// Request admin server password if admin server is enabled and
 	// server password is not set in config.
 	var adminSrvAuthSHA [32]byte
-	if cfg.AdminSrvOn {
+	if cfg.AdminSrvOn && !cfg.Preflight {
 		if len(cfg.AdminSrvPW) == 0 {
 			adminSrvAuthSHA, err = admin.PasswordHashPrompt(ctx, "Admin interface password: ")
 			if err != nil {
@@ -93,6 +97,34 @@ func mainCore(ctx context.Context) error {
 	log.Infof("Found %d assets, loaded %d markets, for network %s",
 		len(assets), len(markets), strings.ToUpper(cfg.Network.String()))
 
+	dbConf := &dexsrv.DBConf{
+		DBName:       cfg.DBName,
+		Host:         cfg.DBHost,
+		User:         cfg.DBUser,
+		Port:         cfg.DBPort,
+		Pass:         cfg.DBPass,
+		ShowPGConfig: cfg.ShowPGConfig,
+	}
+
+	// With --preflight, only check the configuration, DB, and asset nodes.
+	if cfg.Preflight {
+		report := dexsrv.Preflight(&dexsrv.DexConf{
+			LogBackend: cfg.LogMaker,
+			Markets:    markets,
+			Assets:     assets,
+			Network:    cfg.Network,
+			DBConf:     dbConf,
+			RegFeeXPub: cfg.RegFeeXPub,
+			Anarchy:    cfg.Anarchy,
+		})
+		fmt.Print(report)
+		if report.Failed() {
+			return errors.New("preflight checks failed")
+		}
+		fmt.Println("Preflight checks passed.")
+		return nil
+	}
+
 	// Load, or create and save, the DEX signing key.
 	var privKey *secp256k1.PrivateKey
 	if len(cfg.SigningKeyPW) == 0 {
@@ -102,79 +134,137 @@ func mainCore(ctx context.Context) error {
 		}
 	}
 	privKey, err = dexKey(cfg.DEXPrivKeyPath, cfg.SigningKeyPW)
-	encode.ClearBytes(cfg.SigningKeyPW)
 	if err != nil {
+		encode.ClearBytes(cfg.SigningKeyPW)
 		return err
 	}
+	// Load, or create and save, the key that replaces the signing key at the
+	// scheduled rotation time.
+	var keyRotation *dexsrv.KeyRotation
+	if cfg.NextKeyPath != "" {
+		nextKey, err := dexKey(cfg.NextKeyPath, cfg.SigningKeyPW)
+		if err != nil {
+			encode.ClearBytes(cfg.SigningKeyPW)
+			return err
+		}
+		keyRotation = &dexsrv.KeyRotation{
+			NewKey:     nextKey,
+			Activation: cfg.KeyRotation,
+		}
+	}
+	encode.ClearBytes(cfg.SigningKeyPW)
 
-	// TODO: add dcrdex flags and config options: 1) specify state file, 2) do
-	// not load state, 3) load latest state without prompt.
-	log.Infof("Searching for swap state files in %q", cfg.DataDir)
-	stateFile, err := swap.LatestStateFile(cfg.DataDir)
-	if err != nil {
-		return fmt.Errorf("unable to read datadir: %v", err)
+	var upgrade *dexsrv.UpgradeConf
+	if cfg.UpgradeAPIVer > 0 {
+		upgrade = &dexsrv.UpgradeConf{
+			APIVersion: cfg.UpgradeAPIVer,
+			Deadline:   cfg.UpgradeDeadline,
+			Notes:      cfg.UpgradeNotes,
+		}
 	}
+
+	// A standby server loads the primary's swap state when it is promoted.
 	var state *swap.State
-	if stateFile != nil {
-		fmt.Printf("Load swapper state from file %q with time stamp %v? (y, n, or enter to abort) ",
-			stateFile.Name, encode.UnixTimeMilli(stateFile.Stamp))
-		scanner := bufio.NewScanner(os.Stdin)
-		scan := make(chan bool)
-		go func() {
-			scan <- scanner.Scan()
-		}()
-		// Wait for scanned line ending with newline or context done.
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-scan:
-		}
-		promptResp := scanner.Text()
-		err = scanner.Err()
+	if !cfg.Standby {
+		// TODO: add dcrdex flags and config options: 1) specify state file, 2) do
+		// not load state, 3) load latest state without prompt.
+		log.Infof("Searching for swap state files in %q", cfg.DataDir)
+		stateFile, err := swap.LatestStateFile(cfg.DataDir)
 		if err != nil {
-			return fmt.Errorf("input failed: %v", err)
+			return fmt.Errorf("unable to read datadir: %v", err)
 		}
+		if stateFile != nil {
+			fmt.Printf("Load swapper state from file %q with time stamp %v? (y, n, or enter to abort) ",
+				stateFile.Name, encode.UnixTimeMilli(stateFile.Stamp))
+			scanner := bufio.NewScanner(os.Stdin)
+			scan := make(chan bool)
+			go func() {
+				scan <- scanner.Scan()
+			}()
+			// Wait for scanned line ending with newline or context done.
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-scan:
+			}
+			promptResp := scanner.Text()
+			err = scanner.Err()
+			if err != nil {
+				return fmt.Errorf("input failed: %v", err)
+			}
 
-		var doLoad bool
-		switch strings.ToLower(promptResp) {
-		case "y", "yes":
-			doLoad = true
-		case "n", "no":
-		case "":
-			return errors.New("input aborted")
-		default:
-			return fmt.Errorf("invalid response: %q", promptResp)
-		}
+			var doLoad bool
+			switch strings.ToLower(promptResp) {
+			case "y", "yes":
+				doLoad = true
+			case "n", "no":
+			case "":
+				return errors.New("input aborted")
+			default:
+				return fmt.Errorf("invalid response: %q", promptResp)
+			}
 
-		if doLoad {
-			state, err = swap.LoadStateFile(stateFile.Name)
-			if err != nil {
-				return fmt.Errorf("failed to load swap state file %v: %v", stateFile.Name, err)
+			if doLoad {
+				state, err = swap.LoadStateFile(stateFile.Name)
+				if err != nil {
+					return fmt.Errorf("failed to load swap state file %v: %v", stateFile.Name, err)
+				}
+				log.Infof("Loaded swap state file %q, containing %d live matches with "+
+					"%d pending client acks and %d live coin waiters", stateFile.Name,
+					len(state.MatchTrackers), len(state.LiveAckers), len(state.LiveWaiters))
 			}
-			log.Infof("Loaded swap state file %q, containing %d live matches with "+
-				"%d pending client acks and %d live coin waiters", stateFile.Name,
-				len(state.MatchTrackers), len(state.LiveAckers), len(state.LiveWaiters))
+		} else {
+			log.Info("No swap state files found.")
 		}
-	} else {
-		log.Info("No swap state files found.")
 	}
 
+	var policy *dexsrv.PolicyConf
+	if cfg.PolicyFile != "" {
+		policy, err = dexsrv.LoadPolicyFile(cfg.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load policy file: %v", err)
+		}
+		log.Infof("Publishing policy document from %s", cfg.PolicyFile)
+	}
+
+	var penaltyPolicy *account.PenaltyPolicy
+	if cfg.PenaltyFile != "" {
+		penaltyPolicy, err = dexsrv.LoadPenaltyPolicyFile(cfg.PenaltyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load penalty policy: %v", err)
+		}
+		log.Infof("Loaded penalty policy from %s", cfg.PenaltyFile)
+	}
+
+	var regHook auth.RegistrationHook
+	if cfg.RegHookURL != "" {
+		regHook = auth.NewHTTPRegistrationHook(cfg.RegHookURL)
+		log.Infof("Registrations are decided by the hook at %s", cfg.RegHookURL)
+	}
+
+	var marketImports []*market.Export
+	for _, path := range cfg.MarketImports {
+		exp, err := dexsrv.LoadMarketExport(path)
+		if err != nil {
+			return fmt.Errorf("failed to load market export: %v", err)
+		}
+		log.Infof("Importing market %s from %s", exp.Market, path)
+		marketImports = append(marketImports, exp)
+	}
+
+	// The tracer is shared by the DEX and the admin server. It is nil if
+	// tracing is disabled.
+	tracer := trace.NewTracer(cfg.TraceEvents)
+
 	// Create the DEX manager.
 	dexConf := &dexsrv.DexConf{
-		SwapState:  state,
-		DataDir:    cfg.DataDir,
-		LogBackend: cfg.LogMaker,
-		Markets:    markets,
-		Assets:     assets,
-		Network:    cfg.Network,
-		DBConf: &dexsrv.DBConf{
-			DBName:       cfg.DBName,
-			Host:         cfg.DBHost,
-			User:         cfg.DBUser,
-			Port:         cfg.DBPort,
-			Pass:         cfg.DBPass,
-			ShowPGConfig: cfg.ShowPGConfig,
-		},
+		SwapState:        state,
+		DataDir:          cfg.DataDir,
+		LogBackend:       cfg.LogMaker,
+		Markets:          markets,
+		Assets:           assets,
+		Network:          cfg.Network,
+		DBConf:           dbConf,
 		RegFeeXPub:       cfg.RegFeeXPub,
 		RegFeeAmount:     cfg.RegFeeAmount,
 		RegFeeConfirms:   cfg.RegFeeConfirms,
@@ -186,22 +276,70 @@ func mainCore(ctx context.Context) error {
 			RPCCert:     cfg.RPCCert,
 			RPCKey:      cfg.RPCKey,
 			ListenAddrs: cfg.RPCListen,
+			Listeners:   rpcListeners(cfg.WSListen, cfg.DataListen),
 			AltDNSNames: cfg.AltDNSNames,
+			ReusePort:   cfg.ReusePort,
 		},
+		RecordDir:          cfg.RecordDir,
+		Tracer:             tracer,
+		CheckpointInterval: cfg.Checkpoint,
+		Policy:             policy,
+		KeyRotation:        keyRotation,
+		Upgrade:            upgrade,
+		MarketImports:      marketImports,
+		PoWDifficulty:      cfg.PoWBits,
+		PenaltyPolicy:      penaltyPolicy,
+
+		RegistrationHook:     regHook,
+		RegistrationCacheTTL: cfg.RegHookCacheTTL,
 	}
-	dexMan, err := dexsrv.NewDEX(dexConf)
-	if err != nil {
-		return err
+	// Every server holds the database lease while running the DEX, so that a
+	// standby can never be promoted while a primary is running, whether or not
+	// the primary writes checkpoints.
+	dexConf.LeaseTTL = dbLeaseTTL
+
+	// Check the configuration, DB, and asset nodes before binding any
+	// listeners.
+	report := dexsrv.Preflight(dexConf)
+	log.Infof("Preflight report:\n%s", report)
+	if report.Failed() {
+		return errors.New("preflight checks failed")
+	}
+
+	var dexMan *dexsrv.DEX
+	var standby *dexsrv.Standby
+	if cfg.Standby {
+		standby, err = dexsrv.NewStandby(dexConf, cfg.FollowDir)
+		if err != nil {
+			return err
+		}
+	} else {
+		dexMan, err = dexsrv.NewDEX(dexConf)
+		if err != nil {
+			return err
+		}
 	}
 
 	var wg sync.WaitGroup
 	if cfg.AdminSrvOn {
 		srvCFG := &admin.SrvConfig{
-			Core:    dexMan,
-			Addr:    cfg.AdminSrvAddr,
-			AuthSHA: adminSrvAuthSHA,
-			Cert:    cfg.RPCCert,
-			Key:     cfg.RPCKey,
+			AuthSHA:   adminSrvAuthSHA,
+			Cert:      cfg.RPCCert,
+			Key:       cfg.RPCKey,
+			Shutdown:  requestShutdown,
+			Dashboard: cfg.AdminDashboard,
+			Tracer:    tracer,
+		}
+		for _, addr := range cfg.AdminSrvAddrs {
+			srvCFG.Listeners = append(srvCFG.Listeners, &admin.Listener{
+				Addr:      addr,
+				AllowNets: cfg.AdminSrvAllow,
+			})
+		}
+		if standby != nil {
+			srvCFG.Standby = &standbyAdmin{standby}
+		} else {
+			srvCFG.Core = dexMan
 		}
 		adminServer, err := admin.NewServer(srvCFG)
 		if err != nil {
@@ -214,18 +352,81 @@ func mainCore(ctx context.Context) error {
 		}()
 	}
 
-	log.Info("The DEX is running. Hit CTRL+C to quit...")
+	// Drain the DEX on SIGTERM. A standby server can only be drained once
+	// promoted.
+	wg.Add(1)
+	go func() {
+		drainListener(ctx, func() <-chan struct{} {
+			dm := dexMan
+			if standby != nil {
+				dm = standby.DEX()
+			}
+			if dm == nil {
+				return nil
+			}
+			return dm.Drain()
+		})
+		wg.Done()
+	}()
+
+	if standby != nil {
+		wg.Add(1)
+		go func() {
+			standby.Run(ctx)
+			if dm := standby.DEX(); dm != nil {
+				stopOnFence(ctx, dm)
+			}
+			wg.Done()
+		}()
+		log.Infof("Standing by, following swap state in %q. Hit CTRL+C to quit...", cfg.FollowDir)
+	} else {
+		wg.Add(1)
+		go func() {
+			stopOnFence(ctx, dexMan)
+			wg.Done()
+		}()
+		log.Info("The DEX is running. Hit CTRL+C to quit...")
+	}
 	<-ctx.Done()
 	// Wait for the admin server to finish.
 	wg.Wait()
 
-	log.Info("Stopping DEX...")
-	dexMan.Stop()
+	if standby != nil {
+		dexMan = standby.DEX()
+	}
+	if dexMan != nil {
+		log.Info("Stopping DEX...")
+		dexMan.Stop()
+	}
 	log.Info("Bye!")
 
 	return nil
 }
 
+// stopOnFence requests shutdown if another server takes over the DEX by
+// acquiring its database lease.
+func stopOnFence(ctx context.Context, dm *dexsrv.DEX) {
+	select {
+	case <-dm.Fenced():
+		log.Critical("Another server has taken the database lease. Shutting down.")
+		requestShutdown()
+	case <-ctx.Done():
+	}
+}
+
+// rpcListeners creates the policies of the RPC server's websocket-only and
+// data API-only listeners.
+func rpcListeners(wsAddrs, dataAddrs []string) []*dexsrv.RPCListener {
+	listeners := make([]*dexsrv.RPCListener, 0, len(wsAddrs)+len(dataAddrs))
+	for _, addr := range wsAddrs {
+		listeners = append(listeners, &dexsrv.RPCListener{Addr: addr, NoDataAPI: true})
+	}
+	for _, addr := range dataAddrs {
+		listeners = append(listeners, &dexsrv.RPCListener{Addr: addr, NoWebsocket: true})
+	}
+	return listeners
+}
+
 func main() {
 	// Create a context that is canceled when a shutdown request is received
 	// via requestShutdown.
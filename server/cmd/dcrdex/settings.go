@@ -11,6 +11,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"decred.org/dcrdex/dex"
 	dexsrv "decred.org/dcrdex/server/dex"
@@ -22,6 +23,22 @@ type marketConfig struct {
 		Quote    string  `json:"quote"`
 		Duration uint64  `json:"epochDuration"`
 		MBBuffer float64 `json:"marketBuyBuffer"`
+		// TradeFee is an optional trade fee in basis points.
+		TradeFee uint32 `json:"tradeFee"`
+		// MaxOrderAge is an optional limit on how long an order may remain
+		// booked, in hours.
+		MaxOrderAge uint32 `json:"maxOrderAgeHours"`
+		// OrderQueueSize, MaxEpochOrders and MaxEpochBacklog optionally bound
+		// the work waiting for the market. See dex.MarketInfo.
+		OrderQueueSize  uint32 `json:"orderQueueSize"`
+		MaxEpochOrders  uint32 `json:"maxEpochOrders"`
+		MaxEpochBacklog uint32 `json:"maxEpochBacklog"`
+		// MinOrderValue is an optional minimum order value in units of the
+		// quote asset.
+		MinOrderValue uint64 `json:"minOrderValue"`
+		// OddLotPolicy is the optional odd lot policy, "keep" (the default)
+		// or "release".
+		OddLotPolicy string `json:"oddLotPolicy"`
 	} `json:"markets"`
 	Assets map[string]*dexsrv.AssetConf `json:"assets"`
 }
@@ -49,9 +66,10 @@ func loadMarketConf(network dex.Network, src io.Reader) ([]*dex.MarketInfo, []*d
 
 	log.Debug("-------------------- BEGIN parsed markets.json --------------------")
 	log.Debug("MARKETS")
-	log.Debug("                  Base         Quote   EpochDur")
+	log.Debug("                  Base         Quote   EpochDur   TradeFee   MaxOrderAge")
 	for i, mktConf := range conf.Markets {
-		log.Debugf("Market %d: % 12s  % 12s  % 8d ms", i, mktConf.Base, mktConf.Quote, mktConf.Duration)
+		log.Debugf("Market %d: % 12s  % 12s  % 8d ms  % 6d bps  % 8d h", i, mktConf.Base, mktConf.Quote,
+			mktConf.Duration, mktConf.TradeFee, mktConf.MaxOrderAge)
 	}
 	log.Debug("")
 
@@ -121,11 +139,28 @@ func loadMarketConf(network dex.Network, src io.Reader) ([]*dex.MarketInfo, []*d
 			continue
 		}
 
+		if mktConf.TradeFee >= 10000 {
+			return nil, nil, fmt.Errorf("trade fee of %d bps is invalid for market %s-%s",
+				mktConf.TradeFee, mktConf.Base, mktConf.Quote)
+		}
+
+		oddLotPolicy, err := dex.ParseOddLotPolicy(mktConf.OddLotPolicy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("market %s-%s: %w", mktConf.Base, mktConf.Quote, err)
+		}
+
 		mkt, err := dex.NewMarketInfoFromSymbols(baseConf.Symbol, quoteConf.Symbol,
 			baseConf.LotSize, mktConf.Duration, mktConf.MBBuffer)
 		if err != nil {
 			return nil, nil, err
 		}
+		mkt.TradeFee = mktConf.TradeFee
+		mkt.MaxOrderAge = time.Duration(mktConf.MaxOrderAge) * time.Hour
+		mkt.OrderQueueSize = mktConf.OrderQueueSize
+		mkt.MaxEpochOrders = mktConf.MaxEpochOrders
+		mkt.MaxEpochBacklog = mktConf.MaxEpochBacklog
+		mkt.MinOrderValue = mktConf.MinOrderValue
+		mkt.OddLotPolicy = oddLotPolicy
 		markets = append(markets, mkt)
 	}
 
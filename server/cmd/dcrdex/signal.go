@@ -9,6 +9,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"syscall"
 )
 
 // shutdownRequested checks if the Done channel of the given context has been
@@ -80,3 +81,37 @@ func shutdownListener() {
 		log.Info("Shutdown signaled. Already shutting down...")
 	}
 }
+
+// drainListener listens for SIGTERM, and shuts down gracefully by draining the
+// DEX and requesting shutdown once all active swaps are done. The drain
+// function returns nil if there is no running DEX to drain, in which case
+// shutdown is requested immediately. A second SIGTERM also requests shutdown
+// immediately. drainListener returns when ctx is canceled.
+func drainListener(ctx context.Context, drain func() <-chan struct{}) {
+	termChannel := make(chan os.Signal, 1)
+	signal.Notify(termChannel, syscall.SIGTERM)
+	defer signal.Stop(termChannel)
+
+	var drained <-chan struct{}
+	for {
+		select {
+		case sig := <-termChannel:
+			if drained != nil {
+				log.Infof("Received signal (%s) while draining. Shutting down now...", sig)
+				requestShutdown()
+				return
+			}
+			if drained = drain(); drained == nil {
+				log.Infof("Received signal (%s). Shutting down...", sig)
+				requestShutdown()
+				return
+			}
+			log.Infof("Received signal (%s). Draining. Shutting down when active swaps are done...", sig)
+		case <-drained: // nil until draining
+			requestShutdown()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package main
+
+import (
+	"decred.org/dcrdex/server/admin"
+	dexsrv "decred.org/dcrdex/server/dex"
+)
+
+// standbyAdmin satisfies admin.Standby for a dex Standby.
+type standbyAdmin struct {
+	standby *dexsrv.Standby
+}
+
+// Promote promotes the standby server, returning the running DEX.
+func (s *standbyAdmin) Promote() (admin.SvrCore, error) {
+	dexMan, err := s.standby.Promote()
+	if err != nil {
+		return nil, err
+	}
+	return dexMan, nil
+}
+
+// StandbyStatus describes the standby server for the admin server.
+func (s *standbyAdmin) StandbyStatus() *admin.StandbyStatus {
+	status := s.standby.Status()
+	res := &admin.StandbyStatus{
+		Standby:     true,
+		Promoted:    status.Promoted,
+		Promoting:   status.Promoting,
+		StateFile:   status.StateFile,
+		LiveMatches: status.LiveMatches,
+	}
+	if !status.StateTime.IsZero() {
+		res.StateTime = &admin.APITime{Time: status.StateTime}
+	}
+	if status.StateErr != nil {
+		res.StateError = status.StateErr.Error()
+	}
+	return res
+}
@@ -0,0 +1,10 @@
+module decred.org/dcrdex/server/cmd/dexreplay
+
+go 1.13
+
+replace decred.org/dcrdex => ../../..
+
+require (
+	decred.org/dcrdex v0.0.0-00010101000000-000000000000
+	github.com/decred/slog v1.0.0
+)
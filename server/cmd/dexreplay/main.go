@@ -0,0 +1,68 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// dexreplay replays order flow recordings made by dcrdex with the recorddir
+// option through the matcher, and reports any epochs with results that differ
+// from the recording.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"decred.org/dcrdex/server/replay"
+	"github.com/decred/slog"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	verbose := flag.Bool("v", false, "Log each mismatch as it is found.")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dexreplay [-v] recording...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 1
+	}
+
+	if *verbose {
+		logger := slog.NewBackend(os.Stderr).Logger("RPLY")
+		logger.SetLevel(slog.LevelDebug)
+		replay.UseLogger(logger)
+	}
+
+	var failed bool
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		summary, err := replay.Replay(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		fmt.Printf("%s: market %s, %d epochs, %d orders, %d matches, %d mismatches\n",
+			path, summary.Market, summary.Epochs, summary.Orders, summary.Matches,
+			len(summary.Mismatches))
+		for _, mm := range summary.Mismatches {
+			fmt.Printf("    %v\n", mm)
+		}
+		if len(summary.Mismatches) > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,282 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package comms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// apiKeyHeader is the HTTP request header that carries an API key. The key
+	// may also be provided with the apiKeyParam URL query parameter.
+	apiKeyHeader = "X-API-Key"
+	apiKeyParam  = "key"
+
+	// anonDataRateLimit is the number of HTTP data API requests allowed per
+	// rateWindow from an IP address that does not provide an API key.
+	anonDataRateLimit = 60
+
+	// rateWindow is the period over which the HTTP data API request limits
+	// are counted.
+	rateWindow = time.Minute
+)
+
+var (
+	errUnknownAPIKey  = errors.New("unknown or revoked API key")
+	errMarketNotKeyed = errors.New("API key is not valid for the market")
+	errRateLimited    = errors.New("too many requests")
+)
+
+// APIKey is a key issued by the operator to a consumer of the HTTP data API.
+// The key is required for the market data routes, and is limited to RateLimit
+// requests per minute. A key without Markets is valid for all markets.
+type APIKey struct {
+	Key       string    `json:"key"`
+	Label     string    `json:"label"`
+	Markets   []string  `json:"markets,omitempty"`
+	RateLimit uint32    `json:"rateLimit"`
+	Created   time.Time `json:"created"`
+	Revoked   bool      `json:"revoked,omitempty"`
+	// Requests is the number of requests made with the key, not counting
+	// requests that were rejected for exceeding the rate limit.
+	Requests uint64    `json:"requests"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+}
+
+// validFor checks if the key may be used for the market. All keys are valid for
+// the routes that are not market-specific, indicated by an empty market name.
+func (k *APIKey) validFor(mkt string) bool {
+	if mkt == "" || len(k.Markets) == 0 {
+		return true
+	}
+	for _, m := range k.Markets {
+		if m == mkt {
+			return true
+		}
+	}
+	return false
+}
+
+// rateCounter counts requests in fixed windows of rateWindow.
+type rateCounter struct {
+	windowStart time.Time
+	count       uint32
+}
+
+// allow counts a request at time now, and checks that the count for the
+// current window does not exceed the limit.
+func (rc *rateCounter) allow(now time.Time, limit uint32) bool {
+	if now.Sub(rc.windowStart) >= rateWindow {
+		rc.windowStart, rc.count = now, 0
+	}
+	if rc.count >= limit {
+		return false
+	}
+	rc.count++
+	return true
+}
+
+// apiKey is an APIKey with its request counter.
+type apiKey struct {
+	APIKey
+	rate rateCounter
+}
+
+// APIKeyring manages the API keys issued for the HTTP data API, and meters
+// their use. The keys and their usage are stored as JSON in a file.
+type APIKeyring struct {
+	path string
+
+	mtx  sync.Mutex
+	keys map[string]*apiKey
+}
+
+// NewAPIKeyring is the constructor for an APIKeyring. The keys are loaded from
+// the file at path, if it exists.
+func NewAPIKeyring(path string) (*APIKeyring, error) {
+	kr := &APIKeyring{
+		path: path,
+		keys: make(map[string]*apiKey),
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return kr, nil
+		}
+		return nil, fmt.Errorf("error reading API keys: %w", err)
+	}
+	var keys []*APIKey
+	if err = json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("error decoding API keys: %w", err)
+	}
+	for _, k := range keys {
+		kr.keys[k.Key] = &apiKey{APIKey: *k}
+	}
+	return kr, nil
+}
+
+// Issue creates and stores a new API key. A rateLimit of zero is not allowed.
+func (kr *APIKeyring) Issue(label string, markets []string, rateLimit uint32) (*APIKey, error) {
+	if rateLimit == 0 {
+		return nil, fmt.Errorf("zero rate limit")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating API key: %w", err)
+	}
+	k := &apiKey{APIKey: APIKey{
+		Key:       hex.EncodeToString(b),
+		Label:     label,
+		Markets:   markets,
+		RateLimit: rateLimit,
+		Created:   time.Now().UTC(),
+	}}
+
+	kr.mtx.Lock()
+	defer kr.mtx.Unlock()
+	kr.keys[k.Key] = k
+	if err := kr.save(); err != nil {
+		delete(kr.keys, k.Key)
+		return nil, err
+	}
+	key := k.APIKey
+	return &key, nil
+}
+
+// Revoke revokes the API key. The key is kept with its usage for reference.
+func (kr *APIKeyring) Revoke(key string) error {
+	kr.mtx.Lock()
+	defer kr.mtx.Unlock()
+	k, found := kr.keys[key]
+	if !found {
+		return fmt.Errorf("unknown API key")
+	}
+	if k.Revoked {
+		return nil
+	}
+	k.Revoked = true
+	if err := kr.save(); err != nil {
+		k.Revoked = false
+		return err
+	}
+	return nil
+}
+
+// Keys returns all issued API keys, including revoked keys, with their usage,
+// sorted by creation time.
+func (kr *APIKeyring) Keys() []*APIKey {
+	kr.mtx.Lock()
+	defer kr.mtx.Unlock()
+	keys := make([]*APIKey, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		key := k.APIKey
+		keys = append(keys, &key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys
+}
+
+// Save stores the keys with their current usage.
+func (kr *APIKeyring) Save() error {
+	kr.mtx.Lock()
+	defer kr.mtx.Unlock()
+	return kr.save()
+}
+
+// save stores the keys. The mtx must be locked.
+func (kr *APIKeyring) save() error {
+	keys := make([]*APIKey, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		keys = append(keys, &k.APIKey)
+	}
+	b, err := json.MarshalIndent(keys, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error encoding API keys: %w", err)
+	}
+	if err = ioutil.WriteFile(kr.path, b, 0600); err != nil {
+		return fmt.Errorf("error writing API keys: %w", err)
+	}
+	return nil
+}
+
+// use meters a request with the API key for the market, which is empty for the
+// routes that are not market-specific.
+func (kr *APIKeyring) use(key, mkt string) error {
+	kr.mtx.Lock()
+	defer kr.mtx.Unlock()
+	k, found := kr.keys[key]
+	if !found || k.Revoked {
+		return errUnknownAPIKey
+	}
+	if !k.validFor(mkt) {
+		return errMarketNotKeyed
+	}
+	now := time.Now()
+	if !k.rate.allow(now, k.RateLimit) {
+		return errRateLimited
+	}
+	k.Requests++
+	k.LastUsed = now.UTC()
+	return nil
+}
+
+// ipLimiter limits the HTTP data API requests of each IP address that does not
+// provide an API key. The counts are reset together at the start of each
+// window, so addresses are not tracked for longer than rateWindow.
+type ipLimiter struct {
+	mtx         sync.Mutex
+	windowStart time.Time
+	counts      map[string]uint32
+}
+
+func newIPLimiter() *ipLimiter {
+	return &ipLimiter{counts: make(map[string]uint32)}
+}
+
+// allow counts a request from the IP address, and checks that the count for
+// the current window does not exceed anonDataRateLimit.
+func (l *ipLimiter) allow(ip string) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now()
+	if now.Sub(l.windowStart) >= rateWindow {
+		l.windowStart = now
+		l.counts = make(map[string]uint32)
+	}
+	if l.counts[ip] >= anonDataRateLimit {
+		return false
+	}
+	l.counts[ip]++
+	return true
+}
+
+// requestAPIKey gets the API key from the request header or query, if provided.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	return r.URL.Query().Get(apiKeyParam)
+}
+
+// apiKeyStatus is the HTTP status of a response to a request rejected with the
+// error from (*APIKeyring).use.
+func apiKeyStatus(err error) int {
+	switch {
+	case errors.Is(err, errRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errMarketNotKeyed):
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
@@ -10,10 +10,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -22,6 +25,7 @@ import (
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/ws"
+	"github.com/go-chi/chi"
 	"github.com/gorilla/websocket"
 )
 
@@ -34,6 +38,7 @@ func newServer() *Server {
 	return &Server{
 		clients:    make(map[uint64]*wsLink),
 		quarantine: make(map[string]time.Time),
+		ipLimiter:  newIPLimiter(),
 	}
 }
 
@@ -259,6 +264,178 @@ func TestRoute_PanicsDoubleRegistry(t *testing.T) {
 	Route("somemethod", dummyRPCHandler)
 }
 
+// HTTP routes cannot be registered more than once.
+func TestRegisterHTTP_PanicsDoubleRegistry(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("no panic on double HTTP route registration")
+		}
+	}()
+	handler := func() (interface{}, error) { return nil, nil }
+	RegisterHTTP("someroute", handler)
+	RegisterHTTP("someroute", handler)
+}
+
+func TestHTTPRouteHandler(t *testing.T) {
+	var thing interface{}
+	var handlerErr error
+	handler := httpRouteHandler("test", func() (interface{}, error) {
+		return thing, handlerErr
+	})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/api/test", nil))
+		return w
+	}
+
+	thing = map[string]int{"a": 1}
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"a":1}` {
+		t.Fatalf("unexpected response body %q", body)
+	}
+
+	handlerErr = tErr
+	w = get()
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 for handler error, got %d", w.Code)
+	}
+	handlerErr = nil
+
+	// Unencodable result.
+	thing = make(chan int)
+	w = get()
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 for encoding error, got %d", w.Code)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "apikeys.json")
+	kr, err := NewAPIKeyring(path)
+	if err != nil {
+		t.Fatalf("NewAPIKeyring error: %v", err)
+	}
+
+	if _, err = kr.Issue("partner", nil, 0); err == nil {
+		t.Fatalf("no error for zero rate limit")
+	}
+	key, err := kr.Issue("partner", []string{"dcr_btc"}, 2)
+	if err != nil {
+		t.Fatalf("Issue error: %v", err)
+	}
+	allKey, err := kr.Issue("all", nil, 10)
+	if err != nil {
+		t.Fatalf("Issue error: %v", err)
+	}
+
+	s := newServer()
+	s.apiKeys = kr
+	handler := s.requireAPIKey(marketHTTPRouteHandler("depth", func(mkt string) (interface{}, error) {
+		return mkt, nil
+	}))
+	mux := chi.NewRouter()
+	mux.Get("/api/depth/{market}", handler)
+	get := func(path, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			r.Header.Set(apiKeyHeader, key)
+		}
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("/api/depth/dcr_btc", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without key, got %d", w.Code)
+	}
+	if w := get("/api/depth/dcr_btc", "abcd"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 for unknown key, got %d", w.Code)
+	}
+	if w := get("/api/depth/btc_ltc", key.Key); w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for other market, got %d", w.Code)
+	}
+	w := get("/api/depth/dcr_btc", key.Key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `"dcr_btc"` {
+		t.Fatalf("unexpected response body %q", body)
+	}
+	if w = get("/api/depth/dcr_btc?"+apiKeyParam+"="+key.Key, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for key in query, got %d", w.Code)
+	}
+	if w = get("/api/depth/dcr_btc", key.Key); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 over rate limit, got %d", w.Code)
+	}
+	if w = get("/api/depth/btc_ltc", allKey.Key); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for key for all markets, got %d", w.Code)
+	}
+
+	if err = kr.Revoke(allKey.Key); err != nil {
+		t.Fatalf("Revoke error: %v", err)
+	}
+	if w = get("/api/depth/btc_ltc", allKey.Key); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 for revoked key, got %d", w.Code)
+	}
+	if err = kr.Revoke("abcd"); err == nil {
+		t.Fatalf("no error revoking unknown key")
+	}
+
+	// The keys and usage are reloaded from the file.
+	if err = kr.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	kr, err = NewAPIKeyring(path)
+	if err != nil {
+		t.Fatalf("NewAPIKeyring error: %v", err)
+	}
+	keys := kr.Keys()
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(keys))
+	}
+	if keys[0].Key != key.Key || keys[0].Requests != 2 || keys[0].Revoked {
+		t.Fatalf("unexpected first key %+v", keys[0])
+	}
+	if keys[1].Key != allKey.Key || keys[1].Requests != 1 || !keys[1].Revoked {
+		t.Fatalf("unexpected second key %+v", keys[1])
+	}
+}
+
+func TestLimitDataAPI(t *testing.T) {
+	s := newServer()
+	handler := s.limitDataAPI(httpRouteHandler("test", func() (interface{}, error) {
+		return 1, nil
+	}))
+	get := func(ip string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/test", nil)
+		r.RemoteAddr = ip + ":1234"
+		handler(w, r)
+		return w.Code
+	}
+	for i := 0; i < anonDataRateLimit; i++ {
+		if code := get("10.0.0.1"); code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i, code)
+		}
+	}
+	if code := get("10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 over rate limit, got %d", code)
+	}
+	if code := get("10.0.0.2"); code != http.StatusOK {
+		t.Fatalf("expected status 200 for other IP, got %d", code)
+	}
+}
+
 // Test the server with a stub for the client connections.
 func TestClientRequests(t *testing.T) {
 	server := newServer()
@@ -828,3 +1005,358 @@ func TestParseListeners(t *testing.T) {
 		t.Fatal("no error with invalid address")
 	}
 }
+
+func TestDrain(t *testing.T) {
+	server := newServer()
+	var wg sync.WaitGroup
+	defer func() {
+		server.disconnectClients()
+		wg.Wait()
+	}()
+
+	handled := make(chan interface{}, 1)
+	Route("drainkeep", func(_ Link, _ *msgjson.Message) *msgjson.Error {
+		handled <- nil
+		return nil
+	})
+	Route("draindrop", func(_ Link, _ *msgjson.Message) *msgjson.Error {
+		t.Fatalf("draindrop handled while draining")
+		return nil
+	})
+
+	conn := newWsStub()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.websocketHandler(testCtx, conn, "testaddr")
+	}()
+	if !giveItASecond(func() bool {
+		return server.clientCount() == 1
+	}) {
+		t.Fatalf("failed to add client")
+	}
+
+	if server.Draining() {
+		t.Fatalf("server draining before Drain")
+	}
+	server.Drain("drainkeep")
+	if !server.Draining() {
+		t.Fatalf("server not draining after Drain")
+	}
+
+	// A drained route gets an error response.
+	conn.addChan()
+	sendToConn(t, conn, "draindrop", `{}`)
+	var b []byte
+	select {
+	case b = <-conn.recv:
+	case <-time.NewTimer(time.Second).C:
+		t.Fatalf("no response for drained route")
+	}
+	msg, err := msgjson.DecodeMessage(b)
+	if err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	resp, err := msg.Response()
+	if err != nil {
+		t.Fatalf("error decoding response payload: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != msgjson.ServerDrainingError {
+		t.Fatalf("expected a ServerDrainingError, got %v", resp.Error)
+	}
+
+	// A route kept alive is still handled.
+	conn.recv = nil
+	sendToConn(t, conn, "drainkeep", `{}`)
+	readChannel(t, "drainkeep", handled)
+
+	if server.clientCount() != 1 {
+		t.Fatalf("client disconnected while draining")
+	}
+}
+
+func TestListenerPolicies(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "listeners")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	RegisterHTTP("policytest", func() (interface{}, error) {
+		return "ok", nil
+	})
+	defer delete(httpRoutes, "policytest")
+
+	// A listener that offers no services is an error.
+	_, err = NewServer(&RPCConfig{
+		Listeners: []*Listener{{Addr: "127.0.0.1:0", NoWebsocket: true, NoDataAPI: true}},
+		RPCKey:    filepath.Join(tempDir, "rpc.key"),
+		RPCCert:   filepath.Join(tempDir, "rpc.cert"),
+	})
+	if err == nil {
+		t.Fatalf("no error for listener without services")
+	}
+
+	server, err := NewServer(&RPCConfig{
+		ListenAddrs: []string{"127.0.0.1:0"},
+		Listeners: []*Listener{
+			{Addr: "127.0.0.1:0", NoDataAPI: true},
+			{Addr: "127.0.0.1:0", NoWebsocket: true},
+		},
+		RPCKey:  filepath.Join(tempDir, "rpc.key"),
+		RPCCert: filepath.Join(tempDir, "rpc.cert"),
+	})
+	if err != nil {
+		t.Fatalf("server constructor error: %v", err)
+	}
+	if len(server.listeners) != 3 {
+		t.Fatalf("expected 3 listeners, got %d", len(server.listeners))
+	}
+
+	ssw := dex.NewStartStopWaiter(server)
+	ssw.Start(testCtx)
+	defer func() {
+		ssw.Stop()
+		ssw.WaitForShutdown()
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	get := func(l *policyListener, path string) int {
+		t.Helper()
+		resp, err := client.Get("https://" + l.Addr().String() + path)
+		if err != nil {
+			t.Fatalf("GET %s error: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i, l := range server.listeners {
+		wantAPI, wantWS := !l.policy.NoDataAPI, !l.policy.NoWebsocket
+		if code := get(l, "/api/policytest"); (code != http.StatusNotFound) != wantAPI {
+			t.Errorf("listener %d: data API status %d, data API offered = %t", i, code, wantAPI)
+		}
+		// A request without the websocket upgrade headers is rejected, but not
+		// as not found if the websocket API is offered.
+		if code := get(l, "/ws"); (code != http.StatusNotFound) != wantWS {
+			t.Errorf("listener %d: websocket status %d, websocket offered = %t", i, code, wantWS)
+		}
+	}
+}
+
+func TestInheritedListeners(t *testing.T) {
+	tlsConfig := new(tls.Config)
+	inherit := func(names ...string) []*inheritedListener {
+		t.Helper()
+		files := make([]*os.File, 0, len(names))
+		for range names {
+			l, err := net.Listen("tcp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen error: %v", err)
+			}
+			f, err := l.(*net.TCPListener).File()
+			if err != nil {
+				t.Fatalf("File error: %v", err)
+			}
+			l.Close()
+			files = append(files, f)
+		}
+		inherited, err := fileListeners(files, names)
+		if err != nil {
+			t.Fatalf("fileListeners error: %v", err)
+		}
+		return inherited
+	}
+
+	inherited := inherit("", "ws", "data")
+	matchedAddr := inherited[0].Addr().String()
+	policy := &Listener{Addr: matchedAddr, NoDataAPI: true}
+	listeners, err := listen([]*Listener{policy}, inherited, tlsConfig, false)
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	if len(listeners) != 3 {
+		t.Fatalf("expected 3 listeners, got %d", len(listeners))
+	}
+	// The configured address is served by the inherited listener, and the
+	// others have the policies for their names.
+	if listeners[0].policy != policy || listeners[0].Addr().String() != matchedAddr {
+		t.Fatalf("configured address not served by the inherited listener")
+	}
+	if p := listeners[1].policy; p.NoWebsocket || !p.NoDataAPI {
+		t.Fatalf("wrong policy for ws socket: %+v", p)
+	}
+	if p := listeners[2].policy; !p.NoWebsocket || p.NoDataAPI {
+		t.Fatalf("wrong policy for data socket: %+v", p)
+	}
+
+	// A file that is not a listening socket.
+	f, err := ioutil.TempFile("", "notasocket")
+	if err != nil {
+		t.Fatalf("TempFile error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = fileListeners([]*os.File{f}, nil); err == nil {
+		t.Fatalf("no error for a file that is not a listening socket")
+	}
+
+	// Inherited listeners are closed if an address cannot be bound.
+	inherited = inherit("")
+	addr := inherited[0].Addr().String()
+	_, err = listen([]*Listener{{Addr: "1.2.3.4.5:0"}}, inherited, tlsConfig, false)
+	if err == nil {
+		t.Fatalf("no error for bad address")
+	}
+	if l, err := net.Listen("tcp4", addr); err != nil {
+		t.Fatalf("inherited listener not closed: %v", err)
+	} else {
+		l.Close()
+	}
+}
+
+func TestAddrMatches(t *testing.T) {
+	tests := []struct {
+		listenAddr string
+		addr       *net.TCPAddr
+		want       bool
+	}{
+		{"127.0.0.1:7232", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7232}, true},
+		{"127.0.0.1:7232", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7233}, false},
+		{"127.0.0.2:7232", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7232}, false},
+		{":7232", &net.TCPAddr{IP: net.IPv6unspecified, Port: 7232}, true},
+		{":7232", &net.TCPAddr{IP: net.IPv4zero, Port: 7232}, true},
+		{":7232", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7232}, false},
+		{"[::1]:7232", &net.TCPAddr{IP: net.IPv6loopback, Port: 7232}, true},
+		{"[fe80::1%eth0]:7232", &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 7232, Zone: "eth0"}, true},
+	}
+	for _, test := range tests {
+		if got := addrMatches(test.listenAddr, test.addr); got != test.want {
+			t.Errorf("addrMatches(%q, %v) = %t, want %t", test.listenAddr, test.addr, got, test.want)
+		}
+	}
+}
+
+func TestReusePortHandoff(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT handoff tested on linux only")
+	}
+	tempDir, err := ioutil.TempDir("", "handoff")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	newServer := func(addr string) *Server {
+		t.Helper()
+		server, err := NewServer(&RPCConfig{
+			ListenAddrs: []string{addr},
+			RPCKey:      filepath.Join(tempDir, "rpc.key"),
+			RPCCert:     filepath.Join(tempDir, "rpc.cert"),
+			ReusePort:   true,
+		})
+		if err != nil {
+			t.Fatalf("server constructor error: %v", err)
+		}
+		return server
+	}
+
+	oldServer := newServer("127.0.0.1:0")
+	addr := oldServer.listeners[0].Addr().String()
+	// A new server binds the same address.
+	nextServer := newServer(addr)
+	defer nextServer.listeners[0].Close()
+
+	ssw := dex.NewStartStopWaiter(oldServer)
+	ssw.Start(testCtx)
+	defer func() {
+		ssw.Stop()
+		ssw.WaitForShutdown()
+	}()
+
+	// Draining the old server closes its listeners.
+	oldServer.Drain()
+	if _, err = oldServer.listeners[0].Accept(); err == nil {
+		t.Fatalf("listener not closed on drain")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	var handled int
+	Route(msgjson.CancelRoute, func(Link, *msgjson.Message) *msgjson.Error {
+		handled++
+		return nil
+	})
+	defer delete(rpcRoutes, msgjson.CancelRoute)
+	link := newWSLink("testaddr", newWsStub())
+
+	tests := []struct {
+		name, route, payload string
+		wantReason           string
+	}{{
+		name:    "ok",
+		route:   msgjson.CancelRoute,
+		payload: `{"accountid":"00","base":42,"quote":0,"targetid":"01","sig":"02"}`,
+	}, {
+		name:       "unknown field",
+		route:      msgjson.CancelRoute,
+		payload:    `{"accountid":"00","targetid":"01","extra":1}`,
+		wantReason: RejectSchema,
+	}, {
+		name:       "wrong type",
+		route:      msgjson.CancelRoute,
+		payload:    `{"base":"dcr"}`,
+		wantReason: RejectSchema,
+	}, {
+		name:       "too large",
+		route:      msgjson.CancelRoute,
+		payload:    `{"targetid":"` + strings.Repeat("00", 1<<10) + `"}`,
+		wantReason: RejectSize,
+	}}
+	for _, tt := range tests {
+		handled = 0
+		before := rejectCount(tt.route, tt.wantReason)
+		rpcErr := handleMessage(link, makeReq(tt.route, tt.payload))
+		if tt.wantReason == "" {
+			if rpcErr != nil || handled != 1 {
+				t.Fatalf("%s: valid request not handled: %v", tt.name, rpcErr)
+			}
+			continue
+		}
+		if rpcErr == nil || rpcErr.Code != msgjson.RPCParseError || handled != 0 {
+			t.Fatalf("%s: invalid request not rejected", tt.name)
+		}
+		if rejectCount(tt.route, tt.wantReason) != before+1 {
+			t.Fatalf("%s: reject not counted", tt.name)
+		}
+	}
+
+	// Routes without a schema are only limited in size, and unknown routes
+	// are not counted.
+	if _, rpcErr := validateRequest(makeReq("unlisted", `{"any":"field"}`)); rpcErr != nil {
+		t.Fatalf("request on a route without a schema rejected: %v", rpcErr)
+	}
+	if reason, _ := validateRequest(makeReq("unlisted", `"`+strings.Repeat("a", defaultMaxPayload)+`"`)); reason != RejectSize {
+		t.Fatalf("oversized request on a route without a schema not rejected")
+	}
+	handleMessage(link, makeReq("unregistered", `"`+strings.Repeat("a", defaultMaxPayload)+`"`))
+	if rejectCount("unregistered", RejectSize) != 0 {
+		t.Fatalf("reject counted for an unknown route")
+	}
+}
+
+func rejectCount(route, reason string) uint64 {
+	for _, rc := range Rejects() {
+		if rc.Route == route && rc.Reason == reason {
+			return rc.Count
+		}
+	}
+	return 0
+}
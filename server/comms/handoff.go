@@ -0,0 +1,145 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package comms
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The environment variables of the systemd socket activation protocol. See
+// sd_listen_fds(3).
+const (
+	listenPIDEnv     = "LISTEN_PID"
+	listenFDsEnv     = "LISTEN_FDS"
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+	// listenFDsStart is the first file descriptor passed by systemd.
+	listenFDsStart = 3
+)
+
+// The names of inherited sockets, set with FileDescriptorName= in the systemd
+// socket unit, that offer only one service when they are not matched to a
+// configured address.
+const (
+	wsSocketName   = "ws"
+	dataSocketName = "data"
+)
+
+// inheritedListener is a listening socket passed to the process by systemd
+// socket activation.
+type inheritedListener struct {
+	net.Listener
+	name string
+}
+
+// activatedFDs returns the number of listening sockets passed to the process
+// by systemd socket activation.
+func activatedFDs() int {
+	pid, err := strconv.Atoi(os.Getenv(listenPIDEnv))
+	if err != nil || pid != os.Getpid() {
+		return 0
+	}
+	n, err := strconv.Atoi(os.Getenv(listenFDsEnv))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// SocketActivated checks whether listening sockets were passed to the process
+// by systemd socket activation. The sockets are used by the Server in place of
+// binding the matching configured addresses, and any other inherited sockets
+// are served too, so the configured addresses may be omitted.
+func SocketActivated() bool {
+	return activatedFDs() > 0
+}
+
+// takeActivatedListeners creates listeners from the sockets passed by systemd
+// socket activation. The environment variables are unset so that the sockets
+// are taken only once, and are not passed on to child processes.
+func takeActivatedListeners() ([]*inheritedListener, error) {
+	n := activatedFDs()
+	var names []string
+	if namesStr := os.Getenv(listenFDNamesEnv); namesStr != "" {
+		names = strings.Split(namesStr, ":")
+	}
+	os.Unsetenv(listenPIDEnv)
+	os.Unsetenv(listenFDsEnv)
+	os.Unsetenv(listenFDNamesEnv)
+	if n == 0 {
+		return nil, nil
+	}
+	files := make([]*os.File, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		files = append(files, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+	}
+	return fileListeners(files, names)
+}
+
+// fileListeners creates listeners from the files of listening sockets, with
+// the corresponding names. The files are closed.
+func fileListeners(files []*os.File, names []string) ([]*inheritedListener, error) {
+	listeners := make([]*inheritedListener, 0, len(files))
+	var err error
+	for i, f := range files {
+		if err == nil {
+			var l net.Listener
+			l, err = net.FileListener(f)
+			if err != nil {
+				err = fmt.Errorf("inherited socket %s is not a listener: %v", f.Name(), err)
+			} else {
+				il := &inheritedListener{Listener: l}
+				if i < len(names) {
+					il.name = names[i]
+				}
+				listeners = append(listeners, il)
+			}
+		}
+		f.Close()
+	}
+	if err != nil {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+	return listeners, nil
+}
+
+// addrMatches checks whether the listen address is the address of an
+// inherited listener. A wildcard host matches an unspecified IP address.
+func addrMatches(listenAddr string, addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil || port != strconv.Itoa(tcpAddr.Port) {
+		return false
+	}
+	if host == "" {
+		return tcpAddr.IP.IsUnspecified()
+	}
+	if zoneIndex := strings.LastIndex(host, "%"); zoneIndex > 0 {
+		host = host[:zoneIndex]
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.Equal(tcpAddr.IP)
+}
+
+// inheritedPolicy is the policy of an inherited listener that does not match a
+// configured address. The services are limited by the socket's name.
+func inheritedPolicy(l *inheritedListener) *Listener {
+	policy := &Listener{Addr: l.Addr().String()}
+	switch l.name {
+	case wsSocketName:
+		policy.NoDataAPI = true
+	case dataSocketName:
+		policy.NoWebsocket = true
+	}
+	return policy
+}
@@ -9,6 +9,7 @@ import (
 
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/ws"
+	"decred.org/dcrdex/server/trace"
 )
 
 // Link is an interface for a communication channel with an API client. The
@@ -52,6 +53,11 @@ type wsLink struct {
 	// Upon closing, the client's IP address will be quarantined by the server if
 	// ban = true.
 	ban bool
+	// drained checks if requests for a route are rejected because the server
+	// is draining. It may be nil.
+	drained func(route string) bool
+	// tracer records the events of the client's requests. It may be nil.
+	tracer *trace.Tracer
 }
 
 // newWSLink is a constructor for a new wsLink.
@@ -95,9 +101,24 @@ func handleMessage(c *wsLink, msg *msgjson.Message) *msgjson.Error {
 		if handler == nil {
 			return msgjson.NewError(msgjson.RPCUnknownRoute, "unknown route "+msg.Route)
 		}
-		// Handle the request.
+		// Reject payloads that are too large or malformed before the handler
+		// has to decode them.
+		if reason, rpcErr := validateRequest(msg); rpcErr != nil {
+			countReject(msg.Route, reason)
+			log.Debugf("Rejected %s request %d from link %d (%s): %s", msg.Route, msg.ID, c.id, c.IP(), rpcErr.Message)
+			return rpcErr
+		}
+		if c.drained != nil && c.drained(msg.Route) {
+			return msgjson.NewError(msgjson.ServerDrainingError,
+				"server is shutting down and not accepting "+msg.Route+" requests")
+		}
+		// Handle the request, tracing it with a new correlation ID.
+		cid := c.tracer.Begin(msg)
+		defer c.tracer.End(msg)
+		c.tracer.Logf(log, "COMM", cid, "%s request %d from link %d (%s)", msg.Route, msg.ID, c.id, c.IP())
 		rpcError := handler(c, msg)
 		if rpcError != nil {
+			c.tracer.Logf(log, "COMM", cid, "%s request %d failed: %s", msg.Route, msg.ID, rpcError.Message)
 			return rpcError
 		}
 		return nil
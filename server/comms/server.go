@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/elliptic"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -21,6 +22,7 @@ import (
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/ws"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/certgen"
 	"github.com/decred/slog"
 	"github.com/go-chi/chi"
@@ -86,11 +88,82 @@ func RouteHandler(route string) MsgHandler {
 	return rpcRoutes[route]
 }
 
+// HTTPHandler describes a handler for an HTTP data API route. The returned
+// value is JSON-encoded as the response body.
+type HTTPHandler func() (interface{}, error)
+
+// httpRoutes maps HTTP data API routes to the handlers.
+var httpRoutes = make(map[string]HTTPHandler)
+
+// RegisterHTTP registers a handler for a GET request to the HTTP data API at
+// /api/{route}. Like Route, the handler map is global and has no mutex
+// protection. All calls to RegisterHTTP should be done before the Server is
+// started.
+func RegisterHTTP(route string, handler HTTPHandler) {
+	if route == "" {
+		panic("RegisterHTTP: route is empty string")
+	}
+	_, alreadyHave := httpRoutes[route]
+	if alreadyHave {
+		panic(fmt.Sprintf("RegisterHTTP: double registration: %s", route))
+	}
+	httpRoutes[route] = handler
+}
+
+// HTTPMarketHandler describes a handler for a market-specific HTTP data API
+// route. The returned value is JSON-encoded as the response body.
+type HTTPMarketHandler func(mkt string) (interface{}, error)
+
+// marketHTTPRoutes maps market-specific HTTP data API routes to the handlers.
+var marketHTTPRoutes = make(map[string]HTTPMarketHandler)
+
+// RegisterMarketHTTP registers a handler for a GET request to the HTTP data API
+// at /api/{route}/{market}. The market routes require an API key that is valid
+// for the market, and are not served if the Server has no APIKeyring. Like
+// RegisterHTTP, all calls to RegisterMarketHTTP should be done before the
+// Server is started.
+func RegisterMarketHTTP(route string, handler HTTPMarketHandler) {
+	if route == "" {
+		panic("RegisterMarketHTTP: route is empty string")
+	}
+	_, alreadyHave := marketHTTPRoutes[route]
+	if alreadyHave {
+		panic(fmt.Sprintf("RegisterMarketHTTP: double registration: %s", route))
+	}
+	marketHTTPRoutes[route] = handler
+}
+
+// Listener is an address on which the server listens, with the policy for the
+// services offered on that address. For example, the HTTP data API may be
+// offered only on a clearnet address, and not on an onion service address.
+// A wildcard host listens on both IPv4 and IPv6.
+type Listener struct {
+	Addr string
+	// NoWebsocket disables the websocket API on the listener.
+	NoWebsocket bool
+	// NoDataAPI disables the HTTP data API on the listener.
+	NoDataAPI bool
+}
+
+// policyListener is a net.Listener with the policy of its Listener.
+type policyListener struct {
+	net.Listener
+	policy *Listener
+}
+
+// listenerCtxKey is the context key of the *Listener policy of the listener
+// on which a request was received.
+type listenerCtxKey struct{}
+
 // The RPCConfig is the server configuration settings and the only argument
 // to the server's constructor.
 type RPCConfig struct {
-	// ListenAddrs are the addresses on which the server will listen.
+	// ListenAddrs are the addresses on which the server will listen, offering
+	// all services.
 	ListenAddrs []string
+	// Listeners are additional addresses on which the server will listen, each
+	// with its own policy.
+	Listeners []*Listener
 	// The location of the TLS keypair files. If they are not already at the
 	// specified location, a keypair with a self-signed certificate will be
 	// generated and saved to these locations.
@@ -100,13 +173,24 @@ type RPCConfig struct {
 	// TLS keypair. Changing AltDNSNames does not force the keypair to be
 	// regenerated. To regenerate, delete or move the old files.
 	AltDNSNames []string
+	// APIKeys are the keys issued for the HTTP data API. Requests without a
+	// key are limited to anonDataRateLimit per minute for each IP address. If
+	// APIKeys is nil, the market-specific routes are not served.
+	APIKeys *APIKeyring
+	// ReusePort sets SO_REUSEPORT on the listening sockets, so that a new
+	// server process can bind the same addresses before this one is drained
+	// and stopped. See (*Server).Drain.
+	ReusePort bool
+	// Tracer records the events of client requests. It may be nil.
+	Tracer *trace.Tracer
 }
 
 // Server is a low-level communications hub. It supports websocket clients
 // and an HTTP API.
 type Server struct {
-	// One listener for each address specified at (RPCConfig).ListenAddrs.
-	listeners []net.Listener
+	// One listener for each address specified at (RPCConfig).ListenAddrs or
+	// (RPCConfig).Listeners, or two for a wildcard address.
+	listeners []*policyListener
 	// Protect the client map, which maps the (link).id to the client itself.
 	clientMtx sync.RWMutex
 	clients   map[uint64]*wsLink
@@ -117,6 +201,21 @@ type Server struct {
 	// be lifted.
 	banMtx     sync.RWMutex
 	quarantine map[string]time.Time
+	// While draining, new connections are refused, and only the drainRoutes
+	// are handled for existing connections.
+	drainMtx    sync.RWMutex
+	draining    bool
+	drainRoutes map[string]bool
+	// apiKeys and ipLimiter limit the HTTP data API requests.
+	apiKeys   *APIKeyring
+	ipLimiter *ipLimiter
+	// handoff is set if the listening sockets are shared with another server
+	// process, by SO_REUSEPORT or systemd socket activation. The listeners are
+	// closed when draining so that new connections are accepted by the other
+	// process. listenersClosed is set when they are closed.
+	handoff         bool
+	listenersClosed uint32
+	tracer          *trace.Tracer
 }
 
 // A constructor for an Server. The Server handles a map of clients, each
@@ -148,25 +247,19 @@ func NewServer(cfg *RPCConfig) (*Server, error) {
 		Certificates: []tls.Certificate{keypair},
 		MinVersion:   tls.VersionTLS12,
 	}
-	// Parse the specified listen addresses and create the []net.Listener.
-	ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners(cfg.ListenAddrs)
+	// Parse the specified listen addresses and create the listeners.
+	policies := make([]*Listener, 0, len(cfg.ListenAddrs)+len(cfg.Listeners))
+	for _, addr := range cfg.ListenAddrs {
+		policies = append(policies, &Listener{Addr: addr})
+	}
+	policies = append(policies, cfg.Listeners...)
+	inherited, err := takeActivatedListeners()
 	if err != nil {
 		return nil, err
 	}
-	listeners := make([]net.Listener, 0, len(ipv6ListenAddrs)+len(ipv4ListenAddrs))
-	for _, addr := range ipv4ListenAddrs {
-		listener, err := tls.Listen("tcp4", addr, &tlsConfig)
-		if err != nil {
-			return nil, fmt.Errorf("Can't listen on %s: %v", addr, err)
-		}
-		listeners = append(listeners, listener)
-	}
-	for _, addr := range ipv6ListenAddrs {
-		listener, err := tls.Listen("tcp6", addr, &tlsConfig)
-		if err != nil {
-			return nil, fmt.Errorf("Can't listen on %s: %v", addr, err)
-		}
-		listeners = append(listeners, listener)
+	listeners, err := listen(policies, inherited, &tlsConfig, cfg.ReusePort)
+	if err != nil {
+		return nil, err
 	}
 	if len(listeners) == 0 {
 		return nil, fmt.Errorf("RPCS: No valid listen address")
@@ -176,9 +269,86 @@ func NewServer(cfg *RPCConfig) (*Server, error) {
 		listeners:  listeners,
 		clients:    make(map[uint64]*wsLink),
 		quarantine: make(map[string]time.Time),
+		apiKeys:    cfg.APIKeys,
+		ipLimiter:  newIPLimiter(),
+		handoff:    cfg.ReusePort || len(inherited) > 0,
+		tracer:     cfg.Tracer,
 	}, nil
 }
 
+// listen creates the TLS listeners for each Listener. A wildcard address is
+// bound on both IPv4 and IPv6. An address that matches inherited listeners is
+// served by them instead of being bound, and the other inherited listeners are
+// served with a policy for their name. If any address cannot be bound, the
+// listeners that were created and the inherited listeners are closed.
+func listen(policies []*Listener, inherited []*inheritedListener, tlsConfig *tls.Config, reusePort bool) ([]*policyListener, error) {
+	var listeners []*policyListener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+		for _, l := range inherited {
+			if l != nil {
+				l.Close()
+			}
+		}
+	}
+	var lc net.ListenConfig
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	for _, policy := range policies {
+		if policy.NoWebsocket && policy.NoDataAPI {
+			closeAll()
+			return nil, fmt.Errorf("listener %s offers no services", policy.Addr)
+		}
+		var matched bool
+		for i, l := range inherited {
+			if l != nil && addrMatches(policy.Addr, l.Addr()) {
+				listeners = append(listeners, &policyListener{tls.NewListener(l, tlsConfig), policy})
+				inherited[i] = nil
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+		ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners([]string{policy.Addr})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		for _, nw := range []struct {
+			network string
+			addrs   []string
+		}{{"tcp4", ipv4ListenAddrs}, {"tcp6", ipv6ListenAddrs}} {
+			for _, addr := range nw.addrs {
+				listener, err := lc.Listen(context.Background(), nw.network, addr)
+				if err != nil {
+					closeAll()
+					return nil, fmt.Errorf("Can't listen on %s: %v", addr, err)
+				}
+				listeners = append(listeners, &policyListener{tls.NewListener(listener, tlsConfig), policy})
+			}
+		}
+	}
+	for _, l := range inherited {
+		if l != nil {
+			listeners = append(listeners, &policyListener{tls.NewListener(l, tlsConfig), inheritedPolicy(l)})
+		}
+	}
+	return listeners, nil
+}
+
+// listenerPolicy gets the policy of the listener on which the request was
+// received.
+func listenerPolicy(r *http.Request) *Listener {
+	if policy, ok := r.Context().Value(listenerCtxKey{}).(*Listener); ok {
+		return policy
+	}
+	return new(Listener) // all services
+}
+
 // Run starts the server. Run should be called only after all routes are
 // registered.
 func (s *Server) Run(ctx context.Context) {
@@ -192,25 +362,34 @@ func (s *Server) Run(ctx context.Context) {
 		Handler:      mux,
 		ReadTimeout:  rpcTimeoutSeconds * time.Second, // slow requests should not hold connections opened
 		WriteTimeout: rpcTimeoutSeconds * time.Second, // hung responses must die
-		//BaseContext:  func(net.Listener) context.Context { return ctx },
+		// The listener's policy is available to the handlers in the request
+		// context.
+		BaseContext: func(l net.Listener) context.Context {
+			ctx := context.Background()
+			if pl, ok := l.(*policyListener); ok {
+				ctx = context.WithValue(ctx, listenerCtxKey{}, pl.policy)
+			}
+			return ctx
+		},
 	}
 
 	var wg sync.WaitGroup
 
 	// Websocket endpoint.
 	mux.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
-		// If the IP address includes a port, remove it.
-		ip := r.RemoteAddr
-		// If a host:port can be parsed, the IP is only the host portion.
-		host, _, err := net.SplitHostPort(ip)
-		if err == nil && host != "" {
-			ip = host
+		if listenerPolicy(r).NoWebsocket {
+			http.NotFound(w, r)
+			return
 		}
-
+		ip := requestIP(r)
 		if s.isQuarantined(ip) {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
+		if s.Draining() {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
 		if s.clientCount() >= rpcMaxClients {
 			http.Error(w, "server at maximum capacity", http.StatusServiceUnavailable)
 			return
@@ -220,6 +399,11 @@ func (s *Server) Run(ctx context.Context) {
 			log.Errorf("ws connection error: %v", err)
 			return
 		}
+		// Larger messages close the connection. See validate.go for the
+		// request limits of each route.
+		if rl, ok := wsConn.(interface{ SetReadLimit(int64) }); ok {
+			rl.SetReadLimit(maxMessageSize)
+		}
 
 		// http.Server.Shutdown waits for connections to complete (such as this
 		// http.HandlerFunc), but not the long running upgraded websocket
@@ -232,13 +416,27 @@ func (s *Server) Run(ctx context.Context) {
 		}()
 	})
 
+	// HTTP data API endpoints.
+	mux.Route("/api", func(r chi.Router) {
+		r.Use(noDataAPI)
+		for route, handler := range httpRoutes {
+			r.Get("/"+route, s.limitDataAPI(httpRouteHandler(route, handler)))
+		}
+		if s.apiKeys == nil {
+			return
+		}
+		for route, handler := range marketHTTPRoutes {
+			r.Get("/"+route+"/{market}", s.requireAPIKey(marketHTTPRouteHandler(route, handler)))
+		}
+	})
+
 	// Start serving.
 	for _, listener := range s.listeners {
 		wg.Add(1)
-		go func(listener net.Listener) {
+		go func(listener *policyListener) {
 			log.Infof("RPC server listening on %s", listener.Addr())
 			err := httpServer.Serve(listener)
-			if !errors.Is(err, http.ErrServerClosed) {
+			if !errors.Is(err, http.ErrServerClosed) && atomic.LoadUint32(&s.listenersClosed) == 0 {
 				log.Warnf("unexpected (http.Server).Serve error: %v", err)
 			}
 			log.Debugf("RPC listener done for %s", listener.Addr())
@@ -263,9 +461,66 @@ func (s *Server) Run(ctx context.Context) {
 	// When the http.Server is shut down, all websocket clients are gone, and
 	// the listener goroutines have returned, the server is shut down.
 	wg.Wait()
+	if s.apiKeys != nil {
+		if err := s.apiKeys.Save(); err != nil {
+			log.Errorf("Failed to save API key usage: %v", err)
+		}
+	}
 	log.Infof("RPC server shutdown complete")
 }
 
+// noDataAPI responds with a 404 status to HTTP data API requests received on a
+// listener with the NoDataAPI policy.
+func noDataAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if listenerPolicy(r).NoDataAPI {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain puts the server in drain mode ahead of a shutdown. New connections are
+// refused, and requests from connected clients are rejected unless they are
+// for one of the specified routes. Responses to the server's requests are
+// still handled. Drain may be called again to change the routes. If the
+// listening sockets are shared with another server process, by SO_REUSEPORT or
+// systemd socket activation, the listeners are closed instead of refusing new
+// connections, so that they are accepted by the other process.
+func (s *Server) Drain(routes ...string) {
+	drainRoutes := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		drainRoutes[route] = true
+	}
+	s.drainMtx.Lock()
+	s.draining = true
+	s.drainRoutes = drainRoutes
+	s.drainMtx.Unlock()
+	log.Infof("RPC server draining. Handling only routes %v", routes)
+	if s.handoff && atomic.CompareAndSwapUint32(&s.listenersClosed, 0, 1) {
+		for _, l := range s.listeners {
+			l.Close()
+		}
+		log.Infof("RPC listeners closed for handoff of new connections.")
+	}
+}
+
+// Draining checks if the server is in drain mode.
+func (s *Server) Draining() bool {
+	s.drainMtx.RLock()
+	defer s.drainMtx.RUnlock()
+	return s.draining
+}
+
+// routeDrained checks if requests for the route are rejected because the
+// server is draining.
+func (s *Server) routeDrained(route string) bool {
+	s.drainMtx.RLock()
+	defer s.drainMtx.RUnlock()
+	return s.draining && !s.drainRoutes[route]
+}
+
 // Check if the IP address is quarantined.
 func (s *Server) isQuarantined(ip string) bool {
 	s.banMtx.RLock()
@@ -300,6 +555,8 @@ func (s *Server) websocketHandler(ctx context.Context, conn ws.Connection, ip st
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it.
 	client := newWSLink(ip, conn)
+	client.drained = s.routeDrained
+	client.tracer = s.tracer
 	cm, err := s.addClient(client, ctx)
 	if err != nil {
 		log.Errorf("Failed to add client %s", ip)
@@ -452,3 +709,84 @@ func parseListeners(addrs []string) ([]string, []string, bool, error) {
 	}
 	return ipv4ListenAddrs, ipv6ListenAddrs, haveWildcard, nil
 }
+
+// httpRouteHandler wraps an HTTPHandler in an http.HandlerFunc that writes the
+// JSON-encoded result.
+func httpRouteHandler(route string, handler HTTPHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		thing, err := handler()
+		if err != nil {
+			log.Debugf("error handling HTTP %s request: %v", route, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b, err := json.Marshal(thing)
+		if err != nil {
+			log.Errorf("error encoding HTTP %s response: %v", route, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if _, err = w.Write(b); err != nil {
+			log.Debugf("error writing HTTP %s response: %v", route, err)
+		}
+	}
+}
+
+// marketHTTPRouteHandler wraps an HTTPMarketHandler in an http.HandlerFunc that
+// writes the JSON-encoded result for the market in the URL path.
+func marketHTTPRouteHandler(route string, handler HTTPMarketHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mkt := chi.URLParam(r, "market")
+		httpRouteHandler(route, func() (interface{}, error) {
+			return handler(mkt)
+		})(w, r)
+	}
+}
+
+// requestIP is the IP address of the request, without the port.
+func requestIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	// If a host:port can be parsed, the IP is only the host portion.
+	host, _, err := net.SplitHostPort(ip)
+	if err == nil && host != "" {
+		ip = host
+	}
+	return ip
+}
+
+// limitDataAPI wraps a handler for an HTTP data API route that is open to all.
+// A request with an API key is metered against the key's rate limit, and other
+// requests are limited by IP address.
+func (s *Server) limitDataAPI(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := requestAPIKey(r); key != "" && s.apiKeys != nil {
+			if err := s.apiKeys.use(key, ""); err != nil {
+				http.Error(w, err.Error(), apiKeyStatus(err))
+				return
+			}
+		} else if !s.ipLimiter.allow(requestIP(r)) {
+			http.Error(w, errRateLimited.Error(), http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAPIKey wraps a handler for a market-specific HTTP data API route. The
+// request must have an API key that is valid for the market.
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if key == "" {
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+		if err := s.apiKeys.use(key, chi.URLParam(r, "market")); err != nil {
+			http.Error(w, err.Error(), apiKeyStatus(err))
+			return
+		}
+		next(w, r)
+	}
+}
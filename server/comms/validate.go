@@ -0,0 +1,152 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package comms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"decred.org/dcrdex/dex/msgjson"
+)
+
+const (
+	// maxMessageSize is the largest websocket message read from a client.
+	// The connection is closed on a larger message.
+	maxMessageSize = 1 << 20
+	// defaultMaxPayload is the largest request payload accepted on routes
+	// without their own limit.
+	defaultMaxPayload = 1 << 14
+)
+
+// Reasons that a request is rejected by validation.
+const (
+	RejectSize   = "size"
+	RejectSchema = "schema"
+)
+
+// routeSchema is the validation of the payloads of a route's requests, which
+// is applied before the route's handler runs.
+type routeSchema struct {
+	// maxPayload is the largest payload accepted, in bytes.
+	maxPayload int
+	// payload creates the route's payload type for a strict decoding that
+	// rejects unknown fields. The signed order and swap payloads are strict,
+	// since unknown fields would not be covered by the signature. Payloads of
+	// the other routes may gain fields in later client versions, so only
+	// their size is checked.
+	payload func() interface{}
+}
+
+// routeSchemas are the payload validations of the client-originating request
+// routes. Orders allow up to 64 funding coins.
+var routeSchemas = map[string]*routeSchema{
+	msgjson.LimitRoute: {
+		maxPayload: 1 << 16,
+		payload:    func() interface{} { return new(msgjson.LimitOrder) },
+	},
+	msgjson.MarketRoute: {
+		maxPayload: 1 << 16,
+		payload:    func() interface{} { return new(msgjson.MarketOrder) },
+	},
+	msgjson.CancelRoute: {
+		maxPayload: 1 << 10,
+		payload:    func() interface{} { return new(msgjson.CancelOrder) },
+	},
+	msgjson.InitRoute: {
+		maxPayload: 1 << 12,
+		payload:    func() interface{} { return new(msgjson.Init) },
+	},
+	msgjson.RedeemRoute: {
+		maxPayload: 1 << 12,
+		payload:    func() interface{} { return new(msgjson.Redeem) },
+	},
+	msgjson.ConnectRoute:        {maxPayload: 1 << 12},
+	msgjson.RegisterRoute:       {maxPayload: 1 << 12},
+	msgjson.NotifyFeeRoute:      {maxPayload: 1 << 12},
+	msgjson.OrderBookRoute:      {maxPayload: 1 << 10},
+	msgjson.UnsubOrderBookRoute: {maxPayload: 1 << 10},
+	msgjson.ConfigRoute:         {maxPayload: 1 << 10},
+	msgjson.PolicyRoute:         {maxPayload: 1 << 10},
+	msgjson.AppealRoute:         {maxPayload: 1 << 13},
+	msgjson.OrderStatusRoute:    {maxPayload: 1 << 15},
+	msgjson.MatchStatusRoute:    {maxPayload: 1 << 15},
+}
+
+// validateRequest checks the payload of a request against its route's schema.
+// A route without a schema is limited to defaultMaxPayload.
+func validateRequest(msg *msgjson.Message) (reason string, rpcErr *msgjson.Error) {
+	schema := routeSchemas[msg.Route]
+	maxPayload := defaultMaxPayload
+	if schema != nil {
+		maxPayload = schema.maxPayload
+	}
+	if len(msg.Payload) > maxPayload {
+		return RejectSize, msgjson.NewError(msgjson.RPCParseError,
+			fmt.Sprintf("%s payload of %d bytes exceeds the limit of %d bytes", msg.Route, len(msg.Payload), maxPayload))
+	}
+	if schema == nil || schema.payload == nil {
+		return "", nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(msg.Payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(schema.payload()); err != nil {
+		return RejectSchema, msgjson.NewError(msgjson.RPCParseError,
+			fmt.Sprintf("invalid %s payload: %v", msg.Route, err))
+	}
+	if dec.More() {
+		return RejectSchema, msgjson.NewError(msgjson.RPCParseError,
+			fmt.Sprintf("invalid %s payload: trailing data", msg.Route))
+	}
+	return "", nil
+}
+
+// RejectCount is the number of requests on a route that were rejected by
+// validation for a reason.
+type RejectCount struct {
+	Route  string `json:"route"`
+	Reason string `json:"reason"`
+	Count  uint64 `json:"count"`
+}
+
+type rejectKey struct {
+	route, reason string
+}
+
+var (
+	rejectsMtx sync.Mutex
+	rejects    = make(map[rejectKey]uint64)
+)
+
+// countReject counts a request rejected by validation. Only registered routes
+// are counted, so that clients cannot create unbounded metrics.
+func countReject(route, reason string) {
+	rejectsMtx.Lock()
+	rejects[rejectKey{route, reason}]++
+	rejectsMtx.Unlock()
+}
+
+// Rejects returns the counts of requests rejected by validation, sorted by
+// route and reason.
+func Rejects() []*RejectCount {
+	rejectsMtx.Lock()
+	counts := make([]*RejectCount, 0, len(rejects))
+	for k, n := range rejects {
+		counts = append(counts, &RejectCount{
+			Route:  k.route,
+			Reason: k.reason,
+			Count:  n,
+		})
+	}
+	rejectsMtx.Unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Route != counts[j].Route {
+			return counts[i].Route < counts[j].Route
+		}
+		return counts[i].Reason < counts[j].Reason
+	})
+	return counts
+}
@@ -7,13 +7,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/db/driver/pg/internal"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrutil/v2"
 	"github.com/decred/dcrd/hdkeychain/v2"
+	"github.com/lib/pq"
 )
 
 // CloseAccount closes the account by setting the value of the rule column.
@@ -30,6 +33,183 @@ func (a *Archiver) CloseAccount(aid account.AccountID, rule account.Rule) error
 	return nil
 }
 
+// ForgiveAccount reopens a closed account by clearing the rule column.
+func (a *Archiver) ForgiveAccount(aid account.AccountID) error {
+	stmt := fmt.Sprintf(internal.ForgiveAccount, a.tables.accounts)
+	N, err := sqlExec(a.db, stmt, aid)
+	if err != nil {
+		a.fatalBackendErr(err)
+		return fmt.Errorf("error forgiving account %s: %v", aid, err)
+	}
+	if N != 1 {
+		return fmt.Errorf("error forgiving account %s: %d rows updated instead of 1", aid, N)
+	}
+	return nil
+}
+
+// StoreAppeal stores the penalty appeal, replacing any existing appeal for the
+// account.
+func (a *Archiver) StoreAppeal(appeal *db.Appeal) error {
+	stmt := fmt.Sprintf(internal.UpsertAppeal, a.tables.appeals)
+	_, err := a.db.Exec(stmt, appeal.AccountID, appeal.BrokenRule, appeal.Message,
+		appeal.Sig, appeal.Submitted, appeal.Status, appeal.Reason, appeal.Decided)
+	if err != nil {
+		a.fatalBackendErr(err)
+		return fmt.Errorf("error storing appeal for account %s: %v", appeal.AccountID, err)
+	}
+	return nil
+}
+
+// Appeal retrieves the penalty appeal for the account, or nil if the account
+// has not submitted an appeal.
+func (a *Archiver) Appeal(aid account.AccountID) (*db.Appeal, error) {
+	stmt := fmt.Sprintf(internal.SelectAppeal, a.tables.appeals)
+	appeal, err := scanAppeal(a.db.QueryRow(stmt, aid))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return appeal, err
+}
+
+// Appeals returns all penalty appeals, oldest first.
+func (a *Archiver) Appeals() ([]*db.Appeal, error) {
+	stmt := fmt.Sprintf(internal.SelectAllAppeals, a.tables.appeals)
+	rows, err := a.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var appeals []*db.Appeal
+	for rows.Next() {
+		appeal, err := scanAppeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, appeal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return appeals, nil
+}
+
+// DecideAppeal records the operator's decision on the account's pending
+// appeal.
+func (a *Archiver) DecideAppeal(aid account.AccountID, status db.AppealStatus, reason string) error {
+	stmt := fmt.Sprintf(internal.DecideAppeal, a.tables.appeals)
+	N, err := sqlExec(a.db, stmt, status, reason, encode.UnixMilli(time.Now()), aid)
+	if err != nil {
+		a.fatalBackendErr(err)
+		return fmt.Errorf("error deciding appeal for account %s: %v", aid, err)
+	}
+	if N != 1 {
+		return fmt.Errorf("no pending appeal for account %s", aid)
+	}
+	return nil
+}
+
+// RecordTradeFee stores a trade fee accrued by an account. A fee that is
+// already stored for the account's side of the match is not replaced.
+func (a *Archiver) RecordTradeFee(fee *db.TradeFee) error {
+	stmt := fmt.Sprintf(internal.InsertTradeFee, a.tables.tradeFees)
+	_, err := a.db.Exec(stmt, fee.MatchID, fee.AccountID, fee.AssetID, fee.Amount, fee.Stamp)
+	if err != nil {
+		a.fatalBackendErr(err)
+		return fmt.Errorf("error storing trade fee for account %s, match %v: %v",
+			fee.AccountID, fee.MatchID, err)
+	}
+	return nil
+}
+
+// FeeInvoices totals the trade fees accrued from start (inclusive) to end
+// (exclusive) for each account and asset.
+func (a *Archiver) FeeInvoices(start, end int64) ([]*db.FeeInvoice, error) {
+	stmt := fmt.Sprintf(internal.SelectFeeInvoices, a.tables.tradeFees)
+	rows, err := a.db.Query(stmt, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var invoices []*db.FeeInvoice
+	for rows.Next() {
+		inv := &db.FeeInvoice{
+			Start: start,
+			End:   end,
+		}
+		err = rows.Scan(&inv.AccountID, &inv.AssetID, &inv.Amount, &inv.Matches)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// StorePreimageMiss records the circumstances of a missed preimage request.
+func (a *Archiver) StorePreimageMiss(miss *db.PreimageMiss) error {
+	stmt := fmt.Sprintf(internal.InsertPreimageMiss, a.tables.preimageMisses)
+	_, err := a.db.Exec(stmt, miss.OrderID, miss.AccountID, miss.Commitment,
+		miss.Base, miss.Quote, miss.EpochIdx, miss.EpochDur, miss.Reason,
+		miss.Connected, miss.Latency, miss.Stamp)
+	if err != nil {
+		a.fatalBackendErr(err)
+		return fmt.Errorf("error storing preimage miss for order %v: %v", miss.OrderID, err)
+	}
+	return nil
+}
+
+// TradeFees retrieves the trade fees accrued by an account, oldest first.
+func (a *Archiver) TradeFees(aid account.AccountID) ([]*db.TradeFee, error) {
+	stmt := fmt.Sprintf(internal.SelectTradeFees, a.tables.tradeFees)
+	rows, err := a.db.Query(stmt, aid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fees []*db.TradeFee
+	for rows.Next() {
+		fee := new(db.TradeFee)
+		err = rows.Scan(&fee.MatchID, &fee.AccountID, &fee.AssetID, &fee.Amount, &fee.Stamp)
+		if err != nil {
+			return nil, err
+		}
+		fees = append(fees, fee)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return fees, nil
+}
+
+// PreimageMisses retrieves the recorded preimage misses for an account, oldest
+// first.
+func (a *Archiver) PreimageMisses(aid account.AccountID) ([]*db.PreimageMiss, error) {
+	stmt := fmt.Sprintf(internal.SelectPreimageMisses, a.tables.preimageMisses)
+	rows, err := a.db.Query(stmt, aid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var misses []*db.PreimageMiss
+	for rows.Next() {
+		miss := new(db.PreimageMiss)
+		err = rows.Scan(&miss.OrderID, &miss.AccountID, &miss.Commitment,
+			&miss.Base, &miss.Quote, &miss.EpochIdx, &miss.EpochDur, &miss.Reason,
+			&miss.Connected, &miss.Latency, &miss.Stamp)
+		if err != nil {
+			return nil, err
+		}
+		misses = append(misses, miss)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return misses, nil
+}
+
 // Account retrieves the account pubkey, whether the account is paid, and
 // whether the account is open, in that order.
 func (a *Archiver) Account(aid account.AccountID) (*account.Account, bool, bool) {
@@ -80,9 +260,82 @@ func (a *Archiver) AccountInfo(aid account.AccountID) (*db.Account, error) {
 		return nil, err
 	}
 	acct.FeeAddress = feeAddress.String
+
+	stmt = fmt.Sprintf(internal.SelectAccountNotes, a.tables.accountNotes)
+	var notes sql.NullString
+	err := a.db.QueryRow(stmt, aid).Scan(&notes, pq.Array(&acct.Tags))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	acct.Notes = notes.String
 	return acct, nil
 }
 
+// SetAccountNotes stores the operator's notes and tags for an account,
+// replacing any previous notes and tags.
+func (a *Archiver) SetAccountNotes(aid account.AccountID, notes string, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	stmt := fmt.Sprintf(internal.UpsertAccountNotes, a.tables.accountNotes)
+	_, err := a.db.Exec(stmt, aid, notes, pq.Array(tags), encode.UnixMilli(time.Now()))
+	return err
+}
+
+// RecordClientVersion records a connection of an account with a version of the
+// client software at the given time, in UNIX milliseconds.
+func (a *Archiver) RecordClientVersion(aid account.AccountID, userAgent string, apiVer uint16, stamp int64) error {
+	stmt := fmt.Sprintf(internal.UpsertClientVersion, a.tables.clientVersions)
+	_, err := a.db.Exec(stmt, aid, userAgent, apiVer, stamp)
+	return err
+}
+
+// ClientVersions returns the client software versions that an account has
+// connected with, most recently seen first.
+func (a *Archiver) ClientVersions(aid account.AccountID) ([]*db.ClientVersion, error) {
+	stmt := fmt.Sprintf(internal.SelectClientVersions, a.tables.clientVersions)
+	rows, err := a.db.Query(stmt, aid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []*db.ClientVersion
+	for rows.Next() {
+		cv := &db.ClientVersion{AccountID: aid}
+		if err = rows.Scan(&cv.UserAgent, &cv.APIVersion, &cv.FirstSeen, &cv.LastSeen, &cv.Connects); err != nil {
+			return nil, err
+		}
+		versions = append(versions, cv)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// ClientVersionStats aggregates the client software versions seen since the
+// given time, in UNIX milliseconds.
+func (a *Archiver) ClientVersionStats(since int64) ([]*db.ClientVersionStats, error) {
+	stmt := fmt.Sprintf(internal.SelectClientVersionStats, a.tables.clientVersions)
+	rows, err := a.db.Query(stmt, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stats []*db.ClientVersionStats
+	for rows.Next() {
+		s := new(db.ClientVersionStats)
+		if err = rows.Scan(&s.UserAgent, &s.APIVersion, &s.Accounts, &s.Connects, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 // CreateAccount creates an entry for a new account in the accounts table. A
 // DCR registration fee address is created and returned.
 func (a *Archiver) CreateAccount(acct *account.Account) (string, error) {
@@ -209,6 +462,18 @@ func getAccount(dbe *sql.DB, tableName string, aid account.AccountID) (*account.
 	return acct, len(coinID) > 1, rule == 0, err
 }
 
+// scanAppeal scans an appeal from a row selected with the columns of
+// internal.SelectAppeal.
+func scanAppeal(row interface{ Scan(...interface{}) error }) (*db.Appeal, error) {
+	appeal := new(db.Appeal)
+	err := row.Scan(&appeal.AccountID, &appeal.BrokenRule, &appeal.Message, &appeal.Sig,
+		&appeal.Submitted, &appeal.Status, &appeal.Reason, &appeal.Decided)
+	if err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
 // createAccount creates an entry for the account in the accounts table.
 func createAccount(dbe sqlExecutor, tableName string, acct *account.Account, regAddr string) error {
 	stmt := fmt.Sprintf(internal.CreateAccount, tableName)
@@ -8,7 +8,9 @@ import (
 	"reflect"
 	"testing"
 
+	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
 )
 
 var tPubKey = []byte{
@@ -171,3 +173,273 @@ func TestWrongAccount(t *testing.T) {
 		t.Fatalf("no error paying registration fee for unknown account")
 	}
 }
+
+func TestAppeals(t *testing.T) {
+	tCoinID, _ := hex.DecodeString("6e515ff861f2016fd0da2f3eccdf8290c03a9d116bfba2f6729e648bdc6e5aed00000005")
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	acct := tNewAccount(t)
+	if _, err := archie.CreateAccount(acct); err != nil {
+		t.Fatalf("error creating account: %v", err)
+	}
+	if err := archie.PayAccount(tAcctID, tCoinID); err != nil {
+		t.Fatalf("error paying account: %v", err)
+	}
+	if err := archie.CloseAccount(tAcctID, account.FailureToAct); err != nil {
+		t.Fatalf("error closing account: %v", err)
+	}
+
+	// No appeal yet.
+	appeal, err := archie.Appeal(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting missing appeal: %v", err)
+	}
+	if appeal != nil {
+		t.Fatalf("appeal returned before one was stored")
+	}
+
+	appeal = &db.Appeal{
+		AccountID:  tAcctID,
+		BrokenRule: account.FailureToAct,
+		Message:    "my wallet crashed",
+		Sig:        []byte{0x01, 0x02},
+		Submitted:  1585005151210,
+	}
+	if err = archie.StoreAppeal(appeal); err != nil {
+		t.Fatalf("error storing appeal: %v", err)
+	}
+
+	appeals, err := archie.Appeals()
+	if err != nil {
+		t.Fatalf("error getting appeals: %v", err)
+	}
+	if len(appeals) != 1 || !reflect.DeepEqual(appeals[0], appeal) {
+		t.Fatalf("unexpected appeals: %+v", appeals)
+	}
+
+	if err = archie.DecideAppeal(tAcctID, db.AppealAccepted, "okay"); err != nil {
+		t.Fatalf("error deciding appeal: %v", err)
+	}
+	// Only a pending appeal may be decided.
+	if err = archie.DecideAppeal(tAcctID, db.AppealRejected, "no"); err == nil {
+		t.Fatalf("no error deciding an appeal twice")
+	}
+	appeal, err = archie.Appeal(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting appeal: %v", err)
+	}
+	if appeal.Status != db.AppealAccepted || appeal.Reason != "okay" || appeal.Decided == 0 {
+		t.Fatalf("unexpected decided appeal: %+v", appeal)
+	}
+
+	if err = archie.ForgiveAccount(tAcctID); err != nil {
+		t.Fatalf("error forgiving account: %v", err)
+	}
+	if _, _, open := archie.Account(tAcctID); !open {
+		t.Fatalf("forgiven account still marked as closed")
+	}
+}
+
+func TestAccountNotes(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	acct := tNewAccount(t)
+	if _, err := archie.CreateAccount(acct); err != nil {
+		t.Fatalf("error creating account: %v", err)
+	}
+
+	// No notes yet.
+	info, err := archie.AccountInfo(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting account info: %v", err)
+	}
+	if info.Notes != "" || len(info.Tags) != 0 {
+		t.Fatalf("unexpected notes %q and tags %v before any were stored", info.Notes, info.Tags)
+	}
+
+	tags := []string{"suspected bot", "vip"}
+	if err = archie.SetAccountNotes(tAcctID, "refunded 2021-06", tags); err != nil {
+		t.Fatalf("error setting account notes: %v", err)
+	}
+	info, err = archie.AccountInfo(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting account info: %v", err)
+	}
+	if info.Notes != "refunded 2021-06" || !reflect.DeepEqual(info.Tags, tags) {
+		t.Fatalf("unexpected notes %q and tags %v", info.Notes, info.Tags)
+	}
+
+	// Replace the notes and clear the tags.
+	if err = archie.SetAccountNotes(tAcctID, "", nil); err != nil {
+		t.Fatalf("error clearing account notes: %v", err)
+	}
+	info, err = archie.AccountInfo(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting account info: %v", err)
+	}
+	if info.Notes != "" || len(info.Tags) != 0 {
+		t.Fatalf("unexpected notes %q and tags %v after clearing", info.Notes, info.Tags)
+	}
+}
+
+func TestClientVersions(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	otherAcct := account.AccountID{0x01}
+	records := []struct {
+		aid       account.AccountID
+		userAgent string
+		apiVer    uint16
+		stamp     int64
+	}{
+		{tAcctID, "", 0, 1000},
+		{tAcctID, "dexc/0.1.0", 0, 2000},
+		{tAcctID, "dexc/0.1.0", 0, 3000},
+		{otherAcct, "dexc/0.1.0", 0, 4000},
+	}
+	for _, r := range records {
+		if err := archie.RecordClientVersion(r.aid, r.userAgent, r.apiVer, r.stamp); err != nil {
+			t.Fatalf("error recording client version: %v", err)
+		}
+	}
+
+	versions, err := archie.ClientVersions(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting client versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 client versions, got %d", len(versions))
+	}
+	if cv := versions[0]; cv.UserAgent != "dexc/0.1.0" || cv.FirstSeen != 2000 ||
+		cv.LastSeen != 3000 || cv.Connects != 2 {
+		t.Fatalf("unexpected latest client version %+v", cv)
+	}
+
+	stats, err := archie.ClientVersionStats(1500)
+	if err != nil {
+		t.Fatalf("error getting client version stats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected stats for 1 client version, got %d", len(stats))
+	}
+	if s := stats[0]; s.UserAgent != "dexc/0.1.0" || s.Accounts != 2 ||
+		s.Connects != 3 || s.LastSeen != 4000 {
+		t.Fatalf("unexpected client version stats %+v", s)
+	}
+}
+
+func TestTradeFees(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	fee := &db.TradeFee{
+		MatchID:   order.MatchID{0x01},
+		AccountID: tAcctID,
+		AssetID:   42,
+		Amount:    2500,
+		Stamp:     1585005151210,
+	}
+	if err := archie.RecordTradeFee(fee); err != nil {
+		t.Fatalf("error recording trade fee: %v", err)
+	}
+	// The same fee is not stored twice.
+	if err := archie.RecordTradeFee(fee); err != nil {
+		t.Fatalf("error recording duplicate trade fee: %v", err)
+	}
+	fee2 := *fee
+	fee2.MatchID = order.MatchID{0x02}
+	fee2.Amount = 500
+	if err := archie.RecordTradeFee(&fee2); err != nil {
+		t.Fatalf("error recording second trade fee: %v", err)
+	}
+	// A fee outside of the period.
+	fee3 := *fee
+	fee3.MatchID = order.MatchID{0x03}
+	fee3.Stamp = 1585005151210 + 1000
+	if err := archie.RecordTradeFee(&fee3); err != nil {
+		t.Fatalf("error recording third trade fee: %v", err)
+	}
+
+	invoices, err := archie.FeeInvoices(1585005151210, 1585005151210+1000)
+	if err != nil {
+		t.Fatalf("error getting fee invoices: %v", err)
+	}
+	want := &db.FeeInvoice{
+		AccountID: tAcctID,
+		AssetID:   42,
+		Amount:    3000,
+		Matches:   2,
+		Start:     1585005151210,
+		End:       1585005151210 + 1000,
+	}
+	if len(invoices) != 1 || !reflect.DeepEqual(invoices[0], want) {
+		t.Fatalf("unexpected fee invoices: %+v", invoices)
+	}
+
+	fees, err := archie.TradeFees(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting trade fees: %v", err)
+	}
+	if len(fees) != 3 || fees[2].MatchID != fee3.MatchID {
+		t.Fatalf("unexpected trade fees: %+v", fees)
+	}
+}
+
+func TestPreimageMisses(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	miss := &db.PreimageMiss{
+		AccountID:  tAcctID,
+		OrderID:    order.OrderID{0x01},
+		Commitment: order.Commitment{0x02},
+		Base:       42,
+		Quote:      0,
+		EpochIdx:   158500515,
+		EpochDur:   10000,
+		Reason:     db.PreimageInvalid,
+		Connected:  true,
+		Latency:    120,
+		Stamp:      1585005151210,
+	}
+	if err := archie.StorePreimageMiss(miss); err != nil {
+		t.Fatalf("error storing preimage miss: %v", err)
+	}
+	// A repeated miss for the same order is ignored.
+	if err := archie.StorePreimageMiss(miss); err != nil {
+		t.Fatalf("error storing duplicate preimage miss: %v", err)
+	}
+	miss2 := *miss
+	miss2.OrderID = order.OrderID{0x03}
+	miss2.Reason = db.PreimageDisconnected
+	miss2.Connected = false
+	miss2.Latency = 0
+	miss2.Stamp = miss.Stamp + 10000
+	if err := archie.StorePreimageMiss(&miss2); err != nil {
+		t.Fatalf("error storing second preimage miss: %v", err)
+	}
+
+	misses, err := archie.PreimageMisses(tAcctID)
+	if err != nil {
+		t.Fatalf("error getting preimage misses: %v", err)
+	}
+	if len(misses) != 2 || !reflect.DeepEqual(misses[0], miss) || !reflect.DeepEqual(misses[1], &miss2) {
+		t.Fatalf("unexpected preimage misses: %+v", misses)
+	}
+
+	misses, err = archie.PreimageMisses(account.AccountID{0x09})
+	if err != nil {
+		t.Fatalf("error getting preimage misses for unknown account: %v", err)
+	}
+	if len(misses) != 0 {
+		t.Fatalf("unexpected preimage misses for unknown account: %+v", misses)
+	}
+}
@@ -17,6 +17,68 @@ const (
 		broken_rule INT2 DEFAULT 0
 		);`
 
+	// CreateAppealsTable creates the appeals table, which holds at most one
+	// penalty appeal per account. The status column is a db.AppealStatus.
+	CreateAppealsTable = `CREATE TABLE IF NOT EXISTS %s (
+		account_id BYTEA PRIMARY KEY,  -- UNIQUE INDEX
+		broken_rule INT2,
+		message TEXT,
+		sig BYTEA,
+		submitted INT8,
+		status INT2 DEFAULT 0,
+		reason TEXT DEFAULT '',
+		decided INT8 DEFAULT 0
+		);`
+
+	// CreateTradeFeesTable creates the trade_fees table, which holds the trade
+	// fees accrued by each account, one for each of the account's matches.
+	CreateTradeFeesTable = `CREATE TABLE IF NOT EXISTS %s (
+		match_id BYTEA,
+		account_id BYTEA,  -- INDEX this
+		asset_id INT8,
+		amount INT8,
+		stamp INT8,        -- INDEX this
+		PRIMARY KEY (match_id, account_id)
+		);`
+
+	// CreatePreimageMissesTable creates the preimage_misses table, which holds
+	// the circumstances of each missed preimage request. The reason column is a
+	// db.PreimageMissReason.
+	CreatePreimageMissesTable = `CREATE TABLE IF NOT EXISTS %s (
+		oid BYTEA PRIMARY KEY,
+		account_id BYTEA,  -- INDEX this
+		commit BYTEA,
+		base INT8,
+		quote INT8,
+		epoch_idx INT8,
+		epoch_dur INT8,
+		reason INT2,
+		connected BOOL,
+		latency INT8,
+		stamp INT8
+		);`
+
+	// CreateAccountNotesTable creates the account_notes table, which holds the
+	// operator's notes and tags for an account.
+	CreateAccountNotesTable = `CREATE TABLE IF NOT EXISTS %s (
+		account_id BYTEA PRIMARY KEY,  -- UNIQUE INDEX
+		notes TEXT DEFAULT '',
+		tags TEXT[],
+		updated INT8
+		);`
+
+	// CreateClientVersionsTable creates the client_versions table, which holds
+	// the client software versions that each account has connected with.
+	CreateClientVersionsTable = `CREATE TABLE IF NOT EXISTS %s (
+		account_id BYTEA,
+		user_agent TEXT,
+		api_version INT4,
+		first_seen INT8,
+		last_seen INT8,
+		connects INT8,
+		PRIMARY KEY (account_id, user_agent, api_version)
+		);`
+
 	// InsertKeyIfMissing creates an entry for the specified key hash, if it
 	// doesn't already exist.
 	InsertKeyIfMissing = `INSERT INTO %s (key_hash)
@@ -33,6 +95,10 @@ const (
 	// that the account is closed.
 	CloseAccount = `UPDATE %s SET broken_rule = $1 WHERE account_id = $2;`
 
+	// ForgiveAccount clears the broken_rule column for the account, reopening
+	// a closed account.
+	ForgiveAccount = `UPDATE %s SET broken_rule = 0 WHERE account_id = $1;`
+
 	// SelectAccount gathers account details for the specified accound ID. The
 	// details returned from this query are sufficient to determine 1) whether the
 	// registration fee has been paid, or 2) whether the account has been closed.
@@ -59,4 +125,93 @@ const (
 	SetRegOutput = `UPDATE %s SET
 		fee_coin = $1
 		WHERE account_id = $2;`
+
+	// UpsertAppeal stores an appeal, replacing any existing appeal for the
+	// account.
+	UpsertAppeal = `INSERT INTO %s (account_id, broken_rule, message, sig, submitted, status, reason, decided)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (account_id) DO UPDATE
+		SET broken_rule = $2, message = $3, sig = $4, submitted = $5, status = $6, reason = $7, decided = $8;`
+
+	// SelectAppeal retrieves the appeal for the specified account ID.
+	SelectAppeal = `SELECT account_id, broken_rule, message, sig, submitted, status, reason, decided
+		FROM %s
+		WHERE account_id = $1;`
+
+	// SelectAllAppeals retrieves all appeals, oldest first.
+	SelectAllAppeals = `SELECT account_id, broken_rule, message, sig, submitted, status, reason, decided
+		FROM %s
+		ORDER BY submitted;`
+
+	// UpsertAccountNotes stores the notes and tags for an account, replacing
+	// any existing notes and tags.
+	UpsertAccountNotes = `INSERT INTO %s (account_id, notes, tags, updated)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id) DO UPDATE
+		SET notes = $2, tags = $3, updated = $4;`
+
+	// SelectAccountNotes retrieves the notes and tags for an account.
+	SelectAccountNotes = `SELECT notes, tags FROM %s WHERE account_id = $1;`
+
+	// UpsertClientVersion records a connection of an account with a client
+	// software version.
+	UpsertClientVersion = `INSERT INTO %s AS cv (account_id, user_agent, api_version, first_seen, last_seen, connects)
+		VALUES ($1, $2, $3, $4, $4, 1)
+		ON CONFLICT (account_id, user_agent, api_version) DO UPDATE
+		SET last_seen = $4, connects = cv.connects + 1;`
+
+	// SelectClientVersions retrieves the client software versions of an
+	// account, most recently seen first.
+	SelectClientVersions = `SELECT user_agent, api_version, first_seen, last_seen, connects
+		FROM %s
+		WHERE account_id = $1
+		ORDER BY last_seen DESC;`
+
+	// SelectClientVersionStats aggregates the client software versions seen
+	// since a time.
+	SelectClientVersionStats = `SELECT user_agent, api_version, COUNT(*), SUM(connects), MAX(last_seen)
+		FROM %s
+		WHERE last_seen >= $1
+		GROUP BY user_agent, api_version
+		ORDER BY api_version, user_agent;`
+
+	// InsertTradeFee stores a trade fee, unless the fee for the account's side
+	// of the match is already stored.
+	InsertTradeFee = `INSERT INTO %s (match_id, account_id, asset_id, amount, stamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (match_id, account_id) DO NOTHING;`
+
+	// SelectFeeInvoices totals the trade fees in a period for each account and
+	// asset.
+	SelectFeeInvoices = `SELECT account_id, asset_id, SUM(amount), COUNT(*)
+		FROM %s
+		WHERE stamp >= $1 AND stamp < $2
+		GROUP BY account_id, asset_id
+		ORDER BY account_id, asset_id;`
+
+	// SelectTradeFees retrieves the trade fees accrued by the specified
+	// account ID, oldest first.
+	SelectTradeFees = `SELECT match_id, account_id, asset_id, amount, stamp
+		FROM %s
+		WHERE account_id = $1
+		ORDER BY stamp;`
+
+	// InsertPreimageMiss stores a preimage miss. An order's preimage is only
+	// requested once, so a repeated miss for the same order is ignored.
+	InsertPreimageMiss = `INSERT INTO %s (oid, account_id, commit, base, quote,
+			epoch_idx, epoch_dur, reason, connected, latency, stamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (oid) DO NOTHING;`
+
+	// SelectPreimageMisses retrieves the preimage misses for the specified
+	// account ID, oldest first.
+	SelectPreimageMisses = `SELECT oid, account_id, commit, base, quote,
+			epoch_idx, epoch_dur, reason, connected, latency, stamp
+		FROM %s
+		WHERE account_id = $1
+		ORDER BY stamp;`
+
+	// DecideAppeal sets the decision for a pending (status 0) appeal.
+	DecideAppeal = `UPDATE %s SET status = $1, reason = $2, decided = $3
+		WHERE account_id = $4 AND status = 0;`
 )
@@ -0,0 +1,32 @@
+package internal
+
+const (
+	// CreateLeasesTable creates the leases table, which holds the leases that
+	// give a server the exclusive right to run the DEX on a shared database.
+	// The token is the fencing token, incremented on each acquisition.
+	CreateLeasesTable = `CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		token INT8 NOT NULL,
+		expiry TIMESTAMPTZ NOT NULL
+		);`
+
+	// AcquireLease takes a lease if it does not exist, has expired, or is
+	// already held by the holder, returning the new fencing token. No row is
+	// returned if the lease is held by another holder.
+	AcquireLease = `INSERT INTO %s AS l (name, holder, token, expiry)
+		VALUES ($1, $2, 1, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = $2, token = l.token + 1, expiry = now() + $3 * interval '1 millisecond'
+		WHERE l.holder = $2 OR l.expiry < now()
+		RETURNING token;`
+
+	// RenewLease extends a lease if it is still held with the given token.
+	RenewLease = `UPDATE %s
+		SET expiry = now() + $4 * interval '1 millisecond'
+		WHERE name = $1 AND holder = $2 AND token = $3;`
+
+	// ReleaseLease deletes a lease if it is still held with the given token.
+	ReleaseLease = `DELETE FROM %s
+		WHERE name = $1 AND holder = $2 AND token = $3;`
+)
@@ -29,6 +29,7 @@ const (
 		quantity INT8,
 		rate INT8,
 		baseRate INT8, quoteRate INT8, -- contract tx fee rates, NULL for cancel orders
+		tradeFee INT4 DEFAULT 0, -- the market's trade fee rate in basis points, paid by each party
 		status INT2,           -- also updated during swap negotiation, independent from active for failed swaps
 
 		-- The remaining columns are only set during swap negotiation.
@@ -70,12 +71,16 @@ const (
 		takerOrder, takerAccount, takerAddress,
 		makerOrder, makerAccount, makerAddress,
 		epochIdx, epochDur,
-		quantity, rate, baseRate, quoteRate, status)
+		quantity, rate, baseRate, quoteRate, status, tradeFee)
 	VALUES ($1, $2,
 		$3, $4, $5,
 		$6, $7, $8,
 		$9, $10,
-		$11, $12, $13, $14, $15) `  // do not terminate with ;
+		$11, $12, $13, $14, $15, $16) `  // do not terminate with ;
+
+	// AddMatchesTradeFeeColumn adds the tradeFee column to a matches table
+	// created with schema version 2 or older.
+	AddMatchesTradeFeeColumn = `ALTER TABLE %s ADD COLUMN IF NOT EXISTS tradeFee INT4 DEFAULT 0;`
 
 	UpsertMatch = InsertMatch + ` ON CONFLICT (matchid) DO
 	UPDATE SET quantity = $11, status = $15;`
@@ -96,13 +101,13 @@ const (
 	RetrieveMatchByID = `SELECT matchid, active, takerSell,
 		takerOrder, takerAccount, takerAddress,
 		makerOrder, makerAccount, makerAddress,
-		epochIdx, epochDur, quantity, rate, baseRate, quoteRate, status
+		epochIdx, epochDur, quantity, rate, baseRate, quoteRate, status, tradeFee
 	FROM %s WHERE matchid = $1;`
 
 	RetrieveUserMatches = `SELECT matchid, active, takerSell,
 		takerOrder, takerAccount, takerAddress,
 		makerOrder, makerAccount, makerAddress,
-		epochIdx, epochDur, quantity, rate, baseRate, quoteRate, status
+		epochIdx, epochDur, quantity, rate, baseRate, quoteRate, status, tradeFee
 	FROM %s
 	WHERE takerAccount = $1 OR makerAccount = $1;`
 
@@ -114,6 +119,19 @@ const (
 	WHERE (takerAccount = $1 OR makerAccount = $1)
 		AND active;`
 
+	// RetrieveCompletedMatches retrieves the trade matches made in a period
+	// with completed swaps. A match is made at the end of its epoch. Cancel
+	// matches, which have a NULL takerSell, are excluded.
+	RetrieveCompletedMatches = `SELECT matchid, takerSell,
+		takerOrder, takerAccount, takerAddress,
+		makerOrder, makerAccount, makerAddress,
+		epochIdx, epochDur, quantity, rate, baseRate, quoteRate, status,
+		tradeFee, bRedeemTime
+	FROM %s
+	WHERE takerSell IS NOT NULL AND status = $1
+		AND (epochIdx + 1) * epochDur >= $2 AND (epochIdx + 1) * epochDur < $3
+	ORDER BY epochIdx, matchid;`
+
 	SetMakerMatchAckSig = `UPDATE %s SET sigMatchAckMaker = $2 WHERE matchid = $1;`
 	SetTakerMatchAckSig = `UPDATE %s SET sigMatchAckTaker = $2 WHERE matchid = $1;`
 
@@ -0,0 +1,19 @@
+package internal
+
+const (
+	// CreateMetaTable creates the DEX's "meta" table, which holds the version
+	// of the database schema. This table should be created in the public
+	// schema, and contains a single row.
+	CreateMetaTable = `CREATE TABLE IF NOT EXISTS %s (
+		schema_version INT4 NOT NULL
+	)`
+
+	// SelectDBVersion retrieves the database schema version.
+	SelectDBVersion = `SELECT schema_version FROM %s;`
+
+	// InsertDBVersion sets the database schema version of a new meta table.
+	InsertDBVersion = `INSERT INTO %s (schema_version) VALUES ($1);`
+
+	// SetDBVersion updates the database schema version of the meta table.
+	SetDBVersion = `UPDATE %s SET schema_version = $1;`
+)
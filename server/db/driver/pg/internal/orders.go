@@ -23,34 +23,39 @@ const (
 		filled INT8,
 		epoch_idx INT8, epoch_dur INT4,
 		preimage BYTEA UNIQUE,
-		complete_time INT8      -- when the order has successfully completed all swaps
+		complete_time INT8,     -- when the order has successfully completed all swaps
+		visible INT8 DEFAULT 0  -- the displayed quantity of an iceberg order, or 0
 	);`
 
+	// AddOrdersVisibleColumn adds the visible column to an orders table created
+	// with schema version 1.
+	AddOrdersVisibleColumn = `ALTER TABLE %s ADD COLUMN IF NOT EXISTS visible INT8 DEFAULT 0;`
+
 	// InsertOrder inserts a market or limit order into the specified table.
 	InsertOrder = `INSERT INTO %s (oid, type, sell, account_id, address,
 			client_time, server_time, commit, coins, quantity,
 			rate, force, status, filled,
-			epoch_idx, epoch_dur)
+			epoch_idx, epoch_dur, visible)
 		VALUES ($1, $2, $3, $4, $5,
 			$6, $7, $8, $9, $10,
 			$11, $12, $13, $14,
-			$15, $16);`
+			$15, $16, $17);`
 
 	// SelectOrder retrieves all columns with the given order ID. This may be
 	// used for any table with an "oid" column (orders_active, cancels_archived,
 	// etc.).
 	SelectOrder = `SELECT oid, type, sell, account_id, address, client_time, server_time,
-		commit, coins, quantity, rate, force, status, filled
+		commit, coins, quantity, rate, force, status, filled, visible
 	FROM %s WHERE oid = $1;`
 
 	SelectOrdersByStatus = `SELECT oid, type, sell, account_id, address, client_time, server_time,
-		commit, coins, quantity, rate, force, filled
+		commit, coins, quantity, rate, force, filled, visible
 	FROM %s WHERE status = $1;`
 
 	// SelectUserOrders retrieves all columns of all orders for the given
 	// account ID.
 	SelectUserOrders = `SELECT oid, type, sell, account_id, address, client_time, server_time,
-		commit, coins, quantity, rate, force, status, filled
+		commit, coins, quantity, rate, force, status, filled, visible
 	FROM %s WHERE account_id = $1;`
 
 	// SelectCanceledUserOrders gets the ID of orders that were either canceled
@@ -123,7 +128,7 @@ const (
 	//			force,
 	//			2,                                      -- new status (%d)
 	//			123456789,                              -- new filled (%d)
-	//          epoch_idx, epoch_dur, preimage, complete_time
+	//          epoch_idx, epoch_dur, preimage, complete_time, visible
 	//		)
 	//		INSERT INTO dcrdex.dcr_btc.orders_archived  -- destination table (%s)
 	//		SELECT * FROM moved;
@@ -133,7 +138,7 @@ const (
 		RETURNING oid, type, sell, account_id, address,
 			client_time, server_time, commit, coins, quantity,
 			rate, force, %d, %d,
-			epoch_idx, epoch_dur, preimage, complete_time
+			epoch_idx, epoch_dur, preimage, complete_time, visible
 	)
 	INSERT INTO %s
 	SELECT * FROM moved;`
@@ -145,7 +150,7 @@ const (
 		RETURNING oid, type, sell, account_id, address,
 			client_time, server_time, commit, coins, quantity,
 			rate, force, %d, filled, -- revoked status code
-			epoch_idx, epoch_dur, preimage, complete_time
+			epoch_idx, epoch_dur, preimage, complete_time, visible
 	)
 	INSERT INTO %s -- archived orders table for market X
 	SELECT * FROM moved
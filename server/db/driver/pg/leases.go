@@ -0,0 +1,55 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package pg
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/db/driver/pg/internal"
+)
+
+// AcquireLease takes the named lease for holder until ttl from now, if the
+// lease is free, expired, or already held by holder, and returns the new
+// fencing token. Part of the db.LeaseArchiver interface.
+func (a *Archiver) AcquireLease(name, holder string, ttl time.Duration) (uint64, error) {
+	stmt := fmt.Sprintf(internal.AcquireLease, a.tables.leases)
+	var token uint64
+	err := a.db.QueryRow(stmt, name, holder, ttl.Milliseconds()).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, db.ArchiveError{
+			Code:   db.ErrLeaseHeld,
+			Detail: fmt.Sprintf("lease %q", name),
+		}
+	}
+	return token, err
+}
+
+// RenewLease extends a lease taken with AcquireLease until ttl from now. Part
+// of the db.LeaseArchiver interface.
+func (a *Archiver) RenewLease(name, holder string, token uint64, ttl time.Duration) error {
+	stmt := fmt.Sprintf(internal.RenewLease, a.tables.leases)
+	N, err := sqlExec(a.db, stmt, name, holder, token, ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if N == 0 {
+		return db.ArchiveError{
+			Code:   db.ErrLeaseLost,
+			Detail: fmt.Sprintf("lease %q token %d", name, token),
+		}
+	}
+	return nil
+}
+
+// ReleaseLease gives up a lease taken with AcquireLease, if it is still held
+// with the given token. Part of the db.LeaseArchiver interface.
+func (a *Archiver) ReleaseLease(name, holder string, token uint64) error {
+	stmt := fmt.Sprintf(internal.ReleaseLease, a.tables.leases)
+	_, err := a.db.Exec(stmt, name, holder, token)
+	return err
+}
@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/db/driver/pg/internal"
+	"decred.org/dcrdex/server/fault"
 )
 
 func (a *Archiver) matchTableName(match *order.Match) (string, error) {
@@ -54,7 +56,7 @@ func userMatches(ctx context.Context, dbe *sql.DB, tableName string, aid account
 			&m.Taker, &m.TakerAcct, &takerAddr,
 			&m.Maker, &m.MakerAcct, &makerAddr,
 			&m.Epoch.Idx, &m.Epoch.Dur, &m.Quantity, &m.Rate,
-			&baseRate, &quoteRate, &status)
+			&baseRate, &quoteRate, &status, &m.TradeFee)
 		if err != nil {
 			return nil, err
 		}
@@ -163,6 +165,73 @@ func activeUserMatches(ctx context.Context, dbe *sql.DB, tableName string, aid a
 	return ms, nil
 }
 
+// CompletedMatches retrieves the trade matches of all markets that were made
+// from start (inclusive) to end (exclusive) and have completed swaps, sorted by
+// the time they were made.
+func (a *Archiver) CompletedMatches(start, end int64) ([]*db.CompletedMatch, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, a.queryTimeout)
+	defer cancel()
+
+	var matches []*db.CompletedMatch
+	for m, mkt := range a.markets {
+		matchesTableName := fullMatchesTableName(a.dbName, m)
+		matchesM, err := completedMatches(ctx, a.db, matchesTableName, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, cm := range matchesM {
+			cm.Base, cm.Quote = mkt.Base, mkt.Quote
+		}
+		matches = append(matches, matchesM...)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Made < matches[j].Made
+	})
+
+	return matches, nil
+}
+
+func completedMatches(ctx context.Context, dbe *sql.DB, tableName string, start, end int64) ([]*db.CompletedMatch, error) {
+	stmt := fmt.Sprintf(internal.RetrieveCompletedMatches, tableName)
+	rows, err := dbe.QueryContext(ctx, stmt, uint8(order.MatchComplete), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ms []*db.CompletedMatch
+	for rows.Next() {
+		var cm db.CompletedMatch
+		m := &cm.MatchData
+		var status uint8
+		var baseRate, quoteRate, settled sql.NullInt64
+		var takerAddr, makerAddr sql.NullString
+		err := rows.Scan(&m.ID, &m.TakerSell,
+			&m.Taker, &m.TakerAcct, &takerAddr,
+			&m.Maker, &m.MakerAcct, &makerAddr,
+			&m.Epoch.Idx, &m.Epoch.Dur, &m.Quantity, &m.Rate,
+			&baseRate, &quoteRate, &status, &m.TradeFee, &settled)
+		if err != nil {
+			return nil, err
+		}
+		m.Status = order.MatchStatus(status)
+		m.TakerAddr = takerAddr.String
+		m.MakerAddr = makerAddr.String
+		m.BaseRate = uint64(baseRate.Int64)
+		m.QuoteRate = uint64(quoteRate.Int64)
+		cm.Made = int64(m.Epoch.Idx+1) * int64(m.Epoch.Dur)
+		cm.Settled = settled.Int64
+
+		ms = append(ms, &cm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
 func upsertMatch(dbe sqlExecutor, tableName string, match *order.Match) (int64, error) {
 	var takerAddr string
 	tt := match.Taker.Trade()
@@ -188,11 +257,15 @@ func upsertMatch(dbe sqlExecutor, tableName string, match *order.Match) (int64,
 		match.Maker.ID(), match.Maker.User(), match.Maker.Trade().SwapAddress(),
 		match.Epoch.Idx, match.Epoch.Dur,
 		int64(match.Quantity), int64(match.Rate),
-		match.FeeRateBase, match.FeeRateQuote, int8(match.Status))
+		match.FeeRateBase, match.FeeRateQuote, int8(match.Status), match.TradeFee)
 }
 
 // InsertMatch updates an existing match.
 func (a *Archiver) InsertMatch(match *order.Match) error {
+	if fault.Hit(fault.DBError) {
+		log.Warnf("Fault injection: failing to insert match %v", match.ID())
+		return fault.ErrInjected
+	}
 	matchesTableName, err := a.matchTableName(match)
 	if err != nil {
 		return err
@@ -205,6 +278,7 @@ func (a *Archiver) InsertMatch(match *order.Match) error {
 	if N != 1 {
 		return fmt.Errorf("upsertMatch: updated %d rows, expected 1", N)
 	}
+	a.tracer.KeyLogf(log, "DB", match.ID().String(), "stored match, status %v", match.Status)
 	return nil
 }
 
@@ -235,7 +309,7 @@ func matchByID(dbe *sql.DB, tableName string, mid order.MatchID) (*db.MatchData,
 			&m.Taker, &m.TakerAcct, &takerAddr,
 			&m.Maker, &m.MakerAcct, &makerAddr,
 			&m.Epoch.Idx, &m.Epoch.Dur, &m.Quantity, &m.Rate,
-			&baseRate, &quoteRate, &status)
+			&baseRate, &quoteRate, &status, &m.TradeFee)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +24,7 @@ func TestInsertMatch(t *testing.T) {
 	epochID := order.EpochID{132412341, 10}
 	// Taker is selling.
 	matchA := newMatch(limitBuyStanding, limitSellImmediate, limitSellImmediate.Quantity, epochID)
+	matchA.TradeFee = 20
 
 	base, quote := limitBuyStanding.Base(), limitBuyStanding.Quote()
 
@@ -90,6 +91,10 @@ func TestInsertMatch(t *testing.T) {
 				t.Errorf("Incorrect match status, got %d, expected %d",
 					matchData.Status, tt.match.Status)
 			}
+			if matchData.TradeFee != tt.match.TradeFee {
+				t.Errorf("Incorrect match trade fee, got %d, expected %d",
+					matchData.TradeFee, tt.match.TradeFee)
+			}
 			if tt.isCancel {
 				if matchData.Active {
 					t.Errorf("Incorrect match active flag, got %v, expected false",
@@ -636,3 +641,61 @@ func TestActiveMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestCompletedMatches(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	// A completed match in the epoch ending at 1000 ms.
+	limitBuyStanding := newLimitOrder(false, 4500000, 1, order.StandingTiF, 0)
+	limitSellImmediate := newLimitOrder(true, 4490000, 1, order.ImmediateTiF, 10)
+	match := newMatch(limitBuyStanding, limitSellImmediate, limitSellImmediate.Quantity, order.EpochID{99, 10})
+	match.Status = order.MakerRedeemed
+	if err := archie.InsertMatch(match); err != nil {
+		t.Fatalf("InsertMatch() failed: %v", err)
+	}
+	if err := archie.SaveRedeemB(db.MatchID(match), randomBytes(36), 1500); err != nil {
+		t.Fatalf("SaveRedeemB() failed: %v", err)
+	}
+
+	// A match in the same epoch that is still settling.
+	limitBuyStanding2 := newLimitOrder(false, 4500000, 1, order.StandingTiF, 20)
+	limitSellImmediate2 := newLimitOrder(true, 4490000, 1, order.ImmediateTiF, 30)
+	match2 := newMatch(limitBuyStanding2, limitSellImmediate2, limitSellImmediate2.Quantity, order.EpochID{99, 10})
+	match2.Status = order.TakerSwapCast
+	if err := archie.InsertMatch(match2); err != nil {
+		t.Fatalf("InsertMatch() failed: %v", err)
+	}
+
+	matches, err := archie.CompletedMatches(1000, 1010)
+	if err != nil {
+		t.Fatalf("CompletedMatches() failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("retrieved %d completed matches, expected 1", len(matches))
+	}
+	cm := matches[0]
+	if cm.ID != match.ID() {
+		t.Errorf("wrong match ID. got %v, want %v", cm.ID, match.ID())
+	}
+	if cm.Base != match.Maker.BaseAsset || cm.Quote != match.Maker.QuoteAsset {
+		t.Errorf("wrong market. got %d-%d, want %d-%d", cm.Base, cm.Quote,
+			match.Maker.BaseAsset, match.Maker.QuoteAsset)
+	}
+	if cm.Made != 1000 || cm.Settled != 1500 {
+		t.Errorf("wrong times. got made %d, settled %d, want 1000, 1500", cm.Made, cm.Settled)
+	}
+	if !cm.TakerSell || cm.Quantity != match.Quantity || cm.Rate != match.Rate {
+		t.Errorf("wrong match data: %+v", cm.MatchData)
+	}
+
+	// The period excludes the match.
+	matches, err = archie.CompletedMatches(0, 1000)
+	if err != nil {
+		t.Fatalf("CompletedMatches() failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("retrieved %d completed matches, expected 0", len(matches))
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/db/driver/pg/internal"
+	"decred.org/dcrdex/server/fault"
 )
 
 // Wrap the CoinID slice to implement custom Scanner and Valuer.
@@ -184,7 +185,11 @@ func (status pgOrderStatus) active() bool {
 // NewEpochOrder stores the given order with epoch status. This is equivalent to
 // StoreOrder with OrderStatusEpoch.
 func (a *Archiver) NewEpochOrder(ord order.Order, epochIdx, epochDur int64) error {
-	return a.storeOrder(ord, epochIdx, epochDur, orderStatusEpoch)
+	if err := a.storeOrder(ord, epochIdx, epochDur, orderStatusEpoch); err != nil {
+		return err
+	}
+	a.tracer.KeyLogf(log, "DB", ord.ID().String(), "stored epoch order for epoch %d", epochIdx)
+	return nil
 }
 
 func makePseudoCancel(target order.OrderID, user account.AccountID, base, quote uint32, timeStamp time.Time) *order.CancelOrder {
@@ -457,6 +462,10 @@ func (a *Archiver) StoreOrder(ord order.Order, epochIdx, epochDur int64, status
 }
 
 func (a *Archiver) storeOrder(ord order.Order, epochIdx, epochDur int64, status pgOrderStatus) error {
+	if fault.Hit(fault.DBError) {
+		log.Warnf("Fault injection: failing to store order %v", ord.ID())
+		return fault.ErrInjected
+	}
 	marketSchema, err := a.marketSchema(ord.Base(), ord.Quote())
 	if err != nil {
 		return err
@@ -1167,22 +1176,23 @@ func loadTradeFromTable(dbe *sql.DB, fullTable string, oid order.OrderID) (order
 	var trade order.Trade
 	var id order.OrderID
 	var tif order.TimeInForce
-	var rate uint64
+	var rate, visible uint64
 	var status pgOrderStatus
 	err := dbe.QueryRow(stmt, oid).Scan(&id, &prefix.OrderType, &trade.Sell,
 		&prefix.AccountID, &trade.Address, &prefix.ClientTime, &prefix.ServerTime,
 		&prefix.Commit, (*dbCoins)(&trade.Coins),
-		&trade.Quantity, &rate, &tif, &status, &trade.FillAmt)
+		&trade.Quantity, &rate, &tif, &status, &trade.FillAmt, &visible)
 	if err != nil {
 		return nil, orderStatusUnknown, err
 	}
 	switch prefix.OrderType {
 	case order.LimitOrderType:
 		return &order.LimitOrder{
-			T:     *trade.Copy(), // govet would complain because Trade has a Mutex
-			P:     prefix,
-			Rate:  rate,
-			Force: tif,
+			T:       *trade.Copy(), // govet would complain because Trade has a Mutex
+			P:       prefix,
+			Rate:    rate,
+			Force:   tif,
+			Visible: visible,
 		}, status, nil
 	case order.MarketOrderType:
 		return &order.MarketOrder{
@@ -1237,11 +1247,11 @@ func ordersByStatusFromTable(ctx context.Context, dbe *sql.DB, fullTable string,
 		var trade order.Trade
 		var id order.OrderID
 		var tif order.TimeInForce
-		var rate uint64
+		var rate, visible uint64
 		err = rows.Scan(&id, &prefix.OrderType, &trade.Sell,
 			&prefix.AccountID, &trade.Address, &prefix.ClientTime, &prefix.ServerTime,
 			&prefix.Commit, (*dbCoins)(&trade.Coins),
-			&trade.Quantity, &rate, &tif, &trade.FillAmt)
+			&trade.Quantity, &rate, &tif, &trade.FillAmt, &visible)
 		if err != nil {
 			return nil, err
 		}
@@ -1251,10 +1261,11 @@ func ordersByStatusFromTable(ctx context.Context, dbe *sql.DB, fullTable string,
 		switch prefix.OrderType {
 		case order.LimitOrderType:
 			ord = &order.LimitOrder{
-				P:     prefix,
-				T:     *trade.Copy(),
-				Rate:  rate,
-				Force: tif,
+				P:       prefix,
+				T:       *trade.Copy(),
+				Rate:    rate,
+				Force:   tif,
+				Visible: visible,
 			}
 		case order.MarketOrderType:
 			ord = &order.MarketOrder{
@@ -1293,12 +1304,12 @@ func userOrdersFromTable(ctx context.Context, dbe *sql.DB, fullTable string, bas
 		var trade order.Trade
 		var id order.OrderID
 		var tif order.TimeInForce
-		var rate uint64
+		var rate, visible uint64
 		var status pgOrderStatus
 		err = rows.Scan(&id, &prefix.OrderType, &trade.Sell,
 			&prefix.AccountID, &trade.Address, &prefix.ClientTime, &prefix.ServerTime,
 			&prefix.Commit, (*dbCoins)(&trade.Coins),
-			&trade.Quantity, &rate, &tif, &status, &trade.FillAmt)
+			&trade.Quantity, &rate, &tif, &status, &trade.FillAmt, &visible)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1308,10 +1319,11 @@ func userOrdersFromTable(ctx context.Context, dbe *sql.DB, fullTable string, bas
 		switch prefix.OrderType {
 		case order.LimitOrderType:
 			ord = &order.LimitOrder{
-				P:     prefix,
-				T:     *trade.Copy(),
-				Rate:  rate,
-				Force: tif,
+				P:       prefix,
+				T:       *trade.Copy(),
+				Rate:    rate,
+				Force:   tif,
+				Visible: visible,
 			}
 		case order.MarketOrderType:
 			ord = &order.MarketOrder{
@@ -1383,14 +1395,14 @@ func storeLimitOrder(dbe sqlExecutor, tableName string, lo *order.LimitOrder, st
 	stmt := fmt.Sprintf(internal.InsertOrder, tableName)
 	return sqlExec(dbe, stmt, lo.ID(), lo.Type(), lo.Sell, lo.AccountID,
 		lo.Address, lo.ClientTime, lo.ServerTime, lo.Commit, dbCoins(lo.Coins),
-		lo.Quantity, lo.Rate, lo.Force, status, lo.Filled(), epochIdx, epochDur)
+		lo.Quantity, lo.Rate, lo.Force, status, lo.Filled(), epochIdx, epochDur, lo.Visible)
 }
 
 func storeMarketOrder(dbe sqlExecutor, tableName string, mo *order.MarketOrder, status pgOrderStatus, epochIdx, epochDur int64) (int64, error) {
 	stmt := fmt.Sprintf(internal.InsertOrder, tableName)
 	return sqlExec(dbe, stmt, mo.ID(), mo.Type(), mo.Sell, mo.AccountID,
 		mo.Address, mo.ClientTime, mo.ServerTime, mo.Commit, dbCoins(mo.Coins),
-		mo.Quantity, 0, order.ImmediateTiF, status, mo.Filled(), epochIdx, epochDur)
+		mo.Quantity, 0, order.ImmediateTiF, status, mo.Filled(), epochIdx, epochDur, 0)
 }
 
 func updateOrderStatus(dbe sqlExecutor, tableName string, oid order.OrderID, status pgOrderStatus) error {
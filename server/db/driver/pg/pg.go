@@ -12,6 +12,7 @@ import (
 
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/dcrutil/v2"
 	"github.com/decred/dcrd/hdkeychain/v2"
@@ -65,12 +66,21 @@ type Config struct {
 	// FeeKey is base58-encoded extended public key that will be used for
 	// generating fee payment addresses.
 	FeeKey string
+
+	// Tracer records the events of stored orders and matches. It may be nil.
+	Tracer *trace.Tracer
 }
 
 // Some frequently used long-form table names.
 type archiverTables struct {
-	feeKeys  string
-	accounts string
+	feeKeys        string
+	accounts       string
+	appeals        string
+	tradeFees      string
+	preimageMisses string
+	accountNotes   string
+	clientVersions string
+	leases         string
 }
 
 // Archiver must implement server/db.DEXArchivist.
@@ -86,6 +96,7 @@ type Archiver struct {
 	keyHash      []byte // Store the hash to ref the counter table.
 	keyParams    *chaincfg.Params
 	tables       archiverTables
+	tracer       *trace.Tracer
 
 	fatalMtx sync.RWMutex
 	fatal    chan struct{}
@@ -167,10 +178,17 @@ func NewArchiver(ctx context.Context, cfg *Config) (*Archiver, error) {
 		markets:      mktMap,
 		//checkedStores: cfg.CheckedStores,
 		tables: archiverTables{
-			feeKeys:  fullTableName(cfg.DBName, publicSchema, feeKeysTableName),
-			accounts: fullTableName(cfg.DBName, publicSchema, accountsTableName),
+			feeKeys:        fullTableName(cfg.DBName, publicSchema, feeKeysTableName),
+			accounts:       fullTableName(cfg.DBName, publicSchema, accountsTableName),
+			appeals:        fullTableName(cfg.DBName, publicSchema, appealsTableName),
+			tradeFees:      fullTableName(cfg.DBName, publicSchema, tradeFeesTableName),
+			preimageMisses: fullTableName(cfg.DBName, publicSchema, preimageMissesTableName),
+			accountNotes:   fullTableName(cfg.DBName, publicSchema, accountNotesTableName),
+			clientVersions: fullTableName(cfg.DBName, publicSchema, clientVersionsTableName),
+			leases:         fullTableName(cfg.DBName, publicSchema, leasesTableName),
 		},
-		fatal: make(chan struct{}),
+		fatal:  make(chan struct{}),
+		tracer: cfg.Tracer,
 	}
 
 	// Check critical performance-related settings.
@@ -0,0 +1,88 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package pg
+
+import "fmt"
+
+// SchemaReport describes the state of the database schema relative to the
+// market configuration. It is produced by CheckSchema without modifying the
+// database.
+type SchemaReport struct {
+	// Version is the stored schema version. It is zero if the database has no
+	// meta table or no version has been recorded.
+	Version uint32
+	// MissingTables lists the tables that do not yet exist, and would be
+	// created by NewArchiver.
+	MissingTables []string
+	// LotSizeChanges lists the markets whose configured lot size differs from
+	// the lot size stored in the markets table. Changing a market's lot size
+	// is not supported.
+	LotSizeChanges []string
+}
+
+// CheckSchema connects to the database described by cfg and inspects its
+// schema version and tables, without creating or modifying anything.
+func CheckSchema(cfg *Config) (*SchemaReport, error) {
+	db, err := connect(cfg.Host, cfg.Port, cfg.User, cfg.Pass, cfg.DBName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// connect does not necessarily dial the server.
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+
+	report := new(SchemaReport)
+	exists := func(schema, table string) (bool, error) {
+		found, err := namespacedTableExists(db, schema, table)
+		if err != nil {
+			return false, fmt.Errorf("failed to check for table %s.%s: %v", schema, table, err)
+		}
+		if !found {
+			report.MissingTables = append(report.MissingTables, schema+"."+table)
+		}
+		return found, nil
+	}
+
+	haveTable := make(map[string]bool)
+	for _, stmts := range [][]tableStmt{createDEXTableStatements, createAccountTableStatements} {
+		for _, c := range stmts {
+			if haveTable[c.name], err = exists(publicSchema, c.name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if haveTable[metaTableName] {
+		if report.Version, _, err = retrieveDBVersion(db); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %v", err)
+		}
+	}
+
+	storedMkts := make(map[string]uint64)
+	if haveTable[marketsTableName] {
+		mkts, err := loadMarkets(db, marketsTableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read markets table: %v", err)
+		}
+		for _, mkt := range mkts {
+			storedMkts[mkt.Name] = mkt.LotSize
+		}
+	}
+
+	for _, mkt := range cfg.MarketCfg {
+		if lotSize, found := storedMkts[mkt.Name]; found && lotSize != mkt.LotSize {
+			report.LotSizeChanges = append(report.LotSizeChanges,
+				fmt.Sprintf("%s: stored lot size %d, configured %d", mkt.Name, lotSize, mkt.LotSize))
+		}
+		for _, c := range createMarketTableStatements {
+			if _, err = exists(mkt.Name, c.name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -217,6 +217,50 @@ func Test_sqlExec(t *testing.T) {
 	}
 }
 
+func TestCheckSchema(t *testing.T) {
+	if err := cleanTables(archie.db); err != nil {
+		t.Fatalf("cleanTables: %v", err)
+	}
+
+	cfg := &Config{
+		Host:      PGTestsHost,
+		Port:      PGTestsPort,
+		User:      PGTestsUser,
+		Pass:      PGTestsPass,
+		DBName:    PGTestsDBName,
+		MarketCfg: mktConfig(),
+	}
+	report, err := CheckSchema(cfg)
+	if err != nil {
+		t.Fatalf("CheckSchema: %v", err)
+	}
+	if report.Version != DBVersion {
+		t.Errorf("wrong schema version %d, expected %d", report.Version, DBVersion)
+	}
+	if len(report.MissingTables) != 0 {
+		t.Errorf("unexpected missing tables %v", report.MissingTables)
+	}
+	if len(report.LotSizeChanges) != 0 {
+		t.Errorf("unexpected lot size changes %v", report.LotSizeChanges)
+	}
+
+	// A new market with tables to be created, and a lot size change.
+	newMkt, _ := dex.NewMarketInfoFromSymbols("ltc", "dcr", LotSize, EpochDuration, MarketBuyBuffer)
+	changed := *mktInfo
+	changed.LotSize *= 2
+	cfg.MarketCfg = []*dex.MarketInfo{&changed, newMkt}
+	report, err = CheckSchema(cfg)
+	if err != nil {
+		t.Fatalf("CheckSchema: %v", err)
+	}
+	if len(report.MissingTables) != len(createMarketTableStatements) {
+		t.Errorf("expected %d missing tables, got %v", len(createMarketTableStatements), report.MissingTables)
+	}
+	if len(report.LotSizeChanges) != 1 {
+		t.Errorf("expected 1 lot size change, got %v", report.LotSizeChanges)
+	}
+}
+
 func Test_checkCurrentTimeZone(t *testing.T) {
 	currentTZ, err := checkCurrentTimeZone(archie.db)
 	if err != nil {
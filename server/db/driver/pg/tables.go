@@ -5,16 +5,29 @@ package pg
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/server/db/driver/pg/internal"
 )
 
+// DBVersion is the version of the database schema created by this package.
+// The version is stored in the meta table, and a database with a newer schema
+// is refused.
+const DBVersion = 3
+
 const (
-	marketsTableName  = "markets"
-	feeKeysTableName  = "fee_keys"
-	accountsTableName = "accounts"
+	metaTableName           = "meta"
+	marketsTableName        = "markets"
+	feeKeysTableName        = "fee_keys"
+	accountsTableName       = "accounts"
+	appealsTableName        = "appeals"
+	tradeFeesTableName      = "trade_fees"
+	preimageMissesTableName = "preimage_misses"
+	accountNotesTableName   = "account_notes"
+	clientVersionsTableName = "client_versions"
+	leasesTableName         = "leases"
 )
 
 type tableStmt struct {
@@ -23,12 +36,19 @@ type tableStmt struct {
 }
 
 var createDEXTableStatements = []tableStmt{
+	{metaTableName, internal.CreateMetaTable},
 	{marketsTableName, internal.CreateMarketsTable},
+	{leasesTableName, internal.CreateLeasesTable},
 }
 
 var createAccountTableStatements = []tableStmt{
 	{feeKeysTableName, internal.CreateFeeKeysTable},
 	{accountsTableName, internal.CreateAccountsTable},
+	{appealsTableName, internal.CreateAppealsTable},
+	{tradeFeesTableName, internal.CreateTradeFeesTable},
+	{preimageMissesTableName, internal.CreatePreimageMissesTable},
+	{accountNotesTableName, internal.CreateAccountNotesTable},
+	{clientVersionsTableName, internal.CreateClientVersionsTable},
 }
 
 var createMarketTableStatements = []tableStmt{
@@ -103,6 +123,13 @@ func CreateTable(db *sql.DB, schema, tableName string) (bool, error) {
 // PrepareTables ensures that all tables required by the DEX market config,
 // mktConfig, are ready.
 func PrepareTables(db *sql.DB, mktConfig []*dex.MarketInfo) error {
+	// Create the meta table, and check that the schema is not newer than this
+	// version of the DEX supports.
+	ver, err := prepareMeta(db)
+	if err != nil {
+		return err
+	}
+
 	// Create the markets table in the public schema.
 	created, err := CreateTable(db, publicSchema, marketsTableName)
 	if err != nil {
@@ -112,13 +139,23 @@ func PrepareTables(db *sql.DB, mktConfig []*dex.MarketInfo) error {
 		log.Trace("Creating new markets table.")
 	}
 
+	// Create the leases table in the public schema.
+	if _, err = CreateTable(db, publicSchema, leasesTableName); err != nil {
+		return fmt.Errorf("failed to create leases table: %v", err)
+	}
+
 	// Verify config of existing markets, creating a new markets table if none
 	// exists.
-	_, err = prepareMarkets(db, mktConfig)
+	mkts, err := prepareMarkets(db, mktConfig)
 	if err != nil {
 		return err
 	}
 
+	// Bring the tables of existing markets up to the current schema version.
+	if err = upgradeDB(db, ver, mkts); err != nil {
+		return err
+	}
+
 	// Prepare the account and registration key counter tables.
 	err = createAccountTables(db)
 	if err != nil {
@@ -127,6 +164,97 @@ func PrepareTables(db *sql.DB, mktConfig []*dex.MarketInfo) error {
 	return nil
 }
 
+// prepareMeta creates the meta table if it does not exist, recording the
+// current DBVersion in a new table. A database that has a markets table but no
+// schema version predates the meta table, and is recorded as version 1 so that
+// it is upgraded. The stored schema version is returned. An error is returned
+// if the stored schema version is newer than DBVersion.
+func prepareMeta(db *sql.DB) (uint32, error) {
+	// The markets table is checked before any tables are created.
+	existingDB, err := namespacedTableExists(db, publicSchema, marketsTableName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for markets table: %v", err)
+	}
+
+	created, err := CreateTable(db, publicSchema, metaTableName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create meta table: %v", err)
+	}
+	if created {
+		log.Trace("Creating new meta table.")
+	}
+
+	ver, found, err := retrieveDBVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	if !found {
+		ver = DBVersion
+		if existingDB {
+			ver = 1
+			log.Infof("Existing database has no schema version. Assuming version %d.", ver)
+		}
+		stmt := fmt.Sprintf(internal.InsertDBVersion, metaTableName)
+		if _, err = db.Exec(stmt, ver); err != nil {
+			return 0, fmt.Errorf("failed to store schema version: %v", err)
+		}
+		return ver, nil
+	}
+	if ver > DBVersion {
+		return 0, fmt.Errorf("database schema version %d is newer than the supported version %d",
+			ver, DBVersion)
+	}
+	return ver, nil
+}
+
+// upgradeDB upgrades the tables of the existing markets, mkts, from schema
+// version ver to DBVersion, and records the new version in the meta table.
+// Version 2 adds the visible column to the order tables, and version 3 adds the
+// tradeFee column to the matches table.
+func upgradeDB(db *sql.DB, ver uint32, mkts map[string]*dex.MarketInfo) error {
+	if ver >= DBVersion {
+		return nil
+	}
+	log.Infof("Upgrading database schema from version %d to %d.", ver, DBVersion)
+	if ver < 2 {
+		for name := range mkts {
+			for _, table := range []string{"orders_active", "orders_archived"} {
+				stmt := fmt.Sprintf(internal.AddOrdersVisibleColumn, name+"."+table)
+				if _, err := db.Exec(stmt); err != nil {
+					return fmt.Errorf("failed to add visible column to %s.%s: %v", name, table, err)
+				}
+			}
+		}
+	}
+	if ver < 3 {
+		for name := range mkts {
+			stmt := fmt.Sprintf(internal.AddMatchesTradeFeeColumn, name+".matches")
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to add tradeFee column to %s.matches: %v", name, err)
+			}
+		}
+	}
+	stmt := fmt.Sprintf(internal.SetDBVersion, metaTableName)
+	if _, err := db.Exec(stmt, DBVersion); err != nil {
+		return fmt.Errorf("failed to store schema version: %v", err)
+	}
+	return nil
+}
+
+// retrieveDBVersion retrieves the schema version from the meta table. found is
+// false if the table has no version row.
+func retrieveDBVersion(db *sql.DB) (ver uint32, found bool, err error) {
+	stmt := fmt.Sprintf(internal.SelectDBVersion, metaTableName)
+	err = db.QueryRow(stmt).Scan(&ver)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+	return ver, true, nil
+}
+
 // prepareMarkets ensures that the market-specific tables required by the DEX
 // market config, mktConfig, are ready. See also PrepareTables.
 func prepareMarkets(db *sql.DB, mktConfig []*dex.MarketInfo) (map[string]*dex.MarketInfo, error) {
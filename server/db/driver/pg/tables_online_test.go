@@ -3,6 +3,7 @@
 package pg
 
 import (
+	"fmt"
 	"testing"
 
 	"decred.org/dcrdex/dex"
@@ -58,3 +59,72 @@ func TestPrepareTables(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestUpgradeDB(t *testing.T) {
+	if err := nukeAll(archie.db); err != nil {
+		t.Fatal(err)
+	}
+
+	mktConfig, err := dex.NewMarketInfoFromSymbols("DCR", "BTC", 1e9, EpochDuration, MarketBuyBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markets := []*dex.MarketInfo{mktConfig}
+	if err = PrepareTables(archie.db, markets); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revert to a schema that predates the meta table, with no visible column
+	// in the order tables and no tradeFee column in the matches table.
+	if err = dropTable(archie.db, "public."+metaTableName); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"orders_active", "orders_archived"} {
+		stmt := fmt.Sprintf("ALTER TABLE %s.%s DROP COLUMN visible;", mktConfig.Name, table)
+		if _, err = archie.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmt := fmt.Sprintf("ALTER TABLE %s.matches DROP COLUMN tradeFee;", mktConfig.Name)
+	if _, err = archie.db.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = PrepareTables(archie.db, markets); err != nil {
+		t.Fatal(err)
+	}
+	ver, found, err := retrieveDBVersion(archie.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || ver != DBVersion {
+		t.Fatalf("wrong schema version %d (found = %v)", ver, found)
+	}
+	for _, col := range []struct{ table, column string }{
+		{"orders_active", "visible"},
+		{"orders_archived", "visible"},
+		{"matches", "tradefee"},
+	} {
+		var n int
+		err = archie.db.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = $1 AND table_name = $2 AND column_name = $3;`,
+			mktConfig.Name, col.table, col.column).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("%s column not added to %s.%s", col.column, mktConfig.Name, col.table)
+		}
+	}
+
+	// A new database is created at the current version.
+	if err = nukeAll(archie.db); err != nil {
+		t.Fatal(err)
+	}
+	if err = PrepareTables(archie.db, markets); err != nil {
+		t.Fatal(err)
+	}
+	if ver, _, err = retrieveDBVersion(archie.db); err != nil || ver != DBVersion {
+		t.Fatalf("wrong new schema version %d: %v", ver, err)
+	}
+}
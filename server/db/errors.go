@@ -51,6 +51,8 @@ const (
 	ErrReusedCommit
 	ErrOrderNotExecuted
 	ErrUpdateCount
+	ErrLeaseHeld
+	ErrLeaseLost
 )
 
 func (ae ArchiveError) Error() string {
@@ -72,6 +74,10 @@ func (ae ArchiveError) Error() string {
 		desc = "order not in executed status"
 	case ErrUpdateCount:
 		desc = "unexpected number of rows updated"
+	case ErrLeaseHeld:
+		desc = "lease held by another server"
+	case ErrLeaseLost:
+		desc = "lease taken by another server"
 	}
 
 	if ae.Detail == "" {
@@ -146,3 +152,17 @@ func IsErrUpdateCount(err error) bool {
 	var errA ArchiveError
 	return errors.As(err, &errA) && errA.Code == ErrUpdateCount
 }
+
+// IsErrLeaseHeld returns true if the error is of type ArchiveError and has
+// code ErrLeaseHeld.
+func IsErrLeaseHeld(err error) bool {
+	var errA ArchiveError
+	return errors.As(err, &errA) && errA.Code == ErrLeaseHeld
+}
+
+// IsErrLeaseLost returns true if the error is of type ArchiveError and has
+// code ErrLeaseLost.
+func IsErrLeaseLost(err error) bool {
+	var errA ArchiveError
+	return errors.As(err, &errA) && errA.Code == ErrLeaseLost
+}
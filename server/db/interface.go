@@ -74,6 +74,28 @@ type DEXArchivist interface {
 	AccountArchiver
 	MatchArchiver
 	SwapArchiver
+	LeaseArchiver
+}
+
+// LeaseArchiver is the interface required for a server to hold the exclusive
+// right to run the DEX on a database shared with a standby server. Lease
+// expiry is judged by the database clock, so the servers' clocks need not
+// agree.
+type LeaseArchiver interface {
+	// AcquireLease takes the named lease for holder until ttl from now, if the
+	// lease is free, expired, or already held by holder. The lease's fencing
+	// token is incremented and returned. An ArchiveError with code
+	// ErrLeaseHeld is returned if another holder has an unexpired lease.
+	AcquireLease(name, holder string, ttl time.Duration) (uint64, error)
+
+	// RenewLease extends a lease taken with AcquireLease until ttl from now.
+	// An ArchiveError with code ErrLeaseLost is returned if the lease has
+	// since been taken with a newer token.
+	RenewLease(name, holder string, token uint64, ttl time.Duration) error
+
+	// ReleaseLease gives up a lease taken with AcquireLease, if it is still
+	// held with the given token.
+	ReleaseLease(name, holder string, token uint64) error
 }
 
 // OrderArchiver is the interface required for storage and retrieval of all
@@ -121,6 +143,14 @@ type OrderArchiver interface {
 	// StorePreimage stores the preimage associated with an existing order.
 	StorePreimage(ord order.Order, pi order.Preimage) error
 
+	// StorePreimageMiss records the circumstances of a missed preimage
+	// request.
+	StorePreimageMiss(*PreimageMiss) error
+
+	// PreimageMisses retrieves the recorded preimage misses for an account,
+	// oldest first.
+	PreimageMisses(account.AccountID) ([]*PreimageMiss, error)
+
 	// BookOrder books the given order. If the order was already stored (i.e.
 	// NewEpochOrder), it's status and filled amount are updated, otherwise it
 	// is inserted. See also UpdateOrderFilled.
@@ -195,6 +225,53 @@ type AccountArchiver interface {
 
 	// AccountInfo returns data for an account.
 	AccountInfo(account.AccountID) (*Account, error)
+
+	// SetAccountNotes stores the operator's notes and tags for an account,
+	// replacing any previous notes and tags.
+	SetAccountNotes(aid account.AccountID, notes string, tags []string) error
+
+	// ForgiveAccount reopens an account that was closed for violating a rule
+	// of community conduct.
+	ForgiveAccount(account.AccountID) error
+
+	// StoreAppeal stores a penalty appeal, replacing any previous appeal for
+	// the account.
+	StoreAppeal(*Appeal) error
+
+	// Appeal retrieves the penalty appeal for the account. A nil pointer is
+	// returned if the account has not submitted an appeal.
+	Appeal(account.AccountID) (*Appeal, error)
+
+	// Appeals returns all penalty appeals.
+	Appeals() ([]*Appeal, error)
+
+	// DecideAppeal records the operator's decision on the account's pending
+	// appeal.
+	DecideAppeal(aid account.AccountID, status AppealStatus, reason string) error
+
+	// RecordTradeFee stores a trade fee accrued by an account. A fee is stored
+	// at most once for each account and match.
+	RecordTradeFee(*TradeFee) error
+
+	// FeeInvoices totals the trade fees accrued in the period from start
+	// (inclusive) to end (exclusive), in UNIX milliseconds, for each account
+	// and asset.
+	FeeInvoices(start, end int64) ([]*FeeInvoice, error)
+
+	// TradeFees retrieves the trade fees accrued by an account, oldest first.
+	TradeFees(account.AccountID) ([]*TradeFee, error)
+
+	// RecordClientVersion records a connection of an account with a version
+	// of the client software at the given time, in UNIX milliseconds.
+	RecordClientVersion(aid account.AccountID, userAgent string, apiVer uint16, stamp int64) error
+
+	// ClientVersions returns the client software versions that an account has
+	// connected with, most recently seen first.
+	ClientVersions(account.AccountID) ([]*ClientVersion, error)
+
+	// ClientVersionStats aggregates the client software versions seen since
+	// the given time, in UNIX milliseconds.
+	ClientVersionStats(since int64) ([]*ClientVersionStats, error)
 }
 
 // MatchData represents an order pair match, but with just the order IDs instead
@@ -213,10 +290,20 @@ type MatchData struct {
 	Rate      uint64
 	BaseRate  uint64
 	QuoteRate uint64
+	TradeFee  uint32            // the market's trade fee rate in basis points, paid by each party
 	Active    bool              // match negotiation in progress, not yet completed or failed
 	Status    order.MatchStatus // note that failed swaps, where Active=false, can have any status
 }
 
+// CompletedMatch is a trade match with a completed swap.
+type CompletedMatch struct {
+	MatchData
+	Base    uint32
+	Quote   uint32
+	Made    int64 // the end of the match's epoch, in UNIX milliseconds
+	Settled int64 // the taker's redeem time, in UNIX milliseconds
+}
+
 // SwapData contains the data generated by the clients during swap negotiation.
 type SwapData struct {
 	SigMatchAckMaker []byte
@@ -264,6 +351,11 @@ type MatchArchiver interface {
 	UserMatches(aid account.AccountID, base, quote uint32) ([]*MatchData, error)
 	// ActiveMatches retrieves the current active matches for an account.
 	ActiveMatches(account.AccountID) ([]*order.UserMatch, error)
+	// CompletedMatches retrieves the trade matches of all markets that were
+	// made in the period from start (inclusive) to end (exclusive), in UNIX
+	// milliseconds, and have completed swaps. The matches are sorted by the
+	// time they were made.
+	CompletedMatches(start, end int64) ([]*CompletedMatch, error)
 }
 
 // SwapArchiver is the interface required for storage and retrieval of swap
@@ -4,7 +4,10 @@
 package db
 
 import (
+	"encoding/json"
+
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/account"
 )
 
@@ -15,4 +18,195 @@ type Account struct {
 	FeeAddress string            `json:"feeaddress"`
 	FeeCoin    dex.Bytes         `json:"feecoin"`
 	BrokenRule account.Rule      `json:"brokenrule"`
+	Notes      string            `json:"notes,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+}
+
+// AppealStatus is the status of a penalty appeal.
+type AppealStatus uint8
+
+// The possible AppealStatus values.
+const (
+	AppealPending AppealStatus = iota
+	AppealAccepted
+	AppealRejected
+)
+
+// String satisfies the Stringer interface.
+func (s AppealStatus) String() string {
+	switch s {
+	case AppealPending:
+		return "pending"
+	case AppealAccepted:
+		return "accepted"
+	case AppealRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// MarshalJSON marshals the AppealStatus as its string.
+func (s AppealStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Appeal is a client's appeal of the penalty that closed their account, and the
+// operator's decision, if any. Submitted and Decided are UNIX times in
+// milliseconds. Decided is zero for a pending appeal.
+type Appeal struct {
+	AccountID  account.AccountID `json:"accountid"`
+	BrokenRule account.Rule      `json:"brokenrule"`
+	Message    string            `json:"message"`
+	Sig        dex.Bytes         `json:"sig"`
+	Submitted  int64             `json:"submitted"`
+	Status     AppealStatus      `json:"status"`
+	Reason     string            `json:"reason,omitempty"`
+	Decided    int64             `json:"decided,omitempty"`
+}
+
+// TradeFee is the operator's trade fee accrued by an account for its side of a
+// match, in the asset that the account received. Stamp is the UNIX time in
+// milliseconds at which the fee accrued.
+type TradeFee struct {
+	MatchID   order.MatchID
+	AccountID account.AccountID
+	AssetID   uint32
+	Amount    uint64
+	Stamp     int64
+}
+
+// FeeInvoice is the total of an account's accrued trade fees in one asset over
+// a period. Start and End are UNIX times in milliseconds, and the period
+// includes Start but not End.
+type FeeInvoice struct {
+	AccountID account.AccountID `json:"accountid"`
+	AssetID   uint32            `json:"assetid"`
+	Amount    uint64            `json:"amount"`
+	Matches   int               `json:"matches"`
+	Start     int64             `json:"start"`
+	End       int64             `json:"end"`
+}
+
+// ClientVersion records the connections of an account with a version of the
+// client software. UserAgent is as reported by the client in the 'connect'
+// request, and is empty for clients that do not report it. FirstSeen and
+// LastSeen are UNIX times in milliseconds.
+type ClientVersion struct {
+	AccountID  account.AccountID `json:"accountid"`
+	UserAgent  string            `json:"useragent"`
+	APIVersion uint16            `json:"apiversion"`
+	FirstSeen  int64             `json:"firstseen"`
+	LastSeen   int64             `json:"lastseen"`
+	Connects   int64             `json:"connects"`
+}
+
+// ClientVersionStats aggregates the ClientVersions of a client software
+// version. Accounts is the number of accounts that connected with the version,
+// and Connects is the total number of their connections with it. Connected is
+// the number of clients currently connected with the version. It is not
+// stored, and is set by the DEX manager.
+type ClientVersionStats struct {
+	UserAgent  string `json:"useragent"`
+	APIVersion uint16 `json:"apiversion"`
+	Accounts   int64  `json:"accounts"`
+	Connects   int64  `json:"connects"`
+	LastSeen   int64  `json:"lastseen"`
+	Connected  int    `json:"connected"`
+}
+
+// PreimageMissReason describes why an order's preimage was not collected.
+type PreimageMissReason uint8
+
+// The possible PreimageMissReason values.
+const (
+	// PreimageTimeout means the request was delivered, but the client did not
+	// respond before the request expired.
+	PreimageTimeout PreimageMissReason = iota
+	// PreimageDisconnected means the request could not be delivered because
+	// the client was not connected.
+	PreimageDisconnected
+	// PreimageInvalid means the client responded, but the response was
+	// malformed or the preimage did not match the order commitment.
+	PreimageInvalid
+)
+
+// String satisfies the Stringer interface.
+func (r PreimageMissReason) String() string {
+	switch r {
+	case PreimageTimeout:
+		return "timeout"
+	case PreimageDisconnected:
+		return "disconnected"
+	case PreimageInvalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// PreimageMiss records the circumstances of an order's missed preimage
+// request, for distinguishing unreliable connections from deliberate refusal to
+// reveal. Connected is the client's connection state when the miss was
+// determined. Latency is the round-trip time of the client's response to the
+// preimage request, or of its most recent response to any request if it did
+// not respond. A zero Latency means no round trip was measured. Latency is in
+// milliseconds, and Stamp is the UNIX time in milliseconds of the miss.
+type PreimageMiss struct {
+	AccountID   account.AccountID
+	OrderID     order.OrderID
+	Commitment  order.Commitment
+	Base, Quote uint32
+	EpochIdx    int64
+	EpochDur    int64
+	Reason      PreimageMissReason
+	Connected   bool
+	Latency     int64
+	Stamp       int64
+}
+
+// TimelineEventType is the kind of a TimelineEvent.
+type TimelineEventType string
+
+// The possible TimelineEventType values.
+const (
+	// TimelineFirstConnect is the first connection of an account with a
+	// version of the client software.
+	TimelineFirstConnect TimelineEventType = "firstconnect"
+	// TimelineLastConnect is the latest connection of an account with a
+	// version of the client software.
+	TimelineLastConnect TimelineEventType = "lastconnect"
+	// TimelineOrder is the receipt of a trade order.
+	TimelineOrder TimelineEventType = "order"
+	// TimelineCancel is the execution of a cancel order, or the revocation of
+	// an order by the server.
+	TimelineCancel TimelineEventType = "cancel"
+	// TimelineMatch is a match, at the end of the epoch in which it was made.
+	TimelineMatch TimelineEventType = "match"
+	// TimelinePreimageMiss is a missed preimage request.
+	TimelinePreimageMiss TimelineEventType = "preimagemiss"
+	// TimelineAppeal is the submission of a penalty appeal.
+	TimelineAppeal TimelineEventType = "appeal"
+	// TimelineAppealDecided is the operator's decision on a penalty appeal.
+	TimelineAppealDecided TimelineEventType = "appealdecided"
+	// TimelineTradeFee is a trade fee accrued for a match.
+	TimelineTradeFee TimelineEventType = "tradefee"
+)
+
+// TimelineEvent is an event in an account's activity timeline. Stamp is the
+// UNIX time in milliseconds of the event, ID is the order or match ID that the
+// event concerns, if any, and Details is a human-readable description.
+type TimelineEvent struct {
+	Stamp   int64             `json:"stamp"`
+	Type    TimelineEventType `json:"type"`
+	Market  string            `json:"market,omitempty"`
+	ID      string            `json:"id,omitempty"`
+	Details string            `json:"details"`
+}
+
+// Timeline is a page of an account's activity timeline. Total is the number of
+// events in the requested period, of which Events are the ones from Offset.
+type Timeline struct {
+	AccountID account.AccountID `json:"accountid"`
+	Total     int               `json:"total"`
+	Offset    int               `json:"offset"`
+	Events    []*TimelineEvent  `json:"events"`
 }
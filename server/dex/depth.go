@@ -0,0 +1,69 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"fmt"
+
+	"decred.org/dcrdex/server/book"
+	"decred.org/dcrdex/server/comms"
+	"decred.org/dcrdex/server/market"
+)
+
+const (
+	// depthRoute is the HTTP data API route of a market's aggregated book
+	// depth. The route requires an API key.
+	depthRoute = "depth"
+	// apiKeysFile is the file in the DataDir where the API keys are stored.
+	apiKeysFile = "apikeys.json"
+)
+
+// MarketDepth is the aggregated order book of a market, with the price levels
+// sorted from the best rate to the worst rate. Rates and quantities are in
+// atoms.
+type MarketDepth struct {
+	Market string            `json:"market"`
+	Epoch  int64             `json:"epoch"`
+	Buys   []book.PriceLevel `json:"buys"`
+	Sells  []book.PriceLevel `json:"sells"`
+}
+
+// httpDepth is the handler for the HTTP data API market depth request.
+func httpDepth(markets map[string]*market.Market) comms.HTTPMarketHandler {
+	return func(mktName string) (interface{}, error) {
+		mkt := markets[mktName]
+		if mkt == nil || !mkt.Running() {
+			return nil, fmt.Errorf("unknown market %q", mktName)
+		}
+		epoch, buys, sells := mkt.Depth(0)
+		return &MarketDepth{
+			Market: mktName,
+			Epoch:  epoch,
+			Buys:   buys,
+			Sells:  sells,
+		}, nil
+	}
+}
+
+// APIKeys returns the HTTP data API keys with their usage.
+func (dm *DEX) APIKeys() []*comms.APIKey {
+	return dm.apiKeys.Keys()
+}
+
+// IssueAPIKey issues an HTTP data API key. The key is limited to the markets,
+// or valid for all markets if none are given, and to rateLimit requests per
+// minute.
+func (dm *DEX) IssueAPIKey(label string, markets []string, rateLimit uint32) (*comms.APIKey, error) {
+	for _, mktName := range markets {
+		if dm.market(mktName) == nil {
+			return nil, fmt.Errorf("unknown market %q", mktName)
+		}
+	}
+	return dm.apiKeys.Issue(label, markets, rateLimit)
+}
+
+// RevokeAPIKey revokes an HTTP data API key.
+func (dm *DEX) RevokeAPIKey(key string) error {
+	return dm.apiKeys.Revoke(key)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,13 +18,16 @@ import (
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/asset"
 	dcrasset "decred.org/dcrdex/server/asset/dcr"
+	"decred.org/dcrdex/server/asset/external"
 	"decred.org/dcrdex/server/auth"
 	"decred.org/dcrdex/server/coinlock"
 	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/db/driver/pg"
 	"decred.org/dcrdex/server/market"
+	"decred.org/dcrdex/server/replay"
 	"decred.org/dcrdex/server/swap"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 	"github.com/decred/slog"
 )
@@ -37,6 +42,12 @@ type AssetConf struct {
 	MaxFeeRate uint64 `json:"maxFeeRate"`
 	SwapConf   uint32 `json:"swapConf"`
 	ConfigPath string `json:"configPath"`
+	// External is the address of an external backend for the asset, which is
+	// used instead of the asset's driver. See the asset/external package.
+	External string `json:"external,omitempty"`
+	// FeeOracle optionally configures an external fee rate oracle to use when
+	// the backend's fee estimate fails or is not sane.
+	FeeOracle *asset.FeeOracleConfig `json:"feeOracle,omitempty"`
 }
 
 // DBConf groups the database configuration parameters.
@@ -52,6 +63,9 @@ type DBConf struct {
 // RPCConfig is an alias for the comms Server's RPC config struct.
 type RPCConfig = comms.RPCConfig
 
+// RPCListener is an alias for the comms Server's listener policy struct.
+type RPCListener = comms.Listener
+
 // LoggerMaker allows creation of new log subsystems with predefined levels.
 type LoggerMaker struct {
 	*slog.Backend
@@ -103,6 +117,52 @@ type DexConf struct {
 	Anarchy          bool
 	DEXPrivKey       *secp256k1.PrivateKey
 	CommsCfg         *RPCConfig
+	// RecordDir is a directory to which the order flow of each market is
+	// recorded for offline replay. Order flow is not recorded if empty.
+	RecordDir string
+	// Tracer records the events of client requests. Tracing is disabled if
+	// nil.
+	Tracer *trace.Tracer
+	// CheckpointInterval is how often the swap state is saved to DataDir for
+	// a standby server to follow. Zero disables checkpoints.
+	CheckpointInterval time.Duration
+	// Policy is the operator's policy document. If set, the document is
+	// signed with DEXPrivKey and published to clients.
+	Policy *PolicyConf
+	// KeyRotation optionally schedules the replacement of DEXPrivKey.
+	KeyRotation *KeyRotation
+	// Upgrade optionally announces a required protocol upgrade to clients.
+	Upgrade *UpgradeConf
+	// MarketImports are market exports from another server to import before
+	// the markets are started. See (*DEX).ExportMarket.
+	MarketImports []*market.Export
+	// PoWDifficulty is the proof-of-work difficulty initially required of
+	// 'register' and 'connect' requests. Zero disables the requirement. See
+	// (*DEX).SetPoWDifficulty.
+	PoWDifficulty uint8
+	// PenaltyPolicy is the initial mapping of broken rules to their
+	// penalties. If nil, any broken rule closes the account. See
+	// (*DEX).SetPenaltyPolicy.
+	PenaltyPolicy *account.PenaltyPolicy
+	// RegistrationHook, if set, approves or denies each account registration.
+	// Registration is open if nil. See (*DEX).SetRegistrationOverride.
+	RegistrationHook auth.RegistrationHook
+	// RegistrationCacheTTL is how long a decision of the RegistrationHook is
+	// reused for an account. Zero uses auth.DefaultRegistrationCacheTTL.
+	RegistrationCacheTTL time.Duration
+	// LeaseTTL, if non-zero, makes the DEX hold a lease in the database while
+	// it runs, renewing it at a quarter of this interval. A standby server
+	// sharing the database cannot be promoted while the lease is held, and a
+	// DEX that loses its lease is fenced. See (*DEX).Fenced.
+	LeaseTTL time.Duration
+}
+
+// UpgradeConf announces that clients must speak at least APIVersion from the
+// Deadline. Notes describe the upgrade for users.
+type UpgradeConf struct {
+	APIVersion uint16
+	Deadline   time.Time
+	Notes      string
 }
 
 type subsystem struct {
@@ -114,28 +174,51 @@ type subsystem struct {
 // components of the DEX.
 type DEX struct {
 	network     dex.Network
-	markets     map[string]*market.Market
 	assets      map[uint32]*swap.LockableAsset
 	storage     db.DEXArchivist
+	authMgr     *auth.AuthManager
 	swapper     *swap.Swapper
 	orderRouter *market.OrderRouter
 	bookRouter  *market.BookRouter
 	stopWaiters []subsystem
 	server      *comms.Server
+	apiKeys     *comms.APIKeyring
+	recordings  []*os.File
+
+	// quit is closed on Stop, and wg tracks market wind-downs.
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	marketsMtx sync.RWMutex
+	markets    map[string]*market.Market
+	delisting  map[string]bool
 
 	configRespMtx sync.RWMutex
 	configResp    *configResponse
+
+	// lease is the database lease held while running, if DexConf.LeaseTTL is
+	// set. fenced is closed if the lease is lost.
+	lease  *dexLease
+	fenced chan struct{}
+
+	// drained is closed when all active swaps are done after Drain. It is nil
+	// until Drain is called.
+	drainMtx sync.Mutex
+	drained  chan struct{}
 }
 
 // configResponse is defined here to leave open the possibility for hot
-// adjustable parameters while storing a pre-encoded config response message. An
-// update method will need to be defined in the future for this purpose.
+// adjustable parameters while storing a pre-encoded config response message.
+// The config message is signed by the DEX signer whenever it is encoded.
 type configResponse struct {
-	configMsg *msgjson.ConfigResult // constant for now
+	configMsg *msgjson.ConfigResult
 	configEnc json.RawMessage
+	signer    auth.Signer
 }
 
-func newConfigResponse(cfg *DexConf, cfgAssets []*msgjson.Asset, cfgMarkets []*msgjson.Market) (*configResponse, error) {
+func newConfigResponse(cfg *DexConf, cfgAssets []*msgjson.Asset, cfgMarkets []*msgjson.Market,
+	signer auth.Signer, rotation *msgjson.KeyRotation) (*configResponse, error) {
+
 	configMsg := &msgjson.ConfigResult{
 		BroadcastTimeout: uint64(cfg.BroadcastTimeout.Milliseconds()),
 		CancelMax:        cfg.CancelThreshold,
@@ -143,6 +226,15 @@ func newConfigResponse(cfg *DexConf, cfgAssets []*msgjson.Asset, cfgMarkets []*m
 		Assets:           cfgAssets,
 		Markets:          cfgMarkets,
 		Fee:              cfg.RegFeeAmount,
+		KeyRotation:      rotation,
+		APIVersion:       msgjson.APIVersion,
+	}
+	if u := cfg.Upgrade; u != nil {
+		configMsg.Upgrade = &msgjson.Upgrade{
+			APIVersion: u.APIVersion,
+			Deadline:   uint64(encode.UnixMilli(u.Deadline)),
+			Notes:      u.Notes,
+		}
 	}
 
 	// NOTE/TODO: To include active epoch in the market status objects, we need
@@ -151,22 +243,14 @@ func newConfigResponse(cfg *DexConf, cfgAssets []*msgjson.Asset, cfgMarkets []*m
 	// Markets, and suspend info that DEX obtained when calling the Market's
 	// Suspend method.
 
-	encResult, err := json.Marshal(configMsg)
-	if err != nil {
-		return nil, err
-	}
-	payload := &msgjson.ResponsePayload{
-		Result: encResult,
+	cr := &configResponse{
+		configMsg: configMsg,
+		signer:    signer,
 	}
-	encPayload, err := json.Marshal(payload)
-	if err != nil {
+	if err := cr.marshal(); err != nil {
 		return nil, err
 	}
-
-	return &configResponse{
-		configMsg: configMsg,
-		configEnc: encPayload,
-	}, nil
+	return cr, nil
 }
 
 func (cr *configResponse) setMktSuspend(name string, finalEpoch uint64, persist bool) {
@@ -181,35 +265,82 @@ func (cr *configResponse) setMktSuspend(name string, finalEpoch uint64, persist
 	log.Errorf("Failed to set MarketStatus for market %q", name)
 }
 
-func (cr *configResponse) remarshal() {
+func (cr *configResponse) setMktResume(name string, startEpoch uint64) {
+	for _, mkt := range cr.configMsg.Markets {
+		if mkt.Name == name {
+			mkt.MarketStatus.StartEpoch = startEpoch
+			mkt.MarketStatus.FinalEpoch = 0
+			mkt.MarketStatus.Persist = nil
+			cr.remarshal()
+			return
+		}
+	}
+	log.Errorf("Failed to set MarketStatus for market %q", name)
+}
+
+func (cr *configResponse) removeMarket(name string) {
+	mkts := make([]*msgjson.Market, 0, len(cr.configMsg.Markets))
+	for _, mkt := range cr.configMsg.Markets {
+		if mkt.Name != name {
+			mkts = append(mkts, mkt)
+		}
+	}
+	cr.configMsg.Markets = mkts
+	cr.remarshal()
+}
+
+// marshal signs the config message with the signer's current key, and encodes
+// the response payload.
+func (cr *configResponse) marshal() error {
+	cr.configMsg.DEXPubKey = cr.signer.PubKey().SerializeCompressed()
+	sig, err := cr.signer.Sign(cr.configMsg.Serialize())
+	if err != nil {
+		return fmt.Errorf("failed to sign config message: %v", err)
+	}
+	cr.configMsg.SetSig(sig.Serialize())
 	encResult, err := json.Marshal(cr.configMsg)
 	if err != nil {
-		log.Errorf("failed to marshal config message: %v", err)
-		return
+		return fmt.Errorf("failed to marshal config message: %v", err)
 	}
 	payload := &msgjson.ResponsePayload{
 		Result: encResult,
 	}
 	encPayload, err := json.Marshal(payload)
 	if err != nil {
-		log.Errorf("failed to marshal config message payload: %v", err)
-		return
+		return fmt.Errorf("failed to marshal config message payload: %v", err)
 	}
 	cr.configEnc = encPayload
+	return nil
+}
+
+func (cr *configResponse) remarshal() {
+	if err := cr.marshal(); err != nil {
+		log.Error(err)
+	}
 }
 
 // Stop shuts down the DEX. Stop returns only after all components have
 // completed their shutdown.
 func (dm *DEX) Stop() {
 	log.Infof("Stopping subsystems...")
+	close(dm.quit)
 	for _, ssw := range dm.stopWaiters {
 		ssw.Stop()
 		ssw.WaitForShutdown()
 		log.Infof("%s shutdown.", ssw.name)
 	}
+	dm.wg.Wait()
+	if dm.lease != nil {
+		dm.releaseLease()
+	}
 	if err := dm.storage.Close(); err != nil {
 		log.Errorf("DEXArchivist.Close: %v", err)
 	}
+	for _, f := range dm.recordings {
+		if err := f.Close(); err != nil {
+			log.Errorf("Failed to close order flow recording %s: %v", f.Name(), err)
+		}
+	}
 }
 
 func (dm *DEX) handleDEXConfig(conn comms.Link, msg *msgjson.Message) *msgjson.Error {
@@ -229,6 +360,35 @@ func (dm *DEX) handleDEXConfig(conn comms.Link, msg *msgjson.Message) *msgjson.E
 	return nil
 }
 
+// setupBackend sets up the asset's backend, connecting to its external backend
+// if one is configured.
+func setupBackend(assetConf *AssetConf, logger dex.Logger, network dex.Network) (asset.Backend, error) {
+	symbol := strings.ToLower(assetConf.Symbol)
+	if assetConf.External != "" {
+		logger.Infof("Using external backend at %s.", assetConf.External)
+		return external.Setup(symbol, assetConf.External, logger)
+	}
+	return asset.Setup(symbol, assetConf.ConfigPath, logger, network)
+}
+
+// pgConfig creates the pg DB driver configuration.
+func pgConfig(cfg *DexConf) *pg.Config {
+	return &pg.Config{
+		Host:         cfg.DBConf.Host,
+		Port:         strconv.Itoa(int(cfg.DBConf.Port)),
+		User:         cfg.DBConf.User,
+		Pass:         cfg.DBConf.Pass,
+		DBName:       cfg.DBConf.DBName,
+		ShowPGConfig: cfg.DBConf.ShowPGConfig,
+		QueryTimeout: 20 * time.Minute,
+		MarketCfg:    cfg.Markets,
+		//CheckedStores: true,
+		Net:    cfg.Network,
+		FeeKey: cfg.RegFeeXPub,
+		Tracer: cfg.Tracer,
+	}
+}
+
 // NewDEX creates the dex manager and starts all subsystems. Use Stop to
 // shutdown cleanly.
 //  1. Validate each specified asset.
@@ -309,7 +469,7 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 		// asset symbol must be available.
 		log.Infof("Starting asset backend %q...", symbol)
 		logger := cfg.LogBackend.SubLogger("ASSET", symbol)
-		be, err := asset.Setup(symbol, assetConf.ConfigPath, logger, cfg.Network)
+		be, err := setupBackend(assetConf, logger, cfg.Network)
 		if err != nil {
 			abort()
 			return nil, fmt.Errorf("failed to setup asset %q: %v", symbol, err)
@@ -326,6 +486,22 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 
 		startSubSys(fmt.Sprintf("Asset[%s]", symbol), be)
 
+		if assetConf.FeeOracle != nil {
+			// Default the oracle's upper sanity bound to the asset's max fee
+			// rate.
+			oracleCfg := *assetConf.FeeOracle
+			if oracleCfg.MaxFeeRate == 0 {
+				oracleCfg.MaxFeeRate = assetConf.MaxFeeRate
+			}
+			oracle, err := asset.NewFeeOracle(symbol, &oracleCfg, logger)
+			if err != nil {
+				abort()
+				return nil, fmt.Errorf("failed to setup fee oracle for asset %q: %v", symbol, err)
+			}
+			log.Infof("Using fee oracle %s as a fallback for %q fee rates.", oracleCfg.URL, symbol)
+			be = asset.WithFeeOracle(be, oracle)
+		}
+
 		ba := &asset.BackedAsset{
 			Asset: dex.Asset{
 				ID:         ID,
@@ -361,33 +537,67 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 	}
 
 	// Create DEXArchivist with the pg DB driver.
-	pgCfg := &pg.Config{
-		Host:         cfg.DBConf.Host,
-		Port:         strconv.Itoa(int(cfg.DBConf.Port)),
-		User:         cfg.DBConf.User,
-		Pass:         cfg.DBConf.Pass,
-		DBName:       cfg.DBConf.DBName,
-		ShowPGConfig: cfg.DBConf.ShowPGConfig,
-		QueryTimeout: 20 * time.Minute,
-		MarketCfg:    cfg.Markets,
-		//CheckedStores: true,
-		Net:    cfg.Network,
-		FeeKey: cfg.RegFeeXPub,
-	}
-	storage, err := db.Open(ctx, "pg", pgCfg)
+	storage, err := db.Open(ctx, "pg", pgConfig(cfg))
 	if err != nil {
 		abort()
 		return nil, fmt.Errorf("db.Open: %v", err)
 	}
 
+	// Take the database lease before loading any state, so that a primary and
+	// its standby never run the DEX at the same time.
+	var lease *dexLease
+	if cfg.LeaseTTL > 0 {
+		lease, err = acquireLease(storage, cfg.LeaseTTL)
+		if err != nil {
+			abort()
+			return nil, err
+		}
+		defer func() {
+			if lease != nil { // cleared on success
+				err := storage.ReleaseLease(dexLeaseName, lease.holder, lease.token)
+				if err != nil {
+					log.Errorf("Failed to release the database lease: %v", err)
+				}
+			}
+		}()
+	}
+
+	// The DEX signer, which switches to the new key at the scheduled time if
+	// the signing key is being rotated.
+	var signer auth.Signer = cfg.DEXPrivKey
+	var rotation *msgjson.KeyRotation
+	if cfg.KeyRotation != nil {
+		rotation, err = newKeyRotation(cfg.DEXPrivKey, cfg.KeyRotation)
+		if err != nil {
+			abort()
+			return nil, err
+		}
+		signer = &rotatingSigner{
+			oldKey:     cfg.DEXPrivKey,
+			newKey:     cfg.KeyRotation.NewKey,
+			activation: cfg.KeyRotation.Activation,
+		}
+		log.Infof("DEX signing key %x is replaced by %x at %v.", rotation.OldPubKey,
+			rotation.NewPubKey, cfg.KeyRotation.Activation)
+	}
+
 	authCfg := auth.Config{
 		Storage:         storage,
-		Signer:          cfg.DEXPrivKey,
+		Signer:          signer,
 		RegistrationFee: cfg.RegFeeAmount,
 		FeeConfs:        cfg.RegFeeConfirms,
 		FeeChecker:      dcrBackend.FeeCoin,
 		CancelThreshold: cfg.CancelThreshold,
 		Anarchy:         cfg.Anarchy,
+		PoWDifficulty:   cfg.PoWDifficulty,
+		PenaltyPolicy:   cfg.PenaltyPolicy,
+
+		RegistrationHook:     cfg.RegistrationHook,
+		RegistrationCacheTTL: cfg.RegistrationCacheTTL,
+		Tracer:               cfg.Tracer,
+	}
+	if cfg.Policy != nil {
+		authCfg.AppealContact = cfg.Policy.Contact
 	}
 
 	authMgr := auth.NewAuthManager(&authCfg)
@@ -405,9 +615,34 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 		return markets[name].Unbook(lo)
 	}
 
+	// Import the exported markets before the swapper and markets load their
+	// state from storage.
+	swapState := cfg.SwapState
+	for _, exp := range cfg.MarketImports {
+		var mktInfo *dex.MarketInfo
+		for _, mkt := range cfg.Markets {
+			if mkt.Name == exp.Market {
+				mktInfo = mkt
+				break
+			}
+		}
+		if mktInfo == nil {
+			abort()
+			return nil, fmt.Errorf("imported market %q is not configured", exp.Market)
+		}
+		state, err := importMarket(storage, mktInfo, exp)
+		if err != nil {
+			abort()
+			return nil, fmt.Errorf("failed to import market %s: %v", exp.Market, err)
+		}
+		if state != nil {
+			swapState = swap.MergeState(swapState, state)
+		}
+	}
+
 	// Create the swapper.
 	swapperCfg := &swap.Config{
-		State:            cfg.SwapState,
+		State:            swapState,
 		DataDir:          cfg.DataDir,
 		Assets:           lockableAssets,
 		Storage:          storage,
@@ -416,6 +651,9 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 		LockTimeTaker:    dex.LockTimeTaker(cfg.Network),
 		LockTimeMaker:    dex.LockTimeMaker(cfg.Network),
 		UnbookHook:       marketUnbookHook,
+
+		CheckpointInterval: cfg.CheckpointInterval,
+		Tracer:             cfg.Tracer,
 	}
 
 	swapper, err := swap.NewSwapper(swapperCfg)
@@ -428,7 +666,7 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 	for _, mktInf := range cfg.Markets {
 		baseCoinLocker := dexCoinLocker.AssetLocker(mktInf.Base).Book()
 		quoteCoinLocker := dexCoinLocker.AssetLocker(mktInf.Quote).Book()
-		mkt, err := market.NewMarket(mktInf, storage, swapper, authMgr, baseCoinLocker, quoteCoinLocker)
+		mkt, err := market.NewMarket(mktInf, storage, swapper, authMgr, baseCoinLocker, quoteCoinLocker, cfg.Tracer)
 		if err != nil {
 			abort()
 			return nil, fmt.Errorf("NewMarket failed: %v", err)
@@ -436,6 +674,30 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 		markets[mktInf.Name] = mkt
 	}
 
+	// Order flow recordings
+	var recordings []*os.File
+	if cfg.RecordDir != "" {
+		if err := os.MkdirAll(cfg.RecordDir, 0700); err != nil {
+			abort()
+			return nil, fmt.Errorf("failed to create recording directory: %v", err)
+		}
+		stamp := time.Now().Unix()
+		for name, mkt := range markets {
+			recPath := filepath.Join(cfg.RecordDir, fmt.Sprintf("%s_%d.rec", name, stamp))
+			f, err := os.OpenFile(recPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err != nil {
+				abort()
+				return nil, fmt.Errorf("failed to create recording file: %v", err)
+			}
+			recordings = append(recordings, f)
+			if err = mkt.Record(replay.NewRecorder(f)); err != nil {
+				abort()
+				return nil, fmt.Errorf("failed to start recording market %s: %v", name, err)
+			}
+			log.Infof("Recording order flow for market %s to %s", name, recPath)
+		}
+	}
+
 	startSubSys("Swapper", swapper) // after markets map set
 
 	// Set start epoch index for each market. Also create BookSources for the
@@ -456,6 +718,9 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 			Quote:           mkt.Quote(),
 			EpochLen:        mkt.EpochDuration(),
 			MarketBuyBuffer: mkt.MarketBuyBuffer(),
+			TradeFee:        mkt.TradeFee(),
+			MinOrderValue:   mkt.MinOrderValue(),
+			OddLotPolicy:    string(mkt.OddLotPolicy()),
 			MarketStatus: msgjson.MarketStatus{
 				StartEpoch: uint64(startEpochIdx),
 			},
@@ -471,36 +736,96 @@ func NewDEX(cfg *DexConf) (*DEX, error) {
 		Assets:      backedAssets,
 		AuthManager: authMgr,
 		Markets:     marketTunnels,
+		Tracer:      cfg.Tracer,
 	})
 
+	// The signed policy document. HTTP data API routes must be registered
+	// before the comms server is started.
+	var policyResp *policyResponse
+	if cfg.Policy != nil {
+		policyResp, err = newPolicyResponse(cfg.Policy, signer)
+		if err != nil {
+			abort()
+			return nil, err
+		}
+		comms.RegisterHTTP(msgjson.PolicyRoute, policyResp.httpPolicy)
+	}
+	overview := newMarketOverview(markets, cfgAssets)
+	comms.RegisterHTTP(overviewRoute, overview.httpOverview)
+	comms.RegisterMarketHTTP(depthRoute, httpDepth(markets))
+
+	// The API keys for the market data routes.
+	apiKeys, err := comms.NewAPIKeyring(filepath.Join(cfg.DataDir, apiKeysFile))
+	if err != nil {
+		abort()
+		return nil, err
+	}
+	commsCfg := *cfg.CommsCfg
+	commsCfg.APIKeys = apiKeys
+	commsCfg.Tracer = cfg.Tracer
+
 	// Client comms RPC server.
-	server, err := comms.NewServer(cfg.CommsCfg)
+	server, err := comms.NewServer(&commsCfg)
 	if err != nil {
 		abort()
 		return nil, fmt.Errorf("NewServer failed: %v", err)
 	}
 	startSubSys("Comms Server", server)
 
-	cfgResp, err := newConfigResponse(cfg, cfgAssets, cfgMarkets)
+	cfgResp, err := newConfigResponse(cfg, cfgAssets, cfgMarkets, signer, rotation)
 	if err != nil {
 		abort()
 		return nil, err
 	}
 
+	// The DEX's markets map is modified when a market is delisted, but the
+	// unbook hook keeps all markets in case a swap is revoked after removal.
+	dexMarkets := make(map[string]*market.Market, len(markets))
+	for name, mkt := range markets {
+		dexMarkets[name] = mkt
+	}
+
 	dexMgr := &DEX{
 		network:     cfg.Network,
-		markets:     markets,
+		markets:     dexMarkets,
+		delisting:   make(map[string]bool),
+		quit:        make(chan struct{}),
 		assets:      lockableAssets,
+		authMgr:     authMgr,
 		swapper:     swapper,
 		storage:     storage,
 		orderRouter: orderRouter,
 		bookRouter:  bookRouter,
 		stopWaiters: stopWaiters,
 		server:      server,
+		apiKeys:     apiKeys,
+		recordings:  recordings,
 		configResp:  cfgResp,
+		lease:       lease,
+		fenced:      make(chan struct{}),
 	}
+	lease = nil
 
 	comms.Route(msgjson.ConfigRoute, dexMgr.handleDEXConfig)
+	if policyResp != nil {
+		comms.Route(msgjson.PolicyRoute, policyResp.handlePolicy)
+	}
+
+	if dexMgr.lease != nil {
+		dexMgr.wg.Add(1)
+		go func() {
+			defer dexMgr.wg.Done()
+			dexMgr.keepLease()
+		}()
+	}
+
+	if cfg.KeyRotation != nil && time.Now().Before(cfg.KeyRotation.Activation) {
+		dexMgr.wg.Add(1)
+		go func() {
+			defer dexMgr.wg.Done()
+			dexMgr.activateKey(cfg.KeyRotation.Activation, policyResp)
+		}()
+	}
 
 	return dexMgr, nil
 }
@@ -512,10 +837,17 @@ func (dm *DEX) ConfigMsg() json.RawMessage {
 	return dm.configResp.configEnc
 }
 
+// market retrieves the named market, or nil if the market is unknown.
+func (dm *DEX) market(mktName string) *market.Market {
+	dm.marketsMtx.RLock()
+	defer dm.marketsMtx.RUnlock()
+	return dm.markets[mktName]
+}
+
 // TODO: for just market running status, the DEX manager should use its
 // knowledge of Market subsystem state.
 func (dm *DEX) MarketRunning(mktName string) (found, running bool) {
-	mkt := dm.markets[mktName]
+	mkt := dm.market(mktName)
 	if mkt == nil {
 		return
 	}
@@ -525,7 +857,7 @@ func (dm *DEX) MarketRunning(mktName string) (found, running bool) {
 // MarketStatus returns the market.Status for the named market. If the market is
 // unknown to the DEX, nil is returned.
 func (dm *DEX) MarketStatus(mktName string) *market.Status {
-	mkt := dm.markets[mktName]
+	mkt := dm.market(mktName)
 	if mkt == nil {
 		return nil
 	}
@@ -535,6 +867,8 @@ func (dm *DEX) MarketStatus(mktName string) *market.Status {
 // MarketStatuses returns a map of market names to market.Status for all known
 // markets.
 func (dm *DEX) MarketStatuses() map[string]*market.Status {
+	dm.marketsMtx.RLock()
+	defer dm.marketsMtx.RUnlock()
 	statuses := make(map[string]*market.Status, len(dm.markets))
 	for name, mkt := range dm.markets {
 		statuses[name] = mkt.Status()
@@ -542,6 +876,28 @@ func (dm *DEX) MarketStatuses() map[string]*market.Status {
 	return statuses
 }
 
+// EpochTimings returns the epoch processing timings of the named market. If
+// the market is unknown to the DEX, nil is returned.
+func (dm *DEX) EpochTimings(mktName string) *market.Timings {
+	mkt := dm.market(mktName)
+	if mkt == nil {
+		return nil
+	}
+	return mkt.Timings()
+}
+
+// AllEpochTimings returns a map of market names to the epoch processing
+// timings of all known markets.
+func (dm *DEX) AllEpochTimings() map[string]*market.Timings {
+	dm.marketsMtx.RLock()
+	defer dm.marketsMtx.RUnlock()
+	timings := make(map[string]*market.Timings, len(dm.markets))
+	for name, mkt := range dm.markets {
+		timings[name] = mkt.Timings()
+	}
+	return timings
+}
+
 // SuspendMarket schedules a suspension of a given market, with the option to
 // persist the orders on the book (or purge the book automatically on market
 // shutdown). The scheduled final epoch and suspend time are returned. This is a
@@ -552,7 +908,47 @@ func (dm *DEX) SuspendMarket(name string, tSusp time.Time, persistBooks bool) *m
 	// Go through the order router since OrderRouter is likely to have market
 	// status tracking built into it to facilitate resume.
 	suspEpoch := dm.orderRouter.SuspendMarket(name, tSusp, persistBooks)
+	if suspEpoch == nil {
+		return nil
+	}
+	dm.notifySuspension(name, suspEpoch, persistBooks)
+	return suspEpoch
+}
+
+// ForceEpoch ends preimage collection for a closed epoch that is blocking the
+// named market's epoch processing. See (*market.Market).ForceEpoch.
+func (dm *DEX) ForceEpoch(name string, epochIdx int64, skip, dryRun bool) (*market.ForcedEpoch, error) {
+	mkt := dm.market(strings.ToLower(name))
+	if mkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	return mkt.ForceEpoch(epochIdx, skip, dryRun)
+}
+
+// PurgeStaleOrders revokes the booked orders on the named market that were
+// received more than maxAge ago. See (*market.Market).PurgeStaleOrders.
+func (dm *DEX) PurgeStaleOrders(name string, maxAge time.Duration) ([]order.OrderID, error) {
+	mkt := dm.market(strings.ToLower(name))
+	if mkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	return mkt.PurgeStaleOrders(maxAge), nil
+}
+
+// VerifyBook checks the named market's book against the stored booked orders
+// and the status of the funding coins. See (*market.Market).VerifyBook.
+func (dm *DEX) VerifyBook(name string) (*market.BookVerification, error) {
+	mkt := dm.market(strings.ToLower(name))
+	if mkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	return mkt.VerifyBook()
+}
 
+// notifySuspension updates the config response with a market's suspend
+// schedule, and broadcasts a TradeSuspension notification to all connected
+// clients.
+func (dm *DEX) notifySuspension(name string, suspEpoch *market.SuspendEpoch, persistBooks bool) {
 	// Update config message with suspend schedule.
 	dm.configRespMtx.Lock()
 	dm.configResp.setMktSuspend(name, uint64(suspEpoch.Idx), persistBooks)
@@ -567,14 +963,266 @@ func (dm *DEX) SuspendMarket(name string, tSusp time.Time, persistBooks bool) *m
 	})
 	if err != nil {
 		log.Errorf("Failed to create suspend notification: %v", err)
+		return
+	}
+	dm.server.Broadcast(note)
+}
+
+// delistPollInterval is how often a delisted market is checked for active
+// swaps before it is removed.
+const delistPollInterval = 10 * time.Second
+
+// DelistMarket suspends a market as soon as possible and removes it once it
+// has wound down. When the market stops, the booked orders are revoked, and
+// their owners are sent a revoke_order notification. When all of the market's
+// active swaps are complete or revoked, the market is removed from the config
+// response and orders for the market are rejected. The scheduled final epoch
+// and suspend time are returned. The delisting only lasts until the server
+// restarts, when the markets are loaded from the market config again, so the
+// operator must also remove the market from the config.
+func (dm *DEX) DelistMarket(name string) (*market.SuspendEpoch, error) {
+	name = strings.ToLower(name)
+	dm.marketsMtx.Lock()
+	mkt, found := dm.markets[name]
+	if !found {
+		dm.marketsMtx.Unlock()
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	if dm.delisting[name] {
+		dm.marketsMtx.Unlock()
+		return nil, fmt.Errorf("market %q is already being delisted", name)
+	}
+	dm.delisting[name] = true
+	dm.marketsMtx.Unlock()
+
+	// Keep the book on suspend so that the orders can be revoked with
+	// notifications after the market stops. Clients are told that the book
+	// will not persist.
+	suspEpoch := dm.orderRouter.SuspendMarket(name, time.Time{}, true)
+	if suspEpoch == nil {
+		return nil, fmt.Errorf("market %q not found by the order router", name)
+	}
+	dm.notifySuspension(name, suspEpoch, false)
+	log.Infof("Delisting market %s after epoch %d.", name, suspEpoch.Idx)
+
+	dm.wg.Add(1)
+	go func() {
+		defer dm.wg.Done()
+		dm.windDown(name, mkt)
+	}()
+
+	return suspEpoch, nil
+}
+
+// windDown waits for a delisted market to stop, revokes its booked orders,
+// waits for active swaps to settle, and removes the market.
+func (dm *DEX) windDown(name string, mkt *market.Market) {
+	if ssw := dm.subsystem(fmt.Sprintf("Market[%s]", name)); ssw != nil {
+		ssw.WaitForShutdown()
+	}
+
+	select {
+	case <-dm.quit:
+		log.Warnf("DEX shutdown before market %s was delisted. The book was not purged.", name)
+		return
+	default:
+	}
+
+	// Revoke the booked orders.
+	_, buys, sells := mkt.Book()
+	mkt.PurgeBook()
+	for _, lo := range append(buys, sells...) {
+		oid := lo.ID()
+		rev := &msgjson.RevokeOrder{
+			OrderID: oid[:],
+		}
+		if err := dm.authMgr.Sign(rev); err != nil {
+			log.Errorf("Failed to sign revoke_order notification for order %v: %v", oid, err)
+			continue
+		}
+		note, err := msgjson.NewNotification(msgjson.RevokeOrderRoute, rev)
+		if err != nil {
+			log.Errorf("Failed to create revoke_order notification for order %v: %v", oid, err)
+			continue
+		}
+		if err = dm.authMgr.Send(lo.AccountID, note); err != nil {
+			log.Debugf("Failed to send revoke_order notification for order %v to user %v: %v",
+				oid, lo.AccountID, err)
+		}
+	}
+	log.Infof("Revoked %d booked orders on delisted market %s.", len(buys)+len(sells), name)
+
+	// Wait for active swaps to complete or be revoked.
+	ticker := time.NewTicker(delistPollInterval)
+	defer ticker.Stop()
+	for {
+		n := dm.swapper.MarketMatches(mkt.Base(), mkt.Quote())
+		if n == 0 {
+			break
+		}
+		log.Debugf("Waiting for %d active swaps on delisted market %s.", n, name)
+		select {
+		case <-ticker.C:
+		case <-dm.quit:
+			log.Warnf("DEX shutdown before swaps on market %s settled.", name)
+			return
+		}
+	}
+
+	dm.orderRouter.RemoveMarket(name)
+	dm.marketsMtx.Lock()
+	delete(dm.markets, name)
+	delete(dm.delisting, name)
+	dm.marketsMtx.Unlock()
+	dm.configRespMtx.Lock()
+	dm.configResp.removeMarket(name)
+	dm.configRespMtx.Unlock()
+	log.Infof("Market %s delisted. Remove it from the market config, or it will be listed again on restart.", name)
+}
+
+// drainRoutes are the client request routes that are still handled while the
+// DEX is draining, so that active swaps can be completed.
+var drainRoutes = []string{
+	msgjson.ConnectRoute,
+	msgjson.ConfigRoute,
+	msgjson.InitRoute,
+	msgjson.RedeemRoute,
+	msgjson.OrderStatusRoute,
+	msgjson.MatchStatusRoute,
+	msgjson.MatchReceiptRoute,
+}
+
+// Drain prepares the DEX for a graceful shutdown. The comms server stops
+// accepting new connections and rejects requests other than those needed to
+// negotiate active swaps, and every market is suspended as soon as possible
+// with its book persisted. The returned channel is closed when the markets
+// have stopped and all active swaps are complete or revoked. Drain may be
+// called more than once, returning the same channel.
+func (dm *DEX) Drain() <-chan struct{} {
+	dm.drainMtx.Lock()
+	defer dm.drainMtx.Unlock()
+	if dm.drained != nil {
+		return dm.drained
+	}
+	dm.drained = make(chan struct{})
+
+	dm.server.Drain(drainRoutes...)
+
+	dm.marketsMtx.RLock()
+	names := make([]string, 0, len(dm.markets))
+	for name := range dm.markets {
+		if !dm.delisting[name] {
+			names = append(names, name)
+		}
+	}
+	dm.marketsMtx.RUnlock()
+	for _, name := range names {
+		if suspEpoch := dm.SuspendMarket(name, time.Time{}, true); suspEpoch != nil {
+			log.Infof("Draining market %s after epoch %d.", name, suspEpoch.Idx)
+		}
+	}
+
+	dm.wg.Add(1)
+	go func() {
+		defer dm.wg.Done()
+		dm.waitDrained(names)
+	}()
+
+	return dm.drained
+}
+
+// waitDrained waits for the named markets to stop and for all active swaps to
+// complete or be revoked, and then closes the drained channel.
+func (dm *DEX) waitDrained(names []string) {
+	for _, name := range names {
+		ssName := fmt.Sprintf("Market[%s]", name)
+		for _, ssw := range dm.stopWaiters {
+			if ssw.name == ssName {
+				ssw.WaitForShutdown()
+				break
+			}
+		}
+	}
+
+	ticker := time.NewTicker(delistPollInterval)
+	defer ticker.Stop()
+	for {
+		n := dm.swapper.ActiveMatches()
+		if n == 0 {
+			break
+		}
+		log.Infof("Draining. Waiting for %d active swaps.", n)
+		select {
+		case <-ticker.C:
+		case <-dm.quit:
+			log.Warnf("DEX shutdown before active swaps settled.")
+			return
+		}
+	}
+	log.Infof("DEX drained. All active swaps are done.")
+	close(dm.drained)
+}
+
+// ResumeMarket restarts a suspended market at the first epoch that starts at
+// or after asSoonAs, or at the next epoch. The config response is updated with
+// the start epoch, and a TradeResumption notification is broadcast to all
+// connected clients. The scheduled start epoch and its start time are
+// returned.
+func (dm *DEX) ResumeMarket(name string, asSoonAs time.Time) (*market.ResumeEpoch, error) {
+	name = strings.ToLower(name)
+	dm.marketsMtx.RLock()
+	mkt, found := dm.markets[name]
+	delisting := dm.delisting[name]
+	dm.marketsMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	if delisting {
+		return nil, fmt.Errorf("market %q is being delisted", name)
+	}
+	ssw := dm.subsystem(fmt.Sprintf("Market[%s]", name))
+	if ssw == nil {
+		return nil, fmt.Errorf("no subsystem for market %q", name)
+	}
+
+	startIdx, startTime, ok := mkt.Resume(asSoonAs)
+	if !ok {
+		return nil, fmt.Errorf("market %q is not suspended", name)
+	}
+	// The market's final epoch is over, so Run has returned or is returning.
+	ssw.WaitForShutdown()
+	ssw.Start(context.Background())
+
+	dm.configRespMtx.Lock()
+	dm.configResp.setMktResume(name, uint64(startIdx))
+	dm.configRespMtx.Unlock()
+
+	note, err := msgjson.NewNotification(msgjson.ResumptionRoute, msgjson.TradeResumption{
+		MarketID:   name,
+		StartEpoch: uint64(startIdx),
+	})
+	if err != nil {
+		log.Errorf("Failed to create resumption notification: %v", err)
 	} else {
 		dm.server.Broadcast(note)
 	}
-	return suspEpoch
+	log.Infof("Market %s resuming at epoch %d.", name, startIdx)
+
+	return &market.ResumeEpoch{
+		Idx:   startIdx,
+		Start: startTime,
+	}, nil
 }
 
-// TODO: resume by relaunching the market subsystems (Run)
-// Resume / ResumeMarket
+// subsystem is the named subsystem, or nil if there is none.
+func (dm *DEX) subsystem(name string) *subsystem {
+	for i := range dm.stopWaiters {
+		if dm.stopWaiters[i].name == name {
+			return &dm.stopWaiters[i]
+		}
+	}
+	return nil
+}
 
 // Accounts returns data for all accounts.
 func (dm *DEX) Accounts() ([]*db.Account, error) {
@@ -586,8 +1234,151 @@ func (dm *DEX) AccountInfo(aid account.AccountID) (*db.Account, error) {
 	return dm.storage.AccountInfo(aid)
 }
 
+// SetAccountNotes stores the operator's notes and tags for an account,
+// replacing any previous notes and tags.
+func (dm *DEX) SetAccountNotes(aid account.AccountID, notes string, tags []string) error {
+	return dm.storage.SetAccountNotes(aid, notes, tags)
+}
+
+// ClientVersions returns the client software versions that an account has
+// connected with, most recently seen first.
+func (dm *DEX) ClientVersions(aid account.AccountID) ([]*db.ClientVersion, error) {
+	return dm.storage.ClientVersions(aid)
+}
+
+// ClientVersionStats aggregates the client software versions seen since the
+// given time, with the number of clients currently connected with each
+// version.
+func (dm *DEX) ClientVersionStats(since time.Time) ([]*db.ClientVersionStats, error) {
+	stats, err := dm.storage.ClientVersionStats(encode.UnixMilli(since))
+	if err != nil {
+		return nil, err
+	}
+	connected := dm.authMgr.ConnectedVersions()
+	for _, s := range stats {
+		v := auth.ClientVersion{UserAgent: s.UserAgent, APIVersion: s.APIVersion}
+		s.Connected = connected[v]
+		delete(connected, v)
+	}
+	// Versions of connected clients are recorded on connect, so any left are
+	// from connections made before the since time.
+	for v, n := range connected {
+		stats = append(stats, &db.ClientVersionStats{
+			UserAgent:  v.UserAgent,
+			APIVersion: v.APIVersion,
+			Connected:  n,
+		})
+	}
+	return stats, nil
+}
+
+// ConnectedClients is the number of authenticated clients currently
+// connected.
+func (dm *DEX) ConnectedClients() int {
+	var n int
+	for _, c := range dm.authMgr.ConnectedVersions() {
+		n += c
+	}
+	return n
+}
+
+// ActiveMatches is the number of matches still being negotiated.
+func (dm *DEX) ActiveMatches() int {
+	return dm.swapper.ActiveMatches()
+}
+
+// SwapFailures returns the number of matches revoked for inaction since
+// startup, by market and match status.
+func (dm *DEX) SwapFailures() []*swap.SwapFailures {
+	return dm.swapper.SwapFailures()
+}
+
 // Penalize bans an account by canceling the client's orders and setting their rule
-// status to rule.
-func (dm *DEX) Penalize(aid account.AccountID, rule account.Rule) error {
-	return dm.swapper.Penalize(aid, rule)
+// status to rule. The details are sent to the client with the penalty.
+func (dm *DEX) Penalize(aid account.AccountID, rule account.Rule, details string) error {
+	return dm.swapper.Penalize(aid, rule, details)
+}
+
+// Forgive reopens an account that was closed for a rule violation. The reason
+// is sent to the client.
+func (dm *DEX) Forgive(aid account.AccountID, reason string) error {
+	return dm.authMgr.Forgive(aid, reason)
+}
+
+// Appeals returns all penalty appeals.
+func (dm *DEX) Appeals() ([]*db.Appeal, error) {
+	return dm.authMgr.Appeals()
+}
+
+// DecideAppeal accepts or rejects an account's pending penalty appeal. An
+// accepted appeal reopens the account.
+func (dm *DEX) DecideAppeal(aid account.AccountID, accept bool, reason string) error {
+	return dm.authMgr.DecideAppeal(aid, accept, reason)
+}
+
+// PoWDifficulty is the proof-of-work difficulty required of 'register' and
+// 'connect' requests. Zero means no proof of work is required.
+func (dm *DEX) PoWDifficulty() uint8 {
+	return dm.authMgr.PoWDifficulty()
+}
+
+// SetPoWDifficulty sets the proof-of-work difficulty required of 'register'
+// and 'connect' requests to resist a flood of them. Zero disables the
+// requirement.
+func (dm *DEX) SetPoWDifficulty(difficulty uint8) error {
+	return dm.authMgr.SetPoWDifficulty(difficulty)
+}
+
+// SetRegistrationOverride approves or denies the registration of an account
+// regardless of the registration hook. Overrides are not persisted.
+func (dm *DEX) SetRegistrationOverride(aid account.AccountID, approve bool) {
+	dm.authMgr.SetRegistrationOverride(aid, approve)
+}
+
+// ClearRegistrationOverride removes the registration override and any cached
+// registration hook decision for an account.
+func (dm *DEX) ClearRegistrationOverride(aid account.AccountID) {
+	dm.authMgr.ClearRegistrationOverride(aid)
+}
+
+// RegistrationApproval returns the registration override and cached
+// registration hook decision for an account.
+func (dm *DEX) RegistrationApproval(aid account.AccountID) *auth.RegistrationApproval {
+	return dm.authMgr.RegistrationApproval(aid)
+}
+
+// PreimageMisses returns the recorded preimage misses for an account, oldest
+// first.
+func (dm *DEX) PreimageMisses(aid account.AccountID) ([]*db.PreimageMiss, error) {
+	return dm.storage.PreimageMisses(aid)
+}
+
+// PenaltyPolicy returns the mapping of broken rules to their penalties.
+func (dm *DEX) PenaltyPolicy() *account.PenaltyPolicy {
+	return dm.authMgr.PenaltyPolicy()
+}
+
+// SetPenaltyPolicy changes the mapping of broken rules to their penalties,
+// which applies to subsequent violations.
+func (dm *DEX) SetPenaltyPolicy(policy *account.PenaltyPolicy) error {
+	return dm.authMgr.SetPenaltyPolicy(policy)
+}
+
+// MessageRejects returns the counts of client requests rejected by payload
+// validation, by route and reason.
+func (dm *DEX) MessageRejects() []*comms.RejectCount {
+	return comms.Rejects()
+}
+
+// FeeInvoices totals the trade fees accrued from start (inclusive) to end
+// (exclusive) for each account and asset.
+func (dm *DEX) FeeInvoices(start, end time.Time) ([]*db.FeeInvoice, error) {
+	return dm.storage.FeeInvoices(encode.UnixMilli(start), encode.UnixMilli(end))
+}
+
+// CompletedMatches retrieves the trade matches of all markets that were made
+// from start (inclusive) to end (exclusive) and have completed swaps, oldest
+// first.
+func (dm *DEX) CompletedMatches(start, end time.Time) ([]*db.CompletedMatch, error) {
+	return dm.storage.CompletedMatches(encode.UnixMilli(start), encode.UnixMilli(end))
 }
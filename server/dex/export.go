@@ -0,0 +1,165 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/market"
+	"decred.org/dcrdex/server/swap"
+)
+
+// ExportMarket creates an image of the market's booked and epoch orders and
+// its settling matches, which may be imported on startup by another server
+// with the market's configuration. See DexConf.MarketImports.
+func (dm *DEX) ExportMarket(name string) (*market.Export, error) {
+	mkt := dm.market(name)
+	if mkt == nil {
+		return nil, fmt.Errorf("unknown market %q", name)
+	}
+	exp := mkt.Export()
+	swaps, n, err := dm.swapper.MarketState(exp.Base, exp.Quote)
+	if err != nil {
+		return nil, err
+	}
+	exp.Swaps, exp.Matches = swaps, n
+	log.Infof("Exported market %s with %d booked orders, %d epoch orders, and %d settling matches.",
+		name, len(exp.BookOrders), len(exp.EpochOrders), n)
+	return exp, nil
+}
+
+// LoadMarketExport loads a market export from a JSON file.
+func LoadMarketExport(path string) (*market.Export, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(market.Export)
+	if err = json.Unmarshal(b, exp); err != nil {
+		return nil, fmt.Errorf("error parsing market export %s: %v", path, err)
+	}
+	if exp.Version != market.ExportVersion {
+		return nil, fmt.Errorf("market export %s has version %d, expected %d",
+			path, exp.Version, market.ExportVersion)
+	}
+	return exp, nil
+}
+
+// importMarket stores the orders and matches of the market export, returning
+// the swap state of the settling matches to be restored by the Swapper. Orders
+// and matches that are already stored are not replaced. The orders that were
+// in the exported epoch cannot be matched, so they are stored and revoked
+// without counting against their users.
+func importMarket(storage db.DEXArchivist, mktInfo *dex.MarketInfo, exp *market.Export) (*swap.State, error) {
+	if exp.Base != mktInfo.Base || exp.Quote != mktInfo.Quote {
+		return nil, fmt.Errorf("market export is for assets %d-%d, expected %d-%d",
+			exp.Base, exp.Quote, mktInfo.Base, mktInfo.Quote)
+	}
+	if exp.LotSize%mktInfo.LotSize != 0 {
+		return nil, fmt.Errorf("market export lot size %d is not a multiple of lot size %d",
+			exp.LotSize, mktInfo.LotSize)
+	}
+	booked, epoch, err := exp.Orders()
+	if err != nil {
+		return nil, err
+	}
+	var state *swap.State
+	if len(exp.Swaps) > 0 {
+		state, err = swap.DecodeState(exp.Swaps)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding swap state: %v", err)
+		}
+	}
+
+	epochDur := int64(mktInfo.EpochDuration)
+	// storeNew stores the order in the epoch status, with no fills, if it is
+	// not already stored. The order's final status is then set by the caller.
+	storeNew := func(ord order.Order) (bool, error) {
+		_, _, err := storage.Order(ord.ID(), ord.Base(), ord.Quote())
+		if err == nil {
+			return false, nil
+		}
+		if !db.IsErrOrderUnknown(err) {
+			return false, err
+		}
+		// Store an unfilled copy of the order.
+		epochOrd, err := order.DecodeOrder(order.EncodeOrder(ord))
+		if err != nil {
+			return false, err
+		}
+		if trade := epochOrd.Trade(); trade != nil {
+			trade.FillAmt = 0
+		}
+		return true, storage.NewEpochOrder(epochOrd, ord.Time()/epochDur, epochDur)
+	}
+
+	var nBooked, nRevoked, nMatches int
+	for _, lo := range booked {
+		stored, err := storeNew(lo)
+		if err != nil {
+			return nil, fmt.Errorf("error storing booked order %v: %w", lo.ID(), err)
+		}
+		if !stored {
+			continue
+		}
+		if err = storage.BookOrder(lo); err != nil {
+			return nil, fmt.Errorf("error booking order %v: %w", lo.ID(), err)
+		}
+		nBooked++
+	}
+
+	for _, ord := range epoch {
+		stored, err := storeNew(ord)
+		if err != nil {
+			return nil, fmt.Errorf("error storing epoch order %v: %w", ord.ID(), err)
+		}
+		if !stored {
+			continue
+		}
+		if co, ok := ord.(*order.CancelOrder); ok {
+			err = storage.FailCancelOrder(co)
+		} else {
+			_, _, err = storage.RevokeOrderUncounted(ord)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error revoking epoch order %v: %w", ord.ID(), err)
+		}
+		nRevoked++
+	}
+
+	if state != nil {
+		for mid, mtd := range state.MatchTrackers {
+			match := mtd.Match
+			if match.Maker.BaseAsset != mktInfo.Base || match.Maker.QuoteAsset != mktInfo.Quote {
+				return nil, fmt.Errorf("match %v is not a match of market %s", mid, mktInfo.Name)
+			}
+			// The matched orders that are not booked have been executed.
+			for _, ord := range []order.Order{match.Maker, match.Taker} {
+				stored, err := storeNew(ord)
+				if err != nil {
+					return nil, fmt.Errorf("error storing matched order %v: %w", ord.ID(), err)
+				}
+				if stored {
+					if err = storage.ExecuteOrder(ord); err != nil {
+						return nil, fmt.Errorf("error storing matched order %v: %w", ord.ID(), err)
+					}
+				}
+			}
+			if err := storage.InsertMatch(match); err != nil {
+				return nil, fmt.Errorf("error storing match %v: %w", mid, err)
+			}
+			nMatches++
+		}
+	}
+
+	log.Infof("Imported market %s from %v: %d booked orders, %d revoked epoch orders, and %d settling matches.",
+		mktInfo.Name, encode.UnixTimeMilli(exp.Stamp), nBooked, nRevoked, nMatches)
+	return state, nil
+}
@@ -0,0 +1,98 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/server/auth"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+)
+
+// KeyRotation schedules the replacement of the DEX signing key. The rotation
+// is announced to clients in the config response until the operator removes
+// it, which should not be done until clients have had a chance to connect and
+// learn the new key.
+type KeyRotation struct {
+	NewKey     *secp256k1.PrivateKey
+	Activation time.Time
+}
+
+// rotatingSigner is an auth.Signer that signs with the old key until the
+// activation time, and with the new key after.
+type rotatingSigner struct {
+	oldKey, newKey *secp256k1.PrivateKey
+	activation     time.Time
+}
+
+var _ auth.Signer = (*rotatingSigner)(nil)
+
+// key is the signing key for the current time.
+func (s *rotatingSigner) key() *secp256k1.PrivateKey {
+	if time.Now().Before(s.activation) {
+		return s.oldKey
+	}
+	return s.newKey
+}
+
+// Sign signs the hash with the current key.
+func (s *rotatingSigner) Sign(hash []byte) (*secp256k1.Signature, error) {
+	return s.key().Sign(hash)
+}
+
+// PubKey is the public key of the current key.
+func (s *rotatingSigner) PubKey() *secp256k1.PublicKey {
+	return s.key().PubKey()
+}
+
+// newKeyRotation creates the key rotation announcement, signed by both the old
+// and new keys.
+func newKeyRotation(oldKey *secp256k1.PrivateKey, kr *KeyRotation) (*msgjson.KeyRotation, error) {
+	oldPub := oldKey.PubKey()
+	newPub := kr.NewKey.PubKey()
+	if oldPub.IsEqual(newPub) {
+		return nil, fmt.Errorf("the new signing key is the current key")
+	}
+	rotation := &msgjson.KeyRotation{
+		OldPubKey:  oldPub.SerializeCompressed(),
+		NewPubKey:  newPub.SerializeCompressed(),
+		Activation: encode.UnixMilliU(kr.Activation),
+	}
+	sigMsg := rotation.Serialize()
+	oldSig, err := oldKey.Sign(sigMsg)
+	if err != nil {
+		return nil, fmt.Errorf("error signing key rotation with the old key: %v", err)
+	}
+	newSig, err := kr.NewKey.Sign(sigMsg)
+	if err != nil {
+		return nil, fmt.Errorf("error signing key rotation with the new key: %v", err)
+	}
+	rotation.OldSig = oldSig.Serialize()
+	rotation.NewSig = newSig.Serialize()
+	return rotation, nil
+}
+
+// activateKey re-signs the config response and policy document when the new
+// signing key is activated.
+func (dm *DEX) activateKey(activation time.Time, policyResp *policyResponse) {
+	timer := time.NewTimer(time.Until(activation))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-dm.quit:
+		return
+	}
+	dm.configRespMtx.Lock()
+	dm.configResp.remarshal()
+	dm.configRespMtx.Unlock()
+	if policyResp != nil {
+		if err := policyResp.sign(); err != nil {
+			log.Errorf("Failed to re-sign the policy document with the new key: %v", err)
+		}
+	}
+	log.Infof("Activated new DEX signing key %x.", dm.configResp.signer.PubKey().SerializeCompressed())
+}
@@ -0,0 +1,102 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/server/db"
+)
+
+// dexLeaseName is the name of the database lease held by the server that is
+// running the DEX. A primary server and its standby contend for this lease,
+// so that a standby cannot be promoted while the primary is running.
+const dexLeaseName = "dex"
+
+// dexLease is a database lease held by a running DEX.
+type dexLease struct {
+	holder string
+	token  uint64
+	ttl    time.Duration
+}
+
+// newLeaseHolder creates a holder ID that is unique to this process.
+func newLeaseHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%x", host, os.Getpid(), encode.RandomBytes(4))
+}
+
+// acquireLease takes the DEX lease, failing if another server holds it.
+func acquireLease(storage db.LeaseArchiver, ttl time.Duration) (*dexLease, error) {
+	holder := newLeaseHolder()
+	token, err := storage.AcquireLease(dexLeaseName, holder, ttl)
+	if err != nil {
+		if db.IsErrLeaseHeld(err) {
+			return nil, fmt.Errorf("another server is running the DEX on this database, "+
+				"or stopped less than %v ago: %w", ttl, err)
+		}
+		return nil, fmt.Errorf("failed to acquire the database lease: %w", err)
+	}
+	log.Infof("Acquired database lease %q with fencing token %d.", dexLeaseName, token)
+	return &dexLease{
+		holder: holder,
+		token:  token,
+		ttl:    ttl,
+	}, nil
+}
+
+// keepLease renews the DEX lease until Stop. If the lease is taken by another
+// server, or has not been renewed for half of its TTL, the DEX is fenced: the
+// channel returned by Fenced is closed, and the DEX must be stopped. Renewals
+// are attempted every quarter of the TTL, so the DEX is fenced at least a
+// quarter of the TTL before the lease can expire and a standby take over.
+func (dm *DEX) keepLease() {
+	ticker := time.NewTicker(dm.lease.ttl / 4)
+	defer ticker.Stop()
+	lastRenewal := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-dm.quit:
+			return
+		}
+		// The lease expires ttl after the database received the renewal, so
+		// the time before the request is the conservative renewal time.
+		attempt := time.Now()
+		err := dm.storage.RenewLease(dexLeaseName, dm.lease.holder, dm.lease.token, dm.lease.ttl)
+		if err == nil {
+			lastRenewal = attempt
+			continue
+		}
+		if db.IsErrLeaseLost(err) {
+			log.Criticalf("Another server has taken over the DEX: %v", err)
+		} else if time.Since(lastRenewal) < dm.lease.ttl/2 {
+			log.Errorf("Failed to renew the database lease: %v", err)
+			continue
+		} else {
+			log.Criticalf("Unable to renew the database lease before it expires: %v", err)
+		}
+		close(dm.fenced)
+		return
+	}
+}
+
+// Fenced returns a channel that is closed if the DEX loses its database lease
+// to another server. The DEX must be stopped immediately. The channel is never
+// closed if the DEX does not hold a lease. See DexConf.LeaseTTL.
+func (dm *DEX) Fenced() <-chan struct{} {
+	return dm.fenced
+}
+
+// releaseLease gives up the DEX lease so that a standby may be promoted
+// without waiting for it to expire.
+func (dm *DEX) releaseLease() {
+	err := dm.storage.ReleaseLease(dexLeaseName, dm.lease.holder, dm.lease.token)
+	if err != nil {
+		log.Errorf("Failed to release the database lease: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/server/db"
+)
+
+// tLeaseDB is a shared database holding a single lease. Each server has its
+// own tLeaseConn to it, which can be cut off to simulate a network partition.
+type tLeaseDB struct {
+	mtx    sync.Mutex
+	holder string
+	token  uint64
+	expiry time.Time
+}
+
+type tLeaseConn struct {
+	db.DEXArchivist
+	leases *tLeaseDB
+
+	mtx  sync.Mutex
+	down bool
+}
+
+var errConnDown = errors.New("connection down")
+
+func (c *tLeaseConn) setDown(down bool) {
+	c.mtx.Lock()
+	c.down = down
+	c.mtx.Unlock()
+}
+
+func (c *tLeaseConn) isDown() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.down
+}
+
+func (c *tLeaseConn) AcquireLease(name, holder string, ttl time.Duration) (uint64, error) {
+	if c.isDown() {
+		return 0, errConnDown
+	}
+	l := c.leases
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.holder != "" && l.holder != holder && time.Now().Before(l.expiry) {
+		return 0, db.ArchiveError{Code: db.ErrLeaseHeld}
+	}
+	l.holder = holder
+	l.token++
+	l.expiry = time.Now().Add(ttl)
+	return l.token, nil
+}
+
+func (c *tLeaseConn) RenewLease(name, holder string, token uint64, ttl time.Duration) error {
+	if c.isDown() {
+		return errConnDown
+	}
+	l := c.leases
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.holder != holder || l.token != token {
+		return db.ArchiveError{Code: db.ErrLeaseLost}
+	}
+	l.expiry = time.Now().Add(ttl)
+	return nil
+}
+
+func (c *tLeaseConn) ReleaseLease(name, holder string, token uint64) error {
+	if c.isDown() {
+		return errConnDown
+	}
+	l := c.leases
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.holder == holder && l.token == token {
+		l.holder = ""
+	}
+	return nil
+}
+
+// tLeaseDEX creates a DEX that holds the lease, with keepLease running.
+func tLeaseDEX(t *testing.T, conn *tLeaseConn, ttl time.Duration) (*DEX, func()) {
+	t.Helper()
+	lease, err := acquireLease(conn, ttl)
+	if err != nil {
+		t.Fatalf("acquireLease error: %v", err)
+	}
+	dm := &DEX{
+		storage: conn,
+		quit:    make(chan struct{}),
+		lease:   lease,
+		fenced:  make(chan struct{}),
+	}
+	dm.wg.Add(1)
+	go func() {
+		defer dm.wg.Done()
+		dm.keepLease()
+	}()
+	return dm, func() {
+		close(dm.quit)
+		dm.wg.Wait()
+	}
+}
+
+func isFenced(dm *DEX, wait time.Duration) bool {
+	select {
+	case <-dm.Fenced():
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	leases := new(tLeaseDB)
+	primaryConn := &tLeaseConn{leases: leases}
+	standbyConn := &tLeaseConn{leases: leases}
+
+	primary, stopPrimary := tLeaseDEX(t, primaryConn, ttl)
+	defer stopPrimary()
+
+	// The lease is renewed, so the standby can't take over.
+	if isFenced(primary, 3*ttl) {
+		t.Fatalf("primary fenced while renewing")
+	}
+	if _, err := acquireLease(standbyConn, ttl); err == nil {
+		t.Fatalf("standby acquired a held lease")
+	}
+
+	// The primary loses its connection, and is fenced before the lease
+	// expires.
+	primaryConn.setDown(true)
+	start := time.Now()
+	if !isFenced(primary, 2*ttl) {
+		t.Fatalf("primary not fenced after failing to renew")
+	}
+	if time.Since(start) >= ttl {
+		t.Fatalf("primary fenced after the lease expired")
+	}
+
+	// The standby takes over once the lease expires.
+	if _, err := acquireLease(standbyConn, ttl); err == nil {
+		t.Fatalf("standby acquired the lease before it expired")
+	}
+	time.Sleep(ttl)
+	standby, stopStandby := tLeaseDEX(t, standbyConn, ttl)
+	defer stopStandby()
+
+	// The old primary can no longer renew with its token.
+	primaryConn.setDown(false)
+	err := primaryConn.RenewLease(dexLeaseName, primary.lease.holder, primary.lease.token, ttl)
+	if !db.IsErrLeaseLost(err) {
+		t.Fatalf("expected ErrLeaseLost for the old primary, got %v", err)
+	}
+	if isFenced(standby, 3*ttl) {
+		t.Fatalf("new primary fenced while renewing")
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	leases := new(tLeaseDB)
+	conn := &tLeaseConn{leases: leases}
+	dm, stop := tLeaseDEX(t, conn, ttl)
+	defer stop()
+
+	// Another server takes the lease, e.g. after a clock jump. The DEX is
+	// fenced on its next renewal.
+	leases.mtx.Lock()
+	leases.holder, leases.token = "other", leases.token+1
+	leases.mtx.Unlock()
+	if !isFenced(dm, ttl) {
+		t.Fatalf("not fenced after the lease was lost")
+	}
+}
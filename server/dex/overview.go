@@ -0,0 +1,175 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/market"
+)
+
+const (
+	// overviewRoute is the HTTP data API route of the market overview.
+	overviewRoute = "markets/overview"
+	// overviewTTL is how long the market overview is cached.
+	overviewTTL = 30 * time.Second
+	// depthRange is the fraction of the mid-gap rate on either side of it that
+	// is included in the depth of the market overview.
+	depthRange = 0.02
+	// atomsPerCoin converts atoms to the conventional units of an asset.
+	atomsPerCoin = 1e8
+)
+
+// MarketTicker is the market overview entry for a market, in the ticker schema
+// used by market data aggregators such as CoinGecko. Quantities and rates are
+// in conventional units, e.g. DCR rather than atoms, and rates are in units of
+// the quote (target) asset per unit of the base asset. Volumes, highs, and lows
+// cover the last 24 hours.
+type MarketTicker struct {
+	TickerID       string  `json:"ticker_id"`
+	BaseCurrency   string  `json:"base_currency"`
+	TargetCurrency string  `json:"target_currency"`
+	LastPrice      float64 `json:"last_price"`
+	BaseVolume     float64 `json:"base_volume"`
+	TargetVolume   float64 `json:"target_volume"`
+	Bid            float64 `json:"bid"`
+	Ask            float64 `json:"ask"`
+	High           float64 `json:"high"`
+	Low            float64 `json:"low"`
+	// PlusDepth and MinusDepth are the value of the sell orders within 2% above
+	// the mid-gap rate and the buy orders within 2% below it, in units of the
+	// quote asset.
+	PlusDepth  float64 `json:"plus_2_percent_depth"`
+	MinusDepth float64 `json:"minus_2_percent_depth"`
+	// MakerFee and TakerFee are the operator's trade fee as a fraction of the
+	// quantity received. The swap transactions are funded at up to the
+	// assets' maximum fee rates, in atoms/byte.
+	MakerFee        float64 `json:"maker_fee"`
+	TakerFee        float64 `json:"taker_fee"`
+	BaseMaxFeeRate  uint64  `json:"base_max_fee_rate"`
+	QuoteMaxFeeRate uint64  `json:"quote_max_fee_rate"`
+	LotSize         float64 `json:"lot_size"`
+	RateStep        float64 `json:"rate_step"`
+	EpochLen        uint64  `json:"epoch_len"`
+}
+
+// marketOverview builds and caches the market overview document.
+type marketOverview struct {
+	markets map[string]*market.Market
+	assets  map[uint32]*msgjson.Asset
+
+	mtx     sync.Mutex
+	stamp   time.Time
+	tickers []*MarketTicker
+}
+
+// newMarketOverview is the constructor for a marketOverview. The markets map
+// is not modified by the marketOverview, and must not be modified by the
+// caller.
+func newMarketOverview(markets map[string]*market.Market, cfgAssets []*msgjson.Asset) *marketOverview {
+	assets := make(map[uint32]*msgjson.Asset, len(cfgAssets))
+	for _, a := range cfgAssets {
+		assets[a.ID] = a
+	}
+	return &marketOverview{
+		markets: markets,
+		assets:  assets,
+	}
+}
+
+// httpOverview is the handler for the HTTP data API market overview request.
+// The document is rebuilt at most once every overviewTTL.
+func (mo *marketOverview) httpOverview() (interface{}, error) {
+	mo.mtx.Lock()
+	defer mo.mtx.Unlock()
+	if time.Since(mo.stamp) < overviewTTL {
+		return mo.tickers, nil
+	}
+	tickers := make([]*MarketTicker, 0, len(mo.markets))
+	for name, mkt := range mo.markets {
+		// Suspended and delisted markets are not listed.
+		if !mkt.Running() {
+			continue
+		}
+		base, quote := mo.assets[mkt.Base()], mo.assets[mkt.Quote()]
+		if base == nil || quote == nil {
+			return nil, fmt.Errorf("unknown asset for market %s", name)
+		}
+		_, buys, sells := mkt.Book()
+		ticker := newMarketTicker(base, quote, mkt.EpochDuration(), mkt.Stats(), buys, sells)
+		ticker.MakerFee = float64(mkt.TradeFee()) / 1e4
+		ticker.TakerFee = ticker.MakerFee
+		tickers = append(tickers, ticker)
+	}
+	mo.tickers, mo.stamp = tickers, time.Now()
+	return tickers, nil
+}
+
+// newMarketTicker creates the overview entry for a market from its trade stats
+// and book. The book orders must be sorted best first, as from
+// (*market.Market).Book.
+func newMarketTicker(base, quote *msgjson.Asset, epochLen uint64, stats *market.Stats, buys, sells []*order.LimitOrder) *MarketTicker {
+	baseSymbol, quoteSymbol := strings.ToUpper(base.Symbol), strings.ToUpper(quote.Symbol)
+	ticker := &MarketTicker{
+		TickerID:        baseSymbol + "_" + quoteSymbol,
+		BaseCurrency:    baseSymbol,
+		TargetCurrency:  quoteSymbol,
+		LastPrice:       toCoin(stats.LastRate),
+		BaseVolume:      toCoin(stats.BaseVol24),
+		TargetVolume:    toCoin(stats.QuoteVol24),
+		High:            toCoin(stats.High24),
+		Low:             toCoin(stats.Low24),
+		BaseMaxFeeRate:  base.MaxFeeRate,
+		QuoteMaxFeeRate: quote.MaxFeeRate,
+		LotSize:         toCoin(base.LotSize),
+		RateStep:        toCoin(quote.RateStep),
+		EpochLen:        epochLen,
+	}
+
+	var midGap uint64
+	switch {
+	case len(buys) > 0 && len(sells) > 0:
+		ticker.Bid, ticker.Ask = toCoin(buys[0].Rate), toCoin(sells[0].Rate)
+		midGap = (buys[0].Rate + sells[0].Rate) / 2
+	case len(buys) > 0:
+		ticker.Bid = toCoin(buys[0].Rate)
+		midGap = buys[0].Rate
+	case len(sells) > 0:
+		ticker.Ask = toCoin(sells[0].Rate)
+		midGap = sells[0].Rate
+	default:
+		return ticker
+	}
+
+	// Only the displayed part of an iceberg order counts toward the depth, as
+	// in the order book.
+	var plusDepth, minusDepth uint64
+	minRate, maxRate := uint64(float64(midGap)*(1-depthRange)), uint64(float64(midGap)*(1+depthRange))
+	for _, lo := range buys {
+		if lo.Rate < minRate {
+			break
+		}
+		minusDepth += calc.BaseToQuote(lo.Rate, lo.Displayed())
+	}
+	for _, lo := range sells {
+		if lo.Rate > maxRate {
+			break
+		}
+		plusDepth += calc.BaseToQuote(lo.Rate, lo.Displayed())
+	}
+	ticker.PlusDepth, ticker.MinusDepth = toCoin(plusDepth), toCoin(minusDepth)
+	return ticker
+}
+
+// toCoin converts an amount in atoms, or a rate in atoms per coin, to
+// conventional units.
+func toCoin(v uint64) float64 {
+	return float64(v) / atomsPerCoin
+}
@@ -0,0 +1,65 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"testing"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/market"
+)
+
+func TestNewMarketTicker(t *testing.T) {
+	base := &msgjson.Asset{Symbol: "dcr", LotSize: 1e8, MaxFeeRate: 10}
+	quote := &msgjson.Asset{Symbol: "btc", RateStep: 100, MaxFeeRate: 100}
+	stats := &market.Stats{LastRate: 1e6, BaseVol24: 5e8, QuoteVol24: 5e6}
+	newOrder := func(rate, qty, filled, visible uint64) *order.LimitOrder {
+		return &order.LimitOrder{
+			T: order.Trade{
+				Quantity: qty,
+				FillAmt:  filled,
+			},
+			Rate:    rate,
+			Visible: visible,
+		}
+	}
+
+	// An empty book has no bid, ask, or depth.
+	ticker := newMarketTicker(base, quote, 60000, stats, nil, nil)
+	if ticker.TickerID != "DCR_BTC" || ticker.LastPrice != 0.01 || ticker.BaseVolume != 5 {
+		t.Fatalf("wrong ticker %+v", ticker)
+	}
+	if ticker.Bid != 0 || ticker.Ask != 0 || ticker.PlusDepth != 0 || ticker.MinusDepth != 0 {
+		t.Fatalf("non-zero book values for an empty book: %+v", ticker)
+	}
+
+	buys := []*order.LimitOrder{
+		newOrder(1e6, 2e8, 0, 0),
+		// Partially filled.
+		newOrder(99e4, 3e8, 1e8, 0),
+		// More than 2% below the mid-gap rate.
+		newOrder(9e5, 5e8, 0, 0),
+	}
+	sells := []*order.LimitOrder{
+		// An iceberg order only counts its displayed quantity.
+		newOrder(102e4, 10e8, 0, 1e8),
+		// An iceberg order with less remaining than the visible quantity.
+		newOrder(103e4, 10e8, 9e8, 2e8),
+		// More than 2% above the mid-gap rate.
+		newOrder(11e5, 5e8, 0, 0),
+	}
+	ticker = newMarketTicker(base, quote, 60000, stats, buys, sells)
+	if ticker.Bid != 0.01 || ticker.Ask != 0.0102 {
+		t.Fatalf("wrong bid/ask %f/%f", ticker.Bid, ticker.Ask)
+	}
+	// Buys: 2 * 0.01 + 2 * 0.0099
+	if ticker.MinusDepth != 0.0398 {
+		t.Fatalf("wrong minus depth %f", ticker.MinusDepth)
+	}
+	// Sells: 1 * 0.0102 + 1 * 0.0103
+	if ticker.PlusDepth != 0.0205 {
+		t.Fatalf("wrong plus depth %f", ticker.PlusDepth)
+	}
+}
@@ -0,0 +1,149 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/auth"
+	"decred.org/dcrdex/server/comms"
+)
+
+// PolicyConf is the operator's policy document, published to clients via the
+// PolicyRoute and the HTTP data API. The fields are free-form text.
+type PolicyConf struct {
+	Fees      string `json:"fees"`
+	Penalties string `json:"penalties"`
+	Contact   string `json:"contact"`
+	Terms     string `json:"terms"`
+	// Stamp is the publication time of the document.
+	Stamp time.Time `json:"-"`
+}
+
+// LoadPolicyFile reads a JSON-encoded PolicyConf from file. The file's
+// modification time is used as the publication time.
+func LoadPolicyFile(path string) (*PolicyConf, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(PolicyConf)
+	if err = json.Unmarshal(b, policy); err != nil {
+		return nil, fmt.Errorf("error parsing policy file %s: %v", path, err)
+	}
+	if policy.Fees == "" && policy.Penalties == "" && policy.Contact == "" && policy.Terms == "" {
+		return nil, fmt.Errorf("policy file %s has no content", path)
+	}
+	policy.Stamp = fi.ModTime()
+	return policy, nil
+}
+
+// LoadPenaltyPolicyFile reads a JSON-encoded account.PenaltyPolicy from file.
+func LoadPenaltyPolicyFile(path string) (*account.PenaltyPolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(account.PenaltyPolicy)
+	if err = json.Unmarshal(b, policy); err != nil {
+		return nil, fmt.Errorf("error parsing penalty policy file %s: %v", path, err)
+	}
+	if err = policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid penalty policy file %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// policyResponse stores the signed policy document and its pre-encoded
+// response payload. The document is re-signed if the DEX signing key is
+// rotated.
+type policyResponse struct {
+	conf      *PolicyConf
+	signer    auth.Signer
+	mtx       sync.RWMutex
+	policy    *msgjson.Policy
+	policyEnc json.RawMessage
+}
+
+// newPolicyResponse signs the policy document with the provided signer, which
+// should be the DEX's signer.
+func newPolicyResponse(conf *PolicyConf, signer auth.Signer) (*policyResponse, error) {
+	pr := &policyResponse{
+		conf:   conf,
+		signer: signer,
+	}
+	if err := pr.sign(); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// sign signs the policy document with the signer's current key, and encodes
+// the response payload.
+func (pr *policyResponse) sign() error {
+	policy := &msgjson.Policy{
+		DEXPubKey: pr.signer.PubKey().SerializeCompressed(),
+		Stamp:     encode.UnixMilliU(pr.conf.Stamp),
+		Fees:      pr.conf.Fees,
+		Penalties: pr.conf.Penalties,
+		Contact:   pr.conf.Contact,
+		Terms:     pr.conf.Terms,
+	}
+	sig, err := pr.signer.Sign(policy.Serialize())
+	if err != nil {
+		return fmt.Errorf("error signing policy document: %v", err)
+	}
+	policy.SetSig(sig.Serialize())
+
+	encResult, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	encPayload, err := json.Marshal(&msgjson.ResponsePayload{
+		Result: encResult,
+	})
+	if err != nil {
+		return err
+	}
+
+	pr.mtx.Lock()
+	pr.policy = policy
+	pr.policyEnc = encPayload
+	pr.mtx.Unlock()
+	return nil
+}
+
+// handlePolicy is the handler for the PolicyRoute request.
+func (pr *policyResponse) handlePolicy(conn comms.Link, msg *msgjson.Message) *msgjson.Error {
+	pr.mtx.RLock()
+	resp := &msgjson.Message{
+		Type:    msgjson.Response,
+		ID:      msg.ID,
+		Payload: pr.policyEnc,
+	}
+	pr.mtx.RUnlock()
+	if err := conn.Send(resp); err != nil {
+		log.Debugf("error sending policy response: %v", err)
+	}
+	return nil
+}
+
+// httpPolicy is the handler for the HTTP data API policy request.
+func (pr *policyResponse) httpPolicy() (interface{}, error) {
+	pr.mtx.RLock()
+	defer pr.mtx.RUnlock()
+	return pr.policy, nil
+}
@@ -0,0 +1,218 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/server/asset"
+	"decred.org/dcrdex/server/db/driver/pg"
+)
+
+// PreflightStatus is the outcome of a preflight check.
+type PreflightStatus string
+
+// The possible outcomes of a preflight check. A DEX should not be started if
+// any check has PreflightFail status.
+const (
+	PreflightOK   PreflightStatus = "ok"
+	PreflightWarn PreflightStatus = "warn"
+	PreflightFail PreflightStatus = "fail"
+)
+
+// PreflightCheck is the result of a single preflight check.
+type PreflightCheck struct {
+	Name   string          `json:"name"`
+	Status PreflightStatus `json:"status"`
+	Detail string          `json:"detail"`
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Checks []*PreflightCheck `json:"checks"`
+}
+
+func (r *PreflightReport) add(name string, status PreflightStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, &PreflightCheck{
+		Name:   name,
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// Failed is true if any check failed.
+func (r *PreflightReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == PreflightFail {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report with one check per line.
+func (r *PreflightReport) String() string {
+	var sb strings.Builder
+	for _, c := range r.Checks {
+		fmt.Fprintf(&sb, "[%-4s] %-16s %s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail)
+	}
+	return sb.String()
+}
+
+// Preflight checks the DEX configuration without starting the DEX or binding
+// any listeners. The markets and assets are checked for consistency, the
+// database schema is inspected without modification, and each asset backend
+// is set up to check its node's capabilities (e.g. a transaction index). The
+// asset backends are shut down before returning.
+func Preflight(cfg *DexConf) *PreflightReport {
+	report := new(PreflightReport)
+
+	if cfg.Anarchy && cfg.Network == dex.Mainnet {
+		report.add("config", PreflightFail, "user penalties may not be disabled on mainnet")
+	}
+
+	// Check the asset configurations.
+	assetIDs := make(map[uint32]*AssetConf, len(cfg.Assets))
+	for _, assetConf := range cfg.Assets {
+		symbol := strings.ToLower(assetConf.Symbol)
+		name := "asset " + symbol
+		ID, found := dex.BipSymbolID(symbol)
+		if !found {
+			report.add(name, PreflightFail, "unrecognized asset symbol")
+			continue
+		}
+		if _, dup := assetIDs[ID]; dup {
+			report.add(name, PreflightFail, "asset configured more than once")
+			continue
+		}
+		assetIDs[ID] = assetConf
+		if net, err := dex.NetFromString(assetConf.Network); err != nil {
+			report.add(name, PreflightFail, "unrecognized network %q", assetConf.Network)
+		} else if net != cfg.Network {
+			report.add(name, PreflightFail, "configured for network %s, expected %s", net, cfg.Network)
+		}
+		if assetConf.MaxFeeRate == 0 {
+			report.add(name, PreflightFail, "max fee rate of 0 is invalid")
+		}
+		if assetConf.LotSize == 0 || assetConf.RateStep == 0 {
+			report.add(name, PreflightFail, "lot size and rate step must be non-zero")
+		}
+	}
+
+	// Check that each market's assets are configured.
+	mktNames := make(map[string]bool, len(cfg.Markets))
+	var badMkts int
+	for _, mkt := range cfg.Markets {
+		name := "market " + strings.ToLower(mkt.Name)
+		if mktNames[mkt.Name] {
+			report.add(name, PreflightFail, "market configured more than once")
+			badMkts++
+			continue
+		}
+		mktNames[mkt.Name] = true
+		ok := true
+		for _, assetID := range []uint32{mkt.Base, mkt.Quote} {
+			if assetIDs[assetID] == nil {
+				report.add(name, PreflightFail, "asset %s is not configured", dex.BipIDSymbol(assetID))
+				ok = false
+			}
+		}
+		if mkt.EpochDuration == 0 {
+			report.add(name, PreflightFail, "epoch duration must be non-zero")
+			ok = false
+		}
+		if !ok {
+			badMkts++
+		}
+	}
+	if len(cfg.Markets) == 0 {
+		report.add("markets", PreflightWarn, "no markets configured for %s", cfg.Network)
+	} else if badMkts == 0 {
+		report.add("markets", PreflightOK, "%d markets with %d assets", len(cfg.Markets), len(cfg.Assets))
+	}
+
+	preflightDB(cfg, report)
+
+	for _, assetConf := range cfg.Assets {
+		symbol := strings.ToLower(assetConf.Symbol)
+		if _, found := dex.BipSymbolID(symbol); found {
+			preflightAsset(cfg, assetConf, report)
+		}
+	}
+
+	return report
+}
+
+// preflightDB inspects the database schema, adding the results to the report.
+func preflightDB(cfg *DexConf, report *PreflightReport) {
+	pgCfg := pgConfig(cfg)
+	pgCfg.MarketCfg = make([]*dex.MarketInfo, 0, len(cfg.Markets))
+	for _, mkt := range cfg.Markets {
+		// Market names are lower case in the DB, but do not modify the config.
+		mkt := *mkt
+		mkt.Name = strings.ToLower(mkt.Name)
+		pgCfg.MarketCfg = append(pgCfg.MarketCfg, &mkt)
+	}
+	schema, err := pg.CheckSchema(pgCfg)
+	if err != nil {
+		report.add("db", PreflightFail, "failed to inspect database %q: %v", cfg.DBConf.DBName, err)
+		return
+	}
+	switch {
+	case schema.Version > pg.DBVersion:
+		report.add("db schema", PreflightFail, "schema version %d is newer than the supported version %d",
+			schema.Version, pg.DBVersion)
+	case schema.Version == 0:
+		report.add("db schema", PreflightOK, "no schema version recorded, version %d will be set", pg.DBVersion)
+	default:
+		report.add("db schema", PreflightOK, "schema version %d", schema.Version)
+	}
+	for _, change := range schema.LotSizeChanges {
+		report.add("db markets", PreflightFail, "lot size changes are not supported: %s", change)
+	}
+	if len(schema.MissingTables) > 0 {
+		report.add("db tables", PreflightOK, "%d tables will be created: %s", len(schema.MissingTables),
+			strings.Join(schema.MissingTables, ", "))
+	}
+}
+
+// preflightAsset sets up the asset's backend to check its node, adding the
+// results to the report. The backend is shut down before returning.
+func preflightAsset(cfg *DexConf, assetConf *AssetConf, report *PreflightReport) {
+	symbol := strings.ToLower(assetConf.Symbol)
+	name := "node " + symbol
+	logger := cfg.LogBackend.SubLogger("ASSET", symbol)
+	be, err := setupBackend(assetConf, logger, cfg.Network)
+	if err != nil {
+		report.add(name, PreflightFail, "failed to set up backend: %v", err)
+		return
+	}
+	// Running the backend with a canceled context just shuts down its RPC
+	// client.
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		be.Run(ctx)
+	}()
+
+	if pf, ok := be.(asset.Preflighter); ok {
+		if err := pf.Preflight(); err != nil {
+			report.add(name, PreflightFail, "%v", err)
+			return
+		}
+	}
+
+	if _, err := be.FeeRate(); err != nil {
+		fallback := "the max fee rate"
+		if assetConf.FeeOracle != nil {
+			fallback = "the fee oracle"
+		}
+		report.add(name, PreflightWarn, "fee estimation failed, %s will be used: %v", fallback, err)
+		return
+	}
+	report.add(name, PreflightOK, "connected, swap size %d bytes", be.InitTxSize())
+}
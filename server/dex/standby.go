@@ -0,0 +1,215 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/swap"
+)
+
+// standbyFollowInterval is how often a Standby checks for a new swap state
+// checkpoint from the primary.
+const standbyFollowInterval = 5 * time.Second
+
+// Standby is a server in hot standby mode. A Standby shares the database of a
+// primary server, and follows the swap state checkpoints that the primary
+// writes to its data directory (see DexConf.CheckpointInterval). When
+// promoted, the Standby starts a DEX that restores the latest swap state,
+// loads the books from the database, and opens the client listeners. The
+// primary and the promoted Standby each hold a lease in the shared database
+// while running the DEX (see DexConf.LeaseTTL), so promotion is refused until
+// the primary has stopped or its lease has expired. If a live primary does
+// lose its lease, it is fenced and must stop.
+type Standby struct {
+	followDir string
+
+	mtx       sync.RWMutex
+	cfg       *DexConf
+	dex       *DEX
+	promoting bool
+	stateFile *swap.BackupFile
+	state     *swap.State
+	stateErr  error
+}
+
+// StandbyStatus describes the state of a Standby.
+type StandbyStatus struct {
+	// Promoted is true if the Standby has been promoted and is running a DEX.
+	// Promoting is true while the DEX is starting.
+	Promoted  bool
+	Promoting bool
+	// StateFile is the swap state file that will be restored on promotion,
+	// and StateTime is the time it was written by the primary.
+	StateFile string
+	StateTime time.Time
+	// LiveMatches is the number of in-flight swaps in the swap state.
+	LiveMatches int
+	// StateErr is any error encountered loading the latest swap state.
+	StateErr error
+}
+
+// NewStandby creates a Standby for the given DEX configuration, following the
+// swap state checkpoints in followDir. The database connection is checked, but
+// no subsystems are started until Promote is called.
+func NewStandby(cfg *DexConf, followDir string) (*Standby, error) {
+	if cfg.Anarchy && cfg.Network == dex.Mainnet {
+		return nil, fmt.Errorf("User penalties may not be disabled on mainnet.")
+	}
+	if cfg.LeaseTTL <= 0 {
+		return nil, errors.New("a standby requires a database lease")
+	}
+
+	inf, err := os.Stat(followDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid swap state directory: %v", err)
+	}
+	if !inf.IsDir() {
+		return nil, fmt.Errorf("path %q is not a directory", followDir)
+	}
+
+	for _, mkt := range cfg.Markets {
+		mkt.Name = strings.ToLower(mkt.Name)
+	}
+
+	// Make sure the shared DB is reachable now rather than on promotion.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage, err := db.Open(ctx, "pg", pgConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("db.Open: %v", err)
+	}
+	if err = storage.Close(); err != nil {
+		log.Warnf("DEXArchivist.Close: %v", err)
+	}
+
+	s := &Standby{
+		followDir: followDir,
+		cfg:       cfg,
+	}
+	s.follow()
+	return s, nil
+}
+
+// Run follows the primary's swap state until the context is canceled or the
+// Standby is promoted.
+func (s *Standby) Run(ctx context.Context) {
+	ticker := time.NewTicker(standbyFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.DEX() != nil {
+				return
+			}
+			s.follow()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// follow loads the latest swap state file if it is newer than the one already
+// loaded.
+func (s *Standby) follow() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stateFile, err := swap.LatestStateFile(s.followDir)
+	if err != nil {
+		s.stateErr = err
+		log.Errorf("Unable to locate swap state: %v", err)
+		return
+	}
+	if stateFile == nil || (s.stateFile != nil && stateFile.Stamp <= s.stateFile.Stamp) {
+		return
+	}
+	state, err := swap.LoadStateFile(stateFile.Name)
+	if err != nil {
+		// The file may have been replaced by a newer checkpoint since it was
+		// found. Keep the previous state and try again next time.
+		s.stateErr = fmt.Errorf("failed to load swap state file %v: %v", stateFile.Name, err)
+		log.Warn(s.stateErr)
+		return
+	}
+	s.stateFile, s.state, s.stateErr = stateFile, state, nil
+	log.Debugf("Following swap state %q with %d live matches", stateFile.Name, len(state.MatchTrackers))
+}
+
+// Promote starts a DEX with the latest swap state. The DEX takes over the
+// primary's database and client listeners, so Promote fails if the primary
+// still holds the database lease. The Standby is not locked while the DEX
+// starts, so Status remains available.
+func (s *Standby) Promote() (*DEX, error) {
+	s.follow()
+
+	s.mtx.Lock()
+	if s.dex != nil {
+		s.mtx.Unlock()
+		return nil, errors.New("already promoted")
+	}
+	if s.promoting {
+		s.mtx.Unlock()
+		return nil, errors.New("promotion already in progress")
+	}
+	s.promoting = true
+	if s.stateErr != nil {
+		log.Warnf("Promoting with an older swap state: %v", s.stateErr)
+	}
+	if s.state == nil {
+		log.Warnf("Promoting without a swap state. There will be no active swaps.")
+	} else {
+		log.Infof("Promoting with swap state %q from %v, containing %d live matches",
+			s.stateFile.Name, encode.UnixTimeMilli(s.stateFile.Stamp), len(s.state.MatchTrackers))
+	}
+
+	cfg := *s.cfg
+	cfg.SwapState = s.state
+	s.mtx.Unlock()
+
+	dexMan, err := NewDEX(&cfg)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.promoting = false
+	if err != nil {
+		return nil, err
+	}
+	s.dex = dexMan
+	log.Infof("Standby promoted. The DEX is running.")
+	return dexMan, nil
+}
+
+// DEX returns the DEX started by Promote, or nil if the Standby has not been
+// promoted.
+func (s *Standby) DEX() *DEX {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.dex
+}
+
+// Status returns the current StandbyStatus.
+func (s *Standby) Status() *StandbyStatus {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	status := &StandbyStatus{
+		Promoted:  s.dex != nil,
+		Promoting: s.promoting,
+		StateErr:  s.stateErr,
+	}
+	if s.stateFile != nil {
+		status.StateFile = s.stateFile.Name
+		status.StateTime = encode.UnixTimeMilli(s.stateFile.Stamp)
+		status.LiveMatches = len(s.state.MatchTrackers)
+	}
+	return status
+}
@@ -0,0 +1,183 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package dex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/market"
+)
+
+// maxTimelineCancels is the maximum number of the most recent executed cancel
+// orders and revocations that are included in an account's timeline.
+const maxTimelineCancels = 10000
+
+// AccountTimeline merges an account's connections, orders, cancels, matches,
+// penalties, and trade fees into a single chronological list, oldest first.
+// Events from since (inclusive) to until (exclusive) are included, and a page
+// of up to n of them is returned, starting from offset. A zero until means no
+// upper bound. Orders and matches are only listed for the current markets.
+func (dm *DEX) AccountTimeline(aid account.AccountID, since, until time.Time, offset, n int) (*db.Timeline, error) {
+	events, err := dm.timelineEvents(aid)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Stamp < events[j].Stamp
+	})
+
+	sinceMs := encode.UnixMilli(since)
+	inPeriod := events[:0]
+	for _, e := range events {
+		if e.Stamp < sinceMs || (!until.IsZero() && e.Stamp >= encode.UnixMilli(until)) {
+			continue
+		}
+		inPeriod = append(inPeriod, e)
+	}
+
+	timeline := &db.Timeline{
+		AccountID: aid,
+		Total:     len(inPeriod),
+		Offset:    offset,
+		Events:    []*db.TimelineEvent{},
+	}
+	if offset < len(inPeriod) {
+		end := len(inPeriod)
+		if offset+n < end {
+			end = offset + n
+		}
+		timeline.Events = inPeriod[offset:end]
+	}
+	return timeline, nil
+}
+
+// timelineEvents assembles an account's timeline events, in no particular
+// order.
+func (dm *DEX) timelineEvents(aid account.AccountID) ([]*db.TimelineEvent, error) {
+	var events []*db.TimelineEvent
+	add := func(stamp int64, typ db.TimelineEventType, mkt, id, details string) {
+		events = append(events, &db.TimelineEvent{
+			Stamp:   stamp,
+			Type:    typ,
+			Market:  mkt,
+			ID:      id,
+			Details: details,
+		})
+	}
+
+	versions, err := dm.storage.ClientVersions(aid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving client versions: %w", err)
+	}
+	for _, v := range versions {
+		details := fmt.Sprintf("user agent %q, API version %d", v.UserAgent, v.APIVersion)
+		add(v.FirstSeen, db.TimelineFirstConnect, "", "", details)
+		if v.LastSeen != v.FirstSeen {
+			add(v.LastSeen, db.TimelineLastConnect, "",
+				"", fmt.Sprintf("%s, %d connections", details, v.Connects))
+		}
+	}
+
+	dm.marketsMtx.RLock()
+	mkts := make(map[string]*market.Market, len(dm.markets))
+	for name, mkt := range dm.markets {
+		mkts[name] = mkt
+	}
+	dm.marketsMtx.RUnlock()
+
+	for name, mkt := range mkts {
+		ords, statuses, err := dm.storage.UserOrders(context.Background(), aid, mkt.Base(), mkt.Quote())
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving %s orders: %w", name, err)
+		}
+		for i, ord := range ords {
+			add(ord.Time(), db.TimelineOrder, name, ord.ID().String(), orderDetails(ord, statuses[i]))
+		}
+
+		matches, err := dm.storage.UserMatches(aid, mkt.Base(), mkt.Quote())
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving %s matches: %w", name, err)
+		}
+		for _, m := range matches {
+			oid, role := m.Maker, "maker"
+			if m.TakerAcct == aid {
+				oid, role = m.Taker, "taker"
+			}
+			details := fmt.Sprintf("%s for order %s, quantity %d at rate %d, status %s",
+				role, oid, m.Quantity, m.Rate, m.Status)
+			if !m.Active {
+				details += ", inactive"
+			}
+			add(encode.UnixMilli(m.Epoch.End()), db.TimelineMatch, name, m.ID.String(), details)
+		}
+	}
+
+	oids, targets, execTimes, err := dm.storage.ExecutedCancelsForUser(aid, maxTimelineCancels)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving cancels: %w", err)
+	}
+	for i, oid := range oids {
+		add(execTimes[i], db.TimelineCancel, "", oid.String(),
+			fmt.Sprintf("canceled order %s", targets[i]))
+	}
+
+	misses, err := dm.storage.PreimageMisses(aid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving preimage misses: %w", err)
+	}
+	for _, miss := range misses {
+		mkt, _ := dex.MarketName(miss.Base, miss.Quote)
+		add(miss.Stamp, db.TimelinePreimageMiss, mkt, miss.OrderID.String(),
+			fmt.Sprintf("preimage request %s for epoch %d", miss.Reason, miss.EpochIdx))
+	}
+
+	appeal, err := dm.storage.Appeal(aid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving appeal: %w", err)
+	}
+	if appeal != nil {
+		add(appeal.Submitted, db.TimelineAppeal, "", "",
+			fmt.Sprintf("appeal of penalty for %s: %s", appeal.BrokenRule, appeal.Message))
+		if appeal.Status != db.AppealPending {
+			add(appeal.Decided, db.TimelineAppealDecided, "", "",
+				fmt.Sprintf("appeal %s: %s", appeal.Status, appeal.Reason))
+		}
+	}
+
+	fees, err := dm.storage.TradeFees(aid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving trade fees: %w", err)
+	}
+	for _, fee := range fees {
+		add(fee.Stamp, db.TimelineTradeFee, "", fee.MatchID.String(),
+			fmt.Sprintf("%d %s", fee.Amount, dex.BipIDSymbol(fee.AssetID)))
+	}
+
+	return events, nil
+}
+
+// orderDetails describes a trade order for the timeline.
+func orderDetails(ord order.Order, status order.OrderStatus) string {
+	trade := ord.Trade()
+	side := "buy"
+	if trade.Sell {
+		side = "sell"
+	}
+	details := fmt.Sprintf("%s %s, quantity %d", ord.Type(), side, trade.Quantity)
+	if lo, ok := ord.(*order.LimitOrder); ok {
+		details += fmt.Sprintf(" at rate %d", lo.Rate)
+		if lo.Force == order.ImmediateTiF {
+			details += ", immediate"
+		}
+	}
+	return fmt.Sprintf("%s, filled %d, status %s", details, trade.Filled(), status)
+}
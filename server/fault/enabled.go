@@ -0,0 +1,9 @@
+// +build fault
+
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package fault
+
+// Enabled indicates that fault injection is enabled in this build.
+const Enabled = true
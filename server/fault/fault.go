@@ -0,0 +1,136 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package fault provides fault injection points for exercising the server's
+// failure handling paths in integration tests. Faults can only be armed when
+// the server is built with the fault build tag. In normal builds, Enabled is
+// false and the injection points compile to no-ops.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Point identifies a fault injection point.
+type Point string
+
+// These are the supported fault injection points.
+const (
+	// DropAck causes the swapper to ignore a received match, audit or
+	// redemption acknowledgement, as if the client never sent it.
+	DropAck Point = "dropack"
+	// BlockDelay delays the swapper's handling of a new block notification by
+	// the fault's Delay.
+	BlockDelay Point = "blockdelay"
+	// DBError causes an order or match insert to fail with ErrInjected.
+	DBError Point = "dberror"
+	// Reorg causes an asset backend to report the next block as a reorg.
+	Reorg Point = "reorg"
+)
+
+// Points is a list of all supported fault injection points.
+var Points = []Point{DropAck, BlockDelay, DBError, Reorg}
+
+// ErrInjected is the error returned from a DBError injection point.
+var ErrInjected = errors.New("injected fault")
+
+// Fault is an armed fault. A fault with a zero Count remains armed until it is
+// cleared. Otherwise, the fault is cleared after it is hit Count times.
+type Fault struct {
+	Point Point         `json:"point"`
+	Count int           `json:"count"`
+	Delay time.Duration `json:"delay,omitempty"`
+	Hits  int           `json:"hits"`
+}
+
+var (
+	mtx    sync.Mutex
+	faults = make(map[Point]*Fault)
+)
+
+// ValidPoint checks that the point is a supported injection point.
+func ValidPoint(p Point) bool {
+	for _, pt := range Points {
+		if pt == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Arm arms the fault at the point. Any existing fault at the point is
+// replaced. An error is returned if the server was not built with the fault
+// build tag.
+func Arm(p Point, count int, delay time.Duration) error {
+	if !Enabled {
+		return fmt.Errorf("fault injection not enabled in this build")
+	}
+	if !ValidPoint(p) {
+		return fmt.Errorf("unknown fault point %q", p)
+	}
+	if count < 0 {
+		return fmt.Errorf("invalid fault count %d", count)
+	}
+	mtx.Lock()
+	faults[p] = &Fault{Point: p, Count: count, Delay: delay}
+	mtx.Unlock()
+	return nil
+}
+
+// Clear disarms the fault at the point.
+func Clear(p Point) {
+	mtx.Lock()
+	delete(faults, p)
+	mtx.Unlock()
+}
+
+// Armed lists the armed faults, sorted by point.
+func Armed() []*Fault {
+	mtx.Lock()
+	defer mtx.Unlock()
+	fs := make([]*Fault, 0, len(faults))
+	for _, f := range faults {
+		fCopy := *f
+		fs = append(fs, &fCopy)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Point < fs[j].Point })
+	return fs
+}
+
+// Hit is called at an injection point, and is true if a fault is armed at the
+// point. Each hit counts towards the fault's Count.
+func Hit(p Point) bool {
+	if !Enabled {
+		return false
+	}
+	_, hit := hit(p)
+	return hit
+}
+
+// Delay is called at an injection point that delays processing, and returns
+// the armed fault's Delay, or zero if no fault is armed at the point.
+func Delay(p Point) time.Duration {
+	if !Enabled {
+		return 0
+	}
+	d, _ := hit(p)
+	return d
+}
+
+func hit(p Point) (time.Duration, bool) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	f, found := faults[p]
+	if !found {
+		return 0, false
+	}
+	f.Hits++
+	if f.Count > 0 && f.Hits >= f.Count {
+		delete(faults, p)
+	}
+	return f.Delay, true
+}
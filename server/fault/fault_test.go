@@ -0,0 +1,77 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package fault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArm(t *testing.T) {
+	err := Arm(DropAck, 2, 0)
+	if !Enabled {
+		if err == nil {
+			t.Fatalf("no error arming fault in a build without the fault tag")
+		}
+		if Hit(DropAck) {
+			t.Fatalf("fault hit in a build without the fault tag")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Arm error: %v", err)
+	}
+	if err = Arm("nonsense", 0, 0); err == nil {
+		t.Fatalf("no error for unknown point")
+	}
+	if err = Arm(DBError, -1, 0); err == nil {
+		t.Fatalf("no error for negative count")
+	}
+	if err = Arm(BlockDelay, 0, time.Second); err != nil {
+		t.Fatalf("Arm error: %v", err)
+	}
+	defer Clear(BlockDelay)
+
+	armed := Armed()
+	if len(armed) != 2 || armed[0].Point != BlockDelay || armed[1].Point != DropAck {
+		t.Fatalf("unexpected armed faults %v", armed)
+	}
+
+	// DropAck should be cleared after 2 hits.
+	for i := 0; i < 2; i++ {
+		if !Hit(DropAck) {
+			t.Fatalf("missed hit %d", i)
+		}
+	}
+	if Hit(DropAck) {
+		t.Fatalf("fault not cleared after count reached")
+	}
+
+	// BlockDelay has no count, so stays armed.
+	for i := 0; i < 5; i++ {
+		if d := Delay(BlockDelay); d != time.Second {
+			t.Fatalf("wrong delay %v", d)
+		}
+	}
+	Clear(BlockDelay)
+	if d := Delay(BlockDelay); d != 0 {
+		t.Fatalf("delay after clear")
+	}
+}
+
+func TestHitCounting(t *testing.T) {
+	Clear(Reorg)
+	if d, hit := hit(Reorg); hit || d != 0 {
+		t.Fatalf("hit unarmed fault")
+	}
+	mtx.Lock()
+	faults[Reorg] = &Fault{Point: Reorg, Count: 1}
+	mtx.Unlock()
+	if _, hit := hit(Reorg); !hit {
+		t.Fatalf("missed armed fault")
+	}
+	if _, hit := hit(Reorg); hit {
+		t.Fatalf("fault not cleared")
+	}
+}
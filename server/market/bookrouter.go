@@ -328,7 +328,7 @@ out:
 				bookNote := book.update(lo)
 				n := &msgjson.UpdateRemainingNote{
 					OrderNote: bookNote.OrderNote,
-					Remaining: lo.Remaining(),
+					Remaining: lo.Displayed(),
 				}
 				n.Seq = subs.nextSeq()
 				note = n
@@ -537,7 +537,8 @@ func (r *BookRouter) sendNote(route string, subs *subscribers, note interface{})
 }
 
 // limitOrderToMsgOrder converts an *order.LimitOrder to a
-// *msgjson.BookOrderNote.
+// *msgjson.BookOrderNote. Only the displayed quantity of an iceberg order is
+// shown.
 func limitOrderToMsgOrder(o *order.LimitOrder, mkt string) *msgjson.BookOrderNote {
 	oid := o.ID()
 	oSide := uint8(msgjson.BuyOrderNum)
@@ -556,7 +557,7 @@ func limitOrderToMsgOrder(o *order.LimitOrder, mkt string) *msgjson.BookOrderNot
 		},
 		TradeNote: msgjson.TradeNote{
 			Side:     oSide,
-			Quantity: o.Remaining(),
+			Quantity: o.Displayed(),
 			Rate:     o.Rate,
 			TiF:      tif,
 			Time:     encode.UnixMilliU(o.ServerTime),
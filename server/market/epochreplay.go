@@ -0,0 +1,125 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
+)
+
+// epochReplayHistory is the number of the most recent epochs with orders for
+// which the matching inputs are retained for replay requests.
+const epochReplayHistory = 256
+
+// epochReplay is the redacted matching input and the results of an epoch.
+type epochReplay struct {
+	epochIdx  int64
+	epochDur  int64
+	oddLotQty uint64
+	queue     []*matcher.OrderRevealed
+	book      []*order.LimitOrder
+	users     map[order.OrderID]account.AccountID
+	seed      []byte
+	result    []byte
+}
+
+// newEpochReplay creates an epochReplay with redacted copies of the epoch
+// queue orders and the odd-lot quantity used to match them. This must be done
+// before matching updates the fill amounts.
+func newEpochReplay(epochIdx, epochDur int64, oddLotQty uint64, queue []*matcher.OrderRevealed) *epochReplay {
+	rep := &epochReplay{
+		epochIdx:  epochIdx,
+		epochDur:  epochDur,
+		oddLotQty: oddLotQty,
+		queue:     make([]*matcher.OrderRevealed, 0, len(queue)),
+		users:     make(map[order.OrderID]account.AccountID, len(queue)),
+	}
+	for _, or := range queue {
+		rep.queue = append(rep.queue, &matcher.OrderRevealed{
+			Order:    order.RedactOrder(or.Order),
+			Preimage: or.Preimage,
+		})
+		rep.users[or.Order.ID()] = or.Order.User()
+	}
+	return rep
+}
+
+// setResults records the book orders needed to replay the epoch and the
+// results of matching the original queue.
+func (rep *epochReplay) setResults(queue []*matcher.OrderRevealed, matches []*order.MatchSet,
+	seed []byte, updates *matcher.OrdersUpdated) {
+	for _, lo := range replay.EpochBook(queue, matches) {
+		rep.book = append(rep.book, order.RedactOrder(lo).(*order.LimitOrder))
+	}
+	rep.seed = seed
+	rep.result = replay.ResultDigest(seed, matches, updates)
+}
+
+// storeEpochReplay retains the epochReplay, dropping the oldest one if there
+// are more than epochReplayHistory.
+func (m *Market) storeEpochReplay(rep *epochReplay) {
+	m.replaysMtx.Lock()
+	defer m.replaysMtx.Unlock()
+	if m.replays == nil {
+		m.replays = make(map[int64]*epochReplay, epochReplayHistory)
+	}
+	if _, found := m.replays[rep.epochIdx]; !found {
+		m.replayIdxs = append(m.replayIdxs, rep.epochIdx)
+	}
+	m.replays[rep.epochIdx] = rep
+	if len(m.replayIdxs) > epochReplayHistory {
+		delete(m.replays, m.replayIdxs[0])
+		m.replayIdxs = m.replayIdxs[1:]
+	}
+}
+
+// EpochReplay returns the redacted epoch queue, the book orders, and the
+// results of matching for a recent epoch, for the user to verify the matching
+// of their order by replaying the epoch. Only the most recent
+// epochReplayHistory epochs with orders are retained.
+func (m *Market) EpochReplay(epochIdx int64, oid order.OrderID, user account.AccountID) (*msgjson.EpochReplayResult, error) {
+	m.replaysMtx.RLock()
+	rep, found := m.replays[epochIdx]
+	m.replaysMtx.RUnlock()
+	if !found {
+		return nil, ErrEpochNotRetained
+	}
+	// Do not reveal whether the order is in the epoch to other users.
+	if owner, found := rep.users[oid]; !found || owner != user {
+		return nil, ErrOrderNotInEpoch
+	}
+
+	res := &msgjson.EpochReplayResult{
+		MarketID:  m.marketInfo.Name,
+		Epoch:     uint64(rep.epochIdx),
+		Duration:  uint64(rep.epochDur),
+		LotSize:   m.marketInfo.LotSize,
+		TradeFee:  m.marketInfo.TradeFee,
+		OddLotQty: rep.oddLotQty,
+		Book:      make([]*msgjson.ReplayOrder, 0, len(rep.book)),
+		Queue:     make([]*msgjson.ReplayOrder, 0, len(rep.queue)),
+		Seed:      rep.seed,
+		Result:    rep.result,
+	}
+	for _, lo := range rep.book {
+		oid := lo.ID()
+		res.Book = append(res.Book, &msgjson.ReplayOrder{
+			OrderID: oid[:],
+			Order:   order.EncodeOrder(lo),
+		})
+	}
+	for _, or := range rep.queue {
+		oid := or.Order.ID()
+		pi := or.Preimage
+		res.Queue = append(res.Queue, &msgjson.ReplayOrder{
+			OrderID:  oid[:],
+			Order:    order.EncodeOrder(or.Order),
+			Preimage: pi[:],
+		})
+	}
+	return res, nil
+}
@@ -3,6 +3,7 @@ package market
 import (
 	"context"
 	"sync"
+	"time"
 
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/server/matcher"
@@ -14,6 +15,39 @@ type readyEpoch struct {
 	cSum           []byte
 	ordersRevealed []*matcher.OrderRevealed
 	misses         []order.Order
+	collectTime    time.Duration // time spent on preimage collection
+
+	// force is closed by ForceEpoch to end preimage collection early. skip is
+	// set before force is closed, and must only be read after force is closed.
+	force     chan struct{}
+	forceOnce sync.Once
+	skip      bool
+	// cut are the orders that were revoked without matching because preimage
+	// collection was forced to end, or the epoch was skipped.
+	cut []order.Order
+}
+
+// forceReady ends preimage collection early. If skip is true, none of the
+// epoch's orders will be matched. forceReady returns false if the epoch was
+// already forced.
+func (rq *readyEpoch) forceReady(skip bool) bool {
+	var forced bool
+	rq.forceOnce.Do(func() {
+		rq.skip = skip
+		close(rq.force)
+		forced = true
+	})
+	return forced
+}
+
+// forced checks if forceReady was called.
+func (rq *readyEpoch) forced() bool {
+	select {
+	case <-rq.force:
+		return true
+	default:
+		return false
+	}
 }
 
 type epochPump struct {
@@ -22,6 +56,9 @@ type epochPump struct {
 	mtx  sync.RWMutex
 	q    []*readyEpoch
 	halt bool
+	// head is the epoch that the pump is waiting on to complete preimage
+	// collection.
+	head *readyEpoch
 
 	newQ chan struct{}
 }
@@ -62,6 +99,7 @@ func (ep *epochPump) Insert(epoch *EpochQueue) *readyEpoch {
 	rq := &readyEpoch{
 		EpochQueue: epoch,
 		ready:      make(chan struct{}),
+		force:      make(chan struct{}),
 	}
 
 	ep.mtx.Lock()
@@ -100,6 +138,7 @@ func (ep *epochPump) next() <-chan *readyEpoch {
 
 	ep.mtx.Lock()
 	head := ep.popFront()
+	ep.head = head
 	ep.mtx.Unlock()
 
 	if head == nil { // pump halted
@@ -111,7 +150,29 @@ func (ep *epochPump) next() <-chan *readyEpoch {
 	// dies before goroutine completion, the Market is down anyway.
 	go func() {
 		<-head.ready // block until preimage collection is complete (close this channel)
+		ep.mtx.Lock()
+		if ep.head == head {
+			ep.head = nil
+		}
+		ep.mtx.Unlock()
 		ready <- head
 	}()
 	return ready
 }
+
+// waiting returns the epoch that is blocking the pump while it completes
+// preimage collection, or nil if the pump is not waiting on an epoch.
+func (ep *epochPump) waiting() *readyEpoch {
+	ep.mtx.RLock()
+	head := ep.head
+	ep.mtx.RUnlock()
+	if head == nil {
+		return nil
+	}
+	select {
+	case <-head.ready:
+		return nil
+	default:
+		return head
+	}
+}
@@ -0,0 +1,109 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
+)
+
+// ExportVersion is the version of the Export format.
+const ExportVersion = 1
+
+// Export is an image of a market's state for migrating the market to another
+// server, or out of a corrupted DB. The orders are serialized with
+// order.EncodeOrder, and Swaps is the swapper's encoded state of the market's
+// settling matches (see swap.DecodeState). The market should be suspended
+// with its book persisted before it is exported, so that the image is
+// consistent and no orders are in an epoch.
+type Export struct {
+	Version       uint32 `json:"version"`
+	Market        string `json:"market"`
+	Base          uint32 `json:"base"`
+	Quote         uint32 `json:"quote"`
+	LotSize       uint64 `json:"lotSize"`
+	EpochDuration uint64 `json:"epochDuration"`
+	// Stamp is the time of the export in milliseconds.
+	Stamp       int64       `json:"stamp"`
+	Running     bool        `json:"running"`
+	ActiveEpoch int64       `json:"activeEpoch"`
+	BookOrders  []dex.Bytes `json:"bookOrders"`
+	EpochOrders []dex.Bytes `json:"epochOrders"`
+	// Matches is the number of settling matches in Swaps.
+	Matches int       `json:"matches"`
+	Swaps   dex.Bytes `json:"swaps"`
+}
+
+// Export creates an image of the market's booked and epoch orders. The
+// settling matches are added by the caller, who has the Swapper.
+func (m *Market) Export() *Export {
+	exp := &Export{
+		Version:       ExportVersion,
+		Market:        m.marketInfo.Name,
+		Base:          m.marketInfo.Base,
+		Quote:         m.marketInfo.Quote,
+		LotSize:       m.marketInfo.LotSize,
+		EpochDuration: m.marketInfo.EpochDuration,
+		Stamp:         encode.UnixMilli(time.Now()),
+		Running:       m.Running(),
+		BookOrders:    make([]dex.Bytes, 0),
+		EpochOrders:   make([]dex.Bytes, 0),
+	}
+
+	// The booked orders are encoded with the book locked, since their filled
+	// amounts are updated by matching.
+	m.bookMtx.Lock()
+	for _, lo := range append(m.book.BuyOrders(), m.book.SellOrders()...) {
+		exp.BookOrders = append(exp.BookOrders, order.EncodeOrder(lo))
+	}
+	m.bookMtx.Unlock()
+
+	m.epochMtx.RLock()
+	exp.ActiveEpoch = m.activeEpochIdx
+	for _, ord := range m.epochOrders {
+		exp.EpochOrders = append(exp.EpochOrders, order.EncodeOrder(ord))
+	}
+	m.epochMtx.RUnlock()
+	return exp
+}
+
+// Orders decodes the exported orders, checking that they are orders of the
+// market. The booked orders must be standing limit orders.
+func (exp *Export) Orders() (booked []*order.LimitOrder, epoch []order.Order, err error) {
+	checkMarket := func(ord order.Order) error {
+		if ord.Base() != exp.Base || ord.Quote() != exp.Quote {
+			return fmt.Errorf("order %v is not an order of market %s", ord.ID(), exp.Market)
+		}
+		return nil
+	}
+	for _, b := range exp.BookOrders {
+		ord, err := order.DecodeOrder(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding booked order: %w", err)
+		}
+		lo, ok := ord.(*order.LimitOrder)
+		if !ok || lo.Force != order.StandingTiF {
+			return nil, nil, fmt.Errorf("booked order %v is not a standing limit order", ord.ID())
+		}
+		if err = checkMarket(lo); err != nil {
+			return nil, nil, err
+		}
+		booked = append(booked, lo)
+	}
+	for _, b := range exp.EpochOrders {
+		ord, err := order.DecodeOrder(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding epoch order: %w", err)
+		}
+		if err = checkMarket(ord); err != nil {
+			return nil, nil, err
+		}
+		epoch = append(epoch, ord)
+	}
+	return booked, epoch, nil
+}
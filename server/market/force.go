@@ -0,0 +1,118 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"fmt"
+	"time"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
+)
+
+// forceTimeout is how long ForceEpoch waits for a forced epoch to finish
+// preimage collection.
+const forceTimeout = 10 * time.Second
+
+// ForcedEpoch describes a closed epoch that is stuck in preimage collection,
+// and what was done to it by ForceEpoch.
+type ForcedEpoch struct {
+	Epoch   int64
+	Orders  int
+	DryRun  bool
+	Skipped bool
+	// Revoked are the orders that were revoked without being matched.
+	Revoked []order.OrderID
+}
+
+// ForceEpoch ends preimage collection for the closed epoch that is blocking
+// epoch processing. With skip false, the orders with revealed preimages are
+// matched and the others are revoked. With skip true, every order in the
+// epoch is revoked. Revoked orders are not counted against their users. The
+// epoch index must match the stalled epoch, which is reported by a dry run,
+// so that an epoch is not forced unseen. ForceEpoch does not change the epoch
+// schedule, and the orders of the current epoch are not affected.
+func (m *Market) ForceEpoch(epochIdx int64, skip, dryRun bool) (*ForcedEpoch, error) {
+	m.runMtx.RLock()
+	pump := m.pump
+	m.runMtx.RUnlock()
+	if pump == nil {
+		return nil, ErrMarketNotRunning
+	}
+	rq := pump.waiting()
+	if rq == nil {
+		return nil, ErrNoStalledEpoch
+	}
+
+	fe := &ForcedEpoch{
+		Epoch:  rq.Epoch,
+		Orders: len(rq.Orders),
+		DryRun: dryRun,
+	}
+	if dryRun {
+		return fe, nil
+	}
+	if epochIdx != rq.Epoch {
+		return nil, fmt.Errorf("epoch %d is not the stalled epoch %d", epochIdx, rq.Epoch)
+	}
+	if !rq.forceReady(skip) {
+		return nil, fmt.Errorf("epoch %d has already been forced", rq.Epoch)
+	}
+	log.Warnf("Forcing stalled epoch %d on market %s (skip = %v).", rq.Epoch, m.marketInfo.Name, skip)
+
+	select {
+	case <-rq.ready:
+	case <-time.After(forceTimeout):
+		return nil, fmt.Errorf("epoch %d forced, but still not ready after %v", rq.Epoch, forceTimeout)
+	}
+	fe.Skipped = rq.skip
+	for _, ord := range rq.cut {
+		fe.Revoked = append(fe.Revoked, ord.ID())
+	}
+	return fe, nil
+}
+
+// revokeCut revokes the orders that were cut from a forced epoch, without
+// counting the revocations against their users. The users of trade orders are
+// sent a revoke_order notification. Cancel orders simply fail.
+func (m *Market) revokeCut(epochIdx int64, cut []order.Order) {
+	for _, ord := range cut {
+		m.unlockOrderCoins(ord)
+		if co, isCancel := ord.(*order.CancelOrder); isCancel {
+			if err := m.storage.FailCancelOrder(co); err != nil {
+				log.Errorf("Failed to fail cancel order %v from forced epoch %d: %v", co, epochIdx, err)
+			}
+			continue
+		}
+		if _, _, err := m.storage.RevokeOrderUncounted(ord); err != nil {
+			log.Errorf("Failed to revoke order %v from forced epoch %d: %v", ord.ID(), epochIdx, err)
+			continue
+		}
+		m.sendRevokeOrder(ord)
+	}
+	if len(cut) > 0 {
+		log.Warnf("Revoked %d orders from forced epoch %d on market %s.", len(cut), epochIdx, m.marketInfo.Name)
+	}
+}
+
+// sendRevokeOrder sends a revoke_order notification for the order to its user,
+// waiting for the user to connect if necessary.
+func (m *Market) sendRevokeOrder(ord order.Order) {
+	oid := ord.ID()
+	rev := &msgjson.RevokeOrder{
+		OrderID: oid[:],
+	}
+	if err := m.auth.Sign(rev); err != nil {
+		log.Errorf("Failed to sign revoke_order notification for order %v: %v", oid, err)
+		return
+	}
+	note, err := msgjson.NewNotification(msgjson.RevokeOrderRoute, rev)
+	if err != nil {
+		log.Errorf("Failed to create revoke_order notification for order %v: %v", oid, err)
+		return
+	}
+	m.auth.SendWhenConnected(ord.User(), note, DefaultConnectTimeout, func() {
+		log.Debugf("User %v not connected for revoke_order notification for order %v", ord.User(), oid)
+	})
+}
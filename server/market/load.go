@@ -0,0 +1,89 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"decred.org/dcrdex/dex/order"
+)
+
+// Each Market stamps its orders and processes its epochs in its own goroutines,
+// so a busy market does not hold up the others. The work a market may have
+// waiting is bounded. When a market cannot keep up, new trade orders are
+// refused with ErrMarketOverloaded rather than queued without limit. Cancel
+// orders are only refused when the order queue is full, so that users can
+// still pull their orders from an overloaded market.
+
+const (
+	// defaultOrderQueueSize is the default number of submitted orders that
+	// may be waiting for the market to stamp them.
+	defaultOrderQueueSize = 32
+	// defaultMaxEpochBacklog is the default number of closed epochs that may
+	// be waiting to be matched before trade orders are refused.
+	defaultMaxEpochBacklog = 3
+)
+
+// orderQueueSize is the number of submitted orders that may be waiting for the
+// market to stamp them.
+func (m *Market) orderQueueSize() int {
+	if m.marketInfo.OrderQueueSize == 0 {
+		return defaultOrderQueueSize
+	}
+	return int(m.marketInfo.OrderQueueSize)
+}
+
+// maxEpochBacklog is the number of closed epochs that may be waiting to be
+// matched before trade orders are refused.
+func (m *Market) maxEpochBacklog() int {
+	if m.marketInfo.MaxEpochBacklog == 0 {
+		return defaultMaxEpochBacklog
+	}
+	return int(m.marketInfo.MaxEpochBacklog)
+}
+
+// epochClosed records a closed epoch that is waiting to be matched.
+func (m *Market) epochClosed() {
+	m.loadMtx.Lock()
+	m.epochBacklog++
+	m.loadMtx.Unlock()
+}
+
+// epochProcessed records that a closed epoch was matched.
+func (m *Market) epochProcessed() {
+	m.loadMtx.Lock()
+	m.epochBacklog--
+	m.loadMtx.Unlock()
+}
+
+// checkLoad checks that the market can take the order into the epoch, and
+// records the order as shed if it cannot.
+func (m *Market) checkLoad(ord order.Order, epoch *EpochQueue) error {
+	if ord.Type() == order.CancelOrderType {
+		return nil
+	}
+	m.loadMtx.Lock()
+	backlog := m.epochBacklog
+	m.loadMtx.Unlock()
+	if backlog > m.maxEpochBacklog() {
+		return m.shed(ord, "%d epochs waiting to be matched", backlog)
+	}
+	if max := m.marketInfo.MaxEpochOrders; max > 0 {
+		// Each cancel order in the epoch has a distinct target.
+		n := uint32(len(epoch.Orders) - len(epoch.CancelTargets))
+		if n >= max {
+			return m.shed(ord, "epoch %d has %d trade orders", epoch.Epoch, n)
+		}
+	}
+	return nil
+}
+
+// shed records that the order was refused because the market is overloaded,
+// returning ErrMarketOverloaded.
+func (m *Market) shed(ord order.Order, reason string, args ...interface{}) error {
+	m.loadMtx.Lock()
+	m.shedOrders++
+	m.loadMtx.Unlock()
+	log.Warnf("Market %s overloaded, refusing order from user %v: "+reason,
+		append([]interface{}{m.marketInfo.Name, ord.User()}, args...)...)
+	return ErrMarketOverloaded
+}
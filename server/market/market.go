@@ -23,6 +23,8 @@ import (
 	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/db"
 	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
+	"decred.org/dcrdex/server/trace"
 	"github.com/decred/slog"
 )
 
@@ -45,6 +47,11 @@ const (
 	ErrSuspendedAccount       = Error("suspended account")
 	ErrMalformedOrderResponse = Error("malformed order response")
 	ErrInternalServer         = Error("internal server error")
+	ErrNoStalledEpoch         = Error("no epoch is awaiting preimage collection")
+	ErrCoinsLocked            = Error("order coins already locked")
+	ErrEpochNotRetained       = Error("epoch not available for replay")
+	ErrOrderNotInEpoch        = Error("order not in epoch")
+	ErrMarketOverloaded       = Error("market overloaded")
 )
 
 // Swapper coordinates atomic swaps for one or more matchsets.
@@ -84,6 +91,7 @@ type Market struct {
 
 	runMtx  sync.RWMutex
 	running chan struct{} // closed when running
+	pump    *epochPump    // the closed epoch pump while running, for ForceEpoch
 
 	bookMtx      sync.Mutex // guards book and bookEpochIdx
 	book         *book.Book
@@ -106,17 +114,52 @@ type Market struct {
 
 	// Persistent data storage
 	storage db.DEXArchivist
+
+	// stats tracks the rate and volume of matched trades.
+	stats tradeStats
+
+	// timings tracks the time spent processing epochs.
+	timings epochTimings
+
+	// recorder records the order flow for offline replay. It is guarded by
+	// bookMtx.
+	recorder *replay.Recorder
+
+	// replays are the matching inputs of recent epochs, for replay requests.
+	// replayIdxs are the epoch indexes in the order stored.
+	replaysMtx sync.RWMutex
+	replays    map[int64]*epochReplay
+	replayIdxs []int64
+
+	// loadMtx guards epochBacklog, the number of closed epochs waiting to be
+	// matched, and shedOrders, the number of orders refused because the
+	// market was overloaded.
+	loadMtx      sync.Mutex
+	epochBacklog int
+	shedOrders   uint64
+
+	// minOrderQty is the minimum order quantity from the minimum order value
+	// at the last known spot rate. It is accessed atomically.
+	minOrderQty uint64
+
+	// tracer records the events of orders. It may be nil.
+	tracer *trace.Tracer
+
+	// log is the package logger with the market name as a log attribute.
+	log dex.Logger
 }
 
 // NewMarket creates a new Market for the provided base and quote assets, with
-// an epoch cycling at given duration in milliseconds.
+// an epoch cycling at given duration in milliseconds. The tracer may be nil.
 func NewMarket(mktInfo *dex.MarketInfo, storage db.DEXArchivist, swapper Swapper, authMgr AuthManager,
-	coinLockerBase, coinLockerQuote coinlock.CoinLocker) (*Market, error) {
+	coinLockerBase, coinLockerQuote coinlock.CoinLocker, tracer *trace.Tracer) (*Market, error) {
 	// Make sure the DEXArchivist is healthy before taking orders.
 	if err := storage.LastErr(); err != nil {
 		return nil, err
 	}
 
+	mktLog := dex.LoggerWithAttrs(log, dex.LogAttrMarket, mktInfo.Name)
+
 	// Load existing book orders from the DB.
 	base, quote := mktInfo.Base, mktInfo.Quote
 	bookOrders, err := storage.BookOrders(base, quote)
@@ -124,7 +167,7 @@ func NewMarket(mktInfo *dex.MarketInfo, storage db.DEXArchivist, swapper Swapper
 		return nil, err
 	}
 
-	log.Infof("Loaded %d stored book orders.", len(bookOrders))
+	mktLog.Infof("Loaded %d stored book orders.", len(bookOrders))
 	// Put the book orders in a map so orders that no longer have funding coins
 	// can be removed easily.
 	bookOrdersByID := make(map[order.OrderID]*order.LimitOrder, len(bookOrders))
@@ -166,7 +209,7 @@ ordersLoop:
 			if errors.Is(err, asset.CoinNotFoundError) {
 				// spent, exclude this order
 				coin, _ := asset.DecodeCoinID(dex.BipIDSymbol(assetID), lo.Coins[i]) // coin decoding succeeded in CheckUnspent
-				log.Warnf("Coin %s not unspent for unfilled order %v. "+
+				mktLog.Warnf("Coin %s not unspent for unfilled order %v. "+
 					"Revoking the order.", coin, lo)
 			} else {
 				// other failure (coinID decode, RPC, etc.)
@@ -179,7 +222,7 @@ ordersLoop:
 			delete(bookOrdersByID, id)
 			// Revoke the order, but do not count this against the user.
 			if _, _, err = storage.RevokeOrderUncounted(lo); err != nil {
-				log.Errorf("Failed to revoke order %v: %v", lo, err)
+				mktLog.Errorf("Failed to revoke order %v: %v", lo, err)
 			}
 			// No penalization here presently since the market was down, but if
 			// a suspend message with persist=true was sent, the users should
@@ -195,17 +238,17 @@ ordersLoop:
 		}
 	}
 
-	log.Debugf("Locking %d base asset (%d) coins.", len(baseCoins), base)
+	mktLog.Debugf("Locking %d base asset (%d) coins.", len(baseCoins), base)
 	if log.Level() <= slog.LevelTrace {
 		for oid, coins := range baseCoins {
-			log.Tracef(" - order %v: %v", oid, coins)
+			mktLog.Tracef(" - order %v: %v", oid, coins)
 		}
 	}
 
-	log.Debugf("Locking %d quote asset (%d) coins.", len(quoteCoins), quote)
+	mktLog.Debugf("Locking %d quote asset (%d) coins.", len(quoteCoins), quote)
 	if log.Level() <= slog.LevelTrace {
 		for oid, coins := range quoteCoins {
-			log.Tracef(" - order %v: %v", oid, coins)
+			mktLog.Tracef(" - order %v: %v", oid, coins)
 		}
 	}
 
@@ -218,12 +261,12 @@ ordersLoop:
 	}
 
 	for oid := range failedOrderCoins {
-		log.Warnf("Revoking book order %v with already locked coins.", oid)
+		mktLog.Warnf("Revoking book order %v with already locked coins.", oid)
 		bad := bookOrdersByID[oid]
 		delete(bookOrdersByID, oid)
 		// Revoke the order, but do not count this against the user.
 		if _, _, err = storage.RevokeOrderUncounted(bad); err != nil {
-			log.Errorf("Failed to revoke order %v: %v", bad, err)
+			mktLog.Errorf("Failed to revoke order %v: %v", bad, err)
 			// But still not added back on the book.
 		}
 	}
@@ -235,11 +278,11 @@ ordersLoop:
 			// To change market configuration, the operator should suspended the
 			// market with persist=false, but that may not have happened, or
 			// maybe a revoke failed.
-			log.Errorf("Not rebooking order %v with amount (%v/%v) incompatible with current lot size (%v)",
+			mktLog.Errorf("Not rebooking order %v with amount (%v/%v) incompatible with current lot size (%v)",
 				lo.FillAmt, lo.Quantity, mktInfo.LotSize)
 			// Revoke the order, but do not count this against the user.
 			if _, _, err = storage.RevokeOrderUncounted(lo); err != nil {
-				log.Errorf("Failed to revoke order %v: %v", lo, err)
+				mktLog.Errorf("Failed to revoke order %v: %v", lo, err)
 				// But still not added back on the book.
 			}
 			continue
@@ -248,7 +291,7 @@ ordersLoop:
 		if ok := Book.Insert(lo); !ok {
 			// This can only happen if one of the loaded orders has an
 			// incompatible lot size for the current market config.
-			log.Errorf("Failed to insert order %v into %v book.", mktInfo.Name, lo)
+			mktLog.Errorf("Failed to insert order %v into %v book.", mktInfo.Name, lo)
 		}
 	}
 
@@ -256,7 +299,7 @@ ordersLoop:
 		running:          make(chan struct{}), // closed on market start
 		marketInfo:       mktInfo,
 		book:             Book,
-		matcher:          matcher.New(),
+		matcher:          matcher.NewWithTradeFee(mktInfo.TradeFee),
 		persistBook:      true,
 		epochCommitments: make(map[order.Commitment]order.OrderID),
 		epochOrders:      make(map[order.OrderID]order.Order),
@@ -265,6 +308,8 @@ ordersLoop:
 		storage:          storage,
 		coinLockerBase:   coinLockerBase,
 		coinLockerQuote:  coinLockerQuote,
+		tracer:           tracer,
+		log:              mktLog,
 	}, nil
 }
 
@@ -325,6 +370,37 @@ func (m *Market) Suspend(asSoonAs time.Time, persistBook bool) (finalEpochIdx in
 	return
 }
 
+// Resume schedules a suspended market to start cycling epochs again at the
+// first epoch that starts at or after asSoonAs, or at the next epoch. The
+// scheduled start epoch and its start time are returned. Run must be called
+// again to resume the market. ok is false unless the market's suspension is
+// complete.
+func (m *Market) Resume(asSoonAs time.Time) (startEpochIdx int64, startEpochTime time.Time, ok bool) {
+	if m.Running() {
+		return -1, time.Time{}, false
+	}
+	m.epochMtx.Lock()
+	defer m.epochMtx.Unlock()
+
+	dur := int64(m.EpochDuration())
+	nextIdx := 1 + encode.UnixMilli(time.Now())/dur
+	// The market must have run through its final epoch.
+	if m.suspendEpochIdx == 0 || m.suspendEpochIdx >= nextIdx-1 {
+		return -1, time.Time{}, false
+	}
+
+	startEpochIdx = nextIdx
+	ms := encode.UnixMilli(asSoonAs)
+	if idx := (ms + dur - 1) / dur; idx > startEpochIdx {
+		startEpochIdx = idx
+	}
+	m.startEpochIdx = startEpochIdx
+	m.suspendEpochIdx = 0
+	m.persistBook = true
+
+	return startEpochIdx, encode.UnixTimeMilli(startEpochIdx * dur), true
+}
+
 // SetStartEpochIdx sets the starting epoch index. This should generally be
 // called before Run, or Start used to specify the index at the same time.
 func (m *Market) SetStartEpochIdx(startEpochIdx int64) {
@@ -356,10 +432,22 @@ type Status struct {
 	StartEpoch    int64
 	SuspendEpoch  int64
 	PersistBook   bool
+	// EpochBacklog is the number of closed epochs waiting to be matched.
+	EpochBacklog int
+	// ShedOrders is the number of orders refused because the market was
+	// overloaded.
+	ShedOrders uint64
+	// MinOrderQty is the current minimum quantity of an order in units of
+	// the base asset, from the market's minimum order value. Zero means
+	// there is no minimum beyond the lot size.
+	MinOrderQty uint64
 }
 
 // Status returns the current operating state of the Market.
 func (m *Market) Status() *Status {
+	m.loadMtx.Lock()
+	backlog, shed := m.epochBacklog, m.shedOrders
+	m.loadMtx.Unlock()
 	m.epochMtx.Lock()
 	defer m.epochMtx.Unlock()
 	return &Status{
@@ -369,9 +457,20 @@ func (m *Market) Status() *Status {
 		StartEpoch:    m.startEpochIdx,
 		SuspendEpoch:  m.suspendEpochIdx,
 		PersistBook:   m.persistBook,
+		EpochBacklog:  backlog,
+		ShedOrders:    shed,
+		MinOrderQty:   m.MinOrderQty(),
 	}
 }
 
+// SuspendEpoch returns the final epoch index of the market's most recent
+// suspension, or zero if no suspension has been scheduled.
+func (m *Market) SuspendEpoch() int64 {
+	m.epochMtx.RLock()
+	defer m.epochMtx.RUnlock()
+	return m.suspendEpochIdx
+}
+
 // Running indicates is the market is accepting new orders. This will return
 // false when suspended, but false does not necessarily mean Run has stopped
 // since a start epoch may be set. Note that this method is of limited use and
@@ -402,6 +501,19 @@ func (m *Market) MarketBuyBuffer() float64 {
 	return m.marketInfo.MarketBuyBuffer
 }
 
+// TradeFee returns the Market's trade fee rate in basis points.
+func (m *Market) TradeFee() uint32 {
+	return m.marketInfo.TradeFee
+}
+
+// OddLotPolicy returns the Market's treatment of odd lots.
+func (m *Market) OddLotPolicy() dex.OddLotPolicy {
+	if m.marketInfo.OddLotPolicy == "" {
+		return dex.OddLotKeep
+	}
+	return m.marketInfo.OddLotPolicy
+}
+
 // Base is the base asset ID.
 func (m *Market) Base() uint32 {
 	return m.marketInfo.Base
@@ -484,7 +596,7 @@ func (m *Market) SubmitOrderAsync(rec *orderRecord) <-chan error {
 	// putting it on the queue.
 	if err := m.validateOrder(rec.order); err != nil {
 		// Order ID cannot be computed since ServerTime has not been set.
-		log.Debugf("SubmitOrderAsync: Invalid order received from user %v with commitment %v: %v",
+		dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, rec.order.User()).Debugf("SubmitOrderAsync: Invalid order received from user %v with commitment %v: %v",
 			rec.order.User(), rec.order.Commitment(), err)
 		return sendErr(err)
 	}
@@ -497,15 +609,21 @@ func (m *Market) SubmitOrderAsync(rec *orderRecord) <-chan error {
 	case <-m.running:
 	default:
 		// m.orderRouter is closed
-		log.Infof("SubmitOrderAsync: Market stopped with an order in submission (commitment %v).",
+		m.log.Infof("SubmitOrderAsync: Market stopped with an order in submission (commitment %v).",
 			rec.order.Commitment()) // The order is not time stamped, so no OrderID.
 		return sendErr(ErrMarketNotRunning)
 	}
 
 	sig := newOrderUpdateSignal(rec)
 	// The lock is still held, so there is a receiver: either Run's main loop or
-	// the drain in Run's defer that runs until m.running starts blocking.
-	m.orderRouter <- sig
+	// the drain in Run's defer that runs until m.running starts blocking. If
+	// the order queue is full, the market is not keeping up, and the order is
+	// refused rather than blocking the submitter.
+	select {
+	case m.orderRouter <- sig:
+	default:
+		return sendErr(m.shed(rec.order, "order queue full"))
+	}
 	return sig.errChan
 }
 
@@ -595,6 +713,17 @@ func (m *Market) Book() (epoch int64, buys, sells []*order.LimitOrder) {
 	return
 }
 
+// Depth retrieves the n best price levels on each side of the market's book,
+// and the current epoch index as for Book. All levels are returned if n <= 0.
+func (m *Market) Depth(n int) (epoch int64, buys, sells []book.PriceLevel) {
+	m.bookMtx.Lock()
+	buys = m.book.BuyLevels(n)
+	sells = m.book.SellLevels(n)
+	epoch = m.bookEpochIdx
+	m.bookMtx.Unlock()
+	return
+}
+
 // PurgeBook flushes all booked orders from the in-memory book and persistent
 // storage. In terms of storage, this means changing orders with status booked
 // to status revoked.
@@ -605,11 +734,18 @@ func (m *Market) PurgeBook() {
 	// Revoke all booked orders in the DB.
 	sellsRemoved, buysRemoved, err := m.storage.FlushBook(m.marketInfo.Base, m.marketInfo.Quote)
 	if err != nil {
-		log.Errorf("Failed to flush book for market %s: %v", m.marketInfo.Name, err)
+		m.log.Errorf("Failed to flush book for market %s: %v", m.marketInfo.Name, err)
 	} else {
-		log.Infof("Flushed %d sell orders and %d buy orders from market %q book",
+		m.log.Infof("Flushed %d sell orders and %d buy orders from market %q book",
 			len(sellsRemoved), len(buysRemoved), m.marketInfo.Name)
 		// Clear the in-memory order book to match the DB.
+		if m.recorder != nil {
+			var oids []order.OrderID
+			for _, lo := range append(m.book.SellOrders(), m.book.BuyOrders()...) {
+				oids = append(oids, lo.ID())
+			}
+			m.recordUnbook(oids)
+		}
 		m.book.Clear()
 		// Unlock coins for removed orders.
 
@@ -666,12 +802,16 @@ func (m *Market) Run(ctx context.Context) {
 		}
 		m.running = make(chan struct{})
 		running = false
+		m.pump = nil
 		close(m.orderRouter) // stop the order router drain
 		m.runMtx.Unlock()
 
 		// Stop and wait for epoch pump and processing pipeline goroutines.
 		cancel() // may already be done by suspend
 		wgEpochs.Wait()
+		m.loadMtx.Lock()
+		m.epochBacklog = 0
+		m.loadMtx.Unlock()
 
 		// Stop and wait for the order feed goroutine.
 		close(notifyChan)
@@ -688,7 +828,7 @@ func (m *Market) Run(ctx context.Context) {
 		}
 		m.epochMtx.RUnlock()
 
-		log.Infof("Market %q stopped.", m.marketInfo.Name)
+		m.log.Infof("Market %q stopped.", m.marketInfo.Name)
 	}()
 
 	// Start outgoing order feed notification goroutine.
@@ -703,6 +843,9 @@ func (m *Market) Run(ctx context.Context) {
 	// Start the closed epoch pump, which drives preimage collection and orderly
 	// epoch processing.
 	eq := newEpochPump()
+	m.runMtx.Lock()
+	m.pump = eq
+	m.runMtx.Unlock()
 	wgEpochs.Add(1)
 	go func() {
 		defer wgEpochs.Done()
@@ -716,15 +859,34 @@ func (m *Market) Run(ctx context.Context) {
 		for ep := range eq.ready {
 			// epochStart has completed preimage collection.
 			m.processReadyEpoch(ep, notifyChan)
+			m.epochProcessed()
 		}
-		log.Debugf("epoch pump drained for market %s", m.marketInfo.Name)
+		m.log.Debugf("epoch pump drained for market %s", m.marketInfo.Name)
 		// There must be no more notify calls.
 	}()
 
+	// Keep the minimum order quantity current with the spot rate.
+	if m.MinOrderValue() > 0 {
+		wgEpochs.Add(1)
+		go func() {
+			defer wgEpochs.Done()
+			m.minOrderQtyLoop(ctxRun)
+		}()
+	}
+
+	// Revoke booked orders that have exceeded the maximum order age.
+	if m.MaxOrderAge() > 0 {
+		wgEpochs.Add(1)
+		go func() {
+			defer wgEpochs.Done()
+			m.purgeStaleLoop(ctxRun)
+		}()
+	}
+
 	m.epochMtx.Lock()
 	nextEpochIdx := m.startEpochIdx
 	if nextEpochIdx == 0 {
-		log.Warnf("Run: startEpochIdx not set. Starting at the next epoch.")
+		m.log.Warnf("Run: startEpochIdx not set. Starting at the next epoch.")
 		now := encode.UnixMilli(time.Now())
 		nextEpochIdx = 1 + now/int64(m.EpochDuration())
 		m.startEpochIdx = nextEpochIdx
@@ -797,7 +959,7 @@ func (m *Market) Run(ctx context.Context) {
 	// Set the orderRouter field now since the main loop below receives on it,
 	// even though SubmitOrderAsync disallows sends on orderRouter when the
 	// market is not running.
-	m.orderRouter = make(chan *orderUpdateSignal, 32) // implicitly guarded by m.runMtx since Market is not running yet
+	m.orderRouter = make(chan *orderUpdateSignal, m.orderQueueSize()) // implicitly guarded by m.runMtx since Market is not running yet
 
 	for {
 		if ctxRun.Err() != nil {
@@ -805,7 +967,7 @@ func (m *Market) Run(ctx context.Context) {
 		}
 
 		if err := m.storage.LastErr(); err != nil {
-			log.Criticalf("Archivist failing. Last unexpected error: %v", err)
+			m.log.Criticalf("Archivist failing. Last unexpected error: %v", err)
 			return
 		}
 
@@ -829,7 +991,7 @@ func (m *Market) Run(ctx context.Context) {
 		case s := <-m.orderRouter:
 			if currentEpoch == nil {
 				// The order is not time-stamped yet, so the ID cannot be computed.
-				log.Debugf("Order type %v received prior to market start.", s.rec.order.Type())
+				m.log.Debugf("Order type %v received prior to market start.", s.rec.order.Type())
 				s.errChan <- ErrMarketNotRunning
 				continue
 			}
@@ -837,7 +999,7 @@ func (m *Market) Run(ctx context.Context) {
 			// Set the order's server time stamp, giving the order a valid ID.
 			sTime := time.Now().Truncate(time.Millisecond).UTC()
 			s.rec.order.SetTime(sTime) // Order.ID()/UID()/String() is OK now.
-			log.Tracef("Received order %v at %v", s.rec.order, sTime)
+			m.log.Tracef("Received order %v at %v", s.rec.order, sTime)
 
 			// Push the order into the next epoch if receiving and stamping it
 			// took just a little too long.
@@ -846,21 +1008,27 @@ func (m *Market) Run(ctx context.Context) {
 			case currentEpoch.IncludesTime(sTime):
 				orderEpoch = currentEpoch
 			case nextEpoch.IncludesTime(sTime):
-				log.Infof("Order %v (sTime=%d) fell into the next epoch [%d,%d)",
+				m.log.Infof("Order %v (sTime=%d) fell into the next epoch [%d,%d)",
 					s.rec.order, sTime.UnixNano(), nextEpoch.Start.Unix(), nextEpoch.End.Unix())
 				orderEpoch = nextEpoch
 			default:
 				// This should not happen.
-				log.Errorf("Time %d does not fit into current or next epoch!",
+				m.log.Errorf("Time %d does not fit into current or next epoch!",
 					sTime.UnixNano())
 				s.errChan <- ErrEpochMissed
 				continue
 			}
 
+			// Refuse trade orders if the market is not keeping up.
+			if err := m.checkLoad(s.rec.order, orderEpoch); err != nil {
+				s.errChan <- err
+				continue
+			}
+
 			// Stamp and process the order in the target epoch queue.
 			err := m.processOrder(s.rec, orderEpoch, notifyChan, s.errChan)
 			if err != nil {
-				log.Errorf("Failed to process order %v: %v", s.rec.order, err)
+				m.log.Errorf("Failed to process order %v: %v", s.rec.order, err)
 				// Signal to the other Run goroutines to return.
 				return
 			}
@@ -933,7 +1101,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	if rec.order.Type() != order.CancelOrderType {
 		// Do not bother the auth manager for cancel orders.
 		if _, suspended := m.auth.Suspended(rec.order.User()); suspended {
-			log.Debugf("Account %v not allowed to submit order %v", rec.order.User(), rec.order.ID())
+			dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, rec.order.User()).Debugf("Account %v not allowed to submit order %v", rec.order.User(), rec.order.ID())
 			errChan <- ErrSuspendedAccount
 			return nil
 		}
@@ -949,7 +1117,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	otherOid, found := m.epochCommitments[commit]
 	m.epochMtx.RUnlock()
 	if found {
-		log.Debugf("Received order %v with commitment %x also used in previous order %v!",
+		m.log.Debugf("Received order %v with commitment %x also used in previous order %v!",
 			ord, commit, otherOid)
 		errChan <- ErrInvalidCommitment
 		return nil
@@ -960,7 +1128,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	// other orders is prevented by the coinlocker.
 	if co, ok := ord.(*order.CancelOrder); ok {
 		if eco := epoch.CancelTargets[co.TargetOrderID]; eco != nil {
-			log.Debugf("Received cancel order %v targeting %v, but already have %v.",
+			m.log.Debugf("Received cancel order %v targeting %v, but already have %v.",
 				co, co.TargetOrderID, eco)
 			errChan <- ErrDuplicateCancelOrder
 			return nil
@@ -970,7 +1138,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 		// and that the account of the CancelOrder is the same as the account of
 		// the target order.
 		if !m.CancelableBy(co.TargetOrderID, co.AccountID) {
-			log.Debugf("Cancel order %v (account=%v) does not own target order %v.",
+			m.log.Debugf("Cancel order %v (account=%v) does not own target order %v.",
 				co, co.AccountID, co.TargetOrderID)
 			errChan <- ErrInvalidCancelOrder
 			return nil
@@ -982,7 +1150,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	// for processing.
 	respMsg, err := m.orderResponse(rec)
 	if err != nil {
-		log.Errorf("failed to create msgjson.Message for order %v, msgID %v response: %v",
+		m.log.Errorf("failed to create msgjson.Message for order %v, msgID %v response: %v",
 			rec.order, rec.msgID, err)
 		errChan <- ErrMalformedOrderResponse
 		return nil
@@ -991,9 +1159,9 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	// Ensure that the received order does not use locked coins.
 	lockedCoins := m.coinsLocked(ord)
 	if len(lockedCoins) > 0 {
-		log.Debugf("processOrder: Order %v submitted with already-locked coins: %v",
+		m.log.Debugf("processOrder: Order %v submitted with already-locked coins: %v",
 			ord, lockedCoins)
-		errChan <- ErrInvalidOrder
+		errChan <- ErrCoinsLocked
 		return nil
 	}
 
@@ -1015,7 +1183,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	// 	return fmt.Errorf("processOrder: Failed to query for orders by commitment: %v", err)
 	// }
 	// if commitFound {
-	// 	log.Debugf("processOrder: Order %v submitted with reused commitment %v "+
+	// 	m.log.Debugf("processOrder: Order %v submitted with reused commitment %v "+
 	// 		"from previous order %v", ord, commit, prevOrderID)
 	// 	errChan <- ErrInvalidCommitment
 	// 	return nil
@@ -1038,6 +1206,10 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	m.epochCommitments[commit] = oid
 	m.epochMtx.Unlock()
 
+	m.tracer.Link(rec.cid, oid.String())
+	m.tracer.KeyLogf(log, "MKT", oid.String(), "%s order received into %s epoch %d",
+		ord.Type(), m.marketInfo.Name, epoch.Epoch)
+
 	// Respond to the order router only after updating epochOrders so that
 	// Cancelable will reflect that the order is now in the epoch queue.
 	errChan <- nil
@@ -1046,7 +1218,7 @@ func (m *Market) processOrder(rec *orderRecord, epoch *EpochQueue, notifyChan ch
 	// inserted into the current epoch queue.
 	user := ord.User()
 	m.auth.SendWhenConnected(user, respMsg, DefaultConnectTimeout, func() {
-		log.Infof("Failed to send signed new order response to disconnected user %v, order %v",
+		dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, user).Infof("Failed to send signed new order response to disconnected user %v, order %v",
 			user, oid)
 		// The user may not respond to preimage requests...
 	})
@@ -1070,16 +1242,16 @@ func idToBytes(id [order.OrderIDSize]byte) []byte {
 
 // respondError sends an rpcError to a user.
 func (m *Market) respondError(id uint64, user account.AccountID, code int, errMsg string) {
-	log.Debugf("sending error to user %v, code: %d, msg: %s", user, code, errMsg)
+	dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, user).Debugf("sending error to user %v, code: %d, msg: %s", user, code, errMsg)
 	msg, err := msgjson.NewResponse(id, nil, &msgjson.Error{
 		Code:    code,
 		Message: errMsg,
 	})
 	if err != nil {
-		log.Errorf("error creating error response with message '%s': %v", msg, err)
+		m.log.Errorf("error creating error response with message '%s': %v", msg, err)
 	}
 	m.auth.SendWhenConnected(user, msg, DefaultConnectTimeout, func() {
-		log.Infof("Unable to send error response (code %d) to disconnected user %v: %q",
+		dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, user).Infof("Unable to send error response (code %d) to disconnected user %v: %q",
 			code, user, errMsg)
 	})
 }
@@ -1088,11 +1260,17 @@ func (m *Market) respondError(id uint64, user account.AccountID, code int, errMs
 type piData struct {
 	ord      order.Order
 	preimage chan *order.Preimage
+	sent     time.Time
+	// rtt is the round-trip time of the client's response, set by
+	// handlePreimageResp before sending on the preimage channel. It is zero if
+	// the client did not respond.
+	rtt time.Duration
 }
 
 // handlePreimageResp is to be used in the response callback function provided
 // to AuthManager.Request for the preimage route.
 func (m *Market) handlePreimageResp(msg *msgjson.Message, reqData *piData) {
+	reqData.rtt = time.Since(reqData.sent)
 	sendPI := func(pi *order.Preimage) {
 		reqData.preimage <- pi
 	}
@@ -1134,10 +1312,10 @@ func (m *Market) handlePreimageResp(msg *msgjson.Message, reqData *piData) {
 	}
 
 	// The preimage is good.
-	log.Tracef("Good preimage received for order %v: %x", reqData.ord, pi)
+	m.log.Tracef("Good preimage received for order %v: %x", reqData.ord, pi)
 	err = m.storage.StorePreimage(reqData.ord, pi)
 	if err != nil {
-		log.Errorf("StorePreimage: %v", err)
+		m.log.Errorf("StorePreimage: %v", err)
 		// Fatal backend error. New swaps will not begin, but pass the preimage
 		// along so it does not appear as a miss to collectPreimages.
 		m.respondError(msg.ID, reqData.ord.User(), msgjson.UnknownMarketError, "internal server error")
@@ -1151,14 +1329,16 @@ func (m *Market) handlePreimageResp(msg *msgjson.Message, reqData *piData) {
 // and returns the preimages contained in the client responses. This function
 // can block for up to 5 seconds (piTimeout) to allow clients time to respond.
 // Clients that fail to respond, or respond with invalid data (see
-// handlePreimageResp), are counted as misses.
-func (m *Market) collectPreimages(orders []order.Order) (cSum []byte, ordersRevealed []*matcher.OrderRevealed, misses []order.Order) {
+// handlePreimageResp), are counted as misses. If force is closed, collection
+// ends immediately, and the orders with outstanding preimage requests are
+// returned as cut.
+func (m *Market) collectPreimages(orders []order.Order, force <-chan struct{}) (cSum []byte, ordersRevealed []*matcher.OrderRevealed, misses, cut []order.Order) {
 	// Compute the commitment checksum for the order queue.
 	cSum = matcher.CSum(orders)
 
 	// Request preimages from the clients.
 	piTimeout := 5 * time.Second
-	preimages := make(map[order.Order]chan *order.Preimage, len(orders))
+	preimages := make(map[order.Order]*piData, len(orders))
 	for _, ord := range orders {
 		// Make the 'preimage' request.
 		piReqParams := &msgjson.PreimageRequest{
@@ -1169,7 +1349,7 @@ func (m *Market) collectPreimages(orders []order.Order) (cSum []byte, ordersReve
 		if err != nil {
 			// This is likely an impossible condition, but it's not the client's
 			// fault.
-			log.Errorf("error creating preimage request: %v", err)
+			m.log.Errorf("error creating preimage request: %v", err)
 			// TODO: respond to client with server error.
 			continue
 		}
@@ -1182,6 +1362,7 @@ func (m *Market) collectPreimages(orders []order.Order) (cSum []byte, ordersReve
 		reqData := &piData{
 			ord:      ord,
 			preimage: piChan,
+			sent:     time.Now(),
 		}
 
 		// Failure to respond in time is a miss, signalled by a nil pointer.
@@ -1201,25 +1382,37 @@ func (m *Market) collectPreimages(orders []order.Order) (cSum []byte, ordersReve
 			miss() // only called by RequestWithTimeout on expire, not send errors
 			if errors.Is(err, ws.ErrPeerDisconnected) {
 				misses = append(misses, ord)
-				log.Debug("Preimage request failed: client gone.")
+				m.recordPreimageMiss(ord, db.PreimageDisconnected, 0)
+				m.log.Debug("Preimage request failed: client gone.")
 			} else {
 				// Server error should not count as a miss. We may need a way to
 				// identify server connectivity problems to clients are not
 				// penalized when it is not their fault.
-				log.Warnf("Preimage request failed: %v", err) // maybe Debugf if there is nothing unexpected
+				m.log.Warnf("Preimage request failed: %v", err) // maybe Debugf if there is nothing unexpected
 			}
 			continue
 		}
 
-		log.Tracef("Preimage request sent for order %v", ord)
-		preimages[ord] = piChan
+		m.log.Tracef("Preimage request sent for order %v", ord)
+		preimages[ord] = reqData
 	}
 
 	// Receive preimages from response channels.
-	for ord, pic := range preimages {
-		pi := <-pic
+	for ord, reqData := range preimages {
+		var pi *order.Preimage
+		select {
+		case pi = <-reqData.preimage:
+		case <-force:
+			cut = append(cut, ord)
+			continue
+		}
 		if pi == nil {
 			misses = append(misses, ord)
+			if reqData.rtt > 0 {
+				m.recordPreimageMiss(ord, db.PreimageInvalid, reqData.rtt)
+			} else {
+				m.recordPreimageMiss(ord, db.PreimageTimeout, 0)
+			}
 		} else {
 			ordersRevealed = append(ordersRevealed, &matcher.OrderRevealed{
 				Order:    ord,
@@ -1231,15 +1424,49 @@ func (m *Market) collectPreimages(orders []order.Order) (cSum []byte, ordersReve
 	return
 }
 
+// recordPreimageMiss stores the circumstances of a missed preimage request for
+// later review by the operator. The client's connection state is checked now,
+// when the miss is determined. If the client did not respond (rtt is zero), the
+// round-trip time of their most recent response to any request is recorded
+// instead.
+func (m *Market) recordPreimageMiss(ord order.Order, reason db.PreimageMissReason, rtt time.Duration) {
+	connected, lastRTT := m.auth.ConnState(ord.User())
+	if rtt == 0 {
+		rtt = lastRTT
+	}
+	latency := rtt.Milliseconds()
+	if latency == 0 && rtt > 0 {
+		latency = 1 // zero means not measured
+	}
+	epochDur := int64(m.EpochDuration())
+	miss := &db.PreimageMiss{
+		AccountID:  ord.User(),
+		OrderID:    ord.ID(),
+		Commitment: ord.Commitment(),
+		Base:       m.marketInfo.Base,
+		Quote:      m.marketInfo.Quote,
+		EpochIdx:   ord.Time() / epochDur,
+		EpochDur:   epochDur,
+		Reason:     reason,
+		Connected:  connected,
+		Latency:    latency,
+		Stamp:      encode.UnixMilli(time.Now()),
+	}
+	if err := m.storage.StorePreimageMiss(miss); err != nil {
+		m.log.Errorf("Failed to record preimage miss for order %v: %v", ord.ID(), err)
+	}
+}
+
 func (m *Market) enqueueEpoch(eq *epochPump, epoch *EpochQueue) bool {
 	// Enqueue the epoch for matching when preimage collection is completed and
 	// it is this epoch's turn.
 	rq := eq.Insert(epoch)
 	if rq == nil {
 		// should not happen if cycleEpoch considers when the halt began.
-		log.Errorf("failed to enqueue an epoch into a halted epoch pump")
+		m.log.Errorf("failed to enqueue an epoch into a halted epoch pump")
 		return false
 	}
+	m.epochClosed()
 
 	// With this epoch closed, these orders are no longer cancelable, if and
 	// until they are booked in processReadyEpoch (after preimage collection).
@@ -1261,7 +1488,18 @@ func (m *Market) enqueueEpoch(eq *epochPump, epoch *EpochQueue) bool {
 
 	// Start preimage collection.
 	go func() {
-		rq.cSum, rq.ordersRevealed, rq.misses = m.epochStart(orders)
+		start := time.Now()
+		rq.cSum, rq.ordersRevealed, rq.misses, rq.cut = m.epochStart(orders, rq.force)
+		if rq.forced() {
+			if rq.skip {
+				for _, or := range rq.ordersRevealed {
+					rq.cut = append(rq.cut, or.Order)
+				}
+				rq.ordersRevealed = nil
+			}
+			m.revokeCut(rq.Epoch, rq.cut)
+		}
+		rq.collectTime = time.Since(start)
 		close(rq.ready)
 	}()
 
@@ -1269,20 +1507,23 @@ func (m *Market) enqueueEpoch(eq *epochPump, epoch *EpochQueue) bool {
 }
 
 // epochStart collects order preimages, and penalizes users who fail to respond.
-func (m *Market) epochStart(orders []order.Order) (cSum []byte, ordersRevealed []*matcher.OrderRevealed, misses []order.Order) {
+// If force is closed before collection is complete, the orders with preimages
+// still outstanding are returned as cut, and their users are not penalized.
+func (m *Market) epochStart(orders []order.Order, force <-chan struct{}) (cSum []byte, ordersRevealed []*matcher.OrderRevealed, misses, cut []order.Order) {
 	// Solicit the preimages for each order.
-	cSum, ordersRevealed, misses = m.collectPreimages(orders)
+	cSum, ordersRevealed, misses, cut = m.collectPreimages(orders, force)
 	if len(orders) > 0 {
-		log.Infof("Collected %d valid order preimages, missed %d. Commit checksum: %x",
+		m.log.Infof("Collected %d valid order preimages, missed %d. Commit checksum: %x",
 			len(ordersRevealed), len(misses), cSum)
 	}
 
 	// Penalize accounts with misses. TODO: consider if Penalize can be an async
 	// function call.
 	for _, ord := range misses {
-		log.Infof("No preimage received for order %v from user %v. Penalizing user and revoking order.",
+		dex.LoggerWithAttrs(m.log, dex.LogAttrAccount, ord.User()).Infof("No preimage received for order %v from user %v. Penalizing user and revoking order.",
 			ord.ID(), ord.User())
-		m.auth.Penalize(ord.User(), account.PreimageReveal)
+		m.auth.Penalize(ord.User(), account.PreimageReveal,
+			fmt.Sprintf("no preimage revealed for order %v in market %s", ord.ID(), m.marketInfo.Name))
 		// Unlock the order's coins locked in processOrder.
 		m.unlockOrderCoins(ord) // could also be done in processReadyEpoch
 		// Change the order status from orderStatusEpoch to orderStatusRevoked.
@@ -1290,7 +1531,7 @@ func (m *Market) epochStart(orders []order.Order) (cSum []byte, ordersRevealed [
 		if err == nil {
 			m.auth.RecordCancel(ord.User(), coid, ord.ID(), revTime)
 		} else {
-			log.Errorf("Failed to revoke order %v with a new cancel order: %v",
+			m.log.Errorf("Failed to revoke order %v with a new cancel order: %v",
 				ord.UID(), err)
 		}
 	}
@@ -1298,6 +1539,34 @@ func (m *Market) epochStart(orders []order.Order) (cSum []byte, ordersRevealed [
 	return
 }
 
+// Record begins recording the market's order flow for offline replay. The
+// current book is recorded first, followed by the queue and matching results
+// of every epoch with orders, and any orders removed from the book outside of
+// matching. Recording stops if a write fails.
+func (m *Market) Record(rec *replay.Recorder) error {
+	m.bookMtx.Lock()
+	defer m.bookMtx.Unlock()
+	orders := append(m.book.SellOrders(), m.book.BuyOrders()...)
+	if err := rec.RecordBook(m.marketInfo.Name, m.marketInfo.LotSize, orders); err != nil {
+		return err
+	}
+	m.recorder = rec
+	return nil
+}
+
+// recordUnbook records orders removed from the book outside of matching. The
+// bookMtx must be locked.
+func (m *Market) recordUnbook(oids []order.OrderID) {
+	if m.recorder == nil {
+		return
+	}
+	if err := m.recorder.RecordUnbook(oids); err != nil {
+		m.log.Errorf("Failed to record unbooked orders for market %s, recording stopped: %v",
+			m.marketInfo.Name, err)
+		m.recorder = nil
+	}
+}
+
 // Unbook allows the DEX manager to remove a booked order. This does: (1) remove
 // the order from the in-memory book, (2) set the order's status in the DB to
 // "revoked", (3) inform the auth manager of the action for cancellation ratio
@@ -1308,6 +1577,9 @@ func (m *Market) Unbook(lo *order.LimitOrder) bool {
 	// Ensure we do not unbook during matching.
 	m.bookMtx.Lock()
 	_, removed := m.book.Remove(lo.ID())
+	if removed {
+		m.recordUnbook([]order.OrderID{lo.ID()})
+	}
 	m.bookMtx.Unlock()
 
 	m.unlockOrderCoins(lo)
@@ -1322,7 +1594,7 @@ func (m *Market) Unbook(lo *order.LimitOrder) bool {
 	if err == nil {
 		m.auth.RecordCancel(lo.User(), coid, lo.ID(), revTime)
 	} else {
-		log.Errorf("Failed to revoke order %v with a new cancel order: %v",
+		m.log.Errorf("Failed to revoke order %v with a new cancel order: %v",
 			lo.UID(), err)
 	}
 
@@ -1352,30 +1624,75 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 	select {
 	case <-epoch.ready:
 	default:
-		log.Criticalf("preimages not yet collected for epoch %d!", epoch.Epoch)
+		m.log.Criticalf("preimages not yet collected for epoch %d!", epoch.Epoch)
 		return // maybe panic
 	}
 
 	// Abort epoch processing if there was a fatal DB backend error during
 	// preimage collection.
 	if err := m.storage.LastErr(); err != nil {
-		log.Criticalf("aborting epoch processing on account of failing DB: %v", err)
+		m.log.Criticalf("aborting epoch processing on account of failing DB: %v", err)
 		return
 	}
 
-	// Data from preimage collection
+	// Data from preimage collection. Orders that were cut from a forced epoch
+	// were not revealed, and are reported as misses.
 	ordersRevealed := epoch.ordersRevealed
 	cSum := epoch.cSum
-	misses := epoch.misses
+	misses := append(epoch.misses, epoch.cut...)
+
+	// Time each stage of processing. The timings are only recorded if the
+	// epoch is processed completely.
+	timing := &EpochTiming{
+		Epoch:  epoch.Epoch,
+		Orders: len(epoch.Orders),
+	}
+	timing.Stages[StagePreimages] = epoch.collectTime
+	stamp := time.Now()
+	lap := func(stage int) {
+		now := time.Now()
+		timing.Stages[stage] += now.Sub(stamp)
+		stamp = now
+	}
 
 	// Perform order matching using the preimages to shuffle the queue.
-	m.bookMtx.Lock()        // allow a coherent view of book orders with (*Market).Book
+	m.bookMtx.Lock() // allow a coherent view of book orders with (*Market).Book
+	// The queue must be encoded for the recording before matching updates the
+	// fill amounts.
+	var recQueue []*replay.RevealedOrder
+	if m.recorder != nil && len(ordersRevealed) > 0 {
+		recQueue = replay.EncodeQueue(ordersRevealed)
+	}
+	// The odd-lot quantity is only changed with the bookMtx held, so this is
+	// the quantity used for matching.
+	oddLotQty := m.matcher.OddLotQty()
+	var epochRep *epochReplay
+	if len(ordersRevealed) > 0 {
+		epochRep = newEpochReplay(epoch.Epoch, epoch.Duration, oddLotQty, ordersRevealed)
+	}
 	matchTime := time.Now() // considered as the time at which matched cancel orders are executed
 	seed, matches, _, failed, doneOK, partial, booked, unbooked, updates := m.matcher.Match(m.book, ordersRevealed)
+	if epochRep != nil {
+		epochRep.setResults(ordersRevealed, matches, seed, updates)
+	}
+	if recQueue != nil {
+		err := m.recorder.RecordEpoch(epoch.Epoch, epoch.Duration, m.marketInfo.TradeFee, oddLotQty,
+			recQueue, seed, matches, updates)
+		if err != nil {
+			m.log.Errorf("Failed to record epoch %d for market %s, recording stopped: %v",
+				epoch.Epoch, m.marketInfo.Name, err)
+			m.recorder = nil
+		}
+	}
 	m.bookEpochIdx = epoch.Epoch + 1
 	m.bookMtx.Unlock()
+	if epochRep != nil {
+		m.storeEpochReplay(epochRep)
+	}
+	m.stats.record(matches, matchTime)
+	lap(StageMatching)
 	if len(ordersRevealed) > 0 {
-		log.Infof("Matching complete for market %v epoch %d:"+
+		m.log.Infof("Matching complete for market %v epoch %d:"+
 			" %d matches (%d partial fills), %d completed OK (not booked),"+
 			" %d booked, %d unbooked, %d failed",
 			m.marketInfo.Name, epoch.Epoch,
@@ -1429,6 +1746,7 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		if err = m.storage.BookOrder(lo); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", lo.ID().String(), "booked in epoch %d", epoch.Epoch)
 	}
 
 	// Book orders that were partially filled and remain on the books.
@@ -1436,6 +1754,7 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		if err = m.storage.UpdateOrderFilled(lo); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", lo.ID().String(), "partially filled, remaining on book in epoch %d", epoch.Epoch)
 	}
 
 	// Completed orders (includes epoch and formerly booked orders).
@@ -1443,12 +1762,14 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		if err = m.storage.ExecuteOrder(ord); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", ord.ID().String(), "executed in epoch %d", epoch.Epoch)
 	}
 	// Canceled orders.
 	for _, lo := range updates.TradesCanceled {
 		if err = m.storage.CancelOrder(lo); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", lo.ID().String(), "canceled in epoch %d", epoch.Epoch)
 	}
 	// Failed orders refer to epoch queue orders that are unmatched&unbooked, or
 	// had a bad lot size.
@@ -1456,6 +1777,7 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		if err = m.storage.ExecuteOrder(ord); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", ord.ID().String(), "failed to match in epoch %d", epoch.Epoch)
 	}
 
 	// Change cancel orders from epoch status to executed or failed status.
@@ -1463,11 +1785,24 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		if err = m.storage.FailCancelOrder(co); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", co.ID().String(), "cancel failed in epoch %d", epoch.Epoch)
 	}
 	for _, co := range updates.CancelsExecuted {
 		if err = m.storage.ExecuteOrder(co); err != nil {
 			return
 		}
+		m.tracer.KeyLogf(log, "MKT", co.ID().String(), "cancel executed in epoch %d", epoch.Epoch)
+	}
+	lap(StageDB)
+
+	// Standing limit orders that were completed with a remainder had an odd lot
+	// released by the matcher.
+	releasedOddLots := make(map[order.OrderID]bool)
+	for _, ord := range updates.TradesCompleted {
+		if lo, ok := ord.(*order.LimitOrder); ok && lo.Force == order.StandingTiF && lo.Remaining() > 0 {
+			releasedOddLots[lo.ID()] = true
+			m.log.Infof("Released odd lot of %d from order %v in epoch %d.", lo.Remaining(), lo.ID(), epoch.Epoch)
+		}
 	}
 
 	// The Swapper needs to know which orders it is processing are off the book
@@ -1478,7 +1813,7 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		// Non-limit orders are not on the book (!limit).
 		// Immediate force limits are not on the book.
 		// Standing limits with no remaining are not on the book.
-		return !limit || lo.Force == order.ImmediateTiF || lo.Remaining() == 0
+		return !limit || lo.Force == order.ImmediateTiF || lo.Remaining() == 0 || releasedOddLots[lo.ID()]
 		// Don't forget to check canceled orders too.
 	}
 
@@ -1523,6 +1858,7 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		},
 	}
 	notifyChan <- sig
+	lap(StageNotify)
 
 	// Unlock passed but not booked order (e.g. matched market and immediate
 	// orders) coins were locked upon order receipt in processOrder and must be
@@ -1581,12 +1917,17 @@ func (m *Market) processReadyEpoch(epoch *readyEpoch, notifyChan chan<- *updateS
 		}
 	}
 
+	lap(StageBook)
+
 	// Initiate the swaps.
 	if len(matches) > 0 {
-		log.Debugf("Negotiating %d matches for epoch %d:%d", len(matches),
+		m.log.Debugf("Negotiating %d matches for epoch %d:%d", len(matches),
 			epoch.Epoch, epoch.Duration)
 		m.swapper.Negotiate(matches, offBookOrders)
 	}
+	lap(StageNotify)
+
+	m.timings.record(timing)
 }
 
 // validateOrder uses db.ValidateOrder to ensure that the provided order is
@@ -27,6 +27,8 @@ import (
 	"decred.org/dcrdex/server/account"
 	"decred.org/dcrdex/server/coinlock"
 	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/replay"
 	"decred.org/dcrdex/server/swap"
 )
 
@@ -38,6 +40,8 @@ type TArchivist struct {
 	bookedOrders         []*order.LimitOrder
 	epochInserted        chan struct{}
 	revoked              order.Order
+	revokedUncounted     []order.Order
+	preimageMisses       []*db.PreimageMiss
 }
 
 func (ta *TArchivist) LastErr() error         { return nil }
@@ -101,6 +105,17 @@ func (ta *TArchivist) NewEpochOrder(ord order.Order, epochIdx, epochDur int64) e
 	return nil
 }
 func (ta *TArchivist) StorePreimage(ord order.Order, pi order.Preimage) error { return nil }
+func (ta *TArchivist) StorePreimageMiss(miss *db.PreimageMiss) error {
+	ta.mtx.Lock()
+	defer ta.mtx.Unlock()
+	ta.preimageMisses = append(ta.preimageMisses, miss)
+	return nil
+}
+func (ta *TArchivist) PreimageMisses(account.AccountID) ([]*db.PreimageMiss, error) {
+	ta.mtx.Lock()
+	defer ta.mtx.Unlock()
+	return ta.preimageMisses, nil
+}
 func (ta *TArchivist) failOnEpochOrder(ord order.Order) {
 	ta.mtx.Lock()
 	ta.poisonEpochOrder = ord
@@ -126,7 +141,8 @@ func (ta *TArchivist) RevokeOrder(ord order.Order) (order.OrderID, time.Time, er
 	ta.revoked = ord
 	return ord.ID(), time.Now(), nil
 }
-func (ta *TArchivist) RevokeOrderUncounted(order.Order) (order.OrderID, time.Time, error) {
+func (ta *TArchivist) RevokeOrderUncounted(ord order.Order) (order.OrderID, time.Time, error) {
+	ta.revokedUncounted = append(ta.revokedUncounted, ord)
 	return order.OrderID{}, time.Now(), nil
 }
 func (ta *TArchivist) SetOrderCompleteTime(ord order.Order, compTime int64) error { return nil }
@@ -140,6 +156,7 @@ func (ta *TArchivist) MatchByID(mid order.MatchID, base, quote uint32) (*db.Matc
 func (ta *TArchivist) UserMatches(aid account.AccountID, base, quote uint32) ([]*db.MatchData, error) {
 	return nil, nil
 }
+func (ta *TArchivist) CompletedMatches(int64, int64) ([]*db.CompletedMatch, error) { return nil, nil }
 func (ta *TArchivist) ActiveMatches(account.AccountID) ([]*order.UserMatch, error) {
 	return nil, nil
 }
@@ -182,7 +199,38 @@ func (ta *TArchivist) AccountRegAddr(account.AccountID) (string, error)   { retu
 func (ta *TArchivist) PayAccount(account.AccountID, []byte) error         { return nil }
 func (ta *TArchivist) Accounts() ([]*db.Account, error)                   { return nil, nil }
 func (ta *TArchivist) AccountInfo(account.AccountID) (*db.Account, error) { return nil, nil }
+func (ta *TArchivist) ForgiveAccount(account.AccountID) error             { return nil }
+func (ta *TArchivist) StoreAppeal(*db.Appeal) error                       { return nil }
+func (ta *TArchivist) Appeal(account.AccountID) (*db.Appeal, error)       { return nil, nil }
+func (ta *TArchivist) Appeals() ([]*db.Appeal, error)                     { return nil, nil }
 func (ta *TArchivist) Close() error                                       { return nil }
+func (ta *TArchivist) DecideAppeal(account.AccountID, db.AppealStatus, string) error {
+	return nil
+}
+func (ta *TArchivist) SetAccountNotes(account.AccountID, string, []string) error {
+	return nil
+}
+func (ta *TArchivist) RecordClientVersion(account.AccountID, string, uint16, int64) error {
+	return nil
+}
+func (ta *TArchivist) ClientVersions(account.AccountID) ([]*db.ClientVersion, error) {
+	return nil, nil
+}
+func (ta *TArchivist) ClientVersionStats(int64) ([]*db.ClientVersionStats, error) {
+	return nil, nil
+}
+func (ta *TArchivist) AcquireLease(string, string, time.Duration) (uint64, error) {
+	return 1, nil
+}
+func (ta *TArchivist) RenewLease(string, string, uint64, time.Duration) error {
+	return nil
+}
+func (ta *TArchivist) ReleaseLease(string, string, uint64) error {
+	return nil
+}
+func (ta *TArchivist) RecordTradeFee(*db.TradeFee) error                   { return nil }
+func (ta *TArchivist) FeeInvoices(int64, int64) ([]*db.FeeInvoice, error)  { return nil, nil }
+func (ta *TArchivist) TradeFees(account.AccountID) ([]*db.TradeFee, error) { return nil, nil }
 
 func randomOrderID() order.OrderID {
 	pk := randomBytes(order.OrderIDSize)
@@ -245,7 +293,7 @@ func newTestMarket(stor ...*TArchivist) (*Market, *TArchivist, *TAuth, func(), e
 	}
 
 	mkt, err := NewMarket(mktInfo, storage, swapper, authMgr,
-		bookLockerBase, bookLockerQuote)
+		bookLockerBase, bookLockerQuote, nil)
 	if err != nil {
 		return nil, nil, nil, func() {}, fmt.Errorf("Failed to create test market: %v", err)
 	}
@@ -522,6 +570,9 @@ func TestMarket_Suspend(t *testing.T) {
 	if !mkt.Running() {
 		t.Fatal("the market should have be running")
 	}
+	if _, _, ok := mkt.Resume(time.Now()); ok {
+		t.Fatal("a running market should not allow resume")
+	}
 
 	// Wait until after suspend time.
 	<-time.After(time.Until(finalTime.Add(20 * time.Millisecond)))
@@ -534,14 +585,28 @@ func TestMarket_Suspend(t *testing.T) {
 	wg.Wait()
 	mkt.FeedDone(feed)
 
-	// Start up again (consumer resumes the Market manually)
-	startEpochIdx = 1 + encode.UnixMilli(time.Now())/epochDurationMSec
-	startEpochTime = encode.UnixTimeMilli(startEpochIdx * epochDurationMSec)
+	// Start up again at the next epoch.
+	nowIdx := encode.UnixMilli(time.Now()) / epochDurationMSec
+	startEpochIdx, startEpochTime, ok := mkt.Resume(time.Time{})
+	if !ok {
+		t.Fatal("a suspended market should allow resume")
+	}
+	// The epoch may have changed since nowIdx was computed.
+	if (startEpochIdx != nowIdx+1 && startEpochIdx != nowIdx+2) ||
+		!startEpochTime.Equal(encode.UnixTimeMilli(startEpochIdx*epochDurationMSec)) {
+		t.Fatalf("got start epoch %d at %v, wanted %d", startEpochIdx, startEpochTime, nowIdx+1)
+	}
+	if status := mkt.Status(); status.StartEpoch != startEpochIdx || status.SuspendEpoch != 0 {
+		t.Fatalf("wrong status after resume: %+v", status)
+	}
+	if _, _, ok = mkt.Resume(time.Now()); ok {
+		t.Fatal("a resumed market should not allow resume")
+	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		mkt.Start(ctx, startEpochIdx)
+		mkt.Run(ctx)
 	}()
 
 	feed = mkt.OrderFeed()
@@ -1033,6 +1098,60 @@ func TestMarket_Run(t *testing.T) {
 	cleanup()
 }
 
+func TestMarket_collectPreimagesMisses(t *testing.T) {
+	mkt, storage, auth, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("Failed to create test market: %v", err)
+		return
+	}
+	defer cleanup()
+
+	lo, loPI := makeLORevealed(seller3, mkRate3(0.8, 1.0), randLots(10), order.StandingTiF)
+	// No preimage is registered for co, so the emulated client responds with
+	// a preimage that does not match the commitment.
+	co, _ := makeCORevealed(buyer3, randomOrderID())
+
+	auth.piMtx.Lock()
+	auth.preimagesByOrdID[lo.UID()] = loPI
+	auth.piMtx.Unlock()
+	auth.lastRTT = time.Second // should be ignored since the client responded
+
+	_, revealed, misses, cut := mkt.collectPreimages([]order.Order{lo, co}, make(chan struct{}))
+	if len(revealed) != 1 || revealed[0].Order.ID() != lo.ID() {
+		t.Fatalf("expected only the limit order to be revealed, got %d", len(revealed))
+	}
+	if len(misses) != 1 || misses[0].ID() != co.ID() {
+		t.Fatalf("expected only the cancel order to be missed, got %d", len(misses))
+	}
+	if len(cut) != 0 {
+		t.Fatalf("expected no cut orders, got %d", len(cut))
+	}
+
+	stored, _ := storage.PreimageMisses(co.User())
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 stored preimage miss, got %d", len(stored))
+	}
+	miss := stored[0]
+	if miss.OrderID != co.ID() || miss.Commitment != co.Commitment() || miss.AccountID != co.User() {
+		t.Errorf("wrong order recorded for preimage miss")
+	}
+	if miss.Reason != db.PreimageInvalid {
+		t.Errorf("expected reason %v, got %v", db.PreimageInvalid, miss.Reason)
+	}
+	if !miss.Connected {
+		t.Errorf("expected client to be recorded as connected")
+	}
+	if miss.Latency < clientPreimageDelay.Milliseconds() || miss.Latency >= time.Second.Milliseconds() {
+		t.Errorf("expected the response round-trip time to be recorded, got %d ms", miss.Latency)
+	}
+	if miss.Base != mkt.marketInfo.Base || miss.Quote != mkt.marketInfo.Quote {
+		t.Errorf("wrong market recorded for preimage miss")
+	}
+	if miss.EpochIdx != co.Time()/miss.EpochDur {
+		t.Errorf("wrong epoch recorded for preimage miss")
+	}
+}
+
 func TestMarket_enqueueEpoch(t *testing.T) {
 	// This tests processing of a closed epoch by epochStart (for preimage
 	// collection) and processReadyEpoch (for sending the expected book and
@@ -1278,6 +1397,132 @@ func TestMarket_enqueueEpoch(t *testing.T) {
 		})
 	}
 
+	// Every processed epoch is timed.
+	timings := mkt.Timings()
+	if len(timings.Recent) != len(tests) {
+		t.Fatalf("expected timings for %d epochs, got %d", len(tests), len(timings.Recent))
+	}
+	if timings.Recent[0].Orders != len(eq.Orders) || timings.Stages[StageMatching].Count != uint64(len(tests)) {
+		t.Errorf("wrong epoch timings")
+	}
+
+	cancel()
+}
+
+func TestMarket_ForceEpoch(t *testing.T) {
+	mkt, storage, auth, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("Failed to create test market: %v", err)
+	}
+	defer cleanup()
+
+	// Not running.
+	if _, err = mkt.ForceEpoch(0, false, true); !errors.Is(err, ErrMarketNotRunning) {
+		t.Fatalf("expected ErrMarketNotRunning, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ePump := newEpochPump()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ePump.Run(ctx)
+	}()
+	mkt.runMtx.Lock()
+	mkt.pump = ePump
+	mkt.runMtx.Unlock()
+	notifyChan := make(chan *updateSignal, 32)
+	defer close(notifyChan)
+	go func() {
+		for range notifyChan {
+		}
+	}()
+	processed := make(chan *readyEpoch, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ep := range ePump.ready {
+			mkt.processReadyEpoch(ep, notifyChan)
+			processed <- ep
+		}
+	}()
+
+	// Nothing stalled.
+	if _, err = mkt.ForceEpoch(0, false, true); !errors.Is(err, ErrNoStalledEpoch) {
+		t.Fatalf("expected ErrNoStalledEpoch, got %v", err)
+	}
+
+	auth.piMtx.Lock()
+	auth.piStall = true
+	auth.piMtx.Unlock()
+	var epochIdx, epochDur int64 = 123413513, int64(mkt.marketInfo.EpochDuration)
+	stallEpoch := func() (*EpochQueue, *order.LimitOrder) {
+		t.Helper()
+		eq := NewEpoch(epochIdx, epochDur)
+		lo := makeLO(seller3, mkRate3(1.0, 1.2), randLots(10), order.StandingTiF)
+		eq.Insert(lo)
+		mkt.enqueueEpoch(ePump, eq)
+		// Wait for the pump to block on the epoch.
+		for i := 0; i < 100 && ePump.waiting() == nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return eq, lo
+	}
+
+	eq, lo := stallEpoch()
+	fe, err := mkt.ForceEpoch(0, false, true)
+	if err != nil {
+		t.Fatalf("dry run error: %v", err)
+	}
+	if !fe.DryRun || fe.Epoch != eq.Epoch || fe.Orders != 1 {
+		t.Fatalf("unexpected dry run result %+v", fe)
+	}
+	select {
+	case <-processed:
+		t.Fatalf("epoch processed after dry run")
+	default:
+	}
+
+	// Wrong epoch index.
+	if _, err = mkt.ForceEpoch(eq.Epoch+1, false, false); err == nil {
+		t.Fatalf("no error for wrong epoch index")
+	}
+
+	fe, err = mkt.ForceEpoch(eq.Epoch, false, false)
+	if err != nil {
+		t.Fatalf("ForceEpoch error: %v", err)
+	}
+	if fe.Skipped || len(fe.Revoked) != 1 || fe.Revoked[0] != lo.ID() {
+		t.Fatalf("unexpected ForceEpoch result %+v", fe)
+	}
+	ep := <-processed
+	if ep.Epoch != eq.Epoch || len(ep.ordersRevealed) != 0 {
+		t.Fatalf("wrong epoch processed")
+	}
+	if len(storage.revokedUncounted) != 1 || storage.revokedUncounted[0].ID() != lo.ID() {
+		t.Fatalf("cut order not revoked")
+	}
+	auth.sendsMtx.Lock()
+	lastSend := auth.sends[len(auth.sends)-1]
+	auth.sendsMtx.Unlock()
+	if lastSend.Route != msgjson.RevokeOrderRoute {
+		t.Fatalf("revoke_order not sent, last message route %q", lastSend.Route)
+	}
+
+	// Skip.
+	eq, _ = stallEpoch()
+	fe, err = mkt.ForceEpoch(eq.Epoch, true, false)
+	if err != nil {
+		t.Fatalf("ForceEpoch error: %v", err)
+	}
+	if !fe.Skipped || len(fe.Revoked) != 1 {
+		t.Fatalf("unexpected skip result %+v", fe)
+	}
+	<-processed
+
 	cancel()
 }
 
@@ -1425,6 +1670,7 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 	mkt := &Market{
 		auth:    authMgr,
 		storage: &TArchivist{},
+		log:     log,
 	}
 
 	piMsg := &msgjson.PreimageResponse{
@@ -1447,7 +1693,7 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 	// 1. bad Message.Type: RPCParseError
 	msg.Type = msgjson.Request // should be Response
 	lo, pi := newOrder()
-	dat := &piData{lo, make(chan *order.Preimage)}
+	dat := &piData{ord: lo, preimage: make(chan *order.Preimage)}
 	piRes := runAndReceive(msg, dat)
 	if piRes != nil {
 		t.Errorf("Expected <nil> preimage, got %v", piRes)
@@ -1474,7 +1720,7 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 	// 2. empty preimage from client: InvalidPreimage
 	msg, _ = msgjson.NewResponse(5, piMsg, nil)
 	//lo, pi := newOrder()
-	dat = &piData{lo, make(chan *order.Preimage)}
+	dat = &piData{ord: lo, preimage: make(chan *order.Preimage)}
 	piRes = runAndReceive(msg, dat)
 	if piRes != nil {
 		t.Errorf("Expected <nil> preimage, got %v", piRes)
@@ -1556,7 +1802,7 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 	// 5. payload is not msgjson.PreimageResponse, unmarshal still succeeds, but PI is nil
 	notaPiMsg := new(msgjson.OrderBookSubscription)
 	msg, _ = msgjson.NewResponse(5, notaPiMsg, nil)
-	dat = &piData{lo, make(chan *order.Preimage)}
+	dat = &piData{ord: lo, preimage: make(chan *order.Preimage)}
 	piRes = runAndReceive(msg, dat)
 	if piRes != nil {
 		t.Errorf("Expected <nil> preimage, got %v", piRes)
@@ -1581,7 +1827,7 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 	// 6. payload unmarshal error
 	msg, _ = msgjson.NewResponse(5, piMsg, nil)
 	msg.Payload = json.RawMessage(`{"result":1}`) // ResponsePayload with invalid Result
-	dat = &piData{lo, make(chan *order.Preimage)}
+	dat = &piData{ord: lo, preimage: make(chan *order.Preimage)}
 	piRes = runAndReceive(msg, dat)
 	if piRes != nil {
 		t.Errorf("Expected <nil> preimage, got %v", piRes)
@@ -1605,3 +1851,282 @@ func TestMarket_handlePreimageResp(t *testing.T) {
 			msgErr.Message)
 	}
 }
+
+func TestMarket_PurgeStaleOrders(t *testing.T) {
+	mkt, storage, auth, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("Failed to create test market: %v", err)
+	}
+	defer cleanup()
+
+	now := time.Now()
+	stale := makeLO(seller3, mkRate3(1.0, 1.2), randLots(10), order.StandingTiF)
+	stale.ServerTime = now.Add(-2 * time.Hour)
+	fresh := makeLO(buyer3, mkRate3(0.8, 1.0), randLots(10), order.StandingTiF)
+	fresh.ServerTime = now
+	mkt.bookMtx.Lock()
+	for _, lo := range []*order.LimitOrder{stale, fresh} {
+		if !mkt.book.Insert(lo) {
+			mkt.bookMtx.Unlock()
+			t.Fatalf("Failed to insert an order into Market's Book")
+		}
+	}
+	mkt.bookMtx.Unlock()
+
+	revoked := mkt.PurgeStaleOrders(time.Hour)
+	if len(revoked) != 1 || revoked[0] != stale.ID() {
+		t.Fatalf("expected only the stale order to be revoked, got %v", revoked)
+	}
+	if mkt.book.HaveOrder(stale.ID()) {
+		t.Fatalf("stale order still booked")
+	}
+	if !mkt.book.HaveOrder(fresh.ID()) {
+		t.Fatalf("fresh order not booked")
+	}
+	if len(storage.revokedUncounted) != 1 || storage.revokedUncounted[0].ID() != stale.ID() {
+		t.Fatalf("stale order not revoked without counting")
+	}
+	auth.sendsMtx.Lock()
+	lastSend := auth.sends[len(auth.sends)-1]
+	auth.sendsMtx.Unlock()
+	if lastSend.Route != msgjson.RevokeOrderRoute {
+		t.Fatalf("revoke_order not sent, last message route %q", lastSend.Route)
+	}
+
+	// Nothing left to purge.
+	if revoked = mkt.PurgeStaleOrders(time.Hour); len(revoked) != 0 {
+		t.Fatalf("unexpected revoked orders %v", revoked)
+	}
+}
+
+func TestMarket_VerifyBook(t *testing.T) {
+	mkt, storage, _, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("Failed to create test market: %v", err)
+	}
+	defer cleanup()
+
+	both := makeLO(seller3, mkRate3(1.0, 1.2), randLots(10), order.StandingTiF)
+	bookOnly := makeLO(buyer3, mkRate3(0.8, 1.0), randLots(10), order.StandingTiF)
+	storedOnly := makeLO(seller3, mkRate3(1.0, 1.2), randLots(10), order.StandingTiF)
+	mkt.bookMtx.Lock()
+	for _, lo := range []*order.LimitOrder{both, bookOnly} {
+		if !mkt.book.Insert(lo) {
+			mkt.bookMtx.Unlock()
+			t.Fatalf("Failed to insert an order into Market's Book")
+		}
+	}
+	mkt.bookMtx.Unlock()
+	storage.mtx.Lock()
+	storage.bookedOrders = []*order.LimitOrder{both, storedOnly}
+	storage.mtx.Unlock()
+
+	res, err := mkt.VerifyBook()
+	if err != nil {
+		t.Fatalf("VerifyBook failed: %v", err)
+	}
+	if res.BookedOrders != 2 || res.StoredOrders != 2 {
+		t.Fatalf("wrong order counts: booked %d, stored %d", res.BookedOrders, res.StoredOrders)
+	}
+	issues := make(map[string][]string)
+	for _, d := range res.Discrepancies {
+		issues[d.OrderID] = append(issues[d.OrderID], d.Issue)
+	}
+	hasIssue := func(oid order.OrderID, issue string) bool {
+		for _, is := range issues[oid.String()] {
+			if is == issue {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasIssue(bookOnly.ID(), "order is on the book but not stored as booked") {
+		t.Errorf("unstored booked order not reported, got %v", issues)
+	}
+	if !hasIssue(storedOnly.ID(), "order is stored as booked but is not on the book") {
+		t.Errorf("stored order missing from book not reported, got %v", issues)
+	}
+	if hasIssue(both.ID(), "order is on the book but not stored as booked") ||
+		hasIssue(both.ID(), "book order does not match stored order") {
+		t.Errorf("consistent order reported, got %v", issues[both.ID().String()])
+	}
+}
+
+func TestMarket_EpochReplay(t *testing.T) {
+	mkt, _, _, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("Failed to create test market: %v", err)
+	}
+	defer cleanup()
+
+	for i := 0; i < 8; i++ {
+		if !mkt.book.Insert(makeLO(buyer3, mkRate3(0.8, 1.0), randLots(10), order.StandingTiF)) {
+			t.Fatalf("Failed to Insert order into book.")
+		}
+		if !mkt.book.Insert(makeLO(seller3, mkRate3(1.0, 1.2), randLots(10), order.StandingTiF)) {
+			t.Fatalf("Failed to Insert order into book.")
+		}
+	}
+	bestBuy, bestSell := mkt.book.Best()
+
+	var epochIdx, epochDur int64 = 123413513, int64(mkt.marketInfo.EpochDuration)
+	eq := NewEpoch(epochIdx, epochDur)
+	lo, loPI := makeLORevealed(seller3, bestBuy.Rate-dcrRateStep, bestBuy.Quantity*3, order.StandingTiF)
+	co, coPI := makeCORevealed(buyer3, bestSell.ID())
+	eq.Insert(lo)
+	eq.Insert(co)
+	ready := make(chan struct{})
+	close(ready)
+	notifyChan := make(chan *updateSignal, 32)
+	mkt.processReadyEpoch(&readyEpoch{
+		EpochQueue: eq,
+		ready:      ready,
+		ordersRevealed: []*matcher.OrderRevealed{
+			{Order: lo, Preimage: loPI},
+			{Order: co, Preimage: coPI},
+		},
+	}, notifyChan)
+
+	if _, err = mkt.EpochReplay(epochIdx+1, lo.ID(), lo.User()); !errors.Is(err, ErrEpochNotRetained) {
+		t.Fatalf("expected ErrEpochNotRetained, got %v", err)
+	}
+	if _, err = mkt.EpochReplay(epochIdx, lo.ID(), co.User()); !errors.Is(err, ErrOrderNotInEpoch) {
+		t.Fatalf("expected ErrOrderNotInEpoch for another user, got %v", err)
+	}
+
+	res, err := mkt.EpochReplay(epochIdx, lo.ID(), lo.User())
+	if err != nil {
+		t.Fatalf("EpochReplay error: %v", err)
+	}
+	if len(res.Queue) != 2 || len(res.Book) != 2 {
+		t.Fatalf("expected 2 queue and 2 book orders, got %d and %d", len(res.Queue), len(res.Book))
+	}
+
+	decode := func(ro *msgjson.ReplayOrder) order.Order {
+		var oid order.OrderID
+		copy(oid[:], ro.OrderID)
+		ord, err := order.DecodeRedactedOrder(ro.Order, oid)
+		if err != nil {
+			t.Fatalf("DecodeRedactedOrder error: %v", err)
+		}
+		if ord.User() != (account.AccountID{}) {
+			t.Fatalf("order %v not redacted", oid)
+		}
+		return ord
+	}
+	bookOrders := make([]*order.LimitOrder, 0, len(res.Book))
+	for _, ro := range res.Book {
+		bookOrders = append(bookOrders, decode(ro).(*order.LimitOrder))
+	}
+	queue := make([]*matcher.OrderRevealed, 0, len(res.Queue))
+	for _, ro := range res.Queue {
+		var pi order.Preimage
+		copy(pi[:], ro.Preimage)
+		queue = append(queue, &matcher.OrderRevealed{
+			Order:    decode(ro),
+			Preimage: pi,
+		})
+	}
+	seed, matches, result, err := replay.ReplayEpoch(res.LotSize, res.TradeFee, res.OddLotQty, bookOrders, queue)
+	if err != nil {
+		t.Fatalf("ReplayEpoch error: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 replayed matches, got %d", len(matches))
+	}
+	if !bytes.Equal(seed, res.Seed) || !bytes.Equal(result, res.Result) {
+		t.Fatalf("replay results differ")
+	}
+}
+
+func TestMarket_checkLoad(t *testing.T) {
+	mkt, _, _, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("newTestMarket failure: %v", err)
+	}
+	defer cleanup()
+	mkt.marketInfo.MaxEpochOrders = 2
+
+	epoch := NewEpoch(1, int64(mkt.EpochDuration()))
+	lo := makeLO(seller1, mkRate1(0.8, 1.0), randLots(10), order.StandingTiF)
+	if err := mkt.checkLoad(lo, epoch); err != nil {
+		t.Fatalf("unexpected error for empty epoch: %v", err)
+	}
+	epoch.Insert(lo)
+	epoch.Insert(makeCO(seller1, lo.ID()))
+	mo := makeMO(buyer1, randLots(10))
+	if err := mkt.checkLoad(mo, epoch); err != nil {
+		t.Fatalf("unexpected error for epoch with 1 trade order: %v", err)
+	}
+	epoch.Insert(mo)
+
+	// The epoch is full of trade orders, but cancel orders are accepted.
+	lo2 := makeLO(buyer1, mkRate1(0.8, 1.0), randLots(10), order.StandingTiF)
+	if err := mkt.checkLoad(lo2, epoch); !errors.Is(err, ErrMarketOverloaded) {
+		t.Fatalf("expected ErrMarketOverloaded for full epoch, got %v", err)
+	}
+	if err := mkt.checkLoad(makeCO(buyer1, mo.ID()), epoch); err != nil {
+		t.Fatalf("unexpected error for cancel order: %v", err)
+	}
+
+	// Too many closed epochs waiting to be matched.
+	epoch = NewEpoch(2, int64(mkt.EpochDuration()))
+	for i := 0; i <= defaultMaxEpochBacklog; i++ {
+		mkt.epochClosed()
+	}
+	if err := mkt.checkLoad(lo2, epoch); !errors.Is(err, ErrMarketOverloaded) {
+		t.Fatalf("expected ErrMarketOverloaded for epoch backlog, got %v", err)
+	}
+	mkt.epochProcessed()
+	if err := mkt.checkLoad(lo2, epoch); err != nil {
+		t.Fatalf("unexpected error after epoch processed: %v", err)
+	}
+
+	status := mkt.Status()
+	if status.ShedOrders != 2 || status.EpochBacklog != defaultMaxEpochBacklog {
+		t.Fatalf("wrong load status: %d shed orders, %d epoch backlog", status.ShedOrders, status.EpochBacklog)
+	}
+}
+
+func TestMarket_updateMinOrderQty(t *testing.T) {
+	mkt, _, _, cleanup, err := newTestMarket()
+	if err != nil {
+		t.Fatalf("newTestMarket failure: %v", err)
+	}
+	defer cleanup()
+	lotSize := mkt.marketInfo.LotSize
+	rate := mkRate1(0.8, 1.0)
+	mkt.marketInfo.MinOrderValue = matcher.BaseToQuote(rate, 3*lotSize) + 1
+
+	// There is no spot rate with an empty book and no trades.
+	mkt.updateMinOrderQty()
+	if qty := mkt.MinOrderQty(); qty != 0 {
+		t.Fatalf("expected no minimum without a spot rate, got %d", qty)
+	}
+
+	// The minimum is rounded up to a whole number of lots.
+	mkt.stats.lastRate = rate
+	mkt.updateMinOrderQty()
+	if qty := mkt.MinOrderQty(); qty != 4*lotSize {
+		t.Fatalf("expected minimum quantity %d, got %d", 4*lotSize, qty)
+	}
+	if status := mkt.Status(); status.MinOrderQty != 4*lotSize {
+		t.Fatalf("wrong status minimum quantity %d", status.MinOrderQty)
+	}
+	// Odd lots are kept by default.
+	if qty := mkt.matcher.OddLotQty(); qty != 0 {
+		t.Fatalf("expected odd lots to be kept, got odd lot quantity %d", qty)
+	}
+	mkt.marketInfo.OddLotPolicy = dex.OddLotRelease
+	mkt.updateMinOrderQty()
+	if qty := mkt.matcher.OddLotQty(); qty != 4*lotSize {
+		t.Fatalf("expected odd lot quantity %d, got %d", 4*lotSize, qty)
+	}
+
+	// The previous minimum is kept if the spot rate is lost.
+	mkt.stats.lastRate = 0
+	mkt.updateMinOrderQty()
+	if qty := mkt.MinOrderQty(); qty != 4*lotSize {
+		t.Fatalf("expected minimum quantity to be kept, got %d", qty)
+	}
+}
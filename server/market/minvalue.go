@@ -0,0 +1,85 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/calc"
+)
+
+// minOrderQtyInterval is how often a running market with a minimum order value
+// recalculates its minimum order quantity from the spot rate.
+const minOrderQtyInterval = time.Minute
+
+// MinOrderValue is the minimum value of an order, in units of the quote asset.
+// Zero means that orders only need to be at least one lot.
+func (m *Market) MinOrderValue() uint64 {
+	return m.marketInfo.MinOrderValue
+}
+
+// MinOrderQty is the minimum quantity of an order that is quantified in the
+// base asset, which is the minimum order value at the last known spot rate,
+// rounded up to a whole number of lots. Zero means there is no minimum beyond
+// the lot size, which is also the case until the market has a spot rate.
+func (m *Market) MinOrderQty() uint64 {
+	return atomic.LoadUint64(&m.minOrderQty)
+}
+
+// spotRate is the rate of the market's last trade, or the mid-gap rate if
+// there have been no trades since startup. Zero means there is no rate.
+func (m *Market) spotRate() uint64 {
+	m.stats.mtx.Lock()
+	rate := m.stats.lastRate
+	m.stats.mtx.Unlock()
+	if rate == 0 {
+		rate = m.MidGap()
+	}
+	return rate
+}
+
+// updateMinOrderQty recalculates the minimum order quantity at the spot rate.
+// The previous minimum is kept if there is no spot rate. If the market releases
+// odd lots, the matcher releases remainders below the new minimum.
+func (m *Market) updateMinOrderQty() {
+	minValue := m.MinOrderValue()
+	rate := m.spotRate()
+	if minValue == 0 || rate == 0 {
+		return
+	}
+	lotSize := m.marketInfo.LotSize
+	qty := calc.QuoteToBase(rate, minValue)
+	if rem := qty % lotSize; rem != 0 {
+		qty += lotSize - rem
+	}
+	if m.OddLotPolicy() == dex.OddLotRelease {
+		// The quantity is changed between epochs so that epoch records and
+		// replays have the quantity used for matching.
+		m.bookMtx.Lock()
+		m.matcher.SetOddLotQty(qty)
+		m.bookMtx.Unlock()
+	}
+	if old := atomic.SwapUint64(&m.minOrderQty, qty); old != qty {
+		log.Infof("Market %s minimum order quantity is now %d at rate %d (minimum value %d).",
+			m.marketInfo.Name, qty, rate, minValue)
+	}
+}
+
+// minOrderQtyLoop periodically recalculates the minimum order quantity until
+// the context is canceled.
+func (m *Market) minOrderQtyLoop(ctx context.Context) {
+	ticker := time.NewTicker(minOrderQtyInterval)
+	defer ticker.Stop()
+	for {
+		m.updateMinOrderQty()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -6,7 +6,9 @@ package market
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"decred.org/dcrdex/dex"
@@ -18,6 +20,7 @@ import (
 	"decred.org/dcrdex/server/asset"
 	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/trace"
 )
 
 const maxClockOffset = 10_000 // milliseconds
@@ -33,8 +36,10 @@ type AuthManager interface {
 	SendWhenConnected(account.AccountID, *msgjson.Message, time.Duration, func())
 	Request(account.AccountID, *msgjson.Message, func(comms.Link, *msgjson.Message)) error
 	RequestWithTimeout(account.AccountID, *msgjson.Message, func(comms.Link, *msgjson.Message), time.Duration, func()) error
-	Penalize(user account.AccountID, rule account.Rule) error
+	Penalize(user account.AccountID, rule account.Rule, details string, matchIDs ...order.MatchID) error
 	RecordCancel(user account.AccountID, oid, target order.OrderID, t time.Time)
+	ConnState(user account.AccountID) (connected bool, lastRTT time.Duration)
+	LotLimit(user account.AccountID) uint64
 }
 
 const DefaultConnectTimeout = 10 * time.Minute
@@ -51,6 +56,11 @@ type MarketTunnel interface {
 	// MarketBuyBuffer is a coefficient that when multiplied by the market's lot
 	// size specifies the minimum required amount for a market buy order.
 	MarketBuyBuffer() float64
+	// MinOrderValue is the minimum value of an order in units of the quote
+	// asset, and MinOrderQty is that value in units of the base asset at the
+	// market's spot rate. Zero means there is no minimum beyond the lot size.
+	MinOrderValue() uint64
+	MinOrderQty() uint64
 	// CoinLocked should return true if the CoinID is currently a funding Coin
 	// for an active DEX order. This is required for Coin validation to prevent
 	// a user from submitting multiple orders spending the same Coin. This
@@ -75,6 +85,14 @@ type MarketTunnel interface {
 	// false when suspended, but false does not necessarily mean Run has stopped
 	// since a start epoch may be set.
 	Running() bool
+
+	// SuspendEpoch returns the final epoch index of the market's most recent
+	// suspension, or zero if no suspension has been scheduled.
+	SuspendEpoch() int64
+
+	// EpochReplay returns the redacted matching inputs and results of a recent
+	// epoch that included the user's order.
+	EpochReplay(epochIdx int64, oid order.OrderID, user account.AccountID) (*msgjson.EpochReplayResult, error)
 }
 
 // orderRecord contains the information necessary to respond to an order
@@ -83,6 +101,7 @@ type orderRecord struct {
 	order order.Order
 	req   msgjson.Stampable
 	msgID uint64
+	cid   trace.ID // correlation ID of the request
 }
 
 // assetSet is pointers to two different assets, but with 4 ways of addressing
@@ -129,11 +148,16 @@ func (o *outpoint) TxHash() []byte { return o.hash }
 func (o *outpoint) Vout() uint32 { return o.vout }
 
 // OrderRouter handles the 'limit', 'market', and 'cancel' DEX routes. These
-// are authenticated routes used for placing and canceling orders.
+// are authenticated routes used for placing and canceling orders. The
+// 'epoch_replay' route for verifying the matching of an order is also handled.
 type OrderRouter struct {
-	auth    AuthManager
-	assets  map[uint32]*asset.BackedAsset
-	tunnels map[string]MarketTunnel
+	auth   AuthManager
+	assets map[uint32]*asset.BackedAsset
+
+	tunnelsMtx sync.RWMutex
+	tunnels    map[string]MarketTunnel
+
+	tracer *trace.Tracer
 }
 
 // OrderRouterConfig is the configuration settings for an OrderRouter.
@@ -141,6 +165,8 @@ type OrderRouterConfig struct {
 	AuthManager AuthManager
 	Assets      map[uint32]*asset.BackedAsset
 	Markets     map[string]MarketTunnel
+	// Tracer records the events of order requests. It may be nil.
+	Tracer *trace.Tracer
 }
 
 // NewOrderRouter is a constructor for an OrderRouter.
@@ -149,10 +175,12 @@ func NewOrderRouter(cfg *OrderRouterConfig) *OrderRouter {
 		auth:    cfg.AuthManager,
 		assets:  cfg.Assets,
 		tunnels: cfg.Markets,
+		tracer:  cfg.Tracer,
 	}
 	cfg.AuthManager.Route(msgjson.LimitRoute, router.handleLimit)
 	cfg.AuthManager.Route(msgjson.MarketRoute, router.handleMarket)
 	cfg.AuthManager.Route(msgjson.CancelRoute, router.handleCancel)
+	cfg.AuthManager.Route(msgjson.EpochReplayRoute, router.handleEpochReplay)
 	return router
 }
 
@@ -172,7 +200,7 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 	}
 
 	if _, suspended := r.auth.Suspended(user); suspended {
-		return msgjson.NewError(msgjson.MarketNotRunningError, "suspended account may not submit trade orders")
+		return msgjson.NewError(msgjson.AccountSuspendedError, "suspended account may not submit trade orders")
 	}
 
 	tunnel, coins, sell, rpcErr := r.extractMarketDetails(&limit.Prefix, &limit.Trade)
@@ -183,7 +211,7 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 	// Spare some resources if the market is closed now. Any orders that make it
 	// through to a closed market will receive a similar error from SubmitOrder.
 	if !tunnel.Running() {
-		return msgjson.NewError(msgjson.MarketNotRunningError, "market closed to new orders")
+		return marketNotRunningError(tunnel)
 	}
 
 	// Check that OrderType is set correctly
@@ -201,7 +229,8 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 		return msgjson.NewError(msgjson.OrderParameterError, "rate = 0 not allowed")
 	}
 	if limit.Rate%coins.quote.RateStep != 0 {
-		return msgjson.NewError(msgjson.OrderParameterError, "rate not a multiple of ratestep")
+		return msgjson.NewError(msgjson.RateStepError,
+			fmt.Sprintf("rate %d not a multiple of rate step %d", limit.Rate, coins.quote.RateStep))
 	}
 
 	// Calculate the fees and check that the utxo sum is enough.
@@ -212,7 +241,7 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 	fundAsset := &coins.funding.Asset
 	reqVal := calc.RequiredOrderFunds(swapVal, uint64(spendSize), fundAsset)
 	if valSum < reqVal {
-		return msgjson.NewError(msgjson.FundingError,
+		return msgjson.NewError(msgjson.InsufficientFundsError,
 			fmt.Sprintf("not enough funds. need at least %d, got %d", reqVal, valSum))
 	}
 
@@ -227,6 +256,22 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 		return msgjson.NewError(msgjson.OrderParameterError, "unknown time-in-force")
 	}
 
+	// An iceberg order must be booked to hide its size, and it shows a whole
+	// number of lots that is less than its quantity.
+	if limit.Visible > 0 {
+		if force != order.StandingTiF {
+			return msgjson.NewError(msgjson.OrderParameterError, "iceberg orders must be standing orders")
+		}
+		if limit.Visible%coins.base.LotSize != 0 {
+			return msgjson.NewError(msgjson.LotSizeError,
+				fmt.Sprintf("visible quantity %d not a multiple of lot size %d", limit.Visible, coins.base.LotSize))
+		}
+		if limit.Visible >= limit.Quantity {
+			return msgjson.NewError(msgjson.OrderParameterError,
+				fmt.Sprintf("visible quantity %d must be less than the order quantity %d", limit.Visible, limit.Quantity))
+		}
+	}
+
 	// Commitment.
 	if len(limit.Commit) != order.CommitmentSize {
 		return msgjson.NewError(msgjson.OrderParameterError, "invalid commitment")
@@ -251,8 +296,9 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 			Quantity: limit.Quantity,
 			Address:  limit.Address,
 		},
-		Rate:  limit.Rate,
-		Force: force,
+		Rate:    limit.Rate,
+		Force:   force,
+		Visible: limit.Visible,
 	}
 
 	// NOTE: ServerTime is not yet set, so the order's ID, which is computed
@@ -264,10 +310,11 @@ func (r *OrderRouter) handleLimit(user account.AccountID, msg *msgjson.Message)
 		order: lo,
 		req:   limit,
 		msgID: msg.ID,
+		cid:   r.tracer.Of(msg),
 	}
 	if err := tunnel.SubmitOrder(oRecord); err != nil {
 		log.Warnf("Market failed to SubmitOrder: %v", err)
-		return msgjson.NewError(msgjson.UnknownMarketError, "failed to submit order")
+		return submitOrderError(tunnel, err)
 	}
 	return nil
 }
@@ -288,7 +335,7 @@ func (r *OrderRouter) handleMarket(user account.AccountID, msg *msgjson.Message)
 	}
 
 	if _, suspended := r.auth.Suspended(user); suspended {
-		return msgjson.NewError(msgjson.MarketNotRunningError, "suspended account may not submit trade orders")
+		return msgjson.NewError(msgjson.AccountSuspendedError, "suspended account may not submit trade orders")
 	}
 
 	tunnel, assets, sell, rpcErr := r.extractMarketDetails(&market.Prefix, &market.Trade)
@@ -297,7 +344,7 @@ func (r *OrderRouter) handleMarket(user account.AccountID, msg *msgjson.Message)
 	}
 
 	if !tunnel.Running() {
-		return msgjson.NewError(msgjson.MarketNotRunningError, "market %s closed to new orders")
+		return marketNotRunningError(tunnel)
 	}
 
 	// Check that OrderType is set correctly
@@ -330,11 +377,15 @@ func (r *OrderRouter) handleMarket(user account.AccountID, msg *msgjson.Message)
 
 		if market.Quantity < minReq {
 			errStr := fmt.Sprintf("order quantity does not satisfy market buy buffer. %d < %d. midGap = %d", reqVal, minReq, midGap)
-			return msgjson.NewError(msgjson.FundingError, errStr)
+			return msgjson.NewError(msgjson.LotSizeError, errStr)
+		}
+		if minValue := tunnel.MinOrderValue(); market.Quantity < minValue {
+			return msgjson.NewError(msgjson.OrderValueError,
+				fmt.Sprintf("order quantity %d is less than the minimum order value %d", market.Quantity, minValue))
 		}
 	}
 	if valSum < reqVal {
-		return msgjson.NewError(msgjson.FundingError,
+		return msgjson.NewError(msgjson.InsufficientFundsError,
 			fmt.Sprintf("not enough funds. need at least %d, got %d", reqVal, valSum))
 	}
 
@@ -369,10 +420,11 @@ func (r *OrderRouter) handleMarket(user account.AccountID, msg *msgjson.Message)
 		order: mo,
 		req:   market,
 		msgID: msg.ID,
+		cid:   r.tracer.Of(msg),
 	}
 	if err := tunnel.SubmitOrder(oRecord); err != nil {
 		log.Warnf("Market failed to SubmitOrder: %v", err)
-		return msgjson.NewError(msgjson.UnknownMarketError, "failed to submit order")
+		return submitOrderError(tunnel, err)
 	}
 	return nil
 }
@@ -405,7 +457,7 @@ func (r *OrderRouter) handleCancel(user account.AccountID, msg *msgjson.Message)
 	}
 
 	if !tunnel.Running() {
-		return msgjson.NewError(msgjson.MarketNotRunningError, "market %s closed to new orders")
+		return marketNotRunningError(tunnel)
 	}
 
 	if len(cancel.TargetID) != order.OrderIDSize {
@@ -454,10 +506,77 @@ func (r *OrderRouter) handleCancel(user account.AccountID, msg *msgjson.Message)
 		order: co,
 		req:   cancel,
 		msgID: msg.ID,
+		cid:   r.tracer.Of(msg),
 	}
 	if err := tunnel.SubmitOrder(oRecord); err != nil {
 		log.Warnf("Market failed to SubmitOrder: %v", err)
-		return msgjson.NewError(msgjson.UnknownMarketError, "failed to submit order")
+		return submitOrderError(tunnel, err)
+	}
+	return nil
+}
+
+// marketNotRunningError is the error for an order submitted to a market that is
+// not running. A market that was suspended is distinguished from one that has
+// not yet started.
+func marketNotRunningError(tunnel MarketTunnel) *msgjson.Error {
+	if finalEpoch := tunnel.SuspendEpoch(); finalEpoch > 0 {
+		return msgjson.NewError(msgjson.MarketSuspendedError,
+			fmt.Sprintf("market suspended at epoch %d", finalEpoch))
+	}
+	return msgjson.NewError(msgjson.MarketNotRunningError, "market closed to new orders")
+}
+
+// submitOrderError is the error for an order that the Market failed to accept.
+func submitOrderError(tunnel MarketTunnel, err error) *msgjson.Error {
+	switch {
+	case errors.Is(err, ErrMarketNotRunning):
+		return marketNotRunningError(tunnel)
+	case errors.Is(err, ErrSuspendedAccount):
+		return msgjson.NewError(msgjson.AccountSuspendedError, "suspended account may not submit trade orders")
+	case errors.Is(err, ErrMarketOverloaded):
+		return msgjson.NewError(msgjson.MarketOverloadedError, "market overloaded, try again later")
+	case errors.Is(err, ErrCoinsLocked):
+		return msgjson.NewError(msgjson.FundingError, "order coins are locked by another order")
+	case errors.Is(err, ErrInvalidOrder), errors.Is(err, ErrInvalidCommitment),
+		errors.Is(err, ErrDuplicateCancelOrder), errors.Is(err, ErrInvalidCancelOrder):
+		return msgjson.NewError(msgjson.OrderParameterError, err.Error())
+	}
+	return msgjson.NewError(msgjson.UnknownMarketError, "failed to submit order")
+}
+
+// handleEpochReplay is the handler for the 'epoch_replay' route. This route
+// accepts a msgjson.EpochReplayRequest payload, and responds with the redacted
+// matching inputs and results of the requested epoch, if the user's order was
+// in the epoch.
+func (r *OrderRouter) handleEpochReplay(user account.AccountID, msg *msgjson.Message) *msgjson.Error {
+	req := new(msgjson.EpochReplayRequest)
+	err := json.Unmarshal(msg.Payload, req)
+	if err != nil {
+		return msgjson.NewError(msgjson.RPCParseError, "error decoding 'epoch_replay' payload")
+	}
+	if len(req.OrderID) != order.OrderIDSize {
+		return msgjson.NewError(msgjson.OrderParameterError, "invalid order ID")
+	}
+	var oid order.OrderID
+	copy(oid[:], req.OrderID)
+
+	tunnel, rpcErr := r.extractMarket(&msgjson.Prefix{Base: req.Base, Quote: req.Quote})
+	if rpcErr != nil {
+		return rpcErr
+	}
+
+	res, err := tunnel.EpochReplay(int64(req.Epoch), oid, user)
+	if err != nil {
+		return msgjson.NewError(msgjson.EpochReplayError, err.Error())
+	}
+
+	resp, err := msgjson.NewResponse(msg.ID, res, nil)
+	if err != nil {
+		log.Errorf("error creating epoch_replay response: %v", err)
+		return msgjson.NewError(msgjson.RPCInternalError, "internal error")
+	}
+	if err = r.auth.Send(user, resp); err != nil {
+		log.Infof("Failed to send epoch_replay response to user %v: %v", user, err)
 	}
 	return nil
 }
@@ -485,13 +604,29 @@ func (r *OrderRouter) extractMarket(prefix *msgjson.Prefix) (MarketTunnel, *msgj
 	if err != nil {
 		return nil, msgjson.NewError(msgjson.UnknownMarketError, "asset lookup error: "+err.Error())
 	}
-	tunnel, found := r.tunnels[mktName]
+	tunnel, found := r.tunnel(mktName)
 	if !found {
 		return nil, msgjson.NewError(msgjson.UnknownMarketError, "unknown market "+mktName)
 	}
 	return tunnel, nil
 }
 
+// tunnel retrieves the MarketTunnel for the named market.
+func (r *OrderRouter) tunnel(mktName string) (MarketTunnel, bool) {
+	r.tunnelsMtx.RLock()
+	defer r.tunnelsMtx.RUnlock()
+	tunnel, found := r.tunnels[mktName]
+	return tunnel, found
+}
+
+// RemoveMarket stops routing orders to the named market. Orders for the market
+// are rejected as orders for an unknown market.
+func (r *OrderRouter) RemoveMarket(mktName string) {
+	r.tunnelsMtx.Lock()
+	delete(r.tunnels, mktName)
+	r.tunnelsMtx.Unlock()
+}
+
 // SuspendEpoch holds the index and end time of final epoch marking the
 // suspension of a market.
 type SuspendEpoch struct {
@@ -499,6 +634,13 @@ type SuspendEpoch struct {
 	End time.Time
 }
 
+// ResumeEpoch holds the index and start time of the first epoch of a resumed
+// market.
+type ResumeEpoch struct {
+	Idx   int64
+	Start time.Time
+}
+
 // SuspendMarket schedules a suspension of a given market, with the option to
 // persist the orders on the book (or purge the book automatically on market
 // shutdown). The scheduled final epoch and suspend time are returned. Note that
@@ -508,7 +650,7 @@ type SuspendEpoch struct {
 // blocking order submission according to the schedule rather than just checking
 // Market.Running prior to submitting incoming orders to the Market.
 func (r *OrderRouter) SuspendMarket(mktName string, asSoonAs time.Time, persistBooks bool) *SuspendEpoch {
-	mkt, found := r.tunnels[mktName]
+	mkt, found := r.tunnel(mktName)
 	if !found {
 		return nil
 	}
@@ -524,6 +666,8 @@ func (r *OrderRouter) SuspendMarket(mktName string, asSoonAs time.Time, persistB
 // "suspend all as soon as" DEX function with rather than shutting down in the
 // middle of an active epoch as SIGINT shutdown presently does.
 func (r *OrderRouter) Suspend(asSoonAs time.Time, persistBooks bool) map[string]*SuspendEpoch {
+	r.tunnelsMtx.RLock()
+	defer r.tunnelsMtx.RUnlock()
 
 	suspendTimes := make(map[string]*SuspendEpoch, len(r.tunnels))
 	for name, mkt := range r.tunnels {
@@ -608,7 +752,28 @@ func (r *OrderRouter) checkPrefixTrade(user account.AccountID, tunnel MarketTunn
 		return errSet(msgjson.OrderParameterError, "zero quantity not allowed")
 	}
 	if checkLot && trade.Quantity%assets.base.LotSize != 0 {
-		return errSet(msgjson.OrderParameterError, "order quantity not a multiple of lot size")
+		return errSet(msgjson.LotSizeError, fmt.Sprintf("order quantity %d not a multiple of lot size %d",
+			trade.Quantity, assets.base.LotSize))
+	}
+	if minQty := tunnel.MinOrderQty(); checkLot && trade.Quantity < minQty {
+		return errSet(msgjson.OrderValueError, fmt.Sprintf("order quantity %d is less than the minimum %d "+
+			"for an order value of at least %d", trade.Quantity, minQty, tunnel.MinOrderValue()))
+	}
+	// The quantity of the user's orders may be limited as a penalty. A market
+	// buy quantity is in units of the quote asset, and is converted at the
+	// mid-gap rate, so it is not limited while the book is empty.
+	if lotLimit := r.auth.LotLimit(user); lotLimit > 0 {
+		qty := trade.Quantity
+		if !checkLot {
+			qty = 0
+			if midGap := tunnel.MidGap(); midGap > 0 {
+				qty = calc.QuoteToBase(midGap, trade.Quantity)
+			}
+		}
+		if maxQty := lotLimit * assets.base.LotSize; qty > maxQty {
+			return errSet(msgjson.OrderLimitError, fmt.Sprintf("order quantity %d exceeds the account's "+
+				"penalty limit of %d lots (%d)", qty, lotLimit, maxQty))
+		}
 	}
 	// Validate UTXOs
 	// Check that all required arrays are of equal length.
@@ -120,9 +120,13 @@ type TAuth struct {
 	preimagesByMsgID   map[uint64]order.Preimage
 	preimagesByOrdID   map[string]order.Preimage
 	handlePreimageDone chan struct{}
+	piStall            bool // preimage requests are never answered or expired
 	suspensions        map[account.AccountID]bool
 	canceledOrder      order.OrderID
 	cancelOrder        order.OrderID
+	disconnected       bool
+	lastRTT            time.Duration
+	lotLimit           uint64
 }
 
 func (a *TAuth) Route(route string, handler func(account.AccountID, *msgjson.Message) *msgjson.Error) {
@@ -132,6 +136,7 @@ func (a *TAuth) Suspended(user account.AccountID) (found, suspended bool) {
 	suspended, found = a.suspensions[user]
 	return // TODO: test suspended account handling (no trades, just cancels)
 }
+func (a *TAuth) LotLimit(user account.AccountID) uint64 { return a.lotLimit }
 func (a *TAuth) Auth(user account.AccountID, msg, sig []byte) error {
 	//log.Infof("Auth for user %v", user)
 	return a.authErr
@@ -200,7 +205,11 @@ func (a *TAuth) RequestWithTimeout(user account.AccountID, msg *msgjson.Message,
 		log.Info("order id:", piReq.OrderID.String())
 		a.piMtx.Lock()
 		pi, found := a.preimagesByOrdID[piReq.OrderID.String()]
+		stall := a.piStall
 		a.piMtx.Unlock()
+		if stall {
+			return nil
+		}
 		if !found {
 			// If we have no preimage for this order, then we've decided to
 			// expire the response after the expire duration.
@@ -222,11 +231,15 @@ func (a *TAuth) RequestWithTimeout(user account.AccountID, msg *msgjson.Message,
 	}
 	return nil
 }
-func (a *TAuth) Penalize(user account.AccountID, rule account.Rule) error {
+func (a *TAuth) Penalize(user account.AccountID, rule account.Rule, _ string, _ ...order.MatchID) error {
 	log.Infof("Penalize for user %v", user)
 	return nil
 }
 
+func (a *TAuth) ConnState(account.AccountID) (bool, time.Duration) {
+	return !a.disconnected, a.lastRTT
+}
+
 func (a *TAuth) RecordCompletedOrder(account.AccountID, order.OrderID, time.Time) {}
 func (a *TAuth) RecordCancel(aid account.AccountID, coid, oid order.OrderID, t time.Time) {
 	a.cancelOrder = coid
@@ -242,9 +255,19 @@ type TMarketTunnel struct {
 	epochDur   uint64
 	locked     bool
 	cancelable bool
+	notRunning bool
+	suspendIdx int64
+	submitErr  error
+	replay     *msgjson.EpochReplayResult
+	replayErr  error
+	minValue   uint64
+	minQty     uint64
 }
 
 func (m *TMarketTunnel) SubmitOrder(o *orderRecord) error {
+	if m.submitErr != nil {
+		return m.submitErr
+	}
 	// set the server time
 	now := nowMs()
 	o.order.SetTime(now)
@@ -278,6 +301,14 @@ func (m *TMarketTunnel) MarketBuyBuffer() float64 {
 	return m.mbBuffer
 }
 
+func (m *TMarketTunnel) MinOrderValue() uint64 {
+	return m.minValue
+}
+
+func (m *TMarketTunnel) MinOrderQty() uint64 {
+	return m.minQty
+}
+
 func (m *TMarketTunnel) pop() *orderRecord {
 	if len(m.adds) == 0 {
 		return nil
@@ -297,7 +328,15 @@ func (m *TMarketTunnel) Suspend(asSoonAs time.Time, persistBook bool) (finalEpoc
 }
 
 func (m *TMarketTunnel) Running() bool {
-	return true
+	return !m.notRunning
+}
+
+func (m *TMarketTunnel) SuspendEpoch() int64 {
+	return m.suspendIdx
+}
+
+func (m *TMarketTunnel) EpochReplay(epochIdx int64, oid order.OrderID, user account.AccountID) (*msgjson.EpochReplayResult, error) {
+	return m.replay, m.replayErr
 }
 
 type TBackend struct {
@@ -641,14 +680,70 @@ func TestLimit(t *testing.T) {
 
 	// non-step-multiple rate
 	limit.Rate = rate + (btcRateStep / 2)
-	ensureErr("non-step-multiple", sendLimit(), msgjson.OrderParameterError)
+	ensureErr("non-step-multiple", sendLimit(), msgjson.RateStepError)
 	limit.Rate = rate
 
+	// Quantity below the minimum order value.
+	oRig.market.minQty = qty + dcrLotSize
+	ensureErr("below minimum value", sendLimit(), msgjson.OrderValueError)
+	oRig.market.minQty = qty
+	ensureErr("at minimum value", sendLimit(), -1)
+	oRig.market.pop()
+	oRig.market.minQty = 0
+
+	// Quantity above the account's penalty lot limit.
+	oRig.auth.lotLimit = qty/dcrLotSize - 1
+	ensureErr("above lot limit", sendLimit(), msgjson.OrderLimitError)
+	oRig.auth.lotLimit = qty / dcrLotSize
+	ensureErr("at lot limit", sendLimit(), -1)
+	oRig.market.pop()
+	oRig.auth.lotLimit = 0
+
+	// Market not started, and suspended.
+	oRig.market.notRunning = true
+	ensureErr("market not running", sendLimit(), msgjson.MarketNotRunningError)
+	oRig.market.suspendIdx = 1234
+	ensureErr("market suspended", sendLimit(), msgjson.MarketSuspendedError)
+	oRig.market.notRunning, oRig.market.suspendIdx = false, 0
+
+	// Market errors are translated.
+	for _, tt := range []struct {
+		err  error
+		code int
+	}{
+		{ErrSuspendedAccount, msgjson.AccountSuspendedError},
+		{ErrCoinsLocked, msgjson.FundingError},
+		{ErrInvalidCommitment, msgjson.OrderParameterError},
+		{ErrInternalServer, msgjson.UnknownMarketError},
+	} {
+		oRig.market.submitErr = tt.err
+		ensureErr(tt.err.Error(), sendLimit(), tt.code)
+	}
+	oRig.market.submitErr = nil
+
 	// Time-in-force incorrectly marked
 	limit.TiF = 0 // not msgjson.StandingOrderNum (1) or msgjson.ImmediateOrderNum (2)
 	ensureErr("bad tif", sendLimit(), msgjson.OrderParameterError)
 	limit.TiF = msgjson.StandingOrderNum
 
+	// Iceberg orders.
+	limit.Visible = 2 * dcrLotSize
+	ensureErr("iceberg", sendLimit(), -1)
+	oRecord = oRig.market.pop()
+	if oRecord == nil {
+		t.Fatalf("no iceberg order submitted to epoch")
+	}
+	if vis := oRecord.order.(*order.LimitOrder).Visible; vis != limit.Visible {
+		t.Fatalf("wrong visible quantity %d, expected %d", vis, limit.Visible)
+	}
+	limit.Visible = dcrLotSize + dcrLotSize/2
+	ensureErr("iceberg not lot multiple", sendLimit(), msgjson.LotSizeError)
+	limit.Visible = qty
+	ensureErr("iceberg all visible", sendLimit(), msgjson.OrderParameterError)
+	limit.Visible, limit.TiF = dcrLotSize, msgjson.ImmediateOrderNum
+	ensureErr("immediate iceberg", sendLimit(), msgjson.OrderParameterError)
+	limit.Visible, limit.TiF = 0, msgjson.StandingOrderNum
+
 	// Now switch it to a buy order, and ensure it passes
 	// Clear the sends cache first.
 	oRig.auth.sends = nil
@@ -792,9 +887,13 @@ func TestMarketStartProcessStop(t *testing.T) {
 	mkt.Quantity = matcher.BaseToQuote(midGap, uint64(dcrLotSize*1.2))
 	// First check an order that doesn't satisfy the market buy buffer. For
 	// testing, the market buy buffer is set to 1.5.
-	ensureErr("market buy buffer unsatisfied", sendMarket(), msgjson.FundingError)
+	ensureErr("market buy buffer unsatisfied", sendMarket(), msgjson.LotSizeError)
 	mktBuyQty := matcher.BaseToQuote(midGap, uint64(dcrLotSize*1.6))
 	mkt.Quantity = mktBuyQty
+	// Market buys are checked against the minimum order value directly.
+	oRig.market.minValue = mktBuyQty + 1
+	ensureErr("below minimum value", sendMarket(), msgjson.OrderValueError)
+	oRig.market.minValue = 0
 	rpcErr = sendMarket()
 	if rpcErr != nil {
 		t.Fatalf("error for buy order: %s", rpcErr.Message)
@@ -964,6 +1063,56 @@ func TestCancel(t *testing.T) {
 	}
 }
 
+func TestEpochReplay(t *testing.T) {
+	user := oRig.user
+	oid := order.OrderID{245}
+	req := &msgjson.EpochReplayRequest{
+		Base:    dcrID,
+		Quote:   btcID,
+		Epoch:   123,
+		OrderID: oid[:],
+	}
+	ensureErr := makeEnsureErr(t)
+	sendReplay := func() *msgjson.Error {
+		msg, _ := msgjson.NewRequest(6, msgjson.EpochReplayRoute, req)
+		return oRig.router.handleEpochReplay(user.acct, msg)
+	}
+
+	oRig.auth.sends = nil
+	oRig.market.replay = &msgjson.EpochReplayResult{Epoch: 123, Seed: []byte{1}}
+	defer func() { oRig.market.replay = nil }()
+	ensureErr("valid request", sendReplay(), -1)
+	respMsg := oRig.auth.getSend()
+	if respMsg == nil {
+		t.Fatalf("no epoch_replay response")
+	}
+	resp, _ := respMsg.Response()
+	res := new(msgjson.EpochReplayResult)
+	if err := json.Unmarshal(resp.Result, res); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if res.Epoch != 123 || !bytes.Equal(res.Seed, []byte{1}) {
+		t.Fatalf("wrong result %+v", res)
+	}
+
+	// Test an invalid payload.
+	msg := new(msgjson.Message)
+	msg.Payload = []byte(`?`)
+	ensureErr("bad payload", oRig.router.handleEpochReplay(user.acct, msg), msgjson.RPCParseError)
+
+	req.OrderID = []byte{0x01}
+	ensureErr("bad order ID", sendReplay(), msgjson.OrderParameterError)
+	req.OrderID = oid[:]
+
+	req.Quote = 12345
+	ensureErr("unknown market", sendReplay(), msgjson.UnknownMarketError)
+	req.Quote = btcID
+
+	oRig.market.replayErr = ErrOrderNotInEpoch
+	defer func() { oRig.market.replayErr = nil }()
+	ensureErr("market error", sendReplay(), msgjson.EpochReplayError)
+}
+
 func testPrefix(prefix *msgjson.Prefix, checkCode func(string, int)) {
 	ogAcct := prefix.AccountID
 	oid := ordertest.NextAccount()
@@ -1014,7 +1163,7 @@ func testPrefixTrade(prefix *msgjson.Prefix, trade *msgjson.Trade, fundingAsset,
 
 	// non-lot-multiple
 	trade.Quantity = qty + (dcrLotSize / 2)
-	checkCode("non-lot-multiple", msgjson.OrderParameterError)
+	checkCode("non-lot-multiple", msgjson.LotSizeError)
 	trade.Quantity = qty
 
 	// No utxos
@@ -1054,7 +1203,7 @@ func testPrefixTrade(prefix *msgjson.Prefix, trade *msgjson.Trade, fundingAsset,
 
 	// Not enough funding
 	trade.Coins = ogUTXOs[:1]
-	checkCode("unfunded", msgjson.FundingError)
+	checkCode("unfunded", msgjson.InsufficientFundsError)
 	trade.Coins = ogUTXOs
 
 	// Invalid address
@@ -1607,6 +1756,20 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+func TestIcebergBookNote(t *testing.T) {
+	lo, _ := ordertest.RandomLimitOrder()
+	lo.Quantity = 10 * dcrLotSize
+	lo.Visible = 2 * dcrLotSize
+	lo.SetFill(7 * dcrLotSize)
+	if note := limitOrderToMsgOrder(lo, "dcr_btc"); note.Quantity != 2*dcrLotSize {
+		t.Fatalf("iceberg order shown with quantity %d, expected %d", note.Quantity, 2*dcrLotSize)
+	}
+	lo.SetFill(9 * dcrLotSize)
+	if note := limitOrderToMsgOrder(lo, "dcr_btc"); note.Quantity != dcrLotSize {
+		t.Fatalf("iceberg order shown with quantity %d, expected %d", note.Quantity, dcrLotSize)
+	}
+}
+
 func TestBadMessages(t *testing.T) {
 	router := rig.router
 	link, sub := newSubscriber(mkt1)
@@ -0,0 +1,98 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"context"
+	"time"
+
+	"decred.org/dcrdex/dex/order"
+)
+
+// staleCheckInterval is how often a running market with a maximum order age
+// checks its book for stale orders.
+const staleCheckInterval = time.Minute
+
+// MaxOrderAge is how long an order may remain booked before it is revoked.
+// Zero means no limit.
+func (m *Market) MaxOrderAge() time.Duration {
+	return m.marketInfo.MaxOrderAge
+}
+
+// PurgeStaleOrders revokes the booked orders that were received more than
+// maxAge ago. The revocations are not counted against the users, who are sent
+// a revoke_order notification. The IDs of the revoked orders are returned.
+func (m *Market) PurgeStaleOrders(maxAge time.Duration) []order.OrderID {
+	cutoff := time.Now().Add(-maxAge)
+	var stale []*order.LimitOrder
+	m.bookMtx.Lock()
+	for _, lo := range append(m.book.BuyOrders(), m.book.SellOrders()...) {
+		if lo.ServerTime.Before(cutoff) {
+			stale = append(stale, lo)
+		}
+	}
+	m.bookMtx.Unlock()
+
+	revoked := make([]order.OrderID, 0, len(stale))
+	for _, lo := range stale {
+		if m.unbookStale(lo) {
+			revoked = append(revoked, lo.ID())
+		}
+	}
+	if len(revoked) > 0 {
+		log.Infof("Revoked %d booked orders older than %v on market %s.",
+			len(revoked), maxAge, m.marketInfo.Name)
+	}
+	return revoked
+}
+
+// unbookStale removes a stale order from the book, revokes it without counting
+// the revocation against the user, and notifies the book subscribers and the
+// user. unbookStale returns false if the order was no longer booked.
+func (m *Market) unbookStale(lo *order.LimitOrder) bool {
+	// Ensure we do not unbook during matching.
+	m.bookMtx.Lock()
+	_, removed := m.book.Remove(lo.ID())
+	if removed {
+		m.recordUnbook([]order.OrderID{lo.ID()})
+	}
+	m.bookMtx.Unlock()
+	if !removed {
+		return false
+	}
+
+	m.unlockOrderCoins(lo)
+
+	if _, _, err := m.storage.RevokeOrderUncounted(lo); err != nil {
+		log.Errorf("Failed to revoke stale order %v: %v", lo.ID(), err)
+	}
+
+	// Send "unbook" notification to order book subscribers.
+	m.sendToFeeds(&updateSignal{
+		action: unbookAction,
+		data: sigDataUnbookedOrder{
+			order:    lo,
+			epochIdx: -1, // NOTE: no epoch
+		},
+	})
+
+	m.sendRevokeOrder(lo)
+	return true
+}
+
+// purgeStaleLoop periodically revokes booked orders that are older than the
+// market's maximum order age, until the context is canceled.
+func (m *Market) purgeStaleLoop(ctx context.Context) {
+	maxAge := m.MaxOrderAge()
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+	for {
+		m.PurgeStaleOrders(maxAge)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+)
+
+// statsWindow is the period over which the market's trade statistics are
+// computed.
+const statsWindow = 24 * time.Hour
+
+// statsBinSize is the width of the bins that trades are aggregated into. Trades
+// leave the window one bin at a time.
+const statsBinSize = time.Hour
+
+// statsBin aggregates the trades in one statsBinSize period.
+type statsBin struct {
+	idx      int64 // period index, stamp / statsBinSize
+	baseVol  uint64
+	quoteVol uint64
+	open     uint64
+	high     uint64
+	low      uint64
+}
+
+// tradeStats tracks the rate and volume of a market's trades.
+type tradeStats struct {
+	mtx      sync.Mutex
+	lastRate uint64
+	bins     []*statsBin // oldest first
+}
+
+// record adds the trades in the match sets at the match time. Cancel order
+// matches are not trades and are skipped.
+func (s *tradeStats) record(matches []*order.MatchSet, stamp time.Time) {
+	idx := stamp.UnixNano() / int64(statsBinSize)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, set := range matches {
+		if _, ok := set.Taker.(*order.CancelOrder); ok {
+			continue
+		}
+		for i := range set.Makers {
+			qty, rate := set.Amounts[i], set.Rates[i]
+			var bin *statsBin
+			if n := len(s.bins); n > 0 && s.bins[n-1].idx == idx {
+				bin = s.bins[n-1]
+			} else {
+				bin = &statsBin{idx: idx, open: rate, high: rate, low: rate}
+				s.bins = append(s.bins, bin)
+			}
+			bin.baseVol += qty
+			bin.quoteVol += calc.BaseToQuote(rate, qty)
+			if rate > bin.high {
+				bin.high = rate
+			}
+			if rate < bin.low {
+				bin.low = rate
+			}
+			s.lastRate = rate
+		}
+	}
+	s.prune(idx)
+}
+
+// prune removes the bins that are outside of the window ending in the period
+// with index idx. The mutex must be held.
+func (s *tradeStats) prune(idx int64) {
+	oldest := idx - int64(statsWindow/statsBinSize) + 1
+	var i int
+	for i < len(s.bins) && s.bins[i].idx < oldest {
+		i++
+	}
+	s.bins = s.bins[i:]
+}
+
+// Stats is a summary of a market's trades over the last 24 hours. Rates are in
+// units of the quote asset per 1e8 units of the base asset, as with order
+// rates, and volumes are in atoms.
+type Stats struct {
+	// LastRate is the rate of the most recent trade, which may be older than
+	// 24 hours. LastRate is zero if the market has not traded since startup.
+	LastRate uint64
+	// BaseVol24 and QuoteVol24 are the traded volume in each asset.
+	BaseVol24  uint64
+	QuoteVol24 uint64
+	// High24 and Low24 are the highest and lowest trade rates. They are zero
+	// if there were no trades.
+	High24 uint64
+	Low24  uint64
+	// Open24 is the rate of the first trade in the window.
+	Open24 uint64
+}
+
+// stats summarizes the trades in the window ending at stamp.
+func (s *tradeStats) stats(stamp time.Time) *Stats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.prune(stamp.UnixNano() / int64(statsBinSize))
+	st := &Stats{LastRate: s.lastRate}
+	for i, bin := range s.bins {
+		if i == 0 {
+			st.Open24, st.Low24 = bin.open, bin.low
+		}
+		st.BaseVol24 += bin.baseVol
+		st.QuoteVol24 += bin.quoteVol
+		if bin.high > st.High24 {
+			st.High24 = bin.high
+		}
+		if bin.low < st.Low24 {
+			st.Low24 = bin.low
+		}
+	}
+	return st
+}
+
+// Stats returns a summary of the market's trades over the last 24 hours. Only
+// trades matched since the Market was created are included.
+func (m *Market) Stats() *Stats {
+	return m.stats.stats(time.Now())
+}
@@ -0,0 +1,74 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/dex/order"
+)
+
+func TestTradeStats(t *testing.T) {
+	var s tradeStats
+	now := time.Now()
+	maker := &order.LimitOrder{}
+
+	st := s.stats(now)
+	if st.LastRate != 0 || st.BaseVol24 != 0 || st.High24 != 0 {
+		t.Fatalf("non-zero stats with no trades: %+v", st)
+	}
+
+	// An old trade, which later leaves the window.
+	s.record([]*order.MatchSet{{
+		Taker:   &order.LimitOrder{},
+		Makers:  []*order.LimitOrder{maker},
+		Amounts: []uint64{5e8},
+		Rates:   []uint64{1e8},
+	}}, now.Add(-25*time.Hour))
+
+	s.record([]*order.MatchSet{{
+		Taker:   &order.MarketOrder{},
+		Makers:  []*order.LimitOrder{maker, maker},
+		Amounts: []uint64{1e8, 2e8},
+		Rates:   []uint64{3e6, 2e6},
+	}, {
+		// Cancel matches are not trades.
+		Taker:   &order.CancelOrder{},
+		Makers:  []*order.LimitOrder{maker},
+		Amounts: []uint64{7e8},
+		Rates:   []uint64{9e8},
+	}}, now.Add(-2*time.Hour))
+
+	s.record([]*order.MatchSet{{
+		Taker:   &order.LimitOrder{},
+		Makers:  []*order.LimitOrder{maker},
+		Amounts: []uint64{1e8},
+		Rates:   []uint64{4e6},
+	}}, now)
+
+	st = s.stats(now)
+	if st.LastRate != 4e6 {
+		t.Errorf("wrong last rate. wanted %d, got %d", uint64(4e6), st.LastRate)
+	}
+	if st.BaseVol24 != 4e8 {
+		t.Errorf("wrong base volume. wanted %d, got %d", uint64(4e8), st.BaseVol24)
+	}
+	// 1 * 0.03 + 2 * 0.02 + 1 * 0.04 = 0.11
+	if st.QuoteVol24 != 11e6 {
+		t.Errorf("wrong quote volume. wanted %d, got %d", uint64(11e6), st.QuoteVol24)
+	}
+	if st.Open24 != 3e6 || st.High24 != 4e6 || st.Low24 != 2e6 {
+		t.Errorf("wrong open/high/low. wanted 3e6/4e6/2e6, got %d/%d/%d", st.Open24, st.High24, st.Low24)
+	}
+	if len(s.bins) != 2 {
+		t.Errorf("expected 2 bins, found %d", len(s.bins))
+	}
+
+	// Once the window passes, only the last rate remains.
+	st = s.stats(now.Add(25 * time.Hour))
+	if st.LastRate != 4e6 || st.BaseVol24 != 0 || st.High24 != 0 || st.Low24 != 0 {
+		t.Errorf("wrong stats after the window: %+v", st)
+	}
+}
@@ -0,0 +1,110 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"sync"
+	"time"
+)
+
+// The stages of epoch processing that are timed.
+const (
+	// StagePreimages is preimage collection, including penalizing misses.
+	StagePreimages = iota
+	// StageMatching is order matching.
+	StageMatching
+	// StageDB is storing the epoch and the updated orders.
+	StageDB
+	// StageBook is unlocking coins and signaling book changes.
+	StageBook
+	// StageNotify is signaling the match proof and starting swap negotiation,
+	// which sends the match requests.
+	StageNotify
+
+	NumEpochStages
+)
+
+// EpochStages are the names of the stages of epoch processing, indexed by
+// stage.
+var EpochStages = [NumEpochStages]string{"preimages", "matching", "db", "book", "notify"}
+
+// maxRecentTimings is the number of recent epochs that have their timings
+// retained.
+const maxRecentTimings = 100
+
+// EpochTiming is the time spent in each stage of processing an epoch.
+type EpochTiming struct {
+	Epoch  int64
+	Orders int
+	Stages [NumEpochStages]time.Duration
+}
+
+// Total is the total processing time of the epoch.
+func (et *EpochTiming) Total() time.Duration {
+	var total time.Duration
+	for _, d := range et.Stages {
+		total += d
+	}
+	return total
+}
+
+// StageTiming summarizes the time spent in a stage for every epoch processed
+// since the market was created.
+type StageTiming struct {
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Timings are the epoch processing timings of a market.
+type Timings struct {
+	Stages [NumEpochStages]StageTiming
+	// Recent are the timings of the most recent epochs, oldest first.
+	Recent []*EpochTiming
+}
+
+// epochTimings collects the timings of a market's processed epochs.
+type epochTimings struct {
+	mtx    sync.Mutex
+	stages [NumEpochStages]StageTiming
+	recent []*EpochTiming
+}
+
+// record adds the timings of a processed epoch.
+func (t *epochTimings) record(et *EpochTiming) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for i, d := range et.Stages {
+		st := &t.stages[i]
+		st.Count++
+		st.Total += d
+		if d > st.Max {
+			st.Max = d
+		}
+	}
+	t.recent = append(t.recent, et)
+	if len(t.recent) > maxRecentTimings {
+		t.recent = t.recent[len(t.recent)-maxRecentTimings:]
+	}
+}
+
+// snapshot copies the timings.
+func (t *epochTimings) snapshot() *Timings {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	recent := make([]*EpochTiming, 0, len(t.recent))
+	for _, et := range t.recent {
+		etCopy := *et
+		recent = append(recent, &etCopy)
+	}
+	return &Timings{
+		Stages: t.stages,
+		Recent: recent,
+	}
+}
+
+// Timings returns the market's epoch processing timings.
+func (m *Market) Timings() *Timings {
+	return m.timings.snapshot()
+}
@@ -0,0 +1,48 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEpochTimings(t *testing.T) {
+	var timings epochTimings
+	for i := 0; i < maxRecentTimings+5; i++ {
+		et := &EpochTiming{Epoch: int64(i)}
+		et.Stages[StagePreimages] = time.Duration(i) * time.Millisecond
+		et.Stages[StageMatching] = time.Millisecond
+		timings.record(et)
+	}
+
+	snap := timings.snapshot()
+	if len(snap.Recent) != maxRecentTimings {
+		t.Fatalf("expected %d recent timings, got %d", maxRecentTimings, len(snap.Recent))
+	}
+	if snap.Recent[0].Epoch != 5 {
+		t.Fatalf("oldest timings not pruned. oldest epoch = %d", snap.Recent[0].Epoch)
+	}
+	last := snap.Recent[len(snap.Recent)-1]
+	if last.Total() != last.Stages[StagePreimages]+time.Millisecond {
+		t.Fatalf("wrong total %v", last.Total())
+	}
+
+	preimages := snap.Stages[StagePreimages]
+	if preimages.Count != maxRecentTimings+5 {
+		t.Fatalf("wrong count %d", preimages.Count)
+	}
+	if preimages.Max != (maxRecentTimings+4)*time.Millisecond {
+		t.Fatalf("wrong max %v", preimages.Max)
+	}
+	if snap.Stages[StageMatching].Total != (maxRecentTimings+5)*time.Millisecond {
+		t.Fatalf("wrong total %v", snap.Stages[StageMatching].Total)
+	}
+
+	// The snapshot is a copy.
+	snap.Recent[0].Epoch = -1
+	if timings.snapshot().Recent[0].Epoch != 5 {
+		t.Fatalf("snapshot not copied")
+	}
+}
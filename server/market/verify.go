@@ -0,0 +1,116 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package market
+
+import (
+	"errors"
+	"fmt"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/asset"
+)
+
+// BookDiscrepancy is a problem with a booked order found by VerifyBook.
+type BookDiscrepancy struct {
+	OrderID string `json:"orderID"`
+	Issue   string `json:"issue"`
+}
+
+// BookVerification is the result of a book integrity check.
+type BookVerification struct {
+	Market        string             `json:"market"`
+	BookedOrders  int                `json:"bookedOrders"`
+	StoredOrders  int                `json:"storedOrders"`
+	CoinsChecked  int                `json:"coinsChecked"`
+	Discrepancies []*BookDiscrepancy `json:"discrepancies"`
+}
+
+// VerifyBook cross-checks the in-memory order book against the booked orders
+// in storage, and checks that the funding coins of unfilled booked orders are
+// locked and still unspent according to the asset backend. Discrepancies are
+// reported, not repaired. The book is locked while storage is queried, so
+// matching is briefly delayed.
+func (m *Market) VerifyBook() (*BookVerification, error) {
+	base, quote := m.marketInfo.Base, m.marketInfo.Quote
+	m.bookMtx.Lock()
+	booked := append(m.book.BuyOrders(), m.book.SellOrders()...)
+	stored, err := m.storage.BookOrders(base, quote)
+	m.bookMtx.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored book orders: %w", err)
+	}
+
+	res := &BookVerification{
+		Market:        m.marketInfo.Name,
+		BookedOrders:  len(booked),
+		StoredOrders:  len(stored),
+		Discrepancies: make([]*BookDiscrepancy, 0),
+	}
+	report := func(oid order.OrderID, format string, args ...interface{}) {
+		res.Discrepancies = append(res.Discrepancies, &BookDiscrepancy{
+			OrderID: oid.String(),
+			Issue:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	storedByID := make(map[order.OrderID]*order.LimitOrder, len(stored))
+	for _, lo := range stored {
+		storedByID[lo.ID()] = lo
+	}
+
+	for _, lo := range booked {
+		oid := lo.ID()
+		slo, found := storedByID[oid]
+		if !found {
+			report(oid, "order is on the book but not stored as booked")
+		} else {
+			delete(storedByID, oid)
+			if slo.FillAmt != lo.FillAmt {
+				report(oid, "book fill amount %d does not match stored fill amount %d", lo.FillAmt, slo.FillAmt)
+			}
+			if slo.Rate != lo.Rate || slo.Sell != lo.Sell || slo.Quantity != lo.Quantity {
+				report(oid, "book order does not match stored order")
+			}
+		}
+
+		// Coins of partially filled orders are expected to be spent in swaps.
+		if lo.FillAmt > 0 {
+			continue
+		}
+		if len(m.coinsLocked(lo)) != len(lo.Coins) {
+			report(oid, "funding coins are not locked")
+		}
+		assetID := quote
+		if lo.Sell {
+			assetID = base
+		}
+		for _, coinID := range lo.Coins {
+			res.CoinsChecked++
+			err := m.swapper.CheckUnspent(assetID, coinID)
+			if err == nil {
+				continue
+			}
+			coin, decodeErr := asset.DecodeCoinID(dex.BipIDSymbol(assetID), coinID)
+			if decodeErr != nil {
+				coin = coinID.String()
+			}
+			if errors.Is(err, asset.CoinNotFoundError) {
+				report(oid, "funding coin %s is spent", coin)
+			} else {
+				report(oid, "failed to check funding coin %s: %v", coin, err)
+			}
+		}
+	}
+
+	for oid := range storedByID {
+		report(oid, "order is stored as booked but is not on the book")
+	}
+
+	if len(res.Discrepancies) > 0 {
+		log.Warnf("Book verification for market %s found %d discrepancies.",
+			m.marketInfo.Name, len(res.Discrepancies))
+	}
+	return res, nil
+}
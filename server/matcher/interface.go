@@ -14,4 +14,6 @@ type Booker interface {
 	BestBuy() *order.LimitOrder
 	Insert(*order.LimitOrder) bool
 	Remove(order.OrderID) (*order.LimitOrder, bool)
+	// Fill partially fills a booked order, which remains on the book.
+	Fill(*order.LimitOrder, uint64) bool
 }
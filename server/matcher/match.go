@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sort"
+	"sync/atomic"
 
 	"decred.org/dcrdex/dex/calc"
 	"decred.org/dcrdex/dex/order"
@@ -28,13 +29,44 @@ const (
 	peSize = order.PreimageSize
 )
 
-type Matcher struct{}
+// Matcher performs order matching for a market. The Matcher stamps the trade
+// matches with the market's trade fee rate.
+type Matcher struct {
+	tradeFee  uint32 // basis points
+	oddLotQty uint64 // atomic
+}
 
-// New creates a new Matcher.
+// New creates a new Matcher for a market without a trade fee.
 func New() *Matcher {
 	return &Matcher{}
 }
 
+// NewWithTradeFee creates a new Matcher for a market with a trade fee rate in
+// basis points.
+func NewWithTradeFee(tradeFee uint32) *Matcher {
+	return &Matcher{tradeFee: tradeFee}
+}
+
+// SetOddLotQty sets the quantity below which the remainder of a partially
+// filled limit order is an odd lot that is released rather than left on the
+// book. Released orders are completed. Zero keeps all remainders on the book.
+func (m *Matcher) SetOddLotQty(qty uint64) {
+	atomic.StoreUint64(&m.oddLotQty, qty)
+}
+
+// OddLotQty is the quantity below which the remainder of a partially filled
+// limit order is released. See SetOddLotQty.
+func (m *Matcher) OddLotQty() uint64 {
+	return atomic.LoadUint64(&m.oddLotQty)
+}
+
+// oddLot checks if the limit order is partially filled with a remainder that
+// is an odd lot to be released.
+func (m *Matcher) oddLot(lo *order.LimitOrder) bool {
+	remaining := lo.Remaining()
+	return lo.Filled() > 0 && remaining > 0 && remaining < m.OddLotQty()
+}
+
 // orderLotSizeOK checks if the remaining Order quantity is not a multiple of
 // lot size, unless the order is a market buy order, which is not subject to
 // this constraint.
@@ -125,7 +157,8 @@ type OrdersUpdated struct {
 	// a market order, this means it had a match that partially or completely
 	// filled it. For a limit order, this means the time-in-force is immediate
 	// with at least one match for any amount, or the time-in-force is standing
-	// and it is completely filled.
+	// and it is completely filled or partially filled with an odd lot
+	// remaining (see SetOddLotQty).
 	TradesCompleted []order.Order
 }
 
@@ -191,6 +224,7 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 			var makers []*order.LimitOrder
 			matchSet := matchLimitOrder(book, o)
 			if matchSet != nil {
+				matchSet.TradeFee = m.tradeFee
 				matches = append(matches, matchSet)
 				makers = matchSet.Makers
 			} else if o.Force == order.ImmediateTiF {
@@ -203,14 +237,9 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 			// Either matched or standing unmatched => passed.
 			passed = append(passed, q)
 
-			// Unbook matched makers with no remaining amount.
+			// Unbook matched makers with no remaining amount or an odd lot.
 			for _, maker := range makers {
-				if maker.Remaining() == 0 {
-					unbooked = append(unbooked, maker)
-					updates.TradesCompleted = append(updates.TradesCompleted, maker)
-				} else {
-					partialMap[maker.ID()] = maker
-				}
+				m.updateMaker(book, maker, partialMap, &unbooked, updates)
 			}
 
 			var wasBooked bool
@@ -218,8 +247,9 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 				if o.Filled() > 0 {
 					partial = append(partial, q)
 				}
-				if o.Force == order.StandingTiF {
-					// Standing TiF orders go on the book.
+				if o.Force == order.StandingTiF && !m.oddLot(o) {
+					// Standing TiF orders go on the book, unless the
+					// remainder is an odd lot to be released.
 					book.Insert(o)
 					booked = append(booked, q)
 					updates.TradesBooked = append(updates.TradesBooked, o)
@@ -246,6 +276,7 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 				matchSet = matchMarketBuyOrder(book, o)
 			}
 			if matchSet != nil {
+				matchSet.TradeFee = m.tradeFee
 				matches = append(matches, matchSet)
 				passed = append(passed, q)
 				doneOK = append(doneOK, q)
@@ -258,12 +289,7 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 			}
 
 			for _, maker := range matchSet.Makers {
-				if maker.Remaining() == 0 {
-					unbooked = append(unbooked, maker)
-					updates.TradesCompleted = append(updates.TradesCompleted, maker)
-				} else {
-					partialMap[maker.ID()] = maker
-				}
+				m.updateMaker(book, maker, partialMap, &unbooked, updates)
 			}
 
 			// Regardless of remaining amount, market orders never go on the book.
@@ -282,6 +308,29 @@ func (m *Matcher) Match(book Booker, queue []*OrderRevealed) (seed []byte, match
 	return
 }
 
+// updateMaker records the result of matching a maker order. Makers with no
+// remaining amount are unbooked and completed. Makers with an odd lot remaining
+// are removed from the book, unbooked and completed. Other makers are partially
+// filled and remain on the book.
+func (m *Matcher) updateMaker(book Booker, maker *order.LimitOrder, partialMap map[order.OrderID]*order.LimitOrder,
+	unbooked *[]*order.LimitOrder, updates *OrdersUpdated) {
+	switch {
+	case maker.Remaining() == 0:
+	case m.oddLot(maker):
+		if _, ok := book.Remove(maker.ID()); !ok {
+			log.Errorf("Failed to remove standing order %v with an odd lot.", maker)
+		}
+		log.Debugf("Released odd lot of %d from order %v.", maker.Remaining(), maker.ID())
+	default:
+		partialMap[maker.ID()] = maker
+		return
+	}
+	// The maker may have been partially filled earlier in the epoch.
+	delete(partialMap, maker.ID())
+	*unbooked = append(*unbooked, maker)
+	updates.TradesCompleted = append(updates.TradesCompleted, maker)
+}
+
 // limit-limit order matching
 func matchLimitOrder(book Booker, ord *order.LimitOrder) (matchSet *order.MatchSet) {
 	amtRemaining := ord.Remaining() // i.e. ord.Quantity - ord.FillAmt
@@ -321,13 +370,19 @@ func matchLimitOrder(book Booker, ord *order.LimitOrder) (matchSet *order.MatchS
 		if amtRemaining < amt {
 			// Partially fill the standing order, updating its value.
 			amt = amtRemaining
+			if !book.Fill(best, amt) {
+				// Still record the fill on the order so that it is not
+				// matched again beyond its quantity.
+				log.Errorf("Failed to fill standing order %v.", best)
+				best.AddFill(amt)
+			}
 		} else {
 			// The standing order has been consumed. Remove it from the book.
 			if _, ok := book.Remove(best.ID()); !ok {
 				log.Errorf("Failed to remove standing order %v.", best)
 			}
+			best.AddFill(amt)
 		}
-		best.AddFill(amt)
 
 		// Reduce the remaining quantity of the taker order.
 		amtRemaining -= amt
@@ -415,13 +470,19 @@ func matchMarketBuyOrder(book Booker, ord *order.MarketOrder) (matchSet *order.M
 		if amtRemainingBase < amt {
 			// Partially fill the standing order, updating its value.
 			amt = amtRemainingBase - amtRemainingBase%lotSize // amt is a multiple of lot size
+			if !book.Fill(best, amt) {
+				// Still record the fill on the order so that it is not
+				// matched again beyond its quantity.
+				log.Errorf("Failed to fill standing order %v.", best)
+				best.AddFill(amt)
+			}
 		} else {
 			// The standing order has been consumed. Remove it from the book.
 			if _, ok := book.Remove(best.ID()); !ok {
 				log.Errorf("Failed to remove standing order %v.", best)
 			}
+			best.AddFill(amt)
 		}
-		best.AddFill(amt)
 
 		// Reduce the remaining quantity of the taker order.
 		// amtRemainingBase -= amt // FYI
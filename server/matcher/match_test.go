@@ -249,6 +249,11 @@ func (b *BookStub) Remove(orderID order.OrderID) (*order.LimitOrder, bool) {
 	return nil, false
 }
 
+func (b *BookStub) Fill(ord *order.LimitOrder, amt uint64) bool {
+	ord.AddFill(amt)
+	return true
+}
+
 var _ Booker = (*BookStub)(nil)
 
 func newLimitOrder(sell bool, rate, quantityLots uint64, force order.TimeInForce, timeOffset int64) *order.LimitOrder {
@@ -934,6 +939,101 @@ func TestMatch_limitsOnly(t *testing.T) {
 	}
 }
 
+func TestMatch_tradeFee(t *testing.T) {
+	// Setup the match package's logger.
+	startLogger()
+
+	me := NewWithTradeFee(25)
+
+	queue := []*OrderRevealed{
+		newLimit(false, 4550000, 1, order.ImmediateTiF, 0), // buy, 1 lot, immediate, equal rate
+		newCancelOrder(bookBuyOrders[3].ID(), time.Now()),
+	}
+	_, matches, _, _, _, _, _, _, _ := me.Match(newBooker(), queue)
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 match sets, got %d", len(matches))
+	}
+	for _, set := range matches {
+		var wantFee uint32
+		if _, isCancel := set.Taker.(*order.CancelOrder); !isCancel {
+			wantFee = 25
+		}
+		if set.TradeFee != wantFee {
+			t.Errorf("wrong trade fee for %v taker. wanted %d, got %d", set.Taker.Type(), wantFee, set.TradeFee)
+		}
+		for _, match := range set.Matches() {
+			if match.TradeFee != wantFee {
+				t.Errorf("wrong match trade fee for %v taker. wanted %d, got %d", set.Taker.Type(), wantFee, match.TradeFee)
+			}
+		}
+	}
+}
+
+func TestMatch_oddLots(t *testing.T) {
+	// Setup the match package's logger.
+	startLogger()
+
+	me := New()
+	me.SetOddLotQty(3 * LotSize)
+	book := newBooker()
+	numSells, numBuys := book.SellCount(), book.BuyCount()
+
+	// The taker fills the 1 lot sell order and 1 lot of the 2 lot sell order,
+	// whose 1 lot remainder is an odd lot that is released.
+	queue := []*OrderRevealed{
+		newLimit(false, 4600000, 2, order.StandingTiF, 0),
+	}
+	_, matches, _, _, doneOK, _, booked, unbooked, updates := me.Match(book, queue)
+	if len(matches) != 1 || len(matches[0].Makers) != 2 {
+		t.Fatalf("expected 1 match set with 2 makers, got %d", len(matches))
+	}
+	released := bookSellOrders[9]
+	if released.Remaining() != LotSize {
+		t.Fatalf("expected an odd lot remaining, got %d", released.Remaining())
+	}
+	if len(unbooked) != 2 || unbooked[1] != released {
+		t.Fatalf("expected 2 unbooked makers including the odd lot, got %d", len(unbooked))
+	}
+	if len(updates.TradesPartial) != 0 {
+		t.Fatalf("expected no partial fills, got %d", len(updates.TradesPartial))
+	}
+	if len(updates.TradesCompleted) != 3 {
+		t.Fatalf("expected 3 completed orders, got %d", len(updates.TradesCompleted))
+	}
+	if len(doneOK) != 1 || len(booked) != 0 {
+		t.Fatalf("expected the taker to be done, got %d done, %d booked", len(doneOK), len(booked))
+	}
+	if book.SellCount() != numSells-2 {
+		t.Fatalf("expected %d sell orders on the book, got %d", numSells-2, book.SellCount())
+	}
+
+	// The taker fills the remaining 14 lots at the rate, and its 2 lot
+	// remainder is an odd lot that is released instead of booked.
+	taker := newLimit(false, 4700000, 16, order.StandingTiF, 0)
+	_, matches, _, _, doneOK, _, booked, unbooked, updates = me.Match(book, []*OrderRevealed{taker})
+	if len(matches) != 1 || matches[0].Total != 14*LotSize {
+		t.Fatalf("expected 1 match set for 14 lots")
+	}
+	if len(unbooked) != 2 {
+		t.Fatalf("expected 2 unbooked makers, got %d", len(unbooked))
+	}
+	if len(doneOK) != 1 || len(booked) != 0 || len(updates.TradesBooked) != 0 {
+		t.Fatalf("expected the taker to be done, got %d done, %d booked", len(doneOK), len(booked))
+	}
+	if book.BuyCount() != numBuys {
+		t.Fatalf("expected %d buy orders on the book, got %d", numBuys, book.BuyCount())
+	}
+
+	// Without odd lots, the remainder is booked.
+	me.SetOddLotQty(0)
+	book = newBooker()
+	_, _, _, _, _, _, booked, _, _ = me.Match(book, []*OrderRevealed{newLimit(false, 4600000, 4, order.StandingTiF, 0)})
+	if len(booked) != 1 {
+		t.Fatalf("expected the taker to be booked, got %d booked", len(booked))
+	}
+	resetMakers()
+}
+
 func TestMatch_marketSellsOnly(t *testing.T) {
 	// Setup the match package's logger.
 	startLogger()
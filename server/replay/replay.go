@@ -0,0 +1,456 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package replay records the order flow of a market and replays it through
+// the matcher offline. A recording begins with a snapshot of the book,
+// followed by one record for each epoch with the revealed orders in the epoch
+// queue, the market's trade fee rate and odd-lot quantity, the shuffle seed,
+// and a digest of the matching results. Replaying a recording re-runs every
+// epoch through a fresh book and a matcher with the recorded settings, and
+// reports any epoch where the seed or results differ from the recording, so
+// matcher changes can be checked against recorded order flow for identical
+// results.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/book"
+	"decred.org/dcrdex/server/matcher"
+	"github.com/decred/dcrd/crypto/blake256"
+)
+
+// Record types.
+const (
+	BookRecordType   = "book"
+	EpochRecordType  = "epoch"
+	UnbookRecordType = "unbook"
+)
+
+// Record is a single line of a recording. Exactly one of Book, Epoch, or
+// Unbook is set, according to Type.
+type Record struct {
+	Type   string        `json:"type"`
+	Book   *BookRecord   `json:"book,omitempty"`
+	Epoch  *EpochRecord  `json:"epoch,omitempty"`
+	Unbook *UnbookRecord `json:"unbook,omitempty"`
+}
+
+// BookRecord is a snapshot of a market's book at the start of a recording.
+type BookRecord struct {
+	Market  string      `json:"market"`
+	LotSize uint64      `json:"lotsize"`
+	Orders  []dex.Bytes `json:"orders"`
+}
+
+// RevealedOrder is an epoch queue order with its revealed preimage.
+type RevealedOrder struct {
+	Order    dex.Bytes `json:"order"`
+	Preimage dex.Bytes `json:"preimage"`
+}
+
+// EpochRecord is the matcher input and result for an epoch. TradeFee is the
+// market's trade fee rate in basis points, and OddLotQty is the quantity below
+// which the matcher released the remainders of partially filled orders (see
+// (*matcher.Matcher).SetOddLotQty).
+type EpochRecord struct {
+	Epoch     int64            `json:"epoch"`
+	Duration  int64            `json:"duration"`
+	TradeFee  uint32           `json:"tradefee,omitempty"`
+	OddLotQty uint64           `json:"oddlotqty,omitempty"`
+	Queue     []*RevealedOrder `json:"queue"`
+	Seed      dex.Bytes        `json:"seed"`
+	Result    dex.Bytes        `json:"result"`
+}
+
+// UnbookRecord lists orders that were removed from the book outside of
+// matching, such as by a book purge or a revoked order.
+type UnbookRecord struct {
+	Orders []dex.Bytes `json:"orders"`
+}
+
+// EncodeQueue encodes the epoch queue. The orders must be encoded before they
+// are matched, since matching updates their fill amounts.
+func EncodeQueue(queue []*matcher.OrderRevealed) []*RevealedOrder {
+	revealed := make([]*RevealedOrder, 0, len(queue))
+	for _, or := range queue {
+		pi := or.Preimage
+		revealed = append(revealed, &RevealedOrder{
+			Order:    order.EncodeOrder(or.Order),
+			Preimage: pi[:],
+		})
+	}
+	return revealed
+}
+
+// decodeQueue decodes the epoch queue.
+func decodeQueue(revealed []*RevealedOrder) ([]*matcher.OrderRevealed, error) {
+	queue := make([]*matcher.OrderRevealed, 0, len(revealed))
+	for _, ro := range revealed {
+		ord, err := order.DecodeOrder(ro.Order)
+		if err != nil {
+			return nil, err
+		}
+		if len(ro.Preimage) != order.PreimageSize {
+			return nil, fmt.Errorf("invalid preimage length %d for order %v", len(ro.Preimage), ord.ID())
+		}
+		var pi order.Preimage
+		copy(pi[:], ro.Preimage)
+		queue = append(queue, &matcher.OrderRevealed{
+			Order:    ord,
+			Preimage: pi,
+		})
+	}
+	return queue, nil
+}
+
+// newMatcher creates a matcher with the market's trade fee rate and odd-lot
+// quantity.
+func newMatcher(tradeFee uint32, oddLotQty uint64) *matcher.Matcher {
+	m := matcher.NewWithTradeFee(tradeFee)
+	m.SetOddLotQty(oddLotQty)
+	return m
+}
+
+// EpochBook returns copies of the book orders that are needed to replay an
+// epoch with the provided matches, with their fill amounts as they were before
+// matching. These are the makers from before the epoch that were matched or
+// canceled. Book orders that were not touched by matching are not needed,
+// since they could only have stopped a taker from matching further, as an
+// empty book would.
+func EpochBook(queue []*matcher.OrderRevealed, matches []*order.MatchSet) []*order.LimitOrder {
+	inQueue := make(map[order.OrderID]bool, len(queue))
+	for _, or := range queue {
+		inQueue[or.Order.ID()] = true
+	}
+
+	// Matched makers have their matched amounts removed from their fill to
+	// get the pre-epoch fill. The amount of a cancel match is the remaining
+	// amount of the canceled order, not a fill.
+	matched := make(map[order.OrderID]uint64)
+	var makers []*order.LimitOrder
+	for _, ms := range matches {
+		_, isCancel := ms.Taker.(*order.CancelOrder)
+		for i, maker := range ms.Makers {
+			oid := maker.ID()
+			if inQueue[oid] {
+				continue
+			}
+			amt, found := matched[oid]
+			if !found {
+				makers = append(makers, maker)
+			}
+			if !isCancel {
+				amt += ms.Amounts[i]
+			}
+			matched[oid] = amt
+		}
+	}
+
+	orders := make([]*order.LimitOrder, 0, len(makers))
+	for _, lo := range makers {
+		cp := &order.LimitOrder{
+			P:     lo.P,
+			T:     *lo.T.Copy(),
+			Rate:  lo.Rate,
+			Force: lo.Force,
+		}
+		cp.SetFill(lo.Filled() - matched[lo.ID()])
+		orders = append(orders, cp)
+	}
+	return orders
+}
+
+// ReplayEpoch matches the epoch queue against a book with the provided book
+// orders, returning the shuffle seed, the matches, and the digest of the
+// matching results, as computed by ResultDigest. The book orders may be the
+// subset of the book returned by EpochBook. The matcher uses the market's trade
+// fee rate and the odd-lot quantity in effect for the epoch. The orders are
+// modified by matching.
+func ReplayEpoch(lotSize uint64, tradeFee uint32, oddLotQty uint64, bookOrders []*order.LimitOrder,
+	queue []*matcher.OrderRevealed) (seed []byte, matches []*order.MatchSet, result []byte, err error) {
+	// The book only needs room for the book orders and the queue.
+	bk := book.New(lotSize, uint32(len(bookOrders)+len(queue)))
+	for _, lo := range bookOrders {
+		if !bk.Insert(lo) {
+			return nil, nil, nil, fmt.Errorf("failed to book order %v", lo.ID())
+		}
+	}
+	seed, matches, _, _, _, _, _, _, updates := newMatcher(tradeFee, oddLotQty).Match(bk, queue)
+	return seed, matches, ResultDigest(seed, matches, updates), nil
+}
+
+// ResultDigest computes a digest of the matching results for an epoch. The
+// digest covers the seed, every match set in order, and the order IDs in each
+// of the order update lists.
+func ResultDigest(seed []byte, matches []*order.MatchSet, updates *matcher.OrdersUpdated) []byte {
+	h := blake256.New()
+	b8 := make([]byte, 8)
+	writeUint64 := func(v uint64) {
+		binary.BigEndian.PutUint64(b8, v)
+		h.Write(b8)
+	}
+	writeID := func(oid order.OrderID) {
+		h.Write(oid[:])
+	}
+	h.Write(seed)
+	writeUint64(uint64(len(matches)))
+	for _, ms := range matches {
+		writeID(ms.Taker.ID())
+		writeUint64(uint64(len(ms.Makers)))
+		for i, maker := range ms.Makers {
+			writeID(maker.ID())
+			writeUint64(ms.Amounts[i])
+			writeUint64(ms.Rates[i])
+		}
+		writeUint64(ms.Total)
+	}
+	if updates == nil {
+		return h.Sum(nil)
+	}
+	// The matcher does not guarantee the order of the update lists, so the
+	// IDs in each list are sorted before hashing.
+	writeIDs := func(ids []order.OrderID) {
+		sort.Slice(ids, func(i, j int) bool {
+			return bytes.Compare(ids[i][:], ids[j][:]) < 0
+		})
+		writeUint64(uint64(len(ids)))
+		for _, oid := range ids {
+			writeID(oid)
+		}
+	}
+	writeOrders := func(ords []order.Order) {
+		ids := make([]order.OrderID, 0, len(ords))
+		for _, ord := range ords {
+			ids = append(ids, ord.ID())
+		}
+		writeIDs(ids)
+	}
+	writeLimits := func(los []*order.LimitOrder) {
+		ids := make([]order.OrderID, 0, len(los))
+		filled := make(map[order.OrderID]uint64, len(los))
+		for _, lo := range los {
+			oid := lo.ID()
+			ids = append(ids, oid)
+			filled[oid] = lo.Filled()
+		}
+		writeIDs(ids)
+		for _, oid := range ids {
+			writeUint64(filled[oid])
+		}
+	}
+	writeCancels := func(cos []*order.CancelOrder) {
+		ids := make([]order.OrderID, 0, len(cos))
+		for _, co := range cos {
+			ids = append(ids, co.ID())
+		}
+		writeIDs(ids)
+	}
+	writeCancels(updates.CancelsExecuted)
+	writeCancels(updates.CancelsFailed)
+	writeOrders(updates.TradesFailed)
+	writeLimits(updates.TradesBooked)
+	writeLimits(updates.TradesPartial)
+	writeLimits(updates.TradesCanceled)
+	writeOrders(updates.TradesCompleted)
+	return h.Sum(nil)
+}
+
+// Recorder writes a recording of a market's order flow. Recorder methods are
+// safe for concurrent use.
+type Recorder struct {
+	mtx sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder creates a Recorder that writes to w. The book snapshot must be
+// written with RecordBook before any epochs are recorded.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (r *Recorder) write(rec *Record) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.enc.Encode(rec)
+	return r.err
+}
+
+// RecordBook records the snapshot of the book at the start of the recording.
+func (r *Recorder) RecordBook(mkt string, lotSize uint64, orders []*order.LimitOrder) error {
+	encOrders := make([]dex.Bytes, 0, len(orders))
+	for _, lo := range orders {
+		encOrders = append(encOrders, order.EncodeOrder(lo))
+	}
+	return r.write(&Record{
+		Type: BookRecordType,
+		Book: &BookRecord{
+			Market:  mkt,
+			LotSize: lotSize,
+			Orders:  encOrders,
+		},
+	})
+}
+
+// RecordEpoch records the epoch queue, which must be encoded with EncodeQueue
+// before matching, the trade fee rate and odd-lot quantity that the matcher
+// used, and the results of matching.
+func (r *Recorder) RecordEpoch(epochIdx, epochDur int64, tradeFee uint32, oddLotQty uint64,
+	queue []*RevealedOrder, seed []byte, matches []*order.MatchSet, updates *matcher.OrdersUpdated) error {
+	return r.write(&Record{
+		Type: EpochRecordType,
+		Epoch: &EpochRecord{
+			Epoch:     epochIdx,
+			Duration:  epochDur,
+			TradeFee:  tradeFee,
+			OddLotQty: oddLotQty,
+			Queue:     queue,
+			Seed:      seed,
+			Result:    ResultDigest(seed, matches, updates),
+		},
+	})
+}
+
+// RecordUnbook records orders removed from the book outside of matching.
+func (r *Recorder) RecordUnbook(oids []order.OrderID) error {
+	ids := make([]dex.Bytes, 0, len(oids))
+	for i := range oids {
+		ids = append(ids, oids[i].Bytes())
+	}
+	return r.write(&Record{
+		Type:   UnbookRecordType,
+		Unbook: &UnbookRecord{Orders: ids},
+	})
+}
+
+// Mismatch describes an epoch with replay results that differ from the
+// recording.
+type Mismatch struct {
+	Epoch      int64
+	SeedDiffer bool
+	WantResult dex.Bytes
+	GotResult  dex.Bytes
+}
+
+// String describes the mismatch.
+func (m *Mismatch) String() string {
+	if m.SeedDiffer {
+		return fmt.Sprintf("epoch %d: shuffle seed differs", m.Epoch)
+	}
+	return fmt.Sprintf("epoch %d: result %s, recorded %s", m.Epoch, m.GotResult, m.WantResult)
+}
+
+// Summary is the result of a replay.
+type Summary struct {
+	Market     string
+	Epochs     int
+	Orders     int
+	Matches    int
+	Mismatches []*Mismatch
+}
+
+// Replay reads a recording and replays every epoch through a new book and a
+// matcher with the epoch's recorded trade fee rate and odd-lot quantity. Epochs with results that differ from the recording are listed in
+// the returned Summary's Mismatches. An error is only returned if the
+// recording cannot be read.
+func Replay(rd io.Reader) (*Summary, error) {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<28)
+	var bk *book.Book
+	summary := new(Summary)
+	for line := 1; scanner.Scan(); line++ {
+		rec := new(Record)
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		switch rec.Type {
+		case BookRecordType:
+			if bk != nil {
+				return nil, fmt.Errorf("line %d: multiple book records", line)
+			}
+			if rec.Book == nil {
+				return nil, fmt.Errorf("line %d: no book data", line)
+			}
+			summary.Market = rec.Book.Market
+			bk = book.New(rec.Book.LotSize)
+			for _, b := range rec.Book.Orders {
+				ord, err := order.DecodeOrder(b)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				lo, ok := ord.(*order.LimitOrder)
+				if !ok {
+					return nil, fmt.Errorf("line %d: non-limit order %v in book", line, ord.ID())
+				}
+				if !bk.Insert(lo) {
+					return nil, fmt.Errorf("line %d: failed to book order %v", line, lo.ID())
+				}
+			}
+		case EpochRecordType:
+			if bk == nil {
+				return nil, fmt.Errorf("line %d: epoch record before book record", line)
+			}
+			if rec.Epoch == nil {
+				return nil, fmt.Errorf("line %d: no epoch data", line)
+			}
+			queue, err := decodeQueue(rec.Epoch.Queue)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			m := newMatcher(rec.Epoch.TradeFee, rec.Epoch.OddLotQty)
+			seed, matches, _, _, _, _, _, _, updates := m.Match(bk, queue)
+			summary.Epochs++
+			summary.Orders += len(queue)
+			summary.Matches += len(matches)
+			result := ResultDigest(seed, matches, updates)
+			seedDiffers := string(seed) != string(rec.Epoch.Seed)
+			if seedDiffers || string(result) != string(rec.Epoch.Result) {
+				mm := &Mismatch{
+					Epoch:      rec.Epoch.Epoch,
+					SeedDiffer: seedDiffers,
+					WantResult: rec.Epoch.Result,
+					GotResult:  result,
+				}
+				log.Warnf("Replay mismatch: %v", mm)
+				summary.Mismatches = append(summary.Mismatches, mm)
+			}
+		case UnbookRecordType:
+			if bk == nil {
+				return nil, fmt.Errorf("line %d: unbook record before book record", line)
+			}
+			if rec.Unbook == nil {
+				return nil, fmt.Errorf("line %d: no unbook data", line)
+			}
+			for _, b := range rec.Unbook.Orders {
+				if len(b) != order.OrderIDSize {
+					return nil, fmt.Errorf("line %d: invalid order ID length %d", line, len(b))
+				}
+				var oid order.OrderID
+				copy(oid[:], b)
+				// The order may already have been removed by matching.
+				bk.Remove(oid)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type %q", line, rec.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
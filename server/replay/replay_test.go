@@ -0,0 +1,406 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"decred.org/dcrdex/dex/calc"
+	"decred.org/dcrdex/dex/order"
+	"decred.org/dcrdex/server/account"
+	"decred.org/dcrdex/server/book"
+	"decred.org/dcrdex/server/matcher"
+)
+
+var genCorpus = flag.Bool("gencorpus", false, "Regenerate the regression corpus in testdata.")
+
+const (
+	lotSize   = uint64(1e8)
+	rateStep  = uint64(1e4)
+	midRate   = uint64(1e6)
+	epochDur  = int64(10000)
+	buyAddr   = "DcqXswjTPnUcd4FRCkX4vRJxmVtfgGVa5ui"
+	sellAddr  = "149RQGLaHf2gGiL4NXZdH7aA8nYEuLLrgm"
+	startTime = int64(1584000000)
+)
+
+// flowGen generates random but valid order flow from a seeded source.
+type flowGen struct {
+	rnd    *rand.Rand
+	stamp  int64
+	booked []order.OrderID
+}
+
+func newFlowGen(seed int64) *flowGen {
+	return &flowGen{
+		rnd:   rand.New(rand.NewSource(seed)),
+		stamp: startTime,
+	}
+}
+
+func (g *flowGen) prefix(oType order.OrderType) (order.Prefix, order.Preimage) {
+	var pi order.Preimage
+	g.rnd.Read(pi[:])
+	var acct account.AccountID
+	g.rnd.Read(acct[:])
+	g.stamp++
+	return order.Prefix{
+		AccountID:  acct,
+		BaseAsset:  42,
+		QuoteAsset: 0,
+		OrderType:  oType,
+		ClientTime: time.Unix(g.stamp, 0),
+		ServerTime: time.Unix(g.stamp+1, 0),
+		Commit:     pi.Commit(),
+	}, pi
+}
+
+func (g *flowGen) trade(sell bool, qty uint64) order.Trade {
+	addr := buyAddr
+	if sell {
+		addr = sellAddr
+	}
+	return order.Trade{
+		Coins:    []order.CoinID{},
+		Sell:     sell,
+		Quantity: qty,
+		Address:  addr,
+	}
+}
+
+func (g *flowGen) limit(force order.TimeInForce) *matcher.OrderRevealed {
+	sell := g.rnd.Intn(2) == 0
+	p, pi := g.prefix(order.LimitOrderType)
+	rate := midRate + uint64(g.rnd.Intn(21)-10)*rateStep
+	lo := &order.LimitOrder{
+		P:     p,
+		T:     g.trade(sell, uint64(1+g.rnd.Intn(5))*lotSize),
+		Rate:  rate,
+		Force: force,
+	}
+	if force == order.StandingTiF {
+		g.booked = append(g.booked, lo.ID())
+	}
+	return &matcher.OrderRevealed{Order: lo, Preimage: pi}
+}
+
+func (g *flowGen) market() *matcher.OrderRevealed {
+	sell := g.rnd.Intn(2) == 0
+	p, pi := g.prefix(order.MarketOrderType)
+	lots := uint64(1 + g.rnd.Intn(3))
+	qty := lots * lotSize
+	if !sell {
+		qty = calc.BaseToQuote(midRate, qty)
+	}
+	return &matcher.OrderRevealed{
+		Order: &order.MarketOrder{
+			P: p,
+			T: g.trade(sell, qty),
+		},
+		Preimage: pi,
+	}
+}
+
+func (g *flowGen) cancel() *matcher.OrderRevealed {
+	if len(g.booked) == 0 {
+		return g.limit(order.StandingTiF)
+	}
+	p, pi := g.prefix(order.CancelOrderType)
+	i := g.rnd.Intn(len(g.booked))
+	target := g.booked[i]
+	g.booked = append(g.booked[:i], g.booked[i+1:]...)
+	return &matcher.OrderRevealed{
+		Order: &order.CancelOrder{
+			P:             p,
+			TargetOrderID: target,
+		},
+		Preimage: pi,
+	}
+}
+
+func (g *flowGen) queue() []*matcher.OrderRevealed {
+	n := g.rnd.Intn(8)
+	queue := make([]*matcher.OrderRevealed, 0, n)
+	for i := 0; i < n; i++ {
+		switch r := g.rnd.Intn(10); {
+		case r < 5:
+			queue = append(queue, g.limit(order.StandingTiF))
+		case r < 7:
+			queue = append(queue, g.limit(order.ImmediateTiF))
+		case r < 9:
+			queue = append(queue, g.market())
+		default:
+			queue = append(queue, g.cancel())
+		}
+	}
+	return queue
+}
+
+// record generates order flow for the specified number of epochs, matches it
+// with a book and matcher, and writes the recording.
+func record(t *testing.T, seed int64, epochs int) []byte {
+	t.Helper()
+	return recordMarket(t, seed, epochs, 0, 0)
+}
+
+// recordMarket is like record, but matches with the provided trade fee rate
+// and odd-lot quantity.
+func recordMarket(t *testing.T, seed int64, epochs int, tradeFee uint32, oddLotQty uint64) []byte {
+	t.Helper()
+	g := newFlowGen(seed)
+	bk := book.New(lotSize)
+	m := matcher.NewWithTradeFee(tradeFee)
+	m.SetOddLotQty(oddLotQty)
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	// Seed the book with some standing orders before recording begins.
+	for i := 0; i < 10; i++ {
+		m.Match(bk, []*matcher.OrderRevealed{g.limit(order.StandingTiF)})
+	}
+	if err := rec.RecordBook("dcr_btc", lotSize, append(bk.SellOrders(), bk.BuyOrders()...)); err != nil {
+		t.Fatalf("RecordBook error: %v", err)
+	}
+
+	epochIdx := startTime * 1000 / epochDur
+	for i := 0; i < epochs; i++ {
+		epochIdx++
+		queue := g.queue()
+		if len(queue) == 0 {
+			continue
+		}
+		encQueue := EncodeQueue(queue)
+		seed, matches, _, _, _, _, _, _, updates := m.Match(bk, queue)
+		if err := rec.RecordEpoch(epochIdx, epochDur, tradeFee, oddLotQty, encQueue, seed, matches, updates); err != nil {
+			t.Fatalf("RecordEpoch error: %v", err)
+		}
+		// Occasionally remove a booked order outside of matching, as a
+		// revocation would.
+		if g.rnd.Intn(10) == 0 {
+			if best := bk.BestSell(); best != nil {
+				bk.Remove(best.ID())
+				if err := rec.RecordUnbook([]order.OrderID{best.ID()}); err != nil {
+					t.Fatalf("RecordUnbook error: %v", err)
+				}
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReplay(t *testing.T) {
+	recording := record(t, 1, 200)
+	summary, err := Replay(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatalf("Replay error: %v", err)
+	}
+	if summary.Market != "dcr_btc" {
+		t.Errorf("wrong market %q", summary.Market)
+	}
+	if summary.Epochs == 0 || summary.Orders == 0 || summary.Matches == 0 {
+		t.Fatalf("replay did nothing: %+v", summary)
+	}
+	for _, mm := range summary.Mismatches {
+		t.Errorf("unexpected mismatch: %v", mm)
+	}
+}
+
+// TestReplayOddLotRelease checks that a recording of a market that releases odd
+// lots replays without mismatches, and that the recorded odd-lot quantity is
+// needed to reproduce the results.
+func TestReplayOddLotRelease(t *testing.T) {
+	recording := recordMarket(t, 4, 200, 20, 3*lotSize)
+	summary, err := Replay(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatalf("Replay error: %v", err)
+	}
+	if summary.Epochs == 0 || summary.Matches == 0 {
+		t.Fatalf("replay did nothing: %+v", summary)
+	}
+	for _, mm := range summary.Mismatches {
+		t.Errorf("unexpected mismatch: %v", mm)
+	}
+
+	// Without the odd-lot quantity, the released remainders stay on the book.
+	lines := strings.Split(strings.TrimSpace(string(recording)), "\n")
+	for i, line := range lines {
+		rec := new(Record)
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			t.Fatalf("error decoding record: %v", err)
+		}
+		if rec.Type != EpochRecordType {
+			continue
+		}
+		if rec.Epoch.OddLotQty != 3*lotSize || rec.Epoch.TradeFee != 20 {
+			t.Fatalf("wrong matcher settings recorded: odd lot %d, trade fee %d",
+				rec.Epoch.OddLotQty, rec.Epoch.TradeFee)
+		}
+		rec.Epoch.OddLotQty = 0
+		b, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatalf("error encoding record: %v", err)
+		}
+		lines[i] = string(b)
+	}
+	summary, err = Replay(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("Replay error: %v", err)
+	}
+	if len(summary.Mismatches) == 0 {
+		t.Fatalf("no mismatches without the odd-lot quantity")
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	recording := record(t, 2, 50)
+	lines := strings.Split(strings.TrimSpace(string(recording)), "\n")
+
+	// Replace the result digest of the first epoch with the digest for an
+	// empty epoch.
+	var tampered bool
+	for i, line := range lines {
+		rec := new(Record)
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			t.Fatalf("error decoding record: %v", err)
+		}
+		if rec.Type != EpochRecordType {
+			continue
+		}
+		rec.Epoch.Result = ResultDigest(rec.Epoch.Seed, nil, nil)
+		b, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatalf("error encoding record: %v", err)
+		}
+		lines[i] = string(b)
+		tampered = true
+		break
+	}
+	if !tampered {
+		t.Fatalf("no epoch records")
+	}
+
+	summary, err := Replay(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("Replay error: %v", err)
+	}
+	if len(summary.Mismatches) != 1 {
+		t.Fatalf("expected 1 mismatch, got %d", len(summary.Mismatches))
+	}
+	if summary.Mismatches[0].SeedDiffer {
+		t.Fatalf("seed should match")
+	}
+}
+
+// TestReplayEpoch checks that each epoch replayed with redacted orders and
+// only the book orders from EpochBook has the same results as matching with
+// the full book, on a market that releases odd lots.
+func TestReplayEpoch(t *testing.T) {
+	const tradeFee, oddLotQty = 20, 2 * lotSize
+	g := newFlowGen(3)
+	bk := book.New(lotSize, 1024)
+	m := matcher.NewWithTradeFee(tradeFee)
+	m.SetOddLotQty(oddLotQty)
+	for i := 0; i < 10; i++ {
+		m.Match(bk, []*matcher.OrderRevealed{g.limit(order.StandingTiF)})
+	}
+
+	var epochs, matched int
+	for i := 0; i < 200; i++ {
+		queue := g.queue()
+		if len(queue) == 0 {
+			continue
+		}
+		epochs++
+		redactedQueue := make([]*matcher.OrderRevealed, 0, len(queue))
+		for _, or := range queue {
+			redactedQueue = append(redactedQueue, &matcher.OrderRevealed{
+				Order:    order.RedactOrder(or.Order),
+				Preimage: or.Preimage,
+			})
+		}
+		seed, matches, _, _, _, _, _, _, updates := m.Match(bk, queue)
+		matched += len(matches)
+		result := ResultDigest(seed, matches, updates)
+
+		bookOrders := EpochBook(queue, matches)
+		for j, lo := range bookOrders {
+			bookOrders[j] = order.RedactOrder(lo).(*order.LimitOrder)
+		}
+		replaySeed, _, replayResult, err := ReplayEpoch(lotSize, tradeFee, oddLotQty, bookOrders, redactedQueue)
+		if err != nil {
+			t.Fatalf("ReplayEpoch error: %v", err)
+		}
+		if !bytes.Equal(seed, replaySeed) {
+			t.Fatalf("epoch %d: seed mismatch", i)
+		}
+		if !bytes.Equal(result, replayResult) {
+			t.Fatalf("epoch %d: result mismatch", i)
+		}
+	}
+	if epochs == 0 || matched == 0 {
+		t.Fatalf("nothing replayed")
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+	}{
+		{"bad json", "{"},
+		{"unknown type", `{"type":"nope"}`},
+		{"epoch before book", `{"type":"epoch","epoch":{}}`},
+		{"unbook before book", `{"type":"unbook","unbook":{}}`},
+		{"two books", `{"type":"book","book":{"lotsize":1}}` + "\n" + `{"type":"book","book":{"lotsize":1}}`},
+		{"bad order", `{"type":"book","book":{"lotsize":1,"orders":["00"]}}`},
+	}
+	for _, tt := range tests {
+		if _, err := Replay(strings.NewReader(tt.recording)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+// TestCorpus replays each recording in testdata. A mismatch means a matcher
+// change has altered the results for recorded order flow. Regenerate the
+// corpus with -gencorpus only when such a change is intended.
+func TestCorpus(t *testing.T) {
+	if *genCorpus {
+		for i := int64(1); i <= 3; i++ {
+			recording := record(t, i*1000, 150)
+			path := filepath.Join("testdata", fmt.Sprintf("corpus%d.rec", i))
+			if err := ioutil.WriteFile(path, recording, 0644); err != nil {
+				t.Fatalf("error writing %s: %v", path, err)
+			}
+		}
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "*.rec"))
+	if err != nil {
+		t.Fatalf("Glob error: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no corpus files")
+	}
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("error opening %s: %v", path, err)
+		}
+		summary, err := Replay(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: Replay error: %v", path, err)
+		}
+		for _, mm := range summary.Mismatches {
+			t.Errorf("%s: %v", path, mm)
+		}
+	}
+}
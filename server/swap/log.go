@@ -4,6 +4,8 @@
 package swap
 
 import (
+	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/server/account"
 	"github.com/decred/slog"
 )
 
@@ -21,3 +23,14 @@ func DisableLog() {
 func UseLogger(logger slog.Logger) {
 	log = logger
 }
+
+// acctLogger is the package logger with the account as a log attribute.
+func acctLogger(user account.AccountID) dex.Logger {
+	return dex.LoggerWithAttrs(log, dex.LogAttrAccount, user)
+}
+
+// matchLogger is the package logger with the account and match as log
+// attributes.
+func matchLogger(user account.AccountID, matchID interface{}) dex.Logger {
+	return dex.LoggerWithAttrs(log, dex.LogAttrAccount, user, dex.LogAttrMatch, matchID)
+}
@@ -1,8 +1,10 @@
 package swap
 
 import (
+	"bytes"
 	"encoding/gob"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -98,9 +100,19 @@ func LoadStateFile(fileName string) (*State, error) {
 	}
 	defer file.Close()
 
-	dec := gob.NewDecoder(file)
+	return decodeState(file)
+}
+
+// DecodeState decodes a swap state, such as the state of a market's matches
+// from (*Swapper).MarketState.
+func DecodeState(b []byte) (*State, error) {
+	return decodeState(bytes.NewReader(b))
+}
+
+func decodeState(r io.Reader) (*State, error) {
+	dec := gob.NewDecoder(r)
 	var state State
-	err = dec.Decode(&state)
+	err := dec.Decode(&state)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +124,42 @@ func LoadStateFile(fileName string) (*State, error) {
 	return &state, nil
 }
 
+// MergeState adds the matches of the imported State to the state, which may be
+// nil, returning the merged State. Matches that are already in the state are
+// not replaced.
+func MergeState(state, imported *State) *State {
+	if state == nil {
+		return imported
+	}
+	if state.MatchTrackers == nil {
+		state.MatchTrackers = make(map[order.MatchID]*matchTrackerData)
+	}
+	for mid, mtd := range imported.MatchTrackers {
+		if _, found := state.MatchTrackers[mid]; !found {
+			state.MatchTrackers[mid] = mtd
+		}
+	}
+	if state.OrderMatches == nil {
+		state.OrderMatches = make(map[order.OrderID]*orderSwapStat)
+	}
+	for oid, stat := range imported.OrderMatches {
+		if _, found := state.OrderMatches[oid]; !found {
+			state.OrderMatches[oid] = stat
+		}
+	}
+	have := make(map[uint32]bool, len(state.Assets))
+	for _, assetID := range state.Assets {
+		have[assetID] = true
+	}
+	for _, assetID := range imported.Assets {
+		if !have[assetID] {
+			state.Assets = append(state.Assets, assetID)
+			have[assetID] = true
+		}
+	}
+	return state
+}
+
 func init() {
 	// Register the concrete types implementing the order.Order interface.
 	gob.Register(&order.CancelOrder{})
@@ -142,6 +190,26 @@ func (mt *matchTracker) Data() *matchTrackerData {
 	}
 }
 
+// lockedData is like Data, but locks the matchTracker and swapStatuses while
+// the data is collected.
+func (mt *matchTracker) lockedData() *matchTrackerData {
+	mt.mtx.RLock()
+	match := *mt.Match
+	mt.mtx.RUnlock()
+	mt.makerStatus.mtx.RLock()
+	makerStatus := mt.makerStatus.Data()
+	mt.makerStatus.mtx.RUnlock()
+	mt.takerStatus.mtx.RLock()
+	takerStatus := mt.takerStatus.Data()
+	mt.takerStatus.mtx.RUnlock()
+	return &matchTrackerData{
+		Match:       &match,
+		Time:        encode.UnixMilli(mt.time),
+		MakerStatus: makerStatus,
+		TakerStatus: takerStatus,
+	}
+}
+
 type swapStatusData struct {
 	SwapAsset       uint32
 	RedeemAsset     uint32
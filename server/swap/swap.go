@@ -12,10 +12,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"decred.org/dcrdex/dex/calc"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
@@ -26,7 +28,9 @@ import (
 	"decred.org/dcrdex/server/coinlock"
 	"decred.org/dcrdex/server/comms"
 	"decred.org/dcrdex/server/db"
+	"decred.org/dcrdex/server/fault"
 	"decred.org/dcrdex/server/matcher"
+	"decred.org/dcrdex/server/trace"
 )
 
 var (
@@ -54,7 +58,7 @@ type AuthManager interface {
 		expireTimeout time.Duration, expireFunc func()) error
 	RequestWhenConnected(user account.AccountID, req *msgjson.Message, handlerFunc func(comms.Link, *msgjson.Message),
 		expireTimeout, connectTimeout time.Duration, expireFunc func())
-	Penalize(user account.AccountID, rule account.Rule) error
+	Penalize(user account.AccountID, rule account.Rule, details string, matchIDs ...order.MatchID) error
 	RecordCancel(user account.AccountID, oid, target order.OrderID, t time.Time)
 	RecordCompletedOrder(user account.AccountID, oid order.OrderID, t time.Time)
 }
@@ -68,6 +72,7 @@ type Storage interface {
 	CancelOrder(*order.LimitOrder) error
 	RevokeOrder(order.Order) (cancelID order.OrderID, t time.Time, err error)
 	SetOrderCompleteTime(ord order.Order, compTimeMs int64) error
+	RecordTradeFee(*db.TradeFee) error
 }
 
 // swapStatus is information related to the completion or incompletion of each
@@ -269,6 +274,10 @@ func (s *orderSwapTracker) canceled(ord order.Order) {
 type Swapper struct {
 	// Where state is dumped on shutdown.
 	dataDir string
+	// checkpointInterval is how often state is saved while running. The
+	// lastCheckpoint file is removed when it is replaced by a newer one.
+	checkpointInterval time.Duration
+	lastCheckpoint     string
 	// coins is a map to all the Asset information, including the asset backends,
 	// used by this Swapper.
 	coins map[uint32]*LockableAsset
@@ -281,6 +290,8 @@ type Swapper struct {
 	// revoked due to failure of the order's owner to complete the necessary
 	// actions.
 	unbookHook func(lo *order.LimitOrder) bool
+	// tracer records the events of matches.
+	tracer *trace.Tracer
 	// The matches map and the contained matches are protected by the matchMtx.
 	matchMtx sync.RWMutex
 	matches  map[order.MatchID]*matchTracker
@@ -316,6 +327,25 @@ type Swapper struct {
 	// and redeem acks, and match acks.
 	liveAckersMtx sync.Mutex
 	liveAckers    map[uint64]*msgAckers // keyed by server generated message ID
+
+	// failures counts the matches revoked for inaction, by market and the
+	// match status at which they failed.
+	failMtx  sync.Mutex
+	failures map[failureKey]uint64
+}
+
+type failureKey struct {
+	base, quote uint32
+	status      order.MatchStatus
+}
+
+// SwapFailures is the number of matches on a market that were revoked because
+// a party failed to act at a match status.
+type SwapFailures struct {
+	Base   uint32
+	Quote  uint32
+	Status order.MatchStatus
+	Count  uint64
 }
 
 // Config is the swapper configuration settings. A Config instance is the only
@@ -344,6 +374,12 @@ type Config struct {
 	// LockTimeTaker is the locktime Swapper will use for auditing maker swaps.
 	LockTimeMaker time.Duration
 	UnbookHook    func(lo *order.LimitOrder) bool
+	// CheckpointInterval is how often the swap state is saved to DataDir while
+	// the Swapper is running, so that a standby server can follow it. Zero
+	// disables checkpoints, and state is only saved on shutdown.
+	CheckpointInterval time.Duration
+	// Tracer records the events of matches. It may be nil.
+	Tracer *trace.Tracer
 }
 
 // NewSwapper is a constructor for a Swapper.
@@ -365,19 +401,22 @@ func NewSwapper(cfg *Config) (*Swapper, error) {
 
 	authMgr := cfg.AuthManager
 	swapper := &Swapper{
-		dataDir:       cfg.DataDir,
-		coins:         cfg.Assets,
-		storage:       cfg.Storage,
-		authMgr:       authMgr,
-		unbookHook:    cfg.UnbookHook,
-		latencyQ:      wait.NewTickerQueue(recheckInterval),
-		matches:       make(map[order.MatchID]*matchTracker),
-		orders:        newOrderSwapTracker(),
-		bTimeout:      cfg.BroadcastTimeout,
-		lockTimeTaker: cfg.LockTimeTaker,
-		lockTimeMaker: cfg.LockTimeMaker,
-		liveWaiters:   make(map[waiterKey]*handlerArgs),
-		liveAckers:    make(map[uint64]*msgAckers),
+		dataDir:            cfg.DataDir,
+		checkpointInterval: cfg.CheckpointInterval,
+		coins:              cfg.Assets,
+		storage:            cfg.Storage,
+		authMgr:            authMgr,
+		unbookHook:         cfg.UnbookHook,
+		tracer:             cfg.Tracer,
+		latencyQ:           wait.NewTickerQueue(recheckInterval),
+		matches:            make(map[order.MatchID]*matchTracker),
+		orders:             newOrderSwapTracker(),
+		bTimeout:           cfg.BroadcastTimeout,
+		lockTimeTaker:      cfg.LockTimeTaker,
+		lockTimeMaker:      cfg.LockTimeMaker,
+		liveWaiters:        make(map[waiterKey]*handlerArgs),
+		liveAckers:         make(map[uint64]*msgAckers),
+		failures:           make(map[failureKey]uint64),
 	}
 
 	if cfg.State != nil {
@@ -608,34 +647,23 @@ func (s *Swapper) restoreState(state *State, allowPartial bool) error {
 	return nil
 }
 
-func (s *Swapper) saveState() {
-	// Store state.
-	fName := fmt.Sprintf("swapState-%d.gob", encode.UnixMilli(time.Now()))
-	f, err := os.Create(filepath.Join(s.dataDir, fName))
-	if err != nil {
-		log.Errorf("Failed to create swap state file %v: %v", fName, err)
-		return
-	}
-	defer f.Close()
-
+// state collects the swap state for saving. The matches, orders, liveAckers,
+// and liveWaiters maps must not be modified while the State is in use.
+func (s *Swapper) state() *State {
 	mtd := make(map[order.MatchID]*matchTrackerData, len(s.matches))
 	neededAssets := make(map[uint32]struct{}, len(s.coins))
 	for matchID, mt := range s.matches {
-		neededAssets[mt.Match.Maker.BaseAsset] = struct{}{}
-		neededAssets[mt.Match.Maker.QuoteAsset] = struct{}{}
-		mtd[matchID] = &matchTrackerData{
-			Match:       mt.Match,
-			Time:        encode.UnixMilli(mt.time),
-			MakerStatus: mt.makerStatus.Data(),
-			TakerStatus: mt.takerStatus.Data(),
-		}
+		data := mt.lockedData()
+		neededAssets[data.Match.Maker.BaseAsset] = struct{}{}
+		neededAssets[data.Match.Maker.QuoteAsset] = struct{}{}
+		mtd[matchID] = data
 	}
 	assetIDs := make([]uint32, 0, len(neededAssets))
 	for id := range neededAssets {
 		assetIDs = append(assetIDs, id)
 	}
 
-	st := &State{
+	return &State{
 		Version:       stateBinaryVersion,
 		Assets:        assetIDs,
 		MatchTrackers: mtd,
@@ -643,20 +671,133 @@ func (s *Swapper) saveState() {
 		LiveAckers:    s.liveAckers,
 		LiveWaiters:   s.liveWaiters,
 	}
+}
+
+// MarketState encodes the swap state of the market's active matches, for the
+// export of the market to another server. See DecodeState. Outstanding
+// requests to clients and coin waiters are not included. The number of
+// matches in the state is also returned.
+func (s *Swapper) MarketState(base, quote uint32) ([]byte, int, error) {
+	s.matchMtx.RLock()
+	s.orders.mtx.Lock()
+	mtd := make(map[order.MatchID]*matchTrackerData)
+	orderMatches := make(map[order.OrderID]*orderSwapStat)
+	for matchID, mt := range s.matches {
+		if mt.Maker.BaseAsset != base || mt.Maker.QuoteAsset != quote {
+			continue
+		}
+		data := mt.lockedData()
+		mtd[matchID] = data
+		for _, oid := range []order.OrderID{data.Match.Maker.ID(), data.Match.Taker.ID()} {
+			if stat, found := s.orders.orderMatches[oid]; found {
+				orderMatches[oid] = stat
+			}
+		}
+	}
+	st := &State{
+		Version:       stateBinaryVersion,
+		Assets:        []uint32{base, quote},
+		MatchTrackers: mtd,
+		OrderMatches:  orderMatches,
+		LiveAckers:    make(map[uint64]*msgAckers),
+		LiveWaiters:   make(map[waiterKey]*handlerArgs),
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(st)
+	s.orders.mtx.Unlock()
+	s.matchMtx.RUnlock()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode swap state: %v", err)
+	}
+	return buf.Bytes(), len(mtd), nil
+}
+
+// writeStateFile writes the encoded state to a new state file in the data
+// directory, returning the file name. The data is written to a temporary file
+// that is renamed when complete, so a partially written state file is never
+// found by LatestStateFile.
+func (s *Swapper) writeStateFile(b []byte) (string, error) {
+	fName := fmt.Sprintf("swapState-%d.gob", encode.UnixMilli(time.Now()))
+	fPath := filepath.Join(s.dataDir, fName)
+	tmpPath := fPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create swap state file %v: %v", fName, err)
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write swap state data to disk: %v", err)
+	}
+	if err = os.Rename(tmpPath, fPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to rename swap state file %v: %v", fName, err)
+	}
+	return fName, nil
+}
 
-	enc := gob.NewEncoder(f)
-	if err = enc.Encode(st); err != nil {
-		log.Errorf("Failed to save swap state to file %v: %v", fName, err)
+// removeCheckpoint removes the last checkpoint file, which has been superseded
+// by a newer state file.
+func (s *Swapper) removeCheckpoint() {
+	if s.lastCheckpoint == "" {
 		return
 	}
+	if err := os.Remove(filepath.Join(s.dataDir, s.lastCheckpoint)); err != nil {
+		log.Warnf("Failed to remove old swap state checkpoint %v: %v", s.lastCheckpoint, err)
+	}
+	s.lastCheckpoint = ""
+}
 
-	if err = f.Sync(); err != nil {
-		log.Errorf("Failed to write swap state data to disk: %v", err)
-	} else {
-		log.Infof("Saved swap state to file %q, containing %d live matches with "+
-			"%d pending client acks and %d live coin waiters", fName,
-			len(st.MatchTrackers), len(st.LiveAckers), len(st.LiveWaiters))
+func (s *Swapper) saveState() {
+	st := s.state()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(st); err != nil {
+		log.Errorf("Failed to encode swap state: %v", err)
+		return
 	}
+	fName, err := s.writeStateFile(buf.Bytes())
+	if err != nil {
+		log.Errorf("Failed to save swap state: %v", err)
+		return
+	}
+	log.Infof("Saved swap state to file %q, containing %d live matches with "+
+		"%d pending client acks and %d live coin waiters", fName,
+		len(st.MatchTrackers), len(st.LiveAckers), len(st.LiveWaiters))
+	s.removeCheckpoint()
+}
+
+// checkpoint saves the swap state while the Swapper is running, replacing the
+// previous checkpoint.
+func (s *Swapper) checkpoint() {
+	// The state maps are locked until encoding is complete since they are
+	// modified by the comms handlers and the latency queue.
+	var buf bytes.Buffer
+	s.matchMtx.RLock()
+	s.orders.mtx.Lock()
+	s.liveAckersMtx.Lock()
+	s.liveWaitersMtx.Lock()
+	st := s.state()
+	err := gob.NewEncoder(&buf).Encode(st)
+	s.liveWaitersMtx.Unlock()
+	s.liveAckersMtx.Unlock()
+	s.orders.mtx.Unlock()
+	s.matchMtx.RUnlock()
+	if err != nil {
+		log.Errorf("Failed to encode swap state checkpoint: %v", err)
+		return
+	}
+
+	fName, err := s.writeStateFile(buf.Bytes())
+	if err != nil {
+		log.Errorf("Failed to save swap state checkpoint: %v", err)
+		return
+	}
+	log.Debugf("Saved swap state checkpoint %q with %d live matches", fName, len(st.MatchTrackers))
+	s.removeCheckpoint()
+	s.lastCheckpoint = fName
 }
 
 type msgAckers struct {
@@ -781,6 +922,14 @@ func (s *Swapper) Run(ctx context.Context) {
 						// Should not happen. Keep running until cancel.
 						continue
 					}
+					if d := fault.Delay(fault.BlockDelay); d > 0 {
+						log.Warnf("Fault injection: delaying asset %d block notification by %v", assetID, d)
+						select {
+						case <-time.After(d):
+						case <-ctxHelpers.Done():
+							return
+						}
+					}
 					// Do not block on anomalous return of main loop, which is
 					// the s.block receiver.
 					select {
@@ -820,6 +969,14 @@ func (s *Swapper) Run(ctx context.Context) {
 		bcastTicker = time.NewTimer(timeTil)
 	}
 
+	// Periodic state checkpoints, if enabled.
+	var checkpoints <-chan time.Time
+	if s.checkpointInterval > 0 {
+		checkpointTicker := time.NewTicker(s.checkpointInterval)
+		defer checkpointTicker.Stop()
+		checkpoints = checkpointTicker.C
+	}
+
 	// Main loop can stop on internal error via cancel(), or when the caller
 	// cancels the parent context triggering graceful shutdown.
 	wgMain.Add(1)
@@ -886,6 +1043,8 @@ func (s *Swapper) Run(ctx context.Context) {
 						setTimeout(bcastTriggers[0])
 					}
 				}
+			case <-checkpoints:
+				s.checkpoint()
 			case <-mainLoop:
 				return
 			}
@@ -896,22 +1055,31 @@ func (s *Swapper) Run(ctx context.Context) {
 	<-ctxMaster.Done()
 }
 
-func (s *Swapper) tryConfirmSwap(status *swapStatus) {
+// confirmWorkers is the most goroutines that check swap confirmations when a
+// block is processed for an asset whose backend cannot check them in a batch.
+const confirmWorkers = 8
+
+// tryConfirmSwap sets the swap confirmation time if the swap has the required
+// number of confirmations. The return value indicates whether the swap was
+// newly confirmed.
+func (s *Swapper) tryConfirmSwap(status *swapStatus) bool {
 	status.mtx.Lock()
 	defer status.mtx.Unlock()
 	if status.swapTime.IsZero() || !status.swapConfirmed.IsZero() {
-		return
+		return false
 	}
 	confs, err := status.swap.Confirmations()
 	if err != nil {
 		// The transaction has become invalid. No reason to do anything.
-		return
+		return false
 	}
 	// If a swapStatus was created, the asset.Asset is already known to be in
 	// the map.
 	if confs >= int64(s.coins[status.swapAsset].SwapConf) {
 		status.swapConfirmed = time.Now().UTC()
+		return true
 	}
+	return false
 }
 
 // processBlock scans the matches and updates match status based on number of
@@ -922,6 +1090,8 @@ func (s *Swapper) tryConfirmSwap(status *swapStatus) {
 // the appropriate flags in the swapStatus structures.
 func (s *Swapper) processBlock(block *blockNotification) {
 	var completions []*matchTracker
+	var unconfirmed []*swapStatus
+	swapMatches := make(map[*swapStatus]*matchTracker)
 	s.matchMtx.Lock()
 	defer s.matchMtx.Unlock()
 	for _, match := range s.matches {
@@ -943,7 +1113,8 @@ func (s *Swapper) processBlock(block *blockNotification) {
 			}
 			// If the maker has broadcast their transaction, the taker's broadcast
 			// timeout starts once the maker's swap has SwapConf confs.
-			s.tryConfirmSwap(match.makerStatus)
+			unconfirmed = append(unconfirmed, match.makerStatus)
+			swapMatches[match.makerStatus] = match
 		case order.TakerSwapCast:
 			if match.takerStatus.swapAsset != block.assetID {
 				break statusSwitch
@@ -951,7 +1122,8 @@ func (s *Swapper) processBlock(block *blockNotification) {
 			// If the taker has broadcast their transaction, the maker's broadcast
 			// timeout (for redemption) starts once the maker's swap has SwapConf
 			// confs.
-			s.tryConfirmSwap(match.takerStatus)
+			unconfirmed = append(unconfirmed, match.takerStatus)
+			swapMatches[match.takerStatus] = match
 		case order.MakerRedeemed:
 			// It's the taker's turn to redeem. Nothing to do here.
 			break statusSwitch
@@ -974,6 +1146,18 @@ func (s *Swapper) processBlock(block *blockNotification) {
 		match.mtx.RUnlock()
 	}
 
+	for _, status := range s.confirmSwaps(block.assetID, unconfirmed) {
+		match := swapMatches[status]
+		milestone := msgjson.MakerSwapConfirmed
+		if status == match.takerStatus {
+			milestone = msgjson.TakerSwapConfirmed
+		}
+		status.mtx.RLock()
+		coinID, confirmed := status.swap.ID(), status.swapConfirmed
+		status.mtx.RUnlock()
+		s.sendProgress(match, milestone, status.swapAsset, coinID, confirmed)
+	}
+
 	for _, match := range completions {
 		// Note that orders are not considered completed for the purposes of
 		// cancellation ratio until the user sends their redeem ack.
@@ -988,6 +1172,94 @@ func (s *Swapper) processBlock(block *blockNotification) {
 	}
 }
 
+// confirmSwaps checks the confirmations of the swaps of the asset affected by a
+// new block, and returns the swaps that were newly confirmed. If the asset
+// backend is an asset.BatchConfirmer, the swaps are checked in a single batch.
+// Otherwise, they are checked concurrently by up to confirmWorkers goroutines.
+func (s *Swapper) confirmSwaps(assetID uint32, statuses []*swapStatus) []*swapStatus {
+	if len(statuses) == 0 {
+		return nil
+	}
+	if batcher, ok := s.coins[assetID].Backend.(asset.BatchConfirmer); ok {
+		return s.batchConfirmSwaps(batcher, assetID, statuses)
+	}
+	if len(statuses) == 1 {
+		if s.tryConfirmSwap(statuses[0]) {
+			return statuses
+		}
+		return nil
+	}
+
+	workers := confirmWorkers
+	if len(statuses) < workers {
+		workers = len(statuses)
+	}
+	statusChan := make(chan *swapStatus)
+	var confirmedMtx sync.Mutex
+	var confirmed []*swapStatus
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for status := range statusChan {
+				if s.tryConfirmSwap(status) {
+					confirmedMtx.Lock()
+					confirmed = append(confirmed, status)
+					confirmedMtx.Unlock()
+				}
+			}
+		}()
+	}
+	for _, status := range statuses {
+		statusChan <- status
+	}
+	close(statusChan)
+	wg.Wait()
+	return confirmed
+}
+
+// batchConfirmSwaps checks the confirmations of the swaps with a single call to
+// the asset backend's BatchConfirmations method. As with tryConfirmSwap, each
+// swapStatus is locked while its swap is checked. Only the Swapper's main loop
+// locks more than one swapStatus at a time. The swaps that were newly confirmed
+// are returned.
+func (s *Swapper) batchConfirmSwaps(batcher asset.BatchConfirmer, assetID uint32, statuses []*swapStatus) []*swapStatus {
+	checking := make([]*swapStatus, 0, len(statuses))
+	coins := make([]asset.Coin, 0, len(statuses))
+	for _, status := range statuses {
+		status.mtx.Lock()
+		if status.swapTime.IsZero() || !status.swapConfirmed.IsZero() {
+			status.mtx.Unlock()
+			continue
+		}
+		checking = append(checking, status)
+		coins = append(coins, status.swap)
+	}
+	defer func() {
+		for _, status := range checking {
+			status.mtx.Unlock()
+		}
+	}()
+	if len(coins) == 0 {
+		return nil
+	}
+
+	confs, errs := batcher.BatchConfirmations(coins)
+	swapConf := int64(s.coins[assetID].SwapConf)
+	now := time.Now().UTC()
+	var confirmed []*swapStatus
+	for i, status := range checking {
+		// An error means the transaction has become invalid. No reason to do
+		// anything.
+		if errs[i] == nil && confs[i] >= swapConf {
+			status.swapConfirmed = now
+			confirmed = append(confirmed, status)
+		}
+	}
+	return confirmed
+}
+
 // RedeemStatus checks maker and taker redemption completion status.
 func (s *Swapper) RedeemStatus(mStatus, tStatus *swapStatus) (makerRedeemComplete, takerRedeemComplete bool) {
 	mStatus.mtx.RLock()
@@ -1072,6 +1344,7 @@ func (s *Swapper) checkInaction(assetID uint32) {
 				match.ID(), makerFault, match.Status)
 
 			deletions = append(deletions, match)
+			s.countFailure(match.Maker.BaseAsset, match.Maker.QuoteAsset, match.Status)
 
 			// Record the end of this match's processing.
 			s.storage.SetMatchInactive(db.MatchID(match.Match))
@@ -1116,7 +1389,9 @@ func (s *Swapper) checkInaction(assetID uint32) {
 			// may become less severe than account closure (e.g. temporary
 			// suspension, cool down, or order throttling), and restored
 			// accounts will still require a record of the revoked order.
-			s.authMgr.Penalize(orderAtFault.User(), account.FailureToAct)
+			s.authMgr.Penalize(orderAtFault.User(), account.FailureToAct,
+				fmt.Sprintf("failed to act on match %v of order %v at status %v", match.ID(), orderAtFault.ID(), match.Status),
+				match.ID())
 
 			// Send the revoke_match messages, and solicit acks.
 			s.revoke(match)
@@ -1351,7 +1626,7 @@ func (s *Swapper) step(user account.AccountID, matchID order.MatchID) (*stepInfo
 		checkVal = matcher.BaseToQuote(maker.Rate, match.Quantity)
 	}
 
-	log.Debugf("step(user=%v, match=%v): ready for user %v action, status %v, next %v",
+	matchLogger(actor.user, matchID).Debugf("step(user=%v, match=%v): ready for user %v action, status %v, next %v",
 		actor.user, matchID, counterParty.user, match.Status, nextStep)
 
 	return &stepInformation{
@@ -1401,6 +1676,11 @@ func (s *Swapper) processAck(msg *msgjson.Message, acker *messageAcker) {
 	// Remove the live acker from Swapper's tracking.
 	defer s.rmLiveAckers(msg.ID)
 
+	if fault.Hit(fault.DropAck) {
+		matchLogger(acker.user, acker.match.ID()).Warnf("Fault injection: dropping ack for match %v from user %v", acker.match.ID(), acker.user)
+		return
+	}
+
 	// The time that the ack is received is stored for redeem acks to facilitate
 	// cancellation ratio enforcement.
 	tAck := time.Now()
@@ -1519,7 +1799,7 @@ func (s *Swapper) processInit(msg *msgjson.Message, params *msgjson.Init, stepIn
 		if err == asset.CoinNotFoundError {
 			return wait.TryAgain
 		}
-		log.Warnf("Contract error encountered for match %s, actor %s using coin ID %x and contract %x: %v",
+		matchLogger(actor.user, stepInfo.match.ID()).Warnf("Contract error encountered for match %s, actor %s using coin ID %x and contract %x: %v",
 			stepInfo.match.ID(), actor.user, params.CoinID, params.Contract, err)
 		s.respondError(msg.ID, actor.user, msgjson.ContractError,
 			"redemption error")
@@ -1593,8 +1873,8 @@ func (s *Swapper) processInit(msg *msgjson.Message, params *msgjson.Init, stepIn
 	stepInfo.match.Status = stepInfo.nextStep
 	stepInfo.match.mtx.Unlock()
 
-	log.Debugf("processInit: valid contract received at %v from %v for match %v, "+
-		"swapStatus %v => %v", swapTime, actor.user, matchID, stepInfo.step, stepInfo.nextStep)
+	s.tracer.KeyLogf(log, "SWAP", matchID.String(), "valid contract received at %v from %v, "+
+		"swapStatus %v => %v", swapTime, actor.user, stepInfo.step, stepInfo.nextStep)
 
 	// Issue a positive response to the actor.
 	s.authMgr.Sign(params)
@@ -1692,8 +1972,8 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 		if err == asset.CoinNotFoundError {
 			return wait.TryAgain
 		}
-		log.Warnf("Redemption error encountered for match %s, actor %s using coin ID %x to satisfy contract at %x: %v",
-			stepInfo.match.ID(), actor, params.CoinID, cpSwapCoin, err)
+		matchLogger(actor.user, stepInfo.match.ID()).Warnf("Redemption error encountered for match %s, actor %s using coin ID %x to satisfy contract at %x: %v",
+			stepInfo.match.ID(), actor.user, params.CoinID, cpSwapCoin, err)
 		s.respondError(msg.ID, actor.user, msgjson.RedemptionError, "redemption error")
 		return wait.DontTryAgain
 	}
@@ -1721,6 +2001,21 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 		return wait.TryAgain // why not, the DB might come alive
 	}
 
+	// The redeeming party has received the counterparty's swap, and owes the
+	// trade fee on the amount received.
+	if match.TradeFee > 0 {
+		fee := &db.TradeFee{
+			MatchID:   matchID,
+			AccountID: actor.user,
+			AssetID:   actor.swapAsset,
+			Amount:    calc.TradeFee(stepInfo.checkVal, match.TradeFee),
+			Stamp:     redeemTimeMs,
+		}
+		if err = s.storage.RecordTradeFee(fee); err != nil {
+			matchLogger(actor.user, matchID).Errorf("failed to record trade fee for user %v, match %v: %v", actor.user, matchID, err)
+		}
+	}
+
 	// Modify the match's swapStatuses.
 	actor.status.mtx.Lock()
 	actor.status.redemption = redemption
@@ -1731,8 +2026,8 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 	match.Status = stepInfo.nextStep
 	match.mtx.Unlock()
 
-	log.Debugf("processRedeem: valid redemption received at %v from %v for match %v, "+
-		"swapStatus %v => %v", redeemTime, actor.user, matchID, stepInfo.step, stepInfo.nextStep)
+	s.tracer.KeyLogf(log, "SWAP", matchID.String(), "valid redemption received at %v from %v, "+
+		"swapStatus %v => %v", redeemTime, actor.user, stepInfo.step, stepInfo.nextStep)
 
 	// Issue a positive response to the actor.
 	s.authMgr.Sign(params)
@@ -1744,6 +2039,17 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 		Sig:     params.Sig,
 	})
 
+	milestone := msgjson.TakerRedeemed
+	if actor.isMaker {
+		milestone = msgjson.MakerRedeemed
+	}
+	s.sendProgress(match, milestone, actor.status.redeemAsset, params.CoinID, redeemTime)
+
+	// The taker's redemption settles the match. Send both parties a receipt.
+	if stepInfo.nextStep == order.MatchComplete {
+		s.sendReceipts(match, redeemTimeMs)
+	}
+
 	// Inform the counterparty.
 	rParams := &msgjson.Redemption{
 		Redeem: msgjson.Redeem{
@@ -1779,7 +2085,7 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 	// Expire function to unregister the outstanding request.
 	expireFunc := func() {
 		s.rmLiveAckers(notification.ID)
-		log.Infof("redeem ack request failed for user %v (maker=%v).",
+		matchLogger(ack.user, ack.match.ID()).Infof("redeem ack request failed for user %v (maker=%v).",
 			ack.user, ack.isMaker)
 	}
 
@@ -1794,6 +2100,76 @@ func (s *Swapper) processRedeem(msg *msgjson.Message, params *msgjson.Redeem, st
 	return wait.DontTryAgain
 }
 
+// sendReceipts sends a signed settlement receipt for a completed match to both
+// the maker and the taker. The receipt can also be retrieved later with a
+// match_receipt request.
+func (s *Swapper) sendReceipts(match *matchTracker, completeTimeMs int64) {
+	matchID := match.ID()
+	makerOID, takerOID := match.Maker.ID(), match.Taker.ID()
+	receipt := &msgjson.SettlementReceipt{
+		MatchID:      matchID[:],
+		MakerOrderID: makerOID[:],
+		TakerOrderID: takerOID[:],
+		Base:         match.Maker.BaseAsset,
+		Quote:        match.Maker.QuoteAsset,
+		Quantity:     match.Quantity,
+		Rate:         match.Rate,
+		Time:         uint64(completeTimeMs),
+	}
+	match.makerStatus.mtx.RLock()
+	receipt.MakerSwap = match.makerStatus.swap.ID()
+	receipt.MakerRedeem = match.makerStatus.redemption.ID()
+	match.makerStatus.mtx.RUnlock()
+	match.takerStatus.mtx.RLock()
+	receipt.TakerSwap = match.takerStatus.swap.ID()
+	receipt.TakerRedeem = match.takerStatus.redemption.ID()
+	match.takerStatus.mtx.RUnlock()
+
+	if err := s.authMgr.Sign(receipt); err != nil {
+		log.Errorf("error signing settlement receipt for match %v: %v", matchID, err)
+		return
+	}
+	note, err := msgjson.NewNotification(msgjson.ReceiptRoute, receipt)
+	if err != nil {
+		log.Errorf("error creating settlement receipt notification: %v", err)
+		return
+	}
+	for _, user := range []account.AccountID{match.Maker.User(), match.Taker.User()} {
+		user := user
+		s.authMgr.SendWhenConnected(user, note, auth.DefaultConnectTimeout, func() {
+			matchLogger(user, matchID).Infof("Unable to send settlement receipt for match %v to disconnected user %v",
+				matchID, user)
+		})
+	}
+}
+
+// sendProgress informs both parties of the match that it reached a settlement
+// milestone. The notification is not queued for a disconnected user, who can
+// request the match status on reconnect.
+func (s *Swapper) sendProgress(match *matchTracker, milestone string, assetID uint32, coinID []byte, stamp time.Time) {
+	matchID := match.ID()
+	makerOID, takerOID := match.Maker.ID(), match.Taker.ID()
+	note, err := msgjson.NewNotification(msgjson.SettlementProgressRoute, &msgjson.SettlementProgress{
+		MatchID:      matchID[:],
+		MakerOrderID: makerOID[:],
+		TakerOrderID: takerOID[:],
+		Milestone:    milestone,
+		AssetID:      assetID,
+		CoinID:       coinID,
+		Time:         uint64(encode.UnixMilli(stamp)),
+	})
+	if err != nil {
+		log.Errorf("error creating settlement progress notification: %v", err)
+		return
+	}
+	for _, user := range []account.AccountID{match.Maker.User(), match.Taker.User()} {
+		if err = s.authMgr.Send(user, note); err != nil {
+			matchLogger(user, matchID).Debugf("Unable to send %s progress for match %v to user %v: %v",
+				milestone, matchID, user, err)
+		}
+	}
+}
+
 // handleInit handles the 'init' request from a user, which is used to inform
 // the DEX of a newly broadcast swap transaction. The Init message includes the
 // swap contract script and the CoinID of contract. Most of the work is
@@ -1824,7 +2200,7 @@ func (s *Swapper) handleInit(user account.AccountID, msg *msgjson.Message) *msgj
 		return rpcErr
 	}
 
-	log.Debugf("handleInit: 'init' received from %v for match %v, order %v",
+	matchLogger(user, params.MatchID).Debugf("handleInit: 'init' received from %v for match %v, order %v",
 		user, params.MatchID, params.OrderID)
 
 	if len(params.MatchID) != order.MatchIDSize {
@@ -1939,7 +2315,7 @@ func (s *Swapper) handleRedeem(user account.AccountID, msg *msgjson.Message) *ms
 		return rpcErr
 	}
 
-	log.Debugf("handleRedeem: 'redeem' received from %v for match %v, order %v",
+	matchLogger(user, params.MatchID).Debugf("handleRedeem: 'redeem' received from %v for match %v, order %v",
 		user, params.MatchID, params.OrderID)
 
 	if len(params.MatchID) != order.MatchIDSize {
@@ -2019,6 +2395,7 @@ func (s *Swapper) revocationRequests(match *matchTracker) (*msgjson.RevokeMatch,
 // and processing the acknowledgement. Match Sigs and Status are not accessed.
 func (s *Swapper) revoke(match *matchTracker) {
 	log.Infof("revoke: sending the 'revoke_match' request to each client for match %v", match.ID())
+	s.tracer.KeyLogf(log, "SWAP", match.ID().String(), "match revoked")
 	// Unlock the maker and taker order coins.
 	s.unlockOrderCoins(match.Taker)
 	s.unlockOrderCoins(match.Maker)
@@ -2034,7 +2411,7 @@ func (s *Swapper) revoke(match *matchTracker) {
 		// Expire function to unregister the outstanding request.
 		expireFunc := func() {
 			s.rmLiveAckers(req.ID)
-			log.Infof("revoke_match request failed for user %v (taker)", user)
+			matchLogger(user, match.ID()).Infof("revoke_match request failed for user %v (taker)", user)
 		}
 		// Register that there is an outstanding request.
 		s.setLiveAcker(req, ack)
@@ -2101,7 +2478,7 @@ func (s *Swapper) processMatchAcks(user account.AccountID, msg *msgjson.Message,
 		sigMsg := matchInfo.params.Serialize()
 		err = s.authMgr.Auth(user, sigMsg, ack.Sig)
 		if err != nil {
-			log.Warnf("processMatchAcks: 'match' ack for match %v from user %v, "+
+			matchLogger(user, matchInfo.match.ID()).Warnf("processMatchAcks: 'match' ack for match %v from user %v, "+
 				" failed sig verification: %v", matchInfo.match.ID(), user, err)
 			s.respondError(msg.ID, user, msgjson.SignatureError,
 				fmt.Sprintf("signature validation error: %v", err))
@@ -2130,7 +2507,7 @@ func (s *Swapper) processMatchAcks(user account.AccountID, msg *msgjson.Message,
 
 		// Store the signature in the matchTracker. These must be collected
 		// before the init steps begin and swap contracts are broadcasted.
-		log.Debugf("processMatchAcks: storing valid 'match' ack signature from %v (maker=%v) "+
+		matchLogger(user, matchID).Debugf("processMatchAcks: storing valid 'match' ack signature from %v (maker=%v) "+
 			"for match %v (status %v)", user, matchInfo.isMaker, matchID, matchInfo.match.Status)
 		matchInfo.match.mtx.Lock()
 		if matchInfo.isMaker {
@@ -2432,6 +2809,12 @@ func (s *Swapper) Negotiate(matchSets []*order.MatchSet, finalSwap map[order.Ord
 			s.orders.incActiveSwapCount(match.Taker, finalSwap[match.Taker.ID()])
 		}
 
+		// Trace the match under the requests of both orders.
+		mid := match.ID().String()
+		s.tracer.LinkKey(mid, match.Maker.ID().String(), match.Taker.ID().String())
+		s.tracer.KeyLogf(log, "SWAP", mid, "negotiating match of maker order %v and taker order %v",
+			match.Maker.ID(), match.Taker.ID())
+
 		// Create an acker for maker and taker, sharing the same matchTracker.
 		makerMsg, takerMsg := matchNotifications(match) // msgjson.Match for each party
 		addUserMatch(&messageAcker{
@@ -2506,9 +2889,65 @@ func (s *Swapper) Negotiate(matchSets []*order.MatchSet, finalSwap map[order.Ord
 	}
 }
 
+// MarketMatches returns the number of active matches on the market with the
+// given base and quote assets.
+func (s *Swapper) MarketMatches(base, quote uint32) int {
+	s.matchMtx.RLock()
+	defer s.matchMtx.RUnlock()
+	var n int
+	for _, mt := range s.matches {
+		if mt.Maker.BaseAsset == base && mt.Maker.QuoteAsset == quote {
+			n++
+		}
+	}
+	return n
+}
+
+// ActiveMatches is the number of matches that are still being negotiated on
+// all markets.
+func (s *Swapper) ActiveMatches() int {
+	s.matchMtx.RLock()
+	defer s.matchMtx.RUnlock()
+	return len(s.matches)
+}
+
+// countFailure counts a match revoked for inaction.
+func (s *Swapper) countFailure(base, quote uint32, status order.MatchStatus) {
+	s.failMtx.Lock()
+	s.failures[failureKey{base, quote, status}]++
+	s.failMtx.Unlock()
+}
+
+// SwapFailures returns the number of matches that were revoked for inaction
+// since startup, by market and match status, sorted by market and status.
+func (s *Swapper) SwapFailures() []*SwapFailures {
+	s.failMtx.Lock()
+	failures := make([]*SwapFailures, 0, len(s.failures))
+	for k, n := range s.failures {
+		failures = append(failures, &SwapFailures{
+			Base:   k.base,
+			Quote:  k.quote,
+			Status: k.status,
+			Count:  n,
+		})
+	}
+	s.failMtx.Unlock()
+	sort.Slice(failures, func(i, j int) bool {
+		fi, fj := failures[i], failures[j]
+		if fi.Base != fj.Base {
+			return fi.Base < fj.Base
+		}
+		if fi.Quote != fj.Quote {
+			return fi.Quote < fj.Quote
+		}
+		return fi.Status < fj.Status
+	})
+	return failures
+}
+
 // Penalize calls Penalize on the AuthManager and penalizes user for breaking rule.
-func (s *Swapper) Penalize(user account.AccountID, rule account.Rule) error {
-	return s.authMgr.Penalize(user, rule)
+func (s *Swapper) Penalize(user account.AccountID, rule account.Rule, details string) error {
+	return s.authMgr.Penalize(user, rule, details)
 }
 
 func idToBytes(id [order.OrderIDSize]byte) []byte {
@@ -14,6 +14,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -113,6 +114,7 @@ type TAuthManager struct {
 	privkey     *secp256k1.PrivateKey
 	reqs        map[account.AccountID][]*TRequest
 	resps       map[account.AccountID][]*msgjson.Message
+	notes       map[account.AccountID][]*msgjson.Message
 	suspensions map[account.AccountID]account.Rule
 }
 
@@ -125,6 +127,7 @@ func newTAuthManager() *TAuthManager {
 		privkey:     dexPrivKey,
 		reqs:        make(map[account.AccountID][]*TRequest),
 		resps:       make(map[account.AccountID][]*msgjson.Message),
+		notes:       make(map[account.AccountID][]*msgjson.Message),
 		suspensions: make(map[account.AccountID]account.Rule),
 	}
 }
@@ -132,6 +135,10 @@ func newTAuthManager() *TAuthManager {
 func (m *TAuthManager) Send(user account.AccountID, msg *msgjson.Message) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
+	if msg.Type == msgjson.Notification {
+		m.notes[user] = append(m.notes[user], msg)
+		return nil
+	}
 	l := m.resps[user]
 	if l == nil {
 		l = make([]*msgjson.Message, 0, 1)
@@ -192,7 +199,7 @@ func (m *TAuthManager) Route(string,
 	func(account.AccountID, *msgjson.Message) *msgjson.Error) {
 }
 
-func (m *TAuthManager) Penalize(id account.AccountID, rule account.Rule) error {
+func (m *TAuthManager) Penalize(id account.AccountID, rule account.Rule, _ string, _ ...order.MatchID) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 	m.suspensions[id] = rule
@@ -253,10 +260,35 @@ func (m *TAuthManager) getResp(id account.AccountID) (*msgjson.Message, *msgjson
 	return msg, resp
 }
 
+// pop front
+func (m *TAuthManager) getNote(id account.AccountID) *msgjson.Message {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	notes := m.notes[id]
+	if len(notes) == 0 {
+		return nil
+	}
+	m.notes[id] = notes[1:]
+	return notes[0]
+}
+
+// getRouteNote pops the user's notifications until one on the route.
+func (m *TAuthManager) getRouteNote(id account.AccountID, route string) *msgjson.Message {
+	for {
+		note := m.getNote(id)
+		if note == nil || note.Route == route {
+			return note
+		}
+	}
+}
+
 type TStorage struct {
 	fatalMtx sync.RWMutex
 	fatal    chan struct{}
 	fatalErr error
+
+	feeMtx    sync.Mutex
+	tradeFees []*db.TradeFee
 }
 
 func (ts *TStorage) LastErr() error {
@@ -317,6 +349,12 @@ func (s *TStorage) SaveRedeemAckSigA(mid db.MarketMatchID, sig []byte) error {
 	return nil
 }
 func (s *TStorage) SetMatchInactive(mid db.MarketMatchID) error { return nil }
+func (s *TStorage) RecordTradeFee(fee *db.TradeFee) error {
+	s.feeMtx.Lock()
+	s.tradeFees = append(s.tradeFees, fee)
+	s.feeMtx.Unlock()
+	return nil
+}
 
 // This stub satisfies asset.Backend.
 type TAsset struct {
@@ -1400,6 +1438,162 @@ func TestSwaps(t *testing.T) {
 	}
 }
 
+func TestTradeFees(t *testing.T) {
+	rig, cleanup := tNewTestRig(nil)
+	defer cleanup()
+	for _, makerSell := range []bool{true, false} {
+		rig.storage.tradeFees = nil
+		// 1 ABC at 2 XYZ/ABC, with a 0.25% trade fee.
+		rig.matches = tPerfectLimitLimit(uint64(1e8), uint64(2e8), makerSell)
+		rig.matches.matchSet.TradeFee = 25
+		rig.swapper.Negotiate([]*order.MatchSet{rig.matches.matchSet}, nil)
+		testSwap(t, rig)
+
+		matchInfo := rig.matches.matchInfos[0]
+		// The seller receives XYZ, and the buyer receives ABC.
+		wantFees := map[account.AccountID]*db.TradeFee{
+			matchInfo.maker.acct: {AssetID: XYZID, Amount: 5e5},
+			matchInfo.taker.acct: {AssetID: ABCID, Amount: 2.5e5},
+		}
+		if !makerSell {
+			wantFees[matchInfo.maker.acct], wantFees[matchInfo.taker.acct] = wantFees[matchInfo.taker.acct], wantFees[matchInfo.maker.acct]
+		}
+		if len(rig.storage.tradeFees) != 2 {
+			t.Fatalf("expected 2 trade fees, got %d", len(rig.storage.tradeFees))
+		}
+		for _, fee := range rig.storage.tradeFees {
+			want := wantFees[fee.AccountID]
+			if want == nil {
+				t.Fatalf("trade fee recorded for unknown account %v", fee.AccountID)
+			}
+			if fee.MatchID != matchInfo.matchID {
+				t.Errorf("wrong trade fee match ID. wanted %v, got %v", matchInfo.matchID, fee.MatchID)
+			}
+			if fee.AssetID != want.AssetID || fee.Amount != want.Amount {
+				t.Errorf("wrong trade fee. wanted %d of asset %d, got %d of asset %d",
+					want.Amount, want.AssetID, fee.Amount, fee.AssetID)
+			}
+		}
+	}
+
+	// No fees are recorded without a trade fee rate.
+	rig.storage.tradeFees = nil
+	rig.matches = tPerfectLimitLimit(uint64(1e8), uint64(2e8), true)
+	rig.swapper.Negotiate([]*order.MatchSet{rig.matches.matchSet}, nil)
+	testSwap(t, rig)
+	if len(rig.storage.tradeFees) != 0 {
+		t.Fatalf("trade fees recorded without a trade fee rate")
+	}
+}
+
+func TestSettlementReceipts(t *testing.T) {
+	rig, cleanup := tNewTestRig(nil)
+	defer cleanup()
+	rig.matches = tPerfectLimitLimit(uint64(1e8), uint64(2e8), true)
+	rig.swapper.Negotiate([]*order.MatchSet{rig.matches.matchSet}, nil)
+	testSwap(t, rig)
+
+	matchInfo := rig.matches.matchInfos[0]
+	for _, user := range []*tUser{matchInfo.maker, matchInfo.taker} {
+		note := rig.auth.getRouteNote(user.acct, msgjson.ReceiptRoute)
+		if note == nil {
+			t.Fatalf("no settlement receipt sent to %s", user.lbl)
+		}
+		if note.Route != msgjson.ReceiptRoute {
+			t.Fatalf("wrong route for %s's receipt: %s", user.lbl, note.Route)
+		}
+		var receipt msgjson.SettlementReceipt
+		if err := note.Unmarshal(&receipt); err != nil {
+			t.Fatalf("error unmarshaling %s's receipt: %v", user.lbl, err)
+		}
+		if err := checkSigS256(&receipt, rig.auth.privkey.PubKey()); err != nil {
+			t.Fatalf("bad signature on %s's receipt: %v", user.lbl, err)
+		}
+		if !bytes.Equal(receipt.MatchID, matchInfo.matchID[:]) ||
+			!bytes.Equal(receipt.MakerOrderID, matchInfo.makerOID[:]) ||
+			!bytes.Equal(receipt.TakerOrderID, matchInfo.takerOID[:]) ||
+			receipt.Quantity != matchInfo.qty || receipt.Rate != matchInfo.rate {
+			t.Fatalf("wrong match details in %s's receipt", user.lbl)
+		}
+		if !bytes.Equal(receipt.MakerSwap, matchInfo.db.makerSwap.coin.ID()) ||
+			!bytes.Equal(receipt.TakerSwap, matchInfo.db.takerSwap.coin.ID()) ||
+			!bytes.Equal(receipt.MakerRedeem, matchInfo.db.makerRedeem.coin.ID()) ||
+			!bytes.Equal(receipt.TakerRedeem, matchInfo.db.takerRedeem.coin.ID()) {
+			t.Fatalf("wrong coin IDs in %s's receipt", user.lbl)
+		}
+	}
+}
+
+func TestSettlementProgress(t *testing.T) {
+	rig, cleanup := tNewTestRig(nil)
+	defer cleanup()
+	ensureNilErr := makeEnsureNilErr(t)
+	sendBlock := func(node *TAsset) {
+		node.bChan <- &asset.BlockUpdate{Err: nil}
+		tickMempool()
+	}
+	rig.matches = tPerfectLimitLimit(uint64(1e8), uint64(2e8), true)
+	matchInfo := rig.matches.matchInfos[0]
+	rig.matchInfo = matchInfo
+	rig.swapper.Negotiate([]*order.MatchSet{rig.matches.matchSet}, nil)
+
+	ensureNilErr(rig.ackMatch_maker(true))
+	ensureNilErr(rig.ackMatch_taker(true))
+	ensureNilErr(rig.sendSwap_maker(true))
+	ensureNilErr(rig.auditSwap_taker())
+	// No progress until the maker's swap is confirmed.
+	sendBlock(rig.abcNode)
+	if note := rig.auth.getRouteNote(matchInfo.maker.acct, msgjson.SettlementProgressRoute); note != nil {
+		t.Fatalf("progress sent for an unconfirmed swap")
+	}
+	matchInfo.db.makerSwap.coin.setConfs(int64(rig.abc.SwapConf))
+	sendBlock(rig.abcNode)
+	ensureNilErr(rig.ackAudit_taker(true))
+	ensureNilErr(rig.sendSwap_taker(true))
+	ensureNilErr(rig.auditSwap_maker())
+	matchInfo.db.takerSwap.coin.setConfs(int64(rig.xyz.SwapConf))
+	sendBlock(rig.xyzNode)
+	ensureNilErr(rig.ackAudit_maker(true))
+	ensureNilErr(rig.redeem_maker(true))
+	ensureNilErr(rig.ackRedemption_taker(true))
+	ensureNilErr(rig.redeem_taker(true))
+	ensureNilErr(rig.ackRedemption_maker(true))
+
+	wants := []struct {
+		milestone string
+		assetID   uint32
+		coinID    []byte
+	}{
+		{msgjson.MakerSwapConfirmed, ABCID, matchInfo.db.makerSwap.coin.ID()},
+		{msgjson.TakerSwapConfirmed, XYZID, matchInfo.db.takerSwap.coin.ID()},
+		{msgjson.MakerRedeemed, XYZID, matchInfo.db.makerRedeem.coin.ID()},
+		{msgjson.TakerRedeemed, ABCID, matchInfo.db.takerRedeem.coin.ID()},
+	}
+	for _, user := range []*tUser{matchInfo.maker, matchInfo.taker} {
+		for _, want := range wants {
+			note := rig.auth.getRouteNote(user.acct, msgjson.SettlementProgressRoute)
+			if note == nil {
+				t.Fatalf("no %s progress sent to %s", want.milestone, user.lbl)
+			}
+			var progress msgjson.SettlementProgress
+			if err := note.Unmarshal(&progress); err != nil {
+				t.Fatalf("error unmarshaling %s's progress: %v", user.lbl, err)
+			}
+			if progress.Milestone != want.milestone {
+				t.Fatalf("wrong milestone for %s. wanted %s, got %s", user.lbl, want.milestone, progress.Milestone)
+			}
+			if !bytes.Equal(progress.MatchID, matchInfo.matchID[:]) ||
+				!bytes.Equal(progress.MakerOrderID, matchInfo.makerOID[:]) ||
+				!bytes.Equal(progress.TakerOrderID, matchInfo.takerOID[:]) {
+				t.Fatalf("wrong match details in %s's %s progress", user.lbl, want.milestone)
+			}
+			if progress.AssetID != want.assetID || !bytes.Equal(progress.CoinID, want.coinID) {
+				t.Fatalf("wrong coin in %s's %s progress", user.lbl, want.milestone)
+			}
+		}
+	}
+}
+
 func TestNoAck(t *testing.T) {
 	set := tPerfectLimitLimit(uint64(1e8), uint64(1e8), true)
 	matchInfo := set.matchInfos[0]
@@ -1695,6 +1889,18 @@ func TestBroadcastTimeouts(t *testing.T) {
 		}
 		return
 	}
+
+	// Two revocations at each status should have been counted.
+	failures := rig.swapper.SwapFailures()
+	statuses := []order.MatchStatus{order.NewlyMatched, order.MakerSwapCast, order.TakerSwapCast, order.MakerRedeemed}
+	if len(failures) != len(statuses) {
+		t.Fatalf("expected %d failure counts, got %d", len(statuses), len(failures))
+	}
+	for i, f := range failures {
+		if f.Base != ABCID || f.Quote != XYZID || f.Status != statuses[i] || f.Count != 2 {
+			t.Fatalf("wrong failure count %d: %+v", i, f)
+		}
+	}
 }
 
 func TestSigErrors(t *testing.T) {
@@ -2425,3 +2631,183 @@ func TestState(t *testing.T) {
 		t.Fatalf("expected matchTracker to be removed")
 	}
 }
+
+func TestCheckpoint(t *testing.T) {
+	set := tPerfectLimitLimit(uint64(1e8), uint64(1e8), true)
+	matchInfo := set.matchInfos[0]
+	rig, cleanup := tNewTestRig(matchInfo)
+	defer cleanup()
+	rig.swapper.Negotiate([]*order.MatchSet{set.matchSet}, nil)
+
+	countStateFiles := func() int {
+		fileNames, err := filepath.Glob(filepath.Join(rig.swapDataDir, "swapState-*"))
+		if err != nil {
+			t.Fatalf("Glob error: %v", err)
+		}
+		return len(fileNames)
+	}
+
+	rig.swapper.checkpoint()
+	time.Sleep(2 * time.Millisecond) // unique ms stamps
+	rig.swapper.checkpoint()
+	if n := countStateFiles(); n != 1 {
+		t.Fatalf("expected 1 checkpoint file, found %d", n)
+	}
+
+	stateFile, err := LatestStateFile(rig.swapDataDir)
+	if err != nil {
+		t.Fatalf("LatestStateFile error: %v", err)
+	}
+	if stateFile == nil || filepath.Base(stateFile.Name) != rig.swapper.lastCheckpoint {
+		t.Fatalf("latest state file is not the last checkpoint")
+	}
+	state, err := LoadStateFile(stateFile.Name)
+	if err != nil {
+		t.Fatalf("LoadStateFile error: %v", err)
+	}
+	if len(state.MatchTrackers) != 1 {
+		t.Fatalf("expected 1 match in checkpoint, got %d", len(state.MatchTrackers))
+	}
+	if len(state.LiveAckers) == 0 {
+		t.Fatalf("expected live ackers in checkpoint")
+	}
+}
+
+func TestMarketMatches(t *testing.T) {
+	set := tPerfectLimitLimit(uint64(1e8), uint64(1e8), true)
+	rig, cleanup := tNewTestRig(set.matchInfos[0])
+	defer cleanup()
+	if n := rig.swapper.MarketMatches(ABCID, XYZID); n != 0 {
+		t.Fatalf("expected no matches, got %d", n)
+	}
+	rig.swapper.Negotiate([]*order.MatchSet{set.matchSet}, nil)
+	if n := rig.swapper.MarketMatches(ABCID, XYZID); n != 1 {
+		t.Fatalf("expected 1 match, got %d", n)
+	}
+	if n := rig.swapper.MarketMatches(XYZID, ABCID); n != 0 {
+		t.Fatalf("expected no matches on the inverted market, got %d", n)
+	}
+}
+
+func TestMarketState(t *testing.T) {
+	set := tPerfectLimitLimit(uint64(1e8), uint64(1e8), true)
+	rig, cleanup := tNewTestRig(set.matchInfos[0])
+	defer cleanup()
+	rig.swapper.Negotiate([]*order.MatchSet{set.matchSet}, nil)
+
+	b, n, err := rig.swapper.MarketState(ABCID, XYZID)
+	if err != nil {
+		t.Fatalf("MarketState error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 match, got %d", n)
+	}
+	state, err := DecodeState(b)
+	if err != nil {
+		t.Fatalf("DecodeState error: %v", err)
+	}
+	if len(state.MatchTrackers) != 1 || len(state.OrderMatches) != 2 {
+		t.Fatalf("expected 1 match tracker and 2 order stats, got %d and %d",
+			len(state.MatchTrackers), len(state.OrderMatches))
+	}
+
+	// The inverted market has no matches.
+	b, n, err = rig.swapper.MarketState(XYZID, ABCID)
+	if err != nil {
+		t.Fatalf("MarketState error: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no matches on the inverted market, got %d", n)
+	}
+	empty, err := DecodeState(b)
+	if err != nil {
+		t.Fatalf("DecodeState error: %v", err)
+	}
+
+	// Merging does not duplicate or replace existing entries.
+	merged := MergeState(MergeState(empty, state), state)
+	if len(merged.MatchTrackers) != 1 || len(merged.OrderMatches) != 2 {
+		t.Fatalf("expected 1 merged match tracker and 2 order stats, got %d and %d",
+			len(merged.MatchTrackers), len(merged.OrderMatches))
+	}
+}
+
+// TBatchAsset is a TAsset that checks confirmations in batches.
+type TBatchAsset struct {
+	*TAsset
+	batches int
+}
+
+func (a *TBatchAsset) BatchConfirmations(coins []asset.Coin) ([]int64, []error) {
+	a.batches++
+	confs := make([]int64, len(coins))
+	errs := make([]error, len(coins))
+	for i, coin := range coins {
+		confs[i], errs[i] = coin.Confirmations()
+	}
+	return confs, errs
+}
+
+func TestConfirmSwaps(t *testing.T) {
+	batchBackend := &TBatchAsset{TAsset: newTAsset("abc")}
+	s := &Swapper{
+		coins: map[uint32]*LockableAsset{
+			ABCID: {TNewAsset(batchBackend), coinlock.NewAssetCoinLocker()},
+			XYZID: {TNewAsset(newTAsset("xyz")), coinlock.NewAssetCoinLocker()},
+		},
+	}
+
+	// Some swaps with enough confirmations, one without, one that has become
+	// invalid, one already confirmed, and one not yet broadcast.
+	newStatuses := func(assetID uint32) (confirmed, unconfirmed []*swapStatus) {
+		now := time.Now()
+		for i := 0; i < 2*confirmWorkers; i++ {
+			status := &swapStatus{
+				swapAsset: assetID,
+				swapTime:  now,
+				swap:      &TCoin{confs: 2},
+			}
+			confirmed = append(confirmed, status)
+		}
+		unconfirmed = []*swapStatus{
+			{swapAsset: assetID, swapTime: now, swap: &TCoin{confs: 1}},
+			{swapAsset: assetID, swapTime: now, swap: &TCoin{confs: 2, confsErr: errors.New("invalid")}},
+			{swapAsset: assetID},
+		}
+		return
+	}
+	check := func(assetID uint32) {
+		t.Helper()
+		confirmed, unconfirmed := newStatuses(assetID)
+		earlier := time.Now().Add(-time.Minute)
+		alreadyConfirmed := &swapStatus{
+			swapAsset:     assetID,
+			swapTime:      earlier,
+			swapConfirmed: earlier,
+			swap:          &TCoin{confs: 2},
+		}
+		statuses := append(append(confirmed, unconfirmed...), alreadyConfirmed)
+		if newlyConfirmed := s.confirmSwaps(assetID, statuses); len(newlyConfirmed) != len(confirmed) {
+			t.Fatalf("expected %d newly confirmed swaps, got %d", len(confirmed), len(newlyConfirmed))
+		}
+		for i, status := range confirmed {
+			if status.swapConfirmed.IsZero() {
+				t.Fatalf("swap %d not confirmed", i)
+			}
+		}
+		for i, status := range unconfirmed {
+			if !status.swapConfirmed.IsZero() {
+				t.Fatalf("swap %d confirmed", i)
+			}
+		}
+		if !alreadyConfirmed.swapConfirmed.Equal(earlier) {
+			t.Fatalf("confirmation time changed")
+		}
+	}
+
+	check(ABCID)
+	if batchBackend.batches != 1 {
+		t.Fatalf("expected 1 batch, got %d", batchBackend.batches)
+	}
+	check(XYZID)
+}
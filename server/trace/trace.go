@@ -0,0 +1,244 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+// Package trace records the log events of inbound client requests under a
+// correlation ID, so that a request can be followed across the comms, market,
+// swap and db subsystems. Each request is assigned a correlation ID when it is
+// received. Order and match IDs are linked to the correlation ID of the
+// request that created them, and the events logged later for an order or match
+// are recorded under the linked correlation IDs. The most recent events are
+// retained in memory for the admin API.
+//
+// A Tracer is created by the DEX and given to each subsystem in its config. A
+// nil *Tracer is valid, and does nothing, so tracing costs nothing when it is
+// disabled.
+package trace
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/msgjson"
+	"github.com/decred/slog"
+)
+
+// ID is a correlation ID.
+type ID string
+
+// NewID generates a random correlation ID.
+func NewID() ID {
+	return ID(hex.EncodeToString(encode.RandomBytes(8)))
+}
+
+// Event is a log event recorded for the correlation IDs of one or more
+// requests. Key is the order or match ID the event was logged for, if any.
+type Event struct {
+	Time      time.Time `json:"time"`
+	IDs       []ID      `json:"ids"`
+	Key       string    `json:"key,omitempty"`
+	Subsystem string    `json:"subsystem"`
+	Msg       string    `json:"msg"`
+}
+
+// Tracer records the events of client requests. The methods of a nil *Tracer
+// do nothing.
+type Tracer struct {
+	maxEvents int
+
+	mtx       sync.RWMutex
+	events    []*Event
+	next      int // the oldest event, once events is full
+	links     map[string][]ID
+	linkOrder []string
+	active    map[*msgjson.Message]ID
+}
+
+// NewTracer creates a Tracer that retains the maxEvents most recent events,
+// and the links of the maxEvents most recent order and match IDs. If maxEvents
+// is zero, tracing is disabled and nil is returned.
+func NewTracer(maxEvents int) *Tracer {
+	if maxEvents <= 0 {
+		return nil
+	}
+	return &Tracer{
+		maxEvents: maxEvents,
+		events:    make([]*Event, 0, 1024),
+		links:     make(map[string][]ID),
+		active:    make(map[*msgjson.Message]ID),
+	}
+}
+
+// Begin assigns a new correlation ID to an inbound request. End must be called
+// when the request has been handled.
+func (t *Tracer) Begin(msg *msgjson.Message) ID {
+	if t == nil {
+		return ""
+	}
+	id := NewID()
+	t.mtx.Lock()
+	t.active[msg] = id
+	t.mtx.Unlock()
+	return id
+}
+
+// End releases the correlation ID of a request.
+func (t *Tracer) End(msg *msgjson.Message) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	delete(t.active, msg)
+	t.mtx.Unlock()
+}
+
+// Of returns the correlation ID of a request that is being handled, or an
+// empty ID if the request is unknown.
+func (t *Tracer) Of(msg *msgjson.Message) ID {
+	if t == nil {
+		return ""
+	}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.active[msg]
+}
+
+// Link links the keys, e.g. order IDs, to the correlation ID, so that the
+// events logged for the keys are recorded under the correlation ID.
+func (t *Tracer) Link(id ID, keys ...string) {
+	if t == nil || id == "" {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, key := range keys {
+		t.link(key, id)
+	}
+}
+
+// LinkKey links the key, e.g. a match ID, to the correlation IDs that are
+// linked to each of the existing keys, e.g. the IDs of the matched orders.
+func (t *Tracer) LinkKey(key string, existing ...string) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, ex := range existing {
+		for _, id := range t.links[ex] {
+			t.link(key, id)
+		}
+	}
+}
+
+// link links the key to the correlation ID. The mtx must be locked.
+func (t *Tracer) link(key string, id ID) {
+	ids, found := t.links[key]
+	if !found {
+		t.linkOrder = append(t.linkOrder, key)
+		if len(t.linkOrder) > t.maxEvents {
+			delete(t.links, t.linkOrder[0])
+			t.linkOrder = t.linkOrder[1:]
+		}
+	}
+	for _, linked := range ids {
+		if linked == id {
+			return
+		}
+	}
+	t.links[key] = append(ids, id)
+}
+
+// Logf logs a debug message prefixed with the correlation ID, and records the
+// event for the request.
+func (t *Tracer) Logf(log slog.Logger, subsys string, id ID, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	log.Debugf("[%s] %s", id, msg)
+	var ids []ID
+	if id != "" {
+		ids = []ID{id}
+	}
+	t.record(&Event{
+		Time:      time.Now(),
+		IDs:       ids,
+		Subsystem: subsys,
+		Msg:       msg,
+	})
+}
+
+// KeyLogf logs a debug message prefixed with the correlation IDs linked to the
+// key, and records the event for those requests and the key.
+func (t *Tracer) KeyLogf(log slog.Logger, subsys, key string, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	t.mtx.RLock()
+	ids := append([]ID(nil), t.links[key]...)
+	t.mtx.RUnlock()
+	strIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strIDs = append(strIDs, string(id))
+	}
+	log.Debugf("[%s] %s: %s", strings.Join(strIDs, ","), key, msg)
+	t.record(&Event{
+		Time:      time.Now(),
+		IDs:       ids,
+		Key:       key,
+		Subsystem: subsys,
+		Msg:       msg,
+	})
+}
+
+// record stores the event, dropping the oldest event if there are more than
+// maxEvents.
+func (t *Tracer) record(e *Event) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if len(t.events) < t.maxEvents {
+		t.events = append(t.events, e)
+		return
+	}
+	t.events[t.next] = e
+	t.next = (t.next + 1) % t.maxEvents
+}
+
+// Query returns the retained events, oldest first, for the query, which may be
+// a correlation ID, or an order or match ID. The events of an order or match
+// include the events of the requests linked to it. A nil Tracer has no events.
+func (t *Tracer) Query(q string) []*Event {
+	found := make([]*Event, 0)
+	if t == nil {
+		return found
+	}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	ids := map[ID]bool{ID(q): true}
+	for _, id := range t.links[q] {
+		ids[id] = true
+	}
+	matches := func(e *Event) bool {
+		if e.Key == q {
+			return true
+		}
+		for _, id := range e.IDs {
+			if ids[id] {
+				return true
+			}
+		}
+		return false
+	}
+	for i := range t.events {
+		e := t.events[(t.next+i)%len(t.events)]
+		if matches(e) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
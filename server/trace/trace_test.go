@@ -0,0 +1,96 @@
+// This code is available on the terms of the project LICENSE.md file,
+// also available online at https://blueoakcouncil.org/license/1.0.0.
+
+package trace
+
+import (
+	"testing"
+
+	"decred.org/dcrdex/dex/msgjson"
+	"github.com/decred/slog"
+)
+
+func TestTrace(t *testing.T) {
+	log := slog.Disabled
+	tr := NewTracer(100)
+	msg, _ := msgjson.NewRequest(1, "limit", nil)
+	id := tr.Begin(msg)
+	if tr.Of(msg) != id {
+		t.Fatalf("wrong correlation ID for request")
+	}
+	tr.Logf(log, "COMM", id, "received %s request", msg.Route)
+	tr.Link(tr.Of(msg), "order1")
+	tr.KeyLogf(log, "MKT", "order1", "order accepted")
+	tr.End(msg)
+	if tr.Of(msg) != "" {
+		t.Fatalf("correlation ID not released")
+	}
+
+	// A second request for the counterparty order.
+	msg2, _ := msgjson.NewRequest(2, "limit", nil)
+	id2 := tr.Begin(msg2)
+	tr.Link(id2, "order2")
+	tr.End(msg2)
+
+	// The match is linked to both requests.
+	tr.LinkKey("match1", "order1", "order2")
+	tr.KeyLogf(log, "SWAP", "match1", "match created")
+	// An event for an unlinked order.
+	tr.KeyLogf(log, "MKT", "order3", "order booked")
+
+	events := tr.Query(string(id))
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events for the first request, got %d", len(events))
+	}
+	if events[0].Subsystem != "COMM" || events[1].Key != "order1" || events[2].Key != "match1" {
+		t.Fatalf("unexpected events %+v, %+v, %+v", events[0], events[1], events[2])
+	}
+	if events = tr.Query(string(id2)); len(events) != 1 || events[0].Key != "match1" {
+		t.Fatalf("expected the match event for the second request, got %d events", len(events))
+	}
+	if events = tr.Query("order1"); len(events) != 3 {
+		t.Fatalf("expected 3 events for the order, got %d", len(events))
+	}
+	// The match's events include the events of both requests.
+	if events = tr.Query("match1"); len(events) != 3 || len(events[2].IDs) != 2 {
+		t.Fatalf("expected 3 events for the match, got %d", len(events))
+	}
+	if events = tr.Query("order3"); len(events) != 1 || len(events[0].IDs) != 0 {
+		t.Fatalf("expected 1 untraced event for the unlinked order, got %d", len(events))
+	}
+	if events = tr.Query("unknown"); len(events) != 0 {
+		t.Fatalf("expected no events for an unknown ID, got %d", len(events))
+	}
+}
+
+func TestDisabledTracer(t *testing.T) {
+	log := slog.Disabled
+	if NewTracer(0) != nil {
+		t.Fatalf("tracer created with no events")
+	}
+	var tr *Tracer
+	msg, _ := msgjson.NewRequest(1, "limit", nil)
+	id := tr.Begin(msg)
+	if id != "" || tr.Of(msg) != "" {
+		t.Fatalf("correlation ID assigned by a disabled tracer")
+	}
+	tr.Logf(log, "COMM", id, "received %s request", msg.Route)
+	tr.Link(NewID(), "order1")
+	tr.KeyLogf(log, "MKT", "order1", "order accepted")
+	tr.End(msg)
+	if events := tr.Query("order1"); len(events) != 0 {
+		t.Fatalf("events recorded by a disabled tracer")
+	}
+}
+
+func TestTracerRing(t *testing.T) {
+	log := slog.Disabled
+	tr := NewTracer(2)
+	for i := 0; i < 3; i++ {
+		tr.KeyLogf(log, "MKT", "order1", "event %d", i)
+	}
+	events := tr.Query("order1")
+	if len(events) != 2 || events[0].Msg != "event 1" || events[1].Msg != "event 2" {
+		t.Fatalf("wrong retained events %+v", events)
+	}
+}